@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
@@ -41,18 +42,18 @@ func firstFieldPreview(fieldMap map[string]string) string {
 	return ""
 }
 
-func (s *SQLiteStore) CreateUser(user *User) error {
+func (s *SQLStore) CreateUser(ctx context.Context, user *User) error {
 	query := `
 		INSERT INTO users (id, email, display_name, avatar_url, onboarding, last_login_at, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := s.db.Exec(query, user.ID, user.Email, user.DisplayName, user.AvatarURL, boolToInt(user.Onboarding), nullIfZeroTime(user.LastLoginAt), user.CreatedAt.Unix(), user.UpdatedAt.Unix())
+	_, err := s.db.ExecContext(ctx, query, user.ID, user.Email, user.DisplayName, user.AvatarURL, boolToInt(user.Onboarding), nullIfZeroTime(user.LastLoginAt), user.CreatedAt.Unix(), user.UpdatedAt.Unix())
 	return err
 }
 
-func (s *SQLiteStore) GetUserByID(id string) (*User, error) {
+func (s *SQLStore) GetUserByID(ctx context.Context, id string) (*User, error) {
 	query := `SELECT id, email, display_name, avatar_url, onboarding, last_login_at, created_at, updated_at FROM users WHERE id = ?`
-	row := s.db.QueryRow(query, id)
+	row := s.db.QueryRowContext(ctx, query, id)
 
 	var user User
 	var avatar sql.NullString
@@ -78,25 +79,25 @@ func (s *SQLiteStore) GetUserByID(id string) (*User, error) {
 	return &user, nil
 }
 
-func (s *SQLiteStore) GetUserByEmail(email string) (*User, error) {
+func (s *SQLStore) GetUserByEmail(ctx context.Context, email string) (*User, error) {
 	query := `SELECT id FROM users WHERE lower(email) = lower(?)`
 	var userID string
-	if err := s.db.QueryRow(query, email).Scan(&userID); err != nil {
+	if err := s.db.QueryRowContext(ctx, query, email).Scan(&userID); err != nil {
 		return nil, err
 	}
-	return s.GetUserByID(userID)
+	return s.GetUserByID(ctx, userID)
 }
 
-func (s *SQLiteStore) GetUserByOAuth(provider, subject string) (*User, error) {
+func (s *SQLStore) GetUserByOAuth(ctx context.Context, provider, subject string) (*User, error) {
 	query := `SELECT user_id FROM oauth_identities WHERE provider = ? AND subject = ?`
 	var userID string
-	if err := s.db.QueryRow(query, provider, subject).Scan(&userID); err != nil {
+	if err := s.db.QueryRowContext(ctx, query, provider, subject).Scan(&userID); err != nil {
 		return nil, err
 	}
-	return s.GetUserByID(userID)
+	return s.GetUserByID(ctx, userID)
 }
 
-func (s *SQLiteStore) UpsertOAuthIdentity(identity *OAuthIdentity) error {
+func (s *SQLStore) UpsertOAuthIdentity(ctx context.Context, identity *OAuthIdentity) error {
 	query := `
 		INSERT INTO oauth_identities (id, user_id, provider, subject, email, created_at)
 		VALUES (?, ?, ?, ?, ?, ?)
@@ -104,16 +105,16 @@ func (s *SQLiteStore) UpsertOAuthIdentity(identity *OAuthIdentity) error {
 			user_id = excluded.user_id,
 			email = excluded.email
 	`
-	_, err := s.db.Exec(query, identity.ID, identity.UserID, identity.Provider, identity.Subject, identity.Email, identity.CreatedAt.Unix())
+	_, err := s.db.ExecContext(ctx, query, identity.ID, identity.UserID, identity.Provider, identity.Subject, identity.Email, identity.CreatedAt.Unix())
 	return err
 }
 
-func (s *SQLiteStore) CreateWorkspaceRecord(workspace *Workspace) error {
+func (s *SQLStore) CreateWorkspaceRecord(ctx context.Context, workspace *Workspace) error {
 	query := `
 		INSERT INTO workspaces (id, name, slug, collection_id, owner_user_id, organization_id, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := s.db.Exec(
+	_, err := s.db.ExecContext(ctx,
 		query,
 		workspace.ID,
 		workspace.Name,
@@ -127,8 +128,8 @@ func (s *SQLiteStore) CreateWorkspaceRecord(workspace *Workspace) error {
 	return err
 }
 
-func (s *SQLiteStore) UpdateWorkspaceRecord(workspace *Workspace) error {
-	_, err := s.db.Exec(
+func (s *SQLStore) UpdateWorkspaceRecord(ctx context.Context, workspace *Workspace) error {
+	_, err := s.db.ExecContext(ctx,
 		`UPDATE workspaces SET name = ?, slug = ?, collection_id = ?, owner_user_id = ?, organization_id = ?, updated_at = ? WHERE id = ?`,
 		workspace.Name,
 		workspace.Slug,
@@ -141,12 +142,12 @@ func (s *SQLiteStore) UpdateWorkspaceRecord(workspace *Workspace) error {
 	return err
 }
 
-func (s *SQLiteStore) GetWorkspaceRecord(id string) (*Workspace, error) {
+func (s *SQLStore) GetWorkspaceRecord(ctx context.Context, id string) (*Workspace, error) {
 	query := `
 		SELECT id, name, slug, collection_id, owner_user_id, organization_id, created_at, updated_at
 		FROM workspaces WHERE id = ?
 	`
-	row := s.db.QueryRow(query, id)
+	row := s.db.QueryRowContext(ctx, query, id)
 
 	var workspace Workspace
 	var ownerID, orgID sql.NullString
@@ -175,34 +176,34 @@ func (s *SQLiteStore) GetWorkspaceRecord(id string) (*Workspace, error) {
 	return &workspace, nil
 }
 
-func (s *SQLiteStore) GetFirstWorkspaceForUser(userID string) (*Workspace, error) {
+func (s *SQLStore) GetFirstWorkspaceForUser(ctx context.Context, userID string) (*Workspace, error) {
 	query := `SELECT id FROM workspaces WHERE owner_user_id = ? ORDER BY created_at ASC LIMIT 1`
 	var workspaceID string
-	if err := s.db.QueryRow(query, userID).Scan(&workspaceID); err != nil {
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&workspaceID); err != nil {
 		return nil, err
 	}
-	return s.GetWorkspaceRecord(workspaceID)
+	return s.GetWorkspaceRecord(ctx, workspaceID)
 }
 
-func (s *SQLiteStore) CountWorkspacesForUser(userID string) (int, error) {
+func (s *SQLStore) CountWorkspacesForUser(ctx context.Context, userID string) (int, error) {
 	query := `SELECT COUNT(*) FROM workspaces WHERE owner_user_id = ?`
 	var count int
-	err := s.db.QueryRow(query, userID).Scan(&count)
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&count)
 	return count, err
 }
 
-func (s *SQLiteStore) CreateOrganizationRecord(org *Organization) error {
+func (s *SQLStore) CreateOrganizationRecord(ctx context.Context, org *Organization) error {
 	query := `
 		INSERT INTO organizations (id, name, slug, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?)
 	`
-	_, err := s.db.Exec(query, org.ID, org.Name, org.Slug, org.CreatedAt.Unix(), org.UpdatedAt.Unix())
+	_, err := s.db.ExecContext(ctx, query, org.ID, org.Name, org.Slug, org.CreatedAt.Unix(), org.UpdatedAt.Unix())
 	return err
 }
 
-func (s *SQLiteStore) GetOrganizationRecord(id string) (*Organization, error) {
+func (s *SQLStore) GetOrganizationRecord(ctx context.Context, id string) (*Organization, error) {
 	query := `SELECT id, name, slug, created_at, updated_at FROM organizations WHERE id = ?`
-	row := s.db.QueryRow(query, id)
+	row := s.db.QueryRowContext(ctx, query, id)
 
 	var org Organization
 	var createdAt, updatedAt int64
@@ -214,22 +215,22 @@ func (s *SQLiteStore) GetOrganizationRecord(id string) (*Organization, error) {
 	return &org, nil
 }
 
-func (s *SQLiteStore) UpdateOrganizationRecord(org *Organization) error {
-	_, err := s.db.Exec(`UPDATE organizations SET name = ?, slug = ?, updated_at = ? WHERE id = ?`, org.Name, org.Slug, org.UpdatedAt.Unix(), org.ID)
+func (s *SQLStore) UpdateOrganizationRecord(ctx context.Context, org *Organization) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE organizations SET name = ?, slug = ?, updated_at = ? WHERE id = ?`, org.Name, org.Slug, org.UpdatedAt.Unix(), org.ID)
 	return err
 }
 
-func (s *SQLiteStore) DeleteOrganizationRecord(id string) error {
-	_, err := s.db.Exec(`DELETE FROM organizations WHERE id = ?`, id)
+func (s *SQLStore) DeleteOrganizationRecord(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM organizations WHERE id = ?`, id)
 	return err
 }
 
-func (s *SQLiteStore) CreateOrganizationMemberRecord(member *OrganizationMember) error {
+func (s *SQLStore) CreateOrganizationMemberRecord(ctx context.Context, member *OrganizationMember) error {
 	query := `
 		INSERT INTO organization_members (id, organization_id, user_id, email, role, status, invite_token, invite_expires_at, joined_at, removed_at, created_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := s.db.Exec(
+	_, err := s.db.ExecContext(ctx,
 		query,
 		member.ID,
 		member.OrganizationID,
@@ -279,8 +280,8 @@ func scanOrganizationMemberRow(scanner interface{ Scan(dest ...any) error }) (*O
 	return &member, nil
 }
 
-func (s *SQLiteStore) GetOrganizationMember(id string) (*OrganizationMember, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetOrganizationMember(ctx context.Context, id string) (*OrganizationMember, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, organization_id, user_id, email, role, status, invite_token, invite_expires_at, joined_at, removed_at, created_at
 		FROM organization_members
 		WHERE id = ?
@@ -288,8 +289,8 @@ func (s *SQLiteStore) GetOrganizationMember(id string) (*OrganizationMember, err
 	return scanOrganizationMemberRow(row)
 }
 
-func (s *SQLiteStore) GetOrganizationMemberByUser(orgID, userID string) (*OrganizationMember, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetOrganizationMemberByUser(ctx context.Context, orgID, userID string) (*OrganizationMember, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, organization_id, user_id, email, role, status, invite_token, invite_expires_at, joined_at, removed_at, created_at
 		FROM organization_members
 		WHERE organization_id = ? AND user_id = ?
@@ -297,8 +298,8 @@ func (s *SQLiteStore) GetOrganizationMemberByUser(orgID, userID string) (*Organi
 	return scanOrganizationMemberRow(row)
 }
 
-func (s *SQLiteStore) GetOrganizationMemberByEmail(orgID, email string) (*OrganizationMember, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetOrganizationMemberByEmail(ctx context.Context, orgID, email string) (*OrganizationMember, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, organization_id, user_id, email, role, status, invite_token, invite_expires_at, joined_at, removed_at, created_at
 		FROM organization_members
 		WHERE organization_id = ? AND lower(email) = lower(?)
@@ -306,8 +307,8 @@ func (s *SQLiteStore) GetOrganizationMemberByEmail(orgID, email string) (*Organi
 	return scanOrganizationMemberRow(row)
 }
 
-func (s *SQLiteStore) GetOrganizationMemberByInviteToken(token string) (*OrganizationMember, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetOrganizationMemberByInviteToken(ctx context.Context, token string) (*OrganizationMember, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, organization_id, user_id, email, role, status, invite_token, invite_expires_at, joined_at, removed_at, created_at
 		FROM organization_members
 		WHERE invite_token = ?
@@ -315,8 +316,8 @@ func (s *SQLiteStore) GetOrganizationMemberByInviteToken(token string) (*Organiz
 	return scanOrganizationMemberRow(row)
 }
 
-func (s *SQLiteStore) ListOrganizationMembers(orgID string) ([]OrganizationMember, error) {
-	rows, err := s.db.Query(`
+func (s *SQLStore) ListOrganizationMembers(ctx context.Context, orgID string) ([]OrganizationMember, error) {
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT id, organization_id, user_id, email, role, status, invite_token, invite_expires_at, joined_at, removed_at, created_at
 		FROM organization_members
 		WHERE organization_id = ?
@@ -338,8 +339,8 @@ func (s *SQLiteStore) ListOrganizationMembers(orgID string) ([]OrganizationMembe
 	return members, rows.Err()
 }
 
-func (s *SQLiteStore) UpdateOrganizationMember(member *OrganizationMember) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) UpdateOrganizationMember(ctx context.Context, member *OrganizationMember) error {
+	_, err := s.db.ExecContext(ctx, `
 		UPDATE organization_members
 		SET user_id = ?, role = ?, status = ?, invite_token = ?, invite_expires_at = ?, joined_at = ?, removed_at = ?
 		WHERE id = ?
@@ -356,8 +357,8 @@ func (s *SQLiteStore) UpdateOrganizationMember(member *OrganizationMember) error
 	return err
 }
 
-func (s *SQLiteStore) UpsertOrganizationInvitation(member *OrganizationMember) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) UpsertOrganizationInvitation(ctx context.Context, member *OrganizationMember) error {
+	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO organization_members (
 			id, organization_id, user_id, email, role, status, invite_token, invite_expires_at, joined_at, removed_at, created_at
 		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
@@ -383,8 +384,8 @@ func (s *SQLiteStore) UpsertOrganizationInvitation(member *OrganizationMember) e
 	return err
 }
 
-func (s *SQLiteStore) ListOrganizationsForUser(userID string) ([]Organization, error) {
-	rows, err := s.db.Query(`
+func (s *SQLStore) ListOrganizationsForUser(ctx context.Context, userID string) ([]Organization, error) {
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT o.id, o.name, o.slug, o.created_at, o.updated_at
 		FROM organizations o
 		JOIN organization_members om ON om.organization_id = o.id
@@ -410,8 +411,8 @@ func (s *SQLiteStore) ListOrganizationsForUser(userID string) ([]Organization, e
 	return organizations, rows.Err()
 }
 
-func (s *SQLiteStore) GetWorkspaceForOrganization(orgID string) (*Workspace, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetWorkspaceForOrganization(ctx context.Context, orgID string) (*Workspace, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, name, slug, collection_id, owner_user_id, organization_id, created_at, updated_at
 		FROM workspaces
 		WHERE organization_id = ?
@@ -421,12 +422,12 @@ func (s *SQLiteStore) GetWorkspaceForOrganization(orgID string) (*Workspace, err
 	return scanWorkspaceRow(row)
 }
 
-func (s *SQLiteStore) CreateSessionRecord(session *SessionRecord) error {
+func (s *SQLStore) CreateSessionRecord(ctx context.Context, session *SessionRecord) error {
 	query := `
 		INSERT INTO sessions (id, user_id, workspace_id, plan, guest, expires_at, last_seen_at, created_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := s.db.Exec(
+	_, err := s.db.ExecContext(ctx,
 		query,
 		session.ID,
 		session.UserID,
@@ -440,9 +441,9 @@ func (s *SQLiteStore) CreateSessionRecord(session *SessionRecord) error {
 	return err
 }
 
-func (s *SQLiteStore) GetSessionRecord(id string) (*SessionRecord, error) {
+func (s *SQLStore) GetSessionRecord(ctx context.Context, id string) (*SessionRecord, error) {
 	query := `SELECT id, user_id, workspace_id, plan, guest, expires_at, last_seen_at, created_at FROM sessions WHERE id = ?`
-	row := s.db.QueryRow(query, id)
+	row := s.db.QueryRowContext(ctx, query, id)
 
 	var session SessionRecord
 	var workspaceID sql.NullString
@@ -470,13 +471,13 @@ func (s *SQLiteStore) GetSessionRecord(id string) (*SessionRecord, error) {
 	return &session, nil
 }
 
-func (s *SQLiteStore) DeleteSessionRecord(id string) error {
-	_, err := s.db.Exec(`DELETE FROM sessions WHERE id = ?`, id)
+func (s *SQLStore) DeleteSessionRecord(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE id = ?`, id)
 	return err
 }
 
-func (s *SQLiteStore) TouchSessionRecord(id string, expiresAt, lastSeenAt time.Time) error {
-	_, err := s.db.Exec(
+func (s *SQLStore) TouchSessionRecord(ctx context.Context, id string, expiresAt, lastSeenAt time.Time) error {
+	_, err := s.db.ExecContext(ctx,
 		`UPDATE sessions SET expires_at = ?, last_seen_at = ? WHERE id = ?`,
 		expiresAt.Unix(),
 		lastSeenAt.Unix(),
@@ -485,22 +486,22 @@ func (s *SQLiteStore) TouchSessionRecord(id string, expiresAt, lastSeenAt time.T
 	return err
 }
 
-func (s *SQLiteStore) UpdateSessionWorkspace(id, workspaceID string) error {
-	_, err := s.db.Exec(`UPDATE sessions SET workspace_id = ? WHERE id = ?`, nullIfEmpty(workspaceID), id)
+func (s *SQLStore) UpdateSessionWorkspace(ctx context.Context, id, workspaceID string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE sessions SET workspace_id = ? WHERE id = ?`, nullIfEmpty(workspaceID), id)
 	return err
 }
 
-func (s *SQLiteStore) UpdateUserLastLogin(userID string, at time.Time) error {
-	_, err := s.db.Exec(`UPDATE users SET last_login_at = ?, updated_at = ? WHERE id = ?`, at.Unix(), at.Unix(), userID)
+func (s *SQLStore) UpdateUserLastLogin(ctx context.Context, userID string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE users SET last_login_at = ?, updated_at = ? WHERE id = ?`, at.Unix(), at.Unix(), userID)
 	return err
 }
 
-func (s *SQLiteStore) UpdateUserOnboarding(userID string, onboarding bool) error {
-	_, err := s.db.Exec(`UPDATE users SET onboarding = ?, updated_at = ? WHERE id = ?`, boolToInt(onboarding), time.Now().Unix(), userID)
+func (s *SQLStore) UpdateUserOnboarding(ctx context.Context, userID string, onboarding bool) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE users SET onboarding = ?, updated_at = ? WHERE id = ?`, boolToInt(onboarding), time.Now().Unix(), userID)
 	return err
 }
 
-func (s *SQLiteStore) UpsertSubscription(subscription *Subscription) error {
+func (s *SQLStore) UpsertSubscription(ctx context.Context, subscription *Subscription) error {
 	if subscription.BilledQuantity <= 0 {
 		subscription.BilledQuantity = 1
 	}
@@ -525,7 +526,7 @@ func (s *SQLiteStore) UpsertSubscription(subscription *Subscription) error {
 			billed_quantity = excluded.billed_quantity,
 			updated_at = excluded.updated_at
 	`
-	_, err := s.db.Exec(
+	_, err := s.db.ExecContext(ctx,
 		query,
 		subscription.ID,
 		nullIfEmpty(subscription.WorkspaceID),
@@ -547,7 +548,7 @@ func (s *SQLiteStore) UpsertSubscription(subscription *Subscription) error {
 	return err
 }
 
-func (s *SQLiteStore) GetSubscriptionForWorkspace(workspaceID string) (*Subscription, error) {
+func (s *SQLStore) GetSubscriptionForWorkspace(ctx context.Context, workspaceID string) (*Subscription, error) {
 	query := `
 		SELECT id, workspace_id, organization_id, plan, status, provider, provider_customer_id,
 		       provider_subscription_id, provider_subscription_item_id, provider_checkout_session_id,
@@ -557,7 +558,7 @@ func (s *SQLiteStore) GetSubscriptionForWorkspace(workspaceID string) (*Subscrip
 		ORDER BY updated_at DESC
 		LIMIT 1
 	`
-	row := s.db.QueryRow(query, workspaceID)
+	row := s.db.QueryRowContext(ctx, query, workspaceID)
 
 	var subscription Subscription
 	var orgID, provider, customerID, subscriptionID, subscriptionItemID, checkoutSessionID, scheduledPlan sql.NullString
@@ -625,8 +626,8 @@ func (s *SQLiteStore) GetSubscriptionForWorkspace(workspaceID string) (*Subscrip
 	return &subscription, nil
 }
 
-func (s *SQLiteStore) GetSubscriptionForOrganization(organizationID string) (*Subscription, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetSubscriptionForOrganization(ctx context.Context, organizationID string) (*Subscription, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, workspace_id, organization_id, plan, status, provider, provider_customer_id,
 		       provider_subscription_id, provider_subscription_item_id, provider_checkout_session_id,
 		       scheduled_plan, current_period_end, cancel_at_period_end, billed_quantity, created_at, updated_at
@@ -702,8 +703,8 @@ func (s *SQLiteStore) GetSubscriptionForOrganization(organizationID string) (*Su
 	return &subscription, nil
 }
 
-func (s *SQLiteStore) GetSubscriptionByProviderSubscriptionID(providerSubscriptionID string) (*Subscription, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetSubscriptionByProviderSubscriptionID(ctx context.Context, providerSubscriptionID string) (*Subscription, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, workspace_id, organization_id, plan, status, provider, provider_customer_id,
 		       provider_subscription_id, provider_subscription_item_id, provider_checkout_session_id,
 		       scheduled_plan, current_period_end, cancel_at_period_end, billed_quantity, created_at, updated_at
@@ -781,8 +782,8 @@ func (s *SQLiteStore) GetSubscriptionByProviderSubscriptionID(providerSubscripti
 	return &subscription, nil
 }
 
-func (s *SQLiteStore) GetSubscriptionByProviderCheckoutSessionID(providerCheckoutSessionID string) (*Subscription, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetSubscriptionByProviderCheckoutSessionID(ctx context.Context, providerCheckoutSessionID string) (*Subscription, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, workspace_id, organization_id, plan, status, provider, provider_customer_id,
 		       provider_subscription_id, provider_subscription_item_id, provider_checkout_session_id,
 		       scheduled_plan, current_period_end, cancel_at_period_end, billed_quantity, created_at, updated_at
@@ -860,12 +861,13 @@ func (s *SQLiteStore) GetSubscriptionByProviderCheckoutSessionID(providerCheckou
 	return &subscription, nil
 }
 
-func (s *SQLiteStore) CreateSubscriptionEvent(event *SubscriptionEvent) error {
+func (s *SQLStore) CreateSubscriptionEvent(ctx context.Context, event *SubscriptionEvent) error {
 	query := `
-		INSERT OR IGNORE INTO subscription_events (id, subscription_id, event_type, provider_event_id, payload, created_at)
+		INSERT INTO subscription_events (id, subscription_id, event_type, provider_event_id, payload, created_at)
 		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
 	`
-	_, err := s.db.Exec(
+	_, err := s.db.ExecContext(ctx,
 		query,
 		event.ID,
 		event.SubscriptionID,
@@ -877,9 +879,9 @@ func (s *SQLiteStore) CreateSubscriptionEvent(event *SubscriptionEvent) error {
 	return err
 }
 
-func (s *SQLiteStore) CountActiveOrganizationMembers(organizationID string) (int, error) {
+func (s *SQLStore) CountActiveOrganizationMembers(ctx context.Context, organizationID string) (int, error) {
 	var count int
-	if err := s.db.QueryRow(`
+	if err := s.db.QueryRowContext(ctx, `
 		SELECT COUNT(1)
 		FROM organization_members
 		WHERE organization_id = ? AND status = 'active'
@@ -889,12 +891,12 @@ func (s *SQLiteStore) CountActiveOrganizationMembers(organizationID string) (int
 	return count, nil
 }
 
-func (s *SQLiteStore) CreateDeckShareRecord(share *DeckShare) error {
+func (s *SQLStore) CreateDeckShareRecord(ctx context.Context, share *DeckShare) error {
 	query := `
 		INSERT INTO deck_shares (id, deck_id, workspace_id, created_by_user_id, token, access_type, created_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := s.db.Exec(
+	_, err := s.db.ExecContext(ctx,
 		query,
 		share.ID,
 		share.DeckID,
@@ -907,14 +909,14 @@ func (s *SQLiteStore) CreateDeckShareRecord(share *DeckShare) error {
 	return err
 }
 
-func (s *SQLiteStore) GetDeckShareByDeckID(deckID int64) (*DeckShare, error) {
+func (s *SQLStore) GetDeckShareByDeckID(ctx context.Context, deckID int64) (*DeckShare, error) {
 	query := `
 		SELECT id, deck_id, workspace_id, created_by_user_id, token, access_type, created_at
 		FROM deck_shares WHERE deck_id = ?
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
-	row := s.db.QueryRow(query, deckID)
+	row := s.db.QueryRowContext(ctx, query, deckID)
 
 	var share DeckShare
 	var workspaceID, createdBy sql.NullString
@@ -932,24 +934,24 @@ func (s *SQLiteStore) GetDeckShareByDeckID(deckID int64) (*DeckShare, error) {
 	return &share, nil
 }
 
-func (s *SQLiteStore) DeleteDeckShareByDeckID(deckID int64) error {
-	_, err := s.db.Exec(`DELETE FROM deck_shares WHERE deck_id = ?`, deckID)
+func (s *SQLStore) DeleteDeckShareByDeckID(ctx context.Context, deckID int64) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM deck_shares WHERE deck_id = ?`, deckID)
 	return err
 }
 
-func (s *SQLiteStore) CountDeckSharesForWorkspace(workspaceID string) (int, error) {
+func (s *SQLStore) CountDeckSharesForWorkspace(ctx context.Context, workspaceID string) (int, error) {
 	var count int
-	err := s.db.QueryRow(`SELECT COUNT(*) FROM deck_shares WHERE workspace_id = ?`, workspaceID).Scan(&count)
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM deck_shares WHERE workspace_id = ?`, workspaceID).Scan(&count)
 	return count, err
 }
 
-func (s *SQLiteStore) CountSyncDevicesForWorkspace(workspaceID string) (int, error) {
+func (s *SQLStore) CountSyncDevicesForWorkspace(ctx context.Context, workspaceID string) (int, error) {
 	var count int
-	err := s.db.QueryRow(`SELECT COUNT(*) FROM sync_devices WHERE workspace_id = ?`, workspaceID).Scan(&count)
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sync_devices WHERE workspace_id = ?`, workspaceID).Scan(&count)
 	return count, err
 }
 
-func (s *SQLiteStore) ListRecentDeckNotes(collectionID string, deckID int64, limit int, cursorCreatedAt int64, cursorNoteID int64) ([]RecentDeckNoteSummary, error) {
+func (s *SQLStore) ListRecentDeckNotes(ctx context.Context, collectionID string, deckID int64, limit int, cursorCreatedAt int64, cursorNoteID int64) ([]RecentDeckNoteSummary, error) {
 	if limit <= 0 {
 		limit = 20
 	}
@@ -987,7 +989,7 @@ func (s *SQLiteStore) ListRecentDeckNotes(collectionID string, deckID int64, lim
 	`
 	args = append(args, limit)
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
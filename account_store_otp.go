@@ -1,11 +1,12 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"time"
 )
 
-func (s *SQLiteStore) CreateOTPChallenge(challenge *OTPChallenge) error {
+func (s *SQLStore) CreateOTPChallenge(ctx context.Context, challenge *OTPChallenge) error {
 	query := `
 		INSERT INTO otp_challenges (
 			id, email, code_hash, expires_at, attempt_count, max_attempts,
@@ -13,7 +14,7 @@ func (s *SQLiteStore) CreateOTPChallenge(challenge *OTPChallenge) error {
 		)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := s.db.Exec(
+	_, err := s.db.ExecContext(ctx,
 		query,
 		challenge.ID,
 		challenge.Email,
@@ -30,8 +31,8 @@ func (s *SQLiteStore) CreateOTPChallenge(challenge *OTPChallenge) error {
 	return err
 }
 
-func (s *SQLiteStore) InvalidateOTPChallenges(email string) error {
-	_, err := s.db.Exec(
+func (s *SQLStore) InvalidateOTPChallenges(ctx context.Context, email string) error {
+	_, err := s.db.ExecContext(ctx,
 		`UPDATE otp_challenges SET consumed_at = ? WHERE lower(email) = lower(?) AND consumed_at IS NULL`,
 		time.Now().Unix(),
 		email,
@@ -39,7 +40,7 @@ func (s *SQLiteStore) InvalidateOTPChallenges(email string) error {
 	return err
 }
 
-func (s *SQLiteStore) GetLatestOTPChallenge(email string) (*OTPChallenge, error) {
+func (s *SQLStore) GetLatestOTPChallenge(ctx context.Context, email string) (*OTPChallenge, error) {
 	query := `
 		SELECT id, email, code_hash, expires_at, attempt_count, max_attempts,
 		       resend_available_at, consumed_at, requested_ip, user_agent, created_at
@@ -48,7 +49,7 @@ func (s *SQLiteStore) GetLatestOTPChallenge(email string) (*OTPChallenge, error)
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
-	row := s.db.QueryRow(query, email)
+	row := s.db.QueryRowContext(ctx, query, email)
 
 	var challenge OTPChallenge
 	var consumedAt sql.NullInt64
@@ -86,19 +87,19 @@ func (s *SQLiteStore) GetLatestOTPChallenge(email string) (*OTPChallenge, error)
 	return &challenge, nil
 }
 
-func (s *SQLiteStore) IncrementOTPChallengeAttempts(id string) error {
-	_, err := s.db.Exec(`UPDATE otp_challenges SET attempt_count = attempt_count + 1 WHERE id = ?`, id)
+func (s *SQLStore) IncrementOTPChallengeAttempts(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE otp_challenges SET attempt_count = attempt_count + 1 WHERE id = ?`, id)
 	return err
 }
 
-func (s *SQLiteStore) ConsumeOTPChallenge(id string, consumedAt time.Time) error {
-	_, err := s.db.Exec(`UPDATE otp_challenges SET consumed_at = ? WHERE id = ?`, consumedAt.Unix(), id)
+func (s *SQLStore) ConsumeOTPChallenge(ctx context.Context, id string, consumedAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE otp_challenges SET consumed_at = ? WHERE id = ?`, consumedAt.Unix(), id)
 	return err
 }
 
-func (s *SQLiteStore) CountRecentOTPChallengesByEmail(email string, since time.Time) (int, error) {
+func (s *SQLStore) CountRecentOTPChallengesByEmail(ctx context.Context, email string, since time.Time) (int, error) {
 	var count int
-	err := s.db.QueryRow(
+	err := s.db.QueryRowContext(ctx,
 		`SELECT COUNT(*) FROM otp_challenges WHERE lower(email) = lower(?) AND created_at >= ?`,
 		email,
 		since.Unix(),
@@ -106,12 +107,12 @@ func (s *SQLiteStore) CountRecentOTPChallengesByEmail(email string, since time.T
 	return count, err
 }
 
-func (s *SQLiteStore) CountRecentOTPChallengesByIP(ip string, since time.Time) (int, error) {
+func (s *SQLStore) CountRecentOTPChallengesByIP(ctx context.Context, ip string, since time.Time) (int, error) {
 	if ip == "" {
 		return 0, nil
 	}
 	var count int
-	err := s.db.QueryRow(
+	err := s.db.QueryRowContext(ctx,
 		`SELECT COUNT(*) FROM otp_challenges WHERE requested_ip = ? AND created_at >= ?`,
 		ip,
 		since.Unix(),
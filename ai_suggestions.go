@@ -206,25 +206,16 @@ func (h *APIHandler) GenerateCardSuggestions(w http.ResponseWriter, r *http.Requ
 	}
 
 	var req GenerateAICardSuggestionsRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid AI suggestion request.")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
 	req.SourceText = strings.TrimSpace(req.SourceText)
 	req.NoteType = strings.TrimSpace(req.NoteType)
-	if req.SourceText == "" || req.NoteType == "" {
-		respondAPIError(w, http.StatusBadRequest, "invalid_ai_request", "Source text and note type are required.")
-		return
-	}
-	if len(req.SourceText) > 20000 {
-		respondAPIError(w, http.StatusBadRequest, "ai_source_too_large", "Source text is too large. Keep it under 20,000 characters for now.")
-		return
-	}
 
 	noteType, ok := col.NoteTypes[NoteTypeName(req.NoteType)]
 	if !ok {
-		reloaded, err := h.store.GetNoteType(collectionID, NoteTypeName(req.NoteType))
+		reloaded, err := h.store.GetNoteType(r.Context(), collectionID, NoteTypeName(req.NoteType))
 		if err != nil {
 			respondAPIError(w, http.StatusBadRequest, "invalid_note_type", "Note type not found.")
 			return
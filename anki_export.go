@@ -0,0 +1,486 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An .apkg is a zip holding collection.anki2, a SQLite database in Anki's schema 11
+// that every Anki release can import, plus a "media" JSON map from numbered entries in
+// the zip to media filenames. Decks are exported for sharing: cards go out as new cards
+// without review history, as Anki does when scheduling is left out of an export.
+
+const (
+	ankiSchemaVersion = 11
+	ankiDefaultDeckID = 1
+	// ankiIDBase keeps exported deck and note type IDs in the range of Anki's own
+	// millisecond-timestamp IDs and away from its default deck.
+	ankiIDBase int64 = 1_500_000_000_000
+
+	ankiDefaultCSS = ".card {\n font-family: arial;\n font-size: 20px;\n text-align: center;\n color: black;\n background-color: white;\n}\n"
+	ankiLatexPre   = "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n"
+	ankiLatexPost  = "\\end{document}"
+)
+
+var ankiSchemaStatements = []string{
+	`CREATE TABLE col (
+		id integer primary key, crt integer not null, mod integer not null, scm integer not null,
+		ver integer not null, dty integer not null, usn integer not null, ls integer not null,
+		conf text not null, models text not null, decks text not null, dconf text not null, tags text not null
+	)`,
+	`CREATE TABLE notes (
+		id integer primary key, guid text not null, mid integer not null, mod integer not null,
+		usn integer not null, tags text not null, flds text not null, sfld integer not null,
+		csum integer not null, flags integer not null, data text not null
+	)`,
+	`CREATE TABLE cards (
+		id integer primary key, nid integer not null, did integer not null, ord integer not null,
+		mod integer not null, usn integer not null, type integer not null, queue integer not null,
+		due integer not null, ivl integer not null, factor integer not null, reps integer not null,
+		lapses integer not null, left integer not null, odue integer not null, odid integer not null,
+		flags integer not null, data text not null
+	)`,
+	`CREATE TABLE revlog (
+		id integer primary key, cid integer not null, usn integer not null, ease integer not null,
+		ivl integer not null, lastIvl integer not null, factor integer not null, time integer not null,
+		type integer not null
+	)`,
+	`CREATE TABLE graves (usn integer not null, oid integer not null, type integer not null)`,
+	`CREATE INDEX ix_notes_usn ON notes (usn)`,
+	`CREATE INDEX ix_cards_usn ON cards (usn)`,
+	`CREATE INDEX ix_revlog_usn ON revlog (usn)`,
+	`CREATE INDEX ix_cards_nid ON cards (nid)`,
+	`CREATE INDEX ix_cards_sched ON cards (did, queue, due)`,
+	`CREATE INDEX ix_revlog_cid ON revlog (cid)`,
+	`CREATE INDEX ix_notes_csum ON notes (csum)`,
+}
+
+// ankiExport is what goes into one package.
+type ankiExport struct {
+	collectionID string
+	decks        []*Deck
+	noteTypes    []NoteType
+	notes        []Note
+	cards        []*Card
+	media        []*MediaRef
+}
+
+// ankiStableID gives a deck or note type a package ID that stays the same across
+// exports, so re-importing an updated deck updates it in Anki instead of duplicating it.
+func ankiStableID(kind, name string) int64 {
+	return ankiIDBase + int64(crc32.ChecksumIEEE([]byte(kind+"\x00"+name)))
+}
+
+// ankiNoteGUID identifies a note across exports, which Anki uses to update notes it
+// has imported before.
+func ankiNoteGUID(collectionID string, noteID int64) string {
+	sum := sha256.Sum256([]byte(collectionID + ":" + strconv.FormatInt(noteID, 10)))
+	return hex.EncodeToString(sum[:5])
+}
+
+// ankiFieldChecksum is Anki's duplicate-check checksum: the first 32 bits of the SHA-1
+// of the sort field's plain text.
+func ankiFieldChecksum(text string) int64 {
+	sum := sha1.Sum([]byte(text))
+	return int64(binary.BigEndian.Uint32(sum[:4]))
+}
+
+// collectAnkiExport gathers deck and its sub-decks with their cards, notes, note types
+// and the media those refer to.
+func (h *APIHandler) collectAnkiExport(ctx context.Context, collectionID string, col *Collection, deck *Deck) (ankiExport, error) {
+	export := ankiExport{collectionID: collectionID}
+	prefix := strings.ToLower(deck.Name + deckPathSeparator)
+	deckIDs := map[int64]bool{}
+	for _, candidate := range col.Decks {
+		if candidate.ID == deck.ID || strings.HasPrefix(strings.ToLower(candidate.Name), prefix) {
+			export.decks = append(export.decks, candidate)
+			deckIDs[candidate.ID] = true
+		}
+	}
+	sort.Slice(export.decks, func(i, j int) bool { return export.decks[i].Name < export.decks[j].Name })
+
+	noteIDs := map[int64]bool{}
+	for _, card := range col.Cards {
+		if deckIDs[card.DeckID] {
+			export.cards = append(export.cards, card)
+			noteIDs[card.NoteID] = true
+		}
+	}
+	sort.Slice(export.cards, func(i, j int) bool { return export.cards[i].ID < export.cards[j].ID })
+
+	typeNames := map[NoteTypeName]bool{}
+	for id := range noteIDs {
+		note, ok := col.Notes[id]
+		if !ok {
+			continue
+		}
+		export.notes = append(export.notes, note)
+		typeNames[note.Type] = true
+	}
+	sort.Slice(export.notes, func(i, j int) bool { return export.notes[i].ID < export.notes[j].ID })
+	for name := range typeNames {
+		if nt, ok := col.NoteTypes[name]; ok {
+			export.noteTypes = append(export.noteTypes, nt)
+		}
+	}
+	sort.Slice(export.noteTypes, func(i, j int) bool { return export.noteTypes[i].Name < export.noteTypes[j].Name })
+
+	var filenames []string
+	addRefs := func(refs ...string) {
+		for _, ref := range refs {
+			if !slices.Contains(filenames, ref) {
+				filenames = append(filenames, ref)
+			}
+		}
+	}
+	for _, nt := range export.noteTypes {
+		for _, tmpl := range nt.Templates {
+			addRefs(mediaReferences(tmpl.QFmt + tmpl.AFmt)...)
+		}
+	}
+	for _, note := range export.notes {
+		for _, field := range col.NoteTypes[note.Type].Fields {
+			addRefs(mediaReferences(note.FieldMap[field])...)
+		}
+		for filename := range ttsUtterances(col.NoteTypes[note.Type], note) {
+			addRefs(filename)
+		}
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		media, err := h.store.GetCollectionMedia(ctx, collectionID, filename)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return ankiExport{}, err
+		}
+		export.media = append(export.media, media)
+	}
+	return export, nil
+}
+
+// ankiDeckID maps a deck to its package ID. A deck named Default becomes Anki's own
+// default deck rather than a second deck with the same name.
+func ankiDeckID(deck *Deck) int64 {
+	if strings.EqualFold(deck.Name, "Default") {
+		return ankiDefaultDeckID
+	}
+	return ankiStableID("deck", deck.Name)
+}
+
+func ankiDeckJSON(id int64, name string, mod int64) map[string]any {
+	return map[string]any{
+		"id": id, "name": name, "mod": mod, "usn": 0, "desc": "", "dyn": 0, "conf": 1,
+		"collapsed": false, "browserCollapsed": false, "extendNew": 0, "extendRev": 0,
+		"newToday": []int{0, 0}, "revToday": []int{0, 0}, "lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+	}
+}
+
+// ankiModelJSON converts a note type to an Anki model. Templates shown only when a
+// field is filled are wrapped in a {{#Field}} section, which is how Anki expresses it.
+func ankiModelJSON(nt NoteType, deckID, mod int64) map[string]any {
+	fieldIndex := make(map[string]int, len(nt.Fields))
+	fields := make([]map[string]any, 0, len(nt.Fields))
+	for i, name := range nt.Fields {
+		fieldIndex[name] = i
+		opts := nt.FieldOptions[name]
+		font, size := opts.Font, opts.FontSize
+		if font == "" {
+			font = "Arial"
+		}
+		if size <= 0 {
+			size = 20
+		}
+		fields = append(fields, map[string]any{
+			"name": name, "ord": i, "sticky": false, "rtl": opts.RTL, "font": font, "size": size, "media": []string{},
+		})
+	}
+
+	modelType, css := 0, ankiDefaultCSS
+	templates := make([]map[string]any, 0, len(nt.Templates))
+	req := make([][]any, 0, len(nt.Templates))
+	for i, tmpl := range nt.Templates {
+		if tmpl.IsCloze {
+			modelType = 1
+		}
+		if i == 0 && strings.TrimSpace(tmpl.Styling) != "" {
+			css = tmpl.Styling
+		}
+		qfmt := tmpl.QFmt
+		if tmpl.IfFieldNonEmpty != "" {
+			qfmt = "{{#" + tmpl.IfFieldNonEmpty + "}}" + qfmt + "{{/" + tmpl.IfFieldNonEmpty + "}}"
+		}
+		templates = append(templates, map[string]any{
+			"name": tmpl.Name, "ord": i, "qfmt": qfmt, "afmt": tmpl.AFmt,
+			"bqfmt": tmpl.BrowserQFmt, "bafmt": tmpl.BrowserAFmt, "did": nil, "bfont": "", "bsize": 0,
+		})
+
+		// Older Anki releases decide which cards a note gets from req: the fields the
+		// question refers to, any of which must be filled.
+		var used []int
+		for _, m := range fieldTokenRe.FindAllStringSubmatch(qfmt, -1) {
+			parts := strings.Split(strings.TrimSpace(m[1]), ":")
+			name := strings.TrimLeft(strings.TrimSpace(parts[len(parts)-1]), "#^/")
+			if idx, ok := fieldIndex[strings.TrimSpace(name)]; ok && !slices.Contains(used, idx) {
+				used = append(used, idx)
+			}
+		}
+		if len(used) == 0 {
+			req = append(req, []any{i, "none", []int{}})
+		} else {
+			req = append(req, []any{i, "any", used})
+		}
+	}
+
+	return map[string]any{
+		"id": ankiStableID("notetype", string(nt.Name)), "name": string(nt.Name), "type": modelType,
+		"mod": mod, "usn": 0, "sortf": nt.SortFieldIndex, "did": deckID, "tmpls": templates, "flds": fields,
+		"css": css, "latexPre": ankiLatexPre, "latexPost": ankiLatexPost, "latexsvg": false,
+		"req": req, "tags": []string{}, "vers": []int{},
+	}
+}
+
+// writeAnkiPackage builds the .apkg for export.
+func writeAnkiPackage(export ankiExport, now time.Time) ([]byte, error) {
+	tempDir, err := os.MkdirTemp("", "microdote-anki-export-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	dbPath := filepath.Join(tempDir, "collection.anki2")
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Anki collection database: %w", err)
+	}
+	if err := fillAnkiCollection(db, export, now); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := db.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close Anki collection database: %w", err)
+	}
+	collectionData, err := os.ReadFile(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Anki collection database: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	entry, err := zw.Create("collection.anki2")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := entry.Write(collectionData); err != nil {
+		return nil, err
+	}
+	mediaMap := make(map[string]string, len(export.media))
+	for i, media := range export.media {
+		key := strconv.Itoa(i)
+		mediaMap[key] = media.Filename
+		entry, err := zw.Create(key)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := entry.Write(media.Data); err != nil {
+			return nil, err
+		}
+	}
+	entry, err = zw.Create("media")
+	if err != nil {
+		return nil, err
+	}
+	if err := json.NewEncoder(entry).Encode(mediaMap); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func fillAnkiCollection(db *sql.DB, export ankiExport, now time.Time) error {
+	for _, statement := range ankiSchemaStatements {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("failed to create Anki schema: %w", err)
+		}
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	mod := now.Unix()
+	decks := map[string]any{strconv.Itoa(ankiDefaultDeckID): ankiDeckJSON(ankiDefaultDeckID, "Default", mod)}
+	deckIDs := make(map[int64]int64, len(export.decks))
+	for _, deck := range export.decks {
+		id := ankiDeckID(deck)
+		deckIDs[deck.ID] = id
+		decks[strconv.FormatInt(id, 10)] = ankiDeckJSON(id, deck.Name, mod)
+	}
+	firstDeckID := int64(ankiDefaultDeckID)
+	if len(export.decks) > 0 {
+		firstDeckID = deckIDs[export.decks[0].ID]
+	}
+
+	models := make(map[string]any, len(export.noteTypes))
+	noteTypes := make(map[NoteTypeName]NoteType, len(export.noteTypes))
+	for _, nt := range export.noteTypes {
+		models[strconv.FormatInt(ankiStableID("notetype", string(nt.Name)), 10)] = ankiModelJSON(nt, firstDeckID, mod)
+		noteTypes[nt.Name] = nt
+	}
+
+	conf := map[string]any{
+		"nextPos": len(export.cards) + 1, "estTimes": true, "activeDecks": []int64{firstDeckID}, "sortType": "noteFld",
+		"timeLim": 0, "sortBackwards": false, "addToCur": true, "curDeck": firstDeckID, "newSpread": 0,
+		"dueCounts": true, "curModel": nil, "collapseTime": 1200,
+	}
+	dconf := map[string]any{"1": map[string]any{
+		"id": 1, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60, "autoplay": true, "timer": 0,
+		"replayq": true, "dyn": false,
+		"new":   map[string]any{"delays": []float64{1, 10}, "ints": []int{1, 4, 0}, "initialFactor": 2500, "order": 1, "perDay": 20, "bury": false},
+		"lapse": map[string]any{"delays": []float64{10}, "mult": 0, "minInt": 1, "leechFails": 8, "leechAction": 1},
+		"rev":   map[string]any{"perDay": 200, "ease4": 1.3, "ivlFct": 1, "maxIvl": 36500, "bury": false, "hardFactor": 1.2},
+	}}
+	var colJSON [4][]byte
+	for i, value := range []any{conf, models, decks, dconf} {
+		if colJSON[i], err = json.Marshal(value); err != nil {
+			return err
+		}
+	}
+	crt := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Unix()
+	if _, err := tx.Exec(
+		`INSERT INTO col (id, crt, mod, scm, ver, dty, usn, ls, conf, models, decks, dconf, tags) VALUES (1, ?, ?, ?, ?, 0, 0, 0, ?, ?, ?, ?, '{}')`,
+		crt, now.UnixMilli(), now.UnixMilli(), ankiSchemaVersion, string(colJSON[0]), string(colJSON[1]), string(colJSON[2]), string(colJSON[3]),
+	); err != nil {
+		return fmt.Errorf("failed to write Anki collection: %w", err)
+	}
+
+	marked := map[int64]bool{}
+	for _, card := range export.cards {
+		if card.Marked {
+			marked[card.NoteID] = true
+		}
+	}
+	for _, note := range export.notes {
+		nt := noteTypes[note.Type]
+		values := make([]string, len(nt.Fields))
+		for i, field := range nt.Fields {
+			values[i] = note.FieldMap[field]
+		}
+		sortField := ""
+		if nt.SortFieldIndex >= 0 && nt.SortFieldIndex < len(values) {
+			sortField = typedAnswerText(values[nt.SortFieldIndex])
+		}
+		tags := slices.Clone(note.Tags)
+		if marked[note.ID] && !slices.Contains(tags, "marked") {
+			tags = append(tags, "marked")
+		}
+		tagText := ""
+		if len(tags) > 0 {
+			tagText = " " + strings.Join(tags, " ") + " "
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO notes (id, guid, mid, mod, usn, tags, flds, sfld, csum, flags, data) VALUES (?, ?, ?, ?, 0, ?, ?, ?, ?, 0, '')`,
+			note.ID, ankiNoteGUID(export.collectionID, note.ID), ankiStableID("notetype", string(nt.Name)), note.ModifiedAt.Unix(),
+			tagText, strings.Join(values, "\x1f"), sortField, ankiFieldChecksum(sortField),
+		); err != nil {
+			return fmt.Errorf("failed to write Anki note %d: %w", note.ID, err)
+		}
+	}
+
+	notes := make(map[int64]Note, len(export.notes))
+	for _, note := range export.notes {
+		notes[note.ID] = note
+	}
+	for position, card := range export.cards {
+		note, ok := notes[card.NoteID]
+		if !ok {
+			continue
+		}
+		nt := noteTypes[note.Type]
+		ord := -1
+		for i, tmpl := range nt.Templates {
+			if tmpl.Name != card.TemplateName {
+				continue
+			}
+			ord = i
+			if tmpl.IsCloze {
+				ord = card.Ordinal - 1
+			}
+			break
+		}
+		if ord < 0 {
+			continue
+		}
+		queue := 0
+		if card.Suspended {
+			queue = -1
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO cards (id, nid, did, ord, mod, usn, type, queue, due, ivl, factor, reps, lapses, left, odue, odid, flags, data)
+			VALUES (?, ?, ?, ?, ?, 0, 0, ?, ?, 0, 0, 0, 0, 0, 0, 0, ?, '')`,
+			card.ID, card.NoteID, deckIDs[card.DeckID], ord, mod, queue, position+1, card.Flag,
+		); err != nil {
+			return fmt.Errorf("failed to write Anki card %d: %w", card.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ExportDeckAPKG serves GET /api/decks/{id}/export/apkg, packaging the deck and its
+// sub-decks for Anki.
+func (h *APIHandler) ExportDeckAPKG(w http.ResponseWriter, r *http.Request) {
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	id, err := parseIDParam(r, "id")
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_deck_id", "Invalid deck ID")
+		return
+	}
+	deck, ok := col.Decks[id]
+	if !ok {
+		respondAPIError(w, http.StatusNotFound, "deck_not_found", "Deck not found")
+		return
+	}
+
+	export, err := h.collectAnkiExport(r.Context(), collectionID, col, deck)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "export_failed", err.Error())
+		return
+	}
+	data, err := writeAnkiPackage(export, time.Now())
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "export_failed", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": deckShortName(deck.Name) + ".apkg"}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
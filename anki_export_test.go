@@ -0,0 +1,136 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPI_ExportDeckAPKG(t *testing.T) {
+	env := setupAPITestEnv(t)
+	var collectionID string
+	if err := env.store.db.QueryRow("SELECT collection_id FROM decks WHERE id = 1").Scan(&collectionID); err != nil {
+		t.Fatalf("collection lookup failed: %v", err)
+	}
+	if err := env.store.AddMedia(context.Background(), collectionID, &MediaRef{Filename: "heap.png", Data: []byte("PNG heap"), AddedAt: time.Now()}); err != nil {
+		t.Fatalf("add media failed: %v", err)
+	}
+
+	sessionRecord, err := env.store.GetSessionRecord(context.Background(), strings.TrimPrefix(env.authCookie, sessionCookieName+"="))
+	if err != nil {
+		t.Fatalf("failed to load current session: %v", err)
+	}
+	activateWorkspaceSubscriptionForTest(t, env, sessionRecord.WorkspaceID, PlanPro)
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/decks", CreateDeckRequest{Name: "DSA::Trees"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected deck create 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	child := decodeJSON[DeckResponse](t, rr)
+
+	createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic (and reversed card)",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": `Heap <img src="heap.png">`, "Back": "Priority queue"},
+	}, nil)
+	createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Cloze",
+		DeckID:    child.ID,
+		FieldVals: map[string]string{"Text": "{{c1::BST}} and {{c2::AVL}}", "Extra": ""},
+	}, nil)
+
+	var parentID int64
+	if err := env.store.db.QueryRow("SELECT id FROM decks WHERE name = 'DSA'").Scan(&parentID); err != nil {
+		t.Fatalf("parent deck lookup failed: %v", err)
+	}
+	rr = doRawRequest(env.router, http.MethodGet, "/api/decks/"+strconv.FormatInt(parentID, 10)+"/export/apkg", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected export 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Disposition"); got != `attachment; filename=DSA.apkg` {
+		t.Fatalf("unexpected Content-Disposition %q", got)
+	}
+
+	// Only the DSA subtree is exported: the cloze note, not the Basic note in Default.
+	notes, err := parseAnkiPackageImport(rr.Body.Bytes(), importParseOptions{})
+	if err != nil {
+		t.Fatalf("failed to re-import exported package: %v", err)
+	}
+	if len(notes) != 1 || notes[0].NoteType != "Cloze" || notes[0].DeckName != "DSA::Trees" {
+		t.Fatalf("unexpected exported notes %+v", notes)
+	}
+
+	rr = doRawRequest(env.router, http.MethodGet, "/api/decks/1/export/apkg", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected export 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	db, mediaMap := openExportedAnkiPackage(t, rr.Body.Bytes())
+	if len(mediaMap) != 1 || mediaMap["0"] != "heap.png" {
+		t.Fatalf("expected heap.png in the media map, got %v", mediaMap)
+	}
+	var ords string
+	if err := db.QueryRow(`SELECT group_concat(ord) FROM (SELECT ord FROM cards ORDER BY ord)`).Scan(&ords); err != nil {
+		t.Fatalf("failed to read exported cards: %v", err)
+	}
+	if ords != "0,1" {
+		t.Fatalf("expected both reversed cards, got ords %q", ords)
+	}
+	var flds, sfld string
+	if err := db.QueryRow(`SELECT flds, sfld FROM notes`).Scan(&flds, &sfld); err != nil {
+		t.Fatalf("failed to read exported note: %v", err)
+	}
+	if flds != "Heap <img src=\"heap.png\">\x1fPriority queue" || sfld != "Heap" {
+		t.Fatalf("unexpected exported note %q / %q", flds, sfld)
+	}
+
+	if rr := doRawRequest(env.router, http.MethodGet, "/api/decks/999/export/apkg", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown deck 404, got %d", rr.Code)
+	}
+}
+
+func openExportedAnkiPackage(t *testing.T, data []byte) (*sql.DB, map[string]string) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("failed to open package: %v", err)
+	}
+	dbPath := filepath.Join(t.TempDir(), "collection.anki2")
+	mediaMap := map[string]string{}
+	for _, file := range zr.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", file.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", file.Name, err)
+		}
+		switch file.Name {
+		case "collection.anki2":
+			if err := os.WriteFile(dbPath, content, 0o600); err != nil {
+				t.Fatalf("failed to write collection: %v", err)
+			}
+		case "media":
+			if err := json.Unmarshal(content, &mediaMap); err != nil {
+				t.Fatalf("failed to parse media map: %v", err)
+			}
+		}
+	}
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open exported collection: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, mediaMap
+}
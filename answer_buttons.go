@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// The answer buttons statistics break answers down by the button pressed and by the
+// card's maturity before the answer, as Anki's "Answer Buttons" graph does. Reviews of
+// cards whose interval was under matureIntervalDays count as young.
+const (
+	matureIntervalDays     = 21
+	defaultStatsPeriodDays = 30
+	maxStatsPeriodDays     = 3650
+	answerMaturityLearning = "learning"
+	answerMaturityYoung    = "young"
+	answerMaturityMature   = "mature"
+)
+
+// AnswerButtonGroup counts the presses of each button for one maturity.
+type AnswerButtonGroup struct {
+	Maturity    string  `json:"maturity"`
+	Again       int     `json:"again"`
+	Hard        int     `json:"hard"`
+	Good        int     `json:"good"`
+	Easy        int     `json:"easy"`
+	Total       int     `json:"total"`
+	CorrectRate float64 `json:"correctRate"`
+}
+
+type answerButtonsResponse struct {
+	Days   int                 `json:"days"`
+	DeckID int64               `json:"deckId,omitempty"`
+	Groups []AnswerButtonGroup `json:"groups"`
+}
+
+// add counts a press of rating.
+func (g *AnswerButtonGroup) add(rating fsrs.Rating, count int) {
+	switch rating {
+	case fsrs.Again:
+		g.Again += count
+	case fsrs.Hard:
+		g.Hard += count
+	case fsrs.Good:
+		g.Good += count
+	case fsrs.Easy:
+		g.Easy += count
+	}
+	g.Total += count
+}
+
+// GetAnswerButtonStats counts the user's answers in a collection since since, by
+// maturity and button. A deckID of 0 covers every deck; otherwise the deck and its
+// descendants. Manual reschedules are not answers and are left out.
+func (s *SQLStore) GetAnswerButtonStats(ctx context.Context, userID, collectionID string, deckID int64, since time.Time) ([]AnswerButtonGroup, error) {
+	query := fmt.Sprintf(`
+		SELECT CASE
+				WHEN r.state != %[1]d THEN '%[2]s'
+				WHEN COALESCE(r.scheduled_days, 0) < %[3]d THEN '%[4]s'
+				ELSE '%[5]s'
+			END AS maturity, r.rating, COUNT(*)
+		FROM revlog r
+		JOIN cards c ON c.id = r.card_id
+		JOIN decks d ON d.id = c.deck_id
+		WHERE d.collection_id = ?
+		  AND r.reviewed_at >= ?
+		  AND r.kind != ?
+	`, int(fsrs.Review), answerMaturityLearning, matureIntervalDays, answerMaturityYoung, answerMaturityMature)
+	args := []interface{}{collectionID, since.Unix(), reviewKindManual}
+	if strings.TrimSpace(userID) != "" {
+		query += ` AND r.user_id = ?`
+		args = append(args, userID)
+	}
+	if deckID > 0 {
+		query = deckSubtreeCTE + query + ` AND c.deck_id IN (SELECT id FROM subtree)`
+		args = append([]interface{}{deckID}, args...)
+	}
+	query += ` GROUP BY maturity, r.rating`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []AnswerButtonGroup{
+		{Maturity: answerMaturityLearning},
+		{Maturity: answerMaturityYoung},
+		{Maturity: answerMaturityMature},
+	}
+	for rows.Next() {
+		var (
+			maturity string
+			rating   int
+			count    int
+		)
+		if err := rows.Scan(&maturity, &rating, &count); err != nil {
+			return nil, err
+		}
+		for i := range groups {
+			if groups[i].Maturity == maturity {
+				groups[i].add(fsrs.Rating(rating), count)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range groups {
+		if groups[i].Total > 0 {
+			groups[i].CorrectRate = math.Round(float64(groups[i].Total-groups[i].Again)/float64(groups[i].Total)*1000) / 1000
+		}
+	}
+	return groups, nil
+}
+
+// statsPeriodFromRequest reads the days (1-3650, default 30) and deckId query
+// parameters of period statistics, returning when the period starts: whole study days
+// back, today included. It writes an error response and returns false when they are
+// invalid.
+func (h *APIHandler) statsPeriodFromRequest(w http.ResponseWriter, r *http.Request, collectionID string, col *Collection) (int, int64, time.Time, bool) {
+	query := r.URL.Query()
+	days := defaultStatsPeriodDays
+	if raw := strings.TrimSpace(query.Get("days")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxStatsPeriodDays {
+			respondAPIError(w, http.StatusBadRequest, "invalid_days", fmt.Sprintf("days must be between 1 and %d", maxStatsPeriodDays))
+			return 0, 0, time.Time{}, false
+		}
+		days = parsed
+	}
+	var deckID int64
+	if raw := strings.TrimSpace(query.Get("deckId")); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || col.Decks[parsed] == nil {
+			respondAPIError(w, http.StatusBadRequest, "invalid_deck_id", "Deck not found")
+			return 0, 0, time.Time{}, false
+		}
+		deckID = parsed
+	}
+	dayStart, _, err := h.store.studyDay(r.Context(), collectionID, time.Now())
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "study_day_failed", err.Error())
+		return 0, 0, time.Time{}, false
+	}
+	return days, deckID, dayStart.AddDate(0, 0, 1-days), true
+}
+
+// GetAnswerButtonStats serves GET /api/stats/answer-buttons. Query parameters: days
+// and deckId (see statsPeriodFromRequest).
+func (h *APIHandler) GetAnswerButtonStats(w http.ResponseWriter, r *http.Request) {
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	days, deckID, since, ok := h.statsPeriodFromRequest(w, r, collectionID, col)
+	if !ok {
+		return
+	}
+	groups, err := h.store.GetAnswerButtonStats(r.Context(), h.userIDFromRequest(r), collectionID, deckID, since)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "answer_buttons_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, answerButtonsResponse{Days: days, DeckID: deckID, Groups: groups})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestAPI_AnswerButtonStats(t *testing.T) {
+	env := setupAPITestEnv(t)
+	id := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Synapse", "Back": "Junction"},
+	}, nil).Cards[0].ID
+	for _, rating := range []int{1, 4, 3} {
+		if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", id), AnswerCardRequest{Rating: rating}); rr.Code != http.StatusOK {
+			t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+	}
+	// A mature review from today and one from before the period.
+	var userID string
+	if err := env.store.db.QueryRow(`SELECT user_id FROM card_review_states LIMIT 1`).Scan(&userID); err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+	now := time.Now()
+	for i, reviewedAt := range []time.Time{now, now.AddDate(0, 0, -60)} {
+		if _, err := env.store.db.Exec(`
+			INSERT INTO revlog (id, user_id, card_id, rating, state, due, reviewed_at, time_taken_ms, scheduled_days, kind)
+			VALUES (?, ?, ?, ?, ?, ?, ?, 0, 40, ?)
+		`, 9000+i, userID, id, int(fsrs.Again), int(fsrs.Review), reviewedAt.Unix(), reviewedAt.Unix(), reviewKindReview); err != nil {
+			t.Fatalf("insert revlog: %v", err)
+		}
+	}
+
+	rr := doRawRequest(env.router, http.MethodGet, "/api/stats/answer-buttons?days=7", "")
+	resp := decodeJSON[answerButtonsResponse](t, rr)
+	if rr.Code != http.StatusOK || len(resp.Groups) != 3 {
+		t.Fatalf("expected three maturity groups, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	learning, young, mature := resp.Groups[0], resp.Groups[1], resp.Groups[2]
+	if learning.Again != 1 || learning.Easy != 1 || learning.Total != 2 || learning.CorrectRate != 0.5 {
+		t.Fatalf("expected the first two answers counted as learning, got %+v", learning)
+	}
+	if young.Good != 1 || young.Total != 1 || young.CorrectRate != 1 {
+		t.Fatalf("expected the review counted as young, got %+v", young)
+	}
+	if mature.Again != 1 || mature.Total != 1 {
+		t.Fatalf("expected only the mature review in the period, got %+v", mature)
+	}
+	if rr := doRawRequest(env.router, http.MethodGet, "/api/stats/answer-buttons?days=0", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected days=0 to be rejected, got %d", rr.Code)
+	}
+}
@@ -1,20 +1,83 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// requestIDHeader carries a request's ID back to the client. Error bodies repeat it as
+// requestId so a client's report can be matched to the server log.
+const (
+	requestIDHeader    = "X-Request-Id"
+	maxRequestIDLength = 64
 )
 
+// APIErrorResponse is the body of every error response. Code is a stable identifier
+// clients can branch on, such as invalid_request for a malformed body, a
+// <thing>_not_found code for a missing resource, or a <thing>_conflict code when the
+// request clashes with existing data.
 type APIErrorResponse struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
 }
 
 func respondAPIError(w http.ResponseWriter, status int, code, message string) {
+	respondAPIErrorWithDetails(w, status, code, message, nil)
+}
+
+// respondAPIErrorWithDetails is respondAPIError with machine-readable details, such as
+// the limit a request exceeded.
+func respondAPIErrorWithDetails(w http.ResponseWriter, status int, code, message string, details any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(APIErrorResponse{
-		Code:    code,
-		Message: message,
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: w.Header().Get(requestIDHeader),
+	})
+}
+
+// assignRequestID gives a request an ID, keeping a well-formed one the client sent in
+// X-Request-Id. The ID is set on the response header, where error responses find it,
+// and in the context chi's request logger reads.
+func assignRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if w.Header().Get(requestIDHeader) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		id := r.Header.Get(requestIDHeader)
+		if !isValidRequestID(id) {
+			id = newID("req")
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), middleware.RequestIDKey, id)))
 	})
 }
+
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// respondAPINotFound and respondAPIMethodNotAllowed answer requests no API route matches.
+func respondAPINotFound(w http.ResponseWriter, r *http.Request) {
+	respondAPIError(w, http.StatusNotFound, "route_not_found", "No API route matches "+r.URL.Path)
+}
+
+func respondAPIMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	respondAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", r.Method+" is not allowed on "+r.URL.Path)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAPI_ErrorsUseJSONEnvelopeWithRequestID(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	rr := doRawRequestWithHeaders(env.router, http.MethodGet, "/api/decks/abc", "", map[string]string{requestIDHeader: "client-42"})
+	body := decodeJSON[APIErrorResponse](t, rr)
+	if rr.Code != http.StatusBadRequest || body.Code != "invalid_deck_id" || body.Message != "Invalid deck ID" {
+		t.Fatalf("expected a JSON invalid_deck_id error, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if body.RequestID != "client-42" || rr.Header().Get(requestIDHeader) != "client-42" {
+		t.Fatalf("expected the client's request ID to be kept, got %q and header %q", body.RequestID, rr.Header().Get(requestIDHeader))
+	}
+
+	rr = doRawRequestWithHeaders(env.router, http.MethodGet, "/api/cards/999999", "", map[string]string{requestIDHeader: "bad id with spaces"})
+	body = decodeJSON[APIErrorResponse](t, rr)
+	if rr.Code != http.StatusNotFound || body.Code != "card_not_found" || !strings.HasPrefix(body.RequestID, "req_") {
+		t.Fatalf("expected card_not_found with a generated request ID, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr = doRawRequest(env.router, http.MethodGet, "/api/no-such-route", "")
+	if body := decodeJSON[APIErrorResponse](t, rr); rr.Code != http.StatusNotFound || body.Code != "route_not_found" {
+		t.Fatalf("expected a JSON route_not_found error, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr = doJSONRequest(t, env.router, http.MethodPost, "/api/note-types/Basic/fields", map[string]string{"fieldName": "Tags"})
+	if body := decodeJSON[APIErrorResponse](t, rr); rr.Code != http.StatusBadRequest || body.Code != "validation_failed" || body.Details == nil {
+		t.Fatalf("expected validation_failed with details, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
@@ -34,10 +34,22 @@ type billingCheckoutRequest struct {
 	Plan Plan `json:"plan"`
 }
 
+// Validate lets enterprise through so the handler can point the caller to sales.
+func (req *billingCheckoutRequest) Validate(v *requestValidator) {
+	v.check(req.Plan == PlanPro || req.Plan == PlanTeam || req.Plan == PlanEnterprise, "plan", "must be pro or team")
+}
+
 type billingPortalRequest struct {
 	Plan Plan `json:"plan,omitempty"`
 }
 
+// Validate allows a missing plan, which keeps the current one.
+func (req *billingPortalRequest) Validate(v *requestValidator) {
+	if req.Plan != "" {
+		v.check(validManagedPlan(req.Plan), "plan", "must be one of free, pro, team, enterprise")
+	}
+}
+
 type googleUserInfo struct {
 	Sub     string `json:"sub"`
 	Email   string `json:"email"`
@@ -46,35 +58,88 @@ type googleUserInfo struct {
 }
 
 func registerAPIRoutes(r chi.Router, handler *APIHandler) {
+	r.Use(assignRequestID)
+	r.Use(handler.holdActiveProfile)
 	r.Use(handler.SessionMiddleware)
+	r.NotFound(respondAPINotFound)
+	r.MethodNotAllowed(respondAPIMethodNotAllowed)
 
 	r.Get("/health", handler.HealthCheck)
+	r.Get("/docs", ServeAPIDocs)
+	r.Get("/docs/openapi.json", serveOpenAPIDocument(r))
 	r.Get("/auth/session", handler.GetAuthSession)
 	r.Post("/auth/otp/request", handler.RequestOTP)
 	r.Post("/auth/otp/verify", handler.VerifyOTP)
+	r.Post("/auth/login", handler.PasswordLogin)
+	r.Get("/auth/oidc/start", handler.StartOIDCAuth)
+	r.Post("/auth/oidc/start", handler.StartOIDCAuth)
+	r.Get("/auth/oidc/callback", handler.OIDCAuthCallback)
 	r.Post("/auth/logout", handler.Logout)
 	r.Post("/marketplace/webhook", handler.MarketplaceWebhook)
 
 	r.Group(func(r chi.Router) {
 		r.Use(handler.RequireAuthenticatedUser)
-
-		r.Get("/collection", handler.GetCollection)
+		r.Use(handler.SelectRequestedCollection)
+
+		r.Put("/auth/password", handler.SetPassword)
+		r.Get("/tokens", handler.ListAPITokens)
+		r.Post("/tokens", handler.CreateAPIToken)
+		r.Delete("/tokens/{id}", handler.RevokeAPIToken)
+		r.Get("/profiles", handler.ListProfiles)
+		r.Post("/profiles", handler.CreateProfile)
+		r.Post("/profiles/{id}/activate", handler.ActivateProfile)
+		r.With(conditionalGET).Get("/collection", handler.GetCollection)
+		r.Get("/collections", handler.ListCollections)
+		r.Post("/collections", handler.CreateCollection)
+		r.Delete("/collections/{collectionId}", handler.DeleteCollection)
+		r.Get("/collection/export", handler.ExportCollectionJSON)
+		r.Get("/export/revlog.csv", handler.ExportRevlogCSV)
+		r.Get("/account/export", handler.ExportPersonalData)
+		r.Post("/maintenance/check", handler.CheckDatabase)
+		r.Post("/maintenance/cleanup", handler.CleanupOrphans)
+		r.Post("/maintenance/optimize", handler.OptimizeDatabase)
+		r.Post("/collection/import", handler.ImportCollectionJSON)
+		r.Get("/collection/package", handler.DownloadCollectionPackage)
 		r.Get("/dashboard", handler.GetDashboard)
 		r.Post("/import", handler.ImportNotes)
+		r.Post("/import/url", handler.ImportFromURL)
+		r.Post("/media", handler.UploadMedia)
+		r.Get("/media/check", handler.CheckMedia)
+		r.Post("/media/check/delete-unused", handler.DeleteUnusedMedia)
 
-		r.Get("/decks", handler.ListDecks)
+		r.With(conditionalGET).Get("/decks", handler.ListDecks)
 		r.Post("/decks", handler.CreateDeck)
-		r.Get("/decks/{id}", handler.GetDeck)
+		r.With(conditionalGET).Get("/decks/tree", handler.GetDeckTree)
+		r.With(conditionalGET).Get("/decks/{id}", handler.GetDeck)
 		r.Patch("/decks/{id}", handler.UpdateDeck)
 		r.Delete("/decks/{id}", handler.DeleteDeck)
 		r.Get("/decks/{id}/stats", handler.GetDeckStats)
-		r.Get("/decks/{deckId}/notes", handler.GetDeckNotes)
+		r.Get("/decks/{id}/export/apkg", handler.ExportDeckAPKG)
+		r.With(conditionalGET).Get("/decks/{deckId}/notes", handler.GetDeckNotes)
 		r.Get("/decks/{deckId}/due", handler.GetDueCards)
 		r.Post("/decks/{deckId}/share", handler.CreateDeckShare)
 		r.Delete("/decks/{deckId}/share", handler.DeleteDeckShare)
-
-		r.Get("/note-types", handler.ListNoteTypes)
-		r.Get("/note-types/{name}", handler.GetNoteType)
+		r.Get("/decks/{id}/grants", handler.ListDeckGrants)
+		r.Post("/decks/{id}/grants", handler.CreateDeckGrant)
+		r.Delete("/decks/{id}/grants/{grantId}", handler.DeleteDeckGrant)
+		r.Get("/shared-decks", handler.ListSharedDecks)
+		r.Get("/shared-decks/{deckId}/cards", handler.GetSharedDeckCards)
+		r.Get("/shared-decks/{deckId}/due", handler.GetSharedDeckDueCards)
+		r.Post("/shared-decks/{deckId}/cards/{cardId}/answer", handler.AnswerSharedDeckCard)
+
+		r.Get("/deck-options", handler.ListDeckOptionsPresets)
+		r.Post("/deck-options", handler.CreateDeckOptionsPreset)
+		r.Get("/deck-options/{id}", handler.GetDeckOptionsPreset)
+		r.Patch("/deck-options/{id}", handler.UpdateDeckOptionsPreset)
+		r.Delete("/deck-options/{id}", handler.DeleteDeckOptionsPreset)
+		r.Post("/deck-options/{id}/assign", handler.AssignDeckOptionsPreset)
+
+		r.With(conditionalGET).Get("/note-types", handler.ListNoteTypes)
+		r.Post("/note-types", handler.CreateNoteType)
+		r.With(conditionalGET).Get("/note-types/{name}", handler.GetNoteType)
+		r.Delete("/note-types/{name}", handler.DeleteNoteType)
+		r.Post("/note-types/{name}/clone", handler.CloneNoteType)
+		r.Post("/note-types/{name}/rename", handler.RenameNoteType)
 		r.Post("/note-types/{name}/fields", handler.AddField)
 		r.Patch("/note-types/{name}/fields/rename", handler.RenameField)
 		r.Delete("/note-types/{name}/fields", handler.RemoveField)
@@ -85,17 +150,47 @@ func registerAPIRoutes(r chi.Router, handler *APIHandler) {
 		r.Patch("/note-types/{name}/templates/{templateName}", handler.UpdateTemplate)
 		r.Delete("/note-types/{name}/templates/{templateName}", handler.DeleteTemplate)
 
-		r.Get("/notes", handler.ListNotes)
+		r.With(conditionalGET).Get("/notes", handler.ListNotes)
 		r.Post("/notes", handler.CreateNote)
-		r.Get("/notes/{id}", handler.GetNote)
+		r.With(conditionalGET).Get("/notes/{id}", handler.GetNote)
+		r.Put("/notes/{id}", handler.UpdateNote)
 		r.Patch("/notes/{id}", handler.UpdateNote)
 		r.Delete("/notes/{id}", handler.DeleteNote)
+		r.Get("/notes/{id}/occlusions", handler.GetOcclusionMasks)
+		r.Put("/notes/{id}/occlusions", handler.SetOcclusionMasks)
 		r.Post("/notes/check-duplicate", handler.CheckDuplicate)
+		r.Post("/notes/delete-by-query", handler.DeleteNotesByQuery)
+		r.Get("/search", handler.Search)
+		r.Get("/search/fulltext", handler.FullTextSearch)
+		r.Get("/tags", handler.ListTags)
+		r.Post("/tags/rename", handler.RenameTag)
 
 		r.Get("/cards/{id}", handler.GetCard)
 		r.Post("/cards/{id}/answer", handler.AnswerCard)
+		r.Get("/cards/{id}/next-intervals", handler.GetCardNextIntervals)
+		r.Post("/cards/{id}/bury", handler.BuryCard)
+		r.Post("/cards/{id}/unbury", handler.UnburyCard)
+		r.Post("/cards/answers/batch", handler.AnswerCardsBatch)
+		r.Post("/cards/{id}/check-typed", handler.CheckTypedAnswer)
 		r.Patch("/cards/{id}", handler.UpdateCard)
+		r.Post("/cards/move", handler.MoveCards)
+		r.Post("/cards/suspend", handler.SuspendCards)
+		r.Post("/cards/unsuspend", handler.UnsuspendCards)
+		r.Post("/cards/forget", handler.ForgetCards)
+		r.Get("/filtered-decks", handler.ListFilteredDecks)
+		r.Post("/filtered-decks", handler.CreateFilteredDeck)
+		r.Post("/filtered-decks/{id}/rebuild", handler.RebuildFilteredDeck)
+		r.Post("/filtered-decks/{id}/empty", handler.EmptyFilteredDeck)
+		r.Post("/cards/set-due", handler.SetCardsDue)
+		r.Post("/cards/flag", handler.FlagCards)
 		r.Get("/cards/empty", handler.FindEmptyCards)
+		r.Get("/leeches", handler.ListLeeches)
+		r.Get("/stats/workload", handler.ForecastWorkload)
+		r.Get("/stats/today", handler.GetTodayStats)
+		r.Get("/stats/answer-buttons", handler.GetAnswerButtonStats)
+		r.Get("/stats/hourly", handler.GetHourlyReviewStats)
+		r.Get("/stats/goals", handler.GetDailyGoalsProgress)
+		r.Get("/stats/distributions/{metric}", handler.GetMemoryDistribution)
 		r.Post("/cards/empty/delete", handler.DeleteEmptyCards)
 
 		r.Get("/entitlements", handler.GetEntitlements)
@@ -105,6 +200,35 @@ func registerAPIRoutes(r chi.Router, handler *APIHandler) {
 		r.Post("/study-sessions", handler.CreateStudySession)
 		r.Patch("/study-sessions/{id}", handler.UpdateStudySession)
 		r.Get("/analytics/overview", handler.GetStudyAnalyticsOverview)
+		r.Get("/study/queue", handler.GetStudyQueue)
+		r.Get("/study/cram", handler.GetCramQueue)
+		r.Post("/study/sessions", handler.StartStudySession)
+		r.Get("/study/sessions/{id}/next", handler.NextStudySessionCard)
+		r.Post("/study/sessions/{id}/answer", handler.AnswerStudySessionCard)
+		r.Post("/study/sessions/{id}/finish", handler.FinishStudySession)
+		r.Get("/preferences", handler.GetPreferences)
+		r.Patch("/preferences", handler.UpdatePreferences)
+		r.Get("/vacation", handler.GetVacation)
+		r.Put("/vacation", handler.UpdateVacation)
+		r.Delete("/vacation", handler.EndVacation)
+		r.Get("/calendar-feed", handler.GetCalendarFeedSettings)
+		r.Post("/calendar-feed", handler.CreateCalendarFeed)
+		r.Delete("/calendar-feed", handler.DeleteCalendarFeed)
+		r.Get("/graphql", handler.GraphQL)
+		r.Post("/graphql", handler.GraphQL)
+		r.Get("/changes", handler.GetChanges)
+		r.Get("/ws", handler.ServeEvents)
+		r.Get("/operations/{id}", handler.GetOperation)
+		r.Get("/operations/{id}/events", handler.StreamOperation)
+		r.Get("/sync/meta", handler.GetSyncMeta)
+		r.Get("/sync/changes", handler.GetSyncChanges)
+		r.Post("/sync/apply", handler.ApplySyncChanges)
+		r.Get("/sync/media", handler.ListSyncMedia)
+		r.Post("/sync/media/download", handler.DownloadSyncMedia)
+		r.Post("/sync/media/upload", handler.UploadSyncMedia)
+		r.Post("/sync/media/delete", handler.DeleteSyncMedia)
+		r.Get("/oplog", handler.GetOpLog)
+		r.Post("/oplog", handler.PushOpLog)
 
 		r.Post("/billing/checkout", handler.BillingCheckout)
 		r.Post("/billing/portal", handler.BillingPortal)
@@ -138,6 +262,8 @@ func registerAPIRoutes(r chi.Router, handler *APIHandler) {
 			r.Post("/installs/{installId}/update", handler.UpdateStudyGroupInstall)
 			r.Delete("/installs/{installId}", handler.RemoveStudyGroupInstall)
 			r.Get("/dashboard", handler.GetStudyGroupDashboard)
+			r.Get("/leaderboard", handler.GetStudyGroupLeaderboard)
+			r.Patch("/leaderboard", handler.UpdateStudyGroupLeaderboard)
 		})
 
 		r.Route("/marketplace", func(r chi.Router) {
@@ -160,6 +286,8 @@ func registerAPIRoutes(r chi.Router, handler *APIHandler) {
 
 		r.Post("/backups", handler.CreateBackup)
 		r.Get("/backups", handler.ListBackups)
+		r.Get("/backups/policy", handler.GetBackupPolicy)
+		r.Put("/backups/policy", handler.UpdateBackupPolicy)
 		r.Post("/backups/restore", handler.RestoreBackup)
 	})
 }
@@ -224,15 +352,15 @@ func (h *APIHandler) sessionFromRequest(r *http.Request) *SessionRecord {
 
 	cookie, err := r.Cookie(sessionCookieName)
 	if err != nil || cookie.Value == "" {
-		return nil
+		return h.sessionFromAPIToken(r)
 	}
 
-	session, err := h.store.GetSessionRecord(cookie.Value)
+	session, err := h.store.GetSessionRecord(r.Context(), cookie.Value)
 	if err != nil || session == nil {
 		return nil
 	}
 	if !session.ExpiresAt.IsZero() && session.ExpiresAt.Before(time.Now()) {
-		_ = h.store.DeleteSessionRecord(session.ID)
+		_ = h.store.DeleteSessionRecord(r.Context(), session.ID)
 		return nil
 	}
 	return session
@@ -241,11 +369,11 @@ func (h *APIHandler) sessionFromRequest(r *http.Request) *SessionRecord {
 func (h *APIHandler) SessionMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		session := h.sessionFromRequest(r)
-		if session != nil && session.UserID != "" {
+		if session != nil && session.UserID != "" && session.TokenID == "" {
 			now := time.Now()
 			session.LastSeenAt = now
 			session.ExpiresAt = now.Add(h.config.SessionTTL)
-			if err := h.store.TouchSessionRecord(session.ID, session.ExpiresAt, session.LastSeenAt); err == nil {
+			if err := h.store.TouchSessionRecord(r.Context(), session.ID, session.ExpiresAt, session.LastSeenAt); err == nil {
 				h.writeCookie(w, sessionCookieName, session.ID, session.ExpiresAt)
 			}
 		}
@@ -261,52 +389,55 @@ func (h *APIHandler) RequireAuthenticatedUser(next http.Handler) http.Handler {
 			respondAPIError(w, http.StatusUnauthorized, "auth_required", "You must be signed in to access this resource")
 			return
 		}
+		if session.TokenID != "" && !apiTokenAllows(session.TokenScope, r.Method, r.URL.Path) {
+			respondAPIError(w, http.StatusForbidden, "token_scope_forbidden", "This API token's scope does not allow this request")
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
 func (h *APIHandler) planForRequest(r *http.Request, session *SessionRecord) Plan {
-	if subscription := h.subscriptionForSession(session); subscriptionGrantsPlan(subscription, time.Now()) {
+	if subscription := h.subscriptionForSession(r.Context(), session); subscriptionGrantsPlan(subscription, time.Now()) {
 		return subscription.Plan
 	}
 	return resolvePlanFromRequest(r, session)
 }
 
-func (h *APIHandler) subscriptionForSession(session *SessionRecord) *Subscription {
+func (h *APIHandler) subscriptionForSession(ctx context.Context, session *SessionRecord) *Subscription {
 	if session == nil || strings.TrimSpace(session.WorkspaceID) == "" {
 		return nil
 	}
-	if subscription, err := h.store.GetSubscriptionForWorkspace(session.WorkspaceID); err == nil && subscription != nil {
+	if subscription, err := h.store.GetSubscriptionForWorkspace(ctx, session.WorkspaceID); err == nil && subscription != nil {
 		return subscription
 	}
-	workspace, err := h.store.GetWorkspaceRecord(session.WorkspaceID)
+	workspace, err := h.store.GetWorkspaceRecord(ctx, session.WorkspaceID)
 	if err != nil || workspace == nil || strings.TrimSpace(workspace.OrganizationID) == "" {
 		return nil
 	}
-	if subscription, err := h.store.GetSubscriptionForOrganization(workspace.OrganizationID); err == nil {
+	if subscription, err := h.store.GetSubscriptionForOrganization(ctx, workspace.OrganizationID); err == nil {
 		return subscription
 	}
 	return nil
 }
 
-func (h *APIHandler) workspaceForSession(session *SessionRecord) (*Workspace, error) {
+func (h *APIHandler) workspaceForSession(ctx context.Context, session *SessionRecord) (*Workspace, error) {
 	if session == nil || strings.TrimSpace(session.WorkspaceID) == "" {
 		return nil, nil
 	}
-	return h.store.GetWorkspaceRecord(session.WorkspaceID)
+	return h.store.GetWorkspaceRecord(ctx, session.WorkspaceID)
 }
 
 func (h *APIHandler) collectionIDForRequest(r *http.Request) string {
-	session := h.sessionFromRequest(r)
-	if workspace, err := h.workspaceForSession(session); err == nil && workspace != nil && strings.TrimSpace(workspace.CollectionID) != "" {
-		return workspace.CollectionID
+	if collectionID, ok := r.Context().Value(collectionContextKey).(string); ok {
+		return collectionID
 	}
-	return h.collectionID
+	return h.workspaceCollectionID(r.Context(), h.sessionFromRequest(r))
 }
 
 func (h *APIHandler) collectionForRequest(r *http.Request) (*Collection, string, error) {
 	collectionID := h.collectionIDForRequest(r)
-	col, err := h.store.GetCollection(collectionID)
+	col, err := h.store.GetCollection(r.Context(), collectionID)
 	if err != nil {
 		return nil, collectionID, err
 	}
@@ -320,7 +451,7 @@ func (h *APIHandler) collectionForRequest(r *http.Request) (*Collection, string,
 	return col, collectionID, nil
 }
 
-func (h *APIHandler) usageForSession(session *SessionRecord) EntitlementUsage {
+func (h *APIHandler) usageForSession(ctx context.Context, session *SessionRecord) EntitlementUsage {
 	usage := EntitlementUsage{
 		Decks:      len(h.collection.Decks),
 		Notes:      len(h.collection.Notes),
@@ -329,22 +460,22 @@ func (h *APIHandler) usageForSession(session *SessionRecord) EntitlementUsage {
 	if session == nil || session.WorkspaceID == "" {
 		return usage
 	}
-	if workspace, err := h.workspaceForSession(session); err == nil && workspace != nil {
-		if col, err := h.store.GetCollection(workspace.CollectionID); err == nil {
+	if workspace, err := h.workspaceForSession(ctx, session); err == nil && workspace != nil {
+		if col, err := h.store.GetCollection(ctx, workspace.CollectionID); err == nil {
 			usage.Decks = len(col.Decks)
 			usage.Notes = len(col.Notes)
 			usage.CardsTotal = len(col.Cards)
 		}
 	}
 
-	if count, err := h.store.CountDeckSharesForWorkspace(session.WorkspaceID); err == nil {
+	if count, err := h.store.CountDeckSharesForWorkspace(ctx, session.WorkspaceID); err == nil {
 		usage.SharedDecks = count
 	}
-	if count, err := h.store.CountSyncDevicesForWorkspace(session.WorkspaceID); err == nil {
+	if count, err := h.store.CountSyncDevicesForWorkspace(ctx, session.WorkspaceID); err == nil {
 		usage.SyncDevices = count
 	}
 	if session.UserID != "" {
-		if count, err := h.store.CountWorkspacesForUser(session.UserID); err == nil {
+		if count, err := h.store.CountWorkspacesForUser(ctx, session.UserID); err == nil {
 			usage.Workspaces = count
 		}
 	}
@@ -357,32 +488,38 @@ func (h *APIHandler) buildSessionResponse(r *http.Request) AuthSessionResponse {
 	if session != nil {
 		plan = h.planForRequest(r, session)
 	}
-	entitlements := entitlementsForPlan(plan, h.usageForSession(session))
+	entitlements := entitlementsForPlan(plan, h.usageForSession(r.Context(), session))
 
 	response := AuthSessionResponse{
 		Authenticated:        session != nil && session.UserID != "",
 		GoogleAuthConfigured: false,
 		OTPAuthEnabled:       true,
+		PasswordAuthEnabled:  true,
+		OIDCAuthEnabled:      h.config.OIDC.Configured(),
 		Entitlements:         entitlements,
 	}
+	if response.OIDCAuthEnabled {
+		response.OIDCProviderName = h.config.OIDC.ProviderName
+	}
 
 	if session == nil {
 		return response
 	}
 
-	response.Subscription = h.subscriptionForSession(session)
+	response.Subscription = h.subscriptionForSession(r.Context(), session)
 
-	if user, err := h.store.GetUserByID(session.UserID); err == nil {
+	if user, err := h.store.GetUserByID(r.Context(), session.UserID); err == nil {
 		response.User = user
+		response.PasswordSet = h.hasPassword(r.Context(), user.ID)
 	}
 	if session.WorkspaceID != "" {
-		if workspace, err := h.store.GetWorkspaceRecord(session.WorkspaceID); err == nil {
+		if workspace, err := h.store.GetWorkspaceRecord(r.Context(), session.WorkspaceID); err == nil {
 			response.Workspace = workspace
 			if workspace.OrganizationID != "" {
-				if org, err := h.store.GetOrganizationRecord(workspace.OrganizationID); err == nil {
+				if org, err := h.store.GetOrganizationRecord(r.Context(), workspace.OrganizationID); err == nil {
 					response.Organization = org
 				}
-				if member, err := h.store.GetOrganizationMemberByUser(workspace.OrganizationID, session.UserID); err == nil {
+				if member, err := h.store.GetOrganizationMemberByUser(r.Context(), workspace.OrganizationID, session.UserID); err == nil {
 					response.OrganizationMember = member
 				}
 			}
@@ -392,8 +529,8 @@ func (h *APIHandler) buildSessionResponse(r *http.Request) AuthSessionResponse {
 	return response
 }
 
-func (h *APIHandler) ensureDefaultWorkspaceForUser(user *User) (*Workspace, error) {
-	workspace, err := h.store.GetFirstWorkspaceForUser(user.ID)
+func (h *APIHandler) ensureDefaultWorkspaceForUser(ctx context.Context, user *User) (*Workspace, error) {
+	workspace, err := h.store.GetFirstWorkspaceForUser(ctx, user.ID)
 	if err == nil {
 		return workspace, nil
 	}
@@ -404,22 +541,7 @@ func (h *APIHandler) ensureDefaultWorkspaceForUser(user *User) (*Workspace, erro
 	now := time.Now()
 	collectionID := newID("col")
 	collectionName := fmt.Sprintf("%s Collection", firstNonEmpty(user.DisplayName, "Vutadex"))
-	collection := NewCollection()
-	if err := h.store.CreateCollectionRecord(collectionID, collectionName, collection); err != nil {
-		return nil, err
-	}
-	seedCollection, err := h.store.GetCollection(collectionID)
-	if err != nil {
-		return nil, err
-	}
-	for _, nt := range builtins() {
-		ntCopy := nt
-		if err := h.store.CreateNoteType(collectionID, &ntCopy); err != nil {
-			return nil, err
-		}
-	}
-	defaultDeck := seedCollection.NewDeck("Default")
-	if err := h.store.CreateDeckInCollection(collectionID, defaultDeck); err != nil {
+	if err := h.store.createStarterCollection(ctx, collectionID, collectionName); err != nil {
 		return nil, err
 	}
 
@@ -432,7 +554,7 @@ func (h *APIHandler) ensureDefaultWorkspaceForUser(user *User) (*Workspace, erro
 		CreatedAt:    now,
 		UpdatedAt:    now,
 	}
-	return workspace, h.store.CreateWorkspaceRecord(workspace)
+	return workspace, h.store.CreateWorkspaceRecord(ctx, workspace)
 }
 
 func (h *APIHandler) GetAuthSession(w http.ResponseWriter, r *http.Request) {
@@ -471,7 +593,7 @@ func (h *APIHandler) GetDeckNotes(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	summaries, err := h.store.ListRecentDeckNotes(h.collectionIDForRequest(r), deckID, limit, cursorCreatedAt, cursorNoteID)
+	summaries, err := h.store.ListRecentDeckNotes(r.Context(), h.collectionIDForRequest(r), deckID, limit, cursorCreatedAt, cursorNoteID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "deck_notes_failed", err.Error())
 		return
@@ -535,13 +657,13 @@ func (h *APIHandler) GoogleAuthCallback(w http.ResponseWriter, r *http.Request)
 	}
 
 	config := googleOAuthConfig()
-	token, err := config.Exchange(context.Background(), code, oauth2.SetAuthURLParam("code_verifier", verifierCookie.Value))
+	token, err := config.Exchange(r.Context(), code, oauth2.SetAuthURLParam("code_verifier", verifierCookie.Value))
 	if err != nil {
 		respondAPIError(w, http.StatusBadGateway, "oauth_exchange_failed", err.Error())
 		return
 	}
 
-	client := config.Client(context.Background(), token)
+	client := config.Client(r.Context(), token)
 	resp, err := client.Get("https://www.googleapis.com/oauth2/v3/userinfo")
 	if err != nil {
 		respondAPIError(w, http.StatusBadGateway, "oauth_userinfo_failed", err.Error())
@@ -567,7 +689,7 @@ func (h *APIHandler) GoogleAuthCallback(w http.ResponseWriter, r *http.Request)
 	}
 
 	now := time.Now()
-	user, err := h.store.GetUserByOAuth("google", googleUser.Sub)
+	user, err := h.store.GetUserByOAuth(r.Context(), "google", googleUser.Sub)
 	if err == sql.ErrNoRows {
 		user = &User{
 			ID:          newID("usr"),
@@ -578,7 +700,7 @@ func (h *APIHandler) GoogleAuthCallback(w http.ResponseWriter, r *http.Request)
 			CreatedAt:   now,
 			UpdatedAt:   now,
 		}
-		if err := h.store.CreateUser(user); err != nil {
+		if err := h.store.CreateUser(r.Context(), user); err != nil {
 			respondAPIError(w, http.StatusInternalServerError, "user_create_failed", err.Error())
 			return
 		}
@@ -587,7 +709,7 @@ func (h *APIHandler) GoogleAuthCallback(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.store.UpsertOAuthIdentity(&OAuthIdentity{
+	if err := h.store.UpsertOAuthIdentity(r.Context(), &OAuthIdentity{
 		ID:        newID("oauth"),
 		UserID:    user.ID,
 		Provider:  "google",
@@ -599,7 +721,7 @@ func (h *APIHandler) GoogleAuthCallback(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	workspace, err := h.ensureDefaultWorkspaceForUser(user)
+	workspace, err := h.ensureDefaultWorkspaceForUser(r.Context(), user)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "workspace_create_failed", err.Error())
 		return
@@ -614,7 +736,7 @@ func (h *APIHandler) GoogleAuthCallback(w http.ResponseWriter, r *http.Request)
 		LastSeenAt:  now,
 		CreatedAt:   now,
 	}
-	if err := h.store.CreateSessionRecord(session); err != nil {
+	if err := h.store.CreateSessionRecord(r.Context(), session); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "session_create_failed", err.Error())
 		return
 	}
@@ -627,10 +749,14 @@ func (h *APIHandler) GoogleAuthCallback(w http.ResponseWriter, r *http.Request)
 	http.Redirect(w, r, redirectPath, http.StatusTemporaryRedirect)
 }
 
+// Logout ends the cookie session. An API token is revoked through /api/tokens instead.
 func (h *APIHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	session := h.sessionFromRequest(r)
+	if session != nil && !requireSessionLogin(w, session) {
+		return
+	}
 	if session != nil {
-		_ = h.store.DeleteSessionRecord(session.ID)
+		_ = h.store.DeleteSessionRecord(r.Context(), session.ID)
 	}
 	h.clearCookie(w, sessionCookieName)
 	respondJSON(w, http.StatusOK, map[string]bool{"ok": true})
@@ -644,8 +770,7 @@ func (h *APIHandler) ImportLocalCollection(w http.ResponseWriter, r *http.Reques
 	}
 
 	var req ImportLocalCollectionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
@@ -668,21 +793,16 @@ func (h *APIHandler) CreateOrganization(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	plan := h.planForRequest(r, session)
-	if !entitlementsForPlan(plan, h.usageForSession(session)).Features.Organizations {
+	if !entitlementsForPlan(plan, h.usageForSession(r.Context(), session)).Features.Organizations {
 		respondAPIError(w, http.StatusForbidden, "organization_not_available", "Team creation requires a Team or Enterprise plan.")
 		return
 	}
 
 	var req CreateOrganizationRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
-		return
-	}
-	if strings.TrimSpace(req.Name) == "" {
-		respondAPIError(w, http.StatusBadRequest, "invalid_name", "Organization name is required")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
-	workspace, err := h.workspaceForSession(session)
+	workspace, err := h.workspaceForSession(r.Context(), session)
 	if err != nil || workspace == nil {
 		respondAPIError(w, http.StatusInternalServerError, "workspace_not_found", "Workspace not found.")
 		return
@@ -695,7 +815,7 @@ func (h *APIHandler) CreateOrganization(w http.ResponseWriter, r *http.Request)
 		respondAPIError(w, http.StatusForbidden, "organization_forbidden", "Only the workspace owner can turn this workspace into a team.")
 		return
 	}
-	user, err := h.store.GetUserByID(session.UserID)
+	user, err := h.store.GetUserByID(r.Context(), session.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "user_lookup_failed", err.Error())
 		return
@@ -709,7 +829,7 @@ func (h *APIHandler) CreateOrganization(w http.ResponseWriter, r *http.Request)
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
-	if err := h.store.CreateOrganizationRecord(org); err != nil {
+	if err := h.store.CreateOrganizationRecord(r.Context(), org); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "organization_create_failed", err.Error())
 		return
 	}
@@ -724,15 +844,15 @@ func (h *APIHandler) CreateOrganization(w http.ResponseWriter, r *http.Request)
 		JoinedAt:       now,
 		CreatedAt:      now,
 	}
-	if err := h.store.CreateOrganizationMemberRecord(member); err != nil {
+	if err := h.store.CreateOrganizationMemberRecord(r.Context(), member); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "organization_member_failed", err.Error())
 		return
 	}
-	if err := h.ensureWorkspaceAttachedToOrganization(workspace, org); err != nil {
+	if err := h.ensureWorkspaceAttachedToOrganization(r.Context(), workspace, org); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "workspace_attach_failed", err.Error())
 		return
 	}
-	detail, err := h.buildOrganizationDetail(org.ID, session.UserID)
+	detail, err := h.buildOrganizationDetail(r.Context(), org.ID, session.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "organization_detail_failed", err.Error())
 		return
@@ -748,34 +868,22 @@ func (h *APIHandler) AddOrganizationMember(w http.ResponseWriter, r *http.Reques
 	}
 
 	orgID := chi.URLParam(r, "orgId")
-	if _, err := h.store.GetOrganizationRecord(orgID); err != nil {
+	if _, err := h.store.GetOrganizationRecord(r.Context(), orgID); err != nil {
 		respondAPIError(w, http.StatusNotFound, "organization_not_found", "Organization not found")
 		return
 	}
-	currentMember, err := h.store.GetOrganizationMemberByUser(orgID, session.UserID)
+	currentMember, err := h.store.GetOrganizationMemberByUser(r.Context(), orgID, session.UserID)
 	if err != nil || currentMember.Status != "active" || !canManageOrganizationMembers(currentMember.Role) {
 		respondAPIError(w, http.StatusForbidden, "organization_forbidden", "Only team admins and owners can invite members.")
 		return
 	}
 
 	var req AddOrganizationMemberRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
-		return
-	}
-	email, err := normalizeEmail(req.Email)
-	if err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_email", err.Error())
-		return
-	}
-	role := strings.TrimSpace(req.Role)
-	if role == "" {
-		role = "read"
-	}
-	if !validOrganizationRole(role) || role == "owner" {
-		respondAPIError(w, http.StatusBadRequest, "invalid_role", "Role must be read, edit, or admin.")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
+	email, _ := normalizeEmail(req.Email)
+	role := firstNonEmpty(strings.TrimSpace(req.Role), "read")
 
 	now := time.Now()
 	member := &OrganizationMember{
@@ -788,7 +896,7 @@ func (h *APIHandler) AddOrganizationMember(w http.ResponseWriter, r *http.Reques
 		InviteExpiresAt: now.Add(organizationInviteTTL),
 		CreatedAt:       now,
 	}
-	if err := h.store.UpsertOrganizationInvitation(member); err != nil {
+	if err := h.store.UpsertOrganizationInvitation(r.Context(), member); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "organization_member_failed", err.Error())
 		return
 	}
@@ -799,7 +907,7 @@ func (h *APIHandler) AddOrganizationMember(w http.ResponseWriter, r *http.Reques
 func (h *APIHandler) CreateDeckShare(w http.ResponseWriter, r *http.Request) {
 	session := h.sessionFromRequest(r)
 	plan := h.planForRequest(r, session)
-	usage := h.usageForSession(session)
+	usage := h.usageForSession(r.Context(), session)
 	if !entitlementsForPlan(plan, usage).Features.ShareDecks {
 		respondAPIError(w, http.StatusForbidden, "plan_limit_exceeded", "Deck sharing requires a Pro or Team plan")
 		return
@@ -814,14 +922,13 @@ func (h *APIHandler) CreateDeckShare(w http.ResponseWriter, r *http.Request) {
 		respondAPIError(w, http.StatusBadRequest, "invalid_deck_id", "Invalid deck ID")
 		return
 	}
-	if _, err := h.store.GetDeck(deckID); err != nil {
+	if _, err := h.store.GetDeck(r.Context(), deckID); err != nil {
 		respondAPIError(w, http.StatusNotFound, "deck_not_found", "Deck not found")
 		return
 	}
 
 	var req ShareDeckRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeOptionalValidatedRequest(w, r, &req) {
 		return
 	}
 
@@ -837,7 +944,7 @@ func (h *APIHandler) CreateDeckShare(w http.ResponseWriter, r *http.Request) {
 		share.WorkspaceID = session.WorkspaceID
 		share.CreatedByUserID = session.UserID
 	}
-	if err := h.store.CreateDeckShareRecord(share); err != nil {
+	if err := h.store.CreateDeckShareRecord(r.Context(), share); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "deck_share_failed", err.Error())
 		return
 	}
@@ -855,7 +962,7 @@ func (h *APIHandler) DeleteDeckShare(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.store.DeleteDeckShareByDeckID(deckID); err != nil {
+	if err := h.store.DeleteDeckShareByDeckID(r.Context(), deckID); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "deck_share_delete_failed", err.Error())
 		return
 	}
@@ -23,7 +23,7 @@ import (
 )
 
 type apiTestEnv struct {
-	store      *SQLiteStore
+	store      *SQLStore
 	collection *Collection
 	handler    *APIHandler
 	router     http.Handler
@@ -53,6 +53,7 @@ func (s *otpEmailStub) SendOTP(_ context.Context, to, code string, expiresAt tim
 	return nil
 }
 
+// setupAPITestEnv serves a fresh MemoryStore through the API.
 func setupAPITestEnv(t *testing.T) *apiTestEnv {
 	t.Helper()
 	return setupAPITestEnvWithConfig(t, mustLocalAppConfig())
@@ -60,6 +61,24 @@ func setupAPITestEnv(t *testing.T) *apiTestEnv {
 
 func setupAPITestEnvWithConfig(t *testing.T, cfg AppConfig) *apiTestEnv {
 	t.Helper()
+	store := newMemoryStoreForTest(t)
+	// As for any database that is not a SQLite file, the backup manager has no file to
+	// back up, but still writes collection packages.
+	backupDir := filepath.Join(t.TempDir(), "backups")
+	env := seedAPITestEnv(t, store, cfg, NewBackupManager("", backupDir, store))
+	env.store, env.backupDir = store.SQLStore, backupDir
+	return env
+}
+
+// setupFileAPITestEnv serves a SQLite file through the API, with a backup manager, for
+// tests of what needs the database on disk: backups, restores, and profiles.
+func setupFileAPITestEnv(t *testing.T) *apiTestEnv {
+	t.Helper()
+	return setupFileAPITestEnvWithConfig(t, mustLocalAppConfig())
+}
+
+func setupFileAPITestEnvWithConfig(t *testing.T, cfg AppConfig) *apiTestEnv {
+	t.Helper()
 
 	tempDir := t.TempDir()
 	dbPath := filepath.Join(tempDir, "microdote-test.db")
@@ -72,23 +91,32 @@ func setupAPITestEnvWithConfig(t *testing.T, cfg AppConfig) *apiTestEnv {
 	t.Cleanup(func() {
 		_ = store.Close()
 	})
+	env := seedAPITestEnv(t, store, cfg, NewBackupManager(dbPath, backupDir, store))
+	env.store, env.dbPath, env.backupDir = store, dbPath, backupDir
+	return env
+}
+
+// seedAPITestEnv gives store the default collection and a signed-in user, and serves
+// it through a handler.
+func seedAPITestEnv(t *testing.T, store Store, cfg AppConfig, backupMgr *BackupManager) *apiTestEnv {
+	t.Helper()
 
 	col := NewCollection()
 	col.NoteTypes = builtins()
 
-	if err := store.CreateCollection(col); err != nil {
+	if err := store.CreateCollection(context.Background(), col); err != nil {
 		t.Fatalf("failed to create collection: %v", err)
 	}
 	for _, nt := range col.NoteTypes {
 		ntCopy := nt
-		if err := store.CreateNoteType("default", &ntCopy); err != nil {
+		if err := store.CreateNoteType(context.Background(), "default", &ntCopy); err != nil {
 			t.Fatalf("failed to create note type %s: %v", nt.Name, err)
 		}
 	}
 
 	// Ensure deck 1 always exists for tests that assume a valid deck target.
 	deck := col.NewDeck("Default")
-	if err := store.CreateDeck(deck); err != nil {
+	if err := store.CreateDeck(context.Background(), deck); err != nil {
 		t.Fatalf("failed to create default deck: %v", err)
 	}
 
@@ -101,7 +129,7 @@ func setupAPITestEnvWithConfig(t *testing.T, cfg AppConfig) *apiTestEnv {
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
-	if err := store.CreateUser(user); err != nil {
+	if err := store.CreateUser(context.Background(), user); err != nil {
 		t.Fatalf("failed to create test user: %v", err)
 	}
 
@@ -114,7 +142,7 @@ func setupAPITestEnvWithConfig(t *testing.T, cfg AppConfig) *apiTestEnv {
 		CreatedAt:    now,
 		UpdatedAt:    now,
 	}
-	if err := store.CreateWorkspaceRecord(workspace); err != nil {
+	if err := store.CreateWorkspaceRecord(context.Background(), workspace); err != nil {
 		t.Fatalf("failed to create test workspace: %v", err)
 	}
 
@@ -127,21 +155,18 @@ func setupAPITestEnvWithConfig(t *testing.T, cfg AppConfig) *apiTestEnv {
 		LastSeenAt:  now,
 		CreatedAt:   now,
 	}
-	if err := store.CreateSessionRecord(session); err != nil {
+	if err := store.CreateSessionRecord(context.Background(), session); err != nil {
 		t.Fatalf("failed to create test session: %v", err)
 	}
 
-	handler := NewAPIHandlerWithConfig(store, col, NewBackupManager(dbPath, backupDir, store), cfg, NewEmailSender(cfg))
+	handler := NewAPIHandlerWithConfig(store, col, backupMgr, cfg, NewEmailSender(cfg))
 	authCookie := fmt.Sprintf("%s=%s", sessionCookieName, session.ID)
 	router := newTestAPIRouter(handler, authCookie)
 
 	return &apiTestEnv{
-		store:      store,
 		collection: col,
 		handler:    handler,
 		router:     router,
-		dbPath:     dbPath,
-		backupDir:  backupDir,
 		authCookie: authCookie,
 	}
 }
@@ -186,7 +211,7 @@ func createAuthenticatedTestClient(t *testing.T, env *apiTestEnv, email, display
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
-	if err := env.store.CreateUser(user); err != nil {
+	if err := env.store.CreateUser(context.Background(), user); err != nil {
 		t.Fatalf("failed to create test user %s: %v", email, err)
 	}
 
@@ -199,7 +224,7 @@ func createAuthenticatedTestClient(t *testing.T, env *apiTestEnv, email, display
 		CreatedAt:    now,
 		UpdatedAt:    now,
 	}
-	if err := env.store.CreateWorkspaceRecord(workspace); err != nil {
+	if err := env.store.CreateWorkspaceRecord(context.Background(), workspace); err != nil {
 		t.Fatalf("failed to create test workspace for %s: %v", email, err)
 	}
 
@@ -212,7 +237,7 @@ func createAuthenticatedTestClient(t *testing.T, env *apiTestEnv, email, display
 		LastSeenAt:  now,
 		CreatedAt:   now,
 	}
-	if err := env.store.CreateSessionRecord(session); err != nil {
+	if err := env.store.CreateSessionRecord(context.Background(), session); err != nil {
 		t.Fatalf("failed to create test session for %s: %v", email, err)
 	}
 
@@ -238,13 +263,13 @@ func createAuthenticatedIsolatedTestClient(t *testing.T, env *apiTestEnv, email,
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
-	if err := env.store.CreateUser(user); err != nil {
+	if err := env.store.CreateUser(context.Background(), user); err != nil {
 		t.Fatalf("failed to create isolated test user %s: %v", email, err)
 	}
 
 	collectionID := newID("col")
 	collection := NewCollection()
-	if err := env.store.CreateCollectionRecord(collectionID, displayName+" Collection", collection); err != nil {
+	if err := env.store.CreateCollectionRecord(context.Background(), collectionID, displayName+" Collection", collection); err != nil {
 		t.Fatalf("failed to create isolated collection for %s: %v", email, err)
 	}
 
@@ -257,7 +282,7 @@ func createAuthenticatedIsolatedTestClient(t *testing.T, env *apiTestEnv, email,
 		CreatedAt:    now,
 		UpdatedAt:    now,
 	}
-	if err := env.store.CreateWorkspaceRecord(workspace); err != nil {
+	if err := env.store.CreateWorkspaceRecord(context.Background(), workspace); err != nil {
 		t.Fatalf("failed to create isolated workspace for %s: %v", email, err)
 	}
 
@@ -270,7 +295,7 @@ func createAuthenticatedIsolatedTestClient(t *testing.T, env *apiTestEnv, email,
 		LastSeenAt:  now,
 		CreatedAt:   now,
 	}
-	if err := env.store.CreateSessionRecord(session); err != nil {
+	if err := env.store.CreateSessionRecord(context.Background(), session); err != nil {
 		t.Fatalf("failed to create isolated session for %s: %v", email, err)
 	}
 
@@ -298,7 +323,7 @@ func activateWorkspaceSubscriptionForTest(t *testing.T, env *apiTestEnv, workspa
 		UpdatedAt:        now,
 		CurrentPeriodEnd: now.Add(30 * 24 * time.Hour),
 	}
-	if err := env.store.UpsertSubscription(subscription); err != nil {
+	if err := env.store.UpsertSubscription(context.Background(), subscription); err != nil {
 		t.Fatalf("failed to create active workspace subscription for %s: %v", workspaceID, err)
 	}
 }
@@ -567,7 +592,7 @@ func TestAPI_DeckAndCollectionEndpoints(t *testing.T) {
 func TestAPI_DeckWorkloadPolicy_DefaultCapPauseRuleAndPriority(t *testing.T) {
 	env := setupAPITestEnv(t)
 	sessionID := strings.TrimPrefix(env.authCookie, sessionCookieName+"=")
-	sessionRecord, err := env.store.GetSessionRecord(sessionID)
+	sessionRecord, err := env.store.GetSessionRecord(context.Background(), sessionID)
 	if err != nil {
 		t.Fatalf("failed to load current session: %v", err)
 	}
@@ -859,6 +884,38 @@ func TestAPI_NoteAndCardEndpoints(t *testing.T) {
 	}
 }
 
+func TestAPI_CreateNoteRollsBackWhenCardsFail(t *testing.T) {
+	env := setupAPITestEnv(t)
+	deckCards := len(env.handler.collection.Decks[1].Cards)
+	if _, err := env.store.db.Exec(`CREATE TRIGGER reject_cards BEFORE INSERT ON cards BEGIN SELECT RAISE(ABORT, 'card rejected'); END`); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/notes", CreateNoteRequest{TypeID: "Basic", DeckID: 1, FieldVals: map[string]string{"Front": "half", "Back": "written"}})
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected create note 500, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	var notes int
+	if err := env.store.db.QueryRow(`SELECT COUNT(*) FROM notes`).Scan(&notes); err != nil {
+		t.Fatal(err)
+	}
+	if notes != 0 {
+		t.Fatalf("expected the note rolled back, found %d notes", notes)
+	}
+	col := env.handler.collection
+	if len(col.Notes) != 0 || len(col.Cards) != 0 || len(col.Decks[1].Cards) != deckCards {
+		t.Fatalf("expected the collection unchanged, got %d notes, %d cards, deck cards %v", len(col.Notes), len(col.Cards), col.Decks[1].Cards)
+	}
+
+	if _, err := env.store.db.Exec(`DROP TRIGGER reject_cards`); err != nil {
+		t.Fatal(err)
+	}
+	created := createNoteForTest(t, env, CreateNoteRequest{TypeID: "Basic", DeckID: 1, FieldVals: map[string]string{"Front": "whole", "Back": "note"}}, nil)
+	if len(created.Cards) != 1 {
+		t.Fatalf("expected the next note to save, got %+v", created)
+	}
+}
+
 func TestAPI_StudySessionLifecycle(t *testing.T) {
 	env := setupAPITestEnv(t)
 
@@ -913,7 +970,7 @@ func TestAPI_StudySessionLifecycle(t *testing.T) {
 		t.Fatalf("expected completed study session to have endedAt set")
 	}
 
-	reloaded, err := env.store.GetStudySession(session.ID)
+	reloaded, err := env.store.GetStudySession(context.Background(), session.ID)
 	if err != nil {
 		t.Fatalf("failed to reload study session: %v", err)
 	}
@@ -1041,7 +1098,7 @@ func TestAPI_GetDeckNotesReturnsRecentDistinctNotesForDeck(t *testing.T) {
 	env := setupAPITestEnv(t)
 
 	otherDeck := env.collection.NewDeck("Other Deck")
-	if err := env.store.CreateDeck(otherDeck); err != nil {
+	if err := env.store.CreateDeck(context.Background(), otherDeck); err != nil {
 		t.Fatalf("failed to create other deck: %v", err)
 	}
 
@@ -1149,7 +1206,7 @@ func TestAPI_SharedCardsKeepPerUserDueQueuesSeparate(t *testing.T) {
 	}
 
 	answer := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", cardID), AnswerCardRequest{
-		Rating:      3,
+		Rating:      4,
 		TimeTakenMs: 900,
 	})
 	if answer.Code != http.StatusOK {
@@ -1197,7 +1254,7 @@ func TestAPI_SharedCardsKeepPerUserDueQueuesSeparate(t *testing.T) {
 		t.Fatalf("failed to query revlog user_id: %v", err)
 	}
 	sessionID := strings.TrimPrefix(env.authCookie, sessionCookieName+"=")
-	sessionRecord, err := env.store.GetSessionRecord(sessionID)
+	sessionRecord, err := env.store.GetSessionRecord(context.Background(), sessionID)
 	if err != nil {
 		t.Fatalf("failed to load primary test session: %v", err)
 	}
@@ -1519,7 +1576,7 @@ func TestAPI_StudyGroupsUsePublishedVersionsAndPersonalInstalls(t *testing.T) {
 		t.Fatalf("expected active clean version 2 install after update, got %+v", memberInstallV2)
 	}
 
-	oldInstall, err := env.store.GetStudyGroupInstall(memberInstall.ID)
+	oldInstall, err := env.store.GetStudyGroupInstall(context.Background(), memberInstall.ID)
 	if err != nil {
 		t.Fatalf("failed to reload superseded install: %v", err)
 	}
@@ -1527,14 +1584,14 @@ func TestAPI_StudyGroupsUsePublishedVersionsAndPersonalInstalls(t *testing.T) {
 		t.Fatalf("expected original install to be superseded by the new install, got %+v", oldInstall)
 	}
 
-	oldNotes, oldCards, err := env.store.GetDeckContentSummary(memberInstall.InstalledDeckID)
+	oldNotes, oldCards, err := env.store.GetDeckContentSummary(context.Background(), memberInstall.InstalledDeckID)
 	if err != nil {
 		t.Fatalf("failed to read original install summary: %v", err)
 	}
 	if oldNotes != 1 || oldCards != 1 {
 		t.Fatalf("expected original install copy to remain intact on version 1, got notes=%d cards=%d", oldNotes, oldCards)
 	}
-	newNotes, newCards, err := env.store.GetDeckContentSummary(memberInstallV2.InstalledDeckID)
+	newNotes, newCards, err := env.store.GetDeckContentSummary(context.Background(), memberInstallV2.InstalledDeckID)
 	if err != nil {
 		t.Fatalf("failed to read updated install summary: %v", err)
 	}
@@ -1608,14 +1665,14 @@ func TestAPI_StudyGroupsUsePublishedVersionsAndPersonalInstalls(t *testing.T) {
 	if removeInstallRR.Code != http.StatusNoContent {
 		t.Fatalf("expected remove install 204, got %d (%s)", removeInstallRR.Code, removeInstallRR.Body.String())
 	}
-	removedInstall, err := env.store.GetStudyGroupInstall(memberInstallV2.ID)
+	removedInstall, err := env.store.GetStudyGroupInstall(context.Background(), memberInstallV2.ID)
 	if err != nil {
 		t.Fatalf("failed to reload removed install: %v", err)
 	}
 	if removedInstall.Status != "removed" {
 		t.Fatalf("expected removed install status=removed, got %+v", removedInstall)
 	}
-	if _, err := env.store.GetDeck(memberInstallV2.InstalledDeckID); err == nil {
+	if _, err := env.store.GetDeck(context.Background(), memberInstallV2.InstalledDeckID); err == nil {
 		t.Fatalf("expected removing an install to delete its copied deck %d", memberInstallV2.InstalledDeckID)
 	}
 }
@@ -1890,7 +1947,7 @@ func TestAPI_MarketplaceCheckoutSessionSyncCompletesStripeOrder(t *testing.T) {
 		CreatedAt:         time.Now(),
 		UpdatedAt:         time.Now(),
 	}
-	if err := env.store.UpsertMarketplaceCreatorAccount(creatorAccount); err != nil {
+	if err := env.store.UpsertMarketplaceCreatorAccount(context.Background(), creatorAccount); err != nil {
 		t.Fatalf("failed to seed creator account: %v", err)
 	}
 
@@ -1970,7 +2027,7 @@ func TestAPI_MarketplaceCheckoutSessionSyncCompletesStripeOrder(t *testing.T) {
 		UpdatedAt:                 time.Now(),
 	}
 	order.CreatorAmountCents = order.AmountCents - order.PlatformFeeCents
-	if err := env.store.CreateMarketplaceOrder(order); err != nil {
+	if err := env.store.CreateMarketplaceOrder(context.Background(), order); err != nil {
 		t.Fatalf("failed to seed marketplace order: %v", err)
 	}
 
@@ -2026,7 +2083,7 @@ func TestAPI_MarketplaceWebhookCompletesOrderAndUpdatesCreatorAccount(t *testing
 		CreatedAt:         time.Now(),
 		UpdatedAt:         time.Now(),
 	}
-	if err := env.store.UpsertMarketplaceCreatorAccount(creatorAccount); err != nil {
+	if err := env.store.UpsertMarketplaceCreatorAccount(context.Background(), creatorAccount); err != nil {
 		t.Fatalf("failed to seed creator account: %v", err)
 	}
 
@@ -2076,7 +2133,7 @@ func TestAPI_MarketplaceWebhookCompletesOrderAndUpdatesCreatorAccount(t *testing
 	creatorAccount.PayoutsEnabled = true
 	creatorAccount.OnboardingStatus = "active"
 	creatorAccount.UpdatedAt = time.Now()
-	if err := env.store.UpsertMarketplaceCreatorAccount(creatorAccount); err != nil {
+	if err := env.store.UpsertMarketplaceCreatorAccount(context.Background(), creatorAccount); err != nil {
 		t.Fatalf("failed to activate creator account: %v", err)
 	}
 
@@ -2106,7 +2163,7 @@ func TestAPI_MarketplaceWebhookCompletesOrderAndUpdatesCreatorAccount(t *testing
 		UpdatedAt:                 time.Now(),
 	}
 	order.CreatorAmountCents = order.AmountCents - order.PlatformFeeCents
-	if err := env.store.CreateMarketplaceOrder(order); err != nil {
+	if err := env.store.CreateMarketplaceOrder(context.Background(), order); err != nil {
 		t.Fatalf("failed to seed marketplace order: %v", err)
 	}
 
@@ -2128,7 +2185,7 @@ func TestAPI_MarketplaceWebhookCompletesOrderAndUpdatesCreatorAccount(t *testing
 		t.Fatalf("expected account webhook 200, got %d (%s)", accountRR.Code, accountRR.Body.String())
 	}
 
-	creatorAfterWebhook, err := env.store.GetMarketplaceCreatorAccount(creatorAccount.ID)
+	creatorAfterWebhook, err := env.store.GetMarketplaceCreatorAccount(context.Background(), creatorAccount.ID)
 	if err != nil {
 		t.Fatalf("failed to reload creator account: %v", err)
 	}
@@ -2145,7 +2202,7 @@ func TestAPI_MarketplaceWebhookCompletesOrderAndUpdatesCreatorAccount(t *testing
 		t.Fatalf("expected checkout webhook 200, got %d (%s)", checkoutRR.Code, checkoutRR.Body.String())
 	}
 
-	reloadedOrder, err := env.store.GetMarketplaceOrder(order.ID)
+	reloadedOrder, err := env.store.GetMarketplaceOrder(context.Background(), order.ID)
 	if err != nil {
 		t.Fatalf("failed to reload order: %v", err)
 	}
@@ -2153,7 +2210,7 @@ func TestAPI_MarketplaceWebhookCompletesOrderAndUpdatesCreatorAccount(t *testing
 		t.Fatalf("expected webhook to mark order paid, got %+v", reloadedOrder)
 	}
 
-	license, err := env.store.GetMarketplaceLicense(detail.Listing.ID, memberClient.user.ID)
+	license, err := env.store.GetMarketplaceLicense(context.Background(), detail.Listing.ID, memberClient.user.ID)
 	if err != nil {
 		t.Fatalf("failed to load marketplace license: %v", err)
 	}
@@ -2161,7 +2218,7 @@ func TestAPI_MarketplaceWebhookCompletesOrderAndUpdatesCreatorAccount(t *testing
 		t.Fatalf("expected active marketplace license after webhook, got %+v", license)
 	}
 
-	payout, err := env.store.GetMarketplacePayoutByOrder(order.ID)
+	payout, err := env.store.GetMarketplacePayoutByOrder(context.Background(), order.ID)
 	if err != nil {
 		t.Fatalf("failed to load marketplace payout: %v", err)
 	}
@@ -2358,7 +2415,7 @@ func TestAPI_MarketplaceFreeListingsPublishInstallAndUpdateAcrossCollections(t *
 		t.Fatalf("expected active version 2 marketplace install after update, got %+v", memberInstallV2)
 	}
 
-	oldInstall, err := env.store.GetMarketplaceInstall(memberInstall.ID)
+	oldInstall, err := env.store.GetMarketplaceInstall(context.Background(), memberInstall.ID)
 	if err != nil {
 		t.Fatalf("failed to reload superseded marketplace install: %v", err)
 	}
@@ -2366,14 +2423,14 @@ func TestAPI_MarketplaceFreeListingsPublishInstallAndUpdateAcrossCollections(t *
 		t.Fatalf("expected original marketplace install to be superseded by the new install, got %+v", oldInstall)
 	}
 
-	oldNotes, oldCards, err := env.store.GetDeckContentSummary(memberInstall.InstalledDeckID)
+	oldNotes, oldCards, err := env.store.GetDeckContentSummary(context.Background(), memberInstall.InstalledDeckID)
 	if err != nil {
 		t.Fatalf("failed to read original marketplace install summary: %v", err)
 	}
 	if oldNotes != 1 || oldCards != 1 {
 		t.Fatalf("expected original marketplace install copy to remain on v1, got notes=%d cards=%d", oldNotes, oldCards)
 	}
-	newNotes, newCards, err := env.store.GetDeckContentSummary(memberInstallV2.InstalledDeckID)
+	newNotes, newCards, err := env.store.GetDeckContentSummary(context.Background(), memberInstallV2.InstalledDeckID)
 	if err != nil {
 		t.Fatalf("failed to read updated marketplace install summary: %v", err)
 	}
@@ -2385,20 +2442,20 @@ func TestAPI_MarketplaceFreeListingsPublishInstallAndUpdateAcrossCollections(t *
 	if removeInstallRR.Code != http.StatusNoContent {
 		t.Fatalf("expected remove marketplace install 204, got %d (%s)", removeInstallRR.Code, removeInstallRR.Body.String())
 	}
-	removedInstall, err := env.store.GetMarketplaceInstall(memberInstallV2.ID)
+	removedInstall, err := env.store.GetMarketplaceInstall(context.Background(), memberInstallV2.ID)
 	if err != nil {
 		t.Fatalf("failed to reload removed marketplace install: %v", err)
 	}
 	if removedInstall.Status != "removed" {
 		t.Fatalf("expected removed marketplace install status=removed, got %+v", removedInstall)
 	}
-	if _, err := env.store.GetDeck(memberInstallV2.InstalledDeckID); err == nil {
+	if _, err := env.store.GetDeck(context.Background(), memberInstallV2.InstalledDeckID); err == nil {
 		t.Fatalf("expected removing a marketplace install to delete its copied deck %d", memberInstallV2.InstalledDeckID)
 	}
 }
 
 func TestAPI_NoteTypeFieldTemplateEmptyAndBackupEndpoints(t *testing.T) {
-	env := setupAPITestEnv(t)
+	env := setupFileAPITestEnv(t)
 
 	listNoteTypes := doRawRequest(env.router, http.MethodGet, "/api/note-types", "")
 	if listNoteTypes.Code != http.StatusOK {
@@ -2511,7 +2568,7 @@ func TestAPI_NoteTypeFieldTemplateEmptyAndBackupEndpoints(t *testing.T) {
 		Templates: []CardTemplate{{Name: "Card 1", QFmt: "{{Only}}", AFmt: "{{Only}}"}},
 	}
 	env.collection.NoteTypes[single.Name] = single
-	if err := env.store.CreateNoteType("default", &single); err != nil {
+	if err := env.store.CreateNoteType(context.Background(), "default", &single); err != nil {
 		t.Fatalf("failed to create single-field note type: %v", err)
 	}
 	removeLast := doJSONRequest(t, env.router, http.MethodDelete, "/api/note-types/SingleFieldType/fields", RemoveFieldRequest{FieldName: "Only"})
@@ -2612,7 +2669,7 @@ func TestAPI_NoteTypeFieldTemplateEmptyAndBackupEndpoints(t *testing.T) {
 		SRS:          newDueNow(time.Now()),
 		USN:          1,
 	}
-	if err := env.store.CreateCard(orphan); err != nil {
+	if err := env.store.CreateCard(context.Background(), orphan); err != nil {
 		t.Fatalf("failed to create orphan card: %v", err)
 	}
 
@@ -2647,17 +2704,17 @@ func TestAPI_NoteTypeFieldTemplateEmptyAndBackupEndpoints(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to add cloze note: %v", err)
 	}
-	if err := env.store.CreateNote("default", &clozeNote); err != nil {
+	if err := env.store.CreateNote(context.Background(), "default", &clozeNote); err != nil {
 		t.Fatalf("failed to persist cloze note: %v", err)
 	}
 	for _, c := range clozeCards {
-		if err := env.store.CreateCard(c); err != nil {
+		if err := env.store.CreateCard(context.Background(), c); err != nil {
 			t.Fatalf("failed to persist cloze card: %v", err)
 		}
 	}
 	clozeNote.FieldMap["Text"] = "no cloze now"
 	clozeNote.ModifiedAt = time.Now()
-	if err := env.store.UpdateNote(&clozeNote); err != nil {
+	if err := env.store.UpdateNote(context.Background(), &clozeNote); err != nil {
 		t.Fatalf("failed to update cloze note text: %v", err)
 	}
 	env.collection.Notes[clozeNote.ID] = clozeNote
@@ -2674,7 +2731,7 @@ func TestAPI_NoteTypeFieldTemplateEmptyAndBackupEndpoints(t *testing.T) {
 		SRS:          newDueNow(time.Now()),
 		USN:          1,
 	}
-	if err := env.store.CreateCard(emptyCard); err != nil {
+	if err := env.store.CreateCard(context.Background(), emptyCard); err != nil {
 		t.Fatalf("failed to create empty content card: %v", err)
 	}
 
@@ -2714,11 +2771,11 @@ func TestAPI_NoteTypeFieldTemplateEmptyAndBackupEndpoints(t *testing.T) {
 	if createBackup.Code != http.StatusCreated {
 		t.Fatalf("expected create backup 201, got %d (%s)", createBackup.Code, createBackup.Body.String())
 	}
-	var backupResp map[string]string
+	var backupResp CreateBackupResponse
 	if err := json.Unmarshal(createBackup.Body.Bytes(), &backupResp); err != nil {
 		t.Fatalf("failed to decode backup response: %v", err)
 	}
-	backupPath := backupResp["backupPath"]
+	backupPath := backupResp.BackupPath
 	if backupPath == "" {
 		t.Fatalf("expected backup path in response, got %+v", backupResp)
 	}
@@ -2738,7 +2795,7 @@ func TestAPI_NoteTypeFieldTemplateEmptyAndBackupEndpoints(t *testing.T) {
 	}
 	restore := doJSONRequest(t, env.router, http.MethodPost, "/api/backups/restore", RestoreBackupRequest{BackupPath: backupPath})
 	if restore.Code != http.StatusOK {
-		t.Fatalf("expected restore backup warning response 200, got %d (%s)", restore.Code, restore.Body.String())
+		t.Fatalf("expected restore backup 200, got %d (%s)", restore.Code, restore.Body.String())
 	}
 }
 
@@ -2753,11 +2810,11 @@ func TestRegenerateCardsForNoteType_CoversUpdateCreateDeletePaths(t *testing.T)
 	if err != nil {
 		t.Fatalf("failed to add note: %v", err)
 	}
-	if err := env.store.CreateNote("default", &note); err != nil {
+	if err := env.store.CreateNote(context.Background(), "default", &note); err != nil {
 		t.Fatalf("failed to persist note: %v", err)
 	}
 	for _, c := range cards {
-		if err := env.store.CreateCard(c); err != nil {
+		if err := env.store.CreateCard(context.Background(), c); err != nil {
 			t.Fatalf("failed to persist card: %v", err)
 		}
 	}
@@ -2777,7 +2834,7 @@ func TestRegenerateCardsForNoteType_CoversUpdateCreateDeletePaths(t *testing.T)
 		SRS:          newDueNow(time.Now()),
 		USN:          1,
 	}
-	if err := env.store.CreateCard(orphan); err != nil {
+	if err := env.store.CreateCard(context.Background(), orphan); err != nil {
 		t.Fatalf("failed to create orphan card: %v", err)
 	}
 
@@ -2798,15 +2855,15 @@ func TestRegenerateCardsForNoteType_CoversUpdateCreateDeletePaths(t *testing.T)
 		},
 	}
 	env.collection.NoteTypes["Basic"] = basic
-	if err := env.store.UpdateNoteType("default", &basic); err != nil {
+	if err := env.store.UpdateNoteType(context.Background(), "default", &basic); err != nil {
 		t.Fatalf("failed to persist updated note type: %v", err)
 	}
 
-	if err := env.handler.regenerateCardsForNoteType("Basic"); err != nil {
+	if err := env.handler.regenerateCardsForNoteType(context.Background(), "Basic"); err != nil {
 		t.Fatalf("expected regeneration to succeed, got %v", err)
 	}
 
-	updatedCard, err := env.store.GetCard(cards[0].ID)
+	updatedCard, err := env.store.GetCard(context.Background(), cards[0].ID)
 	if err != nil {
 		t.Fatalf("failed to get updated existing card: %v", err)
 	}
@@ -2814,11 +2871,11 @@ func TestRegenerateCardsForNoteType_CoversUpdateCreateDeletePaths(t *testing.T)
 		t.Fatalf("expected existing card content to be refreshed, got %q", updatedCard.Front)
 	}
 
-	if _, err := env.store.GetCard(orphan.ID); err == nil {
+	if _, err := env.store.GetCard(context.Background(), orphan.ID); err == nil {
 		t.Fatalf("expected orphan card %d to be deleted", orphan.ID)
 	}
 
-	byNote, err := env.store.GetCardsByNote(note.ID)
+	byNote, err := env.store.GetCardsByNote(context.Background(), note.ID)
 	if err != nil {
 		t.Fatalf("failed to read cards by note after regeneration: %v", err)
 	}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Personal access tokens let scripts and browser extensions call the API with an
+// "Authorization: Bearer" header instead of a session cookie. Only a SHA-256 hash of a
+// token is stored; the token itself is shown once, when it is created.
+const (
+	apiTokenPrefix      = "mdt_"
+	apiTokenScopeFull   = "full"
+	apiTokenScopeRead   = "read"
+	apiTokenScopeNotes  = "notes"
+	maxAPITokenNameLen  = 100
+	maxAPITokenLifetime = 3650
+)
+
+// APIToken describes a personal access token without its secret. Prefix is the start of
+// the token, to tell tokens apart.
+type APIToken struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Scope       string    `json:"scope"`
+	Prefix      string    `json:"prefix"`
+	WorkspaceID string    `json:"workspaceId,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	LastUsedAt  time.Time `json:"lastUsedAt,omitempty"`
+	ExpiresAt   time.Time `json:"expiresAt,omitempty"`
+	UserID      string    `json:"-"`
+}
+
+type CreateAPITokenRequest struct {
+	Name          string `json:"name"`
+	Scope         string `json:"scope,omitempty"`
+	ExpiresInDays int    `json:"expiresInDays,omitempty"`
+}
+
+func (req *CreateAPITokenRequest) Validate(v *requestValidator) {
+	v.required("name", req.Name)
+	v.maxLength("name", strings.TrimSpace(req.Name), maxAPITokenNameLen)
+	v.oneOf("scope", req.Scope, apiTokenScopeFull, apiTokenScopeRead, apiTokenScopeNotes)
+	v.intRange("expiresInDays", req.ExpiresInDays, 0, maxAPITokenLifetime)
+}
+
+// CreateAPITokenResponse carries the only copy of the new token's secret.
+type CreateAPITokenResponse struct {
+	APIToken
+	Token string `json:"token"`
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateAPIToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return apiTokenPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// apiTokenAllows reports whether a token with scope may call method on path, a path
+// under /api. No token may reach the account's credentials under /auth, so a leaked
+// token cannot change the password and take the account over. Read tokens may only
+// read. Notes tokens may manage notes and upload media, and read the decks, note types,
+// and tags that adding a note needs.
+func apiTokenAllows(scope, method, path string) bool {
+	reading := method == http.MethodGet || method == http.MethodHead
+	path = "/" + strings.Trim(strings.TrimPrefix(path, "/api"), "/")
+	under := func(prefix string) bool {
+		return path == prefix || strings.HasPrefix(path, prefix+"/")
+	}
+	if under("/auth") {
+		return false
+	}
+	switch scope {
+	case apiTokenScopeFull:
+		return true
+	case apiTokenScopeRead:
+		return reading
+	case apiTokenScopeNotes:
+		switch {
+		case under("/notes"):
+			return true
+		case path == "/media":
+			return method == http.MethodPost
+		case under("/decks"), under("/note-types"), under("/tags"):
+			return reading
+		}
+	}
+	return false
+}
+
+func (s *SQLStore) CreateAPIToken(ctx context.Context, token *APIToken, tokenHash string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO api_tokens (id, user_id, workspace_id, name, token_hash, token_prefix, scope, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		token.ID,
+		token.UserID,
+		nullIfEmpty(token.WorkspaceID),
+		token.Name,
+		tokenHash,
+		token.Prefix,
+		token.Scope,
+		token.CreatedAt.Unix(),
+		nullIfZeroTime(token.ExpiresAt),
+	)
+	return err
+}
+
+const apiTokenColumns = `id, user_id, workspace_id, name, token_prefix, scope, created_at, last_used_at, expires_at`
+
+func scanAPIToken(scanner interface{ Scan(dest ...any) error }) (*APIToken, error) {
+	var token APIToken
+	var workspaceID sql.NullString
+	var createdAt int64
+	var lastUsedAt, expiresAt sql.NullInt64
+	if err := scanner.Scan(&token.ID, &token.UserID, &workspaceID, &token.Name, &token.Prefix, &token.Scope, &createdAt, &lastUsedAt, &expiresAt); err != nil {
+		return nil, err
+	}
+	token.WorkspaceID = workspaceID.String
+	token.CreatedAt = time.Unix(createdAt, 0)
+	token.LastUsedAt = unixTimeOrZero(lastUsedAt)
+	token.ExpiresAt = unixTimeOrZero(expiresAt)
+	return &token, nil
+}
+
+// ListAPITokens returns the user's tokens that have not been revoked, newest first.
+func (s *SQLStore) ListAPITokens(ctx context.Context, userID string) ([]APIToken, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+apiTokenColumns+`
+		FROM api_tokens
+		WHERE user_id = ? AND revoked_at IS NULL
+		ORDER BY created_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []APIToken{}
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+	return tokens, rows.Err()
+}
+
+// GetActiveAPITokenByHash finds the unrevoked, unexpired token with tokenHash.
+func (s *SQLStore) GetActiveAPITokenByHash(ctx context.Context, tokenHash string, now time.Time) (*APIToken, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT `+apiTokenColumns+`
+		FROM api_tokens
+		WHERE token_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)
+	`, tokenHash, now.Unix())
+	return scanAPIToken(row)
+}
+
+// TouchAPIToken records a use of the token, at most once a minute.
+func (s *SQLStore) TouchAPIToken(ctx context.Context, id string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE api_tokens SET last_used_at = ?
+		WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)
+	`, at.Unix(), id, at.Add(-time.Minute).Unix())
+	return err
+}
+
+// RevokeAPIToken revokes one of the user's tokens, returning sql.ErrNoRows when the
+// user has no such active token.
+func (s *SQLStore) RevokeAPIToken(ctx context.Context, userID, id string, at time.Time) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL`, at.Unix(), id, userID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// sessionFromAPIToken authenticates a bearer token, returning a session that is not
+// stored and carries the token's scope.
+func (h *APIHandler) sessionFromAPIToken(r *http.Request) *SessionRecord {
+	header := strings.TrimSpace(r.Header.Get("Authorization"))
+	scheme, raw, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil
+	}
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(raw, apiTokenPrefix) {
+		return nil
+	}
+	now := time.Now()
+	token, err := h.store.GetActiveAPITokenByHash(r.Context(), hashAPIToken(raw), now)
+	if err != nil {
+		return nil
+	}
+	_ = h.store.TouchAPIToken(r.Context(), token.ID, now)
+	return &SessionRecord{
+		UserID:      token.UserID,
+		WorkspaceID: token.WorkspaceID,
+		Plan:        PlanFree,
+		LastSeenAt:  now,
+		CreatedAt:   token.CreatedAt,
+		TokenID:     token.ID,
+		TokenScope:  token.Scope,
+	}
+}
+
+// requireSessionLogin rejects requests made with an API token, so a token cannot mint or
+// revoke tokens or change the account's credentials.
+func requireSessionLogin(w http.ResponseWriter, session *SessionRecord) bool {
+	if session.TokenID != "" {
+		respondAPIError(w, http.StatusForbidden, "session_required", "This request needs a signed-in session, not an API token")
+		return false
+	}
+	return true
+}
+
+// ListAPITokens serves GET /api/tokens.
+func (h *APIHandler) ListAPITokens(w http.ResponseWriter, r *http.Request) {
+	session := h.sessionFromRequest(r)
+	if !requireSessionLogin(w, session) {
+		return
+	}
+	tokens, err := h.store.ListAPITokens(r.Context(), session.UserID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "api_tokens_list_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, tokens)
+}
+
+// CreateAPIToken serves POST /api/tokens. The token acts as its creator in the
+// session's workspace, limited by its scope (full, read, or notes; default full).
+func (h *APIHandler) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	session := h.sessionFromRequest(r)
+	if !requireSessionLogin(w, session) {
+		return
+	}
+	var req CreateAPITokenRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	scope := firstNonEmpty(strings.TrimSpace(req.Scope), apiTokenScopeFull)
+
+	secret, err := generateAPIToken()
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "api_token_create_failed", err.Error())
+		return
+	}
+	now := time.Now()
+	token := APIToken{
+		ID:          newID("tok"),
+		Name:        sanitizeHTML(name),
+		Scope:       scope,
+		Prefix:      secret[:len(apiTokenPrefix)+6],
+		WorkspaceID: session.WorkspaceID,
+		CreatedAt:   now,
+		UserID:      session.UserID,
+	}
+	if req.ExpiresInDays > 0 {
+		token.ExpiresAt = now.AddDate(0, 0, req.ExpiresInDays)
+	}
+	if err := h.store.CreateAPIToken(r.Context(), &token, hashAPIToken(secret)); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "api_token_create_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusCreated, CreateAPITokenResponse{APIToken: token, Token: secret})
+}
+
+// RevokeAPIToken serves DELETE /api/tokens/{id}.
+func (h *APIHandler) RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	session := h.sessionFromRequest(r)
+	if !requireSessionLogin(w, session) {
+		return
+	}
+	if err := h.store.RevokeAPIToken(r.Context(), session.UserID, chi.URLParam(r, "id"), time.Now()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondAPIError(w, http.StatusNotFound, "api_token_not_found", "Token not found")
+			return
+		}
+		respondAPIError(w, http.StatusInternalServerError, "api_token_revoke_failed", err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
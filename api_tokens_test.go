@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAPI_APITokensAuthenticateWithinScope(t *testing.T) {
+	env := setupAPITestEnv(t)
+	createToken := func(scope string) CreateAPITokenResponse {
+		rr := doJSONRequest(t, env.router, http.MethodPost, "/api/tokens", CreateAPITokenRequest{Name: scope + " script", Scope: scope})
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected token create 201, got %d (%s)", rr.Code, rr.Body.String())
+		}
+		return decodeJSON[CreateAPITokenResponse](t, rr)
+	}
+	bearer := func(token string) map[string]string {
+		return map[string]string{"X-Test-No-Auth": "1", "Authorization": "Bearer " + token}
+	}
+	newNote := CreateNoteRequest{TypeID: "Basic", DeckID: 1, FieldVals: map[string]string{"Front": "Token", "Back": "Scoped"}}
+
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/tokens", CreateAPITokenRequest{Name: "bad", Scope: "admin"}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown scope to be rejected, got %d", rr.Code)
+	}
+	full := createToken(apiTokenScopeFull)
+	read := createToken(apiTokenScopeRead)
+	notes := createToken(apiTokenScopeNotes)
+	if full.Token == "" || full.Prefix == "" || full.Token[:len(full.Prefix)] != full.Prefix {
+		t.Fatalf("expected the token and its prefix, got %+v", full)
+	}
+	var storedHash string
+	if err := env.store.db.QueryRow(`SELECT token_hash FROM api_tokens WHERE id = ?`, full.ID).Scan(&storedHash); err != nil || storedHash == full.Token || storedHash != hashAPIToken(full.Token) {
+		t.Fatalf("expected only the token hash stored, got %q (%v)", storedHash, err)
+	}
+
+	if rr := doJSONRequestWithHeaders(t, env.router, http.MethodPost, "/api/notes", newNote, bearer(full.Token)); rr.Code != http.StatusCreated {
+		t.Fatalf("expected a full token to create notes, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequestWithHeaders(t, env.router, http.MethodGet, "/api/decks", struct{}{}, bearer(read.Token)); rr.Code != http.StatusOK {
+		t.Fatalf("expected a read token to list decks, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequestWithHeaders(t, env.router, http.MethodPost, "/api/notes", newNote, bearer(read.Token)); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected a read token to be refused writes, got %d", rr.Code)
+	}
+	if rr := doJSONRequestWithHeaders(t, env.router, http.MethodPost, "/api/notes", newNote, bearer(notes.Token)); rr.Code != http.StatusCreated {
+		t.Fatalf("expected a notes token to create notes, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequestWithHeaders(t, env.router, http.MethodPost, "/api/decks", CreateDeckRequest{Name: "Nope"}, bearer(notes.Token)); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected a notes token to be refused deck changes, got %d", rr.Code)
+	}
+	if rr := doJSONRequestWithHeaders(t, env.router, http.MethodPost, "/api/tokens", CreateAPITokenRequest{Name: "minted"}, bearer(full.Token)); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected a token to be refused minting tokens, got %d", rr.Code)
+	}
+	if rr := doJSONRequestWithHeaders(t, env.router, http.MethodGet, "/api/decks", struct{}{}, bearer(apiTokenPrefix+"unknown")); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected an unknown token to be unauthorized, got %d", rr.Code)
+	}
+
+	rr := doRawRequest(env.router, http.MethodGet, "/api/tokens", "")
+	tokens := decodeJSON[[]APIToken](t, rr)
+	if rr.Code != http.StatusOK || len(tokens) != 3 {
+		t.Fatalf("expected three tokens, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	for _, token := range tokens {
+		if token.LastUsedAt.IsZero() {
+			t.Fatalf("expected last use recorded, got %+v", token)
+		}
+	}
+	if rr := doRawRequest(env.router, http.MethodDelete, fmt.Sprintf("/api/tokens/%s", full.ID), ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected revoke 204, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequestWithHeaders(t, env.router, http.MethodGet, "/api/decks", struct{}{}, bearer(full.Token)); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a revoked token to be unauthorized, got %d", rr.Code)
+	}
+	if rr := doRawRequest(env.router, http.MethodDelete, fmt.Sprintf("/api/tokens/%s", full.ID), ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected revoking twice to 404, got %d", rr.Code)
+	}
+}
+
+func TestAPI_APITokensCannotChangeCredentials(t *testing.T) {
+	env := setupAPITestEnv(t)
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/tokens", CreateAPITokenRequest{Name: "full script", Scope: apiTokenScopeFull})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected token create 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	full := decodeJSON[CreateAPITokenResponse](t, rr)
+	bearer := map[string]string{"X-Test-No-Auth": "1", "Authorization": "Bearer " + full.Token}
+
+	body := map[string]string{"newPassword": "correct horse battery staple"}
+	if rr := doJSONRequestWithHeaders(t, env.router, http.MethodPut, "/api/auth/password", body, bearer); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected a full token to be refused setting the password, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequestWithHeaders(t, env.router, http.MethodPost, "/api/auth/logout", struct{}{}, bearer); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected a full token to be refused logout, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequestWithHeaders(t, env.router, http.MethodGet, "/api/decks", struct{}{}, bearer); rr.Code != http.StatusOK {
+		t.Fatalf("expected the token to stay usable, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	for _, path := range []string{"/api/auth/password", "/auth/password", "/api/auth"} {
+		if apiTokenAllows(apiTokenScopeFull, http.MethodPut, path) {
+			t.Errorf("expected %s to be denied to a full token", path)
+		}
+	}
+}
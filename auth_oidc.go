@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// oidcHTTPTimeout bounds each call to the identity provider.
+const oidcHTTPTimeout = 15 * time.Second
+
+// oidcProviderMetadata is the part of an issuer's discovery document sign-in uses.
+type oidcProviderMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// oidcProviderCache holds the discovery document of the configured issuer, fetched on
+// first use.
+type oidcProviderCache struct {
+	mu       sync.Mutex
+	issuer   string
+	metadata *oidcProviderMetadata
+}
+
+// oidcUserInfo is the userinfo response. Subjects are only unique per issuer, so users
+// are mapped by the pair.
+type oidcUserInfo struct {
+	Sub               string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     *bool  `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Picture           string `json:"picture"`
+}
+
+func (cfg OIDCConfig) Configured() bool {
+	return cfg.Issuer != "" && cfg.ClientID != "" && cfg.RedirectURL != ""
+}
+
+// identityProvider is the provider recorded in oauth_identities for the issuer.
+func (cfg OIDCConfig) identityProvider() string {
+	return "oidc:" + cfg.Issuer
+}
+
+func (h *APIHandler) oidcHTTPClient() *http.Client {
+	return &http.Client{Timeout: oidcHTTPTimeout}
+}
+
+// oidcProvider returns the configured issuer's endpoints, discovering them on first
+// use. A failed discovery is retried on the next sign-in.
+func (h *APIHandler) oidcProvider(ctx context.Context) (*oidcProviderMetadata, error) {
+	cfg := h.config.OIDC
+	h.oidc.mu.Lock()
+	defer h.oidc.mu.Unlock()
+	if h.oidc.metadata != nil && h.oidc.issuer == cfg.Issuer {
+		return h.oidc.metadata, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.oidcHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery returned %s", resp.Status)
+	}
+	var metadata oidcProviderMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("decode discovery document: %w", err)
+	}
+	if strings.TrimRight(metadata.Issuer, "/") != cfg.Issuer {
+		return nil, fmt.Errorf("discovery document is for issuer %q, not %q", metadata.Issuer, cfg.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.UserinfoEndpoint == "" {
+		return nil, errors.New("discovery document is missing the authorization, token, or userinfo endpoint")
+	}
+	h.oidc.issuer, h.oidc.metadata = cfg.Issuer, &metadata
+	return &metadata, nil
+}
+
+func (h *APIHandler) oidcOAuthConfig(metadata *oidcProviderMetadata) *oauth2.Config {
+	cfg := h.config.OIDC
+	return &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Scopes:       cfg.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  metadata.AuthorizationEndpoint,
+			TokenURL: metadata.TokenEndpoint,
+		},
+	}
+}
+
+// StartOIDCAuth serves /api/auth/oidc/start. A GET redirects to the provider; a POST
+// returns the URL to send the browser to.
+func (h *APIHandler) StartOIDCAuth(w http.ResponseWriter, r *http.Request) {
+	if !h.config.OIDC.Configured() {
+		respondAPIError(w, http.StatusNotImplemented, "oidc_not_configured", "Single sign-on is not configured")
+		return
+	}
+	metadata, err := h.oidcProvider(r.Context())
+	if err != nil {
+		respondAPIError(w, http.StatusBadGateway, "oidc_discovery_failed", err.Error())
+		return
+	}
+
+	state := randomToken()
+	verifier := randomToken()
+	now := time.Now()
+	h.writeCookie(w, oauthStateCookieName, state, now.Add(oauthCookieTTL))
+	h.writeCookie(w, oauthVerifierCookieName, verifier, now.Add(oauthCookieTTL))
+
+	authURL := h.oidcOAuthConfig(metadata).AuthCodeURL(
+		state,
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		oauth2.SetAuthURLParam("code_challenge", pkceChallenge(verifier)),
+	)
+
+	if r.Method == http.MethodGet {
+		http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"url": authURL})
+}
+
+// OIDCAuthCallback serves GET /api/auth/oidc/callback. The provider's subject maps to a
+// local user; a subject seen for the first time links to the user with the same
+// verified email, or creates one.
+func (h *APIHandler) OIDCAuthCallback(w http.ResponseWriter, r *http.Request) {
+	cfg := h.config.OIDC
+	if !cfg.Configured() {
+		respondAPIError(w, http.StatusNotImplemented, "oidc_not_configured", "Single sign-on is not configured")
+		return
+	}
+
+	if providerErr := strings.TrimSpace(r.URL.Query().Get("error")); providerErr != "" {
+		respondAPIError(w, http.StatusUnauthorized, "oidc_denied", firstNonEmpty(r.URL.Query().Get("error_description"), providerErr))
+		return
+	}
+	stateCookie, err := r.Cookie(oauthStateCookieName)
+	if err != nil || stateCookie.Value == "" || stateCookie.Value != r.URL.Query().Get("state") {
+		respondAPIError(w, http.StatusBadRequest, "oauth_state_invalid", "OAuth state validation failed")
+		return
+	}
+	verifierCookie, err := r.Cookie(oauthVerifierCookieName)
+	if err != nil || verifierCookie.Value == "" {
+		respondAPIError(w, http.StatusBadRequest, "oauth_verifier_missing", "OAuth verifier is missing")
+		return
+	}
+	code := strings.TrimSpace(r.URL.Query().Get("code"))
+	if code == "" {
+		respondAPIError(w, http.StatusBadRequest, "oauth_code_missing", "OAuth code is missing")
+		return
+	}
+
+	metadata, err := h.oidcProvider(r.Context())
+	if err != nil {
+		respondAPIError(w, http.StatusBadGateway, "oidc_discovery_failed", err.Error())
+		return
+	}
+	ctx := context.WithValue(r.Context(), oauth2.HTTPClient, h.oidcHTTPClient())
+	config := h.oidcOAuthConfig(metadata)
+	token, err := config.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", verifierCookie.Value))
+	if err != nil {
+		respondAPIError(w, http.StatusBadGateway, "oauth_exchange_failed", err.Error())
+		return
+	}
+
+	resp, err := config.Client(ctx, token).Get(metadata.UserinfoEndpoint)
+	if err != nil {
+		respondAPIError(w, http.StatusBadGateway, "oauth_userinfo_failed", err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respondAPIError(w, http.StatusBadGateway, "oauth_userinfo_failed", "Userinfo request returned "+resp.Status)
+		return
+	}
+	var info oidcUserInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		respondAPIError(w, http.StatusBadGateway, "oauth_userinfo_invalid", "Failed to decode the user profile")
+		return
+	}
+	email, emailErr := normalizeEmail(info.Email)
+	if info.Sub == "" {
+		respondAPIError(w, http.StatusBadGateway, "oauth_userinfo_invalid", "The user profile has no subject")
+		return
+	}
+
+	now := time.Now()
+	provider := cfg.identityProvider()
+	user, err := h.store.GetUserByOAuth(ctx, provider, info.Sub)
+	if errors.Is(err, sql.ErrNoRows) {
+		if emailErr != nil {
+			respondAPIError(w, http.StatusBadGateway, "oauth_userinfo_invalid", "The identity provider did not share an email address")
+			return
+		}
+		user, err = h.store.GetUserByEmail(ctx, email)
+		switch {
+		case err == nil && (info.EmailVerified == nil || !*info.EmailVerified):
+			respondAPIError(w, http.StatusConflict, "oidc_email_unverified", "An account with this email exists, and the identity provider has not verified the email")
+			return
+		case errors.Is(err, sql.ErrNoRows):
+			user = &User{
+				ID:          newID("usr"),
+				Email:       email,
+				DisplayName: firstNonEmpty(info.Name, info.PreferredUsername, displayNameForEmail(email)),
+				AvatarURL:   info.Picture,
+				Onboarding:  true,
+				CreatedAt:   now,
+				UpdatedAt:   now,
+			}
+			err = h.store.CreateUser(ctx, user)
+		}
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "user_create_failed", err.Error())
+			return
+		}
+	} else if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "user_lookup_failed", err.Error())
+		return
+	}
+
+	if err := h.store.UpsertOAuthIdentity(ctx, &OAuthIdentity{
+		ID:        newID("oauth"),
+		UserID:    user.ID,
+		Provider:  provider,
+		Subject:   info.Sub,
+		Email:     firstNonEmpty(email, user.Email),
+		CreatedAt: now,
+	}); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "oauth_identity_failed", err.Error())
+		return
+	}
+	_ = h.store.UpdateUserLastLogin(ctx, user.ID, now)
+
+	workspace, err := h.ensureDefaultWorkspaceForUser(r.Context(), user)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "workspace_create_failed", err.Error())
+		return
+	}
+
+	session := &SessionRecord{
+		ID:          newID("sess"),
+		UserID:      user.ID,
+		WorkspaceID: workspace.ID,
+		Plan:        PlanFree,
+		ExpiresAt:   now.Add(h.config.SessionTTL),
+		LastSeenAt:  now,
+		CreatedAt:   now,
+	}
+	if err := h.store.CreateSessionRecord(ctx, session); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "session_create_failed", err.Error())
+		return
+	}
+
+	h.clearCookie(w, oauthStateCookieName)
+	h.clearCookie(w, oauthVerifierCookieName)
+	h.writeCookie(w, sessionCookieName, session.ID, session.ExpiresAt)
+
+	http.Redirect(w, r, h.config.AuthSuccessPath, http.StatusTemporaryRedirect)
+}
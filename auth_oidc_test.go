@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func newFakeOIDCProvider(t *testing.T, userinfo map[string]any) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 server.URL,
+				"authorization_endpoint": server.URL + "/authorize",
+				"token_endpoint":         server.URL + "/token",
+				"userinfo_endpoint":      server.URL + "/userinfo",
+			})
+		case "/token":
+			if r.FormValue("code") != "good-code" || r.FormValue("code_verifier") == "" {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "access", "token_type": "Bearer", "expires_in": 3600})
+		case "/userinfo":
+			if r.Header.Get("Authorization") != "Bearer access" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(userinfo)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAPI_OIDCLoginMapsSubjectsToUsers(t *testing.T) {
+	env := setupAPITestEnv(t)
+	noAuth := map[string]string{"X-Test-No-Auth": "1"}
+	if rr := doJSONRequestWithHeaders(t, env.router, http.MethodPost, "/api/auth/oidc/start", struct{}{}, noAuth); rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 before OIDC is configured, got %d", rr.Code)
+	}
+
+	userinfo := map[string]any{"sub": "kc-123", "email": "SSO@example.com", "email_verified": true, "name": "Sso User"}
+	provider := newFakeOIDCProvider(t, userinfo)
+	env.handler.config.OIDC = OIDCConfig{
+		Issuer:       provider.URL,
+		ClientID:     "microdote",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:8000/api/auth/oidc/callback",
+		ProviderName: "Keycloak",
+		Scopes:       []string{"openid", "email", "profile"},
+	}
+
+	signIn := func(code string) *httptest.ResponseRecorder {
+		start := doJSONRequestWithHeaders(t, env.router, http.MethodPost, "/api/auth/oidc/start", struct{}{}, noAuth)
+		if start.Code != http.StatusOK {
+			t.Fatalf("expected start 200, got %d (%s)", start.Code, start.Body.String())
+		}
+		authURL, err := url.Parse(decodeJSON[map[string]string](t, start)["url"])
+		if err != nil || !strings.HasPrefix(authURL.String(), provider.URL+"/authorize") || authURL.Query().Get("code_challenge") == "" {
+			t.Fatalf("expected a PKCE authorization URL at the provider, got %v (%v)", authURL, err)
+		}
+		var cookies []string
+		for _, cookie := range start.Result().Cookies() {
+			cookies = append(cookies, cookie.Name+"="+cookie.Value)
+		}
+		callback := "/api/auth/oidc/callback?code=" + code + "&state=" + url.QueryEscape(authURL.Query().Get("state"))
+		return doRawRequestWithHeaders(env.router, http.MethodGet, callback, "", map[string]string{"X-Test-No-Auth": "1", "Cookie": strings.Join(cookies, "; ")})
+	}
+
+	if rr := signIn("bad-code"); rr.Code != http.StatusBadGateway {
+		t.Fatalf("expected a failed code exchange to 502, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	rr := signIn("good-code")
+	if rr.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("expected callback to redirect, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	user, err := env.store.GetUserByOAuth(context.Background(), "oidc:"+provider.URL, "kc-123")
+	if err != nil || user.Email != "sso@example.com" || user.DisplayName != "Sso User" {
+		t.Fatalf("expected a user created for the subject, got %+v (%v)", user, err)
+	}
+	var sessionID string
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == sessionCookieName && cookie.Value != "" {
+			sessionID = cookie.Value
+		}
+	}
+	session := decodeJSON[AuthSessionResponse](t, doRawRequestWithHeaders(env.router, http.MethodGet, "/api/auth/session", "", map[string]string{"Cookie": sessionCookieName + "=" + sessionID}))
+	if !session.Authenticated || session.User == nil || session.User.ID != user.ID || !session.OIDCAuthEnabled || session.OIDCProviderName != "Keycloak" {
+		t.Fatalf("expected a session for the OIDC user, got %+v", session)
+	}
+
+	// Signing in again finds the same user by subject, even after the email changes.
+	userinfo["email"] = "renamed@example.com"
+	if rr := signIn("good-code"); rr.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("expected second sign-in to redirect, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if again, err := env.store.GetUserByOAuth(context.Background(), "oidc:"+provider.URL, "kc-123"); err != nil || again.ID != user.ID {
+		t.Fatalf("expected the subject to keep mapping to the same user, got %+v (%v)", again, err)
+	}
+
+	// A new subject with an unverified email may not take over an existing account.
+	createAuthenticatedTestClient(t, env, "taken@example.com", "Taken")
+	userinfo["sub"], userinfo["email"], userinfo["email_verified"] = "kc-456", "taken@example.com", false
+	if rr := signIn("good-code"); rr.Code != http.StatusConflict {
+		t.Fatalf("expected an unverified email match to 409, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	userinfo["email_verified"] = true
+	if rr := signIn("good-code"); rr.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("expected a verified email to link, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if linked, err := env.store.GetUserByOAuth(context.Background(), "oidc:"+provider.URL, "kc-456"); err != nil || linked.Email != "taken@example.com" {
+		t.Fatalf("expected the subject linked to the existing user, got %+v (%v)", linked, err)
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"crypto/subtle"
 	"database/sql"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
@@ -27,21 +26,25 @@ type otpVerifyBody struct {
 	Code  string `json:"code"`
 }
 
+func (req *otpRequestBody) Validate(v *requestValidator) {
+	v.email("email", req.Email)
+}
+
+func (req *otpVerifyBody) Validate(v *requestValidator) {
+	v.email("email", req.Email)
+	v.check(len(strings.TrimSpace(req.Code)) == 6, "code", "must be 6 digits")
+}
+
 func (h *APIHandler) RequestOTP(w http.ResponseWriter, r *http.Request) {
 	var req otpRequestBody
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid OTP request body")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
-	email, err := normalizeEmail(req.Email)
-	if err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_email", err.Error())
-		return
-	}
+	email, _ := normalizeEmail(req.Email)
 
 	now := time.Now()
-	if tooMany, err := h.tooManyOTPRequests(email, requestIP(r), now); err != nil {
+	if tooMany, err := h.tooManyOTPRequests(r.Context(), email, requestIP(r), now); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "otp_rate_limit_failed", err.Error())
 		return
 	} else if tooMany {
@@ -49,14 +52,14 @@ func (h *APIHandler) RequestOTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if latest, err := h.store.GetLatestOTPChallenge(email); err == nil {
+	if latest, err := h.store.GetLatestOTPChallenge(r.Context(), email); err == nil {
 		if latest.ConsumedAt.IsZero() && latest.ResendAvailableAt.After(now) {
 			respondAPIError(w, http.StatusTooManyRequests, "otp_retry_later", fmt.Sprintf("Please wait %d seconds before requesting another code.", int(time.Until(latest.ResendAvailableAt).Seconds())+1))
 			return
 		}
 	}
 
-	if err := h.store.InvalidateOTPChallenges(email); err != nil {
+	if err := h.store.InvalidateOTPChallenges(r.Context(), email); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "otp_invalidate_failed", err.Error())
 		return
 	}
@@ -80,12 +83,12 @@ func (h *APIHandler) RequestOTP(w http.ResponseWriter, r *http.Request) {
 	}
 	challenge.CodeHash = hashOTPCode(h.config.SessionSecret, challenge.ID, code)
 
-	if err := h.store.CreateOTPChallenge(challenge); err != nil {
+	if err := h.store.CreateOTPChallenge(r.Context(), challenge); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "otp_store_failed", err.Error())
 		return
 	}
 
-	if err := h.emailSender.SendOTP(context.Background(), email, code, challenge.ExpiresAt); err != nil {
+	if err := h.emailSender.SendOTP(r.Context(), email, code, challenge.ExpiresAt); err != nil {
 		respondAPIError(w, http.StatusBadGateway, "otp_send_failed", err.Error())
 		return
 	}
@@ -106,24 +109,14 @@ func (h *APIHandler) RequestOTP(w http.ResponseWriter, r *http.Request) {
 
 func (h *APIHandler) VerifyOTP(w http.ResponseWriter, r *http.Request) {
 	var req otpVerifyBody
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid OTP verify body")
-		return
-	}
-
-	email, err := normalizeEmail(req.Email)
-	if err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_email", err.Error())
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
+	email, _ := normalizeEmail(req.Email)
 	code := strings.TrimSpace(req.Code)
-	if len(code) != 6 {
-		respondAPIError(w, http.StatusBadRequest, "invalid_code", "OTP code must be 6 digits")
-		return
-	}
 
-	challenge, err := h.store.GetLatestOTPChallenge(email)
+	challenge, err := h.store.GetLatestOTPChallenge(r.Context(), email)
 	if err != nil {
 		respondAPIError(w, http.StatusBadRequest, "otp_not_found", "No active code found for that email address")
 		return
@@ -141,12 +134,12 @@ func (h *APIHandler) VerifyOTP(w http.ResponseWriter, r *http.Request) {
 
 	expectedHash := hashOTPCode(h.config.SessionSecret, challenge.ID, code)
 	if subtle.ConstantTimeCompare([]byte(expectedHash), []byte(challenge.CodeHash)) != 1 {
-		_ = h.store.IncrementOTPChallengeAttempts(challenge.ID)
+		_ = h.store.IncrementOTPChallengeAttempts(r.Context(), challenge.ID)
 		respondAPIError(w, http.StatusBadRequest, "invalid_code", "That code is incorrect")
 		return
 	}
 
-	user, err := h.store.GetUserByEmail(email)
+	user, err := h.store.GetUserByEmail(r.Context(), email)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		respondAPIError(w, http.StatusInternalServerError, "user_lookup_failed", err.Error())
 		return
@@ -162,16 +155,16 @@ func (h *APIHandler) VerifyOTP(w http.ResponseWriter, r *http.Request) {
 			CreatedAt:   now,
 			UpdatedAt:   now,
 		}
-		if err := h.store.CreateUser(user); err != nil {
+		if err := h.store.CreateUser(r.Context(), user); err != nil {
 			respondAPIError(w, http.StatusInternalServerError, "user_create_failed", err.Error())
 			return
 		}
 	} else {
-		_ = h.store.UpdateUserLastLogin(user.ID, now)
+		_ = h.store.UpdateUserLastLogin(r.Context(), user.ID, now)
 		user.LastLoginAt = now
 	}
 
-	workspace, err := h.ensureDefaultWorkspaceForUser(user)
+	workspace, err := h.ensureDefaultWorkspaceForUser(r.Context(), user)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "workspace_create_failed", err.Error())
 		return
@@ -186,11 +179,11 @@ func (h *APIHandler) VerifyOTP(w http.ResponseWriter, r *http.Request) {
 		LastSeenAt:  now,
 		CreatedAt:   now,
 	}
-	if err := h.store.CreateSessionRecord(session); err != nil {
+	if err := h.store.CreateSessionRecord(r.Context(), session); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "session_create_failed", err.Error())
 		return
 	}
-	if err := h.store.ConsumeOTPChallenge(challenge.ID, now); err != nil {
+	if err := h.store.ConsumeOTPChallenge(r.Context(), challenge.ID, now); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "otp_consume_failed", err.Error())
 		return
 	}
@@ -257,8 +250,8 @@ func requestIP(r *http.Request) string {
 	return strings.TrimSpace(r.RemoteAddr)
 }
 
-func (h *APIHandler) tooManyOTPRequests(email, ip string, now time.Time) (bool, error) {
-	emailCount, err := h.store.CountRecentOTPChallengesByEmail(email, now.Add(-15*time.Minute))
+func (h *APIHandler) tooManyOTPRequests(ctx context.Context, email, ip string, now time.Time) (bool, error) {
+	emailCount, err := h.store.CountRecentOTPChallengesByEmail(ctx, email, now.Add(-15*time.Minute))
 	if err != nil {
 		return false, err
 	}
@@ -266,7 +259,7 @@ func (h *APIHandler) tooManyOTPRequests(email, ip string, now time.Time) (bool,
 		return true, nil
 	}
 
-	ipCount, err := h.store.CountRecentOTPChallengesByIP(ip, now.Add(-15*time.Minute))
+	ipCount, err := h.store.CountRecentOTPChallengesByIP(ctx, ip, now.Add(-15*time.Minute))
 	if err != nil {
 		return false, err
 	}
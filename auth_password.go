@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password sign-in sits beside the OTP and Google flows and starts the same server-side
+// session, so the session middleware protects /api the same way whichever was used.
+const (
+	minPasswordLength   = 8
+	maxPasswordLength   = 72 // bcrypt ignores anything longer
+	maxFailedLogins     = 5
+	failedLoginLockout  = 15 * time.Minute
+	passwordBcryptCost  = bcrypt.DefaultCost
+	errInvalidLoginText = "Incorrect email or password"
+)
+
+// dummyPasswordHash is compared against when no account matches, so an unknown email
+// takes as long to reject as a wrong password.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), passwordBcryptCost)
+
+type passwordLoginBody struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type setPasswordBody struct {
+	CurrentPassword string `json:"currentPassword,omitempty"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// Validate is empty: login answers every bad credential, malformed or not, the same way.
+func (req *passwordLoginBody) Validate(v *requestValidator) {}
+
+func (req *setPasswordBody) Validate(v *requestValidator) {
+	if err := validatePassword(req.NewPassword); err != nil {
+		v.fail("newPassword", strings.TrimPrefix(err.Error(), "password "))
+	}
+}
+
+// userPasswordState is what password sign-in needs from a user row. Hash is empty when
+// the user has not set a password.
+type userPasswordState struct {
+	Hash           string
+	FailedAttempts int
+	LockedUntil    time.Time
+}
+
+func (s *SQLStore) GetUserPasswordState(ctx context.Context, userID string) (userPasswordState, error) {
+	var (
+		state       userPasswordState
+		hash        sql.NullString
+		lockedUntil int64
+	)
+	err := s.db.QueryRowContext(ctx, `SELECT password_hash, failed_login_attempts, login_locked_until FROM users WHERE id = ?`, userID).
+		Scan(&hash, &state.FailedAttempts, &lockedUntil)
+	if err != nil {
+		return userPasswordState{}, err
+	}
+	state.Hash = hash.String
+	if lockedUntil > 0 {
+		state.LockedUntil = time.Unix(lockedUntil, 0)
+	}
+	return state, nil
+}
+
+func (s *SQLStore) SetUserPasswordHash(ctx context.Context, userID, hash string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE users
+		SET password_hash = ?, failed_login_attempts = 0, login_locked_until = 0, updated_at = ?
+		WHERE id = ?
+	`, hash, at.Unix(), userID)
+	return err
+}
+
+// RecordFailedLogin counts a wrong password, locking password sign-in until lockUntil
+// once the count reaches maxFailedLogins.
+func (s *SQLStore) RecordFailedLogin(ctx context.Context, userID string, lockUntil time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE users
+		SET failed_login_attempts = failed_login_attempts + 1,
+			login_locked_until = CASE WHEN failed_login_attempts + 1 >= ? THEN ? ELSE login_locked_until END
+		WHERE id = ?
+	`, maxFailedLogins, lockUntil.Unix(), userID)
+	return err
+}
+
+func (s *SQLStore) ClearFailedLogins(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE users SET failed_login_attempts = 0, login_locked_until = 0 WHERE id = ?`, userID)
+	return err
+}
+
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	if len(password) > maxPasswordLength {
+		return fmt.Errorf("password must be at most %d bytes", maxPasswordLength)
+	}
+	return nil
+}
+
+// PasswordLogin serves POST /api/auth/login, signing in with an email and the password
+// set through PUT /api/auth/password.
+func (h *APIHandler) PasswordLogin(w http.ResponseWriter, r *http.Request) {
+	var req passwordLoginBody
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	email, err := normalizeEmail(req.Email)
+	if err != nil || req.Password == "" {
+		respondAPIError(w, http.StatusUnauthorized, "invalid_credentials", errInvalidLoginText)
+		return
+	}
+
+	user, err := h.store.GetUserByEmail(r.Context(), email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondAPIError(w, http.StatusInternalServerError, "user_lookup_failed", err.Error())
+		return
+	}
+	var state userPasswordState
+	if user != nil {
+		if state, err = h.store.GetUserPasswordState(r.Context(), user.ID); err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "user_lookup_failed", err.Error())
+			return
+		}
+	}
+	now := time.Now()
+	if state.LockedUntil.After(now) {
+		respondAPIError(w, http.StatusTooManyRequests, "login_locked", "Too many incorrect passwords. Try again later or sign in with an email code.")
+		return
+	}
+	if state.Hash == "" {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.Password))
+		respondAPIError(w, http.StatusUnauthorized, "invalid_credentials", errInvalidLoginText)
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(state.Hash), []byte(req.Password)) != nil {
+		_ = h.store.RecordFailedLogin(r.Context(), user.ID, now.Add(failedLoginLockout))
+		respondAPIError(w, http.StatusUnauthorized, "invalid_credentials", errInvalidLoginText)
+		return
+	}
+	if state.FailedAttempts > 0 {
+		_ = h.store.ClearFailedLogins(r.Context(), user.ID)
+	}
+	_ = h.store.UpdateUserLastLogin(r.Context(), user.ID, now)
+	user.LastLoginAt = now
+
+	workspace, err := h.ensureDefaultWorkspaceForUser(r.Context(), user)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "workspace_create_failed", err.Error())
+		return
+	}
+
+	session := &SessionRecord{
+		ID:          newID("sess"),
+		UserID:      user.ID,
+		WorkspaceID: workspace.ID,
+		Plan:        PlanFree,
+		ExpiresAt:   now.Add(h.config.SessionTTL),
+		LastSeenAt:  now,
+		CreatedAt:   now,
+	}
+	if err := h.store.CreateSessionRecord(r.Context(), session); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "session_create_failed", err.Error())
+		return
+	}
+
+	h.writeCookie(w, sessionCookieName, session.ID, session.ExpiresAt)
+
+	requestWithSession := r.WithContext(context.WithValue(r.Context(), sessionContextKey, session))
+	respondJSON(w, http.StatusOK, h.buildSessionResponse(requestWithSession))
+}
+
+// SetPassword serves PUT /api/auth/password for the signed-in user. Changing an
+// existing password requires the current one; setting the first one does not, since
+// the session already proves the email. API tokens cannot set it.
+func (h *APIHandler) SetPassword(w http.ResponseWriter, r *http.Request) {
+	session := h.sessionFromRequest(r)
+	if !requireSessionLogin(w, session) {
+		return
+	}
+	var req setPasswordBody
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	state, err := h.store.GetUserPasswordState(r.Context(), session.UserID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "user_lookup_failed", err.Error())
+		return
+	}
+	if state.Hash != "" && bcrypt.CompareHashAndPassword([]byte(state.Hash), []byte(req.CurrentPassword)) != nil {
+		respondAPIError(w, http.StatusForbidden, "invalid_current_password", "Current password is incorrect")
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), passwordBcryptCost)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "password_hash_failed", err.Error())
+		return
+	}
+	if err := h.store.SetUserPasswordHash(r.Context(), session.UserID, string(hash), time.Now()); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "password_update_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// hasPassword reports whether a password is set, for the session response.
+func (h *APIHandler) hasPassword(ctx context.Context, userID string) bool {
+	state, err := h.store.GetUserPasswordState(ctx, userID)
+	return err == nil && strings.TrimSpace(state.Hash) != ""
+}
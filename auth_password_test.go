@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAPI_PasswordLoginCreatesSession(t *testing.T) {
+	env := setupAPITestEnv(t)
+	client := createAuthenticatedTestClient(t, env, "password@example.com", "Password User")
+	noAuth := map[string]string{"X-Test-No-Auth": "1"}
+	login := func(password string) int {
+		return doJSONRequestWithHeaders(t, env.router, http.MethodPost, "/api/auth/login", passwordLoginBody{Email: "Password@example.com", Password: password}, noAuth).Code
+	}
+
+	if code := login("correct horse"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 before a password is set, got %d", code)
+	}
+	if rr := doJSONRequest(t, client.router, http.MethodPut, "/api/auth/password", setPasswordBody{NewPassword: "short"}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected a short password to be rejected, got %d", rr.Code)
+	}
+	if rr := doJSONRequest(t, client.router, http.MethodPut, "/api/auth/password", setPasswordBody{NewPassword: "correct horse"}); rr.Code != http.StatusOK {
+		t.Fatalf("expected password set 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequest(t, client.router, http.MethodPut, "/api/auth/password", setPasswordBody{CurrentPassword: "wrong", NewPassword: "battery staple"}); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected a change without the current password to be rejected, got %d", rr.Code)
+	}
+
+	rr := doJSONRequestWithHeaders(t, env.router, http.MethodPost, "/api/auth/login", passwordLoginBody{Email: "password@example.com", Password: "correct horse"}, noAuth)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected login 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	session := decodeJSON[AuthSessionResponse](t, rr)
+	if !session.Authenticated || session.User == nil || session.User.ID != client.user.ID || !session.PasswordSet {
+		t.Fatalf("expected a session for the user, got %+v", session)
+	}
+	cookies := rr.Result().Cookies()
+	if len(cookies) == 0 || cookies[0].Name != sessionCookieName {
+		t.Fatalf("expected session cookie to be set, got %+v", cookies)
+	}
+	cookie := map[string]string{"Cookie": sessionCookieName + "=" + cookies[0].Value}
+	if rr := doJSONRequestWithHeaders(t, env.router, http.MethodGet, "/api/decks", struct{}{}, cookie); rr.Code != http.StatusOK {
+		t.Fatalf("expected the new session to reach protected endpoints, got %d", rr.Code)
+	}
+	if rr := doJSONRequestWithHeaders(t, env.router, http.MethodPost, "/api/auth/logout", struct{}{}, cookie); rr.Code != http.StatusOK {
+		t.Fatalf("expected logout 200, got %d", rr.Code)
+	}
+	if rr := doJSONRequestWithHeaders(t, env.router, http.MethodGet, "/api/decks", struct{}{}, cookie); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the session to end at logout, got %d", rr.Code)
+	}
+
+	for i := 0; i < maxFailedLogins; i++ {
+		if code := login("wrong password"); code != http.StatusUnauthorized {
+			t.Fatalf("expected wrong password 401, got %d", code)
+		}
+	}
+	if code := login("correct horse"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected login locked after repeated failures, got %d", code)
+	}
+}
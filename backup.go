@@ -2,10 +2,13 @@ package main
 
 import (
 	"archive/zip"
+	"context"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 )
 
@@ -13,11 +16,13 @@ import (
 type BackupManager struct {
 	dbPath       string
 	backupDir    string
-	store        *SQLiteStore
+	store        Store
+	policyMu     sync.Mutex
+	policy       BackupPolicy
 }
 
 // NewBackupManager creates a new backup manager.
-func NewBackupManager(dbPath string, backupDir string, store *SQLiteStore) *BackupManager {
+func NewBackupManager(dbPath string, backupDir string, store Store) *BackupManager {
 	return &BackupManager{
 		dbPath:    dbPath,
 		backupDir: backupDir,
@@ -25,9 +30,22 @@ func NewBackupManager(dbPath string, backupDir string, store *SQLiteStore) *Back
 	}
 }
 
-// CreateBackup creates a timestamped backup of the SQLite database.
+// CreateBackup creates a timestamped backup of the SQLite database, then prunes old
+// backups by the retention policy.
 // Returns the path to the backup file.
-func (bm *BackupManager) CreateBackup(collectionID string) (string, error) {
+func (bm *BackupManager) CreateBackup(ctx context.Context, collectionID string) (string, error) {
+	backupPath, err := bm.createBackup(ctx, collectionID, nil)
+	if err != nil {
+		return "", err
+	}
+	if _, err := bm.PruneBackups(); err != nil {
+		log.Printf("Warning: backup pruning failed: %v", err)
+	}
+	return backupPath, nil
+}
+
+// createBackup is CreateBackup reporting the bytes of the database copied to progress.
+func (bm *BackupManager) createBackup(ctx context.Context, collectionID string, progress *operation) (string, error) {
 	// Ensure backup directory exists
 	if err := os.MkdirAll(bm.backupDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create backup directory: %w", err)
@@ -48,8 +66,16 @@ func (bm *BackupManager) CreateBackup(collectionID string) (string, error) {
 	zipWriter := zip.NewWriter(zipFile)
 	defer zipWriter.Close()
 
-	// Add SQLite database to ZIP
-	if err := bm.addFileToZip(zipWriter, bm.dbPath, "collection.db"); err != nil {
+	// Add SQLite database to ZIP, once the writes still in its WAL are in the file
+	if bm.store != nil {
+		if err := bm.store.checkpoint(ctx); err != nil {
+			return "", fmt.Errorf("failed to checkpoint database: %w", err)
+		}
+	}
+	if info, err := os.Stat(bm.dbPath); err == nil {
+		progress.setTotal(info.Size())
+	}
+	if err := bm.addFileToZip(zipWriter, bm.dbPath, "collection.db", progress); err != nil {
 		return "", fmt.Errorf("failed to add database to backup: %w", err)
 	}
 
@@ -69,7 +95,8 @@ func (bm *BackupManager) CreateBackup(collectionID string) (string, error) {
 	return backupPath, nil
 }
 
-// RestoreBackup restores a collection from a backup ZIP file.
+// RestoreBackup restores a collection from a backup ZIP file, keeping the database it
+// replaces beside it so undoRestore can put it back.
 // WARNING: This replaces the current database. The database connection should be closed
 // before calling this function.
 func (bm *BackupManager) RestoreBackup(backupPath string) error {
@@ -107,82 +134,74 @@ func (bm *BackupManager) RestoreBackup(backupPath string) error {
 		return fmt.Errorf("failed to extract database: %w", err)
 	}
 
-	// Backup current database before replacing (just in case)
-	currentBackupPath := bm.dbPath + ".pre-restore.backup"
+	// Backup current database before replacing
+	currentBackupPath := bm.preRestorePath()
 	if err := bm.copyFile(bm.dbPath, currentBackupPath); err != nil {
-		fmt.Printf("Warning: could not backup current database: %v\n", err)
-	} else {
-		fmt.Printf("Current database backed up to: %s\n", currentBackupPath)
+		return fmt.Errorf("failed to save current database: %w", err)
 	}
+	fmt.Printf("Current database backed up to: %s\n", currentBackupPath)
 
-	// Replace current database with restored one
+	// Replace current database with restored one. A WAL left beside it belongs to the
+	// old database and must not be replayed into the new one.
+	removeSQLiteJournal(bm.dbPath)
 	if err := os.Rename(tempPath, bm.dbPath); err != nil {
 		return fmt.Errorf("failed to replace database: %w", err)
 	}
 
-	// Set flag in metadata to disable auto-sync until user confirms
-	// This will be checked when the server restarts
-	// (In a full implementation, this would integrate with sync system)
-
 	fmt.Printf("Database restored from: %s\n", backupPath)
-	fmt.Println("IMPORTANT: Auto-sync is disabled. Restart server to use restored database.")
-
 	return nil
 }
 
-// CleanupOldBackups removes backups older than the retention policy.
-// retentionCount: number of most recent backups to keep (e.g., 30)
-func (bm *BackupManager) CleanupOldBackups(retentionCount int) error {
-	// List all backup files
-	files, err := filepath.Glob(filepath.Join(bm.backupDir, "microdote-backup-*.zip"))
-	if err != nil {
-		return fmt.Errorf("failed to list backups: %w", err)
+// undoRestore puts back the database the last RestoreBackup replaced.
+func (bm *BackupManager) undoRestore() error {
+	removeSQLiteJournal(bm.dbPath)
+	if err := os.Rename(bm.preRestorePath(), bm.dbPath); err != nil {
+		return fmt.Errorf("failed to put back the database: %w", err)
 	}
+	return nil
+}
 
-	if len(files) <= retentionCount {
-		return nil // Nothing to clean up
+// removeSQLiteJournal removes the WAL and shared-memory files of the closed database
+// at path.
+func removeSQLiteJournal(path string) {
+	for _, suffix := range []string{"-wal", "-shm"} {
+		_ = os.Remove(path + suffix)
 	}
+}
 
-	// Sort by modification time (oldest first)
-	type fileInfo struct {
-		path    string
-		modTime time.Time
-	}
+func (bm *BackupManager) preRestorePath() string {
+	return bm.dbPath + ".pre-restore.backup"
+}
 
-	var fileInfos []fileInfo
-	for _, path := range files {
-		info, err := os.Stat(path)
-		if err != nil {
-			continue
-		}
-		fileInfos = append(fileInfos, fileInfo{path: path, modTime: info.ModTime()})
+// resolveBackup finds the backup a client named by file name or by the path ListBackups
+// reported. Only files in the backup directory are backups.
+func (bm *BackupManager) resolveBackup(name string) (string, error) {
+	path := filepath.Clean(name)
+	if !filepath.IsAbs(path) && filepath.Base(path) == path {
+		path = filepath.Join(bm.backupDir, path)
 	}
-
-	// Sort by modification time (oldest first)
-	for i := 0; i < len(fileInfos); i++ {
-		for j := i + 1; j < len(fileInfos); j++ {
-			if fileInfos[i].modTime.After(fileInfos[j].modTime) {
-				fileInfos[i], fileInfos[j] = fileInfos[j], fileInfos[i]
-			}
-		}
+	dir, err := filepath.Abs(bm.backupDir)
+	if err != nil {
+		return "", err
 	}
-
-	// Delete oldest backups beyond retention count
-	deleteCount := len(fileInfos) - retentionCount
-	for i := 0; i < deleteCount; i++ {
-		if err := os.Remove(fileInfos[i].path); err != nil {
-			fmt.Printf("Warning: failed to delete old backup %s: %v\n", fileInfos[i].path, err)
-		} else {
-			fmt.Printf("Deleted old backup: %s\n", fileInfos[i].path)
-		}
+	if abs, err := filepath.Abs(path); err != nil || filepath.Dir(abs) != dir {
+		return "", fmt.Errorf("%s is not in the backup directory", name)
 	}
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("backup file not found: %s", filepath.Base(path))
+	}
+	return path, nil
+}
 
-	return nil
+// CleanupOldBackups removes all but the retentionCount most recent backups.
+func (bm *BackupManager) CleanupOldBackups(retentionCount int) error {
+	_, err := bm.prune(BackupPolicy{KeepLast: retentionCount})
+	return err
 }
 
 // Helper functions
 
-func (bm *BackupManager) addFileToZip(zipWriter *zip.Writer, filePath string, nameInZip string) error {
+func (bm *BackupManager) addFileToZip(zipWriter *zip.Writer, filePath string, nameInZip string, progress *operation) error {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return err
@@ -194,7 +213,7 @@ func (bm *BackupManager) addFileToZip(zipWriter *zip.Writer, filePath string, na
 		return err
 	}
 
-	_, err = io.Copy(writer, file)
+	_, err = io.Copy(writer, progress.countingReader(file))
 	return err
 }
 
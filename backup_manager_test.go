@@ -2,6 +2,7 @@ package main
 
 import (
 	"archive/zip"
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -147,7 +148,7 @@ func TestBackupManager_CreateBackupErrorsWhenDBMissing(t *testing.T) {
 	backupDir := filepath.Join(tempDir, "backups")
 
 	bm := NewBackupManager(dbPath, backupDir, nil)
-	if _, err := bm.CreateBackup("default"); err == nil {
+	if _, err := bm.CreateBackup(context.Background(), "default"); err == nil {
 		t.Fatal("expected create backup to fail when DB file is missing")
 	}
 }
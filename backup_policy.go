@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// BackupPolicy decides which backups survive pruning: the KeepLast most recent, plus
+// the newest backup of each of the last KeepDaily days, KeepWeekly ISO weeks, and
+// KeepMonthly months that have one. The zero policy keeps every backup.
+type BackupPolicy struct {
+	KeepLast    int `json:"keepLast"`
+	KeepDaily   int `json:"keepDaily"`
+	KeepWeekly  int `json:"keepWeekly"`
+	KeepMonthly int `json:"keepMonthly"`
+}
+
+func (p BackupPolicy) enabled() bool {
+	return p.KeepLast > 0 || p.KeepDaily > 0 || p.KeepWeekly > 0 || p.KeepMonthly > 0
+}
+
+func (p *BackupPolicy) Validate(v *requestValidator) {
+	v.intRange("keepLast", p.KeepLast, 0, maxBackupPolicyCount)
+	v.intRange("keepDaily", p.KeepDaily, 0, maxBackupPolicyCount)
+	v.intRange("keepWeekly", p.KeepWeekly, 0, maxBackupPolicyCount)
+	v.intRange("keepMonthly", p.KeepMonthly, 0, maxBackupPolicyCount)
+}
+
+const (
+	maxBackupPolicyCount = 1000
+	backupPolicyKey      = "backup_policy"
+)
+
+type BackupPolicyResponse struct {
+	Policy BackupPolicy `json:"policy"`
+	Pruned []string     `json:"pruned"`
+}
+
+type backupFile struct {
+	path    string
+	modTime time.Time
+}
+
+// listBackupFiles returns the backups in the backup directory, newest first.
+func (bm *BackupManager) listBackupFiles() ([]backupFile, error) {
+	paths, err := filepath.Glob(filepath.Join(bm.backupDir, "microdote-backup-*.zip"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	files := make([]backupFile, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		files = append(files, backupFile{path: path, modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	return files, nil
+}
+
+// keptBackups marks the backups policy keeps; files are newest first.
+func (p BackupPolicy) keptBackups(files []backupFile) map[string]bool {
+	kept := map[string]bool{}
+	for i := 0; i < p.KeepLast && i < len(files); i++ {
+		kept[files[i].path] = true
+	}
+	keepNewestPer := func(limit int, period func(time.Time) string) {
+		seen := map[string]bool{}
+		for _, file := range files {
+			if len(seen) >= limit {
+				return
+			}
+			if key := period(file.modTime.UTC()); !seen[key] {
+				seen[key] = true
+				kept[file.path] = true
+			}
+		}
+	}
+	keepNewestPer(p.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") })
+	keepNewestPer(p.KeepWeekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+	keepNewestPer(p.KeepMonthly, func(t time.Time) string { return t.Format("2006-01") })
+	return kept
+}
+
+// Policy returns the retention policy applied after each backup.
+func (bm *BackupManager) Policy() BackupPolicy {
+	bm.policyMu.Lock()
+	defer bm.policyMu.Unlock()
+	return bm.policy
+}
+
+func (bm *BackupManager) SetPolicy(policy BackupPolicy) {
+	bm.policyMu.Lock()
+	defer bm.policyMu.Unlock()
+	bm.policy = policy
+}
+
+// PruneBackups deletes the backups the retention policy does not keep and returns the
+// names of those it deleted. A disabled policy deletes nothing.
+func (bm *BackupManager) PruneBackups() ([]string, error) {
+	policy := bm.Policy()
+	if !policy.enabled() {
+		return []string{}, nil
+	}
+	return bm.prune(policy)
+}
+
+func (bm *BackupManager) prune(policy BackupPolicy) ([]string, error) {
+	pruned := []string{}
+	files, err := bm.listBackupFiles()
+	if err != nil {
+		return pruned, err
+	}
+	kept := policy.keptBackups(files)
+	var errs []error
+	for _, file := range files {
+		if kept[file.path] {
+			continue
+		}
+		if err := os.Remove(file.path); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete old backup %s: %w", filepath.Base(file.path), err))
+			continue
+		}
+		log.Printf("Deleted old backup: %s", file.path)
+		pruned = append(pruned, filepath.Base(file.path))
+	}
+	return pruned, errors.Join(errs...)
+}
+
+// loadBackupPolicy returns the policy last saved through the API, or fallback, the
+// configured one, if none has been.
+func (s *SQLStore) loadBackupPolicy(ctx context.Context, fallback BackupPolicy) (BackupPolicy, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx, `SELECT value FROM metadata WHERE key = ?`, backupPolicyKey).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fallback, nil
+	}
+	if err != nil {
+		return fallback, err
+	}
+	var policy BackupPolicy
+	if err := json.Unmarshal([]byte(value), &policy); err != nil {
+		return fallback, fmt.Errorf("stored backup policy is invalid: %w", err)
+	}
+	return policy, nil
+}
+
+func (s *SQLStore) saveBackupPolicy(ctx context.Context, policy BackupPolicy) error {
+	value, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO metadata (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, backupPolicyKey, string(value))
+	return err
+}
+
+// applyStoredBackupPolicy starts the backup manager with the saved policy, falling back
+// to the configured one.
+func (h *APIHandler) applyStoredBackupPolicy(ctx context.Context) {
+	if h.backupManager == nil || h.profiles.registry == nil {
+		return
+	}
+	policy, err := h.profiles.registry.loadBackupPolicy(ctx, h.config.BackupPolicy)
+	if err != nil {
+		log.Printf("Warning: using the configured backup policy: %v", err)
+	}
+	h.backupManager.SetPolicy(policy)
+}
+
+func (h *APIHandler) GetBackupPolicy(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, h.backupManager.Policy())
+}
+
+// UpdateBackupPolicy serves PUT /api/backups/policy. The policy is saved in the main
+// database, so it outlasts restarts and profile switches, and is applied at once.
+func (h *APIHandler) UpdateBackupPolicy(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	var policy BackupPolicy
+	if !decodeValidatedRequest(w, r, &policy) {
+		return
+	}
+	if err := h.profiles.registry.saveBackupPolicy(r.Context(), policy); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "backup_policy_save_failed", err.Error())
+		return
+	}
+	h.backupManager.SetPolicy(policy)
+	pruned, err := h.backupManager.PruneBackups()
+	if err != nil {
+		respondAPIErrorWithDetails(w, http.StatusInternalServerError, "backup_prune_failed", err.Error(), BackupPolicyResponse{Policy: policy, Pruned: pruned})
+		return
+	}
+	respondJSON(w, http.StatusOK, BackupPolicyResponse{Policy: policy, Pruned: pruned})
+}
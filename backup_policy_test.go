@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackupPolicyKeepsNewestPerPeriod(t *testing.T) {
+	day := func(date string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04", date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	// Newest first, as listBackupFiles returns them.
+	files := []backupFile{
+		{path: "a", modTime: day("2026-03-10 18:00")},
+		{path: "b", modTime: day("2026-03-10 09:00")},
+		{path: "c", modTime: day("2026-03-09 09:00")},
+		{path: "d", modTime: day("2026-03-02 09:00")},
+		{path: "e", modTime: day("2026-02-20 09:00")},
+		{path: "f", modTime: day("2026-01-15 09:00")},
+	}
+	for _, tc := range []struct {
+		policy BackupPolicy
+		want   []string
+	}{
+		{BackupPolicy{KeepLast: 2}, []string{"a", "b"}},
+		{BackupPolicy{KeepDaily: 2}, []string{"a", "c"}},
+		{BackupPolicy{KeepWeekly: 2}, []string{"a", "d"}},
+		{BackupPolicy{KeepMonthly: 3}, []string{"a", "e", "f"}},
+		{BackupPolicy{KeepDaily: 1, KeepMonthly: 2}, []string{"a", "e"}},
+	} {
+		kept := tc.policy.keptBackups(files)
+		if len(kept) != len(tc.want) {
+			t.Errorf("%+v kept %v, want %v", tc.policy, kept, tc.want)
+			continue
+		}
+		for _, path := range tc.want {
+			if !kept[path] {
+				t.Errorf("%+v kept %v, want %v", tc.policy, kept, tc.want)
+			}
+		}
+	}
+}
+
+func TestAPI_BackupPolicyPrunesAndPersists(t *testing.T) {
+	env := setupFileAPITestEnv(t)
+	if err := os.MkdirAll(env.backupDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"20260101-000000", "20260102-000000", "20260103-000000"} {
+		path := filepath.Join(env.backupDir, "microdote-backup-"+name+".zip")
+		if err := os.WriteFile(path, []byte("backup"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := time.Date(2026, 1, 1+i, 0, 0, 0, 0, time.UTC)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if rr := doJSONRequest(t, env.router, http.MethodPut, "/api/backups/policy", BackupPolicy{KeepDaily: -1}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected a negative count to be rejected, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr := doJSONRequest(t, env.router, http.MethodPut, "/api/backups/policy", BackupPolicy{KeepDaily: 2})
+	updated := decodeJSON[BackupPolicyResponse](t, rr)
+	if rr.Code != http.StatusOK || len(updated.Pruned) != 1 || updated.Pruned[0] != "microdote-backup-20260101-000000.zip" {
+		t.Fatalf("expected the oldest backup to be pruned, got %d %+v", rr.Code, updated)
+	}
+
+	// Each new backup applies the policy: it and the newest older day survive.
+	rr = doRawRequest(env.router, http.MethodPost, "/api/backups", "{}")
+	created := decodeJSON[CreateBackupResponse](t, rr)
+	if rr.Code != http.StatusCreated || len(created.Pruned) != 1 || created.Pruned[0] != "microdote-backup-20260102-000000.zip" {
+		t.Fatalf("expected the backup to prune the second oldest, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	restarted := NewAPIHandlerWithConfig(env.store, env.collection, NewBackupManager(env.dbPath, env.backupDir, env.store), mustLocalAppConfig(), nil)
+	if policy := restarted.backupManager.Policy(); policy != (BackupPolicy{KeepDaily: 2}) {
+		t.Fatalf("expected the saved policy to outlast a restart, got %+v", policy)
+	}
+	if policy := decodeJSON[BackupPolicy](t, doRawRequest(env.router, http.MethodGet, "/api/backups/policy", "")); policy.KeepDaily != 2 {
+		t.Fatalf("expected GET to return the policy, got %+v", policy)
+	}
+}
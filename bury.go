@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SetCardsBuried buries cards for the rest of the study day, or unburies them, for the
+// user. A blank userID updates the shared card rows.
+func (s *SQLStore) SetCardsBuried(ctx context.Context, userID string, cardIDs []int64, buried bool, now time.Time) (int, error) {
+	var buriedAt int64
+	if buried {
+		buriedAt = now.Unix()
+	}
+	return s.updateCardsStateColumn(ctx, userID, cardIDs, "buried_at", buriedAt)
+}
+
+// unburyPastDays is the day-rollover pass: it unburies every card in the collection
+// buried before its current study day began at dayStart. It runs ahead of building
+// queues and counts, so cards come back on the first visit of a new day.
+func (s *SQLStore) unburyPastDays(ctx context.Context, collectionID string, dayStart time.Time) error {
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE cards SET buried_at = 0
+		WHERE buried_at > 0 AND buried_at < ? AND deck_id IN (SELECT id FROM decks WHERE collection_id = ?)
+	`, dayStart.Unix(), collectionID); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE card_review_states SET buried_at = 0
+		WHERE buried_at > 0 AND buried_at < ?
+		  AND card_id IN (SELECT c.id FROM cards c JOIN decks d ON d.id = c.deck_id WHERE d.collection_id = ?)
+	`, dayStart.Unix(), collectionID)
+	return err
+}
+
+// BuryCard serves POST /api/cards/{id}/bury, hiding the card from the due queue until
+// the next study day.
+func (h *APIHandler) BuryCard(w http.ResponseWriter, r *http.Request) {
+	h.setCardBuried(w, r, true)
+}
+
+// UnburyCard serves POST /api/cards/{id}/unbury.
+func (h *APIHandler) UnburyCard(w http.ResponseWriter, r *http.Request) {
+	h.setCardBuried(w, r, false)
+}
+
+func (h *APIHandler) setCardBuried(w http.ResponseWriter, r *http.Request, buried bool) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	id, err := parseIDParam(r, "id")
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_card_id", "Invalid card ID")
+		return
+	}
+	decks, err := h.store.CardDecksInCollection(r.Context(), collectionID, []int64{id})
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "card_lookup_failed", err.Error())
+		return
+	}
+	if _, ok := decks[id]; !ok {
+		respondAPIError(w, http.StatusNotFound, "card_not_found", "Card not found")
+		return
+	}
+
+	userID := h.userIDFromRequest(r)
+	if _, err := h.store.SetCardsBuried(r.Context(), userID, []int64{id}, buried, time.Now()); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "card_bury_failed", err.Error())
+		return
+	}
+	card, err := h.store.GetCardForUser(r.Context(), userID, id)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "card_load_failed", err.Error())
+		return
+	}
+	if cached, ok := col.Cards[id]; ok && strings.TrimSpace(userID) == "" {
+		cached.Buried = buried
+	}
+	respondJSON(w, http.StatusOK, card)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAPI_BuryHidesCardUntilNextStudyDay(t *testing.T) {
+	env := setupAPITestEnv(t)
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Glycolysis", "Back": "Glucose to pyruvate"},
+	}, nil)
+	cardID := created.Cards[0].ID
+
+	rr := doRawRequest(env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/bury", cardID), "")
+	if card := decodeJSON[Card](t, rr); rr.Code != http.StatusOK || !card.Buried {
+		t.Fatalf("expected the card buried, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if due := decodeJSON[[]*Card](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=10", "")); len(due) != 0 {
+		t.Fatalf("expected a buried card left out of the queue, got %d", len(due))
+	}
+	if stats := decodeJSON[DeckStats](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/stats", "")); stats.Buried != 1 || stats.DueToday != 0 {
+		t.Fatalf("expected one buried card in deck stats, got %+v", stats)
+	}
+
+	rr = doRawRequest(env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/unbury", cardID), "")
+	if card := decodeJSON[Card](t, rr); rr.Code != http.StatusOK || card.Buried {
+		t.Fatalf("expected the card unburied, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if due := decodeJSON[[]*Card](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=10", "")); len(due) != 1 {
+		t.Fatalf("expected the unburied card back in the queue, got %d", len(due))
+	}
+
+	// A card buried yesterday comes back with the first queue of the new day.
+	if rr := doRawRequest(env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/bury", cardID), ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected bury 200, got %d", rr.Code)
+	}
+	dayStart, _ := studyDayBounds(time.Now(), defaultNextDayStartsAt)
+	yesterday := dayStart.Add(-time.Hour).Unix()
+	if _, err := env.store.db.Exec(`UPDATE card_review_states SET buried_at = ? WHERE card_id = ? AND buried_at > 0`, yesterday, cardID); err != nil {
+		t.Fatalf("backdate burial failed: %v", err)
+	}
+	if due := decodeJSON[[]*Card](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=10", "")); len(due) != 1 || due[0].Buried {
+		t.Fatalf("expected the card unburied by the day rollover, got %+v", due)
+	}
+
+	if rr := doRawRequest(env.router, http.MethodPost, "/api/cards/999999/bury", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown card 404, got %d", rr.Code)
+	}
+}
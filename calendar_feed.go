@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+const (
+	defaultCalendarFeedDays = 30
+	maxCalendarFeedDays     = 180
+)
+
+// CalendarFeed is a per-user secret URL that calendar apps poll for the review forecast.
+// Only a hash of the token is stored, like an API token's, so Token is set only in the
+// response that creates or rotates the feed.
+type CalendarFeed struct {
+	ID             string    `json:"id"`
+	UserID         string    `json:"userId"`
+	CollectionID   string    `json:"collectionId"`
+	Token          string    `json:"token,omitempty"`
+	TokenHash      string    `json:"-"`
+	CreatedAt      time.Time `json:"createdAt"`
+	LastAccessedAt time.Time `json:"lastAccessedAt,omitempty"`
+}
+
+type CalendarFeedResponse struct {
+	Feed CalendarFeed `json:"feed"`
+	URL  string       `json:"url,omitempty"`
+}
+
+// ReviewForecastDay is the number of reviews scheduled on a study day, which begins at
+// the collection's next-day hour. Overdue cards are counted on the first day of the
+// forecast.
+type ReviewForecastDay struct {
+	Date    time.Time `json:"date"`
+	Reviews int       `json:"reviews"`
+}
+
+func calendarFeedURL(token string) string {
+	return "/calendar.ics?token=" + token
+}
+
+func hashCalendarFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func scanCalendarFeed(scanner interface{ Scan(dest ...any) error }) (*CalendarFeed, error) {
+	var (
+		feed           CalendarFeed
+		createdAt      int64
+		lastAccessedAt sql.NullInt64
+	)
+	if err := scanner.Scan(&feed.ID, &feed.UserID, &feed.CollectionID, &feed.TokenHash, &createdAt, &lastAccessedAt); err != nil {
+		return nil, err
+	}
+	feed.CreatedAt = time.Unix(createdAt, 0)
+	feed.LastAccessedAt = unixTimeOrZero(lastAccessedAt)
+	return &feed, nil
+}
+
+// UpsertCalendarFeed creates the user's feed for a collection, or rotates its token if
+// one already exists so the old URL stops working.
+func (s *SQLStore) UpsertCalendarFeed(ctx context.Context, feed *CalendarFeed) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO calendar_feeds (id, user_id, collection_id, token_hash, created_at, last_accessed_at)
+		VALUES (?, ?, ?, ?, ?, NULL)
+		ON CONFLICT(user_id, collection_id) DO UPDATE SET
+			token_hash = excluded.token_hash,
+			created_at = excluded.created_at,
+			last_accessed_at = NULL
+	`, feed.ID, feed.UserID, feed.CollectionID, hashCalendarFeedToken(feed.Token), feed.CreatedAt.Unix())
+	return err
+}
+
+func (s *SQLStore) GetCalendarFeedForUser(ctx context.Context, userID, collectionID string) (*CalendarFeed, error) {
+	return scanCalendarFeed(s.db.QueryRowContext(ctx, `
+		SELECT id, user_id, collection_id, token_hash, created_at, last_accessed_at
+		FROM calendar_feeds
+		WHERE user_id = ? AND collection_id = ?
+	`, userID, collectionID))
+}
+
+// GetCalendarFeedByToken finds the feed whose token hashes to the stored hash.
+func (s *SQLStore) GetCalendarFeedByToken(ctx context.Context, token string) (*CalendarFeed, error) {
+	return scanCalendarFeed(s.db.QueryRowContext(ctx, `
+		SELECT id, user_id, collection_id, token_hash, created_at, last_accessed_at
+		FROM calendar_feeds
+		WHERE token_hash = ?
+	`, hashCalendarFeedToken(token)))
+}
+
+func (s *SQLStore) TouchCalendarFeed(ctx context.Context, id string, accessedAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE calendar_feeds SET last_accessed_at = ? WHERE id = ?`, accessedAt.Unix(), id)
+	return err
+}
+
+func (s *SQLStore) DeleteCalendarFeedForUser(ctx context.Context, userID, collectionID string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM calendar_feeds WHERE user_id = ? AND collection_id = ?`, userID, collectionID)
+	return err
+}
+
+// GetReviewForecastForUser returns one entry per study day starting at the day from
+// falls in, counting the user's non-new, unsuspended cards that come due on that day.
+// Days follow the collection's next-day hour, so they match the app's due counts.
+func (s *SQLStore) GetReviewForecastForUser(ctx context.Context, userID, collectionID string, from time.Time, days int) ([]ReviewForecastDay, error) {
+	if days <= 0 {
+		days = defaultCalendarFeedDays
+	}
+	start, _, err := s.studyDay(ctx, collectionID, from)
+	if err != nil {
+		return nil, err
+	}
+
+	forecast := make([]ReviewForecastDay, days)
+	// ends[i] is when day i ends; days are built with AddDate so they stay aligned to
+	// the next-day hour across daylight saving changes.
+	ends := make([]int64, days)
+	for i := range forecast {
+		forecast[i].Date = start.AddDate(0, 0, i)
+		ends[i] = start.AddDate(0, 0, i+1).Unix()
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT rs.due
+		FROM card_review_states rs
+		JOIN cards c ON c.id = rs.card_id
+		JOIN decks d ON d.id = c.deck_id
+		WHERE rs.user_id = ?
+		  AND d.collection_id = ?
+		  AND rs.state != ?
+		  AND COALESCE(rs.suspended, 0) = 0
+		  AND rs.due < ?
+	`, userID, collectionID, int(fsrs.New), ends[days-1])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var due int64
+		if err := rows.Scan(&due); err != nil {
+			return nil, err
+		}
+		// Overdue cards land on the first day, whose end is the first one after due.
+		if i := sort.Search(days, func(i int) bool { return due < ends[i] }); i < days {
+			forecast[i].Reviews++
+		}
+	}
+	return forecast, rows.Err()
+}
+
+// renderReviewForecastICS renders the forecast as an iCalendar document with one
+// all-day event for every day that has reviews scheduled.
+func renderReviewForecastICS(feedID string, forecast []ReviewForecastDay, generatedAt time.Time) string {
+	var b strings.Builder
+	writeLine := func(line string) {
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+
+	stamp := generatedAt.UTC().Format("20060102T150405Z")
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:-//Vutadex//Review Forecast//EN")
+	writeLine("CALSCALE:GREGORIAN")
+	writeLine("METHOD:PUBLISH")
+	writeLine("X-WR-CALNAME:Vutadex reviews")
+	for _, day := range forecast {
+		if day.Reviews <= 0 {
+			continue
+		}
+		summary := fmt.Sprintf("%d reviews due", day.Reviews)
+		if day.Reviews == 1 {
+			summary = "1 review due"
+		}
+		writeLine("BEGIN:VEVENT")
+		writeLine(fmt.Sprintf("UID:%s-%s@vutadex", feedID, day.Date.Format("20060102")))
+		writeLine("DTSTAMP:" + stamp)
+		writeLine("DTSTART;VALUE=DATE:" + day.Date.Format("20060102"))
+		writeLine("DTEND;VALUE=DATE:" + day.Date.AddDate(0, 0, 1).Format("20060102"))
+		writeLine("SUMMARY:" + summary)
+		writeLine("TRANSP:TRANSPARENT")
+		writeLine("END:VEVENT")
+	}
+	writeLine("END:VCALENDAR")
+	return b.String()
+}
+
+func (h *APIHandler) GetCalendarFeedSettings(w http.ResponseWriter, r *http.Request) {
+	userID := h.userIDFromRequest(r)
+	feed, err := h.store.GetCalendarFeedForUser(r.Context(), userID, h.collectionIDForRequest(r))
+	if err == sql.ErrNoRows {
+		respondAPIError(w, http.StatusNotFound, "calendar_feed_not_found", "Calendar feed is not enabled")
+		return
+	}
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "calendar_feed_load_failed", err.Error())
+		return
+	}
+
+	// The token is only known when the feed is created; the settings show the feed
+	// without its URL.
+	respondJSON(w, http.StatusOK, CalendarFeedResponse{Feed: *feed})
+}
+
+// CreateCalendarFeed enables the feed, or rotates the token of an existing one.
+func (h *APIHandler) CreateCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	userID := h.userIDFromRequest(r)
+	if userID == "" {
+		respondAPIError(w, http.StatusUnauthorized, "auth_required", "Sign in to enable the calendar feed")
+		return
+	}
+
+	feed := &CalendarFeed{
+		ID:           newID("cal"),
+		UserID:       userID,
+		CollectionID: h.collectionIDForRequest(r),
+		Token:        randomToken(),
+		CreatedAt:    time.Now(),
+	}
+	if err := h.store.UpsertCalendarFeed(r.Context(), feed); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "calendar_feed_failed", err.Error())
+		return
+	}
+	stored, err := h.store.GetCalendarFeedForUser(r.Context(), feed.UserID, feed.CollectionID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "calendar_feed_failed", err.Error())
+		return
+	}
+	stored.Token = feed.Token
+
+	respondJSON(w, http.StatusCreated, CalendarFeedResponse{Feed: *stored, URL: calendarFeedURL(feed.Token)})
+}
+
+func (h *APIHandler) DeleteCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.DeleteCalendarFeedForUser(r.Context(), h.userIDFromRequest(r), h.collectionIDForRequest(r)); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "calendar_feed_delete_failed", err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ServeCalendarICS serves GET /calendar.ics. Calendar clients cannot send session cookies,
+// so the feed token in the query string is the only credential.
+func (h *APIHandler) ServeCalendarICS(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSpace(r.URL.Query().Get("token"))
+	if token == "" {
+		respondAPIError(w, http.StatusUnauthorized, "calendar_token_required", "Missing calendar token")
+		return
+	}
+
+	feed, err := h.store.GetCalendarFeedByToken(r.Context(), token)
+	if err == sql.ErrNoRows {
+		respondAPIError(w, http.StatusNotFound, "calendar_feed_not_found", "Calendar feed not found")
+		return
+	}
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "calendar_feed_lookup_failed", err.Error())
+		return
+	}
+
+	days := defaultCalendarFeedDays
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		if d, err := strconv.Atoi(daysStr); err == nil && d > 0 {
+			days = min(d, maxCalendarFeedDays)
+		}
+	}
+
+	now := time.Now()
+	forecast, err := h.store.GetReviewForecastForUser(r.Context(), feed.UserID, feed.CollectionID, now, days)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "calendar_forecast_failed", err.Error())
+		return
+	}
+	_ = h.store.TouchCalendarFeed(r.Context(), feed.ID, now)
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="vutadex-reviews.ics"`)
+	w.Header().Set("Cache-Control", "private, max-age=900")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(renderReviewForecastICS(feed.ID, forecast, now)))
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestRenderReviewForecastICS_SkipsEmptyDays(t *testing.T) {
+	start := time.Date(2026, 5, 4, 0, 0, 0, 0, time.UTC)
+	forecast := []ReviewForecastDay{
+		{Date: start, Reviews: 1},
+		{Date: start.AddDate(0, 0, 1), Reviews: 0},
+		{Date: start.AddDate(0, 0, 2), Reviews: 12},
+	}
+
+	ics := renderReviewForecastICS("cal_test", forecast, start)
+	if strings.Count(ics, "BEGIN:VEVENT") != 2 {
+		t.Fatalf("expected 2 events, got:\n%s", ics)
+	}
+	for _, want := range []string{
+		"DTSTART;VALUE=DATE:20260504\r\n",
+		"SUMMARY:1 review due\r\n",
+		"DTSTART;VALUE=DATE:20260506\r\n",
+		"DTEND;VALUE=DATE:20260507\r\n",
+		"SUMMARY:12 reviews due\r\n",
+		"UID:cal_test-20260506@vutadex\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Fatalf("expected ICS to contain %q, got:\n%s", want, ics)
+		}
+	}
+	if !strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\n") || !strings.HasSuffix(ics, "END:VCALENDAR\r\n") {
+		t.Fatalf("expected ICS to be wrapped in VCALENDAR, got:\n%s", ics)
+	}
+}
+
+func TestAPI_CalendarFeedServesForecastByToken(t *testing.T) {
+	env := setupAPITestEnv(t)
+	sessionID := strings.TrimPrefix(env.authCookie, sessionCookieName+"=")
+	sessionRecord, err := env.store.GetSessionRecord(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("failed to load current session: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		createNoteForTest(t, env, CreateNoteRequest{
+			TypeID: "Basic",
+			DeckID: 1,
+			FieldVals: map[string]string{
+				"Front": fmt.Sprintf("Calendar %d", i),
+				"Back":  "Answer",
+			},
+		}, nil)
+	}
+	dueRR := doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=100", "")
+	dueCards := decodeJSON[[]Card](t, dueRR)
+	if len(dueCards) != 3 {
+		t.Fatalf("expected 3 due cards, got %d", len(dueCards))
+	}
+
+	// Days follow the study day, which starts at the next-day hour in local time.
+	today, _ := studyDayBounds(time.Now(), defaultNextDayStartsAt)
+	tomorrow := today.AddDate(0, 0, 1).Add(6 * time.Hour)
+	for i, card := range dueCards {
+		due := tomorrow
+		switch i {
+		case 0:
+			due = time.Now().Add(-48 * time.Hour)
+		case 1:
+			// Due after midnight but before the next day starts: still today.
+			due = today.AddDate(0, 0, 1).Add(-time.Hour)
+		}
+		if _, err := env.store.db.Exec(`
+			UPDATE card_review_states SET state = ?, due = ? WHERE user_id = ? AND card_id = ?
+		`, int(fsrs.Review), due.Unix(), sessionRecord.UserID, card.ID); err != nil {
+			t.Fatalf("failed to schedule card %d: %v", card.ID, err)
+		}
+	}
+
+	server := NewServer(mustLocalAppConfig(), env.handler, fstest.MapFS{
+		"index.html": &fstest.MapFile{Data: []byte("<html></html>")},
+	})
+	authHeaders := map[string]string{"Cookie": env.authCookie}
+
+	createRR := doJSONRequestWithHeaders(t, server, http.MethodPost, "/api/calendar-feed", map[string]string{}, authHeaders)
+	if createRR.Code != http.StatusCreated {
+		t.Fatalf("expected calendar feed create 201, got %d (%s)", createRR.Code, createRR.Body.String())
+	}
+	created := decodeJSON[CalendarFeedResponse](t, createRR)
+	if created.Feed.Token == "" || !strings.HasPrefix(created.URL, "/calendar.ics?token=") {
+		t.Fatalf("expected feed token and url, got %+v", created)
+	}
+	var storedToken string
+	if err := env.store.db.QueryRow(`SELECT token_hash FROM calendar_feeds WHERE id = ?`, created.Feed.ID).Scan(&storedToken); err != nil {
+		t.Fatalf("failed to read stored token: %v", err)
+	}
+	if storedToken != hashCalendarFeedToken(created.Feed.Token) {
+		t.Fatalf("expected only the token's hash to be stored, got %q", storedToken)
+	}
+	settingsRR := doRawRequestWithHeaders(server, http.MethodGet, "/api/calendar-feed", "", authHeaders)
+	if settings := decodeJSON[CalendarFeedResponse](t, settingsRR); settings.Feed.Token != "" || settings.URL != "" {
+		t.Fatalf("expected settings to omit the token, got %+v", settings)
+	}
+
+	if rr := doRawRequest(server, http.MethodGet, "/calendar.ics?token=nope", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown token 404, got %d", rr.Code)
+	}
+	if rr := doRawRequest(server, http.MethodGet, "/calendar.ics", ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected missing token 401, got %d", rr.Code)
+	}
+
+	icsRR := doRawRequest(server, http.MethodGet, created.URL, "")
+	if icsRR.Code != http.StatusOK {
+		t.Fatalf("expected calendar feed 200, got %d (%s)", icsRR.Code, icsRR.Body.String())
+	}
+	if ct := icsRR.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") {
+		t.Fatalf("expected text/calendar content type, got %q", ct)
+	}
+	body := icsRR.Body.String()
+	if !strings.Contains(body, "DTSTART;VALUE=DATE:"+today.Format("20060102")+"\r\nDTEND") ||
+		!strings.Contains(body, "SUMMARY:2 reviews due") {
+		t.Fatalf("expected overdue and late-night cards to land on today, got:\n%s", body)
+	}
+	if !strings.Contains(body, "DTSTART;VALUE=DATE:"+today.AddDate(0, 0, 1).Format("20060102")) || !strings.Contains(body, "SUMMARY:1 review due") {
+		t.Fatalf("expected 1 review tomorrow, got:\n%s", body)
+	}
+
+	rotateRR := doJSONRequestWithHeaders(t, server, http.MethodPost, "/api/calendar-feed", map[string]string{}, authHeaders)
+	rotated := decodeJSON[CalendarFeedResponse](t, rotateRR)
+	if rotated.Feed.Token == created.Feed.Token {
+		t.Fatalf("expected re-creating the feed to rotate its token")
+	}
+	if rr := doRawRequest(server, http.MethodGet, created.URL, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected old token to stop working, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+const (
+	maxAnswerBatchSize = 1000
+	// offlineAnswerClockSkew is how far in the future a device clock may put a review.
+	offlineAnswerClockSkew = 5 * time.Minute
+
+	answerBatchApplied   = "applied"
+	answerBatchDuplicate = "duplicate"
+	answerBatchStale     = "stale"
+	answerBatchRejected  = "rejected"
+)
+
+// OfflineAnswer is one review recorded on a device without connectivity.
+type OfflineAnswer struct {
+	CardID      int64     `json:"cardId"`
+	Rating      int       `json:"rating"` // 1=Again, 2=Hard, 3=Good, 4=Easy
+	ReviewedAt  time.Time `json:"reviewedAt"`
+	TimeTakenMs int       `json:"timeTakenMs"`
+}
+
+// Validate checks one answer on its own. An invalid answer is rejected in its result
+// rather than failing the batch.
+func (a *OfflineAnswer) Validate(v *requestValidator) {
+	v.rating("rating", a.Rating)
+	v.check(a.TimeTakenMs >= 0, "timeTakenMs", "must not be negative")
+	v.check(!a.ReviewedAt.IsZero() && !a.ReviewedAt.After(time.Now().Add(offlineAnswerClockSkew)), "reviewedAt", "must be a time in the past")
+}
+
+type AnswerBatchRequest struct {
+	Answers []OfflineAnswer `json:"answers"`
+}
+
+func (req *AnswerBatchRequest) Validate(v *requestValidator) {
+	v.check(len(req.Answers) > 0, "answers", "is required")
+}
+
+// AnswerBatchResult reports what happened to the answer at Index in the request.
+// Duplicates were already uploaded; stale answers predate the card's last review on the
+// server and are left out rather than scheduled out of order.
+type AnswerBatchResult struct {
+	Index  int    `json:"index"`
+	CardID int64  `json:"cardId"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type AnswerBatchResponse struct {
+	Applied int                 `json:"applied"`
+	Skipped int                 `json:"skipped"`
+	Results []AnswerBatchResult `json:"results"`
+	Cards   []Card              `json:"cards"` // every card the batch rescheduled, in its final state
+}
+
+// AnswerCardsBatch handles POST /api/cards/answers/batch. Answers are replayed through
+// FSRS in reviewedAt order, each from the state the previous one left, exactly as if
+// they had been answered online at those times. Uploading the same batch again is safe.
+func (h *APIHandler) AnswerCardsBatch(w http.ResponseWriter, r *http.Request) {
+	col, _, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	var req AnswerBatchRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	if len(req.Answers) > maxAnswerBatchSize {
+		respondAPIError(w, http.StatusRequestEntityTooLarge, "answer_batch_too_large",
+			fmt.Sprintf("Upload at most %d answers per request", maxAnswerBatchSize))
+		return
+	}
+
+	userID := h.userIDFromRequest(r)
+	resp := AnswerBatchResponse{Results: make([]AnswerBatchResult, len(req.Answers)), Cards: []Card{}}
+	order := make([]int, 0, len(req.Answers))
+	for i, answer := range req.Answers {
+		resp.Results[i] = AnswerBatchResult{Index: i, CardID: answer.CardID}
+		var v requestValidator
+		answer.Validate(&v)
+		switch {
+		case len(v.errs) > 0:
+			resp.Results[i].Status, resp.Results[i].Error = answerBatchRejected, v.errs[0].Field+" "+v.errs[0].Message
+		case col.Cards[answer.CardID] == nil:
+			resp.Results[i].Status, resp.Results[i].Error = answerBatchRejected, "Card not found"
+		default:
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return req.Answers[order[a]].ReviewedAt.Before(req.Answers[order[b]].ReviewedAt)
+	})
+
+	cards := map[int64]*Card{}
+	var rescheduled []int64
+	for _, i := range order {
+		answer := req.Answers[i]
+		result := &resp.Results[i]
+		card, ok := cards[answer.CardID]
+		if !ok {
+			if card, err = h.store.GetCardForUser(r.Context(), userID, answer.CardID); err != nil {
+				respondAPIError(w, http.StatusInternalServerError, "card_load_failed", err.Error())
+				return
+			}
+			cards[answer.CardID] = card
+		}
+
+		duplicate, err := h.store.HasRevlogEntry(r.Context(), userID, answer.CardID, answer.ReviewedAt)
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "revlog_load_failed", err.Error())
+			return
+		}
+		if duplicate {
+			result.Status = answerBatchDuplicate
+			continue
+		}
+		if !card.SRS.LastReview.IsZero() && answer.ReviewedAt.Before(card.SRS.LastReview) {
+			result.Status = answerBatchStale
+			continue
+		}
+
+		// State and log are written per answer, so a batch cut short leaves nothing that
+		// a retry would treat as a duplicate without its schedule.
+		filtered, err := h.store.filteredDeckForCard(r.Context(), card)
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "card_load_failed", err.Error())
+			return
+		}
+		info, step, ease, err := h.scheduleAnswer(r.Context(), col, card, fsrs.Rating(answer.Rating), answer.ReviewedAt)
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "card_update_failed", err.Error())
+			return
+		}
+		kind := reviewKindForState(info.ReviewLog.State)
+		if filtered != nil && !filtered.Reschedule {
+			kind = reviewKindFiltered
+		} else {
+			lapsesBefore := card.SRS.Lapses
+			card.SRS = info.Card
+			card.LearningStep = step
+			card.EaseFactor = ease
+			if err := h.markLeech(r.Context(), col, card, lapsesBefore, answer.ReviewedAt, true); err != nil {
+				respondAPIError(w, http.StatusInternalServerError, "card_update_failed", err.Error())
+				return
+			}
+			if err := h.store.UpdateCardReviewState(r.Context(), userID, card); err != nil {
+				respondAPIError(w, http.StatusInternalServerError, "card_update_failed", err.Error())
+				return
+			}
+		}
+		if err := h.store.AddRevlogDetailForUser(r.Context(), userID, &info.ReviewLog, card.SRS, kind, card.ID, answer.TimeTakenMs); err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "revlog_write_failed", err.Error())
+			return
+		}
+		if err := h.finishFilteredAnswer(r.Context(), col, card, filtered); err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "card_update_failed", err.Error())
+			return
+		}
+		if !slices.Contains(rescheduled, card.ID) {
+			rescheduled = append(rescheduled, card.ID)
+		}
+		result.Status = answerBatchApplied
+	}
+
+	for _, id := range rescheduled {
+		resp.Cards = append(resp.Cards, *cards[id])
+	}
+	for _, result := range resp.Results {
+		if result.Status == answerBatchApplied {
+			resp.Applied++
+		} else {
+			resp.Skipped++
+		}
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAPI_AnswerCardsBatchReplaysInOrder(t *testing.T) {
+	env := setupAPITestEnv(t)
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Queue", "Back": "FIFO"},
+	}, nil)
+	cardID := created.Cards[0].ID
+
+	day1 := time.Now().Add(-72 * time.Hour).Truncate(time.Second)
+	day2 := day1.Add(24 * time.Hour)
+	req := AnswerBatchRequest{Answers: []OfflineAnswer{
+		{CardID: cardID, Rating: 3, ReviewedAt: day2, TimeTakenMs: 2000},
+		{CardID: cardID, Rating: 1, ReviewedAt: day1, TimeTakenMs: 4000},
+		{CardID: cardID, Rating: 5, ReviewedAt: day2},
+		{CardID: 987654, Rating: 3, ReviewedAt: day2},
+		{CardID: cardID, Rating: 3, ReviewedAt: time.Now().Add(time.Hour)},
+	}}
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/answers/batch", req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected batch 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	resp := decodeJSON[AnswerBatchResponse](t, rr)
+	if resp.Applied != 2 || resp.Skipped != 3 {
+		t.Fatalf("expected 2 applied and 3 skipped, got %+v", resp)
+	}
+	for i, want := range []string{answerBatchApplied, answerBatchApplied, answerBatchRejected, answerBatchRejected, answerBatchRejected} {
+		if resp.Results[i].Status != want {
+			t.Fatalf("expected answer %d to be %s, got %+v", i, want, resp.Results[i])
+		}
+	}
+	if len(resp.Cards) != 1 || resp.Cards[0].SRS.Reps != 2 || resp.Cards[0].SRS.Lapses != 0 || !resp.Cards[0].SRS.LastReview.Equal(day2) {
+		t.Fatalf("expected the card rescheduled from both answers ending on day 2, got %+v", resp.Cards)
+	}
+
+	entries, err := env.store.ListRevlogEntriesForCard(context.Background(), "", cardID, 10)
+	if err != nil {
+		t.Fatalf("list revlog failed: %v", err)
+	}
+	if len(entries) != 2 || !entries[0].ReviewedAt.Equal(day2) || entries[1].Rating != 1 || entries[1].TimeTakenMs != 4000 {
+		t.Fatalf("expected reviews logged at their offline times, got %+v", entries)
+	}
+
+	// Uploading again after a lost response changes nothing.
+	rr = doJSONRequest(t, env.router, http.MethodPost, "/api/cards/answers/batch", AnswerBatchRequest{Answers: req.Answers[:2]})
+	again := decodeJSON[AnswerBatchResponse](t, rr)
+	if again.Applied != 0 || again.Results[0].Status != answerBatchDuplicate || again.Results[1].Status != answerBatchDuplicate {
+		t.Fatalf("expected duplicates on re-upload, got %+v", again)
+	}
+
+	// An answer older than the card's last review cannot be replayed.
+	rr = doJSONRequest(t, env.router, http.MethodPost, "/api/cards/answers/batch", AnswerBatchRequest{Answers: []OfflineAnswer{
+		{CardID: cardID, Rating: 3, ReviewedAt: day1.Add(time.Hour)},
+	}})
+	if stale := decodeJSON[AnswerBatchResponse](t, rr); stale.Results[0].Status != answerBatchStale {
+		t.Fatalf("expected a stale answer, got %+v", stale)
+	}
+
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/answers/batch", AnswerBatchRequest{}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an empty batch, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// BulkCardSelectionRequest picks cards by ID or by a search Query; exactly one is given.
+type BulkCardSelectionRequest struct {
+	CardIDs []int64 `json:"cardIds,omitempty"`
+	Query   string  `json:"query,omitempty"`
+}
+
+func (req *BulkCardSelectionRequest) Validate(v *requestValidator) {
+	v.check((len(req.CardIDs) == 0) != (strings.TrimSpace(req.Query) == ""), "cardIds", "or query is required, but not both")
+}
+
+// BulkFlagRequest sets Flag on the selected cards; 0 clears the flag.
+type BulkFlagRequest struct {
+	BulkCardSelectionRequest
+	Flag *int `json:"flag"`
+}
+
+func (req *BulkFlagRequest) Validate(v *requestValidator) {
+	req.BulkCardSelectionRequest.Validate(v)
+	if req.Flag == nil {
+		v.fail("flag", "is required")
+		return
+	}
+	v.flag("flag", *req.Flag)
+}
+
+type BulkFlagResponse struct {
+	Flag         int     `json:"flag"`
+	MatchedCards int     `json:"matchedCards"`
+	ChangedCards int     `json:"changedCards"`
+	CardIDs      []int64 `json:"cardIds"`
+}
+
+type BulkSuspendResponse struct {
+	Suspended    bool    `json:"suspended"`
+	MatchedCards int     `json:"matchedCards"`
+	ChangedCards int     `json:"changedCards"`
+	CardIDs      []int64 `json:"cardIds"`
+}
+
+// updateCardsStateColumn sets a per-user card column on many cards in one transaction
+// and returns how many cards changed. A blank userID updates the shared card rows.
+// column must be one of the fixed names passed by the wrappers below.
+func (s *SQLStore) updateCardsStateColumn(ctx context.Context, userID string, cardIDs []int64, column string, value interface{}) (int, error) {
+	userID = strings.TrimSpace(userID)
+	if userID != "" {
+		if err := s.EnsureReviewStatesForUser(ctx, userID); err != nil {
+			return 0, err
+		}
+	}
+
+	var changed int
+	now := time.Now().Unix()
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		changed = 0
+		for start := 0; start < len(cardIDs); start += maxNoteDeleteBatchSize {
+			chunk := cardIDs[start:min(start+maxNoteDeleteBatchSize, len(cardIDs))]
+			placeholders, args := int64Placeholders(chunk)
+			var (
+				query     string
+				queryArgs []interface{}
+			)
+			if userID == "" {
+				query = fmt.Sprintf(`UPDATE cards SET %s = ? WHERE %s != ? AND id IN (%s)`, column, column, placeholders)
+				queryArgs = append([]interface{}{value, value}, args...)
+			} else {
+				query = fmt.Sprintf(`
+					UPDATE card_review_states SET %s = ?, updated_at = ?
+					WHERE user_id = ? AND %s != ? AND card_id IN (%s)
+				`, column, column, placeholders)
+				queryArgs = append([]interface{}{value, now, userID, value}, args...)
+			}
+			result, err := tx.ExecContext(ctx, query, queryArgs...)
+			if err != nil {
+				return err
+			}
+			affected, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			changed += int(affected)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
+
+// SetCardsSuspended suspends or unsuspends cards for the user.
+func (s *SQLStore) SetCardsSuspended(ctx context.Context, userID string, cardIDs []int64, suspended bool) (int, error) {
+	return s.updateCardsStateColumn(ctx, userID, cardIDs, "suspended", suspended)
+}
+
+// SetCardsFlag sets the flag (0 clears it) on cards for the user.
+func (s *SQLStore) SetCardsFlag(ctx context.Context, userID string, cardIDs []int64, flag int) (int, error) {
+	return s.updateCardsStateColumn(ctx, userID, cardIDs, "flag", flag)
+}
+
+// SuspendCards serves POST /api/cards/suspend.
+func (h *APIHandler) SuspendCards(w http.ResponseWriter, r *http.Request) {
+	h.setCardsSuspended(w, r, true)
+}
+
+// UnsuspendCards serves POST /api/cards/unsuspend.
+func (h *APIHandler) UnsuspendCards(w http.ResponseWriter, r *http.Request) {
+	h.setCardsSuspended(w, r, false)
+}
+
+func (h *APIHandler) setCardsSuspended(w http.ResponseWriter, r *http.Request, suspended bool) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+
+	var req BulkCardSelectionRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	cardIDs, _, ok := h.resolveCardSelection(w, r, collectionID, req.CardIDs, req.Query)
+	if !ok {
+		return
+	}
+
+	userID := h.userIDFromRequest(r)
+	changed, err := h.store.SetCardsSuspended(r.Context(), userID, cardIDs, suspended)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "card_suspend_failed", err.Error())
+		return
+	}
+	if strings.TrimSpace(userID) == "" {
+		for _, id := range cardIDs {
+			if card, ok := col.Cards[id]; ok {
+				card.Suspended = suspended
+			}
+		}
+	}
+
+	respondJSON(w, http.StatusOK, BulkSuspendResponse{
+		Suspended:    suspended,
+		MatchedCards: len(cardIDs),
+		ChangedCards: changed,
+		CardIDs:      cardIDs,
+	})
+}
+
+// FlagCards serves POST /api/cards/flag.
+func (h *APIHandler) FlagCards(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+
+	var req BulkFlagRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	cardIDs, _, ok := h.resolveCardSelection(w, r, collectionID, req.CardIDs, req.Query)
+	if !ok {
+		return
+	}
+
+	userID := h.userIDFromRequest(r)
+	changed, err := h.store.SetCardsFlag(r.Context(), userID, cardIDs, *req.Flag)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "card_flag_failed", err.Error())
+		return
+	}
+	if strings.TrimSpace(userID) == "" {
+		for _, id := range cardIDs {
+			if card, ok := col.Cards[id]; ok {
+				card.Flag = *req.Flag
+			}
+		}
+	}
+
+	respondJSON(w, http.StatusOK, BulkFlagResponse{
+		Flag:         *req.Flag,
+		MatchedCards: len(cardIDs),
+		ChangedCards: changed,
+		CardIDs:      cardIDs,
+	})
+}
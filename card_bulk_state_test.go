@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAPI_BulkSuspendAndUnsuspend(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	var cardIDs []int64
+	for _, front := range []string{"suspend alpha", "suspend beta", "keep gamma"} {
+		note := createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": front, "Back": "answer"},
+		}, nil)
+		cardIDs = append(cardIDs, note.Cards[0].ID)
+	}
+
+	suspendedIDs := func() []int64 {
+		t.Helper()
+		rr := doRawRequest(env.router, http.MethodGet, "/api/search?q=is:suspended", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected search 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+		ids := []int64{}
+		for _, card := range decodeJSON[SearchResponse](t, rr).Cards {
+			ids = append(ids, card.ID)
+		}
+		return ids
+	}
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/suspend", BulkCardSelectionRequest{Query: "suspend"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected suspend by query 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if resp := decodeJSON[BulkSuspendResponse](t, rr); resp.MatchedCards != 2 || resp.ChangedCards != 2 || !resp.Suspended {
+		t.Fatalf("expected two cards suspended, got %+v", resp)
+	}
+	if got := suspendedIDs(); len(got) != 2 || got[0] != cardIDs[0] || got[1] != cardIDs[1] {
+		t.Fatalf("expected the two matching cards to be suspended, got %v", got)
+	}
+
+	rr = doJSONRequest(t, env.router, http.MethodPost, "/api/cards/suspend", BulkCardSelectionRequest{CardIDs: []int64{cardIDs[1], cardIDs[2]}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected suspend by id 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if resp := decodeJSON[BulkSuspendResponse](t, rr); resp.MatchedCards != 2 || resp.ChangedCards != 1 {
+		t.Fatalf("expected only the unsuspended card to change, got %+v", resp)
+	}
+
+	rr = doJSONRequest(t, env.router, http.MethodPost, "/api/cards/unsuspend", BulkCardSelectionRequest{Query: "is:suspended"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected unsuspend 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if resp := decodeJSON[BulkSuspendResponse](t, rr); resp.ChangedCards != 3 || resp.Suspended {
+		t.Fatalf("expected all three cards unsuspended, got %+v", resp)
+	}
+	if got := suspendedIDs(); len(got) != 0 {
+		t.Fatalf("expected no suspended cards, got %v", got)
+	}
+
+	for _, body := range []BulkCardSelectionRequest{{}, {CardIDs: []int64{cardIDs[0]}, Query: "alpha"}} {
+		if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/suspend", body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected %+v to be rejected with 400, got %d", body, rr.Code)
+		}
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/suspend", BulkCardSelectionRequest{CardIDs: []int64{999999}}); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown card 404, got %d", rr.Code)
+	}
+}
+
+func TestAPI_BulkFlagCards(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	var cardIDs []int64
+	for _, front := range []string{"flag alpha", "flag beta", "plain gamma"} {
+		note := createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": front, "Back": "answer"},
+		}, nil)
+		cardIDs = append(cardIDs, note.Cards[0].ID)
+	}
+	flag := func(body BulkFlagRequest) BulkFlagResponse {
+		t.Helper()
+		rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/flag", body)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected flag 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+		return decodeJSON[BulkFlagResponse](t, rr)
+	}
+	intPtr := func(v int) *int { return &v }
+
+	if resp := flag(BulkFlagRequest{BulkCardSelectionRequest: BulkCardSelectionRequest{Query: "flag"}, Flag: intPtr(3)}); resp.MatchedCards != 2 || resp.ChangedCards != 2 {
+		t.Fatalf("expected two cards flagged, got %+v", resp)
+	}
+	if resp := flag(BulkFlagRequest{BulkCardSelectionRequest: BulkCardSelectionRequest{CardIDs: cardIDs}, Flag: intPtr(3)}); resp.MatchedCards != 3 || resp.ChangedCards != 1 {
+		t.Fatalf("expected only the unflagged card to change, got %+v", resp)
+	}
+
+	rr := doRawRequest(env.router, http.MethodGet, "/api/search?q=flag:3", "")
+	if got := decodeJSON[SearchResponse](t, rr); got.Total != 3 {
+		t.Fatalf("expected three cards with flag 3, got %+v", got)
+	}
+
+	if resp := flag(BulkFlagRequest{BulkCardSelectionRequest: BulkCardSelectionRequest{Query: "flag:3"}, Flag: intPtr(0)}); resp.ChangedCards != 3 {
+		t.Fatalf("expected flags cleared on three cards, got %+v", resp)
+	}
+
+	for _, body := range []BulkFlagRequest{
+		{BulkCardSelectionRequest: BulkCardSelectionRequest{CardIDs: cardIDs}},
+		{BulkCardSelectionRequest: BulkCardSelectionRequest{CardIDs: cardIDs}, Flag: intPtr(8)},
+	} {
+		if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/flag", body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected invalid flag to be rejected with 400, got %d", rr.Code)
+		}
+	}
+}
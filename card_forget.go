@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// BulkForgetRequest resets the selected cards to New, like Anki's Forget. Review and
+// lapse counts are kept unless ResetCounts is set, and review history is kept unless
+// DeleteHistory is set.
+type BulkForgetRequest struct {
+	BulkCardSelectionRequest
+	ResetCounts   bool `json:"resetCounts,omitempty"`
+	DeleteHistory bool `json:"deleteHistory,omitempty"`
+}
+
+type BulkForgetResponse struct {
+	MatchedCards   int     `json:"matchedCards"`
+	ResetCards     int     `json:"resetCards"`
+	DeletedReviews int     `json:"deletedReviews"`
+	CardIDs        []int64 `json:"cardIds"`
+}
+
+// cardStateTable is where a user's scheduling state of cards lives: their review states,
+// or the shared card rows for a blank userID.
+type cardStateTable struct {
+	table, idColumn string
+	userClause      string // prefix of the WHERE clause selecting the user's rows
+	userArgs        []interface{}
+}
+
+func newCardStateTable(userID string) cardStateTable {
+	if userID = strings.TrimSpace(userID); userID != "" {
+		return cardStateTable{table: "card_review_states", idColumn: "card_id", userClause: "user_id = ? AND ", userArgs: []interface{}{userID}}
+	}
+	return cardStateTable{table: "cards", idColumn: "id"}
+}
+
+// load returns the FSRS state of each of cardIDs that has a row in the table.
+func (t cardStateTable) load(ctx context.Context, tx *sql.Tx, cardIDs []int64) (map[int64]fsrs.Card, error) {
+	states := make(map[int64]fsrs.Card, len(cardIDs))
+	for start := 0; start < len(cardIDs); start += maxNoteDeleteBatchSize {
+		placeholders, args := int64Placeholders(cardIDs[start:min(start+maxNoteDeleteBatchSize, len(cardIDs))])
+		rows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT %s, fsrs_data FROM %s WHERE %s%s IN (%s)`, t.idColumn, t.table, t.userClause, t.idColumn, placeholders),
+			append(append([]interface{}{}, t.userArgs...), args...)...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var (
+				id       int64
+				fsrsJSON []byte
+				card     fsrs.Card
+			)
+			if err := rows.Scan(&id, &fsrsJSON); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if len(fsrsJSON) > 0 {
+				if err := json.Unmarshal(fsrsJSON, &card); err != nil {
+					rows.Close()
+					return nil, err
+				}
+			}
+			states[id] = card
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return states, nil
+}
+
+// save writes a card's new FSRS state, taking it out of learning steps and burial.
+// extraSet adds further fixed column assignments, e.g. ", ease_factor = 0".
+func (t cardStateTable) save(ctx context.Context, tx *sql.Tx, cardID int64, card fsrs.Card, extraSet string, now time.Time) error {
+	fsrsJSON, err := json.Marshal(card)
+	if err != nil {
+		return err
+	}
+	if t.table == "card_review_states" {
+		extraSet += fmt.Sprintf(", updated_at = %d", now.Unix())
+	}
+	query := fmt.Sprintf(`
+		UPDATE %s SET due = ?, state = ?, fsrs_data = ?, learning_step = 0, buried_at = 0%s
+		WHERE %s%s = ?
+	`, t.table, extraSet, t.userClause, t.idColumn)
+	_, err = tx.ExecContext(ctx, query, append(append([]interface{}{card.Due.Unix(), int(card.State), fsrsJSON}, t.userArgs...), cardID)...)
+	return err
+}
+
+// saveDue moves a card to card.Due, keeping the rest of its scheduling state. The due
+// date is kept both in its column and in the FSRS state, which is what the card is
+// loaded from, so the two must change together.
+func (t cardStateTable) saveDue(ctx context.Context, tx *sql.Tx, cardID int64, card fsrs.Card, now time.Time) error {
+	fsrsJSON, err := json.Marshal(card)
+	if err != nil {
+		return err
+	}
+	extraSet := ""
+	if t.table == "card_review_states" {
+		extraSet = fmt.Sprintf(", updated_at = %d", now.Unix())
+	}
+	query := fmt.Sprintf(`UPDATE %s SET due = ?, fsrs_data = ?%s WHERE %s%s = ?`, t.table, extraSet, t.userClause, t.idColumn)
+	_, err = tx.ExecContext(ctx, query, append(append([]interface{}{card.Due.Unix(), fsrsJSON}, t.userArgs...), cardID)...)
+	return err
+}
+
+// ResetCardsToNew puts cards back into the New state for the user, due now, clearing their
+// learning step, SM-2 ease and burial. A blank userID resets the shared card rows and
+// deletes every user's history of them. It returns how many cards were reset and how
+// many revlog entries were deleted.
+func (s *SQLStore) ResetCardsToNew(ctx context.Context, userID string, cardIDs []int64, resetCounts, deleteHistory bool, now time.Time) (int, int, error) {
+	if strings.TrimSpace(userID) != "" {
+		if err := s.EnsureReviewStatesForUser(ctx, userID); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	table := newCardStateTable(userID)
+	var reset, deleted int
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		states, err := table.load(ctx, tx, cardIDs)
+		if err != nil {
+			return err
+		}
+		for id, previous := range states {
+			card := newDueNow(time.Unix(now.Unix(), 0))
+			if !resetCounts {
+				card.Reps, card.Lapses = previous.Reps, previous.Lapses
+			}
+			if err := table.save(ctx, tx, id, card, ", ease_factor = 0", now); err != nil {
+				return err
+			}
+		}
+		reset, deleted = len(states), 0
+
+		if deleteHistory {
+			for start := 0; start < len(cardIDs); start += maxNoteDeleteBatchSize {
+				placeholders, args := int64Placeholders(cardIDs[start:min(start+maxNoteDeleteBatchSize, len(cardIDs))])
+				result, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM revlog WHERE %scard_id IN (%s)`, table.userClause, placeholders),
+					append(append([]interface{}{}, table.userArgs...), args...)...)
+				if err != nil {
+					return err
+				}
+				affected, err := result.RowsAffected()
+				if err != nil {
+					return err
+				}
+				deleted += int(affected)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return reset, deleted, nil
+}
+
+// ForgetCards serves POST /api/cards/forget.
+func (h *APIHandler) ForgetCards(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+
+	var req BulkForgetRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	cardIDs, _, ok := h.resolveCardSelection(w, r, collectionID, req.CardIDs, req.Query)
+	if !ok {
+		return
+	}
+
+	userID := h.userIDFromRequest(r)
+	now := time.Now()
+	reset, deleted, err := h.store.ResetCardsToNew(r.Context(), userID, cardIDs, req.ResetCounts, req.DeleteHistory, now)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "card_forget_failed", err.Error())
+		return
+	}
+	if strings.TrimSpace(userID) == "" {
+		for _, id := range cardIDs {
+			card, ok := col.Cards[id]
+			if !ok {
+				continue
+			}
+			if refreshed, err := h.store.GetCard(r.Context(), id); err == nil {
+				card.SRS = refreshed.SRS
+				card.LearningStep, card.EaseFactor, card.Buried = 0, 0, false
+			}
+		}
+	}
+
+	respondJSON(w, http.StatusOK, BulkForgetResponse{
+		MatchedCards:   len(cardIDs),
+		ResetCards:     reset,
+		DeletedReviews: deleted,
+		CardIDs:        cardIDs,
+	})
+}
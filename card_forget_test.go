@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestAPI_ForgetCardsResetsToNew(t *testing.T) {
+	env := setupAPITestEnv(t)
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Ribosome", "Back": "Protein synthesis"},
+	}, nil)
+	cardID := created.Cards[0].ID
+
+	for _, rating := range []int{4, 1} {
+		if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", cardID), AnswerCardRequest{Rating: rating}); rr.Code != http.StatusOK {
+			t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+	}
+
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/forget", BulkForgetRequest{}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected an empty selection to be rejected, got %d", rr.Code)
+	}
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/forget", BulkForgetRequest{BulkCardSelectionRequest: BulkCardSelectionRequest{CardIDs: []int64{cardID}}})
+	resp := decodeJSON[BulkForgetResponse](t, rr)
+	if rr.Code != http.StatusOK || resp.ResetCards != 1 || resp.DeletedReviews != 0 {
+		t.Fatalf("expected one card reset, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	card := decodeJSON[Card](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/cards/%d", cardID), ""))
+	if card.SRS.State != fsrs.New || card.SRS.Stability != 0 || card.LearningStep != 0 {
+		t.Fatalf("expected the card back in New, got %+v", card.SRS)
+	}
+	if card.SRS.Reps != 2 || card.SRS.Lapses != 1 {
+		t.Fatalf("expected counts kept by default, got reps %d lapses %d", card.SRS.Reps, card.SRS.Lapses)
+	}
+	if entries, err := env.store.ListRevlogEntriesForCard(context.Background(), "", cardID, 10); err != nil || len(entries) != 2 {
+		t.Fatalf("expected history kept by default, got %d entries (%v)", len(entries), err)
+	}
+	if due := decodeJSON[[]*Card](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=10", "")); len(due) != 1 || due[0].ID != cardID {
+		t.Fatalf("expected the forgotten card due as new, got %+v", due)
+	}
+
+	rr = doJSONRequest(t, env.router, http.MethodPost, "/api/cards/forget", BulkForgetRequest{
+		BulkCardSelectionRequest: BulkCardSelectionRequest{Query: "Ribosome"},
+		ResetCounts:              true,
+		DeleteHistory:            true,
+	})
+	resp = decodeJSON[BulkForgetResponse](t, rr)
+	if rr.Code != http.StatusOK || resp.MatchedCards != 1 || resp.DeletedReviews != 2 {
+		t.Fatalf("expected history deleted, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	card = decodeJSON[Card](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/cards/%d", cardID), ""))
+	if card.SRS.Reps != 0 || card.SRS.Lapses != 0 {
+		t.Fatalf("expected counts reset, got reps %d lapses %d", card.SRS.Reps, card.SRS.Lapses)
+	}
+	if entries, err := env.store.ListRevlogEntriesForCard(context.Background(), "", cardID, 10); err != nil || len(entries) != 0 {
+		t.Fatalf("expected no history left, got %d entries (%v)", len(entries), err)
+	}
+}
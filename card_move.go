@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// MoveCardsRequest moves cards to TargetDeckID. Cards are given by ID, or by a search
+// Query.
+type MoveCardsRequest struct {
+	CardIDs      []int64 `json:"cardIds,omitempty"`
+	Query        string  `json:"query,omitempty"`
+	TargetDeckID int64   `json:"targetDeckId"`
+}
+
+func (req *MoveCardsRequest) Validate(v *requestValidator) {
+	selection := BulkCardSelectionRequest{CardIDs: req.CardIDs, Query: req.Query}
+	selection.Validate(v)
+	v.check(req.TargetDeckID > 0, "targetDeckId", "is required")
+}
+
+type MoveCardsResponse struct {
+	TargetDeckID int64   `json:"targetDeckId"`
+	MovedCards   int     `json:"movedCards"`
+	CardIDs      []int64 `json:"cardIds"`
+}
+
+// CardDecksInCollection maps each of cardIDs that belongs to the collection to its
+// current deck. IDs from other collections, or that do not exist, are left out.
+func (s *SQLStore) CardDecksInCollection(ctx context.Context, collectionID string, cardIDs []int64) (map[int64]int64, error) {
+	decks := make(map[int64]int64, len(cardIDs))
+	for start := 0; start < len(cardIDs); start += maxNoteDeleteBatchSize {
+		chunk := cardIDs[start:min(start+maxNoteDeleteBatchSize, len(cardIDs))]
+		placeholders, args := int64Placeholders(chunk)
+		rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+			SELECT c.id, c.deck_id
+			FROM cards c
+			JOIN decks d ON d.id = c.deck_id
+			WHERE d.collection_id = ? AND c.id IN (%s)
+		`, placeholders), append([]interface{}{collectionID}, args...)...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var cardID, deckID int64
+			if err := rows.Scan(&cardID, &deckID); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			decks[cardID] = deckID
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return decks, nil
+}
+
+// MoveCards sets the deck of every given card to targetDeckID in one transaction. Review
+// state and history stay with the cards; cards moved out of a filtered deck forget their
+// home deck.
+func (s *SQLStore) MoveCards(ctx context.Context, cardIDs []int64, targetDeckID int64) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		for start := 0; start < len(cardIDs); start += maxNoteDeleteBatchSize {
+			chunk := cardIDs[start:min(start+maxNoteDeleteBatchSize, len(cardIDs))]
+			placeholders, args := int64Placeholders(chunk)
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf(`UPDATE cards SET deck_id = ?, original_deck_id = 0, filtered_position = 0 WHERE id IN (%s)`, placeholders),
+				append([]interface{}{targetDeckID}, args...)...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MoveCards serves POST /api/cards/move.
+func (h *APIHandler) MoveCards(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+
+	var req MoveCardsRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	if targetCollectionID, err := h.store.GetDeckCollectionID(r.Context(), req.TargetDeckID); err != nil || targetCollectionID != collectionID {
+		respondAPIError(w, http.StatusBadRequest, "invalid_target_deck_id", "Target deck not found")
+		return
+	}
+	if filtered, err := h.store.IsFilteredDeck(r.Context(), req.TargetDeckID); err != nil || filtered {
+		respondAPIError(w, http.StatusBadRequest, "invalid_target_deck_id", "Cards cannot be moved into a filtered deck; rebuild it instead")
+		return
+	}
+
+	unique, sourceDecks, ok := h.resolveCardSelection(w, r, collectionID, req.CardIDs, req.Query)
+	if !ok {
+		return
+	}
+
+	if err := h.store.MoveCards(r.Context(), unique, req.TargetDeckID); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "card_move_failed", err.Error())
+		return
+	}
+
+	touched := map[int64]bool{req.TargetDeckID: true}
+	for _, id := range unique {
+		from := sourceDecks[id]
+		touched[from] = true
+		h.removeCardFromDeck(col, from, id)
+		h.ensureCardOnDeck(col, req.TargetDeckID, id)
+		if card, ok := col.Cards[id]; ok {
+			card.DeckID = req.TargetDeckID
+		}
+	}
+	deckIDs := make([]int64, 0, len(touched))
+	for id := range touched {
+		deckIDs = append(deckIDs, id)
+	}
+	h.markStudyGroupInstallsForkedByDeckIDs(r.Context(), deckIDs...)
+
+	respondJSON(w, http.StatusOK, MoveCardsResponse{
+		TargetDeckID: req.TargetDeckID,
+		MovedCards:   len(unique),
+		CardIDs:      unique,
+	})
+}
+
+// resolveCardSelection turns a bulk request's cardIds or search query (exactly one must
+// be given) into distinct card IDs in the collection, along with each card's deck. It
+// writes an error response and returns false when the selection is invalid.
+func (h *APIHandler) resolveCardSelection(w http.ResponseWriter, r *http.Request, collectionID string, cardIDs []int64, query string) ([]int64, map[int64]int64, bool) {
+	query = strings.TrimSpace(query)
+	if (len(cardIDs) == 0) == (query == "") {
+		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Provide either cardIds or query")
+		return nil, nil, false
+	}
+	if query != "" {
+		if err := validateSearch(query); err != nil {
+			respondAPIError(w, http.StatusBadRequest, "invalid_query", err.Error())
+			return nil, nil, false
+		}
+		var err error
+		if cardIDs, err = h.store.SearchCardIDs(r.Context(), collectionID, h.userIDFromRequest(r), query); err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "search_failed", err.Error())
+			return nil, nil, false
+		}
+	}
+
+	decks, err := h.store.CardDecksInCollection(r.Context(), collectionID, cardIDs)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "card_lookup_failed", err.Error())
+		return nil, nil, false
+	}
+	seen := make(map[int64]bool, len(cardIDs))
+	unique := make([]int64, 0, len(cardIDs))
+	for _, id := range cardIDs {
+		if _, ok := decks[id]; !ok {
+			respondAPIError(w, http.StatusNotFound, "card_not_found", fmt.Sprintf("Card %d not found", id))
+			return nil, nil, false
+		}
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique, decks, true
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAPI_MoveCardsByIDAndQuery(t *testing.T) {
+	env := setupAPITestEnv(t)
+	sessionID := strings.TrimPrefix(env.authCookie, sessionCookieName+"=")
+	sessionRecord, err := env.store.GetSessionRecord(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("failed to load current session: %v", err)
+	}
+	activateWorkspaceSubscriptionForTest(t, env, sessionRecord.WorkspaceID, PlanPro)
+
+	createDeck := func(name string) int64 {
+		t.Helper()
+		rr := doJSONRequest(t, env.router, http.MethodPost, "/api/decks", CreateDeckRequest{Name: name})
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected deck create 201, got %d (%s)", rr.Code, rr.Body.String())
+		}
+		return decodeJSON[DeckResponse](t, rr).ID
+	}
+	source := createDeck("Move Source")
+	target := createDeck("Move Target")
+
+	first := createNoteForTest(t, env, CreateNoteRequest{TypeID: "Basic", DeckID: source, FieldVals: map[string]string{"Front": "alpha", "Back": "one"}}, nil)
+	second := createNoteForTest(t, env, CreateNoteRequest{TypeID: "Basic", DeckID: source, FieldVals: map[string]string{"Front": "beta", "Back": "two"}}, nil)
+
+	deckOf := func(cardID int64) int64 {
+		t.Helper()
+		var deckID int64
+		if err := env.store.db.QueryRow(`SELECT deck_id FROM cards WHERE id = ?`, cardID).Scan(&deckID); err != nil {
+			t.Fatalf("failed to load card %d: %v", cardID, err)
+		}
+		return deckID
+	}
+
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/move", MoveCardsRequest{CardIDs: []int64{first.Cards[0].ID}, TargetDeckID: 999999}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected unknown target deck 400, got %d", rr.Code)
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/move", MoveCardsRequest{CardIDs: []int64{first.Cards[0].ID, 999999}, TargetDeckID: target}); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown card 404, got %d", rr.Code)
+	}
+	if deckOf(first.Cards[0].ID) != source {
+		t.Fatalf("expected rejected move to leave the card in place")
+	}
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/move", MoveCardsRequest{CardIDs: []int64{first.Cards[0].ID}, TargetDeckID: target})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected move by id 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if resp := decodeJSON[MoveCardsResponse](t, rr); resp.MovedCards != 1 {
+		t.Fatalf("expected one moved card, got %+v", resp)
+	}
+	if deckOf(first.Cards[0].ID) != target || deckOf(second.Cards[0].ID) != source {
+		t.Fatalf("expected only the requested card to move")
+	}
+
+	rr = doJSONRequest(t, env.router, http.MethodPost, "/api/cards/move", MoveCardsRequest{Query: "beta", TargetDeckID: target})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected move by query 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if deckOf(second.Cards[0].ID) != target {
+		t.Fatalf("expected the matching note's card to move")
+	}
+
+	rr = doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/decks/%d", target), "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected target deck 200, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `"cardCount":2`) {
+		t.Fatalf("expected target deck to list both cards, got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+const maxSetDueDays = 36500
+
+// SetDueRequest moves the selected cards to a new due date, like Anki's Set Due Date.
+// Days is a number of days from today ("0" is today) or a range such as "3-7", in
+// which case each card gets a random day in it to spread a backlog; Date is a
+// YYYY-MM-DD day instead. With UpdateStability the card's interval and FSRS stability
+// are changed to match its new due date, so the next review schedules from it.
+type SetDueRequest struct {
+	BulkCardSelectionRequest
+	Days            string `json:"days,omitempty"`
+	Date            string `json:"date,omitempty"`
+	UpdateStability bool   `json:"updateStability,omitempty"`
+}
+
+// Validate checks the form of days and date; whether the date is still ahead depends
+// on the collection's study day and is left to the handler.
+func (req *SetDueRequest) Validate(v *requestValidator) {
+	req.BulkCardSelectionRequest.Validate(v)
+	days, date := strings.TrimSpace(req.Days), strings.TrimSpace(req.Date)
+	switch {
+	case (days == "") == (date == ""):
+		v.fail("days", "or date is required, but not both")
+	case days != "":
+		if _, _, err := parseSetDueDays(days); err != nil {
+			v.fail("days", strings.TrimPrefix(err.Error(), "days "))
+		}
+	default:
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			v.fail("date", "must be YYYY-MM-DD")
+		}
+	}
+}
+
+type SetDueResponse struct {
+	MatchedCards     int     `json:"matchedCards"`
+	RescheduledCards int     `json:"rescheduledCards"`
+	SkippedNewCards  int     `json:"skippedNewCards"`
+	CardIDs          []int64 `json:"cardIds"`
+}
+
+// parseSetDueDays reads "5", "3-7" or "3-7 days" into an inclusive range of days.
+func parseSetDueDays(raw string) (int, int, error) {
+	raw = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(raw), "days"))
+	low, high, isRange := strings.Cut(raw, "-")
+	from, err := strconv.Atoi(strings.TrimSpace(low))
+	if err != nil {
+		return 0, 0, fmt.Errorf("days must be a number of days or a range such as 3-7")
+	}
+	to := from
+	if isRange {
+		if to, err = strconv.Atoi(strings.TrimSpace(high)); err != nil {
+			return 0, 0, fmt.Errorf("days must be a number of days or a range such as 3-7")
+		}
+	}
+	if from < 0 || to < from || to > maxSetDueDays {
+		return 0, 0, fmt.Errorf("days must run from 0 up to %d", maxSetDueDays)
+	}
+	return from, to, nil
+}
+
+// stabilityForInterval is the FSRS stability at which a card reaches the desired
+// retention after days.
+func stabilityForInterval(params fsrs.Parameters, days int) float64 {
+	return float64(days) * params.Factor / (math.Pow(params.RequestRetention, 1/params.Decay) - 1)
+}
+
+// SetCardDueDates moves each card in dues to its new due time for the user and logs a
+// manual revlog entry. Learning and relearning cards become review cards; new cards
+// have no memory state to review from and are skipped. It returns how many cards were
+// rescheduled and how many were skipped as new.
+func (s *SQLStore) SetCardDueDates(ctx context.Context, userID string, dues map[int64]time.Time, updateStability bool, params fsrs.Parameters, now time.Time) (int, int, error) {
+	if strings.TrimSpace(userID) != "" {
+		if err := s.EnsureReviewStatesForUser(ctx, userID); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	cardIDs := make([]int64, 0, len(dues))
+	for id := range dues {
+		cardIDs = append(cardIDs, id)
+	}
+	table := newCardStateTable(userID)
+	var rescheduled, skipped int
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		states, err := table.load(ctx, tx, cardIDs)
+		if err != nil {
+			return err
+		}
+
+		rescheduled, skipped = 0, 0
+		for id, card := range states {
+			if card.State == fsrs.New {
+				skipped++
+				continue
+			}
+			card.Due = dues[id]
+			card.State = fsrs.Review
+			if updateStability && !card.LastReview.IsZero() {
+				interval := max(int(math.Round(card.Due.Sub(card.LastReview).Hours()/24)), 1)
+				card.ScheduledDays = uint64(interval)
+				card.Stability = stabilityForInterval(params, interval)
+			}
+			if err := table.save(ctx, tx, id, card, "", now); err != nil {
+				return err
+			}
+			if err := insertManualRevlogTx(ctx, tx, userID, id, int(card.State), card.Due, now); err != nil {
+				return err
+			}
+			rescheduled++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return rescheduled, skipped, nil
+}
+
+// SetCardsDue serves POST /api/cards/set-due.
+func (h *APIHandler) SetCardsDue(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+
+	var req SetDueRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	days, date := strings.TrimSpace(req.Days), strings.TrimSpace(req.Date)
+
+	now := time.Now()
+	dayStart, _, err := h.store.studyDay(r.Context(), collectionID, now)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "preferences_load_failed", err.Error())
+		return
+	}
+	var from, to int
+	if days != "" {
+		from, to, _ = parseSetDueDays(days)
+	} else {
+		day, _ := time.ParseInLocation("2006-01-02", date, dayStart.Location())
+		today := time.Date(dayStart.Year(), dayStart.Month(), dayStart.Day(), 0, 0, 0, 0, dayStart.Location())
+		from = int(math.Round(day.Sub(today).Hours() / 24))
+		if from < 0 || from > maxSetDueDays {
+			respondAPIError(w, http.StatusBadRequest, "invalid_date", "date must be today or later")
+			return
+		}
+		to = from
+	}
+
+	cardIDs, _, ok := h.resolveCardSelection(w, r, collectionID, req.CardIDs, req.Query)
+	if !ok {
+		return
+	}
+	dues := make(map[int64]time.Time, len(cardIDs))
+	for _, id := range cardIDs {
+		dues[id] = time.Unix(dayStart.AddDate(0, 0, from+rand.IntN(to-from+1)).Unix(), 0)
+	}
+
+	userID := h.userIDFromRequest(r)
+	rescheduled, skipped, err := h.store.SetCardDueDates(r.Context(), userID, dues, req.UpdateStability, col.Params, now)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "card_set_due_failed", err.Error())
+		return
+	}
+	if strings.TrimSpace(userID) == "" {
+		for _, id := range cardIDs {
+			card, ok := col.Cards[id]
+			if !ok {
+				continue
+			}
+			if refreshed, err := h.store.GetCard(r.Context(), id); err == nil {
+				card.SRS = refreshed.SRS
+				card.LearningStep, card.Buried = 0, false
+			}
+		}
+	}
+
+	respondJSON(w, http.StatusOK, SetDueResponse{
+		MatchedCards:     len(cardIDs),
+		RescheduledCards: rescheduled,
+		SkippedNewCards:  skipped,
+		CardIDs:          cardIDs,
+	})
+}
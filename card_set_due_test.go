@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestParseSetDueDays(t *testing.T) {
+	for raw, want := range map[string][2]int{"0": {0, 0}, "5": {5, 5}, "3-7": {3, 7}, " 3 - 7 days": {3, 7}} {
+		from, to, err := parseSetDueDays(raw)
+		if err != nil || from != want[0] || to != want[1] {
+			t.Fatalf("parseSetDueDays(%q) = %d, %d, %v; want %v", raw, from, to, err, want)
+		}
+	}
+	for _, raw := range []string{"", "x", "-1", "7-3", "1-x", "40000"} {
+		if _, _, err := parseSetDueDays(raw); err == nil {
+			t.Fatalf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestAPI_SetCardsDue(t *testing.T) {
+	env := setupAPITestEnv(t)
+	reviewed := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Golgi", "Back": "Packaging"},
+	}, nil).Cards[0].ID
+	unseen := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Vacuole", "Back": "Storage"},
+	}, nil).Cards[0].ID
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", reviewed), AnswerCardRequest{Rating: 4}); rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	dayStart, _ := studyDayBounds(time.Now(), defaultNextDayStartsAt)
+	selection := BulkCardSelectionRequest{CardIDs: []int64{reviewed, unseen}}
+	getCard := func(id int64) Card {
+		return decodeJSON[Card](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/cards/%d", id), ""))
+	}
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/set-due", SetDueRequest{BulkCardSelectionRequest: selection, Days: "5"})
+	resp := decodeJSON[SetDueResponse](t, rr)
+	if rr.Code != http.StatusOK || resp.RescheduledCards != 1 || resp.SkippedNewCards != 1 {
+		t.Fatalf("expected the reviewed card moved and the new card skipped, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	card := getCard(reviewed)
+	if !card.SRS.Due.Equal(dayStart.AddDate(0, 0, 5)) || card.SRS.State != fsrs.Review {
+		t.Fatalf("expected the card due at the start of the fifth study day, got %s", card.SRS.Due)
+	}
+	if getCard(unseen).SRS.State != fsrs.New {
+		t.Fatalf("expected the new card left alone")
+	}
+	entries, err := env.store.ListRevlogEntriesForCard(context.Background(), "", reviewed, 10)
+	if err != nil || len(entries) != 2 || entries[0].Kind != reviewKindManual {
+		t.Fatalf("expected a manual revlog entry, got %+v (%v)", entries, err)
+	}
+
+	rr = doJSONRequest(t, env.router, http.MethodPost, "/api/cards/set-due", SetDueRequest{BulkCardSelectionRequest: selection, Days: "3-7", UpdateStability: true})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected set-due 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	card = getCard(reviewed)
+	offset := int(math.Round(card.SRS.Due.Sub(dayStart).Hours() / 24))
+	if offset < 3 || offset > 7 {
+		t.Fatalf("expected the card due 3-7 days out, got %d", offset)
+	}
+	if card.SRS.ScheduledDays < 2 || math.Abs(card.SRS.Stability-float64(card.SRS.ScheduledDays)) > 0.01 {
+		t.Fatalf("expected stability to match the new interval at 90%% retention, got %+v", card.SRS)
+	}
+
+	tomorrow := dayStart.AddDate(0, 0, 1).Format("2006-01-02")
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/set-due", SetDueRequest{BulkCardSelectionRequest: selection, Date: tomorrow}); rr.Code != http.StatusOK {
+		t.Fatalf("expected set-due by date 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if card := getCard(reviewed); !card.SRS.Due.Equal(dayStart.AddDate(0, 0, 1)) {
+		t.Fatalf("expected the card due tomorrow, got %s", card.SRS.Due)
+	}
+
+	for _, req := range []SetDueRequest{
+		{BulkCardSelectionRequest: selection},
+		{BulkCardSelectionRequest: selection, Days: "1", Date: tomorrow},
+		{BulkCardSelectionRequest: selection, Days: "soon"},
+		{BulkCardSelectionRequest: selection, Date: "2001-01-01"},
+		{Days: "1"},
+	} {
+		if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/set-due", req); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected %+v to be rejected, got %d", req, rr.Code)
+		}
+	}
+}
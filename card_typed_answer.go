@@ -0,0 +1,196 @@
+package main
+
+import (
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// maxTypedAnswerRunes bounds both sides of the comparison; the diff is quadratic.
+const maxTypedAnswerRunes = 2000
+
+const (
+	typedSpanCorrect = "correct"
+	typedSpanMissing = "missing"
+	typedSpanExtra   = "extra"
+)
+
+var plainTextPolicy = bluemonday.StrictPolicy()
+
+type CheckTypedAnswerRequest struct {
+	Typed string `json:"typed"`
+}
+
+// Validate is empty: a blank answer is compared like any other.
+func (req *CheckTypedAnswerRequest) Validate(v *requestValidator) {}
+
+// TypedAnswerSpan is a run of the comparison. Correct text is in both answers, missing
+// text is only in the expected answer, and extra text was typed but not expected.
+type TypedAnswerSpan struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+}
+
+type CheckTypedAnswerResponse struct {
+	CardID   int64             `json:"cardId"`
+	Field    string            `json:"field"`
+	Typed    string            `json:"typed"`
+	Expected string            `json:"expected"`
+	Correct  bool              `json:"correct"`
+	Spans    []TypedAnswerSpan `json:"spans"`
+}
+
+// typedAnswerField returns the field named by the first {{type:Field}} in a question
+// format, whether it is a {{type:cloze:Field}} reference, and whether one was found.
+func typedAnswerField(qfmt string) (string, bool, bool) {
+	for _, m := range fieldTokenRe.FindAllStringSubmatch(qfmt, -1) {
+		key := strings.TrimSpace(m[1])
+		if !strings.HasPrefix(key, "type:") {
+			continue
+		}
+		field := strings.TrimSpace(strings.TrimPrefix(key, "type:"))
+		if rest, ok := strings.CutPrefix(field, "cloze:"); ok {
+			return strings.TrimSpace(rest), true, true
+		}
+		return field, false, true
+	}
+	return "", false, false
+}
+
+// typedAnswerText reduces a field value to the plain text a user would type.
+func typedAnswerText(value string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(plainTextPolicy.Sanitize(value))), " ")
+}
+
+// clozeAnswers joins the answers of every deletion numbered ordinal, as Anki expects
+// them typed for {{type:cloze:Field}}.
+func clozeAnswers(text string, ordinal int) string {
+	var answers []string
+	for _, m := range clozeRe.FindAllStringSubmatch(text, -1) {
+		if m[1] == strconv.Itoa(ordinal) {
+			answers = append(answers, m[2])
+		}
+	}
+	return strings.Join(answers, ", ")
+}
+
+// diffTypedAnswer compares typed against expected rune by rune along their longest
+// common subsequence and merges the result into spans.
+func diffTypedAnswer(typed, expected string) []TypedAnswerSpan {
+	a, b := []rune(typed), []rune(expected)
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	spans := []TypedAnswerSpan{}
+	add := func(kind string, r rune) {
+		if n := len(spans); n > 0 && spans[n-1].Kind == kind {
+			spans[n-1].Text += string(r)
+			return
+		}
+		spans = append(spans, TypedAnswerSpan{Kind: kind, Text: string(r)})
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			add(typedSpanCorrect, a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(typedSpanExtra, a[i])
+			i++
+		default:
+			add(typedSpanMissing, b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		add(typedSpanExtra, a[i])
+	}
+	for ; j < len(b); j++ {
+		add(typedSpanMissing, b[j])
+	}
+	return spans
+}
+
+// CheckTypedAnswer serves POST /api/cards/{id}/check-typed, grading what the user typed
+// for a card whose question contains {{type:Field}}.
+func (h *APIHandler) CheckTypedAnswer(w http.ResponseWriter, r *http.Request) {
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	id, err := parseIDParam(r, "id")
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_card_id", "Invalid card ID")
+		return
+	}
+	var req CheckTypedAnswerRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+
+	decks, err := h.store.CardDecksInCollection(r.Context(), collectionID, []int64{id})
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "card_lookup_failed", err.Error())
+		return
+	}
+	card, err := h.store.GetCard(r.Context(), id)
+	if _, ok := decks[id]; !ok || err != nil {
+		respondAPIError(w, http.StatusNotFound, "card_not_found", "Card not found")
+		return
+	}
+	note, ok := col.Notes[card.NoteID]
+	if !ok {
+		respondAPIError(w, http.StatusNotFound, "note_not_found", "Note not found")
+		return
+	}
+
+	field, cloze, found := "", false, false
+	for _, tmpl := range col.NoteTypes[note.Type].Templates {
+		if tmpl.Name == card.TemplateName {
+			field, cloze, found = typedAnswerField(tmpl.QFmt)
+			break
+		}
+	}
+	if !found {
+		respondAPIError(w, http.StatusBadRequest, "not_typed_answer_card", "This card's template has no {{type:Field}}")
+		return
+	}
+
+	expected := note.FieldMap[field]
+	if cloze {
+		expected = clozeAnswers(expected, card.Ordinal)
+	}
+	expected = typedAnswerText(expected)
+	typed := strings.Join(strings.Fields(req.Typed), " ")
+	if len([]rune(typed)) > maxTypedAnswerRunes || len([]rune(expected)) > maxTypedAnswerRunes {
+		respondAPIError(w, http.StatusBadRequest, "typed_answer_too_long", "Answers longer than 2000 characters cannot be compared")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, CheckTypedAnswerResponse{
+		CardID:   id,
+		Field:    field,
+		Typed:    typed,
+		Expected: expected,
+		Correct:  typed == expected,
+		Spans:    diffTypedAnswer(typed, expected),
+	})
+}
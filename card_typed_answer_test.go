@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestDiffTypedAnswer(t *testing.T) {
+	got := diffTypedAnswer("helo wrld!", "hello world")
+	want := []TypedAnswerSpan{
+		{Kind: typedSpanCorrect, Text: "hel"},
+		{Kind: typedSpanMissing, Text: "l"},
+		{Kind: typedSpanCorrect, Text: "o w"},
+		{Kind: typedSpanMissing, Text: "o"},
+		{Kind: typedSpanCorrect, Text: "rld"},
+		{Kind: typedSpanExtra, Text: "!"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("diff = %+v, want %+v", got, want)
+	}
+	if spans := diffTypedAnswer("", ""); len(spans) != 0 {
+		t.Fatalf("expected no spans for empty answers, got %+v", spans)
+	}
+}
+
+func TestAPI_CheckTypedAnswer(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	typed := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic (type in the answer)",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Capital of France", "Back": "<b>Paris</b>"},
+	}, nil)
+	cardID := typed.Cards[0].ID
+
+	check := func(answer string) CheckTypedAnswerResponse {
+		t.Helper()
+		rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/check-typed", cardID), CheckTypedAnswerRequest{Typed: answer})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected check 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+		return decodeJSON[CheckTypedAnswerResponse](t, rr)
+	}
+
+	if resp := check("  Paris "); !resp.Correct || resp.Expected != "Paris" || resp.Field != "Back" {
+		t.Fatalf("expected exact answer graded correct, got %+v", resp)
+	}
+	resp := check("Pari")
+	if resp.Correct || len(resp.Spans) != 2 || resp.Spans[1] != (TypedAnswerSpan{Kind: typedSpanMissing, Text: "s"}) {
+		t.Fatalf("expected missing final letter, got %+v", resp)
+	}
+
+	basic := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Q", "Back": "A"},
+	}, nil)
+	rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/check-typed", basic.Cards[0].ID), CheckTypedAnswerRequest{Typed: "A"})
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected non-typed card 400, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/999999/check-typed", CheckTypedAnswerRequest{}); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected missing card 404, got %d", rr.Code)
+	}
+}
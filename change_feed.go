@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	changeOpUpsert = "upsert"
+	changeOpDelete = "delete"
+
+	defaultChangeFeedLimit = 500
+	maxChangeFeedLimit     = 5000
+)
+
+// ChangeEvent is one entry of the change log. Events carry identity only; consumers
+// refetch the entity (or drop it for deletes). USNs increase strictly across the whole
+// database, so a client resumes by passing the last USN it has applied.
+type ChangeEvent struct {
+	USN        int64     `json:"usn"`
+	EntityType string    `json:"entityType"`
+	EntityID   string    `json:"entityId"`
+	Op         string    `json:"op"`
+	ChangedAt  time.Time `json:"changedAt"`
+}
+
+type ChangeFeedResponse struct {
+	Changes   []ChangeEvent `json:"changes"`
+	LatestUSN int64         `json:"latestUsn"` // USN to pass as sinceUsn on the next call
+	HasMore   bool          `json:"hasMore"`
+}
+
+// changeLogSource describes how a table's rows map onto change events. Expressions use
+// {row}, which becomes NEW or OLD in the generated trigger.
+type changeLogSource struct {
+	table      string
+	entityType string
+	idExpr     string
+	collection string
+	userExpr   string
+	// upsertOnDelete is set for tables whose rows are per-user state of another entity:
+	// removing the row changes that entity rather than deleting it.
+	upsertOnDelete bool
+}
+
+var changeLogSources = []changeLogSource{
+	{table: "decks", entityType: "deck", idExpr: "{row}.id", collection: "{row}.collection_id"},
+	{table: "deck_options", entityType: "deck_options", idExpr: "{row}.id", collection: "(SELECT collection_id FROM decks WHERE options_id = {row}.id LIMIT 1)"},
+	{table: "note_types", entityType: "note_type", idExpr: "{row}.name", collection: "{row}.collection_id"},
+	{table: "notes", entityType: "note", idExpr: "{row}.id", collection: "{row}.collection_id"},
+	{table: "cards", entityType: "card", idExpr: "{row}.id", collection: "(SELECT collection_id FROM decks WHERE id = {row}.deck_id)"},
+	{
+		table:          "card_review_states",
+		entityType:     "card",
+		idExpr:         "{row}.card_id",
+		collection:     "(SELECT d.collection_id FROM cards c JOIN decks d ON d.id = c.deck_id WHERE c.id = {row}.card_id)",
+		userExpr:       "{row}.user_id",
+		upsertOnDelete: true,
+	},
+	{
+		table:      "revlog",
+		entityType: "revlog",
+		idExpr:     "{row}.id",
+		collection: "(SELECT d.collection_id FROM cards c JOIN decks d ON d.id = c.deck_id WHERE c.id = {row}.card_id)",
+		userExpr:   "{row}.user_id",
+	},
+	{table: "media", entityType: "media", idExpr: "{row}.filename", collection: "{row}.collection_id"},
+}
+
+// changeLogTriggerStatements returns the triggers that append to change_log whenever a
+// tracked table is written. Recording in the database keeps every write path covered
+// without each store method having to remember to log.
+func changeLogTriggerStatements() []string {
+	var statements []string
+	for _, source := range changeLogSources {
+		for _, event := range []string{"INSERT", "UPDATE", "DELETE"} {
+			row, op := "NEW", changeOpUpsert
+			if event == "DELETE" {
+				row = "OLD"
+				if !source.upsertOnDelete {
+					op = changeOpDelete
+				}
+			}
+			expand := func(expr string) string {
+				if expr == "" {
+					return "NULL"
+				}
+				return strings.ReplaceAll(expr, "{row}", row)
+			}
+			statements = append(statements, fmt.Sprintf(`
+		CREATE TRIGGER IF NOT EXISTS change_log_%s_%s AFTER %s ON %s
+		BEGIN
+			INSERT INTO change_log (collection_id, user_id, entity_type, entity_id, op, changed_at)
+			VALUES (%s, %s, '%s', CAST(%s AS TEXT), '%s', CAST(strftime('%%s', 'now') AS INTEGER));
+		END
+		`, source.table, strings.ToLower(event), event, source.table,
+				expand(source.collection), expand(source.userExpr), source.entityType, expand(source.idExpr), op))
+		}
+	}
+	return statements
+}
+
+// ListChanges returns change events for a collection after sinceUSN in USN order. Events
+// scoped to another user's review state are omitted.
+func (s *SQLStore) ListChanges(ctx context.Context, collectionID, userID string, sinceUSN int64, limit int) ([]ChangeEvent, error) {
+	if limit <= 0 {
+		limit = defaultChangeFeedLimit
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT usn, entity_type, entity_id, op, changed_at
+		FROM change_log
+		WHERE collection_id = ?
+		  AND usn > ?
+		  AND (user_id IS NULL OR user_id = '' OR user_id = ?)
+		ORDER BY usn ASC
+		LIMIT ?
+	`, collectionID, sinceUSN, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []ChangeEvent{}
+	for rows.Next() {
+		var (
+			event     ChangeEvent
+			changedAt int64
+		)
+		if err := rows.Scan(&event.USN, &event.EntityType, &event.EntityID, &event.Op, &changedAt); err != nil {
+			return nil, err
+		}
+		event.ChangedAt = time.Unix(changedAt, 0)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// GetChanges serves GET /api/changes?sinceUsn=&limit=. With Accept: application/x-ndjson
+// the whole remaining feed is streamed one event per line instead of a single page.
+func (h *APIHandler) GetChanges(w http.ResponseWriter, r *http.Request) {
+	collectionID := h.collectionIDForRequest(r)
+	userID := h.userIDFromRequest(r)
+
+	sinceUSN, ok := parseSinceUSN(w, r)
+	if !ok {
+		return
+	}
+	limit := changeFeedLimit(r)
+
+	if strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		h.streamChanges(r.Context(), w, collectionID, userID, sinceUSN)
+		return
+	}
+
+	events, err := h.store.ListChanges(r.Context(), collectionID, userID, sinceUSN, limit+1)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "changes_load_failed", err.Error())
+		return
+	}
+	resp := ChangeFeedResponse{Changes: events, LatestUSN: sinceUSN}
+	if len(events) > limit {
+		resp.Changes = events[:limit]
+		resp.HasMore = true
+	}
+	if n := len(resp.Changes); n > 0 {
+		resp.LatestUSN = resp.Changes[n-1].USN
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// parseSinceUSN reads the sinceUsn query parameter, also accepted as sinceUSN. It
+// responds with 400 and returns false when the value is not a non-negative integer.
+func parseSinceUSN(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	query := r.URL.Query()
+	raw := strings.TrimSpace(firstNonEmpty(query.Get("sinceUsn"), query.Get("sinceUSN")))
+	if raw == "" {
+		return 0, true
+	}
+	parsed, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || parsed < 0 {
+		respondAPIError(w, http.StatusBadRequest, "invalid_since_usn", "sinceUsn must be a non-negative integer")
+		return 0, false
+	}
+	return parsed, true
+}
+
+func changeFeedLimit(r *http.Request) int {
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		return min(l, maxChangeFeedLimit)
+	}
+	return defaultChangeFeedLimit
+}
+
+func (h *APIHandler) streamChanges(ctx context.Context, w http.ResponseWriter, collectionID, userID string, sinceUSN int64) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	for {
+		events, err := h.store.ListChanges(ctx, collectionID, userID, sinceUSN, defaultChangeFeedLimit)
+		if err != nil {
+			// Headers are already sent; end the stream with an error line.
+			_ = encoder.Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+			sinceUSN = event.USN
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if len(events) < defaultChangeFeedLimit {
+			return
+		}
+	}
+}
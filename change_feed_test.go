@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestAPI_ChangeFeedReportsOrderedEvents(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	baseline := decodeJSON[ChangeFeedResponse](t, doRawRequest(env.router, http.MethodGet, "/api/changes", ""))
+	since := baseline.LatestUSN
+
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Feed", "Back": "Answer"},
+	}, nil)
+	removed := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Feed removed", "Back": "Answer"},
+	}, nil)
+	noteID := strconv.FormatInt(removed.Note.ID, 10)
+	cardID := created.Cards[0].ID
+
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", cardID), AnswerCardRequest{Rating: 3}); rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doRawRequest(env.router, http.MethodDelete, fmt.Sprintf("/api/notes/%d", removed.Note.ID), ""); rr.Code != http.StatusOK && rr.Code != http.StatusNoContent {
+		t.Fatalf("expected note delete to succeed, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr := doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/changes?sinceUsn=%d", since), "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected changes 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	feed := decodeJSON[ChangeFeedResponse](t, rr)
+	if feed.HasMore || len(feed.Changes) == 0 {
+		t.Fatalf("expected a complete non-empty page, got %+v", feed)
+	}
+
+	seen := map[string]int{}
+	var last int64 = since
+	for _, event := range feed.Changes {
+		if event.USN <= last {
+			t.Fatalf("expected strictly increasing USNs, got %d after %d", event.USN, last)
+		}
+		last = event.USN
+		seen[event.EntityType+":"+event.Op]++
+	}
+	if feed.LatestUSN != last {
+		t.Fatalf("expected latestUsn %d, got %d", last, feed.LatestUSN)
+	}
+	for _, key := range []string{"note:upsert", "card:upsert", "revlog:upsert", "note:delete"} {
+		if seen[key] == 0 {
+			t.Fatalf("expected a %s event, got %v", key, seen)
+		}
+	}
+	var noteDeleted bool
+	for _, event := range feed.Changes {
+		if event.EntityType == "note" && event.Op == changeOpDelete && event.EntityID == noteID {
+			noteDeleted = true
+		}
+	}
+	if !noteDeleted {
+		t.Fatalf("expected delete event for note %s", noteID)
+	}
+
+	page := decodeJSON[ChangeFeedResponse](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/changes?sinceUsn=%d&limit=1", since), ""))
+	if len(page.Changes) != 1 || !page.HasMore || page.LatestUSN != feed.Changes[0].USN {
+		t.Fatalf("expected a single-event page with more to follow, got %+v", page)
+	}
+
+	if bad := doRawRequest(env.router, http.MethodGet, "/api/changes?sinceUsn=abc", ""); bad.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid sinceUsn 400, got %d", bad.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/changes?sinceUsn=%d", since), nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	req.Header.Set("Cookie", env.authCookie)
+	stream := httptest.NewRecorder()
+	env.router.ServeHTTP(stream, req)
+	if stream.Code != http.StatusOK {
+		t.Fatalf("expected ndjson stream 200, got %d", stream.Code)
+	}
+	lines := 0
+	scanner := bufio.NewScanner(stream.Body)
+	for scanner.Scan() {
+		var event ChangeEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("expected each line to be an event: %v", err)
+		}
+		lines++
+	}
+	if lines != len(feed.Changes) {
+		t.Fatalf("expected %d streamed events, got %d", len(feed.Changes), lines)
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -35,10 +37,12 @@ type CardTemplate struct {
 }
 
 type FieldOptions struct {
-	Font       string `json:"font,omitempty"`       // Font family (e.g., "Arial", "Times New Roman")
-	FontSize   int    `json:"fontSize,omitempty"`   // Font size in pixels
-	RTL        bool   `json:"rtl,omitempty"`        // Right-to-left text direction
-	HTMLEditor bool   `json:"htmlEditor,omitempty"` // Use HTML editor mode by default
+	Font         string `json:"font,omitempty"`         // Font family (e.g., "Arial", "Times New Roman")
+	FontSize     int    `json:"fontSize,omitempty"`     // Font size in pixels
+	RTL          bool   `json:"rtl,omitempty"`          // Right-to-left text direction
+	HTMLEditor   bool   `json:"htmlEditor,omitempty"`   // Use HTML editor mode by default
+	DefaultValue string `json:"defaultValue,omitempty"` // Value used when a new note leaves the field blank
+	Placeholder  string `json:"placeholder,omitempty"`  // Input hint shown by editors while the field is empty
 }
 
 type NoteType struct {
@@ -49,6 +53,22 @@ type NoteType struct {
 	FieldOptions   map[string]FieldOptions `json:"fieldOptions,omitempty"` // Per-field editing options
 }
 
+// withFieldDefaults returns a copy of fields where every field that is missing or blank
+// takes its configured default value.
+func (nt NoteType) withFieldDefaults(fields map[string]string) map[string]string {
+	out := make(map[string]string, len(fields))
+	for name, value := range fields {
+		out[name] = value
+	}
+	for name, opts := range nt.FieldOptions {
+		if opts.DefaultValue == "" || strings.TrimSpace(out[name]) != "" {
+			continue
+		}
+		out[name] = opts.DefaultValue
+	}
+	return out
+}
+
 type Note struct {
 	ID         int64             `json:"id"`
 	Type       NoteTypeName      `json:"type"`
@@ -75,7 +95,17 @@ type Card struct {
 	Flag      int   `json:"flag"`      // 0=none, 1-7=color flags for marking cards
 	Marked    bool  `json:"marked"`    // special "marked" tag for review
 	Suspended bool  `json:"suspended"` // whether card is suspended (excluded from study)
+	Buried    bool  `json:"buried"`    // whether card is buried until the next study day
 	USN       int64 `json:"usn"`       // Update Sequence Number for sync
+	// LearningStep is the learning step a card in the Learning state is waiting on.
+	LearningStep int `json:"learningStep,omitempty"`
+	// EaseFactor is the card's SM-2 ease in permille (2500 = 250%); 0 until a deck using
+	// the SM-2 scheduler first reviews it.
+	EaseFactor int `json:"easeFactor,omitempty"`
+	// OriginalDeckID is the home deck of a card pulled into a filtered deck; 0 otherwise.
+	OriginalDeckID int64 `json:"originalDeckId,omitempty"`
+	// NextIntervals previews each answer's interval when the card is served for study.
+	NextIntervals *AnswerIntervals `json:"nextIntervals,omitempty"`
 }
 
 // CardReviewState stores the per-user scheduling and review metadata for a card.
@@ -90,6 +120,26 @@ type CardReviewState struct {
 	UpdatedAt time.Time `json:"updatedAt"`
 }
 
+// RevlogEntry is one row of the persisted review log.
+type RevlogEntry struct {
+	ID          int64     `json:"id"`
+	UserID      string    `json:"userId,omitempty"`
+	CardID      int64     `json:"cardId"`
+	Rating      int       `json:"rating"`
+	State       int       `json:"state"`
+	Due         time.Time `json:"due"`
+	ReviewedAt  time.Time `json:"reviewedAt"`
+	TimeTakenMs int       `json:"timeTakenMs"`
+	Kind        string    `json:"kind"` // learn, review, relearn, filtered, or manual
+	// State the review left the card in; nil for entries recorded before it was kept.
+	NextState *int `json:"nextState,omitempty"`
+	// FSRS memory state after the review; nil for entries recorded before it was kept.
+	Stability     *float64 `json:"stability,omitempty"`
+	Difficulty    *float64 `json:"difficulty,omitempty"`
+	ElapsedDays   int      `json:"elapsedDays"`
+	ScheduledDays int      `json:"scheduledDays"`
+}
+
 type Deck struct {
 	ID            int64 `json:"id"`
 	Name          string
@@ -104,12 +154,21 @@ type Deck struct {
 type DeckOptions struct {
 	ID                 int64
 	Name               string
-	NewCardsPerDay     int   // daily limit for new cards
-	ReviewsPerDay      int   // daily limit for reviews
-	LearningSteps      []int // learning steps in minutes (e.g. [1, 10])
-	GraduatingInterval int   // days until a learning card becomes review card
-	EasyInterval       int   // days for "easy" button on new card
-	// Future: add more options from Tasks 0402-0405 (lapses, relearning, etc.)
+	NewCardsPerDay     int    // daily limit for new cards
+	ReviewsPerDay      int    // daily limit for reviews
+	LearningSteps      []int  // learning steps in minutes (e.g. [1, 10])
+	GraduatingInterval int    // days until a learning card becomes review card
+	EasyInterval       int    // days for "easy" button on new card
+	RelearningSteps    []int  // relearning steps in minutes after a lapse (e.g. [10])
+	MinimumInterval    int    // shortest interval in days after a lapse
+	NewIntervalPercent int    // percentage of the old interval kept after a lapse
+	LeechThreshold     int    // lapses that make a card a leech; 0 turns detection off
+	LeechAction        string // "tag" or "suspend"
+	Scheduler          string // "fsrs" or "sm2"
+	StartingEase       int    // SM-2 ease of a newly graduated card, in permille
+	EasyBonus          int    // percentage SM-2 multiplies Easy intervals by
+	IntervalModifier   int    // percentage applied to every SM-2 review interval
+	ReviewOrder        string // order of the review queue, e.g. "due" or "overdueness"
 }
 
 // MediaRef represents a media file (image, audio, video) referenced by notes.
@@ -142,13 +201,28 @@ type DeckStats struct {
 	TotalCards       int   `json:"totalCards"`
 	DueToday         int   `json:"dueToday"`
 	DueReviewBacklog int   `json:"dueReviewBacklog"`
+
+	// Effective daily limits after ancestor caps are applied (see DeckLimits).
+	NewLimit        int `json:"newLimit"`
+	ReviewLimit     int `json:"reviewLimit"`
+	NewRemaining    int `json:"newRemaining"`
+	ReviewRemaining int `json:"reviewRemaining"`
 }
 
+// defaultCollectionID is the collection a database starts with, used when nothing
+// names another.
+const defaultCollectionID = "default"
+
 type Collection struct {
 	nextNoteID int64
 	nextCardID int64
 	nextDeckID int64
 
+	// ID and Name identify the collection's record; a new collection with no ID is
+	// stored as the default collection.
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
 	NoteTypes map[NoteTypeName]NoteType `json:"noteTypes"`
 	Notes     map[int64]Note            `json:"notes"`
 	Cards     map[int64]*Card           `json:"cards"`
@@ -211,7 +285,7 @@ func (c *Collection) AddNote(deckID int64, ntName NoteTypeName, fields map[strin
 	n := Note{
 		ID:         noteID,
 		Type:       ntName,
-		FieldMap:   fields,
+		FieldMap:   nt.withFieldDefaults(fields),
 		Tags:       []string{}, // empty tags by default
 		USN:        c.USN,      // track when note was created
 		CreatedAt:  now,
@@ -221,6 +295,7 @@ func (c *Collection) AddNote(deckID int64, ntName NoteTypeName, fields map[strin
 
 	genCards, err := c.generateCardsFromNote(nt, n, deckID, now)
 	if err != nil {
+		delete(c.Notes, noteID)
 		return Note{}, nil, err
 	}
 
@@ -241,6 +316,26 @@ func (c *Collection) AddNote(deckID int64, ntName NoteTypeName, fields map[strin
 	return n, out, nil
 }
 
+// removeAddedNote undoes AddNote for a note that could not be saved. Its IDs are not
+// handed out again.
+func (c *Collection) removeAddedNote(n Note, cards []*Card) {
+	delete(c.Notes, n.ID)
+	for _, card := range cards {
+		delete(c.Cards, card.ID)
+		d, ok := c.Decks[card.DeckID]
+		if !ok {
+			continue
+		}
+		kept := d.Cards[:0]
+		for _, cardID := range d.Cards {
+			if cardID != card.ID {
+				kept = append(kept, cardID)
+			}
+		}
+		d.Cards = kept
+	}
+}
+
 // GenerateCards creates cards from a note using its note type templates.
 // This is used when changing a note's note type (note type migration).
 // It regenerates all cards for the note, preserving stable card IDs where possible.
@@ -351,12 +446,14 @@ func (c *Collection) generateCardsFromNote(nt NoteType, n Note, deckID int64, no
 			// If deck not found, fall back to default deck
 		}
 
+		fields := c.templateFields(nt, n, tmpl, targetDeckID)
+
 		if tmpl.IsCloze {
-			textField := n.FieldMap["Text"]
-			ordinals := extractClozeOrdinals(textField)
-			for _, ord := range ordinals {
-				q := renderTemplateWithCloze(tmpl.QFmt, n.FieldMap, ord, false)
-				a := renderTemplateWithCloze(tmpl.AFmt, n.FieldMap, ord, true)
+			for _, ord := range clozeOrdinalsForTemplate(tmpl, n.FieldMap) {
+				q := renderTemplateWithCloze(tmpl.QFmt, fields, ord, false)
+				fields["FrontSide"] = q
+				a := renderTemplateWithCloze(tmpl.AFmt, fields, ord, true)
+				delete(fields, "FrontSide")
 				card := &Card{
 					NoteID:       n.ID,
 					DeckID:       targetDeckID,
@@ -371,8 +468,9 @@ func (c *Collection) generateCardsFromNote(nt NoteType, n Note, deckID int64, no
 			continue
 		}
 
-		q := renderTemplate(tmpl.QFmt, n.FieldMap)
-		a := renderTemplate(tmpl.AFmt, n.FieldMap)
+		q := renderTemplate(tmpl.QFmt, fields)
+		fields["FrontSide"] = q
+		a := renderTemplate(tmpl.AFmt, fields)
 
 		card := &Card{
 			NoteID:       n.ID,
@@ -395,7 +493,26 @@ func newDueNow(now time.Time) fsrs.Card {
 	return c
 }
 
+// templateFields returns the values a template can reference for one card: the note's
+// fields plus Anki's special fields Tags, Type, Deck, Subdeck and Card. FrontSide is
+// added by the caller once the question has been rendered.
+func (c *Collection) templateFields(nt NoteType, n Note, tmpl CardTemplate, deckID int64) map[string]string {
+	fields := make(map[string]string, len(n.FieldMap)+5)
+	for name, value := range n.FieldMap {
+		fields[name] = value
+	}
+	fields["Tags"] = strings.Join(n.Tags, " ")
+	fields["Type"] = string(nt.Name)
+	fields["Card"] = tmpl.Name
+	if deck, ok := c.Decks[deckID]; ok {
+		fields["Deck"] = deck.Name
+		fields["Subdeck"] = deckShortName(deck.Name)
+	}
+	return fields
+}
+
 func renderTemplate(tmpl string, fields map[string]string) string {
+	tmpl = renderSections(tmpl, fields)
 	return fieldTokenRe.ReplaceAllStringFunc(tmpl, func(token string) string {
 		m := fieldTokenRe.FindStringSubmatch(token)
 		if len(m) != 2 {
@@ -413,29 +530,164 @@ func renderTemplate(tmpl string, fields map[string]string) string {
 			}
 			return "[type your answer here]"
 		}
+		if tag, ok := ttsSoundTag(key, fields); ok {
+			return tag
+		}
 
-		// normal {{Field}} replacement
-		return fields[key]
+		// normal {{Field}} replacement; FrontSide is already rendered
+		if key == "FrontSide" {
+			return fields[key]
+		}
+		return markupMath(fields[key])
 	})
 }
 
 func renderTemplateWithCloze(tmpl string, fields map[string]string, targetOrdinal int, reveal bool) string {
-	// First replace {{cloze:Text}} tokens (Anki style)
+	tmpl = renderSections(tmpl, fields)
+	// First replace {{cloze:Field}} tokens (Anki style)
 	out := fieldTokenRe.ReplaceAllStringFunc(tmpl, func(token string) string {
 		m := fieldTokenRe.FindStringSubmatch(token)
 		if len(m) != 2 {
 			return token
 		}
 		key := strings.TrimSpace(m[1])
-		if key == "cloze:Text" {
-			return renderCloze(fields["Text"], targetOrdinal, reveal)
+		if field, ok := clozeTokenField(key); ok {
+			return markupMath(renderCloze(fields[field], targetOrdinal, reveal))
+		}
+		if field, ok := filterTokenField(key, imageOcclusionFilter); ok {
+			return renderOcclusionMasks(fields[field], targetOrdinal, reveal)
+		}
+		if tag, ok := ttsSoundTag(key, fields); ok {
+			return tag
 		}
 		// fallback: normal replacement
-		return fields[key]
+		if key == "FrontSide" {
+			return fields[key]
+		}
+		return markupMath(fields[key])
 	})
 	return out
 }
 
+// clozeTokenField returns the field of a {{cloze:Field}} reference. Other filters may
+// come before it, as in {{cloze:text:Field}}.
+func clozeTokenField(key string) (string, bool) {
+	return filterTokenField(key, "cloze")
+}
+
+// filterTokenField returns the field of a reference that applies filter, such as
+// {{cloze:Field}} or {{image-occlusion:Field}}.
+func filterTokenField(key, filter string) (string, bool) {
+	parts := strings.Split(key, ":")
+	for _, name := range parts[:len(parts)-1] {
+		if strings.TrimSpace(name) == filter {
+			return strings.TrimSpace(parts[len(parts)-1]), true
+		}
+	}
+	return "", false
+}
+
+// templateFilterFields lists the fields tmpl renders through filter, in order of
+// first use.
+func templateFilterFields(tmpl CardTemplate, filter string) []string {
+	var fields []string
+	for _, m := range fieldTokenRe.FindAllStringSubmatch(tmpl.QFmt+tmpl.AFmt, -1) {
+		if field, ok := filterTokenField(strings.TrimSpace(m[1]), filter); ok && !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// clozeTemplateFields lists the fields a cloze template renders through {{cloze:...}},
+// in order of first use. Templates that name none fall back to "Text".
+func clozeTemplateFields(tmpl CardTemplate) []string {
+	fields := templateFilterFields(tmpl, "cloze")
+	if len(fields) == 0 {
+		fields = []string{"Text"}
+	}
+	return fields
+}
+
+// clozeOrdinalsForTemplate returns the sorted cloze numbers found across every cloze
+// field of tmpl; each one becomes a card. Image occlusion templates number their cards
+// by mask ordinal instead.
+func clozeOrdinalsForTemplate(tmpl CardTemplate, fields map[string]string) []int {
+	if masks := templateFilterFields(tmpl, imageOcclusionFilter); len(masks) > 0 {
+		return occlusionOrdinals(fields, masks)
+	}
+	var text strings.Builder
+	for _, field := range clozeTemplateFields(tmpl) {
+		text.WriteString(fields[field])
+		text.WriteString("\n")
+	}
+	return extractClozeOrdinals(text.String())
+}
+
+// renderSections resolves Anki conditional sections: {{#Field}}...{{/Field}} keeps its
+// content only when Field is non-empty, {{^Field}}...{{/Field}} only when it is empty.
+// Sections may nest. An opening tag that is never closed is ignored.
+func renderSections(tmpl string, fields map[string]string) string {
+	var out strings.Builder
+	for {
+		loc := fieldTokenRe.FindStringSubmatchIndex(tmpl)
+		if loc == nil {
+			out.WriteString(tmpl)
+			return out.String()
+		}
+		key := strings.TrimSpace(tmpl[loc[2]:loc[3]])
+		if key == "" || (key[0] != '#' && key[0] != '^') {
+			out.WriteString(tmpl[:loc[1]])
+			tmpl = tmpl[loc[1]:]
+			continue
+		}
+
+		name := strings.TrimSpace(key[1:])
+		innerEnd, closeEnd := findSectionClose(tmpl[loc[1]:], name)
+		if innerEnd < 0 {
+			out.WriteString(tmpl[:loc[1]])
+			tmpl = tmpl[loc[1]:]
+			continue
+		}
+		out.WriteString(tmpl[:loc[0]])
+		if fieldIsEmpty(fields[name]) == (key[0] == '^') {
+			out.WriteString(renderSections(tmpl[loc[1]:loc[1]+innerEnd], fields))
+		}
+		tmpl = tmpl[loc[1]+closeEnd:]
+	}
+}
+
+// findSectionClose finds the {{/name}} that closes a section opened just before body,
+// skipping nested sections on the same field. It returns where the closing token
+// starts and ends, or -1, -1 when there is none.
+func findSectionClose(body, name string) (int, int) {
+	depth := 0
+	for _, loc := range fieldTokenRe.FindAllStringSubmatchIndex(body, -1) {
+		key := strings.TrimSpace(body[loc[2]:loc[3]])
+		if key == "" || strings.TrimSpace(key[1:]) != name {
+			continue
+		}
+		switch key[0] {
+		case '#', '^':
+			depth++
+		case '/':
+			if depth == 0 {
+				return loc[0], loc[1]
+			}
+			depth--
+		}
+	}
+	return -1, -1
+}
+
+// fieldIsEmpty reports whether a field counts as empty for conditional sections:
+// blank, or holding nothing but whitespace and line breaks.
+func fieldIsEmpty(value string) bool {
+	return strings.TrimSpace(emptyFieldMarkupRe.ReplaceAllString(value, "")) == ""
+}
+
+var emptyFieldMarkupRe = regexp.MustCompile(`(?i)<br\s*/?>|&nbsp;`)
+
 func extractClozeOrdinals(text string) []int {
 	seen := map[int]bool{}
 	matches := clozeRe.FindAllStringSubmatch(text, -1)
@@ -498,36 +750,54 @@ func renderCloze(text string, targetOrdinal int, reveal bool) string {
 // InitDefaultCollection initializes or loads the default collection from SQLite.
 // If the collection doesn't exist, it creates one with built-in note types.
 // Also ensures a default profile exists.
-func InitDefaultCollection(dbPath string) (*Collection, *SQLiteStore, error) {
-	return InitDefaultCollectionWithConfig(DatabaseConfig{
-		Mode: DatabaseModeSQLite,
-		Path: dbPath,
-	})
+func InitDefaultCollection(dbPath string) (*Collection, *SQLStore, error) {
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create store: %w", err)
+	}
+	col, err := loadDefaultCollection(store)
+	if err != nil {
+		return nil, nil, err
+	}
+	return col, store, nil
 }
 
-func InitDefaultCollectionWithConfig(cfg DatabaseConfig) (*Collection, *SQLiteStore, error) {
+// InitDefaultCollectionWithConfig does the same for the database cfg names.
+func InitDefaultCollectionWithConfig(cfg DatabaseConfig) (*Collection, Store, error) {
 	store, err := OpenStore(cfg)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create store: %w", err)
 	}
+	col, err := loadDefaultCollection(store)
+	if err != nil {
+		return nil, nil, err
+	}
+	return col, store, nil
+}
+
+// loadDefaultCollection loads the active profile's collection, creating it and the
+// built-in note types it lacks.
+func loadDefaultCollection(store Store) (*Collection, error) {
+	ctx := context.Background()
 
 	// Ensure default profile exists and is active
-	profile, err := store.GetActiveProfile()
+	profile, err := store.GetActiveProfile(ctx)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get active profile: %w", err)
+		return nil, fmt.Errorf("failed to get active profile: %w", err)
 	}
 	fmt.Printf("Active profile: %s (%s)\n", profile.Name, profile.ID)
 
 	// Try to load existing collection for this profile
-	col, err := store.GetCollection(profile.CollectionID)
+	col, err := store.GetCollection(ctx, profile.CollectionID)
 	if err != nil {
 		// Collection doesn't exist, create a new one
 		fmt.Println("Creating new collection with built-in note types...")
 		col = NewCollection()
+		col.ID = profile.CollectionID
 
 		// Create collection record
-		if err := store.CreateCollection(col); err != nil {
-			return nil, nil, fmt.Errorf("failed to create collection: %w", err)
+		if err := store.CreateCollection(ctx, col); err != nil {
+			return nil, fmt.Errorf("failed to create collection: %w", err)
 		}
 	}
 
@@ -537,18 +807,18 @@ func InitDefaultCollectionWithConfig(cfg DatabaseConfig) (*Collection, *SQLiteSt
 		col.NoteTypes = make(map[NoteTypeName]NoteType)
 		for _, nt := range noteTypes {
 			// Check if note type already exists in DB
-			_, err := store.GetNoteType("default", nt.Name)
+			_, err := store.GetNoteType(ctx, col.ID, nt.Name)
 			if err != nil {
 				// Doesn't exist, create it
-				if err := store.CreateNoteType("default", &nt); err != nil {
-					return nil, nil, fmt.Errorf("failed to create note type %s: %w", nt.Name, err)
+				if err := store.CreateNoteType(ctx, col.ID, &nt); err != nil {
+					return nil, fmt.Errorf("failed to create note type %s: %w", nt.Name, err)
 				}
 			}
 			col.NoteTypes[nt.Name] = nt
 		}
 	}
 
-	return col, store, nil
+	return col, nil
 }
 
 func builtins() map[NoteTypeName]NoteType {
@@ -608,6 +878,7 @@ func builtins() map[NoteTypeName]NoteType {
 				},
 			},
 		},
+		imageOcclusionNoteType: imageOcclusionBuiltin(),
 	}
 }
 
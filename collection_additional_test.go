@@ -82,3 +82,90 @@ func TestRenderTemplate_TypeInAnswerAndStandardTokens(t *testing.T) {
 		t.Fatalf("expected empty-type message, got %q", emptyExpected)
 	}
 }
+
+func TestRenderTemplateSections(t *testing.T) {
+	fields := map[string]string{"Front": "hola", "Back": "hello", "Add Reverse": "y", "Extra": " <br> "}
+	cases := map[string]string{
+		"{{#Add Reverse}}{{Back}}{{/Add Reverse}}":                     "hello",
+		"{{^Add Reverse}}none{{/Add Reverse}}":                         "",
+		"{{Front}}{{#Extra}}<hr>{{Extra}}{{/Extra}}":                   "hola",
+		"{{^Extra}}no extra{{/Extra}}":                                 "no extra",
+		"{{#Missing}}x{{/Missing}}{{^Missing}}y{{/Missing}}":           "y",
+		"{{#Front}}a{{#Back}}b{{^Back}}c{{/Back}}{{/Back}}d{{/Front}}": "abd",
+		"{{#Front}}unclosed":                                           "unclosed",
+	}
+	for tmpl, want := range cases {
+		if got := renderTemplate(tmpl, fields); got != want {
+			t.Fatalf("renderTemplate(%q) = %q, want %q", tmpl, got, want)
+		}
+	}
+
+	cloze := renderTemplateWithCloze("{{cloze:Text}}{{#Extra}}!{{/Extra}}", map[string]string{"Text": "{{c1::Paris}} is a city"}, 1, false)
+	if cloze != "[...] is a city" {
+		t.Fatalf("unexpected cloze render %q", cloze)
+	}
+}
+
+func TestGenerateCards_SpecialTemplateFields(t *testing.T) {
+	col := NewCollection()
+	deck := col.NewDeck("Languages::Spanish")
+	nt := NoteType{
+		Name:   "Vocab",
+		Fields: []string{"Word", "Meaning"},
+		Templates: []CardTemplate{{
+			Name: "Recognition",
+			QFmt: "{{Word}} [{{Subdeck}}]",
+			AFmt: "{{FrontSide}}<hr>{{Meaning}} | {{Deck}} | {{Card}} | {{Type}} | {{Tags}}",
+		}},
+	}
+	col.NoteTypes[nt.Name] = nt
+	note := Note{Type: nt.Name, FieldMap: map[string]string{"Word": "perro", "Meaning": "dog"}, Tags: []string{"animals", "a1"}}
+
+	cards, err := col.generateCardsFromNote(nt, note, deck.ID, time.Now())
+	if err != nil || len(cards) != 1 {
+		t.Fatalf("expected one card, got %d (%v)", len(cards), err)
+	}
+	if cards[0].Front != "perro [Spanish]" {
+		t.Fatalf("unexpected front %q", cards[0].Front)
+	}
+	if want := "perro [Spanish]<hr>dog | Languages::Spanish | Recognition | Vocab | animals a1"; cards[0].Back != want {
+		t.Fatalf("back = %q, want %q", cards[0].Back, want)
+	}
+
+	cloze := NoteType{Name: "Cloze", Fields: []string{"Text"}, Templates: []CardTemplate{{Name: "Cloze", QFmt: "{{cloze:Text}}", AFmt: "{{FrontSide}} / {{cloze:Text}}", IsCloze: true}}}
+	clozeCards, err := col.generateCardsFromNote(cloze, Note{FieldMap: map[string]string{"Text": "{{c1::Madrid}} is in Spain"}}, deck.ID, time.Now())
+	if err != nil || len(clozeCards) != 1 || clozeCards[0].Back != "[...] is in Spain / **Madrid** is in Spain" {
+		t.Fatalf("unexpected cloze cards %+v (%v)", clozeCards, err)
+	}
+}
+
+func TestGenerateCards_ClozeInAnyField(t *testing.T) {
+	col := NewCollection()
+	deck := col.NewDeck("Geography")
+	nt := NoteType{
+		Name:   "Two-sided cloze",
+		Fields: []string{"Sentence", "Notes"},
+		Templates: []CardTemplate{{
+			Name:    "Cloze",
+			QFmt:    "{{cloze:Sentence}}<br>{{cloze:Notes}}",
+			AFmt:    "{{cloze:Sentence}}<br>{{cloze:Notes}}",
+			IsCloze: true,
+		}},
+	}
+	note := Note{FieldMap: map[string]string{
+		"Sentence": "{{c1::Lima}} is the capital of {{c2::Peru}}",
+		"Notes":    "Founded in {{c3::1535}}",
+	}}
+
+	cards, err := col.generateCardsFromNote(nt, note, deck.ID, time.Now())
+	if err != nil || len(cards) != 3 {
+		t.Fatalf("expected a card per cloze across both fields, got %d (%v)", len(cards), err)
+	}
+	if cards[2].Ordinal != 3 || cards[2].Front != "Lima is the capital of Peru<br>Founded in [...]" {
+		t.Fatalf("unexpected third card %+v", cards[2])
+	}
+
+	if got := clozeTemplateFields(CardTemplate{QFmt: "{{Front}}"}); len(got) != 1 || got[0] != "Text" {
+		t.Fatalf("expected fallback to Text, got %v", got)
+	}
+}
@@ -0,0 +1,622 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// A collection export is one JSON document holding everything needed to rebuild a
+// collection: note types, deck options presets, decks, notes, cards with their FSRS
+// state, and the review log. IDs in the document only link its records together; an
+// import allocates new IDs, so the same export can be imported into any collection.
+//
+// Scheduling state and review history are the requesting user's. Media files are not
+// included; use a deck .apkg export or a backup to move them.
+
+const (
+	collectionExportFormat  = "microdote.collection"
+	collectionExportVersion = 1
+	// maxCollectionImportBytes bounds the request body of a collection import.
+	maxCollectionImportBytes = 256 << 20
+)
+
+// CollectionExport is the document returned by GET /api/collection/export and accepted
+// by POST /api/collection/import.
+type CollectionExport struct {
+	Format      string                    `json:"format"`  // always "microdote.collection"
+	Version     int                       `json:"version"` // format version, currently 1
+	ExportedAt  time.Time                 `json:"exportedAt"`
+	NoteTypes   []NoteType                `json:"noteTypes"`
+	DeckOptions []CollectionExportOptions `json:"deckOptions"`
+	Decks       []CollectionExportDeck    `json:"decks"`
+	Notes       []CollectionExportNote    `json:"notes"`
+	Cards       []CollectionExportCard    `json:"cards"`
+	Revlog      []CollectionExportReview  `json:"revlog"`
+}
+
+// CollectionExportOptions is a deck options preset.
+type CollectionExportOptions struct {
+	ID                 int64  `json:"id"`
+	Name               string `json:"name"`
+	NewCardsPerDay     int    `json:"newCardsPerDay"`
+	ReviewsPerDay      int    `json:"reviewsPerDay"`
+	LearningSteps      []int  `json:"learningSteps"` // minutes
+	GraduatingInterval int    `json:"graduatingInterval"`
+	EasyInterval       int    `json:"easyInterval"`
+}
+
+// CollectionExportDeck is a deck. The name is the full "Parent::Child" path.
+type CollectionExportDeck struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	ParentID      *int64 `json:"parentId,omitempty"`
+	OptionsID     *int64 `json:"optionsId,omitempty"` // a deckOptions id
+	PriorityOrder int    `json:"priorityOrder"`
+}
+
+// CollectionExportNote is a note. Type names a note type in the same document.
+type CollectionExportNote struct {
+	ID         int64             `json:"id"`
+	Type       NoteTypeName      `json:"type"`
+	Fields     map[string]string `json:"fields"`
+	Tags       []string          `json:"tags"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	ModifiedAt time.Time         `json:"modifiedAt"`
+}
+
+// CollectionExportCard is a card with its rendered sides and FSRS state.
+type CollectionExportCard struct {
+	ID           int64     `json:"id"`
+	NoteID       int64     `json:"noteId"`
+	DeckID       int64     `json:"deckId"`
+	TemplateName string    `json:"templateName"`
+	Ordinal      int       `json:"ordinal"`
+	Front        string    `json:"front"`
+	Back         string    `json:"back"`
+	SRS          fsrs.Card `json:"srs"`
+	Flag         int       `json:"flag"`
+	Marked       bool      `json:"marked"`
+	Suspended    bool      `json:"suspended"`
+}
+
+// CollectionExportReview is one review log entry.
+type CollectionExportReview struct {
+	CardID        int64     `json:"cardId"`
+	Rating        int       `json:"rating"`
+	State         int       `json:"state"`
+	Due           time.Time `json:"due"`
+	ReviewedAt    time.Time `json:"reviewedAt"`
+	TimeTakenMs   int       `json:"timeTakenMs"`
+	Kind          string    `json:"kind"`
+	Stability     *float64  `json:"stability,omitempty"`
+	Difficulty    *float64  `json:"difficulty,omitempty"`
+	ElapsedDays   int       `json:"elapsedDays"`
+	ScheduledDays int       `json:"scheduledDays"`
+}
+
+// CollectionImportResponse counts what an import created.
+type CollectionImportResponse struct {
+	NoteTypesCreated   []string `json:"noteTypesCreated"`
+	DecksCreated       []string `json:"decksCreated"`
+	DeckOptionsCreated int      `json:"deckOptionsCreated"`
+	Notes              int      `json:"notes"`
+	Cards              int      `json:"cards"`
+	Reviews            int      `json:"reviews"`
+}
+
+// ExportCollectionJSON handles GET /api/collection/export.
+func (h *APIHandler) ExportCollectionJSON(w http.ResponseWriter, r *http.Request) {
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	export, err := h.buildCollectionExport(r.Context(), collectionID, col, h.userIDFromRequest(r), time.Now())
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "export_failed", err.Error())
+		return
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "collection.json"}))
+	respondJSON(w, http.StatusOK, export)
+}
+
+func (h *APIHandler) buildCollectionExport(ctx context.Context, collectionID string, col *Collection, userID string, now time.Time) (*CollectionExport, error) {
+	export := &CollectionExport{
+		Format:      collectionExportFormat,
+		Version:     collectionExportVersion,
+		ExportedAt:  now.UTC(),
+		NoteTypes:   []NoteType{},
+		DeckOptions: []CollectionExportOptions{},
+		Decks:       []CollectionExportDeck{},
+		Notes:       []CollectionExportNote{},
+		Cards:       []CollectionExportCard{},
+		Revlog:      []CollectionExportReview{},
+	}
+
+	for _, nt := range col.NoteTypes {
+		export.NoteTypes = append(export.NoteTypes, nt)
+	}
+	sort.Slice(export.NoteTypes, func(i, j int) bool { return export.NoteTypes[i].Name < export.NoteTypes[j].Name })
+
+	seenOptions := map[int64]bool{}
+	for _, deck := range col.Decks {
+		export.Decks = append(export.Decks, CollectionExportDeck{
+			ID:            deck.ID,
+			Name:          deck.Name,
+			ParentID:      deck.ParentID,
+			OptionsID:     deck.OptionsID,
+			PriorityOrder: deck.PriorityOrder,
+		})
+		if deck.OptionsID == nil || seenOptions[*deck.OptionsID] {
+			continue
+		}
+		seenOptions[*deck.OptionsID] = true
+		options, err := h.store.GetDeckOptions(ctx, *deck.OptionsID)
+		if errors.Is(err, sql.ErrNoRows) {
+			export.Decks[len(export.Decks)-1].OptionsID = nil
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		export.DeckOptions = append(export.DeckOptions, CollectionExportOptions{
+			ID:                 options.ID,
+			Name:               options.Name,
+			NewCardsPerDay:     options.NewCardsPerDay,
+			ReviewsPerDay:      options.ReviewsPerDay,
+			LearningSteps:      append([]int{}, options.LearningSteps...),
+			GraduatingInterval: options.GraduatingInterval,
+			EasyInterval:       options.EasyInterval,
+		})
+	}
+	sort.Slice(export.Decks, func(i, j int) bool { return export.Decks[i].ID < export.Decks[j].ID })
+	sort.Slice(export.DeckOptions, func(i, j int) bool { return export.DeckOptions[i].ID < export.DeckOptions[j].ID })
+
+	for _, note := range col.Notes {
+		tags := note.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		export.Notes = append(export.Notes, CollectionExportNote{
+			ID:         note.ID,
+			Type:       note.Type,
+			Fields:     note.FieldMap,
+			Tags:       tags,
+			CreatedAt:  note.CreatedAt,
+			ModifiedAt: note.ModifiedAt,
+		})
+	}
+	sort.Slice(export.Notes, func(i, j int) bool { return export.Notes[i].ID < export.Notes[j].ID })
+
+	for _, stored := range col.Cards {
+		card := *stored
+		if err := h.store.applyReviewStateToCard(ctx, userID, &card); err != nil {
+			return nil, err
+		}
+		export.Cards = append(export.Cards, CollectionExportCard{
+			ID:           card.ID,
+			NoteID:       card.NoteID,
+			DeckID:       card.DeckID,
+			TemplateName: card.TemplateName,
+			Ordinal:      card.Ordinal,
+			Front:        card.Front,
+			Back:         card.Back,
+			SRS:          card.SRS,
+			Flag:         card.Flag,
+			Marked:       card.Marked,
+			Suspended:    card.Suspended,
+		})
+	}
+	sort.Slice(export.Cards, func(i, j int) bool { return export.Cards[i].ID < export.Cards[j].ID })
+
+	entries, err := h.store.ListRevlogEntriesForCollection(ctx, userID, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		export.Revlog = append(export.Revlog, CollectionExportReview{
+			CardID:        entry.CardID,
+			Rating:        entry.Rating,
+			State:         entry.State,
+			Due:           entry.Due.UTC(),
+			ReviewedAt:    entry.ReviewedAt.UTC(),
+			TimeTakenMs:   entry.TimeTakenMs,
+			Kind:          entry.Kind,
+			Stability:     entry.Stability,
+			Difficulty:    entry.Difficulty,
+			ElapsedDays:   entry.ElapsedDays,
+			ScheduledDays: entry.ScheduledDays,
+		})
+	}
+	return export, nil
+}
+
+// ImportCollectionJSON handles POST /api/collection/import. The body is a collection
+// export. Note types and decks that already exist by name are reused; everything else
+// is created. A note type that exists with different fields is a conflict, and nothing
+// is written unless the whole document is valid.
+func (h *APIHandler) ImportCollectionJSON(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxCollectionImportBytes)
+	var export CollectionExport
+	if !decodeValidatedRequest(w, r, &export) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	if err := validateCollectionExport(&export, col); err != nil {
+		var conflict *noteTypeConflictError
+		if errors.As(err, &conflict) {
+			respondAPIError(w, http.StatusConflict, "note_type_conflict", err.Error())
+			return
+		}
+		respondAPIError(w, http.StatusBadRequest, "invalid_collection", err.Error())
+		return
+	}
+
+	result, err := h.importCollectionExport(r.Context(), collectionID, col, &export, h.userIDFromRequest(r))
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "import_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, result)
+}
+
+type noteTypeConflictError struct {
+	Name NoteTypeName
+}
+
+func (e *noteTypeConflictError) Error() string {
+	return fmt.Sprintf("note type %q already exists with different fields", e.Name)
+}
+
+// Validate checks the document's format and version; references into the collection are
+// checked by validateCollectionExport.
+func (export *CollectionExport) Validate(v *requestValidator) {
+	v.check(export.Format == collectionExportFormat, "format", fmt.Sprintf("must be %q", collectionExportFormat))
+	v.intRange("version", export.Version, 1, collectionExportVersion)
+}
+
+// validateCollectionExport checks that every reference in the document resolves, so an
+// import never stops halfway through.
+func validateCollectionExport(export *CollectionExport, col *Collection) error {
+	noteTypes := map[NoteTypeName]bool{}
+	for _, nt := range export.NoteTypes {
+		if strings.TrimSpace(string(nt.Name)) == "" || len(nt.Fields) == 0 || len(nt.Templates) == 0 {
+			return fmt.Errorf("note type %q needs a name, fields and templates", nt.Name)
+		}
+		if existing, ok := col.NoteTypes[nt.Name]; ok && !slicesEqualFold(existing.Fields, nt.Fields) {
+			return &noteTypeConflictError{Name: nt.Name}
+		}
+		noteTypes[nt.Name] = true
+	}
+	options := map[int64]bool{}
+	for _, preset := range export.DeckOptions {
+		options[preset.ID] = true
+	}
+	decks := map[int64]bool{}
+	for _, deck := range export.Decks {
+		if strings.TrimSpace(deck.Name) == "" {
+			return fmt.Errorf("deck %d has no name", deck.ID)
+		}
+		if deck.OptionsID != nil && !options[*deck.OptionsID] {
+			return fmt.Errorf("deck %q refers to unknown deck options %d", deck.Name, *deck.OptionsID)
+		}
+		decks[deck.ID] = true
+	}
+	for _, deck := range export.Decks {
+		if deck.ParentID != nil && !decks[*deck.ParentID] {
+			return fmt.Errorf("deck %q refers to unknown parent deck %d", deck.Name, *deck.ParentID)
+		}
+	}
+	notes := map[int64]bool{}
+	for _, note := range export.Notes {
+		if _, ok := col.NoteTypes[note.Type]; !ok && !noteTypes[note.Type] {
+			return fmt.Errorf("note %d has unknown note type %q", note.ID, note.Type)
+		}
+		notes[note.ID] = true
+	}
+	cards := map[int64]bool{}
+	for _, card := range export.Cards {
+		if !notes[card.NoteID] {
+			return fmt.Errorf("card %d refers to unknown note %d", card.ID, card.NoteID)
+		}
+		if !decks[card.DeckID] {
+			return fmt.Errorf("card %d refers to unknown deck %d", card.ID, card.DeckID)
+		}
+		cards[card.ID] = true
+	}
+	for _, review := range export.Revlog {
+		if !cards[review.CardID] {
+			return fmt.Errorf("review log entry refers to unknown card %d", review.CardID)
+		}
+	}
+	return nil
+}
+
+func slicesEqualFold(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *APIHandler) importCollectionExport(ctx context.Context, collectionID string, col *Collection, export *CollectionExport, userID string) (CollectionImportResponse, error) {
+	result := CollectionImportResponse{NoteTypesCreated: []string{}, DecksCreated: []string{}}
+
+	for _, nt := range export.NoteTypes {
+		if _, ok := col.NoteTypes[nt.Name]; ok {
+			continue
+		}
+		noteType := nt
+		if err := h.store.CreateNoteType(ctx, collectionID, &noteType); err != nil {
+			return result, fmt.Errorf("note type %q: %w", nt.Name, err)
+		}
+		col.NoteTypes[nt.Name] = noteType
+		result.NoteTypesCreated = append(result.NoteTypesCreated, string(nt.Name))
+	}
+
+	optionsByID := make(map[int64]CollectionExportOptions, len(export.DeckOptions))
+	for _, preset := range export.DeckOptions {
+		optionsByID[preset.ID] = preset
+	}
+	createdOptions := map[int64]int64{}
+
+	// Parents are created before their children so ParentID can be remapped.
+	exportedDecks := make(map[int64]CollectionExportDeck, len(export.Decks))
+	for _, deck := range export.Decks {
+		exportedDecks[deck.ID] = deck
+	}
+	deckIDs := map[int64]int64{}
+	var importDeck func(deck CollectionExportDeck, depth int) (int64, error)
+	importDeck = func(deck CollectionExportDeck, depth int) (int64, error) {
+		if id, ok := deckIDs[deck.ID]; ok {
+			return id, nil
+		}
+		if depth > len(export.Decks) {
+			return 0, fmt.Errorf("deck %q is its own ancestor", deck.Name)
+		}
+		for id, existing := range col.Decks {
+			if strings.EqualFold(existing.Name, deck.Name) {
+				deckIDs[deck.ID] = id
+				return id, nil
+			}
+		}
+		var parentID *int64
+		if deck.ParentID != nil {
+			id, err := importDeck(exportedDecks[*deck.ParentID], depth+1)
+			if err != nil {
+				return 0, err
+			}
+			parentID = &id
+		}
+		newDeck := col.NewDeck(sanitizeHTML(deck.Name))
+		newDeck.ParentID = parentID
+		newDeck.PriorityOrder = deck.PriorityOrder
+		if deck.OptionsID != nil {
+			optionsID, ok := createdOptions[*deck.OptionsID]
+			if !ok {
+				preset := optionsByID[*deck.OptionsID]
+				options := &DeckOptions{
+					ID:                 time.Now().UnixNano(),
+					Name:               preset.Name,
+					NewCardsPerDay:     preset.NewCardsPerDay,
+					ReviewsPerDay:      preset.ReviewsPerDay,
+					LearningSteps:      preset.LearningSteps,
+					GraduatingInterval: preset.GraduatingInterval,
+					EasyInterval:       preset.EasyInterval,
+				}
+				if err := h.store.CreateDeckOptions(ctx, options); err != nil {
+					return 0, fmt.Errorf("deck options %q: %w", preset.Name, err)
+				}
+				optionsID = options.ID
+				createdOptions[*deck.OptionsID] = optionsID
+				result.DeckOptionsCreated++
+			}
+			newDeck.OptionsID = &optionsID
+		}
+		if err := h.store.CreateDeckInCollection(ctx, collectionID, newDeck); err != nil {
+			delete(col.Decks, newDeck.ID)
+			return 0, fmt.Errorf("deck %q: %w", deck.Name, err)
+		}
+		deckIDs[deck.ID] = newDeck.ID
+		result.DecksCreated = append(result.DecksCreated, newDeck.Name)
+		return newDeck.ID, nil
+	}
+	for _, deck := range export.Decks {
+		if _, err := importDeck(deck, 0); err != nil {
+			return result, err
+		}
+	}
+
+	noteIDs := map[int64]int64{}
+	for _, exported := range export.Notes {
+		fields := make(map[string]string, len(exported.Fields))
+		for name, value := range exported.Fields {
+			fields[name] = sanitizeHTML(value)
+		}
+		tags := sanitizeImportTags(exported.Tags)
+		if tags == nil {
+			tags = []string{}
+		}
+		col.USN++
+		note := Note{
+			ID:         col.nextNoteID,
+			Type:       exported.Type,
+			FieldMap:   fields,
+			Tags:       tags,
+			USN:        col.USN,
+			CreatedAt:  exported.CreatedAt,
+			ModifiedAt: exported.ModifiedAt,
+		}
+		col.nextNoteID++
+		if err := h.store.CreateNote(ctx, collectionID, &note); err != nil {
+			return result, fmt.Errorf("note %d: %w", exported.ID, err)
+		}
+		col.Notes[note.ID] = note
+		noteIDs[exported.ID] = note.ID
+		result.Notes++
+	}
+
+	cardIDs := map[int64]int64{}
+	for _, exported := range export.Cards {
+		card := &Card{
+			ID:           col.nextCardID,
+			NoteID:       noteIDs[exported.NoteID],
+			DeckID:       deckIDs[exported.DeckID],
+			TemplateName: exported.TemplateName,
+			Ordinal:      exported.Ordinal,
+			Front:        sanitizeHTML(exported.Front),
+			Back:         sanitizeHTML(exported.Back),
+			SRS:          exported.SRS,
+			Flag:         exported.Flag,
+			Marked:       exported.Marked,
+			Suspended:    exported.Suspended,
+			USN:          col.USN,
+		}
+		col.nextCardID++
+		if err := h.store.CreateCard(ctx, card); err != nil {
+			return result, fmt.Errorf("card %d: %w", exported.ID, err)
+		}
+		if err := h.store.UpdateCardReviewState(ctx, userID, card); err != nil {
+			return result, fmt.Errorf("card %d: %w", exported.ID, err)
+		}
+		col.Cards[card.ID] = card
+		if deck, ok := col.Decks[card.DeckID]; ok {
+			deck.Cards = append(deck.Cards, card.ID)
+		}
+		cardIDs[exported.ID] = card.ID
+		result.Cards++
+	}
+
+	entries := make([]RevlogEntry, 0, len(export.Revlog))
+	for _, review := range export.Revlog {
+		entries = append(entries, RevlogEntry{
+			CardID:        cardIDs[review.CardID],
+			Rating:        review.Rating,
+			State:         review.State,
+			Due:           review.Due,
+			ReviewedAt:    review.ReviewedAt,
+			TimeTakenMs:   review.TimeTakenMs,
+			Kind:          review.Kind,
+			Stability:     review.Stability,
+			Difficulty:    review.Difficulty,
+			ElapsedDays:   review.ElapsedDays,
+			ScheduledDays: review.ScheduledDays,
+		})
+	}
+	if err := h.store.ImportRevlogEntries(ctx, userID, entries); err != nil {
+		return result, fmt.Errorf("review log: %w", err)
+	}
+	result.Reviews = len(entries)
+	return result, nil
+}
+
+// ListRevlogEntriesForCollection returns every review log entry for the collection's
+// cards, oldest first. A blank userID returns entries from every user.
+func (s *SQLStore) ListRevlogEntriesForCollection(ctx context.Context, userID, collectionID string) ([]RevlogEntry, error) {
+	query := `
+		SELECT r.id, COALESCE(r.user_id, ''), r.card_id, r.rating, COALESCE(r.state, 0), COALESCE(r.due, 0), COALESCE(r.reviewed_at, 0), COALESCE(r.time_taken_ms, 0),
+			r.stability, r.difficulty, r.elapsed_days, r.scheduled_days, r.kind, r.next_state
+		FROM revlog r
+		JOIN cards c ON c.id = r.card_id
+		JOIN decks d ON d.id = c.deck_id
+		WHERE d.collection_id = ?
+	`
+	args := []interface{}{collectionID}
+	if strings.TrimSpace(userID) != "" {
+		query += ` AND r.user_id = ?`
+		args = append(args, userID)
+	}
+	query += ` ORDER BY r.reviewed_at, r.id`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []RevlogEntry{}
+	for rows.Next() {
+		var (
+			entry      RevlogEntry
+			due        int64
+			reviewedAt int64
+			stability  sql.NullFloat64
+			difficulty sql.NullFloat64
+			nextState  sql.NullInt64
+		)
+		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.CardID, &entry.Rating, &entry.State, &due, &reviewedAt, &entry.TimeTakenMs,
+			&stability, &difficulty, &entry.ElapsedDays, &entry.ScheduledDays, &entry.Kind, &nextState); err != nil {
+			return nil, err
+		}
+		if stability.Valid {
+			entry.Stability = &stability.Float64
+		}
+		if difficulty.Valid {
+			entry.Difficulty = &difficulty.Float64
+		}
+		if nextState.Valid {
+			state := int(nextState.Int64)
+			entry.NextState = &state
+		}
+		entry.Due = time.Unix(due, 0)
+		entry.ReviewedAt = time.Unix(reviewedAt, 0)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// ImportRevlogEntries inserts review log entries for userID in one transaction. Entry
+// IDs are reassigned.
+func (s *SQLStore) ImportRevlogEntries(ctx context.Context, userID string, entries []RevlogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	base := time.Now().UnixNano()
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		for i, entry := range entries {
+			var stability, difficulty, nextState interface{}
+			if entry.Stability != nil {
+				stability = *entry.Stability
+			}
+			if entry.Difficulty != nil {
+				difficulty = *entry.Difficulty
+			}
+			if entry.NextState != nil {
+				nextState = *entry.NextState
+			}
+			kind := entry.Kind
+			if kind == "" {
+				kind = reviewKindForState(fsrs.State(entry.State))
+			}
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO revlog (id, user_id, card_id, rating, state, due, reviewed_at, time_taken_ms, stability, difficulty, elapsed_days, scheduled_days, kind, next_state)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`, base+int64(i), nullIfEmpty(strings.TrimSpace(userID)), entry.CardID, entry.Rating, entry.State, entry.Due.Unix(), entry.ReviewedAt.Unix(),
+				entry.TimeTakenMs, stability, difficulty, entry.ElapsedDays, entry.ScheduledDays, kind, nextState); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAPI_CollectionJSONExportImport(t *testing.T) {
+	source := setupAPITestEnv(t)
+	rr := doJSONRequest(t, source.router, http.MethodPost, "/api/decks", CreateDeckRequest{Name: "Algorithms"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected deck create 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	deck := decodeJSON[DeckResponse](t, rr)
+	created := createNoteForTest(t, source, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    deck.ID,
+		FieldVals: map[string]string{"Front": "Quicksort average", "Back": "O(n log n)"},
+		Tags:      []string{"sorting"},
+	}, nil)
+	cardID := created.Cards[0].ID
+	if rr := doJSONRequest(t, source.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", cardID), AnswerCardRequest{Rating: 3, TimeTakenMs: 1200}); rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr = doRawRequest(source.router, http.MethodGet, "/api/collection/export", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected export 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	exported := decodeJSON[CollectionExport](t, rr)
+	if exported.Format != collectionExportFormat || exported.Version != collectionExportVersion {
+		t.Fatalf("unexpected format header %q v%d", exported.Format, exported.Version)
+	}
+	if len(exported.Notes) != 1 || len(exported.Cards) != 1 || len(exported.Revlog) != 1 {
+		t.Fatalf("expected one note, card and review, got %d/%d/%d", len(exported.Notes), len(exported.Cards), len(exported.Revlog))
+	}
+	if exported.Cards[0].SRS.Reps != 1 || exported.Revlog[0].TimeTakenMs != 1200 {
+		t.Fatalf("expected the review to be exported, got card %+v and revlog %+v", exported.Cards[0].SRS, exported.Revlog[0])
+	}
+
+	target := setupAPITestEnv(t)
+	rr = doJSONRequest(t, target.router, http.MethodPost, "/api/collection/import", exported)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected import 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	result := decodeJSON[CollectionImportResponse](t, rr)
+	if result.Notes != 1 || result.Cards != 1 || result.Reviews != 1 || !containsExact(result.DecksCreated, "Algorithms") {
+		t.Fatalf("unexpected import result %+v", result)
+	}
+
+	rr = doRawRequest(target.router, http.MethodGet, "/api/collection/export", "")
+	reimported := decodeJSON[CollectionExport](t, rr)
+	if len(reimported.Cards) != 1 || reimported.Cards[0].SRS.Reps != 1 || !reimported.Cards[0].SRS.Due.Equal(exported.Cards[0].SRS.Due) {
+		t.Fatalf("expected scheduling state to survive the round trip, got %+v", reimported.Cards)
+	}
+	if len(reimported.Revlog) != 1 || reimported.Revlog[0].CardID != reimported.Cards[0].ID {
+		t.Fatalf("expected the review to point at the imported card, got %+v", reimported.Revlog)
+	}
+	if len(reimported.Notes) != 1 || reimported.Notes[0].Fields["Back"] != "O(n log n)" || !containsExact(reimported.Notes[0].Tags, "sorting") {
+		t.Fatalf("unexpected imported note %+v", reimported.Notes)
+	}
+
+	conflicting := exported
+	conflicting.NoteTypes = append([]NoteType{}, exported.NoteTypes...)
+	for i, nt := range conflicting.NoteTypes {
+		if nt.Name == "Basic" {
+			conflicting.NoteTypes[i].Fields = []string{"Question", "Answer"}
+		}
+	}
+	if rr := doJSONRequest(t, target.router, http.MethodPost, "/api/collection/import", conflicting); rr.Code != http.StatusConflict {
+		t.Fatalf("expected note type conflict 409, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	broken := exported
+	broken.Cards = []CollectionExportCard{{ID: 1, NoteID: 999, DeckID: exported.Decks[0].ID}}
+	if rr := doJSONRequest(t, target.router, http.MethodPost, "/api/collection/import", broken); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected dangling card 400, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
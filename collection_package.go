@@ -0,0 +1,369 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// A collection package is a portable zip of one collection, unlike the local backups
+// CreateBackup makes, which copy the whole server database file. It holds:
+//
+//	manifest.json    format, version, collection, schema version, counts and media list
+//	collection.db    a SQLite database in this app's schema holding only the collection
+//	media/<filename> every media file of the collection
+//
+// collection.db keeps the collection's IDs. Scheduling state and review history are the
+// requesting user's, written to the cards and revlog tables with no user attached, so
+// the package does not depend on accounts on the server that made it. Media files are
+// kept out of collection.db so they can be inspected and copied directly; the manifest
+// lists each with its size and SHA-256.
+
+const (
+	collectionPackageFormat  = "microdote.colpkg"
+	collectionPackageVersion = 1
+)
+
+// CollectionPackageManifest is manifest.json.
+type CollectionPackageManifest struct {
+	Format        string                   `json:"format"`  // always "microdote.colpkg"
+	Version       int                      `json:"version"` // package format version, currently 1
+	CreatedAt     time.Time                `json:"createdAt"`
+	CollectionID  string                   `json:"collectionId"`
+	SchemaVersion int                      `json:"schemaVersion"` // migration version of collection.db
+	Database      string                   `json:"database"`      // path of the database in the zip
+	Counts        CollectionPackageCounts  `json:"counts"`
+	Media         []CollectionPackageMedia `json:"media"`
+}
+
+// CollectionPackageCounts summarises what collection.db holds.
+type CollectionPackageCounts struct {
+	NoteTypes int `json:"noteTypes"`
+	Decks     int `json:"decks"`
+	Notes     int `json:"notes"`
+	Cards     int `json:"cards"`
+	Reviews   int `json:"reviews"`
+	Media     int `json:"media"`
+}
+
+// CollectionPackageMedia describes one media file in the package.
+type CollectionPackageMedia struct {
+	Filename string `json:"filename"`
+	Path     string `json:"path"` // path in the zip
+	Size     int    `json:"size"`
+	SHA256   string `json:"sha256"`
+}
+
+// WriteCollectionPackage writes a package of the collection to w. userID selects whose
+// scheduling state and review history are included.
+func (bm *BackupManager) WriteCollectionPackage(ctx context.Context, w io.Writer, collectionID, userID string) (*CollectionPackageManifest, error) {
+	col, err := bm.store.GetCollection(ctx, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load collection: %w", err)
+	}
+
+	tempDir, err := os.MkdirTemp("", "microdote-colpkg-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	dbPath := filepath.Join(tempDir, "collection.db")
+	manifest, err := bm.writePackageDatabase(ctx, dbPath, collectionID, col, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	zipWriter := zip.NewWriter(w)
+	if err := bm.addFileToZip(zipWriter, dbPath, manifest.Database, nil); err != nil {
+		return nil, fmt.Errorf("failed to add database to package: %w", err)
+	}
+
+	filenames, err := bm.store.ListMediaFilenames(ctx, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list media: %w", err)
+	}
+	manifest.Media = []CollectionPackageMedia{}
+	for _, filename := range filenames {
+		media, err := bm.store.GetCollectionMedia(ctx, collectionID, filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read media %s: %w", filename, err)
+		}
+		entry := CollectionPackageMedia{Filename: filename, Path: "media/" + filename, Size: len(media.Data)}
+		sum := sha256.Sum256(media.Data)
+		entry.SHA256 = hex.EncodeToString(sum[:])
+		writer, err := zipWriter.Create(entry.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add media %s: %w", filename, err)
+		}
+		if _, err := writer.Write(media.Data); err != nil {
+			return nil, fmt.Errorf("failed to add media %s: %w", filename, err)
+		}
+		manifest.Media = append(manifest.Media, entry)
+	}
+	manifest.Counts.Media = len(manifest.Media)
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	writer, err := zipWriter.Create("manifest.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create manifest: %w", err)
+	}
+	if _, err := writer.Write(manifestJSON); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := zipWriter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish package: %w", err)
+	}
+	return manifest, nil
+}
+
+// writePackageDatabase creates a fresh database at dbPath and copies the collection into
+// it, parents before children so foreign keys hold.
+func (bm *BackupManager) writePackageDatabase(ctx context.Context, dbPath, collectionID string, col *Collection, userID string) (*CollectionPackageManifest, error) {
+	pkg, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create package database: %w", err)
+	}
+	defer pkg.Close()
+
+	manifest := &CollectionPackageManifest{
+		Format:       collectionPackageFormat,
+		Version:      collectionPackageVersion,
+		CreatedAt:    time.Now().UTC(),
+		CollectionID: collectionID,
+		Database:     "collection.db",
+	}
+	if manifest.SchemaVersion, err = pkg.getSchemaVersion(); err != nil {
+		return nil, fmt.Errorf("failed to read package schema version: %w", err)
+	}
+
+	name, err := bm.store.GetCollectionName(ctx, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load collection name: %w", err)
+	}
+	if err := pkg.CreateCollectionRecord(ctx, collectionID, name, col); err != nil {
+		return nil, fmt.Errorf("failed to write collection: %w", err)
+	}
+
+	for _, nt := range col.NoteTypes {
+		noteType := nt
+		if err := pkg.CreateNoteType(ctx, collectionID, &noteType); err != nil {
+			return nil, fmt.Errorf("failed to write note type %q: %w", nt.Name, err)
+		}
+		manifest.Counts.NoteTypes++
+	}
+
+	written := map[int64]bool{}
+	var writeDeck func(deck *Deck) error
+	writeDeck = func(deck *Deck) error {
+		if written[deck.ID] {
+			return nil
+		}
+		written[deck.ID] = true
+		if deck.ParentID != nil {
+			if parent, ok := col.Decks[*deck.ParentID]; ok {
+				if err := writeDeck(parent); err != nil {
+					return err
+				}
+			}
+		}
+		copied := *deck
+		if copied.ParentID != nil && col.Decks[*copied.ParentID] == nil {
+			copied.ParentID = nil
+		}
+		if copied.OptionsID != nil {
+			options, err := bm.store.GetDeckOptions(ctx, *copied.OptionsID)
+			if err != nil {
+				copied.OptionsID = nil
+			} else if _, err := pkg.GetDeckOptions(ctx, options.ID); err != nil {
+				if err := pkg.CreateDeckOptions(ctx, options); err != nil {
+					return fmt.Errorf("failed to write options for deck %q: %w", deck.Name, err)
+				}
+			}
+		}
+		if err := pkg.CreateDeckInCollection(ctx, collectionID, &copied); err != nil {
+			return fmt.Errorf("failed to write deck %q: %w", deck.Name, err)
+		}
+		manifest.Counts.Decks++
+		return nil
+	}
+	deckIDs := make([]int64, 0, len(col.Decks))
+	for id := range col.Decks {
+		deckIDs = append(deckIDs, id)
+	}
+	sort.Slice(deckIDs, func(i, j int) bool { return deckIDs[i] < deckIDs[j] })
+	for _, id := range deckIDs {
+		if err := writeDeck(col.Decks[id]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, note := range col.Notes {
+		copied := note
+		if err := pkg.CreateNote(ctx, collectionID, &copied); err != nil {
+			return nil, fmt.Errorf("failed to write note %d: %w", note.ID, err)
+		}
+		manifest.Counts.Notes++
+	}
+
+	for _, stored := range col.Cards {
+		card := *stored
+		if err := bm.store.applyReviewStateToCard(ctx, userID, &card); err != nil {
+			return nil, fmt.Errorf("failed to load review state of card %d: %w", card.ID, err)
+		}
+		if err := pkg.CreateCard(ctx, &card); err != nil {
+			return nil, fmt.Errorf("failed to write card %d: %w", card.ID, err)
+		}
+		manifest.Counts.Cards++
+	}
+
+	reviews, err := bm.store.ListRevlogEntriesForCollection(ctx, userID, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load review log: %w", err)
+	}
+	if err := pkg.ImportRevlogEntries(ctx, "", reviews); err != nil {
+		return nil, fmt.Errorf("failed to write review log: %w", err)
+	}
+	manifest.Counts.Reviews = len(reviews)
+	return manifest, nil
+}
+
+// DownloadCollectionPackage handles GET /api/collection/package.
+func (h *APIHandler) DownloadCollectionPackage(w http.ResponseWriter, r *http.Request) {
+	// The package is built in a temp file so a failure can still be reported as an error
+	// instead of a truncated download.
+	file, err := os.CreateTemp("", "microdote-colpkg-*.zip")
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "package_failed", err.Error())
+		return
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	manifest, err := h.backupManager.WriteCollectionPackage(r.Context(), file, h.collectionIDForRequest(r), h.userIDFromRequest(r))
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "package_failed", err.Error())
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "package_failed", err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("microdote-collection-%s.colpkg.zip", manifest.CreatedAt.Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	http.ServeContent(w, r, filename, manifest.CreatedAt, file)
+}
+
+// parseCollectionPackageImport reads the notes of a collection package for the import
+// pipeline. Each note goes to the deck of its first card; scheduling state stays behind,
+// as it does for shared Anki decks.
+func parseCollectionPackageImport(data []byte, opts importParseOptions) ([]importNormalizedNote, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package: %w", err)
+	}
+	var manifest CollectionPackageManifest
+	var dbEntry *zip.File
+	for _, file := range zr.File {
+		switch file.Name {
+		case "manifest.json":
+			content, err := readZipEntry(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read package manifest: %w", err)
+			}
+			if err := json.Unmarshal(content, &manifest); err != nil {
+				return nil, fmt.Errorf("invalid package manifest: %w", err)
+			}
+		case "collection.db":
+			dbEntry = file
+		}
+	}
+	if manifest.Format != collectionPackageFormat || manifest.Version > collectionPackageVersion {
+		return nil, fmt.Errorf("not a supported collection package (format %q, version %d)", manifest.Format, manifest.Version)
+	}
+	if dbEntry == nil {
+		return nil, errors.New("collection package is missing collection.db")
+	}
+
+	tempDir, err := os.MkdirTemp("", "microdote-colpkg-import-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+	content, err := readZipEntry(dbEntry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collection database: %w", err)
+	}
+	dbPath := filepath.Join(tempDir, "collection.db")
+	if err := os.WriteFile(dbPath, content, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write temp collection database: %w", err)
+	}
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open collection database: %w", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT nt.name, n.field_vals, COALESCE(n.tags, '[]'),
+			COALESCE((SELECT d.name FROM cards c JOIN decks d ON d.id = c.deck_id WHERE c.note_id = n.id ORDER BY c.ordinal, c.id LIMIT 1), '')
+		FROM notes n
+		JOIN note_types nt ON nt.id = n.type_id
+		ORDER BY n.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package notes: %w", err)
+	}
+	defer rows.Close()
+
+	var out []importNormalizedNote
+	for rows.Next() {
+		var noteType, fieldsJSON, tagsJSON, deckName string
+		if err := rows.Scan(&noteType, &fieldsJSON, &tagsJSON, &deckName); err != nil {
+			return nil, fmt.Errorf("failed to read package note: %w", err)
+		}
+		note := importNormalizedNote{
+			DeckName: firstNonEmpty(deckName, opts.DefaultDeckName),
+			NoteType: NoteTypeName(noteType),
+		}
+		if err := json.Unmarshal([]byte(fieldsJSON), &note.Fields); err != nil {
+			return nil, fmt.Errorf("invalid fields in package note: %w", err)
+		}
+		_ = json.Unmarshal([]byte(tagsJSON), &note.Tags)
+		out = append(out, note)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read package notes: %w", err)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no notes found in collection package")
+	}
+	return out, nil
+}
+
+func readZipEntry(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAPI_DownloadCollectionPackage(t *testing.T) {
+	env := setupAPITestEnv(t)
+	var collectionID string
+	if err := env.store.db.QueryRow("SELECT collection_id FROM decks WHERE id = 1").Scan(&collectionID); err != nil {
+		t.Fatalf("collection lookup failed: %v", err)
+	}
+	if err := env.store.AddMedia(context.Background(), collectionID, &MediaRef{Filename: "tree.png", Data: []byte("PNG tree"), AddedAt: time.Now()}); err != nil {
+		t.Fatalf("add media failed: %v", err)
+	}
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/decks", CreateDeckRequest{Name: "Graphs"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected deck create 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	deck := decodeJSON[DeckResponse](t, rr)
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    deck.ID,
+		FieldVals: map[string]string{"Front": `Tree <img src="tree.png">`, "Back": "Connected acyclic graph"},
+	}, nil)
+	cardID := created.Cards[0].ID
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", cardID), AnswerCardRequest{Rating: 3}); rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr = doRawRequest(env.router, http.MethodGet, "/api/collection/package", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected package 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/zip" {
+		t.Fatalf("unexpected Content-Type %q", got)
+	}
+
+	data := rr.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("failed to open package: %v", err)
+	}
+	files := map[string][]byte{}
+	for _, file := range zr.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", file.Name, err)
+		}
+		files[file.Name], err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", file.Name, err)
+		}
+	}
+	if string(files["media/tree.png"]) != "PNG tree" {
+		t.Fatalf("expected media file in package, got entries %v", len(files))
+	}
+
+	var manifest CollectionPackageManifest
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+		t.Fatalf("failed to parse manifest: %v", err)
+	}
+	if manifest.Format != collectionPackageFormat || manifest.CollectionID != collectionID || manifest.SchemaVersion == 0 {
+		t.Fatalf("unexpected manifest header %+v", manifest)
+	}
+	if manifest.Counts.Decks != 2 || manifest.Counts.Notes != 1 || manifest.Counts.Cards != 1 || manifest.Counts.Reviews != 1 || manifest.Counts.Media != 1 {
+		t.Fatalf("unexpected manifest counts %+v", manifest.Counts)
+	}
+	if len(manifest.Media) != 1 || manifest.Media[0].Path != "media/tree.png" || len(manifest.Media[0].SHA256) != 64 {
+		t.Fatalf("unexpected manifest media %+v", manifest.Media)
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "collection.db")
+	if err := os.WriteFile(dbPath, files["collection.db"], 0o600); err != nil {
+		t.Fatalf("failed to write packaged database: %v", err)
+	}
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open packaged database: %v", err)
+	}
+	defer db.Close()
+	var state, reviews, others int
+	if err := db.QueryRow(`SELECT state FROM cards WHERE id = ?`, cardID).Scan(&state); err != nil {
+		t.Fatalf("expected the card under its own ID: %v", err)
+	}
+	if state == 0 {
+		t.Fatalf("expected the answered card to carry its review state")
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM revlog WHERE card_id = ? AND user_id IS NULL`, cardID).Scan(&reviews); err != nil || reviews != 1 {
+		t.Fatalf("expected one unattributed review, got %d (%v)", reviews, err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM collections WHERE id != ?`, collectionID).Scan(&others); err != nil || others != 0 {
+		t.Fatalf("expected only the exported collection, got %d others (%v)", others, err)
+	}
+}
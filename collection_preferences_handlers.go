@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// UpdatePreferencesRequest patches collection preferences; omitted fields are unchanged.
+// Vacation settings have their own endpoint because changing them reschedules cards.
+type UpdatePreferencesRequest struct {
+	InterleaveMode  *string `json:"interleaveMode,omitempty"`
+	NextDayStartsAt *int    `json:"nextDayStartsAt,omitempty"`
+	ReviewsPerDay   *int    `json:"reviewsPerDay,omitempty"`
+	MinutesPerDay   *int    `json:"minutesPerDay,omitempty"`
+}
+
+func (req *UpdatePreferencesRequest) Validate(v *requestValidator) {
+	if req.InterleaveMode != nil {
+		v.check(isValidInterleaveMode(strings.TrimSpace(*req.InterleaveMode)), "interleaveMode", "must be round_robin or proportional")
+	}
+	if req.NextDayStartsAt != nil {
+		v.intRange("nextDayStartsAt", *req.NextDayStartsAt, 0, 23)
+	}
+	if req.ReviewsPerDay != nil {
+		v.intRange("reviewsPerDay", *req.ReviewsPerDay, 0, maxDailyReviewsGoal)
+	}
+	if req.MinutesPerDay != nil {
+		v.intRange("minutesPerDay", *req.MinutesPerDay, 0, maxDailyMinutesGoal)
+	}
+}
+
+func (h *APIHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	prefs, err := h.store.GetCollectionPreferences(r.Context(), h.collectionIDForRequest(r))
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "preferences_load_failed", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, prefs)
+}
+
+func (h *APIHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+
+	var req UpdatePreferencesRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+
+	collectionID := h.collectionIDForRequest(r)
+	prefs, err := h.store.GetCollectionPreferences(r.Context(), collectionID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "preferences_load_failed", err.Error())
+		return
+	}
+
+	if req.InterleaveMode != nil {
+		prefs.Study.InterleaveMode = strings.TrimSpace(*req.InterleaveMode)
+	}
+	if req.NextDayStartsAt != nil {
+		prefs.Study.NextDayStartsAt = *req.NextDayStartsAt
+	}
+	if req.ReviewsPerDay != nil {
+		prefs.Goals.ReviewsPerDay = *req.ReviewsPerDay
+	}
+	if req.MinutesPerDay != nil {
+		prefs.Goals.MinutesPerDay = *req.MinutesPerDay
+	}
+
+	if err := h.store.SaveCollectionPreferences(r.Context(), collectionID, prefs); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "preferences_update_failed", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, prefs)
+}
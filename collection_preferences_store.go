@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// CollectionPreferences holds per-collection study settings that are not tied to a
+// single deck options preset. Stored as a JSON document so new settings can be added
+// without a migration for each one.
+type CollectionPreferences struct {
+	Vacation VacationSettings `json:"vacation"`
+	Study    StudyPreferences `json:"study"`
+	Goals    DailyGoals       `json:"goals"`
+}
+
+// DailyGoals are the study targets for each day; 0 leaves a goal unset.
+type DailyGoals struct {
+	ReviewsPerDay int `json:"reviewsPerDay"`
+	MinutesPerDay int `json:"minutesPerDay"`
+}
+
+// StudyPreferences controls how the combined study queue is assembled.
+type StudyPreferences struct {
+	InterleaveMode string `json:"interleaveMode"`
+	// NextDayStartsAt is the local hour (0-23) a new study day begins.
+	NextDayStartsAt int `json:"nextDayStartsAt"`
+}
+
+func defaultCollectionPreferences() CollectionPreferences {
+	return CollectionPreferences{
+		Vacation: VacationSettings{
+			SpreadDays: defaultVacationSpreadDays,
+		},
+		Study: StudyPreferences{
+			InterleaveMode:  interleaveRoundRobin,
+			NextDayStartsAt: defaultNextDayStartsAt,
+		},
+	}
+}
+
+func (s *SQLStore) GetCollectionPreferences(ctx context.Context, collectionID string) (CollectionPreferences, error) {
+	prefs := defaultCollectionPreferences()
+	if strings.TrimSpace(collectionID) == "" {
+		collectionID = defaultCollectionID
+	}
+
+	var raw string
+	err := s.db.QueryRowContext(ctx, `SELECT preferences FROM collection_preferences WHERE collection_id = ?`, collectionID).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return prefs, nil
+	}
+	if err != nil {
+		return prefs, err
+	}
+	if strings.TrimSpace(raw) == "" {
+		return prefs, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &prefs); err != nil {
+		return defaultCollectionPreferences(), err
+	}
+	return prefs, nil
+}
+
+func (s *SQLStore) SaveCollectionPreferences(ctx context.Context, collectionID string, prefs CollectionPreferences) error {
+	if strings.TrimSpace(collectionID) == "" {
+		collectionID = defaultCollectionID
+	}
+	encoded, err := json.Marshal(prefs)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO collection_preferences (collection_id, preferences, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(collection_id) DO UPDATE SET preferences = excluded.preferences, updated_at = excluded.updated_at
+	`, collectionID, string(encoded), time.Now().Unix())
+	return err
+}
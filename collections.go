@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// A request works in its workspace's collection unless it names another with
+// ?collectionId=. Besides their workspace's collection, users may use the collections
+// they created through POST /api/collections.
+const (
+	collectionContextKey    contextKey = "vutadex_collection"
+	maxCollectionNameLength            = 100
+)
+
+// CollectionSummary describes a collection the caller may use. Workspace marks their
+// workspace's collection, which requests use by default and which cannot be deleted.
+type CollectionSummary struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Workspace bool      `json:"workspace"`
+	DeckCount int       `json:"deckCount"`
+	NoteCount int       `json:"noteCount"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type CreateCollectionRequest struct {
+	Name string `json:"name"`
+}
+
+func (req *CreateCollectionRequest) Validate(v *requestValidator) {
+	v.required("name", req.Name)
+	v.maxLength("name", strings.TrimSpace(req.Name), maxCollectionNameLength)
+}
+
+// createStarterCollection creates a collection with the built-in note types and a
+// Default deck.
+func (s *SQLStore) createStarterCollection(ctx context.Context, collectionID, name string) error {
+	collection := NewCollection()
+	if err := s.CreateCollectionRecord(ctx, collectionID, name, collection); err != nil {
+		return err
+	}
+	seedCollection, err := s.GetCollection(ctx, collectionID)
+	if err != nil {
+		return err
+	}
+	for _, nt := range builtins() {
+		ntCopy := nt
+		if err := s.CreateNoteType(ctx, collectionID, &ntCopy); err != nil {
+			return err
+		}
+	}
+	return s.CreateDeckInCollection(ctx, collectionID, seedCollection.NewDeck("Default"))
+}
+
+func (s *SQLStore) SetCollectionOwner(ctx context.Context, collectionID, userID string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE collections SET owner_user_id = ? WHERE id = ?`, nullIfEmpty(userID), collectionID)
+	return err
+}
+
+// GetCollectionOwner returns the user who created the collection over the API, or ""
+// for a workspace or profile collection.
+func (s *SQLStore) GetCollectionOwner(ctx context.Context, collectionID string) (string, error) {
+	var owner sql.NullString
+	if err := s.db.QueryRowContext(ctx, `SELECT owner_user_id FROM collections WHERE id = ?`, collectionID).Scan(&owner); err != nil {
+		return "", err
+	}
+	return owner.String, nil
+}
+
+// GetCollectionName returns the collection's name.
+func (s *SQLStore) GetCollectionName(ctx context.Context, collectionID string) (string, error) {
+	var name string
+	err := s.db.QueryRowContext(ctx, `SELECT name FROM collections WHERE id = ?`, collectionID).Scan(&name)
+	return name, err
+}
+
+// ListCollectionSummaries describes the workspace collection, when there is one, then
+// the collections the user owns, oldest first.
+func (s *SQLStore) ListCollectionSummaries(ctx context.Context, workspaceCollectionID, userID string) ([]CollectionSummary, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT c.id, c.name, c.created_at,
+		       (SELECT COUNT(*) FROM decks d WHERE d.collection_id = c.id),
+		       (SELECT COUNT(*) FROM notes n WHERE n.collection_id = c.id)
+		FROM collections c
+		WHERE c.id = ? OR c.owner_user_id = ?
+		ORDER BY c.id = ? DESC, c.created_at, c.id
+	`, workspaceCollectionID, userID, workspaceCollectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := []CollectionSummary{}
+	for rows.Next() {
+		var summary CollectionSummary
+		var createdAt int64
+		if err := rows.Scan(&summary.ID, &summary.Name, &createdAt, &summary.DeckCount, &summary.NoteCount); err != nil {
+			return nil, err
+		}
+		summary.CreatedAt = time.Unix(createdAt, 0)
+		summary.Workspace = summary.ID == workspaceCollectionID
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
+
+// collectionInUse reports whether a workspace or profile is built on the collection.
+func (s *SQLStore) collectionInUse(ctx context.Context, collectionID string) (bool, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM workspaces WHERE collection_id = ?) +
+		       (SELECT COUNT(*) FROM profiles WHERE collection_id = ?)
+	`, collectionID, collectionID).Scan(&count)
+	return count > 0, err
+}
+
+// DeleteCollection removes a collection and everything in it in one transaction.
+// Review history and per-user review states go with the cards.
+func (s *SQLStore) DeleteCollection(ctx context.Context, collectionID string) error {
+	statements := []string{
+		`DELETE FROM revlog WHERE card_id IN (
+			SELECT c.id FROM cards c JOIN notes n ON n.id = c.note_id WHERE n.collection_id = ?
+		)`,
+		`DELETE FROM cards WHERE note_id IN (SELECT id FROM notes WHERE collection_id = ?)`,
+		`DELETE FROM notes WHERE collection_id = ?`,
+		`DELETE FROM decks WHERE collection_id = ?`,
+		`DELETE FROM deck_options WHERE collection_id = ?`,
+		`DELETE FROM note_types WHERE collection_id = ?`,
+		`DELETE FROM media WHERE collection_id = ?`,
+		`DELETE FROM change_log WHERE collection_id = ?`,
+		`DELETE FROM oplog WHERE collection_id = ?`,
+		`DELETE FROM oplog_refs WHERE collection_id = ?`,
+		`DELETE FROM collections WHERE id = ?`,
+	}
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement, collectionID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// workspaceCollectionID is the collection of the session's workspace, or the handler's
+// collection when the session has none.
+func (h *APIHandler) workspaceCollectionID(ctx context.Context, session *SessionRecord) string {
+	if workspace, err := h.workspaceForSession(ctx, session); err == nil && workspace != nil && strings.TrimSpace(workspace.CollectionID) != "" {
+		return workspace.CollectionID
+	}
+	return h.collectionID
+}
+
+// canUseCollection reports whether the session may work in the collection: its
+// workspace's collection or one its user created.
+func (h *APIHandler) canUseCollection(ctx context.Context, session *SessionRecord, collectionID string) (bool, error) {
+	if collectionID == h.workspaceCollectionID(ctx, session) {
+		return true, nil
+	}
+	owner, err := h.store.GetCollectionOwner(ctx, collectionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return session != nil && owner != "" && owner == session.UserID, nil
+}
+
+// SelectRequestedCollection routes a request with ?collectionId= to that collection,
+// refusing collections the caller may not use.
+func (h *APIHandler) SelectRequestedCollection(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		collectionID := strings.TrimSpace(r.URL.Query().Get("collectionId"))
+		if collectionID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		allowed, err := h.canUseCollection(r.Context(), h.sessionFromRequest(r), collectionID)
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "collection_access_failed", err.Error())
+			return
+		}
+		if !allowed {
+			respondAPIError(w, http.StatusNotFound, "collection_not_found", "Collection not found")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), collectionContextKey, collectionID)))
+	})
+}
+
+// ListCollections serves GET /api/collections.
+func (h *APIHandler) ListCollections(w http.ResponseWriter, r *http.Request) {
+	session := h.sessionFromRequest(r)
+	summaries, err := h.store.ListCollectionSummaries(r.Context(), h.workspaceCollectionID(r.Context(), session), session.UserID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collections_list_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, summaries)
+}
+
+// CreateCollection serves POST /api/collections, creating a collection owned by the
+// caller with the built-in note types and a Default deck.
+func (h *APIHandler) CreateCollection(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	var req CreateCollectionRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+
+	session := h.sessionFromRequest(r)
+	created := CollectionSummary{ID: newID("col"), Name: sanitizeHTML(name), DeckCount: 1, CreatedAt: time.Now()}
+	if err := h.store.createStarterCollection(r.Context(), created.ID, created.Name); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_create_failed", err.Error())
+		return
+	}
+	if err := h.store.SetCollectionOwner(r.Context(), created.ID, session.UserID); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_create_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusCreated, created)
+}
+
+// DeleteCollection serves DELETE /api/collections/{collectionId}. Only the collections
+// a user created may be deleted, and never one a workspace or profile is built on.
+func (h *APIHandler) DeleteCollection(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	session := h.sessionFromRequest(r)
+	collectionID := chi.URLParam(r, "collectionId")
+	owner, err := h.store.GetCollectionOwner(r.Context(), collectionID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && owner != session.UserID) {
+		respondAPIError(w, http.StatusNotFound, "collection_not_found", "Collection not found")
+		return
+	}
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_delete_failed", err.Error())
+		return
+	}
+	inUse, err := h.store.collectionInUse(r.Context(), collectionID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_delete_failed", err.Error())
+		return
+	}
+	if inUse || collectionID == h.collectionID {
+		respondAPIError(w, http.StatusConflict, "collection_in_use", "This collection belongs to a workspace or profile")
+		return
+	}
+	if err := h.store.DeleteCollection(r.Context(), collectionID); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_delete_failed", err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateCollectionHonorsID(t *testing.T) {
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "collections.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	col := NewCollection()
+	col.ID, col.Name = "spanish", "Spanish"
+	if err := store.CreateCollection(context.Background(), col); err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	loaded, err := store.GetCollection(context.Background(), "spanish")
+	if err != nil {
+		t.Fatalf("expected the collection under its own ID: %v", err)
+	}
+	if loaded.ID != "spanish" || loaded.Name != "Spanish" {
+		t.Fatalf("expected the stored ID and name, got %q %q", loaded.ID, loaded.Name)
+	}
+	if _, err := store.GetCollection(context.Background(), defaultCollectionID); err == nil {
+		t.Fatalf("expected no default collection to be created")
+	}
+}
+
+func TestAPI_CollectionsCanBeCreatedUsedAndDeleted(t *testing.T) {
+	env := setupAPITestEnv(t)
+	other := createAuthenticatedIsolatedTestClient(t, env, "other@example.com", "Other")
+
+	createRR := doJSONRequest(t, env.router, http.MethodPost, "/api/collections", CreateCollectionRequest{Name: "Spanish"})
+	if createRR.Code != http.StatusCreated {
+		t.Fatalf("expected create collection 201, got %d (%s)", createRR.Code, createRR.Body.String())
+	}
+	spanish := decodeJSON[CollectionSummary](t, createRR)
+	if spanish.ID == "" || spanish.Name != "Spanish" || spanish.Workspace || spanish.DeckCount != 1 {
+		t.Fatalf("unexpected new collection: %+v", spanish)
+	}
+
+	collections := decodeJSON[[]CollectionSummary](t, doRawRequest(env.router, http.MethodGet, "/api/collections", ""))
+	if len(collections) != 2 || !collections[0].Workspace || collections[0].ID != defaultCollectionID || collections[1].ID != spanish.ID {
+		t.Fatalf("expected the workspace collection and the new one, got %+v", collections)
+	}
+
+	decks := decodeJSON[[]DeckResponse](t, doRawRequest(env.router, http.MethodGet, "/api/decks?collectionId="+spanish.ID, ""))
+	if len(decks) != 1 || decks[0].Name != "Default" {
+		t.Fatalf("expected the new collection's Default deck, got %+v", decks)
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/notes?collectionId="+spanish.ID, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    decks[0].ID,
+		FieldVals: map[string]string{"Front": "Hola", "Back": "Hello"},
+	}); rr.Code != http.StatusCreated {
+		t.Fatalf("expected create note 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	collections = decodeJSON[[]CollectionSummary](t, doRawRequest(env.router, http.MethodGet, "/api/collections", ""))
+	if collections[0].NoteCount != 0 || collections[1].NoteCount != 1 {
+		t.Fatalf("expected the note in the new collection only, got %+v", collections)
+	}
+
+	if rr := doRawRequest(other.router, http.MethodGet, "/api/decks?collectionId="+spanish.ID, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected another user to be refused the collection, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doRawRequest(other.router, http.MethodDelete, "/api/collections/"+spanish.ID, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected another user's delete to 404, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doRawRequest(env.router, http.MethodDelete, "/api/collections/"+defaultCollectionID, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected the workspace collection to be kept, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	if rr := doRawRequest(env.router, http.MethodDelete, "/api/collections/"+spanish.ID, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected delete 204, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if collections := decodeJSON[[]CollectionSummary](t, doRawRequest(env.router, http.MethodGet, "/api/collections", "")); len(collections) != 1 {
+		t.Fatalf("expected only the workspace collection, got %+v", collections)
+	}
+	var notes int
+	if err := env.store.db.QueryRow(`SELECT COUNT(*) FROM notes WHERE collection_id = ?`, spanish.ID).Scan(&notes); err != nil || notes != 0 {
+		t.Fatalf("expected the collection's notes to be deleted, got %d (%v)", notes, err)
+	}
+}
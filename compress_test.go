@@ -0,0 +1,45 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestServerGzipsResponsesForClientsThatAcceptIt(t *testing.T) {
+	env := setupAPITestEnv(t)
+	server := NewServer(mustLocalAppConfig(), env.handler, fstest.MapFS{
+		"index.html": &fstest.MapFile{Data: []byte("<html></html>")},
+	})
+	for i := 0; i < 8; i++ {
+		createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": strings.Repeat("<b>front</b> ", 20), "Back": "back"},
+		}, nil)
+	}
+
+	rr := doRawRequestWithHeaders(server, http.MethodGet, "/api/notes", "", map[string]string{"Cookie": env.authCookie, "Accept-Encoding": "gzip"})
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected a gzipped note list, got %d with encoding %q", rr.Code, rr.Header().Get("Content-Encoding"))
+	}
+	reader, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("read gzip: %v", err)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil || !strings.Contains(string(body), "front") {
+		t.Fatalf("expected the note list after decompressing, got %v (%s)", err, body)
+	}
+	if rr.Body.Len() >= len(body) {
+		t.Fatalf("expected compression to shrink %d bytes, got %d", len(body), rr.Body.Len())
+	}
+
+	rr = doRawRequestWithHeaders(server, http.MethodGet, "/api/notes", "", map[string]string{"Cookie": env.authCookie})
+	if rr.Header().Get("Content-Encoding") != "" || !strings.Contains(rr.Body.String(), "front") {
+		t.Fatalf("expected a plain response without Accept-Encoding, got encoding %q", rr.Header().Get("Content-Encoding"))
+	}
+}
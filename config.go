@@ -1,19 +1,24 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 type DatabaseMode string
 
 const (
-	DatabaseModeSQLite DatabaseMode = "sqlite"
-	DatabaseModeTurso  DatabaseMode = "turso"
+	DatabaseModeSQLite   DatabaseMode = "sqlite"
+	DatabaseModeTurso    DatabaseMode = "turso"
+	DatabaseModePostgres DatabaseMode = "postgres"
 )
 
 type DatabaseConfig struct {
@@ -21,6 +26,10 @@ type DatabaseConfig struct {
 	URL       string
 	AuthToken string
 	Path      string
+	// Encrypted SQLite files are opened with SQLCipher using Key; a server started
+	// without the key waits for POST /api/unlock.
+	Encrypted bool
+	Key       string
 }
 
 type CookieConfig struct {
@@ -62,6 +71,86 @@ type OpenAIConfig struct {
 	BaseURL string
 }
 
+// TTSConfig selects how {{tts ...}} template references are voiced. Provider is
+// "openai", "command", or empty to disable speech.
+type TTSConfig struct {
+	Provider string
+	Command  string
+	Model    string
+	Voice    string
+}
+
+// MediaConfig limits what can be uploaded to POST /api/media. AllowedTypes are MIME
+// types, and "image/*" allows a whole family.
+type MediaConfig struct {
+	MaxFileBytes int64
+	AllowedTypes []string
+}
+
+// ImportConfig limits decks fetched by POST /api/import/url.
+type ImportConfig struct {
+	MaxURLBytes int64
+}
+
+// OIDCConfig configures sign-in through an external OpenID Connect provider such as
+// Authentik, Keycloak, or Google. Its endpoints are discovered from Issuer; sign-in is
+// offered once Issuer, ClientID, and RedirectURL are set.
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	ProviderName string
+	Scopes       []string
+}
+
+// MediaCleanupConfig controls the periodic sweep that deletes media no note refers to.
+// A file is deleted once it has been unreferenced for GracePeriod; an Interval of zero
+// disables the sweep.
+type MediaCleanupConfig struct {
+	Interval    time.Duration
+	GracePeriod time.Duration
+}
+
+// TLSConfig serves HTTPS, either with the certificate in CertFile and KeyFile or with
+// certificates Let's Encrypt issues for AutocertHosts, cached in AutocertDir. RedirectPort,
+// when set, also listens for plain HTTP there, answering ACME challenges and sending
+// everything else to HTTPS.
+type TLSConfig struct {
+	CertFile      string
+	KeyFile       string
+	AutocertHosts []string
+	AutocertDir   string
+	AutocertEmail string
+	RedirectPort  string
+}
+
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != "" || len(c.AutocertHosts) > 0
+}
+
+// ShutdownConfig controls how a server stops on SIGINT or SIGTERM. In-flight requests
+// get Timeout to finish; Backup takes a final backup of a SQLite database before it is
+// closed.
+type ShutdownConfig struct {
+	Timeout time.Duration
+	Backup  bool
+}
+
+// MaintenanceConfig runs the orphaned record cleanup after every operation that deletes
+// notes, cards, or decks when CleanupAfterDelete is set.
+type MaintenanceConfig struct {
+	CleanupAfterDelete bool
+}
+
+// PprofConfig serves net/http/pprof at /debug/pprof when Enabled. Profiles are only
+// served to signed-in users whose email is in Users; in development an empty list lets
+// any signed-in user profile the server.
+type PprofConfig struct {
+	Enabled bool
+	Users   []string
+}
+
 type AppConfig struct {
 	Environment     string
 	Port            string
@@ -70,6 +159,8 @@ type AppConfig struct {
 	MarketingOrigin string
 	AllowedOrigins  []string
 	Database        DatabaseConfig
+	BackupDir       string
+	BackupPolicy    BackupPolicy
 	Cookie          CookieConfig
 	OTP             OTPConfig
 	SessionTTL      time.Duration
@@ -77,41 +168,180 @@ type AppConfig struct {
 	Email           EmailConfig
 	Stripe          StripeConfig
 	OpenAI          OpenAIConfig
+	TTS             TTSConfig
+	Media           MediaConfig
+	MediaCleanup    MediaCleanupConfig
+	Maintenance     MaintenanceConfig
+	Import          ImportConfig
+	OIDC            OIDCConfig
+	TLS             TLSConfig
+	Shutdown        ShutdownConfig
+	Pprof           PprofConfig
 	AuthSuccessPath string
 }
 
+// Settings are read by their environment variable names. A server also takes a few of
+// them as command-line flags and any of them from an optional YAML config file, named by
+// -config or VUTADEX_CONFIG_FILE, that maps the names to values:
+//
+//	PORT: 9000
+//	VUTADEX_DATABASE_PATH: /var/lib/vutadex/vutadex.db
+//	VUTADEX_ALLOWED_ORIGINS: [https://app.example.com, https://example.com]
+//
+// A flag wins over the environment, which wins over the file.
+const (
+	defaultDatabasePath = "./data/microdote.db"
+	defaultBackupDir    = "./backups"
+	defaultAutocertDir  = "./data/autocert"
+)
+
+// configFlags are the settings a server takes on its command line.
+var configFlags = []struct {
+	name  string
+	key   string
+	usage string
+}{
+	{"env", "VUTADEX_ENV", "environment: development or production"},
+	{"host", "VUTADEX_HOST", "address to listen on"},
+	{"port", "PORT", "port to listen on"},
+	{"db", "VUTADEX_DATABASE_PATH", "SQLite database file"},
+	{"db-encrypted", "VUTADEX_DATABASE_ENCRYPTED", "open the SQLite database with SQLCipher: true or false"},
+	{"backup-dir", "VUTADEX_BACKUP_DIR", "directory for backups"},
+	{"allowed-origins", "VUTADEX_ALLOWED_ORIGINS", "comma-separated origins allowed by CORS"},
+	{"tls-cert", "VUTADEX_TLS_CERT_FILE", "TLS certificate file"},
+	{"tls-key", "VUTADEX_TLS_KEY_FILE", "TLS private key file"},
+	{"autocert-hosts", "VUTADEX_TLS_AUTOCERT_HOSTS", "comma-separated hostnames to get Let's Encrypt certificates for"},
+	{"pprof", "VUTADEX_PPROF_ENABLED", "serve profiles at /debug/pprof: true or false"},
+}
+
+// configSource looks settings up in the flags, the environment, then the config file,
+// and collects the values that fail to parse.
+type configSource struct {
+	flags map[string]string
+	file  map[string]string
+	errs  []error
+}
+
+func (src *configSource) lookup(key string) string {
+	if value, ok := src.flags[key]; ok {
+		return value
+	}
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return src.file[key]
+}
+
+// LoadAppConfig reads the configuration from the environment.
 func LoadAppConfig() (AppConfig, error) {
-	environment := stringEnv("VUTADEX_ENV", "development")
-	appOrigin := stringEnv("VUTADEX_APP_ORIGIN", "http://localhost:8000")
-	marketingOrigin := stringEnv("VUTADEX_MARKETING_ORIGIN", "http://localhost:4173")
-	port := stringEnv("PORT", "8000")
-	host := "localhost"
+	return loadAppConfig(&configSource{})
+}
+
+// LoadAppConfigFromArgs reads the configuration from command-line arguments, the
+// environment, and the config file, in that order of precedence.
+func LoadAppConfigFromArgs(args []string) (AppConfig, error) {
+	flags := flag.NewFlagSet("vutadex", flag.ContinueOnError)
+	configPath := flags.String("config", os.Getenv("VUTADEX_CONFIG_FILE"), "YAML config file")
+	values := make(map[string]*string, len(configFlags))
+	for _, f := range configFlags {
+		values[f.name] = flags.String(f.name, "", f.usage+" ("+f.key+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return AppConfig{}, err
+	}
+
+	src := &configSource{flags: map[string]string{}}
+	flags.Visit(func(f *flag.Flag) {
+		for _, setting := range configFlags {
+			if setting.name == f.Name {
+				src.flags[setting.key] = *values[f.Name]
+			}
+		}
+	})
+	if path := strings.TrimSpace(*configPath); path != "" {
+		file, err := readConfigFile(path)
+		if err != nil {
+			return AppConfig{}, err
+		}
+		src.file = file
+	}
+	return loadAppConfig(src)
+}
+
+// readConfigFile reads a YAML mapping of setting names to values. A list is read as a
+// comma-separated value.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	settings := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case nil:
+			continue
+		case []any:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			settings[strings.ToUpper(key)] = strings.Join(items, ",")
+		case map[string]any:
+			return nil, fmt.Errorf("config file %s: %s must be a value or a list", path, key)
+		default:
+			settings[strings.ToUpper(key)] = fmt.Sprint(v)
+		}
+	}
+	return settings, nil
+}
+
+func isPostgresURL(raw string) bool {
+	scheme, _, _ := strings.Cut(strings.ToLower(raw), "://")
+	return scheme == "postgres" || scheme == "postgresql"
+}
+
+func loadAppConfig(src *configSource) (AppConfig, error) {
+	environment := src.stringEnv("VUTADEX_ENV", "development")
+	appOrigin := src.stringEnv("VUTADEX_APP_ORIGIN", "http://localhost:8000")
+	marketingOrigin := src.stringEnv("VUTADEX_MARKETING_ORIGIN", "http://localhost:4173")
+	port := src.stringEnv("PORT", "8000")
+	// A hosting platform that sets PORT expects the server on every interface.
+	defaultHost := "localhost"
 	if os.Getenv("PORT") != "" {
-		host = "0.0.0.0"
+		defaultHost = "0.0.0.0"
 	}
+	host := src.stringEnv("VUTADEX_HOST", defaultHost)
 
 	database := DatabaseConfig{
-		Path:      stringEnv("VUTADEX_DATABASE_PATH", "./data/microdote.db"),
-		URL:       strings.TrimSpace(os.Getenv("VUTADEX_DATABASE_URL")),
-		AuthToken: strings.TrimSpace(os.Getenv("VUTADEX_DATABASE_AUTH_TOKEN")),
+		Path:      src.stringEnv("VUTADEX_DATABASE_PATH", defaultDatabasePath),
+		URL:       strings.TrimSpace(src.lookup("VUTADEX_DATABASE_URL")),
+		AuthToken: strings.TrimSpace(src.lookup("VUTADEX_DATABASE_AUTH_TOKEN")),
 	}
-	if database.URL != "" {
+	if isPostgresURL(database.URL) {
+		database.Mode = DatabaseModePostgres
+	} else if database.URL != "" {
 		database.Mode = DatabaseModeTurso
 	} else {
 		database.Mode = DatabaseModeSQLite
 	}
+	database.Key = src.lookup("VUTADEX_DATABASE_KEY")
+	database.Encrypted = src.boolEnvDefault("VUTADEX_DATABASE_ENCRYPTED", database.Key != "")
 
-	cookieSecureDefault := boolEnvDefault("VUTADEX_COOKIE_SECURE", database.Mode == DatabaseModeTurso || strings.HasPrefix(appOrigin, "https://"))
-	cookieDomain := strings.TrimSpace(os.Getenv("VUTADEX_COOKIE_DOMAIN"))
+	cookieSecureDefault := src.boolEnvDefault("VUTADEX_COOKIE_SECURE", database.Mode != DatabaseModeSQLite || strings.HasPrefix(appOrigin, "https://"))
+	cookieDomain := strings.TrimSpace(src.lookup("VUTADEX_COOKIE_DOMAIN"))
 	if cookieDomain == "" && cookieSecureDefault {
 		cookieDomain = ".vutadex.com"
 	}
 
-	sessionTTLDays := intEnv("VUTADEX_SESSION_TTL_DAYS", 7)
-	otpTTLMinutes := intEnv("VUTADEX_OTP_TTL_MINUTES", 10)
-	otpResendSeconds := intEnv("VUTADEX_OTP_RESEND_SECONDS", 60)
-	otpMaxAttempts := intEnv("VUTADEX_OTP_MAX_ATTEMPTS", 5)
-	sessionSecret := strings.TrimSpace(os.Getenv("VUTADEX_SESSION_SECRET"))
+	sessionTTLDays := src.intEnv("VUTADEX_SESSION_TTL_DAYS", 7)
+	otpTTLMinutes := src.intEnv("VUTADEX_OTP_TTL_MINUTES", 10)
+	otpResendSeconds := src.intEnv("VUTADEX_OTP_RESEND_SECONDS", 60)
+	otpMaxAttempts := src.intEnv("VUTADEX_OTP_MAX_ATTEMPTS", 5)
+	sessionSecret := strings.TrimSpace(src.lookup("VUTADEX_SESSION_SECRET"))
 	if sessionSecret == "" {
 		sessionSecret = "dev-session-secret-change-me"
 	}
@@ -122,8 +352,15 @@ func LoadAppConfig() (AppConfig, error) {
 		Host:            host,
 		AppOrigin:       appOrigin,
 		MarketingOrigin: marketingOrigin,
-		AllowedOrigins:  buildAllowedOrigins(appOrigin, marketingOrigin),
+		AllowedOrigins:  buildAllowedOrigins(appOrigin, marketingOrigin, src.listEnv("VUTADEX_ALLOWED_ORIGINS", defaultAllowedOrigins(environment))),
 		Database:        database,
+		BackupDir:       src.stringEnv("VUTADEX_BACKUP_DIR", defaultBackupDir),
+		BackupPolicy: BackupPolicy{
+			KeepLast:    src.intEnv("VUTADEX_BACKUP_KEEP_LAST", 0),
+			KeepDaily:   src.intEnv("VUTADEX_BACKUP_KEEP_DAILY", 0),
+			KeepWeekly:  src.intEnv("VUTADEX_BACKUP_KEEP_WEEKLY", 0),
+			KeepMonthly: src.intEnv("VUTADEX_BACKUP_KEEP_MONTHLY", 0),
+		},
 		Cookie: CookieConfig{
 			Domain: cookieDomain,
 			Secure: cookieSecureDefault,
@@ -136,40 +373,147 @@ func LoadAppConfig() (AppConfig, error) {
 		SessionTTL:    time.Duration(sessionTTLDays) * 24 * time.Hour,
 		SessionSecret: sessionSecret,
 		Email: EmailConfig{
-			SendURL:         strings.TrimSpace(os.Getenv("VUTADEX_EMAIL_SEND_URL")),
-			AuthHeaderName:  stringEnv("VUTADEX_EMAIL_SEND_AUTH_HEADER", "Authorization"),
-			AuthHeaderValue: strings.TrimSpace(os.Getenv("VUTADEX_EMAIL_SEND_AUTH_VALUE")),
+			SendURL:         strings.TrimSpace(src.lookup("VUTADEX_EMAIL_SEND_URL")),
+			AuthHeaderName:  src.stringEnv("VUTADEX_EMAIL_SEND_AUTH_HEADER", "Authorization"),
+			AuthHeaderValue: strings.TrimSpace(src.lookup("VUTADEX_EMAIL_SEND_AUTH_VALUE")),
 		},
 		Stripe: StripeConfig{
-			SecretKey:                 strings.TrimSpace(os.Getenv("VUTADEX_STRIPE_SECRET_KEY")),
-			WebhookSecret:             firstNonEmpty(strings.TrimSpace(os.Getenv("VUTADEX_STRIPE_WEBHOOK_SECRET")), strings.TrimSpace(os.Getenv("VUTADEX_BILLING_WEBHOOK_SECRET"))),
-			ConnectCountry:            strings.ToUpper(stringEnv("VUTADEX_STRIPE_CONNECT_COUNTRY", "US")),
-			ConnectRefreshURL:         stringEnv("VUTADEX_STRIPE_CONNECT_REFRESH_URL", strings.TrimRight(appOrigin, "/")+"/marketplace/publish?creator=refresh"),
-			ConnectReturnURL:          stringEnv("VUTADEX_STRIPE_CONNECT_RETURN_URL", strings.TrimRight(appOrigin, "/")+"/marketplace/publish?creator=return"),
-			CheckoutSuccessURL:        stringEnv("VUTADEX_MARKETPLACE_CHECKOUT_SUCCESS_URL", strings.TrimRight(appOrigin, "/")+"/marketplace?checkout=success"),
-			CheckoutCancelURL:         stringEnv("VUTADEX_MARKETPLACE_CHECKOUT_CANCEL_URL", strings.TrimRight(appOrigin, "/")+"/marketplace?checkout=cancelled"),
-			BillingPriceProMonthly:    strings.TrimSpace(os.Getenv("VUTADEX_STRIPE_BILLING_PRICE_PRO_MONTHLY")),
-			BillingPriceTeamMonthly:   strings.TrimSpace(os.Getenv("VUTADEX_STRIPE_BILLING_PRICE_TEAM_MONTHLY")),
-			BillingCheckoutSuccessURL: stringEnv("VUTADEX_STRIPE_BILLING_CHECKOUT_SUCCESS_URL", strings.TrimRight(appOrigin, "/")+"/billing/complete?checkout=success"),
-			BillingCheckoutCancelURL:  stringEnv("VUTADEX_STRIPE_BILLING_CHECKOUT_CANCEL_URL", strings.TrimRight(appOrigin, "/")+"/billing/complete?checkout=cancelled"),
-			BillingPortalReturnURL:    stringEnv("VUTADEX_STRIPE_BILLING_PORTAL_RETURN_URL", strings.TrimRight(appOrigin, "/")+"/settings?billing=returned"),
-			PlatformFeeBasisPts:       intEnv("VUTADEX_MARKETPLACE_PLATFORM_FEE_BPS", 1500),
+			SecretKey:                 strings.TrimSpace(src.lookup("VUTADEX_STRIPE_SECRET_KEY")),
+			WebhookSecret:             firstNonEmpty(strings.TrimSpace(src.lookup("VUTADEX_STRIPE_WEBHOOK_SECRET")), strings.TrimSpace(src.lookup("VUTADEX_BILLING_WEBHOOK_SECRET"))),
+			ConnectCountry:            strings.ToUpper(src.stringEnv("VUTADEX_STRIPE_CONNECT_COUNTRY", "US")),
+			ConnectRefreshURL:         src.stringEnv("VUTADEX_STRIPE_CONNECT_REFRESH_URL", strings.TrimRight(appOrigin, "/")+"/marketplace/publish?creator=refresh"),
+			ConnectReturnURL:          src.stringEnv("VUTADEX_STRIPE_CONNECT_RETURN_URL", strings.TrimRight(appOrigin, "/")+"/marketplace/publish?creator=return"),
+			CheckoutSuccessURL:        src.stringEnv("VUTADEX_MARKETPLACE_CHECKOUT_SUCCESS_URL", strings.TrimRight(appOrigin, "/")+"/marketplace?checkout=success"),
+			CheckoutCancelURL:         src.stringEnv("VUTADEX_MARKETPLACE_CHECKOUT_CANCEL_URL", strings.TrimRight(appOrigin, "/")+"/marketplace?checkout=cancelled"),
+			BillingPriceProMonthly:    strings.TrimSpace(src.lookup("VUTADEX_STRIPE_BILLING_PRICE_PRO_MONTHLY")),
+			BillingPriceTeamMonthly:   strings.TrimSpace(src.lookup("VUTADEX_STRIPE_BILLING_PRICE_TEAM_MONTHLY")),
+			BillingCheckoutSuccessURL: src.stringEnv("VUTADEX_STRIPE_BILLING_CHECKOUT_SUCCESS_URL", strings.TrimRight(appOrigin, "/")+"/billing/complete?checkout=success"),
+			BillingCheckoutCancelURL:  src.stringEnv("VUTADEX_STRIPE_BILLING_CHECKOUT_CANCEL_URL", strings.TrimRight(appOrigin, "/")+"/billing/complete?checkout=cancelled"),
+			BillingPortalReturnURL:    src.stringEnv("VUTADEX_STRIPE_BILLING_PORTAL_RETURN_URL", strings.TrimRight(appOrigin, "/")+"/settings?billing=returned"),
+			PlatformFeeBasisPts:       src.intEnv("VUTADEX_MARKETPLACE_PLATFORM_FEE_BPS", 1500),
 		},
 		OpenAI: OpenAIConfig{
-			APIKey:  strings.TrimSpace(os.Getenv("VUTADEX_OPENAI_API_KEY")),
-			Model:   stringEnv("VUTADEX_OPENAI_MODEL", "gpt-5-mini"),
-			BaseURL: strings.TrimRight(stringEnv("VUTADEX_OPENAI_BASE_URL", "https://api.openai.com/v1"), "/"),
+			APIKey:  strings.TrimSpace(src.lookup("VUTADEX_OPENAI_API_KEY")),
+			Model:   src.stringEnv("VUTADEX_OPENAI_MODEL", "gpt-5-mini"),
+			BaseURL: strings.TrimRight(src.stringEnv("VUTADEX_OPENAI_BASE_URL", "https://api.openai.com/v1"), "/"),
+		},
+		TTS: TTSConfig{
+			Provider: strings.ToLower(strings.TrimSpace(src.lookup("VUTADEX_TTS_PROVIDER"))),
+			Command:  strings.TrimSpace(src.lookup("VUTADEX_TTS_COMMAND")),
+			Model:    src.stringEnv("VUTADEX_TTS_MODEL", "gpt-4o-mini-tts"),
+			Voice:    src.stringEnv("VUTADEX_TTS_VOICE", "alloy"),
+		},
+		Media: MediaConfig{
+			MaxFileBytes: int64(src.intEnv("VUTADEX_MEDIA_MAX_FILE_MB", 50)) << 20,
+			AllowedTypes: src.listEnv("VUTADEX_MEDIA_ALLOWED_TYPES", defaultMediaTypes()),
+		},
+		MediaCleanup: MediaCleanupConfig{
+			Interval:    time.Duration(src.intEnv("VUTADEX_MEDIA_SWEEP_INTERVAL_HOURS", 24)) * time.Hour,
+			GracePeriod: time.Duration(src.intEnv("VUTADEX_MEDIA_GRACE_DAYS", 7)) * 24 * time.Hour,
+		},
+		Maintenance: MaintenanceConfig{
+			CleanupAfterDelete: src.boolEnvDefault("VUTADEX_CLEANUP_AFTER_DELETE", false),
+		},
+		Import: ImportConfig{
+			MaxURLBytes: int64(src.intEnv("VUTADEX_IMPORT_URL_MAX_MB", 100)) << 20,
 		},
-		AuthSuccessPath: stringEnv("VUTADEX_AUTH_SUCCESS_URL", "/decks"),
+		OIDC: OIDCConfig{
+			Issuer:       strings.TrimRight(strings.TrimSpace(src.lookup("VUTADEX_OIDC_ISSUER")), "/"),
+			ClientID:     strings.TrimSpace(src.lookup("VUTADEX_OIDC_CLIENT_ID")),
+			ClientSecret: strings.TrimSpace(src.lookup("VUTADEX_OIDC_CLIENT_SECRET")),
+			RedirectURL:  strings.TrimSpace(src.lookup("VUTADEX_OIDC_REDIRECT_URL")),
+			ProviderName: src.stringEnv("VUTADEX_OIDC_PROVIDER_NAME", "Single sign-on"),
+			Scopes:       src.listEnv("VUTADEX_OIDC_SCOPES", []string{"openid", "profile", "email"}),
+		},
+		TLS: TLSConfig{
+			CertFile:      strings.TrimSpace(src.lookup("VUTADEX_TLS_CERT_FILE")),
+			KeyFile:       strings.TrimSpace(src.lookup("VUTADEX_TLS_KEY_FILE")),
+			AutocertHosts: src.listEnv("VUTADEX_TLS_AUTOCERT_HOSTS", nil),
+			AutocertDir:   src.stringEnv("VUTADEX_TLS_AUTOCERT_DIR", defaultAutocertDir),
+			AutocertEmail: strings.TrimSpace(src.lookup("VUTADEX_TLS_AUTOCERT_EMAIL")),
+			RedirectPort:  strings.TrimSpace(src.lookup("VUTADEX_TLS_REDIRECT_PORT")),
+		},
+		Shutdown: ShutdownConfig{
+			Timeout: time.Duration(src.intEnv("VUTADEX_SHUTDOWN_TIMEOUT_SECONDS", 30)) * time.Second,
+			Backup:  src.boolEnvDefault("VUTADEX_BACKUP_ON_SHUTDOWN", false),
+		},
+		Pprof: PprofConfig{
+			Enabled: src.boolEnvDefault("VUTADEX_PPROF_ENABLED", false),
+			Users:   src.listEnv("VUTADEX_PPROF_USERS", nil),
+		},
+		AuthSuccessPath: src.stringEnv("VUTADEX_AUTH_SUCCESS_URL", "/decks"),
 	}
 
-	if cfg.Database.Mode == DatabaseModeTurso && cfg.Database.AuthToken == "" {
-		return AppConfig{}, fmt.Errorf("VUTADEX_DATABASE_AUTH_TOKEN is required when VUTADEX_DATABASE_URL is set")
+	if err := errors.Join(append(src.errs, cfg.Validate())...); err != nil {
+		return AppConfig{}, err
 	}
-
 	return cfg, nil
 }
 
+// Validate reports every setting a server cannot start with.
+func (cfg AppConfig) Validate() error {
+	var errs []error
+	if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT must be a port number, got %q", cfg.Port))
+	}
+	if strings.TrimSpace(cfg.Host) == "" {
+		errs = append(errs, errors.New("VUTADEX_HOST must not be empty"))
+	}
+	if cfg.Database.Mode == DatabaseModeSQLite && strings.TrimSpace(cfg.Database.Path) == "" {
+		errs = append(errs, errors.New("VUTADEX_DATABASE_PATH must not be empty"))
+	}
+	if cfg.Database.Mode == DatabaseModeTurso && cfg.Database.AuthToken == "" {
+		errs = append(errs, errors.New("VUTADEX_DATABASE_AUTH_TOKEN is required when VUTADEX_DATABASE_URL names a Turso database"))
+	}
+	if cfg.Database.Mode != DatabaseModeSQLite && cfg.Database.Encrypted {
+		errs = append(errs, errors.New("VUTADEX_DATABASE_ENCRYPTED only applies to a SQLite database"))
+	}
+	if cfg.Database.Key != "" && !cfg.Database.Encrypted {
+		errs = append(errs, errors.New("VUTADEX_DATABASE_KEY is set but VUTADEX_DATABASE_ENCRYPTED is false"))
+	}
+	if strings.TrimSpace(cfg.BackupDir) == "" {
+		errs = append(errs, errors.New("VUTADEX_BACKUP_DIR must not be empty"))
+	}
+	if p := cfg.BackupPolicy; p.KeepLast < 0 || p.KeepDaily < 0 || p.KeepWeekly < 0 || p.KeepMonthly < 0 {
+		errs = append(errs, errors.New("VUTADEX_BACKUP_KEEP_* settings must not be negative"))
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		if !isOrigin(origin) {
+			errs = append(errs, fmt.Errorf("allowed origin %q must be a scheme and host, such as https://example.com", origin))
+		}
+	}
+	if cfg.SessionTTL <= 0 {
+		errs = append(errs, errors.New("VUTADEX_SESSION_TTL_DAYS must be positive"))
+	}
+	if cfg.OTP.MaxAttempts <= 0 {
+		errs = append(errs, errors.New("VUTADEX_OTP_MAX_ATTEMPTS must be positive"))
+	}
+	if (cfg.TLS.CertFile == "") != (cfg.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("VUTADEX_TLS_CERT_FILE and VUTADEX_TLS_KEY_FILE must be set together"))
+	}
+	if cfg.TLS.CertFile != "" && len(cfg.TLS.AutocertHosts) > 0 {
+		errs = append(errs, errors.New("VUTADEX_TLS_AUTOCERT_HOSTS cannot be combined with a certificate file"))
+	}
+	if len(cfg.TLS.AutocertHosts) > 0 && strings.TrimSpace(cfg.TLS.AutocertDir) == "" {
+		errs = append(errs, errors.New("VUTADEX_TLS_AUTOCERT_DIR must not be empty"))
+	}
+	if cfg.TLS.RedirectPort != "" {
+		if port, err := strconv.Atoi(cfg.TLS.RedirectPort); err != nil || port < 1 || port > 65535 || cfg.TLS.RedirectPort == cfg.Port {
+			errs = append(errs, fmt.Errorf("VUTADEX_TLS_REDIRECT_PORT must be a port number other than PORT, got %q", cfg.TLS.RedirectPort))
+		}
+	}
+	if cfg.Shutdown.Timeout <= 0 {
+		errs = append(errs, errors.New("VUTADEX_SHUTDOWN_TIMEOUT_SECONDS must be positive"))
+	}
+	if cfg.Media.MaxFileBytes <= 0 {
+		errs = append(errs, errors.New("VUTADEX_MEDIA_MAX_FILE_MB must be positive"))
+	}
+	if cfg.Pprof.Enabled && !cfg.IsDevelopment() && len(cfg.Pprof.Users) == 0 {
+		errs = append(errs, errors.New("VUTADEX_PPROF_USERS must list who may profile a production server"))
+	}
+	return errors.Join(errs...)
+}
+
 func mustLocalAppConfig() AppConfig {
 	cfg, err := LoadAppConfig()
 	if err == nil {
@@ -182,11 +526,12 @@ func mustLocalAppConfig() AppConfig {
 		Host:            "localhost",
 		AppOrigin:       "http://localhost:3000",
 		MarketingOrigin: "http://localhost:4173",
-		AllowedOrigins:  buildAllowedOrigins("http://localhost:3000", "http://localhost:4173"),
+		AllowedOrigins:  buildAllowedOrigins("http://localhost:3000", "http://localhost:4173", defaultAllowedOrigins("development")),
 		Database: DatabaseConfig{
 			Mode: DatabaseModeSQLite,
-			Path: "./data/microdote.db",
+			Path: defaultDatabasePath,
 		},
+		BackupDir: defaultBackupDir,
 		Cookie: CookieConfig{
 			Secure: false,
 		},
@@ -215,6 +560,20 @@ func mustLocalAppConfig() AppConfig {
 			Model:   "gpt-5-mini",
 			BaseURL: "https://api.openai.com/v1",
 		},
+		Media: MediaConfig{
+			MaxFileBytes: 50 << 20,
+			AllowedTypes: defaultMediaTypes(),
+		},
+		MediaCleanup: MediaCleanupConfig{
+			Interval:    24 * time.Hour,
+			GracePeriod: 7 * 24 * time.Hour,
+		},
+		Import: ImportConfig{
+			MaxURLBytes: 100 << 20,
+		},
+		Shutdown: ShutdownConfig{
+			Timeout: 30 * time.Second,
+		},
 		AuthSuccessPath: "/decks",
 	}
 }
@@ -223,27 +582,28 @@ func (cfg AppConfig) IsDevelopment() bool {
 	return strings.ToLower(strings.TrimSpace(cfg.Environment)) != "production"
 }
 
-func buildAllowedOrigins(appOrigin, marketingOrigin string) []string {
-	origins := []string{
-		appOrigin,
-		marketingOrigin,
-		"https://www.vutadex.com",
-		"http://localhost:3000",
-		"http://127.0.0.1:3000",
-		"http://localhost:4173",
-		"http://127.0.0.1:4173",
-		"http://marketing.lvh.me:4174",
-		"http://app.lvh.me:3000",
-		"http://localhost:4317",
-		"http://127.0.0.1:4317",
-	}
-
-	if extra := strings.TrimSpace(os.Getenv("VUTADEX_ALLOWED_ORIGINS")); extra != "" {
-		for _, origin := range strings.Split(extra, ",") {
-			origins = append(origins, strings.TrimSpace(origin))
-		}
+// defaultAllowedOrigins are the origins CORS allows besides the app and marketing
+// origins when VUTADEX_ALLOWED_ORIGINS is unset: the public site, and in development the
+// local web and marketing servers.
+func defaultAllowedOrigins(environment string) []string {
+	origins := []string{"https://www.vutadex.com"}
+	if (AppConfig{Environment: environment}).IsDevelopment() {
+		origins = append(origins,
+			"http://localhost:3000",
+			"http://127.0.0.1:3000",
+			"http://localhost:4173",
+			"http://127.0.0.1:4173",
+			"http://marketing.lvh.me:4174",
+			"http://app.lvh.me:3000",
+			"http://localhost:4317",
+			"http://127.0.0.1:4317",
+		)
 	}
+	return origins
+}
 
+func buildAllowedOrigins(appOrigin, marketingOrigin string, extra []string) []string {
+	origins := append([]string{appOrigin, marketingOrigin}, extra...)
 	seen := make(map[string]struct{}, len(origins))
 	filtered := make([]string, 0, len(origins))
 	for _, origin := range origins {
@@ -251,10 +611,6 @@ func buildAllowedOrigins(appOrigin, marketingOrigin string) []string {
 		if origin == "" {
 			continue
 		}
-		parsed, err := url.Parse(origin)
-		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
-			continue
-		}
 		if _, ok := seen[origin]; ok {
 			continue
 		}
@@ -265,32 +621,53 @@ func buildAllowedOrigins(appOrigin, marketingOrigin string) []string {
 	return filtered
 }
 
-func stringEnv(key, fallback string) string {
-	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+func isOrigin(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && parsed.Scheme != "" && parsed.Host != "" && strings.TrimLeft(parsed.Path, "/") == ""
+}
+
+func (src *configSource) stringEnv(key, fallback string) string {
+	if value := strings.TrimSpace(src.lookup(key)); value != "" {
 		return value
 	}
 	return fallback
 }
 
-func intEnv(key string, fallback int) int {
-	value := strings.TrimSpace(os.Getenv(key))
+// listEnv reads a comma-separated list, dropping blank entries.
+func (src *configSource) listEnv(key string, fallback []string) []string {
+	var values []string
+	for _, value := range strings.Split(src.lookup(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return fallback
+	}
+	return values
+}
+
+func (src *configSource) intEnv(key string, fallback int) int {
+	value := strings.TrimSpace(src.lookup(key))
 	if value == "" {
 		return fallback
 	}
 	parsed, err := strconv.Atoi(value)
 	if err != nil {
+		src.errs = append(src.errs, fmt.Errorf("%s must be a whole number, got %q", key, value))
 		return fallback
 	}
 	return parsed
 }
 
-func boolEnvDefault(key string, fallback bool) bool {
-	value := strings.TrimSpace(os.Getenv(key))
+func (src *configSource) boolEnvDefault(key string, fallback bool) bool {
+	value := strings.TrimSpace(src.lookup(key))
 	if value == "" {
 		return fallback
 	}
 	parsed, err := strconv.ParseBool(value)
 	if err != nil {
+		src.errs = append(src.errs, fmt.Errorf("%s must be true or false, got %q", key, value))
 		return fallback
 	}
 	return parsed
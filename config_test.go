@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadAppConfigFromArgsLayersFlagsEnvironmentAndFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vutadex.yaml")
+	file := "port: 9000\nVUTADEX_DATABASE_PATH: /srv/file.db\nVUTADEX_BACKUP_DIR: /srv/backups\n" +
+		"VUTADEX_ALLOWED_ORIGINS: [https://one.example.com, https://two.example.com]\n"
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	t.Setenv("PORT", "")
+	t.Setenv("VUTADEX_ENV", "production")
+	t.Setenv("VUTADEX_ALLOWED_ORIGINS", "")
+	t.Setenv("VUTADEX_BACKUP_DIR", "")
+	t.Setenv("VUTADEX_DATABASE_PATH", "/srv/env.db")
+
+	cfg, err := LoadAppConfigFromArgs([]string{"-config", path, "-port", "9100"})
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.Port != "9100" || cfg.Host != "localhost" {
+		t.Fatalf("expected the port flag to win over the file, got %s:%s", cfg.Host, cfg.Port)
+	}
+	if cfg.Database.Path != "/srv/env.db" || cfg.BackupDir != "/srv/backups" {
+		t.Fatalf("expected the environment over the file, got %q and %q", cfg.Database.Path, cfg.BackupDir)
+	}
+	if strings.Join(cfg.AllowedOrigins[2:], ",") != "https://one.example.com,https://two.example.com" {
+		t.Fatalf("expected the file's origins in place of the defaults, got %v", cfg.AllowedOrigins)
+	}
+}
+
+func TestLoadAppConfigFromArgsDefaults(t *testing.T) {
+	for _, key := range []string{"PORT", "VUTADEX_HOST", "VUTADEX_ENV", "VUTADEX_DATABASE_PATH", "VUTADEX_DATABASE_URL", "VUTADEX_BACKUP_DIR", "VUTADEX_ALLOWED_ORIGINS", "VUTADEX_CONFIG_FILE"} {
+		t.Setenv(key, "")
+	}
+	cfg, err := LoadAppConfigFromArgs(nil)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.Port != "8000" || cfg.Database.Path != defaultDatabasePath || cfg.BackupDir != defaultBackupDir {
+		t.Fatalf("unexpected defaults: port %q, database %q, backups %q", cfg.Port, cfg.Database.Path, cfg.BackupDir)
+	}
+	if len(cfg.AllowedOrigins) < 3 {
+		t.Fatalf("expected the local development origins, got %v", cfg.AllowedOrigins)
+	}
+}
+
+func TestLoadAppConfigRejectsInvalidSettings(t *testing.T) {
+	t.Setenv("PORT", "eighty")
+	t.Setenv("VUTADEX_OTP_MAX_ATTEMPTS", "many")
+	t.Setenv("VUTADEX_ALLOWED_ORIGINS", "example.com")
+
+	_, err := LoadAppConfig()
+	if err == nil {
+		t.Fatalf("expected invalid settings to be rejected")
+	}
+	for _, key := range []string{"PORT", "VUTADEX_OTP_MAX_ATTEMPTS", "example.com"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Fatalf("expected the error to name %s, got %v", key, err)
+		}
+	}
+}
+
+func TestLoadAppConfigSelectsPostgresForPostgresURL(t *testing.T) {
+	t.Setenv("VUTADEX_DATABASE_URL", "postgres://cards@db.example.com/cards")
+	t.Setenv("VUTADEX_DATABASE_AUTH_TOKEN", "")
+
+	cfg, err := LoadAppConfig()
+	if err != nil {
+		t.Fatalf("expected a PostgreSQL URL to need no Turso auth token, got %v", err)
+	}
+	if cfg.Database.Mode != DatabaseModePostgres {
+		t.Fatalf("expected postgres mode, got %q", cfg.Database.Mode)
+	}
+
+	t.Setenv("VUTADEX_DATABASE_ENCRYPTED", "true")
+	if _, err := LoadAppConfig(); err == nil || !strings.Contains(err.Error(), "VUTADEX_DATABASE_ENCRYPTED") {
+		t.Fatalf("expected encryption to be refused for PostgreSQL, got %v", err)
+	}
+}
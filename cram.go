@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cram sessions serve cards regardless of when they are due, e.g. for last-minute exam
+// review. Answers sent with preview set leave the cards' schedules and the review log
+// untouched, so cramming never distorts FSRS.
+
+// CramResponse is the body of GET /api/study/cram.
+type CramResponse struct {
+	Order string  `json:"order"`
+	Cards []*Card `json:"cards"`
+}
+
+// ListCramCards returns up to limit of the user's unsuspended cards in deckID's subtree
+// (every deck when 0) that match query (all cards when empty), in order.
+func (s *SQLStore) ListCramCards(ctx context.Context, userID, collectionID string, deckID int64, query, order string, limit int, now time.Time) ([]*Card, error) {
+	userID = strings.TrimSpace(userID)
+	if userID != "" {
+		if err := s.EnsureReviewStatesForUser(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	searchSQL, searchArgs, err := compileSearch(s.dialect, collectionID, userID, query, false, now)
+	if err != nil {
+		return nil, err
+	}
+	dayStart, _, err := s.studyDay(ctx, collectionID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	alias, join, args := "c", "", []interface{}{}
+	if userID != "" {
+		alias, join = "rs", `JOIN card_review_states rs ON rs.card_id = c.id AND rs.user_id = ?`
+		args = append(args, userID)
+	}
+	args = append(args, searchArgs...)
+	deckSQL, cte := "", ""
+	if deckID > 0 {
+		cte, deckSQL = deckSubtreeCTE, `AND c.deck_id IN (SELECT id FROM subtree)`
+		args = append([]interface{}{deckID}, args...)
+	}
+	args = append(args, limit)
+
+	ids, err := s.queryIDs(ctx, fmt.Sprintf(`%[1]s
+		SELECT c.id
+		FROM cards c
+		%[2]s
+		WHERE c.id IN (%[3]s)
+		  AND %[4]s.suspended = 0
+		  %[5]s
+		ORDER BY %[6]s
+		LIMIT ?
+	`, cte, join, searchSQL, alias, deckSQL, filteredOrderSQL(s.dialect, order, alias, now.Unix(), dayStart.Unix())), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	cards := make([]*Card, 0, len(ids))
+	for _, id := range ids {
+		card, err := s.GetCardForUser(ctx, userID, id)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+// GetCramQueue serves GET /api/study/cram. Query parameters: deckId, query (a search),
+// limit, and order (a filtered deck order, default random).
+func (h *APIHandler) GetCramQueue(w http.ResponseWriter, r *http.Request) {
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	params := r.URL.Query()
+
+	limit := defaultStudyQueueLimit
+	if raw := strings.TrimSpace(params.Get("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			respondAPIError(w, http.StatusBadRequest, "invalid_limit", "limit must be a positive number")
+			return
+		}
+		limit = min(parsed, maxStudyQueueLimit)
+	}
+	var deckID int64
+	if raw := strings.TrimSpace(params.Get("deckId")); raw != "" {
+		deckID, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || col.Decks[deckID] == nil {
+			respondAPIError(w, http.StatusBadRequest, "invalid_deck_id", "Deck not found")
+			return
+		}
+	}
+	order := filteredOrderRandom
+	if raw := strings.TrimSpace(params.Get("order")); raw != "" {
+		if !isValidFilteredOrder(raw) {
+			respondAPIError(w, http.StatusBadRequest, "invalid_order", "Unknown cram order")
+			return
+		}
+		order = raw
+	}
+	now := time.Now()
+	query := strings.TrimSpace(params.Get("query"))
+	if err := validateSearch(query); err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+
+	cards, err := h.store.ListCramCards(r.Context(), h.userIDFromRequest(r), collectionID, deckID, query, order, limit, now)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "cram_queue_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, CramResponse{Order: order, Cards: cards})
+}
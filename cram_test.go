@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAPI_CramServesCardsWithoutScheduling(t *testing.T) {
+	env := setupAPITestEnv(t)
+	var ids []int64
+	for _, front := range []string{"Mitochondria", "Chloroplast", "Cytoplasm"} {
+		ids = append(ids, createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": front, "Back": "Cell"},
+		}, nil).Cards[0].ID)
+	}
+	// Not due for days, but a cram session still shows it.
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", ids[0]), AnswerCardRequest{Rating: 4}); rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	suspended := true
+	if rr := doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/cards/%d", ids[2]), UpdateCardRequest{Suspended: &suspended}); rr.Code != http.StatusOK {
+		t.Fatalf("expected suspend 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr := doRawRequest(env.router, http.MethodGet, "/api/study/cram?deckId=1&order=added", "")
+	queue := decodeJSON[CramResponse](t, rr)
+	if rr.Code != http.StatusOK || len(queue.Cards) != 2 || queue.Cards[0].ID != ids[0] || queue.Cards[1].ID != ids[1] {
+		t.Fatalf("expected every unsuspended card in added order, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	rr = doRawRequest(env.router, http.MethodGet, "/api/study/cram?query=Chloro*", "")
+	if queue := decodeJSON[CramResponse](t, rr); len(queue.Cards) != 1 || queue.Cards[0].ID != ids[1] || queue.Order != filteredOrderRandom {
+		t.Fatalf("expected the search to narrow the cram queue, got %s", rr.Body.String())
+	}
+	if rr := doRawRequest(env.router, http.MethodGet, "/api/study/cram?order=sideways", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown order to be rejected, got %d", rr.Code)
+	}
+
+	before := queue.Cards[0]
+	rr = doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", ids[0]), AnswerCardRequest{Rating: 1, Preview: true})
+	after := decodeJSON[Card](t, rr)
+	if rr.Code != http.StatusOK || after.SRS.Reps != before.SRS.Reps || !after.SRS.Due.Equal(before.SRS.Due) || after.SRS.Lapses != 0 {
+		t.Fatalf("expected a preview answer to leave the card unchanged, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	entries, err := env.store.ListRevlogEntriesForCard(context.Background(), "", ids[0], 10)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected no revlog entry for a preview answer, got %+v (%v)", entries, err)
+	}
+}
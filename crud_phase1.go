@@ -1,7 +1,7 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"net/http"
 	"sort"
@@ -43,27 +43,66 @@ type ListNotesResponse struct {
 	Total      int                    `json:"total"`
 	NextCursor string                 `json:"nextCursor,omitempty"`
 	PrevCursor string                 `json:"prevCursor,omitempty"`
+	Page       int                    `json:"page,omitempty"`
+	PageSize   int                    `json:"pageSize"`
+	TotalPages int                    `json:"totalPages,omitempty"`
 }
 
+// UpdateNoteRequest replaces a note's fields and tags. TypeID and DeckID default to the
+// note's current type and deck; FieldMap is accepted as an alias of FieldVals to match
+// the note response.
 type UpdateNoteRequest struct {
 	TypeID    string            `json:"typeId"`
 	DeckID    int64             `json:"deckId"`
 	FieldVals map[string]string `json:"fieldVals"`
+	FieldMap  map[string]string `json:"fieldMap,omitempty"`
 	Tags      []string          `json:"tags"`
 }
 
+// Validate allows the type and deck to be left out; they default to the note's own.
+func (req *UpdateNoteRequest) Validate(v *requestValidator) {
+	v.check(req.DeckID >= 0, "deckId", "must not be negative")
+}
+
+// UpdateDeckRequest patches a deck; omitted fields are unchanged. ParentID 0 moves the
+// deck to the top level and OptionsID 0 returns it to the default preset.
 type UpdateDeckRequest struct {
 	Name           *string `json:"name,omitempty"`
+	ParentID       *int64  `json:"parentId,omitempty"`
+	OptionsID      *int64  `json:"optionsId,omitempty"`
 	NewCardsPerDay *int    `json:"newCardsPerDay,omitempty"`
 	ReviewsPerDay  *int    `json:"reviewsPerDay,omitempty"`
 	PriorityOrder  *int    `json:"priorityOrder,omitempty"`
 }
 
+// Validate checks each field that is set. Whether the parent and options preset exist
+// is left to the handler.
+func (req *UpdateDeckRequest) Validate(v *requestValidator) {
+	if req.Name == nil && req.ParentID == nil && req.OptionsID == nil && req.NewCardsPerDay == nil && req.ReviewsPerDay == nil && req.PriorityOrder == nil {
+		v.fail("name", "or another deck field is required")
+	}
+	if req.Name != nil {
+		v.required("name", *req.Name)
+	}
+	if req.PriorityOrder != nil {
+		v.check(*req.PriorityOrder >= 1, "priorityOrder", "must be 1 or greater")
+	}
+	if req.NewCardsPerDay != nil {
+		v.check(*req.NewCardsPerDay >= 0, "newCardsPerDay", "must not be negative")
+	}
+	if req.ReviewsPerDay != nil {
+		v.check(*req.ReviewsPerDay >= 0, "reviewsPerDay", "must not be negative")
+	}
+}
+
 type CreateTemplateRequest struct {
 	Name               string `json:"name"`
 	SourceTemplateName string `json:"sourceTemplateName,omitempty"`
 }
 
+// Validate accepts a blank name; uniqueTemplateName then picks one.
+func (req *CreateTemplateRequest) Validate(v *requestValidator) {}
+
 func sanitizeFieldVals(fieldVals map[string]string) map[string]string {
 	sanitized := make(map[string]string, len(fieldVals))
 	for field, value := range fieldVals {
@@ -78,7 +117,7 @@ func sanitizeTags(tags []string) []string {
 	}
 	sanitized := make([]string, 0, len(tags))
 	for _, tag := range tags {
-		trimmed := strings.TrimSpace(sanitizeHTML(tag))
+		trimmed := normalizeTagPath(sanitizeHTML(tag))
 		if trimmed != "" {
 			sanitized = append(sanitized, trimmed)
 		}
@@ -89,7 +128,7 @@ func sanitizeTags(tags []string) []string {
 func (h *APIHandler) noteToResponse(note *Note, cards []Card) NoteResponse {
 	deckID := int64(0)
 	if len(cards) > 0 {
-		deckID = cards[0].DeckID
+		deckID = cards[0].homeDeckID()
 	}
 	tags := note.Tags
 	if tags == nil {
@@ -120,11 +159,13 @@ func (h *APIHandler) noteFieldPreview(note Note, col *Collection) string {
 	return firstFieldPreview(note.FieldMap)
 }
 
+// primaryDeckDetails is the deck a note's cards belong to: the home deck of its first
+// card, so a note stays in its deck while a filtered deck borrows some of its cards.
 func (h *APIHandler) primaryDeckDetails(cards []Card, col *Collection) (int64, string) {
 	if len(cards) == 0 {
 		return 0, ""
 	}
-	deckID := cards[0].DeckID
+	deckID := cards[0].homeDeckID()
 	deckName := ""
 	if deck, ok := col.Decks[deckID]; ok {
 		deckName = deck.Name
@@ -132,41 +173,6 @@ func (h *APIHandler) primaryDeckDetails(cards []Card, col *Collection) (int64, s
 	return deckID, deckName
 }
 
-func noteMatchesFilter(note Note, preview, query, tagFilter, typeFilter string) bool {
-	if typeFilter != "" && !strings.EqualFold(string(note.Type), typeFilter) {
-		return false
-	}
-	if tagFilter != "" {
-		matched := false
-		for _, tag := range note.Tags {
-			if strings.Contains(strings.ToLower(tag), tagFilter) {
-				matched = true
-				break
-			}
-		}
-		if !matched {
-			return false
-		}
-	}
-	if query == "" {
-		return true
-	}
-	if strings.Contains(strings.ToLower(preview), query) || strings.Contains(strings.ToLower(string(note.Type)), query) {
-		return true
-	}
-	for _, value := range note.FieldMap {
-		if strings.Contains(strings.ToLower(value), query) {
-			return true
-		}
-	}
-	for _, tag := range note.Tags {
-		if strings.Contains(strings.ToLower(tag), query) {
-			return true
-		}
-	}
-	return false
-}
-
 func parseCursorOffset(raw string) (int, error) {
 	if strings.TrimSpace(raw) == "" {
 		return 0, nil
@@ -237,14 +243,18 @@ func (h *APIHandler) allocateCardIdentity(col *Collection, card *Card) {
 	card.USN = col.USN
 }
 
-func (h *APIHandler) regenerateCardsForSingleNote(col *Collection, note *Note, deckID int64, templateAliases map[string]string) ([]Card, error) {
-	existingCards, err := h.store.GetCardsByNote(note.ID)
+// regenerateCardsForSingleNote brings the note's cards in line with its note type,
+// creating cards in deckID (by default the home deck of the note's cards) and deleting
+// cards whose template is gone. Existing cards keep their decks, so cards borrowed by a
+// filtered deck stay borrowed and go home when it is emptied.
+func (h *APIHandler) regenerateCardsForSingleNote(ctx context.Context, col *Collection, note *Note, deckID int64, templateAliases map[string]string) ([]Card, error) {
+	existingCards, err := h.store.GetCardsByNote(ctx, note.ID)
 	if err != nil {
 		return nil, err
 	}
 
 	if deckID == 0 && len(existingCards) > 0 {
-		deckID = existingCards[0].DeckID
+		deckID = existingCards[0].homeDeckID()
 	}
 	if deckID == 0 {
 		deckID = 1
@@ -269,19 +279,13 @@ func (h *APIHandler) regenerateCardsForSingleNote(col *Collection, note *Note, d
 	for _, generated := range newCards {
 		key := fmt.Sprintf("%s:%d", generated.TemplateName, generated.Ordinal)
 		if existingCard, ok := existingCardMap[key]; ok {
-			previousDeckID := existingCard.DeckID
 			existingCard.TemplateName = generated.TemplateName
 			existingCard.Ordinal = generated.Ordinal
-			existingCard.DeckID = generated.DeckID
 			existingCard.Front = generated.Front
 			existingCard.Back = generated.Back
-			if err := h.store.UpdateCard(existingCard); err != nil {
+			if err := h.store.UpdateCard(ctx, existingCard); err != nil {
 				return nil, err
 			}
-			if previousDeckID != existingCard.DeckID {
-				h.removeCardFromDeck(col, previousDeckID, existingCard.ID)
-				h.ensureCardOnDeck(col, existingCard.DeckID, existingCard.ID)
-			}
 			col.Cards[existingCard.ID] = existingCard
 			updatedCards = append(updatedCards, *existingCard)
 			delete(existingCardMap, key)
@@ -292,7 +296,7 @@ func (h *APIHandler) regenerateCardsForSingleNote(col *Collection, note *Note, d
 		}
 
 		h.allocateCardIdentity(col, generated)
-		if err := h.store.CreateCard(generated); err != nil {
+		if err := h.store.CreateCard(ctx, generated); err != nil {
 			return nil, err
 		}
 		col.Cards[generated.ID] = generated
@@ -314,7 +318,7 @@ func (h *APIHandler) regenerateCardsForSingleNote(col *Collection, note *Note, d
 			continue
 		}
 		seen[orphanCard.ID] = struct{}{}
-		if err := h.store.DeleteCard(orphanCard.ID); err != nil {
+		if err := h.store.DeleteCard(ctx, orphanCard.ID); err != nil {
 			return nil, err
 		}
 		h.removeCardFromDeck(col, orphanCard.DeckID, orphanCard.ID)
@@ -331,6 +335,28 @@ func (h *APIHandler) regenerateCardsForSingleNote(col *Collection, note *Note, d
 	return updatedCards, nil
 }
 
+func (h *APIHandler) noteListItem(ctx context.Context, note Note, col *Collection) (NoteListItemResponse, error) {
+	cards, err := h.store.GetCardsByNote(ctx, note.ID)
+	if err != nil {
+		return NoteListItemResponse{}, err
+	}
+	primaryDeckID, primaryDeckName := h.primaryDeckDetails(cards, col)
+	return NoteListItemResponse{
+		ID:           note.ID,
+		TypeID:       string(note.Type),
+		FieldVals:    note.FieldMap,
+		FieldPreview: h.noteFieldPreview(note, col),
+		Tags:         note.Tags,
+		CreatedAt:    note.CreatedAt,
+		ModifiedAt:   note.ModifiedAt,
+		DeckID:       primaryDeckID,
+		DeckName:     primaryDeckName,
+		CardCount:    len(cards),
+	}, nil
+}
+
+// ListNotes pages through notes with ?limit=&cursor= or ?page=&pageSize= (1-based),
+// filtered by ?deckId=, ?typeId=, ?tag=, and free text ?q=.
 func (h *APIHandler) ListNotes(w http.ResponseWriter, r *http.Request) {
 	col, collectionID, err := h.collectionForRequest(r)
 	if err != nil {
@@ -339,7 +365,11 @@ func (h *APIHandler) ListNotes(w http.ResponseWriter, r *http.Request) {
 	}
 
 	limit := 25
-	if rawLimit := strings.TrimSpace(r.URL.Query().Get("limit")); rawLimit != "" {
+	rawLimit := strings.TrimSpace(r.URL.Query().Get("limit"))
+	if rawPageSize := strings.TrimSpace(r.URL.Query().Get("pageSize")); rawPageSize != "" {
+		rawLimit = rawPageSize
+	}
+	if rawLimit != "" {
 		parsed, err := strconv.Atoi(rawLimit)
 		if err != nil || parsed <= 0 {
 			respondAPIError(w, http.StatusBadRequest, "invalid_limit", "Limit must be a positive integer")
@@ -355,20 +385,30 @@ func (h *APIHandler) ListNotes(w http.ResponseWriter, r *http.Request) {
 		respondAPIError(w, http.StatusBadRequest, "invalid_cursor", "Cursor must be a non-negative integer")
 		return
 	}
+	page := 0
+	if rawPage := strings.TrimSpace(r.URL.Query().Get("page")); rawPage != "" {
+		page, err = strconv.Atoi(rawPage)
+		if err != nil || page <= 0 {
+			respondAPIError(w, http.StatusBadRequest, "invalid_page", "Page must be a positive integer")
+			return
+		}
+		offset = (page - 1) * limit
+	}
 
-	deckFilter := int64(0)
+	filter := NoteListFilter{
+		TypeName: strings.TrimSpace(r.URL.Query().Get("typeId")),
+		Tag:      strings.TrimSpace(r.URL.Query().Get("tag")),
+		Query:    strings.TrimSpace(r.URL.Query().Get("q")),
+	}
 	if rawDeckID := strings.TrimSpace(r.URL.Query().Get("deckId")); rawDeckID != "" {
-		deckFilter, err = strconv.ParseInt(rawDeckID, 10, 64)
-		if err != nil || deckFilter <= 0 {
+		filter.DeckID, err = strconv.ParseInt(rawDeckID, 10, 64)
+		if err != nil || filter.DeckID <= 0 {
 			respondAPIError(w, http.StatusBadRequest, "invalid_deck_id", "Invalid deck ID")
 			return
 		}
 	}
-	typeFilter := strings.TrimSpace(r.URL.Query().Get("typeId"))
-	query := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("q")))
-	tagFilter := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("tag")))
 
-	notes, err := h.store.ListNotes(collectionID)
+	notes, total, err := h.store.ListNotesPage(r.Context(), collectionID, filter, offset, limit)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "notes_list_failed", err.Error())
 		return
@@ -376,65 +416,21 @@ func (h *APIHandler) ListNotes(w http.ResponseWriter, r *http.Request) {
 
 	items := make([]NoteListItemResponse, 0, len(notes))
 	for _, note := range notes {
-		cards, err := h.store.GetCardsByNote(note.ID)
+		item, err := h.noteListItem(r.Context(), note, col)
 		if err != nil {
 			respondAPIError(w, http.StatusInternalServerError, "note_cards_failed", err.Error())
 			return
 		}
-		if deckFilter > 0 {
-			inDeck := false
-			for _, card := range cards {
-				if card.DeckID == deckFilter {
-					inDeck = true
-					break
-				}
-			}
-			if !inDeck {
-				continue
-			}
-		}
-
-		preview := h.noteFieldPreview(note, col)
-		if !noteMatchesFilter(note, preview, query, tagFilter, typeFilter) {
-			continue
-		}
-
-		primaryDeckID, primaryDeckName := h.primaryDeckDetails(cards, col)
-		items = append(items, NoteListItemResponse{
-			ID:           note.ID,
-			TypeID:       string(note.Type),
-			FieldVals:    note.FieldMap,
-			FieldPreview: preview,
-			Tags:         note.Tags,
-			CreatedAt:    note.CreatedAt,
-			ModifiedAt:   note.ModifiedAt,
-			DeckID:       primaryDeckID,
-			DeckName:     primaryDeckName,
-			CardCount:    len(cards),
-		})
-	}
-
-	sort.Slice(items, func(i, j int) bool {
-		if items[i].ModifiedAt.Equal(items[j].ModifiedAt) {
-			return items[i].ID > items[j].ID
-		}
-		return items[i].ModifiedAt.After(items[j].ModifiedAt)
-	})
-
-	total := len(items)
-	if offset > total {
-		offset = total
-	}
-	end := offset + limit
-	if end > total {
-		end = total
+		items = append(items, item)
 	}
 
 	response := ListNotesResponse{
-		Notes: items[offset:end],
-		Total: total,
+		Notes:    items,
+		Total:    total,
+		Page:     page,
+		PageSize: limit,
 	}
-	if end < total {
+	if end := offset + len(items); end < total {
 		response.NextCursor = strconv.Itoa(end)
 	}
 	if offset > 0 {
@@ -444,6 +440,9 @@ func (h *APIHandler) ListNotes(w http.ResponseWriter, r *http.Request) {
 		}
 		response.PrevCursor = strconv.Itoa(prev)
 	}
+	if page > 0 {
+		response.TotalPages = (total + limit - 1) / limit
+	}
 
 	respondJSON(w, http.StatusOK, response)
 }
@@ -452,7 +451,7 @@ func (h *APIHandler) UpdateNote(w http.ResponseWriter, r *http.Request) {
 	if !h.requireWorkspaceWritePermission(w, r) {
 		return
 	}
-	col, _, err := h.collectionForRequest(r)
+	col, collectionID, err := h.collectionForRequest(r)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
@@ -465,27 +464,50 @@ func (h *APIHandler) UpdateNote(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req UpdateNoteRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
-	if req.TypeID == "" || req.DeckID == 0 {
-		respondAPIError(w, http.StatusBadRequest, "invalid_note_request", "TypeID and DeckID are required")
-		return
+	if req.FieldVals == nil {
+		req.FieldVals = req.FieldMap
 	}
 
-	note, err := h.store.GetNote(id)
+	note, err := h.store.GetNote(r.Context(), id)
 	if err != nil {
 		respondAPIError(w, http.StatusNotFound, "note_not_found", "Note not found")
 		return
 	}
 
-	existingCards, err := h.store.GetCardsByNote(id)
+	existingCards, err := h.store.GetCardsByNote(r.Context(), id)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "note_cards_failed", err.Error())
 		return
 	}
 
+	if req.TypeID == "" {
+		req.TypeID = string(note.Type)
+	}
+	currentDeckID, _ := h.primaryDeckDetails(existingCards, col)
+	if req.DeckID == 0 {
+		req.DeckID = currentDeckID
+	}
+	if req.TypeID == "" || req.DeckID == 0 {
+		respondAPIError(w, http.StatusBadRequest, "invalid_note_request", "TypeID and DeckID are required")
+		return
+	}
+	// Changing the note's deck moves its cards as POST /api/cards/move does, so it
+	// cannot name a filtered deck.
+	moveCards := req.DeckID != currentDeckID && len(existingCards) > 0
+	if moveCards {
+		if deckCollectionID, err := h.store.GetDeckCollectionID(r.Context(), req.DeckID); err != nil || deckCollectionID != collectionID {
+			respondAPIError(w, http.StatusBadRequest, "invalid_deck_id", "Deck not found")
+			return
+		}
+		if filtered, err := h.store.IsFilteredDeck(r.Context(), req.DeckID); err != nil || filtered {
+			respondAPIError(w, http.StatusBadRequest, "invalid_deck_id", "Cards cannot be moved into a filtered deck; rebuild it instead")
+			return
+		}
+	}
+
 	note.Type = NoteTypeName(req.TypeID)
 	note.FieldMap = sanitizeFieldVals(req.FieldVals)
 	note.Tags = sanitizeTags(req.Tags)
@@ -505,23 +527,41 @@ func (h *APIHandler) UpdateNote(w http.ResponseWriter, r *http.Request) {
 	}
 	session := h.sessionFromRequest(r)
 	plan := h.planForRequest(r, session)
-	usage := h.usageForSession(session)
+	usage := h.usageForSession(r.Context(), session)
 	if err := validateCardsTotalLimit(plan, usage, len(previewCards)-len(existingCards)); err != nil {
 		respondAPIError(w, http.StatusForbidden, "plan_limit_exceeded", err.Error())
 		return
 	}
 
-	if err := h.store.UpdateNote(note); err != nil {
+	if err := h.store.UpdateNote(r.Context(), note); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "note_update_failed", err.Error())
 		return
 	}
-	updatedCards, err := h.regenerateCardsForSingleNote(col, note, req.DeckID, nil)
+	if moveCards {
+		cardIDs := make([]int64, len(existingCards))
+		for i, card := range existingCards {
+			cardIDs[i] = card.ID
+		}
+		if err := h.store.MoveCards(r.Context(), cardIDs, req.DeckID); err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "card_move_failed", err.Error())
+			return
+		}
+		for _, card := range existingCards {
+			h.removeCardFromDeck(col, card.DeckID, card.ID)
+			h.ensureCardOnDeck(col, req.DeckID, card.ID)
+			if cached, ok := col.Cards[card.ID]; ok {
+				cached.DeckID, cached.OriginalDeckID = req.DeckID, 0
+			}
+		}
+	}
+	updatedCards, err := h.regenerateCardsForSingleNote(r.Context(), col, note, req.DeckID, nil)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "card_regeneration_failed", err.Error())
 		return
 	}
 	h.syncCollectionNote(col, note)
-	h.markStudyGroupInstallsForkedByDeckIDs(req.DeckID)
+	h.markStudyGroupInstallsForkedByDeckIDs(r.Context(), req.DeckID)
+	h.synthesizeNoteSpeech(r.Context(), collectionID, col, *note)
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"note":  h.noteToResponse(note, updatedCards),
@@ -533,7 +573,7 @@ func (h *APIHandler) DeleteNote(w http.ResponseWriter, r *http.Request) {
 	if !h.requireWorkspaceWritePermission(w, r) {
 		return
 	}
-	col, _, err := h.collectionForRequest(r)
+	col, collectionID, err := h.collectionForRequest(r)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
@@ -545,32 +585,25 @@ func (h *APIHandler) DeleteNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := h.store.GetNote(id); err != nil {
+	if _, err := h.store.GetNote(r.Context(), id); err != nil {
 		respondAPIError(w, http.StatusNotFound, "note_not_found", "Note not found")
 		return
 	}
 
-	cards, err := h.store.GetCardsByNote(id)
+	cards, err := h.store.DeleteNotesWithCards(r.Context(), []int64{id})
 	if err != nil {
-		respondAPIError(w, http.StatusInternalServerError, "note_cards_failed", err.Error())
+		respondAPIError(w, http.StatusInternalServerError, "note_delete_failed", err.Error())
 		return
 	}
 	deckIDs := make([]int64, 0, len(cards))
 	for _, card := range cards {
 		deckIDs = append(deckIDs, card.DeckID)
-		if err := h.store.DeleteCard(card.ID); err != nil {
-			respondAPIError(w, http.StatusInternalServerError, "card_delete_failed", err.Error())
-			return
-		}
 		h.removeCardFromDeck(col, card.DeckID, card.ID)
 		delete(col.Cards, card.ID)
 	}
-	if err := h.store.DeleteNote(id); err != nil {
-		respondAPIError(w, http.StatusInternalServerError, "note_delete_failed", err.Error())
-		return
-	}
 	delete(col.Notes, id)
-	h.markStudyGroupInstallsForkedByDeckIDs(deckIDs...)
+	h.markStudyGroupInstallsForkedByDeckIDs(r.Context(), deckIDs...)
+	h.cleanupAfterDelete(r.Context(), collectionID, col)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -591,47 +624,48 @@ func (h *APIHandler) UpdateDeck(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req UpdateDeckRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
-		return
-	}
-	if req.Name == nil && req.NewCardsPerDay == nil && req.ReviewsPerDay == nil && req.PriorityOrder == nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "At least one deck field is required")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
-	deck, err := h.store.GetDeck(id)
+	deck, err := h.store.GetDeck(r.Context(), id)
 	if err != nil {
 		respondAPIError(w, http.StatusNotFound, "deck_not_found", "Deck not found")
 		return
 	}
 
 	if req.Name != nil {
-		trimmed := strings.TrimSpace(*req.Name)
-		if trimmed == "" {
-			respondAPIError(w, http.StatusBadRequest, "invalid_name", "Deck name is required")
-			return
-		}
-		deck.Name = sanitizeHTML(trimmed)
+		deck.Name = sanitizeHTML(strings.TrimSpace(*req.Name))
 	}
 	if req.PriorityOrder != nil {
-		if *req.PriorityOrder <= 0 {
-			respondAPIError(w, http.StatusBadRequest, "invalid_priority_order", "Priority must be 1 or greater")
-			return
-		}
 		deck.PriorityOrder = *req.PriorityOrder
 	}
-	if req.NewCardsPerDay != nil || req.ReviewsPerDay != nil {
-		if req.NewCardsPerDay != nil && *req.NewCardsPerDay < 0 {
-			respondAPIError(w, http.StatusBadRequest, "invalid_new_cards_per_day", "New cards per day must be 0 or greater")
-			return
+	if req.ParentID != nil {
+		if *req.ParentID == 0 {
+			deck.ParentID = nil
+		} else {
+			if code, message := h.validateDeckParent(r.Context(), deck, *req.ParentID); code != "" {
+				respondAPIError(w, http.StatusBadRequest, code, message)
+				return
+			}
+			parentID := *req.ParentID
+			deck.ParentID = &parentID
 		}
-		if req.ReviewsPerDay != nil && *req.ReviewsPerDay < 0 {
-			respondAPIError(w, http.StatusBadRequest, "invalid_reviews_per_day", "Reviews per day must be 0 or greater")
-			return
+	}
+	if req.OptionsID != nil {
+		if *req.OptionsID == 0 {
+			deck.OptionsID = nil
+		} else {
+			if _, err := h.store.GetDeckOptionsInCollection(r.Context(), h.collectionIDForRequest(r), *req.OptionsID); err != nil {
+				respondAPIError(w, http.StatusBadRequest, "invalid_options_id", "Deck options preset not found")
+				return
+			}
+			optionsID := *req.OptionsID
+			deck.OptionsID = &optionsID
 		}
-
-		options, err := h.store.EnsureDeckOptionsForDeck(deck)
+	}
+	if req.NewCardsPerDay != nil || req.ReviewsPerDay != nil {
+		options, err := h.store.EnsureDeckOptionsForDeck(r.Context(), deck)
 		if err != nil {
 			respondAPIError(w, http.StatusInternalServerError, "deck_options_failed", err.Error())
 			return
@@ -643,30 +677,58 @@ func (h *APIHandler) UpdateDeck(w http.ResponseWriter, r *http.Request) {
 			options.ReviewsPerDay = *req.ReviewsPerDay
 		}
 		options.Name = fmt.Sprintf("%s settings", deck.Name)
-		if err := h.store.UpdateDeckOptions(options); err != nil {
+		if err := h.store.UpdateDeckOptions(r.Context(), options); err != nil {
 			respondAPIError(w, http.StatusInternalServerError, "deck_options_failed", err.Error())
 			return
 		}
 	}
-	if err := h.store.UpdateDeck(deck); err != nil {
+	if err := h.store.UpdateDeck(r.Context(), deck); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "deck_update_failed", err.Error())
 		return
 	}
 	if existing, ok := col.Decks[id]; ok {
 		existing.Name = deck.Name
+		existing.ParentID = deck.ParentID
 		existing.OptionsID = deck.OptionsID
 		existing.PriorityOrder = deck.PriorityOrder
 	}
-	h.markStudyGroupInstallsForkedByDeckIDs(id)
+	h.markStudyGroupInstallsForkedByDeckIDs(r.Context(), id)
 
-	respondJSON(w, http.StatusOK, h.deckResponse(h.userIDFromRequest(r), deck, col, nil))
+	respondJSON(w, http.StatusOK, h.deckResponse(r.Context(), h.userIDFromRequest(r), deck, col, nil))
+}
+
+// validateDeckParent checks that parentID can become deck's parent: it must be another
+// deck in the same collection and not one of deck's own descendants. It returns an error
+// code and message, or empty strings when the parent is acceptable.
+func (h *APIHandler) validateDeckParent(ctx context.Context, deck *Deck, parentID int64) (string, string) {
+	if parentID == deck.ID {
+		return "invalid_parent_id", "A deck cannot be its own parent"
+	}
+	deckCollectionID, err := h.store.GetDeckCollectionID(ctx, deck.ID)
+	if err != nil {
+		return "invalid_parent_id", "Deck not found"
+	}
+	parentCollectionID, err := h.store.GetDeckCollectionID(ctx, parentID)
+	if err != nil || parentCollectionID != deckCollectionID {
+		return "invalid_parent_id", "Parent deck not found"
+	}
+	ancestry, err := h.store.deckAncestry(ctx, parentID)
+	if err != nil {
+		return "invalid_parent_id", "Parent deck not found"
+	}
+	for _, ancestorID := range ancestry {
+		if ancestorID == deck.ID {
+			return "invalid_parent_id", "A deck cannot be moved under one of its sub-decks"
+		}
+	}
+	return "", ""
 }
 
 func (h *APIHandler) DeleteDeck(w http.ResponseWriter, r *http.Request) {
 	if !h.requireWorkspaceWritePermission(w, r) {
 		return
 	}
-	col, _, err := h.collectionForRequest(r)
+	col, collectionID, err := h.collectionForRequest(r)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
@@ -678,26 +740,99 @@ func (h *APIHandler) DeleteDeck(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	deck, err := h.store.GetDeck(id)
+	deck, err := h.store.GetDeck(r.Context(), id)
 	if err != nil {
 		respondAPIError(w, http.StatusNotFound, "deck_not_found", "Deck not found")
 		return
 	}
-	if len(deck.Cards) > 0 {
-		respondAPIError(w, http.StatusConflict, "deck_not_empty", "Only empty decks can be deleted right now. Move or delete the cards in this deck first.")
-		return
-	}
 	for _, candidate := range col.Decks {
 		if candidate.ParentID != nil && *candidate.ParentID == id {
 			respondAPIError(w, http.StatusConflict, "deck_has_children", "This deck has child decks. Move or delete those child decks first.")
 			return
 		}
 	}
-	if err := h.store.DeleteDeck(id); err != nil {
+
+	// A filtered deck only borrows its cards, so deleting it sends them home. Cards
+	// borrowed from a regular deck come back before it is deleted with its own.
+	filtered, err := h.store.IsFilteredDeck(r.Context(), id)
+	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "deck_delete_failed", err.Error())
 		return
 	}
+	if filtered {
+		moves, err := h.store.DeleteFilteredDeck(r.Context(), id)
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "deck_delete_failed", err.Error())
+			return
+		}
+		h.applyDeckMoves(col, moves)
+		delete(col.Decks, id)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	moves, err := h.store.ReturnBorrowedCards(r.Context(), id)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "deck_delete_failed", err.Error())
+		return
+	}
+	h.applyDeckMoves(col, moves)
+	for _, move := range moves {
+		deck.Cards = append(deck.Cards, move.CardID)
+	}
+
+	disposition := strings.TrimSpace(r.URL.Query().Get("cards"))
+	var targetDeckID *int64
+	switch disposition {
+	case "":
+		if len(deck.Cards) > 0 {
+			respondAPIError(w, http.StatusConflict, "deck_not_empty", "This deck has cards. Pass cards=delete to delete them or cards=move&targetDeckId= to move them.")
+			return
+		}
+	case "delete":
+	case "move":
+		target, err := strconv.ParseInt(strings.TrimSpace(r.URL.Query().Get("targetDeckId")), 10, 64)
+		if err != nil || target <= 0 || target == id {
+			respondAPIError(w, http.StatusBadRequest, "invalid_target_deck_id", "targetDeckId must be another deck")
+			return
+		}
+		deckCollectionID, err := h.store.GetDeckCollectionID(r.Context(), id)
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "deck_delete_failed", err.Error())
+			return
+		}
+		if targetCollectionID, err := h.store.GetDeckCollectionID(r.Context(), target); err != nil || targetCollectionID != deckCollectionID {
+			respondAPIError(w, http.StatusBadRequest, "invalid_target_deck_id", "Target deck not found")
+			return
+		}
+		targetDeckID = &target
+	default:
+		respondAPIError(w, http.StatusBadRequest, "invalid_card_disposition", "cards must be delete or move")
+		return
+	}
+
+	cards, deletedNoteIDs, err := h.store.DeleteDeckAndCards(r.Context(), id, targetDeckID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "deck_delete_failed", err.Error())
+		return
+	}
+	for _, card := range cards {
+		if targetDeckID != nil {
+			h.ensureCardOnDeck(col, card.DeckID, card.ID)
+			if cached, ok := col.Cards[card.ID]; ok {
+				cached.DeckID = card.DeckID
+			}
+			continue
+		}
+		delete(col.Cards, card.ID)
+	}
+	for _, noteID := range deletedNoteIDs {
+		delete(col.Notes, noteID)
+	}
 	delete(col.Decks, id)
+	if targetDeckID != nil {
+		h.markStudyGroupInstallsForkedByDeckIDs(r.Context(), *targetDeckID)
+	}
+	h.cleanupAfterDelete(r.Context(), collectionID, col)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -775,8 +910,7 @@ func (h *APIHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req CreateTemplateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
@@ -799,13 +933,16 @@ func (h *APIHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	nt.Templates = append(nt.Templates, template)
-	if err := h.store.UpdateNoteType(collectionID, &nt); err != nil {
+	if err := h.store.UpdateNoteType(r.Context(), collectionID, &nt); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "template_create_failed", err.Error())
 		return
 	}
 	col.NoteTypes[NoteTypeName(noteTypeName)] = nt
-	h.markStudyGroupInstallsForkedByNoteType(noteTypeName)
-	if err := h.regenerateCardsForNoteTypeInCollection(collectionID, col, noteTypeName); err != nil {
+	h.markStudyGroupInstallsForkedByNoteType(r.Context(), noteTypeName)
+	op := h.startOperation(w, r, operationRegenerateCards, "notes")
+	err = h.regenerateCardsForNoteTypeInCollection(r.Context(), collectionID, col, noteTypeName, op)
+	op.finish(err)
+	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "card_regeneration_failed", err.Error())
 		return
 	}
@@ -848,13 +985,16 @@ func (h *APIHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	nt.Templates = filtered
-	if err := h.store.UpdateNoteType(collectionID, &nt); err != nil {
+	if err := h.store.UpdateNoteType(r.Context(), collectionID, &nt); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "template_delete_failed", err.Error())
 		return
 	}
 	col.NoteTypes[NoteTypeName(noteTypeName)] = nt
-	h.markStudyGroupInstallsForkedByNoteType(noteTypeName)
-	if err := h.regenerateCardsForNoteTypeInCollection(collectionID, col, noteTypeName); err != nil {
+	h.markStudyGroupInstallsForkedByNoteType(r.Context(), noteTypeName)
+	op := h.startOperation(w, r, operationRegenerateCards, "notes")
+	err = h.regenerateCardsForNoteTypeInCollection(r.Context(), collectionID, col, noteTypeName, op)
+	op.finish(err)
+	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "card_regeneration_failed", err.Error())
 		return
 	}
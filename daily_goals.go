@@ -0,0 +1,70 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"time"
+)
+
+// Upper bounds for the daily goals in collection preferences.
+const (
+	maxDailyReviewsGoal = 10000
+	maxDailyMinutesGoal = 1440
+)
+
+// GoalProgress reports today's progress toward one daily goal. Target is 0 when the
+// goal is unset, in which case Percent is 0 and Met is false.
+type GoalProgress struct {
+	Target  int     `json:"target"`
+	Done    int     `json:"done"`
+	Percent float64 `json:"percent"`
+	Met     bool    `json:"met"`
+}
+
+// DailyGoalsProgress is served by GET /api/stats/goals.
+type DailyGoalsProgress struct {
+	Date    string       `json:"date"`
+	Reviews GoalProgress `json:"reviews"`
+	Minutes GoalProgress `json:"minutes"`
+}
+
+func goalProgress(target, done int) GoalProgress {
+	progress := GoalProgress{Target: target, Done: done}
+	if target > 0 {
+		progress.Percent = math.Min(math.Round(float64(done)/float64(target)*1000)/10, 100)
+		progress.Met = done >= target
+	}
+	return progress
+}
+
+// buildDailyGoalsProgress measures today's answers and study time against goals.
+// Minutes studied are whole minutes, rounded down.
+func buildDailyGoalsProgress(goals DailyGoals, today TodayStats) DailyGoalsProgress {
+	return DailyGoalsProgress{
+		Date:    today.Date,
+		Reviews: goalProgress(goals.ReviewsPerDay, today.Answers),
+		Minutes: goalProgress(goals.MinutesPerDay, int(today.TotalTimeMs/int64(time.Minute/time.Millisecond))),
+	}
+}
+
+// GetDailyGoalsProgress serves GET /api/stats/goals, reporting the user's progress
+// toward the collection's daily goals from the current study day's review log.
+func (h *APIHandler) GetDailyGoalsProgress(w http.ResponseWriter, r *http.Request) {
+	collectionID := h.collectionIDForRequest(r)
+	prefs, err := h.store.GetCollectionPreferences(r.Context(), collectionID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "preferences_load_failed", err.Error())
+		return
+	}
+	dayStart, dayEnd, err := h.store.studyDay(r.Context(), collectionID, time.Now())
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "goals_progress_failed", err.Error())
+		return
+	}
+	today, err := h.store.GetTodayStats(r.Context(), h.userIDFromRequest(r), collectionID, dayStart, dayEnd)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "goals_progress_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, buildDailyGoalsProgress(prefs.Goals, today))
+}
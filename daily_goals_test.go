@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAPI_DailyGoalsProgress(t *testing.T) {
+	env := setupAPITestEnv(t)
+	reviews, minutes := 2, 5
+	rr := doJSONRequest(t, env.router, http.MethodPatch, "/api/preferences", UpdatePreferencesRequest{ReviewsPerDay: &reviews, MinutesPerDay: &minutes})
+	if prefs := decodeJSON[CollectionPreferences](t, rr); rr.Code != http.StatusOK || prefs.Goals.ReviewsPerDay != 2 || prefs.Goals.MinutesPerDay != 5 {
+		t.Fatalf("expected goals saved, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	negative := -1
+	if rr := doJSONRequest(t, env.router, http.MethodPatch, "/api/preferences", UpdatePreferencesRequest{MinutesPerDay: &negative}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected negative goal to be rejected, got %d", rr.Code)
+	}
+
+	for _, front := range []string{"Ulna", "Radius"} {
+		card := createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": front, "Back": "Arm"},
+		}, nil).Cards[0]
+		rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", card.ID), AnswerCardRequest{Rating: 3, TimeTakenMs: 90000})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+	}
+
+	rr = doRawRequest(env.router, http.MethodGet, "/api/stats/goals", "")
+	progress := decodeJSON[DailyGoalsProgress](t, rr)
+	if rr.Code != http.StatusOK || progress.Reviews != (GoalProgress{Target: 2, Done: 2, Percent: 100, Met: true}) {
+		t.Fatalf("expected the reviews goal met, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if progress.Minutes != (GoalProgress{Target: 5, Done: 3, Percent: 60}) {
+		t.Fatalf("expected three of five minutes studied, got %+v", progress.Minutes)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mattn/go-sqlite3"
+)
+
+// A SQLite collection can be encrypted at rest with SQLCipher. The SQLite bundled with
+// go-sqlite3 cannot decrypt it, so an encrypting server is built against SQLCipher
+// instead, for example with `go build -tags libsqlite3` and CGO_LDFLAGS=-lsqlcipher.
+// The key comes from VUTADEX_DATABASE_KEY or, when VUTADEX_DATABASE_ENCRYPTED is set
+// without one, from POST /api/unlock on a server that waits for it before starting.
+
+var (
+	errSQLCipherUnavailable = errors.New("database encryption needs a server built against SQLCipher")
+	errWrongDatabaseKey     = errors.New("the database key is wrong or the database is not encrypted")
+)
+
+const unlockFailureDelay = time.Second
+
+// openEncryptedSQLite opens the SQLite file at path with SQLCipher, keying every
+// connection before anything reads the file.
+func openEncryptedSQLite(path, key string) *sql.DB {
+	if path == "" {
+		path = defaultDatabasePath
+	}
+	sqlDriver := &sqlite3.SQLiteDriver{ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+		return keySQLiteConn(conn, key)
+	}}
+	return sql.OpenDB(sqliteConnector{dsn: sqliteDSN(path, false), driver: sqlDriver})
+}
+
+func keySQLiteConn(conn *sqlite3.SQLiteConn, key string) error {
+	if _, err := conn.Exec("PRAGMA key = '"+strings.ReplaceAll(key, "'", "''")+"'", nil); err != nil {
+		return err
+	}
+	// Plain SQLite ignores PRAGMA key; only SQLCipher answers cipher_version.
+	rows, err := conn.Query("PRAGMA cipher_version", nil)
+	if err != nil {
+		return err
+	}
+	err = rows.Next(make([]driver.Value, len(rows.Columns())))
+	_ = rows.Close()
+	if errors.Is(err, io.EOF) {
+		return errSQLCipherUnavailable
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Exec("SELECT count(*) FROM sqlite_master", nil); err != nil {
+		return fmt.Errorf("%w: %v", errWrongDatabaseKey, err)
+	}
+	if _, err := conn.Exec("PRAGMA foreign_keys = ON", nil); err != nil {
+		return err
+	}
+	_, err = conn.Exec("PRAGMA journal_mode = WAL", nil)
+	return err
+}
+
+// sqliteConnector lets a database/sql pool use a driver with its own ConnectHook.
+type sqliteConnector struct {
+	dsn    string
+	driver *sqlite3.SQLiteDriver
+}
+
+func (c sqliteConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c sqliteConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type UnlockDatabaseRequest struct {
+	Key string `json:"key"`
+}
+
+func (req *UnlockDatabaseRequest) Validate(v *requestValidator) {
+	v.required("key", req.Key)
+}
+
+// unlockHandler serves a locked server: POST /api/unlock with the key that opens the
+// database sends it on unlocked, and everything else answers 503 until then. Failed
+// attempts are slowed down and made one at a time.
+func unlockHandler(cfg DatabaseConfig, unlocked chan<- string) http.Handler {
+	var attempts sync.Mutex
+	router := chi.NewRouter()
+	router.Post("/api/unlock", func(w http.ResponseWriter, r *http.Request) {
+		var req UnlockDatabaseRequest
+		if !decodeValidatedRequest(w, r, &req) {
+			return
+		}
+		attempts.Lock()
+		defer attempts.Unlock()
+		keyed := cfg
+		keyed.Key = req.Key
+		store, err := OpenStore(keyed)
+		switch {
+		case errors.Is(err, errSQLCipherUnavailable):
+			respondAPIError(w, http.StatusNotImplemented, "encryption_unavailable", err.Error())
+			return
+		case errors.Is(err, errWrongDatabaseKey):
+			time.Sleep(unlockFailureDelay)
+			respondAPIError(w, http.StatusUnauthorized, "wrong_database_key", errWrongDatabaseKey.Error())
+			return
+		case err != nil:
+			respondAPIError(w, http.StatusInternalServerError, "unlock_failed", err.Error())
+			return
+		}
+		_ = store.Close()
+		select {
+		case unlocked <- req.Key:
+			respondJSON(w, http.StatusOK, map[string]string{"status": "unlocked"})
+		default:
+			respondAPIError(w, http.StatusConflict, "already_unlocked", "The database is already unlocked")
+		}
+	})
+	router.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		respondAPIError(w, http.StatusServiceUnavailable, "database_locked", "The database is locked; POST its key to /api/unlock")
+	})
+	return router
+}
+
+// waitForDatabaseKey serves unlockHandler on the server's address until a client
+// supplies the key, and returns it.
+func waitForDatabaseKey(cfg AppConfig, tlsConfig *serverTLS, addr string) (string, error) {
+	unlocked := make(chan string, 1)
+	server := &http.Server{Addr: addr, Handler: unlockHandler(cfg.Database, unlocked)}
+	serveErr := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			server.TLSConfig = tlsConfig.Config
+			serveErr <- server.ListenAndServeTLS("", "")
+			return
+		}
+		serveErr <- server.ListenAndServe()
+	}()
+	log.Printf("Database is encrypted; waiting for its key at POST %s/api/unlock", addr)
+
+	select {
+	case key := <-unlocked:
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			return "", err
+		}
+		log.Printf("Database unlocked")
+		return key, nil
+	case err := <-serveErr:
+		return "", err
+	}
+}
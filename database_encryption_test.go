@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenStoreWithKeyEncryptsOrNeedsSQLCipher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "encrypted.db")
+	store, err := OpenStore(DatabaseConfig{Mode: DatabaseModeSQLite, Path: path, Encrypted: true, Key: "correct horse"})
+	if errors.Is(err, errSQLCipherUnavailable) {
+		// Built against the SQLite bundled with go-sqlite3.
+		return
+	}
+	if err != nil {
+		t.Fatalf("failed to open encrypted store: %v", err)
+	}
+	_ = store.Close()
+
+	if store, err := NewSQLiteStore(path); err == nil {
+		_ = store.Close()
+		t.Fatalf("expected the encrypted database to be unreadable without its key")
+	}
+	_, err = OpenStore(DatabaseConfig{Mode: DatabaseModeSQLite, Path: path, Encrypted: true, Key: "wrong"})
+	if !errors.Is(err, errWrongDatabaseKey) {
+		t.Fatalf("expected a wrong key to be reported, got %v", err)
+	}
+}
+
+func TestUnlockHandlerAnswersLockedUntilUnlocked(t *testing.T) {
+	unlocked := make(chan string, 1)
+	handler := unlockHandler(DatabaseConfig{Mode: DatabaseModeSQLite, Path: filepath.Join(t.TempDir(), "locked.db"), Encrypted: true}, unlocked)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/decks", nil))
+	if rr.Code != http.StatusServiceUnavailable || !strings.Contains(rr.Body.String(), "database_locked") {
+		t.Fatalf("expected a locked server to answer 503, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/unlock", strings.NewReader(`{}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected unlock without a key to answer 400, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/unlock", strings.NewReader(`{"key":"correct horse"}`)))
+	switch rr.Code {
+	case http.StatusNotImplemented:
+		// Built against the SQLite bundled with go-sqlite3.
+	case http.StatusOK:
+		if key := <-unlocked; key != "correct horse" {
+			t.Fatalf("expected the key to be handed over, got %q", key)
+		}
+	default:
+		t.Fatalf("expected unlock to answer 200 or 501, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
+
+func TestLoadAppConfigDatabaseKeyImpliesEncryption(t *testing.T) {
+	t.Setenv("VUTADEX_DATABASE_KEY", "correct horse")
+	cfg, err := LoadAppConfig()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if !cfg.Database.Encrypted || cfg.Database.Key != "correct horse" {
+		t.Fatalf("expected the key to enable encryption, got %+v", cfg.Database)
+	}
+
+	t.Setenv("VUTADEX_DATABASE_ENCRYPTED", "false")
+	if _, err := LoadAppConfig(); err == nil || !strings.Contains(err.Error(), "VUTADEX_DATABASE_KEY") {
+		t.Fatalf("expected a key without encryption to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAPI_DeleteDeckMovesOrDeletesCards(t *testing.T) {
+	env := setupAPITestEnv(t)
+	sessionID := strings.TrimPrefix(env.authCookie, sessionCookieName+"=")
+	sessionRecord, err := env.store.GetSessionRecord(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("failed to load current session: %v", err)
+	}
+	activateWorkspaceSubscriptionForTest(t, env, sessionRecord.WorkspaceID, PlanPro)
+
+	createDeck := func(name string) int64 {
+		t.Helper()
+		rr := doJSONRequest(t, env.router, http.MethodPost, "/api/decks", CreateDeckRequest{Name: name})
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected deck create 201, got %d (%s)", rr.Code, rr.Body.String())
+		}
+		return decodeJSON[DeckResponse](t, rr).ID
+	}
+	addNotes := func(deckID int64, n int) []createNoteAPIResponse {
+		t.Helper()
+		out := make([]createNoteAPIResponse, 0, n)
+		for i := 0; i < n; i++ {
+			out = append(out, createNoteForTest(t, env, CreateNoteRequest{
+				TypeID:    "Basic",
+				DeckID:    deckID,
+				FieldVals: map[string]string{"Front": fmt.Sprintf("Deck %d note %d", deckID, i), "Back": "Answer"},
+			}, nil))
+		}
+		return out
+	}
+	count := func(query string, args ...interface{}) int {
+		t.Helper()
+		var n int
+		if err := env.store.db.QueryRow(query, args...).Scan(&n); err != nil {
+			t.Fatalf("count failed: %v", err)
+		}
+		return n
+	}
+
+	source := createDeck("Source")
+	target := createDeck("Target")
+	moved := addNotes(source, 2)
+	answered := moved[0].Cards[0].ID
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", answered), AnswerCardRequest{Rating: 3}); rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	if rr := doRawRequest(env.router, http.MethodDelete, fmt.Sprintf("/api/decks/%d", source), ""); rr.Code != http.StatusConflict {
+		t.Fatalf("expected non-empty delete without disposition 409, got %d", rr.Code)
+	}
+	for _, query := range []string{"cards=archive", fmt.Sprintf("cards=move&targetDeckId=%d", source), "cards=move&targetDeckId=999999"} {
+		if rr := doRawRequest(env.router, http.MethodDelete, fmt.Sprintf("/api/decks/%d?%s", source, query), ""); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected %q to be rejected with 400, got %d", query, rr.Code)
+		}
+	}
+
+	rr := doRawRequest(env.router, http.MethodDelete, fmt.Sprintf("/api/decks/%d?cards=move&targetDeckId=%d", source, target), "")
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected move delete 204, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if n := count(`SELECT COUNT(*) FROM cards WHERE deck_id = ?`, target); n != 2 {
+		t.Fatalf("expected 2 cards moved to target, got %d", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM revlog WHERE card_id = ?`, answered); n != 1 {
+		t.Fatalf("expected moved card to keep its history, got %d revlog rows", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM decks WHERE id = ?`, source); n != 0 {
+		t.Fatalf("expected source deck to be removed")
+	}
+
+	rr = doRawRequest(env.router, http.MethodDelete, fmt.Sprintf("/api/decks/%d?cards=delete", target), "")
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected delete with cards 204, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	for _, note := range moved {
+		if n := count(`SELECT COUNT(*) FROM notes WHERE id = ?`, note.Note.ID); n != 0 {
+			t.Fatalf("expected note %d to be deleted with its only cards", note.Note.ID)
+		}
+	}
+	if n := count(`SELECT COUNT(*) FROM revlog WHERE card_id = ?`, answered); n != 0 {
+		t.Fatalf("expected deleted card's revlog to be removed, got %d", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM decks WHERE id = ?`, target); n != 0 {
+		t.Fatalf("expected target deck to be removed")
+	}
+}
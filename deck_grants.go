@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// A deck's owner can share it with other users by email. Grantees read the owner's
+// notes and cards but cannot change them; a student also studies the deck, with the
+// scheduling state kept per user in card_review_states like any other card they study.
+const (
+	deckGrantRoleStudent = "student"
+	deckGrantRoleViewer  = "viewer"
+)
+
+// DeckGrant gives one user a role on a deck and its subdecks.
+type DeckGrant struct {
+	ID          string    `json:"id"`
+	DeckID      int64     `json:"deckId"`
+	OwnerUserID string    `json:"ownerUserId"`
+	Email       string    `json:"email"`
+	UserID      string    `json:"userId,omitempty"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type CreateDeckGrantRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role,omitempty"`
+}
+
+func (req *CreateDeckGrantRequest) Validate(v *requestValidator) {
+	v.email("email", req.Email)
+	v.oneOf("role", req.Role, deckGrantRoleStudent, deckGrantRoleViewer)
+}
+
+// SharedDeckSummary is a deck shared with the caller, as listed by GET
+// /api/shared-decks. DueToday is only counted for students.
+type SharedDeckSummary struct {
+	DeckID     int64  `json:"deckId"`
+	DeckName   string `json:"deckName"`
+	Role       string `json:"role"`
+	OwnerEmail string `json:"ownerEmail"`
+	OwnerName  string `json:"ownerName,omitempty"`
+	TotalCards int    `json:"totalCards"`
+	DueToday   int    `json:"dueToday"`
+}
+
+// SharedCard is the read-only content of a card in a shared deck.
+type SharedCard struct {
+	ID     int64  `json:"id"`
+	NoteID int64  `json:"noteId"`
+	DeckID int64  `json:"deckId"`
+	Front  string `json:"front"`
+	Back   string `json:"back"`
+}
+
+// UpsertDeckGrant grants email access to the deck, changing the role of an existing
+// grant to the same email.
+func (s *SQLStore) UpsertDeckGrant(ctx context.Context, grant *DeckGrant) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO deck_grants (id, deck_id, owner_user_id, email, user_id, role, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(deck_id, email) DO UPDATE SET
+			role = excluded.role,
+			user_id = COALESCE(excluded.user_id, deck_grants.user_id)
+	`,
+		grant.ID,
+		grant.DeckID,
+		grant.OwnerUserID,
+		grant.Email,
+		nullIfEmpty(grant.UserID),
+		grant.Role,
+		grant.CreatedAt.Unix(),
+	)
+	if err != nil {
+		return err
+	}
+	stored, err := s.getDeckGrantByEmail(ctx, grant.DeckID, grant.Email)
+	if err != nil {
+		return err
+	}
+	*grant = *stored
+	return nil
+}
+
+const deckGrantColumns = `id, deck_id, owner_user_id, email, user_id, role, created_at`
+
+func scanDeckGrant(scanner interface{ Scan(dest ...any) error }) (*DeckGrant, error) {
+	var grant DeckGrant
+	var userID sql.NullString
+	var createdAt int64
+	if err := scanner.Scan(&grant.ID, &grant.DeckID, &grant.OwnerUserID, &grant.Email, &userID, &grant.Role, &createdAt); err != nil {
+		return nil, err
+	}
+	grant.UserID = userID.String
+	grant.CreatedAt = time.Unix(createdAt, 0)
+	return &grant, nil
+}
+
+func (s *SQLStore) getDeckGrantByEmail(ctx context.Context, deckID int64, email string) (*DeckGrant, error) {
+	return scanDeckGrant(s.db.QueryRowContext(ctx, `SELECT `+deckGrantColumns+` FROM deck_grants WHERE deck_id = ? AND email = ?`, deckID, email))
+}
+
+func (s *SQLStore) ListDeckGrants(ctx context.Context, deckID int64) ([]DeckGrant, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+deckGrantColumns+` FROM deck_grants WHERE deck_id = ? ORDER BY email`, deckID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []DeckGrant{}
+	for rows.Next() {
+		grant, err := scanDeckGrant(rows)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, *grant)
+	}
+	return grants, rows.Err()
+}
+
+// DeleteDeckGrant revokes a grant on the deck, returning sql.ErrNoRows when there is
+// no such grant.
+func (s *SQLStore) DeleteDeckGrant(ctx context.Context, deckID int64, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM deck_grants WHERE id = ? AND deck_id = ?`, id, deckID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListDeckGrantsForUser returns the grants made to the user, by id or, for grants made
+// before they signed up, by email.
+func (s *SQLStore) ListDeckGrantsForUser(ctx context.Context, userID, email string) ([]DeckGrant, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+deckGrantColumns+`
+		FROM deck_grants
+		WHERE user_id = ? OR (user_id IS NULL AND email = ?)
+		ORDER BY created_at, id
+	`, userID, strings.ToLower(email))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []DeckGrant{}
+	for rows.Next() {
+		grant, err := scanDeckGrant(rows)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, *grant)
+	}
+	return grants, rows.Err()
+}
+
+// GetDeckGrantForUser returns the user's grant on the deck, sql.ErrNoRows if none.
+func (s *SQLStore) GetDeckGrantForUser(ctx context.Context, deckID int64, userID, email string) (*DeckGrant, error) {
+	return scanDeckGrant(s.db.QueryRowContext(ctx, `
+		SELECT `+deckGrantColumns+`
+		FROM deck_grants
+		WHERE deck_id = ? AND (user_id = ? OR (user_id IS NULL AND email = ?))
+	`, deckID, userID, strings.ToLower(email)))
+}
+
+// GetSharedDeckCards returns the cards whose home deck is deckID or one of its
+// descendants, ordered by id.
+func (s *SQLStore) GetSharedDeckCards(ctx context.Context, deckID int64) ([]SharedCard, error) {
+	rows, err := s.db.QueryContext(ctx, deckSubtreeCTE+`
+		SELECT c.id, c.note_id, c.deck_id, COALESCE(c.front, ''), COALESCE(c.back, '')
+		FROM cards c
+		JOIN subtree ON subtree.id = CASE WHEN c.original_deck_id > 0 THEN c.original_deck_id ELSE c.deck_id END
+		ORDER BY c.id
+	`, deckID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cards := []SharedCard{}
+	for rows.Next() {
+		var card SharedCard
+		if err := rows.Scan(&card.ID, &card.NoteID, &card.DeckID, &card.Front, &card.Back); err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+	return cards, rows.Err()
+}
+
+// deckInSubtree reports whether deckID is root or one of its descendants.
+func (s *SQLStore) deckInSubtree(ctx context.Context, root, deckID int64) (bool, error) {
+	var count int
+	if err := s.db.QueryRowContext(ctx, deckSubtreeCTE+`SELECT COUNT(*) FROM subtree WHERE id = ?`, root, deckID).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// loadOwnedDeckForGrants resolves the deck in the URL for its owner, writing an error
+// response and returning 0 unless it is in the caller's collection and they may edit it.
+func (h *APIHandler) loadOwnedDeckForGrants(w http.ResponseWriter, r *http.Request) int64 {
+	deckID, err := parseIDParam(r, "id")
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_deck_id", "Invalid deck ID")
+		return 0
+	}
+	collectionID, err := h.store.GetDeckCollectionID(r.Context(), deckID)
+	if err != nil || collectionID != h.collectionIDForRequest(r) {
+		respondAPIError(w, http.StatusNotFound, "deck_not_found", "Deck not found")
+		return 0
+	}
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return 0
+	}
+	return deckID
+}
+
+// ListDeckGrants serves GET /api/decks/{id}/grants.
+func (h *APIHandler) ListDeckGrants(w http.ResponseWriter, r *http.Request) {
+	deckID := h.loadOwnedDeckForGrants(w, r)
+	if deckID == 0 {
+		return
+	}
+	grants, err := h.store.ListDeckGrants(r.Context(), deckID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "deck_grants_list_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, grants)
+}
+
+// CreateDeckGrant serves POST /api/decks/{id}/grants, sharing the deck with a user as
+// a student (the default) or a viewer. Granting an email again changes its role.
+func (h *APIHandler) CreateDeckGrant(w http.ResponseWriter, r *http.Request) {
+	deckID := h.loadOwnedDeckForGrants(w, r)
+	if deckID == 0 {
+		return
+	}
+	var req CreateDeckGrantRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	email, _ := normalizeEmail(req.Email)
+	role := firstNonEmpty(strings.TrimSpace(req.Role), deckGrantRoleStudent)
+	if filtered, err := h.store.IsFilteredDeck(r.Context(), deckID); err != nil || filtered {
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "deck_lookup_failed", err.Error())
+			return
+		}
+		respondAPIError(w, http.StatusBadRequest, "filtered_deck", "Filtered decks cannot be shared")
+		return
+	}
+
+	session := h.sessionFromRequest(r)
+	grantee, err := h.store.GetUserByEmail(r.Context(), email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondAPIError(w, http.StatusInternalServerError, "user_lookup_failed", err.Error())
+		return
+	}
+	grant := &DeckGrant{
+		ID:          newID("dgr"),
+		DeckID:      deckID,
+		OwnerUserID: session.UserID,
+		Email:       email,
+		Role:        role,
+		CreatedAt:   time.Now(),
+	}
+	if grantee != nil {
+		if grantee.ID == session.UserID {
+			respondAPIError(w, http.StatusBadRequest, "invalid_email", "You already own this deck")
+			return
+		}
+		grant.UserID = grantee.ID
+	}
+	if err := h.store.UpsertDeckGrant(r.Context(), grant); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "deck_grant_create_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusCreated, grant)
+}
+
+// DeleteDeckGrant serves DELETE /api/decks/{id}/grants/{grantId}. The grantee keeps the
+// review history of what they studied, but loses access to the deck.
+func (h *APIHandler) DeleteDeckGrant(w http.ResponseWriter, r *http.Request) {
+	deckID := h.loadOwnedDeckForGrants(w, r)
+	if deckID == 0 {
+		return
+	}
+	if err := h.store.DeleteDeckGrant(r.Context(), deckID, chi.URLParam(r, "grantId")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondAPIError(w, http.StatusNotFound, "deck_grant_not_found", "Grant not found")
+			return
+		}
+		respondAPIError(w, http.StatusInternalServerError, "deck_grant_delete_failed", err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadSharedDeckGrant resolves the caller's grant on the shared deck in the URL,
+// writing an error response and returning nil when there is none or, if studentOnly,
+// when it does not let them study.
+func (h *APIHandler) loadSharedDeckGrant(w http.ResponseWriter, r *http.Request, studentOnly bool) *DeckGrant {
+	deckID, err := parseIDParam(r, "deckId")
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_deck_id", "Invalid deck ID")
+		return nil
+	}
+	session := h.sessionFromRequest(r)
+	user, err := h.store.GetUserByID(r.Context(), session.UserID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "user_lookup_failed", err.Error())
+		return nil
+	}
+	grant, err := h.store.GetDeckGrantForUser(r.Context(), deckID, user.ID, user.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondAPIError(w, http.StatusNotFound, "shared_deck_not_found", "Shared deck not found")
+			return nil
+		}
+		respondAPIError(w, http.StatusInternalServerError, "shared_deck_lookup_failed", err.Error())
+		return nil
+	}
+	if studentOnly && grant.Role != deckGrantRoleStudent {
+		respondAPIError(w, http.StatusForbidden, "shared_deck_forbidden", "Only students can study this deck")
+		return nil
+	}
+	return grant
+}
+
+// ListSharedDecks serves GET /api/shared-decks.
+func (h *APIHandler) ListSharedDecks(w http.ResponseWriter, r *http.Request) {
+	session := h.sessionFromRequest(r)
+	user, err := h.store.GetUserByID(r.Context(), session.UserID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "user_lookup_failed", err.Error())
+		return
+	}
+	grants, err := h.store.ListDeckGrantsForUser(r.Context(), user.ID, user.Email)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "shared_decks_list_failed", err.Error())
+		return
+	}
+
+	summaries := make([]SharedDeckSummary, 0, len(grants))
+	for _, grant := range grants {
+		deck, err := h.store.GetDeck(r.Context(), grant.DeckID)
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "shared_decks_list_failed", err.Error())
+			return
+		}
+		summary := SharedDeckSummary{DeckID: grant.DeckID, DeckName: deck.Name, Role: grant.Role}
+		if owner, err := h.store.GetUserByID(r.Context(), grant.OwnerUserID); err == nil {
+			summary.OwnerEmail = owner.Email
+			summary.OwnerName = owner.DisplayName
+		}
+		statsUserID := user.ID
+		if grant.Role != deckGrantRoleStudent {
+			statsUserID = grant.OwnerUserID
+		}
+		stats, err := h.store.GetDeckStatsForUser(r.Context(), statsUserID, grant.DeckID)
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "shared_decks_list_failed", err.Error())
+			return
+		}
+		summary.TotalCards = stats.TotalCards
+		if grant.Role == deckGrantRoleStudent {
+			summary.DueToday = stats.DueToday
+		}
+		summaries = append(summaries, summary)
+	}
+	respondJSON(w, http.StatusOK, summaries)
+}
+
+// GetSharedDeckCards serves GET /api/shared-decks/{deckId}/cards, the deck's content
+// without anyone's scheduling state.
+func (h *APIHandler) GetSharedDeckCards(w http.ResponseWriter, r *http.Request) {
+	grant := h.loadSharedDeckGrant(w, r, false)
+	if grant == nil {
+		return
+	}
+	cards, err := h.store.GetSharedDeckCards(r.Context(), grant.DeckID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "shared_deck_cards_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, cards)
+}
+
+// GetSharedDeckDueCards serves GET /api/shared-decks/{deckId}/due, a student's study
+// queue for the deck and its subdecks under the owner's deck options.
+func (h *APIHandler) GetSharedDeckDueCards(w http.ResponseWriter, r *http.Request) {
+	grant := h.loadSharedDeckGrant(w, r, true)
+	if grant == nil {
+		return
+	}
+	col, err := h.sharedDeckCollection(r.Context(), grant)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	userID := h.userIDFromRequest(r)
+	cards, err := h.store.GetSubtreeDueCardsForUser(r.Context(), userID, grant.DeckID, 100)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "due_cards_failed", err.Error())
+		return
+	}
+	if err := h.attachNextIntervals(r.Context(), col, cards, time.Now()); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "due_cards_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, cards)
+}
+
+// AnswerSharedDeckCard serves POST /api/shared-decks/{deckId}/cards/{cardId}/answer.
+// The answer only changes the student's own scheduling state; a leech is suspended for
+// them without tagging the owner's note.
+func (h *APIHandler) AnswerSharedDeckCard(w http.ResponseWriter, r *http.Request) {
+	grant := h.loadSharedDeckGrant(w, r, true)
+	if grant == nil {
+		return
+	}
+	cardID, err := parseIDParam(r, "cardId")
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_card_id", "Invalid card ID")
+		return
+	}
+	var req AnswerCardRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+
+	userID := h.userIDFromRequest(r)
+	card, err := h.store.GetCardForUser(r.Context(), userID, cardID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondAPIError(w, http.StatusNotFound, "card_not_found", "Card not found")
+			return
+		}
+		respondAPIError(w, http.StatusInternalServerError, "card_lookup_failed", err.Error())
+		return
+	}
+	homeDeckID := card.DeckID
+	if card.OriginalDeckID > 0 {
+		homeDeckID = card.OriginalDeckID
+	}
+	if shared, err := h.store.deckInSubtree(r.Context(), grant.DeckID, homeDeckID); err != nil || !shared {
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "card_lookup_failed", err.Error())
+			return
+		}
+		respondAPIError(w, http.StatusNotFound, "card_not_found", "Card not found")
+		return
+	}
+	col, err := h.sharedDeckCollection(r.Context(), grant)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	if err := h.applyAnswerFor(r.Context(), col, userID, card, fsrs.Rating(req.Rating), req.TimeTakenMs, time.Now(), false); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "answer_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, card)
+}
+
+// sharedDeckCollection loads the owner's collection, whose settings schedule the deck.
+func (h *APIHandler) sharedDeckCollection(ctx context.Context, grant *DeckGrant) (*Collection, error) {
+	collectionID, err := h.store.GetDeckCollectionID(ctx, grant.DeckID)
+	if err != nil {
+		return nil, err
+	}
+	return h.store.GetCollection(ctx, collectionID)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAPI_DeckGrantsShareContentWithPerStudentScheduling(t *testing.T) {
+	env := setupAPITestEnv(t)
+	student := createAuthenticatedIsolatedTestClient(t, env, "student@example.com", "Student")
+	viewer := createAuthenticatedIsolatedTestClient(t, env, "viewer@example.com", "Viewer")
+
+	for _, front := range []string{"Mitosis", "Meiosis"} {
+		createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": front, "Back": "Cell division"},
+		}, nil)
+	}
+
+	if rr := doRawRequest(student.router, http.MethodGet, "/api/shared-decks/1/cards", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 before the deck is shared, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequest(t, student.router, http.MethodPost, "/api/decks/1/grants", CreateDeckGrantRequest{Email: student.user.Email}); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected a non-owner grant to be rejected with 404, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	grantRR := doJSONRequest(t, env.router, http.MethodPost, "/api/decks/1/grants", CreateDeckGrantRequest{Email: "Student@Example.com"})
+	if grantRR.Code != http.StatusCreated {
+		t.Fatalf("expected grant 201, got %d (%s)", grantRR.Code, grantRR.Body.String())
+	}
+	grant := decodeJSON[DeckGrant](t, grantRR)
+	if grant.Role != deckGrantRoleStudent || grant.UserID != student.user.ID || grant.Email != student.user.Email {
+		t.Fatalf("expected a student grant for the existing user, got %+v", grant)
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/decks/1/grants", CreateDeckGrantRequest{Email: viewer.user.Email, Role: deckGrantRoleViewer}); rr.Code != http.StatusCreated {
+		t.Fatalf("expected viewer grant 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/decks/1/grants", CreateDeckGrantRequest{Email: viewer.user.Email, Role: "editor"}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown role to be rejected, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if grants := decodeJSON[[]DeckGrant](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/grants", "")); len(grants) != 2 {
+		t.Fatalf("expected two grants, got %+v", grants)
+	}
+
+	sharedRR := doRawRequest(student.router, http.MethodGet, "/api/shared-decks", "")
+	shared := decodeJSON[[]SharedDeckSummary](t, sharedRR)
+	if sharedRR.Code != http.StatusOK || len(shared) != 1 || shared[0].DeckID != 1 || shared[0].TotalCards != 2 || shared[0].DueToday != 2 {
+		t.Fatalf("expected the shared deck with two due cards, got %d (%s)", sharedRR.Code, sharedRR.Body.String())
+	}
+	if cards := decodeJSON[[]SharedCard](t, doRawRequest(viewer.router, http.MethodGet, "/api/shared-decks/1/cards", "")); len(cards) != 2 {
+		t.Fatalf("expected the viewer to read both cards, got %+v", cards)
+	}
+	if rr := doRawRequest(viewer.router, http.MethodGet, "/api/shared-decks/1/due", ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected a viewer to be refused the study queue, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	dueRR := doRawRequest(student.router, http.MethodGet, "/api/shared-decks/1/due", "")
+	due := decodeJSON[[]Card](t, dueRR)
+	if dueRR.Code != http.StatusOK || len(due) != 2 || due[0].NextIntervals == nil {
+		t.Fatalf("expected two due cards with intervals, got %d (%s)", dueRR.Code, dueRR.Body.String())
+	}
+	answerPath := fmt.Sprintf("/api/shared-decks/1/cards/%d/answer", due[0].ID)
+	answerRR := doJSONRequest(t, student.router, http.MethodPost, answerPath, AnswerCardRequest{Rating: 4})
+	if answered := decodeJSON[Card](t, answerRR); answerRR.Code != http.StatusOK || answered.SRS.Reps != 1 {
+		t.Fatalf("expected the student's answer to schedule the card, got %d (%s)", answerRR.Code, answerRR.Body.String())
+	}
+	if rr := doJSONRequest(t, viewer.router, http.MethodPost, answerPath, AnswerCardRequest{Rating: 3}); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected a viewer's answer to be refused, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	ownerCard := decodeJSON[Card](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/cards/%d", due[0].ID), ""))
+	if ownerCard.SRS.Reps != 0 {
+		t.Fatalf("expected the owner's scheduling to be untouched, got %+v", ownerCard.SRS)
+	}
+	if shared := decodeJSON[[]SharedDeckSummary](t, doRawRequest(student.router, http.MethodGet, "/api/shared-decks", "")); len(shared) != 1 || shared[0].DueToday != 1 {
+		t.Fatalf("expected one card still due for the student, got %+v", shared)
+	}
+
+	if rr := doRawRequest(env.router, http.MethodDelete, "/api/decks/1/grants/"+grant.ID, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected revoke 204, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doRawRequest(student.router, http.MethodGet, "/api/shared-decks/1/due", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after the grant is revoked, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
+
+func TestAPI_SharedDeckDueCardsIncludeSubdecks(t *testing.T) {
+	env := setupAPITestEnv(t)
+	sessionID := strings.TrimPrefix(env.authCookie, sessionCookieName+"=")
+	sessionRecord, err := env.store.GetSessionRecord(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("failed to load current session: %v", err)
+	}
+	activateWorkspaceSubscriptionForTest(t, env, sessionRecord.WorkspaceID, PlanPro)
+	student := createAuthenticatedIsolatedTestClient(t, env, "student@example.com", "Student")
+
+	childRR := doJSONRequest(t, env.router, http.MethodPost, "/api/decks", CreateDeckRequest{Name: "Organelles"})
+	if childRR.Code != http.StatusCreated {
+		t.Fatalf("expected deck create 201, got %d (%s)", childRR.Code, childRR.Body.String())
+	}
+	child := decodeJSON[DeckResponse](t, childRR)
+	parentID := int64(1)
+	if rr := doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/decks/%d", child.ID), UpdateDeckRequest{ParentID: &parentID}); rr.Code != http.StatusOK {
+		t.Fatalf("expected reparent 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	createNoteForTest(t, env, CreateNoteRequest{TypeID: "Basic", DeckID: 1, FieldVals: map[string]string{"Front": "Cell", "Back": "Unit of life"}}, nil)
+	createNoteForTest(t, env, CreateNoteRequest{TypeID: "Basic", DeckID: child.ID, FieldVals: map[string]string{"Front": "Ribosome", "Back": "Makes protein"}}, nil)
+
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/decks/1/grants", CreateDeckGrantRequest{Email: student.user.Email}); rr.Code != http.StatusCreated {
+		t.Fatalf("expected grant 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	dueRR := doRawRequest(student.router, http.MethodGet, "/api/shared-decks/1/due", "")
+	due := decodeJSON[[]Card](t, dueRR)
+	if dueRR.Code != http.StatusOK || len(due) != 2 {
+		t.Fatalf("expected the parent and child deck cards due, got %d (%s)", dueRR.Code, dueRR.Body.String())
+	}
+	decks := map[int64]bool{}
+	for _, card := range due {
+		decks[card.DeckID] = true
+	}
+	if !decks[1] || !decks[child.ID] {
+		t.Fatalf("expected cards from decks 1 and %d, got %+v", child.ID, decks)
+	}
+
+	answerPath := fmt.Sprintf("/api/shared-decks/1/cards/%d/answer", due[0].ID)
+	if rr := doJSONRequest(t, student.router, http.MethodPost, answerPath, AnswerCardRequest{Rating: 4}); rr.Code != http.StatusOK {
+		t.Fatalf("expected the student's answer to be accepted, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if due := decodeJSON[[]Card](t, doRawRequest(student.router, http.MethodGet, "/api/shared-decks/1/due", "")); len(due) != 1 {
+		t.Fatalf("expected one card left due, got %+v", due)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// maxDeckDepth guards ancestry walks against parent cycles in corrupted data.
+const maxDeckDepth = 64
+
+// DeckLimits are the per-day caps that apply to a deck once its ancestors are taken
+// into account. Following Anki's v3 scheduler, a deck can never show more cards than
+// any ancestor has left for the day, and an ancestor's allowance is shared by its
+// whole subtree.
+type DeckLimits struct {
+	NewLimit        int
+	ReviewLimit     int
+	NewRemaining    int
+	ReviewRemaining int
+}
+
+// deckAncestry returns deckID followed by its parent, grandparent, and so on.
+func (s *SQLStore) deckAncestry(ctx context.Context, deckID int64) ([]int64, error) {
+	chain := []int64{deckID}
+	seen := map[int64]bool{deckID: true}
+	current := deckID
+	for len(chain) < maxDeckDepth {
+		var parentID *int64
+		if err := s.db.QueryRowContext(ctx, `SELECT parent_id FROM decks WHERE id = ?`, current).Scan(&parentID); err != nil {
+			return nil, err
+		}
+		if parentID == nil || seen[*parentID] {
+			break
+		}
+		seen[*parentID] = true
+		chain = append(chain, *parentID)
+		current = *parentID
+	}
+	return chain, nil
+}
+
+// deckSubtreeCTE defines subtree(id, depth): the deck bound to the first placeholder
+// and all of its descendants. Prefix it to a query that selects from subtree.
+var deckSubtreeCTE = fmt.Sprintf(`
+	WITH RECURSIVE subtree(id, depth) AS (
+		SELECT CAST(? AS BIGINT), 0
+		UNION ALL
+		SELECT d.id, subtree.depth + 1 FROM decks d JOIN subtree ON d.parent_id = subtree.id
+		WHERE subtree.depth < %d
+	)`, maxDeckDepth)
+
+// countReviewedInSubtree counts distinct cards reviewed in [dayStart, dayEnd) from
+// deckID or any of its descendants, restricted to the given pre-review states. Manual
+// reschedules are not study and cramming in a filtered deck does not schedule the card's
+// home deck, so neither kind is counted. A blank userID counts reviews from every user.
+func (s *SQLStore) countReviewedInSubtree(ctx context.Context, userID string, deckID, dayStart, dayEnd int64, states []int) (int, error) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(states)), ",")
+	userClause := ""
+	args := []interface{}{deckID, dayStart, dayEnd}
+	for _, state := range states {
+		args = append(args, state)
+	}
+	args = append(args, reviewKindManual, reviewKindFiltered)
+	if strings.TrimSpace(userID) != "" {
+		userClause = "AND r.user_id = ?"
+		args = append(args, userID)
+	}
+
+	query := deckSubtreeCTE + fmt.Sprintf(`
+		SELECT COUNT(DISTINCT r.card_id)
+		FROM revlog r
+		JOIN cards c ON c.id = r.card_id
+		WHERE c.deck_id IN (SELECT id FROM subtree)
+		  AND r.reviewed_at >= ?
+		  AND r.reviewed_at < ?
+		  AND r.state IN (%s)
+		  AND r.kind NOT IN (?, ?)
+		  %s
+	`, placeholders, userClause)
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetEffectiveDeckLimits resolves the deck's daily caps against every ancestor's preset
+// and what each ancestor's subtree has already studied today.
+func (s *SQLStore) GetEffectiveDeckLimits(ctx context.Context, userID string, deckID int64, now time.Time) (DeckLimits, error) {
+	chain, err := s.deckAncestry(ctx, deckID)
+	if err != nil {
+		return DeckLimits{}, err
+	}
+
+	_, dayStartTime, dayEndTime, err := s.studyDayForDeck(ctx, deckID, now)
+	if err != nil {
+		return DeckLimits{}, err
+	}
+	dayStart, dayEnd := dayStartTime.Unix(), dayEndTime.Unix()
+
+	limits := DeckLimits{}
+	for i, id := range chain {
+		newLimit, reviewLimit, err := s.getDeckDailyLimits(ctx, id)
+		if err != nil {
+			return DeckLimits{}, err
+		}
+		newReviewed, err := s.countReviewedInSubtree(ctx, userID, id, dayStart, dayEnd, []int{int(fsrs.New)})
+		if err != nil {
+			return DeckLimits{}, err
+		}
+		reviewed, err := s.countReviewedInSubtree(ctx, userID, id, dayStart, dayEnd, []int{int(fsrs.Review), int(fsrs.Relearning)})
+		if err != nil {
+			return DeckLimits{}, err
+		}
+
+		newRemaining := max(newLimit-newReviewed, 0)
+		reviewRemaining := max(reviewLimit-reviewed, 0)
+		if i == 0 {
+			limits = DeckLimits{
+				NewLimit:        newLimit,
+				ReviewLimit:     reviewLimit,
+				NewRemaining:    newRemaining,
+				ReviewRemaining: reviewRemaining,
+			}
+			continue
+		}
+		limits.NewLimit = min(limits.NewLimit, newLimit)
+		limits.ReviewLimit = min(limits.ReviewLimit, reviewLimit)
+		limits.NewRemaining = min(limits.NewRemaining, newRemaining)
+		limits.ReviewRemaining = min(limits.ReviewRemaining, reviewRemaining)
+	}
+	return limits, nil
+}
+
+// applyDeckLimits copies the effective limits onto deck stats.
+func (s *SQLStore) applyDeckLimits(ctx context.Context, userID string, stats *DeckStats) error {
+	limits, err := s.GetEffectiveDeckLimits(ctx, userID, stats.DeckID, time.Now())
+	if err != nil {
+		return err
+	}
+	stats.NewLimit = limits.NewLimit
+	stats.ReviewLimit = limits.ReviewLimit
+	stats.NewRemaining = limits.NewRemaining
+	stats.ReviewRemaining = limits.ReviewRemaining
+	return nil
+}
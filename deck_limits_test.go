@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestAPI_DeckLimitsInheritAncestorCaps(t *testing.T) {
+	env := setupAPITestEnv(t)
+	sessionID := strings.TrimPrefix(env.authCookie, sessionCookieName+"=")
+	sessionRecord, err := env.store.GetSessionRecord(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("failed to load current session: %v", err)
+	}
+	activateWorkspaceSubscriptionForTest(t, env, sessionRecord.WorkspaceID, PlanPro)
+
+	createDeck := func(name string, parentID *int64) int64 {
+		t.Helper()
+		rr := doJSONRequest(t, env.router, http.MethodPost, "/api/decks", CreateDeckRequest{Name: name})
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected deck create 201, got %d (%s)", rr.Code, rr.Body.String())
+		}
+		created := decodeJSON[DeckResponse](t, rr)
+		if parentID != nil {
+			deck, err := env.store.GetDeck(context.Background(), created.ID)
+			if err != nil {
+				t.Fatalf("failed to load deck %d: %v", created.ID, err)
+			}
+			deck.ParentID = parentID
+			if err := env.store.UpdateDeck(context.Background(), deck); err != nil {
+				t.Fatalf("failed to set parent for deck %d: %v", created.ID, err)
+			}
+		}
+		return created.ID
+	}
+
+	parentID := createDeck("Languages", nil)
+	childID := createDeck("Languages::Spanish", &parentID)
+	siblingID := createDeck("Languages::French", &parentID)
+
+	parentNew := 3
+	if rr := doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/decks/%d", parentID), UpdateDeckRequest{NewCardsPerDay: &parentNew}); rr.Code != http.StatusOK {
+		t.Fatalf("expected parent limit update 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	for i := 0; i < 5; i++ {
+		for _, deckID := range []int64{childID, siblingID} {
+			createNoteForTest(t, env, CreateNoteRequest{
+				TypeID: "Basic",
+				DeckID: deckID,
+				FieldVals: map[string]string{
+					"Front": fmt.Sprintf("Deck %d note %d", deckID, i),
+					"Back":  "Answer",
+				},
+			}, nil)
+		}
+	}
+
+	childDue := decodeJSON[[]Card](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/decks/%d/due?limit=50", childID), ""))
+	if len(childDue) != parentNew {
+		t.Fatalf("expected child to be capped by parent's %d new cards, got %d", parentNew, len(childDue))
+	}
+
+	for _, card := range childDue[:2] {
+		rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", card.ID), AnswerCardRequest{Rating: 3})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+	}
+
+	if rr := doRawRequest(env.router, http.MethodGet, "/api/decks/999/stats", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected stats for an unknown deck 404, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	statsRR := doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/decks/%d/stats", siblingID), "")
+	if statsRR.Code != http.StatusOK {
+		t.Fatalf("expected stats 200, got %d (%s)", statsRR.Code, statsRR.Body.String())
+	}
+	stats := decodeJSON[DeckStats](t, statsRR)
+	if stats.NewLimit != parentNew || stats.NewRemaining != 1 {
+		t.Fatalf("expected sibling to inherit limit=%d with 1 remaining, got limit=%d remaining=%d", parentNew, stats.NewLimit, stats.NewRemaining)
+	}
+	if stats.ReviewLimit != defaultReviewsPerDay {
+		t.Fatalf("expected default review limit %d, got %d", defaultReviewsPerDay, stats.ReviewLimit)
+	}
+
+	siblingDue := decodeJSON[[]Card](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/decks/%d/due?limit=50", siblingID), ""))
+	if len(siblingDue) != 1 {
+		t.Fatalf("expected sibling to share the parent's remaining allowance of 1, got %d", len(siblingDue))
+	}
+
+	parentStats := decodeJSON[DeckStats](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/decks/%d/stats", parentID), ""))
+	if parentStats.NewRemaining != 1 {
+		t.Fatalf("expected parent subtree to report 1 new card remaining, got %d", parentStats.NewRemaining)
+	}
+}
+
+func TestGetEffectiveDeckLimitsIgnoresManualReschedules(t *testing.T) {
+	env := setupAPITestEnv(t)
+	sessionID := strings.TrimPrefix(env.authCookie, sessionCookieName+"=")
+	sessionRecord, err := env.store.GetSessionRecord(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("failed to load current session: %v", err)
+	}
+	userID := sessionRecord.UserID
+
+	createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Rescheduled", "Back": "Answer"},
+	}, nil)
+	due := decodeJSON[[]Card](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=10", ""))
+	if len(due) != 1 {
+		t.Fatalf("expected one due card, got %d", len(due))
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", due[0].ID), AnswerCardRequest{Rating: 3}); rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	now := time.Now()
+	before, err := env.store.GetEffectiveDeckLimits(context.Background(), userID, 1, now)
+	if err != nil {
+		t.Fatalf("failed to load limits: %v", err)
+	}
+	dues := map[int64]time.Time{due[0].ID: now.AddDate(0, 0, 3)}
+	if rescheduled, _, err := env.store.SetCardDueDates(context.Background(), userID, dues, false, fsrs.DefaultParam(), now); err != nil || rescheduled != 1 {
+		t.Fatalf("expected the card to be rescheduled, got %d (%v)", rescheduled, err)
+	}
+
+	after, err := env.store.GetEffectiveDeckLimits(context.Background(), userID, 1, now)
+	if err != nil {
+		t.Fatalf("failed to load limits: %v", err)
+	}
+	if after != before {
+		t.Fatalf("expected a manual reschedule to leave limits at %+v, got %+v", before, after)
+	}
+}
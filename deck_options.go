@@ -0,0 +1,459 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DeckOptionsResponse is a deck options preset and the decks that use it. Decks without
+// a preset study with the default limits.
+type DeckOptionsResponse struct {
+	ID                 int64   `json:"id"`
+	Name               string  `json:"name"`
+	NewCardsPerDay     int     `json:"newCardsPerDay"`
+	ReviewsPerDay      int     `json:"reviewsPerDay"`
+	LearningSteps      []int   `json:"learningSteps"` // minutes
+	GraduatingInterval int     `json:"graduatingInterval"`
+	EasyInterval       int     `json:"easyInterval"`
+	RelearningSteps    []int   `json:"relearningSteps"` // minutes
+	MinimumInterval    int     `json:"minimumInterval"`
+	NewIntervalPercent int     `json:"newIntervalPercent"`
+	LeechThreshold     int     `json:"leechThreshold"`
+	LeechAction        string  `json:"leechAction"`
+	Scheduler          string  `json:"scheduler"`        // "fsrs" or "sm2"
+	StartingEase       int     `json:"startingEase"`     // SM-2, permille
+	EasyBonus          int     `json:"easyBonus"`        // SM-2, percent
+	IntervalModifier   int     `json:"intervalModifier"` // SM-2, percent
+	ReviewOrder        string  `json:"reviewOrder"`
+	DeckIDs            []int64 `json:"deckIds"`
+}
+
+// DeckOptionsRequest creates or updates a preset. Omitted fields keep their current
+// value, or take the default when creating.
+type DeckOptionsRequest struct {
+	Name               *string `json:"name,omitempty"`
+	NewCardsPerDay     *int    `json:"newCardsPerDay,omitempty"`
+	ReviewsPerDay      *int    `json:"reviewsPerDay,omitempty"`
+	LearningSteps      []int   `json:"learningSteps,omitempty"`
+	GraduatingInterval *int    `json:"graduatingInterval,omitempty"`
+	EasyInterval       *int    `json:"easyInterval,omitempty"`
+	RelearningSteps    []int   `json:"relearningSteps,omitempty"`
+	MinimumInterval    *int    `json:"minimumInterval,omitempty"`
+	NewIntervalPercent *int    `json:"newIntervalPercent,omitempty"`
+	LeechThreshold     *int    `json:"leechThreshold,omitempty"`
+	LeechAction        *string `json:"leechAction,omitempty"`
+	Scheduler          *string `json:"scheduler,omitempty"`
+	StartingEase       *int    `json:"startingEase,omitempty"`
+	EasyBonus          *int    `json:"easyBonus,omitempty"`
+	IntervalModifier   *int    `json:"intervalModifier,omitempty"`
+	ReviewOrder        *string `json:"reviewOrder,omitempty"`
+}
+
+type AssignDeckOptionsRequest struct {
+	DeckIDs []int64 `json:"deckIds"`
+}
+
+func (req *AssignDeckOptionsRequest) Validate(v *requestValidator) {
+	v.check(len(req.DeckIDs) > 0, "deckIds", "is required")
+}
+
+// CreateDeckOptionsInCollection stores a new preset in a collection, picking its ID.
+func (s *SQLStore) CreateDeckOptionsInCollection(ctx context.Context, collectionID string, options *DeckOptions) error {
+	if options.ID == 0 {
+		options.ID = time.Now().UnixNano()
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO deck_options (id, collection_id, name, new_cards_per_day, reviews_per_day, learning_steps, graduating_interval, easy_interval,
+			relearning_steps, minimum_interval, new_interval_percent, leech_threshold, leech_action, scheduler, starting_ease, easy_bonus, interval_modifier, review_order)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, options.ID, collectionID, options.Name, options.NewCardsPerDay, options.ReviewsPerDay, learningStepsJSON(options.LearningSteps), options.GraduatingInterval, options.EasyInterval,
+		learningStepsJSON(options.RelearningSteps), options.MinimumInterval, options.NewIntervalPercent, options.LeechThreshold, leechActionOrDefault(options.LeechAction),
+		schedulerOrDefault(options.Scheduler), options.StartingEase, options.EasyBonus, options.IntervalModifier, reviewOrderOrDefault(options.ReviewOrder))
+	return err
+}
+
+// deckOptionsInCollectionSQL matches the presets of a collection, including ones created
+// for a deck before presets were scoped to collections.
+const deckOptionsInCollectionSQL = `(collection_id = ? OR id IN (SELECT options_id FROM decks WHERE collection_id = ? AND options_id IS NOT NULL))`
+
+// ListDeckOptions returns a collection's presets sorted by name.
+func (s *SQLStore) ListDeckOptions(ctx context.Context, collectionID string) ([]*DeckOptions, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM deck_options WHERE `+deckOptionsInCollectionSQL+` ORDER BY lower(name), id`, collectionID, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	presets := make([]*DeckOptions, 0, len(ids))
+	for _, id := range ids {
+		options, err := s.GetDeckOptions(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		presets = append(presets, options)
+	}
+	return presets, nil
+}
+
+// GetDeckOptionsInCollection returns a preset only when it belongs to the collection.
+func (s *SQLStore) GetDeckOptionsInCollection(ctx context.Context, collectionID string, id int64) (*DeckOptions, error) {
+	var found int64
+	if err := s.db.QueryRowContext(ctx, `SELECT id FROM deck_options WHERE id = ? AND `+deckOptionsInCollectionSQL, id, collectionID, collectionID).Scan(&found); err != nil {
+		return nil, err
+	}
+	return s.GetDeckOptions(ctx, found)
+}
+
+// ListDeckIDsUsingOptions returns the decks assigned to a preset.
+func (s *SQLStore) ListDeckIDsUsingOptions(ctx context.Context, optionsID int64) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM decks WHERE options_id = ? ORDER BY id`, optionsID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// DeleteDeckOptions removes a preset; its decks fall back to the default limits.
+func (s *SQLStore) DeleteDeckOptions(ctx context.Context, id int64) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `UPDATE decks SET options_id = NULL WHERE options_id = ?`, id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM deck_options WHERE id = ?`, id)
+		return err
+	})
+}
+
+func (h *APIHandler) deckOptionsResponse(ctx context.Context, options *DeckOptions) (DeckOptionsResponse, error) {
+	deckIDs, err := h.store.ListDeckIDsUsingOptions(ctx, options.ID)
+	if err != nil {
+		return DeckOptionsResponse{}, err
+	}
+	steps, relearningSteps := options.LearningSteps, options.RelearningSteps
+	if steps == nil {
+		steps = []int{}
+	}
+	if relearningSteps == nil {
+		relearningSteps = []int{}
+	}
+	return DeckOptionsResponse{
+		ID:                 options.ID,
+		Name:               options.Name,
+		NewCardsPerDay:     options.NewCardsPerDay,
+		ReviewsPerDay:      options.ReviewsPerDay,
+		LearningSteps:      steps,
+		GraduatingInterval: options.GraduatingInterval,
+		EasyInterval:       options.EasyInterval,
+		RelearningSteps:    relearningSteps,
+		MinimumInterval:    options.MinimumInterval,
+		NewIntervalPercent: options.NewIntervalPercent,
+		LeechThreshold:     options.LeechThreshold,
+		LeechAction:        leechActionOrDefault(options.LeechAction),
+		Scheduler:          schedulerOrDefault(options.Scheduler),
+		StartingEase:       cmp.Or(options.StartingEase, defaultStartingEase),
+		EasyBonus:          cmp.Or(options.EasyBonus, defaultEasyBonus),
+		IntervalModifier:   cmp.Or(options.IntervalModifier, defaultIntervalModifier),
+		ReviewOrder:        reviewOrderOrDefault(options.ReviewOrder),
+		DeckIDs:            deckIDs,
+	}, nil
+}
+
+// Validate checks every field the request sets; fields left out keep their value.
+func (req *DeckOptionsRequest) Validate(v *requestValidator) {
+	if req.Name != nil {
+		v.required("name", *req.Name)
+	}
+	minimum := func(field string, value *int, min int, unit string) {
+		if value != nil {
+			v.check(*value >= min, field, fmt.Sprintf("must be %d%s or greater", min, unit))
+		}
+	}
+	between := func(field string, value *int, min, max int) {
+		if value != nil {
+			v.intRange(field, *value, min, max)
+		}
+	}
+	steps := func(field string, values []int) {
+		for _, step := range values {
+			if step <= 0 {
+				v.fail(field, "must each be 1 minute or longer")
+				return
+			}
+		}
+	}
+	minimum("newCardsPerDay", req.NewCardsPerDay, 0, "")
+	minimum("reviewsPerDay", req.ReviewsPerDay, 0, "")
+	steps("learningSteps", req.LearningSteps)
+	minimum("graduatingInterval", req.GraduatingInterval, 1, " day")
+	minimum("easyInterval", req.EasyInterval, 1, " day")
+	steps("relearningSteps", req.RelearningSteps)
+	minimum("minimumInterval", req.MinimumInterval, 1, " day")
+	between("newIntervalPercent", req.NewIntervalPercent, 0, 100)
+	minimum("leechThreshold", req.LeechThreshold, 0, "")
+	if req.LeechAction != nil {
+		v.check(*req.LeechAction == leechActionTag || *req.LeechAction == leechActionSuspend, "leechAction", "must be tag or suspend")
+	}
+	if req.Scheduler != nil {
+		v.check(*req.Scheduler == schedulerFSRS || *req.Scheduler == schedulerSM2, "scheduler", "must be fsrs or sm2")
+	}
+	between("startingEase", req.StartingEase, minimumEase, 5000)
+	between("easyBonus", req.EasyBonus, 100, 500)
+	between("intervalModifier", req.IntervalModifier, 50, 500)
+	if req.ReviewOrder != nil {
+		v.check(isValidReviewOrder(*req.ReviewOrder), "reviewOrder", "must be due, due_random, overdueness, interval_asc or difficulty_desc")
+	}
+}
+
+// CreateDeckOptionsRequest is a DeckOptionsRequest that must name the new preset.
+type CreateDeckOptionsRequest struct {
+	DeckOptionsRequest
+}
+
+func (req *CreateDeckOptionsRequest) Validate(v *requestValidator) {
+	if req.Name == nil {
+		v.fail("name", "is required")
+	}
+	req.DeckOptionsRequest.Validate(v)
+}
+
+// applyDeckOptionsRequest copies the fields a validated request sets onto options.
+func applyDeckOptionsRequest(options *DeckOptions, req DeckOptionsRequest) {
+	if req.Name != nil {
+		options.Name = sanitizeHTML(strings.TrimSpace(*req.Name))
+	}
+	setInt := func(dst *int, src *int) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	setString := func(dst *string, src *string) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	setInt(&options.NewCardsPerDay, req.NewCardsPerDay)
+	setInt(&options.ReviewsPerDay, req.ReviewsPerDay)
+	if req.LearningSteps != nil {
+		options.LearningSteps = req.LearningSteps
+	}
+	setInt(&options.GraduatingInterval, req.GraduatingInterval)
+	setInt(&options.EasyInterval, req.EasyInterval)
+	if req.RelearningSteps != nil {
+		options.RelearningSteps = req.RelearningSteps
+	}
+	setInt(&options.MinimumInterval, req.MinimumInterval)
+	setInt(&options.NewIntervalPercent, req.NewIntervalPercent)
+	setInt(&options.LeechThreshold, req.LeechThreshold)
+	setString(&options.LeechAction, req.LeechAction)
+	setString(&options.Scheduler, req.Scheduler)
+	setInt(&options.StartingEase, req.StartingEase)
+	setInt(&options.EasyBonus, req.EasyBonus)
+	setInt(&options.IntervalModifier, req.IntervalModifier)
+	setString(&options.ReviewOrder, req.ReviewOrder)
+}
+
+// deckOptionsForRequest loads the preset named by the {id} URL parameter, responding
+// with an error when it is not one of the collection's presets.
+func (h *APIHandler) deckOptionsForRequest(w http.ResponseWriter, r *http.Request) (*DeckOptions, bool) {
+	id, err := parseIDParam(r, "id")
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_options_id", "Invalid deck options ID")
+		return nil, false
+	}
+	options, err := h.store.GetDeckOptionsInCollection(r.Context(), h.collectionIDForRequest(r), id)
+	if err != nil {
+		respondAPIError(w, http.StatusNotFound, "deck_options_not_found", "Deck options preset not found")
+		return nil, false
+	}
+	return options, true
+}
+
+func (h *APIHandler) respondWithDeckOptions(ctx context.Context, w http.ResponseWriter, status int, options *DeckOptions) {
+	resp, err := h.deckOptionsResponse(ctx, options)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "deck_options_failed", err.Error())
+		return
+	}
+	respondJSON(w, status, resp)
+}
+
+// ListDeckOptionsPresets handles GET /api/deck-options.
+func (h *APIHandler) ListDeckOptionsPresets(w http.ResponseWriter, r *http.Request) {
+	presets, err := h.store.ListDeckOptions(r.Context(), h.collectionIDForRequest(r))
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "deck_options_failed", err.Error())
+		return
+	}
+	resp := make([]DeckOptionsResponse, 0, len(presets))
+	for _, options := range presets {
+		item, err := h.deckOptionsResponse(r.Context(), options)
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "deck_options_failed", err.Error())
+			return
+		}
+		resp = append(resp, item)
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// CreateDeckOptionsPreset handles POST /api/deck-options.
+func (h *APIHandler) CreateDeckOptionsPreset(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	var req CreateDeckOptionsRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	options := &DeckOptions{
+		NewCardsPerDay:     defaultNewCardsPerDay,
+		ReviewsPerDay:      defaultReviewsPerDay,
+		LearningSteps:      []int{},
+		GraduatingInterval: 1,
+		EasyInterval:       4,
+		RelearningSteps:    defaultRelearningSteps,
+		MinimumInterval:    1,
+		LeechThreshold:     defaultLeechThreshold,
+		LeechAction:        leechActionTag,
+		Scheduler:          schedulerFSRS,
+		StartingEase:       defaultStartingEase,
+		EasyBonus:          defaultEasyBonus,
+		IntervalModifier:   defaultIntervalModifier,
+		ReviewOrder:        reviewOrderDue,
+	}
+	applyDeckOptionsRequest(options, req.DeckOptionsRequest)
+	if err := h.store.CreateDeckOptionsInCollection(r.Context(), h.collectionIDForRequest(r), options); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "deck_options_failed", err.Error())
+		return
+	}
+	h.respondWithDeckOptions(r.Context(), w, http.StatusCreated, options)
+}
+
+// GetDeckOptionsPreset handles GET /api/deck-options/{id}.
+func (h *APIHandler) GetDeckOptionsPreset(w http.ResponseWriter, r *http.Request) {
+	options, ok := h.deckOptionsForRequest(w, r)
+	if !ok {
+		return
+	}
+	h.respondWithDeckOptions(r.Context(), w, http.StatusOK, options)
+}
+
+// UpdateDeckOptionsPreset handles PATCH /api/deck-options/{id}. Every deck using the
+// preset picks up the change.
+func (h *APIHandler) UpdateDeckOptionsPreset(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	options, ok := h.deckOptionsForRequest(w, r)
+	if !ok {
+		return
+	}
+	var req DeckOptionsRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	applyDeckOptionsRequest(options, req)
+	if err := h.store.UpdateDeckOptions(r.Context(), options); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "deck_options_failed", err.Error())
+		return
+	}
+	h.respondWithDeckOptions(r.Context(), w, http.StatusOK, options)
+}
+
+// DeleteDeckOptionsPreset handles DELETE /api/deck-options/{id}.
+func (h *APIHandler) DeleteDeckOptionsPreset(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, _, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	options, ok := h.deckOptionsForRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store.DeleteDeckOptions(r.Context(), options.ID); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "deck_options_failed", err.Error())
+		return
+	}
+	for _, deck := range col.Decks {
+		if deck.OptionsID != nil && *deck.OptionsID == options.ID {
+			deck.OptionsID = nil
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AssignDeckOptionsPreset handles POST /api/deck-options/{id}/assign, pointing each of
+// the given decks at the preset.
+func (h *APIHandler) AssignDeckOptionsPreset(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, _, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	options, ok := h.deckOptionsForRequest(w, r)
+	if !ok {
+		return
+	}
+	var req AssignDeckOptionsRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	decks := make([]*Deck, 0, len(req.DeckIDs))
+	for _, deckID := range req.DeckIDs {
+		deck, ok := col.Decks[deckID]
+		if !ok {
+			respondAPIError(w, http.StatusNotFound, "deck_not_found", fmt.Sprintf("Deck %d not found", deckID))
+			return
+		}
+		decks = append(decks, deck)
+	}
+	for _, deck := range decks {
+		stored, err := h.store.GetDeck(r.Context(), deck.ID)
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "deck_update_failed", err.Error())
+			return
+		}
+		optionsID := options.ID
+		stored.OptionsID = &optionsID
+		if err := h.store.UpdateDeck(r.Context(), stored); err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "deck_update_failed", err.Error())
+			return
+		}
+		deck.OptionsID = &optionsID
+	}
+	h.respondWithDeckOptions(r.Context(), w, http.StatusOK, options)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAPI_DeckOptionsPresetsLimitDueCards(t *testing.T) {
+	env := setupAPITestEnv(t)
+	for _, front := range []string{"Alpha", "Beta", "Gamma"} {
+		createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": front, "Back": "Letter"},
+		}, nil)
+	}
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/deck-options", map[string]interface{}{"name": "Slow", "newCardsPerDay": 1})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected preset create 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	preset := decodeJSON[DeckOptionsResponse](t, rr)
+	if preset.NewCardsPerDay != 1 || preset.ReviewsPerDay != defaultReviewsPerDay || len(preset.DeckIDs) != 0 ||
+		len(preset.RelearningSteps) != 1 || preset.MinimumInterval != 1 || preset.NewIntervalPercent != 0 {
+		t.Fatalf("expected defaults for omitted fields, got %+v", preset)
+	}
+	if presets := decodeJSON[[]DeckOptionsResponse](t, doRawRequest(env.router, http.MethodGet, "/api/deck-options", "")); len(presets) != 1 || presets[0].ID != preset.ID {
+		t.Fatalf("expected the new preset listed, got %+v", presets)
+	}
+
+	rr = doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/deck-options/%d/assign", preset.ID), AssignDeckOptionsRequest{DeckIDs: []int64{1}})
+	if assigned := decodeJSON[DeckOptionsResponse](t, rr); rr.Code != http.StatusOK || len(assigned.DeckIDs) != 1 || assigned.DeckIDs[0] != 1 {
+		t.Fatalf("expected deck 1 assigned, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	due := decodeJSON[[]*Card](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=10", ""))
+	if len(due) != 1 {
+		t.Fatalf("expected one new card under the preset's limit, got %d", len(due))
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", due[0].ID), AnswerCardRequest{Rating: 4}); rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if due := decodeJSON[[]*Card](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=10", "")); len(due) != 0 {
+		t.Fatalf("expected no more new cards today, got %d", len(due))
+	}
+
+	// Raising the limit on the preset applies to its decks straight away.
+	rr = doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/deck-options/%d", preset.ID), map[string]int{"newCardsPerDay": 2})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected preset update 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if due := decodeJSON[[]*Card](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=10", "")); len(due) != 1 {
+		t.Fatalf("expected one more new card after raising the limit, got %d", len(due))
+	}
+
+	if rr := doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/deck-options/%d", preset.ID), DeckOptionsRequest{LearningSteps: []int{0}}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid learning steps 400, got %d", rr.Code)
+	}
+	rr = doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/deck-options/%d", preset.ID), map[string]interface{}{"relearningSteps": []int{5, 30}, "minimumInterval": 2, "newIntervalPercent": 40})
+	if lapses := decodeJSON[DeckOptionsResponse](t, rr); rr.Code != http.StatusOK || len(lapses.RelearningSteps) != 2 || lapses.MinimumInterval != 2 || lapses.NewIntervalPercent != 40 {
+		t.Fatalf("expected lapse options updated, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/deck-options/%d", preset.ID), map[string]int{"newIntervalPercent": 150}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid new interval 400, got %d", rr.Code)
+	}
+	if rr := doRawRequest(env.router, http.MethodDelete, fmt.Sprintf("/api/deck-options/%d", preset.ID), ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected preset delete 204, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	deck, err := env.store.GetDeck(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("load deck failed: %v", err)
+	}
+	if deck.OptionsID != nil {
+		t.Fatalf("expected the deck back on default limits, got options %d", *deck.OptionsID)
+	}
+	if rr := doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/deck-options/%d", preset.ID), ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected deleted preset 404, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// deckPathSeparator joins deck names into a hierarchy, as in Anki: "Japanese::Grammar".
+const deckPathSeparator = "::"
+
+// DeckTreeNode is a deck with its sub-decks nested beneath it. Stats count the cards of
+// the whole subtree.
+type DeckTreeNode struct {
+	DeckResponse
+	ShortName string         `json:"shortName"`
+	Stats     *DeckStats     `json:"stats,omitempty"`
+	Children  []DeckTreeNode `json:"children"`
+}
+
+// splitDeckPath splits a "Parent::Child" deck name into trimmed segments. Empty
+// segments, as in "A::::B" or "::A", are rejected.
+func splitDeckPath(name string) ([]string, error) {
+	parts := strings.Split(name, deckPathSeparator)
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+		if parts[i] == "" {
+			return nil, fmt.Errorf("deck name %q has an empty level", name)
+		}
+	}
+	return parts, nil
+}
+
+// deckShortName returns the last level of a deck name.
+func deckShortName(name string) string {
+	if idx := strings.LastIndex(name, deckPathSeparator); idx >= 0 {
+		return strings.TrimSpace(name[idx+len(deckPathSeparator):])
+	}
+	return name
+}
+
+// deckByName finds a deck by its full name, ignoring case.
+func (c *Collection) deckByName(name string) *Deck {
+	for _, deck := range c.Decks {
+		if strings.EqualFold(deck.Name, name) {
+			return deck
+		}
+	}
+	return nil
+}
+
+// missingDeckAncestors returns the full names of the parent decks of path that do not
+// exist yet, outermost first.
+func (c *Collection) missingDeckAncestors(path []string) []string {
+	var missing []string
+	for depth := 1; depth < len(path); depth++ {
+		name := strings.Join(path[:depth], deckPathSeparator)
+		if c.deckByName(name) == nil {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// createDeckPath creates the deck at path along with any missing parent decks, linking
+// each to its parent. Existing parents are reused, and their spelling carries over to
+// the new deck's name.
+func (h *APIHandler) createDeckPath(ctx context.Context, col *Collection, collectionID string, path []string) (*Deck, error) {
+	var (
+		parentID   *int64
+		parentName string
+	)
+	childName := func(segment string) string {
+		if parentName == "" {
+			return segment
+		}
+		return parentName + deckPathSeparator + segment
+	}
+	for _, segment := range path[:len(path)-1] {
+		name := childName(segment)
+		parent := col.deckByName(name)
+		if parent == nil {
+			parent = col.NewDeck(name)
+			parent.ParentID = parentID
+			if err := h.store.CreateDeckInCollection(ctx, collectionID, parent); err != nil {
+				return nil, err
+			}
+		}
+		id := parent.ID
+		parentID = &id
+		parentName = parent.Name
+	}
+
+	deck := col.NewDeck(childName(path[len(path)-1]))
+	deck.ParentID = parentID
+	if err := h.store.CreateDeckInCollection(ctx, collectionID, deck); err != nil {
+		return nil, err
+	}
+	return deck, nil
+}
+
+// buildDeckTree nests decks by ParentID. Decks whose parent is missing are treated as
+// roots. Siblings keep the order of decks.
+func buildDeckTree(decks []*Deck, node func(*Deck) DeckTreeNode) []DeckTreeNode {
+	known := make(map[int64]bool, len(decks))
+	for _, deck := range decks {
+		known[deck.ID] = true
+	}
+	children := map[int64][]*Deck{}
+	var roots []*Deck
+	for _, deck := range decks {
+		if deck.ParentID != nil && known[*deck.ParentID] && *deck.ParentID != deck.ID {
+			children[*deck.ParentID] = append(children[*deck.ParentID], deck)
+			continue
+		}
+		roots = append(roots, deck)
+	}
+
+	visited := map[int64]bool{}
+	var build func(deck *Deck) DeckTreeNode
+	build = func(deck *Deck) DeckTreeNode {
+		visited[deck.ID] = true
+		n := node(deck)
+		n.Children = []DeckTreeNode{}
+		for _, child := range children[deck.ID] {
+			if !visited[child.ID] {
+				n.Children = append(n.Children, build(child))
+			}
+		}
+		return n
+	}
+
+	tree := []DeckTreeNode{}
+	for _, deck := range roots {
+		tree = append(tree, build(deck))
+	}
+	// A parent cycle leaves decks unreachable from any root; surface them at the top
+	// level rather than hiding them.
+	for _, deck := range decks {
+		if !visited[deck.ID] {
+			tree = append(tree, build(deck))
+		}
+	}
+	return tree
+}
+
+// GetDeckTree serves GET /api/decks/tree, returning the collection's decks nested under
+// their parents.
+func (h *APIHandler) GetDeckTree(w http.ResponseWriter, r *http.Request) {
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	decks, err := h.store.ListDecks(r.Context(), collectionID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "deck_list_failed", err.Error())
+		return
+	}
+	userID := h.userIDFromRequest(r)
+	if err := h.store.EnsureReviewStatesForUser(r.Context(), userID); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "deck_list_failed", err.Error())
+		return
+	}
+
+	tree := buildDeckTree(decks, func(deck *Deck) DeckTreeNode {
+		node := DeckTreeNode{
+			DeckResponse: h.deckResponse(r.Context(), userID, deck, col, nil),
+			ShortName:    deckShortName(deck.Name),
+		}
+		if stats, err := h.store.GetDeckStatsForUser(r.Context(), userID, deck.ID); err == nil {
+			node.Stats = stats
+		}
+		return node
+	})
+	respondJSON(w, http.StatusOK, tree)
+}
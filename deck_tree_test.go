@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAPI_CreateNestedDeckBuildsTree(t *testing.T) {
+	env := setupAPITestEnv(t)
+	sessionID := strings.TrimPrefix(env.authCookie, sessionCookieName+"=")
+	sessionRecord, err := env.store.GetSessionRecord(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("failed to load current session: %v", err)
+	}
+	activateWorkspaceSubscriptionForTest(t, env, sessionRecord.WorkspaceID, PlanPro)
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/decks", CreateDeckRequest{Name: "Japanese::Grammar::N5"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected nested deck create 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	leaf := decodeJSON[DeckResponse](t, rr)
+	if leaf.Name != "Japanese::Grammar::N5" || leaf.ParentID == nil {
+		t.Fatalf("expected leaf deck with a parent, got %+v", leaf)
+	}
+
+	rr = doJSONRequest(t, env.router, http.MethodPost, "/api/decks", CreateDeckRequest{Name: "japanese :: Vocab"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected sibling deck create 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/decks", CreateDeckRequest{Name: "Japanese::::Kanji"}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected empty level to be rejected with 400, got %d", rr.Code)
+	}
+
+	rr = doRawRequest(env.router, http.MethodGet, "/api/decks/tree", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected tree 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	tree := decodeJSON[[]DeckTreeNode](t, rr)
+
+	var japanese *DeckTreeNode
+	for i := range tree {
+		if tree[i].Name == "Japanese" {
+			japanese = &tree[i]
+		}
+		if strings.Contains(tree[i].Name, "::") {
+			t.Fatalf("expected only root decks at the top level, found %q", tree[i].Name)
+		}
+	}
+	if japanese == nil {
+		t.Fatalf("expected auto-created Japanese root deck in tree: %+v", tree)
+	}
+	if len(japanese.Children) != 2 {
+		t.Fatalf("expected Japanese to have Grammar and Vocab children, got %+v", japanese.Children)
+	}
+	grammar := japanese.Children[0]
+	if grammar.ShortName != "Grammar" || len(grammar.Children) != 1 {
+		t.Fatalf("expected Grammar with one child, got %+v", grammar)
+	}
+	if n5 := grammar.Children[0]; n5.ID != leaf.ID || n5.ShortName != "N5" || *n5.ParentID != grammar.ID {
+		t.Fatalf("expected N5 under Grammar, got %+v", n5)
+	}
+	if vocab := japanese.Children[1]; vocab.Name != "Japanese::Vocab" || vocab.ShortName != "Vocab" {
+		t.Fatalf("expected Vocab to reuse the existing Japanese parent, got %+v", vocab)
+	}
+}
+
+func TestAPI_DeckStatsRollUpSubDecks(t *testing.T) {
+	env := setupAPITestEnv(t)
+	sessionID := strings.TrimPrefix(env.authCookie, sessionCookieName+"=")
+	sessionRecord, err := env.store.GetSessionRecord(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("failed to load current session: %v", err)
+	}
+	activateWorkspaceSubscriptionForTest(t, env, sessionRecord.WorkspaceID, PlanPro)
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/decks", CreateDeckRequest{Name: "Lang::Verbs::Irregular"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected nested deck create 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	leaf := decodeJSON[DeckResponse](t, rr)
+	ancestry, err := env.store.deckAncestry(context.Background(), leaf.ID)
+	if err != nil || len(ancestry) != 3 {
+		t.Fatalf("expected three-level ancestry, got %v (%v)", ancestry, err)
+	}
+	verbsID, langID := ancestry[1], ancestry[2]
+
+	for i, deckID := range []int64{langID, verbsID, leaf.ID, leaf.ID} {
+		createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    deckID,
+			FieldVals: map[string]string{"Front": fmt.Sprintf("Roll-up %d", i), "Back": "Answer"},
+		}, nil)
+	}
+
+	expected := map[int64]int{langID: 4, verbsID: 3, leaf.ID: 2}
+	for deckID, want := range expected {
+		rr := doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/decks/%d/stats", deckID), "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected stats 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+		stats := decodeJSON[DeckStats](t, rr)
+		if stats.TotalCards != want || stats.NewCards != want || stats.DueToday != want {
+			t.Fatalf("deck %d: expected %d cards rolled up, got %+v", deckID, want, stats)
+		}
+	}
+
+	rr = doRawRequest(env.router, http.MethodGet, "/api/decks/tree", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected tree 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	for _, node := range decodeJSON[[]DeckTreeNode](t, rr) {
+		if node.ID != langID {
+			continue
+		}
+		if node.Stats == nil || node.Stats.TotalCards != 4 || node.DueToday != 4 {
+			t.Fatalf("expected Lang tree node to roll up 4 cards, got %+v", node)
+		}
+		if node.CardCount != 1 {
+			t.Fatalf("expected Lang's own card count to stay 1, got %d", node.CardCount)
+		}
+		return
+	}
+	t.Fatalf("Lang deck missing from tree")
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAPI_UpdateDeckParentAndOptionsPreset(t *testing.T) {
+	env := setupAPITestEnv(t)
+	sessionID := strings.TrimPrefix(env.authCookie, sessionCookieName+"=")
+	sessionRecord, err := env.store.GetSessionRecord(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("failed to load current session: %v", err)
+	}
+	activateWorkspaceSubscriptionForTest(t, env, sessionRecord.WorkspaceID, PlanPro)
+
+	createDeck := func(name string) DeckResponse {
+		t.Helper()
+		rr := doJSONRequest(t, env.router, http.MethodPost, "/api/decks", CreateDeckRequest{Name: name})
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected deck create 201, got %d (%s)", rr.Code, rr.Body.String())
+		}
+		return decodeJSON[DeckResponse](t, rr)
+	}
+	patch := func(id int64, req UpdateDeckRequest) *DeckResponse {
+		t.Helper()
+		rr := doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/decks/%d", id), req)
+		if rr.Code != http.StatusOK {
+			return nil
+		}
+		resp := decodeJSON[DeckResponse](t, rr)
+		return &resp
+	}
+
+	parent := createDeck("Languages")
+	child := createDeck("Spanish")
+	grandchild := createDeck("Verbs")
+
+	renamed := "Spanish Vocabulary"
+	updated := patch(child.ID, UpdateDeckRequest{Name: &renamed, ParentID: &parent.ID})
+	if updated == nil || updated.Name != renamed || updated.ParentID == nil || *updated.ParentID != parent.ID {
+		t.Fatalf("expected rename and reparent, got %+v", updated)
+	}
+	if patch(grandchild.ID, UpdateDeckRequest{ParentID: &child.ID}) == nil {
+		t.Fatalf("expected grandchild reparent to succeed")
+	}
+
+	if patch(parent.ID, UpdateDeckRequest{ParentID: &grandchild.ID}) != nil {
+		t.Fatalf("expected moving a deck under its descendant to be rejected")
+	}
+	if patch(parent.ID, UpdateDeckRequest{ParentID: &parent.ID}) != nil {
+		t.Fatalf("expected self-parenting to be rejected")
+	}
+	missing := int64(999999)
+	if patch(parent.ID, UpdateDeckRequest{ParentID: &missing}) != nil {
+		t.Fatalf("expected unknown parent to be rejected")
+	}
+
+	root := int64(0)
+	if detached := patch(grandchild.ID, UpdateDeckRequest{ParentID: &root}); detached == nil || detached.ParentID != nil {
+		t.Fatalf("expected parentId 0 to detach the deck, got %+v", detached)
+	}
+
+	newPerDay := 7
+	withPreset := patch(parent.ID, UpdateDeckRequest{NewCardsPerDay: &newPerDay})
+	if withPreset == nil || withPreset.OptionsID == nil {
+		t.Fatalf("expected limit change to assign a preset, got %+v", withPreset)
+	}
+	switched := patch(grandchild.ID, UpdateDeckRequest{OptionsID: withPreset.OptionsID})
+	if switched == nil || switched.OptionsID == nil || *switched.OptionsID != *withPreset.OptionsID || switched.NewCardsPerDay != 7 {
+		t.Fatalf("expected deck to share the preset, got %+v", switched)
+	}
+	if patch(grandchild.ID, UpdateDeckRequest{OptionsID: &missing}) != nil {
+		t.Fatalf("expected unknown preset to be rejected")
+	}
+	if reset := patch(grandchild.ID, UpdateDeckRequest{OptionsID: &root}); reset == nil || reset.OptionsID != nil || reset.NewCardsPerDay != defaultNewCardsPerDay {
+		t.Fatalf("expected optionsId 0 to restore defaults, got %+v", reset)
+	}
+
+	stored, err := env.store.GetDeck(context.Background(), child.ID)
+	if err != nil || stored.ParentID == nil || *stored.ParentID != parent.ID {
+		t.Fatalf("expected parent to persist, got %+v (%v)", stored, err)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DueCardFilter narrows the study queue by card properties. The zero value matches
+// every card. Filters are applied in SQL so daily limits count only matching cards.
+type DueCardFilter struct {
+	Flags        []int    `json:"flags,omitempty"`
+	ExcludeFlags []int    `json:"excludeFlags,omitempty"`
+	Marked       *bool    `json:"marked,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	ExcludeTags  []string `json:"excludeTags,omitempty"`
+}
+
+func (f DueCardFilter) IsZero() bool {
+	return len(f.Flags) == 0 && len(f.ExcludeFlags) == 0 && f.Marked == nil && len(f.Tags) == 0 && len(f.ExcludeTags) == 0
+}
+
+// parseDueCardFilter reads flag, excludeFlag, marked, tag, and excludeTag query
+// parameters. List parameters accept repeated keys or comma-separated values.
+func parseDueCardFilter(query url.Values) (DueCardFilter, error) {
+	var filter DueCardFilter
+	var err error
+
+	if filter.Flags, err = parseFlagList(query, "flag"); err != nil {
+		return filter, err
+	}
+	if filter.ExcludeFlags, err = parseFlagList(query, "excludeFlag"); err != nil {
+		return filter, err
+	}
+	if raw := strings.TrimSpace(query.Get("marked")); raw != "" {
+		marked, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, fmt.Errorf("marked must be true or false")
+		}
+		filter.Marked = &marked
+	}
+	filter.Tags = splitQueryList(query, "tag")
+	filter.ExcludeTags = splitQueryList(query, "excludeTag")
+	return filter, nil
+}
+
+func splitQueryList(query url.Values, key string) []string {
+	var out []string
+	for _, raw := range query[key] {
+		for _, part := range strings.Split(raw, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+func parseFlagList(query url.Values, key string) ([]int, error) {
+	var flags []int
+	for _, raw := range splitQueryList(query, key) {
+		flag, err := strconv.Atoi(raw)
+		if err != nil || flag < 0 || flag > 7 {
+			return nil, fmt.Errorf("%s must be a flag number between 0 and 7", key)
+		}
+		flags = append(flags, flag)
+	}
+	return flags, nil
+}
+
+// sqlConditions renders the filter as AND-ed conditions. cardAlias names the cards
+// table; propsAlias names the table holding flag/marked (cards, or card_review_states
+// for per-user queues).
+func (f DueCardFilter) sqlConditions(dialect sqlDialect, cardAlias, propsAlias string) (string, []interface{}) {
+	var (
+		clauses []string
+		args    []interface{}
+	)
+	inList := func(column string, values []int, negate bool) {
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(values)), ",")
+		op := "IN"
+		if negate {
+			op = "NOT IN"
+		}
+		clauses = append(clauses, fmt.Sprintf("COALESCE(%s, 0) %s (%s)", column, op, placeholders))
+		for _, v := range values {
+			args = append(args, v)
+		}
+	}
+	tagExists := func(tags []string, negate bool) {
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(tags)), ",")
+		prefix := "EXISTS"
+		if negate {
+			prefix = "NOT EXISTS"
+		}
+		clauses = append(clauses, fmt.Sprintf(`%s (
+			SELECT 1 FROM notes fn, %s
+			WHERE fn.id = %s.note_id AND LOWER(ft.value) IN (%s)
+		)`, prefix, dialect.jsonArrayValues("COALESCE(fn.tags, '[]')", "ft"), cardAlias, placeholders))
+		for _, tag := range tags {
+			args = append(args, strings.ToLower(tag))
+		}
+	}
+
+	if len(f.Flags) > 0 {
+		inList(propsAlias+".flag", f.Flags, false)
+	}
+	if len(f.ExcludeFlags) > 0 {
+		inList(propsAlias+".flag", f.ExcludeFlags, true)
+	}
+	if f.Marked != nil {
+		clauses = append(clauses, fmt.Sprintf("COALESCE(%s.marked, 0) = ?", propsAlias))
+		args = append(args, boolToInt(*f.Marked))
+	}
+	if len(f.Tags) > 0 {
+		tagExists(f.Tags, false)
+	}
+	if len(f.ExcludeTags) > 0 {
+		tagExists(f.ExcludeTags, true)
+	}
+
+	if len(clauses) == 0 {
+		return "", nil
+	}
+	return "AND " + strings.Join(clauses, "\n\t\t  AND "), args
+}
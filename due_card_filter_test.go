@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestParseDueCardFilter(t *testing.T) {
+	query, _ := url.ParseQuery("flag=1,2&flag=3&excludeTag=leech&marked=false&tag=verbs")
+	filter, err := parseDueCardFilter(query)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(filter.Flags) != 3 || filter.Flags[2] != 3 {
+		t.Fatalf("expected flags [1 2 3], got %v", filter.Flags)
+	}
+	if filter.Marked == nil || *filter.Marked {
+		t.Fatalf("expected marked=false, got %v", filter.Marked)
+	}
+	if len(filter.Tags) != 1 || len(filter.ExcludeTags) != 1 {
+		t.Fatalf("unexpected tag filters: %+v", filter)
+	}
+
+	for _, raw := range []string{"flag=9", "excludeFlag=x", "marked=maybe"} {
+		query, _ := url.ParseQuery(raw)
+		if _, err := parseDueCardFilter(query); err == nil {
+			t.Fatalf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestAPI_DueCardsFilterByFlagMarkedAndTag(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	cardIDs := make([]int64, 0, 4)
+	for i, tags := range [][]string{{"verbs"}, {"verbs", "Leech"}, {"nouns"}, nil} {
+		created := createNoteForTest(t, env, CreateNoteRequest{
+			TypeID: "Basic",
+			DeckID: 1,
+			FieldVals: map[string]string{
+				"Front": fmt.Sprintf("Filter %d", i),
+				"Back":  "Answer",
+			},
+			Tags: tags,
+		}, nil)
+		cardIDs = append(cardIDs, created.Cards[0].ID)
+	}
+
+	flag := 1
+	marked := true
+	for _, id := range cardIDs[:2] {
+		rr := doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/cards/%d", id), UpdateCardRequest{Flag: &flag})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected flag update 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/cards/%d", cardIDs[2]), UpdateCardRequest{Marked: &marked}); rr.Code != http.StatusOK {
+		t.Fatalf("expected marked update 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	cases := []struct {
+		query string
+		want  []int64
+	}{
+		{"flag=1", cardIDs[:2]},
+		{"excludeFlag=1", cardIDs[2:]},
+		{"marked=true", cardIDs[2:3]},
+		{"tag=verbs&excludeTag=leech", cardIDs[:1]},
+		{"tag=nouns,leech", cardIDs[1:3]},
+		{"flag=1&tag=nouns", nil},
+	}
+	for _, tc := range cases {
+		rr := doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=50&"+tc.query, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d (%s)", tc.query, rr.Code, rr.Body.String())
+		}
+		cards := decodeJSON[[]Card](t, rr)
+		got := make(map[int64]bool, len(cards))
+		for _, card := range cards {
+			got[card.ID] = true
+		}
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: expected %d cards, got %d", tc.query, len(tc.want), len(got))
+		}
+		for _, id := range tc.want {
+			if !got[id] {
+				t.Fatalf("%s: expected card %d in queue", tc.query, id)
+			}
+		}
+	}
+
+	if rr := doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?flag=12", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid flag 400, got %d", rr.Code)
+	}
+}
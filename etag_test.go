@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAPI_ConditionalGETReturnsNotModified(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	rr := doRawRequest(env.router, http.MethodGet, "/api/decks", "")
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected decks with an ETag, got %d and %q", rr.Code, etag)
+	}
+
+	for _, ifNoneMatch := range []string{etag, strings.TrimPrefix(etag, "W/"), `"other", ` + etag} {
+		rr = doRawRequestWithHeaders(env.router, http.MethodGet, "/api/decks", "", map[string]string{"If-None-Match": ifNoneMatch})
+		if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 || rr.Header().Get("ETag") != etag {
+			t.Fatalf("expected 304 for If-None-Match %s, got %d (%s)", ifNoneMatch, rr.Code, rr.Body.String())
+		}
+	}
+
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/decks", map[string]string{"name": "Spanish"}); rr.Code != http.StatusCreated {
+		t.Fatalf("create deck: %d (%s)", rr.Code, rr.Body.String())
+	}
+	rr = doRawRequestWithHeaders(env.router, http.MethodGet, "/api/decks", "", map[string]string{"If-None-Match": etag})
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") == etag {
+		t.Fatalf("expected a changed deck list with a new ETag, got %d and %q", rr.Code, rr.Header().Get("ETag"))
+	}
+
+	rr = doRawRequestWithHeaders(env.router, http.MethodGet, "/api/notes/999999", "", map[string]string{"If-None-Match": "*"})
+	if rr.Code != http.StatusNotFound || rr.Header().Get("ETag") != "" {
+		t.Fatalf("expected errors to pass through without an ETag, got %d and %q", rr.Code, rr.Header().Get("ETag"))
+	}
+}
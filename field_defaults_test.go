@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAPI_FieldDefaultsAppliedOnNoteCreate(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	rr := doJSONRequest(t, env.router, http.MethodPut, "/api/note-types/Basic/fields/options", SetFieldOptionsRequest{
+		FieldName: "Back",
+		Options:   FieldOptions{DefaultValue: "<b>TODO</b><script>alert(1)</script>", Placeholder: "  Answer goes here  "},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected set field options 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	ntRR := doRawRequest(env.router, http.MethodGet, "/api/note-types/Basic", "")
+	if ntRR.Code != http.StatusOK {
+		t.Fatalf("expected note type 200, got %d", ntRR.Code)
+	}
+	nt := decodeJSON[NoteTypeResponse](t, ntRR)
+	opts := nt.FieldOptions["Back"]
+	if opts.DefaultValue != "<b>TODO</b>" || opts.Placeholder != "Answer goes here" {
+		t.Fatalf("expected sanitized default and trimmed placeholder, got %+v", opts)
+	}
+
+	missing := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Question"},
+	}, nil)
+	if got := missing.Note.FieldMap["Back"]; got != "<b>TODO</b>" {
+		t.Fatalf("expected default for missing field, got %q", got)
+	}
+
+	blank := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Question 2", "Back": "   "},
+	}, nil)
+	if got := blank.Note.FieldMap["Back"]; got != "<b>TODO</b>" {
+		t.Fatalf("expected default for blank field, got %q", got)
+	}
+
+	provided := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Question 3", "Back": "Real answer"},
+	}, nil)
+	if got := provided.Note.FieldMap["Back"]; got != "Real answer" {
+		t.Fatalf("expected provided value to win, got %q", got)
+	}
+	if got := provided.Note.FieldMap["Front"]; got != "Question 3" {
+		t.Fatalf("expected fields without defaults to be untouched, got %q", got)
+	}
+}
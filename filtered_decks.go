@@ -0,0 +1,574 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// A filtered deck borrows the cards matching a search from their home decks so they
+// can be studied together, e.g. for cramming before an exam. Cards remember their home
+// deck in original_deck_id and go back to it when the deck is emptied, rebuilt, or
+// deleted, or once they have been studied.
+const (
+	defaultFilteredDeckLimit = 100
+	maxFilteredDeckLimit     = 9999
+	// Filtered decks can also be ordered by a stable shuffle or by when cards were added,
+	// besides the review orders of deck options presets.
+	filteredOrderRandom = "random"
+	filteredOrderAdded  = "added"
+)
+
+// FilteredDeck is a filtered deck's definition.
+type FilteredDeck struct {
+	DeckID int64  `json:"deckId"`
+	Name   string `json:"name"`
+	Query  string `json:"query"`
+	Limit  int    `json:"limit"`
+	Order  string `json:"order"`
+	// Reschedule applies answers to the cards' schedules as in their home decks. When
+	// off, answers are logged as filtered reviews and leave the schedules unchanged.
+	Reschedule bool      `json:"reschedule"`
+	BuiltAt    time.Time `json:"builtAt"`
+	CardCount  int       `json:"cardCount"`
+}
+
+// CreateFilteredDeckRequest is the body of POST /api/filtered-decks.
+type CreateFilteredDeckRequest struct {
+	Name       string `json:"name"`
+	Query      string `json:"query"`
+	Limit      *int   `json:"limit,omitempty"`
+	Order      string `json:"order,omitempty"`
+	Reschedule *bool  `json:"reschedule,omitempty"`
+}
+
+func (req *CreateFilteredDeckRequest) Validate(v *requestValidator) {
+	v.required("name", req.Name)
+	v.required("query", req.Query)
+	if req.Limit != nil {
+		v.intRange("limit", *req.Limit, 1, maxFilteredDeckLimit)
+	}
+	if order := strings.TrimSpace(req.Order); order != "" {
+		v.check(isValidFilteredOrder(order), "order", "is not a known filtered deck order")
+	}
+}
+
+// FilteredDeckResponse reports a filtered deck after it was built or emptied.
+type FilteredDeckResponse struct {
+	FilteredDeck
+	CardIDs []int64 `json:"cardIds"`
+}
+
+type filteredDecksResponse struct {
+	FilteredDecks []FilteredDeck `json:"filteredDecks"`
+}
+
+// deckMove records a card changing decks, so callers can update the cached collection.
+// Home is the card's home deck after the move: 0 unless it went into a filtered deck.
+type deckMove struct {
+	CardID int64
+	From   int64
+	To     int64
+	Home   int64
+}
+
+func isValidFilteredOrder(order string) bool {
+	return order == filteredOrderRandom || order == filteredOrderAdded || isValidReviewOrder(order)
+}
+
+// filteredOrderSQL returns the ORDER BY clause cards are pulled into a filtered deck in.
+// The random order is seeded with the build time, so each rebuild shuffles anew.
+func filteredOrderSQL(dialect sqlDialect, order, alias string, now, dayStart int64) string {
+	switch order {
+	case filteredOrderRandom:
+		return seededShuffleSQL("c.id", now) + " ASC, c.id ASC"
+	case filteredOrderAdded:
+		return "c.id ASC"
+	}
+	return reviewOrderSQL(dialect, order, alias, now, dayStart)
+}
+
+// CreateFilteredDeck records def as the definition of the already created deck def.DeckID.
+func (s *SQLStore) CreateFilteredDeck(ctx context.Context, def *FilteredDeck) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO filtered_decks (deck_id, query, card_limit, card_order, reschedule)
+		VALUES (?, ?, ?, ?, ?)
+	`, def.DeckID, def.Query, def.Limit, def.Order, def.Reschedule)
+	return err
+}
+
+const filteredDeckColumns = `
+	SELECT f.deck_id, d.name, f.query, f.card_limit, f.card_order, f.reschedule, f.built_at,
+		(SELECT COUNT(*) FROM cards c WHERE c.deck_id = f.deck_id)
+	FROM filtered_decks f
+	JOIN decks d ON d.id = f.deck_id
+`
+
+func scanFilteredDeck(row interface{ Scan(...interface{}) error }) (*FilteredDeck, error) {
+	var (
+		def     FilteredDeck
+		builtAt int64
+	)
+	if err := row.Scan(&def.DeckID, &def.Name, &def.Query, &def.Limit, &def.Order, &def.Reschedule, &builtAt, &def.CardCount); err != nil {
+		return nil, err
+	}
+	if builtAt > 0 {
+		def.BuiltAt = time.Unix(builtAt, 0)
+	}
+	return &def, nil
+}
+
+// GetFilteredDeck returns the definition of a filtered deck, or sql.ErrNoRows when
+// deckID is a regular deck.
+func (s *SQLStore) GetFilteredDeck(ctx context.Context, deckID int64) (*FilteredDeck, error) {
+	return scanFilteredDeck(s.db.QueryRowContext(ctx, filteredDeckColumns+` WHERE f.deck_id = ?`, deckID))
+}
+
+// IsFilteredDeck reports whether deckID is a filtered deck.
+func (s *SQLStore) IsFilteredDeck(ctx context.Context, deckID int64) (bool, error) {
+	_, err := s.GetFilteredDeck(ctx, deckID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ListFilteredDecks returns the collection's filtered decks ordered by name.
+func (s *SQLStore) ListFilteredDecks(ctx context.Context, collectionID string) ([]FilteredDeck, error) {
+	rows, err := s.db.QueryContext(ctx, filteredDeckColumns+` WHERE d.collection_id = ? ORDER BY d.name, d.id`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	decks := []FilteredDeck{}
+	for rows.Next() {
+		def, err := scanFilteredDeck(rows)
+		if err != nil {
+			return nil, err
+		}
+		decks = append(decks, *def)
+	}
+	return decks, rows.Err()
+}
+
+// filteredDeckForCard returns the filtered deck a card was pulled into, or nil when the
+// card is in its home deck.
+func (s *SQLStore) filteredDeckForCard(ctx context.Context, card *Card) (*FilteredDeck, error) {
+	if card.OriginalDeckID == 0 {
+		return nil, nil
+	}
+	def, err := s.GetFilteredDeck(ctx, card.DeckID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return def, err
+}
+
+// returnCardsHomeTx sends the cards matching where back to their home decks.
+func returnCardsHomeTx(ctx context.Context, tx *sql.Tx, where string, args ...interface{}) ([]deckMove, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id, deck_id, original_deck_id FROM cards WHERE original_deck_id > 0 AND `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	var moves []deckMove
+	for rows.Next() {
+		var move deckMove
+		if err := rows.Scan(&move.CardID, &move.From, &move.To); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		moves = append(moves, move)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE cards SET deck_id = original_deck_id, original_deck_id = 0, filtered_position = 0
+		WHERE original_deck_id > 0 AND `+where, args...); err != nil {
+		return nil, err
+	}
+	return moves, nil
+}
+
+// returnCardsHome sends the cards matching where back to their home decks.
+func (s *SQLStore) returnCardsHome(ctx context.Context, where string, args ...interface{}) ([]deckMove, error) {
+	var moves []deckMove
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		moves, err = returnCardsHomeTx(ctx, tx, where, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moves, nil
+}
+
+// EmptyFilteredDeck returns every card in a filtered deck to its home deck.
+func (s *SQLStore) EmptyFilteredDeck(ctx context.Context, deckID int64) ([]deckMove, error) {
+	return s.returnCardsHome(ctx, `deck_id = ?`, deckID)
+}
+
+// ReturnCardHome sends a card in a filtered deck back to its home deck.
+func (s *SQLStore) ReturnCardHome(ctx context.Context, cardID int64) ([]deckMove, error) {
+	return s.returnCardsHome(ctx, `id = ?`, cardID)
+}
+
+// ReturnBorrowedCards sends cards whose home is homeDeckID back from any filtered deck,
+// so that they are not left homeless when homeDeckID is deleted.
+func (s *SQLStore) ReturnBorrowedCards(ctx context.Context, homeDeckID int64) ([]deckMove, error) {
+	return s.returnCardsHome(ctx, `original_deck_id = ?`, homeDeckID)
+}
+
+// DeleteFilteredDeck returns a filtered deck's cards home and deletes the deck.
+func (s *SQLStore) DeleteFilteredDeck(ctx context.Context, deckID int64) ([]deckMove, error) {
+	var moves []deckMove
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		if moves, err = returnCardsHomeTx(ctx, tx, `deck_id = ?`, deckID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM filtered_decks WHERE deck_id = ?`, deckID); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM decks WHERE id = ?`, deckID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moves, nil
+}
+
+// RebuildFilteredDeck empties a filtered deck and pulls in up to its limit of the cards
+// matching its search, in its order. Suspended and buried cards and cards already in
+// another filtered deck are left alone. It returns every card's deck change.
+func (s *SQLStore) RebuildFilteredDeck(ctx context.Context, userID, collectionID string, def *FilteredDeck, now time.Time) ([]deckMove, error) {
+	userID = strings.TrimSpace(userID)
+	if userID != "" {
+		if err := s.EnsureReviewStatesForUser(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	searchSQL, searchArgs, err := compileSearch(s.dialect, collectionID, userID, def.Query, false, now)
+	if err != nil {
+		return nil, err
+	}
+	dayStart, _, err := s.studyDay(ctx, collectionID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	alias, join, args := "c", "", []interface{}{}
+	if userID != "" {
+		alias, join = "rs", `JOIN card_review_states rs ON rs.card_id = c.id AND rs.user_id = ?`
+		args = append(args, userID)
+	}
+	args = append(args, searchArgs...)
+	args = append(args, def.Limit)
+	query := fmt.Sprintf(`
+		SELECT c.id, c.deck_id
+		FROM cards c
+		%[1]s
+		WHERE c.id IN (%[2]s)
+		  AND c.original_deck_id = 0
+		  AND c.deck_id NOT IN (SELECT deck_id FROM filtered_decks)
+		  AND %[3]s.suspended = 0
+		  AND %[3]s.buried_at = 0
+		ORDER BY %[4]s
+		LIMIT ?
+	`, join, searchSQL, alias, filteredOrderSQL(s.dialect, def.Order, alias, now.Unix(), dayStart.Unix()))
+
+	var moves, pulled []deckMove
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		if moves, err = returnCardsHomeTx(ctx, tx, `deck_id = ?`, def.DeckID); err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		pulled = nil
+		for rows.Next() {
+			move := deckMove{To: def.DeckID}
+			if err := rows.Scan(&move.CardID, &move.From); err != nil {
+				rows.Close()
+				return err
+			}
+			move.Home = move.From
+			pulled = append(pulled, move)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for position, move := range pulled {
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE cards SET original_deck_id = deck_id, deck_id = ?, filtered_position = ? WHERE id = ?
+			`, def.DeckID, position+1, move.CardID); err != nil {
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE filtered_decks SET built_at = ? WHERE deck_id = ?`, now.Unix(), def.DeckID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(moves, pulled...), nil
+}
+
+// filteredDeckQueue lists a filtered deck's cards in the order they were pulled in.
+// Daily limits and due dates do not apply; learning cards wait for their next step up
+// to learnAheadUntil.
+func (s *SQLStore) filteredDeckQueue(ctx context.Context, userID string, deckID, learnAheadUntil int64, limit int, filter DueCardFilter) ([]*Card, error) {
+	userID = strings.TrimSpace(userID)
+	alias, join, args := "c", "", []interface{}{}
+	if userID != "" {
+		alias, join = "rs", `JOIN card_review_states rs ON rs.card_id = c.id AND rs.user_id = ?`
+		args = append(args, userID)
+	}
+	filterSQL, filterArgs := filter.sqlConditions(s.dialect, "c", alias)
+	args = append(args, deckID, int(fsrs.Learning), int(fsrs.Relearning), learnAheadUntil)
+	args = append(args, filterArgs...)
+	args = append(args, limit)
+
+	ids, err := s.queryIDs(ctx, fmt.Sprintf(`
+		SELECT c.id
+		FROM cards c
+		%[1]s
+		WHERE c.deck_id = ?
+		  AND %[2]s.suspended = 0
+		  AND %[2]s.buried_at = 0
+		  AND (%[2]s.state NOT IN (?, ?) OR %[2]s.due <= ?)
+		  %[3]s
+		ORDER BY c.filtered_position ASC, c.id ASC
+		LIMIT ?
+	`, join, alias, filterSQL), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	cards := make([]*Card, 0, len(ids))
+	for _, id := range ids {
+		card, err := s.GetCardForUser(ctx, userID, id)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+// homeDeckID is the deck whose options schedule the card: its home deck while it is
+// in a filtered deck.
+func (c *Card) homeDeckID() int64 {
+	if c.OriginalDeckID != 0 {
+		return c.OriginalDeckID
+	}
+	return c.DeckID
+}
+
+// applyDeckMoves mirrors card deck changes in the cached collection.
+func (h *APIHandler) applyDeckMoves(col *Collection, moves []deckMove) {
+	for _, move := range moves {
+		h.removeCardFromDeck(col, move.From, move.CardID)
+		h.ensureCardOnDeck(col, move.To, move.CardID)
+		if card, ok := col.Cards[move.CardID]; ok {
+			card.DeckID = move.To
+			card.OriginalDeckID = move.Home
+		}
+	}
+}
+
+// finishFilteredAnswer sends a card answered in a filtered deck home once it is done
+// there: straight away when the deck does not reschedule, otherwise once the card has
+// left learning.
+func (h *APIHandler) finishFilteredAnswer(ctx context.Context, col *Collection, card *Card, filtered *FilteredDeck) error {
+	if filtered == nil || (filtered.Reschedule && card.SRS.State != fsrs.Review) {
+		return nil
+	}
+	moves, err := h.store.ReturnCardHome(ctx, card.ID)
+	if err != nil {
+		return err
+	}
+	h.applyDeckMoves(col, moves)
+	card.DeckID, card.OriginalDeckID = card.OriginalDeckID, 0
+	return nil
+}
+
+// CreateFilteredDeck serves POST /api/filtered-decks: it creates a filtered deck and
+// builds it straight away.
+func (h *APIHandler) CreateFilteredDeck(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+
+	var req CreateFilteredDeckRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	def := FilteredDeck{Query: strings.TrimSpace(req.Query), Limit: defaultFilteredDeckLimit, Order: reviewOrderDue, Reschedule: true}
+	if err := validateSearch(def.Query); err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+	if req.Limit != nil {
+		def.Limit = *req.Limit
+	}
+	if order := strings.TrimSpace(req.Order); order != "" {
+		def.Order = order
+	}
+	if req.Reschedule != nil {
+		def.Reschedule = *req.Reschedule
+	}
+
+	name := sanitizeHTML(strings.TrimSpace(req.Name))
+	path, err := splitDeckPath(name)
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_name", err.Error())
+		return
+	}
+	if col.deckByName(strings.Join(path, deckPathSeparator)) != nil {
+		respondAPIError(w, http.StatusConflict, "deck_exists", "A deck with this name already exists")
+		return
+	}
+	session := h.sessionFromRequest(r)
+	usage := h.usageForSession(r.Context(), session)
+	usage.Decks += len(col.missingDeckAncestors(path))
+	if err := validateDeckLimit(h.planForRequest(r, session), usage); err != nil {
+		respondAPIError(w, http.StatusForbidden, "plan_limit_exceeded", err.Error())
+		return
+	}
+
+	deck, err := h.createDeckPath(r.Context(), col, collectionID, path)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "deck_create_failed", err.Error())
+		return
+	}
+	def.DeckID, def.Name = deck.ID, deck.Name
+	if err := h.store.CreateFilteredDeck(r.Context(), &def); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "filtered_deck_create_failed", err.Error())
+		return
+	}
+	h.rebuildFilteredDeck(w, r, col, collectionID, &def, http.StatusCreated)
+}
+
+// ListFilteredDecks serves GET /api/filtered-decks.
+func (h *APIHandler) ListFilteredDecks(w http.ResponseWriter, r *http.Request) {
+	_, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	decks, err := h.store.ListFilteredDecks(r.Context(), collectionID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "filtered_deck_list_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, filteredDecksResponse{FilteredDecks: decks})
+}
+
+// filteredDeckForRequest loads the filtered deck named by the id URL parameter. It
+// writes an error response and returns nil when there is none in the collection.
+func (h *APIHandler) filteredDeckForRequest(w http.ResponseWriter, r *http.Request, collectionID string) *FilteredDeck {
+	id, err := parseIDParam(r, "id")
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_deck_id", "Invalid deck ID")
+		return nil
+	}
+	if deckCollectionID, err := h.store.GetDeckCollectionID(r.Context(), id); err != nil || deckCollectionID != collectionID {
+		respondAPIError(w, http.StatusNotFound, "deck_not_found", "Deck not found")
+		return nil
+	}
+	def, err := h.store.GetFilteredDeck(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondAPIError(w, http.StatusNotFound, "filtered_deck_not_found", "This deck is not a filtered deck")
+		return nil
+	}
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "filtered_deck_load_failed", err.Error())
+		return nil
+	}
+	return def
+}
+
+// RebuildFilteredDeck serves POST /api/filtered-decks/{id}/rebuild.
+func (h *APIHandler) RebuildFilteredDeck(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	def := h.filteredDeckForRequest(w, r, collectionID)
+	if def == nil {
+		return
+	}
+	h.rebuildFilteredDeck(w, r, col, collectionID, def, http.StatusOK)
+}
+
+func (h *APIHandler) rebuildFilteredDeck(w http.ResponseWriter, r *http.Request, col *Collection, collectionID string, def *FilteredDeck, status int) {
+	moves, err := h.store.RebuildFilteredDeck(r.Context(), h.userIDFromRequest(r), collectionID, def, time.Now())
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "filtered_deck_build_failed", err.Error())
+		return
+	}
+	h.applyDeckMoves(col, moves)
+	h.respondFilteredDeck(w, r, def.DeckID, status)
+}
+
+// EmptyFilteredDeck serves POST /api/filtered-decks/{id}/empty.
+func (h *APIHandler) EmptyFilteredDeck(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	def := h.filteredDeckForRequest(w, r, collectionID)
+	if def == nil {
+		return
+	}
+	moves, err := h.store.EmptyFilteredDeck(r.Context(), def.DeckID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "filtered_deck_empty_failed", err.Error())
+		return
+	}
+	h.applyDeckMoves(col, moves)
+	h.respondFilteredDeck(w, r, def.DeckID, http.StatusOK)
+}
+
+func (h *APIHandler) respondFilteredDeck(w http.ResponseWriter, r *http.Request, deckID int64, status int) {
+	def, err := h.store.GetFilteredDeck(r.Context(), deckID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "filtered_deck_load_failed", err.Error())
+		return
+	}
+	ids, err := h.store.queryIDs(r.Context(), `SELECT id FROM cards WHERE deck_id = ? ORDER BY filtered_position, id`, deckID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "filtered_deck_load_failed", err.Error())
+		return
+	}
+	respondJSON(w, status, FilteredDeckResponse{FilteredDeck: *def, CardIDs: ids})
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAPI_FilteredDeckLifecycle(t *testing.T) {
+	env := setupAPITestEnv(t)
+	var ids []int64
+	for _, front := range []string{"Ribosome", "Lysosome", "Centrosome"} {
+		ids = append(ids, createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": front, "Back": "Organelle"},
+		}, nil).Cards[0].ID)
+	}
+	getCard := func(id int64) Card {
+		return decodeJSON[Card](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/cards/%d", id), ""))
+	}
+
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/filtered-decks", CreateFilteredDeckRequest{Name: "Cram", Query: "(front:x"}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid search to be rejected, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	limit, reschedule := 2, false
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/filtered-decks", CreateFilteredDeckRequest{
+		Name: "Cram", Query: "front:*some", Limit: &limit, Order: filteredOrderAdded, Reschedule: &reschedule,
+	})
+	created := decodeJSON[FilteredDeckResponse](t, rr)
+	if rr.Code != http.StatusCreated || len(created.CardIDs) != 2 || created.CardIDs[0] != ids[0] || created.CardIDs[1] != ids[1] {
+		t.Fatalf("expected the first two matching cards pulled in, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if card := getCard(ids[0]); card.DeckID != created.DeckID || card.OriginalDeckID != 1 {
+		t.Fatalf("expected the card to remember its home deck, got %+v", card)
+	}
+
+	due := decodeJSON[[]Card](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/decks/%d/due", created.DeckID), ""))
+	if len(due) != 2 || due[0].ID != ids[0] {
+		t.Fatalf("expected the filtered deck's cards in build order, got %+v", due)
+	}
+
+	// Without rescheduling an answer is logged as filtered practice and sends the card home.
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", ids[0]), AnswerCardRequest{Rating: 3}); rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	card := getCard(ids[0])
+	if card.DeckID != 1 || card.OriginalDeckID != 0 || card.SRS.Reps != 0 {
+		t.Fatalf("expected the card home with its schedule untouched, got %+v", card)
+	}
+	entries, err := env.store.ListRevlogEntriesForCard(context.Background(), "", ids[0], 10)
+	if err != nil || len(entries) != 1 || entries[0].Kind != reviewKindFiltered {
+		t.Fatalf("expected a filtered revlog entry, got %+v (%v)", entries, err)
+	}
+
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/move", MoveCardsRequest{TargetDeckID: created.DeckID, CardIDs: []int64{ids[2]}}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected moving cards into a filtered deck to be rejected, got %d", rr.Code)
+	}
+
+	rr = doRawRequest(env.router, http.MethodPost, fmt.Sprintf("/api/filtered-decks/%d/empty", created.DeckID), "")
+	if emptied := decodeJSON[FilteredDeckResponse](t, rr); rr.Code != http.StatusOK || len(emptied.CardIDs) != 0 {
+		t.Fatalf("expected the deck emptied, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if card := getCard(ids[1]); card.DeckID != 1 || card.OriginalDeckID != 0 {
+		t.Fatalf("expected the card back home, got %+v", card)
+	}
+
+	rr = doRawRequest(env.router, http.MethodPost, fmt.Sprintf("/api/filtered-decks/%d/rebuild", created.DeckID), "")
+	if rebuilt := decodeJSON[FilteredDeckResponse](t, rr); rr.Code != http.StatusOK || len(rebuilt.CardIDs) != 2 || rebuilt.BuiltAt.IsZero() {
+		t.Fatalf("expected the deck rebuilt, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doRawRequest(env.router, http.MethodDelete, fmt.Sprintf("/api/decks/%d", created.DeckID), ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected the filtered deck deleted, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	for _, id := range ids {
+		if card := getCard(id); card.DeckID != 1 || card.OriginalDeckID != 0 {
+			t.Fatalf("expected every card home after deleting the filtered deck, got %+v", card)
+		}
+	}
+	list := decodeJSON[filteredDecksResponse](t, doRawRequest(env.router, http.MethodGet, "/api/filtered-decks", ""))
+	if len(list.FilteredDecks) != 0 {
+		t.Fatalf("expected no filtered decks left, got %+v", list.FilteredDecks)
+	}
+}
+
+func TestAPI_FilteredDeckReschedulesUntilGraduated(t *testing.T) {
+	env := setupAPITestEnv(t)
+	id := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Nucleus", "Back": "Control"},
+	}, nil).Cards[0].ID
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/filtered-decks", CreateFilteredDeckRequest{Name: "Exam", Query: "Nucleus"})
+	created := decodeJSON[FilteredDeckResponse](t, rr)
+	if rr.Code != http.StatusCreated || len(created.CardIDs) != 1 {
+		t.Fatalf("expected the card pulled in, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	answer := func(rating int) Card {
+		rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", id), AnswerCardRequest{Rating: rating})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+		return decodeJSON[Card](t, rr)
+	}
+	if card := answer(1); card.DeckID != created.DeckID || card.SRS.Reps != 1 {
+		t.Fatalf("expected a learning card rescheduled but kept in the filtered deck, got %+v", card)
+	}
+	if card := answer(4); card.DeckID != 1 || card.OriginalDeckID != 0 {
+		t.Fatalf("expected the graduated card back home, got %+v", card)
+	}
+}
+
+func TestAPI_EditingNoteLeavesSiblingsOutOfItsFilteredDeck(t *testing.T) {
+	env := setupAPITestEnv(t)
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic (and reversed card)",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Golgi", "Back": "Packaging"},
+	}, nil)
+	if len(created.Cards) != 2 {
+		t.Fatalf("expected two cards, got %d", len(created.Cards))
+	}
+	getCard := func(id int64) Card {
+		return decodeJSON[Card](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/cards/%d", id), ""))
+	}
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/filtered-decks", CreateFilteredDeckRequest{Name: "Cram", Query: "Golgi card:1"})
+	filtered := decodeJSON[FilteredDeckResponse](t, rr)
+	if rr.Code != http.StatusCreated || len(filtered.CardIDs) != 1 || filtered.CardIDs[0] != created.Cards[0].ID {
+		t.Fatalf("expected the first card pulled in, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	note := decodeJSON[NoteResponse](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/notes/%d", created.Note.ID), ""))
+	if note.DeckID != 1 {
+		t.Fatalf("expected the note to stay in its home deck, got %d", note.DeckID)
+	}
+	rr = doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/notes/%d", created.Note.ID), UpdateNoteRequest{
+		DeckID:    note.DeckID,
+		FieldVals: map[string]string{"Front": "Golgi apparatus", "Back": "Packaging"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the note updated, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if card := getCard(created.Cards[0].ID); card.DeckID != filtered.DeckID || card.OriginalDeckID != 1 {
+		t.Fatalf("expected the borrowed card to stay borrowed, got %+v", card)
+	}
+	if card := getCard(created.Cards[1].ID); card.DeckID != 1 || card.OriginalDeckID != 0 {
+		t.Fatalf("expected the sibling to stay home, got %+v", card)
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/notes/%d", created.Note.ID), UpdateNoteRequest{
+		DeckID:    filtered.DeckID,
+		FieldVals: map[string]string{"Front": "Golgi apparatus", "Back": "Packaging"},
+	}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected moving the note into a filtered deck to be rejected, got %d", rr.Code)
+	}
+
+	if rr := doRawRequest(env.router, http.MethodPost, fmt.Sprintf("/api/filtered-decks/%d/empty", filtered.DeckID), ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected the deck emptied, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	for _, card := range created.Cards {
+		if card := getCard(card.ID); card.DeckID != 1 || card.OriginalDeckID != 0 {
+			t.Fatalf("expected every card home after emptying, got %+v", card)
+		}
+	}
+}
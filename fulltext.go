@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The notes_fts table indexes each note's field values under the note's ID as rowid.
+// FTS5 is used when the SQLite build has it (go-sqlite3 needs the sqlite_fts5 build
+// tag; libsql always has it); otherwise the index falls back to FTS4, which every
+// go-sqlite3 build includes. Both are kept in sync by triggers on notes. PostgreSQL
+// has no notes_fts: notes are searched by their text search vector, which an
+// expression index keeps (see postgresNotesVector).
+const (
+	fullTextModuleFTS5     = "fts5"
+	fullTextModuleFTS4     = "fts4"
+	fullTextModulePostgres = "postgres"
+)
+
+type FullTextSearchResponse struct {
+	Query  string                 `json:"query"`
+	Total  int                    `json:"total"`
+	Offset int                    `json:"offset"`
+	Limit  int                    `json:"limit"`
+	Notes  []NoteListItemResponse `json:"notes"`
+}
+
+func notesFullTextCreateStatement(module string) string {
+	return fmt.Sprintf(`CREATE VIRTUAL TABLE IF NOT EXISTS notes_fts USING %s(content, tokenize=unicode61)`, module)
+}
+
+// notesFullTextStatements returns the triggers that keep notes_fts in step with notes,
+// followed by a backfill of existing notes.
+func notesFullTextStatements() []string {
+	content := `(SELECT COALESCE(group_concat(value, ' '), '') FROM json_each(NEW.field_vals))`
+	return []string{
+		`CREATE TRIGGER IF NOT EXISTS notes_fts_insert AFTER INSERT ON notes
+		BEGIN
+			INSERT INTO notes_fts (rowid, content) VALUES (NEW.id, ` + content + `);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS notes_fts_update AFTER UPDATE OF field_vals ON notes
+		BEGIN
+			DELETE FROM notes_fts WHERE rowid = OLD.id;
+			INSERT INTO notes_fts (rowid, content) VALUES (NEW.id, ` + content + `);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS notes_fts_delete AFTER DELETE ON notes
+		BEGIN
+			DELETE FROM notes_fts WHERE rowid = OLD.id;
+		END`,
+		`DELETE FROM notes_fts`,
+		`INSERT INTO notes_fts (rowid, content)
+		SELECT n.id, (SELECT COALESCE(group_concat(value, ' '), '') FROM json_each(n.field_vals)) FROM notes n`,
+	}
+}
+
+// postgresNotesVector is the text search vector of the string values in the field_vals
+// of the notes row qualified by prefix, such as "n.", indexed as written with no prefix.
+func postgresNotesVector(prefix string) string {
+	return `jsonb_to_tsvector('simple', ` + prefix + `field_vals::jsonb, '["string"]')`
+}
+
+// fullTextModule reports which FTS module backs notes_fts.
+func (s *SQLStore) fullTextModule(ctx context.Context) (string, error) {
+	if s.dialect == dialectPostgres {
+		return fullTextModulePostgres, nil
+	}
+	var definition string
+	if err := s.db.QueryRowContext(ctx, `SELECT sql FROM sqlite_master WHERE name = 'notes_fts'`).Scan(&definition); err != nil {
+		return "", err
+	}
+	if strings.Contains(strings.ToLower(definition), fullTextModuleFTS5) {
+		return fullTextModuleFTS5, nil
+	}
+	return fullTextModuleFTS4, nil
+}
+
+// fullTextMatchQuery turns user input into a MATCH expression, or on PostgreSQL a
+// tsquery, in which every word must appear. Words are quoted so operators in the input
+// are searched as text; a word ending in * matches as a prefix when allowPrefix is set.
+// It returns "" when the input has no searchable words.
+func fullTextMatchQuery(module, raw string, allowPrefix bool) string {
+	var phrases []string
+	for _, word := range strings.Fields(raw) {
+		prefix := allowPrefix && strings.HasSuffix(word, "*")
+		word = strings.ReplaceAll(strings.TrimRight(word, "*"), `"`, "")
+		if strings.IndexFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+			continue
+		}
+		switch {
+		case module == fullTextModulePostgres:
+			lexeme := "'" + strings.NewReplacer(`\`, `\\`, "'", "''").Replace(word) + "'"
+			if prefix {
+				lexeme += ":*"
+			}
+			phrases = append(phrases, lexeme)
+		case !prefix:
+			phrases = append(phrases, `"`+word+`"`)
+		case module == fullTextModuleFTS5:
+			phrases = append(phrases, `"`+word+`"*`)
+		default:
+			phrases = append(phrases, `"`+word+`*"`)
+		}
+	}
+	if module == fullTextModulePostgres {
+		return strings.Join(phrases, " & ")
+	}
+	return strings.Join(phrases, " ")
+}
+
+// fullTextMatchCondition is a condition on the notes row qualified by prefix that holds
+// when the note matches the query bound to its placeholder.
+func fullTextMatchCondition(module, prefix string) string {
+	if module == fullTextModulePostgres {
+		return postgresNotesVector(prefix) + ` @@ to_tsquery('simple', ?)`
+	}
+	return prefix + `id IN (SELECT rowid FROM notes_fts WHERE notes_fts MATCH ?)`
+}
+
+// FullTextSearchNotes returns one page of IDs of notes whose fields contain every word
+// of query, best matches first when the index can rank them, along with the total
+// number of matches.
+func (s *SQLStore) FullTextSearchNotes(ctx context.Context, collectionID, query string, offset, limit int) ([]int64, int, error) {
+	module, err := s.fullTextModule(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	match := fullTextMatchQuery(module, query, true)
+	if match == "" {
+		return []int64{}, 0, nil
+	}
+
+	from := `notes_fts JOIN notes n ON n.id = notes_fts.rowid WHERE notes_fts MATCH ?`
+	order := `n.modified_at DESC, n.id DESC`
+	switch module {
+	case fullTextModuleFTS5:
+		order = `notes_fts.rank, ` + order
+	case fullTextModulePostgres:
+		vector := postgresNotesVector("n.")
+		from = `notes n CROSS JOIN to_tsquery('simple', ?) AS q WHERE ` + vector + ` @@ q`
+		order = `ts_rank(` + vector + `, q) DESC, ` + order
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM `+from+` AND n.collection_id = ?
+	`, match, collectionID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	ids, err := s.queryIDs(ctx, `
+		SELECT n.id FROM `+from+` AND n.collection_id = ?
+		ORDER BY `+order+`
+		LIMIT ? OFFSET ?
+	`, match, collectionID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ids, total, nil
+}
+
+// FullTextSearch serves GET /api/search/fulltext?q=&limit=&offset=. Every word of q must
+// appear in the note's fields; end a word with * to match it as a prefix.
+func (h *APIHandler) FullTextSearch(w http.ResponseWriter, r *http.Request) {
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if fullTextMatchQuery(fullTextModuleFTS5, query, true) == "" {
+		respondAPIError(w, http.StatusBadRequest, "invalid_query", "q must contain at least one word")
+		return
+	}
+	limit := defaultSearchLimit
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			respondAPIError(w, http.StatusBadRequest, "invalid_limit", "Limit must be a positive integer")
+			return
+		}
+		limit = min(parsed, maxSearchLimit)
+	}
+	offset, err := parseCursorOffset(r.URL.Query().Get("offset"))
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_offset", "Offset must be a non-negative integer")
+		return
+	}
+
+	ids, total, err := h.store.FullTextSearchNotes(r.Context(), collectionID, query, offset, limit)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "search_failed", err.Error())
+		return
+	}
+
+	resp := FullTextSearchResponse{Query: query, Total: total, Offset: offset, Limit: limit, Notes: make([]NoteListItemResponse, 0, len(ids))}
+	for _, id := range ids {
+		note, ok := col.Notes[id]
+		if !ok {
+			continue
+		}
+		item, err := h.noteListItem(r.Context(), note, col)
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "search_failed", err.Error())
+			return
+		}
+		resp.Notes = append(resp.Notes, item)
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestAPI_FullTextSearchNotes(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	add := func(front, back string) createNoteAPIResponse {
+		return createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": front, "Back": back},
+		}, nil)
+	}
+	mitochondria := add("What is the powerhouse of the cell?", "The mitochondria")
+	ribosome := add("Where are proteins made?", "In the ribosome of the cell")
+	add("Capital of France", "Paris")
+
+	search := func(query string) FullTextSearchResponse {
+		t.Helper()
+		rr := doRawRequest(env.router, http.MethodGet, "/api/search/fulltext?q="+url.QueryEscape(query), "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("search %q: expected 200, got %d (%s)", query, rr.Code, rr.Body.String())
+		}
+		return decodeJSON[FullTextSearchResponse](t, rr)
+	}
+	ids := func(resp FullTextSearchResponse) map[int64]bool {
+		out := map[int64]bool{}
+		for _, note := range resp.Notes {
+			out[note.ID] = true
+		}
+		return out
+	}
+
+	if got := search("cell"); got.Total != 2 || !ids(got)[mitochondria.Note.ID] || !ids(got)[ribosome.Note.ID] {
+		t.Fatalf("expected both cell notes, got %+v", got)
+	}
+	if got := search("CELL powerhouse"); got.Total != 1 || !ids(got)[mitochondria.Note.ID] {
+		t.Fatalf("expected every word to be required, got %+v", got)
+	}
+	if got := search("mito*"); got.Total != 1 || !ids(got)[mitochondria.Note.ID] {
+		t.Fatalf("expected prefix match, got %+v", got)
+	}
+	if got := search(`cell" OR "paris`); got.Total != 0 {
+		t.Fatalf("expected FTS operators in input to be searched as text, got %+v", got)
+	}
+
+	rr := doJSONRequest(t, env.router, http.MethodPut, fmt.Sprintf("/api/notes/%d", ribosome.Note.ID), UpdateNoteRequest{
+		FieldVals: map[string]string{"Front": "Where are proteins made?", "Back": "Endoplasmic reticulum"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected note update 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if got := search("cell"); got.Total != 1 || ids(got)[ribosome.Note.ID] {
+		t.Fatalf("expected edited note to leave the index for its old text, got %+v", got)
+	}
+	if got := search("reticulum"); got.Total != 1 || !ids(got)[ribosome.Note.ID] {
+		t.Fatalf("expected edited note to be indexed under its new text, got %+v", got)
+	}
+
+	if rr := doRawRequest(env.router, http.MethodDelete, fmt.Sprintf("/api/notes/%d", mitochondria.Note.ID), ""); rr.Code != http.StatusNoContent && rr.Code != http.StatusOK {
+		t.Fatalf("expected note delete to succeed, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if got := search("powerhouse"); got.Total != 0 {
+		t.Fatalf("expected deleted note to leave the index, got %+v", got)
+	}
+
+	if rr := doRawRequest(env.router, http.MethodGet, "/api/search/fulltext?q="+url.QueryEscape(`"*"`), ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected query without words to be rejected, got %d", rr.Code)
+	}
+}
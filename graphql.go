@@ -0,0 +1,856 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// This file holds a deliberately small GraphQL engine: enough of the query language for
+// clients to fetch nested read models in one round trip. It supports queries with
+// variables, aliases, arguments, and @include/@skip. Mutations, fragments, and
+// introspection are rejected with an error.
+
+type graphQLValue = any
+
+// graphQLVariableRef is an unresolved `$name` reference inside an argument value.
+type graphQLVariableRef string
+
+type graphQLField struct {
+	Alias      string
+	Name       string
+	Args       map[string]graphQLValue
+	Directives []graphQLDirective
+	Selections []graphQLField
+}
+
+func (f graphQLField) responseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+type graphQLDirective struct {
+	Name string
+	Args map[string]graphQLValue
+}
+
+type graphQLVariableDef struct {
+	Name     string
+	Type     string
+	Default  graphQLValue
+	HasValue bool
+}
+
+type graphQLOperation struct {
+	Kind       string
+	Name       string
+	Variables  []graphQLVariableDef
+	Selections []graphQLField
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+type GraphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+func (req *GraphQLRequest) Validate(v *requestValidator) {
+	v.required("query", req.Query)
+}
+
+type GraphQLResponse struct {
+	Data   any            `json:"data"`
+	Errors []graphQLError `json:"errors,omitempty"`
+}
+
+// graphQLObject keeps response keys in selection order, as the spec requires.
+type graphQLObject struct {
+	keys   []string
+	values map[string]any
+}
+
+func newGraphQLObject() *graphQLObject {
+	return &graphQLObject{values: make(map[string]any)}
+}
+
+func (o *graphQLObject) set(key string, value any) {
+	if _, exists := o.values[key]; !exists {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *graphQLObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		encodedKey, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encodedKey)
+		buf.WriteByte(':')
+		encodedValue, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encodedValue)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Lexer
+
+type graphQLTokenKind int
+
+const (
+	graphQLTokenEOF graphQLTokenKind = iota
+	graphQLTokenPunct
+	graphQLTokenName
+	graphQLTokenInt
+	graphQLTokenFloat
+	graphQLTokenString
+)
+
+type graphQLToken struct {
+	kind  graphQLTokenKind
+	value string
+	pos   int
+}
+
+func lexGraphQL(src string) ([]graphQLToken, error) {
+	src = strings.TrimPrefix(src, "\uFEFF")
+	var tokens []graphQLToken
+	i := 0
+	for i < len(src) {
+		ch := src[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == ',':
+			i++
+		case ch == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.ContainsRune("{}()[]:!$=@", rune(ch)):
+			tokens = append(tokens, graphQLToken{kind: graphQLTokenPunct, value: string(ch), pos: i})
+			i++
+		case ch == '.':
+			if strings.HasPrefix(src[i:], "...") {
+				tokens = append(tokens, graphQLToken{kind: graphQLTokenPunct, value: "...", pos: i})
+				i += 3
+				continue
+			}
+			return nil, fmt.Errorf("unexpected character %q at %d", ch, i)
+		case ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z'):
+			start := i
+			for i < len(src) && (src[i] == '_' || (src[i] >= 'a' && src[i] <= 'z') || (src[i] >= 'A' && src[i] <= 'Z') || (src[i] >= '0' && src[i] <= '9')) {
+				i++
+			}
+			tokens = append(tokens, graphQLToken{kind: graphQLTokenName, value: src[start:i], pos: start})
+		case ch == '-' || (ch >= '0' && ch <= '9'):
+			start := i
+			i++
+			kind := graphQLTokenInt
+			for i < len(src) {
+				c := src[i]
+				if c >= '0' && c <= '9' {
+					i++
+				} else if c == '.' || c == 'e' || c == 'E' || ((c == '+' || c == '-') && (src[i-1] == 'e' || src[i-1] == 'E')) {
+					kind = graphQLTokenFloat
+					i++
+				} else {
+					break
+				}
+			}
+			tokens = append(tokens, graphQLToken{kind: kind, value: src[start:i], pos: start})
+		case ch == '"':
+			start := i
+			i++
+			var b strings.Builder
+			closed := false
+			for i < len(src) {
+				c := src[i]
+				if c == '"' {
+					closed = true
+					i++
+					break
+				}
+				if c == '\\' && i+1 < len(src) {
+					i++
+					switch src[i] {
+					case 'n':
+						b.WriteByte('\n')
+					case 't':
+						b.WriteByte('\t')
+					case 'r':
+						b.WriteByte('\r')
+					case 'u':
+						if i+4 >= len(src) {
+							return nil, fmt.Errorf("invalid unicode escape at %d", i)
+						}
+						code, err := strconv.ParseUint(src[i+1:i+5], 16, 32)
+						if err != nil {
+							return nil, fmt.Errorf("invalid unicode escape at %d", i)
+						}
+						b.WriteRune(rune(code))
+						i += 4
+					default:
+						b.WriteByte(src[i])
+					}
+					i++
+					continue
+				}
+				b.WriteByte(c)
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string at %d", start)
+			}
+			tokens = append(tokens, graphQLToken{kind: graphQLTokenString, value: b.String(), pos: start})
+		default:
+			return nil, fmt.Errorf("unexpected character %q at %d", ch, i)
+		}
+	}
+	tokens = append(tokens, graphQLToken{kind: graphQLTokenEOF, pos: len(src)})
+	return tokens, nil
+}
+
+// Parser
+
+type graphQLParser struct {
+	tokens []graphQLToken
+	pos    int
+}
+
+func parseGraphQLDocument(src string) ([]graphQLOperation, error) {
+	tokens, err := lexGraphQL(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &graphQLParser{tokens: tokens}
+
+	var operations []graphQLOperation
+	for p.peek().kind != graphQLTokenEOF {
+		op, err := p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, op)
+	}
+	if len(operations) == 0 {
+		return nil, fmt.Errorf("document does not contain an operation")
+	}
+	return operations, nil
+}
+
+func (p *graphQLParser) peek() graphQLToken {
+	return p.tokens[p.pos]
+}
+
+func (p *graphQLParser) next() graphQLToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != graphQLTokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *graphQLParser) isPunct(value string) bool {
+	tok := p.peek()
+	return tok.kind == graphQLTokenPunct && tok.value == value
+}
+
+func (p *graphQLParser) expectPunct(value string) error {
+	tok := p.next()
+	if tok.kind != graphQLTokenPunct || tok.value != value {
+		return fmt.Errorf("expected %q at %d", value, tok.pos)
+	}
+	return nil
+}
+
+func (p *graphQLParser) expectName() (string, error) {
+	tok := p.next()
+	if tok.kind != graphQLTokenName {
+		return "", fmt.Errorf("expected name at %d", tok.pos)
+	}
+	return tok.value, nil
+}
+
+func (p *graphQLParser) parseOperation() (graphQLOperation, error) {
+	op := graphQLOperation{Kind: "query"}
+	if p.isPunct("{") {
+		selections, err := p.parseSelectionSet()
+		op.Selections = selections
+		return op, err
+	}
+
+	kind, err := p.expectName()
+	if err != nil {
+		return op, err
+	}
+	switch kind {
+	case "query":
+	case "mutation", "subscription":
+		return op, fmt.Errorf("%s operations are not supported", kind)
+	case "fragment":
+		return op, fmt.Errorf("fragments are not supported")
+	default:
+		return op, fmt.Errorf("unknown operation type %q", kind)
+	}
+
+	if p.peek().kind == graphQLTokenName {
+		op.Name = p.next().value
+	}
+	if p.isPunct("(") {
+		p.next()
+		for !p.isPunct(")") {
+			def, err := p.parseVariableDefinition()
+			if err != nil {
+				return op, err
+			}
+			op.Variables = append(op.Variables, def)
+		}
+		p.next()
+	}
+	if p.isPunct("@") {
+		return op, fmt.Errorf("operation directives are not supported")
+	}
+
+	op.Selections, err = p.parseSelectionSet()
+	return op, err
+}
+
+func (p *graphQLParser) parseVariableDefinition() (graphQLVariableDef, error) {
+	var def graphQLVariableDef
+	if err := p.expectPunct("$"); err != nil {
+		return def, err
+	}
+	name, err := p.expectName()
+	if err != nil {
+		return def, err
+	}
+	def.Name = name
+	if err := p.expectPunct(":"); err != nil {
+		return def, err
+	}
+	def.Type, err = p.parseTypeRef()
+	if err != nil {
+		return def, err
+	}
+	if p.isPunct("=") {
+		p.next()
+		def.Default, err = p.parseValue(true)
+		if err != nil {
+			return def, err
+		}
+		def.HasValue = true
+	}
+	return def, nil
+}
+
+func (p *graphQLParser) parseTypeRef() (string, error) {
+	var typ string
+	if p.isPunct("[") {
+		p.next()
+		inner, err := p.parseTypeRef()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expectPunct("]"); err != nil {
+			return "", err
+		}
+		typ = "[" + inner + "]"
+	} else {
+		name, err := p.expectName()
+		if err != nil {
+			return "", err
+		}
+		typ = name
+	}
+	if p.isPunct("!") {
+		p.next()
+		typ += "!"
+	}
+	return typ, nil
+}
+
+func (p *graphQLParser) parseSelectionSet() ([]graphQLField, error) {
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	var fields []graphQLField
+	for !p.isPunct("}") {
+		if p.peek().kind == graphQLTokenEOF {
+			return nil, fmt.Errorf("unterminated selection set")
+		}
+		if p.isPunct("...") {
+			return nil, fmt.Errorf("fragments are not supported")
+		}
+		field, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	p.next()
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("selection set must not be empty")
+	}
+	return fields, nil
+}
+
+func (p *graphQLParser) parseField() (graphQLField, error) {
+	var field graphQLField
+	name, err := p.expectName()
+	if err != nil {
+		return field, err
+	}
+	if p.isPunct(":") {
+		p.next()
+		field.Alias = name
+		name, err = p.expectName()
+		if err != nil {
+			return field, err
+		}
+	}
+	field.Name = name
+
+	if p.isPunct("(") {
+		field.Args, err = p.parseArguments()
+		if err != nil {
+			return field, err
+		}
+	}
+	for p.isPunct("@") {
+		p.next()
+		directive := graphQLDirective{}
+		directive.Name, err = p.expectName()
+		if err != nil {
+			return field, err
+		}
+		if p.isPunct("(") {
+			directive.Args, err = p.parseArguments()
+			if err != nil {
+				return field, err
+			}
+		}
+		field.Directives = append(field.Directives, directive)
+	}
+	if p.isPunct("{") {
+		field.Selections, err = p.parseSelectionSet()
+		if err != nil {
+			return field, err
+		}
+	}
+	return field, nil
+}
+
+func (p *graphQLParser) parseArguments() (map[string]graphQLValue, error) {
+	if err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+	args := make(map[string]graphQLValue)
+	for !p.isPunct(")") {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.parseValue(false)
+		if err != nil {
+			return nil, err
+		}
+		args[name] = value
+	}
+	p.next()
+	return args, nil
+}
+
+func (p *graphQLParser) parseValue(constant bool) (graphQLValue, error) {
+	tok := p.next()
+	switch tok.kind {
+	case graphQLTokenInt:
+		return strconv.ParseInt(tok.value, 10, 64)
+	case graphQLTokenFloat:
+		return strconv.ParseFloat(tok.value, 64)
+	case graphQLTokenString:
+		return tok.value, nil
+	case graphQLTokenName:
+		switch tok.value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		default:
+			// Enum values are passed to resolvers as plain strings.
+			return tok.value, nil
+		}
+	case graphQLTokenPunct:
+		switch tok.value {
+		case "$":
+			if constant {
+				return nil, fmt.Errorf("variables are not allowed at %d", tok.pos)
+			}
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			return graphQLVariableRef(name), nil
+		case "[":
+			list := []any{}
+			for !p.isPunct("]") {
+				if p.peek().kind == graphQLTokenEOF {
+					return nil, fmt.Errorf("unterminated list")
+				}
+				item, err := p.parseValue(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, item)
+			}
+			p.next()
+			return list, nil
+		case "{":
+			obj := map[string]any{}
+			for !p.isPunct("}") {
+				name, err := p.expectName()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expectPunct(":"); err != nil {
+					return nil, err
+				}
+				item, err := p.parseValue(constant)
+				if err != nil {
+					return nil, err
+				}
+				obj[name] = item
+			}
+			p.next()
+			return obj, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected token at %d", tok.pos)
+}
+
+// Execution
+
+// graphQLResolver resolves one field on a parent value. Arguments have already had
+// variables substituted.
+type graphQLResolver func(source any, args map[string]any) (any, error)
+
+// graphQLFieldDef describes a schema field. Type names an object type in the schema, or
+// is empty for scalars; list-ness is inferred from the resolved value.
+type graphQLFieldDef struct {
+	Type    string
+	Resolve graphQLResolver
+}
+
+type graphQLSchema map[string]map[string]graphQLFieldDef
+
+// Limits on the work one query may ask for. A query is rejected before it runs when
+// its selections nest deeper than maxGraphQLDepth or select more than maxGraphQLFields
+// fields. As list fields repeat their selections for every item, execution also stops
+// resolving fields once maxGraphQLResolvedFields have been resolved.
+const (
+	maxGraphQLDepth          = 8
+	maxGraphQLFields         = 200
+	maxGraphQLResolvedFields = 10000
+)
+
+type graphQLExecutor struct {
+	schema    graphQLSchema
+	variables map[string]any
+	errors    []graphQLError
+	resolved  int
+}
+
+// checkGraphQLLimits reports a query whose selections exceed maxGraphQLDepth or
+// maxGraphQLFields.
+func checkGraphQLLimits(selections []graphQLField) error {
+	fields := 0
+	var walk func(selections []graphQLField, depth int) error
+	walk = func(selections []graphQLField, depth int) error {
+		if depth > maxGraphQLDepth {
+			return fmt.Errorf("query is nested deeper than %d levels", maxGraphQLDepth)
+		}
+		for _, field := range selections {
+			if fields++; fields > maxGraphQLFields {
+				return fmt.Errorf("query selects more than %d fields", maxGraphQLFields)
+			}
+			if err := walk(field.Selections, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(selections, 1)
+}
+
+// executeGraphQL parses and runs a query against the schema's "Query" root type.
+func executeGraphQL(schema graphQLSchema, req GraphQLRequest) (GraphQLResponse, bool) {
+	operations, err := parseGraphQLDocument(req.Query)
+	if err != nil {
+		return GraphQLResponse{Errors: []graphQLError{{Message: err.Error()}}}, false
+	}
+
+	var op *graphQLOperation
+	if req.OperationName == "" {
+		if len(operations) > 1 {
+			return GraphQLResponse{Errors: []graphQLError{{Message: "operationName is required when the document has several operations"}}}, false
+		}
+		op = &operations[0]
+	} else {
+		for i := range operations {
+			if operations[i].Name == req.OperationName {
+				op = &operations[i]
+				break
+			}
+		}
+		if op == nil {
+			return GraphQLResponse{Errors: []graphQLError{{Message: fmt.Sprintf("unknown operation %q", req.OperationName)}}}, false
+		}
+	}
+
+	if err := checkGraphQLLimits(op.Selections); err != nil {
+		return GraphQLResponse{Errors: []graphQLError{{Message: err.Error()}}}, false
+	}
+
+	variables := make(map[string]any, len(op.Variables))
+	for _, def := range op.Variables {
+		value, ok := req.Variables[def.Name]
+		if !ok && def.HasValue {
+			value, ok = def.Default, true
+		}
+		if (!ok || value == nil) && strings.HasSuffix(def.Type, "!") {
+			return GraphQLResponse{Errors: []graphQLError{{Message: fmt.Sprintf("variable $%s of type %s is required", def.Name, def.Type)}}}, false
+		}
+		variables[def.Name] = value
+	}
+
+	exec := &graphQLExecutor{schema: schema, variables: variables}
+	data := exec.executeSelections("Query", nil, op.Selections, nil)
+	return GraphQLResponse{Data: data, Errors: exec.errors}, true
+}
+
+func (e *graphQLExecutor) fail(path []any, format string, args ...any) {
+	e.errors = append(e.errors, graphQLError{Message: fmt.Sprintf(format, args...), Path: append([]any(nil), path...)})
+}
+
+func (e *graphQLExecutor) resolveValue(value graphQLValue) (any, error) {
+	switch v := value.(type) {
+	case graphQLVariableRef:
+		resolved, ok := e.variables[string(v)]
+		if !ok {
+			return nil, fmt.Errorf("variable $%s is not defined", string(v))
+		}
+		return resolved, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			resolved, err := e.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			resolved, err := e.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = resolved
+		}
+		return out, nil
+	default:
+		return value, nil
+	}
+}
+
+func (e *graphQLExecutor) resolveArgs(args map[string]graphQLValue) (map[string]any, error) {
+	out := make(map[string]any, len(args))
+	for name, value := range args {
+		resolved, err := e.resolveValue(value)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = resolved
+	}
+	return out, nil
+}
+
+func (e *graphQLExecutor) shouldInclude(field graphQLField) (bool, error) {
+	for _, directive := range field.Directives {
+		if directive.Name != "include" && directive.Name != "skip" {
+			return false, fmt.Errorf("unknown directive @%s", directive.Name)
+		}
+		args, err := e.resolveArgs(directive.Args)
+		if err != nil {
+			return false, err
+		}
+		condition, ok := args["if"].(bool)
+		if !ok {
+			return false, fmt.Errorf("directive @%s requires a Boolean if argument", directive.Name)
+		}
+		if directive.Name == "include" && !condition {
+			return false, nil
+		}
+		if directive.Name == "skip" && condition {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (e *graphQLExecutor) executeSelections(typeName string, source any, selections []graphQLField, path []any) *graphQLObject {
+	result := newGraphQLObject()
+	fields := e.schema[typeName]
+	for _, field := range selections {
+		key := field.responseKey()
+		fieldPath := append(append([]any(nil), path...), key)
+
+		include, err := e.shouldInclude(field)
+		if err != nil {
+			e.fail(fieldPath, "%s", err.Error())
+			result.set(key, nil)
+			continue
+		}
+		if !include {
+			continue
+		}
+		if field.Name == "__typename" {
+			result.set(key, typeName)
+			continue
+		}
+
+		def, ok := fields[field.Name]
+		if !ok {
+			e.fail(fieldPath, "cannot query field %q on type %q", field.Name, typeName)
+			result.set(key, nil)
+			continue
+		}
+		if e.resolved++; e.resolved > maxGraphQLResolvedFields {
+			if e.resolved == maxGraphQLResolvedFields+1 {
+				e.fail(fieldPath, "query resolves more than %d fields", maxGraphQLResolvedFields)
+			}
+			result.set(key, nil)
+			continue
+		}
+		args, err := e.resolveArgs(field.Args)
+		if err != nil {
+			e.fail(fieldPath, "%s", err.Error())
+			result.set(key, nil)
+			continue
+		}
+		value, err := def.Resolve(source, args)
+		if err != nil {
+			e.fail(fieldPath, "%s", err.Error())
+			result.set(key, nil)
+			continue
+		}
+		result.set(key, e.completeValue(def.Type, value, field, fieldPath))
+	}
+	return result
+}
+
+func (e *graphQLExecutor) completeValue(typeName string, value any, field graphQLField, path []any) any {
+	if value == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil
+	}
+
+	if typeName == "" {
+		if len(field.Selections) > 0 {
+			e.fail(path, "field %q is a scalar and cannot have a selection set", field.Name)
+			return nil
+		}
+		return value
+	}
+	if len(field.Selections) == 0 {
+		e.fail(path, "field %q of type %q must have a selection set", field.Name, typeName)
+		return nil
+	}
+
+	if rv.Kind() == reflect.Slice {
+		items := make([]any, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			items[i] = e.completeValue(typeName, rv.Index(i).Interface(), field, append(append([]any(nil), path...), i))
+		}
+		return items
+	}
+	return e.executeSelections(typeName, value, field.Selections, path)
+}
+
+// Argument helpers shared by resolvers.
+
+func graphQLIntArg(args map[string]any, name string, fallback int64) (int64, error) {
+	value, ok := args[name]
+	if !ok || value == nil {
+		return fallback, nil
+	}
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case float64:
+		if v != float64(int64(v)) {
+			return 0, fmt.Errorf("argument %q must be an Int", name)
+		}
+		return int64(v), nil
+	case json.Number:
+		return v.Int64()
+	case string:
+		// IDs may arrive as strings from clients that follow the ID scalar convention.
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("argument %q must be an Int", name)
+		}
+		return parsed, nil
+	default:
+		return 0, fmt.Errorf("argument %q must be an Int", name)
+	}
+}
+
+func graphQLRequiredIntArg(args map[string]any, name string) (int64, error) {
+	if value, ok := args[name]; !ok || value == nil {
+		return 0, fmt.Errorf("argument %q is required", name)
+	}
+	return graphQLIntArg(args, name, 0)
+}
+
+func graphQLStringArg(args map[string]any, name string) (string, bool, error) {
+	value, ok := args[name]
+	if !ok || value == nil {
+		return "", false, nil
+	}
+	str, ok := value.(string)
+	if !ok {
+		return "", false, fmt.Errorf("argument %q must be a String", name)
+	}
+	return str, true, nil
+}
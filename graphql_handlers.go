@@ -0,0 +1,345 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+const maxGraphQLListLimit = 500
+
+// NoteField is a single named field value, exposed in note type field order.
+type NoteField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func graphQLScalar[T any](get func(T) any) graphQLFieldDef {
+	return graphQLFieldDef{Resolve: func(source any, _ map[string]any) (any, error) {
+		return get(source.(T)), nil
+	}}
+}
+
+func graphQLLimitArg(args map[string]any, fallback int64) (int, error) {
+	limit, err := graphQLIntArg(args, "limit", fallback)
+	if err != nil {
+		return 0, err
+	}
+	if limit <= 0 {
+		return 0, fmt.Errorf("argument \"limit\" must be positive")
+	}
+	return int(min(limit, maxGraphQLListLimit)), nil
+}
+
+// graphQLDueCardFilter maps the optional flag, marked, and tag arguments of
+// Deck.dueCards onto the study queue filter.
+func graphQLDueCardFilter(args map[string]any) (DueCardFilter, error) {
+	var filter DueCardFilter
+	if _, ok := args["flag"]; ok {
+		flag, err := graphQLIntArg(args, "flag", 0)
+		if err != nil {
+			return filter, err
+		}
+		filter.Flags = []int{int(flag)}
+	}
+	if value, ok := args["marked"]; ok && value != nil {
+		marked, ok := value.(bool)
+		if !ok {
+			return filter, fmt.Errorf("argument \"marked\" must be a Boolean")
+		}
+		filter.Marked = &marked
+	}
+	tag, ok, err := graphQLStringArg(args, "tag")
+	if err != nil {
+		return filter, err
+	}
+	if ok {
+		filter.Tags = []string{tag}
+	}
+	return filter, nil
+}
+
+// graphQLSchemaForRequest wires the read-only schema to the same store calls the REST
+// handlers use, scoped to the caller's collection and per-user review state.
+func (h *APIHandler) graphQLSchemaForRequest(r *http.Request) (graphQLSchema, error) {
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	userID := h.userIDFromRequest(r)
+	if err := h.store.EnsureReviewStatesForUser(r.Context(), userID); err != nil {
+		return nil, err
+	}
+
+	loadDeck := func(id int64) (*Deck, error) {
+		deckCollectionID, err := h.store.GetDeckCollectionID(r.Context(), id)
+		if err == sql.ErrNoRows || (err == nil && deckCollectionID != collectionID) {
+			return nil, fmt.Errorf("deck %d not found", id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return h.store.GetDeck(r.Context(), id)
+	}
+	loadCard := func(id int64) (*Card, error) {
+		card, err := h.store.GetCardForUser(r.Context(), userID, id)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("card %d not found", id)
+		}
+		return card, err
+	}
+	loadNote := func(id int64) (*Note, error) {
+		note, err := h.store.GetNote(r.Context(), id)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("note %d not found", id)
+		}
+		return note, err
+	}
+	loadStats := func(deckID int64) (*DeckStats, error) {
+		return h.store.GetDeckStatsForUser(r.Context(), userID, deckID)
+	}
+	loadRevlog := func(cardID int64, args map[string]any) (any, error) {
+		limit, err := graphQLLimitArg(args, 50)
+		if err != nil {
+			return nil, err
+		}
+		return h.store.ListRevlogEntriesForCard(r.Context(), userID, cardID, limit)
+	}
+
+	schema := graphQLSchema{
+		"Query": {
+			"decks": {Type: "Deck", Resolve: func(_ any, _ map[string]any) (any, error) {
+				return h.store.ListDecks(r.Context(), collectionID)
+			}},
+			"deck": {Type: "Deck", Resolve: func(_ any, args map[string]any) (any, error) {
+				id, err := graphQLRequiredIntArg(args, "id")
+				if err != nil {
+					return nil, err
+				}
+				return loadDeck(id)
+			}},
+			"deckStats": {Type: "DeckStats", Resolve: func(_ any, args map[string]any) (any, error) {
+				id, err := graphQLRequiredIntArg(args, "deckId")
+				if err != nil {
+					return nil, err
+				}
+				if _, err := loadDeck(id); err != nil {
+					return nil, err
+				}
+				return loadStats(id)
+			}},
+			"card": {Type: "Card", Resolve: func(_ any, args map[string]any) (any, error) {
+				id, err := graphQLRequiredIntArg(args, "id")
+				if err != nil {
+					return nil, err
+				}
+				return loadCard(id)
+			}},
+			"note": {Type: "Note", Resolve: func(_ any, args map[string]any) (any, error) {
+				id, err := graphQLRequiredIntArg(args, "id")
+				if err != nil {
+					return nil, err
+				}
+				return loadNote(id)
+			}},
+			"revlog": {Type: "RevlogEntry", Resolve: func(_ any, args map[string]any) (any, error) {
+				cardID, err := graphQLRequiredIntArg(args, "cardId")
+				if err != nil {
+					return nil, err
+				}
+				return loadRevlog(cardID, args)
+			}},
+		},
+		"Deck": {
+			"id":            graphQLScalar(func(d *Deck) any { return d.ID }),
+			"name":          graphQLScalar(func(d *Deck) any { return d.Name }),
+			"parentId":      graphQLScalar(func(d *Deck) any { return d.ParentID }),
+			"priorityOrder": graphQLScalar(func(d *Deck) any { return d.PriorityOrder }),
+			"cardCount":     graphQLScalar(func(d *Deck) any { return len(d.Cards) }),
+			"stats": {Type: "DeckStats", Resolve: func(source any, _ map[string]any) (any, error) {
+				return loadStats(source.(*Deck).ID)
+			}},
+			"dueCards": {Type: "Card", Resolve: func(source any, args map[string]any) (any, error) {
+				limit, err := graphQLLimitArg(args, 10)
+				if err != nil {
+					return nil, err
+				}
+				filter, err := graphQLDueCardFilter(args)
+				if err != nil {
+					return nil, err
+				}
+				return h.dueCardsForUser(r.Context(), collectionID, userID, source.(*Deck).ID, limit, filter)
+			}},
+		},
+		"Card": {
+			"id":           graphQLScalar(func(c *Card) any { return c.ID }),
+			"noteId":       graphQLScalar(func(c *Card) any { return c.NoteID }),
+			"deckId":       graphQLScalar(func(c *Card) any { return c.DeckID }),
+			"templateName": graphQLScalar(func(c *Card) any { return c.TemplateName }),
+			"ordinal":      graphQLScalar(func(c *Card) any { return c.Ordinal }),
+			"front":        graphQLScalar(func(c *Card) any { return c.Front }),
+			"back":         graphQLScalar(func(c *Card) any { return c.Back }),
+			"due":          graphQLScalar(func(c *Card) any { return c.SRS.Due }),
+			"state":        graphQLScalar(func(c *Card) any { return int(c.SRS.State) }),
+			"stability":    graphQLScalar(func(c *Card) any { return c.SRS.Stability }),
+			"difficulty":   graphQLScalar(func(c *Card) any { return c.SRS.Difficulty }),
+			"reps":         graphQLScalar(func(c *Card) any { return c.SRS.Reps }),
+			"lapses":       graphQLScalar(func(c *Card) any { return c.SRS.Lapses }),
+			"lastReview":   graphQLScalar(func(c *Card) any { return c.SRS.LastReview }),
+			"flag":         graphQLScalar(func(c *Card) any { return c.Flag }),
+			"marked":       graphQLScalar(func(c *Card) any { return c.Marked }),
+			"suspended":    graphQLScalar(func(c *Card) any { return c.Suspended }),
+			"note": {Type: "Note", Resolve: func(source any, _ map[string]any) (any, error) {
+				return loadNote(source.(*Card).NoteID)
+			}},
+			"deck": {Type: "Deck", Resolve: func(source any, _ map[string]any) (any, error) {
+				return loadDeck(source.(*Card).DeckID)
+			}},
+			"revlog": {Type: "RevlogEntry", Resolve: func(source any, args map[string]any) (any, error) {
+				return loadRevlog(source.(*Card).ID, args)
+			}},
+		},
+		"Note": {
+			"id":         graphQLScalar(func(n *Note) any { return n.ID }),
+			"type":       graphQLScalar(func(n *Note) any { return string(n.Type) }),
+			"tags":       graphQLScalar(func(n *Note) any { return n.Tags }),
+			"createdAt":  graphQLScalar(func(n *Note) any { return n.CreatedAt }),
+			"modifiedAt": graphQLScalar(func(n *Note) any { return n.ModifiedAt }),
+			"fields": {Type: "NoteField", Resolve: func(source any, _ map[string]any) (any, error) {
+				return orderedNoteFields(col, source.(*Note)), nil
+			}},
+			"field": {Resolve: func(source any, args map[string]any) (any, error) {
+				name, ok, err := graphQLStringArg(args, "name")
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					return nil, fmt.Errorf("argument \"name\" is required")
+				}
+				value, exists := source.(*Note).FieldMap[name]
+				if !exists {
+					return nil, nil
+				}
+				return value, nil
+			}},
+			"cards": {Type: "Card", Resolve: func(source any, _ map[string]any) (any, error) {
+				cards, err := h.store.GetCardsByNote(r.Context(), source.(*Note).ID)
+				if err != nil {
+					return nil, err
+				}
+				out := make([]*Card, 0, len(cards))
+				for _, card := range cards {
+					userCard, err := loadCard(card.ID)
+					if err != nil {
+						return nil, err
+					}
+					out = append(out, userCard)
+				}
+				return out, nil
+			}},
+		},
+		"NoteField": {
+			"name":  graphQLScalar(func(f NoteField) any { return f.Name }),
+			"value": graphQLScalar(func(f NoteField) any { return f.Value }),
+		},
+		"RevlogEntry": {
+			"id":            graphQLScalar(func(e RevlogEntry) any { return e.ID }),
+			"cardId":        graphQLScalar(func(e RevlogEntry) any { return e.CardID }),
+			"rating":        graphQLScalar(func(e RevlogEntry) any { return e.Rating }),
+			"state":         graphQLScalar(func(e RevlogEntry) any { return e.State }),
+			"due":           graphQLScalar(func(e RevlogEntry) any { return e.Due }),
+			"reviewedAt":    graphQLScalar(func(e RevlogEntry) any { return e.ReviewedAt }),
+			"timeTakenMs":   graphQLScalar(func(e RevlogEntry) any { return e.TimeTakenMs }),
+			"kind":          graphQLScalar(func(e RevlogEntry) any { return e.Kind }),
+			"stability":     graphQLScalar(func(e RevlogEntry) any { return e.Stability }),
+			"difficulty":    graphQLScalar(func(e RevlogEntry) any { return e.Difficulty }),
+			"elapsedDays":   graphQLScalar(func(e RevlogEntry) any { return e.ElapsedDays }),
+			"scheduledDays": graphQLScalar(func(e RevlogEntry) any { return e.ScheduledDays }),
+		},
+		"DeckStats": {
+			"deckId":           graphQLScalar(func(s *DeckStats) any { return s.DeckID }),
+			"newCards":         graphQLScalar(func(s *DeckStats) any { return s.NewCards }),
+			"learning":         graphQLScalar(func(s *DeckStats) any { return s.Learning }),
+			"review":           graphQLScalar(func(s *DeckStats) any { return s.Review }),
+			"relearning":       graphQLScalar(func(s *DeckStats) any { return s.Relearning }),
+			"suspended":        graphQLScalar(func(s *DeckStats) any { return s.Suspended }),
+			"buried":           graphQLScalar(func(s *DeckStats) any { return s.Buried }),
+			"totalCards":       graphQLScalar(func(s *DeckStats) any { return s.TotalCards }),
+			"dueToday":         graphQLScalar(func(s *DeckStats) any { return s.DueToday }),
+			"dueReviewBacklog": graphQLScalar(func(s *DeckStats) any { return s.DueReviewBacklog }),
+			"newLimit":         graphQLScalar(func(s *DeckStats) any { return s.NewLimit }),
+			"reviewLimit":      graphQLScalar(func(s *DeckStats) any { return s.ReviewLimit }),
+			"newRemaining":     graphQLScalar(func(s *DeckStats) any { return s.NewRemaining }),
+			"reviewRemaining":  graphQLScalar(func(s *DeckStats) any { return s.ReviewRemaining }),
+		},
+	}
+	return schema, nil
+}
+
+// orderedNoteFields lists a note's fields in its note type's order, with any fields the
+// note type no longer declares appended alphabetically.
+func orderedNoteFields(col *Collection, note *Note) []NoteField {
+	fields := make([]NoteField, 0, len(note.FieldMap))
+	seen := make(map[string]bool, len(note.FieldMap))
+	if col != nil {
+		if nt, ok := col.NoteTypes[note.Type]; ok {
+			for _, name := range nt.Fields {
+				if value, exists := note.FieldMap[name]; exists {
+					fields = append(fields, NoteField{Name: name, Value: value})
+					seen[name] = true
+				}
+			}
+		}
+	}
+	var rest []string
+	for name := range note.FieldMap {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	for _, name := range rest {
+		fields = append(fields, NoteField{Name: name, Value: note.FieldMap[name]})
+	}
+	return fields
+}
+
+// GraphQL serves read-only GraphQL queries over decks, notes, cards, revlog, and stats.
+// Both POST with a JSON body and GET with query parameters are accepted.
+func (h *APIHandler) GraphQL(w http.ResponseWriter, r *http.Request) {
+	var req GraphQLRequest
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if raw := r.URL.Query().Get("variables"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &req.Variables); err != nil {
+				respondJSON(w, http.StatusBadRequest, GraphQLResponse{Errors: []graphQLError{{Message: "variables must be a JSON object"}}})
+				return
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, GraphQLResponse{Errors: []graphQLError{{Message: "Invalid request body"}}})
+		return
+	}
+	// Errors use the GraphQL response shape rather than validation_failed.
+	var v requestValidator
+	if req.Validate(&v); len(v.errs) > 0 {
+		respondJSON(w, http.StatusBadRequest, GraphQLResponse{Errors: []graphQLError{{Message: v.errs[0].Field + " " + v.errs[0].Message}}})
+		return
+	}
+
+	schema, err := h.graphQLSchemaForRequest(r)
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, GraphQLResponse{Errors: []graphQLError{{Message: err.Error()}}})
+		return
+	}
+
+	response, ok := executeGraphQL(schema, req)
+	if !ok {
+		respondJSON(w, http.StatusBadRequest, response)
+		return
+	}
+	respondJSON(w, http.StatusOK, response)
+}
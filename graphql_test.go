@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseGraphQLDocument_AliasesArgumentsAndVariables(t *testing.T) {
+	ops, err := parseGraphQLDocument(`
+		# leading comment
+		query Study($deck: Int!, $limit: Int = 5) {
+			first: deck(id: $deck) {
+				name
+				dueCards(limit: $limit) { id note { field(name: "Front") } }
+			}
+		}
+	`)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(ops) != 1 || ops[0].Name != "Study" || len(ops[0].Variables) != 2 {
+		t.Fatalf("unexpected operations: %+v", ops)
+	}
+	if def := ops[0].Variables[1]; def.Type != "Int" || !def.HasValue || def.Default != int64(5) {
+		t.Fatalf("unexpected default variable: %+v", def)
+	}
+	deck := ops[0].Selections[0]
+	if deck.Alias != "first" || deck.Name != "deck" || deck.Args["id"] != graphQLVariableRef("deck") {
+		t.Fatalf("unexpected deck field: %+v", deck)
+	}
+	note := deck.Selections[1].Selections[1]
+	if note.Selections[0].Args["name"] != "Front" {
+		t.Fatalf("expected string argument on note.field, got %+v", note.Selections[0])
+	}
+
+	for _, src := range []string{
+		`mutation { deleteDeck(id: 1) }`,
+		`{ deck(id: 1) { ...DeckParts } }`,
+		`{ deck(id: 1) { name }`,
+		`{ }`,
+	} {
+		if _, err := parseGraphQLDocument(src); err == nil {
+			t.Fatalf("expected parse error for %q", src)
+		}
+	}
+}
+
+func TestExecuteGraphQL_OrdersKeysAndReportsFieldErrors(t *testing.T) {
+	schema := graphQLSchema{
+		"Query": {
+			"greeting": {Resolve: func(_ any, args map[string]any) (any, error) {
+				name, _, err := graphQLStringArg(args, "name")
+				return "hello " + name, err
+			}},
+			"count": {Resolve: func(_ any, _ map[string]any) (any, error) { return 3, nil }},
+		},
+	}
+
+	resp, ok := executeGraphQL(schema, GraphQLRequest{
+		Query:     `query($who: String) { z: count, greeting(name: $who), missing, hidden: count @skip(if: true) }`,
+		Variables: map[string]any{"who": "deck"},
+	})
+	if !ok {
+		t.Fatalf("expected execution to succeed: %+v", resp.Errors)
+	}
+	encoded, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if !strings.HasPrefix(string(encoded), `{"data":{"z":3,"greeting":"hello deck","missing":null}`) {
+		t.Fatalf("unexpected response ordering: %s", encoded)
+	}
+	if len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Message, `"missing"`) {
+		t.Fatalf("expected one error for the unknown field, got %+v", resp.Errors)
+	}
+}
+
+func TestAPI_GraphQLFetchesNestedDeckCardsAndNotes(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID: "Basic",
+		DeckID: 1,
+		FieldVals: map[string]string{
+			"Front": "Capital of France",
+			"Back":  "Paris",
+		},
+		Tags: []string{"geo"},
+	}, nil)
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/graphql", GraphQLRequest{
+		Query: `query Deck($id: Int!) {
+			deck(id: $id) {
+				name
+				stats { totalCards }
+				dueCards(limit: 5) {
+					id
+					note { tags fields { name value } }
+				}
+			}
+		}`,
+		Variables: map[string]any{"id": 1},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected graphql 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	var resp struct {
+		Data struct {
+			Deck struct {
+				Name  string `json:"name"`
+				Stats struct {
+					TotalCards int `json:"totalCards"`
+				} `json:"stats"`
+				DueCards []struct {
+					ID   int64 `json:"id"`
+					Note struct {
+						Tags   []string    `json:"tags"`
+						Fields []NoteField `json:"fields"`
+					} `json:"note"`
+				} `json:"dueCards"`
+			} `json:"deck"`
+		} `json:"data"`
+		Errors []graphQLError `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode graphql response: %v (%s)", err, rr.Body.String())
+	}
+	if len(resp.Errors) > 0 {
+		t.Fatalf("unexpected graphql errors: %+v", resp.Errors)
+	}
+	deck := resp.Data.Deck
+	if deck.Name != "Default" || deck.Stats.TotalCards != 1 || len(deck.DueCards) != 1 {
+		t.Fatalf("unexpected deck payload: %s", rr.Body.String())
+	}
+	due := deck.DueCards[0]
+	if len(due.Note.Fields) != 2 || due.Note.Fields[0] != (NoteField{Name: "Front", Value: "Capital of France"}) {
+		t.Fatalf("expected note fields in note type order, got %+v", due.Note.Fields)
+	}
+	if due.ID != created.Cards[0].ID || len(due.Note.Tags) != 1 || due.Note.Tags[0] != "geo" {
+		t.Fatalf("unexpected due card payload: %+v", due)
+	}
+
+	answer := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", due.ID), AnswerCardRequest{
+		Rating:      1,
+		TimeTakenMs: 1200,
+	})
+	if answer.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", answer.Code, answer.Body.String())
+	}
+
+	revlogRR := doJSONRequest(t, env.router, http.MethodGet, fmt.Sprintf("/api/graphql?query=%s", url.QueryEscape(
+		fmt.Sprintf(`{ card(id: %d) { reps revlog { rating timeTakenMs } } }`, due.ID),
+	)), nil)
+	if revlogRR.Code != http.StatusOK {
+		t.Fatalf("expected graphql GET 200, got %d (%s)", revlogRR.Code, revlogRR.Body.String())
+	}
+	if body := revlogRR.Body.String(); !strings.Contains(body, `"reps":1`) || !strings.Contains(body, `"revlog":[{"rating":1,"timeTakenMs":1200}]`) {
+		t.Fatalf("expected answered card revlog in response, got %s", body)
+	}
+
+	bad := doJSONRequest(t, env.router, http.MethodPost, "/api/graphql", GraphQLRequest{Query: `{ deck(id: 1) { name }`})
+	if bad.Code != http.StatusBadRequest {
+		t.Fatalf("expected syntax error 400, got %d (%s)", bad.Code, bad.Body.String())
+	}
+}
+
+func TestExecuteGraphQL_RejectsQueriesOverTheLimits(t *testing.T) {
+	items := make([]int, 1000)
+	schema := graphQLSchema{
+		"Query": {"items": {Type: "Item", Resolve: func(_ any, _ map[string]any) (any, error) { return items, nil }}},
+		"Item": {
+			"items": {Type: "Item", Resolve: func(_ any, _ map[string]any) (any, error) { return items, nil }},
+			"n":     {Resolve: func(source any, _ map[string]any) (any, error) { return source, nil }},
+		},
+	}
+
+	deep := "{ items " + strings.Repeat("{ items ", maxGraphQLDepth) + "{ n }" + strings.Repeat(" }", maxGraphQLDepth+1) + " }"
+	wide := "{ items { " + strings.Repeat("n ", maxGraphQLFields) + "} }"
+	for name, query := range map[string]string{"deep": deep, "wide": wide} {
+		resp, ok := executeGraphQL(schema, GraphQLRequest{Query: query})
+		if ok || resp.Data != nil || len(resp.Errors) != 1 {
+			t.Fatalf("expected the %s query rejected before running, got %+v", name, resp)
+		}
+	}
+
+	resp, ok := executeGraphQL(schema, GraphQLRequest{Query: `{ items { items { n } } }`})
+	if !ok || len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Message, "more than") {
+		t.Fatalf("expected one error once the resolve budget ran out, got %d errors", len(resp.Errors))
+	}
+}
+
+func TestAPI_GraphQLRejectsDeeplyNestedQueries(t *testing.T) {
+	env := setupAPITestEnv(t)
+	query := "{ deck(id: 1) { " + strings.Repeat("dueCards { note { cards { ", 3) + "id" + strings.Repeat(" } } }", 3) + " } }"
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/graphql", GraphQLRequest{Query: query})
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "nested deeper") {
+		t.Fatalf("expected a deeply nested query rejected with 400, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
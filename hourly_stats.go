@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// HourlyReviews counts the answers given in one hour of the day, in the server's local
+// time like study days. An answer passes unless it was Again.
+type HourlyReviews struct {
+	Hour     int     `json:"hour"`
+	Reviews  int     `json:"reviews"`
+	Passed   int     `json:"passed"`
+	PassRate float64 `json:"passRate"`
+}
+
+type hourlyStatsResponse struct {
+	Days   int             `json:"days"`
+	DeckID int64           `json:"deckId,omitempty"`
+	Hours  []HourlyReviews `json:"hours"`
+}
+
+// GetHourlyReviewStats counts the user's answers in a collection since since by hour of
+// day, returning all 24 hours. A deckID of 0 covers every deck; otherwise the deck and
+// its descendants. Manual reschedules are not answers and are left out.
+func (s *SQLStore) GetHourlyReviewStats(ctx context.Context, userID, collectionID string, deckID int64, since time.Time) ([]HourlyReviews, error) {
+	query := `
+		SELECT ` + s.dialect.localHour("r.reviewed_at") + ` AS hour,
+			COUNT(*), COALESCE(SUM(CASE WHEN r.rating != ? THEN 1 ELSE 0 END), 0)
+		FROM revlog r
+		JOIN cards c ON c.id = r.card_id
+		JOIN decks d ON d.id = c.deck_id
+		WHERE d.collection_id = ?
+		  AND r.reviewed_at >= ?
+		  AND r.kind != ?
+	`
+	args := []interface{}{int(fsrs.Again), collectionID, since.Unix(), reviewKindManual}
+	if strings.TrimSpace(userID) != "" {
+		query += ` AND r.user_id = ?`
+		args = append(args, userID)
+	}
+	if deckID > 0 {
+		query = deckSubtreeCTE + query + ` AND c.deck_id IN (SELECT id FROM subtree)`
+		args = append([]interface{}{deckID}, args...)
+	}
+	query += ` GROUP BY hour`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hours := make([]HourlyReviews, 24)
+	for i := range hours {
+		hours[i].Hour = i
+	}
+	for rows.Next() {
+		var hour, reviews, passed int
+		if err := rows.Scan(&hour, &reviews, &passed); err != nil {
+			return nil, err
+		}
+		if hour < 0 || hour > 23 {
+			continue
+		}
+		hours[hour].Reviews, hours[hour].Passed = reviews, passed
+		if reviews > 0 {
+			hours[hour].PassRate = math.Round(float64(passed)/float64(reviews)*1000) / 1000
+		}
+	}
+	return hours, rows.Err()
+}
+
+// GetHourlyReviewStats serves GET /api/stats/hourly. Query parameters: days and deckId
+// (see statsPeriodFromRequest).
+func (h *APIHandler) GetHourlyReviewStats(w http.ResponseWriter, r *http.Request) {
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	days, deckID, since, ok := h.statsPeriodFromRequest(w, r, collectionID, col)
+	if !ok {
+		return
+	}
+	hours, err := h.store.GetHourlyReviewStats(r.Context(), h.userIDFromRequest(r), collectionID, deckID, since)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "hourly_stats_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, hourlyStatsResponse{Days: days, DeckID: deckID, Hours: hours})
+}
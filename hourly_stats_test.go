@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAPI_HourlyReviewStats(t *testing.T) {
+	env := setupAPITestEnv(t)
+	id := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Thalamus", "Back": "Relay"},
+	}, nil).Cards[0].ID
+	for _, rating := range []int{1, 3} {
+		if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", id), AnswerCardRequest{Rating: rating}); rr.Code != http.StatusOK {
+			t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := doRawRequest(env.router, http.MethodGet, "/api/stats/hourly?days=1&deckId=1", "")
+	resp := decodeJSON[hourlyStatsResponse](t, rr)
+	if rr.Code != http.StatusOK || len(resp.Hours) != 24 {
+		t.Fatalf("expected all 24 hours, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	// Both answers may straddle an hour boundary, so count across the day.
+	reviews, passed := 0, 0
+	for _, hour := range resp.Hours {
+		reviews += hour.Reviews
+		passed += hour.Passed
+	}
+	if now := resp.Hours[time.Now().Hour()]; reviews != 2 || passed != 1 || now.Reviews == 0 {
+		t.Fatalf("expected the answers in the current hour with one pass, got %+v", resp.Hours)
+	}
+	if rr := doRawRequest(env.router, http.MethodGet, "/api/stats/hourly?deckId=999", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown deck to be rejected, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// An Image Occlusion note holds an image and a set of mask rectangles. Masks sharing an
+// ordinal are hidden together, and each ordinal becomes one card: on the front its masks
+// are highlighted and every other mask stays covered, on the back its masks are
+// revealed. Masks are stored as JSON in the note's Masks field and rendered through the
+// {{image-occlusion:Field}} template filter, which works like {{cloze:Field}}.
+const (
+	imageOcclusionNoteType   NoteTypeName = "Image Occlusion"
+	imageOcclusionFilter                  = "image-occlusion"
+	imageOcclusionMaskField               = "Masks"
+	imageOcclusionImageField              = "Image"
+)
+
+// OcclusionMask is a rectangle over the image. Coordinates are fractions of the image's
+// width and height, so masks stay in place however the image is scaled.
+type OcclusionMask struct {
+	Ordinal int     `json:"ordinal"`
+	Left    float64 `json:"left"`
+	Top     float64 `json:"top"`
+	Width   float64 `json:"width"`
+	Height  float64 `json:"height"`
+	Label   string  `json:"label,omitempty"`
+}
+
+type OcclusionMasksRequest struct {
+	Masks []OcclusionMask `json:"masks"`
+}
+
+func (req *OcclusionMasksRequest) Validate(v *requestValidator) {
+	if _, err := normalizeOcclusionMasks(req.Masks); err != nil {
+		v.fail("masks", err.Error())
+	}
+}
+
+type OcclusionMasksResponse struct {
+	NoteID int64           `json:"noteId"`
+	Image  string          `json:"image"`
+	Masks  []OcclusionMask `json:"masks"`
+	Cards  []Card          `json:"cards,omitempty"`
+}
+
+var imageSrcRe = regexp.MustCompile(`(?i)<img[^>]*\ssrc\s*=\s*["']?([^"'\s>]+)`)
+
+func imageOcclusionBuiltin() NoteType {
+	front := `{{#Header}}<div class="io-header">{{Header}}</div>{{/Header}}` +
+		`<div class="image-occlusion" style="position:relative;display:inline-block">{{Image}}{{image-occlusion:Masks}}</div>`
+	return NoteType{
+		Name:   imageOcclusionNoteType,
+		Fields: []string{imageOcclusionImageField, imageOcclusionMaskField, "Header", "Back Extra"},
+		Templates: []CardTemplate{
+			{
+				Name:    "Occlusion",
+				QFmt:    front,
+				AFmt:    front + `{{#Back Extra}}<div class="io-back-extra">{{Back Extra}}</div>{{/Back Extra}}`,
+				IsCloze: true,
+			},
+		},
+		SortFieldIndex: 2,
+	}
+}
+
+// parseOcclusionMasks reads a Masks field. Field values are HTML-escaped when notes are
+// saved, so the JSON is unescaped first. An empty field has no masks.
+func parseOcclusionMasks(value string) ([]OcclusionMask, error) {
+	value = strings.TrimSpace(html.UnescapeString(value))
+	if value == "" {
+		return nil, nil
+	}
+	var masks []OcclusionMask
+	if err := json.Unmarshal([]byte(value), &masks); err != nil {
+		return nil, fmt.Errorf("masks are not valid JSON: %w", err)
+	}
+	return masks, nil
+}
+
+// normalizeOcclusionMasks validates masks and numbers any without an ordinal after the
+// highest one in use, so each new mask gets its own card.
+func normalizeOcclusionMasks(masks []OcclusionMask) ([]OcclusionMask, error) {
+	next := 1
+	for _, mask := range masks {
+		next = max(next, mask.Ordinal+1)
+	}
+	out := make([]OcclusionMask, 0, len(masks))
+	for i, mask := range masks {
+		switch {
+		case mask.Ordinal < 0:
+			return nil, fmt.Errorf("mask %d: ordinal cannot be negative", i+1)
+		case mask.Width <= 0 || mask.Height <= 0:
+			return nil, fmt.Errorf("mask %d: width and height must be positive", i+1)
+		case mask.Left < 0 || mask.Top < 0 || mask.Left+mask.Width > 1 || mask.Top+mask.Height > 1:
+			return nil, fmt.Errorf("mask %d: must lie within the image (coordinates are fractions from 0 to 1)", i+1)
+		}
+		if mask.Ordinal == 0 {
+			mask.Ordinal = next
+			next++
+		}
+		mask.Label = sanitizeHTML(strings.TrimSpace(mask.Label))
+		out = append(out, mask)
+	}
+	return out, nil
+}
+
+// occlusionOrdinals returns the distinct mask ordinals in fields, sorted.
+func occlusionOrdinals(fields map[string]string, names []string) []int {
+	seen := map[int]bool{}
+	var ordinals []int
+	for _, name := range names {
+		masks, err := parseOcclusionMasks(fields[name])
+		if err != nil {
+			continue
+		}
+		for _, mask := range masks {
+			if mask.Ordinal > 0 && !seen[mask.Ordinal] {
+				seen[mask.Ordinal] = true
+				ordinals = append(ordinals, mask.Ordinal)
+			}
+		}
+	}
+	sort.Ints(ordinals)
+	return ordinals
+}
+
+// renderOcclusionMasks draws the masks of a Masks field as absolutely positioned boxes
+// for the card numbered targetOrdinal.
+func renderOcclusionMasks(value string, targetOrdinal int, reveal bool) string {
+	masks, err := parseOcclusionMasks(value)
+	if err != nil {
+		return ""
+	}
+	var out strings.Builder
+	for _, mask := range masks {
+		class, background := "io-mask", "#ffeba2"
+		if mask.Ordinal == targetOrdinal {
+			class, background = "io-mask io-target", "#ff7e7e"
+			if reveal {
+				class, background = "io-mask io-revealed", "transparent"
+			}
+		}
+		fmt.Fprintf(&out, `<div class="%s" data-ordinal="%d" style="position:absolute;left:%.4f%%;top:%.4f%%;width:%.4f%%;height:%.4f%%;background:%s;border:1px solid #212121">`,
+			class, mask.Ordinal, mask.Left*100, mask.Top*100, mask.Width*100, mask.Height*100, background)
+		if mask.Label != "" && !(reveal && mask.Ordinal == targetOrdinal) {
+			out.WriteString(html.EscapeString(html.UnescapeString(mask.Label)))
+		}
+		out.WriteString(`</div>`)
+	}
+	return out.String()
+}
+
+// imageFilename returns the media file an Image field shows: the src of its <img>, or
+// the field itself when it holds a bare filename.
+func imageFilename(value string) string {
+	if m := imageSrcRe.FindStringSubmatch(value); m != nil {
+		return html.UnescapeString(m[1])
+	}
+	return strings.TrimSpace(value)
+}
+
+// occlusionNoteForRequest loads the note named in the URL and checks that its note type
+// renders masks. It writes an error response and returns false otherwise.
+func (h *APIHandler) occlusionNoteForRequest(w http.ResponseWriter, r *http.Request, col *Collection, collectionID string) (*Note, string, bool) {
+	id, err := parseIDParam(r, "id")
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_note_id", "Invalid note ID")
+		return nil, "", false
+	}
+	note, ok := col.Notes[id]
+	if !ok {
+		respondAPIError(w, http.StatusNotFound, "note_not_found", "Note not found")
+		return nil, "", false
+	}
+	for _, tmpl := range col.NoteTypes[note.Type].Templates {
+		if fields := templateFilterFields(tmpl, imageOcclusionFilter); len(fields) > 0 {
+			return &note, fields[0], true
+		}
+	}
+	respondAPIError(w, http.StatusBadRequest, "not_image_occlusion_note", "This note's type has no {{image-occlusion:Field}}")
+	return nil, "", false
+}
+
+// GetOcclusionMasks serves GET /api/notes/{id}/occlusions.
+func (h *APIHandler) GetOcclusionMasks(w http.ResponseWriter, r *http.Request) {
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	note, maskField, ok := h.occlusionNoteForRequest(w, r, col, collectionID)
+	if !ok {
+		return
+	}
+	masks, err := parseOcclusionMasks(note.FieldMap[maskField])
+	if err != nil {
+		respondAPIError(w, http.StatusUnprocessableEntity, "invalid_masks", err.Error())
+		return
+	}
+	if masks == nil {
+		masks = []OcclusionMask{}
+	}
+	respondJSON(w, http.StatusOK, OcclusionMasksResponse{
+		NoteID: note.ID,
+		Image:  imageFilename(note.FieldMap[imageOcclusionImageField]),
+		Masks:  masks,
+	})
+}
+
+// SetOcclusionMasks serves PUT /api/notes/{id}/occlusions, replacing the note's masks and
+// regenerating its cards. Cards keep their review history as long as their ordinal still
+// has a mask. The note's image must already be stored as media.
+func (h *APIHandler) SetOcclusionMasks(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	note, maskField, ok := h.occlusionNoteForRequest(w, r, col, collectionID)
+	if !ok {
+		return
+	}
+
+	var req OcclusionMasksRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	masks, _ := normalizeOcclusionMasks(req.Masks)
+	image := imageFilename(note.FieldMap[imageOcclusionImageField])
+	if image == "" {
+		respondAPIError(w, http.StatusBadRequest, "image_required", "Set the note's Image field before adding masks")
+		return
+	}
+	if _, err := h.store.GetMedia(r.Context(), image); err != nil {
+		respondAPIError(w, http.StatusBadRequest, "image_not_found", fmt.Sprintf("Media file %q not found", image))
+		return
+	}
+
+	encoded, err := json.Marshal(masks)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "note_update_failed", err.Error())
+		return
+	}
+	note.FieldMap[maskField] = string(encoded)
+	note.ModifiedAt = time.Now()
+	if err := h.store.UpdateNote(r.Context(), note); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "note_update_failed", err.Error())
+		return
+	}
+	cards, err := h.regenerateCardsForSingleNote(r.Context(), col, note, 0, nil)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "card_regeneration_failed", err.Error())
+		return
+	}
+	h.syncCollectionNote(col, note)
+
+	respondJSON(w, http.StatusOK, OcclusionMasksResponse{NoteID: note.ID, Image: image, Masks: masks, Cards: cards})
+}
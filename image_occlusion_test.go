@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderOcclusionMasks(t *testing.T) {
+	value := `[{"ordinal":1,"left":0.1,"top":0.2,"width":0.3,"height":0.1,"label":"Femur"},{"ordinal":2,"left":0.5,"top":0.5,"width":0.2,"height":0.2}]`
+
+	front := renderOcclusionMasks(value, 1, false)
+	if !strings.Contains(front, `class="io-mask io-target" data-ordinal="1"`) || !strings.Contains(front, `class="io-mask" data-ordinal="2"`) {
+		t.Fatalf("expected mask 1 highlighted and mask 2 covered, got %q", front)
+	}
+	if !strings.Contains(front, "left:10.0000%;top:20.0000%;width:30.0000%;height:10.0000%") {
+		t.Fatalf("expected percentage geometry, got %q", front)
+	}
+
+	back := renderOcclusionMasks(value, 1, true)
+	if !strings.Contains(back, `class="io-mask io-revealed" data-ordinal="1"`) || strings.Contains(back, "Femur") {
+		t.Fatalf("expected mask 1 revealed without its label, got %q", back)
+	}
+	if !strings.Contains(back, `class="io-mask" data-ordinal="2"`) {
+		t.Fatalf("expected mask 2 to stay covered on the back, got %q", back)
+	}
+
+	escaped := strings.ReplaceAll(value, `"`, "&#34;")
+	if ordinals := occlusionOrdinals(map[string]string{"Masks": escaped}, []string{"Masks"}); fmt.Sprint(ordinals) != "[1 2]" {
+		t.Fatalf("expected ordinals [1 2] from an escaped field, got %v", ordinals)
+	}
+}
+
+func TestNormalizeOcclusionMasks(t *testing.T) {
+	masks, err := normalizeOcclusionMasks([]OcclusionMask{
+		{Left: 0, Top: 0, Width: 0.5, Height: 0.5},
+		{Ordinal: 3, Left: 0.5, Top: 0.5, Width: 0.5, Height: 0.5},
+		{Left: 0.1, Top: 0.6, Width: 0.1, Height: 0.1},
+	})
+	if err != nil {
+		t.Fatalf("normalize failed: %v", err)
+	}
+	if masks[0].Ordinal != 4 || masks[1].Ordinal != 3 || masks[2].Ordinal != 5 {
+		t.Fatalf("expected ordinals 4, 3, 5, got %+v", masks)
+	}
+
+	for _, bad := range []OcclusionMask{
+		{Ordinal: -1, Width: 0.1, Height: 0.1},
+		{Width: 0, Height: 0.1},
+		{Left: 0.8, Width: 0.3, Height: 0.1},
+	} {
+		if _, err := normalizeOcclusionMasks([]OcclusionMask{bad}); err == nil {
+			t.Fatalf("expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestAPI_ImageOcclusionMasks(t *testing.T) {
+	env := setupAPITestEnv(t)
+	var collectionID string
+	if err := env.store.db.QueryRow("SELECT collection_id FROM decks WHERE id = 1").Scan(&collectionID); err != nil {
+		t.Fatalf("collection lookup failed: %v", err)
+	}
+	if err := env.store.AddMedia(context.Background(), collectionID, &MediaRef{Filename: "skeleton.png", Data: []byte("PNG"), AddedAt: time.Now()}); err != nil {
+		t.Fatalf("add media failed: %v", err)
+	}
+
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    string(imageOcclusionNoteType),
+		DeckID:    1,
+		FieldVals: map[string]string{"Image": `<img src="skeleton.png">`, "Header": "Bones"},
+	}, nil)
+	if len(created.Cards) != 0 {
+		t.Fatalf("expected no cards before masks are drawn, got %d", len(created.Cards))
+	}
+	path := fmt.Sprintf("/api/notes/%d/occlusions", created.Note.ID)
+
+	rr := doJSONRequest(t, env.router, http.MethodPut, path, OcclusionMasksRequest{Masks: []OcclusionMask{
+		{Left: 0.1, Top: 0.1, Width: 0.2, Height: 0.2, Label: "Skull"},
+		{Left: 0.4, Top: 0.5, Width: 0.2, Height: 0.3},
+	}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	updated := decodeJSON[OcclusionMasksResponse](t, rr)
+	if updated.Image != "skeleton.png" || len(updated.Masks) != 2 || len(updated.Cards) != 2 {
+		t.Fatalf("unexpected response %+v", updated)
+	}
+	first := updated.Cards[0]
+	if first.Ordinal != 1 || !strings.Contains(first.Front, `io-target" data-ordinal="1"`) || !strings.Contains(first.Front, "Bones") {
+		t.Fatalf("unexpected first card front %q", first.Front)
+	}
+	if !strings.Contains(first.Back, `io-revealed" data-ordinal="1"`) {
+		t.Fatalf("unexpected first card back %q", first.Back)
+	}
+
+	rr = doJSONRequest(t, env.router, http.MethodGet, path, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	got := decodeJSON[OcclusionMasksResponse](t, rr)
+	if len(got.Masks) != 2 || got.Masks[0].Label != "Skull" || got.Masks[1].Ordinal != 2 {
+		t.Fatalf("unexpected masks %+v", got.Masks)
+	}
+
+	// Dropping a mask removes its card and keeps the other.
+	rr = doJSONRequest(t, env.router, http.MethodPut, path, OcclusionMasksRequest{Masks: got.Masks[:1]})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if trimmed := decodeJSON[OcclusionMasksResponse](t, rr); len(trimmed.Cards) != 1 || trimmed.Cards[0].ID != first.ID {
+		t.Fatalf("expected card %d to survive, got %+v", first.ID, trimmed.Cards)
+	}
+
+	rr = doJSONRequest(t, env.router, http.MethodPut, path, OcclusionMasksRequest{Masks: []OcclusionMask{{Left: 0.9, Width: 0.2, Height: 0.1}}})
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected out-of-bounds mask to be rejected, got %d", rr.Code)
+	}
+
+	missing := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    string(imageOcclusionNoteType),
+		DeckID:    1,
+		FieldVals: map[string]string{"Image": "missing.png"},
+	}, nil)
+	rr = doJSONRequest(t, env.router, http.MethodPut, fmt.Sprintf("/api/notes/%d/occlusions", missing.Note.ID), OcclusionMasksRequest{Masks: got.Masks})
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "image_not_found") {
+		t.Fatalf("expected image_not_found, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	basic := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Q", "Back": "A"},
+	}, nil)
+	rr = doJSONRequest(t, env.router, http.MethodGet, fmt.Sprintf("/api/notes/%d/occlusions", basic.Note.ID), nil)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected Basic note to be rejected, got %d", rr.Code)
+	}
+}
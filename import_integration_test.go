@@ -3,6 +3,7 @@ package main
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"mime/multipart"
@@ -89,7 +90,7 @@ func TestAPI_ImportNotes_NativeJSONAndYAML(t *testing.T) {
 			t.Fatalf("expected created decks to include JSON Deck and Cloze Deck, got %+v", result.DecksCreated)
 		}
 
-		notes, err := env.store.ListNotes("default")
+		notes, err := env.store.ListNotes(context.Background(), "default")
 		if err != nil {
 			t.Fatalf("failed to list notes: %v", err)
 		}
@@ -121,7 +122,7 @@ func TestAPI_ImportNotes_NativeJSONAndYAML(t *testing.T) {
 			t.Fatalf("expected 1 imported note, got %+v", result)
 		}
 
-		notes, err := env.store.ListNotes("default")
+		notes, err := env.store.ListNotes(context.Background(), "default")
 		if err != nil {
 			t.Fatalf("failed to list notes: %v", err)
 		}
@@ -154,7 +155,7 @@ func TestAPI_ImportNotes_QuizletAndAnkiText(t *testing.T) {
 			t.Fatalf("expected 1 imported note, got %+v", result)
 		}
 
-		notes, err := env.store.ListNotes("default")
+		notes, err := env.store.ListNotes(context.Background(), "default")
 		if err != nil {
 			t.Fatalf("failed to list notes: %v", err)
 		}
@@ -185,7 +186,7 @@ func TestAPI_ImportNotes_QuizletAndAnkiText(t *testing.T) {
 			t.Fatalf("expected created deck Anki Text Deck, got %+v", result.DecksCreated)
 		}
 
-		notes, err := env.store.ListNotes("default")
+		notes, err := env.store.ListNotes(context.Background(), "default")
 		if err != nil {
 			t.Fatalf("failed to list notes: %v", err)
 		}
@@ -220,7 +221,7 @@ func TestAPI_ImportNotes_AnkiPackage(t *testing.T) {
 		t.Fatalf("expected imported deck to be created, got %+v", result.DecksCreated)
 	}
 
-	notes, err := env.store.ListNotes("default")
+	notes, err := env.store.ListNotes(context.Background(), "default")
 	if err != nil {
 		t.Fatalf("failed to list notes: %v", err)
 	}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// Importers from other SRS tools bring either a card's full review history or only a
+// summary of where its schedule stands. A history is replayed through FSRS answer by
+// answer, which gives both the FSRS state and a review log. A summary is mapped onto
+// FSRS state directly: the last interval stands in for stability, since the old
+// scheduler chose it to land near the same recall probability, and the ease factor
+// stands in for difficulty.
+
+// importScheduling is the progress of one imported card. Reviews win over Summary.
+type importScheduling struct {
+	Reviews []importReview
+	Summary *importSchedulingSummary
+}
+
+// importReview is one past answer, already mapped onto the FSRS rating scale.
+type importReview struct {
+	At          time.Time
+	Rating      fsrs.Rating
+	TimeTakenMs int
+}
+
+// importSchedulingSummary is a card's schedule as another tool last left it.
+type importSchedulingSummary struct {
+	LastReview   time.Time
+	Due          time.Time // zero means LastReview plus IntervalDays
+	IntervalDays float64
+	Reps         int
+	Lapses       int
+	// Difficulty is on FSRS's 1-10 scale; zero uses the FSRS default for a Good answer.
+	Difficulty float64
+}
+
+// fsrsStateForImport returns the FSRS state for an imported card and the review log
+// entries its history produced. The entries have no card ID yet.
+func fsrsStateForImport(params fsrs.Parameters, scheduling importScheduling, now time.Time) (fsrs.Card, []RevlogEntry) {
+	if len(scheduling.Reviews) > 0 {
+		return replayImportedReviews(params, scheduling.Reviews)
+	}
+	if scheduling.Summary != nil && scheduling.Summary.Reps > 0 && !scheduling.Summary.LastReview.IsZero() {
+		return fsrsStateFromSummary(params, *scheduling.Summary, now), nil
+	}
+	return defaultReviewStateCard(now), nil
+}
+
+func replayImportedReviews(params fsrs.Parameters, reviews []importReview) (fsrs.Card, []RevlogEntry) {
+	ordered := append([]importReview(nil), reviews...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].At.Before(ordered[j].At) })
+
+	scheduler := fsrs.NewFSRS(params)
+	card := fsrs.NewCard()
+	entries := make([]RevlogEntry, 0, len(ordered))
+	for _, review := range ordered {
+		if review.Rating < fsrs.Again || review.Rating > fsrs.Easy {
+			continue
+		}
+		info := scheduler.Repeat(card, review.At)[review.Rating]
+		stability, difficulty := info.Card.Stability, info.Card.Difficulty
+		entries = append(entries, RevlogEntry{
+			Rating:        int(info.ReviewLog.Rating),
+			State:         int(info.ReviewLog.State),
+			Due:           info.Card.Due,
+			ReviewedAt:    review.At,
+			TimeTakenMs:   review.TimeTakenMs,
+			Kind:          reviewKindForState(info.ReviewLog.State),
+			Stability:     &stability,
+			Difficulty:    &difficulty,
+			ElapsedDays:   int(info.ReviewLog.ElapsedDays),
+			ScheduledDays: int(info.ReviewLog.ScheduledDays),
+		})
+		card = info.Card
+	}
+	return card, entries
+}
+
+func fsrsStateFromSummary(params fsrs.Parameters, summary importSchedulingSummary, now time.Time) fsrs.Card {
+	interval := math.Max(summary.IntervalDays, 1)
+	difficulty := summary.Difficulty
+	if difficulty <= 0 {
+		difficulty = params.W[4]
+	}
+	due := summary.Due
+	if due.IsZero() {
+		due = summary.LastReview.Add(time.Duration(interval * float64(24*time.Hour)))
+	}
+	elapsed := 0.0
+	if now.After(summary.LastReview) {
+		elapsed = now.Sub(summary.LastReview).Hours() / 24
+	}
+	return fsrs.Card{
+		Due:           due,
+		Stability:     interval,
+		Difficulty:    math.Min(math.Max(difficulty, 1), 10),
+		ElapsedDays:   uint64(elapsed),
+		ScheduledDays: uint64(math.Round(interval)),
+		Reps:          uint64(summary.Reps),
+		Lapses:        uint64(max(summary.Lapses, 0)),
+		State:         fsrs.Review,
+		LastReview:    summary.LastReview,
+	}
+}
+
+// difficultyFromEase maps an SM-2 style ease factor onto FSRS difficulty: the hardest
+// ease a tool allows becomes 10 and easeForEasiest or above becomes 1.
+func difficultyFromEase(ease, hardestEase, easeForEasiest float64) float64 {
+	if ease <= 0 {
+		return 0
+	}
+	scaled := 10 - (ease-hardestEase)*9/(easeForEasiest-hardestEase)
+	return math.Min(math.Max(scaled, 1), 10)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// POST /api/import/url downloads a shared deck, an Anki .apkg or .colpkg or a Microdote
+// collection package, and imports it like an uploaded file. Only HTTPS URLs are fetched,
+// and the client refuses to connect to loopback, private and link-local addresses, also
+// after redirects and DNS changes, so the endpoint cannot reach the server's own network.
+
+const remoteImportTimeout = 2 * time.Minute
+
+var errRemoteImportAddress = errors.New("address is not publicly routable")
+
+// ImportURLRequest is the body of POST /api/import/url.
+type ImportURLRequest struct {
+	URL      string `json:"url"`
+	Source   string `json:"source"`
+	DeckName string `json:"deckName"`
+	NoteType string `json:"noteType"`
+}
+
+func (req *ImportURLRequest) Validate(v *requestValidator) {
+	if _, err := parseRemoteImportURL(req.URL); err != nil {
+		v.fail("url", strings.TrimPrefix(err.Error(), "url "))
+	}
+}
+
+// newRemoteImportClient returns the client used to fetch shared decks.
+func newRemoteImportClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 15 * time.Second,
+		// Control sees the address after DNS resolution, so a hostname that resolves to an
+		// internal address is refused too.
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publiclyRoutable(ip) {
+				return fmt.Errorf("%s: %w", host, errRemoteImportAddress)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   remoteImportTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			if req.URL.Scheme != "https" {
+				return errors.New("redirect to a non-HTTPS URL")
+			}
+			return nil
+		},
+	}
+}
+
+func publiclyRoutable(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// parseRemoteImportURL accepts absolute HTTPS URLs without credentials.
+func parseRemoteImportURL(raw string) (*url.URL, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || parsed.Host == "" {
+		return nil, errors.New("url must be an absolute HTTPS URL")
+	}
+	if parsed.Scheme != "https" {
+		return nil, errors.New("url must use HTTPS")
+	}
+	if parsed.User != nil {
+		return nil, errors.New("url must not contain credentials")
+	}
+	return parsed, nil
+}
+
+// remoteImportFilename names a download from its Content-Disposition or URL path.
+func remoteImportFilename(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return path.Base(params["filename"])
+	}
+	return path.Base(resp.Request.URL.Path)
+}
+
+// ImportFromURL handles POST /api/import/url.
+func (h *APIHandler) ImportFromURL(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	var req ImportURLRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	target, _ := parseRemoteImportURL(req.URL)
+
+	ctx, cancel := context.WithTimeout(r.Context(), remoteImportTimeout)
+	defer cancel()
+	fetchReq, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_url", err.Error())
+		return
+	}
+	resp, err := h.remoteImportClient.Do(fetchReq)
+	if err != nil {
+		if errors.Is(err, errRemoteImportAddress) {
+			respondAPIError(w, http.StatusBadRequest, "url_not_allowed", "The URL points to an address that cannot be fetched")
+			return
+		}
+		respondAPIError(w, http.StatusBadGateway, "fetch_failed", fmt.Sprintf("Could not download the deck: %v", err))
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respondAPIError(w, http.StatusBadGateway, "fetch_failed", fmt.Sprintf("Downloading the deck returned HTTP %d", resp.StatusCode))
+		return
+	}
+
+	maxBytes := h.config.Import.MaxURLBytes
+	tooLarge := func() {
+		respondAPIErrorWithDetails(w, http.StatusRequestEntityTooLarge, "deck_too_large",
+			fmt.Sprintf("Shared decks can be at most %s", formatByteSize(maxBytes)),
+			map[string]int64{"maxBytes": maxBytes})
+	}
+	if resp.ContentLength > maxBytes {
+		tooLarge()
+		return
+	}
+	var body bytes.Buffer
+	if _, err := io.Copy(&body, io.LimitReader(resp.Body, maxBytes+1)); err != nil {
+		respondAPIError(w, http.StatusBadGateway, "fetch_failed", fmt.Sprintf("Could not download the deck: %v", err))
+		return
+	}
+	if int64(body.Len()) > maxBytes {
+		tooLarge()
+		return
+	}
+
+	// Only deck packages are accepted, whatever the URL or Content-Type claims.
+	format := detectZipImportFormat(body.Bytes())
+	if format == "" {
+		respondAPIError(w, http.StatusUnsupportedMediaType, "unsupported_deck_package",
+			"The URL did not return an Anki package (.apkg, .colpkg) or a Microdote collection package")
+		return
+	}
+	if format == "apkg" && strings.EqualFold(path.Ext(remoteImportFilename(resp)), ".colpkg") {
+		format = "colpkg"
+	}
+
+	opts := importParseOptions{
+		Source:          req.Source,
+		FormatHint:      format,
+		Filename:        remoteImportFilename(resp),
+		DefaultDeckName: firstNonEmpty(req.DeckName, "Default"),
+		DefaultNoteType: firstNonEmpty(req.NoteType, "Basic"),
+	}
+	parsed, err := parseImportData(body.Bytes(), opts)
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_deck_package", err.Error())
+		return
+	}
+	h.respondWithImport(w, r, parsed, opts.DefaultDeckName)
+}
@@ -31,6 +31,9 @@ type importNormalizedNote struct {
 	NoteType NoteTypeName
 	Fields   map[string]string
 	Tags     []string
+	// Scheduling carries progress from another SRS tool, keyed by the card's position
+	// among the cards the note generates. Cards without an entry start as new.
+	Scheduling map[int]importScheduling
 }
 
 type nativeImportPayload struct {
@@ -90,6 +93,22 @@ func parseImportData(data []byte, opts importParseOptions) (importParserResult,
 			source = "native"
 		case "apkg", "colpkg":
 			source = "anki"
+		case "mdpkg":
+			source = "microdote"
+		case "md":
+			source = "markdown"
+		case "db":
+			source = "mnemosyne"
+		case "xml":
+			source = detectXMLImportSource(data)
+			if source == "" {
+				return importParserResult{}, errors.New("unrecognized XML export: expected Mnemosyne or SuperMemo XML")
+			}
+		case "txt":
+			source = "auto"
+			if looksLikeSuperMemoQA(data) {
+				source = "supermemo"
+			}
 		default:
 			source = "auto"
 		}
@@ -121,6 +140,33 @@ func parseImportData(data []byte, opts importParseOptions) (importParserResult,
 			return importParserResult{}, err
 		}
 		return importParserResult{Notes: notes, Source: "quizlet", Format: usedFormat}, nil
+	case "microdote":
+		notes, err := parseCollectionPackageImport(data, opts)
+		if err != nil {
+			return importParserResult{}, err
+		}
+		return importParserResult{Notes: notes, Source: "microdote", Format: "mdpkg"}, nil
+	case "mnemosyne":
+		notes, err := parseMnemosyneImport(data, format, opts)
+		if err != nil {
+			return importParserResult{}, err
+		}
+		return importParserResult{Notes: notes, Source: "mnemosyne", Format: format}, nil
+	case "supermemo":
+		notes, err := parseSuperMemoImport(data, format, opts)
+		if err != nil {
+			return importParserResult{}, err
+		}
+		if format != "xml" {
+			format = "txt"
+		}
+		return importParserResult{Notes: notes, Source: "supermemo", Format: format}, nil
+	case "markdown":
+		notes, err := parseMarkdownImport(data, opts)
+		if err != nil {
+			return importParserResult{}, err
+		}
+		return importParserResult{Notes: notes, Source: "markdown", Format: "md"}, nil
 	case "auto":
 		if format == "apkg" || format == "colpkg" {
 			notes, err := parseAnkiPackageImport(data, opts)
@@ -149,7 +195,7 @@ func normalizeImportSource(source string) string {
 		return "auto"
 	}
 	switch s {
-	case "auto", "native", "anki", "quizlet":
+	case "auto", "native", "anki", "quizlet", "markdown", "mnemosyne", "supermemo", "microdote":
 		return s
 	default:
 		return "auto"
@@ -160,9 +206,12 @@ func normalizeImportFormat(format string) string {
 	s := strings.ToLower(strings.TrimSpace(format))
 	s = strings.TrimPrefix(s, ".")
 	switch s {
-	case "json", "yaml", "yml", "csv", "tsv", "txt", "apkg", "colpkg":
-		if s == "yml" {
+	case "json", "yaml", "yml", "csv", "tsv", "txt", "apkg", "colpkg", "md", "markdown", "db", "xml", "mdpkg":
+		switch s {
+		case "yml":
 			return "yaml"
+		case "markdown":
+			return "md"
 		}
 		return s
 	default:
@@ -179,9 +228,26 @@ func detectImportFormat(filename string, data []byte) string {
 		return "yaml"
 	case "csv", "tsv", "txt", "apkg", "colpkg":
 		return ext
+	case "md", "markdown":
+		return "md"
+	case "db", "xml", "mdpkg":
+		return ext
+	case "zip":
+		if format := detectZipImportFormat(data); format != "" {
+			return format
+		}
 	}
 
+	if bytes.HasPrefix(data, []byte("SQLite format 3\x00")) {
+		return "db"
+	}
+	if format := detectZipImportFormat(data); format != "" {
+		return format
+	}
 	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "<") {
+		return "xml"
+	}
 	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
 		return "json"
 	}
@@ -191,6 +257,33 @@ func detectImportFormat(filename string, data []byte) string {
 	return "txt"
 }
 
+// detectZipImportFormat tells Anki packages from Microdote collection packages by the
+// files inside them. It returns "" for anything that is not one of the two.
+func detectZipImportFormat(data []byte) string {
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return ""
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return ""
+	}
+	hasManifest, hasDatabase := false, false
+	for _, file := range zr.File {
+		switch strings.ToLower(file.Name) {
+		case "collection.anki2", "collection.anki21":
+			return "apkg"
+		case "manifest.json":
+			hasManifest = true
+		case "collection.db":
+			hasDatabase = true
+		}
+	}
+	if hasManifest && hasDatabase {
+		return "mdpkg"
+	}
+	return ""
+}
+
 func parseNativeImport(data []byte, format string, opts importParseOptions) ([]importNormalizedNote, string, error) {
 	if format == "" || format == "txt" || format == "csv" || format == "tsv" {
 		format = detectImportFormat(opts.Filename, data)
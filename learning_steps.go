@@ -0,0 +1,212 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// learnAheadWindow lets learning cards due this soon be studied now, so a session does
+// not stall waiting for the next step to come due.
+const learnAheadWindow = 20 * time.Minute
+
+// defaultLearningSteps and defaultRelearningSteps are the steps, in minutes, of decks
+// without a preset.
+var (
+	defaultLearningSteps   = []int{1, 10}
+	defaultRelearningSteps = []int{10}
+)
+
+// learningSchedule is how a deck's new cards move through their learning steps before
+// graduating to FSRS review intervals, and how lapsed review cards are relearnt. With
+// no learning steps, FSRS schedules learning itself; with no relearning steps, a lapsed
+// card goes straight back to review at its lapse interval.
+type learningSchedule struct {
+	Steps          []time.Duration
+	GraduatingDays int
+	EasyDays       int
+
+	RelearnSteps       []time.Duration
+	MinimumDays        int // shortest interval after a lapse
+	NewIntervalPercent int // share of the old interval kept after a lapse
+
+	LeechThreshold int
+	LeechAction    string
+
+	Scheduler        string
+	StartingEase     int
+	EasyBonus        int
+	IntervalModifier int
+}
+
+func newLearningSchedule(options *DeckOptions) learningSchedule {
+	return learningSchedule{
+		Steps:              stepDurations(options.LearningSteps),
+		GraduatingDays:     max(options.GraduatingInterval, 1),
+		EasyDays:           max(options.EasyInterval, 1),
+		RelearnSteps:       stepDurations(options.RelearningSteps),
+		MinimumDays:        max(options.MinimumInterval, 1),
+		NewIntervalPercent: max(options.NewIntervalPercent, 0),
+		LeechThreshold:     max(options.LeechThreshold, 0),
+		LeechAction:        options.LeechAction,
+		Scheduler:          schedulerOrDefault(options.Scheduler),
+		StartingEase:       max(cmp.Or(options.StartingEase, defaultStartingEase), minimumEase),
+		EasyBonus:          cmp.Or(options.EasyBonus, defaultEasyBonus),
+		IntervalModifier:   cmp.Or(options.IntervalModifier, defaultIntervalModifier),
+	}
+}
+
+func stepDurations(stepMinutes []int) []time.Duration {
+	var steps []time.Duration
+	for _, minutes := range stepMinutes {
+		if minutes > 0 {
+			steps = append(steps, time.Duration(minutes)*time.Minute)
+		}
+	}
+	return steps
+}
+
+// learningScheduleForDeck reads the learning and lapse settings of a deck's options preset.
+func (s *SQLStore) learningScheduleForDeck(ctx context.Context, deckID int64) (learningSchedule, error) {
+	var optionsID sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `SELECT options_id FROM decks WHERE id = ?`, deckID).Scan(&optionsID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return learningSchedule{}, err
+	}
+	if optionsID.Valid {
+		options, err := s.GetDeckOptions(ctx, optionsID.Int64)
+		if err == nil {
+			return newLearningSchedule(options), nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return learningSchedule{}, err
+		}
+	}
+	return newLearningSchedule(&DeckOptions{
+		LearningSteps:      defaultLearningSteps,
+		GraduatingInterval: 1,
+		EasyInterval:       4,
+		RelearningSteps:    defaultRelearningSteps,
+		MinimumInterval:    1,
+		LeechThreshold:     defaultLeechThreshold,
+		LeechAction:        leechActionTag,
+	}), nil
+}
+
+// lapseDays is the interval a review card gets back once it has been relearnt: the kept
+// share of its old interval, but no less than the minimum.
+func (ls learningSchedule) lapseDays(card *Card) int {
+	return max(int(card.SRS.ScheduledDays)*ls.NewIntervalPercent/100, ls.MinimumDays)
+}
+
+// apply adjusts FSRS's scheduling of an answer to card for the learning and relearning
+// steps. Again restarts the steps, Hard repeats the current one, Good moves to the next
+// and Easy leaves them at once. Memory state is always FSRS's. It returns the card's
+// next step.
+func (ls learningSchedule) apply(card *Card, info fsrs.SchedulingInfo, rating fsrs.Rating, now time.Time) (fsrs.SchedulingInfo, int) {
+	var (
+		steps      []time.Duration
+		stepState  fsrs.State
+		carryDays  uint64 // interval a relearning card returns to review with
+		graduate   func(easy bool)
+		toReviewIn = func(days int) {
+			info.Card.State = fsrs.Review
+			info.Card.ScheduledDays = uint64(days)
+			info.Card.Due = now.Add(time.Duration(days) * 24 * time.Hour)
+		}
+	)
+	switch card.SRS.State {
+	case fsrs.New, fsrs.Learning:
+		steps, stepState = ls.Steps, fsrs.Learning
+		graduate = func(easy bool) {
+			// Once FSRS has a review interval for the card it stands; before that the
+			// preset's interval applies.
+			if info.Card.State == fsrs.Review {
+				return
+			}
+			if easy {
+				toReviewIn(ls.EasyDays)
+			} else {
+				toReviewIn(ls.GraduatingDays)
+			}
+		}
+	case fsrs.Review:
+		if rating != fsrs.Again {
+			return info, 0
+		}
+		if len(ls.RelearnSteps) == 0 {
+			toReviewIn(ls.lapseDays(card))
+			info.ReviewLog.ScheduledDays = info.Card.ScheduledDays
+			return info, 0
+		}
+		steps, stepState, carryDays = ls.RelearnSteps, fsrs.Relearning, uint64(ls.lapseDays(card))
+	case fsrs.Relearning:
+		steps, stepState = ls.RelearnSteps, fsrs.Relearning
+		carryDays = uint64(max(int(card.SRS.ScheduledDays), ls.MinimumDays))
+		graduate = func(bool) { toReviewIn(int(carryDays)) }
+	}
+	if len(steps) == 0 {
+		return info, 0
+	}
+
+	step := 0
+	if card.SRS.State == stepState {
+		step = min(card.LearningStep, len(steps)-1)
+	}
+	learn := func(delay time.Duration) {
+		info.Card.State = stepState
+		info.Card.ScheduledDays = carryDays
+		info.Card.Due = now.Add(delay)
+	}
+
+	switch {
+	case rating == fsrs.Again:
+		step = 0
+		learn(steps[0])
+	case rating == fsrs.Hard:
+		delay := steps[step]
+		if step == 0 && len(steps) > 1 {
+			delay = (steps[0] + steps[1]) / 2
+		}
+		learn(delay)
+	case rating == fsrs.Good && step+1 < len(steps):
+		step++
+		learn(steps[step])
+	default:
+		graduate(rating == fsrs.Easy)
+		step = 0
+	}
+	info.ReviewLog.ScheduledDays = 0
+	if info.Card.State == fsrs.Review {
+		info.ReviewLog.ScheduledDays = info.Card.ScheduledDays
+	}
+	return info, step
+}
+
+// answer finishes the scheduling of an answer to card that FSRS scheduled as info,
+// following the deck's learning steps and scheduler. It returns the next learning step
+// and the card's SM-2 ease.
+func (ls learningSchedule) answer(params fsrs.Parameters, card *Card, info fsrs.SchedulingInfo, rating fsrs.Rating, now time.Time) (fsrs.SchedulingInfo, int, int) {
+	info, step := ls.apply(card, info, rating, now)
+	ease := card.EaseFactor
+	if ls.Scheduler == schedulerSM2 {
+		info, ease = ls.applySM2(card, info, rating, now, params.MaximumInterval)
+	}
+	return info, step, ease
+}
+
+// scheduleAnswer works out a card's next state after rating it at now. It returns the
+// next learning step and the card's SM-2 ease; the card is not changed.
+func (h *APIHandler) scheduleAnswer(ctx context.Context, col *Collection, card *Card, rating fsrs.Rating, now time.Time) (fsrs.SchedulingInfo, int, int, error) {
+	info := fsrs.NewFSRS(col.Params).Repeat(card.SRS, now)[rating]
+	schedule, err := h.store.learningScheduleForDeck(ctx, card.homeDeckID())
+	if err != nil {
+		return fsrs.SchedulingInfo{}, 0, 0, err
+	}
+	info, step, ease := schedule.answer(col.Params, card, info, rating, now)
+	return info, step, ease, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestLearningScheduleSteps(t *testing.T) {
+	schedule := newLearningSchedule(&DeckOptions{LearningSteps: []int{1, 10}, GraduatingInterval: 1, EasyInterval: 4})
+	now := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	card := &Card{SRS: fsrs.NewCard()}
+	answer := func(rating fsrs.Rating) fsrs.Card {
+		info := fsrs.NewFSRS(fsrs.DefaultParam()).Repeat(card.SRS, now)[rating]
+		info, card.LearningStep = schedule.apply(card, info, rating, now)
+		card.SRS = info.Card
+		return info.Card
+	}
+
+	if next := answer(fsrs.Again); next.State != fsrs.Learning || next.Due != now.Add(time.Minute) || card.LearningStep != 0 {
+		t.Fatalf("expected Again to restart at the 1m step, got %+v step %d", next, card.LearningStep)
+	}
+	if next := answer(fsrs.Good); next.State != fsrs.Learning || next.Due != now.Add(10*time.Minute) || card.LearningStep != 1 {
+		t.Fatalf("expected Good to move to the 10m step, got %+v step %d", next, card.LearningStep)
+	}
+	if next := answer(fsrs.Good); next.State != fsrs.Review || next.ScheduledDays < 1 || card.LearningStep != 0 {
+		t.Fatalf("expected Good on the last step to graduate, got %+v step %d", next, card.LearningStep)
+	}
+
+	card = &Card{SRS: fsrs.NewCard()}
+	if next := answer(fsrs.Easy); next.State != fsrs.Review || next.ScheduledDays < 1 {
+		t.Fatalf("expected Easy to graduate a new card, got %+v", next)
+	}
+}
+
+func TestLearningScheduleLapses(t *testing.T) {
+	schedule := newLearningSchedule(&DeckOptions{RelearningSteps: []int{10}, MinimumInterval: 2, NewIntervalPercent: 50})
+	now := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	lapse := func(card *Card) fsrs.Card {
+		info := fsrs.NewFSRS(fsrs.DefaultParam()).Repeat(card.SRS, now)[fsrs.Again]
+		info, card.LearningStep = schedule.apply(card, info, fsrs.Again, now)
+		card.SRS = info.Card
+		return info.Card
+	}
+	reviewCard := func(days uint64) *Card {
+		return &Card{SRS: fsrs.Card{
+			State: fsrs.Review, Stability: 20, Difficulty: 5, Reps: 4, ScheduledDays: days,
+			LastReview: now.AddDate(0, 0, -int(days)), Due: now,
+		}}
+	}
+
+	card := reviewCard(10)
+	if next := lapse(card); next.State != fsrs.Relearning || next.Due != now.Add(10*time.Minute) || next.ScheduledDays != 5 || next.Lapses != 1 {
+		t.Fatalf("expected a lapse into the 10m relearning step keeping half the interval, got %+v", next)
+	}
+	info := fsrs.NewFSRS(fsrs.DefaultParam()).Repeat(card.SRS, now)[fsrs.Good]
+	if info, _ = schedule.apply(card, info, fsrs.Good, now); info.Card.State != fsrs.Review || info.Card.Due != now.AddDate(0, 0, 5) {
+		t.Fatalf("expected the relearnt card back in review in 5 days, got %+v", info.Card)
+	}
+
+	if next := lapse(reviewCard(3)); next.ScheduledDays != 2 {
+		t.Fatalf("expected the minimum interval after a lapse, got %d days", next.ScheduledDays)
+	}
+
+	schedule.RelearnSteps = nil
+	if next := lapse(reviewCard(10)); next.State != fsrs.Review || next.Due != now.AddDate(0, 0, 5) {
+		t.Fatalf("expected a lapse without relearning steps to go straight back to review, got %+v", next)
+	}
+}
+
+func TestRevlogRecordsStateTransition(t *testing.T) {
+	env := setupAPITestEnv(t)
+	createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Meiosis", "Back": "Gamete division"},
+	}, nil)
+	due := decodeJSON[[]*Card](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=10", ""))
+	if len(due) != 1 {
+		t.Fatalf("expected the new card due, got %d", len(due))
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", due[0].ID), AnswerCardRequest{Rating: 3}); rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	entries, err := env.store.ListRevlogEntriesForCard(context.Background(), "", due[0].ID, 10)
+	if err != nil {
+		t.Fatalf("list revlog failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].State != int(fsrs.New) || entries[0].NextState == nil || *entries[0].NextState != int(fsrs.Learning) {
+		t.Fatalf("expected a New to Learning transition logged, got %+v", entries)
+	}
+}
+
+func TestAPI_LearningCardsServedWithinLearnAhead(t *testing.T) {
+	env := setupAPITestEnv(t)
+	createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Mitosis", "Back": "Cell division"},
+	}, nil)
+
+	due := decodeJSON[[]*Card](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=10", ""))
+	if len(due) != 1 {
+		t.Fatalf("expected the new card due, got %d", len(due))
+	}
+	before := time.Now()
+	rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", due[0].ID), AnswerCardRequest{Rating: 3})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	card := decodeJSON[Card](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/cards/%d", due[0].ID), ""))
+	if card.SRS.State != fsrs.Learning || card.LearningStep != 1 || card.SRS.Due.Before(before.Add(9*time.Minute)) || card.SRS.Due.After(time.Now().Add(11*time.Minute)) {
+		t.Fatalf("expected the card on its 10m learning step, got %+v step %d", card.SRS, card.LearningStep)
+	}
+
+	// The 10m step falls inside the learn-ahead window, so the card can be studied again now.
+	due = decodeJSON[[]*Card](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=10", ""))
+	if len(due) != 1 || due[0].ID != card.ID {
+		t.Fatalf("expected the learning card served early, got %d cards", len(due))
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Leeches are cards that keep lapsing. Once a card's lapses reach its deck's leech
+// threshold, its note is tagged "leech" and, with the suspend action, the card is
+// suspended; as in Anki it is flagged again every half threshold after that.
+const (
+	leechTag              = "leech"
+	leechActionTag        = "tag"
+	leechActionSuspend    = "suspend"
+	defaultLeechThreshold = 8
+)
+
+// LeechCard is one card in the leech report.
+type LeechCard struct {
+	CardID    int64  `json:"cardId"`
+	NoteID    int64  `json:"noteId"`
+	DeckID    int64  `json:"deckId"`
+	Lapses    int    `json:"lapses"`
+	Threshold int    `json:"leechThreshold"`
+	Suspended bool   `json:"suspended"`
+	Tagged    bool   `json:"tagged"`
+	Preview   string `json:"preview"`
+}
+
+func leechActionOrDefault(action string) string {
+	if action == leechActionSuspend {
+		return action
+	}
+	return leechActionTag
+}
+
+// isLeech reports whether reaching lapses makes a card a leech under threshold. A
+// threshold of 0 turns leech detection off.
+func isLeech(lapses uint64, threshold int) bool {
+	if threshold <= 0 || lapses < uint64(threshold) {
+		return false
+	}
+	return (lapses-uint64(threshold))%uint64(max(threshold/2, 1)) == 0
+}
+
+// AddNoteTag adds tag to a note unless it already carries it, ignoring case. It returns
+// the note's tags afterwards.
+func (s *SQLStore) AddNoteTag(ctx context.Context, noteID int64, tag string, now time.Time) ([]string, error) {
+	var (
+		tagsJSON []byte
+		tags     []string
+	)
+	if err := s.db.QueryRowContext(ctx, `SELECT tags FROM notes WHERE id = ?`, noteID).Scan(&tagsJSON); err != nil {
+		return nil, err
+	}
+	if len(tagsJSON) > 0 {
+		if err := json.Unmarshal(tagsJSON, &tags); err != nil {
+			return nil, err
+		}
+	}
+	for _, existing := range tags {
+		if strings.EqualFold(existing, tag) {
+			return tags, nil
+		}
+	}
+	tags = append(tags, tag)
+	encoded, err := json.Marshal(tags)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.ExecContext(ctx, `UPDATE notes SET tags = ?, modified_at = ? WHERE id = ?`, encoded, now.Unix(), noteID); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// markLeech tags and, if the deck says so, suspends a card whose answer just took its
+// lapses from lapsesBefore onto a leech threshold. The suspension is left on card for
+// the caller to save with the rest of the answer.
+func (h *APIHandler) markLeech(ctx context.Context, col *Collection, card *Card, lapsesBefore uint64, now time.Time, tagNote bool) error {
+	if card.SRS.Lapses <= lapsesBefore {
+		return nil
+	}
+	schedule, err := h.store.learningScheduleForDeck(ctx, card.homeDeckID())
+	if err != nil {
+		return err
+	}
+	if !isLeech(card.SRS.Lapses, schedule.LeechThreshold) {
+		return nil
+	}
+	if tagNote {
+		tags, err := h.store.AddNoteTag(ctx, card.NoteID, leechTag, now)
+		if err != nil {
+			return err
+		}
+		if note, ok := col.Notes[card.NoteID]; ok {
+			note.Tags = tags
+			note.ModifiedAt = now
+			col.Notes[card.NoteID] = note
+		}
+	}
+	if schedule.LeechAction == leechActionSuspend {
+		card.Suspended = true
+	}
+	return nil
+}
+
+// ListLeechCards returns the user's cards in a collection that have lapsed at least
+// their deck's leech threshold, or whose note is tagged "leech", most lapses first. A
+// deckID of 0 covers every deck.
+func (s *SQLStore) ListLeechCards(ctx context.Context, userID, collectionID string, deckID int64) ([]LeechCard, error) {
+	query := `
+		SELECT id, note_id, deck_id, lapses, threshold, suspended, tagged FROM (
+			SELECT c.id, c.note_id, c.deck_id,
+				COALESCE(` + s.dialect.jsonNumber("COALESCE(rs.fsrs_data, c.fsrs_data)", "Lapses") + `, 0) AS lapses,
+				COALESCE(o.leech_threshold, ?) AS threshold,
+				COALESCE(rs.suspended, c.suspended, 0) AS suspended,
+				EXISTS (SELECT 1 FROM ` + s.dialect.jsonArrayValues("n.tags", "t") + ` WHERE lower(t.value) = ?) AS tagged
+			FROM cards c
+			JOIN notes n ON n.id = c.note_id
+			JOIN decks d ON d.id = c.deck_id
+			LEFT JOIN deck_options o ON o.id = d.options_id
+			LEFT JOIN card_review_states rs ON rs.card_id = c.id AND rs.user_id = ?
+			WHERE d.collection_id = ? AND (CAST(? AS BIGINT) = 0 OR c.deck_id = ?)
+		) AS leeches
+		WHERE tagged OR (threshold > 0 AND lapses >= threshold)
+		ORDER BY lapses DESC, id
+	`
+	rows, err := s.db.QueryContext(ctx, query, defaultLeechThreshold, leechTag, strings.TrimSpace(userID), collectionID, deckID, deckID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leeches := []LeechCard{}
+	for rows.Next() {
+		var leech LeechCard
+		if err := rows.Scan(&leech.CardID, &leech.NoteID, &leech.DeckID, &leech.Lapses, &leech.Threshold, &leech.Suspended, &leech.Tagged); err != nil {
+			return nil, err
+		}
+		leeches = append(leeches, leech)
+	}
+	return leeches, rows.Err()
+}
+
+// ListLeeches serves GET /api/leeches, optionally narrowed to ?deckId=.
+func (h *APIHandler) ListLeeches(w http.ResponseWriter, r *http.Request) {
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	var deckID int64
+	if rawDeckID := strings.TrimSpace(r.URL.Query().Get("deckId")); rawDeckID != "" {
+		deckID, err = strconv.ParseInt(rawDeckID, 10, 64)
+		if err != nil || deckID <= 0 {
+			respondAPIError(w, http.StatusBadRequest, "invalid_deck_id", "Invalid deck ID")
+			return
+		}
+	}
+
+	leeches, err := h.store.ListLeechCards(r.Context(), h.userIDFromRequest(r), collectionID, deckID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "leech_list_failed", err.Error())
+		return
+	}
+	for i := range leeches {
+		if note, ok := col.Notes[leeches[i].NoteID]; ok {
+			leeches[i].Preview = h.noteFieldPreview(note, col)
+		}
+	}
+	respondJSON(w, http.StatusOK, leeches)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestIsLeech(t *testing.T) {
+	for lapses, want := range map[uint64]bool{0: false, 7: false, 8: true, 9: false, 12: true, 16: true} {
+		if got := isLeech(lapses, 8); got != want {
+			t.Fatalf("isLeech(%d, 8) = %v, want %v", lapses, got, want)
+		}
+	}
+	if isLeech(20, 0) {
+		t.Fatalf("expected a threshold of 0 to turn leech detection off")
+	}
+}
+
+func TestAPI_LeechIsTaggedSuspendedAndReported(t *testing.T) {
+	env := setupAPITestEnv(t)
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Osmosis", "Back": "Diffusion of water"},
+	}, nil)
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/deck-options", map[string]interface{}{"name": "Strict", "leechThreshold": 1, "leechAction": "suspend"})
+	preset := decodeJSON[DeckOptionsResponse](t, rr)
+	if rr.Code != http.StatusCreated || preset.LeechThreshold != 1 || preset.LeechAction != leechActionSuspend {
+		t.Fatalf("expected preset with leech options, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/deck-options/%d/assign", preset.ID), AssignDeckOptionsRequest{DeckIDs: []int64{1}}); rr.Code != http.StatusOK {
+		t.Fatalf("expected assign 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/deck-options/%d", preset.ID), map[string]string{"leechAction": "delete"}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid leech action 400, got %d", rr.Code)
+	}
+
+	due := decodeJSON[[]*Card](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=10", ""))
+	if len(due) != 1 {
+		t.Fatalf("expected the new card due, got %d", len(due))
+	}
+	cardID := due[0].ID
+	if leeches := decodeJSON[[]LeechCard](t, doRawRequest(env.router, http.MethodGet, "/api/leeches", "")); len(leeches) != 0 {
+		t.Fatalf("expected no leeches yet, got %+v", leeches)
+	}
+
+	// Easy graduates the card; Again is its first lapse, which meets the threshold.
+	for _, rating := range []int{4, 1} {
+		if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", cardID), AnswerCardRequest{Rating: rating}); rr.Code != http.StatusOK {
+			t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+	}
+
+	card := decodeJSON[Card](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/cards/%d", cardID), ""))
+	if card.SRS.Lapses != 1 || !card.Suspended {
+		t.Fatalf("expected the leech suspended after one lapse, got lapses %d suspended %v", card.SRS.Lapses, card.Suspended)
+	}
+	stored, err := env.store.GetNote(context.Background(), created.Note.ID)
+	if err != nil {
+		t.Fatalf("load note failed: %v", err)
+	}
+	if !slices.Contains(stored.Tags, leechTag) {
+		t.Fatalf("expected the note tagged leech, got %v", stored.Tags)
+	}
+
+	leeches := decodeJSON[[]LeechCard](t, doRawRequest(env.router, http.MethodGet, "/api/leeches?deckId=1", ""))
+	if len(leeches) != 1 || leeches[0].CardID != cardID || leeches[0].Lapses != 1 || !leeches[0].Suspended || !leeches[0].Tagged || leeches[0].Preview != "Osmosis" {
+		t.Fatalf("expected the card in the leech report, got %+v", leeches)
+	}
+}
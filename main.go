@@ -1,29 +1,44 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"io/fs"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 //go:embed all:web/dist
 var embeddedWebDist embed.FS
 
 func main() {
-	cfg, err := LoadAppConfig()
+	cfg, err := LoadAppConfigFromArgs(os.Args[1:])
 	if err != nil {
 		log.Fatalf("failed to load config: %v", err)
 	}
 
+	serverTLS, err := newServerTLS(cfg.TLS, cfg.Port)
+	if err != nil {
+		log.Fatalf("failed to set up TLS: %v", err)
+	}
+	addr := net.JoinHostPort(cfg.Host, cfg.Port)
+
+	if cfg.Database.Encrypted && cfg.Database.Key == "" {
+		if cfg.Database.Key, err = waitForDatabaseKey(cfg, serverTLS, addr); err != nil {
+			log.Fatalf("failed to unlock the database: %v", err)
+		}
+	}
+
 	log.Printf("Initializing Vutadex server with %s database mode...", cfg.Database.Mode)
 	col, store, err := InitDefaultCollectionWithConfig(cfg.Database)
 	if err != nil {
 		log.Fatalf("failed to initialize collection: %v", err)
 	}
-	defer store.Close()
 
 	log.Printf("Collection loaded with %d decks, %d notes, %d cards", len(col.Decks), len(col.Notes), len(col.Cards))
 
@@ -31,26 +46,81 @@ func main() {
 	if cfg.Database.Mode == DatabaseModeSQLite {
 		backupDBPath = cfg.Database.Path
 	}
-	backupMgr := NewBackupManager(backupDBPath, "./backups", store)
+	backupMgr := NewBackupManager(backupDBPath, cfg.BackupDir, store)
 	handler := NewAPIHandlerWithConfig(store, col, backupMgr, cfg, NewEmailSender(cfg))
+	if err := handler.OpenActiveProfile(context.Background()); err != nil {
+		_ = store.Close()
+		log.Fatalf("failed to open the active profile: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	handler.StartMediaSweeper(ctx)
 
 	frontendFS, err := fs.Sub(embeddedWebDist, "web/dist")
 	if err != nil {
 		log.Fatalf("failed to load embedded app assets: %v; build the app with `bun --cwd web run build` first", err)
 	}
 
-	server := NewServer(cfg, handler, frontendFS)
-	addr := net.JoinHostPort(cfg.Host, cfg.Port)
+	server := &http.Server{Addr: addr, Handler: NewServer(cfg, handler, frontendFS)}
+	scheme := "http"
+	if serverTLS != nil {
+		server.TLSConfig = serverTLS.Config
+		scheme = "https"
+	}
 	appURL := cfg.AppOrigin
-	if os.Getenv("PORT") == "" {
-		appURL = "http://localhost:" + cfg.Port
+	if cfg.Host == "localhost" || net.ParseIP(cfg.Host).IsLoopback() {
+		appURL = scheme + "://" + net.JoinHostPort(cfg.Host, cfg.Port)
 	}
 
-	log.Printf("Server starting on %s", addr)
+	log.Printf("Server starting on %s (%s)", addr, scheme)
 	log.Printf("App available at %s", appURL)
 	log.Printf("API available at %s/api", appURL)
 
-	if err := http.ListenAndServe(addr, server); err != nil {
-		log.Fatalf("server failed: %v", err)
+	serveErr := make(chan error, 2)
+	go func() {
+		if serverTLS != nil {
+			serveErr <- server.ListenAndServeTLS("", "")
+			return
+		}
+		serveErr <- server.ListenAndServe()
+	}()
+	var redirectServer *http.Server
+	if serverTLS != nil && cfg.TLS.RedirectPort != "" {
+		redirectServer = &http.Server{Addr: net.JoinHostPort(cfg.Host, cfg.TLS.RedirectPort), Handler: serverTLS.Redirect}
+		log.Printf("Redirecting HTTP on %s to HTTPS", redirectServer.Addr)
+		go func() {
+			if err := redirectServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				serveErr <- err
+			}
+		}()
+	}
+
+	exitCode := 0
+	select {
+	case err := <-serveErr:
+		log.Printf("server failed: %v", err)
+		exitCode = 1
+	case <-ctx.Done():
+		log.Printf("Shutting down, waiting up to %s for requests to finish...", cfg.Shutdown.Timeout)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
+		if redirectServer != nil {
+			_ = redirectServer.Shutdown(shutdownCtx)
+		}
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("shutdown did not finish cleanly: %v", err)
+			exitCode = 1
+		}
+		cancel()
+		if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("server failed: %v", err)
+		}
+	}
+
+	stop()
+	if err := handler.Close(); err != nil {
+		log.Printf("failed to close cleanly: %v", err)
+		exitCode = 1
 	}
+	log.Printf("Server stopped")
+	os.Exit(exitCode)
 }
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// POST /api/maintenance/check is Anki's Check Database. It runs SQLite's integrity and
+// foreign key checks over the whole database, then looks for damage in the selected
+// collection and repairs what it safely can:
+//
+//   - cards whose note is gone are deleted, with their review log;
+//   - cards whose deck is gone move to the collection's first deck;
+//   - fields a note has but its note type does not are dropped;
+//   - scheduling state that is not valid FSRS JSON is reset to New, due now.
+//
+// Notes whose note type is gone and notes whose fields cannot be read are reported but
+// left alone. Nothing is repaired in a database that fails the integrity check; restore
+// a backup instead.
+const (
+	checkOrphanedCard       = "orphaned_card"
+	checkCardMissingDeck    = "card_missing_deck"
+	checkNoteExtraFields    = "note_extra_fields"
+	checkNoteMissingType    = "note_missing_note_type"
+	checkInvalidNoteFields  = "invalid_note_fields"
+	checkInvalidFSRSData    = "invalid_fsrs_data"
+	maxIntegrityCheckErrors = 100
+)
+
+type DatabaseCheckReport struct {
+	CheckedAt            time.Time              `json:"checkedAt"`
+	IntegrityOK          bool                   `json:"integrityOk"`
+	IntegrityErrors      []string               `json:"integrityErrors"`
+	ForeignKeyViolations []ForeignKeyViolation  `json:"foreignKeyViolations"`
+	Problems             []DatabaseCheckProblem `json:"problems"`
+	Fixed                int                    `json:"fixed"`
+}
+
+// ForeignKeyViolation is a row of PRAGMA foreign_key_check: a row of Table whose
+// reference into Parent points at nothing.
+type ForeignKeyViolation struct {
+	Table  string `json:"table"`
+	RowID  int64  `json:"rowId"`
+	Parent string `json:"parent"`
+}
+
+// DatabaseCheckProblem is one thing the check found. Fix says what was done about it,
+// and is empty when it was left alone.
+type DatabaseCheckProblem struct {
+	Kind   string `json:"kind"`
+	NoteID int64  `json:"noteId,omitempty"`
+	CardID int64  `json:"cardId,omitempty"`
+	UserID string `json:"userId,omitempty"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// CheckDatabase serves POST /api/maintenance/check.
+func (h *APIHandler) CheckDatabase(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	collectionID := h.collectionIDForRequest(r)
+	report, err := h.store.CheckDatabase(r.Context(), collectionID, time.Now())
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "database_check_failed", err.Error())
+		return
+	}
+	if report.Fixed > 0 {
+		// Reload the collection the repairs changed.
+		_, _, _ = h.collectionForRequest(r)
+	}
+	respondJSON(w, http.StatusOK, report)
+}
+
+// CheckDatabase checks the database and repairs the collection, in one transaction.
+func (s *SQLStore) CheckDatabase(ctx context.Context, collectionID string, now time.Time) (*DatabaseCheckReport, error) {
+	report := &DatabaseCheckReport{
+		CheckedAt:            now.UTC(),
+		IntegrityErrors:      []string{},
+		ForeignKeyViolations: []ForeignKeyViolation{},
+		Problems:             []DatabaseCheckProblem{},
+	}
+	// PostgreSQL checks its pages as it reads them and enforces foreign keys as rows are
+	// written, so only SQLite's file is checked for either.
+	report.IntegrityOK = true
+	if s.dialect == dialectSQLite {
+		integrity, err := queryStrings(ctx, s.db, fmt.Sprintf("PRAGMA integrity_check(%d)", maxIntegrityCheckErrors))
+		if err != nil {
+			return nil, fmt.Errorf("integrity check failed: %w", err)
+		}
+		report.IntegrityOK = len(integrity) == 1 && integrity[0] == "ok"
+		if !report.IntegrityOK {
+			report.IntegrityErrors = integrity
+			return report, nil
+		}
+	}
+
+	checks := []func(context.Context, *sql.Tx, string, time.Time, *DatabaseCheckReport) error{
+		checkOrphanedCards,
+		checkCardsMissingDeck,
+		checkNoteFields,
+		checkFSRSData,
+	}
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		report.Problems = []DatabaseCheckProblem{}
+		for _, check := range checks {
+			if err := check(ctx, tx, collectionID, now, report); err != nil {
+				return err
+			}
+		}
+		// Run last, so it reports only what the repairs above did not resolve.
+		if s.dialect == dialectSQLite {
+			var err error
+			if report.ForeignKeyViolations, err = foreignKeyViolations(ctx, tx); err != nil {
+				return fmt.Errorf("foreign key check failed: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, problem := range report.Problems {
+		if problem.Fix != "" {
+			report.Fixed++
+		}
+	}
+	return report, nil
+}
+
+func queryStrings(ctx context.Context, db *sql.DB, query string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	values := []string{}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+func foreignKeyViolations(ctx context.Context, tx *sql.Tx) ([]ForeignKeyViolation, error) {
+	rows, err := tx.QueryContext(ctx, `PRAGMA foreign_key_check`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	violations := []ForeignKeyViolation{}
+	for rows.Next() {
+		var (
+			violation ForeignKeyViolation
+			rowID     sql.NullInt64
+			fkID      int64
+		)
+		if err := rows.Scan(&violation.Table, &rowID, &violation.Parent, &fkID); err != nil {
+			return nil, err
+		}
+		violation.RowID = rowID.Int64
+		violations = append(violations, violation)
+	}
+	return violations, rows.Err()
+}
+
+// queryIDs returns the first column of query's rows.
+func queryIDs(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func checkOrphanedCards(ctx context.Context, tx *sql.Tx, collectionID string, _ time.Time, report *DatabaseCheckReport) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT c.id, c.note_id FROM cards c
+		JOIN decks d ON d.id = c.deck_id
+		WHERE d.collection_id = ? AND NOT EXISTS (SELECT 1 FROM notes n WHERE n.id = c.note_id)
+		ORDER BY c.id
+	`, collectionID)
+	if err != nil {
+		return err
+	}
+	var orphans []DatabaseCheckProblem
+	for rows.Next() {
+		var problem DatabaseCheckProblem
+		if err := rows.Scan(&problem.CardID, &problem.NoteID); err != nil {
+			rows.Close()
+			return err
+		}
+		orphans = append(orphans, problem)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, problem := range orphans {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM revlog WHERE card_id = ?`, problem.CardID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM cards WHERE id = ?`, problem.CardID); err != nil {
+			return err
+		}
+		problem.Kind = checkOrphanedCard
+		problem.Detail = fmt.Sprintf("card %d belongs to note %d, which does not exist", problem.CardID, problem.NoteID)
+		problem.Fix = "deleted the card"
+		report.Problems = append(report.Problems, problem)
+	}
+	return nil
+}
+
+func checkCardsMissingDeck(ctx context.Context, tx *sql.Tx, collectionID string, _ time.Time, report *DatabaseCheckReport) error {
+	cardIDs, err := queryIDs(ctx, tx, `
+		SELECT c.id FROM cards c
+		JOIN notes n ON n.id = c.note_id
+		WHERE n.collection_id = ? AND NOT EXISTS (SELECT 1 FROM decks d WHERE d.id = c.deck_id)
+		ORDER BY c.id
+	`, collectionID)
+	if err != nil || len(cardIDs) == 0 {
+		return err
+	}
+	var deckID int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM decks WHERE collection_id = ? ORDER BY id LIMIT 1`, collectionID).Scan(&deckID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	for _, cardID := range cardIDs {
+		problem := DatabaseCheckProblem{Kind: checkCardMissingDeck, CardID: cardID, Detail: fmt.Sprintf("card %d is in a deck that does not exist", cardID)}
+		if deckID != 0 {
+			if _, err := tx.ExecContext(ctx, `UPDATE cards SET deck_id = ? WHERE id = ?`, deckID, cardID); err != nil {
+				return err
+			}
+			problem.Fix = fmt.Sprintf("moved the card to deck %d", deckID)
+		}
+		report.Problems = append(report.Problems, problem)
+	}
+	return nil
+}
+
+func checkNoteFields(ctx context.Context, tx *sql.Tx, collectionID string, now time.Time, report *DatabaseCheckReport) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT n.id, n.field_vals, nt.fields FROM notes n
+		LEFT JOIN note_types nt ON nt.id = n.type_id
+		WHERE n.collection_id = ?
+		ORDER BY n.id
+	`, collectionID)
+	if err != nil {
+		return err
+	}
+	type fixedNote struct {
+		id     int64
+		fields []byte
+	}
+	var fixes []fixedNote
+	for rows.Next() {
+		var (
+			noteID     int64
+			fieldsJSON string
+			typeFields sql.NullString
+		)
+		if err := rows.Scan(&noteID, &fieldsJSON, &typeFields); err != nil {
+			rows.Close()
+			return err
+		}
+		if !typeFields.Valid {
+			report.Problems = append(report.Problems, DatabaseCheckProblem{Kind: checkNoteMissingType, NoteID: noteID, Detail: fmt.Sprintf("note %d has a note type that does not exist", noteID)})
+			continue
+		}
+		var (
+			fields map[string]string
+			known  []string
+		)
+		if json.Unmarshal([]byte(fieldsJSON), &fields) != nil || json.Unmarshal([]byte(typeFields.String), &known) != nil {
+			report.Problems = append(report.Problems, DatabaseCheckProblem{Kind: checkInvalidNoteFields, NoteID: noteID, Detail: fmt.Sprintf("note %d has fields that cannot be read", noteID)})
+			continue
+		}
+		isKnown := make(map[string]bool, len(known))
+		for _, name := range known {
+			isKnown[name] = true
+		}
+		var extra []string
+		for name := range fields {
+			if !isKnown[name] {
+				extra = append(extra, name)
+				delete(fields, name)
+			}
+		}
+		if len(extra) == 0 {
+			continue
+		}
+		sort.Strings(extra)
+		cleaned, err := json.Marshal(fields)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		fixes = append(fixes, fixedNote{id: noteID, fields: cleaned})
+		report.Problems = append(report.Problems, DatabaseCheckProblem{
+			Kind:   checkNoteExtraFields,
+			NoteID: noteID,
+			Detail: fmt.Sprintf("note %d has fields its note type does not: %s", noteID, strings.Join(extra, ", ")),
+			Fix:    "dropped the fields",
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, fix := range fixes {
+		if _, err := tx.ExecContext(ctx, `UPDATE notes SET field_vals = ?, modified_at = ? WHERE id = ?`, fix.fields, now.Unix(), fix.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFSRSData finds scheduling state that is not valid JSON, in the shared card rows
+// and in every user's review states of the collection's cards.
+func checkFSRSData(ctx context.Context, tx *sql.Tx, collectionID string, now time.Time, report *DatabaseCheckReport) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT c.id, '', c.fsrs_data FROM cards c
+		JOIN notes n ON n.id = c.note_id
+		WHERE n.collection_id = ? AND COALESCE(c.fsrs_data, '') <> ''
+		UNION ALL
+		SELECT s.card_id, s.user_id, s.fsrs_data FROM card_review_states s
+		JOIN cards c ON c.id = s.card_id
+		JOIN notes n ON n.id = c.note_id
+		WHERE n.collection_id = ?
+	`, collectionID, collectionID)
+	if err != nil {
+		return err
+	}
+	var invalid []DatabaseCheckProblem
+	for rows.Next() {
+		var (
+			problem  DatabaseCheckProblem
+			fsrsJSON string
+		)
+		if err := rows.Scan(&problem.CardID, &problem.UserID, &fsrsJSON); err != nil {
+			rows.Close()
+			return err
+		}
+		if json.Valid([]byte(fsrsJSON)) {
+			continue
+		}
+		invalid = append(invalid, problem)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, problem := range invalid {
+		if err := newCardStateTable(problem.UserID).save(ctx, tx, problem.CardID, newDueNow(time.Unix(now.Unix(), 0)), ", ease_factor = 0", now); err != nil {
+			return err
+		}
+		problem.Kind = checkInvalidFSRSData
+		problem.Detail = fmt.Sprintf("card %d has scheduling state that is not valid JSON", problem.CardID)
+		problem.Fix = "reset the card to New"
+		report.Problems = append(report.Problems, problem)
+	}
+	return nil
+}
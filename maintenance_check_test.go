@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestAPI_CheckDatabaseRepairsCollection(t *testing.T) {
+	env := setupAPITestEnv(t)
+	first := createNoteForTest(t, env, CreateNoteRequest{TypeID: "Basic", DeckID: 1, FieldVals: map[string]string{"Front": "one", "Back": "a"}}, nil)
+	second := createNoteForTest(t, env, CreateNoteRequest{TypeID: "Basic", DeckID: 1, FieldVals: map[string]string{"Front": "two", "Back": "b"}}, nil)
+
+	// Damage the database the way a crash or an old bug might, past its foreign keys.
+	conn, err := env.store.db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`PRAGMA foreign_keys = OFF`,
+		`INSERT INTO cards (id, note_id, deck_id, template_name) VALUES (990001, 424242, 1, 'Card 1')`,
+		`UPDATE cards SET deck_id = 777 WHERE note_id = ` + strconv.FormatInt(first.Note.ID, 10),
+		`UPDATE cards SET fsrs_data = '{broken' WHERE note_id = ` + strconv.FormatInt(second.Note.ID, 10),
+		`UPDATE notes SET field_vals = '{"Front":"two","Back":"b","Stray":"x"}' WHERE id = ` + strconv.FormatInt(second.Note.ID, 10),
+		`PRAGMA foreign_keys = ON`,
+	} {
+		if _, err := conn.ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	_ = conn.Close()
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/maintenance/check", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected check 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	report := decodeJSON[DatabaseCheckReport](t, rr)
+	if !report.IntegrityOK || len(report.ForeignKeyViolations) != 0 {
+		t.Fatalf("expected a sound database after repairs, got %+v", report)
+	}
+	found := map[string]DatabaseCheckProblem{}
+	for _, problem := range report.Problems {
+		found[problem.Kind] = problem
+	}
+	for _, kind := range []string{checkOrphanedCard, checkCardMissingDeck, checkNoteExtraFields, checkInvalidFSRSData} {
+		if problem, ok := found[kind]; !ok || problem.Fix == "" {
+			t.Fatalf("expected %s to be found and fixed, got %+v", kind, report.Problems)
+		}
+	}
+	if report.Fixed != len(report.Problems) {
+		t.Fatalf("expected every problem fixed, got %d of %d", report.Fixed, len(report.Problems))
+	}
+
+	note, err := env.store.GetNote(context.Background(), second.Note.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := note.FieldMap["Stray"]; ok || note.FieldMap["Front"] != "two" {
+		t.Fatalf("expected only the stray field dropped, got %v", note.FieldMap)
+	}
+
+	rr = doJSONRequest(t, env.router, http.MethodPost, "/api/maintenance/check", nil)
+	if again := decodeJSON[DatabaseCheckReport](t, rr); len(again.Problems) != 0 {
+		t.Fatalf("expected a second check to find nothing, got %+v", again.Problems)
+	}
+}
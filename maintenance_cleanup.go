@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// The orphaned record cleanup removes what deletions can leave behind: cards whose note
+// is gone, review log entries whose card is gone, and card IDs in the cached decks'
+// card lists that no longer name a card. POST /api/maintenance/cleanup runs it on
+// demand; with VUTADEX_CLEANUP_AFTER_DELETE set it also runs after every deletion of
+// notes, cards, decks, or note types.
+
+// OrphanCleanupReport counts what the cleanup removed.
+type OrphanCleanupReport struct {
+	DeletedCards        int     `json:"deletedCards"`
+	DeletedReviews      int     `json:"deletedReviews"`
+	RemovedDeckCardRefs int     `json:"removedDeckCardRefs"`
+	CardIDs             []int64 `json:"cardIds"` // the deleted cards
+}
+
+func (report OrphanCleanupReport) removedAny() bool {
+	return report.DeletedCards > 0 || report.DeletedReviews > 0 || report.RemovedDeckCardRefs > 0
+}
+
+// CleanupOrphans deletes the collection's cards whose note is gone, with their review
+// log, then every review log entry whose card is gone. The review log does not record
+// its collection, so that last step covers the whole database.
+func (s *SQLStore) CleanupOrphans(ctx context.Context, collectionID string) (OrphanCleanupReport, error) {
+	report := OrphanCleanupReport{CardIDs: []int64{}}
+	var (
+		cardIDs []int64
+		reviews int64
+	)
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		cardIDs, err = queryIDs(ctx, tx, `
+			SELECT c.id FROM cards c
+			JOIN decks d ON d.id = c.deck_id
+			WHERE d.collection_id = ? AND NOT EXISTS (SELECT 1 FROM notes n WHERE n.id = c.note_id)
+			ORDER BY c.id
+		`, collectionID)
+		if err != nil {
+			return err
+		}
+		for start := 0; start < len(cardIDs); start += maxNoteDeleteBatchSize {
+			placeholders, args := int64Placeholders(cardIDs[start:min(start+maxNoteDeleteBatchSize, len(cardIDs))])
+			// The review log refers to cards, so it goes first.
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM revlog WHERE card_id IN (%s)`, placeholders), args...); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM cards WHERE id IN (%s)`, placeholders), args...); err != nil {
+				return err
+			}
+		}
+		result, err := tx.ExecContext(ctx, `DELETE FROM revlog WHERE NOT EXISTS (SELECT 1 FROM cards c WHERE c.id = revlog.card_id)`)
+		if err != nil {
+			return err
+		}
+		reviews, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return report, err
+	}
+	report.DeletedCards = len(cardIDs)
+	report.DeletedReviews = int(reviews)
+	if cardIDs != nil {
+		report.CardIDs = cardIDs
+	}
+	return report, nil
+}
+
+// pruneDeckCardRefs drops card IDs from col's decks that col has no card for, and
+// returns how many it dropped.
+func pruneDeckCardRefs(col *Collection) int {
+	removed := 0
+	for _, deck := range col.Decks {
+		kept := deck.Cards[:0]
+		for _, cardID := range deck.Cards {
+			if _, ok := col.Cards[cardID]; ok {
+				kept = append(kept, cardID)
+			}
+		}
+		removed += len(deck.Cards) - len(kept)
+		deck.Cards = kept
+	}
+	return removed
+}
+
+// cleanupOrphans runs the cleanup on the collection in the database and, when col is
+// given, on its cached copy.
+func (h *APIHandler) cleanupOrphans(ctx context.Context, collectionID string, col *Collection) (OrphanCleanupReport, error) {
+	report, err := h.store.CleanupOrphans(ctx, collectionID)
+	if err != nil {
+		return report, err
+	}
+	if col != nil {
+		for _, cardID := range report.CardIDs {
+			delete(col.Cards, cardID)
+		}
+		report.RemovedDeckCardRefs = pruneDeckCardRefs(col)
+	}
+	return report, nil
+}
+
+// cleanupAfterDelete runs the cleanup after a deletion when the server is configured
+// to. The deletion has already succeeded, so a failure is only logged.
+func (h *APIHandler) cleanupAfterDelete(ctx context.Context, collectionID string, col *Collection) {
+	if !h.config.Maintenance.CleanupAfterDelete {
+		return
+	}
+	report, err := h.cleanupOrphans(ctx, collectionID, col)
+	if err != nil {
+		log.Printf("Warning: orphaned record cleanup failed: %v", err)
+		return
+	}
+	if report.removedAny() {
+		log.Printf("Cleaned up %d orphaned cards, %d review log entries, and %d deck card references",
+			report.DeletedCards, report.DeletedReviews, report.RemovedDeckCardRefs)
+	}
+}
+
+// CleanupOrphans serves POST /api/maintenance/cleanup.
+func (h *APIHandler) CleanupOrphans(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	collectionID := h.collectionIDForRequest(r)
+	// Only the active collection is cached between requests; any other is read afresh.
+	var col *Collection
+	if collectionID == h.collectionID {
+		col = h.collection
+	}
+	report, err := h.cleanupOrphans(r.Context(), collectionID, col)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "cleanup_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
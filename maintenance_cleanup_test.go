@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestAPI_CleanupOrphansRemovesDanglingRecords(t *testing.T) {
+	env := setupAPITestEnv(t)
+	kept := createNoteForTest(t, env, CreateNoteRequest{TypeID: "Basic", DeckID: 1, FieldVals: map[string]string{"Front": "kept", "Back": "a"}}, nil)
+
+	conn, err := env.store.db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`PRAGMA foreign_keys = OFF`,
+		`INSERT INTO cards (id, note_id, deck_id, template_name) VALUES (990001, 424242, 1, 'Card 1')`,
+		`INSERT INTO revlog (card_id, rating, reviewed_at) VALUES (990001, 3, 1)`,
+		`INSERT INTO revlog (card_id, rating, reviewed_at) VALUES (990002, 3, 1)`,
+		`PRAGMA foreign_keys = ON`,
+	} {
+		if _, err := conn.ExecContext(context.Background(), stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	_ = conn.Close()
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/maintenance/cleanup", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected cleanup 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	report := decodeJSON[OrphanCleanupReport](t, rr)
+	if report.DeletedCards != 1 || report.DeletedReviews != 1 || len(report.CardIDs) != 1 || report.CardIDs[0] != 990001 {
+		t.Fatalf("unexpected cleanup report %+v", report)
+	}
+	var remaining int
+	if err := env.store.db.QueryRow(`SELECT COUNT(*) FROM revlog`).Scan(&remaining); err != nil {
+		t.Fatal(err)
+	}
+	if remaining != 0 {
+		t.Fatalf("expected no review log entries left, got %d", remaining)
+	}
+	if _, err := env.store.GetCard(context.Background(), kept.Cards[0].ID); err != nil {
+		t.Fatalf("expected the kept note's card to survive: %v", err)
+	}
+}
+
+func TestAPI_CleanupAfterDeletePrunesDeckCardRefs(t *testing.T) {
+	cfg := mustLocalAppConfig()
+	cfg.Maintenance.CleanupAfterDelete = true
+	env := setupAPITestEnvWithConfig(t, cfg)
+	created := createNoteForTest(t, env, CreateNoteRequest{TypeID: "Basic", DeckID: 1, FieldVals: map[string]string{"Front": "gone", "Back": "a"}}, nil)
+
+	deck := env.handler.collection.Decks[1]
+	deck.Cards = append(deck.Cards, 880001)
+
+	rr := doJSONRequest(t, env.router, http.MethodDelete, "/api/notes/"+strconv.FormatInt(created.Note.ID, 10), nil)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected delete 204, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	for _, cardID := range env.handler.collection.Decks[1].Cards {
+		if cardID == 880001 {
+			t.Fatalf("expected the stale card reference pruned, got %v", env.handler.collection.Decks[1].Cards)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// POST /api/maintenance/optimize runs VACUUM, ANALYZE, and PRAGMA optimize, so a
+// database that has seen years of edits and deletions gives back its free pages and
+// keeps the query planner's statistics current. VACUUM rewrites the whole file, so the
+// request holds the profile lock for writing and every other request waits until it is
+// done. It is tracked as an operation whose progress counts the steps. PostgreSQL has
+// no PRAGMA optimize, so there it runs the first two.
+
+// DatabaseOptimizeReport is the database's size around the optimization.
+type DatabaseOptimizeReport struct {
+	SizeBefore int64     `json:"sizeBefore"` // bytes
+	SizeAfter  int64     `json:"sizeAfter"`
+	FreeBefore int64     `json:"freeBefore"` // bytes in free pages
+	FreeAfter  int64     `json:"freeAfter"`
+	Duration   float64   `json:"durationSeconds"`
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+func isDatabaseOptimize(r *http.Request) bool {
+	return r.Method == http.MethodPost && r.URL.Path == "/api/maintenance/optimize"
+}
+
+// databaseSize returns the database's size and the bytes in its free pages. PostgreSQL
+// reuses the space of deleted rows in place rather than keeping a free list, so it
+// reports no free pages.
+func (s *SQLStore) databaseSize(ctx context.Context) (size, free int64, err error) {
+	if s.dialect == dialectPostgres {
+		err := s.db.QueryRowContext(ctx, `SELECT pg_database_size(current_database())`).Scan(&size)
+		return size, 0, err
+	}
+	var pageSize, pages, freePages int64
+	if err := s.db.QueryRowContext(ctx, `PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return 0, 0, err
+	}
+	if err := s.db.QueryRowContext(ctx, `PRAGMA page_count`).Scan(&pages); err != nil {
+		return 0, 0, err
+	}
+	if err := s.db.QueryRowContext(ctx, `PRAGMA freelist_count`).Scan(&freePages); err != nil {
+		return 0, 0, err
+	}
+	return pages * pageSize, freePages * pageSize, nil
+}
+
+// OptimizeDatabase vacuums and analyzes the database, advancing op after each step.
+func (s *SQLStore) OptimizeDatabase(ctx context.Context, op *operation) (DatabaseOptimizeReport, error) {
+	var report DatabaseOptimizeReport
+	started := time.Now()
+	var err error
+	if report.SizeBefore, report.FreeBefore, err = s.databaseSize(ctx); err != nil {
+		return report, err
+	}
+	steps := []string{`VACUUM`, `ANALYZE`, `PRAGMA optimize`}
+	if s.dialect == dialectPostgres {
+		steps = steps[:2]
+	}
+	op.setTotal(int64(len(steps)))
+	for _, stmt := range steps {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return report, fmt.Errorf("%s: %w", stmt, err)
+		}
+		op.advance(1)
+	}
+	// In WAL mode VACUUM writes the new database through the WAL, which would otherwise
+	// keep the old size on disk until the next checkpoint.
+	if err := s.checkpoint(ctx); err != nil {
+		return report, err
+	}
+	if report.SizeAfter, report.FreeAfter, err = s.databaseSize(ctx); err != nil {
+		return report, err
+	}
+	report.FinishedAt = time.Now().UTC()
+	report.Duration = report.FinishedAt.Sub(started).Seconds()
+	return report, nil
+}
+
+// OptimizeDatabase serves POST /api/maintenance/optimize.
+func (h *APIHandler) OptimizeDatabase(w http.ResponseWriter, r *http.Request) {
+	if h.config.Database.Mode == DatabaseModeTurso {
+		respondAPIError(w, http.StatusNotImplemented, "optimize_unavailable", "Turso manages its own storage")
+		return
+	}
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	op := h.startOperation(w, r, operationOptimize, "steps")
+	report, err := h.store.OptimizeDatabase(r.Context(), op)
+	op.finish(err)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "optimize_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestAPI_OptimizeDatabaseVacuumsAndTracksOperation(t *testing.T) {
+	env := setupAPITestEnv(t)
+	created := createNoteForTest(t, env, CreateNoteRequest{TypeID: "Basic", DeckID: 1, FieldVals: map[string]string{"Front": "kept", "Back": "a"}}, nil)
+	if _, err := env.store.db.Exec(`CREATE TABLE bloat (data BLOB); INSERT INTO bloat SELECT zeroblob(4096) FROM notes, (SELECT 1 UNION ALL SELECT 2), (SELECT 1 UNION ALL SELECT 2); DROP TABLE bloat`); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/maintenance/optimize", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected optimize 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	report := decodeJSON[DatabaseOptimizeReport](t, rr)
+	if report.FreeBefore == 0 || report.FreeAfter != 0 || report.SizeAfter >= report.SizeBefore {
+		t.Fatalf("expected VACUUM to give back the free pages, got %+v", report)
+	}
+
+	id := rr.Header().Get(operationIDHeader)
+	rr = doJSONRequest(t, env.router, http.MethodGet, "/api/operations/"+id, nil)
+	progress := decodeJSON[OperationProgress](t, rr)
+	if progress.Kind != operationOptimize || progress.Status != operationSucceeded || progress.Processed != 3 {
+		t.Fatalf("unexpected operation %+v", progress)
+	}
+	if _, err := env.store.GetNote(context.Background(), created.Note.ID); err != nil {
+		t.Fatalf("expected the note to survive: %v", err)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Markdown imports turn notes written in Obsidian or any notes app into Basic notes.
+// A document written as Q:/A: pairs gives one note per pair. Otherwise every heading
+// with text beneath it gives one note: the heading is the front and the text up to the
+// next heading is the back, so headings that only group other headings are skipped.
+// YAML front matter may name the deck and tags for the whole file.
+
+var (
+	markdownHeadingRe   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	markdownQALineRe    = regexp.MustCompile(`(?i)^\s*(?:\*\*|__)?(q|a|question|answer)(?:\*\*|__)?\s*:(?:\*\*|__)?\s*(.*)$`)
+	markdownBulletRe    = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	markdownOrderedRe   = regexp.MustCompile(`^\d+[.)]\s+(.*)$`)
+	markdownEmbedRe     = regexp.MustCompile(`!\[\[([^\]|]+)(?:\|[^\]]*)?\]\]`)
+	markdownImageRe     = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)\)`)
+	markdownLinkRe      = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	markdownWikiAliasRe = regexp.MustCompile(`\[\[[^\]|]+\|([^\]]+)\]\]`)
+	markdownWikiLinkRe  = regexp.MustCompile(`\[\[([^\]]+)\]\]`)
+	markdownBoldRe      = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	markdownItalicRe    = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+	markdownStrikeRe    = regexp.MustCompile(`~~(.+?)~~`)
+)
+
+type markdownFrontMatter struct {
+	Deck string `yaml:"deck"`
+	Tags any    `yaml:"tags"`
+}
+
+// splitMarkdownFrontMatter separates a leading "---" YAML block from the document.
+func splitMarkdownFrontMatter(text string) (markdownFrontMatter, string, error) {
+	var meta markdownFrontMatter
+	rest, ok := strings.CutPrefix(text, "---\n")
+	if !ok {
+		return meta, text, nil
+	}
+	end := strings.Index(rest, "\n---")
+	if end < 0 {
+		return meta, text, nil
+	}
+	if err := yaml.Unmarshal([]byte(rest[:end]), &meta); err != nil {
+		return meta, text, fmt.Errorf("invalid Markdown front matter: %w", err)
+	}
+	body := rest[end+len("\n---"):]
+	if idx := strings.Index(body, "\n"); idx >= 0 {
+		body = body[idx+1:]
+	} else {
+		body = ""
+	}
+	return meta, body, nil
+}
+
+func (meta markdownFrontMatter) tags() []string {
+	switch tags := meta.Tags.(type) {
+	case string:
+		return splitTags(tags)
+	case []any:
+		var out []string
+		for _, tag := range tags {
+			out = append(out, strings.Fields(fmt.Sprint(tag))...)
+		}
+		return dedupeTags(out)
+	}
+	return nil
+}
+
+func parseMarkdownImport(data []byte, opts importParseOptions) ([]importNormalizedNote, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	meta, body, err := splitMarkdownFrontMatter(text)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(body, "\n")
+
+	var pairs [][2]string
+	if markdownHasQAPairs(lines) {
+		pairs = markdownQAPairs(lines)
+	} else {
+		pairs = markdownHeadingPairs(lines)
+	}
+
+	deckName := firstNonEmpty(strings.TrimSpace(meta.Deck), opts.DefaultDeckName)
+	noteType := NoteTypeName(firstNonEmpty(opts.DefaultNoteType, "Basic"))
+	tags := meta.tags()
+	var out []importNormalizedNote
+	for _, pair := range pairs {
+		front, back := markdownInline(strings.TrimSpace(pair[0])), markdownToHTML(pair[1])
+		if front == "" || back == "" {
+			continue
+		}
+		out = append(out, importNormalizedNote{
+			DeckName: deckName,
+			NoteType: noteType,
+			Fields:   map[string]string{"Front": front, "Back": back},
+			Tags:     tags,
+		})
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no notes found in Markdown: write a heading above each answer, or Q: and A: lines")
+	}
+	return out, nil
+}
+
+func markdownHasQAPairs(lines []string) bool {
+	inFence := false
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+		if m := markdownQALineRe.FindStringSubmatch(line); !inFence && m != nil && strings.EqualFold(m[1][:1], "q") {
+			return true
+		}
+	}
+	return false
+}
+
+// markdownQAPairs collects Q:/A: pairs. Lines after a Q: or A: line continue it until
+// the next Q: line or heading.
+func markdownQAPairs(lines []string) [][2]string {
+	var pairs [][2]string
+	var question, answer []string
+	part := -1 // 0 while reading a question, 1 while reading its answer
+	flush := func() {
+		if part == 1 {
+			pairs = append(pairs, [2]string{strings.Join(question, "\n"), strings.Join(answer, "\n")})
+		}
+		question, answer, part = nil, nil, -1
+	}
+	inFence := false
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if !inFence {
+			if markdownHeadingRe.MatchString(line) {
+				flush()
+				continue
+			}
+			if m := markdownQALineRe.FindStringSubmatch(line); m != nil {
+				if strings.EqualFold(m[1][:1], "q") {
+					flush()
+					question, part = []string{m[2]}, 0
+				} else if part >= 0 {
+					answer, part = append(answer, m[2]), 1
+				}
+				continue
+			}
+		}
+		switch part {
+		case 0:
+			question = append(question, line)
+		case 1:
+			answer = append(answer, line)
+		}
+	}
+	flush()
+	return pairs
+}
+
+// markdownHeadingPairs pairs each heading with the text beneath it.
+func markdownHeadingPairs(lines []string) [][2]string {
+	var pairs [][2]string
+	heading, hasHeading := "", false
+	var body []string
+	flush := func() {
+		if hasHeading && strings.TrimSpace(strings.Join(body, "\n")) != "" {
+			pairs = append(pairs, [2]string{heading, strings.Join(body, "\n")})
+		}
+		body = nil
+	}
+	inFence := false
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if m := markdownHeadingRe.FindStringSubmatch(line); !inFence && m != nil {
+			flush()
+			heading, hasHeading = m[2], true
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+	return pairs
+}
+
+// markdownToHTML renders the block-level Markdown a note body uses: paragraphs, bullet
+// and numbered lists, and fenced code. A single paragraph is returned without a
+// wrapper so short answers stay plain.
+func markdownToHTML(text string) string {
+	var blocks []string
+	var paragraph, code []string
+	listTag, inFence := "", false
+	var list strings.Builder
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			blocks = append(blocks, "<p>"+strings.Join(paragraph, "<br>")+"</p>")
+			paragraph = nil
+		}
+	}
+	flushList := func() {
+		if listTag != "" {
+			blocks = append(blocks, "<"+listTag+">"+list.String()+"</"+listTag+">")
+			list.Reset()
+			listTag = ""
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if inFence {
+			if strings.HasPrefix(trimmed, "```") {
+				blocks = append(blocks, "<pre><code>"+html.EscapeString(strings.Join(code, "\n"))+"</code></pre>")
+				code, inFence = nil, false
+			} else {
+				code = append(code, line)
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") {
+			flushParagraph()
+			flushList()
+			inFence = true
+			continue
+		}
+		if trimmed == "" {
+			flushParagraph()
+			flushList()
+			continue
+		}
+
+		tag, item := "", ""
+		if m := markdownBulletRe.FindStringSubmatch(trimmed); m != nil {
+			tag, item = "ul", m[1]
+		} else if m := markdownOrderedRe.FindStringSubmatch(trimmed); m != nil {
+			tag, item = "ol", m[1]
+		}
+		if tag != "" {
+			flushParagraph()
+			if listTag != tag {
+				flushList()
+				listTag = tag
+			}
+			list.WriteString("<li>" + markdownInline(item) + "</li>")
+			continue
+		}
+		flushList()
+		paragraph = append(paragraph, markdownInline(trimmed))
+	}
+	if inFence {
+		blocks = append(blocks, "<pre><code>"+html.EscapeString(strings.Join(code, "\n"))+"</code></pre>")
+	}
+	flushParagraph()
+	flushList()
+
+	if len(blocks) == 1 && strings.HasPrefix(blocks[0], "<p>") {
+		return strings.TrimSuffix(strings.TrimPrefix(blocks[0], "<p>"), "</p>")
+	}
+	return strings.Join(blocks, "")
+}
+
+// markdownInline renders inline Markdown: code spans, images and Obsidian embeds,
+// links and wiki links, bold, italic and strikethrough.
+func markdownInline(text string) string {
+	segments := strings.Split(html.EscapeString(text), "`")
+	for i, segment := range segments {
+		// Odd segments sit between backticks; an unmatched final backtick is literal.
+		if i%2 == 1 && i < len(segments)-1 {
+			segments[i] = "<code>" + segment + "</code>"
+			continue
+		}
+		if i%2 == 1 {
+			segment = "`" + segment
+		}
+		segment = markdownEmbedRe.ReplaceAllString(segment, `<img src="$1">`)
+		segment = markdownImageRe.ReplaceAllString(segment, `<img src="$2" alt="$1">`)
+		segment = markdownLinkRe.ReplaceAllString(segment, `<a href="$2">$1</a>`)
+		segment = markdownWikiAliasRe.ReplaceAllString(segment, "$1")
+		segment = markdownWikiLinkRe.ReplaceAllString(segment, "$1")
+		segment = markdownBoldRe.ReplaceAllString(segment, "<b>$1$2</b>")
+		segment = markdownItalicRe.ReplaceAllString(segment, "<i>$1$2</i>")
+		segment = markdownStrikeRe.ReplaceAllString(segment, "<s>$1</s>")
+		segments[i] = segment
+	}
+	return strings.Join(segments, "")
+}
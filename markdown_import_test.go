@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestParseMarkdownImport_Headings(t *testing.T) {
+	doc := "---\ndeck: DSA::Graphs\ntags: [graphs, bfs]\n---\n# Graphs\n\n## What does BFS use?\n\nA **queue**.\n\n## Steps of Dijkstra\n\n1. Pick the closest node\n2. Relax its edges\n\n```\ndist[v] = dist[u] + w\n```\n\n## Empty heading\n"
+	notes, err := parseMarkdownImport([]byte(doc), importParseOptions{DefaultDeckName: "Default", DefaultNoteType: "Basic"})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(notes) != 2 {
+		t.Fatalf("expected 2 notes, got %+v", notes)
+	}
+	if notes[0].DeckName != "DSA::Graphs" || notes[0].NoteType != "Basic" {
+		t.Fatalf("unexpected deck or type %+v", notes[0])
+	}
+	if len(notes[0].Tags) != 2 || notes[0].Tags[0] != "graphs" || notes[0].Tags[1] != "bfs" {
+		t.Fatalf("unexpected tags %v", notes[0].Tags)
+	}
+	if notes[0].Fields["Front"] != "What does BFS use?" || notes[0].Fields["Back"] != "A <b>queue</b>." {
+		t.Fatalf("unexpected first note %+v", notes[0].Fields)
+	}
+	want := "<ol><li>Pick the closest node</li><li>Relax its edges</li></ol><pre><code>dist[v] = dist[u] + w</code></pre>"
+	if notes[1].Fields["Back"] != want {
+		t.Fatalf("unexpected second back %q", notes[1].Fields["Back"])
+	}
+}
+
+func TestParseMarkdownImport_QAPairs(t *testing.T) {
+	doc := "# Chapter 1\n\nQ: What is `O(1)`?\nA: Constant time\nregardless of input.\n\n**Q:** Embed?\n**A:** ![[graph.png]] see [docs](https://example.com)\n\nQ: No answer\n"
+	notes, err := parseMarkdownImport([]byte(doc), importParseOptions{DefaultDeckName: "Default", DefaultNoteType: "Basic"})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(notes) != 2 {
+		t.Fatalf("expected 2 notes, got %+v", notes)
+	}
+	if notes[0].DeckName != "Default" || notes[0].Fields["Front"] != "What is <code>O(1)</code>?" || notes[0].Fields["Back"] != "Constant time<br>regardless of input." {
+		t.Fatalf("unexpected first note %+v", notes[0])
+	}
+	if notes[1].Fields["Back"] != `<img src="graph.png"> see <a href="https://example.com">docs</a>` {
+		t.Fatalf("unexpected second back %q", notes[1].Fields["Back"])
+	}
+
+	if _, err := parseMarkdownImport([]byte("just some text\n"), importParseOptions{}); err == nil {
+		t.Fatalf("expected an error for Markdown without notes")
+	}
+}
+
+func TestAPI_ImportNotes_Markdown(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	resp := doMultipartImportRequest(t, env.router, nil, "notes.md", []byte("## Stack order\n\nLIFO\n\n## Queue order\n\nFIFO\n"))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected import 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	result := decodeJSON[ImportNotesResponse](t, resp)
+	if result.Imported != 2 || result.Source != "markdown" || result.Format != "md" {
+		t.Fatalf("unexpected import result %+v", result)
+	}
+
+	notes, err := env.store.ListNotes(context.Background(), "default")
+	if err != nil {
+		t.Fatalf("failed to list notes: %v", err)
+	}
+	if len(notes) != 2 {
+		t.Fatalf("expected two imported notes, got %d", len(notes))
+	}
+	for _, note := range notes {
+		if note.Type != "Basic" || note.FieldMap["Back"] == "" {
+			t.Fatalf("unexpected imported note %+v", note)
+		}
+	}
+}
@@ -18,14 +18,14 @@ func (h *APIHandler) getMarketplaceCreatorAccountStatus(ctx context.Context, use
 	response := MarketplaceCreatorAccountStatusResponse{
 		Provider: provider.ProviderName(),
 	}
-	account, err := h.store.GetMarketplaceCreatorAccountByUser(userID)
+	account, err := h.store.GetMarketplaceCreatorAccountByUser(ctx, userID)
 	if err == nil {
 		if refreshed, refreshErr := provider.RefreshCreatorAccount(ctx, account); refreshErr == nil && refreshed != nil {
 			refreshed.UserID = account.UserID
 			refreshed.WorkspaceID = account.WorkspaceID
 			refreshed.ID = account.ID
 			refreshed.CreatedAt = account.CreatedAt
-			if err := h.store.UpsertMarketplaceCreatorAccount(refreshed); err == nil {
+			if err := h.store.UpsertMarketplaceCreatorAccount(ctx, refreshed); err == nil {
 				account = refreshed
 			}
 		}
@@ -43,24 +43,24 @@ func (h *APIHandler) GetMarketplaceCreatorAccountStatus(w http.ResponseWriter, r
 func (h *APIHandler) StartMarketplaceCreatorAccount(w http.ResponseWriter, r *http.Request) {
 	session := h.sessionFromRequest(r)
 	plan := h.planForRequest(r, session)
-	if !entitlementsForPlan(plan, h.usageForSession(session)).Features.MarketplacePublish {
+	if !entitlementsForPlan(plan, h.usageForSession(r.Context(), session)).Features.MarketplacePublish {
 		respondAPIError(w, http.StatusForbidden, "marketplace_publish_not_available", "Marketplace creator accounts are reserved for Pro, Team, and Enterprise workspaces.")
 		return
 	}
 
-	user, err := h.store.GetUserByID(session.UserID)
+	user, err := h.store.GetUserByID(r.Context(), session.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "user_not_found", err.Error())
 		return
 	}
-	workspace, err := h.store.GetWorkspaceForUser(user.ID, session.WorkspaceID)
+	workspace, err := h.store.GetWorkspaceForUser(r.Context(), user.ID, session.WorkspaceID)
 	if err != nil {
 		respondAPIError(w, http.StatusBadRequest, "invalid_workspace", "Current workspace not found.")
 		return
 	}
 
 	var existing *MarketplaceCreatorAccount
-	if account, err := h.store.GetMarketplaceCreatorAccountByUser(user.ID); err == nil {
+	if account, err := h.store.GetMarketplaceCreatorAccountByUser(r.Context(), user.ID); err == nil {
 		existing = account
 	} else if !errors.Is(err, sql.ErrNoRows) {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_creator_account_failed", err.Error())
@@ -73,7 +73,7 @@ func (h *APIHandler) StartMarketplaceCreatorAccount(w http.ResponseWriter, r *ht
 		respondAPIError(w, http.StatusConflict, "marketplace_creator_account_failed", err.Error())
 		return
 	}
-	if err := h.store.UpsertMarketplaceCreatorAccount(account); err != nil {
+	if err := h.store.UpsertMarketplaceCreatorAccount(r.Context(), account); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_creator_account_failed", err.Error())
 		return
 	}
@@ -84,8 +84,8 @@ func (h *APIHandler) StartMarketplaceCreatorAccount(w http.ResponseWriter, r *ht
 	})
 }
 
-func (h *APIHandler) activeMarketplaceLicense(listingID, userID string) (*MarketplaceLicense, error) {
-	license, err := h.store.GetMarketplaceLicense(listingID, userID)
+func (h *APIHandler) activeMarketplaceLicense(ctx context.Context, listingID, userID string) (*MarketplaceLicense, error) {
+	license, err := h.store.GetMarketplaceLicense(ctx, listingID, userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -98,11 +98,11 @@ func (h *APIHandler) activeMarketplaceLicense(listingID, userID string) (*Market
 	return license, nil
 }
 
-func (h *APIHandler) canAccessPremiumMarketplaceListing(listing *MarketplaceListing, userID string) (*MarketplaceLicense, bool, error) {
+func (h *APIHandler) canAccessPremiumMarketplaceListing(ctx context.Context, listing *MarketplaceListing, userID string) (*MarketplaceLicense, bool, error) {
 	if listing.PriceMode == "free" || listing.CreatorUserID == userID {
 		return nil, true, nil
 	}
-	license, err := h.activeMarketplaceLicense(listing.ID, userID)
+	license, err := h.activeMarketplaceLicense(ctx, listing.ID, userID)
 	if err != nil {
 		return nil, false, err
 	}
@@ -110,7 +110,7 @@ func (h *APIHandler) canAccessPremiumMarketplaceListing(listing *MarketplaceList
 }
 
 func (h *APIHandler) ensureMarketplaceCreatorReady(ctx context.Context, listing *MarketplaceListing) (*MarketplaceCreatorAccount, error) {
-	account, err := h.store.GetMarketplaceCreatorAccountByUser(listing.CreatorUserID)
+	account, err := h.store.GetMarketplaceCreatorAccountByUser(ctx, listing.CreatorUserID)
 	if err != nil {
 		return nil, err
 	}
@@ -120,7 +120,7 @@ func (h *APIHandler) ensureMarketplaceCreatorReady(ctx context.Context, listing
 		refreshed.WorkspaceID = account.WorkspaceID
 		refreshed.ID = account.ID
 		refreshed.CreatedAt = account.CreatedAt
-		if err := h.store.UpsertMarketplaceCreatorAccount(refreshed); err == nil {
+		if err := h.store.UpsertMarketplaceCreatorAccount(ctx, refreshed); err == nil {
 			account = refreshed
 		}
 	}
@@ -130,9 +130,9 @@ func (h *APIHandler) ensureMarketplaceCreatorReady(ctx context.Context, listing
 	return account, nil
 }
 
-func (h *APIHandler) completeMarketplaceOrder(order *MarketplaceOrder) (*MarketplaceLicense, error) {
+func (h *APIHandler) completeMarketplaceOrder(ctx context.Context, order *MarketplaceOrder) (*MarketplaceLicense, error) {
 	if order.Status == "paid" {
-		license, err := h.store.GetMarketplaceLicense(order.ListingID, order.BuyerUserID)
+		license, err := h.store.GetMarketplaceLicense(ctx, order.ListingID, order.BuyerUserID)
 		if err == nil {
 			return license, nil
 		}
@@ -145,7 +145,7 @@ func (h *APIHandler) completeMarketplaceOrder(order *MarketplaceOrder) (*Marketp
 	order.Status = "paid"
 	order.CompletedAt = now
 	order.UpdatedAt = now
-	if err := h.store.UpdateMarketplaceOrder(order); err != nil {
+	if err := h.store.UpdateMarketplaceOrder(ctx, order); err != nil {
 		return nil, err
 	}
 
@@ -159,11 +159,11 @@ func (h *APIHandler) completeMarketplaceOrder(order *MarketplaceOrder) (*Marketp
 		CreatedAt:            now,
 		UpdatedAt:            now,
 	}
-	if existing, err := h.store.GetMarketplaceLicense(order.ListingID, order.BuyerUserID); err == nil {
+	if existing, err := h.store.GetMarketplaceLicense(ctx, order.ListingID, order.BuyerUserID); err == nil {
 		license.ID = existing.ID
 		license.CreatedAt = existing.CreatedAt
 	}
-	if err := h.store.UpsertMarketplaceLicense(license); err != nil {
+	if err := h.store.UpsertMarketplaceLicense(ctx, license); err != nil {
 		return nil, err
 	}
 
@@ -180,19 +180,19 @@ func (h *APIHandler) completeMarketplaceOrder(order *MarketplaceOrder) (*Marketp
 		CreatedAt:        now,
 		UpdatedAt:        now,
 	}
-	if existing, err := h.store.GetMarketplacePayoutByOrder(order.ID); err == nil {
+	if existing, err := h.store.GetMarketplacePayoutByOrder(ctx, order.ID); err == nil {
 		payout.ID = existing.ID
 		payout.ProviderTransferID = existing.ProviderTransferID
 		payout.CreatedAt = existing.CreatedAt
 	}
-	if err := h.store.UpsertMarketplacePayout(payout); err != nil {
+	if err := h.store.UpsertMarketplacePayout(ctx, payout); err != nil {
 		return nil, err
 	}
 
 	return license, nil
 }
 
-func (h *APIHandler) failMarketplaceOrder(order *MarketplaceOrder) error {
+func (h *APIHandler) failMarketplaceOrder(ctx context.Context, order *MarketplaceOrder) error {
 	if order == nil {
 		return nil
 	}
@@ -201,7 +201,7 @@ func (h *APIHandler) failMarketplaceOrder(order *MarketplaceOrder) error {
 	}
 	order.Status = "failed"
 	order.UpdatedAt = time.Now()
-	return h.store.UpdateMarketplaceOrder(order)
+	return h.store.UpdateMarketplaceOrder(ctx, order)
 }
 
 func (h *APIHandler) SyncMarketplaceCheckoutSession(w http.ResponseWriter, r *http.Request) {
@@ -212,7 +212,7 @@ func (h *APIHandler) SyncMarketplaceCheckoutSession(w http.ResponseWriter, r *ht
 	}
 
 	session := h.sessionFromRequest(r)
-	order, err := h.store.GetMarketplaceOrderByCheckoutSession("stripe", sessionID)
+	order, err := h.store.GetMarketplaceOrderByCheckoutSession(r.Context(), "stripe", sessionID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			respondAPIError(w, http.StatusNotFound, "marketplace_order_not_found", "Marketplace order not found.")
@@ -242,7 +242,7 @@ func (h *APIHandler) syncMarketplaceCheckoutSession(ctx context.Context, order *
 	}
 
 	if order.Status == "paid" {
-		license, err := h.store.GetMarketplaceLicense(order.ListingID, order.BuyerUserID)
+		license, err := h.store.GetMarketplaceLicense(ctx, order.ListingID, order.BuyerUserID)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				return nil, response, nil
@@ -253,7 +253,7 @@ func (h *APIHandler) syncMarketplaceCheckoutSession(ctx context.Context, order *
 		return license, response, nil
 	}
 
-	creatorAccount, err := h.store.GetMarketplaceCreatorAccount(order.CreatorAccountID)
+	creatorAccount, err := h.store.GetMarketplaceCreatorAccount(ctx, order.CreatorAccountID)
 	if err != nil {
 		return nil, response, err
 	}
@@ -270,7 +270,7 @@ func (h *APIHandler) syncMarketplaceCheckoutSession(ctx context.Context, order *
 	response.Order = *order
 
 	if state.Completed {
-		license, err := h.completeMarketplaceOrder(order)
+		license, err := h.completeMarketplaceOrder(ctx, order)
 		if err != nil {
 			return nil, response, err
 		}
@@ -279,7 +279,7 @@ func (h *APIHandler) syncMarketplaceCheckoutSession(ctx context.Context, order *
 		return license, response, nil
 	}
 	if strings.EqualFold(state.PaymentStatus, "unpaid") && strings.EqualFold(state.Status, "expired") {
-		if err := h.failMarketplaceOrder(order); err != nil {
+		if err := h.failMarketplaceOrder(ctx, order); err != nil {
 			return nil, response, err
 		}
 		response.Order = *order
@@ -317,7 +317,7 @@ func (h *APIHandler) MarketplaceWebhook(w http.ResponseWriter, r *http.Request)
 			respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid checkout session payload.")
 			return
 		}
-		order, err := h.store.GetMarketplaceOrderByCheckoutSession("stripe", session.ID)
+		order, err := h.store.GetMarketplaceOrderByCheckoutSession(r.Context(), "stripe", session.ID)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				respondJSON(w, http.StatusOK, map[string]bool{"ok": true})
@@ -329,7 +329,7 @@ func (h *APIHandler) MarketplaceWebhook(w http.ResponseWriter, r *http.Request)
 		if session.PaymentIntent != "" {
 			order.ProviderPaymentIntentID = session.PaymentIntent
 		}
-		if _, err := h.completeMarketplaceOrder(order); err != nil {
+		if _, err := h.completeMarketplaceOrder(r.Context(), order); err != nil {
 			respondAPIError(w, http.StatusInternalServerError, "marketplace_checkout_complete_failed", err.Error())
 			return
 		}
@@ -339,12 +339,12 @@ func (h *APIHandler) MarketplaceWebhook(w http.ResponseWriter, r *http.Request)
 			respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid checkout session payload.")
 			return
 		}
-		order, err := h.store.GetMarketplaceOrderByCheckoutSession("stripe", session.ID)
+		order, err := h.store.GetMarketplaceOrderByCheckoutSession(r.Context(), "stripe", session.ID)
 		if err == nil {
 			if session.PaymentIntent != "" {
 				order.ProviderPaymentIntentID = session.PaymentIntent
 			}
-			if err := h.failMarketplaceOrder(order); err != nil {
+			if err := h.failMarketplaceOrder(r.Context(), order); err != nil {
 				respondAPIError(w, http.StatusInternalServerError, "marketplace_order_update_failed", err.Error())
 				return
 			}
@@ -358,7 +358,7 @@ func (h *APIHandler) MarketplaceWebhook(w http.ResponseWriter, r *http.Request)
 			respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid account payload.")
 			return
 		}
-		creatorAccount, err := h.store.GetMarketplaceCreatorAccountByProviderAccount("stripe", firstNonEmpty(accountObject.ID, event.Account))
+		creatorAccount, err := h.store.GetMarketplaceCreatorAccountByProviderAccount(r.Context(), "stripe", firstNonEmpty(accountObject.ID, event.Account))
 		if err == nil {
 			updated := *creatorAccount
 			updated.UpdatedAt = time.Now()
@@ -368,7 +368,7 @@ func (h *APIHandler) MarketplaceWebhook(w http.ResponseWriter, r *http.Request)
 				ChargesEnabled:   accountObject.ChargesEnabled,
 				PayoutsEnabled:   accountObject.PayoutsEnabled,
 			})
-			if err := h.store.UpsertMarketplaceCreatorAccount(&updated); err != nil {
+			if err := h.store.UpsertMarketplaceCreatorAccount(r.Context(), &updated); err != nil {
 				respondAPIError(w, http.StatusInternalServerError, "marketplace_creator_account_failed", err.Error())
 				return
 			}
@@ -384,12 +384,12 @@ func (h *APIHandler) MarketplaceWebhook(w http.ResponseWriter, r *http.Request)
 func (h *APIHandler) CheckoutMarketplaceListing(w http.ResponseWriter, r *http.Request) {
 	ref := chi.URLParam(r, "ref")
 	session := h.sessionFromRequest(r)
-	user, err := h.store.GetUserByID(session.UserID)
+	user, err := h.store.GetUserByID(r.Context(), session.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "user_not_found", err.Error())
 		return
 	}
-	listing, err := h.store.resolveMarketplaceListing(ref)
+	listing, err := h.store.resolveMarketplaceListing(r.Context(), ref)
 	if err != nil || listing.Status != "published" {
 		respondAPIError(w, http.StatusNotFound, "marketplace_listing_not_found", "Marketplace listing not found.")
 		return
@@ -403,13 +403,13 @@ func (h *APIHandler) CheckoutMarketplaceListing(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	license, err := h.activeMarketplaceLicense(listing.ID, user.ID)
+	license, err := h.activeMarketplaceLicense(r.Context(), listing.ID, user.ID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_license_lookup_failed", err.Error())
 		return
 	}
 	if license != nil {
-		order, err := h.store.GetMarketplaceOrder(license.OrderID)
+		order, err := h.store.GetMarketplaceOrder(r.Context(), license.OrderID)
 		if err != nil {
 			respondAPIError(w, http.StatusInternalServerError, "marketplace_order_lookup_failed", err.Error())
 			return
@@ -432,7 +432,7 @@ func (h *APIHandler) CheckoutMarketplaceListing(w http.ResponseWriter, r *http.R
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_creator_account_failed", err.Error())
 		return
 	}
-	latestVersion, err := h.store.GetLatestMarketplaceListingVersion(listing.ID)
+	latestVersion, err := h.store.GetLatestMarketplaceListingVersion(r.Context(), listing.ID)
 	if err != nil {
 		respondAPIError(w, http.StatusConflict, "marketplace_not_published", "No published marketplace version is available.")
 		return
@@ -473,14 +473,14 @@ func (h *APIHandler) CheckoutMarketplaceListing(w http.ResponseWriter, r *http.R
 	if order.ProviderPaymentIntentID == "" {
 		order.ProviderPaymentIntentID = response.Order.ProviderPaymentIntentID
 	}
-	if err := h.store.CreateMarketplaceOrder(order); err != nil {
+	if err := h.store.CreateMarketplaceOrder(r.Context(), order); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_order_create_failed", err.Error())
 		return
 	}
 
 	response.Order = *order
 	if response.Completed {
-		license, err := h.completeMarketplaceOrder(order)
+		license, err := h.completeMarketplaceOrder(r.Context(), order)
 		if err != nil {
 			respondAPIError(w, http.StatusInternalServerError, "marketplace_checkout_complete_failed", err.Error())
 			return
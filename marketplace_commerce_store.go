@@ -1,12 +1,13 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"time"
 )
 
-func (s *SQLiteStore) GetMarketplaceCreatorAccount(id string) (*MarketplaceCreatorAccount, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetMarketplaceCreatorAccount(ctx context.Context, id string) (*MarketplaceCreatorAccount, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, user_id, workspace_id, provider, provider_account_id, onboarding_status,
 		       details_submitted, charges_enabled, payouts_enabled, onboarding_url, dashboard_url,
 		       onboarding_completed_at, created_at, updated_at
@@ -16,8 +17,8 @@ func (s *SQLiteStore) GetMarketplaceCreatorAccount(id string) (*MarketplaceCreat
 	return scanMarketplaceCreatorAccount(row)
 }
 
-func (s *SQLiteStore) GetMarketplaceCreatorAccountByUser(userID string) (*MarketplaceCreatorAccount, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetMarketplaceCreatorAccountByUser(ctx context.Context, userID string) (*MarketplaceCreatorAccount, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, user_id, workspace_id, provider, provider_account_id, onboarding_status,
 		       details_submitted, charges_enabled, payouts_enabled, onboarding_url, dashboard_url,
 		       onboarding_completed_at, created_at, updated_at
@@ -27,8 +28,8 @@ func (s *SQLiteStore) GetMarketplaceCreatorAccountByUser(userID string) (*Market
 	return scanMarketplaceCreatorAccount(row)
 }
 
-func (s *SQLiteStore) GetMarketplaceCreatorAccountByProviderAccount(provider, providerAccountID string) (*MarketplaceCreatorAccount, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetMarketplaceCreatorAccountByProviderAccount(ctx context.Context, provider, providerAccountID string) (*MarketplaceCreatorAccount, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, user_id, workspace_id, provider, provider_account_id, onboarding_status,
 		       details_submitted, charges_enabled, payouts_enabled, onboarding_url, dashboard_url,
 		       onboarding_completed_at, created_at, updated_at
@@ -38,8 +39,8 @@ func (s *SQLiteStore) GetMarketplaceCreatorAccountByProviderAccount(provider, pr
 	return scanMarketplaceCreatorAccount(row)
 }
 
-func (s *SQLiteStore) UpsertMarketplaceCreatorAccount(account *MarketplaceCreatorAccount) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) UpsertMarketplaceCreatorAccount(ctx context.Context, account *MarketplaceCreatorAccount) error {
+	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO marketplace_creator_accounts (
 			id, user_id, workspace_id, provider, provider_account_id, onboarding_status,
 			details_submitted, charges_enabled, payouts_enabled, onboarding_url, dashboard_url,
@@ -109,8 +110,8 @@ func scanMarketplaceCreatorAccount(scanner interface{ Scan(dest ...any) error })
 	return &account, nil
 }
 
-func (s *SQLiteStore) CreateMarketplaceOrder(order *MarketplaceOrder) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) CreateMarketplaceOrder(ctx context.Context, order *MarketplaceOrder) error {
+	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO marketplace_orders (
 			id, listing_id, listing_version_number, buyer_user_id, buyer_workspace_id, creator_user_id,
 			creator_account_id, provider, provider_checkout_session_id, provider_payment_intent_id,
@@ -140,8 +141,8 @@ func (s *SQLiteStore) CreateMarketplaceOrder(order *MarketplaceOrder) error {
 	return err
 }
 
-func (s *SQLiteStore) GetMarketplaceOrderByCheckoutSession(provider, checkoutSessionID string) (*MarketplaceOrder, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetMarketplaceOrderByCheckoutSession(ctx context.Context, provider, checkoutSessionID string) (*MarketplaceOrder, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, listing_id, listing_version_number, buyer_user_id, buyer_workspace_id, creator_user_id,
 		       creator_account_id, provider, provider_checkout_session_id, provider_payment_intent_id,
 		       status, amount_cents, currency, platform_fee_cents, creator_amount_cents, completed_at,
@@ -152,8 +153,8 @@ func (s *SQLiteStore) GetMarketplaceOrderByCheckoutSession(provider, checkoutSes
 	return scanMarketplaceOrder(row)
 }
 
-func (s *SQLiteStore) GetMarketplaceOrder(id string) (*MarketplaceOrder, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetMarketplaceOrder(ctx context.Context, id string) (*MarketplaceOrder, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, listing_id, listing_version_number, buyer_user_id, buyer_workspace_id, creator_user_id,
 		       creator_account_id, provider, provider_checkout_session_id, provider_payment_intent_id,
 		       status, amount_cents, currency, platform_fee_cents, creator_amount_cents, completed_at,
@@ -164,8 +165,8 @@ func (s *SQLiteStore) GetMarketplaceOrder(id string) (*MarketplaceOrder, error)
 	return scanMarketplaceOrder(row)
 }
 
-func (s *SQLiteStore) UpdateMarketplaceOrder(order *MarketplaceOrder) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) UpdateMarketplaceOrder(ctx context.Context, order *MarketplaceOrder) error {
+	_, err := s.db.ExecContext(ctx, `
 		UPDATE marketplace_orders
 		SET status = ?, provider_payment_intent_id = ?, completed_at = ?, updated_at = ?
 		WHERE id = ?
@@ -216,8 +217,8 @@ func scanMarketplaceOrder(scanner interface{ Scan(dest ...any) error }) (*Market
 	return &order, nil
 }
 
-func (s *SQLiteStore) GetMarketplaceLicense(listingID, buyerUserID string) (*MarketplaceLicense, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetMarketplaceLicense(ctx context.Context, listingID, buyerUserID string) (*MarketplaceLicense, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, listing_id, buyer_user_id, order_id, status, granted_version_number, created_at, updated_at
 		FROM marketplace_licenses
 		WHERE listing_id = ? AND buyer_user_id = ?
@@ -225,8 +226,8 @@ func (s *SQLiteStore) GetMarketplaceLicense(listingID, buyerUserID string) (*Mar
 	return scanMarketplaceLicense(row)
 }
 
-func (s *SQLiteStore) UpsertMarketplaceLicense(license *MarketplaceLicense) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) UpsertMarketplaceLicense(ctx context.Context, license *MarketplaceLicense) error {
+	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO marketplace_licenses (
 			id, listing_id, buyer_user_id, order_id, status, granted_version_number, created_at, updated_at
 		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
@@ -270,8 +271,8 @@ func scanMarketplaceLicense(scanner interface{ Scan(dest ...any) error }) (*Mark
 	return &license, nil
 }
 
-func (s *SQLiteStore) GetMarketplacePayoutByOrder(orderID string) (*MarketplacePayout, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetMarketplacePayoutByOrder(ctx context.Context, orderID string) (*MarketplacePayout, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, order_id, creator_user_id, creator_account_id, provider, provider_transfer_id,
 		       status, amount_cents, currency, platform_fee_cents, created_at, updated_at
 		FROM marketplace_payouts
@@ -280,8 +281,8 @@ func (s *SQLiteStore) GetMarketplacePayoutByOrder(orderID string) (*MarketplaceP
 	return scanMarketplacePayout(row)
 }
 
-func (s *SQLiteStore) UpsertMarketplacePayout(payout *MarketplacePayout) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) UpsertMarketplacePayout(ctx context.Context, payout *MarketplacePayout) error {
+	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO marketplace_payouts (
 			id, order_id, creator_user_id, creator_account_id, provider, provider_transfer_id,
 			status, amount_cents, currency, platform_fee_cents, created_at, updated_at
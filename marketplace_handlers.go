@@ -1,8 +1,8 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -21,10 +21,20 @@ func validMarketplacePriceMode(raw string) bool {
 	}
 }
 
+// validateMarketplaceListing checks the fields a listing is created and updated with.
+func validateMarketplaceListing(v *requestValidator, deckID int64, title, priceMode string, priceCents int) {
+	v.check(deckID != 0, "deckId", "is required")
+	v.required("title", title)
+	if strings.TrimSpace(priceMode) != "" {
+		v.check(validMarketplacePriceMode(priceMode), "priceMode", "must be free or premium")
+	}
+	v.check(priceCents >= 0, "priceCents", "must not be negative")
+}
+
 func (h *APIHandler) requireMarketplacePublishEntitlement(w http.ResponseWriter, r *http.Request) (*SessionRecord, bool) {
 	session := h.sessionFromRequest(r)
 	plan := h.planForRequest(r, session)
-	usage := h.usageForSession(session)
+	usage := h.usageForSession(r.Context(), session)
 	if !entitlementsForPlan(plan, usage).Features.MarketplacePublish {
 		respondAPIError(w, http.StatusForbidden, "marketplace_publish_not_available", "Marketplace publishing is reserved for Pro, Team, and Enterprise workspaces.")
 		return nil, false
@@ -32,14 +42,14 @@ func (h *APIHandler) requireMarketplacePublishEntitlement(w http.ResponseWriter,
 	return session, true
 }
 
-func (h *APIHandler) uniqueMarketplaceSlug(raw, title, excludeID string) (string, error) {
+func (h *APIHandler) uniqueMarketplaceSlug(ctx context.Context, raw, title, excludeID string) (string, error) {
 	base := slugify(firstNonEmpty(strings.TrimSpace(raw), strings.TrimSpace(title)))
 	if base == "workspace" {
 		base = "listing"
 	}
 	candidate := base
 	for attempt := 0; attempt < 100; attempt++ {
-		exists, err := h.store.MarketplaceListingSlugExists(candidate, excludeID)
+		exists, err := h.store.MarketplaceListingSlugExists(ctx, candidate, excludeID)
 		if err != nil {
 			return "", err
 		}
@@ -56,24 +66,24 @@ func (h *APIHandler) loadMarketplaceListingForEdit(r *http.Request, ref string)
 	if session == nil || session.UserID == "" {
 		return nil, nil, nil, sql.ErrNoRows
 	}
-	user, err := h.store.GetUserByID(session.UserID)
+	user, err := h.store.GetUserByID(r.Context(), session.UserID)
 	if err != nil {
 		return nil, nil, session, err
 	}
-	listing, err := h.store.resolveMarketplaceListing(ref)
+	listing, err := h.store.resolveMarketplaceListing(r.Context(), ref)
 	if err != nil {
 		return nil, user, session, err
 	}
 	return listing, user, session, nil
 }
 
-func (h *APIHandler) installMarketplaceVersion(listing *MarketplaceListing, version *MarketplaceListingVersion, destinationWorkspace *Workspace, user *User) (*MarketplaceInstall, error) {
-	sourceDeck, err := h.store.GetDeck(version.SourceDeckID)
+func (h *APIHandler) installMarketplaceVersion(ctx context.Context, listing *MarketplaceListing, version *MarketplaceListingVersion, destinationWorkspace *Workspace, user *User) (*MarketplaceInstall, error) {
+	sourceDeck, err := h.store.GetDeck(ctx, version.SourceDeckID)
 	if err != nil {
 		return nil, err
 	}
 	installedName := fmt.Sprintf("%s (Marketplace v%d)", sourceDeck.Name, version.VersionNumber)
-	newDeck, err := h.store.CopyDeckToCollection(version.SourceDeckID, destinationWorkspace.CollectionID, installedName)
+	newDeck, err := h.store.CopyDeckToCollection(ctx, version.SourceDeckID, destinationWorkspace.CollectionID, installedName)
 	if err != nil {
 		return nil, err
 	}
@@ -90,13 +100,13 @@ func (h *APIHandler) installMarketplaceVersion(listing *MarketplaceListing, vers
 		CreatedAt:           now,
 		UpdatedAt:           now,
 	}
-	if err := h.store.CreateMarketplaceInstall(install); err != nil {
+	if err := h.store.CreateMarketplaceInstall(ctx, install); err != nil {
 		return nil, err
 	}
-	if err := h.reloadCollectionSnapshot(destinationWorkspace.CollectionID); err != nil {
+	if err := h.reloadCollectionSnapshot(ctx, destinationWorkspace.CollectionID); err != nil {
 		return nil, err
 	}
-	return h.store.GetMarketplaceInstall(install.ID)
+	return h.store.GetMarketplaceInstall(ctx, install.ID)
 }
 
 func (h *APIHandler) ListMarketplaceListings(w http.ResponseWriter, r *http.Request) {
@@ -108,7 +118,7 @@ func (h *APIHandler) ListMarketplaceListings(w http.ResponseWriter, r *http.Requ
 		workspaceID = session.WorkspaceID
 		userID = session.UserID
 	}
-	listings, err := h.store.ListMarketplaceListings(scope, userID, workspaceID)
+	listings, err := h.store.ListMarketplaceListings(r.Context(), scope, userID, workspaceID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_list_failed", err.Error())
 		return
@@ -126,21 +136,15 @@ func (h *APIHandler) CreateMarketplaceListing(w http.ResponseWriter, r *http.Req
 	}
 
 	var req CreateMarketplaceListingRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
-	if req.DeckID == 0 || strings.TrimSpace(req.Title) == "" {
-		respondAPIError(w, http.StatusBadRequest, "invalid_marketplace_listing", "Deck and title are required.")
-		return
-	}
-
-	workspace, err := h.store.GetWorkspaceRecord(session.WorkspaceID)
+	workspace, err := h.store.GetWorkspaceRecord(r.Context(), session.WorkspaceID)
 	if err != nil {
 		respondAPIError(w, http.StatusBadRequest, "workspace_not_found", "Workspace not found.")
 		return
 	}
-	sourceCollectionID, err := h.store.GetDeckCollectionID(req.DeckID)
+	sourceCollectionID, err := h.store.GetDeckCollectionID(r.Context(), req.DeckID)
 	if err != nil {
 		respondAPIError(w, http.StatusBadRequest, "source_deck_not_found", "Source deck not found.")
 		return
@@ -150,7 +154,7 @@ func (h *APIHandler) CreateMarketplaceListing(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	slug, err := h.uniqueMarketplaceSlug(req.Slug, req.Title, "")
+	slug, err := h.uniqueMarketplaceSlug(r.Context(), req.Slug, req.Title, "")
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_slug_failed", err.Error())
 		return
@@ -159,10 +163,6 @@ func (h *APIHandler) CreateMarketplaceListing(w http.ResponseWriter, r *http.Req
 	if priceMode == "" {
 		priceMode = "free"
 	}
-	if !validMarketplacePriceMode(priceMode) {
-		respondAPIError(w, http.StatusBadRequest, "invalid_marketplace_price_mode", "Price mode must be free or premium.")
-		return
-	}
 	currency := strings.ToUpper(strings.TrimSpace(req.Currency))
 	if currency == "" {
 		currency = "USD"
@@ -188,11 +188,11 @@ func (h *APIHandler) CreateMarketplaceListing(w http.ResponseWriter, r *http.Req
 		CreatedAt:     now,
 		UpdatedAt:     now,
 	}
-	if err := h.store.CreateMarketplaceListing(listing); err != nil {
+	if err := h.store.CreateMarketplaceListing(r.Context(), listing); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_create_failed", err.Error())
 		return
 	}
-	detail, err := h.store.BuildMarketplaceListingDetail(listing.ID, session.UserID, session.WorkspaceID)
+	detail, err := h.store.BuildMarketplaceListingDetail(r.Context(), listing.ID, session.UserID, session.WorkspaceID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_detail_failed", err.Error())
 		return
@@ -209,7 +209,7 @@ func (h *APIHandler) GetMarketplaceListing(w http.ResponseWriter, r *http.Reques
 		userID = session.UserID
 		workspaceID = session.WorkspaceID
 	}
-	detail, err := h.store.BuildMarketplaceListingDetail(ref, userID, workspaceID)
+	detail, err := h.store.BuildMarketplaceListingDetail(r.Context(), ref, userID, workspaceID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			respondAPIError(w, http.StatusNotFound, "marketplace_listing_not_found", "Marketplace listing not found.")
@@ -246,21 +246,15 @@ func (h *APIHandler) UpdateMarketplaceListing(w http.ResponseWriter, r *http.Req
 	}
 
 	var req UpdateMarketplaceListingRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
-		return
-	}
-	if req.DeckID == 0 || strings.TrimSpace(req.Title) == "" {
-		respondAPIError(w, http.StatusBadRequest, "invalid_marketplace_listing", "Deck and title are required.")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
-
-	workspace, err := h.store.GetWorkspaceRecord(session.WorkspaceID)
+	workspace, err := h.store.GetWorkspaceRecord(r.Context(), session.WorkspaceID)
 	if err != nil {
 		respondAPIError(w, http.StatusBadRequest, "workspace_not_found", "Workspace not found.")
 		return
 	}
-	sourceCollectionID, err := h.store.GetDeckCollectionID(req.DeckID)
+	sourceCollectionID, err := h.store.GetDeckCollectionID(r.Context(), req.DeckID)
 	if err != nil {
 		respondAPIError(w, http.StatusBadRequest, "source_deck_not_found", "Source deck not found.")
 		return
@@ -270,7 +264,7 @@ func (h *APIHandler) UpdateMarketplaceListing(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	slug, err := h.uniqueMarketplaceSlug(req.Slug, req.Title, listing.ID)
+	slug, err := h.uniqueMarketplaceSlug(r.Context(), req.Slug, req.Title, listing.ID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_slug_failed", err.Error())
 		return
@@ -279,10 +273,6 @@ func (h *APIHandler) UpdateMarketplaceListing(w http.ResponseWriter, r *http.Req
 	if priceMode == "" {
 		priceMode = "free"
 	}
-	if !validMarketplacePriceMode(priceMode) {
-		respondAPIError(w, http.StatusBadRequest, "invalid_marketplace_price_mode", "Price mode must be free or premium.")
-		return
-	}
 	currency := strings.ToUpper(strings.TrimSpace(req.Currency))
 	if currency == "" {
 		currency = "USD"
@@ -300,11 +290,11 @@ func (h *APIHandler) UpdateMarketplaceListing(w http.ResponseWriter, r *http.Req
 	listing.PriceCents = req.PriceCents
 	listing.Currency = currency
 	listing.UpdatedAt = time.Now()
-	if err := h.store.UpdateMarketplaceListing(listing); err != nil {
+	if err := h.store.UpdateMarketplaceListing(r.Context(), listing); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_update_failed", err.Error())
 		return
 	}
-	detail, err := h.store.BuildMarketplaceListingDetail(listing.ID, session.UserID, session.WorkspaceID)
+	detail, err := h.store.BuildMarketplaceListingDetail(r.Context(), listing.ID, session.UserID, session.WorkspaceID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_detail_failed", err.Error())
 		return
@@ -335,7 +325,7 @@ func (h *APIHandler) DeleteMarketplaceListing(w http.ResponseWriter, r *http.Req
 		respondAPIError(w, http.StatusForbidden, "marketplace_forbidden", "You can only delete listings in your current workspace.")
 		return
 	}
-	installCount, err := h.store.CountMarketplaceInstalls(listing.ID)
+	installCount, err := h.store.CountMarketplaceInstalls(r.Context(), listing.ID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_delete_failed", err.Error())
 		return
@@ -344,7 +334,7 @@ func (h *APIHandler) DeleteMarketplaceListing(w http.ResponseWriter, r *http.Req
 		respondAPIError(w, http.StatusConflict, "marketplace_listing_has_installs", "Remove active installs before deleting this listing.")
 		return
 	}
-	if err := h.store.DeleteMarketplaceListing(listing.ID); err != nil {
+	if err := h.store.DeleteMarketplaceListing(r.Context(), listing.ID); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_delete_failed", err.Error())
 		return
 	}
@@ -383,16 +373,15 @@ func (h *APIHandler) PublishMarketplaceListing(w http.ResponseWriter, r *http.Re
 	}
 
 	var req PublishMarketplaceListingRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, http.ErrBodyNotAllowed) {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeOptionalValidatedRequest(w, r, &req) {
 		return
 	}
 
 	nextVersion := 1
-	if latestVersion, err := h.store.GetLatestMarketplaceListingVersion(listing.ID); err == nil {
+	if latestVersion, err := h.store.GetLatestMarketplaceListingVersion(r.Context(), listing.ID); err == nil {
 		nextVersion = latestVersion.VersionNumber + 1
 	}
-	noteCount, cardCount, err := h.store.GetDeckContentSummary(listing.DeckID)
+	noteCount, cardCount, err := h.store.GetDeckContentSummary(r.Context(), listing.DeckID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_publish_failed", err.Error())
 		return
@@ -408,13 +397,13 @@ func (h *APIHandler) PublishMarketplaceListing(w http.ResponseWriter, r *http.Re
 		CardCount:         cardCount,
 		CreatedAt:         time.Now(),
 	}
-	if err := h.store.CreateMarketplaceListingVersion(version); err != nil {
+	if err := h.store.CreateMarketplaceListingVersion(r.Context(), version); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_publish_failed", err.Error())
 		return
 	}
 	listing.Status = "published"
 	listing.UpdatedAt = time.Now()
-	if err := h.store.UpdateMarketplaceListing(listing); err != nil {
+	if err := h.store.UpdateMarketplaceListing(r.Context(), listing); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_publish_failed", err.Error())
 		return
 	}
@@ -424,17 +413,17 @@ func (h *APIHandler) PublishMarketplaceListing(w http.ResponseWriter, r *http.Re
 func (h *APIHandler) InstallMarketplaceListing(w http.ResponseWriter, r *http.Request) {
 	ref := chi.URLParam(r, "ref")
 	session := h.sessionFromRequest(r)
-	user, err := h.store.GetUserByID(session.UserID)
+	user, err := h.store.GetUserByID(r.Context(), session.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "user_not_found", err.Error())
 		return
 	}
-	listing, err := h.store.resolveMarketplaceListing(ref)
+	listing, err := h.store.resolveMarketplaceListing(r.Context(), ref)
 	if err != nil || listing.Status != "published" {
 		respondAPIError(w, http.StatusNotFound, "marketplace_listing_not_found", "Marketplace listing not found.")
 		return
 	}
-	if _, allowed, err := h.canAccessPremiumMarketplaceListing(listing, user.ID); err != nil {
+	if _, allowed, err := h.canAccessPremiumMarketplaceListing(r.Context(), listing, user.ID); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_license_lookup_failed", err.Error())
 		return
 	} else if !allowed {
@@ -443,24 +432,23 @@ func (h *APIHandler) InstallMarketplaceListing(w http.ResponseWriter, r *http.Re
 	}
 
 	var req InstallMarketplaceListingRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 	if req.DestinationWorkspaceID == "" {
 		req.DestinationWorkspaceID = session.WorkspaceID
 	}
-	workspace, err := h.store.GetWorkspaceForUser(user.ID, req.DestinationWorkspaceID)
+	workspace, err := h.store.GetWorkspaceForUser(r.Context(), user.ID, req.DestinationWorkspaceID)
 	if err != nil {
 		respondAPIError(w, http.StatusBadRequest, "invalid_destination_workspace", "Destination workspace not found.")
 		return
 	}
-	latestVersion, err := h.store.GetLatestMarketplaceListingVersion(listing.ID)
+	latestVersion, err := h.store.GetLatestMarketplaceListingVersion(r.Context(), listing.ID)
 	if err != nil {
 		respondAPIError(w, http.StatusConflict, "marketplace_not_published", "Publish a listing version before it can be installed.")
 		return
 	}
-	if currentInstall, err := h.store.GetCurrentMarketplaceInstall(listing.ID, user.ID); err == nil {
+	if currentInstall, err := h.store.GetCurrentMarketplaceInstall(r.Context(), listing.ID, user.ID); err == nil {
 		if currentInstall.SourceVersionNumber == latestVersion.VersionNumber {
 			respondJSON(w, http.StatusOK, currentInstall)
 			return
@@ -468,7 +456,7 @@ func (h *APIHandler) InstallMarketplaceListing(w http.ResponseWriter, r *http.Re
 		respondAPIError(w, http.StatusConflict, "marketplace_update_required", "Use the install update flow to move to a newer marketplace version.")
 		return
 	}
-	install, err := h.installMarketplaceVersion(listing, latestVersion, workspace, user)
+	install, err := h.installMarketplaceVersion(r.Context(), listing, latestVersion, workspace, user)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_install_failed", err.Error())
 		return
@@ -480,29 +468,29 @@ func (h *APIHandler) UpdateMarketplaceInstall(w http.ResponseWriter, r *http.Req
 	ref := chi.URLParam(r, "ref")
 	installID := chi.URLParam(r, "installId")
 	session := h.sessionFromRequest(r)
-	user, err := h.store.GetUserByID(session.UserID)
+	user, err := h.store.GetUserByID(r.Context(), session.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "user_not_found", err.Error())
 		return
 	}
-	listing, err := h.store.resolveMarketplaceListing(ref)
+	listing, err := h.store.resolveMarketplaceListing(r.Context(), ref)
 	if err != nil || listing.Status != "published" {
 		respondAPIError(w, http.StatusNotFound, "marketplace_listing_not_found", "Marketplace listing not found.")
 		return
 	}
-	if _, allowed, err := h.canAccessPremiumMarketplaceListing(listing, user.ID); err != nil {
+	if _, allowed, err := h.canAccessPremiumMarketplaceListing(r.Context(), listing, user.ID); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_license_lookup_failed", err.Error())
 		return
 	} else if !allowed {
 		respondAPIError(w, http.StatusConflict, "marketplace_purchase_required", "Purchase this premium listing before installing updates.")
 		return
 	}
-	currentInstall, err := h.store.GetMarketplaceInstall(installID)
+	currentInstall, err := h.store.GetMarketplaceInstall(r.Context(), installID)
 	if err != nil || currentInstall.ListingID != listing.ID || currentInstall.InstalledByUserID != user.ID {
 		respondAPIError(w, http.StatusNotFound, "marketplace_install_not_found", "Marketplace install not found.")
 		return
 	}
-	latestVersion, err := h.store.GetLatestMarketplaceListingVersion(listing.ID)
+	latestVersion, err := h.store.GetLatestMarketplaceListingVersion(r.Context(), listing.ID)
 	if err != nil {
 		respondAPIError(w, http.StatusConflict, "marketplace_not_published", "No published marketplace version is available.")
 		return
@@ -513,21 +501,20 @@ func (h *APIHandler) UpdateMarketplaceInstall(w http.ResponseWriter, r *http.Req
 	}
 
 	var req UpdateMarketplaceInstallRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, http.ErrBodyNotAllowed) {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeOptionalValidatedRequest(w, r, &req) {
 		return
 	}
 	destinationWorkspaceID := currentInstall.WorkspaceID
 	if strings.TrimSpace(req.DestinationWorkspaceID) != "" {
 		destinationWorkspaceID = strings.TrimSpace(req.DestinationWorkspaceID)
 	}
-	workspace, err := h.store.GetWorkspaceForUser(user.ID, destinationWorkspaceID)
+	workspace, err := h.store.GetWorkspaceForUser(r.Context(), user.ID, destinationWorkspaceID)
 	if err != nil {
 		respondAPIError(w, http.StatusBadRequest, "invalid_destination_workspace", "Destination workspace not found.")
 		return
 	}
 
-	nextInstall, err := h.installMarketplaceVersion(listing, latestVersion, workspace, user)
+	nextInstall, err := h.installMarketplaceVersion(r.Context(), listing, latestVersion, workspace, user)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_install_update_failed", err.Error())
 		return
@@ -535,7 +522,7 @@ func (h *APIHandler) UpdateMarketplaceInstall(w http.ResponseWriter, r *http.Req
 	currentInstall.Status = "superseded"
 	currentInstall.SupersededByInstall = nextInstall.ID
 	currentInstall.UpdatedAt = time.Now()
-	if err := h.store.UpdateMarketplaceInstall(currentInstall); err != nil {
+	if err := h.store.UpdateMarketplaceInstall(r.Context(), currentInstall); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_install_update_failed", err.Error())
 		return
 	}
@@ -545,13 +532,13 @@ func (h *APIHandler) UpdateMarketplaceInstall(w http.ResponseWriter, r *http.Req
 func (h *APIHandler) RemoveMarketplaceInstall(w http.ResponseWriter, r *http.Request) {
 	installID := chi.URLParam(r, "installId")
 	session := h.sessionFromRequest(r)
-	install, err := h.store.GetMarketplaceInstall(installID)
+	install, err := h.store.GetMarketplaceInstall(r.Context(), installID)
 	if err != nil || install.InstalledByUserID != session.UserID {
 		respondAPIError(w, http.StatusNotFound, "marketplace_install_not_found", "Marketplace install not found.")
 		return
 	}
 	if install.InstalledDeckID != 0 {
-		if err := h.store.DeleteCopiedDeck(install.InstalledDeckID); err != nil {
+		if err := h.store.DeleteCopiedDeck(r.Context(), install.InstalledDeckID); err != nil {
 			respondAPIError(w, http.StatusInternalServerError, "marketplace_install_delete_failed", err.Error())
 			return
 		}
@@ -560,12 +547,12 @@ func (h *APIHandler) RemoveMarketplaceInstall(w http.ResponseWriter, r *http.Req
 	install.InstalledDeckID = 0
 	install.InstalledDeckName = ""
 	install.UpdatedAt = time.Now()
-	if err := h.store.UpdateMarketplaceInstall(install); err != nil {
+	if err := h.store.UpdateMarketplaceInstall(r.Context(), install); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "marketplace_install_delete_failed", err.Error())
 		return
 	}
-	if workspace, err := h.store.GetWorkspaceRecord(install.WorkspaceID); err == nil {
-		if reloadErr := h.reloadCollectionSnapshot(workspace.CollectionID); reloadErr != nil {
+	if workspace, err := h.store.GetWorkspaceRecord(r.Context(), install.WorkspaceID); err == nil {
+		if reloadErr := h.reloadCollectionSnapshot(r.Context(), workspace.CollectionID); reloadErr != nil {
 			respondAPIError(w, http.StatusInternalServerError, "marketplace_install_delete_failed", reloadErr.Error())
 			return
 		}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"strings"
@@ -44,7 +45,7 @@ func parseMarketplaceTags(raw string) []string {
 	return sanitizeMarketplaceTags(tags)
 }
 
-func (s *SQLiteStore) MarketplaceListingSlugExists(slug, excludeID string) (bool, error) {
+func (s *SQLStore) MarketplaceListingSlugExists(ctx context.Context, slug, excludeID string) (bool, error) {
 	var count int
 	query := `SELECT COUNT(*) FROM marketplace_listings WHERE slug = ?`
 	args := []any{slug}
@@ -52,14 +53,14 @@ func (s *SQLiteStore) MarketplaceListingSlugExists(slug, excludeID string) (bool
 		query += ` AND id <> ?`
 		args = append(args, excludeID)
 	}
-	if err := s.db.QueryRow(query, args...).Scan(&count); err != nil {
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
 
-func (s *SQLiteStore) CreateMarketplaceListing(listing *MarketplaceListing) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) CreateMarketplaceListing(ctx context.Context, listing *MarketplaceListing) error {
+	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO marketplace_listings (
 			id, workspace_id, deck_id, slug, title, summary, description, category, tags, cover_image_url,
 			creator_user_id, price_mode, price_cents, currency, status, created_at, updated_at
@@ -86,8 +87,8 @@ func (s *SQLiteStore) CreateMarketplaceListing(listing *MarketplaceListing) erro
 	return err
 }
 
-func (s *SQLiteStore) UpdateMarketplaceListing(listing *MarketplaceListing) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) UpdateMarketplaceListing(ctx context.Context, listing *MarketplaceListing) error {
+	_, err := s.db.ExecContext(ctx, `
 		UPDATE marketplace_listings
 		SET deck_id = ?, slug = ?, title = ?, summary = ?, description = ?, category = ?, tags = ?,
 			cover_image_url = ?, price_mode = ?, price_cents = ?, currency = ?, status = ?, updated_at = ?
@@ -111,13 +112,13 @@ func (s *SQLiteStore) UpdateMarketplaceListing(listing *MarketplaceListing) erro
 	return err
 }
 
-func (s *SQLiteStore) DeleteMarketplaceListing(id string) error {
-	_, err := s.db.Exec(`DELETE FROM marketplace_listings WHERE id = ?`, id)
+func (s *SQLStore) DeleteMarketplaceListing(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM marketplace_listings WHERE id = ?`, id)
 	return err
 }
 
-func (s *SQLiteStore) GetMarketplaceListingByID(id string) (*MarketplaceListing, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetMarketplaceListingByID(ctx context.Context, id string) (*MarketplaceListing, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, workspace_id, deck_id, slug, title, summary, description, category, tags, cover_image_url,
 		       creator_user_id, price_mode, price_cents, currency, status, created_at, updated_at
 		FROM marketplace_listings
@@ -126,8 +127,8 @@ func (s *SQLiteStore) GetMarketplaceListingByID(id string) (*MarketplaceListing,
 	return scanMarketplaceListing(row)
 }
 
-func (s *SQLiteStore) GetMarketplaceListingBySlug(slug string) (*MarketplaceListing, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetMarketplaceListingBySlug(ctx context.Context, slug string) (*MarketplaceListing, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, workspace_id, deck_id, slug, title, summary, description, category, tags, cover_image_url,
 		       creator_user_id, price_mode, price_cents, currency, status, created_at, updated_at
 		FROM marketplace_listings
@@ -170,8 +171,8 @@ func scanMarketplaceListing(scanner interface{ Scan(dest ...any) error }) (*Mark
 	return &listing, nil
 }
 
-func (s *SQLiteStore) CreateMarketplaceListingVersion(version *MarketplaceListingVersion) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) CreateMarketplaceListingVersion(ctx context.Context, version *MarketplaceListingVersion) error {
+	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO marketplace_listing_versions (
 			id, listing_id, version_number, source_deck_id, published_by_user_id,
 			change_summary, note_count, card_count, created_at
@@ -190,8 +191,8 @@ func (s *SQLiteStore) CreateMarketplaceListingVersion(version *MarketplaceListin
 	return err
 }
 
-func (s *SQLiteStore) ListMarketplaceListingVersions(listingID string) ([]MarketplaceListingVersion, error) {
-	rows, err := s.db.Query(`
+func (s *SQLStore) ListMarketplaceListingVersions(ctx context.Context, listingID string) ([]MarketplaceListingVersion, error) {
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT id, listing_id, version_number, source_deck_id, published_by_user_id,
 		       change_summary, note_count, card_count, created_at
 		FROM marketplace_listing_versions
@@ -214,8 +215,8 @@ func (s *SQLiteStore) ListMarketplaceListingVersions(listingID string) ([]Market
 	return versions, rows.Err()
 }
 
-func (s *SQLiteStore) GetLatestMarketplaceListingVersion(listingID string) (*MarketplaceListingVersion, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetLatestMarketplaceListingVersion(ctx context.Context, listingID string) (*MarketplaceListingVersion, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, listing_id, version_number, source_deck_id, published_by_user_id,
 		       change_summary, note_count, card_count, created_at
 		FROM marketplace_listing_versions
@@ -246,8 +247,8 @@ func scanMarketplaceListingVersion(scanner interface{ Scan(dest ...any) error })
 	return &version, nil
 }
 
-func (s *SQLiteStore) CreateMarketplaceInstall(install *MarketplaceInstall) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) CreateMarketplaceInstall(ctx context.Context, install *MarketplaceInstall) error {
+	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO marketplace_installs (
 			id, listing_id, workspace_id, installed_by_user_id, installed_deck_id,
 			source_version_number, status, superseded_by_install_id, created_at, updated_at
@@ -267,8 +268,8 @@ func (s *SQLiteStore) CreateMarketplaceInstall(install *MarketplaceInstall) erro
 	return err
 }
 
-func (s *SQLiteStore) GetMarketplaceInstall(id string) (*MarketplaceInstall, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetMarketplaceInstall(ctx context.Context, id string) (*MarketplaceInstall, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT i.id, i.listing_id, i.workspace_id, i.installed_by_user_id, i.installed_deck_id,
 		       d.name, i.source_version_number, i.status, i.superseded_by_install_id, i.created_at, i.updated_at
 		FROM marketplace_installs i
@@ -278,8 +279,8 @@ func (s *SQLiteStore) GetMarketplaceInstall(id string) (*MarketplaceInstall, err
 	return scanMarketplaceInstall(row)
 }
 
-func (s *SQLiteStore) GetCurrentMarketplaceInstall(listingID, userID string) (*MarketplaceInstall, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetCurrentMarketplaceInstall(ctx context.Context, listingID, userID string) (*MarketplaceInstall, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT i.id, i.listing_id, i.workspace_id, i.installed_by_user_id, i.installed_deck_id,
 		       d.name, i.source_version_number, i.status, i.superseded_by_install_id, i.created_at, i.updated_at
 		FROM marketplace_installs i
@@ -329,8 +330,8 @@ func scanMarketplaceInstall(scanner interface{ Scan(dest ...any) error }) (*Mark
 	return &install, nil
 }
 
-func (s *SQLiteStore) UpdateMarketplaceInstall(install *MarketplaceInstall) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) UpdateMarketplaceInstall(ctx context.Context, install *MarketplaceInstall) error {
+	_, err := s.db.ExecContext(ctx, `
 		UPDATE marketplace_installs
 		SET workspace_id = ?, installed_deck_id = ?, source_version_number = ?, status = ?,
 		    superseded_by_install_id = ?, updated_at = ?
@@ -347,9 +348,9 @@ func (s *SQLiteStore) UpdateMarketplaceInstall(install *MarketplaceInstall) erro
 	return err
 }
 
-func (s *SQLiteStore) CountMarketplaceInstalls(listingID string) (int, error) {
+func (s *SQLStore) CountMarketplaceInstalls(ctx context.Context, listingID string) (int, error) {
 	var count int
-	if err := s.db.QueryRow(`
+	if err := s.db.QueryRowContext(ctx, `
 		SELECT COUNT(*)
 		FROM marketplace_installs
 		WHERE listing_id = ? AND status = 'active'
@@ -359,27 +360,27 @@ func (s *SQLiteStore) CountMarketplaceInstalls(listingID string) (int, error) {
 	return count, nil
 }
 
-func (s *SQLiteStore) resolveMarketplaceListing(ref string) (*MarketplaceListing, error) {
+func (s *SQLStore) resolveMarketplaceListing(ctx context.Context, ref string) (*MarketplaceListing, error) {
 	ref = strings.TrimSpace(ref)
 	if ref == "" {
 		return nil, sql.ErrNoRows
 	}
-	if listing, err := s.GetMarketplaceListingByID(ref); err == nil {
+	if listing, err := s.GetMarketplaceListingByID(ctx, ref); err == nil {
 		return listing, nil
 	}
-	return s.GetMarketplaceListingBySlug(ref)
+	return s.GetMarketplaceListingBySlug(ctx, ref)
 }
 
-func (s *SQLiteStore) BuildMarketplaceListingSummary(listing *MarketplaceListing, userID, workspaceID string) (MarketplaceListingSummary, error) {
-	creator, err := s.GetUserByID(listing.CreatorUserID)
+func (s *SQLStore) BuildMarketplaceListingSummary(ctx context.Context, listing *MarketplaceListing, userID, workspaceID string) (MarketplaceListingSummary, error) {
+	creator, err := s.GetUserByID(ctx, listing.CreatorUserID)
 	if err != nil {
 		return MarketplaceListingSummary{}, err
 	}
-	deck, err := s.GetDeck(listing.DeckID)
+	deck, err := s.GetDeck(ctx, listing.DeckID)
 	if err != nil {
 		return MarketplaceListingSummary{}, err
 	}
-	installCount, err := s.CountMarketplaceInstalls(listing.ID)
+	installCount, err := s.CountMarketplaceInstalls(ctx, listing.ID)
 	if err != nil {
 		return MarketplaceListingSummary{}, err
 	}
@@ -409,14 +410,14 @@ func (s *SQLiteStore) BuildMarketplaceListingSummary(listing *MarketplaceListing
 		UpdatedAt:          listing.UpdatedAt,
 	}
 
-	if latestVersion, err := s.GetLatestMarketplaceListingVersion(listing.ID); err == nil {
+	if latestVersion, err := s.GetLatestMarketplaceListingVersion(ctx, listing.ID); err == nil {
 		summary.LatestVersionNumber = latestVersion.VersionNumber
 	}
 	if userID != "" {
-		if license, err := s.GetMarketplaceLicense(listing.ID, userID); err == nil && license.Status == "active" {
+		if license, err := s.GetMarketplaceLicense(ctx, listing.ID, userID); err == nil && license.Status == "active" {
 			summary.CurrentUserLicense = license
 		}
-		if install, err := s.GetCurrentMarketplaceInstall(listing.ID, userID); err == nil {
+		if install, err := s.GetCurrentMarketplaceInstall(ctx, listing.ID, userID); err == nil {
 			summary.CurrentUserInstall = install
 			if summary.LatestVersionNumber > 0 && install.SourceVersionNumber < summary.LatestVersionNumber {
 				summary.UpdateAvailable = true
@@ -427,7 +428,7 @@ func (s *SQLiteStore) BuildMarketplaceListingSummary(listing *MarketplaceListing
 	return summary, nil
 }
 
-func (s *SQLiteStore) ListMarketplaceListings(scope, userID, workspaceID string) ([]MarketplaceListingSummary, error) {
+func (s *SQLStore) ListMarketplaceListings(ctx context.Context, scope, userID, workspaceID string) ([]MarketplaceListingSummary, error) {
 	scope = strings.TrimSpace(scope)
 	query := `
 		SELECT id, workspace_id, deck_id, slug, title, summary, description, category, tags, cover_image_url,
@@ -444,7 +445,7 @@ func (s *SQLiteStore) ListMarketplaceListings(scope, userID, workspaceID string)
 	}
 	query += ` ORDER BY updated_at DESC, created_at DESC`
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -456,7 +457,7 @@ func (s *SQLiteStore) ListMarketplaceListings(scope, userID, workspaceID string)
 		if err != nil {
 			return nil, err
 		}
-		summary, err := s.BuildMarketplaceListingSummary(listing, userID, workspaceID)
+		summary, err := s.BuildMarketplaceListingSummary(ctx, listing, userID, workspaceID)
 		if err != nil {
 			return nil, err
 		}
@@ -465,12 +466,12 @@ func (s *SQLiteStore) ListMarketplaceListings(scope, userID, workspaceID string)
 	return listings, rows.Err()
 }
 
-func (s *SQLiteStore) BuildMarketplaceListingDetail(ref, userID, workspaceID string) (*MarketplaceListingDetail, error) {
-	listing, err := s.resolveMarketplaceListing(ref)
+func (s *SQLStore) BuildMarketplaceListingDetail(ctx context.Context, ref, userID, workspaceID string) (*MarketplaceListingDetail, error) {
+	listing, err := s.resolveMarketplaceListing(ctx, ref)
 	if err != nil {
 		return nil, err
 	}
-	summary, err := s.BuildMarketplaceListingSummary(listing, userID, workspaceID)
+	summary, err := s.BuildMarketplaceListingSummary(ctx, listing, userID, workspaceID)
 	if err != nil {
 		return nil, err
 	}
@@ -486,14 +487,14 @@ func (s *SQLiteStore) BuildMarketplaceListingDetail(ref, userID, workspaceID str
 		CanEdit:            summary.CanEdit,
 		CanPublish:         summary.CanEdit,
 	}
-	if latestVersion, err := s.GetLatestMarketplaceListingVersion(listing.ID); err == nil {
+	if latestVersion, err := s.GetLatestMarketplaceListingVersion(ctx, listing.ID); err == nil {
 		detail.LatestVersion = latestVersion
 	}
-	if versions, err := s.ListMarketplaceListingVersions(listing.ID); err == nil {
+	if versions, err := s.ListMarketplaceListingVersions(ctx, listing.ID); err == nil {
 		detail.Versions = versions
 	}
 	if userID != "" {
-		if workspaces, err := s.ListWorkspacesForUser(userID); err == nil {
+		if workspaces, err := s.ListWorkspacesForUser(ctx, userID); err == nil {
 			detail.AvailableWorkspaces = workspaces
 		}
 	}
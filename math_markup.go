@@ -0,0 +1,34 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Math in fields is left for the client to typeset with MathJax. Anki's [latex],
+// [$] and [$$] markup is rewritten to MathJax delimiters, and every math segment is
+// wrapped in a span with class "math" plus "math-inline" or "math-display", so clients
+// know to load MathJax only for cards that need it.
+var mathMarkupRe = regexp.MustCompile(`(?s)\[latex\](.*?)\[/latex\]|\[\$\$\](.*?)\[/\$\$\]|\[\$\](.*?)\[/\$\]|\\\((.*?)\\\)|\\\[(.*?)\\\]`)
+
+const (
+	mathInlineClass  = "math math-inline"
+	mathDisplayClass = "math math-display"
+)
+
+// markupMath rewrites the math segments of a field value for MathJax.
+func markupMath(value string) string {
+	if !strings.ContainsAny(value, `[\`) {
+		return value
+	}
+	return mathMarkupRe.ReplaceAllStringFunc(value, func(segment string) string {
+		m := mathMarkupRe.FindStringSubmatch(segment)
+		switch {
+		case m[1] != "" || m[2] != "" || m[5] != "":
+			return `<span class="` + mathDisplayClass + `">\[` + m[1] + m[2] + m[5] + `\]</span>`
+		case m[3] != "" || m[4] != "":
+			return `<span class="` + mathInlineClass + `">\(` + m[3] + m[4] + `\)</span>`
+		}
+		return segment
+	})
+}
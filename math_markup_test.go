@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMarkupMath(t *testing.T) {
+	cases := map[string]string{
+		`plain text`:                     `plain text`,
+		`area \(\pi r^2\)`:               `area <span class="math math-inline">\(\pi r^2\)</span>`,
+		`\[E = mc^2\]`:                   `<span class="math math-display">\[E = mc^2\]</span>`,
+		`[$]x_1[/$] and [$$]\sum x[/$$]`: `<span class="math math-inline">\(x_1\)</span> and <span class="math math-display">\[\sum x\]</span>`,
+		`[latex]a &lt; b[/latex]`:        `<span class="math math-display">\[a &lt; b\]</span>`,
+		`[unrelated] brackets`:           `[unrelated] brackets`,
+	}
+	for in, want := range cases {
+		if got := markupMath(in); got != want {
+			t.Fatalf("markupMath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRenderTemplateMarksUpMathOnce(t *testing.T) {
+	col := NewCollection()
+	deck := col.NewDeck("Physics")
+	nt := NoteType{Name: "Basic", Fields: []string{"Front", "Back"}, Templates: []CardTemplate{{
+		Name: "Card 1", QFmt: "{{Front}}", AFmt: "{{FrontSide}}<hr>{{Back}}",
+	}}}
+	note := Note{FieldMap: map[string]string{"Front": `Energy of mass \(m\)?`, "Back": `[$]mc^2[/$]`}}
+
+	cards, err := col.generateCardsFromNote(nt, note, deck.ID, time.Now())
+	if err != nil || len(cards) != 1 {
+		t.Fatalf("expected one card, got %d (%v)", len(cards), err)
+	}
+	front := `Energy of mass <span class="math math-inline">\(m\)</span>?`
+	if cards[0].Front != front {
+		t.Fatalf("front = %q", cards[0].Front)
+	}
+	if want := front + `<hr><span class="math math-inline">\(mc^2\)</span>`; cards[0].Back != want {
+		t.Fatalf("back = %q, want %q", cards[0].Back, want)
+	}
+}
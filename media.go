@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// mediaContentTypes maps the extensions cards commonly embed to their MIME types;
+// other extensions fall back to the system MIME table. Which types may be uploaded is
+// configured separately, and defaults to the types listed here, so that user files are
+// never served as HTML or SVG from our origin unless the operator allows it.
+var mediaContentTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".avif": "image/avif",
+	".bmp":  "image/bmp",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/ogg",
+	".wav":  "audio/wav",
+	".flac": "audio/flac",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+}
+
+// mismatchedMediaContent lists sniffed content types that never belong in a media file
+// whatever its name claims, such as an archive renamed to .png.
+var mismatchedMediaContent = map[string]bool{
+	"text/html; charset=utf-8":     true,
+	"text/xml; charset=utf-8":      true,
+	"application/pdf":              true,
+	"application/postscript":       true,
+	"application/zip":              true,
+	"application/x-gzip":           true,
+	"application/x-rar-compressed": true,
+	"application/wasm":             true,
+}
+
+// defaultMediaTypes is the upload allowlist when none is configured.
+func defaultMediaTypes() []string {
+	var types []string
+	for _, contentType := range mediaContentTypes {
+		if !slices.Contains(types, contentType) {
+			types = append(types, contentType)
+		}
+	}
+	slices.Sort(types)
+	return types
+}
+
+// mediaTypeAllowed reports whether contentType matches an allowlist entry, either
+// exactly or through a "family/*" wildcard.
+func mediaTypeAllowed(contentType string, allowed []string) bool {
+	family, _, _ := strings.Cut(contentType, "/")
+	for _, entry := range allowed {
+		entry = strings.TrimSpace(entry)
+		if strings.EqualFold(entry, contentType) || strings.EqualFold(entry, family+"/*") || entry == "*/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// formatByteSize renders a configured limit for error messages.
+func formatByteSize(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%d MB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%d KB", n>>10)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// MediaRejection details why an upload was refused.
+type MediaRejection struct {
+	Filename     string   `json:"filename,omitempty"`
+	ContentType  string   `json:"contentType,omitempty"`
+	Size         int64    `json:"size,omitempty"`
+	MaxFileBytes int64    `json:"maxFileBytes,omitempty"`
+	AllowedTypes []string `json:"allowedTypes,omitempty"`
+}
+
+type MediaResponse struct {
+	Filename    string    `json:"filename"`
+	URL         string    `json:"url"`
+	ContentType string    `json:"contentType"`
+	Size        int       `json:"size"`
+	AddedAt     time.Time `json:"addedAt"`
+}
+
+func mediaResponse(m *MediaRef) MediaResponse {
+	return MediaResponse{
+		Filename:    m.Filename,
+		URL:         "/media/" + url.PathEscape(m.Filename),
+		ContentType: mediaContentType(m.Filename),
+		Size:        len(m.Data),
+		AddedAt:     m.AddedAt,
+	}
+}
+
+func mediaContentType(filename string) string {
+	ext := strings.ToLower(path.Ext(filename))
+	if contentType, ok := mediaContentTypes[ext]; ok {
+		return contentType
+	}
+	if contentType, _, err := mime.ParseMediaType(mime.TypeByExtension(ext)); err == nil {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// cleanMediaFilename reduces an uploaded filename to its base name, as cards refer to
+// media by bare filename.
+func cleanMediaFilename(name string) (string, error) {
+	name = strings.TrimSpace(path.Base(strings.ReplaceAll(name, "\\", "/")))
+	switch {
+	case name == "" || name == "." || name == "/":
+		return "", fmt.Errorf("filename is required")
+	case len(name) > 255:
+		return "", fmt.Errorf("filename is longer than 255 bytes")
+	case strings.ContainsAny(name, "\"'<>&\x00"):
+		return "", fmt.Errorf("filename cannot contain quotes, < > & or NUL")
+	}
+	return name, nil
+}
+
+// mediaRejection is why an uploaded media file was refused, as an API error.
+type mediaRejection struct {
+	Status  int             `json:"-"`
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Details *MediaRejection `json:"details,omitempty"`
+}
+
+func (m *mediaRejection) respond(w http.ResponseWriter) {
+	if m.Details == nil {
+		respondAPIError(w, m.Status, m.Code, m.Message)
+		return
+	}
+	respondAPIErrorWithDetails(w, m.Status, m.Code, m.Message, m.Details)
+}
+
+func (h *APIHandler) mediaTooLarge(size int64) *mediaRejection {
+	limits := h.config.Media
+	return &mediaRejection{
+		Status:  http.StatusRequestEntityTooLarge,
+		Code:    "media_too_large",
+		Message: fmt.Sprintf("Media files are limited to %s", formatByteSize(limits.MaxFileBytes)),
+		Details: &MediaRejection{Size: size, MaxFileBytes: limits.MaxFileBytes},
+	}
+}
+
+// checkMediaName cleans an uploaded file's name and checks its type is allowed.
+func (h *APIHandler) checkMediaName(name string) (string, *mediaRejection) {
+	filename, err := cleanMediaFilename(name)
+	if err != nil {
+		return "", &mediaRejection{Status: http.StatusBadRequest, Code: "invalid_media", Message: err.Error()}
+	}
+	contentType := mediaContentType(filename)
+	if allowed := h.config.Media.AllowedTypes; !mediaTypeAllowed(contentType, allowed) {
+		return "", &mediaRejection{
+			Status:  http.StatusUnsupportedMediaType,
+			Code:    "media_type_not_allowed",
+			Message: fmt.Sprintf("%s files are not allowed", contentType),
+			Details: &MediaRejection{Filename: filename, ContentType: contentType, AllowedTypes: allowed},
+		}
+	}
+	return filename, nil
+}
+
+// checkMediaData checks an uploaded file's size and that its content matches its name.
+func (h *APIHandler) checkMediaData(filename string, data []byte) *mediaRejection {
+	switch {
+	case len(data) == 0:
+		return &mediaRejection{Status: http.StatusBadRequest, Code: "invalid_media", Message: "File is empty"}
+	case int64(len(data)) > h.config.Media.MaxFileBytes:
+		return h.mediaTooLarge(int64(len(data)))
+	}
+	if sniffed := http.DetectContentType(data); mismatchedMediaContent[sniffed] {
+		contentType := mediaContentType(filename)
+		return &mediaRejection{
+			Status:  http.StatusUnsupportedMediaType,
+			Code:    "media_content_mismatch",
+			Message: fmt.Sprintf("%s does not contain %s data", filename, contentType),
+			Details: &MediaRejection{Filename: filename, ContentType: contentType},
+		}
+	}
+	return nil
+}
+
+func mediaSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// storeMedia saves data under filename, or returns the existing file when the same
+// bytes are already stored under that name. A different file with the same name is kept
+// and the upload is stored with a content hash added to its name.
+func (h *APIHandler) storeMedia(ctx context.Context, collectionID, filename string, data []byte) (*MediaRef, bool, error) {
+	sum := sha256.Sum256(data)
+	stem, ext := strings.TrimSuffix(filename, path.Ext(filename)), path.Ext(filename)
+	for _, candidate := range []string{filename, stem + "-" + hex.EncodeToString(sum[:4]) + ext} {
+		existing, err := h.store.GetMedia(ctx, candidate)
+		if errors.Is(err, sql.ErrNoRows) {
+			media := &MediaRef{Filename: candidate, Data: data, AddedAt: time.Now()}
+			if err := h.store.AddMedia(ctx, collectionID, media); err != nil {
+				return nil, false, err
+			}
+			return media, true, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if bytes.Equal(existing.Data, data) {
+			if _, err := h.store.GetCollectionMedia(ctx, collectionID, candidate); err == nil {
+				return existing, false, nil
+			}
+		}
+	}
+	return nil, false, fmt.Errorf("a different file named %q already exists", filename)
+}
+
+// UploadMedia serves POST /api/media. The multipart "file" part is stored under its
+// filename; the response names the file cards should reference, which differs from
+// the upload's name when that name was already taken by other content.
+func (h *APIHandler) UploadMedia(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	collectionID := h.collectionIDForRequest(r)
+
+	// Allow for the multipart framing around the file.
+	r.Body = http.MaxBytesReader(w, r.Body, h.config.Media.MaxFileBytes+1<<20)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			h.mediaTooLarge(0).respond(w)
+			return
+		}
+		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Expected a multipart form with a file part")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_request", "file is required")
+		return
+	}
+	defer file.Close()
+
+	filename, rejection := h.checkMediaName(header.Filename)
+	if rejection != nil {
+		rejection.respond(w)
+		return
+	}
+	if header.Size > h.config.Media.MaxFileBytes {
+		h.mediaTooLarge(header.Size).respond(w)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(file, h.config.Media.MaxFileBytes+1))
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Failed to read file")
+		return
+	}
+	if rejection := h.checkMediaData(filename, data); rejection != nil {
+		rejection.respond(w)
+		return
+	}
+
+	media, created, err := h.storeMedia(r.Context(), collectionID, filename, data)
+	if err != nil {
+		respondAPIError(w, http.StatusConflict, "media_conflict", err.Error())
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	respondJSON(w, status, mediaResponse(media))
+}
+
+// ServeMedia serves GET /media/{filename}, the URL cards' <img> and [sound:...]
+// references resolve to. Only the signed-in workspace's files are visible.
+func (h *APIHandler) ServeMedia(w http.ResponseWriter, r *http.Request) {
+	filename, err := url.PathUnescape(chi.URLParam(r, "filename"))
+	if err != nil {
+		respondAPIError(w, http.StatusNotFound, "media_not_found", "Media not found")
+		return
+	}
+	media, err := h.store.GetCollectionMedia(r.Context(), h.collectionIDForRequest(r), filename)
+	if err != nil {
+		respondAPIError(w, http.StatusNotFound, "media_not_found", "Media not found")
+		return
+	}
+	w.Header().Set("Content-Type", mediaContentType(media.Filename))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	http.ServeContent(w, r, media.Filename, media.AddedAt, bytes.NewReader(media.Data))
+}
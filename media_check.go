@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var soundRefRe = regexp.MustCompile(`\[sound:([^\]]+)\]`)
+
+// MissingMedia is a file that notes refer to but that is not stored.
+type MissingMedia struct {
+	Filename string  `json:"filename"`
+	NoteIDs  []int64 `json:"noteIds"`
+}
+
+// MediaCheckResponse is the Check Media report. Unused files are stored but referenced
+// by no note, template or generated speech; files whose names start with "_" are
+// reserved for templates and never count as unused, as in Anki.
+type MediaCheckResponse struct {
+	Total   int            `json:"total"`
+	Missing []MissingMedia `json:"missing"`
+	Unused  []string       `json:"unused"`
+	Deleted []string       `json:"deleted,omitempty"`
+}
+
+// mediaReferences lists the local files value refers to through <img src> and
+// [sound:...]. Remote and data URLs are skipped.
+func mediaReferences(value string) []string {
+	var refs []string
+	add := func(ref string) {
+		ref = strings.TrimSpace(html.UnescapeString(ref))
+		ref = strings.TrimPrefix(ref, "/media/")
+		if unescaped, err := url.PathUnescape(ref); err == nil {
+			ref = unescaped
+		}
+		if ref == "" || strings.Contains(ref, "://") || strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "//") {
+			return
+		}
+		if !slices.Contains(refs, ref) {
+			refs = append(refs, ref)
+		}
+	}
+	for _, m := range imageSrcRe.FindAllStringSubmatch(value, -1) {
+		add(m[1])
+	}
+	for _, m := range soundRefRe.FindAllStringSubmatch(value, -1) {
+		add(m[1])
+	}
+	return refs
+}
+
+// checkMedia compares the media references of col's notes and templates with the
+// stored files.
+func (h *APIHandler) checkMedia(ctx context.Context, collectionID string, col *Collection) (MediaCheckResponse, error) {
+	stored, err := h.store.ListMediaFilenames(ctx, collectionID)
+	if err != nil {
+		return MediaCheckResponse{}, err
+	}
+
+	used := map[string]bool{}
+	for _, nt := range col.NoteTypes {
+		for _, tmpl := range nt.Templates {
+			for _, ref := range mediaReferences(tmpl.QFmt + tmpl.AFmt) {
+				used[ref] = true
+			}
+		}
+	}
+
+	referencedBy := map[string][]int64{}
+	for _, note := range col.Notes {
+		for _, value := range note.FieldMap {
+			for _, ref := range mediaReferences(value) {
+				referencedBy[ref] = append(referencedBy[ref], note.ID)
+			}
+		}
+		if nt, ok := col.NoteTypes[note.Type]; ok {
+			for filename := range ttsUtterances(nt, note) {
+				used[filename] = true
+			}
+			if note.Type == imageOcclusionNoteType {
+				if image := imageFilename(note.FieldMap[imageOcclusionImageField]); image != "" {
+					referencedBy[image] = append(referencedBy[image], note.ID)
+				}
+			}
+		}
+	}
+
+	storedSet := make(map[string]bool, len(stored))
+	report := MediaCheckResponse{Total: len(stored), Missing: []MissingMedia{}, Unused: []string{}}
+	for _, filename := range stored {
+		storedSet[filename] = true
+		if !used[filename] && referencedBy[filename] == nil && !strings.HasPrefix(filename, "_") {
+			report.Unused = append(report.Unused, filename)
+		}
+	}
+	for filename, noteIDs := range referencedBy {
+		if storedSet[filename] {
+			continue
+		}
+		slices.Sort(noteIDs)
+		report.Missing = append(report.Missing, MissingMedia{Filename: filename, NoteIDs: slices.Compact(noteIDs)})
+	}
+	sort.Slice(report.Missing, func(i, j int) bool {
+		return report.Missing[i].Filename < report.Missing[j].Filename
+	})
+	return report, nil
+}
+
+// CheckMedia serves GET /api/media/check.
+func (h *APIHandler) CheckMedia(w http.ResponseWriter, r *http.Request) {
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	report, err := h.checkMedia(r.Context(), collectionID, col)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "media_check_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
+
+// DeleteUnusedMedia serves POST /api/media/check/delete-unused. The check is run again
+// so only files that are unused right now are deleted; the report reflects the
+// collection afterwards.
+func (h *APIHandler) DeleteUnusedMedia(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	report, err := h.checkMedia(r.Context(), collectionID, col)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "media_check_failed", err.Error())
+		return
+	}
+	if _, err := h.store.DeleteCollectionMedia(r.Context(), collectionID, report.Unused); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "media_delete_failed", err.Error())
+		return
+	}
+	report.Total -= len(report.Unused)
+	report.Deleted, report.Unused = report.Unused, []string{}
+	respondJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Media that no note, template or generated speech refers to any more is deleted by a
+// periodic sweep rather than at the moment a note is deleted or edited. Each sweep marks
+// newly unreferenced files and clears the mark on files that are referenced again, then
+// deletes files that have stayed unreferenced for the grace period. The grace period
+// covers uploads that are not saved into a note yet and edits that are undone.
+
+// MediaSweepResult reports one sweep of one collection.
+type MediaSweepResult struct {
+	CollectionID string   `json:"collectionId"`
+	Unused       int      `json:"unused"`
+	Deleted      []string `json:"deleted"`
+}
+
+// sweepCollectionMedia runs one sweep over a collection.
+func (h *APIHandler) sweepCollectionMedia(ctx context.Context, collectionID string, col *Collection, now time.Time) (MediaSweepResult, error) {
+	result := MediaSweepResult{CollectionID: collectionID, Deleted: []string{}}
+	report, err := h.checkMedia(ctx, collectionID, col)
+	if err != nil {
+		return result, err
+	}
+	unused := make(map[string]bool, len(report.Unused))
+	for _, filename := range report.Unused {
+		unused[filename] = true
+	}
+	if err := h.store.MarkUnusedMedia(ctx, collectionID, unused, now); err != nil {
+		return result, err
+	}
+	deleted, err := h.store.DeleteMediaUnusedBefore(ctx, collectionID, now.Add(-h.config.MediaCleanup.GracePeriod))
+	if err != nil {
+		return result, err
+	}
+	result.Unused = len(report.Unused) - len(deleted)
+	if deleted != nil {
+		result.Deleted = deleted
+	}
+	return result, nil
+}
+
+// sweepMedia sweeps every collection. A collection that fails is logged and skipped.
+func (h *APIHandler) sweepMedia(ctx context.Context, now time.Time) []MediaSweepResult {
+	collectionIDs, err := h.store.ListCollectionIDs(ctx)
+	if err != nil {
+		log.Printf("media sweep: failed to list collections: %v", err)
+		return nil
+	}
+	var results []MediaSweepResult
+	for _, collectionID := range collectionIDs {
+		col, err := h.store.GetCollection(ctx, collectionID)
+		if err != nil {
+			log.Printf("media sweep: failed to load collection %s: %v", collectionID, err)
+			continue
+		}
+		result, err := h.sweepCollectionMedia(ctx, collectionID, col, now)
+		if err != nil {
+			log.Printf("media sweep: collection %s: %v", collectionID, err)
+			continue
+		}
+		if len(result.Deleted) > 0 {
+			log.Printf("media sweep: deleted %d unused files from collection %s", len(result.Deleted), collectionID)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// StartMediaSweeper sweeps media on the configured interval until ctx is done. It does
+// nothing when the interval is zero. Close waits for the sweeper to stop.
+func (h *APIHandler) StartMediaSweeper(ctx context.Context) {
+	interval := h.config.MediaCleanup.Interval
+	if interval <= 0 {
+		return
+	}
+	h.background.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				h.profiles.mu.RLock()
+				h.sweepMedia(ctx, now)
+				h.profiles.mu.RUnlock()
+			}
+		}
+	})
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Media sync keeps a device's media folder in step with the collection's media table.
+// Files are compared by SHA-256, so only files whose content differs are transferred:
+//
+//  1. GET /api/sync/media lists every file with its hash; with ?sinceUSN= it lists only
+//     files added, changed or deleted after that change-log USN.
+//  2. POST /api/sync/media/download returns the requested files as a zip, up to
+//     mediaSyncChunkBytes per response; the zip's manifest names the files left over.
+//  3. POST /api/sync/media/upload stores the multipart "file" parts of one chunk. A name
+//     already used by different content is stored under a new name, which the device
+//     must use in its notes from then on.
+//  4. POST /api/sync/media/delete removes files unless they changed after sinceUsn.
+
+// mediaSyncChunkBytes bounds the file data in one media sync download or upload; a single
+// file larger than this still travels alone.
+const mediaSyncChunkBytes = 32 << 20
+
+// MediaSyncEntry describes a media file without its contents.
+type MediaSyncEntry struct {
+	Filename string    `json:"filename"`
+	SHA256   string    `json:"sha256"`
+	Size     int64     `json:"size"`
+	AddedAt  time.Time `json:"addedAt"`
+}
+
+type MediaSyncListResponse struct {
+	Files     []MediaSyncEntry `json:"files"`
+	Deleted   []string         `json:"deleted"`
+	LatestUSN int64            `json:"latestUsn"`
+}
+
+type MediaSyncFilesRequest struct {
+	Filenames []string `json:"filenames"`
+	SinceUSN  int64    `json:"sinceUsn,omitempty"` // delete only: last USN the device pulled
+}
+
+func (req *MediaSyncFilesRequest) Validate(v *requestValidator) {
+	v.check(len(req.Filenames) > 0, "filenames", "is required")
+	v.check(req.SinceUSN >= 0, "sinceUsn", "must not be negative")
+}
+
+// MediaSyncDownloadManifest is manifest.json in a media download; files sit under media/.
+type MediaSyncDownloadManifest struct {
+	Files     []MediaSyncEntry `json:"files"`
+	Remaining []string         `json:"remaining"` // requested but left for the next download
+	Missing   []string         `json:"missing"`   // not in the collection
+}
+
+type MediaSyncStored struct {
+	Filename string `json:"filename"` // name the file was uploaded under
+	StoredAs string `json:"storedAs"` // name notes must reference
+	SHA256   string `json:"sha256"`
+	Created  bool   `json:"created"` // false when the same content was already stored
+}
+
+type MediaSyncRejected struct {
+	Filename string          `json:"filename"`
+	Code     string          `json:"code"`
+	Message  string          `json:"message"`
+	Details  *MediaRejection `json:"details,omitempty"`
+}
+
+type MediaSyncUploadResponse struct {
+	Stored   []MediaSyncStored   `json:"stored"`
+	Rejected []MediaSyncRejected `json:"rejected"`
+}
+
+type MediaSyncDeleteResponse struct {
+	Deleted   []string       `json:"deleted"`
+	Conflicts []SyncConflict `json:"conflicts,omitempty"` // EntityID is 0; Message names the file
+}
+
+// ListMediaSyncEntries describes a collection's media files, sorted by name. A nil
+// filenames lists every file.
+func (s *SQLStore) ListMediaSyncEntries(ctx context.Context, collectionID string, filenames []string) ([]MediaSyncEntry, error) {
+	query := `SELECT filename, COALESCE(sha256, ''), LENGTH(data), COALESCE(added_at, 0) FROM media WHERE collection_id = ?`
+	args := []interface{}{collectionID}
+	if filenames != nil {
+		if len(filenames) == 0 {
+			return []MediaSyncEntry{}, nil
+		}
+		query += ` AND filename IN (` + strings.TrimSuffix(strings.Repeat("?,", len(filenames)), ",") + `)`
+		for _, filename := range filenames {
+			args = append(args, filename)
+		}
+	}
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY filename`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []MediaSyncEntry{}
+	for rows.Next() {
+		var entry MediaSyncEntry
+		var addedAt int64
+		if err := rows.Scan(&entry.Filename, &entry.SHA256, &entry.Size, &addedAt); err != nil {
+			return nil, err
+		}
+		entry.AddedAt = time.Unix(addedAt, 0)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// mediaChangesSince returns the media files written and deleted after sinceUSN, up to
+// and including untilUSN.
+func (h *APIHandler) mediaChangesSince(ctx context.Context, collectionID, userID string, sinceUSN, untilUSN int64) (changed, deleted []string, err error) {
+	lastOp := map[string]string{}
+	var order []string
+	for sinceUSN < untilUSN {
+		events, err := h.store.ListChanges(ctx, collectionID, userID, sinceUSN, maxChangeFeedLimit)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, event := range events {
+			if event.USN > untilUSN {
+				break
+			}
+			if event.EntityType == "media" {
+				if _, seen := lastOp[event.EntityID]; !seen {
+					order = append(order, event.EntityID)
+				}
+				lastOp[event.EntityID] = event.Op
+			}
+		}
+		if len(events) < maxChangeFeedLimit {
+			break
+		}
+		sinceUSN = events[len(events)-1].USN
+	}
+	changed, deleted = []string{}, []string{}
+	for _, filename := range order {
+		if lastOp[filename] == changeOpDelete {
+			deleted = append(deleted, filename)
+		} else {
+			changed = append(changed, filename)
+		}
+	}
+	return changed, deleted, nil
+}
+
+// ListSyncMedia handles GET /api/sync/media?sinceUSN=.
+func (h *APIHandler) ListSyncMedia(w http.ResponseWriter, r *http.Request) {
+	collectionID := h.collectionIDForRequest(r)
+	sinceUSN, ok := parseSinceUSN(w, r)
+	if !ok {
+		return
+	}
+	latest, err := h.store.LatestChangeUSN(r.Context(), collectionID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "media_sync_failed", err.Error())
+		return
+	}
+	resp := MediaSyncListResponse{Deleted: []string{}, LatestUSN: latest}
+
+	var filenames []string
+	if sinceUSN > 0 {
+		filenames, resp.Deleted, err = h.mediaChangesSince(r.Context(), collectionID, h.userIDFromRequest(r), sinceUSN, latest)
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "media_sync_failed", err.Error())
+			return
+		}
+	}
+	// Files changed and then deleted again within the window are simply absent here.
+	if resp.Files, err = h.store.ListMediaSyncEntries(r.Context(), collectionID, filenames); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "media_sync_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+func decodeMediaSyncFilesRequest(w http.ResponseWriter, r *http.Request) (MediaSyncFilesRequest, bool) {
+	var req MediaSyncFilesRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return req, false
+	}
+	if len(req.Filenames) > maxChangeFeedLimit {
+		respondAPIError(w, http.StatusRequestEntityTooLarge, "media_sync_batch_too_large",
+			fmt.Sprintf("Send at most %d filenames per request", maxChangeFeedLimit))
+		return req, false
+	}
+	return req, true
+}
+
+// DownloadSyncMedia handles POST /api/sync/media/download.
+func (h *APIHandler) DownloadSyncMedia(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeMediaSyncFilesRequest(w, r)
+	if !ok {
+		return
+	}
+	collectionID := h.collectionIDForRequest(r)
+
+	manifest := MediaSyncDownloadManifest{Files: []MediaSyncEntry{}, Remaining: []string{}, Missing: []string{}}
+	var files []*MediaRef
+	var total int64
+	seen := map[string]bool{}
+	for _, filename := range req.Filenames {
+		if seen[filename] {
+			continue
+		}
+		seen[filename] = true
+		if len(files) > 0 && total >= mediaSyncChunkBytes {
+			manifest.Remaining = append(manifest.Remaining, filename)
+			continue
+		}
+		media, err := h.store.GetCollectionMedia(r.Context(), collectionID, filename)
+		if err != nil {
+			manifest.Missing = append(manifest.Missing, filename)
+			continue
+		}
+		if len(files) > 0 && total+int64(len(media.Data)) > mediaSyncChunkBytes {
+			manifest.Remaining = append(manifest.Remaining, filename)
+			continue
+		}
+		files = append(files, media)
+		total += int64(len(media.Data))
+		manifest.Files = append(manifest.Files, MediaSyncEntry{
+			Filename: media.Filename,
+			SHA256:   mediaSHA256(media.Data),
+			Size:     int64(len(media.Data)),
+			AddedAt:  media.AddedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="media.zip"`)
+	w.WriteHeader(http.StatusOK)
+	zipWriter := zip.NewWriter(w)
+	writeEntry := func(name string, data []byte) error {
+		// Media is mostly compressed already; storing it saves CPU for nothing lost.
+		writer, err := zipWriter.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(data)
+		return err
+	}
+	manifestJSON, err := json.Marshal(manifest)
+	if err == nil {
+		err = writeEntry("manifest.json", manifestJSON)
+	}
+	for _, media := range files {
+		if err != nil {
+			break
+		}
+		err = writeEntry("media/"+media.Filename, media.Data)
+	}
+	if err == nil {
+		// Headers are already sent, so on error the zip is left unterminated and the
+		// client retries the download.
+		zipWriter.Close()
+	}
+}
+
+// UploadSyncMedia handles POST /api/sync/media/upload.
+func (h *APIHandler) UploadSyncMedia(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	collectionID := h.collectionIDForRequest(r)
+
+	// Allow for the multipart framing around the files.
+	limit := max(int64(mediaSyncChunkBytes), h.config.Media.MaxFileBytes) + 1<<20
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Expected a multipart form with file parts")
+		return
+	}
+
+	resp := MediaSyncUploadResponse{Stored: []MediaSyncStored{}, Rejected: []MediaSyncRejected{}}
+	reject := func(filename string, rejection *mediaRejection) {
+		resp.Rejected = append(resp.Rejected, MediaSyncRejected{
+			Filename: filename, Code: rejection.Code, Message: rejection.Message, Details: rejection.Details,
+		})
+	}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var maxBytes *http.MaxBytesError
+			if errors.As(err, &maxBytes) {
+				respondAPIError(w, http.StatusRequestEntityTooLarge, "media_sync_chunk_too_large",
+					fmt.Sprintf("Upload at most %s of media per request", formatByteSize(mediaSyncChunkBytes)))
+				return
+			}
+			respondAPIError(w, http.StatusBadRequest, "invalid_request", "Malformed multipart body")
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+		filename, rejection := h.checkMediaName(part.FileName())
+		if rejection != nil {
+			reject(part.FileName(), rejection)
+			part.Close()
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(part, h.config.Media.MaxFileBytes+1))
+		part.Close()
+		if err != nil {
+			var maxBytes *http.MaxBytesError
+			if errors.As(err, &maxBytes) {
+				respondAPIError(w, http.StatusRequestEntityTooLarge, "media_sync_chunk_too_large",
+					fmt.Sprintf("Upload at most %s of media per request", formatByteSize(mediaSyncChunkBytes)))
+				return
+			}
+			respondAPIError(w, http.StatusBadRequest, "invalid_request", "Failed to read file")
+			return
+		}
+		if rejection := h.checkMediaData(filename, data); rejection != nil {
+			reject(filename, rejection)
+			continue
+		}
+		media, created, err := h.storeMedia(r.Context(), collectionID, filename, data)
+		if err != nil {
+			reject(filename, &mediaRejection{Code: "media_conflict", Message: err.Error()})
+			continue
+		}
+		resp.Stored = append(resp.Stored, MediaSyncStored{
+			Filename: filename, StoredAs: media.Filename, SHA256: mediaSHA256(data), Created: created,
+		})
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// DeleteSyncMedia handles POST /api/sync/media/delete.
+func (h *APIHandler) DeleteSyncMedia(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	req, ok := decodeMediaSyncFilesRequest(w, r)
+	if !ok {
+		return
+	}
+	collectionID := h.collectionIDForRequest(r)
+	latest, err := h.store.LatestChangeUSN(r.Context(), collectionID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "media_sync_failed", err.Error())
+		return
+	}
+	changedSince, _, err := h.mediaChangesSince(r.Context(), collectionID, h.userIDFromRequest(r), req.SinceUSN, latest)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "media_sync_failed", err.Error())
+		return
+	}
+	changed := map[string]bool{}
+	for _, filename := range changedSince {
+		changed[filename] = true
+	}
+
+	resp := MediaSyncDeleteResponse{Deleted: []string{}}
+	for _, filename := range req.Filenames {
+		if changed[filename] {
+			resp.Conflicts = append(resp.Conflicts, SyncConflict{EntityType: "media", Reason: "changed_on_server", Message: filename})
+			continue
+		}
+		deleted, err := h.store.DeleteCollectionMedia(r.Context(), collectionID, []string{filename})
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "media_sync_failed", err.Error())
+			return
+		}
+		if deleted == 0 {
+			resp.Conflicts = append(resp.Conflicts, SyncConflict{EntityType: "media", Reason: "not_found", Message: filename})
+			continue
+		}
+		resp.Deleted = append(resp.Deleted, filename)
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
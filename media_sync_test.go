@@ -0,0 +1,131 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func doMediaSyncUploadRequest(t *testing.T, router http.Handler, files map[string][]byte) MediaSyncUploadResponse {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for filename, content := range files {
+		part, err := writer.CreateFormFile("file", filename)
+		if err != nil {
+			t.Fatalf("failed to create multipart file part: %v", err)
+		}
+		if _, err := part.Write(content); err != nil {
+			t.Fatalf("failed to write multipart file content: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close multipart writer: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sync/media/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected media upload 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	return decodeJSON[MediaSyncUploadResponse](t, rr)
+}
+
+func TestAPI_MediaSyncTransfersOnlyChangedFiles(t *testing.T) {
+	env := setupAPITestEnv(t)
+	uploaded := doMediaSyncUploadRequest(t, env.router, map[string][]byte{
+		"cat.png":   []byte("PNG cat"),
+		"dog.mp3":   []byte("ID3 dog"),
+		"page.html": []byte("<script>"),
+	})
+	if len(uploaded.Stored) != 2 || len(uploaded.Rejected) != 1 || uploaded.Rejected[0].Code != "media_type_not_allowed" {
+		t.Fatalf("expected two files stored and the html rejected, got %+v", uploaded)
+	}
+
+	manifest := decodeJSON[MediaSyncListResponse](t, doRawRequest(env.router, http.MethodGet, "/api/sync/media", ""))
+	if len(manifest.Files) != 2 || manifest.Files[0].Filename != "cat.png" || manifest.Files[0].SHA256 != mediaSHA256([]byte("PNG cat")) || manifest.Files[0].Size != 7 {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+
+	// Same content is not stored twice; a clash with different content is renamed.
+	again := doMediaSyncUploadRequest(t, env.router, map[string][]byte{"cat.png": []byte("PNG cat")})
+	if len(again.Stored) != 1 || again.Stored[0].Created || again.Stored[0].StoredAs != "cat.png" {
+		t.Fatalf("expected the identical file to be reused, got %+v", again)
+	}
+	renamed := doMediaSyncUploadRequest(t, env.router, map[string][]byte{"dog.mp3": []byte("ID3 puppy")})
+	if len(renamed.Stored) != 1 || !renamed.Stored[0].Created || renamed.Stored[0].StoredAs == "dog.mp3" {
+		t.Fatalf("expected the clashing file under a new name, got %+v", renamed)
+	}
+
+	delta := decodeJSON[MediaSyncListResponse](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/sync/media?sinceUSN=%d", manifest.LatestUSN), ""))
+	if len(delta.Files) != 1 || delta.Files[0].Filename != renamed.Stored[0].StoredAs || len(delta.Deleted) != 0 || delta.LatestUSN <= manifest.LatestUSN {
+		t.Fatalf("expected only the renamed file in the delta, got %+v", delta)
+	}
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/sync/media/download", MediaSyncFilesRequest{Filenames: []string{"cat.png", "missing.png"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected download 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	archive, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatalf("expected a zip, got %v", err)
+	}
+	contents := map[string][]byte{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("open %s failed: %v", file.Name, err)
+		}
+		contents[file.Name], _ = io.ReadAll(reader)
+		reader.Close()
+	}
+	var downloaded MediaSyncDownloadManifest
+	if err := json.Unmarshal(contents["manifest.json"], &downloaded); err != nil {
+		t.Fatalf("decode manifest failed: %v", err)
+	}
+	if len(contents) != 2 || string(contents["media/cat.png"]) != "PNG cat" || len(downloaded.Files) != 1 || len(downloaded.Missing) != 1 {
+		t.Fatalf("expected only cat.png with missing.png reported, got %v %+v", contents, downloaded)
+	}
+}
+
+func TestAPI_MediaSyncDeleteReportsConflicts(t *testing.T) {
+	env := setupAPITestEnv(t)
+	doMediaSyncUploadRequest(t, env.router, map[string][]byte{"old.png": []byte("PNG old"), "new.png": []byte("PNG new")})
+	cursor := decodeJSON[MediaSyncListResponse](t, doRawRequest(env.router, http.MethodGet, "/api/sync/media", "")).LatestUSN
+
+	// Another device replaces new.png after this device's cursor, so this device's delete loses.
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/sync/media/delete", MediaSyncFilesRequest{Filenames: []string{"new.png"}, SinceUSN: cursor})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected delete 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	doMediaSyncUploadRequest(t, env.router, map[string][]byte{"new.png": []byte("PNG newer")})
+
+	rr = doJSONRequest(t, env.router, http.MethodPost, "/api/sync/media/delete", MediaSyncFilesRequest{
+		Filenames: []string{"old.png", "new.png", "gone.png"},
+		SinceUSN:  cursor,
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected delete 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	result := decodeJSON[MediaSyncDeleteResponse](t, rr)
+	if len(result.Deleted) != 1 || result.Deleted[0] != "old.png" || len(result.Conflicts) != 2 {
+		t.Fatalf("expected old.png deleted and two conflicts, got %+v", result)
+	}
+	if result.Conflicts[0].Message != "new.png" || result.Conflicts[0].Reason != "changed_on_server" || result.Conflicts[1].Reason != "not_found" {
+		t.Fatalf("unexpected conflicts %+v", result.Conflicts)
+	}
+
+	delta := decodeJSON[MediaSyncListResponse](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/sync/media?sinceUSN=%d", cursor), ""))
+	if len(delta.Deleted) != 1 || delta.Deleted[0] != "old.png" || len(delta.Files) != 1 || delta.Files[0].Filename != "new.png" {
+		t.Fatalf("expected the delete and the re-upload in the delta, got %+v", delta)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func doMediaUploadRequest(t *testing.T, server http.Handler, cookie, filename string, content []byte) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("failed to create multipart file part: %v", err)
+	}
+	if _, err := part.Write(content); err != nil {
+		t.Fatalf("failed to write multipart file content: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close multipart writer: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/media", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Cookie", cookie)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCleanMediaFilename(t *testing.T) {
+	for input, want := range map[string]string{
+		"cat.png":             "cat.png",
+		"../../etc/cat.JPG":   "cat.JPG",
+		`C:\Users\me\dog.mp3`: "dog.mp3",
+	} {
+		if got, err := cleanMediaFilename(input); err != nil || got != want {
+			t.Fatalf("cleanMediaFilename(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", ".", `a"b.png`, "<x>.png"} {
+		if _, err := cleanMediaFilename(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestAPI_MediaUploadAndServe(t *testing.T) {
+	env := setupAPITestEnv(t)
+	server := NewServer(mustLocalAppConfig(), env.handler, fstest.MapFS{
+		"index.html": &fstest.MapFile{Data: []byte("<html></html>")},
+	})
+
+	rr := doMediaUploadRequest(t, server, env.authCookie, "my cat.png", []byte("PNG one"))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected upload 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	uploaded := decodeJSON[MediaResponse](t, rr)
+	if uploaded.Filename != "my cat.png" || uploaded.URL != "/media/my%20cat.png" || uploaded.ContentType != "image/png" || uploaded.Size != 7 {
+		t.Fatalf("unexpected upload response %+v", uploaded)
+	}
+
+	rr = doRawRequestWithHeaders(server, http.MethodGet, uploaded.URL, "", map[string]string{"Cookie": env.authCookie})
+	if rr.Code != http.StatusOK || rr.Body.String() != "PNG one" {
+		t.Fatalf("expected media body, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Type"); got != "image/png" {
+		t.Fatalf("expected image/png, got %q", got)
+	}
+
+	// Re-uploading the same bytes reuses the file; different bytes get a new name.
+	if rr := doMediaUploadRequest(t, server, env.authCookie, "my cat.png", []byte("PNG one")); rr.Code != http.StatusOK {
+		t.Fatalf("expected identical re-upload 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	rr = doMediaUploadRequest(t, server, env.authCookie, "my cat.png", []byte("PNG two"))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected renamed upload 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if renamed := decodeJSON[MediaResponse](t, rr); renamed.Filename == "my cat.png" {
+		t.Fatalf("expected a different name for different content, got %+v", renamed)
+	}
+
+	rr = doMediaUploadRequest(t, server, env.authCookie, "sound.mp3", []byte("ID3"))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected audio upload 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	rr = doRawRequestWithHeaders(server, http.MethodGet, "/media/sound.mp3", "", map[string]string{"Cookie": env.authCookie})
+	if got := rr.Header().Get("Content-Type"); rr.Code != http.StatusOK || got != "audio/mpeg" {
+		t.Fatalf("expected audio/mpeg, got %d %q", rr.Code, got)
+	}
+
+	if rr := doMediaUploadRequest(t, server, env.authCookie, "page.html", []byte("<script>")); rr.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected html upload to be rejected, got %d", rr.Code)
+	}
+	if rr := doRawRequestWithHeaders(server, http.MethodGet, "/media/missing.png", "", map[string]string{"Cookie": env.authCookie}); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected missing media 404, got %d", rr.Code)
+	}
+	if rr := doRawRequest(server, http.MethodGet, uploaded.URL, ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected anonymous media request 401, got %d", rr.Code)
+	}
+}
+
+func TestAPI_MediaUploadLimits(t *testing.T) {
+	env := setupAPITestEnv(t)
+	env.handler.config.Media = MediaConfig{MaxFileBytes: 16, AllowedTypes: []string{"image/*", "audio/mpeg"}}
+	server := NewServer(mustLocalAppConfig(), env.handler, fstest.MapFS{
+		"index.html": &fstest.MapFile{Data: []byte("<html></html>")},
+	})
+
+	if rr := doMediaUploadRequest(t, server, env.authCookie, "photo.webp", []byte("RIFF")); rr.Code != http.StatusCreated {
+		t.Fatalf("expected wildcard-allowed upload 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr := doMediaUploadRequest(t, server, env.authCookie, "clip.mp4", []byte("video"))
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected disallowed type 415, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	rejected := decodeJSON[struct {
+		Code    string         `json:"code"`
+		Details MediaRejection `json:"details"`
+	}](t, rr)
+	if rejected.Code != "media_type_not_allowed" || rejected.Details.ContentType != "video/mp4" || len(rejected.Details.AllowedTypes) != 2 {
+		t.Fatalf("unexpected rejection %+v", rejected)
+	}
+
+	rr = doMediaUploadRequest(t, server, env.authCookie, "big.png", bytes.Repeat([]byte("x"), 17))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected oversized upload 413, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	tooLarge := decodeJSON[struct {
+		Code    string         `json:"code"`
+		Details MediaRejection `json:"details"`
+	}](t, rr)
+	if tooLarge.Code != "media_too_large" || tooLarge.Details.MaxFileBytes != 16 || tooLarge.Details.Size != 17 {
+		t.Fatalf("unexpected rejection %+v", tooLarge)
+	}
+
+	rr = doMediaUploadRequest(t, server, env.authCookie, "archive.png", []byte("PK\x03\x04zipdata"))
+	if rr.Code != http.StatusUnsupportedMediaType || !strings.Contains(rr.Body.String(), "media_content_mismatch") {
+		t.Fatalf("expected renamed archive to be rejected, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
+
+func TestMediaReferences(t *testing.T) {
+	refs := mediaReferences(`<img src="a.png"> [sound:b.mp3] <img src="/media/my%20c.jpg"> <img src="https://example.com/d.png"> <img src="a.png">`)
+	if strings.Join(refs, ",") != "a.png,my c.jpg,b.mp3" {
+		t.Fatalf("unexpected references %v", refs)
+	}
+}
+
+func TestAPI_CheckMedia(t *testing.T) {
+	env := setupAPITestEnv(t)
+	var collectionID string
+	if err := env.store.db.QueryRow("SELECT collection_id FROM decks WHERE id = 1").Scan(&collectionID); err != nil {
+		t.Fatalf("collection lookup failed: %v", err)
+	}
+	for _, filename := range []string{"used.png", "orphan.png", "_template.css.png", "sound.mp3"} {
+		if err := env.store.AddMedia(context.Background(), collectionID, &MediaRef{Filename: filename, Data: []byte(filename), AddedAt: time.Now()}); err != nil {
+			t.Fatalf("add media %s failed: %v", filename, err)
+		}
+	}
+
+	note := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": `<img src="used.png"> [sound:sound.mp3]`, "Back": `<img src="gone.png">`},
+	}, nil)
+
+	rr := doJSONRequest(t, env.router, http.MethodGet, "/api/media/check", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	report := decodeJSON[MediaCheckResponse](t, rr)
+	if report.Total != 4 || strings.Join(report.Unused, ",") != "orphan.png" {
+		t.Fatalf("unexpected unused files %+v", report)
+	}
+	if len(report.Missing) != 1 || report.Missing[0].Filename != "gone.png" || report.Missing[0].NoteIDs[0] != note.Note.ID {
+		t.Fatalf("unexpected missing files %+v", report.Missing)
+	}
+
+	rr = doJSONRequest(t, env.router, http.MethodPost, "/api/media/check/delete-unused", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	cleaned := decodeJSON[MediaCheckResponse](t, rr)
+	if strings.Join(cleaned.Deleted, ",") != "orphan.png" || len(cleaned.Unused) != 0 || cleaned.Total != 3 {
+		t.Fatalf("unexpected cleanup report %+v", cleaned)
+	}
+	if _, err := env.store.GetMedia(context.Background(), "orphan.png"); err == nil {
+		t.Fatalf("expected orphan.png to be deleted")
+	}
+	if _, err := env.store.GetMedia(context.Background(), "used.png"); err != nil {
+		t.Fatalf("expected used.png to be kept: %v", err)
+	}
+}
+
+func TestMediaSweepHonoursGracePeriod(t *testing.T) {
+	env := setupAPITestEnv(t)
+	env.handler.config.MediaCleanup.GracePeriod = 7 * 24 * time.Hour
+	var collectionID string
+	if err := env.store.db.QueryRow("SELECT collection_id FROM decks WHERE id = 1").Scan(&collectionID); err != nil {
+		t.Fatalf("collection lookup failed: %v", err)
+	}
+	for _, filename := range []string{"kept.png", "dropped.png", "stray.png"} {
+		if err := env.store.AddMedia(context.Background(), collectionID, &MediaRef{Filename: filename, Data: []byte(filename), AddedAt: time.Now()}); err != nil {
+			t.Fatalf("add media %s failed: %v", filename, err)
+		}
+	}
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": `<img src="kept.png">`, "Back": `<img src="dropped.png">`},
+	}, nil)
+
+	sweep := func(now time.Time) MediaSweepResult {
+		t.Helper()
+		col, err := env.store.GetCollection(context.Background(), collectionID)
+		if err != nil {
+			t.Fatalf("load collection failed: %v", err)
+		}
+		result, err := env.handler.sweepCollectionMedia(context.Background(), collectionID, col, now)
+		if err != nil {
+			t.Fatalf("sweep failed: %v", err)
+		}
+		return result
+	}
+
+	start := time.Now()
+	if result := sweep(start); result.Unused != 1 || len(result.Deleted) != 0 {
+		t.Fatalf("expected stray.png marked but kept, got %+v", result)
+	}
+
+	note := created.Note
+	note.FieldMap["Back"] = "text only"
+	if err := env.store.UpdateNote(context.Background(), &note); err != nil {
+		t.Fatalf("update note failed: %v", err)
+	}
+	if result := sweep(start.Add(24 * time.Hour)); result.Unused != 2 || len(result.Deleted) != 0 {
+		t.Fatalf("expected two files waiting out the grace period, got %+v", result)
+	}
+
+	result := sweep(start.Add(7*24*time.Hour + time.Minute))
+	if strings.Join(result.Deleted, ",") != "stray.png" || result.Unused != 1 {
+		t.Fatalf("expected only stray.png past its grace period, got %+v", result)
+	}
+
+	// A file referenced again before its grace period ends is kept.
+	note.FieldMap["Back"] = `<img src="dropped.png">`
+	if err := env.store.UpdateNote(context.Background(), &note); err != nil {
+		t.Fatalf("update note failed: %v", err)
+	}
+	if result := sweep(start.Add(30 * 24 * time.Hour)); result.Unused != 0 || len(result.Deleted) != 0 {
+		t.Fatalf("expected nothing left to delete, got %+v", result)
+	}
+	for _, filename := range []string{"kept.png", "dropped.png"} {
+		if _, err := env.store.GetMedia(context.Background(), filename); err != nil {
+			t.Fatalf("expected %s to survive: %v", filename, err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// Distribution metrics served by GET /api/stats/distributions/{metric}. Intervals and
+// stability are bucketed in days, difficulty across FSRS's 1-10 scale. New cards have no
+// memory state yet and are left out.
+const (
+	distributionIntervals  = "intervals"
+	distributionStability  = "stability"
+	distributionDifficulty = "difficulty"
+)
+
+// dayBucketEdges are the lower bounds of the day buckets; the last one is open-ended.
+var dayBucketEdges = []float64{0, 1, 2, 3, 4, 5, 6, 7, 14, 21, 30, 60, 90, 180, 365, 730}
+
+// DistributionBucket counts the cards with a value in [Min, Max). Max is 0 for the open
+// last bucket.
+type DistributionBucket struct {
+	Label string  `json:"label"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max,omitempty"`
+	Count int     `json:"count"`
+}
+
+// Distribution is a histogram of one memory metric.
+type Distribution struct {
+	Metric  string               `json:"metric"`
+	DeckID  int64                `json:"deckId,omitempty"`
+	Cards   int                  `json:"cards"`
+	Average float64              `json:"average"`
+	Median  float64              `json:"median"`
+	Buckets []DistributionBucket `json:"buckets"`
+}
+
+func isValidDistributionMetric(metric string) bool {
+	switch metric {
+	case distributionIntervals, distributionStability, distributionDifficulty:
+		return true
+	}
+	return false
+}
+
+// distributionValue reads metric from a card's memory state.
+func distributionValue(card fsrs.Card, metric string) float64 {
+	switch metric {
+	case distributionStability:
+		return card.Stability
+	case distributionDifficulty:
+		return card.Difficulty
+	}
+	return float64(card.ScheduledDays)
+}
+
+func distributionBuckets(metric string) []DistributionBucket {
+	if metric == distributionDifficulty {
+		buckets := make([]DistributionBucket, 0, 9)
+		for lower := 1.0; lower < 10; lower++ {
+			buckets = append(buckets, DistributionBucket{Label: fmt.Sprintf("%g-%g", lower, lower+1), Min: lower, Max: lower + 1})
+		}
+		return buckets
+	}
+	buckets := make([]DistributionBucket, len(dayBucketEdges))
+	for i, lower := range dayBucketEdges {
+		bucket := DistributionBucket{Min: lower}
+		switch {
+		case i == len(dayBucketEdges)-1:
+			bucket.Label = fmt.Sprintf("%gd+", lower)
+		case dayBucketEdges[i+1]-lower == 1:
+			bucket.Max, bucket.Label = dayBucketEdges[i+1], fmt.Sprintf("%gd", lower)
+		default:
+			bucket.Max, bucket.Label = dayBucketEdges[i+1], fmt.Sprintf("%g-%gd", lower, dayBucketEdges[i+1]-1)
+		}
+		buckets[i] = bucket
+	}
+	return buckets
+}
+
+// buildDistribution buckets metric across the reviewed cards among cards.
+func buildDistribution(cards []fsrs.Card, metric string) Distribution {
+	dist := Distribution{Metric: metric, Buckets: distributionBuckets(metric)}
+	values := make([]float64, 0, len(cards))
+	for _, card := range cards {
+		if card.State == fsrs.New {
+			continue
+		}
+		values = append(values, distributionValue(card, metric))
+	}
+	if len(values) == 0 {
+		return dist
+	}
+
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+		// The last bucket catches everything above the scale, the first everything below.
+		i := len(dist.Buckets) - 1
+		for i > 0 && value < dist.Buckets[i].Min {
+			i--
+		}
+		dist.Buckets[i].Count++
+	}
+	dist.Cards = len(values)
+	dist.Average = math.Round(sum/float64(len(values))*100) / 100
+	slices.Sort(values)
+	middle := values[len(values)/2]
+	if len(values)%2 == 0 {
+		middle = (values[len(values)/2-1] + middle) / 2
+	}
+	dist.Median = math.Round(middle*100) / 100
+	return dist
+}
+
+// GetMemoryDistribution serves GET /api/stats/distributions/{metric}, where metric is
+// intervals, stability, or difficulty. deckId narrows it to a deck and its descendants.
+func (h *APIHandler) GetMemoryDistribution(w http.ResponseWriter, r *http.Request) {
+	metric := chi.URLParam(r, "metric")
+	if !isValidDistributionMetric(metric) {
+		respondAPIError(w, http.StatusNotFound, "unknown_distribution", "Distribution must be intervals, stability, or difficulty")
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	var deckID int64
+	if raw := strings.TrimSpace(r.URL.Query().Get("deckId")); raw != "" {
+		deckID, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || col.Decks[deckID] == nil {
+			respondAPIError(w, http.StatusBadRequest, "invalid_deck_id", "Deck not found")
+			return
+		}
+	}
+	cards, err := h.store.ListCardMemoryStates(r.Context(), h.userIDFromRequest(r), collectionID, deckID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "distribution_failed", err.Error())
+		return
+	}
+	dist := buildDistribution(cards, metric)
+	dist.DeckID = deckID
+	respondJSON(w, http.StatusOK, dist)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestBuildDistribution(t *testing.T) {
+	cards := []fsrs.Card{
+		{State: fsrs.New},
+		{State: fsrs.Review, ScheduledDays: 1, Stability: 1.5, Difficulty: 2.5},
+		{State: fsrs.Review, ScheduledDays: 10, Stability: 12, Difficulty: 5},
+		{State: fsrs.Review, ScheduledDays: 1000, Stability: 900, Difficulty: 10},
+	}
+	intervals := buildDistribution(cards, distributionIntervals)
+	if intervals.Cards != 3 || intervals.Median != 10 || intervals.Average != 337 {
+		t.Fatalf("expected new cards left out of the summary, got %+v", intervals)
+	}
+	counts := map[string]int{}
+	for _, bucket := range intervals.Buckets {
+		counts[bucket.Label] = bucket.Count
+	}
+	if counts["1d"] != 1 || counts["7-13d"] != 1 || counts["730d+"] != 1 {
+		t.Fatalf("expected each interval in its bucket, got %+v", intervals.Buckets)
+	}
+	difficulty := buildDistribution(cards, distributionDifficulty)
+	if len(difficulty.Buckets) != 9 || difficulty.Buckets[1].Count != 1 || difficulty.Buckets[4].Count != 1 || difficulty.Buckets[8].Count != 1 {
+		t.Fatalf("expected difficulty 10 in the top bucket, got %+v", difficulty.Buckets)
+	}
+}
+
+func TestAPI_MemoryDistribution(t *testing.T) {
+	env := setupAPITestEnv(t)
+	id := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Myelin", "Back": "Sheath"},
+	}, nil).Cards[0].ID
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", id), AnswerCardRequest{Rating: 4}); rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	rr := doRawRequest(env.router, http.MethodGet, "/api/stats/distributions/stability?deckId=1", "")
+	dist := decodeJSON[Distribution](t, rr)
+	if rr.Code != http.StatusOK || dist.Cards != 1 || dist.DeckID != 1 || dist.Average <= 0 {
+		t.Fatalf("expected the reviewed card's stability, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doRawRequest(env.router, http.MethodGet, "/api/stats/distributions/ease", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected an unknown metric to 404, got %d", rr.Code)
+	}
+}
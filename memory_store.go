@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+)
+
+// MemoryStore is a Store whose database lives only in memory, for tests and throwaway
+// collections such as a demo that should leave nothing on disk; the API tests run on
+// it. It is a SQLStore on a private in-memory SQLite database, so the API handlers
+// take it like any other store and its queries are the ones a file runs. Unlike a
+// file, which is kept in WAL mode, it cannot be read while a transaction writes to it,
+// so a store method must not read outside the transaction it has open. Closing it
+// discards the database.
+type MemoryStore struct {
+	*SQLStore
+	// keep holds one connection open for the store's lifetime: SQLite frees an
+	// in-memory database when its last connection closes, and the pool closes idle ones.
+	keep *sql.Conn
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// memoryStoreCount numbers the in-memory databases, which are shared by name between
+// the connections of one pool and must not be shared between stores.
+var memoryStoreCount atomic.Int64
+
+// NewMemoryStore returns a MemoryStore with an up-to-date, empty schema.
+func NewMemoryStore() (*MemoryStore, error) {
+	// The memdb VFS shares one database between the pool's connections with ordinary
+	// locking, so the busy timeout orders writers as it does for a file.
+	name := fmt.Sprintf("/microdote-memory-%d", memoryStoreCount.Add(1))
+	db, err := sql.Open("sqlite3", sqliteDSN("file:"+name, false)+"&vfs=memdb&_foreign_keys=on")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	configureSQLitePool(db)
+	keep, err := db.Conn(context.Background())
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	store, err := newSQLStore(db, dialectSQLite)
+	if err != nil {
+		_ = keep.Close()
+		return nil, err
+	}
+	return &MemoryStore{SQLStore: store, keep: keep}, nil
+}
+
+// Close discards the database.
+func (s *MemoryStore) Close() error {
+	_ = s.keep.Close()
+	return s.SQLStore.Close()
+}
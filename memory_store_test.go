@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func newMemoryStoreForTest(t *testing.T) *MemoryStore {
+	t.Helper()
+	store, err := NewMemoryStore()
+	if err != nil {
+		t.Fatalf("Failed to create memory store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func TestMemoryStoreServesTheAPI(t *testing.T) {
+	store := newMemoryStoreForTest(t)
+	env := seedAPITestEnv(t, store, mustLocalAppConfig(), nil)
+
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Hola", "Back": "Hello"},
+	}, nil)
+	if len(created.Cards) != 1 {
+		t.Fatalf("Expected one card, got %d", len(created.Cards))
+	}
+	cardID := created.Cards[0].ID
+
+	answer := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", cardID), AnswerCardRequest{Rating: 3, TimeTakenMs: 1500})
+	if answer.Code != http.StatusOK {
+		t.Fatalf("Expected answer 200, got %d (%s)", answer.Code, answer.Body.String())
+	}
+	get := doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/cards/%d", cardID), "")
+	if get.Code != http.StatusOK {
+		t.Fatalf("Expected get card 200, got %d (%s)", get.Code, get.Body.String())
+	}
+	if card := decodeJSON[Card](t, get); card.SRS.State == fsrs.New {
+		t.Errorf("Expected the answered card to leave the new state")
+	}
+	if logs, err := store.GetRevlogForCard(context.Background(), cardID); err != nil || len(logs) != 1 {
+		t.Errorf("Expected one review log entry, got %d (err %v)", len(logs), err)
+	}
+}
+
+func TestMemoryStoresAreSeparateAndOutliveIdleConnections(t *testing.T) {
+	ctx := context.Background()
+	first := newMemoryStoreForTest(t)
+	second := newMemoryStoreForTest(t)
+
+	if err := first.CreateCollection(ctx, NewCollection()); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	if err := first.CreateDeck(ctx, &Deck{ID: 1, Name: "Test", Cards: []int64{}}); err != nil {
+		t.Fatalf("Failed to create deck: %v", err)
+	}
+	// Let the pool close its idle connections; the database must outlive them.
+	first.db.SetMaxIdleConns(0)
+	time.Sleep(10 * time.Millisecond)
+	if _, err := first.GetDeck(ctx, 1); err != nil {
+		t.Fatalf("Expected the deck to survive idle connections closing, got %v", err)
+	}
+	if _, err := second.GetDeck(ctx, 1); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected the second store to be empty, got %v", err)
+	}
+}
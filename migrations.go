@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
 )
 
 // migrate runs database migrations to ensure schema is up to date.
-func (s *SQLiteStore) migrate() error {
+func (s *SQLStore) migrate() error {
+	if s.dialect == dialectPostgres {
+		return s.migratePostgres()
+	}
+
 	// Ensure metadata table exists first
 	if err := s.ensureMetadataTable(); err != nil {
 		return err
@@ -40,6 +45,31 @@ func (s *SQLiteStore) migrate() error {
 		{14, "add_deck_priority_order", s.runMigration014_AddDeckPriorityOrder},
 		{15, "add_focus_session_protocol_fields", s.runMigration015_AddFocusSessionProtocolFields},
 		{16, "add_subscription_billing_fields", s.runMigration016_AddSubscriptionBillingFields},
+		{17, "add_collection_preferences", s.runMigration017_AddCollectionPreferences},
+		{18, "add_calendar_feeds", s.runMigration018_AddCalendarFeeds},
+		{19, "add_revlog_fsrs_detail", s.runMigration019_AddRevlogFSRSDetail},
+		{20, "add_revlog_review_kind", s.runMigration020_AddRevlogReviewKind},
+		{21, "add_change_log", s.runMigration021_AddChangeLog},
+		{22, "add_notes_full_text_index", s.runMigration022_AddNotesFullTextIndex},
+		{23, "add_image_occlusion_note_type", s.runMigration023_AddImageOcclusionNoteType},
+		{24, "add_media_unused_since", s.runMigration024_AddMediaUnusedSince},
+		{25, "add_media_sha256", s.runMigration025_AddMediaSHA256},
+		{26, "add_operation_log", s.runMigration026_AddOperationLog},
+		{27, "add_deck_options_collection", s.runMigration027_AddDeckOptionsCollection},
+		{28, "add_learning_step", s.runMigration028_AddLearningStep},
+		{29, "add_lapse_options", s.runMigration029_AddLapseOptions},
+		{30, "add_leech_options", s.runMigration030_AddLeechOptions},
+		{31, "add_card_buried_at", s.runMigration031_AddCardBuriedAt},
+		{32, "add_sm2_scheduler", s.runMigration032_AddSM2Scheduler},
+		{33, "add_review_order", s.runMigration033_AddReviewOrder},
+		{34, "add_filtered_decks", s.runMigration034_AddFilteredDecks},
+		{35, "add_study_session_total_time", s.runMigration035_AddStudySessionTotalTime},
+		{36, "add_study_group_leaderboard_opt_in", s.runMigration036_AddStudyGroupLeaderboardOptIn},
+		{37, "add_user_passwords", s.runMigration037_AddUserPasswords},
+		{38, "add_api_tokens", s.runMigration038_AddAPITokens},
+		{39, "add_deck_grants", s.runMigration039_AddDeckGrants},
+		{40, "add_collection_owners", s.runMigration040_AddCollectionOwners},
+		{41, "hash_calendar_feed_tokens", s.runMigration041_HashCalendarFeedTokens},
 	}
 
 	for _, m := range migrations {
@@ -59,7 +89,7 @@ func (s *SQLiteStore) migrate() error {
 	return nil
 }
 
-func (s *SQLiteStore) ensureMetadataTable() error {
+func (s *SQLStore) ensureMetadataTable() error {
 	query := `
 		CREATE TABLE IF NOT EXISTS metadata (
 			key TEXT PRIMARY KEY,
@@ -70,7 +100,7 @@ func (s *SQLiteStore) ensureMetadataTable() error {
 	return err
 }
 
-func (s *SQLiteStore) getSchemaVersion() (int, error) {
+func (s *SQLStore) getSchemaVersion() (int, error) {
 	var version int
 	err := s.db.QueryRow("SELECT value FROM metadata WHERE key = 'schema_version'").Scan(&version)
 	if err == sql.ErrNoRows {
@@ -79,16 +109,18 @@ func (s *SQLiteStore) getSchemaVersion() (int, error) {
 	return version, err
 }
 
-func (s *SQLiteStore) setSchemaVersion(version int) error {
-	query := `
-		INSERT OR REPLACE INTO metadata (key, value)
+const schemaVersionUpsert = `
+		INSERT INTO metadata (key, value)
 		VALUES ('schema_version', ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value
 	`
-	_, err := s.db.Exec(query, fmt.Sprintf("%d", version))
+
+func (s *SQLStore) setSchemaVersion(version int) error {
+	_, err := s.db.Exec(schemaVersionUpsert, fmt.Sprintf("%d", version))
 	return err
 }
 
-func (s *SQLiteStore) columnExists(tableName, columnName string) (bool, error) {
+func (s *SQLStore) columnExists(tableName, columnName string) (bool, error) {
 	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", tableName))
 	if err != nil {
 		return false, err
@@ -116,7 +148,7 @@ func (s *SQLiteStore) columnExists(tableName, columnName string) (bool, error) {
 }
 
 // runMigration001_InitialSchema creates the initial database schema for M0.
-func (s *SQLiteStore) runMigration001_InitialSchema() error {
+func (s *SQLStore) runMigration001_InitialSchema() error {
 	schema := `
 	-- Collections table
 	CREATE TABLE IF NOT EXISTS collections (
@@ -247,7 +279,7 @@ func (s *SQLiteStore) runMigration001_InitialSchema() error {
 }
 
 // runMigration002_AddFieldOptions adds sort_field_index and field_options columns to note_types.
-func (s *SQLiteStore) runMigration002_AddFieldOptions() error {
+func (s *SQLStore) runMigration002_AddFieldOptions() error {
 	schema := `
 	ALTER TABLE note_types ADD COLUMN sort_field_index INTEGER DEFAULT 0;
 	ALTER TABLE note_types ADD COLUMN field_options TEXT;
@@ -261,7 +293,7 @@ func (s *SQLiteStore) runMigration002_AddFieldOptions() error {
 	return nil
 }
 
-func (s *SQLiteStore) runMigration003_AddAccountBillingSchema() error {
+func (s *SQLStore) runMigration003_AddAccountBillingSchema() error {
 	schema := `
 	CREATE TABLE IF NOT EXISTS users (
 		id TEXT PRIMARY KEY,
@@ -413,7 +445,7 @@ func (s *SQLiteStore) runMigration003_AddAccountBillingSchema() error {
 	return nil
 }
 
-func (s *SQLiteStore) runMigration014_AddDeckPriorityOrder() error {
+func (s *SQLStore) runMigration014_AddDeckPriorityOrder() error {
 	exists, err := s.columnExists("decks", "priority_order")
 	if err != nil {
 		return err
@@ -439,7 +471,7 @@ func (s *SQLiteStore) runMigration014_AddDeckPriorityOrder() error {
 	return nil
 }
 
-func (s *SQLiteStore) runMigration015_AddFocusSessionProtocolFields() error {
+func (s *SQLStore) runMigration015_AddFocusSessionProtocolFields() error {
 	statements := []string{
 		`ALTER TABLE study_sessions ADD COLUMN protocol TEXT NOT NULL DEFAULT ''`,
 		`ALTER TABLE study_sessions ADD COLUMN target_minutes INTEGER NOT NULL DEFAULT 0`,
@@ -456,7 +488,7 @@ func (s *SQLiteStore) runMigration015_AddFocusSessionProtocolFields() error {
 	return nil
 }
 
-func (s *SQLiteStore) runMigration004_AddOTPAuthSchema() error {
+func (s *SQLStore) runMigration004_AddOTPAuthSchema() error {
 	statements := []string{
 		`ALTER TABLE users ADD COLUMN last_login_at INTEGER`,
 		`ALTER TABLE sessions ADD COLUMN last_seen_at INTEGER`,
@@ -496,7 +528,7 @@ func isIgnorableMigrationError(err error) bool {
 	return strings.Contains(message, "duplicate column name")
 }
 
-func (s *SQLiteStore) runMigration005_AddPhase1FoundationSchema() error {
+func (s *SQLStore) runMigration005_AddPhase1FoundationSchema() error {
 	statements := []string{
 		`ALTER TABLE entitlements ADD COLUMN max_cards_total INTEGER NOT NULL DEFAULT 100`,
 		`
@@ -580,7 +612,7 @@ func (s *SQLiteStore) runMigration005_AddPhase1FoundationSchema() error {
 	return nil
 }
 
-func (s *SQLiteStore) runMigration006_AddPerUserReviewState() error {
+func (s *SQLStore) runMigration006_AddPerUserReviewState() error {
 	schema := `
 		CREATE TABLE IF NOT EXISTS card_review_states (
 			user_id TEXT NOT NULL,
@@ -681,7 +713,7 @@ func (s *SQLiteStore) runMigration006_AddPerUserReviewState() error {
 	return nil
 }
 
-func (s *SQLiteStore) runMigration007_AddStudyGroupVersioningSchema() error {
+func (s *SQLStore) runMigration007_AddStudyGroupVersioningSchema() error {
 	statements := []string{
 		`ALTER TABLE study_group_members ADD COLUMN invite_token TEXT`,
 		`ALTER TABLE study_group_members ADD COLUMN invite_expires_at INTEGER`,
@@ -755,7 +787,7 @@ func (s *SQLiteStore) runMigration007_AddStudyGroupVersioningSchema() error {
 	return nil
 }
 
-func (s *SQLiteStore) runMigration008_RetainRemovedStudyGroupInstalls() error {
+func (s *SQLStore) runMigration008_RetainRemovedStudyGroupInstalls() error {
 	statements := []string{
 		`ALTER TABLE study_group_installs RENAME TO study_group_installs_old`,
 		`
@@ -805,7 +837,7 @@ func (s *SQLiteStore) runMigration008_RetainRemovedStudyGroupInstalls() error {
 	return nil
 }
 
-func (s *SQLiteStore) runMigration009_ScopeNoteTypeIDsByCollection() error {
+func (s *SQLStore) runMigration009_ScopeNoteTypeIDsByCollection() error {
 	statements := []string{
 		`ALTER TABLE revlog RENAME TO revlog_old`,
 		`ALTER TABLE card_review_states RENAME TO card_review_states_old`,
@@ -955,7 +987,7 @@ func (s *SQLiteStore) runMigration009_ScopeNoteTypeIDsByCollection() error {
 	return nil
 }
 
-func (s *SQLiteStore) runMigration010_ExpandMarketplaceFoundationSchema() error {
+func (s *SQLStore) runMigration010_ExpandMarketplaceFoundationSchema() error {
 	statements := []string{
 		`ALTER TABLE marketplace_listings ADD COLUMN category TEXT NOT NULL DEFAULT ''`,
 		`ALTER TABLE marketplace_listings ADD COLUMN tags TEXT NOT NULL DEFAULT '[]'`,
@@ -1027,7 +1059,7 @@ func (s *SQLiteStore) runMigration010_ExpandMarketplaceFoundationSchema() error
 	return nil
 }
 
-func (s *SQLiteStore) runMigration011_AddMarketplaceCommerceSchema() error {
+func (s *SQLStore) runMigration011_AddMarketplaceCommerceSchema() error {
 	statements := []string{
 		`
 		CREATE TABLE IF NOT EXISTS marketplace_creator_accounts (
@@ -1135,7 +1167,7 @@ func (s *SQLiteStore) runMigration011_AddMarketplaceCommerceSchema() error {
 	return nil
 }
 
-func (s *SQLiteStore) runMigration012_AddStudySessionsSchema() error {
+func (s *SQLStore) runMigration012_AddStudySessionsSchema() error {
 	statements := []string{
 		`
 		CREATE TABLE IF NOT EXISTS study_sessions (
@@ -1173,7 +1205,7 @@ func (s *SQLiteStore) runMigration012_AddStudySessionsSchema() error {
 	return nil
 }
 
-func (s *SQLiteStore) runMigration013_AddPhase5AAccountTeamSchema() error {
+func (s *SQLStore) runMigration013_AddPhase5AAccountTeamSchema() error {
 	statements := []string{
 		`ALTER TABLE users ADD COLUMN onboarding INTEGER NOT NULL DEFAULT 0`,
 		`ALTER TABLE organization_members ADD COLUMN invite_token TEXT`,
@@ -1195,7 +1227,7 @@ func (s *SQLiteStore) runMigration013_AddPhase5AAccountTeamSchema() error {
 	return nil
 }
 
-func (s *SQLiteStore) runMigration016_AddSubscriptionBillingFields() error {
+func (s *SQLStore) runMigration016_AddSubscriptionBillingFields() error {
 	statements := []string{
 		`ALTER TABLE subscriptions ADD COLUMN scheduled_plan TEXT`,
 		`ALTER TABLE subscriptions ADD COLUMN provider_subscription_item_id TEXT`,
@@ -1215,3 +1247,528 @@ func (s *SQLiteStore) runMigration016_AddSubscriptionBillingFields() error {
 
 	return nil
 }
+
+func (s *SQLStore) runMigration017_AddCollectionPreferences() error {
+	statements := []string{
+		`
+		CREATE TABLE IF NOT EXISTS collection_preferences (
+			collection_id TEXT PRIMARY KEY,
+			preferences TEXT NOT NULL DEFAULT '{}',
+			updated_at INTEGER NOT NULL,
+			FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE
+		)
+		`,
+	}
+
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil && !isIgnorableMigrationError(err) {
+			return fmt.Errorf("failed to apply collection preferences migration statement: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLStore) runMigration018_AddCalendarFeeds() error {
+	statements := []string{
+		`
+		CREATE TABLE IF NOT EXISTS calendar_feeds (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			collection_id TEXT NOT NULL,
+			token TEXT NOT NULL UNIQUE,
+			created_at INTEGER NOT NULL,
+			last_accessed_at INTEGER,
+			UNIQUE(user_id, collection_id),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE
+		)
+		`,
+	}
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil && !isIgnorableMigrationError(err) {
+			return fmt.Errorf("failed to apply calendar feeds migration statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// runMigration019_AddRevlogFSRSDetail keeps the FSRS memory state and interval data of
+// each review so the optimizer and retention stats can replay history.
+func (s *SQLStore) runMigration019_AddRevlogFSRSDetail() error {
+	statements := []string{
+		`ALTER TABLE revlog ADD COLUMN stability REAL`,
+		`ALTER TABLE revlog ADD COLUMN difficulty REAL`,
+		`ALTER TABLE revlog ADD COLUMN elapsed_days INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE revlog ADD COLUMN scheduled_days INTEGER NOT NULL DEFAULT 0`,
+	}
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil && !isIgnorableMigrationError(err) {
+			return fmt.Errorf("failed to apply revlog FSRS detail migration statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// runMigration020_AddRevlogReviewKind tags each revlog entry with how the review happened;
+// existing entries are classified by the state the card was in.
+func (s *SQLStore) runMigration020_AddRevlogReviewKind() error {
+	statements := []string{
+		`ALTER TABLE revlog ADD COLUMN kind TEXT NOT NULL DEFAULT 'review'`,
+		// fsrs states: 0 new, 1 learning, 2 review, 3 relearning.
+		`UPDATE revlog SET kind = CASE COALESCE(state, 0)
+			WHEN 2 THEN 'review'
+			WHEN 3 THEN 'relearn'
+			ELSE 'learn'
+		END`,
+		`CREATE INDEX IF NOT EXISTS idx_revlog_kind_reviewed ON revlog(kind, reviewed_at)`,
+	}
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil && !isIgnorableMigrationError(err) {
+			return fmt.Errorf("failed to apply revlog review kind migration statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// runMigration021_AddChangeLog creates the change log behind /api/changes and the
+// triggers that feed it. Tables rebuilt by later migrations must recreate their triggers.
+func (s *SQLStore) runMigration021_AddChangeLog() error {
+	statements := []string{
+		`
+		CREATE TABLE IF NOT EXISTS change_log (
+			usn INTEGER PRIMARY KEY AUTOINCREMENT,
+			collection_id TEXT,
+			user_id TEXT,
+			entity_type TEXT NOT NULL,
+			entity_id TEXT NOT NULL,
+			op TEXT NOT NULL,
+			changed_at INTEGER NOT NULL
+		)
+		`,
+		`CREATE INDEX IF NOT EXISTS idx_change_log_collection_usn ON change_log(collection_id, usn)`,
+	}
+	statements = append(statements, changeLogTriggerStatements()...)
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil && !isIgnorableMigrationError(err) {
+			return fmt.Errorf("failed to apply change log migration statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// runMigration022_AddNotesFullTextIndex builds notes_fts over note field values, using
+// FTS5 when this SQLite has it and FTS4 otherwise.
+func (s *SQLStore) runMigration022_AddNotesFullTextIndex() error {
+	if _, err := s.db.Exec(notesFullTextCreateStatement(fullTextModuleFTS5)); err != nil {
+		if !strings.Contains(strings.ToLower(err.Error()), "no such module") {
+			return fmt.Errorf("failed to create notes full-text index: %w", err)
+		}
+		if _, err := s.db.Exec(notesFullTextCreateStatement(fullTextModuleFTS4)); err != nil {
+			return fmt.Errorf("failed to create notes full-text index: %w", err)
+		}
+	}
+	for _, statement := range notesFullTextStatements() {
+		if _, err := s.db.Exec(statement); err != nil && !isIgnorableMigrationError(err) {
+			return fmt.Errorf("failed to apply notes full-text migration statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// runMigration023_AddImageOcclusionNoteType gives existing collections the built-in
+// Image Occlusion note type; new collections get it from builtins().
+func (s *SQLStore) runMigration023_AddImageOcclusionNoteType() error {
+	rows, err := s.db.Query(`SELECT id FROM collections`)
+	if err != nil {
+		return fmt.Errorf("failed to list collections: %w", err)
+	}
+	var collectionIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to list collections: %w", err)
+		}
+		collectionIDs = append(collectionIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list collections: %w", err)
+	}
+
+	nt := imageOcclusionBuiltin()
+	for _, collectionID := range collectionIDs {
+		if _, err := s.GetNoteType(context.Background(), collectionID, nt.Name); err == nil {
+			continue
+		}
+		if err := s.CreateNoteType(context.Background(), collectionID, &nt); err != nil && !isIgnorableMigrationError(err) {
+			return fmt.Errorf("failed to add image occlusion note type to collection %s: %w", collectionID, err)
+		}
+	}
+	return nil
+}
+
+// runMigration024_AddMediaUnusedSince records when the media sweep first found a file
+// unreferenced, so it can be deleted once the grace period has passed.
+func (s *SQLStore) runMigration024_AddMediaUnusedSince() error {
+	if _, err := s.db.Exec(`ALTER TABLE media ADD COLUMN unused_since INTEGER`); err != nil && !isIgnorableMigrationError(err) {
+		return fmt.Errorf("failed to add media unused_since: %w", err)
+	}
+	return nil
+}
+
+// runMigration025_AddMediaSHA256 stores each media file's SHA-256 so media sync can
+// compare files without reading them. The change-log trigger is suspended during the
+// backfill, since computing a hash does not change the file.
+func (s *SQLStore) runMigration025_AddMediaSHA256() error {
+	if _, err := s.db.Exec(`ALTER TABLE media ADD COLUMN sha256 TEXT`); err != nil && !isIgnorableMigrationError(err) {
+		return fmt.Errorf("failed to add media sha256: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DROP TRIGGER IF EXISTS change_log_media_update`); err != nil {
+		return fmt.Errorf("failed to suspend media change log: %w", err)
+	}
+	rows, err := tx.Query(`SELECT id, data FROM media WHERE sha256 IS NULL`)
+	if err != nil {
+		return fmt.Errorf("failed to read media: %w", err)
+	}
+	hashes := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read media: %w", err)
+		}
+		hashes[id] = mediaSHA256(data)
+	}
+	rows.Close()
+	for id, hash := range hashes {
+		if _, err := tx.Exec(`UPDATE media SET sha256 = ? WHERE id = ?`, hash, id); err != nil {
+			return fmt.Errorf("failed to backfill media sha256: %w", err)
+		}
+	}
+	for _, statement := range changeLogTriggerStatements() {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to restore media change log: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// runMigration026_AddOperationLog adds the append-only operation log used by oplog sync,
+// and the map from the operations that created entities to the entities' IDs.
+func (s *SQLStore) runMigration026_AddOperationLog() error {
+	statements := []string{
+		`
+		CREATE TABLE IF NOT EXISTS oplog (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			collection_id TEXT NOT NULL,
+			op_id TEXT NOT NULL,
+			actor TEXT NOT NULL,
+			lamport INTEGER NOT NULL,
+			kind TEXT NOT NULL,
+			target TEXT NOT NULL,
+			register TEXT NOT NULL DEFAULT '',
+			field TEXT NOT NULL DEFAULT '',
+			value TEXT NOT NULL DEFAULT '',
+			user_id TEXT,
+			received_at INTEGER NOT NULL,
+			UNIQUE(collection_id, op_id)
+		)
+		`,
+		`CREATE INDEX IF NOT EXISTS idx_oplog_target ON oplog(collection_id, target, register)`,
+		`
+		CREATE TABLE IF NOT EXISTS oplog_refs (
+			collection_id TEXT NOT NULL,
+			ref TEXT NOT NULL,
+			entity_type TEXT NOT NULL,
+			entity_id INTEGER NOT NULL,
+			PRIMARY KEY (collection_id, ref)
+		)
+		`,
+		`CREATE INDEX IF NOT EXISTS idx_oplog_refs_entity ON oplog_refs(collection_id, entity_type, entity_id)`,
+	}
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil && !isIgnorableMigrationError(err) {
+			return fmt.Errorf("failed to apply operation log migration statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// runMigration027_AddDeckOptionsCollection scopes deck options presets to a collection so
+// presets no deck uses yet can still be listed. Existing presets take the collection of
+// a deck using them.
+func (s *SQLStore) runMigration027_AddDeckOptionsCollection() error {
+	statements := []string{
+		`ALTER TABLE deck_options ADD COLUMN collection_id TEXT`,
+		`UPDATE deck_options SET collection_id = (SELECT collection_id FROM decks WHERE options_id = deck_options.id LIMIT 1) WHERE collection_id IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_deck_options_collection ON deck_options(collection_id)`,
+	}
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil && !isIgnorableMigrationError(err) {
+			return fmt.Errorf("failed to apply deck options collection migration statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// runMigration028_AddLearningStep records which learning step each card is on, for
+// cards and for each user's review state.
+func (s *SQLStore) runMigration028_AddLearningStep() error {
+	for _, table := range []string{"cards", "card_review_states"} {
+		if _, err := s.db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN learning_step INTEGER NOT NULL DEFAULT 0`); err != nil && !isIgnorableMigrationError(err) {
+			return fmt.Errorf("failed to add %s learning_step: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// runMigration029_AddLapseOptions adds relearning steps and lapse intervals to deck
+// options presets, and records the state each review left its card in.
+func (s *SQLStore) runMigration029_AddLapseOptions() error {
+	statements := []string{
+		`ALTER TABLE deck_options ADD COLUMN relearning_steps TEXT NOT NULL DEFAULT '[10]'`,
+		`ALTER TABLE deck_options ADD COLUMN minimum_interval INTEGER NOT NULL DEFAULT 1`,
+		`ALTER TABLE deck_options ADD COLUMN new_interval_percent INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE revlog ADD COLUMN next_state INTEGER`,
+	}
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil && !isIgnorableMigrationError(err) {
+			return fmt.Errorf("failed to apply lapse options migration statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// runMigration030_AddLeechOptions adds the leech threshold and action to deck options
+// presets, defaulting to Anki's: tag the note after 8 lapses.
+func (s *SQLStore) runMigration030_AddLeechOptions() error {
+	statements := []string{
+		`ALTER TABLE deck_options ADD COLUMN leech_threshold INTEGER NOT NULL DEFAULT 8`,
+		`ALTER TABLE deck_options ADD COLUMN leech_action TEXT NOT NULL DEFAULT 'tag'`,
+	}
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil && !isIgnorableMigrationError(err) {
+			return fmt.Errorf("failed to apply leech options migration statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// runMigration031_AddCardBuriedAt records when a card was buried, for cards and for each
+// user's review state; 0 means not buried.
+func (s *SQLStore) runMigration031_AddCardBuriedAt() error {
+	for _, table := range []string{"cards", "card_review_states"} {
+		statements := []string{
+			`ALTER TABLE ` + table + ` ADD COLUMN buried_at INTEGER NOT NULL DEFAULT 0`,
+			`CREATE INDEX IF NOT EXISTS idx_` + table + `_buried_at ON ` + table + `(buried_at) WHERE buried_at > 0`,
+		}
+		for _, statement := range statements {
+			if _, err := s.db.Exec(statement); err != nil && !isIgnorableMigrationError(err) {
+				return fmt.Errorf("failed to add %s buried_at: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// runMigration032_AddSM2Scheduler lets a deck options preset pick the SM-2 scheduler
+// instead of FSRS, with Anki's SM-2 defaults, and gives cards and review states an SM-2
+// ease factor; 0 means SM-2 has not scheduled the card yet.
+func (s *SQLStore) runMigration032_AddSM2Scheduler() error {
+	statements := []string{
+		`ALTER TABLE deck_options ADD COLUMN scheduler TEXT NOT NULL DEFAULT 'fsrs'`,
+		`ALTER TABLE deck_options ADD COLUMN starting_ease INTEGER NOT NULL DEFAULT 2500`,
+		`ALTER TABLE deck_options ADD COLUMN easy_bonus INTEGER NOT NULL DEFAULT 130`,
+		`ALTER TABLE deck_options ADD COLUMN interval_modifier INTEGER NOT NULL DEFAULT 100`,
+		`ALTER TABLE cards ADD COLUMN ease_factor INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE card_review_states ADD COLUMN ease_factor INTEGER NOT NULL DEFAULT 0`,
+	}
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil && !isIgnorableMigrationError(err) {
+			return fmt.Errorf("failed to apply SM-2 scheduler migration statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// runMigration033_AddReviewOrder adds the order reviews are shown in to deck options
+// presets, keeping the existing oldest-due-first order.
+func (s *SQLStore) runMigration033_AddReviewOrder() error {
+	if _, err := s.db.Exec(`ALTER TABLE deck_options ADD COLUMN review_order TEXT NOT NULL DEFAULT 'due'`); err != nil && !isIgnorableMigrationError(err) {
+		return fmt.Errorf("failed to add deck_options review_order: %w", err)
+	}
+	return nil
+}
+
+// runMigration034_AddFilteredDecks stores the search, limit, and order filtered decks
+// are built from, and lets cards remember the home deck they return to and their place
+// in the filtered deck's queue.
+func (s *SQLStore) runMigration034_AddFilteredDecks() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS filtered_decks (
+			deck_id INTEGER PRIMARY KEY REFERENCES decks(id) ON DELETE CASCADE,
+			query TEXT NOT NULL,
+			card_limit INTEGER NOT NULL DEFAULT 100,
+			card_order TEXT NOT NULL DEFAULT 'due',
+			reschedule INTEGER NOT NULL DEFAULT 1,
+			built_at INTEGER NOT NULL DEFAULT 0
+		)`,
+		`ALTER TABLE cards ADD COLUMN original_deck_id INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE cards ADD COLUMN filtered_position INTEGER NOT NULL DEFAULT 0`,
+		`CREATE INDEX IF NOT EXISTS idx_cards_original_deck_id ON cards(original_deck_id) WHERE original_deck_id > 0`,
+	}
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil && !isIgnorableMigrationError(err) {
+			return fmt.Errorf("failed to apply filtered decks migration statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// runMigration035_AddStudySessionTotalTime records the answer time spent in a study
+// session run by the server.
+func (s *SQLStore) runMigration035_AddStudySessionTotalTime() error {
+	if _, err := s.db.Exec(`ALTER TABLE study_sessions ADD COLUMN total_time_ms INTEGER NOT NULL DEFAULT 0`); err != nil && !isIgnorableMigrationError(err) {
+		return fmt.Errorf("failed to add study_sessions total_time_ms: %w", err)
+	}
+	return nil
+}
+
+// runMigration036_AddStudyGroupLeaderboardOptIn records which study group members have
+// chosen to appear on the group's leaderboard.
+func (s *SQLStore) runMigration036_AddStudyGroupLeaderboardOptIn() error {
+	if _, err := s.db.Exec(`ALTER TABLE study_group_members ADD COLUMN leaderboard_opt_in INTEGER NOT NULL DEFAULT 0`); err != nil && !isIgnorableMigrationError(err) {
+		return fmt.Errorf("failed to add study_group_members leaderboard_opt_in: %w", err)
+	}
+	return nil
+}
+
+// runMigration037_AddUserPasswords stores bcrypt password hashes for password sign-in,
+// with the failed attempts that lock it for a while.
+func (s *SQLStore) runMigration037_AddUserPasswords() error {
+	statements := []string{
+		`ALTER TABLE users ADD COLUMN password_hash TEXT`,
+		`ALTER TABLE users ADD COLUMN failed_login_attempts INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE users ADD COLUMN login_locked_until INTEGER NOT NULL DEFAULT 0`,
+	}
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil && !isIgnorableMigrationError(err) {
+			return fmt.Errorf("failed to apply user passwords migration statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// runMigration038_AddAPITokens stores personal access tokens by hash, with the scope
+// that limits what they may call.
+func (s *SQLStore) runMigration038_AddAPITokens() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS api_tokens (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			workspace_id TEXT REFERENCES workspaces(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			token_prefix TEXT NOT NULL,
+			scope TEXT NOT NULL DEFAULT 'full',
+			created_at INTEGER NOT NULL,
+			last_used_at INTEGER,
+			expires_at INTEGER,
+			revoked_at INTEGER
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens(user_id)`,
+	}
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil && !isIgnorableMigrationError(err) {
+			return fmt.Errorf("failed to apply api tokens migration statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// runMigration039_AddDeckGrants records the users a deck's owner has shared it with and
+// the role each was given. A grant made before the grantee signs up is matched by email.
+func (s *SQLStore) runMigration039_AddDeckGrants() error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS deck_grants (
+			id TEXT PRIMARY KEY,
+			deck_id INTEGER NOT NULL REFERENCES decks(id) ON DELETE CASCADE,
+			owner_user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			email TEXT NOT NULL,
+			user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+			role TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			UNIQUE(deck_id, email)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_deck_grants_email ON deck_grants(email)`,
+		`CREATE INDEX IF NOT EXISTS idx_deck_grants_user_id ON deck_grants(user_id)`,
+	}
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil && !isIgnorableMigrationError(err) {
+			return fmt.Errorf("failed to apply deck grants migration statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// runMigration040_AddCollectionOwners records who created a collection over the API.
+// Collections that belong to a workspace have no owner.
+func (s *SQLStore) runMigration040_AddCollectionOwners() error {
+	statements := []string{
+		`ALTER TABLE collections ADD COLUMN owner_user_id TEXT REFERENCES users(id) ON DELETE SET NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_collections_owner_user_id ON collections(owner_user_id)`,
+	}
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil && !isIgnorableMigrationError(err) {
+			return fmt.Errorf("failed to apply collection owners migration statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// runMigration041_HashCalendarFeedTokens replaces each calendar feed's token with its
+// SHA-256, as API tokens are stored, so a copy of the database does not expose working
+// feed URLs. Existing URLs keep working: lookups hash the token they are given.
+func (s *SQLStore) runMigration041_HashCalendarFeedTokens() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, token FROM calendar_feeds`)
+	if err != nil {
+		return fmt.Errorf("failed to read calendar feed tokens: %w", err)
+	}
+	hashes := map[string]string{}
+	for rows.Next() {
+		var id, token string
+		if err := rows.Scan(&id, &token); err != nil {
+			rows.Close()
+			return err
+		}
+		hashes[id] = hashCalendarFeedToken(token)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, hash := range hashes {
+		if _, err := tx.Exec(`UPDATE calendar_feeds SET token = ? WHERE id = ?`, hash, id); err != nil {
+			return fmt.Errorf("failed to hash calendar feed token: %w", err)
+		}
+	}
+	if _, err := tx.Exec(`ALTER TABLE calendar_feeds RENAME COLUMN token TO token_hash`); err != nil {
+		return fmt.Errorf("failed to rename calendar feed token column: %w", err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// Mnemosyne 2 keeps its collection in an SQLite database (default.db) with every
+// repetition in a log table, so cards arrive with their full history. Mnemosyne 1 XML
+// exports only hold each card's current schedule, which is mapped onto FSRS state.
+//
+// Front-to-back and both-ways facts become Basic and Basic (and reversed card) notes.
+// Cards of other card types (vocabulary, cloze, custom) become one Basic note per card
+// from the question and answer Mnemosyne rendered for them.
+
+const (
+	mnemosyneRepetitionEvent = 9
+	// Mnemosyne's easiness ranges from 1.3 upward; 3.0 and above is treated as easiest.
+	mnemosyneHardestEase  = 1.3
+	mnemosyneEasiestEase  = 3.0
+	mnemosyneUntaggedName = "__UNTAGGED__"
+)
+
+func parseMnemosyneImport(data []byte, format string, opts importParseOptions) ([]importNormalizedNote, error) {
+	switch format {
+	case "db":
+		return parseMnemosyneDatabase(data, opts)
+	case "xml":
+		return parseMnemosyneXML(data, opts)
+	default:
+		return nil, fmt.Errorf("unsupported Mnemosyne format %q: upload default.db or an XML export", format)
+	}
+}
+
+// mnemosyneRating maps Mnemosyne's 0-5 grades onto FSRS ratings. Grades 0 and 1 are
+// failures; 2 to 5 are passes from "with difficulty" to "easy".
+func mnemosyneRating(grade int) (fsrs.Rating, bool) {
+	switch {
+	case grade < 0 || grade > 5:
+		return 0, false
+	case grade <= 1:
+		return fsrs.Again, true
+	case grade == 2:
+		return fsrs.Hard, true
+	case grade == 5:
+		return fsrs.Easy, true
+	default:
+		return fsrs.Good, true
+	}
+}
+
+func mnemosyneTags(raw string) []string {
+	var tags []string
+	for _, tag := range strings.Split(raw, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == mnemosyneUntaggedName {
+			continue
+		}
+		tags = append(tags, strings.Join(strings.Fields(tag), "_"))
+	}
+	return dedupeTags(tags)
+}
+
+type mnemosyneCard struct {
+	ID          string
+	FactID      int64
+	CardType    string
+	FactView    string
+	Question    string
+	Answer      string
+	Tags        string
+	Grade       int
+	Easiness    float64
+	AcqReps     int
+	RetReps     int
+	Lapses      int
+	LastRep     int64
+	NextRep     int64
+	Repetitions []importReview
+}
+
+// scheduling returns the card's progress: its logged repetitions, or a summary of its
+// schedule when the log has been pruned.
+func (c mnemosyneCard) scheduling() (importScheduling, bool) {
+	if len(c.Repetitions) > 0 {
+		return importScheduling{Reviews: c.Repetitions}, true
+	}
+	if c.Grade < 0 || c.LastRep <= 0 {
+		return importScheduling{}, false
+	}
+	return importScheduling{Summary: &importSchedulingSummary{
+		LastReview:   time.Unix(c.LastRep, 0),
+		Due:          time.Unix(c.NextRep, 0),
+		IntervalDays: float64(c.NextRep-c.LastRep) / 86400,
+		Reps:         c.AcqReps + c.RetReps,
+		Lapses:       c.Lapses,
+		Difficulty:   difficultyFromEase(c.Easiness, mnemosyneHardestEase, mnemosyneEasiestEase),
+	}}, true
+}
+
+func parseMnemosyneDatabase(data []byte, opts importParseOptions) ([]importNormalizedNote, error) {
+	tempDir, err := os.MkdirTemp("", "microdote-mnemosyne-import-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	tempDBPath := filepath.Join(tempDir, "default.db")
+	if err := os.WriteFile(tempDBPath, data, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write temp Mnemosyne database: %w", err)
+	}
+	db, err := sql.Open("sqlite3", tempDBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Mnemosyne database: %w", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT id, _fact_id, card_type_id, fact_view_id, COALESCE(question, ''), COALESCE(answer, ''), COALESCE(tags, ''),
+			COALESCE(grade, -1), COALESCE(easiness, 0), COALESCE(acq_reps, 0), COALESCE(ret_reps, 0), COALESCE(lapses, 0),
+			COALESCE(last_rep, -1), COALESCE(next_rep, -1)
+		FROM cards
+		ORDER BY _fact_id, fact_view_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("not a Mnemosyne 2 database: %w", err)
+	}
+	var cards []*mnemosyneCard
+	byID := map[string]*mnemosyneCard{}
+	for rows.Next() {
+		card := &mnemosyneCard{}
+		if err := rows.Scan(&card.ID, &card.FactID, &card.CardType, &card.FactView, &card.Question, &card.Answer, &card.Tags,
+			&card.Grade, &card.Easiness, &card.AcqReps, &card.RetReps, &card.Lapses, &card.LastRep, &card.NextRep); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read Mnemosyne cards: %w", err)
+		}
+		cards = append(cards, card)
+		byID[card.ID] = card
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read Mnemosyne cards: %w", err)
+	}
+
+	factFields := map[int64]map[string]string{}
+	fieldRows, err := db.Query(`SELECT _fact_id, key, value FROM data_for_fact`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Mnemosyne facts: %w", err)
+	}
+	for fieldRows.Next() {
+		var factID int64
+		var key, value string
+		if err := fieldRows.Scan(&factID, &key, &value); err != nil {
+			fieldRows.Close()
+			return nil, fmt.Errorf("failed to read Mnemosyne facts: %w", err)
+		}
+		if factFields[factID] == nil {
+			factFields[factID] = map[string]string{}
+		}
+		factFields[factID][key] = value
+	}
+	fieldRows.Close()
+
+	// The log table is optional: users can prune it, and card summaries cover that case.
+	if logRows, err := db.Query(`
+		SELECT object_id, timestamp, grade, COALESCE(thinking_time, 0)
+		FROM log
+		WHERE event_type = ?
+		ORDER BY timestamp
+	`, mnemosyneRepetitionEvent); err == nil {
+		for logRows.Next() {
+			var cardID string
+			var timestamp int64
+			var grade, thinkingSeconds int
+			if err := logRows.Scan(&cardID, &timestamp, &grade, &thinkingSeconds); err != nil {
+				logRows.Close()
+				return nil, fmt.Errorf("failed to read Mnemosyne log: %w", err)
+			}
+			rating, ok := mnemosyneRating(grade)
+			card := byID[cardID]
+			if !ok || card == nil {
+				continue
+			}
+			card.Repetitions = append(card.Repetitions, importReview{At: time.Unix(timestamp, 0), Rating: rating, TimeTakenMs: thinkingSeconds * 1000})
+		}
+		logRows.Close()
+	}
+
+	deckName := firstNonEmpty(opts.DefaultDeckName, "Default")
+	var out []importNormalizedNote
+	factNotes := map[int64]int{}
+	for _, card := range cards {
+		baseType, _, _ := strings.Cut(card.CardType, "::")
+		fields := factFields[card.FactID]
+		if (baseType == "1" || baseType == "2") && strings.TrimSpace(fields["f"]) != "" {
+			idx, seen := factNotes[card.FactID]
+			if !seen {
+				noteType := NoteTypeName("Basic")
+				if baseType == "2" {
+					noteType = "Basic (and reversed card)"
+				}
+				out = append(out, importNormalizedNote{
+					DeckName:   deckName,
+					NoteType:   noteType,
+					Fields:     map[string]string{"Front": fields["f"], "Back": fields["b"]},
+					Tags:       mnemosyneTags(card.Tags),
+					Scheduling: map[int]importScheduling{},
+				})
+				idx = len(out) - 1
+				factNotes[card.FactID] = idx
+			}
+			position := 0
+			if strings.HasSuffix(card.FactView, ".2") {
+				position = 1
+			}
+			if scheduling, ok := card.scheduling(); ok {
+				out[idx].Scheduling[position] = scheduling
+			}
+			continue
+		}
+
+		if strings.TrimSpace(card.Question) == "" {
+			continue
+		}
+		note := importNormalizedNote{
+			DeckName: deckName,
+			NoteType: "Basic",
+			Fields:   map[string]string{"Front": card.Question, "Back": card.Answer},
+			Tags:     mnemosyneTags(card.Tags),
+		}
+		if scheduling, ok := card.scheduling(); ok {
+			note.Scheduling = map[int]importScheduling{0: scheduling}
+		}
+		out = append(out, note)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no cards found in Mnemosyne database")
+	}
+	return out, nil
+}
+
+type mnemosyneXMLExport struct {
+	XMLName     xml.Name           `xml:"mnemosyne"`
+	TimeOfStart int64              `xml:"time_of_start,attr"`
+	Items       []mnemosyneXMLItem `xml:"item"`
+}
+
+type mnemosyneXMLItem struct {
+	Unseen   int     `xml:"u,attr"`
+	Grade    string  `xml:"gr,attr"`
+	Easiness float64 `xml:"e,attr"`
+	AcqReps  int     `xml:"ac_rp,attr"`
+	RetReps  int     `xml:"rt_rp,attr"`
+	Lapses   int     `xml:"lps,attr"`
+	LastRep  int64   `xml:"l_rp,attr"` // days since time_of_start
+	NextRep  int64   `xml:"n_rp,attr"` // days since time_of_start
+	Category string  `xml:"cat"`
+	Question string  `xml:"Q"`
+	Answer   string  `xml:"A"`
+}
+
+func parseMnemosyneXML(data []byte, opts importParseOptions) ([]importNormalizedNote, error) {
+	var export mnemosyneXMLExport
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = xmlCharsetReader
+	if err := decoder.Decode(&export); err != nil {
+		return nil, fmt.Errorf("invalid Mnemosyne XML: %w", err)
+	}
+	start := time.Unix(export.TimeOfStart, 0).UTC()
+
+	var out []importNormalizedNote
+	for _, item := range export.Items {
+		if strings.TrimSpace(item.Question) == "" {
+			continue
+		}
+		note := importNormalizedNote{
+			DeckName: firstNonEmpty(strings.TrimSpace(item.Category), opts.DefaultDeckName, "Default"),
+			NoteType: "Basic",
+			Fields:   map[string]string{"Front": item.Question, "Back": item.Answer},
+		}
+		grade, err := strconv.Atoi(strings.TrimSpace(item.Grade))
+		if item.Unseen == 0 && err == nil && grade >= 0 && export.TimeOfStart > 0 && item.AcqReps+item.RetReps > 0 {
+			note.Scheduling = map[int]importScheduling{0: {Summary: &importSchedulingSummary{
+				LastReview:   start.AddDate(0, 0, int(item.LastRep)),
+				Due:          start.AddDate(0, 0, int(item.NextRep)),
+				IntervalDays: float64(item.NextRep - item.LastRep),
+				Reps:         item.AcqReps + item.RetReps,
+				Lapses:       item.Lapses,
+				Difficulty:   difficultyFromEase(item.Easiness, mnemosyneHardestEase, mnemosyneEasiestEase),
+			}}}
+		}
+		out = append(out, note)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no items found in Mnemosyne XML")
+	}
+	return out, nil
+}
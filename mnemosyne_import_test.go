@@ -0,0 +1,101 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func buildMnemosyneDatabase(t *testing.T) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "default.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	statements := []string{
+		`CREATE TABLE cards(_id INTEGER PRIMARY KEY, id TEXT, card_type_id TEXT, _fact_id INTEGER, fact_view_id TEXT, question TEXT, answer TEXT, tags TEXT,
+			grade INTEGER, next_rep INTEGER, last_rep INTEGER, easiness REAL, acq_reps INTEGER, ret_reps INTEGER, lapses INTEGER)`,
+		`CREATE TABLE data_for_fact(_fact_id INTEGER, key TEXT, value TEXT)`,
+		`CREATE TABLE log(_id INTEGER PRIMARY KEY AUTOINCREMENT, event_type INTEGER, timestamp INTEGER, object_id TEXT, grade INTEGER, thinking_time INTEGER)`,
+		`INSERT INTO data_for_fact VALUES (1, 'f', 'perro'), (1, 'b', 'dog'), (2, 'f', 'gato'), (2, 'b', 'cat'), (3, 'f', 'casa')`,
+		`INSERT INTO cards VALUES
+			(1, 'c1', '1', 1, '1.1', 'perro', 'dog', 'Spanish, Animals', 4, 1700864000, 1700000000, 2.5, 1, 2, 0),
+			(2, 'c2', '2', 2, '2.1', 'gato', 'cat', '__UNTAGGED__', -1, -1, -1, 2.5, 0, 0, 0),
+			(3, 'c3', '2', 2, '2.2', 'cat', 'gato', '__UNTAGGED__', 3, 1700500000, 1700100000, 2.2, 1, 0, 0),
+			(4, 'c4', '3', 3, '3.1', 'casa', 'house', 'Spanish', -1, -1, -1, 2.5, 0, 0, 0)`,
+		`INSERT INTO log (event_type, timestamp, object_id, grade, thinking_time) VALUES
+			(9, 1699000000, 'c1', 1, 4), (9, 1699086400, 'c1', 4, 3), (9, 1700000000, 'c1', 5, 2), (6, 1699000000, 'c1', -1, 0)`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("failed to build database: %v", err)
+		}
+	}
+	db.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read database: %v", err)
+	}
+	return data
+}
+
+func TestParseMnemosyneDatabase(t *testing.T) {
+	parsed, err := parseImportData(buildMnemosyneDatabase(t), importParseOptions{Filename: "default.db", DefaultDeckName: "Default"})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if parsed.Source != "mnemosyne" || parsed.Format != "db" || len(parsed.Notes) != 3 {
+		t.Fatalf("unexpected result %s/%s with %d notes", parsed.Source, parsed.Format, len(parsed.Notes))
+	}
+
+	basic := parsed.Notes[0]
+	if basic.NoteType != "Basic" || basic.Fields["Front"] != "perro" || len(basic.Tags) != 2 || basic.Tags[0] != "Spanish" {
+		t.Fatalf("unexpected front-to-back note %+v", basic)
+	}
+	if reviews := basic.Scheduling[0].Reviews; len(reviews) != 3 || reviews[0].Rating != fsrs.Again || reviews[2].Rating != fsrs.Easy || reviews[1].TimeTakenMs != 3000 {
+		t.Fatalf("expected three replayable repetitions, got %+v", basic.Scheduling)
+	}
+
+	reversed := parsed.Notes[1]
+	if reversed.NoteType != "Basic (and reversed card)" || len(reversed.Tags) != 0 {
+		t.Fatalf("unexpected both-ways note %+v", reversed)
+	}
+	if _, ok := reversed.Scheduling[0]; ok {
+		t.Fatalf("expected the unseen forward card to stay new")
+	}
+	if summary := reversed.Scheduling[1].Summary; summary == nil || summary.Reps != 1 || !summary.Due.Equal(time.Unix(1700500000, 0)) {
+		t.Fatalf("expected a summary for the reverse card, got %+v", reversed.Scheduling)
+	}
+
+	if vocab := parsed.Notes[2]; vocab.Fields["Front"] != "casa" || vocab.Fields["Back"] != "house" || vocab.Scheduling != nil {
+		t.Fatalf("unexpected vocabulary note %+v", vocab)
+	}
+}
+
+func TestParseMnemosyneXML(t *testing.T) {
+	mnemosyneXML := `<?xml version="1.0" encoding="UTF-8"?>
+<mnemosyne core_version="1" time_of_start="1672531200">
+<category active="1"><name>Capitals</name></category>
+<item id="a1" gr="4" e="2.8" ac_rp="1" rt_rp="3" lps="1" l_rp="100" n_rp="130"><cat>Capitals</cat><Q>France</Q><A>Paris</A></item>
+<item id="a2" u="1" gr="0" e="2.5" ac_rp="0" rt_rp="0" lps="0" l_rp="0" n_rp="0"><cat>Capitals</cat><Q>Peru</Q><A>Lima</A></item>
+</mnemosyne>`
+	parsed, err := parseImportData([]byte(mnemosyneXML), importParseOptions{Filename: "export.xml", DefaultDeckName: "Default"})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if parsed.Source != "mnemosyne" || len(parsed.Notes) != 2 || parsed.Notes[0].DeckName != "Capitals" {
+		t.Fatalf("unexpected Mnemosyne XML result %+v", parsed)
+	}
+	summary := parsed.Notes[0].Scheduling[0].Summary
+	if summary == nil || summary.Reps != 4 || summary.Lapses != 1 || summary.IntervalDays != 30 || !summary.LastReview.Equal(time.Date(2023, 4, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected Mnemosyne summary %+v", summary)
+	}
+	if parsed.Notes[1].Scheduling != nil {
+		t.Fatalf("expected the unseen item to stay new")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// IntervalPreview is how long a card would wait after one answer.
+type IntervalPreview struct {
+	Seconds int64  `json:"seconds"`
+	Label   string `json:"label"` // e.g. "10m", "3d", "1.5mo"
+}
+
+// AnswerIntervals previews the interval each answer button would give a card, so clients
+// can label the buttons as Anki does.
+type AnswerIntervals struct {
+	Again IntervalPreview `json:"again"`
+	Hard  IntervalPreview `json:"hard"`
+	Good  IntervalPreview `json:"good"`
+	Easy  IntervalPreview `json:"easy"`
+}
+
+// formatIntervalLabel renders an interval in Anki's short style.
+func formatIntervalLabel(d time.Duration) string {
+	days := d.Hours() / 24
+	trim := func(value float64, unit string) string {
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", value), ".0") + unit
+	}
+	switch {
+	case d < time.Minute:
+		return "<1m"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(math.Round(d.Minutes())))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(math.Round(d.Hours())))
+	case days < 30:
+		return fmt.Sprintf("%dd", int(math.Round(days)))
+	case days < 365:
+		return trim(days/30, "mo")
+	default:
+		return trim(days/365, "y")
+	}
+}
+
+// answerIntervals previews every answer to card at now under schedule, exactly as
+// answering would schedule it.
+func answerIntervals(params fsrs.Parameters, schedule learningSchedule, card *Card, now time.Time) *AnswerIntervals {
+	records := fsrs.NewFSRS(params).Repeat(card.SRS, now)
+	preview := func(rating fsrs.Rating) IntervalPreview {
+		info, _, _ := schedule.answer(params, card, records[rating], rating, now)
+		wait := max(info.Card.Due.Sub(now), 0)
+		return IntervalPreview{Seconds: int64(wait.Seconds()), Label: formatIntervalLabel(wait)}
+	}
+	return &AnswerIntervals{
+		Again: preview(fsrs.Again),
+		Hard:  preview(fsrs.Hard),
+		Good:  preview(fsrs.Good),
+		Easy:  preview(fsrs.Easy),
+	}
+}
+
+// attachNextIntervals fills in the answer previews of cards served for study.
+func (h *APIHandler) attachNextIntervals(ctx context.Context, col *Collection, cards []*Card, now time.Time) error {
+	schedules := map[int64]learningSchedule{}
+	for _, card := range cards {
+		deckID := card.homeDeckID()
+		schedule, ok := schedules[deckID]
+		if !ok {
+			var err error
+			if schedule, err = h.store.learningScheduleForDeck(ctx, deckID); err != nil {
+				return err
+			}
+			schedules[deckID] = schedule
+		}
+		card.NextIntervals = answerIntervals(col.Params, schedule, card, now)
+	}
+	return nil
+}
+
+// GetCardNextIntervals handles GET /api/cards/{id}/next-intervals.
+func (h *APIHandler) GetCardNextIntervals(w http.ResponseWriter, r *http.Request) {
+	col, _, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	id, err := parseIDParam(r, "id")
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_card_id", "Invalid card ID")
+		return
+	}
+	card, err := h.store.GetCardForUser(r.Context(), h.userIDFromRequest(r), id)
+	if err != nil {
+		respondAPIError(w, http.StatusNotFound, "card_not_found", "Card not found")
+		return
+	}
+	schedule, err := h.store.learningScheduleForDeck(r.Context(), card.homeDeckID())
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "schedule_load_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, answerIntervals(col.Params, schedule, card, time.Now()))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatIntervalLabel(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		30 * time.Second:      "<1m",
+		10 * time.Minute:      "10m",
+		3 * time.Hour:         "3h",
+		3 * 24 * time.Hour:    "3d",
+		45 * 24 * time.Hour:   "1.5mo",
+		60 * 24 * time.Hour:   "2mo",
+		730 * 24 * time.Hour:  "2y",
+		1000 * 24 * time.Hour: "2.7y",
+	} {
+		if got := formatIntervalLabel(d); got != want {
+			t.Fatalf("formatIntervalLabel(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestAPI_DueCardsIncludeNextIntervals(t *testing.T) {
+	env := setupAPITestEnv(t)
+	createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Photosynthesis", "Back": "Light to sugar"},
+	}, nil)
+
+	due := decodeJSON[[]*Card](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=10", ""))
+	if len(due) != 1 || due[0].NextIntervals == nil {
+		t.Fatalf("expected the due card with interval previews, got %+v", due)
+	}
+	// Default learning steps are 1m and 10m; Hard sits between them.
+	intervals := due[0].NextIntervals
+	if intervals.Again.Label != "1m" || intervals.Hard.Label != "6m" || intervals.Good.Label != "10m" || !strings.HasSuffix(intervals.Easy.Label, "d") {
+		t.Fatalf("unexpected previews: %+v", intervals)
+	}
+
+	rr := doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/cards/%d/next-intervals", due[0].ID), "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected next-intervals 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if previews := decodeJSON[AnswerIntervals](t, rr); previews.Good.Label != "10m" || previews.Easy.Label != intervals.Easy.Label {
+		t.Fatalf("expected the endpoint to match the due card, got %+v", previews)
+	}
+
+	queue := decodeJSON[StudyQueueResponse](t, doRawRequest(env.router, http.MethodGet, "/api/study/queue", ""))
+	if len(queue.Cards) != 1 || queue.Cards[0].NextIntervals == nil || queue.Cards[0].NextIntervals.Good.Label != "10m" {
+		t.Fatalf("expected the study queue to carry previews, got %+v", queue.Cards)
+	}
+
+	before := time.Now()
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", due[0].ID), AnswerCardRequest{Rating: 3}); rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	card := decodeJSON[Card](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/cards/%d", due[0].ID), ""))
+	if wait := card.SRS.Due.Sub(before); wait < 9*time.Minute || wait > 11*time.Minute {
+		t.Fatalf("expected Good to schedule the card as previewed, got %s", wait)
+	}
+	if rr := doRawRequest(env.router, http.MethodGet, "/api/cards/999999/next-intervals", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown card 404, got %d", rr.Code)
+	}
+}
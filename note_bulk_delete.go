@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultNoteDeleteBatchSize = 200
+	maxNoteDeleteBatchSize     = 1000
+)
+
+// DeleteNotesByQueryRequest deletes every note matching Query. Send DryRun first to see
+// what would go; ExpectedCount, when set, aborts the delete if the match has changed.
+type DeleteNotesByQueryRequest struct {
+	Query         string `json:"query"`
+	DryRun        bool   `json:"dryRun"`
+	ExpectedCount *int   `json:"expectedCount,omitempty"`
+	BatchSize     int    `json:"batchSize,omitempty"`
+}
+
+func (req *DeleteNotesByQueryRequest) Validate(v *requestValidator) {
+	v.required("query", req.Query)
+	if req.ExpectedCount != nil {
+		v.check(*req.ExpectedCount >= 0, "expectedCount", "must not be negative")
+	}
+}
+
+type DeleteNotesByQueryResponse struct {
+	Query        string `json:"query"`
+	DryRun       bool   `json:"dryRun"`
+	MatchedNotes int    `json:"matchedNotes"`
+	MatchedCards int    `json:"matchedCards"`
+	DeletedNotes int    `json:"deletedNotes"`
+	DeletedCards int    `json:"deletedCards"`
+	Batches      int    `json:"batches"`
+}
+
+// NoteDeleteProgress is streamed after each batch when the client asks for NDJSON.
+type NoteDeleteProgress struct {
+	Batch        int `json:"batch"`
+	Batches      int `json:"batches"`
+	DeletedNotes int `json:"deletedNotes"`
+	TotalNotes   int `json:"totalNotes"`
+}
+
+// CountCardsForNotes returns how many cards belong to the given notes.
+func (s *SQLStore) CountCardsForNotes(ctx context.Context, noteIDs []int64) (int, error) {
+	total := 0
+	for start := 0; start < len(noteIDs); start += maxNoteDeleteBatchSize {
+		chunk := noteIDs[start:min(start+maxNoteDeleteBatchSize, len(noteIDs))]
+		placeholders, args := int64Placeholders(chunk)
+		var count int
+		if err := s.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM cards WHERE note_id IN (%s)`, placeholders), args...).Scan(&count); err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// DeleteNotesWithCards removes notes, their cards, and the cards' review history in one
+// transaction, returning the deleted cards so callers can update in-memory state.
+func (s *SQLStore) DeleteNotesWithCards(ctx context.Context, noteIDs []int64) ([]*Card, error) {
+	if len(noteIDs) == 0 {
+		return nil, nil
+	}
+	placeholders, args := int64Placeholders(noteIDs)
+
+	statements := []string{
+		`DELETE FROM revlog WHERE card_id IN (SELECT id FROM cards WHERE note_id IN (%s))`,
+		`DELETE FROM cards WHERE note_id IN (%s)`,
+		`DELETE FROM notes WHERE id IN (%s)`,
+	}
+	var cards []*Card
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT id, note_id, deck_id FROM cards WHERE note_id IN (%s)`, placeholders), args...)
+		if err != nil {
+			return err
+		}
+		cards = nil
+		for rows.Next() {
+			card := &Card{}
+			if err := rows.Scan(&card.ID, &card.NoteID, &card.DeckID); err != nil {
+				rows.Close()
+				return err
+			}
+			cards = append(cards, card)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf(statement, placeholders), args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cards, nil
+}
+
+func int64Placeholders(ids []int64) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?,", len(ids)), ","), args
+}
+
+// DeleteNotesByQuery serves POST /api/notes/delete-by-query. Matches are deleted in
+// batches, each in its own transaction, so a large delete never holds one long write
+// lock. With Accept: application/x-ndjson a progress line is written per batch, followed
+// by the final summary.
+func (h *APIHandler) DeleteNotesByQuery(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+
+	var req DeleteNotesByQueryRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	req.Query = strings.TrimSpace(req.Query)
+	userID := h.userIDFromRequest(r)
+	if err := validateSearch(req.Query); err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+	batchSize := req.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultNoteDeleteBatchSize
+	}
+	batchSize = min(batchSize, maxNoteDeleteBatchSize)
+
+	noteIDs, err := h.store.SearchNoteIDs(r.Context(), collectionID, userID, req.Query)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "search_failed", err.Error())
+		return
+	}
+	cardCount, err := h.store.CountCardsForNotes(r.Context(), noteIDs)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "search_failed", err.Error())
+		return
+	}
+
+	resp := DeleteNotesByQueryResponse{
+		Query:        req.Query,
+		DryRun:       req.DryRun,
+		MatchedNotes: len(noteIDs),
+		MatchedCards: cardCount,
+		Batches:      (len(noteIDs) + batchSize - 1) / batchSize,
+	}
+	if req.DryRun {
+		respondJSON(w, http.StatusOK, resp)
+		return
+	}
+	if req.ExpectedCount != nil && *req.ExpectedCount != len(noteIDs) {
+		respondAPIError(w, http.StatusConflict, "match_count_changed",
+			fmt.Sprintf("query now matches %d notes, expected %d", len(noteIDs), *req.ExpectedCount))
+		return
+	}
+
+	var encoder *json.Encoder
+	var flusher http.Flusher
+	if strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		encoder = json.NewEncoder(w)
+		flusher, _ = w.(http.Flusher)
+	}
+
+	deckIDs := map[int64]bool{}
+	for batch := 0; batch < resp.Batches; batch++ {
+		chunk := noteIDs[batch*batchSize : min((batch+1)*batchSize, len(noteIDs))]
+		cards, err := h.store.DeleteNotesWithCards(r.Context(), chunk)
+		if err != nil {
+			if encoder != nil {
+				_ = encoder.Encode(map[string]string{"error": err.Error()})
+				return
+			}
+			respondAPIError(w, http.StatusInternalServerError, "note_delete_failed", err.Error())
+			return
+		}
+		for _, card := range cards {
+			h.removeCardFromDeck(col, card.DeckID, card.ID)
+			delete(col.Cards, card.ID)
+			deckIDs[card.DeckID] = true
+		}
+		for _, id := range chunk {
+			delete(col.Notes, id)
+		}
+		resp.DeletedNotes += len(chunk)
+		resp.DeletedCards += len(cards)
+
+		if encoder != nil {
+			_ = encoder.Encode(NoteDeleteProgress{
+				Batch:        batch + 1,
+				Batches:      resp.Batches,
+				DeletedNotes: resp.DeletedNotes,
+				TotalNotes:   len(noteIDs),
+			})
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+
+	touched := make([]int64, 0, len(deckIDs))
+	for id := range deckIDs {
+		touched = append(touched, id)
+	}
+	h.markStudyGroupInstallsForkedByDeckIDs(r.Context(), touched...)
+	h.cleanupAfterDelete(r.Context(), collectionID, col)
+
+	if encoder != nil {
+		_ = encoder.Encode(resp)
+		return
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPI_DeleteNotesByQuery(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	var answered int64
+	for i := 0; i < 5; i++ {
+		tags := []string{"keep"}
+		if i < 3 {
+			tags = []string{"obsolete"}
+		}
+		created := createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": fmt.Sprintf("Bulk %d", i), "Back": "Answer"},
+			Tags:      tags,
+		}, nil)
+		if i == 0 {
+			answered = created.Cards[0].ID
+		}
+	}
+	// Review history must not block the delete.
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", answered), AnswerCardRequest{Rating: 3}); rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/notes/delete-by-query", DeleteNotesByQueryRequest{Query: "  "}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected empty query 400, got %d", rr.Code)
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/notes/delete-by-query", DeleteNotesByQueryRequest{Query: `"open`}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected malformed query 400, got %d", rr.Code)
+	}
+
+	dryRR := doJSONRequest(t, env.router, http.MethodPost, "/api/notes/delete-by-query", DeleteNotesByQueryRequest{Query: "tag:obsolete", DryRun: true, BatchSize: 2})
+	if dryRR.Code != http.StatusOK {
+		t.Fatalf("expected dry run 200, got %d (%s)", dryRR.Code, dryRR.Body.String())
+	}
+	dry := decodeJSON[DeleteNotesByQueryResponse](t, dryRR)
+	if dry.MatchedNotes != 3 || dry.MatchedCards != 3 || dry.DeletedNotes != 0 || dry.Batches != 2 {
+		t.Fatalf("unexpected dry run %+v", dry)
+	}
+
+	stale := 4
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/notes/delete-by-query", DeleteNotesByQueryRequest{Query: "tag:obsolete", ExpectedCount: &stale}); rr.Code != http.StatusConflict {
+		t.Fatalf("expected mismatched count 409, got %d", rr.Code)
+	}
+
+	body, _ := json.Marshal(DeleteNotesByQueryRequest{Query: "tag:obsolete", ExpectedCount: &dry.MatchedNotes, BatchSize: 2})
+	req := httptest.NewRequest(http.MethodPost, "/api/notes/delete-by-query", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/x-ndjson")
+	req.Header.Set("Cookie", env.authCookie)
+	rr := httptest.NewRecorder()
+	env.router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected delete 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(rr.Body)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if len(lines) != 3 {
+		t.Fatalf("expected 2 progress lines and a summary, got %v", lines)
+	}
+	var progress NoteDeleteProgress
+	if err := json.Unmarshal([]byte(lines[0]), &progress); err != nil || progress.Batch != 1 || progress.DeletedNotes != 2 || progress.TotalNotes != 3 {
+		t.Fatalf("unexpected first progress line %s", lines[0])
+	}
+	var summary DeleteNotesByQueryResponse
+	if err := json.Unmarshal([]byte(lines[2]), &summary); err != nil || summary.DeletedNotes != 3 || summary.DeletedCards != 3 {
+		t.Fatalf("unexpected summary %s", lines[2])
+	}
+
+	after := decodeJSON[DeleteNotesByQueryResponse](t, doJSONRequest(t, env.router, http.MethodPost, "/api/notes/delete-by-query", DeleteNotesByQueryRequest{Query: "bulk", DryRun: true}))
+	if after.MatchedNotes != 2 {
+		t.Fatalf("expected the 2 kept notes to remain, got %d", after.MatchedNotes)
+	}
+	var revlogRows int
+	if err := env.store.db.QueryRow(`SELECT COUNT(*) FROM revlog WHERE card_id = ?`, answered).Scan(&revlogRows); err != nil || revlogRows != 0 {
+		t.Fatalf("expected deleted card's revlog to be removed, got %d (%v)", revlogRows, err)
+	}
+}
+
+func TestAPI_DeleteNoteCascadesToCardsAndRevlog(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic (and reversed card)",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Cascade", "Back": "Answer"},
+	}, nil)
+	if len(created.Cards) != 2 {
+		t.Fatalf("expected 2 cards, got %d", len(created.Cards))
+	}
+	for _, card := range created.Cards {
+		if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", card.ID), AnswerCardRequest{Rating: 3}); rr.Code != http.StatusOK {
+			t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := doRawRequest(env.router, http.MethodDelete, fmt.Sprintf("/api/notes/%d", created.Note.ID), "")
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected note delete 204, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	var cards, revlogRows int
+	if err := env.store.db.QueryRow(`SELECT COUNT(*) FROM cards WHERE note_id = ?`, created.Note.ID).Scan(&cards); err != nil || cards != 0 {
+		t.Fatalf("expected the note's cards to be deleted, got %d (%v)", cards, err)
+	}
+	if err := env.store.db.QueryRow(`SELECT COUNT(*) FROM revlog WHERE card_id IN (?, ?)`, created.Cards[0].ID, created.Cards[1].ID).Scan(&revlogRows); err != nil || revlogRows != 0 {
+		t.Fatalf("expected the cards' revlog to be deleted, got %d (%v)", revlogRows, err)
+	}
+
+	if rr := doRawRequest(env.router, http.MethodDelete, fmt.Sprintf("/api/notes/%d", created.Note.ID), ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected second delete 404, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// NoteListFilter narrows ListNotesPage. Tag and Query are case-insensitive substring
+// matches; Query looks at field values, tags, and the note type name.
+type NoteListFilter struct {
+	DeckID   int64
+	TypeName string
+	Tag      string
+	Query    string
+}
+
+// likeContains escapes value for a substring LIKE match. Use with ESCAPE '\'.
+func likeContains(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + replacer.Replace(value) + "%"
+}
+
+func scanNote(scanner interface{ Scan(dest ...any) error }) (Note, error) {
+	var (
+		note                    Note
+		typeID                  string
+		fieldValsJSON, tagsJSON []byte
+		createdAt, modifiedAt   int64
+	)
+	if err := scanner.Scan(&note.ID, &typeID, &fieldValsJSON, &tagsJSON, &note.USN, &createdAt, &modifiedAt); err != nil {
+		return Note{}, err
+	}
+	note.Type = noteTypeNameFromRecordID(typeID)
+	note.CreatedAt = time.Unix(createdAt, 0)
+	note.ModifiedAt = time.Unix(modifiedAt, 0)
+	if err := json.Unmarshal(fieldValsJSON, &note.FieldMap); err != nil {
+		return Note{}, err
+	}
+	if err := json.Unmarshal(tagsJSON, &note.Tags); err != nil {
+		return Note{}, err
+	}
+	return note, nil
+}
+
+// ListNotesPage returns one page of notes, most recently modified first, along with the
+// number of notes matching the filter. Filtering and paging happen in SQL so large
+// collections are never loaded whole.
+func (s *SQLStore) ListNotesPage(ctx context.Context, collectionID string, filter NoteListFilter, offset, limit int) ([]Note, int, error) {
+	where := `n.collection_id = ?`
+	args := []interface{}{collectionID}
+	like := s.dialect.like()
+	tags := s.dialect.jsonArrayValues("n.tags", "t")
+
+	if filter.DeckID > 0 {
+		where += ` AND EXISTS (SELECT 1 FROM cards c WHERE c.note_id = n.id AND c.deck_id = ?)`
+		args = append(args, filter.DeckID)
+	}
+	if typeName := strings.TrimSpace(filter.TypeName); typeName != "" {
+		where += ` AND lower(n.type_id) = lower(?)`
+		args = append(args, noteTypeRecordID(collectionID, NoteTypeName(typeName)))
+	}
+	if tag := strings.TrimSpace(filter.Tag); tag != "" {
+		where += ` AND EXISTS (SELECT 1 FROM ` + tags + ` WHERE t.value ` + like + ` ? ESCAPE '\')`
+		args = append(args, likeContains(tag))
+	}
+	if query := strings.TrimSpace(filter.Query); query != "" {
+		pattern := likeContains(query)
+		where += ` AND (
+			substr(n.type_id, ?) ` + like + ` ? ESCAPE '\'
+			OR EXISTS (SELECT 1 FROM ` + s.dialect.jsonObjectEntries("n.field_vals", "f") + ` WHERE f.value ` + like + ` ? ESCAPE '\')
+			OR EXISTS (SELECT 1 FROM ` + tags + ` WHERE t.value ` + like + ` ? ESCAPE '\')
+		)`
+		args = append(args, len(noteTypeRecordID(collectionID, ""))+1, pattern, pattern, pattern)
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM notes n WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT n.id, n.type_id, n.field_vals, n.tags, n.usn, n.created_at, n.modified_at
+		FROM notes n
+		WHERE `+where+`
+		ORDER BY n.modified_at DESC, n.id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	notes := []Note{}
+	for rows.Next() {
+		note, err := scanNote(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		notes = append(notes, note)
+	}
+	return notes, total, rows.Err()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAPI_ListNotesPagesAndFiltersInSQL(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	for i := 0; i < 7; i++ {
+		tags := []string{"odd_one"}
+		if i%2 == 0 {
+			tags = []string{"even"}
+		}
+		createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": fmt.Sprintf("Paged %d", i), "Back": "100% sure"},
+			Tags:      tags,
+		}, nil)
+	}
+
+	first := decodeJSON[ListNotesResponse](t, doRawRequest(env.router, http.MethodGet, "/api/notes?page=1&pageSize=3", ""))
+	if first.Total != 7 || len(first.Notes) != 3 || first.TotalPages != 3 || first.Page != 1 || first.PageSize != 3 {
+		t.Fatalf("unexpected first page %+v", first)
+	}
+	last := decodeJSON[ListNotesResponse](t, doRawRequest(env.router, http.MethodGet, "/api/notes?page=3&pageSize=3", ""))
+	if len(last.Notes) != 1 || last.NextCursor != "" {
+		t.Fatalf("expected a single note on the last page, got %+v", last)
+	}
+	if first.Notes[0].ID == last.Notes[0].ID {
+		t.Fatalf("expected pages not to overlap")
+	}
+
+	even := decodeJSON[ListNotesResponse](t, doRawRequest(env.router, http.MethodGet, "/api/notes?tag=EVEN&typeId=basic&deckId=1&pageSize=50", ""))
+	if even.Total != 4 {
+		t.Fatalf("expected 4 even-tagged notes, got %d", even.Total)
+	}
+	for _, note := range even.Notes {
+		if note.DeckID != 1 || note.CardCount != 1 {
+			t.Fatalf("expected deck and card count on list items, got %+v", note)
+		}
+	}
+
+	// LIKE wildcards in user input are matched literally.
+	if got := decodeJSON[ListNotesResponse](t, doRawRequest(env.router, http.MethodGet, "/api/notes?tag=v_n", "")); got.Total != 0 {
+		t.Fatalf("expected underscore to be literal, got %d matches", got.Total)
+	}
+	if got := decodeJSON[ListNotesResponse](t, doRawRequest(env.router, http.MethodGet, "/api/notes?q=100%25", "")); got.Total != 7 {
+		t.Fatalf("expected percent search to match every note, got %d", got.Total)
+	}
+	if got := decodeJSON[ListNotesResponse](t, doRawRequest(env.router, http.MethodGet, "/api/notes?q=paged%203", "")); got.Total != 1 {
+		t.Fatalf("expected one free-text match, got %d", got.Total)
+	}
+
+	if rr := doRawRequest(env.router, http.MethodGet, "/api/notes?page=0", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid page 400, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,544 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateNoteTypeRequest defines a custom note type. Templates use the same shape as
+// the note type responses.
+type CreateNoteTypeRequest struct {
+	Name           string         `json:"name"`
+	Fields         []string       `json:"fields"`
+	Templates      []TemplateInfo `json:"templates"`
+	SortFieldIndex int            `json:"sortFieldIndex"`
+}
+
+// Validate checks the request's shape; buildCustomNoteType checks the templates against
+// the fields and the name against the collection.
+func (req *CreateNoteTypeRequest) Validate(v *requestValidator) {
+	v.required("name", sanitizeHTML(req.Name))
+	v.check(len(req.Fields) > 0, "fields", "must list at least one field")
+	for _, field := range req.Fields {
+		v.fieldName("fields", field)
+	}
+	v.check(req.SortFieldIndex >= 0 && req.SortFieldIndex < max(len(req.Fields), 1), "sortFieldIndex", "must refer to one of the fields")
+	v.check(len(req.Templates) > 0, "templates", "must list at least one template")
+}
+
+// CloneNoteTypeRequest names the copy. Without a name it is called "<original> copy".
+type CloneNoteTypeRequest struct {
+	Name string `json:"name"`
+}
+
+// Validate is empty: a blank name picks the default copy name.
+func (req *CloneNoteTypeRequest) Validate(v *requestValidator) {}
+
+type RenameNoteTypeRequest struct {
+	NewName string `json:"newName"`
+}
+
+func (req *RenameNoteTypeRequest) Validate(v *requestValidator) {
+	v.required("newName", sanitizeHTML(req.NewName))
+}
+
+var errNoteTypeExists = errors.New("a note type with this name already exists")
+
+// templateSpecialFields can be referenced from any template without being a field of
+// the note type.
+var templateSpecialFields = map[string]bool{
+	"Tags":    true,
+	"Type":    true,
+	"Deck":    true,
+	"Subdeck": true,
+	"Card":    true,
+}
+
+func noteTypeResponse(nt NoteType) NoteTypeResponse {
+	templates := make([]TemplateInfo, 0, len(nt.Templates))
+	for _, t := range nt.Templates {
+		templates = append(templates, TemplateInfo{
+			Name:            t.Name,
+			QFmt:            t.QFmt,
+			AFmt:            t.AFmt,
+			Styling:         t.Styling,
+			IfFieldNonEmpty: t.IfFieldNonEmpty,
+			IsCloze:         t.IsCloze,
+			DeckOverride:    t.DeckOverride,
+			BrowserQFmt:     t.BrowserQFmt,
+			BrowserAFmt:     t.BrowserAFmt,
+		})
+	}
+	return NoteTypeResponse{
+		Name:           string(nt.Name),
+		Fields:         nt.Fields,
+		Templates:      templates,
+		SortFieldIndex: nt.SortFieldIndex,
+		FieldOptions:   nt.FieldOptions,
+	}
+}
+
+// validateTemplateSyntax checks that every {{ is closed, that {{#Field}} and
+// {{^Field}} sections are closed by a matching {{/Field}}, and that every reference
+// names a field of the note type or a special field. FrontSide is only allowed on the
+// answer side. It returns the fields wrapped in a cloze: filter.
+func validateTemplateSyntax(tmpl string, fields map[string]bool, answerSide bool) ([]string, error) {
+	var (
+		sections []string
+		clozes   []string
+	)
+	rest := tmpl
+	for {
+		open := strings.Index(rest, "{{")
+		if open < 0 {
+			break
+		}
+		end := strings.Index(rest[open+2:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed {{ in %q", rest[open:])
+		}
+		token := strings.TrimSpace(rest[open+2 : open+2+end])
+		rest = rest[open+2+end+2:]
+		if token == "" {
+			return nil, fmt.Errorf("empty {{}} reference")
+		}
+
+		switch token[0] {
+		case '#', '^':
+			name := strings.TrimSpace(token[1:])
+			if !fields[name] && !templateSpecialFields[name] {
+				return nil, fmt.Errorf("section {{%s}} refers to unknown field %q", token, name)
+			}
+			sections = append(sections, name)
+			continue
+		case '/':
+			name := strings.TrimSpace(token[1:])
+			if len(sections) == 0 || sections[len(sections)-1] != name {
+				return nil, fmt.Errorf("{{%s}} does not close an open section", token)
+			}
+			sections = sections[:len(sections)-1]
+			continue
+		case '!':
+			continue
+		}
+
+		// Filters come before the field name: {{text:hint:Field}}.
+		parts := strings.Split(token, ":")
+		name := strings.TrimSpace(parts[len(parts)-1])
+		if name == "FrontSide" && len(parts) == 1 {
+			if !answerSide {
+				return nil, fmt.Errorf("{{FrontSide}} can only be used on the answer side")
+			}
+			continue
+		}
+		if !fields[name] && !templateSpecialFields[name] {
+			return nil, fmt.Errorf("{{%s}} refers to unknown field %q", token, name)
+		}
+		for _, filter := range parts[:len(parts)-1] {
+			if filter = strings.TrimSpace(filter); filter == "cloze" || filter == imageOcclusionFilter {
+				clozes = append(clozes, name)
+			}
+		}
+	}
+	if len(sections) > 0 {
+		return nil, fmt.Errorf("section {{#%s}} is never closed", sections[len(sections)-1])
+	}
+	return clozes, nil
+}
+
+// buildCustomNoteType validates req against the collection's existing note types and
+// returns the note type to store.
+func buildCustomNoteType(col *Collection, req CreateNoteTypeRequest) (NoteType, error) {
+	name := sanitizeHTML(strings.TrimSpace(req.Name))
+	for existing := range col.NoteTypes {
+		if strings.EqualFold(string(existing), name) {
+			return NoteType{}, errNoteTypeExists
+		}
+	}
+
+	nt := NoteType{Name: NoteTypeName(name), Fields: make([]string, 0, len(req.Fields))}
+	known := make(map[string]bool, len(req.Fields))
+	for _, raw := range req.Fields {
+		field := sanitizeHTML(strings.TrimSpace(raw))
+		if known[field] {
+			return NoteType{}, fmt.Errorf("field %q is listed twice", field)
+		}
+		known[field] = true
+		nt.Fields = append(nt.Fields, field)
+	}
+	nt.SortFieldIndex = req.SortFieldIndex
+
+	for _, t := range req.Templates {
+		template := CardTemplate{
+			Name:            sanitizeHTML(strings.TrimSpace(t.Name)),
+			QFmt:            sanitizeHTML(t.QFmt),
+			AFmt:            sanitizeHTML(t.AFmt),
+			Styling:         sanitizeHTML(t.Styling),
+			IfFieldNonEmpty: sanitizeHTML(strings.TrimSpace(t.IfFieldNonEmpty)),
+			IsCloze:         t.IsCloze,
+			DeckOverride:    sanitizeHTML(strings.TrimSpace(t.DeckOverride)),
+			BrowserQFmt:     sanitizeHTML(t.BrowserQFmt),
+			BrowserAFmt:     sanitizeHTML(t.BrowserAFmt),
+		}
+		if template.Name == "" {
+			return NoteType{}, fmt.Errorf("template names cannot be empty")
+		}
+		for _, other := range nt.Templates {
+			if strings.EqualFold(other.Name, template.Name) {
+				return NoteType{}, fmt.Errorf("template %q is listed twice", template.Name)
+			}
+		}
+		if strings.TrimSpace(template.QFmt) == "" {
+			return NoteType{}, fmt.Errorf("template %q needs a question format", template.Name)
+		}
+		if template.IfFieldNonEmpty != "" && !known[template.IfFieldNonEmpty] {
+			return NoteType{}, fmt.Errorf("template %q: ifFieldNonEmpty refers to unknown field %q", template.Name, template.IfFieldNonEmpty)
+		}
+
+		clozes, err := validateTemplateSyntax(template.QFmt, known, false)
+		if err != nil {
+			return NoteType{}, fmt.Errorf("template %q question: %w", template.Name, err)
+		}
+		for _, side := range []struct {
+			label, format string
+			answer        bool
+		}{
+			{"answer", template.AFmt, true},
+			{"browser question", template.BrowserQFmt, false},
+			{"browser answer", template.BrowserAFmt, true},
+		} {
+			if _, err := validateTemplateSyntax(side.format, known, side.answer); err != nil {
+				return NoteType{}, fmt.Errorf("template %q %s: %w", template.Name, side.label, err)
+			}
+		}
+		if template.IsCloze && len(clozes) == 0 {
+			return NoteType{}, fmt.Errorf("cloze template %q must contain a {{cloze:Field}} reference on the question side", template.Name)
+		}
+		nt.Templates = append(nt.Templates, template)
+	}
+	return nt, nil
+}
+
+// CreateNoteType serves POST /api/note-types, adding a custom note type to the
+// collection.
+func (h *APIHandler) CreateNoteType(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+
+	var req CreateNoteTypeRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	nt, err := buildCustomNoteType(col, req)
+	if errors.Is(err, errNoteTypeExists) {
+		respondAPIError(w, http.StatusConflict, "note_type_exists", err.Error())
+		return
+	}
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_note_type", err.Error())
+		return
+	}
+
+	if err := h.store.CreateNoteType(r.Context(), collectionID, &nt); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "note_type_create_failed", err.Error())
+		return
+	}
+	col.NoteTypes[nt.Name] = nt
+
+	respondJSON(w, http.StatusCreated, noteTypeResponse(nt))
+}
+
+// CloneNoteType serves POST /api/note-types/{name}/clone, copying the note type's
+// fields, templates and field options under a new name. Notes are not copied.
+func (h *APIHandler) CloneNoteType(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+
+	source, ok := col.NoteTypes[NoteTypeName(chi.URLParam(r, "name"))]
+	if !ok {
+		respondAPIError(w, http.StatusNotFound, "note_type_not_found", "Note type not found")
+		return
+	}
+	var req CloneNoteTypeRequest
+	if !decodeOptionalValidatedRequest(w, r, &req) {
+		return
+	}
+	name := sanitizeHTML(strings.TrimSpace(req.Name))
+	if name == "" {
+		name = uniqueNoteTypeName(col, string(source.Name)+" copy")
+	}
+	for existing := range col.NoteTypes {
+		if strings.EqualFold(string(existing), name) {
+			respondAPIError(w, http.StatusConflict, "note_type_exists", errNoteTypeExists.Error())
+			return
+		}
+	}
+
+	clone := NoteType{
+		Name:           NoteTypeName(name),
+		Fields:         slices.Clone(source.Fields),
+		Templates:      slices.Clone(source.Templates),
+		SortFieldIndex: source.SortFieldIndex,
+		FieldOptions:   maps.Clone(source.FieldOptions),
+	}
+	if err := h.store.CreateNoteType(r.Context(), collectionID, &clone); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "note_type_create_failed", err.Error())
+		return
+	}
+	col.NoteTypes[clone.Name] = clone
+
+	respondJSON(w, http.StatusCreated, noteTypeResponse(clone))
+}
+
+// uniqueNoteTypeName returns base, or base followed by the first free number, so that
+// it does not clash with an existing note type.
+func uniqueNoteTypeName(col *Collection, base string) string {
+	taken := func(candidate string) bool {
+		for existing := range col.NoteTypes {
+			if strings.EqualFold(string(existing), candidate) {
+				return true
+			}
+		}
+		return false
+	}
+	name := base
+	for index := 2; taken(name); index++ {
+		name = fmt.Sprintf("%s %d", base, index)
+	}
+	return name
+}
+
+// DeleteNoteType removes a note type that no notes use any more.
+func (s *SQLStore) DeleteNoteType(ctx context.Context, collectionID string, name NoteTypeName) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM note_types WHERE collection_id = ? AND name = ?`, collectionID, string(name))
+	return err
+}
+
+// RenameNoteType moves a note type to a new name. The name is part of the note type's
+// ID, so the row is copied under the new ID, every note is pointed at it, and the old
+// row is removed, all in one transaction.
+func (s *SQLStore) RenameNoteType(ctx context.Context, collectionID string, from, to NoteTypeName, now time.Time) error {
+	oldID, newID := noteTypeRecordID(collectionID, from), noteTypeRecordID(collectionID, to)
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO note_types (id, collection_id, name, fields, templates, sort_field_index, field_options)
+			SELECT ?, collection_id, ?, fields, templates, sort_field_index, field_options
+			FROM note_types WHERE id = ?
+		`, newID, string(to), oldID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE notes SET type_id = ?, modified_at = ? WHERE type_id = ?`, newID, now.Unix(), oldID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM note_types WHERE id = ?`, oldID)
+		return err
+	})
+}
+
+// migrateNoteFields maps a note's fields onto the target note type. Fields with the same
+// name carry over; the remaining source fields fill the remaining target fields in
+// order. Source fields left without a place are dropped.
+func migrateNoteFields(from, to NoteType, values map[string]string) map[string]string {
+	migrated := make(map[string]string, len(to.Fields))
+	used := map[string]bool{}
+	for _, field := range to.Fields {
+		if slices.Contains(from.Fields, field) {
+			migrated[field] = values[field]
+			used[field] = true
+		}
+	}
+	leftover := make([]string, 0, len(from.Fields))
+	for _, field := range from.Fields {
+		if !used[field] {
+			leftover = append(leftover, field)
+		}
+	}
+	for _, field := range to.Fields {
+		if _, ok := migrated[field]; ok {
+			continue
+		}
+		migrated[field] = ""
+		if len(leftover) > 0 {
+			migrated[field] = values[leftover[0]]
+			leftover = leftover[1:]
+		}
+	}
+	return migrated
+}
+
+// RenameNoteType serves POST /api/note-types/{name}/rename. Notes of the type follow it
+// to the new name.
+func (h *APIHandler) RenameNoteType(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+
+	name := NoteTypeName(chi.URLParam(r, "name"))
+	nt, ok := col.NoteTypes[name]
+	if !ok {
+		respondAPIError(w, http.StatusNotFound, "note_type_not_found", "Note type not found")
+		return
+	}
+	var req RenameNoteTypeRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	newName := NoteTypeName(sanitizeHTML(strings.TrimSpace(req.NewName)))
+	if newName == name {
+		respondJSON(w, http.StatusOK, noteTypeResponse(nt))
+		return
+	}
+	for existing := range col.NoteTypes {
+		if existing != name && strings.EqualFold(string(existing), string(newName)) {
+			respondAPIError(w, http.StatusConflict, "note_type_exists", errNoteTypeExists.Error())
+			return
+		}
+	}
+
+	now := time.Now()
+	if err := h.store.RenameNoteType(r.Context(), collectionID, name, newName, now); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "note_type_rename_failed", err.Error())
+		return
+	}
+	nt.Name = newName
+	delete(col.NoteTypes, name)
+	col.NoteTypes[newName] = nt
+	for id, note := range col.Notes {
+		if note.Type == name {
+			note.Type = newName
+			note.ModifiedAt = now
+			col.Notes[id] = note
+		}
+	}
+	h.markStudyGroupInstallsForkedByNoteType(r.Context(), string(newName))
+
+	respondJSON(w, http.StatusOK, noteTypeResponse(nt))
+}
+
+// DeleteNoteType serves DELETE /api/note-types/{name}. A note type still used by notes
+// is only deleted with notes=delete, which deletes those notes and their cards, or
+// notes=migrate&targetType=, which converts them to another note type first. Migrated
+// cards keep their review history when the target has a template at the same position.
+func (h *APIHandler) DeleteNoteType(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	nt, ok := col.NoteTypes[NoteTypeName(name)]
+	if !ok {
+		respondAPIError(w, http.StatusNotFound, "note_type_not_found", "Note type not found")
+		return
+	}
+	if len(col.NoteTypes) == 1 {
+		respondAPIError(w, http.StatusConflict, "last_note_type", "A collection needs at least one note type")
+		return
+	}
+	notes, err := h.store.GetNotesByType(r.Context(), collectionID, name)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "note_type_delete_failed", err.Error())
+		return
+	}
+
+	disposition := strings.TrimSpace(r.URL.Query().Get("notes"))
+	switch disposition {
+	case "":
+		if len(notes) > 0 {
+			respondAPIError(w, http.StatusConflict, "note_type_in_use", "Notes use this note type. Pass notes=delete to delete them or notes=migrate&targetType= to convert them.")
+			return
+		}
+	case "delete":
+		ids := make([]int64, 0, len(notes))
+		for _, note := range notes {
+			ids = append(ids, note.ID)
+		}
+		for start := 0; start < len(ids); start += maxNoteDeleteBatchSize {
+			chunk := ids[start:min(start+maxNoteDeleteBatchSize, len(ids))]
+			cards, err := h.store.DeleteNotesWithCards(r.Context(), chunk)
+			if err != nil {
+				respondAPIError(w, http.StatusInternalServerError, "note_type_delete_failed", err.Error())
+				return
+			}
+			for _, card := range cards {
+				h.removeCardFromDeck(col, card.DeckID, card.ID)
+				delete(col.Cards, card.ID)
+			}
+			for _, id := range chunk {
+				delete(col.Notes, id)
+			}
+		}
+	case "migrate":
+		targetName := strings.TrimSpace(r.URL.Query().Get("targetType"))
+		target, ok := col.NoteTypes[NoteTypeName(targetName)]
+		if !ok || targetName == name {
+			respondAPIError(w, http.StatusBadRequest, "invalid_target_type", "targetType must be another note type")
+			return
+		}
+		templateAliases := map[string]string{}
+		for i, template := range nt.Templates {
+			if i < len(target.Templates) {
+				templateAliases[template.Name] = target.Templates[i].Name
+			}
+		}
+		now := time.Now()
+		for i := range notes {
+			note := &notes[i]
+			note.FieldMap = migrateNoteFields(nt, target, note.FieldMap)
+			note.Type = target.Name
+			note.ModifiedAt = now
+			if err := h.store.UpdateNote(r.Context(), note); err != nil {
+				respondAPIError(w, http.StatusInternalServerError, "note_type_migrate_failed", err.Error())
+				return
+			}
+			col.Notes[note.ID] = *note
+			if _, err := h.regenerateCardsForSingleNote(r.Context(), col, note, 0, templateAliases); err != nil {
+				respondAPIError(w, http.StatusInternalServerError, "card_regeneration_failed", err.Error())
+				return
+			}
+		}
+		h.markStudyGroupInstallsForkedByNoteType(r.Context(), targetName)
+	default:
+		respondAPIError(w, http.StatusBadRequest, "invalid_note_disposition", "notes must be delete or migrate")
+		return
+	}
+
+	if err := h.store.DeleteNoteType(r.Context(), collectionID, nt.Name); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "note_type_delete_failed", err.Error())
+		return
+	}
+	delete(col.NoteTypes, nt.Name)
+	h.markStudyGroupInstallsForkedByNoteType(r.Context(), name)
+	h.cleanupAfterDelete(r.Context(), collectionID, col)
+	w.WriteHeader(http.StatusNoContent)
+}
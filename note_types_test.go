@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateTemplateSyntax(t *testing.T) {
+	fields := map[string]bool{"Word": true, "Meaning": true, "Text": true}
+	cases := []struct {
+		tmpl    string
+		answer  bool
+		wantErr string
+	}{
+		{tmpl: "{{Word}} {{#Meaning}}{{Meaning}}{{/Meaning}} {{Tags}}"},
+		{tmpl: "{{FrontSide}}<hr>{{text:Meaning}}", answer: true},
+		{tmpl: "{{cloze:Text}}"},
+		{tmpl: "{{Word", wantErr: "unclosed"},
+		{tmpl: "{{Missing}}", wantErr: "unknown field"},
+		{tmpl: "{{#Word}}{{Word}}", wantErr: "never closed"},
+		{tmpl: "{{#Word}}{{/Meaning}}", wantErr: "does not close"},
+		{tmpl: "{{FrontSide}}", wantErr: "answer side"},
+	}
+	for _, tc := range cases {
+		_, err := validateTemplateSyntax(tc.tmpl, fields, tc.answer)
+		if tc.wantErr == "" && err != nil {
+			t.Fatalf("%q: unexpected error %v", tc.tmpl, err)
+		}
+		if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+			t.Fatalf("%q: expected error containing %q, got %v", tc.tmpl, tc.wantErr, err)
+		}
+	}
+}
+
+func TestAPI_CreateNoteType(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	req := CreateNoteTypeRequest{
+		Name:   "Vocabulary",
+		Fields: []string{"Word", "Meaning", "Example"},
+		Templates: []TemplateInfo{
+			{Name: "Recognition", QFmt: "{{Word}}", AFmt: "{{FrontSide}}<hr id=\"answer\">{{Meaning}}{{#Example}}<br>{{Example}}{{/Example}}"},
+			{Name: "Recall", QFmt: "{{Meaning}}", AFmt: "{{FrontSide}}<hr id=\"answer\">{{Word}}"},
+		},
+	}
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/note-types", req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected create 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	created := decodeJSON[NoteTypeResponse](t, rr)
+	if created.Name != "Vocabulary" || len(created.Fields) != 3 || len(created.Templates) != 2 {
+		t.Fatalf("unexpected note type %+v", created)
+	}
+
+	rr = doRawRequest(env.router, http.MethodGet, "/api/note-types/Vocabulary", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected get 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	note := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Vocabulary",
+		DeckID:    1,
+		FieldVals: map[string]string{"Word": "perro", "Meaning": "dog"},
+	}, nil)
+	if len(note.Cards) != 2 {
+		t.Fatalf("expected two cards from the custom note type, got %d", len(note.Cards))
+	}
+
+	var collectionID string
+	if err := env.store.db.QueryRow(`SELECT collection_id FROM decks WHERE id = 1`).Scan(&collectionID); err != nil {
+		t.Fatalf("collection lookup: %v", err)
+	}
+	stored, err := env.store.GetNoteType(context.Background(), collectionID, "Vocabulary")
+	if err != nil || len(stored.Templates) != 2 {
+		t.Fatalf("expected note type persisted, got %+v (%v)", stored, err)
+	}
+
+	rr = doJSONRequest(t, env.router, http.MethodPost, "/api/note-types", CreateNoteTypeRequest{
+		Name:      "vocabulary",
+		Fields:    []string{"A"},
+		Templates: []TemplateInfo{{Name: "Card 1", QFmt: "{{A}}"}},
+	})
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected duplicate name 409, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	for _, bad := range []CreateNoteTypeRequest{
+		{Name: "No fields", Templates: []TemplateInfo{{Name: "Card 1", QFmt: "x"}}},
+		{Name: "No templates", Fields: []string{"A"}},
+		{Name: "Reserved", Fields: []string{"Tags"}, Templates: []TemplateInfo{{Name: "Card 1", QFmt: "{{Tags}}"}}},
+		{Name: "Unknown ref", Fields: []string{"A"}, Templates: []TemplateInfo{{Name: "Card 1", QFmt: "{{B}}"}}},
+		{Name: "Unclosed", Fields: []string{"A"}, Templates: []TemplateInfo{{Name: "Card 1", QFmt: "{{#A}}x"}}},
+		{Name: "Cloze without cloze field", Fields: []string{"A"}, Templates: []TemplateInfo{{Name: "Cloze", QFmt: "{{A}}", IsCloze: true}}},
+	} {
+		rr := doJSONRequest(t, env.router, http.MethodPost, "/api/note-types", bad)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d (%s)", bad.Name, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestAPI_DeleteNoteType(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	createType := func(name string, fields []string, templates []TemplateInfo) {
+		t.Helper()
+		rr := doJSONRequest(t, env.router, http.MethodPost, "/api/note-types", CreateNoteTypeRequest{Name: name, Fields: fields, Templates: templates})
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected create %s 201, got %d (%s)", name, rr.Code, rr.Body.String())
+		}
+	}
+	createType("Word", []string{"Term", "Definition"}, []TemplateInfo{
+		{Name: "Forward", QFmt: "{{Term}}", AFmt: "{{FrontSide}}<hr>{{Definition}}"},
+	})
+	createType("Scratch", []string{"Only"}, []TemplateInfo{{Name: "Card 1", QFmt: "{{Only}}"}})
+
+	word := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Word",
+		DeckID:    1,
+		FieldVals: map[string]string{"Term": "gato", "Definition": "cat"},
+	}, nil)
+	scratch := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Scratch",
+		DeckID:    1,
+		FieldVals: map[string]string{"Only": "throwaway"},
+	}, nil)
+
+	rr := doRawRequest(env.router, http.MethodDelete, "/api/note-types/Word", "")
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected delete of used type 409, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	rr = doRawRequest(env.router, http.MethodDelete, "/api/note-types/Word?notes=migrate&targetType=Word", "")
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected migrate onto itself 400, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr = doRawRequest(env.router, http.MethodDelete, "/api/note-types/Word?notes=migrate&targetType=Basic", "")
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected migrate 204, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	migrated, err := env.store.GetNote(context.Background(), word.Note.ID)
+	if err != nil {
+		t.Fatalf("load migrated note: %v", err)
+	}
+	if migrated.Type != "Basic" || migrated.FieldMap["Front"] != "gato" || migrated.FieldMap["Back"] != "cat" {
+		t.Fatalf("expected fields mapped onto Basic, got %+v", migrated)
+	}
+	cards, err := env.store.GetCardsByNote(context.Background(), word.Note.ID)
+	if err != nil || len(cards) != 1 || cards[0].ID != word.Cards[0].ID || cards[0].TemplateName != "Card 1" {
+		t.Fatalf("expected the original card kept under the Basic template, got %+v (%v)", cards, err)
+	}
+
+	rr = doRawRequest(env.router, http.MethodDelete, "/api/note-types/Scratch?notes=delete", "")
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected cascade delete 204, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if _, err := env.store.GetNote(context.Background(), scratch.Note.ID); err == nil {
+		t.Fatalf("expected scratch note deleted")
+	}
+
+	rr = doRawRequest(env.router, http.MethodGet, "/api/note-types", "")
+	for _, nt := range decodeJSON[[]NoteTypeResponse](t, rr) {
+		if nt.Name == "Word" || nt.Name == "Scratch" {
+			t.Fatalf("expected %s removed from the note type list", nt.Name)
+		}
+	}
+	if rr := doRawRequest(env.router, http.MethodDelete, "/api/note-types/Missing", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected missing type 404, got %d", rr.Code)
+	}
+}
+
+func TestAPI_CloneNoteType(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	rr := doJSONRequest(t, env.router, http.MethodPut, "/api/note-types/Basic/fields/options", SetFieldOptionsRequest{
+		FieldName: "Front",
+		Options:   FieldOptions{Font: "Georgia"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected field options 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr = doRawRequest(env.router, http.MethodPost, "/api/note-types/Basic/clone", "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected clone 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	clone := decodeJSON[NoteTypeResponse](t, rr)
+	if clone.Name != "Basic copy" || len(clone.Fields) != 2 || len(clone.Templates) != 1 || clone.FieldOptions["Front"].Font != "Georgia" {
+		t.Fatalf("unexpected clone %+v", clone)
+	}
+
+	rr = doRawRequest(env.router, http.MethodPost, "/api/note-types/Basic/clone", "")
+	if clone := decodeJSON[NoteTypeResponse](t, rr); rr.Code != http.StatusCreated || clone.Name != "Basic copy 2" {
+		t.Fatalf("expected second clone named Basic copy 2, got %d %+v", rr.Code, clone)
+	}
+
+	qfmt := "<b>{{Front}}</b>"
+	rr = doJSONRequest(t, env.router, http.MethodPatch, "/api/note-types/Basic%20copy/templates/Card%201", UpdateTemplateRequest{QFmt: &qfmt})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected template edit on clone 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	rr = doRawRequest(env.router, http.MethodGet, "/api/note-types/Basic", "")
+	if original := decodeJSON[NoteTypeResponse](t, rr); original.Templates[0].QFmt == qfmt {
+		t.Fatalf("expected original template untouched, got %q", original.Templates[0].QFmt)
+	}
+
+	rr = doJSONRequest(t, env.router, http.MethodPost, "/api/note-types/Basic/clone", CloneNoteTypeRequest{Name: "basic"})
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected clash 409, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doRawRequest(env.router, http.MethodPost, "/api/note-types/Missing/clone", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected missing type 404, got %d", rr.Code)
+	}
+}
+
+func TestAPI_RenameNoteType(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	note := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "hola", "Back": "hello"},
+	}, nil)
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/note-types/Basic/rename", RenameNoteTypeRequest{NewName: "Cloze"})
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected clash 409, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr = doJSONRequest(t, env.router, http.MethodPost, "/api/note-types/Basic/rename", RenameNoteTypeRequest{NewName: "Simple"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected rename 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if renamed := decodeJSON[NoteTypeResponse](t, rr); renamed.Name != "Simple" || len(renamed.Fields) != 2 {
+		t.Fatalf("unexpected renamed type %+v", renamed)
+	}
+
+	stored, err := env.store.GetNote(context.Background(), note.Note.ID)
+	if err != nil || stored.Type != "Simple" {
+		t.Fatalf("expected note moved to Simple, got %+v (%v)", stored, err)
+	}
+	if rr := doRawRequest(env.router, http.MethodGet, "/api/note-types/Basic", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected old name gone, got %d", rr.Code)
+	}
+	if rr := doRawRequest(env.router, http.MethodGet, "/api/search?q=note:Simple", ""); decodeJSON[SearchResponse](t, rr).Total != 1 {
+		t.Fatalf("expected note found under the new name, got %s", rr.Body.String())
+	}
+
+	createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Simple",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "adios", "Back": "bye"},
+	}, nil)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestAPI_PutNoteRegeneratesCardsAndKeepsSRS(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Old question", "Back": "Old answer"},
+		Tags:      []string{"draft"},
+	}, nil)
+	cardID := created.Cards[0].ID
+
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", cardID), AnswerCardRequest{Rating: 3}); rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr := doJSONRequest(t, env.router, http.MethodPut, fmt.Sprintf("/api/notes/%d", created.Note.ID), UpdateNoteRequest{
+		FieldMap: map[string]string{"Front": "New question", "Back": "New answer"},
+		Tags:     []string{"final"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected note put 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	updated := decodeJSON[createNoteAPIResponse](t, rr)
+	if updated.Note.FieldMap["Front"] != "New question" || len(updated.Note.Tags) != 1 || updated.Note.Tags[0] != "final" {
+		t.Fatalf("expected fields and tags to be replaced, got %+v", updated.Note)
+	}
+	if len(updated.Cards) != 1 || updated.Cards[0].ID != cardID {
+		t.Fatalf("expected the existing card to be kept, got %+v", updated.Cards)
+	}
+	if !strings.Contains(updated.Cards[0].Front, "New question") {
+		t.Fatalf("expected card front to be regenerated, got %q", updated.Cards[0].Front)
+	}
+
+	card := decodeJSON[Card](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/cards/%d", cardID), ""))
+	if card.SRS.State == fsrs.New || card.SRS.Reps == 0 {
+		t.Fatalf("expected review state to survive the edit, got %+v", card.SRS)
+	}
+
+	if rr := doJSONRequest(t, env.router, http.MethodPut, "/api/notes/999999", UpdateNoteRequest{FieldMap: map[string]string{"Front": "x"}}); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected missing note 404, got %d", rr.Code)
+	}
+}
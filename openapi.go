@@ -0,0 +1,399 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// The OpenAPI document is built from the router itself, so every route appears in it
+// with its path parameters and whether it needs a signed-in user. Operations listed in
+// openAPIOperations also describe their request and response bodies, with schemas
+// reflected from the Go types' JSON tags.
+
+// openAPIOperation describes the bodies of the operation served by a handler method.
+// Status is the success status, 200 when zero; a nil Response means 204 No Content
+// when Status is 204 and an unspecified JSON body otherwise.
+type openAPIOperation struct {
+	Request  any
+	Response any
+	Status   int
+}
+
+var openAPIOperations = map[string]openAPIOperation{
+	"HealthCheck":    {Response: map[string]string{}},
+	"GetAuthSession": {Response: AuthSessionResponse{}},
+	"GetDashboard":   {Response: DashboardResponse{}},
+	"GetCollection":  {Response: Collection{}},
+
+	"ListAPITokens":  {Response: []APIToken{}},
+	"CreateAPIToken": {Request: CreateAPITokenRequest{}, Response: CreateAPITokenResponse{}, Status: http.StatusCreated},
+	"RevokeAPIToken": {Status: http.StatusNoContent},
+
+	"ListProfiles":     {Response: []ProfileResponse{}},
+	"CreateProfile":    {Request: CreateProfileRequest{}, Response: ProfileResponse{}, Status: http.StatusCreated},
+	"ActivateProfile":  {Response: ProfileResponse{}},
+	"ListCollections":  {Response: []CollectionSummary{}},
+	"CreateCollection": {Request: CreateCollectionRequest{}, Response: CollectionSummary{}, Status: http.StatusCreated},
+	"DeleteCollection": {Status: http.StatusNoContent},
+
+	"ListDecks":    {Response: []DeckResponse{}},
+	"CreateDeck":   {Request: CreateDeckRequest{}, Response: DeckResponse{}, Status: http.StatusCreated},
+	"UpdateDeck":   {Request: UpdateDeckRequest{}, Response: DeckResponse{}},
+	"GetDeckTree":  {Response: []DeckTreeNode{}},
+	"GetDeckStats": {Response: DeckStats{}},
+	"GetDueCards":  {Response: []Card{}},
+
+	"ListDeckGrants":        {Response: []DeckGrant{}},
+	"CreateDeckGrant":       {Request: CreateDeckGrantRequest{}, Response: DeckGrant{}, Status: http.StatusCreated},
+	"DeleteDeckGrant":       {Status: http.StatusNoContent},
+	"ListSharedDecks":       {Response: []SharedDeckSummary{}},
+	"GetSharedDeckCards":    {Response: []SharedCard{}},
+	"GetSharedDeckDueCards": {Response: []Card{}},
+	"AnswerSharedDeckCard":  {Request: AnswerCardRequest{}, Response: Card{}},
+
+	"ListNoteTypes":   {Response: []NoteTypeResponse{}},
+	"GetNoteType":     {Response: NoteTypeResponse{}},
+	"AddField":        {Request: AddFieldRequest{}},
+	"RenameField":     {Request: RenameFieldRequest{}},
+	"RemoveField":     {Request: RemoveFieldRequest{}},
+	"ReorderFields":   {Request: ReorderFieldsRequest{}},
+	"SetSortField":    {Request: SetSortFieldRequest{}},
+	"SetFieldOptions": {Request: SetFieldOptionsRequest{}},
+	"CreateTemplate":  {Request: CreateTemplateRequest{}, Response: TemplatesResponse{}, Status: http.StatusCreated},
+	"UpdateTemplate":  {Request: UpdateTemplateRequest{}, Response: TemplatesResponse{}},
+
+	"ListNotes":        {Response: ListNotesResponse{}},
+	"CreateNote":       {Request: CreateNoteRequest{}, Status: http.StatusCreated},
+	"GetNote":          {Response: NoteResponse{}},
+	"UpdateNote":       {Request: UpdateNoteRequest{}},
+	"CheckDuplicate":   {Request: CheckDuplicateRequest{}, Response: DuplicateResult{}},
+	"FindEmptyCards":   {Response: EmptyCardsResponse{}},
+	"DeleteEmptyCards": {Request: DeleteEmptyCardsRequest{}, Response: DeleteEmptyCardsResponse{}},
+
+	"GetCard":    {Response: Card{}},
+	"AnswerCard": {Request: AnswerCardRequest{}, Response: Card{}},
+	"UpdateCard": {Request: UpdateCardRequest{}, Response: Card{}},
+	"Search":     {Response: SearchResponse{}},
+
+	"CreateBackup":       {Response: CreateBackupResponse{}, Status: http.StatusCreated},
+	"RestoreBackup":      {Request: RestoreBackupRequest{}, Response: RestoreBackupResponse{}},
+	"GetBackupPolicy":    {Response: BackupPolicy{}},
+	"UpdateBackupPolicy": {Request: BackupPolicy{}, Response: BackupPolicyResponse{}},
+	"GetOperation":       {Response: OperationProgress{}},
+	"CheckDatabase":      {Response: DatabaseCheckReport{}},
+	"CleanupOrphans":     {Response: OrphanCleanupReport{}},
+	"OptimizeDatabase":   {Response: DatabaseOptimizeReport{}},
+}
+
+var routeParamPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// serveOpenAPIDocument serves the OpenAPI 3 document for the routes under /api, built on
+// the first request once every route is registered.
+func serveOpenAPIDocument(routes chi.Routes) http.HandlerFunc {
+	var (
+		once sync.Once
+		doc  map[string]any
+		err  error
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { doc, err = buildOpenAPIDocument(routes, "/api") })
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "openapi_failed", err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, doc)
+	}
+}
+
+func buildOpenAPIDocument(routes chi.Routes, prefix string) (map[string]any, error) {
+	schemas := newOpenAPISchemas()
+	errorResponse := map[string]any{
+		"description": "Error",
+		"content":     map[string]any{"application/json": map[string]any{"schema": schemas.of(reflect.TypeOf(APIErrorResponse{}))}},
+	}
+	paths := map[string]map[string]any{}
+	err := chi.Walk(routes, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		route = strings.TrimSuffix(route, "/")
+		if route == "" || strings.HasPrefix(route, "/docs") {
+			return nil
+		}
+		name := funcName(handler)
+		path := prefix + routeParamPattern.ReplaceAllString(route, "{$1}")
+
+		op := map[string]any{
+			"operationId": name,
+			"summary":     summarizeHandlerName(name),
+			"tags":        []string{strings.SplitN(strings.TrimPrefix(route, "/"), "/", 2)[0]},
+		}
+		var params []map[string]any
+		for _, match := range routeParamPattern.FindAllStringSubmatch(route, -1) {
+			params = append(params, map[string]any{
+				"name":     match[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": "string"},
+			})
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		for _, mw := range middlewares {
+			if strings.Contains(funcName(mw), "RequireAuthenticatedUser") {
+				op["security"] = []map[string][]string{{"session": {}}, {"bearer": {}}}
+				break
+			}
+		}
+
+		described := openAPIOperations[name]
+		if described.Request != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": schemas.of(reflect.TypeOf(described.Request))}},
+			}
+		}
+		status := described.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := map[string]any{"description": http.StatusText(status)}
+		if status != http.StatusNoContent {
+			schema := map[string]any{}
+			if described.Response != nil {
+				schema = schemas.of(reflect.TypeOf(described.Response))
+			}
+			success["content"] = map[string]any{"application/json": map[string]any{"schema": schema}}
+		}
+		op["responses"] = map[string]any{
+			strconv.Itoa(status): success,
+			"default":            errorResponse,
+		}
+
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(method)] = op
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Vutadex API",
+			"version":     "1",
+			"description": "Errors share one JSON envelope. Pass ?collectionId= to work in a collection other than the workspace's.",
+		},
+		"servers": []map[string]string{{"url": "/"}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]any{
+				"session": map[string]any{"type": "apiKey", "in": "cookie", "name": sessionCookieName},
+				"bearer":  map[string]any{"type": "http", "scheme": "bearer", "description": "A personal access token"},
+			},
+		},
+	}, nil
+}
+
+// funcName is the method or function name behind a handler or middleware, such as
+// ListDecks for handler.ListDecks.
+func funcName(fn any) string {
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func {
+		return ""
+	}
+	name := runtime.FuncForPC(value.Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// summarizeHandlerName turns ListDeckGrants into "List deck grants".
+func summarizeHandlerName(name string) string {
+	var words []string
+	start := 0
+	runes := []rune(name)
+	for i := 1; i < len(runes); i++ {
+		if unicode.IsUpper(runes[i]) && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	words = append(words, string(runes[start:]))
+	for i := 1; i < len(words); i++ {
+		if strings.ToUpper(words[i]) != words[i] {
+			words[i] = strings.ToLower(words[i])
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// openAPISchemas reflects Go types into JSON schemas, placing named structs under
+// components so recursive types terminate.
+type openAPISchemas struct {
+	components map[string]any
+	names      map[reflect.Type]string
+}
+
+func newOpenAPISchemas() *openAPISchemas {
+	return &openAPISchemas{components: map[string]any{}, names: map[reflect.Type]string{}}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+func (s *openAPISchemas) of(t reflect.Type) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case rawMessageType:
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := s.of(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]any{"allOf": []any{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		name, ok := s.names[t]
+		if !ok {
+			name = schemaName(t)
+			s.names[t] = name
+			s.components[name] = map[string]any{}
+			s.components[name] = s.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+func (s *openAPISchemas) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	s.addFields(t, properties, &required)
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (s *openAPISchemas) addFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				s.addFields(embedded, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema := s.of(field.Type)
+		if strings.Contains(options, "string") {
+			schema = map[string]any{"type": "string"}
+		}
+		properties[name] = schema
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// schemaName names a component after its type, qualifying types from other packages
+// the way this package imports them, such as fsrs.Card.
+func schemaName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if pkg == localPackagePath {
+		return t.Name()
+	}
+	parts := strings.Split(pkg, "/")
+	last := parts[len(parts)-1]
+	if len(parts) > 1 && len(last) > 1 && last[0] == 'v' && strings.Trim(last[1:], "0123456789") == "" {
+		last = parts[len(parts)-2]
+	}
+	return strings.TrimPrefix(last, "go-") + "." + t.Name()
+}
+
+var localPackagePath = reflect.TypeOf(openAPIOperation{}).PkgPath()
+
+// swaggerUIPage loads Swagger UI from a CDN and points it at the OpenAPI document.
+const swaggerUIPage = `<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Vutadex API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+<script>
+window.ui = SwaggerUIBundle({url: "/api/docs/openapi.json", dom_id: "#swagger-ui", withCredentials: true});
+</script>
+</body>
+</html>
+`
+
+// ServeAPIDocs serves GET /api/docs, a Swagger UI for the OpenAPI document.
+func ServeAPIDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(swaggerUIPage))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestAPI_OpenAPIDocumentCoversEveryRoute(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	rr := doRawRequest(env.router, http.MethodGet, "/api/docs/openapi.json", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the OpenAPI document, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	doc := decodeJSON[struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}](t, rr)
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Fatalf("expected an OpenAPI 3 document, got %q", doc.OpenAPI)
+	}
+
+	operationIDs := map[string]bool{}
+	for _, operations := range doc.Paths {
+		for _, op := range operations {
+			operationIDs[op["operationId"].(string)] = true
+		}
+	}
+	routes := env.router.(chi.Routes)
+	err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		route = strings.TrimSuffix(route, "/")
+		if !strings.HasPrefix(route, "/api/") || strings.HasPrefix(route, "/api/docs") {
+			return nil
+		}
+		path := routeParamPattern.ReplaceAllString(route, "{$1}")
+		if doc.Paths[path][strings.ToLower(method)] == nil {
+			t.Errorf("%s %s is missing from the OpenAPI document", method, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk routes: %v", err)
+	}
+	for name := range openAPIOperations {
+		if !operationIDs[name] {
+			t.Errorf("openAPIOperations describes %s, which serves no route", name)
+		}
+	}
+
+	createDeck := doc.Paths["/api/decks"]["post"]
+	if createDeck["requestBody"] == nil || createDeck["security"] == nil {
+		t.Fatalf("expected POST /api/decks to describe its body and require auth, got %v", createDeck)
+	}
+	if doc.Paths["/api/health"]["get"]["security"] != nil {
+		t.Fatal("expected the health check to be public")
+	}
+	for _, name := range []string{"DeckResponse", "Card", "APIErrorResponse"} {
+		if doc.Components.Schemas[name] == nil {
+			t.Errorf("expected a %s schema", name)
+		}
+	}
+
+	rr = doRawRequest(env.router, http.MethodGet, "/api/docs", "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Header().Get("Content-Type"), "text/html") || !strings.Contains(rr.Body.String(), "/api/docs/openapi.json") {
+		t.Fatalf("expected the Swagger UI page, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
+
+func TestSummarizeHandlerName(t *testing.T) {
+	for name, want := range map[string]string{
+		"ListDeckGrants": "List deck grants",
+		"CreateAPIToken": "Create API token",
+		"HealthCheck":    "Health check",
+	} {
+		if got := summarizeHandlerName(name); got != want {
+			t.Errorf("summarizeHandlerName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
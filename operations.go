@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Imports, card regeneration, and backups can take minutes on a big collection. A
+// client that wants to show progress names the operation with an X-Operation-Id header
+// on the request, then reads GET /api/operations/{id}/events, a server-sent event
+// stream of OperationProgress, alongside it. The stream may be opened before the request
+// starts. Without the header the server picks an ID, returned in the same header.
+const (
+	operationIDHeader = "X-Operation-Id"
+
+	operationImport          = "import"
+	operationRegenerateCards = "regenerate_cards"
+	operationBackup          = "backup"
+	operationOptimize        = "optimize"
+
+	operationPending   = "pending"
+	operationRunning   = "running"
+	operationSucceeded = "succeeded"
+	operationFailed    = "failed"
+
+	operationRetention       = 10 * time.Minute
+	maxOperationWarnings     = 100
+	operationStreamKeepalive = 15 * time.Second
+)
+
+type OperationProgress struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind,omitempty"`
+	Status     string     `json:"status"`
+	Processed  int64      `json:"processed"`
+	Total      int64      `json:"total"`
+	Unit       string     `json:"unit,omitempty"` // what Processed and Total count: notes or bytes
+	Warnings   []string   `json:"warnings,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+func (p OperationProgress) done() bool {
+	return p.Status == operationSucceeded || p.Status == operationFailed
+}
+
+// operation is one tracked operation. Its methods do nothing on a nil operation, so
+// code that reports progress does not need to know whether anyone is watching.
+type operation struct {
+	userID  string
+	mu      sync.Mutex
+	state   OperationProgress
+	changed chan struct{} // closed and replaced on every update
+	updated time.Time
+}
+
+func (op *operation) snapshot() (OperationProgress, <-chan struct{}) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	state := op.state
+	state.Warnings = append([]string(nil), op.state.Warnings...)
+	return state, op.changed
+}
+
+func (op *operation) update(change func(*OperationProgress)) {
+	if op == nil {
+		return
+	}
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	change(&op.state)
+	op.updated = time.Now()
+	close(op.changed)
+	op.changed = make(chan struct{})
+}
+
+func (op *operation) setTotal(total int64) {
+	op.update(func(p *OperationProgress) { p.Total = total })
+}
+
+// report records how far the operation has got and the warnings so far; only the last
+// maxOperationWarnings are kept.
+func (op *operation) report(processed int64, warnings []string) {
+	op.update(func(p *OperationProgress) {
+		p.Processed = processed
+		if len(warnings) > maxOperationWarnings {
+			warnings = warnings[len(warnings)-maxOperationWarnings:]
+		}
+		p.Warnings = append(p.Warnings[:0], warnings...)
+	})
+}
+
+func (op *operation) advance(n int64) {
+	op.update(func(p *OperationProgress) { p.Processed += n })
+}
+
+func (op *operation) warn(format string, args ...any) {
+	op.update(func(p *OperationProgress) {
+		p.Warnings = append(p.Warnings, fmt.Sprintf(format, args...))
+		if len(p.Warnings) > maxOperationWarnings {
+			p.Warnings = p.Warnings[len(p.Warnings)-maxOperationWarnings:]
+		}
+	})
+}
+
+func (op *operation) finish(err error) {
+	op.update(func(p *OperationProgress) {
+		now := time.Now().UTC()
+		p.FinishedAt = &now
+		p.Status = operationSucceeded
+		if err != nil {
+			p.Status, p.Error = operationFailed, err.Error()
+		}
+	})
+}
+
+// countingReader advances op by the bytes read through it.
+func (op *operation) countingReader(r io.Reader) io.Reader {
+	if op == nil {
+		return r
+	}
+	return &progressReader{r: r, op: op}
+}
+
+type progressReader struct {
+	r  io.Reader
+	op *operation
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.op.advance(int64(n))
+	return n, err
+}
+
+// operationRegistry holds the operations of the last operationRetention. The zero value
+// is ready to use.
+type operationRegistry struct {
+	mu  sync.Mutex
+	ops map[string]*operation
+}
+
+// lookup returns the user's operation id, creating a pending one when create is set. It
+// returns nil for an operation that belongs to someone else.
+func (reg *operationRegistry) lookup(id, userID string, create bool) *operation {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	now := time.Now()
+	for key, op := range reg.ops {
+		op.mu.Lock()
+		stale := now.Sub(op.updated) > operationRetention && op.state.Status != operationRunning
+		op.mu.Unlock()
+		if stale {
+			delete(reg.ops, key)
+		}
+	}
+	if op, ok := reg.ops[id]; ok {
+		if op.userID != userID {
+			return nil
+		}
+		return op
+	}
+	if !create {
+		return nil
+	}
+	if reg.ops == nil {
+		reg.ops = map[string]*operation{}
+	}
+	op := &operation{
+		userID:  userID,
+		state:   OperationProgress{ID: id, Status: operationPending},
+		changed: make(chan struct{}),
+		updated: now,
+	}
+	reg.ops[id] = op
+	return op
+}
+
+// startOperation begins tracking the request's operation under the ID the client named,
+// or a new one, which is echoed in the response's X-Operation-Id header. It returns nil
+// if the ID belongs to another user's operation or one already under way, in which
+// case the request simply goes untracked.
+func (h *APIHandler) startOperation(w http.ResponseWriter, r *http.Request, kind, unit string) *operation {
+	id := r.Header.Get(operationIDHeader)
+	if !isValidRequestID(id) {
+		id = newID("op")
+	}
+	op := h.operations.lookup(id, h.userIDFromRequest(r), true)
+	if op == nil {
+		return nil
+	}
+	if state, _ := op.snapshot(); state.Status != operationPending && !state.done() {
+		return nil
+	}
+	w.Header().Set(operationIDHeader, id)
+	op.update(func(p *OperationProgress) {
+		now := time.Now().UTC()
+		*p = OperationProgress{ID: id, Kind: kind, Status: operationRunning, Unit: unit, StartedAt: &now}
+	})
+	return op
+}
+
+// GetOperation serves GET /api/operations/{id}, the operation's progress right now.
+func (h *APIHandler) GetOperation(w http.ResponseWriter, r *http.Request) {
+	op := h.operations.lookup(chi.URLParam(r, "id"), h.userIDFromRequest(r), false)
+	if op == nil {
+		respondAPIError(w, http.StatusNotFound, "operation_not_found", "Operation not found")
+		return
+	}
+	state, _ := op.snapshot()
+	respondJSON(w, http.StatusOK, state)
+}
+
+// StreamOperation serves GET /api/operations/{id}/events. Each event is a "progress"
+// event carrying OperationProgress; the stream ends after the one whose status is
+// succeeded or failed.
+func (h *APIHandler) StreamOperation(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !isValidRequestID(id) {
+		respondAPIError(w, http.StatusBadRequest, "invalid_operation_id", "Invalid operation ID")
+		return
+	}
+	op := h.operations.lookup(id, h.userIDFromRequest(r), true)
+	if op == nil {
+		respondAPIError(w, http.StatusNotFound, "operation_not_found", "Operation not found")
+		return
+	}
+	// The stream outlives the work it reports on, and never reads the store.
+	releaseActiveProfile(r)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+
+	keepalive := time.NewTicker(operationStreamKeepalive)
+	defer keepalive.Stop()
+	for {
+		state, changed := op.snapshot()
+		if err := writeOperationEvent(w, state); err != nil || rc.Flush() != nil || state.done() {
+			return
+		}
+	wait:
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-changed:
+				break wait
+			case <-keepalive.C:
+				if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil || rc.Flush() != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+func writeOperationEvent(w io.Writer, state OperationProgress) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data)
+	return err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPI_OperationProgressStreamsOverSSE(t *testing.T) {
+	env := setupFileAPITestEnv(t)
+	server := httptest.NewServer(env.router)
+	t.Cleanup(server.Close)
+
+	// The client opens the stream first, then starts the import under the same ID.
+	resp, err := http.Get(server.URL + "/api/operations/op-import-1/events")
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	events := make(chan OperationProgress, 64)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				var progress OperationProgress
+				if json.Unmarshal([]byte(data), &progress) == nil {
+					events <- progress
+				}
+			}
+		}
+	}()
+	if first := <-events; first.Status != operationPending {
+		t.Fatalf("expected the stream to start pending, got %+v", first)
+	}
+
+	rr := doJSONRequestWithHeaders(t, env.router, http.MethodPost, "/api/import", ImportNotesJSONRequest{
+		Content:  "front,back\nhola,hello\nadios,goodbye\ngato,cat\n",
+		Filename: "spanish.csv",
+	}, map[string]string{operationIDHeader: "op-import-1"})
+	if rr.Code != http.StatusOK || rr.Header().Get(operationIDHeader) != "op-import-1" {
+		t.Fatalf("expected the import to run as op-import-1, got %d %q (%s)", rr.Code, rr.Header().Get(operationIDHeader), rr.Body.String())
+	}
+
+	var last OperationProgress
+	for progress := range events {
+		last = progress
+	}
+	if last.Status != operationSucceeded || last.Kind != operationImport || last.Total == 0 || last.Processed != last.Total || last.FinishedAt == nil {
+		t.Fatalf("expected the stream to end with the finished import, got %+v", last)
+	}
+
+	rr = doRawRequest(env.router, http.MethodPost, "/api/backups", "{}")
+	id := rr.Header().Get(operationIDHeader)
+	if rr.Code != http.StatusCreated || !strings.HasPrefix(id, "op_") {
+		t.Fatalf("expected the backup to get an operation ID, got %d %q", rr.Code, id)
+	}
+	backup := decodeJSON[OperationProgress](t, doRawRequest(env.router, http.MethodGet, "/api/operations/"+id, ""))
+	if backup.Kind != operationBackup || backup.Unit != "bytes" || backup.Total == 0 || backup.Processed != backup.Total {
+		t.Fatalf("expected a finished backup counting bytes, got %+v", backup)
+	}
+
+	if rr := doRawRequest(env.router, http.MethodGet, "/api/operations/op-missing", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected an unknown operation to 404, got %d", rr.Code)
+	}
+	if op := env.handler.operations.lookup("op-import-1", "usr_someone_else", true); op != nil {
+		t.Fatal("expected another user's operation to be hidden")
+	}
+}
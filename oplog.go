@@ -0,0 +1,718 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Oplog sync is the alternative to snapshot sync (sync.go) for people editing a shared
+// collection at the same time. Instead of whole entities, clients exchange operations
+// such as "set this note's Back field" or "remove this tag", appended to a per-collection
+// log that is never rewritten.
+//
+// Each operation names a register: one field of one note, one tag on a note, a deck's
+// name, or whether the entity was deleted. Operations on the same register are ordered
+// by (lamport, actor, id) and the last one wins, so two replicas holding the same
+// operations agree on the result whatever order they received them in. A delete wins
+// over every edit to the entity.
+//
+//  1. POST /api/oplog appends a batch of operations and applies those that win.
+//     Operations already in the log are skipped, so a retried push is safe.
+//  2. GET /api/oplog?after= returns operations in the order the server appended them,
+//     with the seq to pass as after next time.
+//
+// Entities created through the log are targeted by the ID of the operation that created
+// them; refs in both responses give their server IDs. Entities that existed before are
+// targeted as "note:<id>" or "deck:<id>".
+
+const (
+	opNoteCreate   = "note.create"
+	opNoteSetField = "note.set_field"
+	opNoteSetTag   = "note.set_tag"
+	opNoteDelete   = "note.delete"
+	opDeckCreate   = "deck.create"
+	opDeckRename   = "deck.rename"
+	opDeckDelete   = "deck.delete"
+
+	opRegisterCreated = "created"
+	opRegisterDeleted = "deleted"
+
+	maxOperationIDLength = 128
+)
+
+// Operation is one entry of the operation log. Value is JSON: a string for set_field and
+// rename, a bool (present or not) for set_tag, OpNoteCreateValue or OpDeckCreateValue for
+// creates, and absent for deletes.
+type Operation struct {
+	ID      string          `json:"id"`    // unique within the collection, e.g. "<actor>:<counter>"
+	Actor   string          `json:"actor"` // the device or person that made the edit
+	Lamport int64           `json:"lamport"`
+	Kind    string          `json:"kind"`
+	Target  string          `json:"target"`          // "note:<id>", "deck:<id>" or a create operation's ID
+	Field   string          `json:"field,omitempty"` // field name for set_field, tag for set_tag
+	Value   json.RawMessage `json:"value,omitempty"`
+	Seq     int64           `json:"seq,omitempty"` // position in the server's log
+}
+
+type OpNoteCreateValue struct {
+	Type   NoteTypeName      `json:"type"`
+	Deck   string            `json:"deck"` // "deck:<id>" or a deck.create operation's ID
+	Fields map[string]string `json:"fields"`
+	Tags   []string          `json:"tags,omitempty"`
+}
+
+type OpDeckCreateValue struct {
+	Name string `json:"name"`
+}
+
+type OpLogPushRequest struct {
+	Ops []Operation `json:"ops"`
+}
+
+// Validate only requires operations; each one is checked as it is applied and rejected
+// on its own.
+func (req *OpLogPushRequest) Validate(v *requestValidator) {
+	v.check(len(req.Ops) > 0, "ops", "is required")
+}
+
+// OpLogRejection is an operation that was not applied. Invalid operations are not added
+// to the log; not_applied ones are, but the server could not carry them out, for example
+// because a plan limit was reached.
+type OpLogRejection struct {
+	ID      string `json:"id"`
+	Reason  string `json:"reason"` // invalid or not_applied
+	Message string `json:"message"`
+}
+
+type OpLogPushResponse struct {
+	Appended   int              `json:"appended"`
+	Duplicates int              `json:"duplicates"`
+	Rejected   []OpLogRejection `json:"rejected"`
+	NotApplied []OpLogRejection `json:"notApplied"`
+	Refs       map[string]int64 `json:"refs"`    // create operation ID -> server entity ID
+	Seq        int64            `json:"seq"`     // latest seq in the log
+	Lamport    int64            `json:"lamport"` // new local operations must be later than this
+}
+
+type OpLogPullResponse struct {
+	Ops     []Operation      `json:"ops"`
+	Refs    map[string]int64 `json:"refs"`
+	Seq     int64            `json:"seq"` // pass as after on the next call
+	HasMore bool             `json:"hasMore"`
+	Lamport int64            `json:"lamport"`
+}
+
+// opLess orders operations on a register; the greatest one wins.
+func opLess(a, b Operation) bool {
+	if a.Lamport != b.Lamport {
+		return a.Lamport < b.Lamport
+	}
+	if a.Actor != b.Actor {
+		return a.Actor < b.Actor
+	}
+	return a.ID < b.ID
+}
+
+// mergeOpLogs merges operation logs into one, each operation once, in the order every
+// replica replays them.
+func mergeOpLogs(logs ...[]Operation) []Operation {
+	seen := map[string]bool{}
+	var merged []Operation
+	for _, log := range logs {
+		for _, op := range log {
+			if !seen[op.ID] {
+				seen[op.ID] = true
+				merged = append(merged, op)
+			}
+		}
+	}
+	slices.SortFunc(merged, func(a, b Operation) int {
+		switch {
+		case opLess(a, b):
+			return -1
+		case opLess(b, a):
+			return 1
+		}
+		return 0
+	})
+	return merged
+}
+
+// opEntityType returns the type of entity an operation kind acts on.
+func opEntityType(kind string) string {
+	entityType, _, _ := strings.Cut(kind, ".")
+	return entityType
+}
+
+// opRegister names the register an operation writes.
+func opRegister(op Operation) string {
+	switch op.Kind {
+	case opNoteCreate, opDeckCreate:
+		return opRegisterCreated
+	case opNoteDelete, opDeckDelete:
+		return opRegisterDeleted
+	case opNoteSetField:
+		return "field:" + op.Field
+	case opNoteSetTag:
+		return "tag:" + op.Field
+	}
+	return "name"
+}
+
+// winningOps returns the winning operation of each register among ops on one target.
+// Any delete counts, however early.
+func winningOps(ops []Operation) map[string]Operation {
+	winners := map[string]Operation{}
+	for _, op := range ops {
+		register := opRegister(op)
+		if current, ok := winners[register]; !ok || opLess(current, op) {
+			winners[register] = op
+		}
+	}
+	return winners
+}
+
+// parseEntityRef splits a "note:<id>" or "deck:<id>" target.
+func parseEntityRef(target string) (string, int64, bool) {
+	entityType, rawID, ok := strings.Cut(target, ":")
+	if !ok || (entityType != "note" && entityType != "deck") {
+		return "", 0, false
+	}
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil || id <= 0 {
+		return "", 0, false
+	}
+	return entityType, id, true
+}
+
+// validateOperation checks op and fills in what the server derives from it.
+func validateOperation(op *Operation) error {
+	switch {
+	case op.ID == "" || len(op.ID) > maxOperationIDLength:
+		return fmt.Errorf("id is required and at most %d bytes", maxOperationIDLength)
+	case op.Actor == "" || len(op.Actor) > maxOperationIDLength:
+		return fmt.Errorf("actor is required and at most %d bytes", maxOperationIDLength)
+	case op.Lamport <= 0:
+		return fmt.Errorf("lamport must be positive")
+	}
+	op.Seq = 0
+
+	switch op.Kind {
+	case opNoteCreate, opDeckCreate:
+		if op.Target != "" && op.Target != op.ID {
+			return fmt.Errorf("a create operation targets itself")
+		}
+		op.Target, op.Field = op.ID, ""
+		if _, _, ok := parseEntityRef(op.ID); ok {
+			return fmt.Errorf("create operation IDs cannot look like entity references")
+		}
+	case opNoteSetField, opNoteSetTag, opNoteDelete, opDeckRename, opDeckDelete:
+		if op.Target == "" {
+			return fmt.Errorf("target is required")
+		}
+		if entityType, _, ok := parseEntityRef(op.Target); ok && entityType != opEntityType(op.Kind) {
+			return fmt.Errorf("%s cannot target a %s", op.Kind, entityType)
+		}
+	default:
+		return fmt.Errorf("unknown kind %q", op.Kind)
+	}
+
+	switch op.Kind {
+	case opNoteCreate:
+		var value OpNoteCreateValue
+		if err := json.Unmarshal(op.Value, &value); err != nil || value.Type == "" || value.Deck == "" {
+			return fmt.Errorf("value needs type and deck")
+		}
+	case opDeckCreate:
+		var value OpDeckCreateValue
+		if err := json.Unmarshal(op.Value, &value); err != nil || strings.TrimSpace(value.Name) == "" {
+			return fmt.Errorf("value needs name")
+		}
+	case opNoteSetField, opDeckRename:
+		var value string
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return fmt.Errorf("value must be a string")
+		}
+		if op.Kind == opNoteSetField && op.Field == "" {
+			return fmt.Errorf("field is required")
+		}
+		if op.Kind == opDeckRename && strings.TrimSpace(value) == "" {
+			return fmt.Errorf("value must be a deck name")
+		}
+	case opNoteSetTag:
+		var value bool
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return fmt.Errorf("value must be true or false")
+		}
+		tags := sanitizeTags([]string{op.Field})
+		if len(tags) != 1 || tags[0] != op.Field {
+			return fmt.Errorf("field must be a single tag")
+		}
+	case opNoteDelete, opDeckDelete:
+		op.Field, op.Value = "", nil
+	}
+	return nil
+}
+
+// AppendOperation adds op to a collection's log and sets its Seq. It returns false when
+// the log already holds an operation with op's ID.
+func (s *SQLStore) AppendOperation(ctx context.Context, collectionID, userID string, op *Operation) (bool, error) {
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO oplog (collection_id, op_id, actor, lamport, kind, target, register, field, value, user_id, received_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (collection_id, op_id) DO NOTHING
+		RETURNING seq
+	`, collectionID, op.ID, op.Actor, op.Lamport, op.Kind, op.Target, opRegister(*op), op.Field, string(op.Value), userID, time.Now().Unix()).Scan(&op.Seq)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *SQLStore) queryOperations(ctx context.Context, query string, args ...interface{}) ([]Operation, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT seq, op_id, actor, lamport, kind, target, field, value FROM oplog WHERE `+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ops := []Operation{}
+	for rows.Next() {
+		var op Operation
+		var value string
+		if err := rows.Scan(&op.Seq, &op.ID, &op.Actor, &op.Lamport, &op.Kind, &op.Target, &op.Field, &value); err != nil {
+			return nil, err
+		}
+		if value != "" {
+			op.Value = json.RawMessage(value)
+		}
+		ops = append(ops, op)
+	}
+	return ops, rows.Err()
+}
+
+// ListOperations returns up to limit operations appended after seq, oldest first.
+func (s *SQLStore) ListOperations(ctx context.Context, collectionID string, after int64, limit int) ([]Operation, error) {
+	return s.queryOperations(ctx, `collection_id = ? AND seq > ? ORDER BY seq LIMIT ?`, collectionID, after, limit)
+}
+
+// ListOperationsForTarget returns every operation on one entity.
+func (s *SQLStore) ListOperationsForTarget(ctx context.Context, collectionID, target string) ([]Operation, error) {
+	return s.queryOperations(ctx, `collection_id = ? AND target = ? ORDER BY seq`, collectionID, target)
+}
+
+// ListPendingCreateTargets returns create operations whose entity has not been created
+// and was not deleted, such as a note whose deck had not arrived yet.
+func (s *SQLStore) ListPendingCreateTargets(ctx context.Context, collectionID string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT o.target FROM oplog o
+		WHERE o.collection_id = ? AND o.register = ?
+			AND NOT EXISTS (SELECT 1 FROM oplog_refs r WHERE r.collection_id = o.collection_id AND r.ref = o.target)
+			AND NOT EXISTS (SELECT 1 FROM oplog d WHERE d.collection_id = o.collection_id AND d.target = o.target AND d.register = ?)
+		ORDER BY o.seq
+	`, collectionID, opRegisterCreated, opRegisterDeleted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var targets []string
+	for rows.Next() {
+		var target string
+		if err := rows.Scan(&target); err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, rows.Err()
+}
+
+// OperationLogHead returns the latest seq and the highest Lamport time in a log.
+func (s *SQLStore) OperationLogHead(ctx context.Context, collectionID string) (int64, int64, error) {
+	var seq, lamport int64
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(seq), 0), COALESCE(MAX(lamport), 0) FROM oplog WHERE collection_id = ?`, collectionID).Scan(&seq, &lamport)
+	return seq, lamport, err
+}
+
+// ResolveOperationRef returns the entity a create operation made, or sql.ErrNoRows.
+func (s *SQLStore) ResolveOperationRef(ctx context.Context, collectionID, ref string) (int64, error) {
+	var id int64
+	err := s.db.QueryRowContext(ctx, `SELECT entity_id FROM oplog_refs WHERE collection_id = ? AND ref = ?`, collectionID, ref).Scan(&id)
+	return id, err
+}
+
+// OperationRefForEntity returns the create operation that made an entity, or
+// sql.ErrNoRows when it was not created through the log.
+func (s *SQLStore) OperationRefForEntity(ctx context.Context, collectionID, entityType string, id int64) (string, error) {
+	var ref string
+	err := s.db.QueryRowContext(ctx, `SELECT ref FROM oplog_refs WHERE collection_id = ? AND entity_type = ? AND entity_id = ? LIMIT 1`, collectionID, entityType, id).Scan(&ref)
+	return ref, err
+}
+
+func (s *SQLStore) SaveOperationRef(ctx context.Context, collectionID, ref, entityType string, id int64) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO oplog_refs (collection_id, ref, entity_type, entity_id) VALUES (?, ?, ?, ?)
+		ON CONFLICT (collection_id, ref) DO UPDATE SET entity_type = excluded.entity_type, entity_id = excluded.entity_id
+	`, collectionID, ref, entityType, id)
+	return err
+}
+
+// opLogRefs resolves the create operations among ops.
+func (h *APIHandler) opLogRefs(ctx context.Context, collectionID string, ops []Operation) (map[string]int64, error) {
+	refs := map[string]int64{}
+	for _, op := range ops {
+		if opRegister(op) != opRegisterCreated {
+			continue
+		}
+		id, err := h.store.ResolveOperationRef(ctx, collectionID, op.Target)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		refs[op.Target] = id
+	}
+	return refs, nil
+}
+
+// GetOpLog handles GET /api/oplog?after=&limit=.
+func (h *APIHandler) GetOpLog(w http.ResponseWriter, r *http.Request) {
+	collectionID := h.collectionIDForRequest(r)
+	var after int64
+	if raw := r.URL.Query().Get("after"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 0 {
+			respondAPIError(w, http.StatusBadRequest, "invalid_after", "after must be a non-negative integer")
+			return
+		}
+		after = parsed
+	}
+	limit := changeFeedLimit(r)
+
+	ops, err := h.store.ListOperations(r.Context(), collectionID, after, limit+1)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "oplog_load_failed", err.Error())
+		return
+	}
+	resp := OpLogPullResponse{Ops: ops, Seq: after}
+	if len(ops) > limit {
+		resp.Ops, resp.HasMore = ops[:limit], true
+	}
+	if len(resp.Ops) > 0 {
+		resp.Seq = resp.Ops[len(resp.Ops)-1].Seq
+	}
+	if resp.Refs, err = h.opLogRefs(r.Context(), collectionID, resp.Ops); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "oplog_load_failed", err.Error())
+		return
+	}
+	if _, resp.Lamport, err = h.store.OperationLogHead(r.Context(), collectionID); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "oplog_load_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// PushOpLog handles POST /api/oplog.
+func (h *APIHandler) PushOpLog(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	var req OpLogPushRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	if len(req.Ops) > maxSyncApplyEntities {
+		respondAPIError(w, http.StatusRequestEntityTooLarge, "oplog_batch_too_large",
+			fmt.Sprintf("Send at most %d operations per request", maxSyncApplyEntities))
+		return
+	}
+
+	userID := h.userIDFromRequest(r)
+	session := h.sessionFromRequest(r)
+	applier := &opLogApplier{
+		syncApplier: &syncApplier{
+			ctx:          r.Context(),
+			h:            h,
+			col:          col,
+			collectionID: collectionID,
+			userID:       userID,
+			changed:      map[string]bool{},
+			session:      session,
+			plan:         h.planForRequest(r, session),
+			usage:        h.usageForSession(r.Context(), session),
+			resp:         SyncApplyResponse{DeckIDs: map[int64]int64{}, NoteIDs: map[int64]int64{}},
+		},
+		fresh:    map[string]bool{},
+		failures: map[string]string{},
+	}
+	resp := OpLogPushResponse{Rejected: []OpLogRejection{}, NotApplied: []OpLogRejection{}}
+	var valid, appended []Operation
+	var targets []string
+	for i := range req.Ops {
+		op := req.Ops[i]
+		if err := validateOperation(&op); err != nil {
+			resp.Rejected = append(resp.Rejected, OpLogRejection{ID: op.ID, Reason: "invalid", Message: err.Error()})
+			continue
+		}
+		// Ops on an entity the log created are kept with the rest of that entity's ops.
+		if entityType, id, ok := parseEntityRef(op.Target); ok {
+			ref, err := h.store.OperationRefForEntity(r.Context(), collectionID, entityType, id)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				respondAPIError(w, http.StatusInternalServerError, "oplog_append_failed", err.Error())
+				return
+			}
+			if ref != "" {
+				op.Target = ref
+			}
+		}
+		valid = append(valid, op)
+		ok, err := h.store.AppendOperation(r.Context(), collectionID, userID, &op)
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "oplog_append_failed", err.Error())
+			return
+		}
+		if !ok {
+			resp.Duplicates++
+			continue
+		}
+		resp.Appended++
+		appended = append(appended, op)
+		applier.fresh[op.ID] = true
+		if !slices.Contains(targets, op.Target) {
+			targets = append(targets, op.Target)
+		}
+	}
+
+	pending, err := h.store.ListPendingCreateTargets(r.Context(), collectionID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "oplog_apply_failed", err.Error())
+		return
+	}
+	// Pending creates go first: their decks may have arrived in this push.
+	for _, target := range append(pending, targets...) {
+		if err := applier.reconcile(target); err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "oplog_apply_failed", err.Error())
+			return
+		}
+	}
+	if err := h.store.EnsureReviewStatesForUser(r.Context(), userID); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "oplog_apply_failed", err.Error())
+		return
+	}
+	if err := h.store.UpdateCollectionByID(r.Context(), collectionID, col); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "oplog_apply_failed", err.Error())
+		return
+	}
+
+	for _, op := range appended {
+		if message, failed := applier.failures[op.Target]; failed {
+			resp.NotApplied = append(resp.NotApplied, OpLogRejection{ID: op.ID, Reason: "not_applied", Message: message})
+		}
+	}
+	if resp.Refs, err = h.opLogRefs(r.Context(), collectionID, valid); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "oplog_apply_failed", err.Error())
+		return
+	}
+	if resp.Seq, resp.Lamport, err = h.store.OperationLogHead(r.Context(), collectionID); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "oplog_apply_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// opLogApplier carries out operations through the snapshot sync applier, which holds
+// the validation and plan limits for each change. Its conflicts become failures.
+type opLogApplier struct {
+	*syncApplier
+	fresh    map[string]bool   // IDs of operations appended by this push
+	failures map[string]string // target -> why its operations could not be applied
+}
+
+// run calls step and records any conflict it reports against target.
+func (a *opLogApplier) run(target string, step func() error) (bool, error) {
+	before := len(a.resp.Conflicts)
+	if err := step(); err != nil {
+		return false, err
+	}
+	if len(a.resp.Conflicts) > before {
+		conflict := a.resp.Conflicts[len(a.resp.Conflicts)-1]
+		a.failures[target] = strings.TrimSpace(conflict.Reason + ": " + conflict.Message)
+		return false, nil
+	}
+	return true, nil
+}
+
+// resolve finds the entity a target names, creating it from its create operation the
+// first time. created reports that every register should be written.
+func (a *opLogApplier) resolve(target string, winners map[string]Operation) (id int64, created, ok bool, err error) {
+	if _, id, ok := parseEntityRef(target); ok {
+		return id, false, true, nil
+	}
+	id, err = a.h.store.ResolveOperationRef(a.ctx, a.collectionID, target)
+	if err == nil {
+		return id, false, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, false, false, err
+	}
+	create, hasCreate := winners[opRegisterCreated]
+	if _, deleted := winners[opRegisterDeleted]; !hasCreate || deleted {
+		// Waiting for the create operation, or deleted before it was ever created.
+		return 0, false, false, nil
+	}
+
+	switch create.Kind {
+	case opDeckCreate:
+		var value OpDeckCreateValue
+		json.Unmarshal(create.Value, &value)
+		ok, err = a.run(target, func() error { return a.createDeck(SyncDeck{ID: -1, Name: value.Name}) })
+		id = a.resp.DeckIDs[-1]
+		delete(a.resp.DeckIDs, -1)
+	case opNoteCreate:
+		var value OpNoteCreateValue
+		json.Unmarshal(create.Value, &value)
+		deckID, deckOK, deckErr := a.deckForRef(value.Deck)
+		if deckErr != nil || !deckOK {
+			return 0, false, false, deckErr
+		}
+		note := SyncNote{Note: Note{ID: -1, Type: value.Type, FieldMap: value.Fields, Tags: value.Tags}, DeckID: deckID}
+		ok, err = a.run(target, func() error { return a.createNote(note) })
+		id = a.resp.NoteIDs[-1]
+		delete(a.resp.NoteIDs, -1)
+	}
+	if err != nil || !ok || id == 0 {
+		return 0, false, false, err
+	}
+	if err := a.h.store.SaveOperationRef(a.ctx, a.collectionID, target, opEntityType(create.Kind), id); err != nil {
+		return 0, false, false, err
+	}
+	return id, true, true, nil
+}
+
+// deckForRef resolves a note.create's deck, which may itself still be pending.
+func (a *opLogApplier) deckForRef(ref string) (int64, bool, error) {
+	if entityType, id, ok := parseEntityRef(ref); ok {
+		return id, entityType == "deck", nil
+	}
+	id, err := a.h.store.ResolveOperationRef(a.ctx, a.collectionID, ref)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return id, err == nil, err
+}
+
+// reconcile brings one entity in line with the winning operations on it. For an entity
+// that already existed, only registers won by this push are written, so edits made
+// outside the log since are not undone by older operations.
+func (a *opLogApplier) reconcile(target string) error {
+	ops, err := a.h.store.ListOperationsForTarget(a.ctx, a.collectionID, target)
+	if err != nil || len(ops) == 0 {
+		return err
+	}
+	entityType := opEntityType(ops[0].Kind)
+	ops = slices.DeleteFunc(ops, func(op Operation) bool { return opEntityType(op.Kind) != entityType })
+	winners := winningOps(ops)
+
+	id, created, ok, err := a.resolve(target, winners)
+	if err != nil || !ok {
+		return err
+	}
+	won := func(op Operation) bool { return created || a.fresh[op.ID] }
+
+	if del, deleted := winners[opRegisterDeleted]; deleted {
+		if !won(del) {
+			return nil
+		}
+		req := SyncApplyRequest{}
+		if entityType == "note" {
+			req.Deleted.Notes = []int64{id}
+		} else {
+			req.Deleted.Decks = []int64{id}
+		}
+		_, err := a.run(target, func() error { return a.applyDeletions(req) })
+		return err
+	}
+
+	registers := make([]string, 0, len(winners))
+	for register := range winners {
+		registers = append(registers, register)
+	}
+	slices.Sort(registers)
+
+	switch entityType {
+	case "deck":
+		rename, ok := winners["name"]
+		deck := a.col.Decks[id]
+		if !ok || !won(rename) || deck == nil {
+			return nil
+		}
+		var name string
+		json.Unmarshal(rename.Value, &name)
+		incoming := syncDeckFromDeck(deck)
+		incoming.Name = name
+		_, err := a.run(target, func() error { return a.applyDecks(SyncApplyRequest{Decks: []SyncDeck{incoming}}) })
+		return err
+	case "note":
+		if _, exists := a.col.Notes[id]; !exists {
+			a.failures[target] = "not_found: note " + strconv.FormatInt(id, 10)
+			return nil
+		}
+		note, err := a.h.store.GetNote(a.ctx, id)
+		if err != nil {
+			return fmt.Errorf("note %d: %w", id, err)
+		}
+		noteType := a.col.NoteTypes[note.Type]
+		changed := false
+		for _, register := range registers {
+			op := winners[register]
+			if !won(op) {
+				continue
+			}
+			switch op.Kind {
+			case opNoteSetField:
+				var value string
+				json.Unmarshal(op.Value, &value)
+				if !slices.Contains(noteType.Fields, op.Field) {
+					a.failures[target] = fmt.Sprintf("invalid: %s has no field %q", note.Type, op.Field)
+					continue
+				}
+				if note.FieldMap[op.Field] != value {
+					note.FieldMap[op.Field] = value
+					changed = true
+				}
+			case opNoteSetTag:
+				var present bool
+				json.Unmarshal(op.Value, &present)
+				if has := slices.Contains(note.Tags, op.Field); has != present {
+					if present {
+						note.Tags = append(note.Tags, op.Field)
+					} else {
+						note.Tags = slices.DeleteFunc(note.Tags, func(tag string) bool { return tag == op.Field })
+					}
+					changed = true
+				}
+			}
+		}
+		if !changed {
+			return nil
+		}
+		incoming := SyncNote{Note: Note{ID: id, Type: note.Type, FieldMap: note.FieldMap, Tags: note.Tags}}
+		_, err = a.run(target, func() error { return a.applyNotes(SyncApplyRequest{Notes: []SyncNote{incoming}}) })
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func opValue(t *testing.T, value interface{}) json.RawMessage {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("marshal op value failed: %v", err)
+	}
+	return data
+}
+
+func pushOpLog(t *testing.T, env *apiTestEnv, ops ...Operation) OpLogPushResponse {
+	t.Helper()
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/oplog", OpLogPushRequest{Ops: ops})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected oplog push 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	return decodeJSON[OpLogPushResponse](t, rr)
+}
+
+func TestMergeOpLogsIsDeterministic(t *testing.T) {
+	alice := []Operation{
+		{ID: "alice:1", Actor: "alice", Lamport: 1, Kind: opNoteSetField, Target: "note:1", Field: "Back"},
+		{ID: "alice:2", Actor: "alice", Lamport: 3, Kind: opNoteSetField, Target: "note:1", Field: "Front"},
+	}
+	bob := []Operation{
+		{ID: "bob:1", Actor: "bob", Lamport: 1, Kind: opNoteSetField, Target: "note:1", Field: "Back"},
+		alice[0],
+	}
+	ab, ba := mergeOpLogs(alice, bob), mergeOpLogs(bob, alice)
+	if len(ab) != 3 || !slices.EqualFunc(ab, ba, func(a, b Operation) bool { return a.ID == b.ID }) {
+		t.Fatalf("expected the same merged order either way, got %v and %v", ab, ba)
+	}
+	if winner := winningOps(ab)["field:Back"]; winner.ID != "bob:1" {
+		t.Fatalf("expected the tie broken by actor, got %+v", winner)
+	}
+}
+
+func TestAPI_OpLogMergesConcurrentEdits(t *testing.T) {
+	env := setupAPITestEnv(t)
+	created := pushOpLog(t, env,
+		Operation{ID: "alice:1", Actor: "alice", Lamport: 1, Kind: opDeckCreate, Value: opValue(t, OpDeckCreateValue{Name: "Distributed"})},
+		Operation{ID: "alice:2", Actor: "alice", Lamport: 2, Kind: opNoteCreate, Value: opValue(t, OpNoteCreateValue{
+			Type: "Basic", Deck: "alice:1", Fields: map[string]string{"Front": "Paxos", "Back": "Consensus"},
+		})},
+	)
+	noteID := created.Refs["alice:2"]
+	if created.Appended != 2 || created.Refs["alice:1"] <= 1 || noteID <= 0 || len(created.NotApplied) != 0 {
+		t.Fatalf("expected the deck and note created, got %+v", created)
+	}
+
+	// Bob pulls, then both edit Back at the same Lamport time; bob wins the tie either way.
+	pulled := decodeJSON[OpLogPullResponse](t, doRawRequest(env.router, http.MethodGet, "/api/oplog?after=0", ""))
+	if len(pulled.Ops) != 2 || pulled.Refs["alice:2"] != noteID || pulled.Lamport != 2 {
+		t.Fatalf("unexpected pull %+v", pulled)
+	}
+	pushOpLog(t, env,
+		Operation{ID: "bob:1", Actor: "bob", Lamport: 3, Kind: opNoteSetField, Target: "alice:2", Field: "Back", Value: opValue(t, "Agreement among replicas")},
+		Operation{ID: "bob:2", Actor: "bob", Lamport: 5, Kind: opNoteSetTag, Target: fmt.Sprintf("note:%d", noteID), Field: "consensus", Value: opValue(t, false)},
+	)
+	aliceEdits := []Operation{
+		{ID: "alice:3", Actor: "alice", Lamport: 3, Kind: opNoteSetField, Target: "alice:2", Field: "Back", Value: opValue(t, "Leader election")},
+		{ID: "alice:4", Actor: "alice", Lamport: 3, Kind: opNoteSetField, Target: "alice:2", Field: "Front", Value: opValue(t, "Paxos (Lamport)")},
+		{ID: "alice:5", Actor: "alice", Lamport: 4, Kind: opNoteSetTag, Target: "alice:2", Field: "consensus", Value: opValue(t, true)},
+	}
+	pushOpLog(t, env, aliceEdits...)
+
+	note, err := env.store.GetNote(context.Background(), noteID)
+	if err != nil {
+		t.Fatalf("load note failed: %v", err)
+	}
+	if note.FieldMap["Back"] != "Agreement among replicas" || note.FieldMap["Front"] != "Paxos (Lamport)" || slices.Contains(note.Tags, "consensus") {
+		t.Fatalf("expected both edits merged by Lamport order, got %+v", note)
+	}
+
+	retry := pushOpLog(t, env, append(aliceEdits, Operation{ID: "alice:6", Actor: "alice", Kind: opNoteSetField})...)
+	if retry.Appended != 0 || retry.Duplicates != 3 || len(retry.Rejected) != 1 || retry.Rejected[0].Reason != "invalid" {
+		t.Fatalf("expected duplicates skipped and the bad op rejected, got %+v", retry)
+	}
+
+	// A delete wins over a later edit from another actor.
+	pushOpLog(t, env, Operation{ID: "bob:3", Actor: "bob", Lamport: 6, Kind: opNoteDelete, Target: fmt.Sprintf("note:%d", noteID)})
+	pushOpLog(t, env, Operation{ID: "alice:7", Actor: "alice", Lamport: 9, Kind: opNoteSetField, Target: "alice:2", Field: "Back", Value: opValue(t, "Too late")})
+	if _, err := env.store.GetNote(context.Background(), noteID); err == nil {
+		t.Fatalf("expected the note to stay deleted")
+	}
+
+	rest := decodeJSON[OpLogPullResponse](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/oplog?after=%d", pulled.Seq), ""))
+	if len(rest.Ops) != 7 || rest.Ops[1].Target != "alice:2" || rest.Lamport != 9 {
+		t.Fatalf("expected later ops with entity targets mapped onto their create op, got %+v", rest)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// A personal data export is a ZIP archive of everything the server holds for the
+// signed-in user in the selected collection, for data portability: their account and
+// API tokens (never the secrets), the collection's preferences, its notes and cards
+// with the user's scheduling state and review log (the collection export document),
+// their study sessions, and the media files. manifest.json says what is in it.
+// Unlike a backup it holds no one else's data and cannot be restored.
+
+const (
+	personalDataExportFormat  = "microdote.personal-data"
+	personalDataExportVersion = 1
+)
+
+// PersonalDataManifest is manifest.json in a personal data export.
+type PersonalDataManifest struct {
+	Format        string    `json:"format"`  // always "microdote.personal-data"
+	Version       int       `json:"version"` // format version, currently 1
+	ExportedAt    time.Time `json:"exportedAt"`
+	UserID        string    `json:"userId"`
+	CollectionID  string    `json:"collectionId"`
+	Files         []string  `json:"files"` // every other file in the archive
+	Notes         int       `json:"notes"`
+	Cards         int       `json:"cards"`
+	Reviews       int       `json:"reviews"`
+	StudySessions int       `json:"studySessions"`
+	Media         int       `json:"media"`
+}
+
+// PersonalDataAccount is account.json in a personal data export.
+type PersonalDataAccount struct {
+	User      *User      `json:"user"`
+	APITokens []APIToken `json:"apiTokens"`
+}
+
+// ExportPersonalData serves GET /api/account/export.
+func (h *APIHandler) ExportPersonalData(w http.ResponseWriter, r *http.Request) {
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	userID := h.userIDFromRequest(r)
+	now := time.Now()
+
+	user, err := h.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "export_failed", fmt.Sprintf("failed to load account: %v", err))
+		return
+	}
+	tokens, err := h.store.ListAPITokens(r.Context(), userID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "export_failed", fmt.Sprintf("failed to load API tokens: %v", err))
+		return
+	}
+	prefs, err := h.store.GetCollectionPreferences(r.Context(), collectionID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "export_failed", fmt.Sprintf("failed to load preferences: %v", err))
+		return
+	}
+	collection, err := h.buildCollectionExport(r.Context(), collectionID, col, userID, now)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "export_failed", err.Error())
+		return
+	}
+	sessions, err := h.store.ListStudySessionsForUser(r.Context(), userID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "export_failed", fmt.Sprintf("failed to load study sessions: %v", err))
+		return
+	}
+	media, err := h.store.ListMediaFilenames(r.Context(), collectionID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "export_failed", fmt.Sprintf("failed to list media: %v", err))
+		return
+	}
+
+	documents := []struct {
+		name  string
+		value any
+	}{
+		{"account.json", PersonalDataAccount{User: user, APITokens: tokens}},
+		{"preferences.json", prefs},
+		{"collection.json", collection},
+		{"study_sessions.json", sessions},
+	}
+	manifest := PersonalDataManifest{
+		Format:        personalDataExportFormat,
+		Version:       personalDataExportVersion,
+		ExportedAt:    now.UTC(),
+		UserID:        userID,
+		CollectionID:  collectionID,
+		Files:         []string{},
+		Notes:         len(collection.Notes),
+		Cards:         len(collection.Cards),
+		Reviews:       len(collection.Revlog),
+		StudySessions: len(sessions),
+		Media:         len(media),
+	}
+	for _, doc := range documents {
+		manifest.Files = append(manifest.Files, doc.name)
+	}
+	for _, filename := range media {
+		manifest.Files = append(manifest.Files, "media/"+filename)
+	}
+
+	filename := "microdote-personal-data-" + now.UTC().Format("20060102") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.WriteHeader(http.StatusOK)
+
+	// The status is already sent, so an error part-way can only cut the archive short,
+	// which leaves it without the central directory a ZIP reader needs.
+	archive := zip.NewWriter(w)
+	if err := writeZipJSON(archive, "manifest.json", manifest); err != nil {
+		return
+	}
+	for _, doc := range documents {
+		if err := writeZipJSON(archive, doc.name, doc.value); err != nil {
+			return
+		}
+	}
+	for _, filename := range media {
+		ref, err := h.store.GetCollectionMedia(r.Context(), collectionID, filename)
+		if err != nil {
+			return
+		}
+		entry, err := archive.CreateHeader(&zip.FileHeader{Name: "media/" + filename, Method: zip.Store, Modified: ref.AddedAt})
+		if err != nil {
+			return
+		}
+		if _, err := entry.Write(ref.Data); err != nil {
+			return
+		}
+	}
+	_ = archive.Close()
+}
+
+func writeZipJSON(archive *zip.Writer, name string, value any) error {
+	entry, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
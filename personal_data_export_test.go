@@ -0,0 +1,70 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestAPI_ExportPersonalDataBundlesEverything(t *testing.T) {
+	env := setupAPITestEnv(t)
+	createNoteForTest(t, env, CreateNoteRequest{TypeID: "Basic", DeckID: 1, FieldVals: map[string]string{"Front": "mine", "Back": "a"}}, nil)
+	if rr := doMediaUploadRequest(t, env.router, env.authCookie, "cat.png", []byte("PNG one")); rr.Code != http.StatusCreated {
+		t.Fatalf("expected upload 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr := doRawRequest(env.router, http.MethodGet, "/api/account/export", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected export 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/zip" {
+		t.Fatalf("expected a ZIP archive, got %q", got)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatalf("failed to read export archive: %v", err)
+	}
+	files := map[string][]byte{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", file.Name, err)
+		}
+		files[file.Name], _ = io.ReadAll(reader)
+		_ = reader.Close()
+	}
+
+	var manifest PersonalDataManifest
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+		t.Fatalf("failed to decode manifest: %v", err)
+	}
+	if manifest.Format != personalDataExportFormat || manifest.Notes != 1 || manifest.Media != 1 {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+	for _, name := range manifest.Files {
+		if _, ok := files[name]; !ok {
+			t.Fatalf("manifest lists %s, which the archive lacks", name)
+		}
+	}
+	if string(files["media/cat.png"]) != "PNG one" {
+		t.Fatalf("expected the media file in the archive, got %q", files["media/cat.png"])
+	}
+
+	var collection CollectionExport
+	if err := json.Unmarshal(files["collection.json"], &collection); err != nil {
+		t.Fatalf("failed to decode collection: %v", err)
+	}
+	if len(collection.Notes) != 1 || collection.Notes[0].Fields["Front"] != "mine" {
+		t.Fatalf("expected the note in collection.json, got %+v", collection.Notes)
+	}
+	var account PersonalDataAccount
+	if err := json.Unmarshal(files["account.json"], &account); err != nil {
+		t.Fatalf("failed to decode account: %v", err)
+	}
+	if account.User == nil || account.User.Email != "test@example.com" {
+		t.Fatalf("expected the signed-in account, got %+v", account.User)
+	}
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"net/http"
 	"strings"
@@ -66,15 +65,15 @@ func (h *APIHandler) organizationAccessForRequest(r *http.Request, orgID string)
 	if session == nil || session.UserID == "" {
 		return nil, nil, nil, nil, sql.ErrNoRows
 	}
-	org, err := h.store.GetOrganizationRecord(orgID)
+	org, err := h.store.GetOrganizationRecord(r.Context(), orgID)
 	if err != nil {
 		return nil, nil, nil, session, err
 	}
-	member, err := h.store.GetOrganizationMemberByUser(orgID, session.UserID)
+	member, err := h.store.GetOrganizationMemberByUser(r.Context(), orgID, session.UserID)
 	if err != nil {
 		return nil, nil, nil, session, err
 	}
-	workspace, err := h.store.GetWorkspaceForOrganization(orgID)
+	workspace, err := h.store.GetWorkspaceForOrganization(r.Context(), orgID)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return nil, nil, nil, session, err
 	}
@@ -84,34 +83,34 @@ func (h *APIHandler) organizationAccessForRequest(r *http.Request, orgID string)
 	return org, member, workspace, session, nil
 }
 
-func (h *APIHandler) organizationSubscription(orgID string, workspaceID string) *Subscription {
+func (h *APIHandler) organizationSubscription(ctx context.Context, orgID string, workspaceID string) *Subscription {
 	if workspaceID != "" {
-		if subscription, err := h.store.GetSubscriptionForWorkspace(workspaceID); err == nil {
+		if subscription, err := h.store.GetSubscriptionForWorkspace(ctx, workspaceID); err == nil {
 			return subscription
 		}
 	}
 	if orgID != "" {
-		if subscription, err := h.store.GetSubscriptionForOrganization(orgID); err == nil {
+		if subscription, err := h.store.GetSubscriptionForOrganization(ctx, orgID); err == nil {
 			return subscription
 		}
 	}
 	return nil
 }
 
-func (h *APIHandler) buildOrganizationDetail(orgID, userID string) (*OrganizationDetail, error) {
-	org, err := h.store.GetOrganizationRecord(orgID)
+func (h *APIHandler) buildOrganizationDetail(ctx context.Context, orgID, userID string) (*OrganizationDetail, error) {
+	org, err := h.store.GetOrganizationRecord(ctx, orgID)
 	if err != nil {
 		return nil, err
 	}
-	member, err := h.store.GetOrganizationMemberByUser(orgID, userID)
+	member, err := h.store.GetOrganizationMemberByUser(ctx, orgID, userID)
 	if err != nil {
 		return nil, err
 	}
-	members, err := h.store.ListOrganizationMembers(orgID)
+	members, err := h.store.ListOrganizationMembers(ctx, orgID)
 	if err != nil {
 		return nil, err
 	}
-	workspace, err := h.store.GetWorkspaceForOrganization(orgID)
+	workspace, err := h.store.GetWorkspaceForOrganization(ctx, orgID)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return nil, err
 	}
@@ -129,13 +128,13 @@ func (h *APIHandler) buildOrganizationDetail(orgID, userID string) (*Organizatio
 		CanEdit:          canWriteWorkspaceContent(member.Role),
 	}
 	if workspace != nil {
-		detail.Subscription = h.organizationSubscription(org.ID, workspace.ID)
+		detail.Subscription = h.organizationSubscription(ctx, org.ID, workspace.ID)
 	}
 	return detail, nil
 }
 
-func (h *APIHandler) ensureOrganizationWorkspace(org *Organization, session *SessionRecord) (*Workspace, error) {
-	if workspace, err := h.store.GetWorkspaceForOrganization(org.ID); err == nil {
+func (h *APIHandler) ensureOrganizationWorkspace(ctx context.Context, org *Organization, session *SessionRecord) (*Workspace, error) {
+	if workspace, err := h.store.GetWorkspaceForOrganization(ctx, org.ID); err == nil {
 		return workspace, nil
 	} else if !errors.Is(err, sql.ErrNoRows) {
 		return nil, err
@@ -143,17 +142,17 @@ func (h *APIHandler) ensureOrganizationWorkspace(org *Organization, session *Ses
 
 	collectionID := newID("col")
 	collection := NewCollection()
-	if err := h.store.CreateCollectionRecord(collectionID, org.Name, collection); err != nil {
+	if err := h.store.CreateCollectionRecord(ctx, collectionID, org.Name, collection); err != nil {
 		return nil, err
 	}
 	for _, nt := range builtins() {
 		ntCopy := nt
-		if err := h.store.CreateNoteType(collectionID, &ntCopy); err != nil {
+		if err := h.store.CreateNoteType(ctx, collectionID, &ntCopy); err != nil {
 			return nil, err
 		}
 	}
 	defaultDeck := collection.NewDeck("Default")
-	if err := h.store.CreateDeckInCollection(collectionID, defaultDeck); err != nil {
+	if err := h.store.CreateDeckInCollection(ctx, collectionID, defaultDeck); err != nil {
 		return nil, err
 	}
 
@@ -168,16 +167,16 @@ func (h *APIHandler) ensureOrganizationWorkspace(org *Organization, session *Ses
 		CreatedAt:      now,
 		UpdatedAt:      now,
 	}
-	if err := h.store.CreateWorkspaceRecord(workspace); err != nil {
+	if err := h.store.CreateWorkspaceRecord(ctx, workspace); err != nil {
 		return nil, err
 	}
 	return workspace, nil
 }
 
-func (h *APIHandler) ensureWorkspaceAttachedToOrganization(workspace *Workspace, org *Organization) error {
+func (h *APIHandler) ensureWorkspaceAttachedToOrganization(ctx context.Context, workspace *Workspace, org *Organization) error {
 	workspace.OrganizationID = org.ID
 	workspace.UpdatedAt = time.Now()
-	return h.store.UpdateWorkspaceRecord(workspace)
+	return h.store.UpdateWorkspaceRecord(ctx, workspace)
 }
 
 func (h *APIHandler) activeOrganizationMemberForCurrentWorkspace(r *http.Request) (*Workspace, *OrganizationMember, *SessionRecord, error) {
@@ -185,14 +184,14 @@ func (h *APIHandler) activeOrganizationMemberForCurrentWorkspace(r *http.Request
 	if session == nil || session.UserID == "" {
 		return nil, nil, nil, sql.ErrNoRows
 	}
-	workspace, err := h.workspaceForSession(session)
+	workspace, err := h.workspaceForSession(r.Context(), session)
 	if err != nil || workspace == nil {
 		return nil, nil, session, err
 	}
 	if workspace.OrganizationID == "" {
 		return workspace, nil, session, nil
 	}
-	member, err := h.store.GetOrganizationMemberByUser(workspace.OrganizationID, session.UserID)
+	member, err := h.store.GetOrganizationMemberByUser(r.Context(), workspace.OrganizationID, session.UserID)
 	if err != nil {
 		return workspace, nil, session, err
 	}
@@ -225,24 +224,19 @@ func (h *APIHandler) CompleteOnboardingPlanSelection(w http.ResponseWriter, r *h
 		respondAPIError(w, http.StatusUnauthorized, "auth_required", "You must be signed in to choose a plan.")
 		return
 	}
-	user, err := h.store.GetUserByID(session.UserID)
+	user, err := h.store.GetUserByID(r.Context(), session.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "user_lookup_failed", err.Error())
 		return
 	}
-	workspace, err := h.workspaceForSession(session)
+	workspace, err := h.workspaceForSession(r.Context(), session)
 	if err != nil || workspace == nil {
 		respondAPIError(w, http.StatusInternalServerError, "workspace_not_found", "Workspace not found.")
 		return
 	}
 
 	var req UpdateWorkspacePlanRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
-		return
-	}
-	if !validManagedPlan(req.Plan) {
-		respondAPIError(w, http.StatusBadRequest, "invalid_plan", "Choose free, pro, team, or enterprise.")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 	if _, disabled := h.subscriptionBilling.(*disabledSubscriptionBillingProvider); !disabled {
@@ -266,7 +260,7 @@ func (h *APIHandler) CompleteOnboardingPlanSelection(w http.ResponseWriter, r *h
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		}
-		if err := h.store.CreateOrganizationRecord(org); err != nil {
+		if err := h.store.CreateOrganizationRecord(r.Context(), org); err != nil {
 			respondAPIError(w, http.StatusInternalServerError, "organization_create_failed", err.Error())
 			return
 		}
@@ -280,11 +274,11 @@ func (h *APIHandler) CompleteOnboardingPlanSelection(w http.ResponseWriter, r *h
 			JoinedAt:       time.Now(),
 			CreatedAt:      time.Now(),
 		}
-		if err := h.store.CreateOrganizationMemberRecord(member); err != nil {
+		if err := h.store.CreateOrganizationMemberRecord(r.Context(), member); err != nil {
 			respondAPIError(w, http.StatusInternalServerError, "organization_member_failed", err.Error())
 			return
 		}
-		if err := h.ensureWorkspaceAttachedToOrganization(workspace, org); err != nil {
+		if err := h.ensureWorkspaceAttachedToOrganization(r.Context(), workspace, org); err != nil {
 			respondAPIError(w, http.StatusInternalServerError, "workspace_attach_failed", err.Error())
 			return
 		}
@@ -301,7 +295,7 @@ func (h *APIHandler) CompleteOnboardingPlanSelection(w http.ResponseWriter, r *h
 
 func (h *APIHandler) applyWorkspacePlanChange(r *http.Request, session *SessionRecord, workspace *Workspace, plan Plan, clearOnboarding bool) (*AuthSessionResponse, error) {
 	now := time.Now()
-	subscription := h.organizationSubscription(workspace.OrganizationID, workspace.ID)
+	subscription := h.organizationSubscription(r.Context(), workspace.OrganizationID, workspace.ID)
 	if subscription == nil {
 		subscription = &Subscription{
 			ID:             newID("sub"),
@@ -318,11 +312,11 @@ func (h *APIHandler) applyWorkspacePlanChange(r *http.Request, session *SessionR
 		subscription.Provider = "manual"
 	}
 	subscription.UpdatedAt = now
-	if err := h.store.UpsertSubscription(subscription); err != nil {
+	if err := h.store.UpsertSubscription(r.Context(), subscription); err != nil {
 		return nil, err
 	}
 	if clearOnboarding {
-		if err := h.store.UpdateUserOnboarding(session.UserID, false); err != nil {
+		if err := h.store.UpdateUserOnboarding(r.Context(), session.UserID, false); err != nil {
 			return nil, err
 		}
 	}
@@ -341,19 +335,14 @@ func (h *APIHandler) UpdateWorkspacePlan(w http.ResponseWriter, r *http.Request)
 		respondAPIError(w, http.StatusForbidden, "workspace_mismatch", "Plan changes are only supported for the current workspace.")
 		return
 	}
-	workspace, err := h.workspaceForSession(session)
+	workspace, err := h.workspaceForSession(r.Context(), session)
 	if err != nil || workspace == nil {
 		respondAPIError(w, http.StatusInternalServerError, "workspace_not_found", "Workspace not found.")
 		return
 	}
 
 	var req UpdateWorkspacePlanRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
-		return
-	}
-	if !validManagedPlan(req.Plan) {
-		respondAPIError(w, http.StatusBadRequest, "invalid_plan", "Choose free, pro, team, or enterprise.")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 	if _, disabled := h.subscriptionBilling.(*disabledSubscriptionBillingProvider); !disabled {
@@ -377,7 +366,7 @@ func (h *APIHandler) UpdateWorkspacePlan(w http.ResponseWriter, r *http.Request)
 			return
 		}
 		if req.Plan == PlanTeam || req.Plan == PlanEnterprise {
-			user, err := h.store.GetUserByID(session.UserID)
+			user, err := h.store.GetUserByID(r.Context(), session.UserID)
 			if err != nil {
 				respondAPIError(w, http.StatusInternalServerError, "user_lookup_failed", err.Error())
 				return
@@ -389,7 +378,7 @@ func (h *APIHandler) UpdateWorkspacePlan(w http.ResponseWriter, r *http.Request)
 				CreatedAt: time.Now(),
 				UpdatedAt: time.Now(),
 			}
-			if err := h.store.CreateOrganizationRecord(org); err != nil {
+			if err := h.store.CreateOrganizationRecord(r.Context(), org); err != nil {
 				respondAPIError(w, http.StatusInternalServerError, "organization_create_failed", err.Error())
 				return
 			}
@@ -403,18 +392,18 @@ func (h *APIHandler) UpdateWorkspacePlan(w http.ResponseWriter, r *http.Request)
 				JoinedAt:       time.Now(),
 				CreatedAt:      time.Now(),
 			}
-			if err := h.store.CreateOrganizationMemberRecord(member); err != nil {
+			if err := h.store.CreateOrganizationMemberRecord(r.Context(), member); err != nil {
 				respondAPIError(w, http.StatusInternalServerError, "organization_member_failed", err.Error())
 				return
 			}
-			if err := h.ensureWorkspaceAttachedToOrganization(workspace, org); err != nil {
+			if err := h.ensureWorkspaceAttachedToOrganization(r.Context(), workspace, org); err != nil {
 				respondAPIError(w, http.StatusInternalServerError, "workspace_attach_failed", err.Error())
 				return
 			}
 			workspace.OrganizationID = org.ID
 		}
 	} else {
-		member, err := h.store.GetOrganizationMemberByUser(workspace.OrganizationID, session.UserID)
+		member, err := h.store.GetOrganizationMemberByUser(r.Context(), workspace.OrganizationID, session.UserID)
 		if err != nil || member.Status != "active" || !canManageOrganizationPlan(member.Role) {
 			respondAPIError(w, http.StatusForbidden, "plan_forbidden", "Only team admins and owners can manage this plan.")
 			return
@@ -444,7 +433,7 @@ func (h *APIHandler) GetOrganization(w http.ResponseWriter, r *http.Request) {
 		respondAPIError(w, http.StatusForbidden, "organization_forbidden", "Your membership is not active.")
 		return
 	}
-	detail, err := h.buildOrganizationDetail(org.ID, session.UserID)
+	detail, err := h.buildOrganizationDetail(r.Context(), org.ID, session.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "organization_detail_failed", err.Error())
 		return
@@ -467,7 +456,7 @@ func (h *APIHandler) ListOrganizationMembers(w http.ResponseWriter, r *http.Requ
 		respondAPIError(w, http.StatusForbidden, "organization_forbidden", "Your membership is not active.")
 		return
 	}
-	members, err := h.store.ListOrganizationMembers(orgID)
+	members, err := h.store.ListOrganizationMembers(r.Context(), orgID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "organization_members_failed", err.Error())
 		return
@@ -492,8 +481,7 @@ func (h *APIHandler) UpdateOrganization(w http.ResponseWriter, r *http.Request)
 	}
 
 	var req UpdateOrganizationRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 	if trimmed := strings.TrimSpace(req.Name); trimmed != "" {
@@ -503,19 +491,19 @@ func (h *APIHandler) UpdateOrganization(w http.ResponseWriter, r *http.Request)
 		org.Slug = slugify(trimmed)
 	}
 	org.UpdatedAt = time.Now()
-	if err := h.store.UpdateOrganizationRecord(org); err != nil {
+	if err := h.store.UpdateOrganizationRecord(r.Context(), org); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "organization_update_failed", err.Error())
 		return
 	}
 	if workspace != nil {
 		workspace.Name = org.Name
 		workspace.UpdatedAt = time.Now()
-		if err := h.store.UpdateWorkspaceRecord(workspace); err != nil {
+		if err := h.store.UpdateWorkspaceRecord(r.Context(), workspace); err != nil {
 			respondAPIError(w, http.StatusInternalServerError, "workspace_update_failed", err.Error())
 			return
 		}
 	}
-	detail, err := h.buildOrganizationDetail(org.ID, session.UserID)
+	detail, err := h.buildOrganizationDetail(r.Context(), org.ID, session.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "organization_detail_failed", err.Error())
 		return
@@ -538,7 +526,7 @@ func (h *APIHandler) DeleteOrganization(w http.ResponseWriter, r *http.Request)
 		respondAPIError(w, http.StatusForbidden, "organization_forbidden", "Only the team owner can delete this team.")
 		return
 	}
-	members, err := h.store.ListOrganizationMembers(org.ID)
+	members, err := h.store.ListOrganizationMembers(r.Context(), org.ID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "organization_members_failed", err.Error())
 		return
@@ -547,7 +535,7 @@ func (h *APIHandler) DeleteOrganization(w http.ResponseWriter, r *http.Request)
 	for i := range members {
 		members[i].Status = "removed"
 		members[i].RemovedAt = now
-		if err := h.store.UpdateOrganizationMember(&members[i]); err != nil {
+		if err := h.store.UpdateOrganizationMember(r.Context(), &members[i]); err != nil {
 			respondAPIError(w, http.StatusInternalServerError, "organization_member_update_failed", err.Error())
 			return
 		}
@@ -555,12 +543,12 @@ func (h *APIHandler) DeleteOrganization(w http.ResponseWriter, r *http.Request)
 	if workspace != nil {
 		workspace.OrganizationID = ""
 		workspace.UpdatedAt = now
-		if err := h.store.UpdateWorkspaceRecord(workspace); err != nil {
+		if err := h.store.UpdateWorkspaceRecord(r.Context(), workspace); err != nil {
 			respondAPIError(w, http.StatusInternalServerError, "workspace_update_failed", err.Error())
 			return
 		}
 	}
-	if err := h.store.DeleteOrganizationRecord(org.ID); err != nil {
+	if err := h.store.DeleteOrganizationRecord(r.Context(), org.ID); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "organization_delete_failed", err.Error())
 		return
 	}
@@ -583,23 +571,19 @@ func (h *APIHandler) UpdateOrganizationMember(w http.ResponseWriter, r *http.Req
 		respondAPIError(w, http.StatusForbidden, "organization_forbidden", "Only team admins and owners can manage members.")
 		return
 	}
-	target, err := h.store.GetOrganizationMember(targetID)
+	target, err := h.store.GetOrganizationMember(r.Context(), targetID)
 	if err != nil || target.OrganizationID != orgID {
 		respondAPIError(w, http.StatusNotFound, "organization_member_not_found", "Team member not found.")
 		return
 	}
 
 	var req UpdateOrganizationMemberRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
-	if strings.TrimSpace(req.Role) != "" {
-		if !validOrganizationRole(req.Role) {
-			respondAPIError(w, http.StatusBadRequest, "invalid_role", "Role must be one of read, edit, admin, or owner.")
-			return
-		}
+	req.Role, req.Status = strings.TrimSpace(req.Role), strings.TrimSpace(req.Status)
+	if req.Role != "" {
 		if req.Role == "owner" && currentMember.Role != "owner" {
 			respondAPIError(w, http.StatusForbidden, "organization_forbidden", "Only the current owner can assign ownership.")
 			return
@@ -610,11 +594,7 @@ func (h *APIHandler) UpdateOrganizationMember(w http.ResponseWriter, r *http.Req
 		}
 		target.Role = req.Role
 	}
-	if strings.TrimSpace(req.Status) != "" {
-		if !validOrganizationMemberStatus(req.Status) {
-			respondAPIError(w, http.StatusBadRequest, "invalid_status", "Invalid team membership status.")
-			return
-		}
+	if req.Status != "" {
 		if target.Role == "owner" && currentMember.Role != "owner" && req.Status != target.Status {
 			respondAPIError(w, http.StatusForbidden, "organization_forbidden", "Only the current owner can change the owner membership.")
 			return
@@ -628,12 +608,12 @@ func (h *APIHandler) UpdateOrganizationMember(w http.ResponseWriter, r *http.Req
 			target.RemovedAt = now
 		}
 	}
-	if err := h.store.UpdateOrganizationMember(target); err != nil {
+	if err := h.store.UpdateOrganizationMember(r.Context(), target); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "organization_member_update_failed", err.Error())
 		return
 	}
 	workspaceID := ""
-	if workspace, err := h.store.GetWorkspaceForOrganization(orgID); err == nil {
+	if workspace, err := h.store.GetWorkspaceForOrganization(r.Context(), orgID); err == nil {
 		workspaceID = workspace.ID
 	}
 	if err := h.reconcileOrganizationSeatBilling(r.Context(), orgID, workspaceID); err != nil {
@@ -659,7 +639,7 @@ func (h *APIHandler) DeleteOrganizationMember(w http.ResponseWriter, r *http.Req
 		respondAPIError(w, http.StatusForbidden, "organization_forbidden", "Only team admins and owners can manage members.")
 		return
 	}
-	target, err := h.store.GetOrganizationMember(targetID)
+	target, err := h.store.GetOrganizationMember(r.Context(), targetID)
 	if err != nil || target.OrganizationID != orgID {
 		respondAPIError(w, http.StatusNotFound, "organization_member_not_found", "Team member not found.")
 		return
@@ -670,12 +650,12 @@ func (h *APIHandler) DeleteOrganizationMember(w http.ResponseWriter, r *http.Req
 	}
 	target.Status = "removed"
 	target.RemovedAt = time.Now()
-	if err := h.store.UpdateOrganizationMember(target); err != nil {
+	if err := h.store.UpdateOrganizationMember(r.Context(), target); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "organization_member_update_failed", err.Error())
 		return
 	}
 	workspaceID := ""
-	if workspace, err := h.store.GetWorkspaceForOrganization(orgID); err == nil {
+	if workspace, err := h.store.GetWorkspaceForOrganization(r.Context(), orgID); err == nil {
 		workspaceID = workspace.ID
 	}
 	if err := h.reconcileOrganizationSeatBilling(r.Context(), orgID, workspaceID); err != nil {
@@ -693,11 +673,10 @@ func (h *APIHandler) JoinOrganization(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req JoinOrganizationRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
-	member, err := h.store.GetOrganizationMemberByInviteToken(strings.TrimSpace(req.Token))
+	member, err := h.store.GetOrganizationMemberByInviteToken(r.Context(), strings.TrimSpace(req.Token))
 	if err != nil {
 		respondAPIError(w, http.StatusNotFound, "organization_invite_not_found", "Invite not found.")
 		return
@@ -708,11 +687,11 @@ func (h *APIHandler) JoinOrganization(w http.ResponseWriter, r *http.Request) {
 	}
 	if !member.InviteExpiresAt.IsZero() && member.InviteExpiresAt.Before(time.Now()) {
 		member.Status = "expired"
-		_ = h.store.UpdateOrganizationMember(member)
+		_ = h.store.UpdateOrganizationMember(r.Context(), member)
 		respondAPIError(w, http.StatusBadRequest, "organization_invite_expired", "That invite has expired.")
 		return
 	}
-	user, err := h.store.GetUserByID(session.UserID)
+	user, err := h.store.GetUserByID(r.Context(), session.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "user_lookup_failed", err.Error())
 		return
@@ -722,7 +701,7 @@ func (h *APIHandler) JoinOrganization(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	orgWorkspace, err := h.store.GetWorkspaceForOrganization(member.OrganizationID)
+	orgWorkspace, err := h.store.GetWorkspaceForOrganization(r.Context(), member.OrganizationID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "organization_workspace_failed", "Team workspace not found.")
 		return
@@ -732,7 +711,7 @@ func (h *APIHandler) JoinOrganization(w http.ResponseWriter, r *http.Request) {
 	member.JoinedAt = time.Now()
 	member.InviteToken = ""
 	member.InviteExpiresAt = time.Time{}
-	if err := h.store.UpdateOrganizationMember(member); err != nil {
+	if err := h.store.UpdateOrganizationMember(r.Context(), member); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "organization_join_failed", err.Error())
 		return
 	}
@@ -740,12 +719,12 @@ func (h *APIHandler) JoinOrganization(w http.ResponseWriter, r *http.Request) {
 		respondAPIError(w, http.StatusInternalServerError, "organization_billing_failed", err.Error())
 		return
 	}
-	if err := h.store.UpdateSessionWorkspace(session.ID, orgWorkspace.ID); err != nil {
+	if err := h.store.UpdateSessionWorkspace(r.Context(), session.ID, orgWorkspace.ID); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "session_workspace_failed", err.Error())
 		return
 	}
 	session.WorkspaceID = orgWorkspace.ID
-	detail, err := h.buildOrganizationDetail(member.OrganizationID, session.UserID)
+	detail, err := h.buildOrganizationDetail(r.Context(), member.OrganizationID, session.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "organization_detail_failed", err.Error())
 		return
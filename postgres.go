@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// PostgresStore is a Store on a PostgreSQL database, chosen by giving
+// VUTADEX_DATABASE_URL a postgres:// URL. It runs SQLStore's queries, which keep to
+// the SQL both databases share and take what differs from the store's dialect, on a
+// schema with migrations of its own (see postgres_migrations.go).
+type PostgresStore struct {
+	*SQLStore
+}
+
+var _ Store = (*PostgresStore)(nil)
+
+const (
+	// postgresLockTimeout bounds a statement's wait for a lock, as the busy timeout does
+	// on SQLite, so a writer stuck behind another fails instead of hanging.
+	postgresLockTimeout = sqliteBusyTimeout
+
+	// postgresLogLock is the first key of the advisory locks that order a collection's
+	// change log and oplog; the second is a hash of the collection's ID. A writer takes
+	// its collection's lock as it appends to either log and holds it until it commits, so
+	// a collection's entries commit in the order of their sequence numbers and a client
+	// resuming from one misses nothing. Writers to different collections do not wait.
+	postgresLogLock = 0x766474
+
+	// postgresMigrationLock keys the advisory lock a migration holds, so servers starting
+	// together bring the schema up to date one at a time.
+	postgresMigrationLock = 0x766475
+)
+
+// OpenPostgresStore connects to the PostgreSQL database at dsn, a postgres:// URL or a
+// key=value connection string, and brings its schema up to date.
+func OpenPostgresStore(dsn string) (*PostgresStore, error) {
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	store, err := newSQLStore(sql.OpenDB(postgresConnector{connector}), dialectPostgres)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresStore{SQLStore: store}, nil
+}
+
+// postgresConnector opens connections that take the store's queries as written: with
+// ? placeholders, which it binds as PostgreSQL's $1, $2, ..., and with the argument
+// types SQLite takes (see postgresConn.CheckNamedValue).
+type postgresConnector struct {
+	*pq.Connector
+}
+
+func (c postgresConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pgConn, ok := conn.(postgresDriverConn)
+	if !ok {
+		_ = conn.Close()
+		return nil, fmt.Errorf("postgres driver connection %T lacks context support", conn)
+	}
+	if _, err := pgConn.ExecContext(ctx, fmt.Sprintf("SET lock_timeout = %d", postgresLockTimeout.Milliseconds()), nil); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &postgresConn{pgConn}, nil
+}
+
+// postgresDriverConn is what database/sql uses of a pq connection.
+type postgresDriverConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+type postgresConn struct {
+	postgresDriverConn
+}
+
+func (c *postgresConn) Prepare(query string) (driver.Stmt, error) {
+	return c.postgresDriverConn.Prepare(postgresPlaceholders(query))
+}
+
+func (c *postgresConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.postgresDriverConn.PrepareContext(ctx, postgresPlaceholders(query))
+}
+
+func (c *postgresConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.postgresDriverConn.ExecContext(ctx, postgresPlaceholders(query), args)
+}
+
+func (c *postgresConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.postgresDriverConn.QueryContext(ctx, postgresPlaceholders(query), args)
+}
+
+// BeginTx begins a serializable transaction unless opts asks for another isolation
+// level. The store reads and then writes inside its transactions on the understanding
+// that no other writer changes what it read meanwhile, which SQLite guarantees by
+// locking the database; PostgreSQL keeps it by aborting a transaction that conflicts
+// with a concurrent one, which the store then runs again (see postgresRetryable).
+func (c *postgresConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if opts.Isolation == driver.IsolationLevel(sql.LevelDefault) {
+		opts.Isolation = driver.IsolationLevel(sql.LevelSerializable)
+	}
+	return c.postgresDriverConn.BeginTx(ctx, opts)
+}
+
+// postgresRetryable reports whether err is PostgreSQL aborting a transaction for a
+// serialization failure or a deadlock, after which the transaction may be run again.
+func postgresRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "40001" || pqErr.Code == "40P01"
+}
+
+// postgresLogLockCall takes the advisory lock ordering the log of the collection in
+// expr (see postgresLogLock).
+func postgresLogLockCall(expr string) string {
+	return fmt.Sprintf("pg_advisory_xact_lock(%d, hashtext(COALESCE(%s, '')))", postgresLogLock, expr)
+}
+
+// CheckNamedValue binds arguments as SQLite stores them. Booleans become 0 and 1, for
+// the integer columns that hold flags, and byte slices other than sqlBlob become text,
+// for the text columns that hold JSON.
+func (c *postgresConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if blob, ok := nv.Value.(sqlBlob); ok {
+		nv.Value = []byte(blob)
+		return nil
+	}
+	value, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case bool:
+		value = int64(boolToInt(v))
+	case []byte:
+		value = string(v)
+	}
+	nv.Value = value
+	return nil
+}
+
+// postgresPlaceholders numbers the ? placeholders of query as $1, $2, ..., leaving
+// question marks in quoted strings, quoted identifiers, dollar-quoted bodies, and
+// comments alone. The store's queries are written for both databases, so they never use
+// PostgreSQL's own ? operators, which would be taken for placeholders here.
+func postgresPlaceholders(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n := 0
+	for i := 0; i < len(query); {
+		end := postgresTokenEnd(query, i)
+		if end == i+1 && query[i] == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		} else {
+			b.WriteString(query[i:end])
+		}
+		i = end
+	}
+	return b.String()
+}
+
+// postgresTokenEnd returns the end of the token of query at i: a quoted string or
+// identifier, a dollar-quoted body, a comment, or else the single byte at i. A token
+// left open runs to the end of query.
+func postgresTokenEnd(query string, i int) int {
+	rest := query[i:]
+	closing := func(from int, delim string) int {
+		if end := strings.Index(query[from:], delim); end >= 0 {
+			return from + end + len(delim)
+		}
+		return len(query)
+	}
+	switch c := rest[0]; {
+	case c == '\'' && i > 0 && (query[i-1] == 'E' || query[i-1] == 'e') && (i == 1 || !isSQLIdentByte(query[i-2])):
+		// An escape string: a backslash escapes the quote after it.
+		for j := i + 1; j < len(query); j++ {
+			switch query[j] {
+			case '\\':
+				j++
+			case '\'':
+				return j + 1
+			}
+		}
+		return len(query)
+	case c == '\'' || c == '"':
+		return closing(i+1, string(c))
+	case strings.HasPrefix(rest, "--"):
+		return closing(i, "\n")
+	case strings.HasPrefix(rest, "/*"):
+		return closing(i+2, "*/")
+	case c == '$' && (i == 0 || !isSQLIdentByte(query[i-1])):
+		tag := 1
+		for tag < len(rest) && rest[tag] != '$' && isSQLIdentByte(rest[tag]) && !(tag == 1 && rest[tag] >= '0' && rest[tag] <= '9') {
+			tag++
+		}
+		if tag < len(rest) && rest[tag] == '$' {
+			return closing(i+tag+1, rest[:tag+1])
+		}
+	}
+	return i + 1
+}
+
+func isSQLIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,1041 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// migratePostgres brings a PostgreSQL database's schema up to date. PostgreSQL starts
+// from the schema SQLite's migrations had built by the time it was supported, so its
+// migrations are numbered on their own, and each runs in a transaction that rolls back
+// if it fails.
+func (s *SQLStore) migratePostgres() error {
+	if err := s.ensureMetadataTable(); err != nil {
+		return err
+	}
+
+	migrations := []struct {
+		version    int
+		name       string
+		statements func() []string
+	}{
+		{1, "initial_schema", postgresInitialSchemaStatements},
+	}
+
+	version, err := s.getSchemaVersion()
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if version >= m.version {
+			continue
+		}
+		fmt.Printf("Running migration %d: %s\n", m.version, m.name)
+		if err := s.runPostgresMigration(m.version, m.statements()); err != nil {
+			return fmt.Errorf("migration %d failed: %w", m.version, err)
+		}
+		version = m.version
+	}
+
+	fmt.Printf("Database schema up to date (version %d)\n", version)
+	return nil
+}
+
+// runPostgresMigration applies statements and records version in one transaction. The
+// transaction takes the migration lock before it reads the schema version, so of two
+// servers starting at once, the second finds the migration already applied and skips
+// it. It reads committed data rather than the snapshot a serializable transaction
+// would have taken before the lock was granted.
+func (s *SQLStore) runPostgresMigration(version int, statements []string) error {
+	tx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(fmt.Sprintf("SELECT pg_advisory_xact_lock(%d)", postgresMigrationLock)); err != nil {
+		return err
+	}
+	var current int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(CAST(value AS BIGINT)), 0) FROM metadata WHERE key = 'schema_version'`).Scan(&current); err != nil {
+		return err
+	}
+	if current >= version {
+		return nil
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(schemaVersionUpsert, fmt.Sprintf("%d", version)); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+	return tx.Commit()
+}
+
+// postgresInitialSchemaStatements returns the schema of SQLite migration 41 in
+// PostgreSQL's types, with notes searched through an expression index in place of
+// notes_fts and the change log written by trigger functions.
+func postgresInitialSchemaStatements() []string {
+	statements := append([]string{}, postgresInitialSchema...)
+	statements = append(statements,
+		`CREATE INDEX IF NOT EXISTS idx_notes_full_text ON notes USING GIN (`+postgresNotesVector("")+`)`,
+	)
+	statements = append(statements, postgresLogOrderStatements("change_log", "usn")...)
+	statements = append(statements, postgresLogOrderStatements("oplog", "seq")...)
+	return append(statements, postgresChangeLogTriggerStatements()...)
+}
+
+// postgresLogOrderStatements returns a trigger that numbers each row inserted into a
+// collection's log, table, only once the writer holds that collection's log lock (see
+// postgresLogLock). The identity default is drawn before the trigger runs, when the
+// writer may still be waiting for the lock, so the trigger draws column again.
+func postgresLogOrderStatements(table, column string) []string {
+	return []string{
+		fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION %[1]s_order() RETURNS trigger AS $$
+		BEGIN
+			PERFORM %[3]s;
+			NEW.%[2]s := nextval(pg_get_serial_sequence('%[1]s', '%[2]s'));
+			RETURN NEW;
+		END
+		$$ LANGUAGE plpgsql
+		`, table, column, postgresLogLockCall("NEW.collection_id")),
+		fmt.Sprintf(`CREATE TRIGGER %[1]s_order BEFORE INSERT ON %[1]s FOR EACH ROW EXECUTE FUNCTION %[1]s_order()`, table),
+	}
+}
+
+// postgresChangeLogTriggerStatements returns PostgreSQL's version of the triggers from
+// changeLogTriggerStatements: a function per tracked table, run after each row it writes.
+func postgresChangeLogTriggerStatements() []string {
+	var statements []string
+	for _, source := range changeLogSources {
+		insert := func(row, op string) string {
+			expand := func(expr string) string {
+				if expr == "" {
+					return "NULL"
+				}
+				return strings.ReplaceAll(expr, "{row}", row)
+			}
+			return fmt.Sprintf(`INSERT INTO change_log (collection_id, user_id, entity_type, entity_id, op, changed_at)
+			VALUES (%s, %s, '%s', CAST(%s AS TEXT), '%s', CAST(EXTRACT(EPOCH FROM now()) AS BIGINT))`,
+				expand(source.collection), expand(source.userExpr), source.entityType, expand(source.idExpr), op)
+		}
+		deleteOp := changeOpDelete
+		if source.upsertOnDelete {
+			deleteOp = changeOpUpsert
+		}
+		statements = append(statements, fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION change_log_%[1]s() RETURNS trigger AS $$
+		BEGIN
+			IF TG_OP = 'DELETE' THEN
+				%[2]s;
+			ELSE
+				%[3]s;
+			END IF;
+			RETURN NULL;
+		END
+		$$ LANGUAGE plpgsql
+		`, source.table, insert("OLD", deleteOp), insert("NEW", changeOpUpsert)),
+			fmt.Sprintf(`CREATE TRIGGER change_log_%[1]s AFTER INSERT OR UPDATE OR DELETE ON %[1]s FOR EACH ROW EXECUTE FUNCTION change_log_%[1]s()`, source.table))
+	}
+	return statements
+}
+
+var postgresInitialSchema = []string{
+	`
+	CREATE TABLE IF NOT EXISTS users (
+		id TEXT PRIMARY KEY,
+		email TEXT NOT NULL UNIQUE,
+		display_name TEXT NOT NULL,
+		avatar_url TEXT,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		last_login_at BIGINT,
+		onboarding BIGINT NOT NULL DEFAULT 0,
+		password_hash TEXT,
+		failed_login_attempts BIGINT NOT NULL DEFAULT 0,
+		login_locked_until BIGINT NOT NULL DEFAULT 0
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS collections (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		usn BIGINT DEFAULT 0,
+		last_sync BIGINT,
+		created_at BIGINT,
+		owner_user_id TEXT REFERENCES users(id) ON DELETE SET NULL
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS organizations (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		slug TEXT NOT NULL UNIQUE,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS workspaces (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		slug TEXT NOT NULL UNIQUE,
+		collection_id TEXT NOT NULL,
+		owner_user_id TEXT,
+		organization_id TEXT,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		FOREIGN KEY (collection_id) REFERENCES collections(id),
+		FOREIGN KEY (owner_user_id) REFERENCES users(id),
+		FOREIGN KEY (organization_id) REFERENCES organizations(id)
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS api_tokens (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		workspace_id TEXT REFERENCES workspaces(id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		token_prefix TEXT NOT NULL,
+		scope TEXT NOT NULL DEFAULT 'full',
+		created_at BIGINT NOT NULL,
+		last_used_at BIGINT,
+		expires_at BIGINT,
+		revoked_at BIGINT
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS calendar_feeds (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		collection_id TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at BIGINT NOT NULL,
+		last_accessed_at BIGINT,
+		UNIQUE(user_id, collection_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS deck_options (
+		id BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		new_cards_per_day BIGINT DEFAULT 20,
+		reviews_per_day BIGINT DEFAULT 200,
+		learning_steps TEXT,
+		graduating_interval BIGINT DEFAULT 1,
+		easy_interval BIGINT DEFAULT 4,
+		collection_id TEXT,
+		relearning_steps TEXT NOT NULL DEFAULT '[10]',
+		minimum_interval BIGINT NOT NULL DEFAULT 1,
+		new_interval_percent BIGINT NOT NULL DEFAULT 0,
+		leech_threshold BIGINT NOT NULL DEFAULT 8,
+		leech_action TEXT NOT NULL DEFAULT 'tag',
+		scheduler TEXT NOT NULL DEFAULT 'fsrs',
+		starting_ease BIGINT NOT NULL DEFAULT 2500,
+		easy_bonus BIGINT NOT NULL DEFAULT 130,
+		interval_modifier BIGINT NOT NULL DEFAULT 100,
+		review_order TEXT NOT NULL DEFAULT 'due'
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS decks (
+		id BIGINT PRIMARY KEY,
+		collection_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		parent_id BIGINT,
+		options_id BIGINT,
+		priority_order BIGINT NOT NULL DEFAULT 0,
+		FOREIGN KEY (collection_id) REFERENCES collections(id),
+		FOREIGN KEY (parent_id) REFERENCES decks(id),
+		FOREIGN KEY (options_id) REFERENCES deck_options(id)
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS note_types (
+		id TEXT PRIMARY KEY,
+		collection_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		fields TEXT NOT NULL,
+		templates TEXT NOT NULL,
+		sort_field_index BIGINT NOT NULL DEFAULT 0,
+		field_options TEXT NOT NULL DEFAULT '{}',
+		FOREIGN KEY (collection_id) REFERENCES collections(id),
+		UNIQUE(collection_id, name)
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS notes (
+		id BIGINT PRIMARY KEY,
+		collection_id TEXT NOT NULL,
+		type_id TEXT NOT NULL,
+		field_vals TEXT NOT NULL,
+		tags TEXT,
+		usn BIGINT DEFAULT 0,
+		created_at BIGINT,
+		modified_at BIGINT,
+		FOREIGN KEY (collection_id) REFERENCES collections(id),
+		FOREIGN KEY (type_id) REFERENCES note_types(id)
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS cards (
+		id BIGINT PRIMARY KEY,
+		note_id BIGINT NOT NULL,
+		deck_id BIGINT NOT NULL,
+		template_name TEXT NOT NULL,
+		ordinal BIGINT DEFAULT 0,
+		front TEXT,
+		back TEXT,
+		due BIGINT,
+		state BIGINT,
+		fsrs_data TEXT,
+		flag BIGINT DEFAULT 0,
+		marked BIGINT DEFAULT 0,
+		suspended BIGINT DEFAULT 0,
+		usn BIGINT DEFAULT 0,
+		learning_step BIGINT NOT NULL DEFAULT 0,
+		buried_at BIGINT NOT NULL DEFAULT 0,
+		ease_factor BIGINT NOT NULL DEFAULT 0,
+		original_deck_id BIGINT NOT NULL DEFAULT 0,
+		filtered_position BIGINT NOT NULL DEFAULT 0,
+		FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE,
+		FOREIGN KEY (deck_id) REFERENCES decks(id)
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS card_review_states (
+		user_id TEXT NOT NULL,
+		card_id BIGINT NOT NULL,
+		due BIGINT NOT NULL,
+		state BIGINT NOT NULL,
+		fsrs_data TEXT NOT NULL,
+		flag BIGINT DEFAULT 0,
+		marked BIGINT DEFAULT 0,
+		suspended BIGINT DEFAULT 0,
+		updated_at BIGINT NOT NULL,
+		learning_step BIGINT NOT NULL DEFAULT 0,
+		buried_at BIGINT NOT NULL DEFAULT 0,
+		ease_factor BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (user_id, card_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (card_id) REFERENCES cards(id) ON DELETE CASCADE
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS change_log (
+		usn BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+		collection_id TEXT,
+		user_id TEXT,
+		entity_type TEXT NOT NULL,
+		entity_id TEXT NOT NULL,
+		op TEXT NOT NULL,
+		changed_at BIGINT NOT NULL
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS collection_preferences (
+		collection_id TEXT PRIMARY KEY,
+		preferences TEXT NOT NULL DEFAULT '{}',
+		updated_at BIGINT NOT NULL,
+		FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS deck_grants (
+		id TEXT PRIMARY KEY,
+		deck_id BIGINT NOT NULL REFERENCES decks(id) ON DELETE CASCADE,
+		owner_user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		email TEXT NOT NULL,
+		user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+		role TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		UNIQUE(deck_id, email)
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS deck_shares (
+		id TEXT PRIMARY KEY,
+		deck_id BIGINT NOT NULL,
+		workspace_id TEXT,
+		created_by_user_id TEXT,
+		token TEXT NOT NULL UNIQUE,
+		access_type TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		FOREIGN KEY (deck_id) REFERENCES decks(id) ON DELETE CASCADE,
+		FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+		FOREIGN KEY (created_by_user_id) REFERENCES users(id) ON DELETE SET NULL
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS entitlements (
+		id TEXT PRIMARY KEY,
+		workspace_id TEXT,
+		organization_id TEXT,
+		plan TEXT NOT NULL,
+		max_decks BIGINT NOT NULL,
+		max_notes BIGINT NOT NULL,
+		max_shared_decks BIGINT NOT NULL,
+		max_sync_devices BIGINT NOT NULL,
+		max_workspaces BIGINT NOT NULL,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		max_cards_total BIGINT NOT NULL DEFAULT 100,
+		FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+		FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS filtered_decks (
+		deck_id BIGINT PRIMARY KEY REFERENCES decks(id) ON DELETE CASCADE,
+		query TEXT NOT NULL,
+		card_limit BIGINT NOT NULL DEFAULT 100,
+		card_order TEXT NOT NULL DEFAULT 'due',
+		reschedule BIGINT NOT NULL DEFAULT 1,
+		built_at BIGINT NOT NULL DEFAULT 0
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS marketplace_creator_accounts (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		workspace_id TEXT NOT NULL,
+		provider TEXT NOT NULL,
+		provider_account_id TEXT NOT NULL,
+		onboarding_status TEXT NOT NULL DEFAULT 'pending',
+		details_submitted BIGINT NOT NULL DEFAULT 0,
+		charges_enabled BIGINT NOT NULL DEFAULT 0,
+		payouts_enabled BIGINT NOT NULL DEFAULT 0,
+		onboarding_url TEXT NOT NULL DEFAULT '',
+		dashboard_url TEXT NOT NULL DEFAULT '',
+		onboarding_completed_at BIGINT,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		UNIQUE(user_id),
+		UNIQUE(provider, provider_account_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS marketplace_listings (
+		id TEXT PRIMARY KEY,
+		workspace_id TEXT NOT NULL,
+		deck_id BIGINT NOT NULL,
+		slug TEXT NOT NULL UNIQUE,
+		title TEXT NOT NULL,
+		summary TEXT NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		creator_user_id TEXT NOT NULL,
+		price_mode TEXT NOT NULL DEFAULT 'free',
+		price_cents BIGINT NOT NULL DEFAULT 0,
+		currency TEXT NOT NULL DEFAULT 'USD',
+		status TEXT NOT NULL DEFAULT 'draft',
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		category TEXT NOT NULL DEFAULT '',
+		tags TEXT NOT NULL DEFAULT '[]',
+		cover_image_url TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+		FOREIGN KEY (deck_id) REFERENCES decks(id) ON DELETE CASCADE,
+		FOREIGN KEY (creator_user_id) REFERENCES users(id) ON DELETE CASCADE
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS marketplace_installs (
+		id TEXT PRIMARY KEY,
+		listing_id TEXT NOT NULL,
+		workspace_id TEXT NOT NULL,
+		installed_by_user_id TEXT NOT NULL,
+		installed_deck_id BIGINT,
+		source_version_number BIGINT NOT NULL DEFAULT 0,
+		status TEXT NOT NULL DEFAULT 'active',
+		superseded_by_install_id TEXT,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		FOREIGN KEY (listing_id) REFERENCES marketplace_listings(id) ON DELETE CASCADE,
+		FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+		FOREIGN KEY (installed_by_user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (installed_deck_id) REFERENCES decks(id) ON DELETE SET NULL,
+		FOREIGN KEY (superseded_by_install_id) REFERENCES marketplace_installs(id) ON DELETE SET NULL
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS marketplace_orders (
+		id TEXT PRIMARY KEY,
+		listing_id TEXT NOT NULL,
+		listing_version_number BIGINT NOT NULL,
+		buyer_user_id TEXT NOT NULL,
+		buyer_workspace_id TEXT NOT NULL,
+		creator_user_id TEXT NOT NULL,
+		creator_account_id TEXT,
+		provider TEXT NOT NULL,
+		provider_checkout_session_id TEXT NOT NULL,
+		provider_payment_intent_id TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL,
+		amount_cents BIGINT NOT NULL,
+		currency TEXT NOT NULL,
+		platform_fee_cents BIGINT NOT NULL,
+		creator_amount_cents BIGINT NOT NULL,
+		completed_at BIGINT,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		UNIQUE(provider, provider_checkout_session_id),
+		FOREIGN KEY (listing_id) REFERENCES marketplace_listings(id) ON DELETE CASCADE,
+		FOREIGN KEY (buyer_user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (buyer_workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+		FOREIGN KEY (creator_user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (creator_account_id) REFERENCES marketplace_creator_accounts(id) ON DELETE SET NULL
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS marketplace_licenses (
+		id TEXT PRIMARY KEY,
+		listing_id TEXT NOT NULL,
+		buyer_user_id TEXT NOT NULL,
+		order_id TEXT NOT NULL,
+		status TEXT NOT NULL,
+		granted_version_number BIGINT NOT NULL,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		UNIQUE(listing_id, buyer_user_id),
+		UNIQUE(order_id),
+		FOREIGN KEY (listing_id) REFERENCES marketplace_listings(id) ON DELETE CASCADE,
+		FOREIGN KEY (buyer_user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (order_id) REFERENCES marketplace_orders(id) ON DELETE CASCADE
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS marketplace_listing_versions (
+		id TEXT PRIMARY KEY,
+		listing_id TEXT NOT NULL,
+		version_number BIGINT NOT NULL,
+		source_deck_id BIGINT NOT NULL,
+		published_by_user_id TEXT NOT NULL,
+		change_summary TEXT NOT NULL DEFAULT '',
+		note_count BIGINT NOT NULL,
+		card_count BIGINT NOT NULL,
+		created_at BIGINT NOT NULL,
+		UNIQUE(listing_id, version_number),
+		FOREIGN KEY (listing_id) REFERENCES marketplace_listings(id) ON DELETE CASCADE,
+		FOREIGN KEY (source_deck_id) REFERENCES decks(id) ON DELETE CASCADE,
+		FOREIGN KEY (published_by_user_id) REFERENCES users(id) ON DELETE CASCADE
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS marketplace_payouts (
+		id TEXT PRIMARY KEY,
+		order_id TEXT NOT NULL,
+		creator_user_id TEXT NOT NULL,
+		creator_account_id TEXT NOT NULL,
+		provider TEXT NOT NULL,
+		provider_transfer_id TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL,
+		amount_cents BIGINT NOT NULL,
+		currency TEXT NOT NULL,
+		platform_fee_cents BIGINT NOT NULL,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		UNIQUE(order_id),
+		FOREIGN KEY (order_id) REFERENCES marketplace_orders(id) ON DELETE CASCADE,
+		FOREIGN KEY (creator_user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (creator_account_id) REFERENCES marketplace_creator_accounts(id) ON DELETE CASCADE
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS media (
+		id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+		collection_id TEXT NOT NULL,
+		filename TEXT UNIQUE NOT NULL,
+		data BYTEA,
+		added_at BIGINT,
+		unused_since BIGINT,
+		sha256 TEXT,
+		FOREIGN KEY (collection_id) REFERENCES collections(id)
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS oauth_identities (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		provider TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		email TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		UNIQUE(provider, subject),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS oplog (
+		seq BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+		collection_id TEXT NOT NULL,
+		op_id TEXT NOT NULL,
+		actor TEXT NOT NULL,
+		lamport BIGINT NOT NULL,
+		kind TEXT NOT NULL,
+		target TEXT NOT NULL,
+		register TEXT NOT NULL DEFAULT '',
+		field TEXT NOT NULL DEFAULT '',
+		value TEXT NOT NULL DEFAULT '',
+		user_id TEXT,
+		received_at BIGINT NOT NULL,
+		UNIQUE(collection_id, op_id)
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS oplog_refs (
+		collection_id TEXT NOT NULL,
+		ref TEXT NOT NULL,
+		entity_type TEXT NOT NULL,
+		entity_id BIGINT NOT NULL,
+		PRIMARY KEY (collection_id, ref)
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS organization_members (
+		id TEXT PRIMARY KEY,
+		organization_id TEXT NOT NULL,
+		user_id TEXT,
+		email TEXT NOT NULL,
+		role TEXT NOT NULL,
+		status TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		invite_token TEXT,
+		invite_expires_at BIGINT,
+		joined_at BIGINT,
+		removed_at BIGINT,
+		UNIQUE(organization_id, email),
+		FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS otp_challenges (
+		id TEXT PRIMARY KEY,
+		email TEXT NOT NULL,
+		code_hash TEXT NOT NULL,
+		expires_at BIGINT NOT NULL,
+		attempt_count BIGINT NOT NULL DEFAULT 0,
+		max_attempts BIGINT NOT NULL,
+		resend_available_at BIGINT NOT NULL,
+		consumed_at BIGINT,
+		requested_ip TEXT,
+		user_agent TEXT,
+		created_at BIGINT NOT NULL
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS profiles (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		collection_id TEXT,
+		sync_account TEXT,
+		created_at BIGINT,
+		FOREIGN KEY (collection_id) REFERENCES collections(id)
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS revlog (
+		id BIGINT PRIMARY KEY,
+		card_id BIGINT NOT NULL,
+		rating BIGINT NOT NULL,
+		state BIGINT,
+		due BIGINT,
+		reviewed_at BIGINT,
+		time_taken_ms BIGINT DEFAULT 0,
+		user_id TEXT,
+		stability DOUBLE PRECISION,
+		difficulty DOUBLE PRECISION,
+		elapsed_days BIGINT NOT NULL DEFAULT 0,
+		scheduled_days BIGINT NOT NULL DEFAULT 0,
+		kind TEXT NOT NULL DEFAULT 'review',
+		next_state BIGINT,
+		FOREIGN KEY (card_id) REFERENCES cards(id)
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS sessions (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		workspace_id TEXT,
+		plan TEXT NOT NULL,
+		guest BIGINT NOT NULL DEFAULT 0,
+		expires_at BIGINT,
+		created_at BIGINT NOT NULL,
+		last_seen_at BIGINT,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE SET NULL
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS study_groups (
+		id TEXT PRIMARY KEY,
+		workspace_id TEXT NOT NULL,
+		primary_deck_id BIGINT NOT NULL,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		visibility TEXT NOT NULL DEFAULT 'private',
+		join_policy TEXT NOT NULL DEFAULT 'invite',
+		created_by_user_id TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+		FOREIGN KEY (primary_deck_id) REFERENCES decks(id) ON DELETE CASCADE,
+		FOREIGN KEY (created_by_user_id) REFERENCES users(id) ON DELETE CASCADE
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS study_group_events (
+		id TEXT PRIMARY KEY,
+		study_group_id TEXT NOT NULL,
+		actor_user_id TEXT,
+		event_type TEXT NOT NULL,
+		payload TEXT NOT NULL DEFAULT '',
+		created_at BIGINT NOT NULL,
+		FOREIGN KEY (study_group_id) REFERENCES study_groups(id) ON DELETE CASCADE,
+		FOREIGN KEY (actor_user_id) REFERENCES users(id) ON DELETE SET NULL
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS study_group_members (
+		id TEXT PRIMARY KEY,
+		study_group_id TEXT NOT NULL,
+		user_id TEXT,
+		email TEXT NOT NULL,
+		role TEXT NOT NULL,
+		status TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		invite_token TEXT,
+		invite_expires_at BIGINT,
+		joined_at BIGINT,
+		removed_at BIGINT,
+		leaderboard_opt_in BIGINT NOT NULL DEFAULT 0,
+		UNIQUE(study_group_id, email),
+		FOREIGN KEY (study_group_id) REFERENCES study_groups(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS study_group_installs (
+		id TEXT PRIMARY KEY,
+		study_group_id TEXT NOT NULL,
+		study_group_member_id TEXT NOT NULL,
+		destination_workspace_id TEXT NOT NULL,
+		installed_deck_id BIGINT,
+		source_version_number BIGINT NOT NULL,
+		status TEXT NOT NULL,
+		sync_state TEXT NOT NULL,
+		superseded_by_install_id TEXT,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		FOREIGN KEY (study_group_id) REFERENCES study_groups(id) ON DELETE CASCADE,
+		FOREIGN KEY (study_group_member_id) REFERENCES study_group_members(id) ON DELETE CASCADE,
+		FOREIGN KEY (destination_workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+		FOREIGN KEY (installed_deck_id) REFERENCES decks(id) ON DELETE SET NULL,
+		FOREIGN KEY (superseded_by_install_id) REFERENCES study_group_installs(id) ON DELETE SET NULL
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS study_group_versions (
+		id TEXT PRIMARY KEY,
+		study_group_id TEXT NOT NULL,
+		version_number BIGINT NOT NULL,
+		source_deck_id BIGINT NOT NULL,
+		published_by_user_id TEXT NOT NULL,
+		change_summary TEXT NOT NULL DEFAULT '',
+		note_count BIGINT NOT NULL DEFAULT 0,
+		card_count BIGINT NOT NULL DEFAULT 0,
+		created_at BIGINT NOT NULL,
+		UNIQUE(study_group_id, version_number),
+		FOREIGN KEY (study_group_id) REFERENCES study_groups(id) ON DELETE CASCADE,
+		FOREIGN KEY (source_deck_id) REFERENCES decks(id) ON DELETE CASCADE,
+		FOREIGN KEY (published_by_user_id) REFERENCES users(id) ON DELETE CASCADE
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS study_sessions (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		workspace_id TEXT NOT NULL,
+		deck_id BIGINT,
+		mode TEXT NOT NULL DEFAULT 'review',
+		status TEXT NOT NULL DEFAULT 'active',
+		started_at BIGINT NOT NULL,
+		ended_at BIGINT,
+		cards_reviewed BIGINT NOT NULL DEFAULT 0,
+		again_count BIGINT NOT NULL DEFAULT 0,
+		hard_count BIGINT NOT NULL DEFAULT 0,
+		good_count BIGINT NOT NULL DEFAULT 0,
+		easy_count BIGINT NOT NULL DEFAULT 0,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		protocol TEXT NOT NULL DEFAULT '',
+		target_minutes BIGINT NOT NULL DEFAULT 0,
+		break_minutes BIGINT NOT NULL DEFAULT 0,
+		total_time_ms BIGINT NOT NULL DEFAULT 0,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS subscriptions (
+		id TEXT PRIMARY KEY,
+		workspace_id TEXT,
+		organization_id TEXT,
+		plan TEXT NOT NULL,
+		status TEXT NOT NULL,
+		provider TEXT,
+		provider_customer_id TEXT,
+		provider_subscription_id TEXT,
+		current_period_end BIGINT,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		scheduled_plan TEXT,
+		provider_subscription_item_id TEXT,
+		provider_checkout_session_id TEXT,
+		cancel_at_period_end BIGINT NOT NULL DEFAULT 0,
+		billed_quantity BIGINT NOT NULL DEFAULT 1,
+		FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+		FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS subscription_events (
+		id TEXT PRIMARY KEY,
+		subscription_id TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		provider_event_id TEXT,
+		payload TEXT,
+		created_at BIGINT NOT NULL,
+		UNIQUE(provider_event_id),
+		FOREIGN KEY (subscription_id) REFERENCES subscriptions(id) ON DELETE CASCADE
+	)
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS sync_devices (
+		id TEXT PRIMARY KEY,
+		workspace_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		platform TEXT,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		UNIQUE(workspace_id, name),
+		FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE
+	)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens(user_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_card_review_states_buried_at ON card_review_states(buried_at) WHERE buried_at > 0
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_card_review_states_card ON card_review_states(card_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_card_review_states_user_due ON card_review_states(user_id, due)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_cards_buried_at ON cards(buried_at) WHERE buried_at > 0
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_cards_deck ON cards(deck_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_cards_due ON cards(due, deck_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_cards_note ON cards(note_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_cards_original_deck_id ON cards(original_deck_id) WHERE original_deck_id > 0
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_change_log_collection_usn ON change_log(collection_id, usn)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_collections_owner_user_id ON collections(owner_user_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_deck_grants_email ON deck_grants(email)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_deck_grants_user_id ON deck_grants(user_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_deck_options_collection ON deck_options(collection_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_deck_shares_deck ON deck_shares(deck_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_decks_collection ON decks(collection_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_decks_collection_priority ON decks(collection_id, priority_order, id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_decks_parent ON decks(parent_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_marketplace_creator_accounts_workspace ON marketplace_creator_accounts(workspace_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_marketplace_installs_listing ON marketplace_installs(listing_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_marketplace_installs_user ON marketplace_installs(installed_by_user_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_marketplace_installs_workspace ON marketplace_installs(workspace_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_marketplace_licenses_buyer ON marketplace_licenses(buyer_user_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_marketplace_licenses_listing ON marketplace_licenses(listing_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_marketplace_listing_versions_listing ON marketplace_listing_versions(listing_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_marketplace_listings_creator ON marketplace_listings(creator_user_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_marketplace_listings_deck ON marketplace_listings(deck_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_marketplace_listings_status ON marketplace_listings(status)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_marketplace_listings_workspace ON marketplace_listings(workspace_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_marketplace_orders_buyer ON marketplace_orders(buyer_user_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_marketplace_orders_listing ON marketplace_orders(listing_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_marketplace_orders_status ON marketplace_orders(status)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_marketplace_payouts_creator ON marketplace_payouts(creator_user_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_marketplace_payouts_status ON marketplace_payouts(status)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_note_types_collection_name ON note_types(collection_id, name)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_notes_collection_type ON notes(collection_id, type_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_oauth_identities_lookup ON oauth_identities(provider, subject)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_oplog_target ON oplog(collection_id, target, register)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_oplog_refs_entity ON oplog_refs(collection_id, entity_type, entity_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_organization_members_token ON organization_members(invite_token)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_organization_members_user ON organization_members(user_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_otp_challenges_email_consumed ON otp_challenges(email, consumed_at)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_otp_challenges_email_created ON otp_challenges(email, created_at DESC)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_revlog_card ON revlog(card_id, reviewed_at)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_revlog_kind_reviewed ON revlog(kind, reviewed_at)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_revlog_user_card_reviewed ON revlog(user_id, card_id, reviewed_at)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_study_group_events_group ON study_group_events(study_group_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_study_group_installs_deck ON study_group_installs(installed_deck_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_study_group_installs_group ON study_group_installs(study_group_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_study_group_installs_member ON study_group_installs(study_group_member_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_study_group_installs_workspace ON study_group_installs(destination_workspace_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_study_group_members_group ON study_group_members(study_group_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_study_group_members_token ON study_group_members(invite_token)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_study_group_members_user ON study_group_members(user_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_study_group_versions_group ON study_group_versions(study_group_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_study_groups_primary_deck ON study_groups(primary_deck_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_study_groups_workspace ON study_groups(workspace_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_study_sessions_deck_id ON study_sessions(deck_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_study_sessions_mode_status ON study_sessions(mode, status)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_study_sessions_user_started_at ON study_sessions(user_id, started_at)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_study_sessions_user_status ON study_sessions(user_id, status)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_study_sessions_workspace_started_at ON study_sessions(workspace_id, started_at)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_subscriptions_provider_checkout ON subscriptions(provider_checkout_session_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_subscriptions_provider_customer ON subscriptions(provider_customer_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_subscriptions_provider_subscription ON subscriptions(provider_subscription_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_subscriptions_workspace ON subscriptions(workspace_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_sync_devices_workspace ON sync_devices(workspace_id)
+	`,
+	`
+	CREATE INDEX IF NOT EXISTS idx_workspaces_owner ON workspaces(owner_user_id)
+	`,
+}
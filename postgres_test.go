@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestPostgresPlaceholdersNumbersOnlyBareQuestionMarks(t *testing.T) {
+	cases := map[string]string{
+		"SELECT 1": "SELECT 1",
+		"SELECT * FROM notes WHERE id = ? AND x = ?": "SELECT * FROM notes WHERE id = $1 AND x = $2",
+		"SELECT '?', \"a?\" FROM t WHERE v = ?":      "SELECT '?', \"a?\" FROM t WHERE v = $1",
+		"SELECT 'it''s ?' WHERE a = ?":               "SELECT 'it''s ?' WHERE a = $1",
+		"-- why?\nSELECT ? -- or?":                   "-- why?\nSELECT $1 -- or?",
+		"SELECT 'unterminated ?":                     "SELECT 'unterminated ?",
+		"SELECT /* why? */ ? /* or? ":                "SELECT /* why? */ $1 /* or? ",
+		"SELECT E'it\\'s ?', e'\\\\', ?":             "SELECT E'it\\'s ?', e'\\\\', $1",
+		"SELECT $$ a ? $$, $f$ b ? $f$, ?":           "SELECT $$ a ? $$, $f$ b ? $f$, $1",
+		"SELECT x$y, $1x ? $":                        "SELECT x$y, $1x $1 $",
+		"SELECT TYPE'?' FROM t WHERE v = ?":          "SELECT TYPE'?' FROM t WHERE v = $1",
+	}
+	for query, want := range cases {
+		if got := postgresPlaceholders(query); got != want {
+			t.Errorf("postgresPlaceholders(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestPostgresConnBindsArgumentsAsSQLiteStoresThem(t *testing.T) {
+	conn := &postgresConn{}
+	cases := []struct {
+		in   any
+		want any
+	}{
+		{true, int64(1)},
+		{false, int64(0)},
+		{[]byte(`{"a":1}`), `{"a":1}`},
+		{sqlBlob("\x00\x01"), []byte("\x00\x01")},
+		{int(7), int64(7)},
+		{"text", "text"},
+		{nil, nil},
+	}
+	for _, c := range cases {
+		nv := driver.NamedValue{Ordinal: 1, Value: c.in}
+		if err := conn.CheckNamedValue(&nv); err != nil {
+			t.Fatalf("CheckNamedValue(%#v): %v", c.in, err)
+		}
+		if fmt.Sprintf("%#v", nv.Value) != fmt.Sprintf("%#v", c.want) {
+			t.Errorf("CheckNamedValue(%#v) bound %#v, want %#v", c.in, nv.Value, c.want)
+		}
+	}
+}
+
+// recordingDriverConn records the options of the transactions begun on it.
+type recordingDriverConn struct {
+	postgresDriverConn
+	opts []driver.TxOptions
+}
+
+func (c *recordingDriverConn) BeginTx(_ context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.opts = append(c.opts, opts)
+	return nil, nil
+}
+
+func TestPostgresConnBeginsSerializableTransactions(t *testing.T) {
+	recorder := &recordingDriverConn{}
+	conn := &postgresConn{recorder}
+	for _, level := range []sql.IsolationLevel{sql.LevelDefault, sql.LevelReadCommitted} {
+		if _, err := conn.BeginTx(context.Background(), driver.TxOptions{Isolation: driver.IsolationLevel(level)}); err != nil {
+			t.Fatalf("BeginTx: %v", err)
+		}
+	}
+	want := []sql.IsolationLevel{sql.LevelSerializable, sql.LevelReadCommitted}
+	for i, opts := range recorder.opts {
+		if sql.IsolationLevel(opts.Isolation) != want[i] {
+			t.Errorf("Transaction %d began %v, want %v", i, sql.IsolationLevel(opts.Isolation), want[i])
+		}
+	}
+}
+
+func TestInTxRunsAgainAfterASerializationFailure(t *testing.T) {
+	store := newMemoryStoreForTest(t)
+	conflict := fmt.Errorf("failed to save card: %w", &pq.Error{Code: "40001"})
+	run := func(failures int, fail error) (int, error) {
+		calls := 0
+		err := store.inTx(context.Background(), func(tx *sql.Tx) error {
+			calls++
+			if calls <= failures {
+				return fail
+			}
+			return nil
+		})
+		return calls, err
+	}
+
+	if calls, err := run(1, conflict); err == nil || calls != 1 {
+		t.Errorf("SQLite: expected the conflict returned after one call, got %d calls (err %v)", calls, err)
+	}
+
+	store.dialect = dialectPostgres
+	if calls, err := run(2, conflict); err != nil || calls != 3 {
+		t.Errorf("Expected two conflicts to be retried, got %d calls (err %v)", calls, err)
+	}
+	if calls, err := run(maxTxAttempts, conflict); !errors.Is(err, conflict) || calls != maxTxAttempts {
+		t.Errorf("Expected the conflict after %d attempts, got %d calls (err %v)", maxTxAttempts, calls, err)
+	}
+	unique := &pq.Error{Code: "23505"}
+	if calls, err := run(1, unique); !errors.Is(err, unique) || calls != 1 {
+		t.Errorf("Expected a unique violation to be returned at once, got %d calls (err %v)", calls, err)
+	}
+}
+
+func TestFullTextMatchQueryBuildsPostgresTSQuery(t *testing.T) {
+	got := fullTextMatchQuery(fullTextModulePostgres, `cell mito* it's back\slash "" *`, true)
+	want := `'cell' & 'mito':* & 'it''s' & 'back\\slash'`
+	if got != want {
+		t.Errorf("fullTextMatchQuery = %q, want %q", got, want)
+	}
+	if got := fullTextMatchQuery(fullTextModulePostgres, "mito*", false); got != `'mito'` {
+		t.Errorf("fullTextMatchQuery without prefixes = %q, want 'mito'", got)
+	}
+}
+
+// newPostgresStoreForTest opens a PostgresStore on a schema of its own in the database
+// at VUTADEX_TEST_POSTGRES_URL, skipping the test when it is unset.
+func newPostgresStoreForTest(t *testing.T) *PostgresStore {
+	t.Helper()
+	dsn := os.Getenv("VUTADEX_TEST_POSTGRES_URL")
+	if dsn == "" {
+		t.Skip("VUTADEX_TEST_POSTGRES_URL is not set")
+	}
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { _ = admin.Close() })
+	schema := fmt.Sprintf("microdote_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	t.Cleanup(func() { _, _ = admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("VUTADEX_TEST_POSTGRES_URL must be a postgres:// URL: %v", err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	store, err := OpenPostgresStore(u.String())
+	if err != nil {
+		t.Fatalf("Failed to open postgres store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func TestPostgresStoreServesTheAPI(t *testing.T) {
+	store := newPostgresStoreForTest(t)
+	env := seedAPITestEnv(t, store, mustLocalAppConfig(), nil)
+
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "What is the powerhouse of the cell?", "Back": "The mitochondria"},
+		Tags:      []string{"biology"},
+	}, nil)
+	if len(created.Cards) != 1 {
+		t.Fatalf("Expected one card, got %d", len(created.Cards))
+	}
+	cardID := created.Cards[0].ID
+
+	answer := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", cardID), AnswerCardRequest{Rating: 3, TimeTakenMs: 1500})
+	if answer.Code != http.StatusOK {
+		t.Fatalf("Expected answer 200, got %d (%s)", answer.Code, answer.Body.String())
+	}
+	if logs, err := store.GetRevlogForCard(context.Background(), cardID); err != nil || len(logs) != 1 {
+		t.Errorf("Expected one review log entry, got %d (err %v)", len(logs), err)
+	}
+
+	for _, path := range []string{
+		"/api/search/fulltext?q=" + url.QueryEscape("mito*"),
+		"/api/notes?tag=biology",
+		"/api/search?q=" + url.QueryEscape("tag:biology -is:suspended"),
+		"/api/stats/hourly",
+	} {
+		if rr := doRawRequest(env.router, http.MethodGet, path, ""); rr.Code != http.StatusOK {
+			t.Errorf("GET %s: expected 200, got %d (%s)", path, rr.Code, rr.Body.String())
+		}
+	}
+	found := decodeJSON[FullTextSearchResponse](t, doRawRequest(env.router, http.MethodGet, "/api/search/fulltext?q=mito*", ""))
+	if found.Total != 1 || len(found.Notes) != 1 || found.Notes[0].ID != created.Note.ID {
+		t.Errorf("Expected the full-text search to find the note, got %+v", found)
+	}
+}
+
+func TestPostgresStoreMigrationsAreIdempotent(t *testing.T) {
+	store := newPostgresStoreForTest(t)
+	if err := store.migrate(); err != nil {
+		t.Fatalf("Expected a second migration run to succeed, got %v", err)
+	}
+	version, err := store.getSchemaVersion()
+	if err != nil || version != 1 {
+		t.Errorf("Expected schema version 1, got %d (err %v)", version, err)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// mountProfiler serves net/http/pprof and expvar under /debug when cfg.Enabled. A
+// signed-in user or API token is required, and the user's email must be in cfg.Users
+// when it is set. Profiles can be fetched with curl and read with go tool pprof:
+//
+//	curl -H "Authorization: Bearer $TOKEN" -o cpu.pprof https://host/debug/pprof/profile?seconds=30
+func mountProfiler(router chi.Router, cfg PprofConfig, handler *APIHandler) {
+	if !cfg.Enabled {
+		return
+	}
+	router.With(
+		handler.holdActiveProfile,
+		handler.SessionMiddleware,
+		handler.RequireAuthenticatedUser,
+		handler.requireProfilerUser(cfg.Users),
+	).Mount("/debug", middleware.Profiler())
+}
+
+func (h *APIHandler) requireProfilerUser(users []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(users) > 0 {
+				user, err := h.store.GetUserByID(r.Context(), h.userIDFromRequest(r))
+				if err != nil || !containsEmail(users, user.Email) {
+					respondAPIError(w, http.StatusForbidden, "profiler_forbidden", "You are not allowed to profile this server")
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func containsEmail(emails []string, email string) bool {
+	email = strings.TrimSpace(email)
+	for _, candidate := range emails {
+		if email != "" && strings.EqualFold(strings.TrimSpace(candidate), email) {
+			return true
+		}
+	}
+	return false
+}
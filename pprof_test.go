@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestProfilerRequiresConfigAndAllowedUser(t *testing.T) {
+	env := setupAPITestEnv(t)
+	frontend := fstest.MapFS{"index.html": &fstest.MapFile{Data: []byte("<html>app</html>")}}
+	cookie := map[string]string{"Cookie": env.authCookie}
+
+	cfg := mustLocalAppConfig()
+	server := NewServer(cfg, env.handler, frontend)
+	if rr := doRawRequestWithHeaders(server, http.MethodGet, "/debug/pprof/", "", cookie); strings.Contains(rr.Body.String(), "goroutine") {
+		t.Fatalf("expected no profiler unless enabled, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	cfg.Pprof = PprofConfig{Enabled: true, Users: []string{"ops@example.com"}}
+	server = NewServer(cfg, env.handler, frontend)
+	if rr := doRawRequest(server, http.MethodGet, "/debug/pprof/", ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected anonymous profiling to be rejected, got %d", rr.Code)
+	}
+	if rr := doRawRequestWithHeaders(server, http.MethodGet, "/debug/pprof/", "", cookie); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected a user off the list to be rejected, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	cfg.Pprof.Users = []string{"ops@example.com", "Test@Example.com"}
+	server = NewServer(cfg, env.handler, frontend)
+	rr := doRawRequestWithHeaders(server, http.MethodGet, "/debug/pprof/", "", cookie)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "goroutine") {
+		t.Fatalf("expected the pprof index, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doRawRequestWithHeaders(server, http.MethodGet, "/debug/pprof/heap?debug=1", "", cookie); rr.Code != http.StatusOK {
+		t.Fatalf("expected a heap profile, got %d", rr.Code)
+	}
+}
+
+func TestLoadAppConfigRequiresProfilerUsersInProduction(t *testing.T) {
+	t.Setenv("VUTADEX_ENV", "production")
+	t.Setenv("VUTADEX_PPROF_ENABLED", "true")
+
+	_, err := LoadAppConfig()
+	if err == nil || !strings.Contains(err.Error(), "VUTADEX_PPROF_USERS") {
+		t.Fatalf("expected VUTADEX_PPROF_USERS to be required, got %v", err)
+	}
+}
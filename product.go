@@ -1,6 +1,9 @@
 package main
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type Plan string
 
@@ -110,6 +113,10 @@ type SessionRecord struct {
 	ExpiresAt   time.Time
 	LastSeenAt  time.Time
 	CreatedAt   time.Time
+	// TokenID and TokenScope are set for a request authenticated with an API token
+	// rather than a stored session.
+	TokenID    string
+	TokenScope string
 }
 
 type OTPChallenge struct {
@@ -168,6 +175,10 @@ type AuthSessionResponse struct {
 	Authenticated        bool                `json:"authenticated"`
 	GoogleAuthConfigured bool                `json:"googleAuthConfigured"`
 	OTPAuthEnabled       bool                `json:"otpAuthEnabled"`
+	PasswordAuthEnabled  bool                `json:"passwordAuthEnabled"`
+	PasswordSet          bool                `json:"passwordSet"`
+	OIDCAuthEnabled      bool                `json:"oidcAuthEnabled"`
+	OIDCProviderName     string              `json:"oidcProviderName,omitempty"`
 	User                 *User               `json:"user,omitempty"`
 	Workspace            *Workspace          `json:"workspace,omitempty"`
 	Organization         *Organization       `json:"organization,omitempty"`
@@ -215,29 +226,59 @@ type CreateOrganizationRequest struct {
 	Slug string `json:"slug,omitempty"`
 }
 
+func (req *CreateOrganizationRequest) Validate(v *requestValidator) {
+	v.required("name", req.Name)
+}
+
 type AddOrganizationMemberRequest struct {
 	Email string `json:"email"`
 	Role  string `json:"role"`
 }
 
+// Validate leaves ownership out; it is assigned by updating a member.
+func (req *AddOrganizationMemberRequest) Validate(v *requestValidator) {
+	v.email("email", req.Email)
+	v.oneOf("role", req.Role, "read", "edit", "admin")
+}
+
 type UpdateOrganizationRequest struct {
 	Name string `json:"name"`
 	Slug string `json:"slug,omitempty"`
 }
 
+// Validate accepts any update; blank fields are left unchanged.
+func (req *UpdateOrganizationRequest) Validate(v *requestValidator) {}
+
 type UpdateOrganizationMemberRequest struct {
 	Role   string `json:"role,omitempty"`
 	Status string `json:"status,omitempty"`
 }
 
+func (req *UpdateOrganizationMemberRequest) Validate(v *requestValidator) {
+	if role := strings.TrimSpace(req.Role); role != "" {
+		v.check(validOrganizationRole(role), "role", "must be one of read, edit, admin, owner")
+	}
+	if status := strings.TrimSpace(req.Status); status != "" {
+		v.check(validOrganizationMemberStatus(status), "status", "must be one of invited, active, removed, declined, expired")
+	}
+}
+
 type JoinOrganizationRequest struct {
 	Token string `json:"token"`
 }
 
+func (req *JoinOrganizationRequest) Validate(v *requestValidator) {
+	v.required("token", req.Token)
+}
+
 type UpdateWorkspacePlanRequest struct {
 	Plan Plan `json:"plan"`
 }
 
+func (req *UpdateWorkspacePlanRequest) Validate(v *requestValidator) {
+	v.check(validManagedPlan(req.Plan), "plan", "must be one of free, pro, team, enterprise")
+}
+
 type OrganizationDetail struct {
 	Organization     Organization         `json:"organization"`
 	Workspace        *Workspace           `json:"workspace,omitempty"`
@@ -253,10 +294,18 @@ type ShareDeckRequest struct {
 	AccessType string `json:"accessType"`
 }
 
+// Validate allows read links only; a blank access type means read.
+func (req *ShareDeckRequest) Validate(v *requestValidator) {
+	v.oneOf("accessType", req.AccessType, "read")
+}
+
 type ImportLocalCollectionRequest struct {
 	Collection Collection `json:"collection"`
 }
 
+// Validate accepts any collection; an empty one is answered without importing.
+func (req *ImportLocalCollectionRequest) Validate(v *requestValidator) {}
+
 type GenerateAICardSuggestionsRequest struct {
 	SourceText        string            `json:"sourceText"`
 	NoteType          string            `json:"noteType"`
@@ -264,6 +313,15 @@ type GenerateAICardSuggestionsRequest struct {
 	MaxSuggestions    int               `json:"maxSuggestions,omitempty"`
 }
 
+// maxAISourceTextLength caps the text sent to the AI provider.
+const maxAISourceTextLength = 20000
+
+func (req *GenerateAICardSuggestionsRequest) Validate(v *requestValidator) {
+	v.required("sourceText", req.SourceText)
+	v.maxLength("sourceText", strings.TrimSpace(req.SourceText), maxAISourceTextLength)
+	v.required("noteType", req.NoteType)
+}
+
 type AICardSuggestion struct {
 	Title     string            `json:"title"`
 	Rationale string            `json:"rationale"`
@@ -293,6 +351,7 @@ type StudySession struct {
 	HardCount     int       `json:"hardCount"`
 	GoodCount     int       `json:"goodCount"`
 	EasyCount     int       `json:"easyCount"`
+	TotalTimeMs   int64     `json:"totalTimeMs"`
 	CreatedAt     time.Time `json:"createdAt"`
 	UpdatedAt     time.Time `json:"updatedAt"`
 }
@@ -305,6 +364,20 @@ type CreateStudySessionRequest struct {
 	BreakMinutes  int    `json:"breakMinutes,omitempty"`
 }
 
+// Validate checks the focus settings only for focus sessions; a review session ignores
+// them.
+func (req *CreateStudySessionRequest) Validate(v *requestValidator) {
+	mode := strings.TrimSpace(req.Mode)
+	v.check(mode == "" || validStudySessionMode(mode), "mode", "must be review or focus")
+	if mode != "focus" {
+		return
+	}
+	v.check(validFocusProtocol(strings.TrimSpace(req.Protocol)), "protocol", "must be one of pomodoro, deep-focus, custom")
+	v.check(req.TargetMinutes >= 0, "targetMinutes", "must not be negative")
+	v.check(req.BreakMinutes >= 0, "breakMinutes", "must not be negative")
+	v.check(req.DeckID == 0, "deckId", "must be left out; focus sessions do not attach to a deck")
+}
+
 type UpdateStudySessionRequest struct {
 	Status        string    `json:"status,omitempty"`
 	CardsReviewed *int      `json:"cardsReviewed,omitempty"`
@@ -315,6 +388,25 @@ type UpdateStudySessionRequest struct {
 	EndedAt       time.Time `json:"endedAt,omitempty"`
 }
 
+func (req *UpdateStudySessionRequest) Validate(v *requestValidator) {
+	v.check(req.Status == "" || validStudySessionStatus(req.Status), "status", "must be one of active, completed, abandoned")
+	counts := []struct {
+		field string
+		value *int
+	}{
+		{"cardsReviewed", req.CardsReviewed},
+		{"againCount", req.AgainCount},
+		{"hardCount", req.HardCount},
+		{"goodCount", req.GoodCount},
+		{"easyCount", req.EasyCount},
+	}
+	for _, count := range counts {
+		if count.value != nil {
+			v.check(*count.value >= 0, count.field, "must not be negative")
+		}
+	}
+}
+
 type StudyAnalyticsOverview struct {
 	Sessions7D       int                   `json:"sessions7d"`
 	CardsReviewed7D  int                   `json:"cardsReviewed7d"`
@@ -503,6 +595,11 @@ type CreateStudyGroupRequest struct {
 	JoinPolicy    string `json:"joinPolicy,omitempty"`
 }
 
+func (req *CreateStudyGroupRequest) Validate(v *requestValidator) {
+	v.required("name", req.Name)
+	v.check(req.PrimaryDeckID != 0, "primaryDeckId", "is required")
+}
+
 type UpdateStudyGroupRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
@@ -510,34 +607,64 @@ type UpdateStudyGroupRequest struct {
 	JoinPolicy  string `json:"joinPolicy,omitempty"`
 }
 
+// Validate accepts any update; blank fields other than the description are left unchanged.
+func (req *UpdateStudyGroupRequest) Validate(v *requestValidator) {}
+
 type InviteStudyGroupMemberRequest struct {
 	Email string `json:"email"`
 	Role  string `json:"role"`
 }
 
+// Validate leaves ownership out; a group has exactly one owner.
+func (req *InviteStudyGroupMemberRequest) Validate(v *requestValidator) {
+	v.email("email", req.Email)
+	v.oneOf("role", normalizeStudyGroupRole(req.Role), "read", "edit", "admin")
+}
+
 type UpdateStudyGroupMemberRequest struct {
 	Role   string `json:"role,omitempty"`
 	Status string `json:"status,omitempty"`
 }
 
+func (req *UpdateStudyGroupMemberRequest) Validate(v *requestValidator) {
+	v.oneOf("role", normalizeStudyGroupRole(req.Role), "read", "edit", "admin")
+	if status := strings.TrimSpace(req.Status); status != "" {
+		v.check(validStudyGroupMemberStatus(status), "status", "must be one of invited, active, removed, declined, expired")
+	}
+}
+
 type JoinStudyGroupRequest struct {
 	Token                  string `json:"token"`
 	DestinationWorkspaceID string `json:"destinationWorkspaceId"`
 	InstallLatest          bool   `json:"installLatest"`
 }
 
+func (req *JoinStudyGroupRequest) Validate(v *requestValidator) {
+	v.required("token", req.Token)
+}
+
 type PublishStudyGroupVersionRequest struct {
 	ChangeSummary string `json:"changeSummary"`
 }
 
+// Validate is empty: the change summary is optional.
+func (req *PublishStudyGroupVersionRequest) Validate(v *requestValidator) {}
+
 type InstallStudyGroupDeckRequest struct {
 	DestinationWorkspaceID string `json:"destinationWorkspaceId"`
 }
 
+// Validate is empty: the destination defaults to the caller's workspace and is looked up
+// by the handler.
+func (req *InstallStudyGroupDeckRequest) Validate(v *requestValidator) {}
+
 type UpdateStudyGroupInstallRequest struct {
 	DestinationWorkspaceID string `json:"destinationWorkspaceId,omitempty"`
 }
 
+// Validate is empty: the destination defaults to the current install's workspace.
+func (req *UpdateStudyGroupInstallRequest) Validate(v *requestValidator) {}
+
 type MarketplaceListing struct {
 	ID            string    `json:"id"`
 	WorkspaceID   string    `json:"workspaceId"`
@@ -704,6 +831,10 @@ type CreateMarketplaceListingRequest struct {
 	Currency      string   `json:"currency,omitempty"`
 }
 
+func (req *CreateMarketplaceListingRequest) Validate(v *requestValidator) {
+	validateMarketplaceListing(v, req.DeckID, req.Title, req.PriceMode, req.PriceCents)
+}
+
 type UpdateMarketplaceListingRequest struct {
 	DeckID        int64    `json:"deckId"`
 	Title         string   `json:"title"`
@@ -718,18 +849,32 @@ type UpdateMarketplaceListingRequest struct {
 	Currency      string   `json:"currency,omitempty"`
 }
 
+func (req *UpdateMarketplaceListingRequest) Validate(v *requestValidator) {
+	validateMarketplaceListing(v, req.DeckID, req.Title, req.PriceMode, req.PriceCents)
+}
+
 type PublishMarketplaceListingRequest struct {
 	ChangeSummary string `json:"changeSummary"`
 }
 
+// Validate is empty: the change summary is optional.
+func (req *PublishMarketplaceListingRequest) Validate(v *requestValidator) {}
+
 type InstallMarketplaceListingRequest struct {
 	DestinationWorkspaceID string `json:"destinationWorkspaceId"`
 }
 
+// Validate is empty: the destination defaults to the caller's workspace and is looked up
+// by the handler.
+func (req *InstallMarketplaceListingRequest) Validate(v *requestValidator) {}
+
 type UpdateMarketplaceInstallRequest struct {
 	DestinationWorkspaceID string `json:"destinationWorkspaceId,omitempty"`
 }
 
+// Validate is empty: the destination defaults to the current install's workspace.
+func (req *UpdateMarketplaceInstallRequest) Validate(v *requestValidator) {}
+
 type MarketplaceCreatorAccountStatusResponse struct {
 	Account        *MarketplaceCreatorAccount `json:"account,omitempty"`
 	Provider       string                     `json:"provider"`
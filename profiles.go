@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Each profile keeps its collection in its own SQLite file, as Anki does. The main
+// database lists the profiles, remembers the active one, and is the default profile's
+// own file; any other profile's file is in a profiles directory beside it.
+//
+// Activating a profile swaps the store and collection every handler reads, for every
+// user of the server, so profiles are only offered by a local server. API requests hold
+// the profile lock for reading and activation holds it for writing, so no request sees
+// the swap half done.
+const (
+	defaultProfileID     = "default"
+	maxProfileNameLength = 100
+)
+
+// profileState is the handler's view of the profiles: registry is the main database and
+// mainPath its file, empty when the database is not a local file.
+type profileState struct {
+	mu       sync.RWMutex
+	registry Store
+	mainPath string
+	activeID string
+}
+
+type ProfileResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type CreateProfileRequest struct {
+	Name string `json:"name"`
+}
+
+func (req *CreateProfileRequest) Validate(v *requestValidator) {
+	v.required("name", req.Name)
+	v.maxLength("name", strings.TrimSpace(req.Name), maxProfileNameLength)
+}
+
+func (h *APIHandler) activeProfileID() string {
+	return firstNonEmpty(h.profiles.activeID, defaultProfileID)
+}
+
+func (h *APIHandler) profileResponse(profile *Profile) ProfileResponse {
+	return ProfileResponse{
+		ID:        profile.ID,
+		Name:      profile.Name,
+		Active:    profile.ID == h.activeProfileID(),
+		CreatedAt: profile.CreatedAt,
+	}
+}
+
+// profilePath is the database file holding the profile's collection.
+func (h *APIHandler) profilePath(id string) string {
+	if id == defaultProfileID {
+		return h.profiles.mainPath
+	}
+	return filepath.Join(filepath.Dir(h.profiles.mainPath), "profiles", id+".db")
+}
+
+// profileDatabase is how to open the profile database at path, encrypted with the same
+// key as the main one.
+func (h *APIHandler) profileDatabase(path string) DatabaseConfig {
+	return DatabaseConfig{Mode: DatabaseModeSQLite, Path: path, Encrypted: h.config.Database.Encrypted, Key: h.config.Database.Key}
+}
+
+// isProfileActivation reports whether r is POST /api/profiles/{id}/activate, which
+// holds the profile lock for writing.
+func isProfileActivation(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+	rest, ok := strings.CutPrefix(r.URL.Path, "/api/profiles/")
+	return ok && strings.Count(rest, "/") == 1 && strings.HasSuffix(rest, "/activate")
+}
+
+// holdActiveProfile keeps the active profile from changing while a request runs.
+// Activating a profile and restoring a backup change it, and optimizing the database
+// rewrites it, so they hold the lock for writing.
+func (h *APIHandler) holdActiveProfile(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isProfileActivation(r) || isBackupRestore(r) || isDatabaseOptimize(r) {
+			h.profiles.mu.Lock()
+			defer h.profiles.mu.Unlock()
+		} else {
+			h.profiles.mu.RLock()
+			var once sync.Once
+			release := func() { once.Do(h.profiles.mu.RUnlock) }
+			defer release()
+			r = r.WithContext(context.WithValue(r.Context(), activeProfileReleaseKey, release))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+const activeProfileReleaseKey contextKey = "vutadex_active_profile_release"
+
+// releaseActiveProfile lets a long-lived request, such as a WebSocket, stop holding the
+// profile lock once it no longer reads the store.
+func releaseActiveProfile(r *http.Request) {
+	if release, ok := r.Context().Value(activeProfileReleaseKey).(func()); ok {
+		release()
+	}
+}
+
+// OpenActiveProfile switches to the profile the registry last recorded as active, for
+// a server starting up.
+func (h *APIHandler) OpenActiveProfile(ctx context.Context) error {
+	if h.profiles.mainPath == "" {
+		return nil
+	}
+	h.profiles.mu.Lock()
+	defer h.profiles.mu.Unlock()
+	profile, err := h.profiles.registry.GetActiveProfile(ctx)
+	if err != nil {
+		return err
+	}
+	return h.switchProfile(ctx, profile)
+}
+
+// switchProfile makes the profile's database the active store, creating it on first
+// use. The caller holds the profile lock for writing.
+func (h *APIHandler) switchProfile(ctx context.Context, profile *Profile) error {
+	if profile.ID == h.activeProfileID() {
+		return nil
+	}
+	path := h.profilePath(profile.ID)
+	var (
+		store Store
+		col   *Collection
+		err   error
+	)
+	if profile.ID == defaultProfileID {
+		store = h.profiles.registry
+		col, err = store.GetCollection(ctx, profile.CollectionID)
+	} else {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("failed to create profiles directory: %w", err)
+		}
+		col, store, err = InitDefaultCollectionWithConfig(h.profileDatabase(path))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open profile %s: %w", profile.ID, err)
+	}
+	if err := h.profiles.registry.SetActiveProfile(ctx, profile.ID); err != nil {
+		if store != h.profiles.registry {
+			_ = store.Close()
+		}
+		return err
+	}
+
+	previous := h.store
+	h.store, h.collection, h.collectionID = store, col, col.ID
+	if h.backupManager != nil {
+		h.backupManager.dbPath, h.backupManager.store = path, store
+	}
+	h.profiles.activeID = profile.ID
+	h.events.disconnectAll()
+	if previous != h.profiles.registry {
+		_ = previous.Close()
+	}
+	return nil
+}
+
+// carrySessionIntoProfile signs the caller in to the newly active profile under the same
+// session ID, adding their account and a workspace to it on first use. Everyone else is
+// signed out of the server, since their sessions are in the other profile's database.
+func (h *APIHandler) carrySessionIntoProfile(ctx context.Context, session *SessionRecord, user *User, now time.Time) error {
+	profileUser, err := h.store.GetUserByEmail(ctx, user.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		profileUser = &User{
+			ID:          user.ID,
+			Email:       user.Email,
+			DisplayName: user.DisplayName,
+			AvatarURL:   user.AvatarURL,
+			LastLoginAt: now,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		err = h.store.CreateUser(ctx, profileUser)
+	}
+	if err != nil {
+		return err
+	}
+	workspace, err := h.ensureDefaultWorkspaceForUser(ctx, profileUser)
+	if err != nil {
+		return err
+	}
+	if err := h.store.DeleteSessionRecord(ctx, session.ID); err != nil {
+		return err
+	}
+	return h.store.CreateSessionRecord(ctx, &SessionRecord{
+		ID:          session.ID,
+		UserID:      profileUser.ID,
+		WorkspaceID: workspace.ID,
+		Plan:        session.Plan,
+		ExpiresAt:   session.ExpiresAt,
+		LastSeenAt:  now,
+		CreatedAt:   now,
+	})
+}
+
+// requireLocalProfiles writes an error response unless this server can switch profiles:
+// it must run locally on a SQLite file.
+func (h *APIHandler) requireLocalProfiles(w http.ResponseWriter) bool {
+	if !h.config.IsDevelopment() {
+		respondAPIError(w, http.StatusForbidden, "profiles_unavailable", "Profiles are only available on a local server")
+		return false
+	}
+	if h.profiles.mainPath == "" {
+		respondAPIError(w, http.StatusNotImplemented, "profiles_unavailable", "Profiles need a local SQLite database")
+		return false
+	}
+	return true
+}
+
+// ListProfiles serves GET /api/profiles.
+func (h *APIHandler) ListProfiles(w http.ResponseWriter, r *http.Request) {
+	if !h.requireLocalProfiles(w) {
+		return
+	}
+	if _, err := h.profiles.registry.GetActiveProfile(r.Context()); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "profiles_list_failed", err.Error())
+		return
+	}
+	profiles, err := h.profiles.registry.ListProfiles(r.Context())
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "profiles_list_failed", err.Error())
+		return
+	}
+	response := make([]ProfileResponse, 0, len(profiles))
+	for _, profile := range profiles {
+		response = append(response, h.profileResponse(profile))
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// CreateProfile serves POST /api/profiles. The profile's database is created when it is
+// first activated.
+func (h *APIHandler) CreateProfile(w http.ResponseWriter, r *http.Request) {
+	if !h.requireLocalProfiles(w) {
+		return
+	}
+	var req CreateProfileRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	// The registry's default profile and collection must exist before another profile
+	// can refer to the collection.
+	if _, err := h.profiles.registry.GetActiveProfile(r.Context()); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "profile_create_failed", err.Error())
+		return
+	}
+	profile := &Profile{
+		ID:           newID("prof"),
+		Name:         sanitizeHTML(name),
+		CollectionID: h.collectionID,
+		CreatedAt:    time.Now(),
+	}
+	if err := h.profiles.registry.CreateProfile(r.Context(), profile); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "profile_create_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusCreated, h.profileResponse(profile))
+}
+
+// ActivateProfile serves POST /api/profiles/{id}/activate, switching the server to the
+// profile's database without a restart. The caller stays signed in.
+func (h *APIHandler) ActivateProfile(w http.ResponseWriter, r *http.Request) {
+	if !h.requireLocalProfiles(w) {
+		return
+	}
+	session := h.sessionFromRequest(r)
+	if !requireSessionLogin(w, session) {
+		return
+	}
+	profile, err := h.profiles.registry.GetProfile(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondAPIError(w, http.StatusNotFound, "profile_not_found", "Profile not found")
+			return
+		}
+		respondAPIError(w, http.StatusInternalServerError, "profile_lookup_failed", err.Error())
+		return
+	}
+	if profile.ID == h.activeProfileID() {
+		respondJSON(w, http.StatusOK, h.profileResponse(profile))
+		return
+	}
+	user, err := h.store.GetUserByID(r.Context(), session.UserID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "user_lookup_failed", err.Error())
+		return
+	}
+	if err := h.switchProfile(r.Context(), profile); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "profile_activate_failed", err.Error())
+		return
+	}
+	if err := h.carrySessionIntoProfile(r.Context(), session, user, time.Now()); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "profile_activate_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, h.profileResponse(profile))
+}
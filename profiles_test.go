@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAPI_ProfilesKeepSeparateDatabases(t *testing.T) {
+	env := setupFileAPITestEnv(t)
+	createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Default profile", "Back": "Note"},
+	}, nil)
+
+	createRR := doJSONRequest(t, env.router, http.MethodPost, "/api/profiles", CreateProfileRequest{Name: "Spanish"})
+	if createRR.Code != http.StatusCreated {
+		t.Fatalf("expected create profile 201, got %d (%s)", createRR.Code, createRR.Body.String())
+	}
+	spanish := decodeJSON[ProfileResponse](t, createRR)
+	if spanish.Active {
+		t.Fatalf("expected a new profile to be inactive, got %+v", spanish)
+	}
+	if profiles := decodeJSON[[]ProfileResponse](t, doRawRequest(env.router, http.MethodGet, "/api/profiles", "")); len(profiles) != 2 {
+		t.Fatalf("expected the default and the new profile, got %+v", profiles)
+	}
+	if rr := doRawRequest(env.router, http.MethodPost, "/api/profiles/prof_missing/activate", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected an unknown profile to 404, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	activateRR := doRawRequest(env.router, http.MethodPost, "/api/profiles/"+spanish.ID+"/activate", "")
+	if activated := decodeJSON[ProfileResponse](t, activateRR); activateRR.Code != http.StatusOK || !activated.Active {
+		t.Fatalf("expected activate 200, got %d (%s)", activateRR.Code, activateRR.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(env.dbPath), "profiles", spanish.ID+".db")); err != nil {
+		t.Fatalf("expected the profile's own database file: %v", err)
+	}
+
+	decks := decodeJSON[[]DeckResponse](t, doRawRequest(env.router, http.MethodGet, "/api/decks", ""))
+	if len(decks) != 1 || decks[0].NoteCount != 0 {
+		t.Fatalf("expected an empty collection in the new profile, got %+v", decks)
+	}
+	createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    decks[0].ID,
+		FieldVals: map[string]string{"Front": "Hola", "Back": "Hello"},
+	}, nil)
+
+	if rr := doRawRequest(env.router, http.MethodPost, "/api/profiles/default/activate", ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected switching back 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	decks = decodeJSON[[]DeckResponse](t, doRawRequest(env.router, http.MethodGet, "/api/decks", ""))
+	if len(decks) != 1 || decks[0].NoteCount != 1 {
+		t.Fatalf("expected only the default profile's note, got %+v", decks)
+	}
+
+	if rr := doRawRequest(env.router, http.MethodPost, "/api/profiles/"+spanish.ID+"/activate", ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected reactivating 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	decks = decodeJSON[[]DeckResponse](t, doRawRequest(env.router, http.MethodGet, "/api/decks", ""))
+	if len(decks) != 1 || decks[0].NoteCount != 1 {
+		t.Fatalf("expected the profile's note to persist in its file, got %+v", decks)
+	}
+	if rr := doRawRequest(env.router, http.MethodPost, "/api/profiles/default/activate", ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected switching back 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
+
+func TestProfilesUnavailableInProduction(t *testing.T) {
+	cfg := mustLocalAppConfig()
+	cfg.Environment = "production"
+	env := setupAPITestEnvWithConfig(t, cfg)
+
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/profiles", CreateProfileRequest{Name: "Spanish"}); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected profiles to be refused in production, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
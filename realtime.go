@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// GET /api/ws upgrades to a WebSocket that pushes collection events as they happen, so
+// several open clients stay in step without polling. Events say what changed, not the
+// new state: a client refetches what it shows, or reads /api/changes from the USN in
+// the connected event. A client that falls behind, or whose server switches profiles,
+// is disconnected and should reconnect and refetch.
+const (
+	eventConnected        = "connected"
+	eventCardAnswered     = "card.answered"
+	eventNoteCreated      = "note.created"
+	eventDueCountsChanged = "due_counts.changed"
+	eventSyncCompleted    = "sync.completed"
+
+	eventBufferSize        = 32
+	eventWriteTimeout      = 10 * time.Second
+	eventKeepaliveInterval = 30 * time.Second
+)
+
+type RealtimeEvent struct {
+	Type         string    `json:"type"`
+	CollectionID string    `json:"collectionId"`
+	DeckID       int64     `json:"deckId,omitempty"`
+	NoteID       int64     `json:"noteId,omitempty"`
+	CardID       int64     `json:"cardId,omitempty"`
+	USN          int64     `json:"usn,omitempty"`
+	At           time.Time `json:"at"`
+
+	// userID limits an event about one user's own study state to that user's clients.
+	userID string
+}
+
+// eventHub fans events out to the WebSocket clients of each collection. The zero value
+// is ready to use.
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[*eventSubscriber]struct{}
+}
+
+type eventSubscriber struct {
+	collectionID string
+	userID       string
+	events       chan RealtimeEvent
+}
+
+func (hub *eventHub) subscribe(collectionID, userID string) *eventSubscriber {
+	sub := &eventSubscriber{collectionID: collectionID, userID: userID, events: make(chan RealtimeEvent, eventBufferSize)}
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.subscribers == nil {
+		hub.subscribers = map[*eventSubscriber]struct{}{}
+	}
+	hub.subscribers[sub] = struct{}{}
+	return sub
+}
+
+func (hub *eventHub) unsubscribe(sub *eventSubscriber) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if _, ok := hub.subscribers[sub]; ok {
+		delete(hub.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// publish delivers event without blocking; a subscriber whose buffer is full is dropped.
+func (hub *eventHub) publish(event RealtimeEvent) {
+	if event.At.IsZero() {
+		event.At = time.Now().UTC()
+	}
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for sub := range hub.subscribers {
+		if sub.collectionID != event.CollectionID || (event.userID != "" && sub.userID != event.userID) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(hub.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// disconnectAll ends every subscription, for a profile switch or shutdown.
+func (hub *eventHub) disconnectAll() {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for sub := range hub.subscribers {
+		delete(hub.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// publishAnswer tells the user's clients that they answered card and that their due
+// counts moved.
+func (h *APIHandler) publishAnswer(col *Collection, userID string, card *Card) {
+	collectionID := firstNonEmpty(col.ID, defaultCollectionID)
+	h.events.publish(RealtimeEvent{Type: eventCardAnswered, CollectionID: collectionID, DeckID: card.DeckID, NoteID: card.NoteID, CardID: card.ID, userID: userID})
+	h.events.publish(RealtimeEvent{Type: eventDueCountsChanged, CollectionID: collectionID, DeckID: card.DeckID, userID: userID})
+}
+
+func (h *APIHandler) ServeEvents(w http.ResponseWriter, r *http.Request) {
+	collectionID := h.collectionIDForRequest(r)
+	userID := h.userIDFromRequest(r)
+	usn, err := h.store.LatestChangeUSN(r.Context(), collectionID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "changes_load_failed", err.Error())
+		return
+	}
+
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: h.websocketOriginPatterns()})
+	if err != nil {
+		// Accept has already answered the request.
+		return
+	}
+	defer conn.CloseNow()
+
+	sub := h.events.subscribe(collectionID, userID)
+	defer h.events.unsubscribe(sub)
+	// The connection outlives the request; holding the profile lock for it would block
+	// profile switches, which end the subscription instead.
+	releaseActiveProfile(r)
+
+	ctx := conn.CloseRead(r.Context())
+	if err := writeEvent(ctx, conn, RealtimeEvent{Type: eventConnected, CollectionID: collectionID, USN: usn, At: time.Now().UTC()}); err != nil {
+		return
+	}
+	keepalive := time.NewTicker(eventKeepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.events:
+			if !ok {
+				conn.Close(websocket.StatusTryAgainLater, "reconnect and refetch")
+				return
+			}
+			if err := writeEvent(ctx, conn, event); err != nil {
+				return
+			}
+		case <-keepalive.C:
+			pingCtx, cancel := context.WithTimeout(ctx, eventWriteTimeout)
+			err := conn.Ping(pingCtx)
+			cancel()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(ctx context.Context, conn *websocket.Conn, event RealtimeEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, eventWriteTimeout)
+	defer cancel()
+	return wsjson.Write(ctx, conn, event)
+}
+
+// websocketOriginPatterns lets the origins CORS allows open a WebSocket. Same-origin
+// pages and clients that send no Origin, like a desktop app, are always accepted.
+func (h *APIHandler) websocketOriginPatterns() []string {
+	var hosts []string
+	for _, origin := range h.config.AllowedOrigins {
+		if u, err := url.Parse(origin); err == nil && u.Host != "" {
+			hosts = append(hosts, u.Host)
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+func TestAPI_WebSocketPushesCollectionEvents(t *testing.T) {
+	env := setupFileAPITestEnv(t)
+	server := httptest.NewServer(env.router)
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.CloseNow()
+
+	next := func() RealtimeEvent {
+		t.Helper()
+		var event RealtimeEvent
+		if err := wsjson.Read(ctx, conn, &event); err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		return event
+	}
+	if event := next(); event.Type != eventConnected || event.CollectionID != "default" {
+		t.Fatalf("expected a connected event, got %+v", event)
+	}
+
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "hola", "Back": "hello"},
+	}, nil)
+	if event := next(); event.Type != eventNoteCreated || event.NoteID != created.Note.ID || event.DeckID != 1 {
+		t.Fatalf("expected note.created for note %d, got %+v", created.Note.ID, event)
+	}
+	if event := next(); event.Type != eventDueCountsChanged {
+		t.Fatalf("expected due_counts.changed, got %+v", event)
+	}
+
+	cardID := created.Cards[0].ID
+	rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", cardID), map[string]int{"rating": 3})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("answer card: %d (%s)", rr.Code, rr.Body.String())
+	}
+	if event := next(); event.Type != eventCardAnswered || event.CardID != cardID {
+		t.Fatalf("expected card.answered for card %d, got %+v", cardID, event)
+	}
+	if event := next(); event.Type != eventDueCountsChanged {
+		t.Fatalf("expected due_counts.changed, got %+v", event)
+	}
+
+	// The open socket does not hold up a profile switch, which ends the subscription
+	// with a close the client can retry after.
+	profile := decodeJSON[ProfileResponse](t, doJSONRequest(t, env.router, http.MethodPost, "/api/profiles", CreateProfileRequest{Name: "Spanish"}))
+	if rr := doRawRequest(env.router, http.MethodPost, "/api/profiles/"+profile.ID+"/activate", ""); rr.Code != http.StatusOK {
+		t.Fatalf("activate profile: %d (%s)", rr.Code, rr.Body.String())
+	}
+	var event RealtimeEvent
+	if err := wsjson.Read(ctx, conn, &event); websocket.CloseStatus(err) != websocket.StatusTryAgainLater {
+		t.Fatalf("expected a try-again-later close, got %v", err)
+	}
+}
+
+func TestEventHubScopesAndDropsSlowSubscribers(t *testing.T) {
+	var hub eventHub
+	mine := hub.subscribe("default", "usr_a")
+	theirs := hub.subscribe("default", "usr_b")
+	other := hub.subscribe("col_other", "usr_a")
+
+	hub.publish(RealtimeEvent{Type: eventCardAnswered, CollectionID: "default", userID: "usr_a"})
+	hub.publish(RealtimeEvent{Type: eventNoteCreated, CollectionID: "default"})
+	if len(mine.events) != 2 || len(theirs.events) != 1 || len(other.events) != 0 {
+		t.Fatalf("expected events scoped by collection and user, got %d, %d, %d", len(mine.events), len(theirs.events), len(other.events))
+	}
+
+	for i := 0; i < eventBufferSize; i++ {
+		hub.publish(RealtimeEvent{Type: eventNoteCreated, CollectionID: "default"})
+	}
+	drained := 0
+	for range mine.events {
+		drained++
+	}
+	if drained != eventBufferSize {
+		t.Fatalf("expected a full subscriber to be closed after %d events, got %d", eventBufferSize, drained)
+	}
+	hub.unsubscribe(mine)
+	hub.unsubscribe(theirs)
+	hub.unsubscribe(other)
+}
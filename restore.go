@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Restoring a backup replaces the active profile's database file while the server runs.
+// The request holds the profile lock for writing, like a profile activation, so no other
+// request sees the store closed or half swapped.
+
+// isBackupRestore reports whether r is POST /api/backups/restore, which holds the
+// profile lock for writing.
+func isBackupRestore(r *http.Request) bool {
+	return r.Method == http.MethodPost && r.URL.Path == "/api/backups/restore"
+}
+
+// restoreBackup closes the active store, replaces its database with the backup, and
+// reopens it. If the restored database cannot be opened, the replaced one is put back
+// and reopened instead. The caller holds the profile lock for writing.
+func (h *APIHandler) restoreBackup(ctx context.Context, backupPath string) error {
+	if err := h.store.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+	restoreErr := h.backupManager.RestoreBackup(backupPath)
+	if restoreErr == nil {
+		if restoreErr = h.reopenActiveProfile(); restoreErr == nil {
+			if h.activeProfileID() == defaultProfileID {
+				h.applyStoredBackupPolicy(ctx)
+			}
+			h.events.disconnectAll()
+			return nil
+		}
+		if err := h.backupManager.undoRestore(); err != nil {
+			restoreErr = errors.Join(restoreErr, err)
+		}
+	}
+	if err := h.reopenActiveProfile(); err != nil {
+		return errors.Join(restoreErr, fmt.Errorf("failed to reopen database: %w", err))
+	}
+	return restoreErr
+}
+
+// reopenActiveProfile opens the active profile's database file again and makes it the
+// active store. A backup is a copy of this same file, so it holds the same collection.
+// It is not tied to the request: a client going away must not leave the server without
+// a store.
+func (h *APIHandler) reopenActiveProfile() error {
+	store, err := OpenStore(h.profileDatabase(h.backupManager.dbPath))
+	if err != nil {
+		return err
+	}
+	col, err := store.GetCollection(context.Background(), h.collectionID)
+	if err != nil {
+		_ = store.Close()
+		return fmt.Errorf("failed to load collection %s: %w", h.collectionID, err)
+	}
+	if h.profiles.registry == h.store {
+		h.profiles.registry = store
+	}
+	h.store, h.collection = store, col
+	h.backupManager.store = store
+	return nil
+}
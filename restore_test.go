@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestAPI_RestoreBackupReplacesLiveDatabase(t *testing.T) {
+	env := setupFileAPITestEnv(t)
+	t.Cleanup(func() { _ = env.handler.store.Close() })
+
+	kept := createNoteForTest(t, env, CreateNoteRequest{TypeID: "Basic", DeckID: 1, FieldVals: map[string]string{"Front": "kept", "Back": "a"}}, nil)
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/backups", map[string]string{})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected backup 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	backup := decodeJSON[CreateBackupResponse](t, rr)
+	discarded := createNoteForTest(t, env, CreateNoteRequest{TypeID: "Basic", DeckID: 1, FieldVals: map[string]string{"Front": "discarded", "Back": "b"}}, nil)
+
+	rr = doJSONRequest(t, env.router, http.MethodPost, "/api/backups/restore", RestoreBackupRequest{BackupPath: filepath.Base(backup.BackupPath)})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected restore 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if restored := decodeJSON[RestoreBackupResponse](t, rr); restored.BackupPath != backup.BackupPath {
+		t.Fatalf("expected restore of %s, got %s", backup.BackupPath, restored.BackupPath)
+	}
+
+	if rr := doJSONRequest(t, env.router, http.MethodGet, fmt.Sprintf("/api/notes/%d", kept.Note.ID), nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected note from before the backup, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodGet, fmt.Sprintf("/api/notes/%d", discarded.Note.ID), nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected note from after the backup to be gone, got %d", rr.Code)
+	}
+	createNoteForTest(t, env, CreateNoteRequest{TypeID: "Basic", DeckID: 1, FieldVals: map[string]string{"Front": "after", "Back": "c"}}, nil)
+}
+
+func TestAPI_RestoreBackupRejectsFilesOutsideBackupDir(t *testing.T) {
+	env := setupFileAPITestEnv(t)
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/backups/restore", RestoreBackupRequest{BackupPath: env.dbPath})
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected restore of a non-backup 404, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodGet, "/api/decks", nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected the database to stay open, got %d", rr.Code)
+	}
+}
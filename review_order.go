@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Review orders a deck options preset can pick for the review part of the study queue.
+// Learning and new cards are always shown in due order.
+const (
+	reviewOrderDue            = "due"             // oldest due first
+	reviewOrderDueRandom      = "due_random"      // by due day, shuffled within each day
+	reviewOrderOverdueness    = "overdueness"     // most overdue relative to the interval first
+	reviewOrderIntervalAsc    = "interval_asc"    // shortest interval first
+	reviewOrderDifficultyDesc = "difficulty_desc" // hardest (highest FSRS difficulty) first
+)
+
+func isValidReviewOrder(order string) bool {
+	switch order {
+	case reviewOrderDue, reviewOrderDueRandom, reviewOrderOverdueness, reviewOrderIntervalAsc, reviewOrderDifficultyDesc:
+		return true
+	}
+	return false
+}
+
+func reviewOrderOrDefault(order string) string {
+	if isValidReviewOrder(order) {
+		return order
+	}
+	return reviewOrderDue
+}
+
+// reviewOrderSQL returns the ORDER BY terms for a queue in the given order, where alias
+// is the table holding the cards' scheduling state and c the cards table. Orders are
+// computed in SQL from stable inputs, so every client is served the same queue; the
+// shuffle of due_random is seeded by the study day.
+func reviewOrderSQL(dialect sqlDialect, order, alias string, now, dayStart int64) string {
+	const daySeconds = 86400
+	tieBreak := fmt.Sprintf("%s.due ASC, c.id ASC", alias)
+	switch order {
+	case reviewOrderDueRandom:
+		// Offset keeps overdue days positive, as integer division truncates.
+		return fmt.Sprintf("(%[1]s.due - %[2]d + %[3]d) / %[4]d ASC, %[5]s ASC, c.id ASC",
+			alias, dayStart, maxSetDueDays*daySeconds, daySeconds, seededShuffleSQL("c.id", dayStart/daySeconds))
+	case reviewOrderOverdueness:
+		scheduledDays := fmt.Sprintf("COALESCE(%s, 0)", dialect.jsonNumber(alias+".fsrs_data", "ScheduledDays"))
+		return fmt.Sprintf("CAST(%d - %s.due AS DOUBLE PRECISION) / %s DESC, %s",
+			now, alias, dialect.greatest(scheduledDays, "1"), tieBreak)
+	case reviewOrderIntervalAsc:
+		return fmt.Sprintf("COALESCE(%s, 0) ASC, %s", dialect.jsonNumber(alias+".fsrs_data", "ScheduledDays"), tieBreak)
+	case reviewOrderDifficultyDesc:
+		return fmt.Sprintf("COALESCE(%s, 0) DESC, %s", dialect.jsonNumber(alias+".fsrs_data", "Difficulty"), tieBreak)
+	}
+	return tieBreak
+}
+
+// shufflePrime is the modulus of seededShuffleSQL: below 2^31, so products of two
+// residues fit in 64-bit integers, and 2 mod 3, so cubing permutes the residues.
+const shufflePrime = 2147483579
+
+// seededShuffleSQL is an integer key that shuffles rows by column, a positive integer
+// such as a card ID, differently for every seed. The column goes through an affine map
+// chosen by the seed and is then cubed, both modulo shufflePrime; as each step is a
+// permutation, distinct IDs below the prime get distinct keys. The arithmetic is the
+// same in every dialect.
+func seededShuffleSQL(column string, seed int64) string {
+	// splitmix64 spreads nearby seeds, such as consecutive days, far apart.
+	z := uint64(seed) + 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z ^= z >> 31
+	a := 1 + z%(shufflePrime-1)
+	b := (z >> 32) % shufflePrime
+	affine := fmt.Sprintf("((%s %% %d) * %d + %d) %% %d", column, shufflePrime, a, b, shufflePrime)
+	return fmt.Sprintf("((%[1]s * %[1]s %% %[2]d) * %[1]s %% %[2]d)", affine, shufflePrime)
+}
+
+// reviewOrderForDeck reads the review order of a deck's options preset.
+func (s *SQLStore) reviewOrderForDeck(ctx context.Context, deckID int64) (string, error) {
+	var order sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT o.review_order FROM decks d LEFT JOIN deck_options o ON o.id = d.options_id WHERE d.id = ?
+	`, deckID).Scan(&order)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+	return reviewOrderOrDefault(order.String), nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestAPI_ReviewOrderOptions(t *testing.T) {
+	env := setupAPITestEnv(t)
+	var ids []int64
+	for _, front := range []string{"Alpha", "Beta", "Gamma"} {
+		created := createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": front, "Back": "Letter"},
+		}, nil)
+		id := created.Cards[0].ID
+		if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", id), AnswerCardRequest{Rating: 4}); rr.Code != http.StatusOK {
+			t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+		ids = append(ids, id)
+	}
+	var userID string
+	if err := env.store.db.QueryRow(`SELECT user_id FROM card_review_states LIMIT 1`).Scan(&userID); err != nil {
+		t.Fatalf("failed to find the test user: %v", err)
+	}
+
+	dayStart, _ := studyDayBounds(time.Now(), defaultNextDayStartsAt)
+	states := []struct {
+		due        time.Time
+		interval   uint64
+		difficulty float64
+	}{
+		{dayStart.Add(-24 * time.Hour), 100, 3}, // Alpha
+		{dayStart.Add(-48 * time.Hour), 2, 8},   // Beta
+		{dayStart.Add(-12 * time.Hour), 1, 5},   // Gamma
+	}
+	for i, state := range states {
+		card, err := env.store.GetCardForUser(context.Background(), userID, ids[i])
+		if err != nil {
+			t.Fatalf("failed to load card: %v", err)
+		}
+		card.SRS.State = fsrs.Review
+		card.SRS.Due = state.due
+		card.SRS.ScheduledDays = state.interval
+		card.SRS.Difficulty = state.difficulty
+		if err := env.store.UpdateCardReviewState(context.Background(), userID, card); err != nil {
+			t.Fatalf("failed to update card: %v", err)
+		}
+	}
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/deck-options", map[string]interface{}{"name": "Ordered"})
+	preset := decodeJSON[DeckOptionsResponse](t, rr)
+	if rr.Code != http.StatusCreated || preset.ReviewOrder != reviewOrderDue {
+		t.Fatalf("expected a preset in due order, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/deck-options/%d/assign", preset.ID), AssignDeckOptionsRequest{DeckIDs: []int64{1}}); rr.Code != http.StatusOK {
+		t.Fatalf("expected assign 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/deck-options/%d", preset.ID), map[string]string{"reviewOrder": "alphabetical"}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected unknown review order 400, got %d", rr.Code)
+	}
+
+	queue := func(order string) []int64 {
+		t.Helper()
+		if rr := doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/deck-options/%d", preset.ID), map[string]string{"reviewOrder": order}); rr.Code != http.StatusOK {
+			t.Fatalf("expected update 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+		var got []int64
+		for _, card := range decodeJSON[[]*Card](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=10", "")) {
+			got = append(got, card.ID)
+		}
+		return got
+	}
+	alpha, beta, gamma := ids[0], ids[1], ids[2]
+	for order, want := range map[string][]int64{
+		reviewOrderDue:            {beta, alpha, gamma},
+		reviewOrderOverdueness:    {beta, gamma, alpha},
+		reviewOrderIntervalAsc:    {gamma, beta, alpha},
+		reviewOrderDifficultyDesc: {beta, gamma, alpha},
+	} {
+		if got := queue(order); !slices.Equal(got, want) {
+			t.Fatalf("%s: expected %v, got %v", order, want, got)
+		}
+	}
+
+	// Due-then-random keeps due days in order and is stable between requests.
+	first := queue(reviewOrderDueRandom)
+	if len(first) != 3 || first[0] != beta || !slices.Equal(first, queue(reviewOrderDueRandom)) {
+		t.Fatalf("expected a stable shuffle after the oldest day, got %v", first)
+	}
+}
+
+func TestSeededShuffleSQLOrdersDifferentlyPerSeed(t *testing.T) {
+	store := newMemoryStoreForTest(t)
+	shuffle := func(seed int64) []int64 {
+		t.Helper()
+		rows, err := store.db.Query(`
+			WITH RECURSIVE c(id) AS (SELECT 1 UNION ALL SELECT id + 1 FROM c WHERE id < 40)
+			SELECT id FROM c ORDER BY ` + seededShuffleSQL("c.id", seed) + `, c.id`)
+		if err != nil {
+			t.Fatalf("shuffle query failed: %v", err)
+		}
+		defer rows.Close()
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				t.Fatalf("scan failed: %v", err)
+			}
+			ids = append(ids, id)
+		}
+		return ids
+	}
+	// isRotation reports whether b is a cyclic rotation of a, which is all the old
+	// shuffle's seed could change.
+	isRotation := func(a, b []int64) bool {
+		for shift := range a {
+			if slices.Equal(append(slices.Clone(a[shift:]), a[:shift]...), b) {
+				return true
+			}
+		}
+		return false
+	}
+
+	first, again, second := shuffle(20000), shuffle(20000), shuffle(20001)
+	if len(first) != 40 || !slices.Equal(first, again) {
+		t.Fatalf("expected a stable shuffle of 40 rows, got %v and %v", first, again)
+	}
+	if isRotation(first, second) || isRotation(first, slices.Sorted(slices.Values(first))) {
+		t.Fatalf("expected seeds to order the rows differently, got %v and %v", first, second)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// revlogCSVHeader names the columns of GET /api/export/revlog.csv. States are FSRS
+// states (0 new, 1 learning, 2 review, 3 relearning); stability, difficulty, and
+// next_state are empty for entries recorded before they were kept.
+var revlogCSVHeader = []string{
+	"review_id", "reviewed_at", "card_id", "note_id", "deck_id", "rating", "state", "next_state", "kind",
+	"time_taken_ms", "elapsed_days", "scheduled_days", "stability", "difficulty", "due",
+}
+
+// revlogCSVFlushEvery is how many rows are written between flushes of the stream.
+const revlogCSVFlushEvery = 500
+
+// EachRevlogEntry calls fn for the user's review log entries in a collection reviewed
+// at or after since, oldest first, with each card's note and current deck. A deckID of
+// 0 covers every deck; otherwise the deck and its descendants.
+func (s *SQLStore) EachRevlogEntry(ctx context.Context, userID, collectionID string, deckID int64, since time.Time, fn func(entry RevlogEntry, noteID, deckID int64) error) error {
+	query := `
+		SELECT ` + revlogEntryColumns + `, c.note_id, c.deck_id
+		FROM revlog r
+		JOIN cards c ON c.id = r.card_id
+		JOIN decks d ON d.id = c.deck_id
+		WHERE d.collection_id = ? AND COALESCE(r.reviewed_at, 0) >= ?
+	`
+	args := []interface{}{collectionID, since.Unix()}
+	if strings.TrimSpace(userID) != "" {
+		query += ` AND r.user_id = ?`
+		args = append(args, userID)
+	}
+	if deckID > 0 {
+		query = deckSubtreeCTE + query + ` AND c.deck_id IN (SELECT id FROM subtree)`
+		args = append([]interface{}{deckID}, args...)
+	}
+	query += ` ORDER BY r.reviewed_at, r.id`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var noteID, cardDeckID int64
+		entry, err := scanRevlogEntry(rows, &noteID, &cardDeckID)
+		if err != nil {
+			return err
+		}
+		if err := fn(entry, noteID, cardDeckID); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// parseExportSince reads the since parameter: a YYYY-MM-DD date (local midnight), an
+// RFC 3339 time, or empty for the whole history.
+func parseExportSince(raw string) (time.Time, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Unix(0, 0), true
+	}
+	if date, err := time.ParseInLocation("2006-01-02", raw, time.Local); err == nil {
+		return date, true
+	}
+	if at, err := time.Parse(time.RFC3339, raw); err == nil {
+		return at, true
+	}
+	return time.Time{}, false
+}
+
+func optionalFloatCSV(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
+
+// ExportRevlogCSV serves GET /api/export/revlog.csv, streaming the caller's review log
+// as CSV for analysis outside the app. Query parameters: deckId and since.
+func (h *APIHandler) ExportRevlogCSV(w http.ResponseWriter, r *http.Request) {
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	var deckID int64
+	if raw := strings.TrimSpace(r.URL.Query().Get("deckId")); raw != "" {
+		deckID, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || col.Decks[deckID] == nil {
+			respondAPIError(w, http.StatusBadRequest, "invalid_deck_id", "Deck not found")
+			return
+		}
+	}
+	since, ok := parseExportSince(r.URL.Query().Get("since"))
+	if !ok {
+		respondAPIError(w, http.StatusBadRequest, "invalid_since", "since must be a YYYY-MM-DD date or an RFC 3339 time")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="revlog.csv"`)
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	out := csv.NewWriter(w)
+	_ = out.Write(revlogCSVHeader)
+
+	// The status is already sent, so an error part-way can only cut the stream short.
+	written := 0
+	_ = h.store.EachRevlogEntry(r.Context(), h.userIDFromRequest(r), collectionID, deckID, since, func(entry RevlogEntry, noteID, cardDeckID int64) error {
+		nextState := ""
+		if entry.NextState != nil {
+			nextState = strconv.Itoa(*entry.NextState)
+		}
+		if err := out.Write([]string{
+			strconv.FormatInt(entry.ID, 10),
+			entry.ReviewedAt.UTC().Format(time.RFC3339),
+			strconv.FormatInt(entry.CardID, 10),
+			strconv.FormatInt(noteID, 10),
+			strconv.FormatInt(cardDeckID, 10),
+			strconv.Itoa(entry.Rating),
+			strconv.Itoa(entry.State),
+			nextState,
+			entry.Kind,
+			strconv.Itoa(entry.TimeTakenMs),
+			strconv.Itoa(entry.ElapsedDays),
+			strconv.Itoa(entry.ScheduledDays),
+			optionalFloatCSV(entry.Stability),
+			optionalFloatCSV(entry.Difficulty),
+			entry.Due.UTC().Format(time.RFC3339),
+		}); err != nil {
+			return err
+		}
+		if written++; written%revlogCSVFlushEvery == 0 {
+			out.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return out.Error()
+	})
+	out.Flush()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPI_ExportRevlogCSV(t *testing.T) {
+	env := setupAPITestEnv(t)
+	note := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Pons", "Back": "Bridge"},
+	}, nil)
+	id := note.Cards[0].ID
+	for _, rating := range []int{1, 3} {
+		if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", id), AnswerCardRequest{Rating: rating, TimeTakenMs: 2500}); rr.Code != http.StatusOK {
+			t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := doRawRequest(env.router, http.MethodGet, "/api/export/revlog.csv?deckId=1", "")
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("expected a CSV download, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil || len(records) != 3 || strings.Join(records[0], ",") != strings.Join(revlogCSVHeader, ",") {
+		t.Fatalf("expected a header and two rows, got %v (%v)", records, err)
+	}
+	first := records[1]
+	if first[2] != fmt.Sprint(id) || first[3] != fmt.Sprint(note.Note.ID) || first[5] != "1" || first[9] != "2500" || first[12] == "" {
+		t.Fatalf("expected the first answer with its note and memory state, got %v", first)
+	}
+
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	rr = doRawRequest(env.router, http.MethodGet, "/api/export/revlog.csv?since="+tomorrow, "")
+	if records, _ := csv.NewReader(rr.Body).ReadAll(); len(records) != 1 {
+		t.Fatalf("expected only the header for a future since, got %v", records)
+	}
+	if rr := doRawRequest(env.router, http.MethodGet, "/api/export/revlog.csv?since=yesterday", ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid since to be rejected, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// Review kinds recorded on revlog entries. Statistics and daily limits only treat
+// learn, review, and relearn entries as real study; filtered entries come from cram and
+// filtered-deck sessions, and manual entries mark reschedules that involved no answer.
+const (
+	reviewKindLearn    = "learn"
+	reviewKindReview   = "review"
+	reviewKindRelearn  = "relearn"
+	reviewKindFiltered = "filtered"
+	reviewKindManual   = "manual"
+)
+
+func isValidReviewKind(kind string) bool {
+	switch kind {
+	case reviewKindLearn, reviewKindReview, reviewKindRelearn, reviewKindFiltered, reviewKindManual:
+		return true
+	}
+	return false
+}
+
+// reviewKindForState classifies an answer by the state the card was in before it.
+func reviewKindForState(state fsrs.State) string {
+	switch state {
+	case fsrs.Review:
+		return reviewKindReview
+	case fsrs.Relearning:
+		return reviewKindRelearn
+	default:
+		return reviewKindLearn
+	}
+}
+
+// insertManualRevlogTx logs a reschedule that moved a card to due without an answer.
+// Rating 0 marks it as such, matching Anki's convention for manual entries.
+func insertManualRevlogTx(ctx context.Context, tx *sql.Tx, userID string, cardID int64, state int, due, now time.Time) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO revlog (id, user_id, card_id, rating, state, due, reviewed_at, time_taken_ms, kind)
+		VALUES (?, ?, ?, 0, ?, ?, ?, 0, ?)
+	`, time.Now().UnixNano(), nullIfEmpty(userID), cardID, state, due.Unix(), now.Unix(), reviewKindManual)
+	return err
+}
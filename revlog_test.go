@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"net/http"
 	"os"
 	"testing"
 	"time"
@@ -22,7 +25,7 @@ func TestRevlogPersistence(t *testing.T) {
 
 	// Create a deck
 	deck := col.NewDeck("Revlog Test Deck")
-	err = store.CreateDeck(deck)
+	err = store.CreateDeck(context.Background(), deck)
 	if err != nil {
 		t.Fatalf("Failed to create deck: %v", err)
 	}
@@ -37,14 +40,14 @@ func TestRevlogPersistence(t *testing.T) {
 	}
 
 	// Persist note
-	err = store.CreateNote("default", &note)
+	err = store.CreateNote(context.Background(), "default", &note)
 	if err != nil {
 		t.Fatalf("Failed to persist note: %v", err)
 	}
 
 	// Persist card
 	card := cards[0]
-	err = store.CreateCard(card)
+	err = store.CreateCard(context.Background(), card)
 	if err != nil {
 		t.Fatalf("Failed to persist card: %v", err)
 	}
@@ -60,7 +63,7 @@ func TestRevlogPersistence(t *testing.T) {
 	}
 
 	// Persist the revlog entry (simulating what server.go does)
-	err = store.AddRevlog(revlog, cardID, timeTakenMs)
+	err = store.AddRevlog(context.Background(), revlog, cardID, timeTakenMs)
 	if err != nil {
 		t.Fatalf("Failed to persist revlog: %v", err)
 	}
@@ -101,7 +104,7 @@ func TestRevlogPersistence(t *testing.T) {
 			t.Fatalf("Failed to answer card with rating %v: %v", rating, err)
 		}
 
-		err = store.AddRevlog(revlog, cardID, times[i])
+		err = store.AddRevlog(context.Background(), revlog, cardID, times[i])
 		if err != nil {
 			t.Fatalf("Failed to persist revlog for rating %v: %v", rating, err)
 		}
@@ -120,3 +123,68 @@ func TestRevlogPersistence(t *testing.T) {
 		t.Errorf("Expected %d revlog entries with non-zero time, got %d", expectedCount, count)
 	}
 }
+
+// TestRevlogPersistsFSRSDetail validates that answering a card keeps the FSRS memory state
+// and interval data alongside the rating.
+func TestRevlogPersistsFSRSDetail(t *testing.T) {
+	env := setupAPITestEnv(t)
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Detail", "Back": "Answer"},
+	}, nil)
+	cardID := created.Cards[0].ID
+
+	for _, rating := range []int{3, 3} {
+		rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", cardID), AnswerCardRequest{Rating: rating, TimeTakenMs: 900})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+	}
+
+	entries, err := env.store.ListRevlogEntriesForCard(context.Background(), "", cardID, 10)
+	if err != nil {
+		t.Fatalf("Failed to list revlog: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 revlog entries, got %d", len(entries))
+	}
+	for _, entry := range entries {
+		if entry.Stability == nil || *entry.Stability <= 0 {
+			t.Errorf("Expected positive stability, got %v", entry.Stability)
+		}
+		if entry.Difficulty == nil || *entry.Difficulty <= 0 {
+			t.Errorf("Expected positive difficulty, got %v", entry.Difficulty)
+		}
+		if entry.Kind != reviewKindLearn {
+			t.Errorf("Expected learn kind for a new card, got %q", entry.Kind)
+		}
+	}
+
+	legacy := &fsrs.ReviewLog{Rating: fsrs.Good, State: fsrs.Review, Review: time.Now(), ElapsedDays: 4, ScheduledDays: 9}
+	if err := env.store.AddRevlog(context.Background(), legacy, cardID, 500); err != nil {
+		t.Fatalf("Failed to persist revlog: %v", err)
+	}
+	logs, err := env.store.GetRevlogForCard(context.Background(), cardID)
+	if err != nil {
+		t.Fatalf("Failed to load revlog: %v", err)
+	}
+	last := logs[len(logs)-1]
+	if last.ElapsedDays != 4 || last.ScheduledDays != 9 {
+		t.Errorf("Expected elapsed/scheduled days 4/9, got %d/%d", last.ElapsedDays, last.ScheduledDays)
+	}
+	entries, err = env.store.ListRevlogEntriesForCard(context.Background(), "", cardID, 1)
+	if err != nil {
+		t.Fatalf("Failed to list revlog: %v", err)
+	}
+	if entries[0].Stability != nil {
+		t.Errorf("Expected no stability without a memory state, got %v", *entries[0].Stability)
+	}
+	if entries[0].Kind != reviewKindReview {
+		t.Errorf("Expected kind derived from review state, got %q", entries[0].Kind)
+	}
+
+	if err := env.store.AddRevlogDetailForUser(context.Background(), "", legacy, fsrs.Card{}, "bogus", cardID, 0); err == nil {
+		t.Errorf("Expected invalid review kind to be rejected")
+	}
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// searchTerm is a single key:value condition of a search string.
+//
+// Supported syntax, following Anki's browser:
+//
+//	dog            a field containing "dog"
+//	"a phrase"     a field containing the phrase
+//	-dog           negates any term or group
+//	a b            both terms (AND is implied; "and" may be written out)
+//	a or b         either term
+//	(a or b) c     parentheses group terms
+//	front:dog      the Front field is exactly "dog" (use * for wildcards)
+//	deck:Spanish   cards in the deck or its sub-decks
+//	tag:verb       notes tagged verb or verb::anything; tag:none for untagged notes
+//	note:Basic     notes of the note type
+//	card:2         cards of the second template or cloze c2; card:Reverse by name
+//	is:due         review and learning cards that are due now
+//	is:new, is:learn, is:review, is:suspended, is:marked
+//	flag:2         cards with the flag (0 for none)
+//	added:7        notes added in the last 7 days, today included
+//	nid:1,2,3      notes by ID
+//	cid:1,2,3      cards by ID
+type searchTerm struct {
+	key   string // "" for plain text
+	value string
+}
+
+// searchNode is a parsed search. Leaves hold a term; inner nodes combine their children.
+type searchNode struct {
+	op       string // searchOpAnd, searchOpOr, searchOpNot, or "" for a leaf
+	children []searchNode
+	term     searchTerm
+}
+
+const (
+	searchOpAnd = "and"
+	searchOpOr  = "or"
+	searchOpNot = "not"
+)
+
+// searchToken is a word or parenthesis from a search string. Quoted words are never
+// treated as operators, so "or" in quotes searches for the text.
+type searchToken struct {
+	text   string
+	quoted bool
+	paren  bool
+}
+
+func tokenizeSearch(raw string) ([]searchToken, error) {
+	var (
+		tokens  []searchToken
+		current strings.Builder
+		quoted  bool
+		used    bool // any quote seen in the current word
+		started bool
+	)
+	flush := func() {
+		if started {
+			tokens = append(tokens, searchToken{text: current.String(), quoted: used})
+		}
+		current.Reset()
+		started, used = false, false
+	}
+
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			used, started = true, true
+		case quoted:
+			current.WriteRune(r)
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			flush()
+		case r == '(' && (!started || (current.String() == "-" && !used)):
+			// "(" opens a group at the start of a word; "-(" negates one.
+			if started {
+				tokens = append(tokens, searchToken{text: "-", paren: true})
+				current.Reset()
+				started = false
+			}
+			tokens = append(tokens, searchToken{text: "(", paren: true})
+		case r == ')':
+			flush()
+			tokens = append(tokens, searchToken{text: ")", paren: true})
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unbalanced quote in search")
+	}
+	flush()
+	return tokens, nil
+}
+
+// parseSearch parses a search string. An empty search returns a nil node, which
+// matches everything.
+func parseSearch(raw string) (*searchNode, error) {
+	tokens, err := tokenizeSearch(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	p := &searchParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in search", p.tokens[p.pos].text)
+	}
+	return &node, nil
+}
+
+type searchParser struct {
+	tokens []searchToken
+	pos    int
+}
+
+func (p *searchParser) peekOperator(op string) bool {
+	if p.pos >= len(p.tokens) {
+		return false
+	}
+	token := p.tokens[p.pos]
+	return !token.quoted && !token.paren && strings.EqualFold(token.text, op)
+}
+
+func (p *searchParser) peekParen(text string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].paren && p.tokens[p.pos].text == text
+}
+
+func (p *searchParser) parseOr() (searchNode, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return searchNode{}, err
+	}
+	nodes := []searchNode{first}
+	for p.peekOperator(searchOpOr) {
+		p.pos++
+		next, err := p.parseAnd()
+		if err != nil {
+			return searchNode{}, err
+		}
+		nodes = append(nodes, next)
+	}
+	if len(nodes) == 1 {
+		return first, nil
+	}
+	return searchNode{op: searchOpOr, children: nodes}, nil
+}
+
+func (p *searchParser) parseAnd() (searchNode, error) {
+	var nodes []searchNode
+	for p.pos < len(p.tokens) && !p.peekParen(")") && !p.peekOperator(searchOpOr) {
+		if p.peekOperator(searchOpAnd) {
+			p.pos++
+			continue
+		}
+		node, err := p.parseUnary()
+		if err != nil {
+			return searchNode{}, err
+		}
+		nodes = append(nodes, node)
+	}
+	switch len(nodes) {
+	case 0:
+		return searchNode{}, fmt.Errorf("search has an empty group or a dangling operator")
+	case 1:
+		return nodes[0], nil
+	}
+	return searchNode{op: searchOpAnd, children: nodes}, nil
+}
+
+func (p *searchParser) parseUnary() (searchNode, error) {
+	if p.peekParen("-") {
+		p.pos++
+		inner, err := p.parseUnary()
+		if err != nil {
+			return searchNode{}, err
+		}
+		return searchNode{op: searchOpNot, children: []searchNode{inner}}, nil
+	}
+	if p.peekParen("(") {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return searchNode{}, err
+		}
+		if !p.peekParen(")") {
+			return searchNode{}, fmt.Errorf("unbalanced parenthesis in search")
+		}
+		p.pos++
+		return inner, nil
+	}
+	if p.peekParen(")") {
+		return searchNode{}, fmt.Errorf("unbalanced parenthesis in search")
+	}
+
+	token := p.tokens[p.pos]
+	p.pos++
+	text := token.text
+	negate := false
+	if strings.HasPrefix(text, "-") && len(text) > 1 {
+		negate = true
+		text = text[1:]
+	}
+	term := searchTerm{value: text}
+	if idx := strings.Index(text, ":"); idx > 0 {
+		term.key = strings.ToLower(text[:idx])
+		term.value = text[idx+1:]
+	}
+	if term.value == "" {
+		return searchNode{}, fmt.Errorf("search term %q has no value", token.text)
+	}
+	node := searchNode{term: term}
+	if negate {
+		node = searchNode{op: searchOpNot, children: []searchNode{node}}
+	}
+	return node, nil
+}
+
+// searchLikePattern turns a search value into a LIKE pattern: * matches any run of
+// characters and _ a single character, as in Anki. Use with ESCAPE '\'.
+func searchLikePattern(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `*`, `%`)
+	return replacer.Replace(value)
+}
+
+// searchCompiler turns search nodes into SQL over notes aliased n and cards aliased c.
+// When userID is set, card scheduling columns come from that user's review state,
+// joined as rs.
+type searchCompiler struct {
+	dialect      sqlDialect
+	collectionID string
+	userID       string
+	now          time.Time
+}
+
+// cardColumn returns the expression for a per-user card column.
+func (sc searchCompiler) cardColumn(name string) string {
+	if sc.userID == "" {
+		return "c." + name
+	}
+	return fmt.Sprintf("COALESCE(rs.%s, c.%s)", name, name)
+}
+
+// from returns the FROM clause and its arguments for a card-level search.
+func (sc searchCompiler) from(notesOnly bool) (string, []interface{}) {
+	join := "JOIN cards c ON c.note_id = n.id"
+	if notesOnly {
+		// Keep notes without cards searchable by their fields and tags.
+		join = "LEFT JOIN cards c ON c.note_id = n.id"
+	}
+	if sc.userID == "" {
+		return "FROM notes n " + join, nil
+	}
+	return "FROM notes n " + join + " LEFT JOIN card_review_states rs ON rs.card_id = c.id AND rs.user_id = ?", []interface{}{sc.userID}
+}
+
+func (sc searchCompiler) compile(node searchNode) (string, []interface{}, error) {
+	switch node.op {
+	case "":
+		return sc.termCondition(node.term)
+	case searchOpNot:
+		cond, args, err := sc.compile(node.children[0])
+		if err != nil {
+			return "", nil, err
+		}
+		// Card columns are NULL for notes without cards; treat those as not matching.
+		return "NOT COALESCE(" + cond + ", FALSE)", args, nil
+	}
+
+	parts := make([]string, 0, len(node.children))
+	var args []interface{}
+	for _, child := range node.children {
+		cond, childArgs, err := sc.compile(child)
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, "("+cond+")")
+		args = append(args, childArgs...)
+	}
+	return strings.Join(parts, " "+strings.ToUpper(node.op)+" "), args, nil
+}
+
+func parseSearchIDs(key, value string) ([]interface{}, error) {
+	var ids []interface{}
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: expects IDs, got %q", key, part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (sc searchCompiler) termCondition(t searchTerm) (string, []interface{}, error) {
+	pattern := searchLikePattern(t.value)
+	like := sc.dialect.like()
+	fields := sc.dialect.jsonObjectEntries("n.field_vals", "f")
+	tags := sc.dialect.jsonArrayValues("n.tags", "t")
+	switch t.key {
+	case "":
+		return `EXISTS (SELECT 1 FROM ` + fields + ` WHERE f.value ` + like + ` ? ESCAPE '\')`,
+			[]interface{}{"%" + pattern + "%"}, nil
+	case "deck":
+		return `c.deck_id IN (
+			SELECT id FROM decks WHERE collection_id = ? AND (name ` + like + ` ? ESCAPE '\' OR name ` + like + ` ? ESCAPE '\')
+		)`, []interface{}{sc.collectionID, pattern, pattern + "::%"}, nil
+	case "tag":
+		if strings.EqualFold(t.value, "none") {
+			return `COALESCE(` + sc.dialect.jsonArrayLength("n.tags") + `, 0) = 0`, nil, nil
+		}
+		return `EXISTS (SELECT 1 FROM ` + tags + ` WHERE t.value ` + like + ` ? ESCAPE '\' OR t.value ` + like + ` ? ESCAPE '\')`,
+			[]interface{}{pattern, pattern + "::%"}, nil
+	case "note":
+		return `n.type_id IN (SELECT id FROM note_types WHERE collection_id = ? AND name ` + like + ` ? ESCAPE '\')`,
+			[]interface{}{sc.collectionID, pattern}, nil
+	case "card":
+		if n, err := strconv.Atoi(t.value); err == nil {
+			return `(c.ordinal = ? OR c.template_name = ?)`, []interface{}{n, fmt.Sprintf("Card %d", n)}, nil
+		}
+		return `c.template_name ` + like + ` ? ESCAPE '\'`, []interface{}{pattern}, nil
+	case "is":
+		return sc.stateCondition(strings.ToLower(t.value))
+	case "flag":
+		flag, err := strconv.Atoi(t.value)
+		if err != nil || flag < 0 || flag > 7 {
+			return "", nil, fmt.Errorf("flag: expects a number from 0 to 7, got %q", t.value)
+		}
+		return sc.cardColumn("flag") + ` = ?`, []interface{}{flag}, nil
+	case "added":
+		days, err := strconv.Atoi(t.value)
+		if err != nil || days <= 0 {
+			return "", nil, fmt.Errorf("added: expects a positive number of days, got %q", t.value)
+		}
+		dayStart := time.Date(sc.now.Year(), sc.now.Month(), sc.now.Day(), 0, 0, 0, 0, sc.now.Location())
+		return `n.created_at >= ?`, []interface{}{dayStart.AddDate(0, 0, -(days - 1)).Unix()}, nil
+	case "nid", "cid":
+		ids, err := parseSearchIDs(t.key, t.value)
+		if err != nil {
+			return "", nil, err
+		}
+		column := "n.id"
+		if t.key == "cid" {
+			column = "c.id"
+		}
+		return fmt.Sprintf(`%s IN (%s)`, column, strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")), ids, nil
+	default:
+		// Any other key names a field, matched against the whole field value.
+		return `EXISTS (SELECT 1 FROM ` + fields + ` WHERE lower(f.key) = lower(?) AND f.value ` + like + ` ? ESCAPE '\')`,
+			[]interface{}{t.key, pattern}, nil
+	}
+}
+
+func (sc searchCompiler) stateCondition(value string) (string, []interface{}, error) {
+	state := sc.cardColumn("state")
+	switch value {
+	case "due":
+		return fmt.Sprintf(`%s IN (?, ?, ?) AND %s <= ? AND %s = 0`, state, sc.cardColumn("due"), sc.cardColumn("suspended")),
+			[]interface{}{int(fsrs.Learning), int(fsrs.Review), int(fsrs.Relearning), sc.now.Unix()}, nil
+	case "new":
+		return state + ` = ?`, []interface{}{int(fsrs.New)}, nil
+	case "learn":
+		return state + ` IN (?, ?)`, []interface{}{int(fsrs.Learning), int(fsrs.Relearning)}, nil
+	case "review":
+		return state + ` IN (?, ?)`, []interface{}{int(fsrs.Review), int(fsrs.Relearning)}, nil
+	case "suspended":
+		return sc.cardColumn("suspended") + ` = 1`, nil, nil
+	case "marked":
+		return sc.cardColumn("marked") + ` = 1`, nil, nil
+	}
+	return "", nil, fmt.Errorf("unknown search is:%s", value)
+}
+
+// compileSearch builds the SELECT for a search. With notesOnly it selects distinct note
+// IDs; otherwise card IDs. Errors are always problems with the search string, so
+// handlers can report them as bad requests.
+func compileSearch(dialect sqlDialect, collectionID, userID, query string, notesOnly bool, now time.Time) (string, []interface{}, error) {
+	node, err := parseSearch(query)
+	if err != nil {
+		return "", nil, err
+	}
+	sc := searchCompiler{dialect: dialect, collectionID: collectionID, userID: strings.TrimSpace(userID), now: now}
+
+	from, args := sc.from(notesOnly)
+	where := `n.collection_id = ?`
+	args = append(args, collectionID)
+	if node != nil {
+		cond, condArgs, err := sc.compile(*node)
+		if err != nil {
+			return "", nil, err
+		}
+		where += " AND (" + cond + ")"
+		args = append(args, condArgs...)
+	}
+	if notesOnly {
+		return `SELECT DISTINCT n.id ` + from + ` WHERE ` + where + ` ORDER BY n.id ASC`, args, nil
+	}
+	return `SELECT c.id ` + from + ` WHERE ` + where + ` ORDER BY c.id ASC`, args, nil
+}
+
+// validateSearch reports what is wrong with a search string, if anything. Whether a
+// search compiles depends on neither the collection nor the database.
+func validateSearch(query string) error {
+	_, _, err := compileSearch(dialectSQLite, "", "", query, false, time.Now())
+	return err
+}
+
+func (s *SQLStore) queryIDs(ctx context.Context, sqlQuery string, args ...interface{}) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SearchNoteIDs returns the IDs of notes in the collection matching the search string,
+// in ascending order. An empty search matches every note.
+func (s *SQLStore) SearchNoteIDs(ctx context.Context, collectionID, userID, query string) ([]int64, error) {
+	sqlQuery, args, err := compileSearch(s.dialect, collectionID, userID, query, true, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return s.queryIDs(ctx, sqlQuery, args...)
+}
+
+// SearchCardIDs returns the IDs of cards in the collection matching the search string,
+// in ascending order, judging scheduling terms by userID's review state.
+func (s *SQLStore) SearchCardIDs(ctx context.Context, collectionID, userID, query string) ([]int64, error) {
+	if strings.TrimSpace(userID) != "" {
+		if err := s.EnsureReviewStatesForUser(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	sqlQuery, args, err := compileSearch(s.dialect, collectionID, userID, query, false, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return s.queryIDs(ctx, sqlQuery, args...)
+}
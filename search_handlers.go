@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	searchModeCards = "cards"
+	searchModeNotes = "notes"
+
+	defaultSearchLimit = 100
+	maxSearchLimit     = 1000
+)
+
+// SearchCardResult is a matching card with its deck name for display in the browser.
+type SearchCardResult struct {
+	Card
+	DeckName string `json:"deckName"`
+}
+
+type SearchResponse struct {
+	Query  string                 `json:"query"`
+	Mode   string                 `json:"mode"`
+	Total  int                    `json:"total"`
+	Offset int                    `json:"offset"`
+	Limit  int                    `json:"limit"`
+	Cards  []SearchCardResult     `json:"cards,omitempty"`
+	Notes  []NoteListItemResponse `json:"notes,omitempty"`
+}
+
+// Search serves GET /api/search?q=&mode=cards|notes&limit=&offset=. The query uses the
+// syntax described on searchTerm; an empty query matches everything.
+func (h *APIHandler) Search(w http.ResponseWriter, r *http.Request) {
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	userID := h.userIDFromRequest(r)
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	mode := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("mode")))
+	if mode == "" {
+		mode = searchModeCards
+	}
+	if mode != searchModeCards && mode != searchModeNotes {
+		respondAPIError(w, http.StatusBadRequest, "invalid_mode", "mode must be cards or notes")
+		return
+	}
+
+	limit := defaultSearchLimit
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			respondAPIError(w, http.StatusBadRequest, "invalid_limit", "Limit must be a positive integer")
+			return
+		}
+		limit = min(parsed, maxSearchLimit)
+	}
+	offset, err := parseCursorOffset(r.URL.Query().Get("offset"))
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_offset", "Offset must be a non-negative integer")
+		return
+	}
+
+	if err := validateSearch(query); err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+
+	var ids []int64
+	if mode == searchModeNotes {
+		ids, err = h.store.SearchNoteIDs(r.Context(), collectionID, userID, query)
+	} else {
+		ids, err = h.store.SearchCardIDs(r.Context(), collectionID, userID, query)
+	}
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "search_failed", err.Error())
+		return
+	}
+
+	resp := SearchResponse{Query: query, Mode: mode, Total: len(ids), Offset: offset, Limit: limit}
+	page := ids[min(offset, len(ids)):min(offset+limit, len(ids))]
+
+	if mode == searchModeNotes {
+		resp.Notes = make([]NoteListItemResponse, 0, len(page))
+		for _, id := range page {
+			note, ok := col.Notes[id]
+			if !ok {
+				continue
+			}
+			item, err := h.noteListItem(r.Context(), note, col)
+			if err != nil {
+				respondAPIError(w, http.StatusInternalServerError, "search_failed", err.Error())
+				return
+			}
+			resp.Notes = append(resp.Notes, item)
+		}
+		respondJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	resp.Cards = make([]SearchCardResult, 0, len(page))
+	for _, id := range page {
+		card, err := h.store.GetCardForUser(r.Context(), userID, id)
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "search_failed", err.Error())
+			return
+		}
+		result := SearchCardResult{Card: *card}
+		if deck, ok := col.Decks[card.DeckID]; ok {
+			result.DeckName = deck.Name
+		}
+		resp.Cards = append(resp.Cards, result)
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// formatSearchNode renders a parsed search in prefix form for comparison.
+func formatSearchNode(n searchNode) string {
+	if n.op == "" {
+		return fmt.Sprintf("%s:%q", n.term.key, n.term.value)
+	}
+	parts := make([]string, 0, len(n.children))
+	for _, child := range n.children {
+		parts = append(parts, formatSearchNode(child))
+	}
+	return n.op + "(" + strings.Join(parts, " ") + ")"
+}
+
+func TestParseSearch(t *testing.T) {
+	cases := map[string]string{
+		`dog -tag:verb "a phrase" deck:"My Deck" "note:Basic (and reversed card)"`: `and(:"dog" not(tag:"verb") :"a phrase" deck:"My Deck" note:"Basic (and reversed card)")`,
+		`a or b c`:              `or(:"a" and(:"b" :"c"))`,
+		`(a OR b) and c`:        `and(or(:"a" :"b") :"c")`,
+		`-(is:due or flag:1) x`: `and(not(or(is:"due" flag:"1")) :"x")`,
+		`"or" dog`:              `and(:"or" :"dog")`,
+	}
+	for query, want := range cases {
+		node, err := parseSearch(query)
+		if err != nil {
+			t.Fatalf("parse %q failed: %v", query, err)
+		}
+		if got := formatSearchNode(*node); got != want {
+			t.Fatalf("parse %q: expected %s, got %s", query, want, got)
+		}
+	}
+	if node, err := parseSearch("   "); err != nil || node != nil {
+		t.Fatalf("expected blank search to match everything, got %+v (%v)", node, err)
+	}
+
+	for _, bad := range []string{`"open`, `deck:`, `nid:1,x`, `(a or b`, `a)`, `a or`, `()`, `is:buried`, `flag:9`, `added:0`} {
+		if err := validateSearch(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+
+	if got := searchLikePattern(`50%_off*`); got != `50\%_off%` {
+		t.Fatalf("unexpected like pattern %q", got)
+	}
+}
+
+func TestSearchNoteIDs(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	add := func(front, back string, tags ...string) int64 {
+		return createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": front, "Back": back},
+			Tags:      tags,
+		}, nil).Note.ID
+	}
+	dog := add("the dog", "perro", "animals::mammals")
+	cat := add("the cat", "gato", "animals")
+	verb := add("to run", "correr", "verbs")
+
+	var collectionID string
+	if err := env.store.db.QueryRow(`SELECT collection_id FROM notes WHERE id = ?`, dog).Scan(&collectionID); err != nil {
+		t.Fatalf("failed to load collection id: %v", err)
+	}
+
+	cases := map[string][]int64{
+		"dog":                               {dog},
+		"DOG":                               {dog},
+		"-dog":                              {cat, verb},
+		"tag:animals":                       {dog, cat},
+		"tag:animals::*":                    {dog},
+		"back:gat*":                         {cat},
+		"front:dog":                         {},
+		"the -tag:animals":                  {},
+		"deck:Default":                      {dog, cat, verb},
+		"note:basic":                        {dog, cat, verb},
+		"note:Cloze":                        {},
+		"dog or cat":                        {dog, cat},
+		"(dog or cat) -tag:animals::*":      {cat},
+		"tag:none":                          {},
+		"added:1":                           {dog, cat, verb},
+		fmt.Sprintf("nid:%d,%d", dog, verb): {dog, verb},
+	}
+	for query, want := range cases {
+		got, err := env.store.SearchNoteIDs(context.Background(), collectionID, "", query)
+		if err != nil {
+			t.Fatalf("search %q failed: %v", query, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("search %q: expected %v, got %v", query, want, got)
+		}
+	}
+}
+
+func TestSearchCardsByScheduling(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	add := func(front string) createNoteAPIResponse {
+		return createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": front, "Back": "answer"},
+		}, nil)
+	}
+	fresh := add("fresh")
+	answered := add("answered")
+	flagged := add("flagged")
+	old := add("old")
+
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", answered.Cards[0].ID), AnswerCardRequest{Rating: 3}); rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	suspended := true
+	flag := 2
+	if rr := doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/cards/%d", flagged.Cards[0].ID), UpdateCardRequest{Flag: &flag, Suspended: &suspended}); rr.Code != http.StatusOK {
+		t.Fatalf("expected card update 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if _, err := env.store.db.Exec(`UPDATE notes SET created_at = ? WHERE id = ?`, time.Now().AddDate(0, 0, -30).Unix(), old.Note.ID); err != nil {
+		t.Fatalf("failed to backdate note: %v", err)
+	}
+
+	search := func(query string) SearchResponse {
+		t.Helper()
+		rr := doRawRequest(env.router, http.MethodGet, "/api/search?q="+url.QueryEscape(query), "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("search %q: expected 200, got %d (%s)", query, rr.Code, rr.Body.String())
+		}
+		return decodeJSON[SearchResponse](t, rr)
+	}
+	ids := func(resp SearchResponse) []int64 {
+		out := []int64{}
+		for _, card := range resp.Cards {
+			out = append(out, card.ID)
+		}
+		return out
+	}
+
+	cases := map[string][]int64{
+		"is:new -is:suspended":                   {fresh.Cards[0].ID, old.Cards[0].ID},
+		"is:suspended":                           {flagged.Cards[0].ID},
+		"flag:2":                                 {flagged.Cards[0].ID},
+		"is:learn or is:review":                  {answered.Cards[0].ID},
+		"-added:7":                               {old.Cards[0].ID},
+		"(fresh or old) -added:7":                {old.Cards[0].ID},
+		fmt.Sprintf("cid:%d", fresh.Cards[0].ID): {fresh.Cards[0].ID},
+	}
+	for query, want := range cases {
+		if got := ids(search(query)); !reflect.DeepEqual(got, want) {
+			t.Fatalf("search %q: expected %v, got %v", query, want, got)
+		}
+	}
+
+	resp := search("is:new")
+	if resp.Total != 3 || len(resp.Cards) != 3 || resp.Cards[0].DeckName == "" {
+		t.Fatalf("expected three new cards with deck names, got %+v", resp)
+	}
+
+	rr := doRawRequest(env.router, http.MethodGet, "/api/search?mode=notes&limit=1&offset=1&q=answer", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected notes search 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	notes := decodeJSON[SearchResponse](t, rr)
+	if notes.Total != 4 || len(notes.Notes) != 1 || notes.Notes[0].ID != answered.Note.ID {
+		t.Fatalf("expected the second of four notes, got %+v", notes)
+	}
+
+	if rr := doRawRequest(env.router, http.MethodGet, "/api/search?q="+url.QueryEscape("(unbalanced"), ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid search 400, got %d", rr.Code)
+	}
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -11,6 +14,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -26,16 +30,23 @@ func sanitizeHTML(input string) string {
 
 // APIHandler wraps the store and provides HTTP handlers
 type APIHandler struct {
-	store               *SQLiteStore
+	store               Store
 	collectionID        string
 	collection          *Collection
 	backupManager       *BackupManager
 	config              AppConfig
 	emailSender         EmailSender
 	subscriptionBilling subscriptionBillingProvider
+	tts                 ttsProvider
+	remoteImportClient  *http.Client
+	oidc                oidcProviderCache
+	profiles            profileState
+	background          sync.WaitGroup
+	events              eventHub
+	operations          operationRegistry
 }
 
-func NewAPIHandler(store *SQLiteStore, collection *Collection, backupMgr *BackupManager) *APIHandler {
+func NewAPIHandler(store Store, collection *Collection, backupMgr *BackupManager) *APIHandler {
 	cfg, err := LoadAppConfig()
 	if err != nil {
 		cfg = mustLocalAppConfig()
@@ -43,16 +54,27 @@ func NewAPIHandler(store *SQLiteStore, collection *Collection, backupMgr *Backup
 	return NewAPIHandlerWithConfig(store, collection, backupMgr, cfg, NewEmailSender(cfg))
 }
 
-func NewAPIHandlerWithConfig(store *SQLiteStore, collection *Collection, backupMgr *BackupManager, cfg AppConfig, emailSender EmailSender) *APIHandler {
-	return &APIHandler{
+func NewAPIHandlerWithConfig(store Store, collection *Collection, backupMgr *BackupManager, cfg AppConfig, emailSender EmailSender) *APIHandler {
+	handler := &APIHandler{
 		store:               store,
-		collectionID:        "default",
+		collectionID:        defaultCollectionID,
 		collection:          collection,
 		backupManager:       backupMgr,
 		config:              cfg,
 		emailSender:         emailSender,
 		subscriptionBilling: newSubscriptionBillingProvider(cfg),
+		tts:                 newTTSProvider(cfg),
+		remoteImportClient:  newRemoteImportClient(),
 	}
+	if collection != nil && collection.ID != "" {
+		handler.collectionID = collection.ID
+	}
+	handler.profiles.registry = store
+	if backupMgr != nil {
+		handler.profiles.mainPath = backupMgr.dbPath
+	}
+	handler.applyStoredBackupPolicy(context.Background())
+	return handler
 }
 
 // Request/Response types
@@ -60,10 +82,15 @@ type CreateDeckRequest struct {
 	Name string `json:"name"`
 }
 
+func (req *CreateDeckRequest) Validate(v *requestValidator) {
+	v.required("name", req.Name)
+}
+
 type DeckResponse struct {
 	ID                  int64              `json:"id"`
 	Name                string             `json:"name"`
 	ParentID            *int64             `json:"parentId,omitempty"`
+	OptionsID           *int64             `json:"optionsId,omitempty"`
 	CardIDs             []int64            `json:"cardIds"`
 	DueToday            int                `json:"dueToday"`
 	DueReviewBacklog    int                `json:"dueReviewBacklog"`
@@ -98,6 +125,11 @@ type CreateNoteRequest struct {
 	AllowDuplicate bool              `json:"allowDuplicate"` // Override duplicate check
 }
 
+func (req *CreateNoteRequest) Validate(v *requestValidator) {
+	v.required("typeId", req.TypeID)
+	v.check(req.DeckID != 0, "deckId", "is required")
+}
+
 type CheckDuplicateRequest struct {
 	TypeID    string `json:"typeId"`
 	FieldName string `json:"fieldName"` // Field to check for duplicates (usually "Front" or first field)
@@ -105,6 +137,9 @@ type CheckDuplicateRequest struct {
 	DeckID    int64  `json:"deckId,omitempty"` // Optional: limit scope to deck
 }
 
+// Validate is empty: a blank value is simply never a duplicate.
+func (req *CheckDuplicateRequest) Validate(v *requestValidator) {}
+
 type DuplicateResult struct {
 	IsDuplicate bool        `json:"isDuplicate"`
 	Duplicates  []NoteBrief `json:"duplicates,omitempty"`
@@ -120,6 +155,14 @@ type NoteBrief struct {
 type AnswerCardRequest struct {
 	Rating      int `json:"rating"`      // 1=Again, 2=Hard, 3=Good, 4=Easy
 	TimeTakenMs int `json:"timeTakenMs"` // Time spent on the card in milliseconds
+	// Preview marks an answer from a cram session: the card is returned unchanged and
+	// no revlog entry is written.
+	Preview bool `json:"preview,omitempty"`
+}
+
+func (req *AnswerCardRequest) Validate(v *requestValidator) {
+	v.rating("rating", req.Rating)
+	v.check(req.TimeTakenMs >= 0, "timeTakenMs", "must not be negative")
 }
 
 type UpdateCardRequest struct {
@@ -128,6 +171,12 @@ type UpdateCardRequest struct {
 	Suspended *bool `json:"suspended,omitempty"` // toggle suspended status
 }
 
+func (req *UpdateCardRequest) Validate(v *requestValidator) {
+	if req.Flag != nil {
+		v.flag("flag", *req.Flag)
+	}
+}
+
 type ImportNotesJSONRequest struct {
 	Content  string `json:"content"`
 	Filename string `json:"filename"`
@@ -137,6 +186,10 @@ type ImportNotesJSONRequest struct {
 	NoteType string `json:"noteType,omitempty"`
 }
 
+func (req *ImportNotesJSONRequest) Validate(v *requestValidator) {
+	v.check(strings.TrimSpace(req.Content) != "", "content", "is required when file upload is not used")
+}
+
 type ImportNotesResponse struct {
 	Imported     int      `json:"imported"`
 	Skipped      int      `json:"skipped"`
@@ -151,7 +204,7 @@ type ImportNotesResponse struct {
 func (h *APIHandler) GetCollection(w http.ResponseWriter, r *http.Request) {
 	col, _, err := h.collectionForRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
 	}
 
@@ -161,34 +214,34 @@ func (h *APIHandler) GetCollection(w http.ResponseWriter, r *http.Request) {
 func (h *APIHandler) ListDecks(w http.ResponseWriter, r *http.Request) {
 	col, collectionID, err := h.collectionForRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
 	}
-	decks, err := h.store.ListDecks(collectionID)
+	decks, err := h.store.ListDecks(r.Context(), collectionID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "deck_list_failed", err.Error())
 		return
 	}
 	userID := h.userIDFromRequest(r)
 	session := h.sessionFromRequest(r)
-	if err := h.store.EnsureReviewStatesForUser(userID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.store.EnsureReviewStatesForUser(r.Context(), userID); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "review_state_failed", err.Error())
 		return
 	}
 	workspaceID := ""
 	if session != nil {
 		workspaceID = session.WorkspaceID
 	}
-	analyticsByDeck, err := h.store.GetDeckStudyAnalyticsSummary(userID, workspaceID)
+	analyticsByDeck, err := h.store.GetDeckStudyAnalyticsSummary(r.Context(), userID, workspaceID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "deck_analytics_failed", err.Error())
 		return
 	}
 
 	// Convert to response format
 	var response []DeckResponse
 	for _, d := range decks {
-		response = append(response, h.deckResponse(userID, d, col, analyticsByDeck))
+		response = append(response, h.deckResponse(r.Context(), userID, d, col, analyticsByDeck))
 	}
 
 	respondJSON(w, http.StatusOK, response)
@@ -205,55 +258,54 @@ func (h *APIHandler) CreateDeck(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req CreateDeckRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
-	if req.Name == "" {
-		respondAPIError(w, http.StatusBadRequest, "invalid_name", "Deck name is required")
+	// Sanitize deck name to prevent XSS
+	sanitizedName := sanitizeHTML(req.Name)
+
+	// "Parent::Child" names create any missing parent decks along the way.
+	path, err := splitDeckPath(sanitizedName)
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_name", err.Error())
 		return
 	}
 
 	session := h.sessionFromRequest(r)
 	plan := h.planForRequest(r, session)
-	usage := h.usageForSession(session)
+	usage := h.usageForSession(r.Context(), session)
+	usage.Decks += len(col.missingDeckAncestors(path))
 	if err := validateDeckLimit(plan, usage); err != nil {
 		respondAPIError(w, http.StatusForbidden, "plan_limit_exceeded", err.Error())
 		return
 	}
 
-	// Sanitize deck name to prevent XSS
-	sanitizedName := sanitizeHTML(req.Name)
-
-	// Create deck using collection method
-	deck := col.NewDeck(sanitizedName)
-
-	// Persist to database
-	if err := h.store.CreateDeckInCollection(collectionID, deck); err != nil {
+	deck, err := h.createDeckPath(r.Context(), col, collectionID, path)
+	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "deck_create_failed", err.Error())
 		return
 	}
 
-	respondJSON(w, http.StatusCreated, h.deckResponse(h.userIDFromRequest(r), deck, col, nil))
+	respondJSON(w, http.StatusCreated, h.deckResponse(r.Context(), h.userIDFromRequest(r), deck, col, nil))
 }
 
 func (h *APIHandler) GetDeck(w http.ResponseWriter, r *http.Request) {
 	col, _, err := h.collectionForRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
 	}
 
 	id, err := parseIDParam(r, "id")
 	if err != nil {
-		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		respondAPIError(w, http.StatusBadRequest, "invalid_deck_id", "Invalid deck ID")
 		return
 	}
 
-	deck, err := h.store.GetDeck(id)
+	deck, err := h.store.GetDeck(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		respondAPIError(w, http.StatusNotFound, "deck_not_found", err.Error())
 		return
 	}
 
@@ -262,21 +314,21 @@ func (h *APIHandler) GetDeck(w http.ResponseWriter, r *http.Request) {
 	if session != nil {
 		workspaceID = session.WorkspaceID
 	}
-	analyticsByDeck, err := h.store.GetDeckStudyAnalyticsSummary(h.userIDFromRequest(r), workspaceID)
+	analyticsByDeck, err := h.store.GetDeckStudyAnalyticsSummary(r.Context(), h.userIDFromRequest(r), workspaceID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "deck_analytics_failed", err.Error())
 		return
 	}
 
 	// Get deck stats
-	stats, err := h.store.GetDeckStatsForUser(h.userIDFromRequest(r), id)
+	stats, err := h.store.GetDeckStatsForUser(r.Context(), h.userIDFromRequest(r), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "deck_stats_failed", err.Error())
 		return
 	}
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"deck":  h.deckResponse(h.userIDFromRequest(r), deck, col, analyticsByDeck),
+		"deck":  h.deckResponse(r.Context(), h.userIDFromRequest(r), deck, col, analyticsByDeck),
 		"stats": stats,
 	})
 }
@@ -299,7 +351,7 @@ func (h *APIHandler) userIDFromRequest(r *http.Request) string {
 
 func (h *APIHandler) deckDeleteBlockedReason(deck *Deck, cardCount int, col *Collection) string {
 	if cardCount > 0 {
-		return "Only empty decks can be deleted right now. Move or delete the cards in this deck first."
+		return "This deck has cards. Choose whether to delete them or move them to another deck."
 	}
 	for _, candidate := range col.Decks {
 		if candidate.ParentID != nil && *candidate.ParentID == deck.ID {
@@ -309,7 +361,7 @@ func (h *APIHandler) deckDeleteBlockedReason(deck *Deck, cardCount int, col *Col
 	return ""
 }
 
-func (h *APIHandler) deckResponse(userID string, deck *Deck, col *Collection, analyticsByDeck map[int64]DeckStudyAnalytics) DeckResponse {
+func (h *APIHandler) deckResponse(ctx context.Context, userID string, deck *Deck, col *Collection, analyticsByDeck map[int64]DeckStudyAnalytics) DeckResponse {
 	dueToday := 0
 	dueReviewBacklog := 0
 	newCardsPerDay := defaultNewCardsPerDay
@@ -326,14 +378,16 @@ func (h *APIHandler) deckResponse(userID string, deck *Deck, col *Collection, an
 		noteIDs[card.NoteID] = struct{}{}
 	}
 
-	if stats, err := h.store.GetDeckStatsForUser(userID, deck.ID); err == nil {
-		dueToday = stats.DueToday
-		dueReviewBacklog = stats.DueReviewBacklog
-	}
-	if configuredNew, configuredReview, err := h.store.getDeckDailyLimits(deck.ID); err == nil {
+	if configuredNew, configuredReview, err := h.store.getDeckDailyLimits(ctx, deck.ID); err == nil {
 		newCardsPerDay = configuredNew
 		reviewsPerDay = configuredReview
 	}
+	effectiveReviewLimit := reviewsPerDay
+	if stats, err := h.store.GetDeckStatsForUser(ctx, userID, deck.ID); err == nil {
+		dueToday = stats.DueToday
+		dueReviewBacklog = stats.DueReviewBacklog
+		effectiveReviewLimit = stats.ReviewLimit
+	}
 
 	deleteBlockedReason := h.deckDeleteBlockedReason(deck, cardCount, col)
 	analytics := DeckStudyAnalytics{}
@@ -345,13 +399,14 @@ func (h *APIHandler) deckResponse(userID string, deck *Deck, col *Collection, an
 		ID:                  deck.ID,
 		Name:                deck.Name,
 		ParentID:            deck.ParentID,
+		OptionsID:           deck.OptionsID,
 		CardIDs:             deck.Cards,
 		DueToday:            dueToday,
 		DueReviewBacklog:    dueReviewBacklog,
 		NewCardsPerDay:      newCardsPerDay,
 		ReviewsPerDay:       reviewsPerDay,
 		PriorityOrder:       deck.PriorityOrder,
-		NewCardsPaused:      dueReviewBacklog > reviewsPerDay,
+		NewCardsPaused:      dueReviewBacklog > effectiveReviewLimit,
 		NoteCount:           len(noteIDs),
 		CardCount:           cardCount,
 		CanDelete:           deleteBlockedReason == "",
@@ -367,7 +422,7 @@ func (h *APIHandler) buildDashboardResponse(r *http.Request) DashboardResponse {
 	}
 	studyAnalytics := StudyAnalyticsOverview{}
 	if session := h.sessionFromRequest(r); session != nil {
-		if analytics, err := h.store.GetStudyAnalyticsOverview(session.UserID, session.WorkspaceID); err == nil {
+		if analytics, err := h.store.GetStudyAnalyticsOverview(r.Context(), session.UserID, session.WorkspaceID); err == nil {
 			studyAnalytics = analytics
 		}
 	}
@@ -375,7 +430,7 @@ func (h *APIHandler) buildDashboardResponse(r *http.Request) DashboardResponse {
 	noteCards := h.buildNoteCardIndex(col)
 	recentNotes := make([]NoteListItemResponse, 0, len(col.Notes))
 	dueToday := 0
-	if count, err := h.store.CountDueCardsForUser(h.userIDFromRequest(r)); err == nil {
+	if count, err := h.store.CountDueCardsForUser(r.Context(), h.userIDFromRequest(r), h.collectionIDForRequest(r)); err == nil {
 		dueToday = count
 	}
 
@@ -432,13 +487,17 @@ func (h *APIHandler) buildDashboardResponse(r *http.Request) DashboardResponse {
 func (h *APIHandler) GetDeckStats(w http.ResponseWriter, r *http.Request) {
 	id, err := parseIDParam(r, "id")
 	if err != nil {
-		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		respondAPIError(w, http.StatusBadRequest, "invalid_deck_id", "Invalid deck ID")
 		return
 	}
 
-	stats, err := h.store.GetDeckStatsForUser(h.userIDFromRequest(r), id)
+	stats, err := h.store.GetDeckStatsForUser(r.Context(), h.userIDFromRequest(r), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondAPIError(w, http.StatusNotFound, "deck_not_found", "Deck not found")
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "deck_stats_failed", err.Error())
 		return
 	}
 
@@ -456,19 +515,13 @@ func (h *APIHandler) CreateNote(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req CreateNoteRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
-		return
-	}
-
-	if req.TypeID == "" || req.DeckID == 0 {
-		respondAPIError(w, http.StatusBadRequest, "invalid_note_request", "TypeID and DeckID are required")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
 	session := h.sessionFromRequest(r)
 	plan := h.planForRequest(r, session)
-	usage := h.usageForSession(session)
+	usage := h.usageForSession(r.Context(), session)
 	if err := validateNoteLimit(plan, usage); err != nil {
 		respondAPIError(w, http.StatusForbidden, "plan_limit_exceeded", err.Error())
 		return
@@ -482,6 +535,7 @@ func (h *APIHandler) CreateNote(w http.ResponseWriter, r *http.Request) {
 		respondAPIError(w, http.StatusBadRequest, "invalid_note_type", "Note type not found")
 		return
 	}
+	sanitizedFieldVals = noteType.withFieldDefaults(sanitizedFieldVals)
 	previewAt := time.Now()
 	previewNote := Note{
 		Type:       NoteTypeName(req.TypeID),
@@ -509,25 +563,21 @@ func (h *APIHandler) CreateNote(w http.ResponseWriter, r *http.Request) {
 
 	// Set tags if provided (use sanitized tags)
 	note.Tags = sanitizedTags
-	// Persist note to database
-	if err := h.store.CreateNote(collectionID, &note); err != nil {
+	// Persist the note and its cards together
+	if err := h.store.CreateNoteWithCards(r.Context(), collectionID, &note, cards); err != nil {
+		col.removeAddedNote(note, cards)
 		respondAPIError(w, http.StatusInternalServerError, "note_persist_failed", err.Error())
 		return
 	}
 
-	// Persist generated cards to database
-	for _, card := range cards {
-		if err := h.store.CreateCard(card); err != nil {
-			respondAPIError(w, http.StatusInternalServerError, "card_persist_failed", fmt.Sprintf("Failed to save card: %v", err))
-			return
-		}
-	}
-
 	responseCards := make([]Card, 0, len(cards))
 	for _, card := range cards {
 		responseCards = append(responseCards, *card)
 	}
-	h.markStudyGroupInstallsForkedByDeckIDs(req.DeckID)
+	h.markStudyGroupInstallsForkedByDeckIDs(r.Context(), req.DeckID)
+	h.synthesizeNoteSpeech(r.Context(), collectionID, col, note)
+	h.events.publish(RealtimeEvent{Type: eventNoteCreated, CollectionID: collectionID, DeckID: req.DeckID, NoteID: note.ID})
+	h.events.publish(RealtimeEvent{Type: eventDueCountsChanged, CollectionID: collectionID, DeckID: req.DeckID})
 
 	respondJSON(w, http.StatusCreated, map[string]interface{}{
 		"note":  h.noteToResponse(&note, responseCards),
@@ -539,10 +589,27 @@ func (h *APIHandler) ImportNotes(w http.ResponseWriter, r *http.Request) {
 	if !h.requireWorkspaceWritePermission(w, r) {
 		return
 	}
-	fileData, opts, err := parseImportRequest(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	var fileData []byte
+	var opts importParseOptions
+	if strings.HasPrefix(strings.ToLower(r.Header.Get("Content-Type")), "multipart/form-data") {
+		var err error
+		if fileData, opts, err = parseImportUpload(r); err != nil {
+			respondAPIError(w, http.StatusBadRequest, "invalid_import", err.Error())
+			return
+		}
+	} else {
+		var req ImportNotesJSONRequest
+		if !decodeValidatedRequest(w, r, &req) {
+			return
+		}
+		fileData = []byte(req.Content)
+		opts = importParseOptions{
+			Source:          req.Source,
+			FormatHint:      req.Format,
+			Filename:        req.Filename,
+			DefaultDeckName: req.DeckName,
+			DefaultNoteType: req.NoteType,
+		}
 	}
 
 	opts.DefaultDeckName = firstNonEmpty(opts.DefaultDeckName, "Default")
@@ -550,17 +617,25 @@ func (h *APIHandler) ImportNotes(w http.ResponseWriter, r *http.Request) {
 
 	parsed, err := parseImportData(fileData, opts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		respondAPIError(w, http.StatusBadRequest, "invalid_import", err.Error())
 		return
 	}
 
+	h.respondWithImport(w, r, parsed, opts.DefaultDeckName)
+}
+
+// respondWithImport adds parsed notes to the request's collection and reports the
+// result; an import that adds nothing is a 400.
+func (h *APIHandler) respondWithImport(w http.ResponseWriter, r *http.Request, parsed importParserResult, defaultDeckName string) {
 	col, collectionID, err := h.collectionForRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
 	}
 
-	importResult := h.applyImportedNotesToCollection(collectionID, col, parsed.Notes, opts.DefaultDeckName)
+	op := h.startOperation(w, r, operationImport, "notes")
+	importResult := h.applyImportedNotesToCollection(r.Context(), collectionID, col, parsed.Notes, defaultDeckName, h.userIDFromRequest(r), op)
+	op.finish(nil)
 	importResult.Source = parsed.Source
 	importResult.Format = parsed.Format
 
@@ -575,17 +650,17 @@ func (h *APIHandler) ImportNotes(w http.ResponseWriter, r *http.Request) {
 func (h *APIHandler) GetNote(w http.ResponseWriter, r *http.Request) {
 	id, err := parseIDParam(r, "id")
 	if err != nil {
-		http.Error(w, "Invalid note ID", http.StatusBadRequest)
+		respondAPIError(w, http.StatusBadRequest, "invalid_note_id", "Invalid note ID")
 		return
 	}
 
-	note, err := h.store.GetNote(id)
+	note, err := h.store.GetNote(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		respondAPIError(w, http.StatusNotFound, "note_not_found", err.Error())
 		return
 	}
 
-	cards, err := h.store.GetCardsByNote(id)
+	cards, err := h.store.GetCardsByNote(r.Context(), id)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "note_cards_failed", err.Error())
 		return
@@ -596,8 +671,7 @@ func (h *APIHandler) GetNote(w http.ResponseWriter, r *http.Request) {
 
 func (h *APIHandler) CheckDuplicate(w http.ResponseWriter, r *http.Request) {
 	var req CheckDuplicateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
@@ -607,9 +681,9 @@ func (h *APIHandler) CheckDuplicate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check for duplicates in the collection
-	duplicates, err := h.store.FindDuplicateNotes(h.collectionIDForRequest(r), req.FieldName, req.Value, req.DeckID)
+	duplicates, err := h.store.FindDuplicateNotes(r.Context(), h.collectionIDForRequest(r), req.FieldName, req.Value, req.DeckID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "duplicate_check_failed", err.Error())
 		return
 	}
 
@@ -624,7 +698,7 @@ func (h *APIHandler) CheckDuplicate(w http.ResponseWriter, r *http.Request) {
 func (h *APIHandler) GetDueCards(w http.ResponseWriter, r *http.Request) {
 	deckID, err := parseIDParam(r, "deckId")
 	if err != nil {
-		http.Error(w, "Invalid deck ID", http.StatusBadRequest)
+		respondAPIError(w, http.StatusBadRequest, "invalid_deck_id", "Invalid deck ID")
 		return
 	}
 
@@ -635,25 +709,54 @@ func (h *APIHandler) GetDueCards(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	cards, err := h.store.GetDueCardsForUser(h.userIDFromRequest(r), deckID, limit)
+	filter, err := parseDueCardFilter(r.URL.Query())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	cards, err := h.dueCardsForUser(r.Context(), collectionID, h.userIDFromRequest(r), deckID, limit, filter)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "due_cards_failed", err.Error())
+		return
+	}
+	if err := h.attachNextIntervals(r.Context(), col, cards, time.Now()); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "next_intervals_failed", err.Error())
 		return
 	}
 
 	respondJSON(w, http.StatusOK, cards)
 }
 
+// dueCardsForUser builds the study queue for a deck, returning nothing while the
+// collection is on vacation.
+func (h *APIHandler) dueCardsForUser(ctx context.Context, collectionID, userID string, deckID int64, limit int, filter DueCardFilter) ([]*Card, error) {
+	now := time.Now()
+	prefs, err := h.settleVacation(ctx, collectionID, now)
+	if err != nil {
+		return nil, err
+	}
+	if prefs.Vacation.Active(now) {
+		return []*Card{}, nil
+	}
+	return h.store.GetDueCardsForUserFiltered(ctx, userID, deckID, limit, filter)
+}
+
 func (h *APIHandler) GetCard(w http.ResponseWriter, r *http.Request) {
 	id, err := parseIDParam(r, "id")
 	if err != nil {
-		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		respondAPIError(w, http.StatusBadRequest, "invalid_card_id", "Invalid card ID")
 		return
 	}
 
-	card, err := h.store.GetCardForUser(h.userIDFromRequest(r), id)
+	card, err := h.store.GetCardForUser(r.Context(), h.userIDFromRequest(r), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		respondAPIError(w, http.StatusNotFound, "card_not_found", err.Error())
 		return
 	}
 
@@ -663,83 +766,107 @@ func (h *APIHandler) GetCard(w http.ResponseWriter, r *http.Request) {
 func (h *APIHandler) AnswerCard(w http.ResponseWriter, r *http.Request) {
 	col, _, err := h.collectionForRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
 	}
 
 	id, err := parseIDParam(r, "id")
 	if err != nil {
-		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		respondAPIError(w, http.StatusBadRequest, "invalid_card_id", "Invalid card ID")
 		return
 	}
 
 	var req AnswerCardRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if req.Rating < 1 || req.Rating > 4 {
-		http.Error(w, "Rating must be 1-4 (Again/Hard/Good/Easy)", http.StatusBadRequest)
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
 	userID := h.userIDFromRequest(r)
-	card, err := h.store.GetCardForUser(userID, id)
+	card, err := h.store.GetCardForUser(r.Context(), userID, id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "card_lookup_failed", err.Error())
 		return
 	}
-
-	sched := fsrs.NewFSRS(col.Params).Repeat(card.SRS, time.Now())
-	info, ok := sched[fsrs.Rating(req.Rating)]
-	if !ok {
-		http.Error(w, "Unable to schedule card review", http.StatusInternalServerError)
-		return
-	}
-	card.SRS = info.Card
-
-	if err := h.store.UpdateCardReviewState(userID, card); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if req.Preview {
+		respondJSON(w, http.StatusOK, card)
 		return
 	}
 
-	if err := h.store.AddRevlogForUser(userID, &info.ReviewLog, id, req.TimeTakenMs); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.applyAnswer(r.Context(), col, userID, card, fsrs.Rating(req.Rating), req.TimeTakenMs, time.Now()); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "card_answer_failed", err.Error())
 		return
 	}
 
 	respondJSON(w, http.StatusOK, card)
 }
 
+// applyAnswer schedules the user's answer on card at now, saves the card's new state,
+// and logs the review.
+func (h *APIHandler) applyAnswer(ctx context.Context, col *Collection, userID string, card *Card, rating fsrs.Rating, timeTakenMs int, now time.Time) error {
+	return h.applyAnswerFor(ctx, col, userID, card, rating, timeTakenMs, now, true)
+}
+
+// applyAnswerFor is applyAnswer for a user who may or may not edit the card's note. One
+// who may not, like a student studying a shared deck, only changes their own state: a
+// leech is suspended for them but its note is not tagged.
+func (h *APIHandler) applyAnswerFor(ctx context.Context, col *Collection, userID string, card *Card, rating fsrs.Rating, timeTakenMs int, now time.Time, editsNote bool) error {
+	filtered, err := h.store.filteredDeckForCard(ctx, card)
+	if err != nil {
+		return err
+	}
+	info, step, ease, err := h.scheduleAnswer(ctx, col, card, rating, now)
+	if err != nil {
+		return err
+	}
+	kind := reviewKindForState(info.ReviewLog.State)
+	if filtered != nil && !filtered.Reschedule {
+		// Practice in a filtered deck that does not reschedule leaves the card as it was.
+		kind = reviewKindFiltered
+	} else {
+		lapsesBefore := card.SRS.Lapses
+		card.SRS = info.Card
+		card.LearningStep = step
+		card.EaseFactor = ease
+		if err := h.markLeech(ctx, col, card, lapsesBefore, now, editsNote); err != nil {
+			return err
+		}
+		if err := h.store.UpdateCardReviewState(ctx, userID, card); err != nil {
+			return err
+		}
+	}
+	if err := h.store.AddRevlogDetailForUser(ctx, userID, &info.ReviewLog, card.SRS, kind, card.ID, timeTakenMs); err != nil {
+		return err
+	}
+	if err := h.finishFilteredAnswer(ctx, col, card, filtered); err != nil {
+		return err
+	}
+	h.publishAnswer(col, userID, card)
+	return nil
+}
+
 func (h *APIHandler) UpdateCard(w http.ResponseWriter, r *http.Request) {
 	id, err := parseIDParam(r, "id")
 	if err != nil {
-		http.Error(w, "Invalid card ID", http.StatusBadRequest)
+		respondAPIError(w, http.StatusBadRequest, "invalid_card_id", "Invalid card ID")
 		return
 	}
 
 	var req UpdateCardRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
 	userID := h.userIDFromRequest(r)
 
 	// Get card from store
-	card, err := h.store.GetCardForUser(userID, id)
+	card, err := h.store.GetCardForUser(r.Context(), userID, id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		respondAPIError(w, http.StatusNotFound, "card_not_found", err.Error())
 		return
 	}
 
 	// Update fields if provided
 	if req.Flag != nil {
-		if *req.Flag < 0 || *req.Flag > 7 {
-			http.Error(w, "Flag must be 0-7", http.StatusBadRequest)
-			return
-		}
 		card.Flag = *req.Flag
 	}
 	if req.Marked != nil {
@@ -750,8 +877,8 @@ func (h *APIHandler) UpdateCard(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Persist changes
-	if err := h.store.UpdateCardReviewState(userID, card); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.store.UpdateCardReviewState(r.Context(), userID, card); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "card_update_failed", err.Error())
 		return
 	}
 
@@ -815,33 +942,13 @@ type TemplatesResponse struct {
 func (h *APIHandler) ListNoteTypes(w http.ResponseWriter, r *http.Request) {
 	col, _, err := h.collectionForRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
 	}
 
 	var noteTypes []NoteTypeResponse
 	for _, nt := range col.NoteTypes {
-		var templates []TemplateInfo
-		for _, t := range nt.Templates {
-			templates = append(templates, TemplateInfo{
-				Name:            t.Name,
-				QFmt:            t.QFmt,
-				AFmt:            t.AFmt,
-				Styling:         t.Styling,
-				IfFieldNonEmpty: t.IfFieldNonEmpty,
-				IsCloze:         t.IsCloze,
-				DeckOverride:    t.DeckOverride,
-				BrowserQFmt:     t.BrowserQFmt,
-				BrowserAFmt:     t.BrowserAFmt,
-			})
-		}
-		noteTypes = append(noteTypes, NoteTypeResponse{
-			Name:           string(nt.Name),
-			Fields:         nt.Fields,
-			Templates:      templates,
-			SortFieldIndex: nt.SortFieldIndex,
-			FieldOptions:   nt.FieldOptions,
-		})
+		noteTypes = append(noteTypes, noteTypeResponse(nt))
 	}
 
 	sort.Slice(noteTypes, func(i, j int) bool {
@@ -854,39 +961,18 @@ func (h *APIHandler) ListNoteTypes(w http.ResponseWriter, r *http.Request) {
 func (h *APIHandler) GetNoteType(w http.ResponseWriter, r *http.Request) {
 	col, _, err := h.collectionForRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
 	}
 
 	name := chi.URLParam(r, "name")
 	nt, ok := col.NoteTypes[NoteTypeName(name)]
 	if !ok {
-		http.Error(w, "Note type not found", http.StatusNotFound)
-		return
-	}
-
-	var templates []TemplateInfo
-	for _, t := range nt.Templates {
-		templates = append(templates, TemplateInfo{
-			Name:            t.Name,
-			QFmt:            t.QFmt,
-			AFmt:            t.AFmt,
-			Styling:         t.Styling,
-			IfFieldNonEmpty: t.IfFieldNonEmpty,
-			IsCloze:         t.IsCloze,
-			DeckOverride:    t.DeckOverride,
-			BrowserQFmt:     t.BrowserQFmt,
-			BrowserAFmt:     t.BrowserAFmt,
-		})
+		respondAPIError(w, http.StatusNotFound, "note_type_not_found", "Note type not found")
+		return
 	}
 
-	respondJSON(w, http.StatusOK, NoteTypeResponse{
-		Name:           string(nt.Name),
-		Fields:         nt.Fields,
-		Templates:      templates,
-		SortFieldIndex: nt.SortFieldIndex,
-		FieldOptions:   nt.FieldOptions,
-	})
+	respondJSON(w, http.StatusOK, noteTypeResponse(nt))
 }
 
 // Reserved field names that cannot be used
@@ -917,47 +1003,69 @@ type ReorderFieldsRequest struct {
 	Fields []string `json:"fields"` // New field order
 }
 
+func (req *SetSortFieldRequest) Validate(v *requestValidator) {
+	v.check(req.FieldIndex >= 0, "fieldIndex", "must not be negative")
+}
+
+func (req *SetFieldOptionsRequest) Validate(v *requestValidator) {
+	v.required("fieldName", req.FieldName)
+}
+
+func (req *UpdateTemplateRequest) Validate(v *requestValidator) {
+	if req.Name != nil {
+		v.required("name", *req.Name)
+	}
+}
+
+func (req *AddFieldRequest) Validate(v *requestValidator) {
+	v.fieldName("fieldName", req.FieldName)
+	if req.Position != nil {
+		v.check(*req.Position >= 0, "position", "must not be negative")
+	}
+}
+
+func (req *RenameFieldRequest) Validate(v *requestValidator) {
+	v.required("oldName", req.OldName)
+	v.fieldName("newName", req.NewName)
+}
+
+func (req *RemoveFieldRequest) Validate(v *requestValidator) {
+	v.required("fieldName", req.FieldName)
+}
+
+func (req *ReorderFieldsRequest) Validate(v *requestValidator) {
+	v.check(len(req.Fields) > 0, "fields", "must list the note type's fields")
+}
+
 func (h *APIHandler) AddField(w http.ResponseWriter, r *http.Request) {
 	if !h.requireWorkspaceWritePermission(w, r) {
 		return
 	}
 	col, collectionID, err := h.collectionForRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
 	}
 
 	name := chi.URLParam(r, "name")
 	nt, ok := col.NoteTypes[NoteTypeName(name)]
 	if !ok {
-		http.Error(w, "Note type not found", http.StatusNotFound)
+		respondAPIError(w, http.StatusNotFound, "note_type_not_found", "Note type not found")
 		return
 	}
 
 	var req AddFieldRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if req.FieldName == "" {
-		http.Error(w, "fieldName is required", http.StatusBadRequest)
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
 	// Sanitize field name to prevent XSS
-	sanitizedFieldName := sanitizeHTML(req.FieldName)
-
-	// Check for reserved field names
-	if reservedFieldNames[sanitizedFieldName] {
-		http.Error(w, fmt.Sprintf("'%s' is a reserved field name", sanitizedFieldName), http.StatusBadRequest)
-		return
-	}
+	sanitizedFieldName := sanitizeHTML(strings.TrimSpace(req.FieldName))
 
 	// Check for duplicate field name
 	for _, f := range nt.Fields {
 		if f == sanitizedFieldName {
-			http.Error(w, "Field name already exists", http.StatusBadRequest)
+			respondAPIError(w, http.StatusBadRequest, "field_name_conflict", "Field name already exists")
 			return
 		}
 	}
@@ -974,14 +1082,14 @@ func (h *APIHandler) AddField(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update in store
-	if err := h.store.UpdateNoteType(collectionID, &nt); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.store.UpdateNoteType(r.Context(), collectionID, &nt); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "note_type_update_failed", err.Error())
 		return
 	}
 
 	// Update collection cache
 	col.NoteTypes[NoteTypeName(name)] = nt
-	h.markStudyGroupInstallsForkedByNoteType(name)
+	h.markStudyGroupInstallsForkedByNoteType(r.Context(), name)
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"message": "Field added successfully",
@@ -995,36 +1103,24 @@ func (h *APIHandler) RenameField(w http.ResponseWriter, r *http.Request) {
 	}
 	col, collectionID, err := h.collectionForRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
 	}
 
 	name := chi.URLParam(r, "name")
 	nt, ok := col.NoteTypes[NoteTypeName(name)]
 	if !ok {
-		http.Error(w, "Note type not found", http.StatusNotFound)
+		respondAPIError(w, http.StatusNotFound, "note_type_not_found", "Note type not found")
 		return
 	}
 
 	var req RenameFieldRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if req.OldName == "" || req.NewName == "" {
-		http.Error(w, "oldName and newName are required", http.StatusBadRequest)
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
 	// Sanitize new field name to prevent XSS
-	sanitizedNewName := sanitizeHTML(req.NewName)
-
-	// Check for reserved field names
-	if reservedFieldNames[sanitizedNewName] {
-		http.Error(w, fmt.Sprintf("'%s' is a reserved field name", sanitizedNewName), http.StatusBadRequest)
-		return
-	}
+	sanitizedNewName := sanitizeHTML(strings.TrimSpace(req.NewName))
 
 	// Find and rename the field
 	found := false
@@ -1037,7 +1133,7 @@ func (h *APIHandler) RenameField(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !found {
-		http.Error(w, "Field not found", http.StatusNotFound)
+		respondAPIError(w, http.StatusNotFound, "field_not_found", "Field not found")
 		return
 	}
 
@@ -1056,7 +1152,7 @@ func (h *APIHandler) RenameField(w http.ResponseWriter, r *http.Request) {
 				break
 			}
 		}
-		http.Error(w, "Field name already exists", http.StatusBadRequest)
+		respondAPIError(w, http.StatusBadRequest, "field_name_conflict", "Field name already exists")
 		return
 	}
 
@@ -1070,14 +1166,14 @@ func (h *APIHandler) RenameField(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update in store
-	if err := h.store.UpdateNoteType(collectionID, &nt); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.store.UpdateNoteType(r.Context(), collectionID, &nt); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "note_type_update_failed", err.Error())
 		return
 	}
 
 	// Update collection cache
 	col.NoteTypes[NoteTypeName(name)] = nt
-	h.markStudyGroupInstallsForkedByNoteType(name)
+	h.markStudyGroupInstallsForkedByNoteType(r.Context(), name)
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"message": "Field renamed successfully",
@@ -1091,31 +1187,25 @@ func (h *APIHandler) RemoveField(w http.ResponseWriter, r *http.Request) {
 	}
 	col, collectionID, err := h.collectionForRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
 	}
 
 	name := chi.URLParam(r, "name")
 	nt, ok := col.NoteTypes[NoteTypeName(name)]
 	if !ok {
-		http.Error(w, "Note type not found", http.StatusNotFound)
+		respondAPIError(w, http.StatusNotFound, "note_type_not_found", "Note type not found")
 		return
 	}
 
 	var req RemoveFieldRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if req.FieldName == "" {
-		http.Error(w, "fieldName is required", http.StatusBadRequest)
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
 	// Must have at least one field
 	if len(nt.Fields) <= 1 {
-		http.Error(w, "Cannot remove the last field", http.StatusBadRequest)
+		respondAPIError(w, http.StatusBadRequest, "last_field", "Cannot remove the last field")
 		return
 	}
 
@@ -1131,21 +1221,21 @@ func (h *APIHandler) RemoveField(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !found {
-		http.Error(w, "Field not found", http.StatusNotFound)
+		respondAPIError(w, http.StatusNotFound, "field_not_found", "Field not found")
 		return
 	}
 
 	nt.Fields = newFields
 
 	// Update in store
-	if err := h.store.UpdateNoteType(collectionID, &nt); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.store.UpdateNoteType(r.Context(), collectionID, &nt); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "note_type_update_failed", err.Error())
 		return
 	}
 
 	// Update collection cache
 	col.NoteTypes[NoteTypeName(name)] = nt
-	h.markStudyGroupInstallsForkedByNoteType(name)
+	h.markStudyGroupInstallsForkedByNoteType(r.Context(), name)
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"message": "Field removed successfully",
@@ -1159,26 +1249,25 @@ func (h *APIHandler) ReorderFields(w http.ResponseWriter, r *http.Request) {
 	}
 	col, collectionID, err := h.collectionForRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
 	}
 
 	name := chi.URLParam(r, "name")
 	nt, ok := col.NoteTypes[NoteTypeName(name)]
 	if !ok {
-		http.Error(w, "Note type not found", http.StatusNotFound)
+		respondAPIError(w, http.StatusNotFound, "note_type_not_found", "Note type not found")
 		return
 	}
 
 	var req ReorderFieldsRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
 	// Validate that the new order contains the same fields
 	if len(req.Fields) != len(nt.Fields) {
-		http.Error(w, "Field count mismatch", http.StatusBadRequest)
+		respondAPIError(w, http.StatusBadRequest, "invalid_field_order", "Field count mismatch")
 		return
 	}
 
@@ -1190,28 +1279,28 @@ func (h *APIHandler) ReorderFields(w http.ResponseWriter, r *http.Request) {
 
 	for _, f := range req.Fields {
 		if !existingFields[f] {
-			http.Error(w, fmt.Sprintf("Unknown field: %s", f), http.StatusBadRequest)
+			respondAPIErrorWithDetails(w, http.StatusBadRequest, "unknown_field", fmt.Sprintf("Unknown field: %s", f), map[string]string{"field": f})
 			return
 		}
 		delete(existingFields, f)
 	}
 
 	if len(existingFields) > 0 {
-		http.Error(w, "Some fields are missing from the new order", http.StatusBadRequest)
+		respondAPIError(w, http.StatusBadRequest, "invalid_field_order", "Some fields are missing from the new order")
 		return
 	}
 
 	nt.Fields = req.Fields
 
 	// Update in store
-	if err := h.store.UpdateNoteType(collectionID, &nt); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.store.UpdateNoteType(r.Context(), collectionID, &nt); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "note_type_update_failed", err.Error())
 		return
 	}
 
 	// Update collection cache
 	col.NoteTypes[NoteTypeName(name)] = nt
-	h.markStudyGroupInstallsForkedByNoteType(name)
+	h.markStudyGroupInstallsForkedByNoteType(r.Context(), name)
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"message": "Fields reordered successfully",
@@ -1225,40 +1314,38 @@ func (h *APIHandler) SetSortField(w http.ResponseWriter, r *http.Request) {
 	}
 	col, collectionID, err := h.collectionForRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
 	}
 
 	name := chi.URLParam(r, "name")
 	nt, ok := col.NoteTypes[NoteTypeName(name)]
 	if !ok {
-		http.Error(w, "Note type not found", http.StatusNotFound)
+		respondAPIError(w, http.StatusNotFound, "note_type_not_found", "Note type not found")
 		return
 	}
 
 	var req SetSortFieldRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
-	// Validate field index
-	if req.FieldIndex < 0 || req.FieldIndex >= len(nt.Fields) {
-		http.Error(w, "Invalid field index", http.StatusBadRequest)
+	if req.FieldIndex >= len(nt.Fields) {
+		respondAPIError(w, http.StatusBadRequest, "invalid_field_index", "Invalid field index")
 		return
 	}
 
 	nt.SortFieldIndex = req.FieldIndex
 
 	// Update in store
-	if err := h.store.UpdateNoteType(collectionID, &nt); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.store.UpdateNoteType(r.Context(), collectionID, &nt); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "note_type_update_failed", err.Error())
 		return
 	}
 
 	// Update collection cache
 	col.NoteTypes[NoteTypeName(name)] = nt
-	h.markStudyGroupInstallsForkedByNoteType(name)
+	h.markStudyGroupInstallsForkedByNoteType(r.Context(), name)
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"message":        "Sort field updated successfully",
@@ -1273,20 +1360,19 @@ func (h *APIHandler) SetFieldOptions(w http.ResponseWriter, r *http.Request) {
 	}
 	col, collectionID, err := h.collectionForRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
 	}
 
 	name := chi.URLParam(r, "name")
 	nt, ok := col.NoteTypes[NoteTypeName(name)]
 	if !ok {
-		http.Error(w, "Note type not found", http.StatusNotFound)
+		respondAPIError(w, http.StatusNotFound, "note_type_not_found", "Note type not found")
 		return
 	}
 
 	var req SetFieldOptionsRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
@@ -1299,10 +1385,13 @@ func (h *APIHandler) SetFieldOptions(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if !fieldExists {
-		http.Error(w, "Field not found", http.StatusBadRequest)
+		respondAPIError(w, http.StatusBadRequest, "field_not_found", "Field not found")
 		return
 	}
 
+	req.Options.DefaultValue = sanitizeHTML(req.Options.DefaultValue)
+	req.Options.Placeholder = strings.TrimSpace(req.Options.Placeholder)
+
 	// Initialize FieldOptions map if nil
 	if nt.FieldOptions == nil {
 		nt.FieldOptions = make(map[string]FieldOptions)
@@ -1312,14 +1401,14 @@ func (h *APIHandler) SetFieldOptions(w http.ResponseWriter, r *http.Request) {
 	nt.FieldOptions[req.FieldName] = req.Options
 
 	// Update in store
-	if err := h.store.UpdateNoteType(collectionID, &nt); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.store.UpdateNoteType(r.Context(), collectionID, &nt); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "note_type_update_failed", err.Error())
 		return
 	}
 
 	// Update collection cache
 	col.NoteTypes[NoteTypeName(name)] = nt
-	h.markStudyGroupInstallsForkedByNoteType(name)
+	h.markStudyGroupInstallsForkedByNoteType(r.Context(), name)
 
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"message":      "Field options updated successfully",
@@ -1333,7 +1422,7 @@ func (h *APIHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
 	}
 	col, collectionID, err := h.collectionForRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
 	}
 
@@ -1342,13 +1431,12 @@ func (h *APIHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
 
 	nt, ok := col.NoteTypes[NoteTypeName(noteTypeName)]
 	if !ok {
-		http.Error(w, "Note type not found", http.StatusNotFound)
+		respondAPIError(w, http.StatusNotFound, "note_type_not_found", "Note type not found")
 		return
 	}
 
 	var req UpdateTemplateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
@@ -1362,7 +1450,7 @@ func (h *APIHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if templateIndex == -1 {
-		http.Error(w, "Template not found", http.StatusNotFound)
+		respondAPIError(w, http.StatusNotFound, "template_not_found", "Template not found")
 		return
 	}
 
@@ -1370,14 +1458,10 @@ func (h *APIHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
 	// Update fields if provided
 	if req.Name != nil {
 		nextName := sanitizeHTML(strings.TrimSpace(*req.Name))
-		if nextName == "" {
-			http.Error(w, "Template name is required", http.StatusBadRequest)
-			return
-		}
 		if !strings.EqualFold(nextName, templateName) {
 			for i, candidate := range nt.Templates {
 				if i != templateIndex && strings.EqualFold(candidate.Name, nextName) {
-					http.Error(w, "Template name already exists", http.StatusBadRequest)
+					respondAPIError(w, http.StatusBadRequest, "template_name_conflict", "Template name already exists")
 					return
 				}
 			}
@@ -1408,18 +1492,21 @@ func (h *APIHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update in store
-	if err := h.store.UpdateNoteType(collectionID, &nt); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.store.UpdateNoteType(r.Context(), collectionID, &nt); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "note_type_update_failed", err.Error())
 		return
 	}
 
 	// Update collection cache
 	col.NoteTypes[NoteTypeName(noteTypeName)] = nt
-	h.markStudyGroupInstallsForkedByNoteType(noteTypeName)
+	h.markStudyGroupInstallsForkedByNoteType(r.Context(), noteTypeName)
 
 	// Regenerate cards for all notes of this type
 	// This ensures cards reflect the updated templates
-	if err := h.regenerateCardsForNoteTypeWithAliases(collectionID, col, noteTypeName, templateAliases); err != nil {
+	op := h.startOperation(w, r, operationRegenerateCards, "notes")
+	err = h.regenerateCardsForNoteTypeWithAliases(r.Context(), collectionID, col, noteTypeName, templateAliases, op)
+	op.finish(err)
+	if err != nil {
 		log.Printf("Warning: Failed to regenerate cards after template update: %v", err)
 		// Don't fail the request - template was updated successfully
 	}
@@ -1429,33 +1516,36 @@ func (h *APIHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
 
 // regenerateCardsForNoteType regenerates cards for all notes of a given note type.
 // This preserves existing card scheduling data (SRS state, flags, etc.) while updating content.
-func (h *APIHandler) regenerateCardsForNoteType(noteTypeName string) error {
-	return h.regenerateCardsForNoteTypeWithAliases(h.collectionID, h.collection, noteTypeName, nil)
+func (h *APIHandler) regenerateCardsForNoteType(ctx context.Context, noteTypeName string) error {
+	return h.regenerateCardsForNoteTypeWithAliases(ctx, h.collectionID, h.collection, noteTypeName, nil, nil)
 }
 
-func (h *APIHandler) regenerateCardsForNoteTypeInCollection(collectionID string, col *Collection, noteTypeName string) error {
-	return h.regenerateCardsForNoteTypeWithAliases(collectionID, col, noteTypeName, nil)
+func (h *APIHandler) regenerateCardsForNoteTypeInCollection(ctx context.Context, collectionID string, col *Collection, noteTypeName string, progress *operation) error {
+	return h.regenerateCardsForNoteTypeWithAliases(ctx, collectionID, col, noteTypeName, nil, progress)
 }
 
-func (h *APIHandler) regenerateCardsForNoteTypeWithAliases(collectionID string, col *Collection, noteTypeName string, templateAliases map[string]string) error {
+func (h *APIHandler) regenerateCardsForNoteTypeWithAliases(ctx context.Context, collectionID string, col *Collection, noteTypeName string, templateAliases map[string]string, progress *operation) error {
 	// Get all notes of this type
-	notes, err := h.store.GetNotesByType(collectionID, noteTypeName)
+	notes, err := h.store.GetNotesByType(ctx, collectionID, noteTypeName)
 	if err != nil {
 		return fmt.Errorf("failed to get notes: %w", err)
 	}
 
+	progress.setTotal(int64(len(notes)))
 	for _, note := range notes {
 		// Get the deck ID from one of the note's existing cards
 		// If the note has no cards, we'll use the default deck
 		deckID := int64(1) // default deck
-		existingCards, err := h.store.GetCardsByNote(note.ID)
+		existingCards, err := h.store.GetCardsByNote(ctx, note.ID)
 		if err == nil && len(existingCards) > 0 {
-			deckID = existingCards[0].DeckID
+			deckID = existingCards[0].homeDeckID()
 		}
 
-		if _, err := h.regenerateCardsForSingleNote(col, &note, deckID, templateAliases); err != nil {
+		if _, err := h.regenerateCardsForSingleNote(ctx, col, &note, deckID, templateAliases); err != nil {
 			log.Printf("Warning: Failed to regenerate cards for note %d: %v", note.ID, err)
+			progress.warn("note %d: %v", note.ID, err)
 		}
+		progress.advance(1)
 	}
 
 	return nil
@@ -1484,14 +1574,14 @@ type EmptyCardsResponse struct {
 func (h *APIHandler) FindEmptyCards(w http.ResponseWriter, r *http.Request) {
 	col, collectionID, err := h.collectionForRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
 	}
 
 	// Get all notes
-	notes, err := h.store.ListNotes(collectionID)
+	notes, err := h.store.ListNotes(r.Context(), collectionID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "note_list_failed", err.Error())
 		return
 	}
 
@@ -1505,7 +1595,7 @@ func (h *APIHandler) FindEmptyCards(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Get cards for this note
-		cards, err := h.store.GetCardsByNote(note.ID)
+		cards, err := h.store.GetCardsByNote(r.Context(), note.ID)
 		if err != nil {
 			log.Printf("Warning: Failed to get cards for note %d: %v", note.ID, err)
 			continue
@@ -1513,18 +1603,17 @@ func (h *APIHandler) FindEmptyCards(w http.ResponseWriter, r *http.Request) {
 
 		for _, card := range cards {
 			// Check if this is a cloze card
-			isCloze := false
-			for _, tmpl := range nt.Templates {
+			var clozeTemplate *CardTemplate
+			for i, tmpl := range nt.Templates {
 				if tmpl.Name == card.TemplateName && tmpl.IsCloze {
-					isCloze = true
+					clozeTemplate = &nt.Templates[i]
 					break
 				}
 			}
 
-			if isCloze {
-				// Check if the cloze ordinal still exists in the text
-				textField := note.FieldMap["Text"]
-				ordinals := extractClozeOrdinals(textField)
+			if clozeTemplate != nil {
+				// Check if the cloze ordinal still exists in the cloze fields
+				ordinals := clozeOrdinalsForTemplate(*clozeTemplate, note.FieldMap)
 
 				hasOrdinal := false
 				for _, ord := range ordinals {
@@ -1578,6 +1667,10 @@ type DeleteEmptyCardsRequest struct {
 	CardIDs []int64 `json:"cardIds"` // List of card IDs to delete
 }
 
+func (req *DeleteEmptyCardsRequest) Validate(v *requestValidator) {
+	v.check(len(req.CardIDs) > 0, "cardIds", "must list at least one card")
+}
+
 type DeleteEmptyCardsResponse struct {
 	Deleted int      `json:"deleted"`
 	Failed  []string `json:"failed,omitempty"` // Error messages for failed deletions
@@ -1585,20 +1678,14 @@ type DeleteEmptyCardsResponse struct {
 
 // DeleteEmptyCards deletes specified empty cards
 func (h *APIHandler) DeleteEmptyCards(w http.ResponseWriter, r *http.Request) {
-	col, _, err := h.collectionForRequest(r)
+	col, collectionID, err := h.collectionForRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
 		return
 	}
 
 	var req DeleteEmptyCardsRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if len(req.CardIDs) == 0 {
-		http.Error(w, "No card IDs provided", http.StatusBadRequest)
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
@@ -1606,7 +1693,7 @@ func (h *APIHandler) DeleteEmptyCards(w http.ResponseWriter, r *http.Request) {
 	var failed []string
 
 	for _, cardID := range req.CardIDs {
-		if err := h.store.DeleteCard(cardID); err != nil {
+		if err := h.store.DeleteCard(r.Context(), cardID); err != nil {
 			failed = append(failed, fmt.Sprintf("Card %d: %v", cardID, err))
 			log.Printf("Failed to delete card %d: %v", cardID, err)
 		} else {
@@ -1616,73 +1703,60 @@ func (h *APIHandler) DeleteEmptyCards(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	h.cleanupAfterDelete(r.Context(), collectionID, col)
+
 	respondJSON(w, http.StatusOK, DeleteEmptyCardsResponse{
 		Deleted: deleted,
 		Failed:  failed,
 	})
 }
 
-func parseImportRequest(r *http.Request) ([]byte, importParseOptions, error) {
-	contentType := strings.ToLower(r.Header.Get("Content-Type"))
-	if strings.HasPrefix(contentType, "multipart/form-data") {
-		if err := r.ParseMultipartForm(32 << 20); err != nil {
-			return nil, importParseOptions{}, fmt.Errorf("invalid multipart form: %w", err)
-		}
-
-		file, header, err := r.FormFile("file")
-		if err != nil {
-			return nil, importParseOptions{}, fmt.Errorf("file is required: %w", err)
-		}
-		defer file.Close()
-
-		fileData, err := io.ReadAll(file)
-		if err != nil {
-			return nil, importParseOptions{}, fmt.Errorf("failed to read file: %w", err)
-		}
-		if len(strings.TrimSpace(string(fileData))) == 0 {
-			return nil, importParseOptions{}, fmt.Errorf("import file is empty")
-		}
+// parseImportUpload reads a multipart import: the file plus its options as form values.
+func parseImportUpload(r *http.Request) ([]byte, importParseOptions, error) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		return nil, importParseOptions{}, fmt.Errorf("invalid multipart form: %w", err)
+	}
 
-		return fileData, importParseOptions{
-			Source:          r.FormValue("source"),
-			FormatHint:      r.FormValue("format"),
-			Filename:        header.Filename,
-			DefaultDeckName: r.FormValue("deckName"),
-			DefaultNoteType: r.FormValue("noteType"),
-		}, nil
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, importParseOptions{}, fmt.Errorf("file is required: %w", err)
 	}
+	defer file.Close()
 
-	var req ImportNotesJSONRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, importParseOptions{}, fmt.Errorf("invalid request body")
+	fileData, err := io.ReadAll(file)
+	if err != nil {
+		return nil, importParseOptions{}, fmt.Errorf("failed to read file: %w", err)
 	}
-	if strings.TrimSpace(req.Content) == "" {
-		return nil, importParseOptions{}, fmt.Errorf("content is required when file upload is not used")
+	if len(strings.TrimSpace(string(fileData))) == 0 {
+		return nil, importParseOptions{}, fmt.Errorf("import file is empty")
 	}
 
-	return []byte(req.Content), importParseOptions{
-		Source:          req.Source,
-		FormatHint:      req.Format,
-		Filename:        req.Filename,
-		DefaultDeckName: req.DeckName,
-		DefaultNoteType: req.NoteType,
+	return fileData, importParseOptions{
+		Source:          r.FormValue("source"),
+		FormatHint:      r.FormValue("format"),
+		Filename:        header.Filename,
+		DefaultDeckName: r.FormValue("deckName"),
+		DefaultNoteType: r.FormValue("noteType"),
 	}, nil
 }
 
-func (h *APIHandler) applyImportedNotes(notes []importNormalizedNote, defaultDeckName string) ImportNotesResponse {
-	return h.applyImportedNotesToCollection(h.collectionID, h.collection, notes, defaultDeckName)
+func (h *APIHandler) applyImportedNotes(ctx context.Context, notes []importNormalizedNote, defaultDeckName string) ImportNotesResponse {
+	return h.applyImportedNotesToCollection(ctx, h.collectionID, h.collection, notes, defaultDeckName, "", nil)
 }
 
-func (h *APIHandler) applyImportedNotesToCollection(collectionID string, col *Collection, notes []importNormalizedNote, defaultDeckName string) ImportNotesResponse {
+func (h *APIHandler) applyImportedNotesToCollection(ctx context.Context, collectionID string, col *Collection, notes []importNormalizedNote, defaultDeckName string, userID string, progress *operation) ImportNotesResponse {
 	result := ImportNotesResponse{}
 	deckCache := make(map[string]int64)
 	createdDecks := make(map[string]struct{})
+	var reviews []RevlogEntry
 
 	for id, deck := range col.Decks {
 		deckCache[strings.ToLower(deck.Name)] = id
 	}
 
+	progress.setTotal(int64(len(notes)))
 	for i, importedNote := range notes {
+		progress.report(int64(i), result.Errors)
 		noteTypeName := importedNote.NoteType
 		if noteTypeName == "" {
 			noteTypeName = "Basic"
@@ -1696,7 +1770,7 @@ func (h *APIHandler) applyImportedNotesToCollection(collectionID string, col *Co
 		}
 
 		deckName := firstNonEmpty(importedNote.DeckName, defaultDeckName, "Default")
-		deckID, err := h.ensureDeckByName(collectionID, col, deckName, deckCache, createdDecks)
+		deckID, err := h.ensureDeckByName(ctx, collectionID, col, deckName, deckCache, createdDecks)
 		if err != nil {
 			result.Skipped++
 			result.Errors = append(result.Errors, fmt.Sprintf("row %d: failed to resolve deck %q: %v", i+1, deckName, err))
@@ -1732,33 +1806,54 @@ func (h *APIHandler) applyImportedNotesToCollection(collectionID string, col *Co
 			note.Tags = []string{}
 		}
 
-		if err := h.store.CreateNote(collectionID, &note); err != nil {
+		if err := h.store.CreateNote(ctx, collectionID, &note); err != nil {
 			result.Skipped++
 			result.Errors = append(result.Errors, fmt.Sprintf("row %d: failed to persist note: %v", i+1, err))
 			continue
 		}
 
 		cardErr := false
-		for _, card := range cards {
-			if err := h.store.CreateCard(card); err != nil {
+		for position, card := range cards {
+			scheduling, scheduled := importedNote.Scheduling[position]
+			var history []RevlogEntry
+			if scheduled {
+				card.SRS, history = fsrsStateForImport(col.Params, scheduling, time.Now())
+			}
+			if err := h.store.CreateCard(ctx, card); err != nil {
 				cardErr = true
 				result.Errors = append(result.Errors, fmt.Sprintf("row %d: failed to persist card: %v", i+1, err))
 				break
 			}
+			if !scheduled {
+				continue
+			}
+			if err := h.store.UpdateCardReviewState(ctx, userID, card); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("row %d: failed to keep review progress: %v", i+1, err))
+				continue
+			}
+			for _, entry := range history {
+				entry.CardID = card.ID
+				reviews = append(reviews, entry)
+			}
 		}
 		if cardErr {
 			result.Skipped++
 			continue
 		}
+		h.synthesizeNoteSpeech(ctx, collectionID, col, note)
 
 		result.Imported++
 	}
 
+	if err := h.store.ImportRevlogEntries(ctx, userID, reviews); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("failed to import review history: %v", err))
+	}
+	progress.report(int64(len(notes)), result.Errors)
 	result.DecksCreated = sortedKeys(createdDecks)
 	return result
 }
 
-func (h *APIHandler) ensureDeckByName(collectionID string, col *Collection, deckName string, deckCache map[string]int64, createdDecks map[string]struct{}) (int64, error) {
+func (h *APIHandler) ensureDeckByName(ctx context.Context, collectionID string, col *Collection, deckName string, deckCache map[string]int64, createdDecks map[string]struct{}) (int64, error) {
 	name := firstNonEmpty(deckName, "Default")
 	key := strings.ToLower(name)
 	if id, ok := deckCache[key]; ok {
@@ -1778,7 +1873,7 @@ func (h *APIHandler) ensureDeckByName(collectionID string, col *Collection, deck
 	}
 
 	newDeck := col.NewDeck(sanitized)
-	if err := h.store.CreateDeckInCollection(collectionID, newDeck); err != nil {
+	if err := h.store.CreateDeckInCollection(ctx, collectionID, newDeck); err != nil {
 		return 0, err
 	}
 
@@ -1815,17 +1910,37 @@ func stripHTML(html string) string {
 
 // Backup endpoints
 
+// CreateBackupResponse reports a new backup and the old ones the retention policy
+// pruned after it.
+type CreateBackupResponse struct {
+	Message    string   `json:"message"`
+	BackupPath string   `json:"backupPath"`
+	Timestamp  string   `json:"timestamp"`
+	Pruned     []string `json:"pruned"`
+}
+
 func (h *APIHandler) CreateBackup(w http.ResponseWriter, r *http.Request) {
-	backupPath, err := h.backupManager.CreateBackup(h.collectionIDForRequest(r))
+	if h.backupManager == nil || h.backupManager.dbPath == "" {
+		respondAPIError(w, http.StatusNotImplemented, "backup_unavailable", "Backups need a local SQLite database")
+		return
+	}
+	op := h.startOperation(w, r, operationBackup, "bytes")
+	backupPath, err := h.backupManager.createBackup(r.Context(), h.collectionIDForRequest(r), op)
+	op.finish(err)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create backup: %v", err), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "backup_create_failed", fmt.Sprintf("Failed to create backup: %v", err))
 		return
 	}
+	pruned, err := h.backupManager.PruneBackups()
+	if err != nil {
+		log.Printf("Warning: backup pruning failed: %v", err)
+	}
 
-	respondJSON(w, http.StatusCreated, map[string]string{
-		"message":    "Backup created successfully",
-		"backupPath": backupPath,
-		"timestamp":  time.Now().Format(time.RFC3339),
+	respondJSON(w, http.StatusCreated, CreateBackupResponse{
+		Message:    "Backup created successfully",
+		BackupPath: backupPath,
+		Timestamp:  time.Now().Format(time.RFC3339),
+		Pruned:     pruned,
 	})
 }
 
@@ -1833,31 +1948,56 @@ type RestoreBackupRequest struct {
 	BackupPath string `json:"backupPath"`
 }
 
+func (req *RestoreBackupRequest) Validate(v *requestValidator) {
+	v.required("backupPath", req.BackupPath)
+}
+
+type RestoreBackupResponse struct {
+	Message    string `json:"message"`
+	BackupPath string `json:"backupPath"`
+	RestoredAt string `json:"restoredAt"`
+}
+
+// RestoreBackup serves POST /api/backups/restore. It replaces the active profile's
+// database with the backup, named by file name or path, without a restart. Since that
+// replaces every user's data, only a local server on a SQLite file offers it.
 func (h *APIHandler) RestoreBackup(w http.ResponseWriter, r *http.Request) {
 	var req RestoreBackupRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
-
-	if req.BackupPath == "" {
-		http.Error(w, "backupPath is required", http.StatusBadRequest)
+	if !h.config.IsDevelopment() {
+		respondAPIError(w, http.StatusForbidden, "restore_unavailable", "Restoring backups is only available on a local server")
+		return
+	}
+	if h.backupManager == nil || h.backupManager.dbPath == "" {
+		respondAPIError(w, http.StatusNotImplemented, "restore_unavailable", "Restoring backups needs a local SQLite database")
+		return
+	}
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	backupPath, err := h.backupManager.resolveBackup(req.BackupPath)
+	if err != nil {
+		respondAPIError(w, http.StatusNotFound, "backup_not_found", err.Error())
 		return
 	}
 
-	// Warning: This will close the database connection and replace the database
-	// In a production system, this would need more careful handling
-	respondJSON(w, http.StatusOK, map[string]string{
-		"message": "Restore functionality requires server restart. Use with caution.",
-		"warning": "This operation will replace the current database.",
-		"note":    "Implement with /api/backups/restore endpoint after server architecture supports it.",
+	if err := h.restoreBackup(r.Context(), backupPath); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "backup_restore_failed", fmt.Sprintf("Failed to restore backup: %v", err))
+		return
+	}
+	respondJSON(w, http.StatusOK, RestoreBackupResponse{
+		Message:    "Backup restored successfully",
+		BackupPath: backupPath,
+		RestoredAt: time.Now().Format(time.RFC3339),
 	})
 }
 
 func (h *APIHandler) ListBackups(w http.ResponseWriter, r *http.Request) {
 	files, err := filepath.Glob(filepath.Join(h.backupManager.backupDir, "microdote-backup-*.zip"))
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to list backups: %v", err), http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "backup_list_failed", fmt.Sprintf("Failed to list backups: %v", err))
 		return
 	}
 
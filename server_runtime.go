@@ -11,15 +11,33 @@ import (
 	"github.com/go-chi/cors"
 )
 
+// compressibleContentTypes are gzipped for clients that accept it. Media, .apkg
+// packages, and backups are left alone since they are already compressed.
+var compressibleContentTypes = []string{
+	"application/json",
+	"application/x-ndjson",
+	"text/csv",
+	"text/calendar",
+	"text/html",
+	"text/css",
+	"text/plain",
+	"text/javascript",
+	"application/javascript",
+	"image/svg+xml",
+}
+
 func NewServer(cfg AppConfig, handler *APIHandler, frontend fs.FS) http.Handler {
 	router := chi.NewRouter()
+	router.Use(assignRequestID)
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.RealIP)
+	router.Use(middleware.Compress(5, compressibleContentTypes...))
 	router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   cfg.AllowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization", "X-Vutadex-Plan"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "X-Vutadex-Plan", "If-None-Match", requestIDHeader, operationIDHeader},
+		ExposedHeaders:   []string{"ETag", requestIDHeader, operationIDHeader},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
@@ -27,6 +45,9 @@ func NewServer(cfg AppConfig, handler *APIHandler, frontend fs.FS) http.Handler
 	router.Route("/api", func(r chi.Router) {
 		registerAPIRoutes(r, handler)
 	})
+	router.With(handler.holdActiveProfile).Get("/calendar.ics", handler.ServeCalendarICS)
+	router.With(handler.holdActiveProfile, handler.SessionMiddleware, handler.RequireAuthenticatedUser).Get("/media/{filename}", handler.ServeMedia)
+	mountProfiler(router, cfg.Pprof, handler)
 
 	spaHandler := NewEmbeddedSPAHandler(frontend)
 	router.Handle("/*", spaHandler)
@@ -40,13 +61,13 @@ func NewEmbeddedSPAHandler(frontend fs.FS) http.Handler {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet && r.Method != http.MethodHead {
-			http.NotFound(w, r)
+			respondAPIError(w, http.StatusNotFound, "not_found", "Not found")
 			return
 		}
 
 		cleanPath := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
 		if cleanPath == "api" || strings.HasPrefix(cleanPath, "api/") {
-			http.NotFound(w, r)
+			respondAPINotFound(w, r)
 			return
 		}
 
@@ -68,14 +89,14 @@ func NewEmbeddedSPAHandler(frontend fs.FS) http.Handler {
 func serveEmbeddedIndex(frontend fs.FS, w http.ResponseWriter, r *http.Request) {
 	index, err := frontend.Open("index.html")
 	if err != nil {
-		http.Error(w, "embedded app index is unavailable", http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "app_unavailable", "embedded app index is unavailable")
 		return
 	}
 	defer index.Close()
 
 	stat, err := index.Stat()
 	if err != nil {
-		http.Error(w, "embedded app index metadata is unavailable", http.StatusInternalServerError)
+		respondAPIError(w, http.StatusInternalServerError, "app_unavailable", "embedded app index metadata is unavailable")
 		return
 	}
 
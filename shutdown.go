@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Close releases the handler once the HTTP server has drained its requests. It
+// disconnects WebSocket clients, which the server does not drain, waits
+// for background work such as the media sweeper, whose context the caller has already
+// cancelled, takes the final backup when Shutdown.Backup is set, and closes the active
+// profile's database and the main one.
+func (h *APIHandler) Close() error {
+	h.events.disconnectAll()
+	h.background.Wait()
+	h.profiles.mu.Lock()
+	defer h.profiles.mu.Unlock()
+
+	var errs []error
+	if h.config.Shutdown.Backup && h.backupManager != nil && h.backupManager.dbPath != "" {
+		if _, err := h.backupManager.CreateBackup(context.Background(), h.collectionID); err != nil {
+			errs = append(errs, fmt.Errorf("final backup failed: %w", err))
+		}
+	}
+	if h.store != nil {
+		if err := h.store.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close database: %w", err))
+		}
+	}
+	if h.profiles.registry != nil && h.profiles.registry != h.store {
+		if err := h.profiles.registry.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close profile registry: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCloseTakesFinalBackupAndClosesStore(t *testing.T) {
+	cfg := mustLocalAppConfig()
+	cfg.Shutdown.Backup = true
+	cfg.MediaCleanup.Interval = time.Hour
+	env := setupFileAPITestEnvWithConfig(t, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	env.handler.StartMediaSweeper(ctx)
+	cancel()
+	if err := env.handler.Close(); err != nil {
+		t.Fatalf("expected a clean close, got %v", err)
+	}
+
+	backups, err := filepath.Glob(filepath.Join(env.backupDir, "microdote-backup-*.zip"))
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("expected one final backup, got %v (%v)", backups, err)
+	}
+	if err := env.store.db.Ping(); err == nil {
+		t.Fatalf("expected the store to be closed")
+	}
+}
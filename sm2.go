@@ -0,0 +1,84 @@
+package main
+
+import (
+	"cmp"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// Decks whose preset picks the SM-2 scheduler get Anki's classic intervals, driven by a
+// per-card ease, instead of FSRS's. Cards, revlog and learning steps are shared between
+// the two, and FSRS keeps tracking every card's memory state under SM-2 too, so a deck
+// can be switched back to FSRS at any time without rescheduling its cards.
+const (
+	schedulerFSRS = "fsrs"
+	schedulerSM2  = "sm2"
+
+	defaultStartingEase     = 2500 // permille
+	minimumEase             = 1300
+	defaultEasyBonus        = 130 // percent
+	defaultIntervalModifier = 100 // percent
+	sm2HardMultiplier       = 1.2
+)
+
+func schedulerOrDefault(scheduler string) string {
+	if scheduler == schedulerSM2 {
+		return scheduler
+	}
+	return schedulerFSRS
+}
+
+// applySM2 replaces the review interval of an answer, already through apply's learning
+// steps, with SM-2's, and returns the card's ease afterwards. Graduating cards take the
+// preset's graduating or easy interval; review cards grow by their ease, with a late
+// review credited for the extra time it survived. Lapses keep apply's interval and cost
+// 20% ease; Hard costs 15% and Easy adds 15%.
+func (ls learningSchedule) applySM2(card *Card, info fsrs.SchedulingInfo, rating fsrs.Rating, now time.Time, maxDays float64) (fsrs.SchedulingInfo, int) {
+	ease := cmp.Or(card.EaseFactor, ls.StartingEase)
+	toReviewIn := func(days int) {
+		days = max(min(days, int(maxDays)), 1)
+		info.Card.State = fsrs.Review
+		info.Card.ScheduledDays = uint64(days)
+		info.Card.Due = now.Add(time.Duration(days) * 24 * time.Hour)
+		info.ReviewLog.ScheduledDays = uint64(days)
+	}
+
+	switch card.SRS.State {
+	case fsrs.New, fsrs.Learning:
+		if info.Card.State != fsrs.Review {
+			break
+		}
+		if rating == fsrs.Easy {
+			toReviewIn(ls.EasyDays)
+		} else {
+			toReviewIn(ls.GraduatingDays)
+		}
+	case fsrs.Review:
+		if rating == fsrs.Again {
+			ease = max(ease-200, minimumEase)
+			break
+		}
+		interval := max(int(card.SRS.ScheduledDays), 1)
+		late := 0
+		if !card.SRS.LastReview.IsZero() {
+			late = max(int(now.Sub(card.SRS.LastReview).Hours()/24)-interval, 0)
+		}
+		modifier := float64(ls.IntervalModifier) / 100
+		factor := float64(ease) / 1000
+		hard := max(int(float64(interval)*sm2HardMultiplier*modifier), interval+1)
+		good := max(int(float64(interval+late/2)*factor*modifier), hard+1)
+		switch rating {
+		case fsrs.Hard:
+			toReviewIn(hard)
+			ease = max(ease-150, minimumEase)
+		case fsrs.Good:
+			toReviewIn(good)
+		case fsrs.Easy:
+			easyBonus := float64(ls.EasyBonus) / 100
+			toReviewIn(max(int(float64(interval+late)*factor*easyBonus*modifier), good+1))
+			ease += 150
+		}
+	}
+	return info, ease
+}
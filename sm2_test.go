@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestApplySM2ReviewIntervals(t *testing.T) {
+	now := time.Date(2026, 5, 10, 9, 0, 0, 0, time.UTC)
+	schedule := newLearningSchedule(&DeckOptions{GraduatingInterval: 1, EasyInterval: 4, MinimumInterval: 1})
+	card := &Card{EaseFactor: 2500, SRS: fsrs.Card{State: fsrs.Review, ScheduledDays: 10, LastReview: now.AddDate(0, 0, -10)}}
+
+	cases := []struct {
+		rating   fsrs.Rating
+		wantDays uint64
+		wantEase int
+	}{
+		{fsrs.Hard, 12, 2350},
+		{fsrs.Good, 25, 2500},
+		{fsrs.Easy, 32, 2650},
+	}
+	for _, tc := range cases {
+		info, ease := schedule.applySM2(card, fsrs.SchedulingInfo{}, tc.rating, now, 36500)
+		if info.Card.ScheduledDays != tc.wantDays || info.Card.State != fsrs.Review || ease != tc.wantEase {
+			t.Fatalf("rating %d: got %d days, ease %d; want %d days, ease %d", tc.rating, info.Card.ScheduledDays, ease, tc.wantDays, tc.wantEase)
+		}
+	}
+
+	// A late review is credited for half the extra time on Good.
+	late := &Card{EaseFactor: 2000, SRS: fsrs.Card{State: fsrs.Review, ScheduledDays: 10, LastReview: now.AddDate(0, 0, -20)}}
+	if info, _ := schedule.applySM2(late, fsrs.SchedulingInfo{}, fsrs.Good, now, 36500); info.Card.ScheduledDays != 30 {
+		t.Fatalf("expected a late Good to give 30 days, got %d", info.Card.ScheduledDays)
+	}
+	if _, ease := schedule.applySM2(&Card{EaseFactor: 1400, SRS: card.SRS}, fsrs.SchedulingInfo{}, fsrs.Again, now, 36500); ease != minimumEase {
+		t.Fatalf("expected a lapse to floor the ease at %d, got %d", minimumEase, ease)
+	}
+}
+
+func TestAPI_SM2SchedulerPreset(t *testing.T) {
+	env := setupAPITestEnv(t)
+	createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Mitochondria", "Back": "Powerhouse"},
+	}, nil)
+
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/deck-options", map[string]interface{}{"name": "Bad", "scheduler": "sm5"}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected unknown scheduler 400, got %d", rr.Code)
+	}
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/deck-options", map[string]interface{}{"name": "Classic", "scheduler": "sm2"})
+	preset := decodeJSON[DeckOptionsResponse](t, rr)
+	if rr.Code != http.StatusCreated || preset.Scheduler != schedulerSM2 || preset.StartingEase != defaultStartingEase || preset.EasyBonus != defaultEasyBonus {
+		t.Fatalf("expected an SM-2 preset with default settings, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/deck-options/%d/assign", preset.ID), AssignDeckOptionsRequest{DeckIDs: []int64{1}}); rr.Code != http.StatusOK {
+		t.Fatalf("expected assign 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	due := decodeJSON[[]*Card](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=10", ""))
+	if len(due) != 1 {
+		t.Fatalf("expected the new card due, got %d", len(due))
+	}
+	cardID := due[0].ID
+	answer := func(rating int) Card {
+		t.Helper()
+		rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", cardID), AnswerCardRequest{Rating: rating})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+		return decodeJSON[Card](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/cards/%d", cardID), ""))
+	}
+
+	// Easy graduates at the preset's easy interval; Good then multiplies it by the ease.
+	if card := answer(4); card.SRS.ScheduledDays != 4 || card.EaseFactor != defaultStartingEase {
+		t.Fatalf("expected graduation to 4 days at ease 2500, got %d days, ease %d", card.SRS.ScheduledDays, card.EaseFactor)
+	}
+	card := answer(3)
+	if card.SRS.ScheduledDays != 10 || card.EaseFactor != defaultStartingEase {
+		t.Fatalf("expected Good to give 10 days, got %d days, ease %d", card.SRS.ScheduledDays, card.EaseFactor)
+	}
+	if card.SRS.Stability <= 0 || card.SRS.Reps != 2 {
+		t.Fatalf("expected FSRS memory state kept under SM-2, got %+v", card.SRS)
+	}
+
+	// Switching back to FSRS keeps the card and its history.
+	if rr := doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/deck-options/%d", preset.ID), map[string]string{"scheduler": "fsrs"}); rr.Code != http.StatusOK {
+		t.Fatalf("expected update 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if card := answer(3); card.SRS.Reps != 3 || card.EaseFactor != defaultStartingEase {
+		t.Fatalf("expected FSRS to carry on from the SM-2 card, got reps %d, ease %d", card.SRS.Reps, card.EaseFactor)
+	}
+}
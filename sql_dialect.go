@@ -0,0 +1,98 @@
+package main
+
+import "fmt"
+
+// sqlDialect is the SQL a store's database speaks. The store's queries are written in
+// the SQL that SQLite and PostgreSQL share; the few fragments they cannot share come
+// from the dialect.
+type sqlDialect int
+
+const (
+	dialectSQLite sqlDialect = iota
+	dialectPostgres
+)
+
+// sqlBlob marks a query argument as binary data. Every other byte slice the store
+// binds is a JSON document headed for a text column, which PostgreSQL must be sent as
+// text rather than bytea.
+type sqlBlob []byte
+
+// retryable reports whether err aborted a transaction only because it conflicted with
+// a concurrent one, so that running it again may succeed.
+func (d sqlDialect) retryable(err error) bool {
+	return d == dialectPostgres && postgresRetryable(err)
+}
+
+// like is the operator that matches a LIKE pattern ignoring case, as SQLite's LIKE does.
+func (d sqlDialect) like() string {
+	if d == dialectPostgres {
+		return "ILIKE"
+	}
+	return "LIKE"
+}
+
+// jsonArrayValues is a table source over the elements of the JSON array in expr, with
+// each element's text in the column value.
+func (d sqlDialect) jsonArrayValues(expr, alias string) string {
+	if d == dialectPostgres {
+		return fmt.Sprintf("jsonb_array_elements_text(%s) AS %s(value)", postgresJSONArray(expr), alias)
+	}
+	return fmt.Sprintf("json_each(%s) AS %s", expr, alias)
+}
+
+// jsonObjectEntries is a table source over the members of the JSON object in expr, in
+// the columns key and value.
+func (d sqlDialect) jsonObjectEntries(expr, alias string) string {
+	if d == dialectPostgres {
+		return fmt.Sprintf("jsonb_each_text(CASE WHEN jsonb_typeof((%[1]s)::jsonb) = 'object' THEN (%[1]s)::jsonb END) AS %[2]s(key, value)", expr, alias)
+	}
+	return fmt.Sprintf("json_each(%s) AS %s", expr, alias)
+}
+
+// jsonArrayLength is the number of elements of the JSON array in expr.
+func (d sqlDialect) jsonArrayLength(expr string) string {
+	if d == dialectPostgres {
+		return fmt.Sprintf("jsonb_array_length(%s)", postgresJSONArray(expr))
+	}
+	return fmt.Sprintf("json_array_length(%s)", expr)
+}
+
+// postgresJSONArray casts expr to jsonb, or to NULL when it holds something other than
+// an array, such as the null a note without tags is saved with; SQLite's JSON functions
+// take those as empty, where PostgreSQL's would fail.
+func postgresJSONArray(expr string) string {
+	return fmt.Sprintf("CASE WHEN jsonb_typeof((%[1]s)::jsonb) = 'array' THEN (%[1]s)::jsonb END", expr)
+}
+
+// jsonNumber is the number under key in the JSON object in expr, or NULL.
+func (d sqlDialect) jsonNumber(expr, key string) string {
+	if d == dialectPostgres {
+		return fmt.Sprintf("CAST((%s)::jsonb ->> '%s' AS DOUBLE PRECISION)", expr, key)
+	}
+	return fmt.Sprintf("json_extract(%s, '$.%s')", expr, key)
+}
+
+// greatest is the larger of two values.
+func (d sqlDialect) greatest(a, b string) string {
+	if d == dialectPostgres {
+		return fmt.Sprintf("GREATEST(%s, %s)", a, b)
+	}
+	return fmt.Sprintf("MAX(%s, %s)", a, b)
+}
+
+// utcDate is the UTC calendar date, as YYYY-MM-DD, of the Unix time in expr.
+func (d sqlDialect) utcDate(expr string) string {
+	if d == dialectPostgres {
+		return fmt.Sprintf("to_char(to_timestamp(%s) AT TIME ZONE 'UTC', 'YYYY-MM-DD')", expr)
+	}
+	return fmt.Sprintf("date(%s, 'unixepoch')", expr)
+}
+
+// localHour is the hour of day of the Unix time in expr: in the server's local time on
+// SQLite, and in the session's time zone on PostgreSQL.
+func (d sqlDialect) localHour(expr string) string {
+	if d == dialectPostgres {
+		return fmt.Sprintf("CAST(EXTRACT(HOUR FROM to_timestamp(%s)) AS INTEGER)", expr)
+	}
+	return fmt.Sprintf("CAST(strftime('%%H', %s, 'unixepoch', 'localtime') AS INTEGER)", expr)
+}
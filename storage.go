@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -14,84 +15,404 @@ import (
 )
 
 // Store defines the persistence interface for Microdote.
-// All business logic should interact with this interface, not directly with SQL.
+// All business logic should interact with this interface, not directly with SQL; the
+// API handler holds nothing more specific, so any Store can back the server.
+// Methods take the context of the request they serve, so their queries stop when
+// the client goes away.
 type Store interface {
 	// Collection
-	CreateCollection(c *Collection) error
-	GetCollection(id string) (*Collection, error)
-	UpdateCollection(c *Collection) error
+	CreateCollection(ctx context.Context, c *Collection) error
+	GetCollection(ctx context.Context, id string) (*Collection, error)
+	UpdateCollection(ctx context.Context, c *Collection) error
 
 	// Decks
-	CreateDeck(d *Deck) error
-	GetDeck(id int64) (*Deck, error)
-	UpdateDeck(d *Deck) error
-	DeleteDeck(id int64) error
-	ListDecks(collectionID string) ([]*Deck, error)
+	CreateDeck(ctx context.Context, d *Deck) error
+	GetDeck(ctx context.Context, id int64) (*Deck, error)
+	UpdateDeck(ctx context.Context, d *Deck) error
+	DeleteDeck(ctx context.Context, id int64) error
+	ListDecks(ctx context.Context, collectionID string) ([]*Deck, error)
 
 	// Note Types
-	CreateNoteType(collectionID string, nt *NoteType) error
-	GetNoteType(collectionID string, name NoteTypeName) (*NoteType, error)
-	UpdateNoteType(collectionID string, nt *NoteType) error
-	ListNoteTypes(collectionID string) (map[NoteTypeName]NoteType, error)
+	CreateNoteType(ctx context.Context, collectionID string, nt *NoteType) error
+	GetNoteType(ctx context.Context, collectionID string, name NoteTypeName) (*NoteType, error)
+	UpdateNoteType(ctx context.Context, collectionID string, nt *NoteType) error
+	ListNoteTypes(ctx context.Context, collectionID string) (map[NoteTypeName]NoteType, error)
 
 	// Notes
-	CreateNote(collectionID string, n *Note) error
-	GetNote(id int64) (*Note, error)
-	UpdateNote(n *Note) error
-	DeleteNote(id int64) error
-	ListNotes(collectionID string) (map[int64]Note, error)
-	FindDuplicateNotes(collectionID, fieldName, value string, deckID int64) ([]NoteBrief, error)
+	CreateNote(ctx context.Context, collectionID string, n *Note) error
+	CreateNoteWithCards(ctx context.Context, collectionID string, n *Note, cards []*Card) error
+	GetNote(ctx context.Context, id int64) (*Note, error)
+	UpdateNote(ctx context.Context, n *Note) error
+	DeleteNote(ctx context.Context, id int64) error
+	ListNotes(ctx context.Context, collectionID string) (map[int64]Note, error)
+	FindDuplicateNotes(ctx context.Context, collectionID, fieldName, value string, deckID int64) ([]NoteBrief, error)
 
 	// Cards
-	CreateCard(c *Card) error
-	GetCard(id int64) (*Card, error)
-	GetCardForUser(userID string, id int64) (*Card, error)
-	UpdateCard(c *Card) error
-	DeleteCard(id int64) error
-	GetDueCards(deckID int64, limit int) ([]*Card, error)
-	GetDueCardsForUser(userID string, deckID int64, limit int) ([]*Card, error)
-	ListCardsInDeck(deckID int64) ([]*Card, error)
-	GetDeckStats(deckID int64) (*DeckStats, error)
-	GetDeckStatsForUser(userID string, deckID int64) (*DeckStats, error)
-	CountDueCardsForUser(userID string) (int, error)
-	EnsureReviewStatesForUser(userID string) error
-	UpdateCardReviewState(userID string, c *Card) error
+	CreateCard(ctx context.Context, c *Card) error
+	GetCard(ctx context.Context, id int64) (*Card, error)
+	GetCardForUser(ctx context.Context, userID string, id int64) (*Card, error)
+	UpdateCard(ctx context.Context, c *Card) error
+	DeleteCard(ctx context.Context, id int64) error
+	GetDueCards(ctx context.Context, deckID int64, limit int) ([]*Card, error)
+	GetDueCardsForUser(ctx context.Context, userID string, deckID int64, limit int) ([]*Card, error)
+	ListCardsInDeck(ctx context.Context, deckID int64) ([]*Card, error)
+	GetDeckStats(ctx context.Context, deckID int64) (*DeckStats, error)
+	GetDeckStatsForUser(ctx context.Context, userID string, deckID int64) (*DeckStats, error)
+	CountDueCardsForUser(ctx context.Context, userID, collectionID string) (int, error)
+	EnsureReviewStatesForUser(ctx context.Context, userID string) error
+	UpdateCardReviewState(ctx context.Context, userID string, c *Card) error
 
 	// Revlog
-	AddRevlog(r *fsrs.ReviewLog, cardID int64, timeTakenMs int) error
-	AddRevlogForUser(userID string, r *fsrs.ReviewLog, cardID int64, timeTakenMs int) error
-	GetRevlogForCard(cardID int64) ([]*fsrs.ReviewLog, error)
+	AddRevlog(ctx context.Context, r *fsrs.ReviewLog, cardID int64, timeTakenMs int) error
+	AddRevlogForUser(ctx context.Context, userID string, r *fsrs.ReviewLog, cardID int64, timeTakenMs int) error
+	AddRevlogDetailForUser(ctx context.Context, userID string, r *fsrs.ReviewLog, after fsrs.Card, kind string, cardID int64, timeTakenMs int) error
+	GetRevlogForCard(ctx context.Context, cardID int64) ([]*fsrs.ReviewLog, error)
 
 	// Study sessions
-	CreateStudySessionRecord(session *StudySession) error
-	GetStudySession(id string) (*StudySession, error)
-	GetStudySessionForUser(id, userID string) (*StudySession, error)
-	UpdateStudySessionRecord(session *StudySession) error
+	CreateStudySessionRecord(ctx context.Context, session *StudySession) error
+	GetStudySession(ctx context.Context, id string) (*StudySession, error)
+	GetStudySessionForUser(ctx context.Context, id, userID string) (*StudySession, error)
+	UpdateStudySessionRecord(ctx context.Context, session *StudySession) error
 
 	// Media
-	AddMedia(collectionID string, m *MediaRef) error
-	GetMedia(filename string) (*MediaRef, error)
-	DeleteMedia(filename string) error
+	AddMedia(ctx context.Context, collectionID string, m *MediaRef) error
+	GetMedia(ctx context.Context, filename string) (*MediaRef, error)
+	DeleteMedia(ctx context.Context, filename string) error
 
 	// Profiles (Task 0003)
-	CreateProfile(p *Profile) error
-	GetProfile(id string) (*Profile, error)
-	ListProfiles() ([]*Profile, error)
-	SetActiveProfile(id string) error
-	GetActiveProfile() (*Profile, error)
-
-	// Transactions
-	BeginTx() (*sql.Tx, error)
-	CommitTx(tx *sql.Tx) error
-	RollbackTx(tx *sql.Tx) error
+	CreateProfile(ctx context.Context, p *Profile) error
+	GetProfile(ctx context.Context, id string) (*Profile, error)
+	ListProfiles(ctx context.Context) ([]*Profile, error)
+	SetActiveProfile(ctx context.Context, id string) error
+	GetActiveProfile(ctx context.Context) (*Profile, error)
+
+	// Accounts, workspaces, organizations, sessions, and subscriptions
+	CreateUser(ctx context.Context, user *User) error
+	GetUserByID(ctx context.Context, id string) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	GetUserByOAuth(ctx context.Context, provider, subject string) (*User, error)
+	UpsertOAuthIdentity(ctx context.Context, identity *OAuthIdentity) error
+	CreateWorkspaceRecord(ctx context.Context, workspace *Workspace) error
+	UpdateWorkspaceRecord(ctx context.Context, workspace *Workspace) error
+	GetWorkspaceRecord(ctx context.Context, id string) (*Workspace, error)
+	GetFirstWorkspaceForUser(ctx context.Context, userID string) (*Workspace, error)
+	CountWorkspacesForUser(ctx context.Context, userID string) (int, error)
+	CreateOrganizationRecord(ctx context.Context, org *Organization) error
+	GetOrganizationRecord(ctx context.Context, id string) (*Organization, error)
+	UpdateOrganizationRecord(ctx context.Context, org *Organization) error
+	DeleteOrganizationRecord(ctx context.Context, id string) error
+	CreateOrganizationMemberRecord(ctx context.Context, member *OrganizationMember) error
+	GetOrganizationMember(ctx context.Context, id string) (*OrganizationMember, error)
+	GetOrganizationMemberByUser(ctx context.Context, orgID, userID string) (*OrganizationMember, error)
+	GetOrganizationMemberByInviteToken(ctx context.Context, token string) (*OrganizationMember, error)
+	ListOrganizationMembers(ctx context.Context, orgID string) ([]OrganizationMember, error)
+	UpdateOrganizationMember(ctx context.Context, member *OrganizationMember) error
+	UpsertOrganizationInvitation(ctx context.Context, member *OrganizationMember) error
+	GetWorkspaceForOrganization(ctx context.Context, orgID string) (*Workspace, error)
+	CreateSessionRecord(ctx context.Context, session *SessionRecord) error
+	GetSessionRecord(ctx context.Context, id string) (*SessionRecord, error)
+	DeleteSessionRecord(ctx context.Context, id string) error
+	TouchSessionRecord(ctx context.Context, id string, expiresAt, lastSeenAt time.Time) error
+	UpdateSessionWorkspace(ctx context.Context, id, workspaceID string) error
+	UpdateUserLastLogin(ctx context.Context, userID string, at time.Time) error
+	UpdateUserOnboarding(ctx context.Context, userID string, onboarding bool) error
+	UpsertSubscription(ctx context.Context, subscription *Subscription) error
+	GetSubscriptionForWorkspace(ctx context.Context, workspaceID string) (*Subscription, error)
+	GetSubscriptionForOrganization(ctx context.Context, organizationID string) (*Subscription, error)
+	GetSubscriptionByProviderSubscriptionID(ctx context.Context, providerSubscriptionID string) (*Subscription, error)
+	GetSubscriptionByProviderCheckoutSessionID(ctx context.Context, providerCheckoutSessionID string) (*Subscription, error)
+	CreateSubscriptionEvent(ctx context.Context, event *SubscriptionEvent) error
+	CountActiveOrganizationMembers(ctx context.Context, organizationID string) (int, error)
+	CreateDeckShareRecord(ctx context.Context, share *DeckShare) error
+	DeleteDeckShareByDeckID(ctx context.Context, deckID int64) error
+	CountDeckSharesForWorkspace(ctx context.Context, workspaceID string) (int, error)
+	CountSyncDevicesForWorkspace(ctx context.Context, workspaceID string) (int, error)
+	ListRecentDeckNotes(ctx context.Context, collectionID string, deckID int64, limit int, cursorCreatedAt int64, cursorNoteID int64) ([]RecentDeckNoteSummary, error)
+
+	// Sign-in codes
+	CreateOTPChallenge(ctx context.Context, challenge *OTPChallenge) error
+	InvalidateOTPChallenges(ctx context.Context, email string) error
+	GetLatestOTPChallenge(ctx context.Context, email string) (*OTPChallenge, error)
+	IncrementOTPChallengeAttempts(ctx context.Context, id string) error
+	ConsumeOTPChallenge(ctx context.Context, id string, consumedAt time.Time) error
+	CountRecentOTPChallengesByEmail(ctx context.Context, email string, since time.Time) (int, error)
+	CountRecentOTPChallengesByIP(ctx context.Context, ip string, since time.Time) (int, error)
+
+	// Answer button statistics
+	GetAnswerButtonStats(ctx context.Context, userID, collectionID string, deckID int64, since time.Time) ([]AnswerButtonGroup, error)
+
+	// API tokens
+	CreateAPIToken(ctx context.Context, token *APIToken, tokenHash string) error
+	ListAPITokens(ctx context.Context, userID string) ([]APIToken, error)
+	GetActiveAPITokenByHash(ctx context.Context, tokenHash string, now time.Time) (*APIToken, error)
+	TouchAPIToken(ctx context.Context, id string, at time.Time) error
+	RevokeAPIToken(ctx context.Context, userID, id string, at time.Time) error
+
+	// Passwords
+	GetUserPasswordState(ctx context.Context, userID string) (userPasswordState, error)
+	SetUserPasswordHash(ctx context.Context, userID, hash string, at time.Time) error
+	RecordFailedLogin(ctx context.Context, userID string, lockUntil time.Time) error
+	ClearFailedLogins(ctx context.Context, userID string) error
+
+	// Backups
+	checkpoint(ctx context.Context) error
+	loadBackupPolicy(ctx context.Context, fallback BackupPolicy) (BackupPolicy, error)
+	saveBackupPolicy(ctx context.Context, policy BackupPolicy) error
+
+	// Burying
+	SetCardsBuried(ctx context.Context, userID string, cardIDs []int64, buried bool, now time.Time) (int, error)
+
+	// Calendar feeds
+	UpsertCalendarFeed(ctx context.Context, feed *CalendarFeed) error
+	GetCalendarFeedForUser(ctx context.Context, userID, collectionID string) (*CalendarFeed, error)
+	GetCalendarFeedByToken(ctx context.Context, token string) (*CalendarFeed, error)
+	TouchCalendarFeed(ctx context.Context, id string, accessedAt time.Time) error
+	DeleteCalendarFeedForUser(ctx context.Context, userID, collectionID string) error
+	GetReviewForecastForUser(ctx context.Context, userID, collectionID string, from time.Time, days int) ([]ReviewForecastDay, error)
+
+	// Bulk card state
+	SetCardsSuspended(ctx context.Context, userID string, cardIDs []int64, suspended bool) (int, error)
+	SetCardsFlag(ctx context.Context, userID string, cardIDs []int64, flag int) (int, error)
+
+	// Forgetting cards
+	ResetCardsToNew(ctx context.Context, userID string, cardIDs []int64, resetCounts, deleteHistory bool, now time.Time) (int, int, error)
+
+	// Moving cards
+	CardDecksInCollection(ctx context.Context, collectionID string, cardIDs []int64) (map[int64]int64, error)
+	MoveCards(ctx context.Context, cardIDs []int64, targetDeckID int64) error
+
+	// Setting due dates
+	SetCardDueDates(ctx context.Context, userID string, dues map[int64]time.Time, updateStability bool, params fsrs.Parameters, now time.Time) (int, int, error)
+
+	// Change feed
+	ListChanges(ctx context.Context, collectionID, userID string, sinceUSN int64, limit int) ([]ChangeEvent, error)
+
+	// Review log import and export
+	ListRevlogEntriesForCollection(ctx context.Context, userID, collectionID string) ([]RevlogEntry, error)
+	ImportRevlogEntries(ctx context.Context, userID string, entries []RevlogEntry) error
+
+	// Collection preferences
+	GetCollectionPreferences(ctx context.Context, collectionID string) (CollectionPreferences, error)
+	SaveCollectionPreferences(ctx context.Context, collectionID string, prefs CollectionPreferences) error
+
+	// Collections
+	createStarterCollection(ctx context.Context, collectionID, name string) error
+	SetCollectionOwner(ctx context.Context, collectionID, userID string) error
+	GetCollectionOwner(ctx context.Context, collectionID string) (string, error)
+	GetCollectionName(ctx context.Context, collectionID string) (string, error)
+	ListCollectionSummaries(ctx context.Context, workspaceCollectionID, userID string) ([]CollectionSummary, error)
+	collectionInUse(ctx context.Context, collectionID string) (bool, error)
+	DeleteCollection(ctx context.Context, collectionID string) error
+
+	// Cramming
+	ListCramCards(ctx context.Context, userID, collectionID string, deckID int64, query, order string, limit int, now time.Time) ([]*Card, error)
+
+	// Deck grants
+	UpsertDeckGrant(ctx context.Context, grant *DeckGrant) error
+	ListDeckGrants(ctx context.Context, deckID int64) ([]DeckGrant, error)
+	DeleteDeckGrant(ctx context.Context, deckID int64, id string) error
+	ListDeckGrantsForUser(ctx context.Context, userID, email string) ([]DeckGrant, error)
+	GetDeckGrantForUser(ctx context.Context, deckID int64, userID, email string) (*DeckGrant, error)
+	GetSharedDeckCards(ctx context.Context, deckID int64) ([]SharedCard, error)
+	deckInSubtree(ctx context.Context, root, deckID int64) (bool, error)
+
+	// Deck limits
+	deckAncestry(ctx context.Context, deckID int64) ([]int64, error)
+
+	// Deck option presets
+	CreateDeckOptionsInCollection(ctx context.Context, collectionID string, options *DeckOptions) error
+	ListDeckOptions(ctx context.Context, collectionID string) ([]*DeckOptions, error)
+	GetDeckOptionsInCollection(ctx context.Context, collectionID string, id int64) (*DeckOptions, error)
+	ListDeckIDsUsingOptions(ctx context.Context, optionsID int64) ([]int64, error)
+	DeleteDeckOptions(ctx context.Context, id int64) error
+
+	// Filtered decks
+	CreateFilteredDeck(ctx context.Context, def *FilteredDeck) error
+	GetFilteredDeck(ctx context.Context, deckID int64) (*FilteredDeck, error)
+	IsFilteredDeck(ctx context.Context, deckID int64) (bool, error)
+	ListFilteredDecks(ctx context.Context, collectionID string) ([]FilteredDeck, error)
+	filteredDeckForCard(ctx context.Context, card *Card) (*FilteredDeck, error)
+	EmptyFilteredDeck(ctx context.Context, deckID int64) ([]deckMove, error)
+	ReturnCardHome(ctx context.Context, cardID int64) ([]deckMove, error)
+	ReturnBorrowedCards(ctx context.Context, homeDeckID int64) ([]deckMove, error)
+	DeleteFilteredDeck(ctx context.Context, deckID int64) ([]deckMove, error)
+	RebuildFilteredDeck(ctx context.Context, userID, collectionID string, def *FilteredDeck, now time.Time) ([]deckMove, error)
+
+	// Full-text search
+	FullTextSearchNotes(ctx context.Context, collectionID, query string, offset, limit int) ([]int64, int, error)
+
+	// Hourly statistics
+	GetHourlyReviewStats(ctx context.Context, userID, collectionID string, deckID int64, since time.Time) ([]HourlyReviews, error)
+
+	// Learning steps
+	learningScheduleForDeck(ctx context.Context, deckID int64) (learningSchedule, error)
+
+	// Leeches
+	AddNoteTag(ctx context.Context, noteID int64, tag string, now time.Time) ([]string, error)
+	ListLeechCards(ctx context.Context, userID, collectionID string, deckID int64) ([]LeechCard, error)
+
+	// Maintenance
+	CheckDatabase(ctx context.Context, collectionID string, now time.Time) (*DatabaseCheckReport, error)
+	CleanupOrphans(ctx context.Context, collectionID string) (OrphanCleanupReport, error)
+	OptimizeDatabase(ctx context.Context, op *operation) (DatabaseOptimizeReport, error)
+
+	// Marketplace
+	GetMarketplaceCreatorAccount(ctx context.Context, id string) (*MarketplaceCreatorAccount, error)
+	GetMarketplaceCreatorAccountByUser(ctx context.Context, userID string) (*MarketplaceCreatorAccount, error)
+	GetMarketplaceCreatorAccountByProviderAccount(ctx context.Context, provider, providerAccountID string) (*MarketplaceCreatorAccount, error)
+	UpsertMarketplaceCreatorAccount(ctx context.Context, account *MarketplaceCreatorAccount) error
+	CreateMarketplaceOrder(ctx context.Context, order *MarketplaceOrder) error
+	GetMarketplaceOrderByCheckoutSession(ctx context.Context, provider, checkoutSessionID string) (*MarketplaceOrder, error)
+	GetMarketplaceOrder(ctx context.Context, id string) (*MarketplaceOrder, error)
+	UpdateMarketplaceOrder(ctx context.Context, order *MarketplaceOrder) error
+	GetMarketplaceLicense(ctx context.Context, listingID, buyerUserID string) (*MarketplaceLicense, error)
+	UpsertMarketplaceLicense(ctx context.Context, license *MarketplaceLicense) error
+	GetMarketplacePayoutByOrder(ctx context.Context, orderID string) (*MarketplacePayout, error)
+	UpsertMarketplacePayout(ctx context.Context, payout *MarketplacePayout) error
+	MarketplaceListingSlugExists(ctx context.Context, slug, excludeID string) (bool, error)
+	CreateMarketplaceListing(ctx context.Context, listing *MarketplaceListing) error
+	UpdateMarketplaceListing(ctx context.Context, listing *MarketplaceListing) error
+	DeleteMarketplaceListing(ctx context.Context, id string) error
+	CreateMarketplaceListingVersion(ctx context.Context, version *MarketplaceListingVersion) error
+	GetLatestMarketplaceListingVersion(ctx context.Context, listingID string) (*MarketplaceListingVersion, error)
+	CreateMarketplaceInstall(ctx context.Context, install *MarketplaceInstall) error
+	GetMarketplaceInstall(ctx context.Context, id string) (*MarketplaceInstall, error)
+	GetCurrentMarketplaceInstall(ctx context.Context, listingID, userID string) (*MarketplaceInstall, error)
+	UpdateMarketplaceInstall(ctx context.Context, install *MarketplaceInstall) error
+	CountMarketplaceInstalls(ctx context.Context, listingID string) (int, error)
+	resolveMarketplaceListing(ctx context.Context, ref string) (*MarketplaceListing, error)
+	ListMarketplaceListings(ctx context.Context, scope, userID, workspaceID string) ([]MarketplaceListingSummary, error)
+	BuildMarketplaceListingDetail(ctx context.Context, ref, userID, workspaceID string) (*MarketplaceListingDetail, error)
+
+	// Media sync
+	ListMediaSyncEntries(ctx context.Context, collectionID string, filenames []string) ([]MediaSyncEntry, error)
+
+	// Bulk note deletion
+	CountCardsForNotes(ctx context.Context, noteIDs []int64) (int, error)
+	DeleteNotesWithCards(ctx context.Context, noteIDs []int64) ([]*Card, error)
+
+	// Note listing
+	ListNotesPage(ctx context.Context, collectionID string, filter NoteListFilter, offset, limit int) ([]Note, int, error)
+
+	// Note type management
+	DeleteNoteType(ctx context.Context, collectionID string, name NoteTypeName) error
+	RenameNoteType(ctx context.Context, collectionID string, from, to NoteTypeName, now time.Time) error
+
+	// Operation log
+	AppendOperation(ctx context.Context, collectionID, userID string, op *Operation) (bool, error)
+	ListOperations(ctx context.Context, collectionID string, after int64, limit int) ([]Operation, error)
+	ListOperationsForTarget(ctx context.Context, collectionID, target string) ([]Operation, error)
+	ListPendingCreateTargets(ctx context.Context, collectionID string) ([]string, error)
+	OperationLogHead(ctx context.Context, collectionID string) (int64, int64, error)
+	ResolveOperationRef(ctx context.Context, collectionID, ref string) (int64, error)
+	OperationRefForEntity(ctx context.Context, collectionID, entityType string, id int64) (string, error)
+	SaveOperationRef(ctx context.Context, collectionID, ref, entityType string, id int64) error
+
+	// Review log export
+	EachRevlogEntry(ctx context.Context, userID, collectionID string, deckID int64, since time.Time, fn func(entry RevlogEntry, noteID, deckID int64) error) error
+
+	// Search
+	queryIDs(ctx context.Context, sqlQuery string, args ...interface{}) ([]int64, error)
+	SearchNoteIDs(ctx context.Context, collectionID, userID, query string) ([]int64, error)
+	SearchCardIDs(ctx context.Context, collectionID, userID, query string) ([]int64, error)
+
+	// Collections, decks, cards, and media beyond the basics
+	CreateCollectionRecord(ctx context.Context, collectionID, name string, c *Collection) error
+	UpdateCollectionByID(ctx context.Context, collectionID string, c *Collection) error
+	CreateDeckInCollection(ctx context.Context, collectionID string, d *Deck) error
+	DeleteDeckAndCards(ctx context.Context, deckID int64, targetDeckID *int64) ([]*Card, []int64, error)
+	GetDeckOptions(ctx context.Context, id int64) (*DeckOptions, error)
+	CreateDeckOptions(ctx context.Context, options *DeckOptions) error
+	UpdateDeckOptions(ctx context.Context, options *DeckOptions) error
+	EnsureDeckOptionsForDeck(ctx context.Context, deck *Deck) (*DeckOptions, error)
+	GetNotesByType(ctx context.Context, collectionID string, noteTypeName string) ([]Note, error)
+	GetCardsByNote(ctx context.Context, noteID int64) ([]Card, error)
+	applyReviewStateToCard(ctx context.Context, userID string, card *Card) error
+	getDeckDailyLimits(ctx context.Context, deckID int64) (int, int, error)
+	GetDueCardsForUserFiltered(ctx context.Context, userID string, deckID int64, limit int, filter DueCardFilter) ([]*Card, error)
+	GetSubtreeDueCardsForUser(ctx context.Context, userID string, deckID int64, limit int) ([]*Card, error)
+	ListRevlogEntriesForCard(ctx context.Context, userID string, cardID int64, limit int) ([]RevlogEntry, error)
+	GetCollectionMedia(ctx context.Context, collectionID, filename string) (*MediaRef, error)
+	ListMediaFilenames(ctx context.Context, collectionID string) ([]string, error)
+	DeleteCollectionMedia(ctx context.Context, collectionID string, filenames []string) (int64, error)
+	MarkUnusedMedia(ctx context.Context, collectionID string, unused map[string]bool, now time.Time) error
+	DeleteMediaUnusedBefore(ctx context.Context, collectionID string, cutoff time.Time) ([]string, error)
+	ListCollectionIDs(ctx context.Context) ([]string, error)
+
+	// Study analytics
+	GetStudyAnalyticsOverview(ctx context.Context, userID, workspaceID string) (StudyAnalyticsOverview, error)
+	GetDeckStudyAnalyticsSummary(ctx context.Context, userID, workspaceID string) (map[int64]DeckStudyAnalytics, error)
+
+	// Study days
+	studyDay(ctx context.Context, collectionID string, now time.Time) (time.Time, time.Time, error)
+
+	// Study group leaderboards
+	SetStudyGroupLeaderboardOptIn(ctx context.Context, memberID string, optIn bool) error
+	GetStudyGroupLeaderboardOptIn(ctx context.Context, memberID string) (bool, error)
+	GetStudyGroupLeaderboard(ctx context.Context, groupID string, now time.Time) ([]StudyGroupLeaderboardStanding, error)
+
+	// Study groups
+	GetWorkspaceForUser(ctx context.Context, userID, workspaceID string) (*Workspace, error)
+	GetDeckCollectionID(ctx context.Context, deckID int64) (string, error)
+	GetDeckContentSummary(ctx context.Context, deckID int64) (noteCount, cardCount int, err error)
+	CreateStudyGroup(ctx context.Context, group *StudyGroup) error
+	GetStudyGroup(ctx context.Context, id string) (*StudyGroup, error)
+	UpdateStudyGroup(ctx context.Context, group *StudyGroup) error
+	DeleteStudyGroup(ctx context.Context, id string) error
+	CreateStudyGroupMember(ctx context.Context, member *StudyGroupMember) error
+	UpsertStudyGroupInvitation(ctx context.Context, member *StudyGroupMember) error
+	GetStudyGroupMember(ctx context.Context, id string) (*StudyGroupMember, error)
+	GetStudyGroupMemberByGroupAndEmail(ctx context.Context, groupID, email string) (*StudyGroupMember, error)
+	GetStudyGroupMemberByInviteToken(ctx context.Context, token string) (*StudyGroupMember, error)
+	UpdateStudyGroupMember(ctx context.Context, member *StudyGroupMember) error
+	CreateStudyGroupVersion(ctx context.Context, version *StudyGroupVersion) error
+	GetLatestStudyGroupVersion(ctx context.Context, groupID string) (*StudyGroupVersion, error)
+	CreateStudyGroupInstall(ctx context.Context, install *StudyGroupInstall) error
+	GetStudyGroupInstall(ctx context.Context, id string) (*StudyGroupInstall, error)
+	GetCurrentStudyGroupInstall(ctx context.Context, groupID, memberID string) (*StudyGroupInstall, error)
+	UpdateStudyGroupInstall(ctx context.Context, install *StudyGroupInstall) error
+	MarkStudyGroupInstallForkedByDeckID(ctx context.Context, deckID int64) error
+	MarkStudyGroupInstallsForkedByNoteType(ctx context.Context, collectionID string, noteTypeName string) error
+	CreateStudyGroupEvent(ctx context.Context, event *StudyGroupEvent) error
+	GetStudyGroupDashboard(ctx context.Context, groupID string) (StudyGroupDashboard, error)
+	ListStudyGroupSummariesForUser(ctx context.Context, userID string) ([]StudyGroupSummary, error)
+	getStudyGroupMembership(ctx context.Context, groupID, userID, email string) (*StudyGroupMember, error)
+	BuildStudyGroupDetail(ctx context.Context, groupID, userID string) (*StudyGroupDetail, error)
+	CopyDeckToCollection(ctx context.Context, sourceDeckID int64, destinationCollectionID, installedDeckName string) (*Deck, error)
+	DeleteCopiedDeck(ctx context.Context, deckID int64) error
+	encodeStudyGroupEventPayload(payload any) string
+
+	// Study session history
+	ListStudySessionsForUser(ctx context.Context, userID string) ([]StudySession, error)
+
+	// Sync
+	LatestChangeUSN(ctx context.Context, collectionID string) (int64, error)
+	GetRevlogEntry(ctx context.Context, id int64) (*RevlogEntry, error)
+	HasRevlogEntry(ctx context.Context, userID string, cardID int64, reviewedAt time.Time) (bool, error)
+
+	// Tags
+	ListTagTree(ctx context.Context, collectionID string) ([]TagNode, error)
+	RenameTag(ctx context.Context, collectionID, from, to string, now time.Time) (map[int64][]string, error)
+
+	// Today's statistics
+	GetTodayStats(ctx context.Context, userID, collectionID string, dayStart, dayEnd time.Time) (TodayStats, error)
+
+	// Vacation mode
+	RescheduleVacationBacklog(ctx context.Context, collectionID string, startsAt, returnAt time.Time, spreadDays int) (int, error)
+
+	// Workload forecast
+	ListCardMemoryStates(ctx context.Context, userID, collectionID string, deckID int64) ([]fsrs.Card, error)
 
 	// Close database connection
 	Close() error
 }
 
-// SQLiteStore implements Store using SQLite as the backend.
-type SQLiteStore struct {
-	db *sql.DB
+// SQLStore implements Store on a SQL database. Its queries keep to the SQL that SQLite
+// and PostgreSQL share and take what differs from dialect, so the SQLite store,
+// MemoryStore and PostgresStore all run this code.
+type SQLStore struct {
+	db      *sql.DB
+	dialect sqlDialect
 }
 
 func noteTypeRecordID(collectionID string, name NoteTypeName) string {
@@ -109,36 +430,82 @@ func noteTypeNameFromRecordID(raw string) NoteTypeName {
 const (
 	defaultNewCardsPerDay = 20
 	defaultReviewsPerDay  = 200
+
+	sqliteBusyTimeout = 5 * time.Second
+	// The pool keeps this many connections open between requests, rather than
+	// database/sql's default of two, so concurrent requests do not reopen the file.
+	sqliteMaxIdleConns    = 16
+	sqliteConnMaxIdleTime = 5 * time.Minute
 )
 
 // NewSQLiteStore creates a new SQLite store and runs migrations.
-func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
-	return OpenStore(DatabaseConfig{
+func NewSQLiteStore(dbPath string) (*SQLStore, error) {
+	return openSQLiteStore(DatabaseConfig{
 		Mode: DatabaseModeSQLite,
 		Path: dbPath,
 	})
 }
 
-func OpenStore(cfg DatabaseConfig) (*SQLiteStore, error) {
-	driverName, dsn, err := databaseDSN(cfg)
+// OpenStore opens the database cfg names and brings its schema up to date.
+func OpenStore(cfg DatabaseConfig) (Store, error) {
+	if cfg.Mode == DatabaseModePostgres {
+		store, err := OpenPostgresStore(strings.TrimSpace(cfg.URL))
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+	store, err := openSQLiteStore(cfg)
 	if err != nil {
 		return nil, err
 	}
+	return store, nil
+}
 
-	db, err := sql.Open(driverName, dsn)
+// openSQLiteStore opens a SQLite file or a Turso database.
+func openSQLiteStore(cfg DatabaseConfig) (*SQLStore, error) {
+	driverName, dsn, err := databaseDSN(cfg)
 	if err != nil {
+		return nil, err
+	}
+
+	var db *sql.DB
+	if cfg.Key != "" {
+		db = openEncryptedSQLite(strings.TrimSpace(cfg.Path), cfg.Key)
+	} else if db, err = sql.Open(driverName, dsn); err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	if driverName == "sqlite3" {
+		configureSQLitePool(db)
+	}
+	return newSQLStore(db, dialectSQLite)
+}
+
+// configureSQLitePool sizes a SQLite connection pool. There is no cap on open
+// connections: the store runs queries while reading the rows of others, so a capped
+// pool can deadlock with every connection waiting for one more. SQLite's write lock,
+// with the busy timeout, is what orders writers.
+func configureSQLitePool(db *sql.DB) {
+	db.SetMaxOpenConns(0)
+	db.SetMaxIdleConns(sqliteMaxIdleConns)
+	db.SetConnMaxIdleTime(sqliteConnMaxIdleTime)
+}
+
+// newSQLStore checks the connection to db, a database speaking dialect, and brings
+// its schema up to date, closing db if either fails.
+func newSQLStore(db *sql.DB, dialect sqlDialect) (*SQLStore, error) {
 	// Test connection
 	if err := db.Ping(); err != nil {
+		_ = db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	store := &SQLiteStore{db: db}
+	store := &SQLStore{db: db, dialect: dialect}
 
 	// Run migrations
 	if err := store.migrate(); err != nil {
+		_ = db.Close()
 		return nil, fmt.Errorf("migration failed: %w", err)
 	}
 
@@ -163,54 +530,116 @@ func databaseDSN(cfg DatabaseConfig) (string, string, error) {
 	case DatabaseModeSQLite, "":
 		dbPath := strings.TrimSpace(cfg.Path)
 		if dbPath == "" {
-			dbPath = "./data/microdote.db"
+			dbPath = defaultDatabasePath
 		}
-		return "sqlite3", dbPath + "?_foreign_keys=on", nil
+		return "sqlite3", sqliteDSN(dbPath, true), nil
 	default:
 		return "", "", fmt.Errorf("unsupported database mode: %s", cfg.Mode)
 	}
 }
 
-func (s *SQLiteStore) Close() error {
+// sqliteDSN adds the connection settings to a SQLite file path: a busy timeout, so a
+// connection waits out another's write instead of failing with "database is locked",
+// and transactions that take the write lock when they begin, since a busy timeout
+// cannot help one that holds a read lock and then wants to write. With pragmas it also
+// turns on foreign keys and the WAL journal, which lets readers work alongside a
+// writer. An encrypted database cannot be read before it is keyed, so keySQLiteConn
+// sets those itself.
+func sqliteDSN(path string, pragmas bool) string {
+	dsn := fmt.Sprintf("%s?_busy_timeout=%d&_txlock=immediate", path, sqliteBusyTimeout.Milliseconds())
+	if pragmas {
+		dsn += "&_foreign_keys=on&_journal_mode=WAL"
+	}
+	return dsn
+}
+
+// checkpoint moves the WAL's writes into the database file and empties the WAL, so the
+// file alone holds everything committed. PostgreSQL has no file to complete.
+func (s *SQLStore) checkpoint(ctx context.Context) error {
+	if s.dialect == dialectPostgres {
+		return nil
+	}
+	_, err := s.db.ExecContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`)
+	return err
+}
+
+func (s *SQLStore) Close() error {
 	return s.db.Close()
 }
 
 // Transaction methods
-func (s *SQLiteStore) BeginTx() (*sql.Tx, error) {
-	return s.db.Begin()
+func (s *SQLStore) BeginTx(ctx context.Context) (*sql.Tx, error) {
+	return s.db.BeginTx(ctx, nil)
 }
 
-func (s *SQLiteStore) CommitTx(tx *sql.Tx) error {
+func (s *SQLStore) CommitTx(tx *sql.Tx) error {
 	return tx.Commit()
 }
 
-func (s *SQLiteStore) RollbackTx(tx *sql.Tx) error {
+func (s *SQLStore) RollbackTx(tx *sql.Tx) error {
 	return tx.Rollback()
 }
 
+// maxTxAttempts bounds how often inTx runs a transaction the database keeps aborting.
+const maxTxAttempts = 5
+
+// inTx runs fn in a transaction and commits it. PostgreSQL runs the store's
+// transactions serializable and aborts one that conflicts with another running
+// alongside it; inTx then waits briefly and runs fn again from the start, so fn must
+// set its results afresh on each call rather than add to what an earlier call left.
+// SQLite locks the database for a writer, so there fn runs once.
+func (s *SQLStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	for attempt := 1; ; attempt++ {
+		err := s.runTx(ctx, fn)
+		if err == nil || attempt == maxTxAttempts || !s.dialect.retryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt*attempt) * 10 * time.Millisecond):
+		}
+	}
+}
+
+func (s *SQLStore) runTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 // Collection methods
-func (s *SQLiteStore) CreateCollection(c *Collection) error {
-	return s.CreateCollectionRecord("default", "Default Collection", c)
+func (s *SQLStore) CreateCollection(ctx context.Context, c *Collection) error {
+	return s.CreateCollectionRecord(ctx, c.ID, c.Name, c)
 }
 
-func (s *SQLiteStore) CreateCollectionRecord(collectionID, name string, c *Collection) error {
+func (s *SQLStore) CreateCollectionRecord(ctx context.Context, collectionID, name string, c *Collection) error {
 	query := `
 		INSERT INTO collections (id, name, usn, last_sync, created_at)
 		VALUES (?, ?, ?, ?, ?)
 	`
 	if strings.TrimSpace(collectionID) == "" {
-		collectionID = "default"
+		collectionID = defaultCollectionID
 	}
 	if strings.TrimSpace(name) == "" {
 		name = "Default Collection"
 	}
-	_, err := s.db.Exec(query, collectionID, name, c.USN, c.LastSync.Unix(), time.Now().Unix())
-	return err
+	if _, err := s.db.ExecContext(ctx, query, collectionID, name, c.USN, c.LastSync.Unix(), time.Now().Unix()); err != nil {
+		return err
+	}
+	c.ID, c.Name = collectionID, name
+	return nil
 }
 
-func (s *SQLiteStore) GetCollection(id string) (*Collection, error) {
+func (s *SQLStore) GetCollection(ctx context.Context, id string) (*Collection, error) {
 	query := `SELECT id, name, usn, last_sync, created_at FROM collections WHERE id = ?`
-	row := s.db.QueryRow(query, id)
+	row := s.db.QueryRowContext(ctx, query, id)
 
 	var name string
 	var usn int64
@@ -223,27 +652,28 @@ func (s *SQLiteStore) GetCollection(id string) (*Collection, error) {
 
 	// Load collection data
 	col := NewCollection()
+	col.ID, col.Name = id, name
 	col.USN = usn
 	if lastSync > 0 {
 		col.LastSync = time.Unix(lastSync, 0)
 	}
 
 	// Load note types
-	noteTypes, err := s.ListNoteTypes(id)
+	noteTypes, err := s.ListNoteTypes(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	col.NoteTypes = noteTypes
 
 	// Load notes
-	notes, err := s.ListNotes(id)
+	notes, err := s.ListNotes(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	col.Notes = notes
 
 	// Load decks (which will load their cards)
-	decks, err := s.ListDecks(id)
+	decks, err := s.ListDecks(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -255,7 +685,7 @@ func (s *SQLiteStore) GetCollection(id string) (*Collection, error) {
 	// Load all cards
 	col.Cards = make(map[int64]*Card)
 	for _, deck := range decks {
-		cards, err := s.ListCardsInDeck(deck.ID)
+		cards, err := s.ListCardsInDeck(ctx, deck.ID)
 		if err != nil {
 			return nil, err
 		}
@@ -265,61 +695,61 @@ func (s *SQLiteStore) GetCollection(id string) (*Collection, error) {
 	}
 
 	// Initialize ID counters based on existing data
-	col.nextDeckID = s.getMaxID("decks") + 1
-	col.nextNoteID = s.getMaxID("notes") + 1
-	col.nextCardID = s.getMaxID("cards") + 1
+	col.nextDeckID = s.getMaxID(ctx, "decks") + 1
+	col.nextNoteID = s.getMaxID(ctx, "notes") + 1
+	col.nextCardID = s.getMaxID(ctx, "cards") + 1
 
 	return col, nil
 }
 
 // getMaxID returns the maximum ID from a table, or 0 if table is empty
-func (s *SQLiteStore) getMaxID(tableName string) int64 {
+func (s *SQLStore) getMaxID(ctx context.Context, tableName string) int64 {
 	var maxID sql.NullInt64
 	query := fmt.Sprintf("SELECT MAX(id) FROM %s", tableName)
-	s.db.QueryRow(query).Scan(&maxID)
+	s.db.QueryRowContext(ctx, query).Scan(&maxID)
 	if maxID.Valid {
 		return maxID.Int64
 	}
 	return 0
 }
 
-func (s *SQLiteStore) UpdateCollection(c *Collection) error {
-	return s.UpdateCollectionByID("default", c)
+func (s *SQLStore) UpdateCollection(ctx context.Context, c *Collection) error {
+	return s.UpdateCollectionByID(ctx, c.ID, c)
 }
 
-func (s *SQLiteStore) UpdateCollectionByID(collectionID string, c *Collection) error {
+func (s *SQLStore) UpdateCollectionByID(ctx context.Context, collectionID string, c *Collection) error {
 	query := `UPDATE collections SET usn = ?, last_sync = ? WHERE id = ?`
 	if strings.TrimSpace(collectionID) == "" {
-		collectionID = "default"
+		collectionID = defaultCollectionID
 	}
-	_, err := s.db.Exec(query, c.USN, c.LastSync.Unix(), collectionID)
+	_, err := s.db.ExecContext(ctx, query, c.USN, c.LastSync.Unix(), collectionID)
 	return err
 }
 
 // Deck methods
-func (s *SQLiteStore) CreateDeck(d *Deck) error {
-	return s.CreateDeckInCollection("default", d)
+func (s *SQLStore) CreateDeck(ctx context.Context, d *Deck) error {
+	return s.CreateDeckInCollection(ctx, defaultCollectionID, d)
 }
 
-func (s *SQLiteStore) CreateDeckInCollection(collectionID string, d *Deck) error {
+func (s *SQLStore) CreateDeckInCollection(ctx context.Context, collectionID string, d *Deck) error {
 	query := `
 		INSERT INTO decks (id, collection_id, name, parent_id, options_id, priority_order)
 		VALUES (?, ?, ?, ?, ?, ?)
 	`
 	if strings.TrimSpace(collectionID) == "" {
-		collectionID = "default"
+		collectionID = defaultCollectionID
 	}
 	priorityOrder := d.PriorityOrder
 	if priorityOrder <= 0 {
 		priorityOrder = int(d.ID)
 	}
-	_, err := s.db.Exec(query, d.ID, collectionID, d.Name, d.ParentID, d.OptionsID, priorityOrder)
+	_, err := s.db.ExecContext(ctx, query, d.ID, collectionID, d.Name, d.ParentID, d.OptionsID, priorityOrder)
 	return err
 }
 
-func (s *SQLiteStore) GetDeck(id int64) (*Deck, error) {
+func (s *SQLStore) GetDeck(ctx context.Context, id int64) (*Deck, error) {
 	query := `SELECT id, name, parent_id, options_id, priority_order FROM decks WHERE id = ?`
-	row := s.db.QueryRow(query, id)
+	row := s.db.QueryRowContext(ctx, query, id)
 
 	var deck Deck
 	var parentID, optionsID sql.NullInt64
@@ -346,7 +776,7 @@ func (s *SQLiteStore) GetDeck(id int64) (*Deck, error) {
 
 	// Load card IDs for this deck
 	cardQuery := `SELECT id FROM cards WHERE deck_id = ? ORDER BY id`
-	rows, err := s.db.Query(cardQuery, id)
+	rows, err := s.db.QueryContext(ctx, cardQuery, id)
 	if err != nil {
 		return nil, err
 	}
@@ -364,26 +794,108 @@ func (s *SQLiteStore) GetDeck(id int64) (*Deck, error) {
 	return &deck, nil
 }
 
-func (s *SQLiteStore) UpdateDeck(d *Deck) error {
+func (s *SQLStore) UpdateDeck(ctx context.Context, d *Deck) error {
 	priorityOrder := d.PriorityOrder
 	if priorityOrder <= 0 {
 		priorityOrder = int(d.ID)
 	}
 	query := `UPDATE decks SET name = ?, parent_id = ?, options_id = ?, priority_order = ? WHERE id = ?`
-	_, err := s.db.Exec(query, d.Name, d.ParentID, d.OptionsID, priorityOrder, d.ID)
+	_, err := s.db.ExecContext(ctx, query, d.Name, d.ParentID, d.OptionsID, priorityOrder, d.ID)
 	return err
 }
 
-func (s *SQLiteStore) DeleteDeck(id int64) error {
-	// In a full implementation, handle card deletion or moving to another deck
+// DeleteDeck removes an empty deck row. Use DeleteDeckAndCards for decks with cards.
+func (s *SQLStore) DeleteDeck(ctx context.Context, id int64) error {
 	query := `DELETE FROM decks WHERE id = ?`
-	_, err := s.db.Exec(query, id)
+	_, err := s.db.ExecContext(ctx, query, id)
 	return err
 }
 
-func (s *SQLiteStore) ListDecks(collectionID string) ([]*Deck, error) {
+// DeleteDeckAndCards removes a deck in one transaction. When targetDeckID is set the
+// deck's cards move there first; otherwise they are deleted with their review history,
+// along with any notes left without cards. It returns the deck's cards (with DeckID
+// updated when moved) and the IDs of deleted notes.
+func (s *SQLStore) DeleteDeckAndCards(ctx context.Context, deckID int64, targetDeckID *int64) ([]*Card, []int64, error) {
+	var (
+		cards          []*Card
+		deletedNoteIDs []int64
+	)
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT id, note_id FROM cards WHERE deck_id = ? ORDER BY id`, deckID)
+		if err != nil {
+			return err
+		}
+		cards = nil
+		for rows.Next() {
+			card := &Card{DeckID: deckID}
+			if err := rows.Scan(&card.ID, &card.NoteID); err != nil {
+				rows.Close()
+				return err
+			}
+			cards = append(cards, card)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		deletedNoteIDs = nil
+		if targetDeckID != nil {
+			if _, err := tx.ExecContext(ctx, `UPDATE cards SET deck_id = ? WHERE deck_id = ?`, *targetDeckID, deckID); err != nil {
+				return err
+			}
+			for _, card := range cards {
+				card.DeckID = *targetDeckID
+			}
+		} else {
+			noteRows, err := tx.QueryContext(ctx, `
+				SELECT DISTINCT note_id FROM cards
+				WHERE deck_id = ?
+				  AND note_id NOT IN (SELECT note_id FROM cards WHERE deck_id != ?)
+			`, deckID, deckID)
+			if err != nil {
+				return err
+			}
+			for noteRows.Next() {
+				var noteID int64
+				if err := noteRows.Scan(&noteID); err != nil {
+					noteRows.Close()
+					return err
+				}
+				deletedNoteIDs = append(deletedNoteIDs, noteID)
+			}
+			noteRows.Close()
+			if err := noteRows.Err(); err != nil {
+				return err
+			}
+
+			if _, err := tx.ExecContext(ctx, `DELETE FROM revlog WHERE card_id IN (SELECT id FROM cards WHERE deck_id = ?)`, deckID); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, `DELETE FROM cards WHERE deck_id = ?`, deckID); err != nil {
+				return err
+			}
+			for _, noteID := range deletedNoteIDs {
+				if _, err := tx.ExecContext(ctx, `DELETE FROM notes WHERE id = ?`, noteID); err != nil {
+					return err
+				}
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM decks WHERE id = ?`, deckID); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return cards, deletedNoteIDs, nil
+}
+
+func (s *SQLStore) ListDecks(ctx context.Context, collectionID string) ([]*Deck, error) {
 	query := `SELECT id FROM decks WHERE collection_id = ? ORDER BY priority_order ASC, id ASC`
-	rows, err := s.db.Query(query, collectionID)
+	rows, err := s.db.QueryContext(ctx, query, collectionID)
 	if err != nil {
 		return nil, err
 	}
@@ -395,7 +907,7 @@ func (s *SQLiteStore) ListDecks(collectionID string) ([]*Deck, error) {
 		if err := rows.Scan(&deckID); err != nil {
 			return nil, err
 		}
-		deck, err := s.GetDeck(deckID)
+		deck, err := s.GetDeck(ctx, deckID)
 		if err != nil {
 			return nil, err
 		}
@@ -405,16 +917,19 @@ func (s *SQLiteStore) ListDecks(collectionID string) ([]*Deck, error) {
 	return decks, nil
 }
 
-func (s *SQLiteStore) GetDeckOptions(id int64) (*DeckOptions, error) {
-	row := s.db.QueryRow(`
-		SELECT id, name, new_cards_per_day, reviews_per_day, learning_steps, graduating_interval, easy_interval
+func (s *SQLStore) GetDeckOptions(ctx context.Context, id int64) (*DeckOptions, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT id, name, new_cards_per_day, reviews_per_day, learning_steps, graduating_interval, easy_interval,
+			relearning_steps, minimum_interval, new_interval_percent, leech_threshold, leech_action,
+			scheduler, starting_ease, easy_bonus, interval_modifier, review_order
 		FROM deck_options
 		WHERE id = ?
 	`, id)
 
 	var (
-		options       DeckOptions
-		learningSteps sql.NullString
+		options         DeckOptions
+		learningSteps   sql.NullString
+		relearningSteps sql.NullString
 	)
 	if err := row.Scan(
 		&options.ID,
@@ -424,6 +939,16 @@ func (s *SQLiteStore) GetDeckOptions(id int64) (*DeckOptions, error) {
 		&learningSteps,
 		&options.GraduatingInterval,
 		&options.EasyInterval,
+		&relearningSteps,
+		&options.MinimumInterval,
+		&options.NewIntervalPercent,
+		&options.LeechThreshold,
+		&options.LeechAction,
+		&options.Scheduler,
+		&options.StartingEase,
+		&options.EasyBonus,
+		&options.IntervalModifier,
+		&options.ReviewOrder,
 	); err != nil {
 		return nil, err
 	}
@@ -431,44 +956,53 @@ func (s *SQLiteStore) GetDeckOptions(id int64) (*DeckOptions, error) {
 	if learningSteps.Valid && strings.TrimSpace(learningSteps.String) != "" {
 		_ = json.Unmarshal([]byte(learningSteps.String), &options.LearningSteps)
 	}
+	if relearningSteps.Valid && strings.TrimSpace(relearningSteps.String) != "" {
+		_ = json.Unmarshal([]byte(relearningSteps.String), &options.RelearningSteps)
+	}
 
 	return &options, nil
 }
 
-func (s *SQLiteStore) CreateDeckOptions(options *DeckOptions) error {
-	stepsJSON := "[]"
-	if len(options.LearningSteps) > 0 {
-		if encoded, err := json.Marshal(options.LearningSteps); err == nil {
-			stepsJSON = string(encoded)
+func learningStepsJSON(steps []int) string {
+	if len(steps) > 0 {
+		if encoded, err := json.Marshal(steps); err == nil {
+			return string(encoded)
 		}
 	}
+	return "[]"
+}
 
-	_, err := s.db.Exec(`
-		INSERT INTO deck_options (id, name, new_cards_per_day, reviews_per_day, learning_steps, graduating_interval, easy_interval)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, options.ID, options.Name, options.NewCardsPerDay, options.ReviewsPerDay, stepsJSON, options.GraduatingInterval, options.EasyInterval)
+func (s *SQLStore) CreateDeckOptions(ctx context.Context, options *DeckOptions) error {
+	stepsJSON := learningStepsJSON(options.LearningSteps)
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO deck_options (id, name, new_cards_per_day, reviews_per_day, learning_steps, graduating_interval, easy_interval,
+			relearning_steps, minimum_interval, new_interval_percent, leech_threshold, leech_action, scheduler, starting_ease, easy_bonus, interval_modifier, review_order)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, options.ID, options.Name, options.NewCardsPerDay, options.ReviewsPerDay, stepsJSON, options.GraduatingInterval, options.EasyInterval,
+		learningStepsJSON(options.RelearningSteps), options.MinimumInterval, options.NewIntervalPercent, options.LeechThreshold, leechActionOrDefault(options.LeechAction),
+		schedulerOrDefault(options.Scheduler), options.StartingEase, options.EasyBonus, options.IntervalModifier, reviewOrderOrDefault(options.ReviewOrder))
 	return err
 }
 
-func (s *SQLiteStore) UpdateDeckOptions(options *DeckOptions) error {
-	stepsJSON := "[]"
-	if len(options.LearningSteps) > 0 {
-		if encoded, err := json.Marshal(options.LearningSteps); err == nil {
-			stepsJSON = string(encoded)
-		}
-	}
+func (s *SQLStore) UpdateDeckOptions(ctx context.Context, options *DeckOptions) error {
+	stepsJSON := learningStepsJSON(options.LearningSteps)
 
-	_, err := s.db.Exec(`
+	_, err := s.db.ExecContext(ctx, `
 		UPDATE deck_options
-		SET name = ?, new_cards_per_day = ?, reviews_per_day = ?, learning_steps = ?, graduating_interval = ?, easy_interval = ?
+		SET name = ?, new_cards_per_day = ?, reviews_per_day = ?, learning_steps = ?, graduating_interval = ?, easy_interval = ?,
+			relearning_steps = ?, minimum_interval = ?, new_interval_percent = ?, leech_threshold = ?, leech_action = ?,
+			scheduler = ?, starting_ease = ?, easy_bonus = ?, interval_modifier = ?, review_order = ?
 		WHERE id = ?
-	`, options.Name, options.NewCardsPerDay, options.ReviewsPerDay, stepsJSON, options.GraduatingInterval, options.EasyInterval, options.ID)
+	`, options.Name, options.NewCardsPerDay, options.ReviewsPerDay, stepsJSON, options.GraduatingInterval, options.EasyInterval,
+		learningStepsJSON(options.RelearningSteps), options.MinimumInterval, options.NewIntervalPercent, options.LeechThreshold, leechActionOrDefault(options.LeechAction),
+		schedulerOrDefault(options.Scheduler), options.StartingEase, options.EasyBonus, options.IntervalModifier, reviewOrderOrDefault(options.ReviewOrder), options.ID)
 	return err
 }
 
-func (s *SQLiteStore) EnsureDeckOptionsForDeck(deck *Deck) (*DeckOptions, error) {
+func (s *SQLStore) EnsureDeckOptionsForDeck(ctx context.Context, deck *Deck) (*DeckOptions, error) {
 	if deck.OptionsID != nil {
-		options, err := s.GetDeckOptions(*deck.OptionsID)
+		options, err := s.GetDeckOptions(ctx, *deck.OptionsID)
 		if err == nil {
 			return options, nil
 		}
@@ -486,19 +1020,28 @@ func (s *SQLiteStore) EnsureDeckOptionsForDeck(deck *Deck) (*DeckOptions, error)
 		LearningSteps:      []int{},
 		GraduatingInterval: 1,
 		EasyInterval:       4,
-	}
-	if err := s.CreateDeckOptions(options); err != nil {
+		RelearningSteps:    defaultRelearningSteps,
+		MinimumInterval:    1,
+		LeechThreshold:     defaultLeechThreshold,
+		LeechAction:        leechActionTag,
+		Scheduler:          schedulerFSRS,
+		StartingEase:       defaultStartingEase,
+		EasyBonus:          defaultEasyBonus,
+		IntervalModifier:   defaultIntervalModifier,
+		ReviewOrder:        reviewOrderDue,
+	}
+	if err := s.CreateDeckOptions(ctx, options); err != nil {
 		return nil, err
 	}
 	deck.OptionsID = &options.ID
-	if err := s.UpdateDeck(deck); err != nil {
+	if err := s.UpdateDeck(ctx, deck); err != nil {
 		return nil, err
 	}
 	return options, nil
 }
 
 // Note Type methods
-func (s *SQLiteStore) CreateNoteType(collectionID string, nt *NoteType) error {
+func (s *SQLStore) CreateNoteType(ctx context.Context, collectionID string, nt *NoteType) error {
 	fieldsJSON, err := json.Marshal(nt.Fields)
 	if err != nil {
 		return err
@@ -521,13 +1064,13 @@ func (s *SQLiteStore) CreateNoteType(collectionID string, nt *NoteType) error {
 		INSERT INTO note_types (id, collection_id, name, fields, templates, sort_field_index, field_options)
 		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err = s.db.Exec(query, noteTypeRecordID(collectionID, nt.Name), collectionID, string(nt.Name), fieldsJSON, templatesJSON, nt.SortFieldIndex, fieldOptionsJSON)
+	_, err = s.db.ExecContext(ctx, query, noteTypeRecordID(collectionID, nt.Name), collectionID, string(nt.Name), fieldsJSON, templatesJSON, nt.SortFieldIndex, fieldOptionsJSON)
 	return err
 }
 
-func (s *SQLiteStore) GetNoteType(collectionID string, name NoteTypeName) (*NoteType, error) {
+func (s *SQLStore) GetNoteType(ctx context.Context, collectionID string, name NoteTypeName) (*NoteType, error) {
 	query := `SELECT name, fields, templates, sort_field_index, field_options FROM note_types WHERE collection_id = ? AND name = ?`
-	row := s.db.QueryRow(query, collectionID, string(name))
+	row := s.db.QueryRowContext(ctx, query, collectionID, string(name))
 
 	var ntName string
 	var fieldsJSON, templatesJSON []byte
@@ -564,7 +1107,7 @@ func (s *SQLiteStore) GetNoteType(collectionID string, name NoteTypeName) (*Note
 	}, nil
 }
 
-func (s *SQLiteStore) UpdateNoteType(collectionID string, nt *NoteType) error {
+func (s *SQLStore) UpdateNoteType(ctx context.Context, collectionID string, nt *NoteType) error {
 	fieldsJSON, err := json.Marshal(nt.Fields)
 	if err != nil {
 		return err
@@ -588,13 +1131,13 @@ func (s *SQLiteStore) UpdateNoteType(collectionID string, nt *NoteType) error {
 		SET fields = ?, templates = ?, sort_field_index = ?, field_options = ?
 		WHERE collection_id = ? AND name = ?
 	`
-	_, err = s.db.Exec(query, fieldsJSON, templatesJSON, nt.SortFieldIndex, fieldOptionsJSON, collectionID, string(nt.Name))
+	_, err = s.db.ExecContext(ctx, query, fieldsJSON, templatesJSON, nt.SortFieldIndex, fieldOptionsJSON, collectionID, string(nt.Name))
 	return err
 }
 
-func (s *SQLiteStore) ListNoteTypes(collectionID string) (map[NoteTypeName]NoteType, error) {
+func (s *SQLStore) ListNoteTypes(ctx context.Context, collectionID string) (map[NoteTypeName]NoteType, error) {
 	query := `SELECT name, fields, templates, sort_field_index, field_options FROM note_types WHERE collection_id = ?`
-	rows, err := s.db.Query(query, collectionID)
+	rows, err := s.db.QueryContext(ctx, query, collectionID)
 	if err != nil {
 		return nil, err
 	}
@@ -639,8 +1182,33 @@ func (s *SQLiteStore) ListNoteTypes(collectionID string) (map[NoteTypeName]NoteT
 	return noteTypes, nil
 }
 
+// sqlExecer is what the insert helpers need of a *sql.DB or *sql.Tx.
+type sqlExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
 // Note methods
-func (s *SQLiteStore) CreateNote(collectionID string, n *Note) error {
+func (s *SQLStore) CreateNote(ctx context.Context, collectionID string, n *Note) error {
+	return insertNote(ctx, s.db, collectionID, n)
+}
+
+// CreateNoteWithCards saves a new note and its cards in one transaction, so a failure
+// leaves neither behind.
+func (s *SQLStore) CreateNoteWithCards(ctx context.Context, collectionID string, n *Note, cards []*Card) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := insertNote(ctx, tx, collectionID, n); err != nil {
+			return fmt.Errorf("failed to save note: %w", err)
+		}
+		for _, card := range cards {
+			if err := insertCard(ctx, tx, card); err != nil {
+				return fmt.Errorf("failed to save card: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func insertNote(ctx context.Context, exec sqlExecer, collectionID string, n *Note) error {
 	fieldValsJSON, err := json.Marshal(n.FieldMap)
 	if err != nil {
 		return err
@@ -654,14 +1222,14 @@ func (s *SQLiteStore) CreateNote(collectionID string, n *Note) error {
 		INSERT INTO notes (id, collection_id, type_id, field_vals, tags, usn, created_at, modified_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err = s.db.Exec(query, n.ID, collectionID, noteTypeRecordID(collectionID, n.Type), fieldValsJSON, tagsJSON,
+	_, err = exec.ExecContext(ctx, query, n.ID, collectionID, noteTypeRecordID(collectionID, n.Type), fieldValsJSON, tagsJSON,
 		n.USN, n.CreatedAt.Unix(), n.ModifiedAt.Unix())
 	return err
 }
 
-func (s *SQLiteStore) GetNote(id int64) (*Note, error) {
+func (s *SQLStore) GetNote(ctx context.Context, id int64) (*Note, error) {
 	query := `SELECT id, collection_id, type_id, field_vals, tags, usn, created_at, modified_at FROM notes WHERE id = ?`
-	row := s.db.QueryRow(query, id)
+	row := s.db.QueryRowContext(ctx, query, id)
 
 	var note Note
 	var collectionID string
@@ -689,7 +1257,7 @@ func (s *SQLiteStore) GetNote(id int64) (*Note, error) {
 	return &note, nil
 }
 
-func (s *SQLiteStore) UpdateNote(n *Note) error {
+func (s *SQLStore) UpdateNote(ctx context.Context, n *Note) error {
 	fieldValsJSON, err := json.Marshal(n.FieldMap)
 	if err != nil {
 		return err
@@ -705,23 +1273,22 @@ func (s *SQLiteStore) UpdateNote(n *Note) error {
 		WHERE id = ?
 	`
 	var collectionID string
-	if err := s.db.QueryRow(`SELECT collection_id FROM notes WHERE id = ?`, n.ID).Scan(&collectionID); err != nil {
+	if err := s.db.QueryRowContext(ctx, `SELECT collection_id FROM notes WHERE id = ?`, n.ID).Scan(&collectionID); err != nil {
 		return err
 	}
-	_, err = s.db.Exec(query, noteTypeRecordID(collectionID, n.Type), fieldValsJSON, tagsJSON, n.USN, n.ModifiedAt.Unix(), n.ID)
+	_, err = s.db.ExecContext(ctx, query, noteTypeRecordID(collectionID, n.Type), fieldValsJSON, tagsJSON, n.USN, n.ModifiedAt.Unix(), n.ID)
 	return err
 }
 
-func (s *SQLiteStore) DeleteNote(id int64) error {
-	// In a full implementation, cascade delete cards
-	query := `DELETE FROM notes WHERE id = ?`
-	_, err := s.db.Exec(query, id)
+// DeleteNote removes the note together with its cards and their review history.
+func (s *SQLStore) DeleteNote(ctx context.Context, id int64) error {
+	_, err := s.DeleteNotesWithCards(ctx, []int64{id})
 	return err
 }
 
-func (s *SQLiteStore) ListNotes(collectionID string) (map[int64]Note, error) {
+func (s *SQLStore) ListNotes(ctx context.Context, collectionID string) (map[int64]Note, error) {
 	query := `SELECT id FROM notes WHERE collection_id = ?`
-	rows, err := s.db.Query(query, collectionID)
+	rows, err := s.db.QueryContext(ctx, query, collectionID)
 	if err != nil {
 		return nil, err
 	}
@@ -733,7 +1300,7 @@ func (s *SQLiteStore) ListNotes(collectionID string) (map[int64]Note, error) {
 		if err := rows.Scan(&noteID); err != nil {
 			return nil, err
 		}
-		note, err := s.GetNote(noteID)
+		note, err := s.GetNote(ctx, noteID)
 		if err != nil {
 			return nil, err
 		}
@@ -744,11 +1311,11 @@ func (s *SQLiteStore) ListNotes(collectionID string) (map[int64]Note, error) {
 }
 
 // GetNotesByType returns all notes of a specific note type
-func (s *SQLiteStore) GetNotesByType(collectionID string, noteTypeName string) ([]Note, error) {
+func (s *SQLStore) GetNotesByType(ctx context.Context, collectionID string, noteTypeName string) ([]Note, error) {
 	query := `SELECT id, collection_id, type_id, field_vals, tags, usn, created_at, modified_at 
 	          FROM notes 
 	          WHERE collection_id = ? AND type_id = ?`
-	rows, err := s.db.Query(query, collectionID, noteTypeRecordID(collectionID, NoteTypeName(noteTypeName)))
+	rows, err := s.db.QueryContext(ctx, query, collectionID, noteTypeRecordID(collectionID, NoteTypeName(noteTypeName)))
 	if err != nil {
 		return nil, err
 	}
@@ -785,10 +1352,18 @@ func (s *SQLiteStore) GetNotesByType(collectionID string, noteTypeName string) (
 	return notes, rows.Err()
 }
 
-func (s *SQLiteStore) FindDuplicateNotes(collectionID, fieldName, value string, deckID int64) ([]NoteBrief, error) {
-	// Search notes where the specified field contains the value
+func (s *SQLStore) FindDuplicateNotes(ctx context.Context, collectionID, fieldName, value string, deckID int64) ([]NoteBrief, error) {
+	// Narrow to notes containing every word of the value via the full-text index, then
+	// compare the field exactly. Values with no indexable words fall back to a scan.
 	query := `SELECT id, type_id, field_vals FROM notes WHERE collection_id = ?`
-	rows, err := s.db.Query(query, collectionID)
+	args := []interface{}{collectionID}
+	if module, err := s.fullTextModule(ctx); err == nil {
+		if match := fullTextMatchQuery(module, value, false); match != "" {
+			query += ` AND ` + fullTextMatchCondition(module, "")
+			args = append(args, match)
+		}
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -817,7 +1392,7 @@ func (s *SQLiteStore) FindDuplicateNotes(collectionID, fieldName, value string,
 			if normalizedFieldVal == normalizedValue {
 				// If deckID specified, check if any card for this note is in that deck
 				if deckID > 0 {
-					inDeck, _ := s.noteHasCardInDeck(noteID, deckID)
+					inDeck, _ := s.noteHasCardInDeck(ctx, noteID, deckID)
 					if !inDeck {
 						continue
 					}
@@ -834,15 +1409,19 @@ func (s *SQLiteStore) FindDuplicateNotes(collectionID, fieldName, value string,
 	return duplicates, nil
 }
 
-func (s *SQLiteStore) noteHasCardInDeck(noteID, deckID int64) (bool, error) {
+func (s *SQLStore) noteHasCardInDeck(ctx context.Context, noteID, deckID int64) (bool, error) {
 	query := `SELECT COUNT(*) FROM cards WHERE note_id = ? AND deck_id = ?`
 	var count int
-	err := s.db.QueryRow(query, noteID, deckID).Scan(&count)
+	err := s.db.QueryRowContext(ctx, query, noteID, deckID).Scan(&count)
 	return count > 0, err
 }
 
 // Card methods
-func (s *SQLiteStore) CreateCard(c *Card) error {
+func (s *SQLStore) CreateCard(ctx context.Context, c *Card) error {
+	return insertCard(ctx, s.db, c)
+}
+
+func insertCard(ctx context.Context, exec sqlExecer, c *Card) error {
 	fsrsJSON, err := json.Marshal(c.SRS)
 	if err != nil {
 		return err
@@ -853,19 +1432,19 @@ func (s *SQLiteStore) CreateCard(c *Card) error {
 		                   due, state, fsrs_data, flag, marked, suspended, usn)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err = s.db.Exec(query, c.ID, c.NoteID, c.DeckID, c.TemplateName, c.Ordinal, c.Front, c.Back,
+	_, err = exec.ExecContext(ctx, query, c.ID, c.NoteID, c.DeckID, c.TemplateName, c.Ordinal, c.Front, c.Back,
 		c.SRS.Due.Unix(), int(c.SRS.State), fsrsJSON, c.Flag, c.Marked, c.Suspended, c.USN)
 	return err
 }
 
 // GetCardsByNote returns all cards for a given note
-func (s *SQLiteStore) GetCardsByNote(noteID int64) ([]Card, error) {
+func (s *SQLStore) GetCardsByNote(ctx context.Context, noteID int64) ([]Card, error) {
 	query := `
 		SELECT id, note_id, deck_id, template_name, ordinal, front, back,
-		       due, state, fsrs_data, flag, marked, suspended, usn
+		       due, state, fsrs_data, flag, marked, suspended, usn, learning_step, buried_at, ease_factor, original_deck_id
 		FROM cards WHERE note_id = ?
 	`
-	rows, err := s.db.Query(query, noteID)
+	rows, err := s.db.QueryContext(ctx, query, noteID)
 	if err != nil {
 		return nil, err
 	}
@@ -878,9 +1457,10 @@ func (s *SQLiteStore) GetCardsByNote(noteID int64) ([]Card, error) {
 		var state int
 		var fsrsJSON []byte
 		var marked, suspended int
+		var buriedAt int64
 
 		err := rows.Scan(&card.ID, &card.NoteID, &card.DeckID, &card.TemplateName, &card.Ordinal,
-			&card.Front, &card.Back, &dueUnix, &state, &fsrsJSON, &card.Flag, &marked, &suspended, &card.USN)
+			&card.Front, &card.Back, &dueUnix, &state, &fsrsJSON, &card.Flag, &marked, &suspended, &card.USN, &card.LearningStep, &buriedAt, &card.EaseFactor, &card.OriginalDeckID)
 		if err != nil {
 			return nil, err
 		}
@@ -889,6 +1469,7 @@ func (s *SQLiteStore) GetCardsByNote(noteID int64) ([]Card, error) {
 		card.SRS.State = fsrs.State(state)
 		card.Marked = marked != 0
 		card.Suspended = suspended != 0
+		card.Buried = buriedAt != 0
 
 		if err := json.Unmarshal(fsrsJSON, &card.SRS); err != nil {
 			return nil, err
@@ -900,28 +1481,30 @@ func (s *SQLiteStore) GetCardsByNote(noteID int64) ([]Card, error) {
 	return cards, rows.Err()
 }
 
-func (s *SQLiteStore) GetCard(id int64) (*Card, error) {
+func (s *SQLStore) GetCard(ctx context.Context, id int64) (*Card, error) {
 	query := `
 		SELECT id, note_id, deck_id, template_name, ordinal, front, back,
-		       due, state, fsrs_data, flag, marked, suspended, usn
+		       due, state, fsrs_data, flag, marked, suspended, usn, learning_step, buried_at, ease_factor, original_deck_id
 		FROM cards WHERE id = ?
 	`
-	row := s.db.QueryRow(query, id)
+	row := s.db.QueryRowContext(ctx, query, id)
 
 	var card Card
 	var dueUnix int64
 	var state int
 	var fsrsJSON []byte
 	var marked, suspended int
+	var buriedAt int64
 
 	err := row.Scan(&card.ID, &card.NoteID, &card.DeckID, &card.TemplateName, &card.Ordinal,
-		&card.Front, &card.Back, &dueUnix, &state, &fsrsJSON, &card.Flag, &marked, &suspended, &card.USN)
+		&card.Front, &card.Back, &dueUnix, &state, &fsrsJSON, &card.Flag, &marked, &suspended, &card.USN, &card.LearningStep, &buriedAt, &card.EaseFactor, &card.OriginalDeckID)
 	if err != nil {
 		return nil, err
 	}
 
 	card.Marked = marked == 1
 	card.Suspended = suspended == 1
+	card.Buried = buriedAt != 0
 
 	if err := json.Unmarshal(fsrsJSON, &card.SRS); err != nil {
 		return nil, err
@@ -938,7 +1521,7 @@ func defaultReviewStateCard(now time.Time) fsrs.Card {
 	return card
 }
 
-func (s *SQLiteStore) ensureReviewStateForCard(userID string, cardID int64) error {
+func (s *SQLStore) ensureReviewStateForCard(ctx context.Context, userID string, cardID int64) error {
 	if strings.TrimSpace(userID) == "" {
 		return nil
 	}
@@ -950,18 +1533,19 @@ func (s *SQLiteStore) ensureReviewStateForCard(userID string, cardID int64) erro
 		return err
 	}
 
-	_, err = s.db.Exec(`
-		INSERT OR IGNORE INTO card_review_states (
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO card_review_states (
 			user_id, card_id, due, state, fsrs_data, flag, marked, suspended, updated_at
 		)
 		SELECT ?, c.id, ?, ?, ?, 0, 0, 0, ?
 		FROM cards c
 		WHERE c.id = ?
+		ON CONFLICT DO NOTHING
 	`, userID, initialCard.Due.Unix(), int(initialCard.State), fsrsJSON, now.Unix(), cardID)
 	return err
 }
 
-func (s *SQLiteStore) EnsureReviewStatesForUser(userID string) error {
+func (s *SQLStore) EnsureReviewStatesForUser(ctx context.Context, userID string) error {
 	if strings.TrimSpace(userID) == "" {
 		return nil
 	}
@@ -973,27 +1557,29 @@ func (s *SQLiteStore) EnsureReviewStatesForUser(userID string) error {
 		return err
 	}
 
-	_, err = s.db.Exec(`
-		INSERT OR IGNORE INTO card_review_states (
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO card_review_states (
 			user_id, card_id, due, state, fsrs_data, flag, marked, suspended, updated_at
 		)
 		SELECT ?, c.id, ?, ?, ?, 0, 0, 0, ?
 		FROM cards c
+		WHERE true
+		ON CONFLICT DO NOTHING
 	`, userID, initialCard.Due.Unix(), int(initialCard.State), fsrsJSON, now.Unix())
 	return err
 }
 
-func (s *SQLiteStore) applyReviewStateToCard(userID string, card *Card) error {
+func (s *SQLStore) applyReviewStateToCard(ctx context.Context, userID string, card *Card) error {
 	if strings.TrimSpace(userID) == "" {
 		return nil
 	}
 
-	if err := s.ensureReviewStateForCard(userID, card.ID); err != nil {
+	if err := s.ensureReviewStateForCard(ctx, userID, card.ID); err != nil {
 		return err
 	}
 
 	query := `
-		SELECT due, state, fsrs_data, flag, marked, suspended, updated_at
+		SELECT due, state, fsrs_data, flag, marked, suspended, updated_at, learning_step, buried_at, ease_factor
 		FROM card_review_states
 		WHERE user_id = ? AND card_id = ?
 	`
@@ -1006,8 +1592,9 @@ func (s *SQLiteStore) applyReviewStateToCard(userID string, card *Card) error {
 		marked    int
 		suspended int
 		updatedAt int64
+		buriedAt  int64
 	)
-	if err := s.db.QueryRow(query, userID, card.ID).Scan(&dueUnix, &state, &fsrsJSON, &flag, &marked, &suspended, &updatedAt); err != nil {
+	if err := s.db.QueryRowContext(ctx, query, userID, card.ID).Scan(&dueUnix, &state, &fsrsJSON, &flag, &marked, &suspended, &updatedAt, &card.LearningStep, &buriedAt, &card.EaseFactor); err != nil {
 		return err
 	}
 
@@ -1019,21 +1606,22 @@ func (s *SQLiteStore) applyReviewStateToCard(userID string, card *Card) error {
 	card.Flag = flag
 	card.Marked = marked == 1
 	card.Suspended = suspended == 1
+	card.Buried = buriedAt != 0
 	return nil
 }
 
-func (s *SQLiteStore) GetCardForUser(userID string, id int64) (*Card, error) {
-	card, err := s.GetCard(id)
+func (s *SQLStore) GetCardForUser(ctx context.Context, userID string, id int64) (*Card, error) {
+	card, err := s.GetCard(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	if err := s.applyReviewStateToCard(userID, card); err != nil {
+	if err := s.applyReviewStateToCard(ctx, userID, card); err != nil {
 		return nil, err
 	}
 	return card, nil
 }
 
-func (s *SQLiteStore) UpdateCard(c *Card) error {
+func (s *SQLStore) UpdateCard(ctx context.Context, c *Card) error {
 	fsrsJSON, err := json.Marshal(c.SRS)
 	if err != nil {
 		return err
@@ -1042,19 +1630,19 @@ func (s *SQLiteStore) UpdateCard(c *Card) error {
 	query := `
 		UPDATE cards
 		SET note_id = ?, deck_id = ?, template_name = ?, ordinal = ?, front = ?, back = ?,
-		    due = ?, state = ?, fsrs_data = ?, flag = ?, marked = ?, suspended = ?, usn = ?
+		    due = ?, state = ?, fsrs_data = ?, flag = ?, marked = ?, suspended = ?, usn = ?, learning_step = ?, ease_factor = ?
 		WHERE id = ?
 	`
-	_, err = s.db.Exec(query, c.NoteID, c.DeckID, c.TemplateName, c.Ordinal, c.Front, c.Back,
-		c.SRS.Due.Unix(), int(c.SRS.State), fsrsJSON, c.Flag, c.Marked, c.Suspended, c.USN, c.ID)
+	_, err = s.db.ExecContext(ctx, query, c.NoteID, c.DeckID, c.TemplateName, c.Ordinal, c.Front, c.Back,
+		c.SRS.Due.Unix(), int(c.SRS.State), fsrsJSON, c.Flag, c.Marked, c.Suspended, c.USN, c.LearningStep, c.EaseFactor, c.ID)
 	return err
 }
 
-func (s *SQLiteStore) UpdateCardReviewState(userID string, c *Card) error {
+func (s *SQLStore) UpdateCardReviewState(ctx context.Context, userID string, c *Card) error {
 	if strings.TrimSpace(userID) == "" {
-		return s.UpdateCard(c)
+		return s.UpdateCard(ctx, c)
 	}
-	if err := s.ensureReviewStateForCard(userID, c.ID); err != nil {
+	if err := s.ensureReviewStateForCard(ctx, userID, c.ID); err != nil {
 		return err
 	}
 
@@ -1063,26 +1651,31 @@ func (s *SQLiteStore) UpdateCardReviewState(userID string, c *Card) error {
 		return err
 	}
 
-	_, err = s.db.Exec(`
+	_, err = s.db.ExecContext(ctx, `
 		UPDATE card_review_states
-		SET due = ?, state = ?, fsrs_data = ?, flag = ?, marked = ?, suspended = ?, updated_at = ?
+		SET due = ?, state = ?, fsrs_data = ?, flag = ?, marked = ?, suspended = ?, learning_step = ?, ease_factor = ?, updated_at = ?
 		WHERE user_id = ? AND card_id = ?
-	`, c.SRS.Due.Unix(), int(c.SRS.State), fsrsJSON, c.Flag, c.Marked, c.Suspended, time.Now().Unix(), userID, c.ID)
+	`, c.SRS.Due.Unix(), int(c.SRS.State), fsrsJSON, c.Flag, c.Marked, c.Suspended, c.LearningStep, c.EaseFactor, time.Now().Unix(), userID, c.ID)
 	return err
 }
 
-func (s *SQLiteStore) DeleteCard(id int64) error {
-	query := `DELETE FROM cards WHERE id = ?`
-	_, err := s.db.Exec(query, id)
-	return err
+// DeleteCard removes the card and its review history; revlog rows reference cards.
+func (s *SQLStore) DeleteCard(ctx context.Context, id int64) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM revlog WHERE card_id = ?`, id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM cards WHERE id = ?`, id)
+		return err
+	})
 }
 
-func (s *SQLiteStore) getDeckDailyLimits(deckID int64) (int, int, error) {
+func (s *SQLStore) getDeckDailyLimits(ctx context.Context, deckID int64) (int, int, error) {
 	newLimit := defaultNewCardsPerDay
 	reviewLimit := defaultReviewsPerDay
 
 	var optionsID sql.NullInt64
-	if err := s.db.QueryRow(`SELECT options_id FROM decks WHERE id = ?`, deckID).Scan(&optionsID); err != nil {
+	if err := s.db.QueryRowContext(ctx, `SELECT options_id FROM decks WHERE id = ?`, deckID).Scan(&optionsID); err != nil {
 		return newLimit, reviewLimit, err
 	}
 
@@ -1091,7 +1684,7 @@ func (s *SQLiteStore) getDeckDailyLimits(deckID int64) (int, int, error) {
 	}
 
 	var configuredNew, configuredReview int
-	err := s.db.QueryRow(
+	err := s.db.QueryRowContext(ctx,
 		`SELECT new_cards_per_day, reviews_per_day FROM deck_options WHERE id = ?`,
 		optionsID.Int64,
 	).Scan(&configuredNew, &configuredReview)
@@ -1112,141 +1705,37 @@ func (s *SQLiteStore) getDeckDailyLimits(deckID int64) (int, int, error) {
 	return newLimit, reviewLimit, nil
 }
 
-func (s *SQLiteStore) countDistinctReviewedCardsByStates(deckID, dayStart, dayEnd int64, states []int) (int, error) {
-	if len(states) == 0 {
-		return 0, nil
-	}
-
-	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(states)), ",")
-	query := fmt.Sprintf(`
-		SELECT COUNT(DISTINCT r.card_id)
-		FROM revlog r
-		JOIN cards c ON c.id = r.card_id
-		WHERE c.deck_id = ?
-		  AND r.reviewed_at >= ?
-		  AND r.reviewed_at < ?
-		  AND r.state IN (%s)
-	`, placeholders)
-
-	args := make([]interface{}, 0, 3+len(states))
-	args = append(args, deckID, dayStart, dayEnd)
-	for _, state := range states {
-		args = append(args, state)
-	}
-
-	var count int
-	if err := s.db.QueryRow(query, args...).Scan(&count); err != nil {
-		return 0, err
-	}
-	return count, nil
-}
-
-func (s *SQLiteStore) getTodayReviewedCounts(deckID int64, now time.Time) (int, int, error) {
-	dayStartTime := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	dayStart := dayStartTime.Unix()
-	dayEnd := dayStartTime.Add(24 * time.Hour).Unix()
-
-	newReviewed, err := s.countDistinctReviewedCardsByStates(deckID, dayStart, dayEnd, []int{int(fsrs.New)})
-	if err != nil {
-		return 0, 0, err
-	}
-
-	reviewed, err := s.countDistinctReviewedCardsByStates(
-		deckID,
-		dayStart,
-		dayEnd,
-		[]int{int(fsrs.Review), int(fsrs.Relearning)},
-	)
-	if err != nil {
-		return 0, 0, err
-	}
-
-	return newReviewed, reviewed, nil
-}
-
-func (s *SQLiteStore) countDistinctReviewedCardsByStatesForUser(userID string, deckID, dayStart, dayEnd int64, states []int) (int, error) {
-	if len(states) == 0 {
-		return 0, nil
-	}
-
-	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(states)), ",")
-	query := fmt.Sprintf(`
-		SELECT COUNT(DISTINCT r.card_id)
-		FROM revlog r
-		JOIN cards c ON c.id = r.card_id
-		WHERE r.user_id = ?
-		  AND c.deck_id = ?
-		  AND r.reviewed_at >= ?
-		  AND r.reviewed_at < ?
-		  AND r.state IN (%s)
-	`, placeholders)
-
-	args := make([]interface{}, 0, 4+len(states))
-	args = append(args, userID, deckID, dayStart, dayEnd)
-	for _, state := range states {
-		args = append(args, state)
-	}
-
-	var count int
-	if err := s.db.QueryRow(query, args...).Scan(&count); err != nil {
-		return 0, err
-	}
-	return count, nil
-}
-
-func (s *SQLiteStore) getTodayReviewedCountsForUser(userID string, deckID int64, now time.Time) (int, int, error) {
-	if strings.TrimSpace(userID) == "" {
-		return s.getTodayReviewedCounts(deckID, now)
-	}
-
-	dayStartTime := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	dayStart := dayStartTime.Unix()
-	dayEnd := dayStartTime.Add(24 * time.Hour).Unix()
-
-	newReviewed, err := s.countDistinctReviewedCardsByStatesForUser(userID, deckID, dayStart, dayEnd, []int{int(fsrs.New)})
-	if err != nil {
-		return 0, 0, err
-	}
-
-	reviewed, err := s.countDistinctReviewedCardsByStatesForUser(
-		userID,
-		deckID,
-		dayStart,
-		dayEnd,
-		[]int{int(fsrs.Review), int(fsrs.Relearning)},
-	)
-	if err != nil {
-		return 0, 0, err
-	}
-
-	return newReviewed, reviewed, nil
-}
-
-func (s *SQLiteStore) getDueCardIDsByStates(deckID, now int64, states []int, limit int) ([]int64, error) {
+// getDueCardIDsByStates lists the deck's cards in the given states due by now, sorted by
+// orderBy (see reviewOrderSQL).
+func (s *SQLStore) getDueCardIDsByStates(ctx context.Context, deckID, now int64, states []int, limit int, filter DueCardFilter, orderBy string) ([]int64, error) {
 	if len(states) == 0 || limit <= 0 {
 		return []int64{}, nil
 	}
 
 	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(states)), ",")
+	filterSQL, filterArgs := filter.sqlConditions(s.dialect, "c", "c")
 	query := fmt.Sprintf(`
-		SELECT id
-		FROM cards
-		WHERE deck_id = ?
-		  AND due <= ?
-		  AND suspended = 0
-		  AND state IN (%s)
-		ORDER BY due ASC, id ASC
+		SELECT c.id
+		FROM cards c
+		WHERE c.deck_id = ?
+		  AND c.due <= ?
+		  AND c.suspended = 0
+		  AND c.buried_at = 0
+		  AND c.state IN (%s)
+		  %s
+		ORDER BY %s
 		LIMIT ?
-	`, placeholders)
+	`, placeholders, filterSQL, orderBy)
 
-	args := make([]interface{}, 0, 3+len(states))
+	args := make([]interface{}, 0, 3+len(states)+len(filterArgs))
 	args = append(args, deckID, now)
 	for _, state := range states {
 		args = append(args, state)
 	}
+	args = append(args, filterArgs...)
 	args = append(args, limit)
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -1264,33 +1753,48 @@ func (s *SQLiteStore) getDueCardIDsByStates(deckID, now int64, states []int, lim
 	return ids, rows.Err()
 }
 
-func (s *SQLiteStore) getDueCardIDsByStatesForUser(userID string, deckID, now int64, states []int, limit int) ([]int64, error) {
+// getDueCardIDsByStatesForUser is getDueCardIDsByStates over the user's review states.
+// With subdecks set it draws from deckID's whole subtree, matching cards by their home
+// deck so cards pulled into a filtered deck are not lost.
+func (s *SQLStore) getDueCardIDsByStatesForUser(ctx context.Context, userID string, deckID int64, subdecks bool, now int64, states []int, limit int, filter DueCardFilter, orderBy string) ([]int64, error) {
 	if len(states) == 0 || limit <= 0 {
 		return []int64{}, nil
 	}
 
+	cte, deckSQL := "", "c.deck_id = ?"
+	if subdecks {
+		cte, deckSQL = deckSubtreeCTE, "CASE WHEN c.original_deck_id > 0 THEN c.original_deck_id ELSE c.deck_id END IN (SELECT id FROM subtree)"
+	}
 	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(states)), ",")
-	query := fmt.Sprintf(`
+	filterSQL, filterArgs := filter.sqlConditions(s.dialect, "c", "rs")
+	query := cte + fmt.Sprintf(`
 		SELECT c.id
 		FROM cards c
 		JOIN card_review_states rs ON rs.card_id = c.id
 		WHERE rs.user_id = ?
-		  AND c.deck_id = ?
+		  AND %s
 		  AND rs.due <= ?
 		  AND rs.suspended = 0
+		  AND rs.buried_at = 0
 		  AND rs.state IN (%s)
-		ORDER BY rs.due ASC, c.id ASC
+		  %s
+		ORDER BY %s
 		LIMIT ?
-	`, placeholders)
+	`, deckSQL, placeholders, filterSQL, orderBy)
 
-	args := make([]interface{}, 0, 4+len(states))
-	args = append(args, userID, deckID, now)
+	args := make([]interface{}, 0, 5+len(states)+len(filterArgs))
+	if subdecks {
+		args = append(args, deckID, userID, now)
+	} else {
+		args = append(args, userID, deckID, now)
+	}
 	for _, state := range states {
 		args = append(args, state)
 	}
+	args = append(args, filterArgs...)
 	args = append(args, limit)
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -1308,45 +1812,60 @@ func (s *SQLiteStore) getDueCardIDsByStatesForUser(userID string, deckID, now in
 	return ids, rows.Err()
 }
 
-func (s *SQLiteStore) GetDueCards(deckID int64, limit int) ([]*Card, error) {
+func (s *SQLStore) GetDueCards(ctx context.Context, deckID int64, limit int) ([]*Card, error) {
+	return s.GetDueCardsFiltered(ctx, deckID, limit, DueCardFilter{})
+}
+
+// GetDueCardsFiltered builds the deck's study queue from cards matching filter.
+func (s *SQLStore) GetDueCardsFiltered(ctx context.Context, deckID int64, limit int, filter DueCardFilter) ([]*Card, error) {
 	if limit <= 0 {
 		return []*Card{}, nil
 	}
-
-	now := time.Now().Unix()
-	newLimit, reviewLimit, err := s.getDeckDailyLimits(deckID)
+	clock := time.Now()
+	collectionID, dayStart, dayEnd, err := s.studyDayForDeck(ctx, deckID, clock)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.unburyPastDays(ctx, collectionID, dayStart); err != nil {
+		return nil, err
+	}
 
-	newReviewedToday, reviewedToday, err := s.getTodayReviewedCounts(deckID, time.Now())
+	now := clock.Unix()
+	learnAheadUntil := clock.Add(learnAheadWindow).Unix()
+	if filtered, err := s.IsFilteredDeck(ctx, deckID); err != nil || filtered {
+		if err != nil {
+			return nil, err
+		}
+		return s.filteredDeckQueue(ctx, "", deckID, learnAheadUntil, limit, filter)
+	}
+	limits, err := s.GetEffectiveDeckLimits(ctx, "", deckID, clock)
 	if err != nil {
 		return nil, err
 	}
 
-	newRemaining := newLimit - newReviewedToday
-	if newRemaining < 0 {
+	newRemaining := limits.NewRemaining
+	reviewRemaining := limits.ReviewRemaining
+	if stats, err := s.GetDeckStats(ctx, deckID); err == nil && stats.DueReviewBacklog > limits.ReviewLimit {
 		newRemaining = 0
 	}
 
-	reviewRemaining := reviewLimit - reviewedToday
-	if reviewRemaining < 0 {
-		reviewRemaining = 0
-	}
-	if stats, err := s.GetDeckStats(deckID); err == nil && stats.DueReviewBacklog > reviewLimit {
-		newRemaining = 0
+	reviewOrder, err := s.reviewOrderForDeck(ctx, deckID)
+	if err != nil {
+		return nil, err
 	}
+	dueOrderBy := reviewOrderSQL(s.dialect, reviewOrderDue, "c", now, dayStart.Unix())
+	reviewOrderBy := reviewOrderSQL(s.dialect, reviewOrder, "c", now, dayStart.Unix())
 
 	remaining := limit
 	cardIDs := make([]int64, 0, limit)
-	appendCardIDs := func(stateGroup []int, groupLimit int) error {
+	appendCardIDs := func(stateGroup []int, groupLimit int, dueBy int64, orderBy string) error {
 		if remaining <= 0 || groupLimit <= 0 {
 			return nil
 		}
 		if groupLimit > remaining {
 			groupLimit = remaining
 		}
-		ids, err := s.getDueCardIDsByStates(deckID, now, stateGroup, groupLimit)
+		ids, err := s.getDueCardIDsByStates(ctx, deckID, dueBy, stateGroup, groupLimit, filter, orderBy)
 		if err != nil {
 			return err
 		}
@@ -1355,23 +1874,25 @@ func (s *SQLiteStore) GetDueCards(deckID int64, limit int) ([]*Card, error) {
 		return nil
 	}
 
-	// Prioritize older review backlog before learning/new cards.
-	if err := appendCardIDs([]int{int(fsrs.Review), int(fsrs.Relearning)}, reviewRemaining); err != nil {
+	// Prioritize older review backlog before learning/new cards. Reviews are due by the
+	// study day, so everything due before the next day starts is shown today.
+	if err := appendCardIDs([]int{int(fsrs.Review), int(fsrs.Relearning)}, reviewRemaining, dayEnd.Unix()-1, reviewOrderBy); err != nil {
 		return nil, err
 	}
 
-	// Learning/relearning cards are time-critical and are not capped by daily new/review limits.
-	if err := appendCardIDs([]int{int(fsrs.Learning)}, remaining); err != nil {
+	// Learning cards are time-critical: they are not capped by daily new/review limits and
+	// are shown up to learnAheadWindow before they fall due.
+	if err := appendCardIDs([]int{int(fsrs.Learning)}, remaining, learnAheadUntil, dueOrderBy); err != nil {
 		return nil, err
 	}
 
-	if err := appendCardIDs([]int{int(fsrs.New)}, newRemaining); err != nil {
+	if err := appendCardIDs([]int{int(fsrs.New)}, newRemaining, now, dueOrderBy); err != nil {
 		return nil, err
 	}
 
 	cards := make([]*Card, 0, len(cardIDs))
 	for _, cardID := range cardIDs {
-		card, err := s.GetCard(cardID)
+		card, err := s.GetCard(ctx, cardID)
 		if err != nil {
 			return nil, err
 		}
@@ -1381,52 +1902,77 @@ func (s *SQLiteStore) GetDueCards(deckID int64, limit int) ([]*Card, error) {
 	return cards, nil
 }
 
-func (s *SQLiteStore) GetDueCardsForUser(userID string, deckID int64, limit int) ([]*Card, error) {
+func (s *SQLStore) GetDueCardsForUser(ctx context.Context, userID string, deckID int64, limit int) ([]*Card, error) {
+	return s.GetDueCardsForUserFiltered(ctx, userID, deckID, limit, DueCardFilter{})
+}
+
+// GetDueCardsForUserFiltered builds the user's study queue from cards matching filter.
+func (s *SQLStore) GetDueCardsForUserFiltered(ctx context.Context, userID string, deckID int64, limit int, filter DueCardFilter) ([]*Card, error) {
 	if strings.TrimSpace(userID) == "" {
-		return s.GetDueCards(deckID, limit)
+		return s.GetDueCardsFiltered(ctx, deckID, limit, filter)
 	}
+	return s.dueCardsForUser(ctx, userID, deckID, false, limit, filter)
+}
+
+// GetSubtreeDueCardsForUser builds the user's study queue from deckID and all of its
+// descendants, under deckID's limits.
+func (s *SQLStore) GetSubtreeDueCardsForUser(ctx context.Context, userID string, deckID int64, limit int) ([]*Card, error) {
+	return s.dueCardsForUser(ctx, userID, deckID, true, limit, DueCardFilter{})
+}
+
+func (s *SQLStore) dueCardsForUser(ctx context.Context, userID string, deckID int64, subdecks bool, limit int, filter DueCardFilter) ([]*Card, error) {
 	if limit <= 0 {
 		return []*Card{}, nil
 	}
-
-	if err := s.EnsureReviewStatesForUser(userID); err != nil {
+	clock := time.Now()
+	collectionID, dayStart, dayEnd, err := s.studyDayForDeck(ctx, deckID, clock)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.unburyPastDays(ctx, collectionID, dayStart); err != nil {
 		return nil, err
 	}
 
-	now := time.Now().Unix()
-	newLimit, reviewLimit, err := s.getDeckDailyLimits(deckID)
-	if err != nil {
+	if err := s.EnsureReviewStatesForUser(ctx, userID); err != nil {
 		return nil, err
 	}
 
-	newReviewedToday, reviewedToday, err := s.getTodayReviewedCountsForUser(userID, deckID, time.Now())
+	now := clock.Unix()
+	learnAheadUntil := clock.Add(learnAheadWindow).Unix()
+	if filtered, err := s.IsFilteredDeck(ctx, deckID); err != nil || filtered {
+		if err != nil {
+			return nil, err
+		}
+		return s.filteredDeckQueue(ctx, userID, deckID, learnAheadUntil, limit, filter)
+	}
+	limits, err := s.GetEffectiveDeckLimits(ctx, userID, deckID, clock)
 	if err != nil {
 		return nil, err
 	}
 
-	newRemaining := newLimit - newReviewedToday
-	if newRemaining < 0 {
+	newRemaining := limits.NewRemaining
+	reviewRemaining := limits.ReviewRemaining
+	if stats, err := s.GetDeckStatsForUser(ctx, userID, deckID); err == nil && stats.DueReviewBacklog > limits.ReviewLimit {
 		newRemaining = 0
 	}
 
-	reviewRemaining := reviewLimit - reviewedToday
-	if reviewRemaining < 0 {
-		reviewRemaining = 0
-	}
-	if stats, err := s.GetDeckStatsForUser(userID, deckID); err == nil && stats.DueReviewBacklog > reviewLimit {
-		newRemaining = 0
+	reviewOrder, err := s.reviewOrderForDeck(ctx, deckID)
+	if err != nil {
+		return nil, err
 	}
+	dueOrderBy := reviewOrderSQL(s.dialect, reviewOrderDue, "rs", now, dayStart.Unix())
+	reviewOrderBy := reviewOrderSQL(s.dialect, reviewOrder, "rs", now, dayStart.Unix())
 
 	remaining := limit
 	cardIDs := make([]int64, 0, limit)
-	appendCardIDs := func(stateGroup []int, groupLimit int) error {
+	appendCardIDs := func(stateGroup []int, groupLimit int, dueBy int64, orderBy string) error {
 		if remaining <= 0 || groupLimit <= 0 {
 			return nil
 		}
 		if groupLimit > remaining {
 			groupLimit = remaining
 		}
-		ids, err := s.getDueCardIDsByStatesForUser(userID, deckID, now, stateGroup, groupLimit)
+		ids, err := s.getDueCardIDsByStatesForUser(ctx, userID, deckID, subdecks, dueBy, stateGroup, groupLimit, filter, orderBy)
 		if err != nil {
 			return err
 		}
@@ -1435,19 +1981,19 @@ func (s *SQLiteStore) GetDueCardsForUser(userID string, deckID int64, limit int)
 		return nil
 	}
 
-	if err := appendCardIDs([]int{int(fsrs.Review), int(fsrs.Relearning)}, reviewRemaining); err != nil {
+	if err := appendCardIDs([]int{int(fsrs.Review), int(fsrs.Relearning)}, reviewRemaining, dayEnd.Unix()-1, reviewOrderBy); err != nil {
 		return nil, err
 	}
-	if err := appendCardIDs([]int{int(fsrs.Learning)}, remaining); err != nil {
+	if err := appendCardIDs([]int{int(fsrs.Learning)}, remaining, learnAheadUntil, dueOrderBy); err != nil {
 		return nil, err
 	}
-	if err := appendCardIDs([]int{int(fsrs.New)}, newRemaining); err != nil {
+	if err := appendCardIDs([]int{int(fsrs.New)}, newRemaining, now, dueOrderBy); err != nil {
 		return nil, err
 	}
 
 	cards := make([]*Card, 0, len(cardIDs))
 	for _, cardID := range cardIDs {
-		card, err := s.GetCardForUser(userID, cardID)
+		card, err := s.GetCardForUser(ctx, userID, cardID)
 		if err != nil {
 			return nil, err
 		}
@@ -1457,9 +2003,9 @@ func (s *SQLiteStore) GetDueCardsForUser(userID string, deckID int64, limit int)
 	return cards, nil
 }
 
-func (s *SQLiteStore) ListCardsInDeck(deckID int64) ([]*Card, error) {
+func (s *SQLStore) ListCardsInDeck(ctx context.Context, deckID int64) ([]*Card, error) {
 	query := `SELECT id FROM cards WHERE deck_id = ? ORDER BY id`
-	rows, err := s.db.Query(query, deckID)
+	rows, err := s.db.QueryContext(ctx, query, deckID)
 	if err != nil {
 		return nil, err
 	}
@@ -1471,7 +2017,7 @@ func (s *SQLiteStore) ListCardsInDeck(deckID int64) ([]*Card, error) {
 		if err := rows.Scan(&cardID); err != nil {
 			return nil, err
 		}
-		card, err := s.GetCard(cardID)
+		card, err := s.GetCard(ctx, cardID)
 		if err != nil {
 			return nil, err
 		}
@@ -1482,34 +2028,49 @@ func (s *SQLiteStore) ListCardsInDeck(deckID int64) ([]*Card, error) {
 }
 
 // Revlog methods
-func (s *SQLiteStore) AddRevlog(r *fsrs.ReviewLog, cardID int64, timeTakenMs int) error {
-	query := `
-		INSERT INTO revlog (id, card_id, rating, state, due, reviewed_at, time_taken_ms)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`
-	// Generate ID (in real implementation, use proper ID generation)
-	id := time.Now().UnixNano()
-	_, err := s.db.Exec(query, id, cardID, int(r.Rating), int(r.State), r.Review.Unix(), r.Review.Unix(), timeTakenMs)
-	return err
+func (s *SQLStore) AddRevlog(ctx context.Context, r *fsrs.ReviewLog, cardID int64, timeTakenMs int) error {
+	return s.insertRevlog(ctx, "", r, nil, reviewKindForState(r.State), cardID, timeTakenMs)
 }
 
-func (s *SQLiteStore) AddRevlogForUser(userID string, r *fsrs.ReviewLog, cardID int64, timeTakenMs int) error {
-	if strings.TrimSpace(userID) == "" {
-		return s.AddRevlog(r, cardID, timeTakenMs)
+func (s *SQLStore) AddRevlogForUser(ctx context.Context, userID string, r *fsrs.ReviewLog, cardID int64, timeTakenMs int) error {
+	return s.insertRevlog(ctx, userID, r, nil, reviewKindForState(r.State), cardID, timeTakenMs)
+}
+
+// AddRevlogDetailForUser records a review of the given kind together with the FSRS memory
+// state (stability and difficulty) the card was left in, which fsrs.ReviewLog itself does
+// not carry. A blank kind is derived from the card's state before the review.
+func (s *SQLStore) AddRevlogDetailForUser(ctx context.Context, userID string, r *fsrs.ReviewLog, after fsrs.Card, kind string, cardID int64, timeTakenMs int) error {
+	if kind == "" {
+		kind = reviewKindForState(r.State)
+	}
+	if !isValidReviewKind(kind) {
+		return fmt.Errorf("invalid review kind %q", kind)
+	}
+	return s.insertRevlog(ctx, userID, r, &after, kind, cardID, timeTakenMs)
+}
+
+func (s *SQLStore) insertRevlog(ctx context.Context, userID string, r *fsrs.ReviewLog, after *fsrs.Card, kind string, cardID int64, timeTakenMs int) error {
+	var stability, difficulty, nextState interface{}
+	if after != nil {
+		stability, difficulty, nextState = after.Stability, after.Difficulty, int(after.State)
 	}
 
 	query := `
-		INSERT INTO revlog (id, user_id, card_id, rating, state, due, reviewed_at, time_taken_ms)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO revlog (id, user_id, card_id, rating, state, due, reviewed_at, time_taken_ms, stability, difficulty, elapsed_days, scheduled_days, kind, next_state)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
+	// Generate ID (in real implementation, use proper ID generation)
 	id := time.Now().UnixNano()
-	_, err := s.db.Exec(query, id, userID, cardID, int(r.Rating), int(r.State), r.Review.Unix(), r.Review.Unix(), timeTakenMs)
+	_, err := s.db.ExecContext(ctx, query,
+		id, nullIfEmpty(strings.TrimSpace(userID)), cardID, int(r.Rating), int(r.State), r.Review.Unix(), r.Review.Unix(), timeTakenMs,
+		stability, difficulty, int64(r.ElapsedDays), int64(r.ScheduledDays), kind, nextState,
+	)
 	return err
 }
 
-func (s *SQLiteStore) GetRevlogForCard(cardID int64) ([]*fsrs.ReviewLog, error) {
-	query := `SELECT rating, state, due, reviewed_at FROM revlog WHERE card_id = ? ORDER BY reviewed_at`
-	rows, err := s.db.Query(query, cardID)
+func (s *SQLStore) GetRevlogForCard(ctx context.Context, cardID int64) ([]*fsrs.ReviewLog, error) {
+	query := `SELECT rating, state, due, reviewed_at, elapsed_days, scheduled_days FROM revlog WHERE card_id = ? ORDER BY reviewed_at`
+	rows, err := s.db.QueryContext(ctx, query, cardID)
 	if err != nil {
 		return nil, err
 	}
@@ -1518,16 +2079,18 @@ func (s *SQLiteStore) GetRevlogForCard(cardID int64) ([]*fsrs.ReviewLog, error)
 	var logs []*fsrs.ReviewLog
 	for rows.Next() {
 		var rating, state int
-		var dueUnix, reviewedAt int64
+		var dueUnix, reviewedAt, elapsedDays, scheduledDays int64
 
-		if err := rows.Scan(&rating, &state, &dueUnix, &reviewedAt); err != nil {
+		if err := rows.Scan(&rating, &state, &dueUnix, &reviewedAt, &elapsedDays, &scheduledDays); err != nil {
 			return nil, err
 		}
 
 		log := &fsrs.ReviewLog{
-			Rating: fsrs.Rating(rating),
-			State:  fsrs.State(state),
-			Review: time.Unix(reviewedAt, 0),
+			Rating:        fsrs.Rating(rating),
+			State:         fsrs.State(state),
+			Review:        time.Unix(reviewedAt, 0),
+			ElapsedDays:   uint64(elapsedDays),
+			ScheduledDays: uint64(scheduledDays),
 		}
 		logs = append(logs, log)
 	}
@@ -1535,19 +2098,90 @@ func (s *SQLiteStore) GetRevlogForCard(cardID int64) ([]*fsrs.ReviewLog, error)
 	return logs, nil
 }
 
+// ListRevlogEntriesForCard returns the most recent review log entries for a card, newest
+// first. A blank userID returns entries from every user.
+func (s *SQLStore) ListRevlogEntriesForCard(ctx context.Context, userID string, cardID int64, limit int) ([]RevlogEntry, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	query := `SELECT ` + revlogEntryColumns + ` FROM revlog r WHERE r.card_id = ?`
+	args := []interface{}{cardID}
+	if strings.TrimSpace(userID) != "" {
+		query += ` AND r.user_id = ?`
+		args = append(args, userID)
+	}
+	query += ` ORDER BY r.reviewed_at DESC, r.id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []RevlogEntry{}
+	for rows.Next() {
+		entry, err := scanRevlogEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// revlogEntryColumns selects a RevlogEntry from revlog aliased r, for scanRevlogEntry.
+const revlogEntryColumns = `r.id, COALESCE(r.user_id, ''), r.card_id, r.rating, COALESCE(r.state, 0), COALESCE(r.due, 0),
+	COALESCE(r.reviewed_at, 0), COALESCE(r.time_taken_ms, 0), r.stability, r.difficulty, r.elapsed_days, r.scheduled_days,
+	r.kind, r.next_state`
+
+// scanRevlogEntry reads a row selected with revlogEntryColumns, followed by any extra
+// columns into extra.
+func scanRevlogEntry(scanner interface{ Scan(...any) error }, extra ...any) (RevlogEntry, error) {
+	var (
+		entry      RevlogEntry
+		due        int64
+		reviewedAt int64
+		stability  sql.NullFloat64
+		difficulty sql.NullFloat64
+		nextState  sql.NullInt64
+	)
+	dest := []any{&entry.ID, &entry.UserID, &entry.CardID, &entry.Rating, &entry.State, &due, &reviewedAt, &entry.TimeTakenMs,
+		&stability, &difficulty, &entry.ElapsedDays, &entry.ScheduledDays, &entry.Kind, &nextState}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return RevlogEntry{}, err
+	}
+	if stability.Valid {
+		entry.Stability = &stability.Float64
+	}
+	if difficulty.Valid {
+		entry.Difficulty = &difficulty.Float64
+	}
+	if nextState.Valid {
+		state := int(nextState.Int64)
+		entry.NextState = &state
+	}
+	entry.Due = time.Unix(due, 0)
+	entry.ReviewedAt = time.Unix(reviewedAt, 0)
+	return entry, nil
+}
+
 // Media methods
-func (s *SQLiteStore) AddMedia(collectionID string, m *MediaRef) error {
-	query := `
-		INSERT INTO media (id, collection_id, filename, data, added_at)
-		VALUES (?, ?, ?, ?, ?)
-	`
-	_, err := s.db.Exec(query, m.ID, collectionID, m.Filename, m.Data, m.AddedAt.Unix())
-	return err
+func (s *SQLStore) AddMedia(ctx context.Context, collectionID string, m *MediaRef) error {
+	columns, values := `collection_id, filename, data, added_at, sha256`, `?, ?, ?, ?, ?`
+	args := []interface{}{collectionID, m.Filename, sqlBlob(m.Data), m.AddedAt.Unix(), mediaSHA256(m.Data)}
+	if m.ID != 0 {
+		columns, values = `id, `+columns, `?, `+values
+		args = append([]interface{}{m.ID}, args...)
+	}
+	query := `INSERT INTO media (` + columns + `) VALUES (` + values + `) RETURNING id`
+	return s.db.QueryRowContext(ctx, query, args...).Scan(&m.ID)
 }
 
-func (s *SQLiteStore) GetMedia(filename string) (*MediaRef, error) {
+func (s *SQLStore) GetMedia(ctx context.Context, filename string) (*MediaRef, error) {
 	query := `SELECT id, filename, data, added_at FROM media WHERE filename = ?`
-	row := s.db.QueryRow(query, filename)
+	row := s.db.QueryRowContext(ctx, query, filename)
 
 	var m MediaRef
 	var addedAt int64
@@ -1561,20 +2195,173 @@ func (s *SQLiteStore) GetMedia(filename string) (*MediaRef, error) {
 	return &m, nil
 }
 
-func (s *SQLiteStore) DeleteMedia(filename string) error {
+// GetCollectionMedia is GetMedia restricted to one collection's files.
+func (s *SQLStore) GetCollectionMedia(ctx context.Context, collectionID, filename string) (*MediaRef, error) {
+	query := `SELECT id, filename, data, added_at FROM media WHERE collection_id = ? AND filename = ?`
+	row := s.db.QueryRowContext(ctx, query, collectionID, filename)
+
+	var m MediaRef
+	var addedAt int64
+	if err := row.Scan(&m.ID, &m.Filename, &m.Data, &addedAt); err != nil {
+		return nil, err
+	}
+	m.AddedAt = time.Unix(addedAt, 0)
+	return &m, nil
+}
+
+// ListMediaFilenames returns the names of a collection's media files, sorted.
+func (s *SQLStore) ListMediaFilenames(ctx context.Context, collectionID string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT filename FROM media WHERE collection_id = ? ORDER BY filename`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var filenames []string
+	for rows.Next() {
+		var filename string
+		if err := rows.Scan(&filename); err != nil {
+			return nil, err
+		}
+		filenames = append(filenames, filename)
+	}
+	return filenames, rows.Err()
+}
+
+// DeleteCollectionMedia deletes the named files from one collection and returns how
+// many existed.
+func (s *SQLStore) DeleteCollectionMedia(ctx context.Context, collectionID string, filenames []string) (int64, error) {
+	var deleted int64
+	for _, filename := range filenames {
+		result, err := s.db.ExecContext(ctx, `DELETE FROM media WHERE collection_id = ? AND filename = ?`, collectionID, filename)
+		if err != nil {
+			return deleted, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+	}
+	return deleted, nil
+}
+
+// MarkUnusedMedia records now as the time each file in unused stopped being referenced,
+// unless it was already marked, and clears the mark on every other file.
+func (s *SQLStore) MarkUnusedMedia(ctx context.Context, collectionID string, unused map[string]bool, now time.Time) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT filename, unused_since FROM media WHERE collection_id = ?`, collectionID)
+		if err != nil {
+			return err
+		}
+		var mark, unmark []string
+		for rows.Next() {
+			var filename string
+			var unusedSince sql.NullInt64
+			if err := rows.Scan(&filename, &unusedSince); err != nil {
+				rows.Close()
+				return err
+			}
+			switch {
+			case unused[filename] && !unusedSince.Valid:
+				mark = append(mark, filename)
+			case !unused[filename] && unusedSince.Valid:
+				unmark = append(unmark, filename)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, filename := range mark {
+			if _, err := tx.ExecContext(ctx, `UPDATE media SET unused_since = ? WHERE collection_id = ? AND filename = ?`, now.Unix(), collectionID, filename); err != nil {
+				return err
+			}
+		}
+		for _, filename := range unmark {
+			if _, err := tx.ExecContext(ctx, `UPDATE media SET unused_since = NULL WHERE collection_id = ? AND filename = ?`, collectionID, filename); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteMediaUnusedBefore deletes a collection's files that have been marked unused
+// since before cutoff and returns their names.
+func (s *SQLStore) DeleteMediaUnusedBefore(ctx context.Context, collectionID string, cutoff time.Time) ([]string, error) {
+	var filenames []string
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT filename FROM media WHERE collection_id = ? AND unused_since IS NOT NULL AND unused_since <= ? ORDER BY filename`, collectionID, cutoff.Unix())
+		if err != nil {
+			return err
+		}
+		filenames = nil
+		for rows.Next() {
+			var filename string
+			if err := rows.Scan(&filename); err != nil {
+				rows.Close()
+				return err
+			}
+			filenames = append(filenames, filename)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM media WHERE collection_id = ? AND unused_since IS NOT NULL AND unused_since <= ?`, collectionID, cutoff.Unix())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return filenames, nil
+}
+
+// ListCollectionIDs returns the ID of every collection.
+func (s *SQLStore) ListCollectionIDs(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM collections ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (s *SQLStore) DeleteMedia(ctx context.Context, filename string) error {
 	query := `DELETE FROM media WHERE filename = ?`
-	_, err := s.db.Exec(query, filename)
+	_, err := s.db.ExecContext(ctx, query, filename)
 	return err
 }
 
-// GetDeckStats returns card counts by state for a deck
-func (s *SQLiteStore) GetDeckStats(deckID int64) (*DeckStats, error) {
+// GetDeckStats returns card counts by state for a deck, including the cards of all its
+// sub-decks as Anki's deck list does.
+func (s *SQLStore) GetDeckStats(ctx context.Context, deckID int64) (*DeckStats, error) {
+	collectionID, dayStart, dayEndTime, err := s.studyDayForDeck(ctx, deckID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.unburyPastDays(ctx, collectionID, dayStart); err != nil {
+		return nil, err
+	}
+
 	stats := &DeckStats{DeckID: deckID}
-	now := time.Now().Unix()
+	dayEnd := dayEndTime.Unix()
 
-	// Get all cards for the deck
-	query := `SELECT state, suspended, due FROM cards WHERE deck_id = ?`
-	rows, err := s.db.Query(query, deckID)
+	// Get all cards for the deck and its descendants
+	query := deckSubtreeCTE + ` SELECT state, suspended, buried_at, due FROM cards WHERE deck_id IN (SELECT id FROM subtree)`
+	rows, err := s.db.QueryContext(ctx, query, deckID)
 	if err != nil {
 		return nil, err
 	}
@@ -1582,9 +2369,9 @@ func (s *SQLiteStore) GetDeckStats(deckID int64) (*DeckStats, error) {
 
 	for rows.Next() {
 		var state, suspended int
-		var due int64
+		var buriedAt, due int64
 
-		if err := rows.Scan(&state, &suspended, &due); err != nil {
+		if err := rows.Scan(&state, &suspended, &buriedAt, &due); err != nil {
 			return nil, err
 		}
 
@@ -1594,54 +2381,73 @@ func (s *SQLiteStore) GetDeckStats(deckID int64) (*DeckStats, error) {
 			stats.Suspended++
 			continue
 		}
+		if buriedAt != 0 {
+			stats.Buried++
+			continue
+		}
 
 		// fsrs.State values: New=0, Learning=1, Review=2, Relearning=3
 		switch state {
 		case 0: // New
 			stats.NewCards++
-			if due <= now {
+			if due < dayEnd {
 				stats.DueToday++
 			}
 		case 1: // Learning
 			stats.Learning++
-			if due <= now {
+			if due < dayEnd {
 				stats.DueToday++
 			}
 		case 2: // Review
 			stats.Review++
-			if due <= now {
+			if due < dayEnd {
 				stats.DueToday++
 				stats.DueReviewBacklog++
 			}
 		case 3: // Relearning
 			stats.Relearning++
-			if due <= now {
+			if due < dayEnd {
 				stats.DueToday++
 				stats.DueReviewBacklog++
 			}
 		}
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
 
+	if err := s.applyDeckLimits(ctx, "", stats); err != nil {
+		return nil, err
+	}
 	return stats, nil
 }
 
-func (s *SQLiteStore) GetDeckStatsForUser(userID string, deckID int64) (*DeckStats, error) {
+// GetDeckStatsForUser is GetDeckStats over the user's own review state.
+func (s *SQLStore) GetDeckStatsForUser(ctx context.Context, userID string, deckID int64) (*DeckStats, error) {
 	if strings.TrimSpace(userID) == "" {
-		return s.GetDeckStats(deckID)
+		return s.GetDeckStats(ctx, deckID)
+	}
+	collectionID, dayStart, dayEndTime, err := s.studyDayForDeck(ctx, deckID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.unburyPastDays(ctx, collectionID, dayStart); err != nil {
+		return nil, err
 	}
-	if err := s.EnsureReviewStatesForUser(userID); err != nil {
+	if err := s.EnsureReviewStatesForUser(ctx, userID); err != nil {
 		return nil, err
 	}
 
 	stats := &DeckStats{DeckID: deckID}
-	now := time.Now().Unix()
+	dayEnd := dayEndTime.Unix()
 
-	rows, err := s.db.Query(`
-		SELECT rs.state, rs.suspended, rs.due
+	rows, err := s.db.QueryContext(ctx, deckSubtreeCTE+`
+		SELECT rs.state, rs.suspended, rs.buried_at, rs.due
 		FROM cards c
 		JOIN card_review_states rs ON rs.card_id = c.id
-		WHERE rs.user_id = ? AND c.deck_id = ?
-	`, userID, deckID)
+		WHERE c.deck_id IN (SELECT id FROM subtree) AND rs.user_id = ?
+	`, deckID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -1649,9 +2455,9 @@ func (s *SQLiteStore) GetDeckStatsForUser(userID string, deckID int64) (*DeckSta
 
 	for rows.Next() {
 		var state, suspended int
-		var due int64
+		var buriedAt, due int64
 
-		if err := rows.Scan(&state, &suspended, &due); err != nil {
+		if err := rows.Scan(&state, &suspended, &buriedAt, &due); err != nil {
 			return nil, err
 		}
 
@@ -1661,69 +2467,96 @@ func (s *SQLiteStore) GetDeckStatsForUser(userID string, deckID int64) (*DeckSta
 			stats.Suspended++
 			continue
 		}
+		if buriedAt != 0 {
+			stats.Buried++
+			continue
+		}
 
 		switch state {
 		case int(fsrs.New):
 			stats.NewCards++
-			if due <= now {
+			if due < dayEnd {
 				stats.DueToday++
 			}
 		case int(fsrs.Learning):
 			stats.Learning++
-			if due <= now {
+			if due < dayEnd {
 				stats.DueToday++
 			}
 		case int(fsrs.Review):
 			stats.Review++
-			if due <= now {
+			if due < dayEnd {
 				stats.DueToday++
 				stats.DueReviewBacklog++
 			}
 		case int(fsrs.Relearning):
 			stats.Relearning++
-			if due <= now {
+			if due < dayEnd {
 				stats.DueToday++
 				stats.DueReviewBacklog++
 			}
 		}
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
 
-	return stats, rows.Err()
+	if err := s.applyDeckLimits(ctx, userID, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
 }
 
-func (s *SQLiteStore) CountDueCardsForUser(userID string) (int, error) {
+// CountDueCardsForUser counts the user's cards in a collection that fall due before its
+// current study day ends.
+func (s *SQLStore) CountDueCardsForUser(ctx context.Context, userID, collectionID string) (int, error) {
+	dayStart, dayEnd, err := s.studyDay(ctx, collectionID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if err := s.unburyPastDays(ctx, collectionID, dayStart); err != nil {
+		return 0, err
+	}
 	if strings.TrimSpace(userID) == "" {
 		var count int
-		err := s.db.QueryRow(`SELECT COUNT(*) FROM cards WHERE suspended = 0 AND due <= ?`, time.Now().Unix()).Scan(&count)
+		err := s.db.QueryRowContext(ctx, `
+			SELECT COUNT(*)
+			FROM cards c
+			JOIN decks d ON d.id = c.deck_id
+			WHERE d.collection_id = ? AND c.suspended = 0 AND c.buried_at = 0 AND c.due < ?
+		`, collectionID, dayEnd.Unix()).Scan(&count)
 		return count, err
 	}
-	if err := s.EnsureReviewStatesForUser(userID); err != nil {
+	if err := s.EnsureReviewStatesForUser(ctx, userID); err != nil {
 		return 0, err
 	}
 
 	var count int
-	err := s.db.QueryRow(`
+	err = s.db.QueryRowContext(ctx, `
 		SELECT COUNT(*)
-		FROM card_review_states
-		WHERE user_id = ? AND suspended = 0 AND due <= ?
-	`, userID, time.Now().Unix()).Scan(&count)
+		FROM card_review_states rs
+		JOIN cards c ON c.id = rs.card_id
+		JOIN decks d ON d.id = c.deck_id
+		WHERE rs.user_id = ? AND d.collection_id = ? AND rs.suspended = 0 AND rs.buried_at = 0 AND rs.due < ?
+	`, userID, collectionID, dayEnd.Unix()).Scan(&count)
 	return count, err
 }
 
 // Profile methods (Task 0003)
 
-func (s *SQLiteStore) CreateProfile(p *Profile) error {
+func (s *SQLStore) CreateProfile(ctx context.Context, p *Profile) error {
 	query := `
 		INSERT INTO profiles (id, name, collection_id, sync_account, created_at)
 		VALUES (?, ?, ?, ?, ?)
 	`
-	_, err := s.db.Exec(query, p.ID, p.Name, p.CollectionID, p.SyncAccount, p.CreatedAt.Unix())
+	_, err := s.db.ExecContext(ctx, query, p.ID, p.Name, p.CollectionID, p.SyncAccount, p.CreatedAt.Unix())
 	return err
 }
 
-func (s *SQLiteStore) GetProfile(id string) (*Profile, error) {
+func (s *SQLStore) GetProfile(ctx context.Context, id string) (*Profile, error) {
 	query := `SELECT id, name, collection_id, sync_account, created_at FROM profiles WHERE id = ?`
-	row := s.db.QueryRow(query, id)
+	row := s.db.QueryRowContext(ctx, query, id)
 
 	var p Profile
 	var syncAccount sql.NullString
@@ -1742,9 +2575,9 @@ func (s *SQLiteStore) GetProfile(id string) (*Profile, error) {
 	return &p, nil
 }
 
-func (s *SQLiteStore) ListProfiles() ([]*Profile, error) {
+func (s *SQLStore) ListProfiles(ctx context.Context) ([]*Profile, error) {
 	query := `SELECT id, name, collection_id, sync_account, created_at FROM profiles ORDER BY name`
-	rows, err := s.db.Query(query)
+	rows, err := s.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -1772,42 +2605,45 @@ func (s *SQLiteStore) ListProfiles() ([]*Profile, error) {
 	return profiles, nil
 }
 
-func (s *SQLiteStore) SetActiveProfile(id string) error {
-	query := `INSERT OR REPLACE INTO metadata (key, value) VALUES ('active_profile', ?)`
-	_, err := s.db.Exec(query, id)
+func (s *SQLStore) SetActiveProfile(ctx context.Context, id string) error {
+	query := `
+		INSERT INTO metadata (key, value) VALUES ('active_profile', ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value
+	`
+	_, err := s.db.ExecContext(ctx, query, id)
 	return err
 }
 
-func (s *SQLiteStore) GetActiveProfile() (*Profile, error) {
+func (s *SQLStore) GetActiveProfile(ctx context.Context) (*Profile, error) {
 	// Get active profile ID from metadata
 	var profileID string
-	err := s.db.QueryRow("SELECT value FROM metadata WHERE key = 'active_profile'").Scan(&profileID)
+	err := s.db.QueryRowContext(ctx, "SELECT value FROM metadata WHERE key = 'active_profile'").Scan(&profileID)
 	if err == sql.ErrNoRows {
 		// No active profile set, return default if exists
-		return s.getOrCreateDefaultProfile()
+		return s.getOrCreateDefaultProfile(ctx)
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	return s.GetProfile(profileID)
+	return s.GetProfile(ctx, profileID)
 }
 
-func (s *SQLiteStore) getOrCreateDefaultProfile() (*Profile, error) {
+func (s *SQLStore) getOrCreateDefaultProfile(ctx context.Context) (*Profile, error) {
 	// Try to get "default" profile
-	profile, err := s.GetProfile("default")
+	profile, err := s.GetProfile(ctx, "default")
 	if err == nil {
 		// Set as active
-		s.SetActiveProfile("default")
+		s.SetActiveProfile(ctx, "default")
 		return profile, nil
 	}
 
 	// Ensure default collection exists first (foreign key constraint)
-	_, colErr := s.GetCollection("default")
+	_, colErr := s.GetCollection(ctx, defaultCollectionID)
 	if colErr != nil {
 		// Create default collection
 		defaultCol := NewCollection()
-		if err := s.CreateCollection(defaultCol); err != nil {
+		if err := s.CreateCollection(ctx, defaultCol); err != nil {
 			return nil, fmt.Errorf("failed to create default collection: %w", err)
 		}
 	}
@@ -1816,15 +2652,15 @@ func (s *SQLiteStore) getOrCreateDefaultProfile() (*Profile, error) {
 	profile = &Profile{
 		ID:           "default",
 		Name:         "Default",
-		CollectionID: "default",
+		CollectionID: defaultCollectionID,
 		SyncAccount:  "",
 		CreatedAt:    time.Now(),
 	}
 
-	if err := s.CreateProfile(profile); err != nil {
+	if err := s.CreateProfile(ctx, profile); err != nil {
 		return nil, err
 	}
 
-	s.SetActiveProfile("default")
+	s.SetActiveProfile(ctx, "default")
 	return profile, nil
 }
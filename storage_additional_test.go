@@ -1,12 +1,13 @@
 package main
 
 import (
+	"context"
 	"path/filepath"
 	"testing"
 	"time"
 )
 
-func setupStoreWithTempDB(t *testing.T) (*SQLiteStore, *Collection) {
+func setupStoreWithTempDB(t *testing.T) (*SQLStore, *Collection) {
 	t.Helper()
 
 	dbPath := filepath.Join(t.TempDir(), "storage-additional.db")
@@ -19,7 +20,7 @@ func setupStoreWithTempDB(t *testing.T) (*SQLiteStore, *Collection) {
 	})
 
 	col := NewCollection()
-	if err := store.CreateCollection(col); err != nil {
+	if err := store.CreateCollection(context.Background(), col); err != nil {
 		t.Fatalf("failed to create collection: %v", err)
 	}
 
@@ -29,7 +30,7 @@ func setupStoreWithTempDB(t *testing.T) (*SQLiteStore, *Collection) {
 func TestSQLiteStore_TransactionsAndCRUDBranches(t *testing.T) {
 	store, _ := setupStoreWithTempDB(t)
 
-	tx, err := store.BeginTx()
+	tx, err := store.BeginTx(context.Background())
 	if err != nil {
 		t.Fatalf("BeginTx failed: %v", err)
 	}
@@ -37,7 +38,7 @@ func TestSQLiteStore_TransactionsAndCRUDBranches(t *testing.T) {
 		t.Fatalf("RollbackTx failed: %v", err)
 	}
 
-	tx2, err := store.BeginTx()
+	tx2, err := store.BeginTx(context.Background())
 	if err != nil {
 		t.Fatalf("BeginTx (second) failed: %v", err)
 	}
@@ -49,11 +50,11 @@ func TestSQLiteStore_TransactionsAndCRUDBranches(t *testing.T) {
 	collection := NewCollection()
 	collection.USN = 77
 	collection.LastSync = now
-	if err := store.UpdateCollection(collection); err != nil {
+	if err := store.UpdateCollection(context.Background(), collection); err != nil {
 		t.Fatalf("UpdateCollection failed: %v", err)
 	}
 
-	loadedCollection, err := store.GetCollection("default")
+	loadedCollection, err := store.GetCollection(context.Background(), "default")
 	if err != nil {
 		t.Fatalf("GetCollection failed: %v", err)
 	}
@@ -63,19 +64,19 @@ func TestSQLiteStore_TransactionsAndCRUDBranches(t *testing.T) {
 
 	deck1 := &Deck{ID: 1, Name: "Deck 1", Cards: []int64{}}
 	deck2 := &Deck{ID: 2, Name: "Deck 2", Cards: []int64{}}
-	if err := store.CreateDeck(deck1); err != nil {
+	if err := store.CreateDeck(context.Background(), deck1); err != nil {
 		t.Fatalf("CreateDeck(deck1) failed: %v", err)
 	}
-	if err := store.CreateDeck(deck2); err != nil {
+	if err := store.CreateDeck(context.Background(), deck2); err != nil {
 		t.Fatalf("CreateDeck(deck2) failed: %v", err)
 	}
 
 	newName := "Deck 1 Updated"
 	deck1.Name = newName
-	if err := store.UpdateDeck(deck1); err != nil {
+	if err := store.UpdateDeck(context.Background(), deck1); err != nil {
 		t.Fatalf("UpdateDeck failed: %v", err)
 	}
-	gotDeck1, err := store.GetDeck(deck1.ID)
+	gotDeck1, err := store.GetDeck(context.Background(), deck1.ID)
 	if err != nil {
 		t.Fatalf("GetDeck(deck1) failed: %v", err)
 	}
@@ -83,10 +84,10 @@ func TestSQLiteStore_TransactionsAndCRUDBranches(t *testing.T) {
 		t.Fatalf("expected updated deck name %q, got %q", newName, gotDeck1.Name)
 	}
 
-	if err := store.DeleteDeck(deck2.ID); err != nil {
+	if err := store.DeleteDeck(context.Background(), deck2.ID); err != nil {
 		t.Fatalf("DeleteDeck(deck2) failed: %v", err)
 	}
-	if _, err := store.GetDeck(deck2.ID); err == nil {
+	if _, err := store.GetDeck(context.Background(), deck2.ID); err == nil {
 		t.Fatalf("expected deleted deck %d to be missing", deck2.ID)
 	}
 
@@ -96,7 +97,7 @@ func TestSQLiteStore_TransactionsAndCRUDBranches(t *testing.T) {
 		Templates:      []CardTemplate{{Name: "Card 1", QFmt: "{{Front}}", AFmt: "{{Back}}"}},
 		SortFieldIndex: 0,
 	}
-	if err := store.CreateNoteType("default", &basic); err != nil {
+	if err := store.CreateNoteType(context.Background(), "default", &basic); err != nil {
 		t.Fatalf("CreateNoteType failed: %v", err)
 	}
 
@@ -109,7 +110,7 @@ func TestSQLiteStore_TransactionsAndCRUDBranches(t *testing.T) {
 		CreatedAt:  now,
 		ModifiedAt: now,
 	}
-	if err := store.CreateNote("default", note); err != nil {
+	if err := store.CreateNote(context.Background(), "default", note); err != nil {
 		t.Fatalf("CreateNote failed: %v", err)
 	}
 
@@ -117,10 +118,10 @@ func TestSQLiteStore_TransactionsAndCRUDBranches(t *testing.T) {
 	note.Tags = []string{"tag-b"}
 	note.USN = 2
 	note.ModifiedAt = now.Add(5 * time.Minute)
-	if err := store.UpdateNote(note); err != nil {
+	if err := store.UpdateNote(context.Background(), note); err != nil {
 		t.Fatalf("UpdateNote failed: %v", err)
 	}
-	gotNote, err := store.GetNote(note.ID)
+	gotNote, err := store.GetNote(context.Background(), note.ID)
 	if err != nil {
 		t.Fatalf("GetNote failed: %v", err)
 	}
@@ -139,11 +140,11 @@ func TestSQLiteStore_TransactionsAndCRUDBranches(t *testing.T) {
 		SRS:          newDueNow(now),
 		USN:          1,
 	}
-	if err := store.CreateCard(card); err != nil {
+	if err := store.CreateCard(context.Background(), card); err != nil {
 		t.Fatalf("CreateCard failed: %v", err)
 	}
 
-	notesByType, err := store.GetNotesByType("default", "Basic")
+	notesByType, err := store.GetNotesByType(context.Background(), "default", "Basic")
 	if err != nil {
 		t.Fatalf("GetNotesByType failed: %v", err)
 	}
@@ -151,7 +152,7 @@ func TestSQLiteStore_TransactionsAndCRUDBranches(t *testing.T) {
 		t.Fatalf("expected 1 note by type, got %d", len(notesByType))
 	}
 
-	cardsByNote, err := store.GetCardsByNote(note.ID)
+	cardsByNote, err := store.GetCardsByNote(context.Background(), note.ID)
 	if err != nil {
 		t.Fatalf("GetCardsByNote failed: %v", err)
 	}
@@ -169,13 +170,13 @@ func TestSQLiteStore_TransactionsAndCRUDBranches(t *testing.T) {
 		CreatedAt:  now,
 		ModifiedAt: now,
 	}
-	if err := store.CreateNote("default", secondNote); err != nil {
+	if err := store.CreateNote(context.Background(), "default", secondNote); err != nil {
 		t.Fatalf("CreateNote(second) failed: %v", err)
 	}
-	if err := store.DeleteNote(secondNote.ID); err != nil {
+	if err := store.DeleteNote(context.Background(), secondNote.ID); err != nil {
 		t.Fatalf("DeleteNote(second) failed: %v", err)
 	}
-	if _, err := store.GetNote(secondNote.ID); err == nil {
+	if _, err := store.GetNote(context.Background(), secondNote.ID); err == nil {
 		t.Fatalf("expected deleted note %d to be missing", secondNote.ID)
 	}
 }
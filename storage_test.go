@@ -1,16 +1,19 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
 	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
 )
 
-func setupTestDB(t *testing.T) (*SQLiteStore, func()) {
+func setupTestDB(t *testing.T) (*SQLStore, func()) {
 	// Create temporary database
 	dbPath := "./test_microdote.db"
 	store, err := NewSQLiteStore(dbPath)
@@ -32,7 +35,7 @@ func TestCreateAndGetDeck(t *testing.T) {
 
 	// Create collection first (required for foreign key)
 	col := NewCollection()
-	if err := store.CreateCollection(col); err != nil {
+	if err := store.CreateCollection(context.Background(), col); err != nil {
 		t.Fatalf("Failed to create collection: %v", err)
 	}
 
@@ -43,13 +46,13 @@ func TestCreateAndGetDeck(t *testing.T) {
 		Cards: []int64{},
 	}
 
-	err := store.CreateDeck(deck)
+	err := store.CreateDeck(context.Background(), deck)
 	if err != nil {
 		t.Fatalf("Failed to create deck: %v", err)
 	}
 
 	// Retrieve the deck
-	retrieved, err := store.GetDeck(1)
+	retrieved, err := store.GetDeck(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("Failed to get deck: %v", err)
 	}
@@ -65,7 +68,7 @@ func TestListDecks(t *testing.T) {
 
 	// Create collection
 	col := NewCollection()
-	store.CreateCollection(col)
+	store.CreateCollection(context.Background(), col)
 
 	// Create multiple decks
 	decks := []*Deck{
@@ -75,13 +78,13 @@ func TestListDecks(t *testing.T) {
 	}
 
 	for _, d := range decks {
-		if err := store.CreateDeck(d); err != nil {
+		if err := store.CreateDeck(context.Background(), d); err != nil {
 			t.Fatalf("Failed to create deck: %v", err)
 		}
 	}
 
 	// List decks
-	retrieved, err := store.ListDecks("default")
+	retrieved, err := store.ListDecks(context.Background(), "default")
 	if err != nil {
 		t.Fatalf("Failed to list decks: %v", err)
 	}
@@ -97,7 +100,7 @@ func TestCreateAndGetNote(t *testing.T) {
 
 	// Create collection
 	col := NewCollection()
-	store.CreateCollection(col)
+	store.CreateCollection(context.Background(), col)
 
 	// Create note type first
 	nt := &NoteType{
@@ -107,7 +110,7 @@ func TestCreateAndGetNote(t *testing.T) {
 			{Name: "Card 1", QFmt: "{{Front}}", AFmt: "{{Back}}"},
 		},
 	}
-	store.CreateNoteType("default", nt)
+	store.CreateNoteType(context.Background(), "default", nt)
 
 	// Create a note
 	note := &Note{
@@ -120,13 +123,13 @@ func TestCreateAndGetNote(t *testing.T) {
 		ModifiedAt: time.Now(),
 	}
 
-	err := store.CreateNote("default", note)
+	err := store.CreateNote(context.Background(), "default", note)
 	if err != nil {
 		t.Fatalf("Failed to create note: %v", err)
 	}
 
 	// Retrieve the note
-	retrieved, err := store.GetNote(1)
+	retrieved, err := store.GetNote(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("Failed to get note: %v", err)
 	}
@@ -146,12 +149,12 @@ func TestCreateAndGetCard(t *testing.T) {
 
 	// Create collection, deck, and note
 	col := NewCollection()
-	store.CreateCollection(col)
-	store.CreateDeck(&Deck{ID: 1, Name: "Test", Cards: []int64{}})
+	store.CreateCollection(context.Background(), col)
+	store.CreateDeck(context.Background(), &Deck{ID: 1, Name: "Test", Cards: []int64{}})
 
 	nt := &NoteType{Name: "Basic", Fields: []string{"Front", "Back"}, Templates: []CardTemplate{{Name: "Card 1", QFmt: "{{Front}}", AFmt: "{{Back}}"}}}
-	store.CreateNoteType("default", nt)
-	store.CreateNote("default", &Note{ID: 1, Type: "Basic", FieldMap: map[string]string{"Front": "Q"}, Tags: []string{}, USN: 1, CreatedAt: time.Now(), ModifiedAt: time.Now()})
+	store.CreateNoteType(context.Background(), "default", nt)
+	store.CreateNote(context.Background(), "default", &Note{ID: 1, Type: "Basic", FieldMap: map[string]string{"Front": "Q"}, Tags: []string{}, USN: 1, CreatedAt: time.Now(), ModifiedAt: time.Now()})
 
 	// Create a card
 	card := &Card{
@@ -169,13 +172,13 @@ func TestCreateAndGetCard(t *testing.T) {
 		USN:          1,
 	}
 
-	err := store.CreateCard(card)
+	err := store.CreateCard(context.Background(), card)
 	if err != nil {
 		t.Fatalf("Failed to create card: %v", err)
 	}
 
 	// Retrieve the card
-	retrieved, err := store.GetCard(1)
+	retrieved, err := store.GetCard(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("Failed to get card: %v", err)
 	}
@@ -195,14 +198,14 @@ func TestGetDueCards(t *testing.T) {
 
 	// Setup collection, deck, note type, and notes
 	col := NewCollection()
-	store.CreateCollection(col)
-	store.CreateDeck(&Deck{ID: 1, Name: "Test", Cards: []int64{}})
+	store.CreateCollection(context.Background(), col)
+	store.CreateDeck(context.Background(), &Deck{ID: 1, Name: "Test", Cards: []int64{}})
 
 	nt := &NoteType{Name: "Basic", Fields: []string{"Front", "Back"}, Templates: []CardTemplate{{Name: "Card 1", QFmt: "{{Front}}", AFmt: "{{Back}}"}}}
-	store.CreateNoteType("default", nt)
+	store.CreateNoteType(context.Background(), "default", nt)
 
 	for i := 1; i <= 3; i++ {
-		store.CreateNote("default", &Note{ID: int64(i), Type: "Basic", FieldMap: map[string]string{"Front": "Q"}, Tags: []string{}, USN: 1, CreatedAt: time.Now(), ModifiedAt: time.Now()})
+		store.CreateNote(context.Background(), "default", &Note{ID: int64(i), Type: "Basic", FieldMap: map[string]string{"Front": "Q"}, Tags: []string{}, USN: 1, CreatedAt: time.Now(), ModifiedAt: time.Now()})
 	}
 
 	now := time.Now()
@@ -216,13 +219,13 @@ func TestGetDueCards(t *testing.T) {
 	}
 
 	for _, c := range cards {
-		if err := store.CreateCard(c); err != nil {
+		if err := store.CreateCard(context.Background(), c); err != nil {
 			t.Fatalf("Failed to create card: %v", err)
 		}
 	}
 
 	// Get due cards
-	due, err := store.GetDueCards(1, 10)
+	due, err := store.GetDueCards(context.Background(), 1, 10)
 	if err != nil {
 		t.Fatalf("Failed to get due cards: %v", err)
 	}
@@ -237,7 +240,7 @@ func TestGetDueCardsDailyLimitsAndBacklogOrder(t *testing.T) {
 	defer cleanup()
 
 	col := NewCollection()
-	if err := store.CreateCollection(col); err != nil {
+	if err := store.CreateCollection(context.Background(), col); err != nil {
 		t.Fatalf("Failed to create collection: %v", err)
 	}
 
@@ -249,18 +252,18 @@ func TestGetDueCardsDailyLimitsAndBacklogOrder(t *testing.T) {
 		t.Fatalf("Failed to create deck options: %v", err)
 	}
 
-	if err := store.CreateDeck(&Deck{ID: 1, Name: "Limited Deck", Cards: []int64{}, OptionsID: &optionsID}); err != nil {
+	if err := store.CreateDeck(context.Background(), &Deck{ID: 1, Name: "Limited Deck", Cards: []int64{}, OptionsID: &optionsID}); err != nil {
 		t.Fatalf("Failed to create deck: %v", err)
 	}
 
 	nt := &NoteType{Name: "Basic", Fields: []string{"Front", "Back"}, Templates: []CardTemplate{{Name: "Card 1", QFmt: "{{Front}}", AFmt: "{{Back}}"}}}
-	if err := store.CreateNoteType("default", nt); err != nil {
+	if err := store.CreateNoteType(context.Background(), "default", nt); err != nil {
 		t.Fatalf("Failed to create note type: %v", err)
 	}
 
 	now := time.Now()
 	for i := 1; i <= 4; i++ {
-		if err := store.CreateNote("default", &Note{
+		if err := store.CreateNote(context.Background(), "default", &Note{
 			ID:         int64(i),
 			Type:       "Basic",
 			FieldMap:   map[string]string{"Front": "Q", "Back": "A"},
@@ -283,20 +286,20 @@ func TestGetDueCardsDailyLimitsAndBacklogOrder(t *testing.T) {
 	newNewer.SRS.State = fsrs.New
 
 	for _, card := range []*Card{reviewOlder, reviewNewer, newOlder, newNewer} {
-		if err := store.CreateCard(card); err != nil {
+		if err := store.CreateCard(context.Background(), card); err != nil {
 			t.Fatalf("Failed to create card %d: %v", card.ID, err)
 		}
 	}
 
 	// Consume one review and one new slot for today.
-	if err := store.AddRevlog(&fsrs.ReviewLog{Rating: fsrs.Good, State: fsrs.Review, Review: now}, reviewNewer.ID, 1000); err != nil {
+	if err := store.AddRevlog(context.Background(), &fsrs.ReviewLog{Rating: fsrs.Good, State: fsrs.Review, Review: now}, reviewNewer.ID, 1000); err != nil {
 		t.Fatalf("Failed to create review revlog entry: %v", err)
 	}
-	if err := store.AddRevlog(&fsrs.ReviewLog{Rating: fsrs.Good, State: fsrs.New, Review: now}, newNewer.ID, 1000); err != nil {
+	if err := store.AddRevlog(context.Background(), &fsrs.ReviewLog{Rating: fsrs.Good, State: fsrs.New, Review: now}, newNewer.ID, 1000); err != nil {
 		t.Fatalf("Failed to create new revlog entry: %v", err)
 	}
 
-	dueCards, err := store.GetDueCards(1, 10)
+	dueCards, err := store.GetDueCards(context.Background(), 1, 10)
 	if err != nil {
 		t.Fatalf("Failed to get due cards: %v", err)
 	}
@@ -320,7 +323,7 @@ func TestProfileCRUD(t *testing.T) {
 
 	// Create collection first
 	col := NewCollection()
-	store.CreateCollection(col)
+	store.CreateCollection(context.Background(), col)
 
 	// Create profile
 	profile := &Profile{
@@ -331,13 +334,13 @@ func TestProfileCRUD(t *testing.T) {
 		CreatedAt:    time.Now(),
 	}
 
-	err := store.CreateProfile(profile)
+	err := store.CreateProfile(context.Background(), profile)
 	if err != nil {
 		t.Fatalf("Failed to create profile: %v", err)
 	}
 
 	// Get profile
-	retrieved, err := store.GetProfile("test-profile")
+	retrieved, err := store.GetProfile(context.Background(), "test-profile")
 	if err != nil {
 		t.Fatalf("Failed to get profile: %v", err)
 	}
@@ -347,7 +350,7 @@ func TestProfileCRUD(t *testing.T) {
 	}
 
 	// List profiles
-	profiles, err := store.ListProfiles()
+	profiles, err := store.ListProfiles(context.Background())
 	if err != nil {
 		t.Fatalf("Failed to list profiles: %v", err)
 	}
@@ -357,12 +360,12 @@ func TestProfileCRUD(t *testing.T) {
 	}
 
 	// Set and get active profile
-	err = store.SetActiveProfile("test-profile")
+	err = store.SetActiveProfile(context.Background(), "test-profile")
 	if err != nil {
 		t.Fatalf("Failed to set active profile: %v", err)
 	}
 
-	active, err := store.GetActiveProfile()
+	active, err := store.GetActiveProfile(context.Background())
 	if err != nil {
 		t.Fatalf("Failed to get active profile: %v", err)
 	}
@@ -378,15 +381,15 @@ func TestCollectionIDCounters(t *testing.T) {
 
 	// Create collection with some data
 	col := NewCollection()
-	store.CreateCollection(col)
+	store.CreateCollection(context.Background(), col)
 
 	// Create decks with specific IDs
-	store.CreateDeck(&Deck{ID: 1, Name: "Deck 1", Cards: []int64{}})
-	store.CreateDeck(&Deck{ID: 5, Name: "Deck 5", Cards: []int64{}})
-	store.CreateDeck(&Deck{ID: 3, Name: "Deck 3", Cards: []int64{}})
+	store.CreateDeck(context.Background(), &Deck{ID: 1, Name: "Deck 1", Cards: []int64{}})
+	store.CreateDeck(context.Background(), &Deck{ID: 5, Name: "Deck 5", Cards: []int64{}})
+	store.CreateDeck(context.Background(), &Deck{ID: 3, Name: "Deck 3", Cards: []int64{}})
 
 	// Load collection
-	loaded, err := store.GetCollection("default")
+	loaded, err := store.GetCollection(context.Background(), "default")
 	if err != nil {
 		t.Fatalf("Failed to load collection: %v", err)
 	}
@@ -403,13 +406,85 @@ func TestCollectionIDCounters(t *testing.T) {
 	}
 }
 
+func TestSQLiteStoreConcurrentWrites(t *testing.T) {
+	store, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	var mode string
+	if err := store.db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil {
+		t.Fatalf("Failed to read journal mode: %v", err)
+	}
+	if mode != "wal" {
+		t.Fatalf("Expected WAL journal mode, got %q", mode)
+	}
+	col := NewCollection()
+	if err := store.CreateCollection(context.Background(), col); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	// Writers, each in its own transaction, with readers alongside them.
+	const writers = 32
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			tx, err := store.BeginTx(context.Background())
+			if err != nil {
+				errs <- err
+				return
+			}
+			if _, err := tx.Exec(`INSERT INTO decks (id, collection_id, name) VALUES (?, ?, ?)`, 100+i, col.ID, fmt.Sprintf("Deck %d", i)); err != nil {
+				_ = tx.Rollback()
+				errs <- err
+				return
+			}
+			errs <- tx.Commit()
+		}(i)
+		go func() {
+			defer wg.Done()
+			_, err := store.ListDecks(context.Background(), col.ID)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Concurrent access failed: %v", err)
+		}
+	}
+}
+
+func TestSQLiteStoreStopsOnCanceledContext(t *testing.T) {
+	store, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	col := NewCollection()
+	if err := store.CreateCollection(context.Background(), col); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.ListDecks(ctx, col.ID); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a canceled query, got %v", err)
+	}
+	if err := store.CreateDeck(ctx, &Deck{ID: 42, Name: "Never", Cards: []int64{}}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a canceled insert, got %v", err)
+	}
+	if _, err := store.GetDeck(context.Background(), 42); err == nil {
+		t.Fatal("Expected the canceled insert to leave no deck")
+	}
+}
+
 func TestBackupCreation(t *testing.T) {
 	store, cleanup := setupTestDB(t)
 	defer cleanup()
 
 	// Create collection
 	col := NewCollection()
-	store.CreateCollection(col)
+	store.CreateCollection(context.Background(), col)
 
 	// Create backup manager
 	backupDir := "./test_backups"
@@ -419,7 +494,7 @@ func TestBackupCreation(t *testing.T) {
 	bm := NewBackupManager("./test_microdote.db", backupDir, store)
 
 	// Create backup
-	backupPath, err := bm.CreateBackup("default")
+	backupPath, err := bm.CreateBackup(context.Background(), "default")
 	if err != nil {
 		t.Fatalf("Failed to create backup: %v", err)
 	}
@@ -438,11 +513,11 @@ func TestGetDeckStats(t *testing.T) {
 
 	// Setup
 	col := NewCollection()
-	store.CreateCollection(col)
-	store.CreateDeck(&Deck{ID: 1, Name: "Test Deck", Cards: []int64{}})
+	store.CreateCollection(context.Background(), col)
+	store.CreateDeck(context.Background(), &Deck{ID: 1, Name: "Test Deck", Cards: []int64{}})
 
 	nt := &NoteType{Name: "Basic", Fields: []string{"Front", "Back"}, Templates: []CardTemplate{{Name: "Card 1", QFmt: "{{Front}}", AFmt: "{{Back}}"}}}
-	store.CreateNoteType("default", nt)
+	store.CreateNoteType(context.Background(), "default", nt)
 
 	// Create cards with different states
 	now := time.Now()
@@ -451,7 +526,7 @@ func TestGetDeckStats(t *testing.T) {
 
 	// Create notes
 	for i := 1; i <= 5; i++ {
-		store.CreateNote("default", &Note{
+		store.CreateNote(context.Background(), "default", &Note{
 			ID:         int64(i),
 			Type:       "Basic",
 			FieldMap:   map[string]string{"Front": "Q", "Back": "A"},
@@ -475,13 +550,13 @@ func TestGetDeckStats(t *testing.T) {
 	cards[2].SRS.State = 2 // Review state
 
 	for _, c := range cards {
-		if err := store.CreateCard(c); err != nil {
+		if err := store.CreateCard(context.Background(), c); err != nil {
 			t.Fatalf("Failed to create card: %v", err)
 		}
 	}
 
 	// Get deck stats
-	stats, err := store.GetDeckStats(1)
+	stats, err := store.GetDeckStats(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("Failed to get deck stats: %v", err)
 	}
@@ -516,17 +591,17 @@ func TestStudyFlow(t *testing.T) {
 
 	// Setup collection
 	col := NewCollection()
-	store.CreateCollection(col)
+	store.CreateCollection(context.Background(), col)
 
 	// Add built-in note types
 	noteTypes := builtins()
 	for _, nt := range noteTypes {
-		store.CreateNoteType("default", &nt)
+		store.CreateNoteType(context.Background(), "default", &nt)
 	}
 
 	// Create a deck
 	deck := &Deck{ID: 1, Name: "Study Deck", Cards: []int64{}}
-	store.CreateDeck(deck)
+	store.CreateDeck(context.Background(), deck)
 
 	// Create a note
 	note := &Note{
@@ -538,7 +613,7 @@ func TestStudyFlow(t *testing.T) {
 		CreatedAt:  time.Now(),
 		ModifiedAt: time.Now(),
 	}
-	store.CreateNote("default", note)
+	store.CreateNote(context.Background(), "default", note)
 
 	// Create a card
 	now := time.Now()
@@ -556,10 +631,10 @@ func TestStudyFlow(t *testing.T) {
 		Suspended:    false,
 		USN:          1,
 	}
-	store.CreateCard(card)
+	store.CreateCard(context.Background(), card)
 
 	// Get due cards
-	dueCards, err := store.GetDueCards(1, 10)
+	dueCards, err := store.GetDueCards(context.Background(), 1, 10)
 	if err != nil {
 		t.Fatalf("Failed to get due cards: %v", err)
 	}
@@ -579,13 +654,13 @@ func TestStudyFlow(t *testing.T) {
 
 	// Update card in database
 	updatedCard := col.Cards[1]
-	err = store.UpdateCard(updatedCard)
+	err = store.UpdateCard(context.Background(), updatedCard)
 	if err != nil {
 		t.Fatalf("Failed to update card: %v", err)
 	}
 
 	// Verify card was rescheduled (should have future due date)
-	retrievedCard, err := store.GetCard(1)
+	retrievedCard, err := store.GetCard(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("Failed to retrieve card: %v", err)
 	}
@@ -594,14 +669,16 @@ func TestStudyFlow(t *testing.T) {
 		t.Error("Expected card to be rescheduled to future date")
 	}
 
-	// Card should no longer be due
-	dueCardsAfter, err := store.GetDueCards(1, 10)
+	// Card should no longer be due, except as a learning card inside the learn-ahead window
+	dueCardsAfter, err := store.GetDueCards(context.Background(), 1, 10)
 	if err != nil {
 		t.Fatalf("Failed to get due cards after answer: %v", err)
 	}
 
-	if len(dueCardsAfter) != 0 {
-		t.Errorf("Expected 0 due cards after answering, got %d", len(dueCardsAfter))
+	for _, card := range dueCardsAfter {
+		if card.SRS.State != fsrs.Learning || card.SRS.Due.After(time.Now().Add(learnAheadWindow)) {
+			t.Errorf("Expected only learn-ahead cards after answering, got %+v", card.SRS)
+		}
 	}
 }
 
@@ -611,11 +688,11 @@ func TestAnswerCardMultipleRatings(t *testing.T) {
 
 	// Setup
 	col := NewCollection()
-	store.CreateCollection(col)
-	store.CreateDeck(&Deck{ID: 1, Name: "Test", Cards: []int64{}})
+	store.CreateCollection(context.Background(), col)
+	store.CreateDeck(context.Background(), &Deck{ID: 1, Name: "Test", Cards: []int64{}})
 
 	nt := &NoteType{Name: "Basic", Fields: []string{"Front", "Back"}, Templates: []CardTemplate{{Name: "Card 1", QFmt: "{{Front}}", AFmt: "{{Back}}"}}}
-	store.CreateNoteType("default", nt)
+	store.CreateNoteType(context.Background(), "default", nt)
 
 	now := time.Now()
 
@@ -627,7 +704,7 @@ func TestAnswerCardMultipleRatings(t *testing.T) {
 		cardID := int64(i + 1)
 
 		// Create note
-		store.CreateNote("default", &Note{
+		store.CreateNote(context.Background(), "default", &Note{
 			ID:         noteID,
 			Type:       "Basic",
 			FieldMap:   map[string]string{"Front": "Q", "Back": "A"},
@@ -648,7 +725,7 @@ func TestAnswerCardMultipleRatings(t *testing.T) {
 			SRS:          newDueNow(now),
 			USN:          1,
 		}
-		store.CreateCard(card)
+		store.CreateCard(context.Background(), card)
 
 		// Answer with different ratings
 		col.Cards = make(map[int64]*Card)
@@ -673,11 +750,11 @@ func TestEmptyDeckStudy(t *testing.T) {
 
 	// Create collection and empty deck
 	col := NewCollection()
-	store.CreateCollection(col)
-	store.CreateDeck(&Deck{ID: 1, Name: "Empty Deck", Cards: []int64{}})
+	store.CreateCollection(context.Background(), col)
+	store.CreateDeck(context.Background(), &Deck{ID: 1, Name: "Empty Deck", Cards: []int64{}})
 
 	// Get due cards from empty deck
-	dueCards, err := store.GetDueCards(1, 10)
+	dueCards, err := store.GetDueCards(context.Background(), 1, 10)
 	if err != nil {
 		t.Fatalf("Failed to get due cards: %v", err)
 	}
@@ -687,7 +764,7 @@ func TestEmptyDeckStudy(t *testing.T) {
 	}
 
 	// Get stats for empty deck
-	stats, err := store.GetDeckStats(1)
+	stats, err := store.GetDeckStats(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("Failed to get stats for empty deck: %v", err)
 	}
@@ -716,7 +793,7 @@ func TestAddNoteWithTags(t *testing.T) {
 
 	// Create a deck
 	deck := col.NewDeck("Test Deck")
-	store.CreateDeck(deck)
+	store.CreateDeck(context.Background(), deck)
 
 	// Add a note with tags using Basic note type
 	note, cards, err := col.AddNote(deck.ID, "Basic", map[string]string{
@@ -732,21 +809,21 @@ func TestAddNoteWithTags(t *testing.T) {
 	note.Tags = []string{"geography", "europe", "capitals"}
 
 	// Persist note
-	err = store.CreateNote("default", &note)
+	err = store.CreateNote(context.Background(), "default", &note)
 	if err != nil {
 		t.Fatalf("Failed to persist note: %v", err)
 	}
 
 	// Persist cards
 	for _, card := range cards {
-		err = store.CreateCard(card)
+		err = store.CreateCard(context.Background(), card)
 		if err != nil {
 			t.Fatalf("Failed to persist card: %v", err)
 		}
 	}
 
 	// Verify note was created with correct fields
-	retrievedNote, err := store.GetNote(note.ID)
+	retrievedNote, err := store.GetNote(context.Background(), note.ID)
 	if err != nil {
 		t.Fatalf("Failed to retrieve note: %v", err)
 	}
@@ -808,7 +885,7 @@ func TestClozeNoteCreation(t *testing.T) {
 
 	// Create a deck
 	deck := col.NewDeck("Cloze Test Deck")
-	store.CreateDeck(deck)
+	store.CreateDeck(context.Background(), deck)
 
 	// Add a cloze note - {{c1::}} creates cloze deletions
 	note, cards, err := col.AddNote(deck.ID, "Cloze", map[string]string{
@@ -845,7 +922,7 @@ func TestFindDuplicateNotes(t *testing.T) {
 
 	// Create a deck
 	deck := col.NewDeck("Test Deck")
-	store.CreateDeck(deck)
+	store.CreateDeck(context.Background(), deck)
 
 	// Create first note with Front="Hello World"
 	note1, cards1, err := col.AddNote(deck.ID, "Basic", map[string]string{
@@ -855,9 +932,9 @@ func TestFindDuplicateNotes(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to add note: %v", err)
 	}
-	store.CreateNote("default", &note1)
+	store.CreateNote(context.Background(), "default", &note1)
 	for _, card := range cards1 {
-		store.CreateCard(card)
+		store.CreateCard(context.Background(), card)
 	}
 
 	// Create second note with different content
@@ -868,13 +945,13 @@ func TestFindDuplicateNotes(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to add note: %v", err)
 	}
-	store.CreateNote("default", &note2)
+	store.CreateNote(context.Background(), "default", &note2)
 	for _, card := range cards2 {
-		store.CreateCard(card)
+		store.CreateCard(context.Background(), card)
 	}
 
 	// Test 1: Check for exact duplicate - should find one
-	duplicates, err := store.FindDuplicateNotes("default", "Front", "Hello World", 0)
+	duplicates, err := store.FindDuplicateNotes(context.Background(), "default", "Front", "Hello World", 0)
 	if err != nil {
 		t.Fatalf("Failed to find duplicates: %v", err)
 	}
@@ -883,7 +960,7 @@ func TestFindDuplicateNotes(t *testing.T) {
 	}
 
 	// Test 2: Check for case-insensitive duplicate
-	duplicates, err = store.FindDuplicateNotes("default", "Front", "hello world", 0)
+	duplicates, err = store.FindDuplicateNotes(context.Background(), "default", "Front", "hello world", 0)
 	if err != nil {
 		t.Fatalf("Failed to find duplicates: %v", err)
 	}
@@ -892,7 +969,7 @@ func TestFindDuplicateNotes(t *testing.T) {
 	}
 
 	// Test 3: Check for non-existent content - should find none
-	duplicates, err = store.FindDuplicateNotes("default", "Front", "Non-existent question", 0)
+	duplicates, err = store.FindDuplicateNotes(context.Background(), "default", "Front", "Non-existent question", 0)
 	if err != nil {
 		t.Fatalf("Failed to find duplicates: %v", err)
 	}
@@ -901,7 +978,7 @@ func TestFindDuplicateNotes(t *testing.T) {
 	}
 
 	// Test 4: Check with whitespace normalization
-	duplicates, err = store.FindDuplicateNotes("default", "Front", "  Hello World  ", 0)
+	duplicates, err = store.FindDuplicateNotes(context.Background(), "default", "Front", "  Hello World  ", 0)
 	if err != nil {
 		t.Fatalf("Failed to find duplicates: %v", err)
 	}
@@ -923,8 +1000,8 @@ func TestFindDuplicateNotesWithDeckFilter(t *testing.T) {
 	// Create two decks
 	deck1 := col.NewDeck("Deck 1")
 	deck2 := col.NewDeck("Deck 2")
-	store.CreateDeck(deck1)
-	store.CreateDeck(deck2)
+	store.CreateDeck(context.Background(), deck1)
+	store.CreateDeck(context.Background(), deck2)
 
 	// Create note in deck1
 	note1, cards1, err := col.AddNote(deck1.ID, "Basic", map[string]string{
@@ -934,9 +1011,9 @@ func TestFindDuplicateNotesWithDeckFilter(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to add note: %v", err)
 	}
-	store.CreateNote("default", &note1)
+	store.CreateNote(context.Background(), "default", &note1)
 	for _, card := range cards1 {
-		store.CreateCard(card)
+		store.CreateCard(context.Background(), card)
 	}
 
 	// Create note in deck2
@@ -947,13 +1024,13 @@ func TestFindDuplicateNotesWithDeckFilter(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to add note: %v", err)
 	}
-	store.CreateNote("default", &note2)
+	store.CreateNote(context.Background(), "default", &note2)
 	for _, card := range cards2 {
-		store.CreateCard(card)
+		store.CreateCard(context.Background(), card)
 	}
 
 	// Test 1: Without deck filter - should find 2
-	duplicates, err := store.FindDuplicateNotes("default", "Front", "Same Question", 0)
+	duplicates, err := store.FindDuplicateNotes(context.Background(), "default", "Front", "Same Question", 0)
 	if err != nil {
 		t.Fatalf("Failed to find duplicates: %v", err)
 	}
@@ -962,7 +1039,7 @@ func TestFindDuplicateNotesWithDeckFilter(t *testing.T) {
 	}
 
 	// Test 2: With deck1 filter - should find 1
-	duplicates, err = store.FindDuplicateNotes("default", "Front", "Same Question", deck1.ID)
+	duplicates, err = store.FindDuplicateNotes(context.Background(), "default", "Front", "Same Question", deck1.ID)
 	if err != nil {
 		t.Fatalf("Failed to find duplicates: %v", err)
 	}
@@ -971,7 +1048,7 @@ func TestFindDuplicateNotesWithDeckFilter(t *testing.T) {
 	}
 
 	// Test 3: With deck2 filter - should find 1
-	duplicates, err = store.FindDuplicateNotes("default", "Front", "Same Question", deck2.ID)
+	duplicates, err = store.FindDuplicateNotes(context.Background(), "default", "Front", "Same Question", deck2.ID)
 	if err != nil {
 		t.Fatalf("Failed to find duplicates: %v", err)
 	}
@@ -985,10 +1062,10 @@ func TestFindDuplicateNotesEmptyValue(t *testing.T) {
 	defer cleanup()
 
 	col := NewCollection()
-	store.CreateCollection(col)
+	store.CreateCollection(context.Background(), col)
 
 	// Empty value should return empty result
-	duplicates, err := store.FindDuplicateNotes("default", "Front", "", 0)
+	duplicates, err := store.FindDuplicateNotes(context.Background(), "default", "Front", "", 0)
 	if err != nil {
 		t.Fatalf("Failed to check empty value: %v", err)
 	}
@@ -997,7 +1074,7 @@ func TestFindDuplicateNotesEmptyValue(t *testing.T) {
 	}
 
 	// Whitespace-only value should return empty result (normalized to empty)
-	duplicates, err = store.FindDuplicateNotes("default", "Front", "   ", 0)
+	duplicates, err = store.FindDuplicateNotes(context.Background(), "default", "Front", "   ", 0)
 	if err != nil {
 		t.Fatalf("Failed to check whitespace value: %v", err)
 	}
@@ -1013,7 +1090,7 @@ func TestUpdateNoteType(t *testing.T) {
 	defer cleanup()
 
 	col := NewCollection()
-	store.CreateCollection(col)
+	store.CreateCollection(context.Background(), col)
 
 	// Create a note type
 	nt := &NoteType{
@@ -1025,17 +1102,17 @@ func TestUpdateNoteType(t *testing.T) {
 			AFmt: "{{Back}}",
 		}},
 	}
-	store.CreateNoteType("default", nt)
+	store.CreateNoteType(context.Background(), "default", nt)
 
 	// Update the note type - add a field
 	nt.Fields = []string{"Front", "Back", "Extra"}
-	err := store.UpdateNoteType("default", nt)
+	err := store.UpdateNoteType(context.Background(), "default", nt)
 	if err != nil {
 		t.Fatalf("Failed to update note type: %v", err)
 	}
 
 	// Verify the update
-	retrieved, err := store.GetNoteType("default", "TestType")
+	retrieved, err := store.GetNoteType(context.Background(), "default", "TestType")
 	if err != nil {
 		t.Fatalf("Failed to get note type: %v", err)
 	}
@@ -1054,7 +1131,7 @@ func TestFieldReorder(t *testing.T) {
 	defer cleanup()
 
 	col := NewCollection()
-	store.CreateCollection(col)
+	store.CreateCollection(context.Background(), col)
 
 	// Create a note type
 	nt := &NoteType{
@@ -1066,17 +1143,17 @@ func TestFieldReorder(t *testing.T) {
 			AFmt: "{{Field2}}",
 		}},
 	}
-	store.CreateNoteType("default", nt)
+	store.CreateNoteType(context.Background(), "default", nt)
 
 	// Reorder fields
 	nt.Fields = []string{"Field3", "Field1", "Field2"}
-	err := store.UpdateNoteType("default", nt)
+	err := store.UpdateNoteType(context.Background(), "default", nt)
 	if err != nil {
 		t.Fatalf("Failed to reorder fields: %v", err)
 	}
 
 	// Verify the reorder
-	retrieved, err := store.GetNoteType("default", "ReorderTest")
+	retrieved, err := store.GetNoteType(context.Background(), "default", "ReorderTest")
 	if err != nil {
 		t.Fatalf("Failed to get note type: %v", err)
 	}
@@ -1097,14 +1174,14 @@ func TestUpdateCardFlag(t *testing.T) {
 
 	// Setup
 	col := NewCollection()
-	store.CreateCollection(col)
-	store.CreateDeck(&Deck{ID: 1, Name: "Test", Cards: []int64{}})
+	store.CreateCollection(context.Background(), col)
+	store.CreateDeck(context.Background(), &Deck{ID: 1, Name: "Test", Cards: []int64{}})
 
 	nt := &NoteType{Name: "Basic", Fields: []string{"Front", "Back"}, Templates: []CardTemplate{{Name: "Card 1", QFmt: "{{Front}}", AFmt: "{{Back}}"}}}
-	store.CreateNoteType("default", nt)
+	store.CreateNoteType(context.Background(), "default", nt)
 
 	now := time.Now()
-	store.CreateNote("default", &Note{ID: 1, Type: "Basic", FieldMap: map[string]string{"Front": "Q"}, Tags: []string{}, USN: 1, CreatedAt: now, ModifiedAt: now})
+	store.CreateNote(context.Background(), "default", &Note{ID: 1, Type: "Basic", FieldMap: map[string]string{"Front": "Q"}, Tags: []string{}, USN: 1, CreatedAt: now, ModifiedAt: now})
 
 	// Create card with flag 0
 	card := &Card{
@@ -1118,17 +1195,17 @@ func TestUpdateCardFlag(t *testing.T) {
 		Flag:         0,
 		USN:          1,
 	}
-	store.CreateCard(card)
+	store.CreateCard(context.Background(), card)
 
 	// Update flag to 3 (green)
 	card.Flag = 3
-	err := store.UpdateCard(card)
+	err := store.UpdateCard(context.Background(), card)
 	if err != nil {
 		t.Fatalf("Failed to update card flag: %v", err)
 	}
 
 	// Verify flag was updated
-	retrieved, err := store.GetCard(1)
+	retrieved, err := store.GetCard(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("Failed to get card: %v", err)
 	}
@@ -1143,14 +1220,14 @@ func TestUpdateCardMarked(t *testing.T) {
 
 	// Setup
 	col := NewCollection()
-	store.CreateCollection(col)
-	store.CreateDeck(&Deck{ID: 1, Name: "Test", Cards: []int64{}})
+	store.CreateCollection(context.Background(), col)
+	store.CreateDeck(context.Background(), &Deck{ID: 1, Name: "Test", Cards: []int64{}})
 
 	nt := &NoteType{Name: "Basic", Fields: []string{"Front", "Back"}, Templates: []CardTemplate{{Name: "Card 1", QFmt: "{{Front}}", AFmt: "{{Back}}"}}}
-	store.CreateNoteType("default", nt)
+	store.CreateNoteType(context.Background(), "default", nt)
 
 	now := time.Now()
-	store.CreateNote("default", &Note{ID: 1, Type: "Basic", FieldMap: map[string]string{"Front": "Q"}, Tags: []string{}, USN: 1, CreatedAt: now, ModifiedAt: now})
+	store.CreateNote(context.Background(), "default", &Note{ID: 1, Type: "Basic", FieldMap: map[string]string{"Front": "Q"}, Tags: []string{}, USN: 1, CreatedAt: now, ModifiedAt: now})
 
 	// Create card with marked=false
 	card := &Card{
@@ -1164,17 +1241,17 @@ func TestUpdateCardMarked(t *testing.T) {
 		Marked:       false,
 		USN:          1,
 	}
-	store.CreateCard(card)
+	store.CreateCard(context.Background(), card)
 
 	// Update marked to true
 	card.Marked = true
-	err := store.UpdateCard(card)
+	err := store.UpdateCard(context.Background(), card)
 	if err != nil {
 		t.Fatalf("Failed to update card marked: %v", err)
 	}
 
 	// Verify marked was updated
-	retrieved, err := store.GetCard(1)
+	retrieved, err := store.GetCard(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("Failed to get card: %v", err)
 	}
@@ -1189,14 +1266,14 @@ func TestUpdateCardSuspended(t *testing.T) {
 
 	// Setup
 	col := NewCollection()
-	store.CreateCollection(col)
-	store.CreateDeck(&Deck{ID: 1, Name: "Test", Cards: []int64{}})
+	store.CreateCollection(context.Background(), col)
+	store.CreateDeck(context.Background(), &Deck{ID: 1, Name: "Test", Cards: []int64{}})
 
 	nt := &NoteType{Name: "Basic", Fields: []string{"Front", "Back"}, Templates: []CardTemplate{{Name: "Card 1", QFmt: "{{Front}}", AFmt: "{{Back}}"}}}
-	store.CreateNoteType("default", nt)
+	store.CreateNoteType(context.Background(), "default", nt)
 
 	now := time.Now()
-	store.CreateNote("default", &Note{ID: 1, Type: "Basic", FieldMap: map[string]string{"Front": "Q"}, Tags: []string{}, USN: 1, CreatedAt: now, ModifiedAt: now})
+	store.CreateNote(context.Background(), "default", &Note{ID: 1, Type: "Basic", FieldMap: map[string]string{"Front": "Q"}, Tags: []string{}, USN: 1, CreatedAt: now, ModifiedAt: now})
 
 	// Create card with suspended=false
 	card := &Card{
@@ -1210,17 +1287,17 @@ func TestUpdateCardSuspended(t *testing.T) {
 		Suspended:    false,
 		USN:          1,
 	}
-	store.CreateCard(card)
+	store.CreateCard(context.Background(), card)
 
 	// Update suspended to true
 	card.Suspended = true
-	err := store.UpdateCard(card)
+	err := store.UpdateCard(context.Background(), card)
 	if err != nil {
 		t.Fatalf("Failed to update card suspended: %v", err)
 	}
 
 	// Verify suspended was updated
-	retrieved, err := store.GetCard(1)
+	retrieved, err := store.GetCard(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("Failed to get card: %v", err)
 	}
@@ -1229,7 +1306,7 @@ func TestUpdateCardSuspended(t *testing.T) {
 	}
 
 	// Suspended cards should not appear in due cards
-	dueCards, err := store.GetDueCards(1, 10)
+	dueCards, err := store.GetDueCards(context.Background(), 1, 10)
 	if err != nil {
 		t.Fatalf("Failed to get due cards: %v", err)
 	}
@@ -1245,7 +1322,7 @@ func TestUpdateTemplate(t *testing.T) {
 	defer cleanup()
 
 	col := NewCollection()
-	store.CreateCollection(col)
+	store.CreateCollection(context.Background(), col)
 
 	// Create a note type with a template
 	nt := &NoteType{
@@ -1258,20 +1335,20 @@ func TestUpdateTemplate(t *testing.T) {
 			Styling: "",
 		}},
 	}
-	store.CreateNoteType("default", nt)
+	store.CreateNoteType(context.Background(), "default", nt)
 
 	// Update the template
 	nt.Templates[0].QFmt = "<div class='question'>{{Front}}</div>"
 	nt.Templates[0].AFmt = "<div class='answer'>{{Back}}</div>"
 	nt.Templates[0].Styling = ".card { font-size: 20px; }"
 
-	err := store.UpdateNoteType("default", nt)
+	err := store.UpdateNoteType(context.Background(), "default", nt)
 	if err != nil {
 		t.Fatalf("Failed to update note type: %v", err)
 	}
 
 	// Verify the update
-	retrieved, err := store.GetNoteType("default", "TemplateTest")
+	retrieved, err := store.GetNoteType(context.Background(), "default", "TemplateTest")
 	if err != nil {
 		t.Fatalf("Failed to get note type: %v", err)
 	}
@@ -1308,7 +1385,7 @@ func TestTemplateStylingPersistence(t *testing.T) {
 	basic.Templates[0].QFmt = "<div class='front'>{{Front}}</div>"
 	basic.Templates[0].AFmt = "<div class='back'>{{Back}}</div>"
 
-	err = store.UpdateNoteType("default", &basic)
+	err = store.UpdateNoteType(context.Background(), "default", &basic)
 	if err != nil {
 		t.Fatalf("Failed to update note type: %v", err)
 	}
@@ -1338,7 +1415,7 @@ func TestUpdateMultipleTemplates(t *testing.T) {
 	defer cleanup()
 
 	col := NewCollection()
-	store.CreateCollection(col)
+	store.CreateCollection(context.Background(), col)
 
 	// Create a note type with multiple templates
 	nt := &NoteType{
@@ -1349,20 +1426,20 @@ func TestUpdateMultipleTemplates(t *testing.T) {
 			{Name: "Card 2", QFmt: "{{Back}}", AFmt: "{{Front}}", Styling: ""},
 		},
 	}
-	store.CreateNoteType("default", nt)
+	store.CreateNoteType(context.Background(), "default", nt)
 
 	// Update only the second template
 	nt.Templates[1].QFmt = "Reverse: {{Back}}"
 	nt.Templates[1].AFmt = "Answer: {{Front}}"
 	nt.Templates[1].Styling = ".reverse { color: blue; }"
 
-	err := store.UpdateNoteType("default", nt)
+	err := store.UpdateNoteType(context.Background(), "default", nt)
 	if err != nil {
 		t.Fatalf("Failed to update note type: %v", err)
 	}
 
 	// Verify both templates
-	retrieved, err := store.GetNoteType("default", "MultiTemplate")
+	retrieved, err := store.GetNoteType(context.Background(), "default", "MultiTemplate")
 	if err != nil {
 		t.Fatalf("Failed to get note type: %v", err)
 	}
@@ -1390,7 +1467,7 @@ func TestSetFieldOptions(t *testing.T) {
 	defer cleanup()
 
 	col := NewCollection()
-	store.CreateCollection(col)
+	store.CreateCollection(context.Background(), col)
 
 	// Create a note type with field options
 	nt := &NoteType{
@@ -1400,7 +1477,7 @@ func TestSetFieldOptions(t *testing.T) {
 			{Name: "Card 1", QFmt: "{{Front}}", AFmt: "{{Back}}"},
 		},
 	}
-	store.CreateNoteType("default", nt)
+	store.CreateNoteType(context.Background(), "default", nt)
 
 	// Set field options for "Front" field
 	nt.FieldOptions = map[string]FieldOptions{
@@ -1411,13 +1488,13 @@ func TestSetFieldOptions(t *testing.T) {
 		},
 	}
 
-	err := store.UpdateNoteType("default", nt)
+	err := store.UpdateNoteType(context.Background(), "default", nt)
 	if err != nil {
 		t.Fatalf("Failed to update note type with field options: %v", err)
 	}
 
 	// Retrieve and verify
-	retrieved, err := store.GetNoteType("default", "FieldOptionsTest")
+	retrieved, err := store.GetNoteType(context.Background(), "default", "FieldOptionsTest")
 	if err != nil {
 		t.Fatalf("Failed to get note type: %v", err)
 	}
@@ -1447,7 +1524,7 @@ func TestFieldOptionsRTL(t *testing.T) {
 	defer cleanup()
 
 	col := NewCollection()
-	store.CreateCollection(col)
+	store.CreateCollection(context.Background(), col)
 
 	// Create a note type for RTL testing (e.g., Hebrew/Arabic)
 	nt := &NoteType{
@@ -1464,13 +1541,13 @@ func TestFieldOptionsRTL(t *testing.T) {
 		},
 	}
 
-	err := store.CreateNoteType("default", nt)
+	err := store.CreateNoteType(context.Background(), "default", nt)
 	if err != nil {
 		t.Fatalf("Failed to create note type: %v", err)
 	}
 
 	// Retrieve and verify RTL option
-	retrieved, err := store.GetNoteType("default", "RTLTest")
+	retrieved, err := store.GetNoteType(context.Background(), "default", "RTLTest")
 	if err != nil {
 		t.Fatalf("Failed to get note type: %v", err)
 	}
@@ -1489,11 +1566,11 @@ func TestDeleteCardAndListCardsInDeck(t *testing.T) {
 	defer cleanup()
 
 	col := NewCollection()
-	if err := store.CreateCollection(col); err != nil {
+	if err := store.CreateCollection(context.Background(), col); err != nil {
 		t.Fatalf("Failed to create collection: %v", err)
 	}
 
-	if err := store.CreateDeck(&Deck{ID: 1, Name: "DeleteCard Deck", Cards: []int64{}}); err != nil {
+	if err := store.CreateDeck(context.Background(), &Deck{ID: 1, Name: "DeleteCard Deck", Cards: []int64{}}); err != nil {
 		t.Fatalf("Failed to create deck: %v", err)
 	}
 
@@ -1504,13 +1581,13 @@ func TestDeleteCardAndListCardsInDeck(t *testing.T) {
 			{Name: "Card 1", QFmt: "{{Front}}", AFmt: "{{Back}}"},
 		},
 	}
-	if err := store.CreateNoteType("default", noteType); err != nil {
+	if err := store.CreateNoteType(context.Background(), "default", noteType); err != nil {
 		t.Fatalf("Failed to create note type: %v", err)
 	}
 
 	now := time.Now()
 	for i := 1; i <= 2; i++ {
-		if err := store.CreateNote("default", &Note{
+		if err := store.CreateNote(context.Background(), "default", &Note{
 			ID:         int64(i),
 			Type:       noteType.Name,
 			FieldMap:   map[string]string{"Front": "Q", "Back": "A"},
@@ -1546,12 +1623,12 @@ func TestDeleteCardAndListCardsInDeck(t *testing.T) {
 		USN:          1,
 	}
 	for _, card := range []*Card{cardOne, cardTwo} {
-		if err := store.CreateCard(card); err != nil {
+		if err := store.CreateCard(context.Background(), card); err != nil {
 			t.Fatalf("Failed to create card %d: %v", card.ID, err)
 		}
 	}
 
-	initialCards, err := store.ListCardsInDeck(1)
+	initialCards, err := store.ListCardsInDeck(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("Failed to list initial cards: %v", err)
 	}
@@ -1559,15 +1636,15 @@ func TestDeleteCardAndListCardsInDeck(t *testing.T) {
 		t.Fatalf("Expected 2 cards before deletion, got %d", len(initialCards))
 	}
 
-	if err := store.DeleteCard(cardOne.ID); err != nil {
+	if err := store.DeleteCard(context.Background(), cardOne.ID); err != nil {
 		t.Fatalf("Failed to delete card: %v", err)
 	}
 
-	if _, err := store.GetCard(cardOne.ID); !errors.Is(err, sql.ErrNoRows) {
+	if _, err := store.GetCard(context.Background(), cardOne.ID); !errors.Is(err, sql.ErrNoRows) {
 		t.Fatalf("Expected sql.ErrNoRows for deleted card, got %v", err)
 	}
 
-	remainingCards, err := store.ListCardsInDeck(1)
+	remainingCards, err := store.ListCardsInDeck(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("Failed to list cards after deletion: %v", err)
 	}
@@ -1584,11 +1661,11 @@ func TestGetRevlogForCard(t *testing.T) {
 	defer cleanup()
 
 	col := NewCollection()
-	if err := store.CreateCollection(col); err != nil {
+	if err := store.CreateCollection(context.Background(), col); err != nil {
 		t.Fatalf("Failed to create collection: %v", err)
 	}
 
-	if err := store.CreateDeck(&Deck{ID: 1, Name: "Revlog Deck", Cards: []int64{}}); err != nil {
+	if err := store.CreateDeck(context.Background(), &Deck{ID: 1, Name: "Revlog Deck", Cards: []int64{}}); err != nil {
 		t.Fatalf("Failed to create deck: %v", err)
 	}
 
@@ -1599,12 +1676,12 @@ func TestGetRevlogForCard(t *testing.T) {
 			{Name: "Card 1", QFmt: "{{Front}}", AFmt: "{{Back}}"},
 		},
 	}
-	if err := store.CreateNoteType("default", noteType); err != nil {
+	if err := store.CreateNoteType(context.Background(), "default", noteType); err != nil {
 		t.Fatalf("Failed to create note type: %v", err)
 	}
 
 	now := time.Now()
-	if err := store.CreateNote("default", &Note{
+	if err := store.CreateNote(context.Background(), "default", &Note{
 		ID:         1,
 		Type:       noteType.Name,
 		FieldMap:   map[string]string{"Front": "Q", "Back": "A"},
@@ -1627,20 +1704,20 @@ func TestGetRevlogForCard(t *testing.T) {
 		SRS:          newDueNow(now),
 		USN:          1,
 	}
-	if err := store.CreateCard(card); err != nil {
+	if err := store.CreateCard(context.Background(), card); err != nil {
 		t.Fatalf("Failed to create card: %v", err)
 	}
 
 	firstReview := now.Add(-2 * time.Hour)
 	secondReview := now.Add(-1 * time.Hour)
-	if err := store.AddRevlog(&fsrs.ReviewLog{Rating: fsrs.Again, State: fsrs.Learning, Review: firstReview}, card.ID, 1200); err != nil {
+	if err := store.AddRevlog(context.Background(), &fsrs.ReviewLog{Rating: fsrs.Again, State: fsrs.Learning, Review: firstReview}, card.ID, 1200); err != nil {
 		t.Fatalf("Failed to add first revlog entry: %v", err)
 	}
-	if err := store.AddRevlog(&fsrs.ReviewLog{Rating: fsrs.Good, State: fsrs.Review, Review: secondReview}, card.ID, 1800); err != nil {
+	if err := store.AddRevlog(context.Background(), &fsrs.ReviewLog{Rating: fsrs.Good, State: fsrs.Review, Review: secondReview}, card.ID, 1800); err != nil {
 		t.Fatalf("Failed to add second revlog entry: %v", err)
 	}
 
-	logs, err := store.GetRevlogForCard(card.ID)
+	logs, err := store.GetRevlogForCard(context.Background(), card.ID)
 	if err != nil {
 		t.Fatalf("Failed to fetch revlog entries: %v", err)
 	}
@@ -1664,7 +1741,7 @@ func TestMediaCRUD(t *testing.T) {
 	defer cleanup()
 
 	col := NewCollection()
-	if err := store.CreateCollection(col); err != nil {
+	if err := store.CreateCollection(context.Background(), col); err != nil {
 		t.Fatalf("Failed to create collection: %v", err)
 	}
 
@@ -1676,11 +1753,11 @@ func TestMediaCRUD(t *testing.T) {
 		AddedAt:  now,
 	}
 
-	if err := store.AddMedia("default", media); err != nil {
+	if err := store.AddMedia(context.Background(), "default", media); err != nil {
 		t.Fatalf("Failed to add media: %v", err)
 	}
 
-	retrieved, err := store.GetMedia(media.Filename)
+	retrieved, err := store.GetMedia(context.Background(), media.Filename)
 	if err != nil {
 		t.Fatalf("Failed to get media: %v", err)
 	}
@@ -1696,11 +1773,11 @@ func TestMediaCRUD(t *testing.T) {
 		}
 	}
 
-	if err := store.DeleteMedia(media.Filename); err != nil {
+	if err := store.DeleteMedia(context.Background(), media.Filename); err != nil {
 		t.Fatalf("Failed to delete media: %v", err)
 	}
 
-	_, err = store.GetMedia(media.Filename)
+	_, err = store.GetMedia(context.Background(), media.Filename)
 	if !errors.Is(err, sql.ErrNoRows) {
 		t.Fatalf("Expected sql.ErrNoRows after media delete, got %v", err)
 	}
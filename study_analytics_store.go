@@ -1,11 +1,12 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"time"
 )
 
-func (s *SQLiteStore) GetStudyAnalyticsOverview(userID, workspaceID string) (StudyAnalyticsOverview, error) {
+func (s *SQLStore) GetStudyAnalyticsOverview(ctx context.Context, userID, workspaceID string) (StudyAnalyticsOverview, error) {
 	overview := StudyAnalyticsOverview{}
 	if userID == "" || workspaceID == "" {
 		return overview, nil
@@ -19,7 +20,7 @@ func (s *SQLiteStore) GetStudyAnalyticsOverview(userID, workspaceID string) (Stu
 		minutesStudiedSeconds int64
 		lastStudiedAt         sql.NullInt64
 	)
-	if err := s.db.QueryRow(`
+	if err := s.db.QueryRowContext(ctx, `
 		SELECT
 			COUNT(*),
 			COALESCE(SUM(cards_reviewed), 0),
@@ -51,7 +52,7 @@ func (s *SQLiteStore) GetStudyAnalyticsOverview(userID, workspaceID string) (Stu
 	overview.MinutesStudied7D = int(minutesStudiedSeconds / 60)
 
 	var focusSecondsStudied int64
-	if err := s.db.QueryRow(`
+	if err := s.db.QueryRowContext(ctx, `
 		SELECT
 			COUNT(*),
 			COALESCE(SUM(CASE
@@ -69,7 +70,7 @@ func (s *SQLiteStore) GetStudyAnalyticsOverview(userID, workspaceID string) (Stu
 	}
 	overview.FocusMinutes7D = int(focusSecondsStudied / 60)
 
-	if err := s.db.QueryRow(`
+	if err := s.db.QueryRowContext(ctx, `
 		SELECT MAX(COALESCE(ended_at, updated_at, started_at))
 		FROM study_sessions
 		WHERE user_id = ? AND workspace_id = ? AND (
@@ -80,19 +81,19 @@ func (s *SQLiteStore) GetStudyAnalyticsOverview(userID, workspaceID string) (Stu
 	}
 	overview.LastStudiedAt = unixTimeOrZero(lastStudiedAt)
 
-	streak, err := s.currentStudyStreak(userID, workspaceID, now)
+	streak, err := s.currentStudyStreak(ctx, userID, workspaceID, now)
 	if err != nil {
 		return overview, err
 	}
 	overview.CurrentStreak = streak
 
-	dailyActivity, err := s.getStudyAnalyticsDailyActivity(userID, workspaceID, windowStart, 7)
+	dailyActivity, err := s.getStudyAnalyticsDailyActivity(ctx, userID, workspaceID, windowStart, 7)
 	if err != nil {
 		return overview, err
 	}
 	overview.DailyActivity = dailyActivity
 
-	recentSessions, err := s.getRecentStudySessionSummaries(userID, workspaceID, 5)
+	recentSessions, err := s.getRecentStudySessionSummaries(ctx, userID, workspaceID, 5)
 	if err != nil {
 		return overview, err
 	}
@@ -101,14 +102,14 @@ func (s *SQLiteStore) GetStudyAnalyticsOverview(userID, workspaceID string) (Stu
 	return overview, nil
 }
 
-func (s *SQLiteStore) GetDeckStudyAnalyticsSummary(userID, workspaceID string) (map[int64]DeckStudyAnalytics, error) {
+func (s *SQLStore) GetDeckStudyAnalyticsSummary(ctx context.Context, userID, workspaceID string) (map[int64]DeckStudyAnalytics, error) {
 	summaries := make(map[int64]DeckStudyAnalytics)
 	if userID == "" || workspaceID == "" {
 		return summaries, nil
 	}
 
 	since := startOfUTCDay(time.Now().UTC()).AddDate(0, 0, -6).Unix()
-	rows, err := s.db.Query(`
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT
 			deck_id,
 			COALESCE(SUM(CASE WHEN COALESCE(ended_at, updated_at, started_at) >= ? THEN 1 ELSE 0 END), 0) AS sessions_7d,
@@ -163,10 +164,10 @@ func (s *SQLiteStore) GetDeckStudyAnalyticsSummary(userID, workspaceID string) (
 	return summaries, rows.Err()
 }
 
-func (s *SQLiteStore) getStudyAnalyticsDailyActivity(userID, workspaceID string, windowStart time.Time, days int) ([]StudyAnalyticsDay, error) {
-	rows, err := s.db.Query(`
+func (s *SQLStore) getStudyAnalyticsDailyActivity(ctx context.Context, userID, workspaceID string, windowStart time.Time, days int) ([]StudyAnalyticsDay, error) {
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT
-			date(COALESCE(ended_at, updated_at, started_at), 'unixepoch') AS study_day,
+			`+s.dialect.utcDate("COALESCE(ended_at, updated_at, started_at)")+` AS study_day,
 			COUNT(*),
 			COALESCE(SUM(cards_reviewed), 0),
 			COALESCE(SUM(CASE
@@ -213,8 +214,8 @@ func (s *SQLiteStore) getStudyAnalyticsDailyActivity(userID, workspaceID string,
 	return dailyActivity, nil
 }
 
-func (s *SQLiteStore) getRecentStudySessionSummaries(userID, workspaceID string, limit int) ([]StudySessionSummary, error) {
-	rows, err := s.db.Query(`
+func (s *SQLStore) getRecentStudySessionSummaries(ctx context.Context, userID, workspaceID string, limit int) ([]StudySessionSummary, error) {
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT
 			id,
 			deck_id,
@@ -282,9 +283,9 @@ func (s *SQLiteStore) getRecentStudySessionSummaries(userID, workspaceID string,
 	return recent, rows.Err()
 }
 
-func (s *SQLiteStore) currentStudyStreak(userID, workspaceID string, now time.Time) (int, error) {
-	rows, err := s.db.Query(`
-		SELECT DISTINCT date(COALESCE(ended_at, updated_at, started_at), 'unixepoch') AS study_day
+func (s *SQLStore) currentStudyStreak(ctx context.Context, userID, workspaceID string, now time.Time) (int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT `+s.dialect.utcDate("COALESCE(ended_at, updated_at, started_at)")+` AS study_day
 		FROM study_sessions
 		WHERE user_id = ? AND workspace_id = ? AND (
 			cards_reviewed > 0 OR (mode = 'focus' AND status = 'completed')
@@ -307,10 +308,12 @@ func (s *SQLiteStore) currentStudyStreak(userID, workspaceID string, now time.Ti
 	if err := rows.Err(); err != nil {
 		return 0, err
 	}
-	if len(studyDays) == 0 {
-		return 0, nil
-	}
+	return studyDayStreak(studyDays, now), nil
+}
 
+// studyDayStreak counts the consecutive days, ending with now's, among studyDays: distinct
+// YYYY-MM-DD dates, newest first.
+func studyDayStreak(studyDays []string, now time.Time) int {
 	expected := now.Format("2006-01-02")
 	streak := 0
 	for _, studyDay := range studyDays {
@@ -321,7 +324,7 @@ func (s *SQLiteStore) currentStudyStreak(userID, workspaceID string, now time.Ti
 		now = now.AddDate(0, 0, -1)
 		expected = now.Format("2006-01-02")
 	}
-	return streak, nil
+	return streak
 }
 
 func startOfUTCDay(value time.Time) time.Time {
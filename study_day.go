@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// defaultNextDayStartsAt is the hour a new study day begins, as in Anki: reviews done
+// shortly after midnight still count towards the day before.
+const defaultNextDayStartsAt = 4
+
+// studyDayBounds returns the start and end of the study day now falls in, when each
+// day starts at hour in now's location.
+func studyDayBounds(now time.Time, hour int) (time.Time, time.Time) {
+	start := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if now.Before(start) {
+		start = start.AddDate(0, 0, -1)
+	}
+	return start, start.AddDate(0, 0, 1)
+}
+
+// studyDay returns the bounds of a collection's current study day.
+func (s *SQLStore) studyDay(ctx context.Context, collectionID string, now time.Time) (time.Time, time.Time, error) {
+	prefs, err := s.GetCollectionPreferences(ctx, collectionID)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	start, end := studyDayBounds(now, prefs.Study.NextDayStartsAt)
+	return start, end, nil
+}
+
+// studyDayForDeck returns the collection a deck belongs to and the bounds of its current
+// study day.
+func (s *SQLStore) studyDayForDeck(ctx context.Context, deckID int64, now time.Time) (string, time.Time, time.Time, error) {
+	collectionID, err := s.GetDeckCollectionID(ctx, deckID)
+	if err != nil {
+		return "", time.Time{}, time.Time{}, err
+	}
+	start, end, err := s.studyDay(ctx, collectionID, now)
+	return collectionID, start, end, err
+}
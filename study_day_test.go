@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStudyDayBounds(t *testing.T) {
+	loc := time.FixedZone("test", 2*60*60)
+	start, end := studyDayBounds(time.Date(2026, 3, 10, 2, 30, 0, 0, loc), 4)
+	if !start.Equal(time.Date(2026, 3, 9, 4, 0, 0, 0, loc)) || !end.Equal(time.Date(2026, 3, 10, 4, 0, 0, 0, loc)) {
+		t.Fatalf("expected 2:30 AM to belong to the day before, got %v - %v", start, end)
+	}
+	start, _ = studyDayBounds(time.Date(2026, 3, 10, 4, 0, 0, 0, loc), 4)
+	if !start.Equal(time.Date(2026, 3, 10, 4, 0, 0, 0, loc)) {
+		t.Fatalf("expected the cutoff hour to start a new day, got %v", start)
+	}
+}
+
+func TestAPI_NextDayStartsAtSetsWhenReviewsAreDue(t *testing.T) {
+	env := setupAPITestEnv(t)
+	createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Krebs cycle", "Back": "Citric acid cycle"},
+	}, nil)
+	due := decodeJSON[[]*Card](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=10", ""))
+	if len(due) != 1 {
+		t.Fatalf("expected the new card due, got %d", len(due))
+	}
+	cardID := due[0].ID
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", cardID), AnswerCardRequest{Rating: 4}); rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	now := time.Now()
+	if _, err := env.store.db.Exec(`UPDATE card_review_states SET due = ? WHERE card_id = ?`, now.Add(2*time.Hour).Unix(), cardID); err != nil {
+		t.Fatalf("move review due failed: %v", err)
+	}
+
+	if rr := doJSONRequest(t, env.router, http.MethodPatch, "/api/preferences", map[string]int{"nextDayStartsAt": 24}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid hour 400, got %d", rr.Code)
+	}
+	for _, tc := range []struct {
+		hour int
+		due  int
+	}{
+		// The day ends within the hour, before the review falls due.
+		{(now.Hour() + 1) % 24, 0},
+		// The day ends after the review falls due, so it is studied today.
+		{(now.Hour() + 3) % 24, 1},
+	} {
+		rr := doJSONRequest(t, env.router, http.MethodPatch, "/api/preferences", map[string]int{"nextDayStartsAt": tc.hour})
+		if prefs := decodeJSON[CollectionPreferences](t, rr); rr.Code != http.StatusOK || prefs.Study.NextDayStartsAt != tc.hour {
+			t.Fatalf("expected nextDayStartsAt %d saved, got %d (%s)", tc.hour, rr.Code, rr.Body.String())
+		}
+		if due := decodeJSON[[]*Card](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=10", "")); len(due) != tc.due {
+			t.Fatalf("expected %d due with the day starting at %d:00, got %d", tc.due, tc.hour, len(due))
+		}
+		if stats := decodeJSON[DeckStats](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/stats", "")); stats.DueToday != tc.due {
+			t.Fatalf("expected DueToday %d with the day starting at %d:00, got %d", tc.due, tc.hour, stats.DueToday)
+		}
+	}
+}
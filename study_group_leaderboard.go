@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// StudyGroupLeaderboardStanding is one member's place on a study group leaderboard,
+// which ranks the active members who opted in by the answers they gave today on their
+// installs of the group's decks, then by streak. Days are UTC days, as on the group
+// dashboard, since members may study in collections with different day cutoffs.
+type StudyGroupLeaderboardStanding struct {
+	Rank          int    `json:"rank"`
+	UserID        string `json:"userId"`
+	Email         string `json:"email"`
+	DisplayName   string `json:"displayName,omitempty"`
+	ReviewsToday  int    `json:"reviewsToday"`
+	Reviews7D     int    `json:"reviews7d"`
+	CurrentStreak int    `json:"currentStreak"`
+}
+
+// StudyGroupLeaderboard is served by GET /api/study-groups/{id}/leaderboard. OptedIn
+// reports whether the caller appears on it.
+type StudyGroupLeaderboard struct {
+	Date      string                          `json:"date"`
+	OptedIn   bool                            `json:"optedIn"`
+	Standings []StudyGroupLeaderboardStanding `json:"standings"`
+}
+
+type UpdateStudyGroupLeaderboardRequest struct {
+	OptIn bool `json:"optIn"`
+}
+
+// Validate is empty: both values of optIn are valid.
+func (req *UpdateStudyGroupLeaderboardRequest) Validate(v *requestValidator) {}
+
+func (s *SQLStore) SetStudyGroupLeaderboardOptIn(ctx context.Context, memberID string, optIn bool) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE study_group_members SET leaderboard_opt_in = ? WHERE id = ?`, boolToInt(optIn), memberID)
+	return err
+}
+
+func (s *SQLStore) GetStudyGroupLeaderboardOptIn(ctx context.Context, memberID string) (bool, error) {
+	var optIn int
+	if err := s.db.QueryRowContext(ctx, `SELECT leaderboard_opt_in FROM study_group_members WHERE id = ?`, memberID).Scan(&optIn); err != nil {
+		return false, err
+	}
+	return optIn != 0, nil
+}
+
+// GetStudyGroupLeaderboard ranks the group's opted-in active members as of now.
+func (s *SQLStore) GetStudyGroupLeaderboard(ctx context.Context, groupID string, now time.Time) ([]StudyGroupLeaderboardStanding, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT m.id, m.user_id, m.email, COALESCE(u.display_name, '')
+		FROM study_group_members m
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE m.study_group_id = ?
+		  AND m.status = 'active'
+		  AND m.leaderboard_opt_in = 1
+		  AND m.user_id IS NOT NULL
+	`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		memberIDs []string
+		standings []StudyGroupLeaderboardStanding
+	)
+	for rows.Next() {
+		var memberID string
+		var standing StudyGroupLeaderboardStanding
+		if err := rows.Scan(&memberID, &standing.UserID, &standing.Email, &standing.DisplayName); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		memberIDs = append(memberIDs, memberID)
+		standings = append(standings, standing)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	today := startOfUTCDay(now)
+	for i := range standings {
+		days, err := s.studyGroupMemberReviewDays(ctx, groupID, memberIDs[i], standings[i].UserID)
+		if err != nil {
+			return nil, err
+		}
+		studyDays := make([]string, 0, len(days))
+		for _, day := range days {
+			studyDays = append(studyDays, day.Date)
+			if day.Date == today.Format("2006-01-02") {
+				standings[i].ReviewsToday = day.CardsReviewed
+			}
+			if day.Date >= today.AddDate(0, 0, -6).Format("2006-01-02") {
+				standings[i].Reviews7D += day.CardsReviewed
+			}
+		}
+		standings[i].CurrentStreak = studyDayStreak(studyDays, today)
+	}
+
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.ReviewsToday != b.ReviewsToday {
+			return a.ReviewsToday > b.ReviewsToday
+		}
+		if a.CurrentStreak != b.CurrentStreak {
+			return a.CurrentStreak > b.CurrentStreak
+		}
+		if a.Reviews7D != b.Reviews7D {
+			return a.Reviews7D > b.Reviews7D
+		}
+		return strings.ToLower(a.Email) < strings.ToLower(b.Email)
+	})
+	for i := range standings {
+		standings[i].Rank = i + 1
+	}
+	return standings, nil
+}
+
+// studyGroupMemberReviewDays counts a member's answers on their installs of the group's
+// decks by UTC day, newest first. Manual reschedules are not answers and are left out.
+func (s *SQLStore) studyGroupMemberReviewDays(ctx context.Context, groupID, memberID, userID string) ([]StudyAnalyticsDay, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+s.dialect.utcDate("r.reviewed_at")+` AS study_day, COUNT(*)
+		FROM revlog r
+		JOIN cards c ON c.id = r.card_id
+		JOIN study_group_installs i ON i.installed_deck_id = c.deck_id
+		WHERE i.study_group_id = ?
+		  AND i.study_group_member_id = ?
+		  AND i.status != 'removed'
+		  AND r.user_id = ?
+		  AND r.kind != ?
+		GROUP BY study_day
+		ORDER BY study_day DESC
+	`, groupID, memberID, userID, reviewKindManual)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []StudyAnalyticsDay
+	for rows.Next() {
+		var day StudyAnalyticsDay
+		if err := rows.Scan(&day.Date, &day.CardsReviewed); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+// loadActiveStudyGroupMember resolves the caller's active membership of the group in
+// the URL, writing an error response and returning nil when there is none.
+func (h *APIHandler) loadActiveStudyGroupMember(w http.ResponseWriter, r *http.Request) *StudyGroupMember {
+	_, member, _, _, err := h.loadStudyGroupAccess(r, chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondAPIError(w, http.StatusNotFound, "study_group_not_found", "Study group not found.")
+			return nil
+		}
+		respondAPIError(w, http.StatusInternalServerError, "study_group_access_failed", err.Error())
+		return nil
+	}
+	if member.Status != "active" {
+		respondAPIError(w, http.StatusForbidden, "study_group_forbidden", "Only active members can see this study group's leaderboard.")
+		return nil
+	}
+	return member
+}
+
+func (h *APIHandler) respondStudyGroupLeaderboard(ctx context.Context, w http.ResponseWriter, member *StudyGroupMember) {
+	optedIn, err := h.store.GetStudyGroupLeaderboardOptIn(ctx, member.ID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "study_group_leaderboard_failed", err.Error())
+		return
+	}
+	now := time.Now()
+	standings, err := h.store.GetStudyGroupLeaderboard(ctx, member.StudyGroupID, now)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "study_group_leaderboard_failed", err.Error())
+		return
+	}
+	if standings == nil {
+		standings = []StudyGroupLeaderboardStanding{}
+	}
+	respondJSON(w, http.StatusOK, StudyGroupLeaderboard{
+		Date:      startOfUTCDay(now).Format("2006-01-02"),
+		OptedIn:   optedIn,
+		Standings: standings,
+	})
+}
+
+// GetStudyGroupLeaderboard serves GET /api/study-groups/{id}/leaderboard.
+func (h *APIHandler) GetStudyGroupLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if member := h.loadActiveStudyGroupMember(w, r); member != nil {
+		h.respondStudyGroupLeaderboard(r.Context(), w, member)
+	}
+}
+
+// UpdateStudyGroupLeaderboard serves PATCH /api/study-groups/{id}/leaderboard, opting
+// the caller in to or out of the leaderboard.
+func (h *APIHandler) UpdateStudyGroupLeaderboard(w http.ResponseWriter, r *http.Request) {
+	member := h.loadActiveStudyGroupMember(w, r)
+	if member == nil {
+		return
+	}
+	var req UpdateStudyGroupLeaderboardRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	if err := h.store.SetStudyGroupLeaderboardOptIn(r.Context(), member.ID, req.OptIn); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "study_group_leaderboard_update_failed", err.Error())
+		return
+	}
+	h.respondStudyGroupLeaderboard(r.Context(), w, member)
+}
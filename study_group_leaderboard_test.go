@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAPI_StudyGroupLeaderboardIsOptIn(t *testing.T) {
+	env := setupAPITestEnv(t)
+	memberClient := createAuthenticatedIsolatedTestClient(t, env, "leaderboard-member@example.com", "Leaderboard Member")
+	teamHeaders := map[string]string{"X-Vutadex-Plan": "team"}
+
+	for _, front := range []string{"Mitosis", "Meiosis"} {
+		createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": front, "Back": "Cell division"},
+		}, nil)
+	}
+	createGroup := doJSONRequestWithHeaders(t, env.router, http.MethodPost, "/api/study-groups", CreateStudyGroupRequest{
+		Name:          "Biology Club",
+		PrimaryDeckID: 1,
+		Visibility:    "private",
+		JoinPolicy:    "invite",
+	}, teamHeaders)
+	if createGroup.Code != http.StatusCreated {
+		t.Fatalf("expected create study group 201, got %d (%s)", createGroup.Code, createGroup.Body.String())
+	}
+	groupID := decodeJSON[StudyGroupDetail](t, createGroup).Group.ID
+	if rr := doJSONRequestWithHeaders(t, env.router, http.MethodPost, fmt.Sprintf("/api/study-groups/%s/versions", groupID), PublishStudyGroupVersionRequest{ChangeSummary: "First release"}, teamHeaders); rr.Code != http.StatusCreated {
+		t.Fatalf("expected publish 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	inviteRR := doJSONRequestWithHeaders(t, env.router, http.MethodPost, fmt.Sprintf("/api/study-groups/%s/members", groupID), InviteStudyGroupMemberRequest{
+		Email: memberClient.user.Email,
+		Role:  "read",
+	}, teamHeaders)
+	if inviteRR.Code != http.StatusCreated {
+		t.Fatalf("expected invite 201, got %d (%s)", inviteRR.Code, inviteRR.Body.String())
+	}
+	invite := decodeJSON[StudyGroupMember](t, inviteRR)
+	if rr := doJSONRequest(t, memberClient.router, http.MethodPost, "/api/study-groups/join", JoinStudyGroupRequest{
+		Token:                  invite.InviteToken,
+		DestinationWorkspaceID: memberClient.workspace.ID,
+		InstallLatest:          true,
+	}); rr.Code != http.StatusOK {
+		t.Fatalf("expected join 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	var installedDeckID int64
+	if err := env.store.db.QueryRow(`SELECT installed_deck_id FROM study_group_installs WHERE study_group_id = ?`, groupID).Scan(&installedDeckID); err != nil {
+		t.Fatalf("failed to load member install: %v", err)
+	}
+
+	dueRR := doRawRequest(memberClient.router, http.MethodGet, fmt.Sprintf("/api/decks/%d/due?limit=10", installedDeckID), "")
+	for _, card := range decodeJSON[[]Card](t, dueRR) {
+		if rr := doJSONRequest(t, memberClient.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", card.ID), AnswerCardRequest{Rating: 3}); rr.Code != http.StatusOK {
+			t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+	}
+
+	path := fmt.Sprintf("/api/study-groups/%s/leaderboard", groupID)
+	rr := doRawRequest(memberClient.router, http.MethodGet, path, "")
+	if board := decodeJSON[StudyGroupLeaderboard](t, rr); rr.Code != http.StatusOK || board.OptedIn || len(board.Standings) != 0 {
+		t.Fatalf("expected an empty leaderboard before anyone opts in, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr = doJSONRequest(t, memberClient.router, http.MethodPatch, path, UpdateStudyGroupLeaderboardRequest{OptIn: true})
+	board := decodeJSON[StudyGroupLeaderboard](t, rr)
+	if rr.Code != http.StatusOK || !board.OptedIn || len(board.Standings) != 1 {
+		t.Fatalf("expected the member on the leaderboard after opting in, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if standing := board.Standings[0]; standing.Rank != 1 || standing.Email != memberClient.user.Email || standing.ReviewsToday != 2 || standing.Reviews7D != 2 || standing.CurrentStreak != 1 {
+		t.Fatalf("expected today's reviews and a one-day streak, got %+v", standing)
+	}
+
+	rr = doJSONRequest(t, env.router, http.MethodPatch, path, UpdateStudyGroupLeaderboardRequest{OptIn: true})
+	board = decodeJSON[StudyGroupLeaderboard](t, rr)
+	if rr.Code != http.StatusOK || len(board.Standings) != 2 || board.Standings[0].Email != memberClient.user.Email || board.Standings[1].ReviewsToday != 0 {
+		t.Fatalf("expected the owner ranked below the member, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr = doJSONRequest(t, memberClient.router, http.MethodPatch, path, UpdateStudyGroupLeaderboardRequest{OptIn: false})
+	if board := decodeJSON[StudyGroupLeaderboard](t, rr); rr.Code != http.StatusOK || len(board.Standings) != 1 || board.Standings[0].Email == memberClient.user.Email {
+		t.Fatalf("expected the member removed after opting out, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
@@ -1,10 +1,11 @@
 package main
 
 import (
+	"context"
 	"log"
 )
 
-func (h *APIHandler) markStudyGroupInstallsForkedByDeckIDs(deckIDs ...int64) {
+func (h *APIHandler) markStudyGroupInstallsForkedByDeckIDs(ctx context.Context, deckIDs ...int64) {
 	seen := make(map[int64]struct{}, len(deckIDs))
 	for _, deckID := range deckIDs {
 		if deckID == 0 {
@@ -14,17 +15,17 @@ func (h *APIHandler) markStudyGroupInstallsForkedByDeckIDs(deckIDs ...int64) {
 			continue
 		}
 		seen[deckID] = struct{}{}
-		if err := h.store.MarkStudyGroupInstallForkedByDeckID(deckID); err != nil {
+		if err := h.store.MarkStudyGroupInstallForkedByDeckID(ctx, deckID); err != nil {
 			log.Printf("failed to mark study group install forked for deck %d: %v", deckID, err)
 		}
 	}
 }
 
-func (h *APIHandler) markStudyGroupInstallsForkedByNoteType(noteTypeName string) {
+func (h *APIHandler) markStudyGroupInstallsForkedByNoteType(ctx context.Context, noteTypeName string) {
 	if noteTypeName == "" {
 		return
 	}
-	if err := h.store.MarkStudyGroupInstallsForkedByNoteType(h.collectionID, noteTypeName); err != nil {
+	if err := h.store.MarkStudyGroupInstallsForkedByNoteType(ctx, h.collectionID, noteTypeName); err != nil {
 		log.Printf("failed to mark study group installs forked for note type %s: %v", noteTypeName, err)
 	}
 }
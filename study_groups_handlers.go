@@ -1,8 +1,8 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -12,15 +12,6 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
-func validStudyGroupRole(role string) bool {
-	switch role {
-	case "owner", "admin", "edit", "read":
-		return true
-	default:
-		return false
-	}
-}
-
 func normalizeStudyGroupRole(role string) string {
 	switch strings.TrimSpace(role) {
 	case "member":
@@ -47,11 +38,11 @@ func canEditStudyGroupSource(role string) bool {
 	return role == "owner" || role == "admin" || role == "edit"
 }
 
-func (h *APIHandler) reloadCollectionSnapshot(collectionID string) error {
+func (h *APIHandler) reloadCollectionSnapshot(ctx context.Context, collectionID string) error {
 	if strings.TrimSpace(collectionID) == "" || collectionID != h.collectionID {
 		return nil
 	}
-	col, err := h.store.GetCollection(collectionID)
+	col, err := h.store.GetCollection(ctx, collectionID)
 	if err != nil {
 		return err
 	}
@@ -62,7 +53,7 @@ func (h *APIHandler) reloadCollectionSnapshot(collectionID string) error {
 func (h *APIHandler) requireStudyGroupsEntitlement(w http.ResponseWriter, r *http.Request) (*SessionRecord, Plan, bool) {
 	session := h.sessionFromRequest(r)
 	plan := h.planForRequest(r, session)
-	usage := h.usageForSession(session)
+	usage := h.usageForSession(r.Context(), session)
 	if !entitlementsForPlan(plan, usage).Features.StudyGroups {
 		respondAPIError(w, http.StatusForbidden, "study_groups_not_available", "Study group creation and management are reserved for Team and Enterprise workspaces.")
 		return nil, "", false
@@ -75,28 +66,28 @@ func (h *APIHandler) loadStudyGroupAccess(r *http.Request, groupID string) (*Stu
 	if session == nil || session.UserID == "" {
 		return nil, nil, nil, nil, sql.ErrNoRows
 	}
-	user, err := h.store.GetUserByID(session.UserID)
+	user, err := h.store.GetUserByID(r.Context(), session.UserID)
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
-	group, err := h.store.GetStudyGroup(groupID)
+	group, err := h.store.GetStudyGroup(r.Context(), groupID)
 	if err != nil {
 		return nil, nil, nil, session, err
 	}
-	member, err := h.store.getStudyGroupMembership(groupID, user.ID, user.Email)
+	member, err := h.store.getStudyGroupMembership(r.Context(), groupID, user.ID, user.Email)
 	if err != nil {
 		return nil, nil, user, session, err
 	}
 	return group, member, user, session, nil
 }
 
-func (h *APIHandler) installStudyGroupVersion(group *StudyGroup, member *StudyGroupMember, version *StudyGroupVersion, destinationWorkspace *Workspace) (*StudyGroupInstall, error) {
-	sourceDeck, err := h.store.GetDeck(version.SourceDeckID)
+func (h *APIHandler) installStudyGroupVersion(ctx context.Context, group *StudyGroup, member *StudyGroupMember, version *StudyGroupVersion, destinationWorkspace *Workspace) (*StudyGroupInstall, error) {
+	sourceDeck, err := h.store.GetDeck(ctx, version.SourceDeckID)
 	if err != nil {
 		return nil, err
 	}
 	installedName := fmt.Sprintf("%s (Group v%d)", sourceDeck.Name, version.VersionNumber)
-	newDeck, err := h.store.CopyDeckToCollection(version.SourceDeckID, destinationWorkspace.CollectionID, installedName)
+	newDeck, err := h.store.CopyDeckToCollection(ctx, version.SourceDeckID, destinationWorkspace.CollectionID, installedName)
 	if err != nil {
 		return nil, err
 	}
@@ -114,18 +105,18 @@ func (h *APIHandler) installStudyGroupVersion(group *StudyGroup, member *StudyGr
 		CreatedAt:              now,
 		UpdatedAt:              now,
 	}
-	if err := h.store.CreateStudyGroupInstall(install); err != nil {
+	if err := h.store.CreateStudyGroupInstall(ctx, install); err != nil {
 		return nil, err
 	}
-	if err := h.reloadCollectionSnapshot(destinationWorkspace.CollectionID); err != nil {
+	if err := h.reloadCollectionSnapshot(ctx, destinationWorkspace.CollectionID); err != nil {
 		return nil, err
 	}
-	return h.store.GetStudyGroupInstall(install.ID)
+	return h.store.GetStudyGroupInstall(ctx, install.ID)
 }
 
 func (h *APIHandler) ListStudyGroups(w http.ResponseWriter, r *http.Request) {
 	session := h.sessionFromRequest(r)
-	summaries, err := h.store.ListStudyGroupSummariesForUser(session.UserID)
+	summaries, err := h.store.ListStudyGroupSummariesForUser(r.Context(), session.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_groups_list_failed", err.Error())
 		return
@@ -143,22 +134,17 @@ func (h *APIHandler) CreateStudyGroup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req CreateStudyGroupRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 	req.Name = strings.TrimSpace(req.Name)
-	if req.Name == "" || req.PrimaryDeckID == 0 {
-		respondAPIError(w, http.StatusBadRequest, "invalid_study_group_request", "Name and primaryDeckId are required.")
-		return
-	}
 
-	workspace, err := h.store.GetWorkspaceRecord(session.WorkspaceID)
+	workspace, err := h.store.GetWorkspaceRecord(r.Context(), session.WorkspaceID)
 	if err != nil {
 		respondAPIError(w, http.StatusBadRequest, "workspace_not_found", "Workspace not found.")
 		return
 	}
-	sourceCollectionID, err := h.store.GetDeckCollectionID(req.PrimaryDeckID)
+	sourceCollectionID, err := h.store.GetDeckCollectionID(r.Context(), req.PrimaryDeckID)
 	if err != nil {
 		respondAPIError(w, http.StatusBadRequest, "source_deck_not_found", "Primary deck not found.")
 		return
@@ -187,12 +173,12 @@ func (h *APIHandler) CreateStudyGroup(w http.ResponseWriter, r *http.Request) {
 	if group.JoinPolicy == "" {
 		group.JoinPolicy = "invite"
 	}
-	if err := h.store.CreateStudyGroup(group); err != nil {
+	if err := h.store.CreateStudyGroup(r.Context(), group); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_create_failed", err.Error())
 		return
 	}
 
-	user, err := h.store.GetUserByID(session.UserID)
+	user, err := h.store.GetUserByID(r.Context(), session.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_owner_failed", err.Error())
 		return
@@ -207,11 +193,11 @@ func (h *APIHandler) CreateStudyGroup(w http.ResponseWriter, r *http.Request) {
 		JoinedAt:     now,
 		CreatedAt:    now,
 	}
-	if err := h.store.CreateStudyGroupMember(member); err != nil {
+	if err := h.store.CreateStudyGroupMember(r.Context(), member); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_owner_failed", err.Error())
 		return
 	}
-	_ = h.store.CreateStudyGroupEvent(&StudyGroupEvent{
+	_ = h.store.CreateStudyGroupEvent(r.Context(), &StudyGroupEvent{
 		ID:           newID("sge"),
 		StudyGroupID: group.ID,
 		ActorUserID:  session.UserID,
@@ -220,7 +206,7 @@ func (h *APIHandler) CreateStudyGroup(w http.ResponseWriter, r *http.Request) {
 		CreatedAt:    now,
 	})
 
-	detail, err := h.store.BuildStudyGroupDetail(group.ID, session.UserID)
+	detail, err := h.store.BuildStudyGroupDetail(r.Context(), group.ID, session.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_detail_failed", err.Error())
 		return
@@ -231,7 +217,7 @@ func (h *APIHandler) CreateStudyGroup(w http.ResponseWriter, r *http.Request) {
 func (h *APIHandler) GetStudyGroup(w http.ResponseWriter, r *http.Request) {
 	groupID := chi.URLParam(r, "id")
 	session := h.sessionFromRequest(r)
-	detail, err := h.store.BuildStudyGroupDetail(groupID, session.UserID)
+	detail, err := h.store.BuildStudyGroupDetail(r.Context(), groupID, session.UserID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			respondAPIError(w, http.StatusNotFound, "study_group_not_found", "Study group not found.")
@@ -260,8 +246,7 @@ func (h *APIHandler) UpdateStudyGroup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req UpdateStudyGroupRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
@@ -276,11 +261,11 @@ func (h *APIHandler) UpdateStudyGroup(w http.ResponseWriter, r *http.Request) {
 		group.JoinPolicy = joinPolicy
 	}
 	group.UpdatedAt = time.Now()
-	if err := h.store.UpdateStudyGroup(group); err != nil {
+	if err := h.store.UpdateStudyGroup(r.Context(), group); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_update_failed", err.Error())
 		return
 	}
-	_ = h.store.CreateStudyGroupEvent(&StudyGroupEvent{
+	_ = h.store.CreateStudyGroupEvent(r.Context(), &StudyGroupEvent{
 		ID:           newID("sge"),
 		StudyGroupID: group.ID,
 		ActorUserID:  member.UserID,
@@ -288,7 +273,7 @@ func (h *APIHandler) UpdateStudyGroup(w http.ResponseWriter, r *http.Request) {
 		Payload:      h.store.encodeStudyGroupEventPayload(map[string]any{"visibility": group.Visibility, "joinPolicy": group.JoinPolicy}),
 		CreatedAt:    time.Now(),
 	})
-	detail, err := h.store.BuildStudyGroupDetail(group.ID, member.UserID)
+	detail, err := h.store.BuildStudyGroupDetail(r.Context(), group.ID, member.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_detail_failed", err.Error())
 		return
@@ -311,7 +296,7 @@ func (h *APIHandler) DeleteStudyGroup(w http.ResponseWriter, r *http.Request) {
 		respondAPIError(w, http.StatusForbidden, "study_group_forbidden", "Only owners and admins can delete this study group.")
 		return
 	}
-	if err := h.store.DeleteStudyGroup(group.ID); err != nil {
+	if err := h.store.DeleteStudyGroup(r.Context(), group.ID); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_delete_failed", err.Error())
 		return
 	}
@@ -335,19 +320,11 @@ func (h *APIHandler) InviteStudyGroupMember(w http.ResponseWriter, r *http.Reque
 	}
 
 	var req InviteStudyGroupMemberRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 	req.Email = strings.TrimSpace(strings.ToLower(req.Email))
-	req.Role = normalizeStudyGroupRole(req.Role)
-	if req.Role == "" {
-		req.Role = "read"
-	}
-	if req.Email == "" || !validStudyGroupRole(req.Role) || req.Role == "owner" {
-		respondAPIError(w, http.StatusBadRequest, "invalid_member_request", "Invite requires an email and role of admin, edit, or read.")
-		return
-	}
+	req.Role = firstNonEmpty(normalizeStudyGroupRole(req.Role), "read")
 
 	now := time.Now()
 	invite := &StudyGroupMember{
@@ -360,16 +337,16 @@ func (h *APIHandler) InviteStudyGroupMember(w http.ResponseWriter, r *http.Reque
 		InviteExpiresAt: now.Add(7 * 24 * time.Hour),
 		CreatedAt:       now,
 	}
-	if err := h.store.UpsertStudyGroupInvitation(invite); err != nil {
+	if err := h.store.UpsertStudyGroupInvitation(r.Context(), invite); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_invite_failed", err.Error())
 		return
 	}
-	savedInvite, err := h.store.GetStudyGroupMemberByGroupAndEmail(group.ID, req.Email)
+	savedInvite, err := h.store.GetStudyGroupMemberByGroupAndEmail(r.Context(), group.ID, req.Email)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_invite_failed", err.Error())
 		return
 	}
-	_ = h.store.CreateStudyGroupEvent(&StudyGroupEvent{
+	_ = h.store.CreateStudyGroupEvent(r.Context(), &StudyGroupEvent{
 		ID:           newID("sge"),
 		StudyGroupID: group.ID,
 		ActorUserID:  member.UserID,
@@ -397,7 +374,7 @@ func (h *APIHandler) UpdateStudyGroupMember(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	targetMember, err := h.store.GetStudyGroupMember(targetMemberID)
+	targetMember, err := h.store.GetStudyGroupMember(r.Context(), targetMemberID)
 	if err != nil || targetMember.StudyGroupID != group.ID {
 		respondAPIError(w, http.StatusNotFound, "study_group_member_not_found", "Study group member not found.")
 		return
@@ -408,22 +385,13 @@ func (h *APIHandler) UpdateStudyGroupMember(w http.ResponseWriter, r *http.Reque
 	}
 
 	var req UpdateStudyGroupMemberRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 	if role := normalizeStudyGroupRole(req.Role); role != "" {
-		if !validStudyGroupRole(role) || role == "owner" {
-			respondAPIError(w, http.StatusBadRequest, "invalid_member_role", "Role must be admin, edit, or read.")
-			return
-		}
 		targetMember.Role = role
 	}
 	if status := strings.TrimSpace(req.Status); status != "" {
-		if !validStudyGroupMemberStatus(status) {
-			respondAPIError(w, http.StatusBadRequest, "invalid_member_status", "Invalid study group member status.")
-			return
-		}
 		targetMember.Status = status
 		if status == "active" && targetMember.JoinedAt.IsZero() {
 			targetMember.JoinedAt = time.Now()
@@ -433,11 +401,11 @@ func (h *APIHandler) UpdateStudyGroupMember(w http.ResponseWriter, r *http.Reque
 			targetMember.InviteToken = ""
 		}
 	}
-	if err := h.store.UpdateStudyGroupMember(targetMember); err != nil {
+	if err := h.store.UpdateStudyGroupMember(r.Context(), targetMember); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_member_update_failed", err.Error())
 		return
 	}
-	_ = h.store.CreateStudyGroupEvent(&StudyGroupEvent{
+	_ = h.store.CreateStudyGroupEvent(r.Context(), &StudyGroupEvent{
 		ID:           newID("sge"),
 		StudyGroupID: group.ID,
 		ActorUserID:  member.UserID,
@@ -465,7 +433,7 @@ func (h *APIHandler) DeleteStudyGroupMember(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	targetMember, err := h.store.GetStudyGroupMember(targetMemberID)
+	targetMember, err := h.store.GetStudyGroupMember(r.Context(), targetMemberID)
 	if err != nil || targetMember.StudyGroupID != group.ID {
 		respondAPIError(w, http.StatusNotFound, "study_group_member_not_found", "Study group member not found.")
 		return
@@ -478,11 +446,11 @@ func (h *APIHandler) DeleteStudyGroupMember(w http.ResponseWriter, r *http.Reque
 	targetMember.RemovedAt = time.Now()
 	targetMember.InviteToken = ""
 	targetMember.InviteExpiresAt = time.Time{}
-	if err := h.store.UpdateStudyGroupMember(targetMember); err != nil {
+	if err := h.store.UpdateStudyGroupMember(r.Context(), targetMember); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_member_delete_failed", err.Error())
 		return
 	}
-	_ = h.store.CreateStudyGroupEvent(&StudyGroupEvent{
+	_ = h.store.CreateStudyGroupEvent(r.Context(), &StudyGroupEvent{
 		ID:           newID("sge"),
 		StudyGroupID: group.ID,
 		ActorUserID:  member.UserID,
@@ -495,23 +463,18 @@ func (h *APIHandler) DeleteStudyGroupMember(w http.ResponseWriter, r *http.Reque
 
 func (h *APIHandler) JoinStudyGroup(w http.ResponseWriter, r *http.Request) {
 	session := h.sessionFromRequest(r)
-	user, err := h.store.GetUserByID(session.UserID)
+	user, err := h.store.GetUserByID(r.Context(), session.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "user_not_found", err.Error())
 		return
 	}
 
 	var req JoinStudyGroupRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 	req.Token = strings.TrimSpace(req.Token)
-	if req.Token == "" {
-		respondAPIError(w, http.StatusBadRequest, "invalid_invite_token", "Invite token is required.")
-		return
-	}
-	member, err := h.store.GetStudyGroupMemberByInviteToken(req.Token)
+	member, err := h.store.GetStudyGroupMemberByInviteToken(r.Context(), req.Token)
 	if err != nil {
 		respondAPIError(w, http.StatusNotFound, "study_group_invite_not_found", "Invite not found.")
 		return
@@ -522,7 +485,7 @@ func (h *APIHandler) JoinStudyGroup(w http.ResponseWriter, r *http.Request) {
 	}
 	if !member.InviteExpiresAt.IsZero() && member.InviteExpiresAt.Before(time.Now()) {
 		member.Status = "expired"
-		_ = h.store.UpdateStudyGroupMember(member)
+		_ = h.store.UpdateStudyGroupMember(r.Context(), member)
 		respondAPIError(w, http.StatusConflict, "study_group_invite_expired", "This invite has expired.")
 		return
 	}
@@ -534,13 +497,13 @@ func (h *APIHandler) JoinStudyGroup(w http.ResponseWriter, r *http.Request) {
 	if req.DestinationWorkspaceID == "" {
 		req.DestinationWorkspaceID = session.WorkspaceID
 	}
-	workspace, err := h.store.GetWorkspaceForUser(user.ID, req.DestinationWorkspaceID)
+	workspace, err := h.store.GetWorkspaceForUser(r.Context(), user.ID, req.DestinationWorkspaceID)
 	if err != nil {
 		respondAPIError(w, http.StatusBadRequest, "invalid_destination_workspace", "Destination workspace not found.")
 		return
 	}
 
-	group, err := h.store.GetStudyGroup(member.StudyGroupID)
+	group, err := h.store.GetStudyGroup(r.Context(), member.StudyGroupID)
 	if err != nil {
 		respondAPIError(w, http.StatusNotFound, "study_group_not_found", "Study group not found.")
 		return
@@ -551,21 +514,21 @@ func (h *APIHandler) JoinStudyGroup(w http.ResponseWriter, r *http.Request) {
 	member.JoinedAt = time.Now()
 	member.InviteToken = ""
 	member.InviteExpiresAt = time.Time{}
-	if err := h.store.UpdateStudyGroupMember(member); err != nil {
+	if err := h.store.UpdateStudyGroupMember(r.Context(), member); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_join_failed", err.Error())
 		return
 	}
 
 	if req.InstallLatest {
-		if latestVersion, err := h.store.GetLatestStudyGroupVersion(group.ID); err == nil {
-			if _, err := h.installStudyGroupVersion(group, member, latestVersion, workspace); err != nil {
+		if latestVersion, err := h.store.GetLatestStudyGroupVersion(r.Context(), group.ID); err == nil {
+			if _, err := h.installStudyGroupVersion(r.Context(), group, member, latestVersion, workspace); err != nil {
 				respondAPIError(w, http.StatusInternalServerError, "study_group_install_failed", err.Error())
 				return
 			}
 		}
 	}
 
-	_ = h.store.CreateStudyGroupEvent(&StudyGroupEvent{
+	_ = h.store.CreateStudyGroupEvent(r.Context(), &StudyGroupEvent{
 		ID:           newID("sge"),
 		StudyGroupID: group.ID,
 		ActorUserID:  user.ID,
@@ -573,7 +536,7 @@ func (h *APIHandler) JoinStudyGroup(w http.ResponseWriter, r *http.Request) {
 		Payload:      h.store.encodeStudyGroupEventPayload(map[string]any{"workspaceId": workspace.ID, "installLatest": req.InstallLatest}),
 		CreatedAt:    time.Now(),
 	})
-	detail, err := h.store.BuildStudyGroupDetail(group.ID, user.ID)
+	detail, err := h.store.BuildStudyGroupDetail(r.Context(), group.ID, user.ID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_detail_failed", err.Error())
 		return
@@ -584,7 +547,7 @@ func (h *APIHandler) JoinStudyGroup(w http.ResponseWriter, r *http.Request) {
 func (h *APIHandler) ListStudyGroupVersions(w http.ResponseWriter, r *http.Request) {
 	groupID := chi.URLParam(r, "id")
 	session := h.sessionFromRequest(r)
-	detail, err := h.store.BuildStudyGroupDetail(groupID, session.UserID)
+	detail, err := h.store.BuildStudyGroupDetail(r.Context(), groupID, session.UserID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			respondAPIError(w, http.StatusNotFound, "study_group_not_found", "Study group not found.")
@@ -613,16 +576,15 @@ func (h *APIHandler) PublishStudyGroupVersion(w http.ResponseWriter, r *http.Req
 	}
 
 	var req PublishStudyGroupVersionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, http.ErrBodyNotAllowed) {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeOptionalValidatedRequest(w, r, &req) {
 		return
 	}
 
 	nextVersion := 1
-	if latestVersion, err := h.store.GetLatestStudyGroupVersion(group.ID); err == nil {
+	if latestVersion, err := h.store.GetLatestStudyGroupVersion(r.Context(), group.ID); err == nil {
 		nextVersion = latestVersion.VersionNumber + 1
 	}
-	noteCount, cardCount, err := h.store.GetDeckContentSummary(group.PrimaryDeckID)
+	noteCount, cardCount, err := h.store.GetDeckContentSummary(r.Context(), group.PrimaryDeckID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_version_failed", err.Error())
 		return
@@ -638,11 +600,11 @@ func (h *APIHandler) PublishStudyGroupVersion(w http.ResponseWriter, r *http.Req
 		CardCount:         cardCount,
 		CreatedAt:         time.Now(),
 	}
-	if err := h.store.CreateStudyGroupVersion(version); err != nil {
+	if err := h.store.CreateStudyGroupVersion(r.Context(), version); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_version_failed", err.Error())
 		return
 	}
-	_ = h.store.CreateStudyGroupEvent(&StudyGroupEvent{
+	_ = h.store.CreateStudyGroupEvent(r.Context(), &StudyGroupEvent{
 		ID:           newID("sge"),
 		StudyGroupID: group.ID,
 		ActorUserID:  member.UserID,
@@ -670,24 +632,23 @@ func (h *APIHandler) InstallStudyGroupDeck(w http.ResponseWriter, r *http.Reques
 	}
 
 	var req InstallStudyGroupDeckRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 	if req.DestinationWorkspaceID == "" {
 		req.DestinationWorkspaceID = session.WorkspaceID
 	}
-	workspace, err := h.store.GetWorkspaceForUser(user.ID, req.DestinationWorkspaceID)
+	workspace, err := h.store.GetWorkspaceForUser(r.Context(), user.ID, req.DestinationWorkspaceID)
 	if err != nil {
 		respondAPIError(w, http.StatusBadRequest, "invalid_destination_workspace", "Destination workspace not found.")
 		return
 	}
-	latestVersion, err := h.store.GetLatestStudyGroupVersion(group.ID)
+	latestVersion, err := h.store.GetLatestStudyGroupVersion(r.Context(), group.ID)
 	if err != nil {
 		respondAPIError(w, http.StatusConflict, "study_group_no_published_version", "Publish a source version before members can install it.")
 		return
 	}
-	if currentInstall, err := h.store.GetCurrentStudyGroupInstall(group.ID, member.ID); err == nil {
+	if currentInstall, err := h.store.GetCurrentStudyGroupInstall(r.Context(), group.ID, member.ID); err == nil {
 		if currentInstall.SourceVersionNumber == latestVersion.VersionNumber {
 			respondJSON(w, http.StatusOK, currentInstall)
 			return
@@ -695,12 +656,12 @@ func (h *APIHandler) InstallStudyGroupDeck(w http.ResponseWriter, r *http.Reques
 		respondAPIError(w, http.StatusConflict, "study_group_update_required", "Use the install update flow to move to a newer version.")
 		return
 	}
-	install, err := h.installStudyGroupVersion(group, member, latestVersion, workspace)
+	install, err := h.installStudyGroupVersion(r.Context(), group, member, latestVersion, workspace)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_install_failed", err.Error())
 		return
 	}
-	_ = h.store.CreateStudyGroupEvent(&StudyGroupEvent{
+	_ = h.store.CreateStudyGroupEvent(r.Context(), &StudyGroupEvent{
 		ID:           newID("sge"),
 		StudyGroupID: group.ID,
 		ActorUserID:  user.ID,
@@ -727,12 +688,12 @@ func (h *APIHandler) UpdateStudyGroupInstall(w http.ResponseWriter, r *http.Requ
 		respondAPIError(w, http.StatusForbidden, "study_group_membership_inactive", "Only active members can update installs.")
 		return
 	}
-	currentInstall, err := h.store.GetStudyGroupInstall(installID)
+	currentInstall, err := h.store.GetStudyGroupInstall(r.Context(), installID)
 	if err != nil || currentInstall.StudyGroupID != group.ID || currentInstall.StudyGroupMemberID != member.ID {
 		respondAPIError(w, http.StatusNotFound, "study_group_install_not_found", "Study group install not found.")
 		return
 	}
-	latestVersion, err := h.store.GetLatestStudyGroupVersion(group.ID)
+	latestVersion, err := h.store.GetLatestStudyGroupVersion(r.Context(), group.ID)
 	if err != nil {
 		respondAPIError(w, http.StatusConflict, "study_group_no_published_version", "No published version is available.")
 		return
@@ -743,21 +704,20 @@ func (h *APIHandler) UpdateStudyGroupInstall(w http.ResponseWriter, r *http.Requ
 	}
 
 	var req UpdateStudyGroupInstallRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, http.ErrBodyNotAllowed) {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+	if !decodeOptionalValidatedRequest(w, r, &req) {
 		return
 	}
 	destinationWorkspaceID := currentInstall.DestinationWorkspaceID
 	if strings.TrimSpace(req.DestinationWorkspaceID) != "" {
 		destinationWorkspaceID = strings.TrimSpace(req.DestinationWorkspaceID)
 	}
-	workspace, err := h.store.GetWorkspaceForUser(user.ID, destinationWorkspaceID)
+	workspace, err := h.store.GetWorkspaceForUser(r.Context(), user.ID, destinationWorkspaceID)
 	if err != nil {
 		respondAPIError(w, http.StatusBadRequest, "invalid_destination_workspace", "Destination workspace not found.")
 		return
 	}
 
-	nextInstall, err := h.installStudyGroupVersion(group, member, latestVersion, workspace)
+	nextInstall, err := h.installStudyGroupVersion(r.Context(), group, member, latestVersion, workspace)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_install_update_failed", err.Error())
 		return
@@ -765,11 +725,11 @@ func (h *APIHandler) UpdateStudyGroupInstall(w http.ResponseWriter, r *http.Requ
 	currentInstall.Status = "superseded"
 	currentInstall.SupersededByInstallID = nextInstall.ID
 	currentInstall.UpdatedAt = time.Now()
-	if err := h.store.UpdateStudyGroupInstall(currentInstall); err != nil {
+	if err := h.store.UpdateStudyGroupInstall(r.Context(), currentInstall); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_install_update_failed", err.Error())
 		return
 	}
-	_ = h.store.CreateStudyGroupEvent(&StudyGroupEvent{
+	_ = h.store.CreateStudyGroupEvent(r.Context(), &StudyGroupEvent{
 		ID:           newID("sge"),
 		StudyGroupID: group.ID,
 		ActorUserID:  user.ID,
@@ -792,7 +752,7 @@ func (h *APIHandler) RemoveStudyGroupInstall(w http.ResponseWriter, r *http.Requ
 		respondAPIError(w, http.StatusInternalServerError, "study_group_access_failed", err.Error())
 		return
 	}
-	install, err := h.store.GetStudyGroupInstall(installID)
+	install, err := h.store.GetStudyGroupInstall(r.Context(), installID)
 	if err != nil || install.StudyGroupID != group.ID {
 		respondAPIError(w, http.StatusNotFound, "study_group_install_not_found", "Study group install not found.")
 		return
@@ -801,7 +761,7 @@ func (h *APIHandler) RemoveStudyGroupInstall(w http.ResponseWriter, r *http.Requ
 		respondAPIError(w, http.StatusForbidden, "study_group_forbidden", "You can only remove your own install.")
 		return
 	}
-	if err := h.store.DeleteCopiedDeck(install.InstalledDeckID); err != nil {
+	if err := h.store.DeleteCopiedDeck(r.Context(), install.InstalledDeckID); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_install_delete_failed", err.Error())
 		return
 	}
@@ -809,18 +769,18 @@ func (h *APIHandler) RemoveStudyGroupInstall(w http.ResponseWriter, r *http.Requ
 	install.InstalledDeckID = 0
 	install.InstalledDeckName = ""
 	install.UpdatedAt = time.Now()
-	if err := h.store.UpdateStudyGroupInstall(install); err != nil {
+	if err := h.store.UpdateStudyGroupInstall(r.Context(), install); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_install_delete_failed", err.Error())
 		return
 	}
-	destinationWorkspace, err := h.store.GetWorkspaceRecord(install.DestinationWorkspaceID)
+	destinationWorkspace, err := h.store.GetWorkspaceRecord(r.Context(), install.DestinationWorkspaceID)
 	if err == nil {
-		if reloadErr := h.reloadCollectionSnapshot(destinationWorkspace.CollectionID); reloadErr != nil {
+		if reloadErr := h.reloadCollectionSnapshot(r.Context(), destinationWorkspace.CollectionID); reloadErr != nil {
 			respondAPIError(w, http.StatusInternalServerError, "study_group_install_delete_failed", reloadErr.Error())
 			return
 		}
 	}
-	_ = h.store.CreateStudyGroupEvent(&StudyGroupEvent{
+	_ = h.store.CreateStudyGroupEvent(r.Context(), &StudyGroupEvent{
 		ID:           newID("sge"),
 		StudyGroupID: group.ID,
 		ActorUserID:  member.UserID,
@@ -842,7 +802,7 @@ func (h *APIHandler) GetStudyGroupDashboard(w http.ResponseWriter, r *http.Reque
 		respondAPIError(w, http.StatusInternalServerError, "study_group_access_failed", err.Error())
 		return
 	}
-	dashboard, err := h.store.GetStudyGroupDashboard(groupID)
+	dashboard, err := h.store.GetStudyGroupDashboard(r.Context(), groupID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_group_dashboard_failed", err.Error())
 		return
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -17,8 +18,8 @@ func unixTimeOrZero(value sql.NullInt64) time.Time {
 	return time.Unix(value.Int64, 0)
 }
 
-func (s *SQLiteStore) ListWorkspacesForUser(userID string) ([]Workspace, error) {
-	rows, err := s.db.Query(`
+func (s *SQLStore) ListWorkspacesForUser(ctx context.Context, userID string) ([]Workspace, error) {
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT id, name, slug, collection_id, owner_user_id, organization_id, created_at, updated_at
 		FROM workspaces
 		WHERE owner_user_id = ?
@@ -45,8 +46,8 @@ func (s *SQLiteStore) ListWorkspacesForUser(userID string) ([]Workspace, error)
 	return workspaces, rows.Err()
 }
 
-func (s *SQLiteStore) GetWorkspaceForUser(userID, workspaceID string) (*Workspace, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetWorkspaceForUser(ctx context.Context, userID, workspaceID string) (*Workspace, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, name, slug, collection_id, owner_user_id, organization_id, created_at, updated_at
 		FROM workspaces
 		WHERE id = ?
@@ -89,23 +90,23 @@ func scanWorkspaceRow(scanner interface{ Scan(dest ...any) error }) (*Workspace,
 	return &workspace, nil
 }
 
-func (s *SQLiteStore) GetDeckCollectionID(deckID int64) (string, error) {
+func (s *SQLStore) GetDeckCollectionID(ctx context.Context, deckID int64) (string, error) {
 	var collectionID string
-	if err := s.db.QueryRow(`SELECT collection_id FROM decks WHERE id = ?`, deckID).Scan(&collectionID); err != nil {
+	if err := s.db.QueryRowContext(ctx, `SELECT collection_id FROM decks WHERE id = ?`, deckID).Scan(&collectionID); err != nil {
 		return "", err
 	}
 	return collectionID, nil
 }
 
-func (s *SQLiteStore) GetDeckContentSummary(deckID int64) (noteCount, cardCount int, err error) {
-	if err = s.db.QueryRow(`SELECT COUNT(DISTINCT note_id), COUNT(*) FROM cards WHERE deck_id = ?`, deckID).Scan(&noteCount, &cardCount); err != nil {
+func (s *SQLStore) GetDeckContentSummary(ctx context.Context, deckID int64) (noteCount, cardCount int, err error) {
+	if err = s.db.QueryRowContext(ctx, `SELECT COUNT(DISTINCT note_id), COUNT(*) FROM cards WHERE deck_id = ?`, deckID).Scan(&noteCount, &cardCount); err != nil {
 		return 0, 0, err
 	}
 	return noteCount, cardCount, nil
 }
 
-func (s *SQLiteStore) CreateStudyGroup(group *StudyGroup) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) CreateStudyGroup(ctx context.Context, group *StudyGroup) error {
+	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO study_groups (
 			id, workspace_id, primary_deck_id, name, description, visibility, join_policy,
 			created_by_user_id, created_at, updated_at
@@ -125,8 +126,8 @@ func (s *SQLiteStore) CreateStudyGroup(group *StudyGroup) error {
 	return err
 }
 
-func (s *SQLiteStore) GetStudyGroup(id string) (*StudyGroup, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetStudyGroup(ctx context.Context, id string) (*StudyGroup, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, workspace_id, primary_deck_id, name, description, visibility, join_policy, created_by_user_id, created_at, updated_at
 		FROM study_groups
 		WHERE id = ?
@@ -156,8 +157,8 @@ func scanStudyGroup(scanner interface{ Scan(dest ...any) error }) (*StudyGroup,
 	return &group, nil
 }
 
-func (s *SQLiteStore) UpdateStudyGroup(group *StudyGroup) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) UpdateStudyGroup(ctx context.Context, group *StudyGroup) error {
+	_, err := s.db.ExecContext(ctx, `
 		UPDATE study_groups
 		SET name = ?, description = ?, visibility = ?, join_policy = ?, updated_at = ?
 		WHERE id = ?
@@ -165,13 +166,13 @@ func (s *SQLiteStore) UpdateStudyGroup(group *StudyGroup) error {
 	return err
 }
 
-func (s *SQLiteStore) DeleteStudyGroup(id string) error {
-	_, err := s.db.Exec(`DELETE FROM study_groups WHERE id = ?`, id)
+func (s *SQLStore) DeleteStudyGroup(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM study_groups WHERE id = ?`, id)
 	return err
 }
 
-func (s *SQLiteStore) CreateStudyGroupMember(member *StudyGroupMember) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) CreateStudyGroupMember(ctx context.Context, member *StudyGroupMember) error {
+	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO study_group_members (
 			id, study_group_id, user_id, email, role, status, invite_token, invite_expires_at, joined_at, removed_at, created_at
 		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
@@ -191,8 +192,8 @@ func (s *SQLiteStore) CreateStudyGroupMember(member *StudyGroupMember) error {
 	return err
 }
 
-func (s *SQLiteStore) UpsertStudyGroupInvitation(member *StudyGroupMember) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) UpsertStudyGroupInvitation(ctx context.Context, member *StudyGroupMember) error {
+	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO study_group_members (
 			id, study_group_id, user_id, email, role, status, invite_token, invite_expires_at, joined_at, removed_at, created_at
 		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
@@ -218,8 +219,8 @@ func (s *SQLiteStore) UpsertStudyGroupInvitation(member *StudyGroupMember) error
 	return err
 }
 
-func (s *SQLiteStore) GetStudyGroupMember(id string) (*StudyGroupMember, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetStudyGroupMember(ctx context.Context, id string) (*StudyGroupMember, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, study_group_id, user_id, email, role, status, invite_token, invite_expires_at, joined_at, removed_at, created_at
 		FROM study_group_members
 		WHERE id = ?
@@ -227,8 +228,8 @@ func (s *SQLiteStore) GetStudyGroupMember(id string) (*StudyGroupMember, error)
 	return scanStudyGroupMember(row)
 }
 
-func (s *SQLiteStore) GetStudyGroupMemberByGroupAndEmail(groupID, email string) (*StudyGroupMember, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetStudyGroupMemberByGroupAndEmail(ctx context.Context, groupID, email string) (*StudyGroupMember, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, study_group_id, user_id, email, role, status, invite_token, invite_expires_at, joined_at, removed_at, created_at
 		FROM study_group_members
 		WHERE study_group_id = ? AND lower(email) = lower(?)
@@ -236,8 +237,8 @@ func (s *SQLiteStore) GetStudyGroupMemberByGroupAndEmail(groupID, email string)
 	return scanStudyGroupMember(row)
 }
 
-func (s *SQLiteStore) GetStudyGroupMemberByUser(groupID, userID string) (*StudyGroupMember, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetStudyGroupMemberByUser(ctx context.Context, groupID, userID string) (*StudyGroupMember, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, study_group_id, user_id, email, role, status, invite_token, invite_expires_at, joined_at, removed_at, created_at
 		FROM study_group_members
 		WHERE study_group_id = ? AND user_id = ?
@@ -245,8 +246,8 @@ func (s *SQLiteStore) GetStudyGroupMemberByUser(groupID, userID string) (*StudyG
 	return scanStudyGroupMember(row)
 }
 
-func (s *SQLiteStore) GetStudyGroupMemberByInviteToken(token string) (*StudyGroupMember, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetStudyGroupMemberByInviteToken(ctx context.Context, token string) (*StudyGroupMember, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, study_group_id, user_id, email, role, status, invite_token, invite_expires_at, joined_at, removed_at, created_at
 		FROM study_group_members
 		WHERE invite_token = ?
@@ -254,8 +255,8 @@ func (s *SQLiteStore) GetStudyGroupMemberByInviteToken(token string) (*StudyGrou
 	return scanStudyGroupMember(row)
 }
 
-func (s *SQLiteStore) ListStudyGroupMembers(groupID string) ([]StudyGroupMember, error) {
-	rows, err := s.db.Query(`
+func (s *SQLStore) ListStudyGroupMembers(ctx context.Context, groupID string) ([]StudyGroupMember, error) {
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT id, study_group_id, user_id, email, role, status, invite_token, invite_expires_at, joined_at, removed_at, created_at
 		FROM study_group_members
 		WHERE study_group_id = ?
@@ -310,8 +311,8 @@ func scanStudyGroupMember(scanner interface{ Scan(dest ...any) error }) (*StudyG
 	return &member, nil
 }
 
-func (s *SQLiteStore) UpdateStudyGroupMember(member *StudyGroupMember) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) UpdateStudyGroupMember(ctx context.Context, member *StudyGroupMember) error {
+	_, err := s.db.ExecContext(ctx, `
 		UPDATE study_group_members
 		SET user_id = ?, role = ?, status = ?, invite_token = ?, invite_expires_at = ?, joined_at = ?, removed_at = ?
 		WHERE id = ?
@@ -328,8 +329,8 @@ func (s *SQLiteStore) UpdateStudyGroupMember(member *StudyGroupMember) error {
 	return err
 }
 
-func (s *SQLiteStore) CreateStudyGroupVersion(version *StudyGroupVersion) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) CreateStudyGroupVersion(ctx context.Context, version *StudyGroupVersion) error {
+	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO study_group_versions (
 			id, study_group_id, version_number, source_deck_id, published_by_user_id,
 			change_summary, note_count, card_count, created_at
@@ -348,8 +349,8 @@ func (s *SQLiteStore) CreateStudyGroupVersion(version *StudyGroupVersion) error
 	return err
 }
 
-func (s *SQLiteStore) ListStudyGroupVersions(groupID string) ([]StudyGroupVersion, error) {
-	rows, err := s.db.Query(`
+func (s *SQLStore) ListStudyGroupVersions(ctx context.Context, groupID string) ([]StudyGroupVersion, error) {
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT id, study_group_id, version_number, source_deck_id, published_by_user_id,
 		       change_summary, note_count, card_count, created_at
 		FROM study_group_versions
@@ -372,8 +373,8 @@ func (s *SQLiteStore) ListStudyGroupVersions(groupID string) ([]StudyGroupVersio
 	return versions, rows.Err()
 }
 
-func (s *SQLiteStore) GetLatestStudyGroupVersion(groupID string) (*StudyGroupVersion, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetLatestStudyGroupVersion(ctx context.Context, groupID string) (*StudyGroupVersion, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, study_group_id, version_number, source_deck_id, published_by_user_id,
 		       change_summary, note_count, card_count, created_at
 		FROM study_group_versions
@@ -404,8 +405,8 @@ func scanStudyGroupVersion(scanner interface{ Scan(dest ...any) error }) (*Study
 	return &version, nil
 }
 
-func (s *SQLiteStore) CreateStudyGroupInstall(install *StudyGroupInstall) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) CreateStudyGroupInstall(ctx context.Context, install *StudyGroupInstall) error {
+	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO study_group_installs (
 			id, study_group_id, study_group_member_id, destination_workspace_id,
 			installed_deck_id, source_version_number, status, sync_state,
@@ -427,8 +428,8 @@ func (s *SQLiteStore) CreateStudyGroupInstall(install *StudyGroupInstall) error
 	return err
 }
 
-func (s *SQLiteStore) GetStudyGroupInstall(id string) (*StudyGroupInstall, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetStudyGroupInstall(ctx context.Context, id string) (*StudyGroupInstall, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT i.id, i.study_group_id, i.study_group_member_id, i.destination_workspace_id,
 		       i.installed_deck_id, d.name, i.source_version_number, i.status, i.sync_state,
 		       i.superseded_by_install_id, i.created_at, i.updated_at
@@ -439,8 +440,8 @@ func (s *SQLiteStore) GetStudyGroupInstall(id string) (*StudyGroupInstall, error
 	return scanStudyGroupInstall(row)
 }
 
-func (s *SQLiteStore) GetStudyGroupInstallByDeckID(deckID int64) (*StudyGroupInstall, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetStudyGroupInstallByDeckID(ctx context.Context, deckID int64) (*StudyGroupInstall, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT i.id, i.study_group_id, i.study_group_member_id, i.destination_workspace_id,
 		       i.installed_deck_id, d.name, i.source_version_number, i.status, i.sync_state,
 		       i.superseded_by_install_id, i.created_at, i.updated_at
@@ -453,8 +454,8 @@ func (s *SQLiteStore) GetStudyGroupInstallByDeckID(deckID int64) (*StudyGroupIns
 	return scanStudyGroupInstall(row)
 }
 
-func (s *SQLiteStore) GetCurrentStudyGroupInstall(groupID, memberID string) (*StudyGroupInstall, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetCurrentStudyGroupInstall(ctx context.Context, groupID, memberID string) (*StudyGroupInstall, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT i.id, i.study_group_id, i.study_group_member_id, i.destination_workspace_id,
 		       i.installed_deck_id, d.name, i.source_version_number, i.status, i.sync_state,
 		       i.superseded_by_install_id, i.created_at, i.updated_at
@@ -502,8 +503,8 @@ func scanStudyGroupInstall(scanner interface{ Scan(dest ...any) error }) (*Study
 	return &install, nil
 }
 
-func (s *SQLiteStore) UpdateStudyGroupInstall(install *StudyGroupInstall) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) UpdateStudyGroupInstall(ctx context.Context, install *StudyGroupInstall) error {
+	_, err := s.db.ExecContext(ctx, `
 		UPDATE study_group_installs
 		SET destination_workspace_id = ?, installed_deck_id = ?, source_version_number = ?,
 		    status = ?, sync_state = ?, superseded_by_install_id = ?, updated_at = ?
@@ -528,8 +529,8 @@ func nullableDeckID(deckID int64) any {
 	return deckID
 }
 
-func (s *SQLiteStore) MarkStudyGroupInstallForkedByDeckID(deckID int64) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) MarkStudyGroupInstallForkedByDeckID(ctx context.Context, deckID int64) error {
+	_, err := s.db.ExecContext(ctx, `
 		UPDATE study_group_installs
 		SET sync_state = 'forked', updated_at = ?
 		WHERE installed_deck_id = ? AND status != 'removed' AND sync_state != 'forked'
@@ -537,8 +538,8 @@ func (s *SQLiteStore) MarkStudyGroupInstallForkedByDeckID(deckID int64) error {
 	return err
 }
 
-func (s *SQLiteStore) MarkStudyGroupInstallsForkedByNoteType(collectionID string, noteTypeName string) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) MarkStudyGroupInstallsForkedByNoteType(ctx context.Context, collectionID string, noteTypeName string) error {
+	_, err := s.db.ExecContext(ctx, `
 		UPDATE study_group_installs
 		SET sync_state = 'forked', updated_at = ?
 		WHERE status != 'removed'
@@ -554,8 +555,8 @@ func (s *SQLiteStore) MarkStudyGroupInstallsForkedByNoteType(collectionID string
 	return err
 }
 
-func (s *SQLiteStore) CreateStudyGroupEvent(event *StudyGroupEvent) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) CreateStudyGroupEvent(ctx context.Context, event *StudyGroupEvent) error {
+	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO study_group_events (id, study_group_id, actor_user_id, event_type, payload, created_at)
 		VALUES (?, ?, ?, ?, ?, ?)
 	`,
@@ -569,11 +570,11 @@ func (s *SQLiteStore) CreateStudyGroupEvent(event *StudyGroupEvent) error {
 	return err
 }
 
-func (s *SQLiteStore) ListStudyGroupEvents(groupID string, limit int) ([]StudyGroupEvent, error) {
+func (s *SQLStore) ListStudyGroupEvents(ctx context.Context, groupID string, limit int) ([]StudyGroupEvent, error) {
 	if limit <= 0 {
 		limit = 20
 	}
-	rows, err := s.db.Query(`
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT id, study_group_id, actor_user_id, event_type, payload, created_at
 		FROM study_group_events
 		WHERE study_group_id = ?
@@ -610,10 +611,10 @@ func scanStudyGroupEvent(scanner interface{ Scan(dest ...any) error }) (*StudyGr
 	return &event, nil
 }
 
-func (s *SQLiteStore) GetStudyGroupDashboard(groupID string) (StudyGroupDashboard, error) {
+func (s *SQLStore) GetStudyGroupDashboard(ctx context.Context, groupID string) (StudyGroupDashboard, error) {
 	dashboard := StudyGroupDashboard{}
 
-	if err := s.db.QueryRow(`
+	if err := s.db.QueryRowContext(ctx, `
 		SELECT COUNT(*)
 		FROM study_group_members
 		WHERE study_group_id = ? AND status = 'active'
@@ -621,7 +622,7 @@ func (s *SQLiteStore) GetStudyGroupDashboard(groupID string) (StudyGroupDashboar
 		return dashboard, err
 	}
 
-	if err := s.db.QueryRow(`
+	if err := s.db.QueryRowContext(ctx, `
 		SELECT COUNT(*)
 		FROM study_group_installs i
 		JOIN study_group_members m ON m.id = i.study_group_member_id
@@ -636,7 +637,7 @@ func (s *SQLiteStore) GetStudyGroupDashboard(groupID string) (StudyGroupDashboar
 	todayStart := startOfUTCDay(now).Unix()
 
 	var minutesStudiedSeconds int64
-	if err := s.db.QueryRow(`
+	if err := s.db.QueryRowContext(ctx, `
 		SELECT
 			COUNT(DISTINCT i.study_group_member_id),
 			COUNT(*),
@@ -669,9 +670,9 @@ func (s *SQLiteStore) GetStudyGroupDashboard(groupID string) (StudyGroupDashboar
 	}
 	dashboard.MinutesStudied7D = int(minutesStudiedSeconds / 60)
 
-	if latestVersion, err := s.GetLatestStudyGroupVersion(groupID); err == nil {
+	if latestVersion, err := s.GetLatestStudyGroupVersion(ctx, groupID); err == nil {
 		dashboard.LatestVersionNumber = latestVersion.VersionNumber
-		if err := s.db.QueryRow(`
+		if err := s.db.QueryRowContext(ctx, `
 			SELECT COUNT(*)
 			FROM study_group_installs i
 			JOIN study_group_members m ON m.id = i.study_group_member_id
@@ -684,13 +685,13 @@ func (s *SQLiteStore) GetStudyGroupDashboard(groupID string) (StudyGroupDashboar
 		}
 	}
 
-	dailyActivity, err := s.getStudyGroupDailyActivity(groupID, windowStart, 7)
+	dailyActivity, err := s.getStudyGroupDailyActivity(ctx, groupID, windowStart, 7)
 	if err != nil {
 		return dashboard, err
 	}
 	dashboard.DailyActivity = dailyActivity
 
-	rows, err := s.db.Query(`
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT
 			m.user_id,
 			m.email,
@@ -739,10 +740,10 @@ func (s *SQLiteStore) GetStudyGroupDashboard(groupID string) (StudyGroupDashboar
 	return dashboard, rows.Err()
 }
 
-func (s *SQLiteStore) getStudyGroupDailyActivity(groupID string, windowStart time.Time, days int) ([]StudyAnalyticsDay, error) {
-	rows, err := s.db.Query(`
+func (s *SQLStore) getStudyGroupDailyActivity(ctx context.Context, groupID string, windowStart time.Time, days int) ([]StudyAnalyticsDay, error) {
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT
-			date(COALESCE(ss.ended_at, ss.updated_at, ss.started_at), 'unixepoch') AS study_day,
+			`+s.dialect.utcDate("COALESCE(ss.ended_at, ss.updated_at, ss.started_at)")+` AS study_day,
 			COUNT(*),
 			COALESCE(SUM(ss.cards_reviewed), 0),
 			COALESCE(SUM(CASE
@@ -795,17 +796,19 @@ func (s *SQLiteStore) getStudyGroupDailyActivity(groupID string, windowStart tim
 	return dailyActivity, nil
 }
 
-func (s *SQLiteStore) ListStudyGroupSummariesForUser(userID string) ([]StudyGroupSummary, error) {
-	user, err := s.GetUserByID(userID)
+func (s *SQLStore) ListStudyGroupSummariesForUser(ctx context.Context, userID string) ([]StudyGroupSummary, error) {
+	user, err := s.GetUserByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := s.db.Query(`
-		SELECT DISTINCT sg.id
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT sg.id
 		FROM study_groups sg
-		JOIN study_group_members m ON m.study_group_id = sg.id
-		WHERE m.user_id = ? OR lower(m.email) = lower(?)
+		WHERE sg.id IN (
+			SELECT m.study_group_id FROM study_group_members m
+			WHERE m.user_id = ? OR lower(m.email) = lower(?)
+		)
 		ORDER BY sg.created_at DESC
 	`, userID, user.Email)
 	if err != nil {
@@ -819,7 +822,7 @@ func (s *SQLiteStore) ListStudyGroupSummariesForUser(userID string) ([]StudyGrou
 		if err := rows.Scan(&groupID); err != nil {
 			return nil, err
 		}
-		summary, err := s.BuildStudyGroupSummary(groupID, userID, user.Email)
+		summary, err := s.BuildStudyGroupSummary(ctx, groupID, userID, user.Email)
 		if err != nil {
 			return nil, err
 		}
@@ -828,8 +831,8 @@ func (s *SQLiteStore) ListStudyGroupSummariesForUser(userID string) ([]StudyGrou
 	return summaries, rows.Err()
 }
 
-func (s *SQLiteStore) BuildStudyGroupSummary(groupID, userID, email string) (StudyGroupSummary, error) {
-	group, err := s.GetStudyGroup(groupID)
+func (s *SQLStore) BuildStudyGroupSummary(ctx context.Context, groupID, userID, email string) (StudyGroupSummary, error) {
+	group, err := s.GetStudyGroup(ctx, groupID)
 	if err != nil {
 		return StudyGroupSummary{}, err
 	}
@@ -841,27 +844,27 @@ func (s *SQLiteStore) BuildStudyGroupSummary(groupID, userID, email string) (Stu
 		SourceDeckID: group.PrimaryDeckID,
 	}
 
-	if deck, err := s.GetDeck(group.PrimaryDeckID); err == nil {
+	if deck, err := s.GetDeck(ctx, group.PrimaryDeckID); err == nil {
 		summary.SourceDeckName = deck.Name
 	}
 
-	member, err := s.getStudyGroupMembership(group.ID, userID, email)
+	member, err := s.getStudyGroupMembership(ctx, group.ID, userID, email)
 	if err == nil {
 		summary.Role = member.Role
 		summary.MembershipStatus = member.Status
-		if install, err := s.GetCurrentStudyGroupInstall(group.ID, member.ID); err == nil {
+		if install, err := s.GetCurrentStudyGroupInstall(ctx, group.ID, member.ID); err == nil {
 			summary.CurrentUserInstall = install
 		}
 	}
 
-	if latestVersion, err := s.GetLatestStudyGroupVersion(group.ID); err == nil {
+	if latestVersion, err := s.GetLatestStudyGroupVersion(ctx, group.ID); err == nil {
 		summary.LatestVersionNumber = latestVersion.VersionNumber
 		if summary.CurrentUserInstall != nil && summary.CurrentUserInstall.SourceVersionNumber < latestVersion.VersionNumber {
 			summary.UpdateAvailable = true
 		}
 	}
 
-	dashboard, err := s.GetStudyGroupDashboard(group.ID)
+	dashboard, err := s.GetStudyGroupDashboard(ctx, group.ID)
 	if err != nil {
 		return StudyGroupSummary{}, err
 	}
@@ -871,9 +874,9 @@ func (s *SQLiteStore) BuildStudyGroupSummary(groupID, userID, email string) (Stu
 	return summary, nil
 }
 
-func (s *SQLiteStore) getStudyGroupMembership(groupID, userID, email string) (*StudyGroupMember, error) {
+func (s *SQLStore) getStudyGroupMembership(ctx context.Context, groupID, userID, email string) (*StudyGroupMember, error) {
 	if strings.TrimSpace(userID) != "" {
-		member, err := s.GetStudyGroupMemberByUser(groupID, userID)
+		member, err := s.GetStudyGroupMemberByUser(ctx, groupID, userID)
 		if err == nil {
 			return member, nil
 		}
@@ -884,19 +887,19 @@ func (s *SQLiteStore) getStudyGroupMembership(groupID, userID, email string) (*S
 	if strings.TrimSpace(email) == "" {
 		return nil, sql.ErrNoRows
 	}
-	return s.GetStudyGroupMemberByGroupAndEmail(groupID, email)
+	return s.GetStudyGroupMemberByGroupAndEmail(ctx, groupID, email)
 }
 
-func (s *SQLiteStore) BuildStudyGroupDetail(groupID, userID string) (*StudyGroupDetail, error) {
-	user, err := s.GetUserByID(userID)
+func (s *SQLStore) BuildStudyGroupDetail(ctx context.Context, groupID, userID string) (*StudyGroupDetail, error) {
+	user, err := s.GetUserByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
-	group, err := s.GetStudyGroup(groupID)
+	group, err := s.GetStudyGroup(ctx, groupID)
 	if err != nil {
 		return nil, err
 	}
-	member, err := s.getStudyGroupMembership(groupID, userID, user.Email)
+	member, err := s.getStudyGroupMembership(ctx, groupID, userID, user.Email)
 	if err != nil {
 		return nil, err
 	}
@@ -907,19 +910,19 @@ func (s *SQLiteStore) BuildStudyGroupDetail(groupID, userID string) (*StudyGroup
 		MembershipStatus: member.Status,
 	}
 
-	if deck, err := s.GetDeck(group.PrimaryDeckID); err == nil {
+	if deck, err := s.GetDeck(ctx, group.PrimaryDeckID); err == nil {
 		detail.SourceDeckName = deck.Name
 	}
-	if latestVersion, err := s.GetLatestStudyGroupVersion(groupID); err == nil {
+	if latestVersion, err := s.GetLatestStudyGroupVersion(ctx, groupID); err == nil {
 		detail.LatestVersion = latestVersion
 	}
-	if versions, err := s.ListStudyGroupVersions(groupID); err == nil {
+	if versions, err := s.ListStudyGroupVersions(ctx, groupID); err == nil {
 		detail.Versions = versions
 	}
-	if members, err := s.ListStudyGroupMembers(groupID); err == nil {
+	if members, err := s.ListStudyGroupMembers(ctx, groupID); err == nil {
 		detail.Members = members
 	}
-	if install, err := s.GetCurrentStudyGroupInstall(groupID, member.ID); err == nil {
+	if install, err := s.GetCurrentStudyGroupInstall(ctx, groupID, member.ID); err == nil {
 		detail.CurrentUserInstall = install
 		if detail.LatestVersion != nil && install.SourceVersionNumber < detail.LatestVersion.VersionNumber {
 			detail.UpdateAvailable = true
@@ -929,230 +932,211 @@ func (s *SQLiteStore) BuildStudyGroupDetail(groupID, userID string) (*StudyGroup
 	detail.CanManageMembers = member.Role == "owner" || member.Role == "admin"
 	detail.CanInvite = detail.CanManageMembers
 	detail.CanPublishVersion = detail.CanManageMembers
-	if dashboard, err := s.GetStudyGroupDashboard(groupID); err == nil {
+	if dashboard, err := s.GetStudyGroupDashboard(ctx, groupID); err == nil {
 		detail.Dashboard = dashboard
 	}
-	if events, err := s.ListStudyGroupEvents(groupID, 20); err == nil {
+	if events, err := s.ListStudyGroupEvents(ctx, groupID, 20); err == nil {
 		detail.RecentEvents = events
 	}
-	if workspaces, err := s.ListWorkspacesForUser(userID); err == nil {
+	if workspaces, err := s.ListWorkspacesForUser(ctx, userID); err == nil {
 		detail.AvailableWorkspaces = workspaces
 	}
 	return detail, nil
 }
 
-func (s *SQLiteStore) CopyDeckToCollection(sourceDeckID int64, destinationCollectionID, installedDeckName string) (*Deck, error) {
-	sourceCollectionID, err := s.GetDeckCollectionID(sourceDeckID)
+func (s *SQLStore) CopyDeckToCollection(ctx context.Context, sourceDeckID int64, destinationCollectionID, installedDeckName string) (*Deck, error) {
+	sourceCollectionID, err := s.GetDeckCollectionID(ctx, sourceDeckID)
 	if err != nil {
 		return nil, err
 	}
-
-	tx, err := s.db.Begin()
+	sourceDeck, err := s.GetDeck(ctx, sourceDeckID)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback()
-		}
-	}()
 
-	nextID := func(table string) (int64, error) {
-		var next int64
-		query := fmt.Sprintf("SELECT COALESCE(MAX(id), 0) + 1 FROM %s", table)
-		if err := tx.QueryRow(query).Scan(&next); err != nil {
-			return 0, err
+	var newDeckID int64
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		nextID := func(table string) (int64, error) {
+			var next int64
+			query := fmt.Sprintf("SELECT COALESCE(MAX(id), 0) + 1 FROM %s", table)
+			if err := tx.QueryRowContext(ctx, query).Scan(&next); err != nil {
+				return 0, err
+			}
+			return next, nil
 		}
-		return next, nil
-	}
 
-	sourceDeck, err := s.GetDeck(sourceDeckID)
-	if err != nil {
-		return nil, err
-	}
-	newDeckID, err := nextID("decks")
-	if err != nil {
-		return nil, err
-	}
-	deckName := strings.TrimSpace(installedDeckName)
-	if deckName == "" {
-		deckName = sourceDeck.Name
-	}
-	if _, err = tx.Exec(`
-		INSERT INTO decks (id, collection_id, name, parent_id, options_id)
-		VALUES (?, ?, ?, ?, ?)
-	`, newDeckID, destinationCollectionID, deckName, nil, sourceDeck.OptionsID); err != nil {
-		return nil, err
-	}
-
-	rows, err := tx.Query(`
-		SELECT c.id, c.note_id, c.template_name, c.ordinal, c.front, c.back,
-		       c.due, c.state, c.fsrs_data, c.flag, c.marked, c.suspended, c.usn,
-		       n.type_id, n.field_vals, n.tags, n.usn, n.created_at, n.modified_at
-		FROM cards c
-		JOIN notes n ON n.id = c.note_id
-		WHERE c.deck_id = ?
-		ORDER BY c.note_id ASC, c.id ASC
-	`, sourceDeckID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+		var err error
+		newDeckID, err = nextID("decks")
+		if err != nil {
+			return err
+		}
+		deckName := strings.TrimSpace(installedDeckName)
+		if deckName == "" {
+			deckName = sourceDeck.Name
+		}
+		if _, err = tx.ExecContext(ctx, `
+			INSERT INTO decks (id, collection_id, name, parent_id, options_id)
+			VALUES (?, ?, ?, ?, ?)
+		`, newDeckID, destinationCollectionID, deckName, nil, sourceDeck.OptionsID); err != nil {
+			return err
+		}
 
-	noteTypeEnsured := make(map[string]bool)
-	noteIDMap := make(map[int64]int64)
-	for rows.Next() {
-		var (
-			sourceCardID       int64
-			sourceNoteID       int64
-			templateName       string
-			ordinal            int
-			front              string
-			back               string
-			dueUnix            int64
-			state              int
-			fsrsData           []byte
-			flag               int
-			marked             int
-			suspended          int
-			cardUSN            int64
-			noteTypeID         string
-			fieldValsJSON      []byte
-			tagsJSON           []byte
-			noteUSN            int64
-			noteCreatedAtUnix  int64
-			noteModifiedAtUnix int64
-		)
-		if err := rows.Scan(
-			&sourceCardID,
-			&sourceNoteID,
-			&templateName,
-			&ordinal,
-			&front,
-			&back,
-			&dueUnix,
-			&state,
-			&fsrsData,
-			&flag,
-			&marked,
-			&suspended,
-			&cardUSN,
-			&noteTypeID,
-			&fieldValsJSON,
-			&tagsJSON,
-			&noteUSN,
-			&noteCreatedAtUnix,
-			&noteModifiedAtUnix,
-		); err != nil {
-			return nil, err
+		rows, err := tx.QueryContext(ctx, `
+			SELECT c.id, c.note_id, c.template_name, c.ordinal, c.front, c.back,
+			       c.due, c.state, c.fsrs_data, c.flag, c.marked, c.suspended, c.usn,
+			       n.type_id, n.field_vals, n.tags, n.usn, n.created_at, n.modified_at
+			FROM cards c
+			JOIN notes n ON n.id = c.note_id
+			WHERE c.deck_id = ?
+			ORDER BY c.note_id ASC, c.id ASC
+		`, sourceDeckID)
+		if err != nil {
+			return err
 		}
+		defer rows.Close()
 
-		noteTypeName := noteTypeNameFromRecordID(noteTypeID)
-		if !noteTypeEnsured[string(noteTypeName)] {
+		noteTypeEnsured := make(map[string]bool)
+		noteIDMap := make(map[int64]int64)
+		for rows.Next() {
 			var (
-				typeFields     []byte
-				typeTemplates  []byte
-				sortFieldIndex int
-				fieldOptions   []byte
+				sourceCardID       int64
+				sourceNoteID       int64
+				templateName       string
+				ordinal            int
+				front              string
+				back               string
+				dueUnix            int64
+				state              int
+				fsrsData           []byte
+				flag               int
+				marked             int
+				suspended          int
+				cardUSN            int64
+				noteTypeID         string
+				fieldValsJSON      []byte
+				tagsJSON           []byte
+				noteUSN            int64
+				noteCreatedAtUnix  int64
+				noteModifiedAtUnix int64
 			)
-			if err := tx.QueryRow(`
-				SELECT fields, templates, sort_field_index, field_options
-				FROM note_types
-				WHERE collection_id = ? AND name = ?
-			`, sourceCollectionID, string(noteTypeName)).Scan(&typeFields, &typeTemplates, &sortFieldIndex, &fieldOptions); err != nil {
-				return nil, err
+			if err := rows.Scan(
+				&sourceCardID,
+				&sourceNoteID,
+				&templateName,
+				&ordinal,
+				&front,
+				&back,
+				&dueUnix,
+				&state,
+				&fsrsData,
+				&flag,
+				&marked,
+				&suspended,
+				&cardUSN,
+				&noteTypeID,
+				&fieldValsJSON,
+				&tagsJSON,
+				&noteUSN,
+				&noteCreatedAtUnix,
+				&noteModifiedAtUnix,
+			); err != nil {
+				return err
 			}
-			if _, err := tx.Exec(`
-				INSERT INTO note_types (id, collection_id, name, fields, templates, sort_field_index, field_options)
-				VALUES (?, ?, ?, ?, ?, ?, ?)
-				ON CONFLICT(collection_id, name) DO NOTHING
-			`, noteTypeRecordID(destinationCollectionID, noteTypeName), destinationCollectionID, string(noteTypeName), typeFields, typeTemplates, sortFieldIndex, fieldOptions); err != nil {
-				return nil, err
+
+			noteTypeName := noteTypeNameFromRecordID(noteTypeID)
+			if !noteTypeEnsured[string(noteTypeName)] {
+				var (
+					typeFields     []byte
+					typeTemplates  []byte
+					sortFieldIndex int
+					fieldOptions   []byte
+				)
+				if err := tx.QueryRowContext(ctx, `
+					SELECT fields, templates, sort_field_index, field_options
+					FROM note_types
+					WHERE collection_id = ? AND name = ?
+				`, sourceCollectionID, string(noteTypeName)).Scan(&typeFields, &typeTemplates, &sortFieldIndex, &fieldOptions); err != nil {
+					return err
+				}
+				if _, err := tx.ExecContext(ctx, `
+					INSERT INTO note_types (id, collection_id, name, fields, templates, sort_field_index, field_options)
+					VALUES (?, ?, ?, ?, ?, ?, ?)
+					ON CONFLICT(collection_id, name) DO NOTHING
+				`, noteTypeRecordID(destinationCollectionID, noteTypeName), destinationCollectionID, string(noteTypeName), typeFields, typeTemplates, sortFieldIndex, fieldOptions); err != nil {
+					return err
+				}
+				noteTypeEnsured[string(noteTypeName)] = true
 			}
-			noteTypeEnsured[string(noteTypeName)] = true
-		}
 
-		newNoteID, ok := noteIDMap[sourceNoteID]
-		if !ok {
-			newNoteID, err = nextID("notes")
+			newNoteID, ok := noteIDMap[sourceNoteID]
+			if !ok {
+				newNoteID, err = nextID("notes")
+				if err != nil {
+					return err
+				}
+				if _, err := tx.ExecContext(ctx, `
+					INSERT INTO notes (id, collection_id, type_id, field_vals, tags, usn, created_at, modified_at)
+					VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+				`, newNoteID, destinationCollectionID, noteTypeRecordID(destinationCollectionID, noteTypeName), fieldValsJSON, tagsJSON, noteUSN, noteCreatedAtUnix, noteModifiedAtUnix); err != nil {
+					return err
+				}
+				noteIDMap[sourceNoteID] = newNoteID
+			}
+
+			newCardID, err := nextID("cards")
 			if err != nil {
-				return nil, err
+				return err
 			}
-			if _, err := tx.Exec(`
-				INSERT INTO notes (id, collection_id, type_id, field_vals, tags, usn, created_at, modified_at)
-				VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-			`, newNoteID, destinationCollectionID, noteTypeRecordID(destinationCollectionID, noteTypeName), fieldValsJSON, tagsJSON, noteUSN, noteCreatedAtUnix, noteModifiedAtUnix); err != nil {
-				return nil, err
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO cards (
+					id, note_id, deck_id, template_name, ordinal, front, back, due, state, fsrs_data, flag, marked, suspended, usn
+				) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`, newCardID, newNoteID, newDeckID, templateName, ordinal, front, back, dueUnix, state, fsrsData, flag, marked, suspended, cardUSN); err != nil {
+				return err
 			}
-			noteIDMap[sourceNoteID] = newNoteID
-		}
-
-		newCardID, err := nextID("cards")
-		if err != nil {
-			return nil, err
 		}
-		if _, err := tx.Exec(`
-			INSERT INTO cards (
-				id, note_id, deck_id, template_name, ordinal, front, back, due, state, fsrs_data, flag, marked, suspended, usn
-			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		`, newCardID, newNoteID, newDeckID, templateName, ordinal, front, back, dueUnix, state, fsrsData, flag, marked, suspended, cardUSN); err != nil {
-			return nil, err
-		}
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-
-	if err := tx.Commit(); err != nil {
+		return rows.Err()
+	})
+	if err != nil {
 		return nil, err
 	}
-	return s.GetDeck(newDeckID)
+	return s.GetDeck(ctx, newDeckID)
 }
 
-func (s *SQLiteStore) DeleteCopiedDeck(deckID int64) error {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer func() {
+func (s *SQLStore) DeleteCopiedDeck(ctx context.Context, deckID int64) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT DISTINCT note_id FROM cards WHERE deck_id = ?`, deckID)
 		if err != nil {
-			_ = tx.Rollback()
+			return err
 		}
-	}()
+		defer rows.Close()
 
-	rows, err := tx.Query(`SELECT DISTINCT note_id FROM cards WHERE deck_id = ?`, deckID)
-	if err != nil {
-		return err
-	}
-	defer rows.Close()
-
-	var noteIDs []int64
-	for rows.Next() {
-		var noteID int64
-		if err := rows.Scan(&noteID); err != nil {
+		var noteIDs []int64
+		for rows.Next() {
+			var noteID int64
+			if err := rows.Scan(&noteID); err != nil {
+				return err
+			}
+			noteIDs = append(noteIDs, noteID)
+		}
+		if err := rows.Err(); err != nil {
 			return err
 		}
-		noteIDs = append(noteIDs, noteID)
-	}
-	if err := rows.Err(); err != nil {
-		return err
-	}
 
-	if _, err := tx.Exec(`DELETE FROM cards WHERE deck_id = ?`, deckID); err != nil {
-		return err
-	}
-	for _, noteID := range noteIDs {
-		if _, err := tx.Exec(`DELETE FROM notes WHERE id = ?`, noteID); err != nil {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM cards WHERE deck_id = ?`, deckID); err != nil {
 			return err
 		}
-	}
-	if _, err := tx.Exec(`DELETE FROM decks WHERE id = ?`, deckID); err != nil {
+		for _, noteID := range noteIDs {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM notes WHERE id = ?`, noteID); err != nil {
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM decks WHERE id = ?`, deckID)
 		return err
-	}
-	return tx.Commit()
+	})
 }
 
-func (s *SQLiteStore) encodeStudyGroupEventPayload(payload any) string {
+func (s *SQLStore) encodeStudyGroupEventPayload(payload any) string {
 	if payload == nil {
 		return ""
 	}
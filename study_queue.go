@@ -0,0 +1,163 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	interleaveRoundRobin   = "round_robin"
+	interleaveProportional = "proportional"
+
+	defaultStudyQueueLimit = 50
+	maxStudyQueueLimit     = 500
+)
+
+func isValidInterleaveMode(mode string) bool {
+	return mode == interleaveRoundRobin || mode == interleaveProportional
+}
+
+type StudyQueueResponse struct {
+	Mode  string  `json:"mode"`
+	Cards []*Card `json:"cards"`
+}
+
+// interleaveDeckQueues merges per-deck queues into one. Round-robin takes one card from
+// each deck in turn; proportional spreads each deck's cards evenly across the session so
+// a deck with twice the due cards appears twice as often. Each deck's own order is kept.
+func interleaveDeckQueues(queues [][]*Card, mode string, limit int) []*Card {
+	total := 0
+	for _, queue := range queues {
+		total += len(queue)
+	}
+	if limit <= 0 || limit > total {
+		limit = total
+	}
+
+	out := make([]*Card, 0, limit)
+	taken := make([]int, len(queues))
+
+	if mode == interleaveProportional {
+		for len(out) < limit {
+			best := -1
+			var bestScore float64
+			for i, queue := range queues {
+				if taken[i] >= len(queue) {
+					continue
+				}
+				// Pick the deck that is furthest behind its fair share of the session.
+				score := (float64(taken[i]) + 0.5) / float64(len(queue))
+				if best == -1 || score < bestScore {
+					best, bestScore = i, score
+				}
+			}
+			if best == -1 {
+				break
+			}
+			out = append(out, queues[best][taken[best]])
+			taken[best]++
+		}
+		return out
+	}
+
+	for len(out) < limit {
+		progressed := false
+		for i, queue := range queues {
+			if len(out) >= limit {
+				break
+			}
+			if taken[i] < len(queue) {
+				out = append(out, queue[taken[i]])
+				taken[i]++
+				progressed = true
+			}
+		}
+		if !progressed {
+			break
+		}
+	}
+	return out
+}
+
+// GetStudyQueue returns due cards from every deck (or the decks in ?deckIds=) interleaved
+// according to the collection's study preference, or ?mode= when given.
+func (h *APIHandler) GetStudyQueue(w http.ResponseWriter, r *http.Request) {
+	collectionID := h.collectionIDForRequest(r)
+	userID := h.userIDFromRequest(r)
+
+	limit := defaultStudyQueueLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = min(l, maxStudyQueueLimit)
+		}
+	}
+
+	filter, err := parseDueCardFilter(r.URL.Query())
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_filter", err.Error())
+		return
+	}
+
+	mode := strings.TrimSpace(r.URL.Query().Get("mode"))
+	if mode == "" {
+		prefs, err := h.store.GetCollectionPreferences(r.Context(), collectionID)
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "preferences_load_failed", err.Error())
+			return
+		}
+		mode = prefs.Study.InterleaveMode
+	}
+	if !isValidInterleaveMode(mode) {
+		respondAPIError(w, http.StatusBadRequest, "invalid_interleave_mode", "mode must be round_robin or proportional")
+		return
+	}
+
+	decks, err := h.store.ListDecks(r.Context(), collectionID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "decks_load_failed", err.Error())
+		return
+	}
+
+	var onlyDecks map[int64]bool
+	if raw := splitQueryList(r.URL.Query(), "deckIds"); len(raw) > 0 {
+		onlyDecks = make(map[int64]bool, len(raw))
+		for _, value := range raw {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				respondAPIError(w, http.StatusBadRequest, "invalid_deck_id", "deckIds must be deck IDs")
+				return
+			}
+			onlyDecks[id] = true
+		}
+	}
+
+	queues := make([][]*Card, 0, len(decks))
+	for _, deck := range decks {
+		if onlyDecks != nil && !onlyDecks[deck.ID] {
+			continue
+		}
+		cards, err := h.dueCardsForUser(r.Context(), collectionID, userID, deck.ID, limit, filter)
+		if err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "study_queue_failed", err.Error())
+			return
+		}
+		if len(cards) > 0 {
+			queues = append(queues, cards)
+		}
+	}
+
+	cards := interleaveDeckQueues(queues, mode, limit)
+	col, _, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	if err := h.attachNextIntervals(r.Context(), col, cards, time.Now()); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "study_queue_failed", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, StudyQueueResponse{Mode: mode, Cards: cards})
+}
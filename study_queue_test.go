@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func testQueue(deckID int64, n int) []*Card {
+	queue := make([]*Card, n)
+	for i := range queue {
+		queue[i] = &Card{ID: deckID*100 + int64(i), DeckID: deckID}
+	}
+	return queue
+}
+
+func deckSequence(cards []*Card) string {
+	out := ""
+	for _, card := range cards {
+		out += fmt.Sprint(card.DeckID)
+	}
+	return out
+}
+
+func TestInterleaveDeckQueues(t *testing.T) {
+	queues := [][]*Card{testQueue(1, 4), testQueue(2, 2), testQueue(3, 1)}
+
+	if got := deckSequence(interleaveDeckQueues(queues, interleaveRoundRobin, 0)); got != "1231211" {
+		t.Fatalf("unexpected round-robin order %s", got)
+	}
+	if got := deckSequence(interleaveDeckQueues(queues, interleaveRoundRobin, 4)); got != "1231" {
+		t.Fatalf("expected limit to cut the round-robin queue, got %s", got)
+	}
+
+	proportional := interleaveDeckQueues(queues, interleaveProportional, 0)
+	if got := deckSequence(proportional); got != "1213121" {
+		t.Fatalf("unexpected proportional order %s", got)
+	}
+	if len(proportional) != 7 {
+		t.Fatalf("expected every card once, got %d", len(proportional))
+	}
+	for i := 1; i < len(proportional); i++ {
+		prev, cur := proportional[i-1], proportional[i]
+		if prev.DeckID == cur.DeckID && cur.ID < prev.ID {
+			t.Fatalf("expected per-deck order to be preserved")
+		}
+	}
+
+	heavy := interleaveDeckQueues([][]*Card{testQueue(1, 6), testQueue(2, 2)}, interleaveProportional, 4)
+	if got := deckSequence(heavy); got != "1121" {
+		t.Fatalf("expected the larger deck to dominate the first four cards, got %s", got)
+	}
+}
+
+func TestAPI_StudyQueueInterleavesDecksPerPreference(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	secondDeckRR := doJSONRequest(t, env.router, http.MethodPost, "/api/decks", CreateDeckRequest{Name: "Second"})
+	if secondDeckRR.Code != http.StatusCreated {
+		t.Fatalf("expected deck create 201, got %d (%s)", secondDeckRR.Code, secondDeckRR.Body.String())
+	}
+	secondDeck := decodeJSON[DeckResponse](t, secondDeckRR)
+
+	for i := 0; i < 3; i++ {
+		for _, deckID := range []int64{1, secondDeck.ID} {
+			createNoteForTest(t, env, CreateNoteRequest{
+				TypeID: "Basic",
+				DeckID: deckID,
+				FieldVals: map[string]string{
+					"Front": fmt.Sprintf("Deck %d card %d", deckID, i),
+					"Back":  "Answer",
+				},
+			}, nil)
+		}
+	}
+
+	rr := doRawRequest(env.router, http.MethodGet, "/api/study/queue?limit=10", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected study queue 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	queue := decodeJSON[StudyQueueResponse](t, rr)
+	if queue.Mode != interleaveRoundRobin || len(queue.Cards) != 6 {
+		t.Fatalf("expected 6 round-robin cards, got mode=%s len=%d", queue.Mode, len(queue.Cards))
+	}
+	for i := 1; i < len(queue.Cards); i++ {
+		if queue.Cards[i].DeckID == queue.Cards[i-1].DeckID {
+			t.Fatalf("expected decks to alternate, got %s", deckSequence(queue.Cards))
+		}
+	}
+
+	mode := interleaveProportional
+	prefsRR := doJSONRequest(t, env.router, http.MethodPatch, "/api/preferences", UpdatePreferencesRequest{InterleaveMode: &mode})
+	if prefsRR.Code != http.StatusOK {
+		t.Fatalf("expected preferences update 200, got %d (%s)", prefsRR.Code, prefsRR.Body.String())
+	}
+	if prefs := decodeJSON[CollectionPreferences](t, prefsRR); prefs.Study.InterleaveMode != interleaveProportional {
+		t.Fatalf("expected proportional preference to persist, got %+v", prefs.Study)
+	}
+
+	onlySecond := doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/study/queue?deckIds=%d", secondDeck.ID), "")
+	scoped := decodeJSON[StudyQueueResponse](t, onlySecond)
+	if scoped.Mode != interleaveProportional || len(scoped.Cards) != 3 {
+		t.Fatalf("expected 3 proportional cards from the second deck, got mode=%s len=%d", scoped.Mode, len(scoped.Cards))
+	}
+	for _, card := range scoped.Cards {
+		if card.DeckID != secondDeck.ID {
+			t.Fatalf("expected only second deck cards, got deck %d", card.DeckID)
+		}
+	}
+
+	bad := "shuffle"
+	if rr := doJSONRequest(t, env.router, http.MethodPatch, "/api/preferences", UpdatePreferencesRequest{InterleaveMode: &bad}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid mode 400, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// The /api/study/sessions endpoints run a review session on the server: it serves the
+// deck's next due card, applies answers, and keeps the session's counts, so clients do
+// not have to orchestrate due fetching and answering themselves.
+
+// StartStudySessionRequest is the body of POST /api/study/sessions.
+type StartStudySessionRequest struct {
+	DeckID int64 `json:"deckId"`
+}
+
+func (req *StartStudySessionRequest) Validate(v *requestValidator) {
+	v.check(req.DeckID > 0, "deckId", "is required")
+}
+
+// StudySessionNextResponse holds the next card to study, or no card once the deck has
+// nothing left due.
+type StudySessionNextResponse struct {
+	Session *StudySession `json:"session"`
+	Card    *Card         `json:"card"`
+	Done    bool          `json:"done"`
+}
+
+// StudySessionAnswerRequest is the body of POST /api/study/sessions/{id}/answer.
+type StudySessionAnswerRequest struct {
+	CardID      int64 `json:"cardId"`
+	Rating      int   `json:"rating"` // 1=Again, 2=Hard, 3=Good, 4=Easy
+	TimeTakenMs int   `json:"timeTakenMs"`
+}
+
+func (req *StudySessionAnswerRequest) Validate(v *requestValidator) {
+	v.check(req.CardID > 0, "cardId", "is required")
+	v.rating("rating", req.Rating)
+	v.check(req.TimeTakenMs >= 0, "timeTakenMs", "must not be negative")
+}
+
+// StudySessionAnswerResponse holds the answered card and the updated session.
+type StudySessionAnswerResponse struct {
+	Session *StudySession `json:"session"`
+	Card    *Card         `json:"card"`
+}
+
+// StudySessionReport is returned when a session finishes.
+type StudySessionReport struct {
+	Session         *StudySession `json:"session"`
+	CardsStudied    int           `json:"cardsStudied"`
+	AgainCount      int           `json:"againCount"`
+	HardCount       int           `json:"hardCount"`
+	GoodCount       int           `json:"goodCount"`
+	EasyCount       int           `json:"easyCount"`
+	TotalTimeMs     int64         `json:"totalTimeMs"`
+	AverageTimeMs   int64         `json:"averageTimeMs"`
+	DurationSeconds int64         `json:"durationSeconds"`
+}
+
+// countStudySessionAnswer adds an answer with rating to the session's counts.
+func countStudySessionAnswer(session *StudySession, rating fsrs.Rating, timeTakenMs int) {
+	session.CardsReviewed++
+	session.TotalTimeMs += int64(max(timeTakenMs, 0))
+	switch rating {
+	case fsrs.Again:
+		session.AgainCount++
+	case fsrs.Hard:
+		session.HardCount++
+	case fsrs.Good:
+		session.GoodCount++
+	case fsrs.Easy:
+		session.EasyCount++
+	}
+}
+
+// reportStudySession sums up a finished session.
+func reportStudySession(session *StudySession) StudySessionReport {
+	report := StudySessionReport{
+		Session:      session,
+		CardsStudied: session.CardsReviewed,
+		AgainCount:   session.AgainCount,
+		HardCount:    session.HardCount,
+		GoodCount:    session.GoodCount,
+		EasyCount:    session.EasyCount,
+		TotalTimeMs:  session.TotalTimeMs,
+	}
+	if session.CardsReviewed > 0 {
+		report.AverageTimeMs = session.TotalTimeMs / int64(session.CardsReviewed)
+	}
+	if !session.EndedAt.IsZero() {
+		report.DurationSeconds = int64(session.EndedAt.Sub(session.StartedAt).Seconds())
+	}
+	return report
+}
+
+// StartStudySession serves POST /api/study/sessions.
+func (h *APIHandler) StartStudySession(w http.ResponseWriter, r *http.Request) {
+	session := h.sessionFromRequest(r)
+	if session == nil || strings.TrimSpace(session.UserID) == "" {
+		respondAPIError(w, http.StatusUnauthorized, "study_session_unauthorized", "Authentication is required.")
+		return
+	}
+	var req StartStudySessionRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	col, _, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	if col.Decks[req.DeckID] == nil {
+		respondAPIError(w, http.StatusBadRequest, "deck_not_found", "Deck not found.")
+		return
+	}
+	workspace, err := h.workspaceForSession(r.Context(), session)
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "workspace_not_found", "Workspace not found.")
+		return
+	}
+
+	now := time.Now()
+	studySession := &StudySession{
+		ID:          newID("sts"),
+		UserID:      session.UserID,
+		WorkspaceID: workspace.ID,
+		DeckID:      req.DeckID,
+		Mode:        "review",
+		Status:      "active",
+		StartedAt:   now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := h.store.CreateStudySessionRecord(r.Context(), studySession); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "study_session_create_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusCreated, studySession)
+}
+
+// activeStudySession loads the caller's active review session named by the id URL
+// parameter. It writes an error response and returns nil otherwise.
+func (h *APIHandler) activeStudySession(w http.ResponseWriter, r *http.Request) *StudySession {
+	session := h.sessionFromRequest(r)
+	if session == nil || strings.TrimSpace(session.UserID) == "" {
+		respondAPIError(w, http.StatusUnauthorized, "study_session_unauthorized", "Authentication is required.")
+		return nil
+	}
+	studySession, err := h.store.GetStudySessionForUser(r.Context(), strings.TrimSpace(chi.URLParam(r, "id")), session.UserID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && (studySession.Mode != "review" || studySession.DeckID == 0)) {
+		respondAPIError(w, http.StatusNotFound, "study_session_not_found", "Study session not found.")
+		return nil
+	}
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "study_session_load_failed", err.Error())
+		return nil
+	}
+	if studySession.Status != "active" {
+		respondAPIError(w, http.StatusConflict, "study_session_closed", "Study session is already closed.")
+		return nil
+	}
+	return studySession
+}
+
+// NextStudySessionCard serves GET /api/study/sessions/{id}/next.
+func (h *APIHandler) NextStudySessionCard(w http.ResponseWriter, r *http.Request) {
+	studySession := h.activeStudySession(w, r)
+	if studySession == nil {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	cards, err := h.dueCardsForUser(r.Context(), collectionID, h.userIDFromRequest(r), studySession.DeckID, 1, DueCardFilter{})
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "study_queue_failed", err.Error())
+		return
+	}
+	if err := h.attachNextIntervals(r.Context(), col, cards, time.Now()); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "study_queue_failed", err.Error())
+		return
+	}
+	response := StudySessionNextResponse{Session: studySession, Done: len(cards) == 0}
+	if len(cards) > 0 {
+		response.Card = cards[0]
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// AnswerStudySessionCard serves POST /api/study/sessions/{id}/answer.
+func (h *APIHandler) AnswerStudySessionCard(w http.ResponseWriter, r *http.Request) {
+	studySession := h.activeStudySession(w, r)
+	if studySession == nil {
+		return
+	}
+	var req StudySessionAnswerRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	col, _, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	if col.Cards[req.CardID] == nil {
+		respondAPIError(w, http.StatusNotFound, "card_not_found", "Card not found")
+		return
+	}
+
+	userID := h.userIDFromRequest(r)
+	card, err := h.store.GetCardForUser(r.Context(), userID, req.CardID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "card_load_failed", err.Error())
+		return
+	}
+	now := time.Now()
+	if err := h.applyAnswer(r.Context(), col, userID, card, fsrs.Rating(req.Rating), req.TimeTakenMs, now); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "card_update_failed", err.Error())
+		return
+	}
+
+	countStudySessionAnswer(studySession, fsrs.Rating(req.Rating), req.TimeTakenMs)
+	studySession.UpdatedAt = now
+	if err := h.store.UpdateStudySessionRecord(r.Context(), studySession); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "study_session_update_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, StudySessionAnswerResponse{Session: studySession, Card: card})
+}
+
+// FinishStudySession serves POST /api/study/sessions/{id}/finish.
+func (h *APIHandler) FinishStudySession(w http.ResponseWriter, r *http.Request) {
+	studySession := h.activeStudySession(w, r)
+	if studySession == nil {
+		return
+	}
+	now := time.Now()
+	studySession.Status = "completed"
+	studySession.EndedAt = now
+	studySession.UpdatedAt = now
+	if err := h.store.UpdateStudySessionRecord(r.Context(), studySession); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "study_session_update_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, reportStudySession(studySession))
+}
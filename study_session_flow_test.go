@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAPI_StudySessionFlow(t *testing.T) {
+	env := setupAPITestEnv(t)
+	for _, front := range []string{"Axon", "Dendrite"} {
+		createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": front, "Back": "Neuron"},
+		}, nil)
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/study/sessions", StartStudySessionRequest{DeckID: 999}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown deck to be rejected, got %d", rr.Code)
+	}
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/study/sessions", StartStudySessionRequest{DeckID: 1})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected session start 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	session := decodeJSON[StudySession](t, rr)
+	base := fmt.Sprintf("/api/study/sessions/%s", session.ID)
+
+	// Easy graduates each new card, so the deck runs out after two answers.
+	for i, rating := range []int{4, 4} {
+		next := decodeJSON[StudySessionNextResponse](t, doRawRequest(env.router, http.MethodGet, base+"/next", ""))
+		if next.Done || next.Card == nil || next.Card.NextIntervals == nil {
+			t.Fatalf("expected card %d to be served with interval previews, got %+v", i, next)
+		}
+		rr := doJSONRequest(t, env.router, http.MethodPost, base+"/answer", StudySessionAnswerRequest{CardID: next.Card.ID, Rating: rating, TimeTakenMs: 3000})
+		answered := decodeJSON[StudySessionAnswerResponse](t, rr)
+		if rr.Code != http.StatusOK || answered.Session.CardsReviewed != i+1 || answered.Card.SRS.Reps != 1 {
+			t.Fatalf("expected the answer applied and counted, got %d (%s)", rr.Code, rr.Body.String())
+		}
+	}
+	if next := decodeJSON[StudySessionNextResponse](t, doRawRequest(env.router, http.MethodGet, base+"/next", "")); !next.Done || next.Card != nil {
+		t.Fatalf("expected the session to be done, got %+v", next)
+	}
+
+	rr = doRawRequest(env.router, http.MethodPost, base+"/finish", "")
+	report := decodeJSON[StudySessionReport](t, rr)
+	if rr.Code != http.StatusOK || report.CardsStudied != 2 || report.EasyCount != 2 || report.TotalTimeMs != 6000 || report.AverageTimeMs != 3000 || report.Session.Status != "completed" {
+		t.Fatalf("expected a summary of the session, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPost, base+"/answer", StudySessionAnswerRequest{CardID: 1, Rating: 3}); rr.Code != http.StatusConflict {
+		t.Fatalf("expected answers to a finished session to be rejected, got %d", rr.Code)
+	}
+}
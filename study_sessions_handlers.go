@@ -2,7 +2,6 @@ package main
 
 import (
 	"database/sql"
-	"encoding/json"
 	"net/http"
 	"strings"
 	"time"
@@ -44,40 +43,23 @@ func (h *APIHandler) CreateStudySession(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	workspace, err := h.workspaceForSession(session)
+	workspace, err := h.workspaceForSession(r.Context(), session)
 	if err != nil {
 		respondAPIError(w, http.StatusBadRequest, "workspace_not_found", "Workspace not found.")
 		return
 	}
 
 	var req CreateStudySessionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body.")
-		return
-	}
-
-	req.Mode = strings.TrimSpace(req.Mode)
-	if req.Mode == "" {
-		req.Mode = "review"
-	}
-	if !validStudySessionMode(req.Mode) {
-		respondAPIError(w, http.StatusBadRequest, "invalid_study_session_mode", "Mode must be review or focus.")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
+	req.Mode = firstNonEmpty(strings.TrimSpace(req.Mode), "review")
 	req.Protocol = strings.TrimSpace(req.Protocol)
 	if req.Mode == "focus" {
-		if !validFocusProtocol(req.Protocol) {
-			respondAPIError(w, http.StatusBadRequest, "invalid_focus_protocol", "Protocol must be pomodoro, deep-focus, or custom.")
-			return
-		}
 		if req.Protocol == "" {
 			req.Protocol = "pomodoro"
 		}
-		if req.TargetMinutes < 0 || req.BreakMinutes < 0 {
-			respondAPIError(w, http.StatusBadRequest, "invalid_focus_minutes", "Focus and break durations must be zero or greater.")
-			return
-		}
 		if req.TargetMinutes == 0 {
 			switch req.Protocol {
 			case "deep-focus":
@@ -94,10 +76,6 @@ func (h *APIHandler) CreateStudySession(w http.ResponseWriter, r *http.Request)
 				req.BreakMinutes = 5
 			}
 		}
-		if req.DeckID != 0 {
-			respondAPIError(w, http.StatusBadRequest, "invalid_focus_deck", "Focus sessions do not attach to a deck.")
-			return
-		}
 	} else {
 		req.Protocol = ""
 		req.TargetMinutes = 0
@@ -105,7 +83,7 @@ func (h *APIHandler) CreateStudySession(w http.ResponseWriter, r *http.Request)
 	}
 
 	if req.DeckID != 0 {
-		deckCollectionID, err := h.store.GetDeckCollectionID(req.DeckID)
+		deckCollectionID, err := h.store.GetDeckCollectionID(r.Context(), req.DeckID)
 		if err != nil {
 			respondAPIError(w, http.StatusBadRequest, "deck_not_found", "Deck not found.")
 			return
@@ -132,7 +110,7 @@ func (h *APIHandler) CreateStudySession(w http.ResponseWriter, r *http.Request)
 		UpdatedAt:     now,
 	}
 
-	if err := h.store.CreateStudySessionRecord(studySession); err != nil {
+	if err := h.store.CreateStudySessionRecord(r.Context(), studySession); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_session_create_failed", err.Error())
 		return
 	}
@@ -153,7 +131,7 @@ func (h *APIHandler) UpdateStudySession(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	studySession, err := h.store.GetStudySessionForUser(studySessionID, session.UserID)
+	studySession, err := h.store.GetStudySessionForUser(r.Context(), studySessionID, session.UserID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			respondAPIError(w, http.StatusNotFound, "study_session_not_found", "Study session not found.")
@@ -169,23 +147,10 @@ func (h *APIHandler) UpdateStudySession(w http.ResponseWriter, r *http.Request)
 	}
 
 	var req UpdateStudySessionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body.")
-		return
-	}
-
-	if req.Status != "" && !validStudySessionStatus(req.Status) {
-		respondAPIError(w, http.StatusBadRequest, "invalid_study_session_status", "Status must be active, completed, or abandoned.")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
 
-	for _, value := range []*int{req.CardsReviewed, req.AgainCount, req.HardCount, req.GoodCount, req.EasyCount} {
-		if value != nil && *value < 0 {
-			respondAPIError(w, http.StatusBadRequest, "invalid_study_session_counts", "Study session counts must be zero or greater.")
-			return
-		}
-	}
-
 	if req.CardsReviewed != nil {
 		studySession.CardsReviewed = *req.CardsReviewed
 	}
@@ -214,12 +179,12 @@ func (h *APIHandler) UpdateStudySession(w http.ResponseWriter, r *http.Request)
 	}
 
 	studySession.UpdatedAt = time.Now()
-	if err := h.store.UpdateStudySessionRecord(studySession); err != nil {
+	if err := h.store.UpdateStudySessionRecord(r.Context(), studySession); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_session_update_failed", err.Error())
 		return
 	}
 
-	reloaded, err := h.store.GetStudySessionForUser(studySession.ID, session.UserID)
+	reloaded, err := h.store.GetStudySessionForUser(r.Context(), studySession.ID, session.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_session_reload_failed", err.Error())
 		return
@@ -235,7 +200,7 @@ func (h *APIHandler) GetStudyAnalyticsOverview(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	analytics, err := h.store.GetStudyAnalyticsOverview(session.UserID, session.WorkspaceID)
+	analytics, err := h.store.GetStudyAnalyticsOverview(r.Context(), session.UserID, session.WorkspaceID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "study_analytics_load_failed", err.Error())
 		return
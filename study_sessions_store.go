@@ -1,16 +1,17 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"time"
 )
 
-func (s *SQLiteStore) CreateStudySessionRecord(session *StudySession) error {
-	_, err := s.db.Exec(`
+func (s *SQLStore) CreateStudySessionRecord(ctx context.Context, session *StudySession) error {
+	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO study_sessions (
 			id, user_id, workspace_id, deck_id, mode, protocol, target_minutes, break_minutes, status, started_at, ended_at,
-			cards_reviewed, again_count, hard_count, good_count, easy_count, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			cards_reviewed, again_count, hard_count, good_count, easy_count, total_time_ms, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		session.ID,
 		session.UserID,
@@ -28,37 +29,63 @@ func (s *SQLiteStore) CreateStudySessionRecord(session *StudySession) error {
 		session.HardCount,
 		session.GoodCount,
 		session.EasyCount,
+		session.TotalTimeMs,
 		session.CreatedAt.Unix(),
 		session.UpdatedAt.Unix(),
 	)
 	return err
 }
 
-func (s *SQLiteStore) GetStudySession(id string) (*StudySession, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetStudySession(ctx context.Context, id string) (*StudySession, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, user_id, workspace_id, deck_id, mode, protocol, target_minutes, break_minutes, status, started_at, ended_at,
-			cards_reviewed, again_count, hard_count, good_count, easy_count, created_at, updated_at
+			cards_reviewed, again_count, hard_count, good_count, easy_count, total_time_ms, created_at, updated_at
 		FROM study_sessions
 		WHERE id = ?
 	`, id)
 	return scanStudySession(row)
 }
 
-func (s *SQLiteStore) GetStudySessionForUser(id, userID string) (*StudySession, error) {
-	row := s.db.QueryRow(`
+func (s *SQLStore) GetStudySessionForUser(ctx context.Context, id, userID string) (*StudySession, error) {
+	row := s.db.QueryRowContext(ctx, `
 		SELECT id, user_id, workspace_id, deck_id, mode, protocol, target_minutes, break_minutes, status, started_at, ended_at,
-			cards_reviewed, again_count, hard_count, good_count, easy_count, created_at, updated_at
+			cards_reviewed, again_count, hard_count, good_count, easy_count, total_time_ms, created_at, updated_at
 		FROM study_sessions
 		WHERE id = ? AND user_id = ?
 	`, id, userID)
 	return scanStudySession(row)
 }
 
-func (s *SQLiteStore) UpdateStudySessionRecord(session *StudySession) error {
-	_, err := s.db.Exec(`
+// ListStudySessionsForUser returns every study session of the user, oldest first.
+func (s *SQLStore) ListStudySessionsForUser(ctx context.Context, userID string) ([]StudySession, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, workspace_id, deck_id, mode, protocol, target_minutes, break_minutes, status, started_at, ended_at,
+			cards_reviewed, again_count, hard_count, good_count, easy_count, total_time_ms, created_at, updated_at
+		FROM study_sessions
+		WHERE user_id = ?
+		ORDER BY started_at, id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []StudySession{}
+	for rows.Next() {
+		session, err := scanStudySession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *SQLStore) UpdateStudySessionRecord(ctx context.Context, session *StudySession) error {
+	_, err := s.db.ExecContext(ctx, `
 		UPDATE study_sessions
 		SET status = ?, ended_at = ?, cards_reviewed = ?, again_count = ?, hard_count = ?,
-			good_count = ?, easy_count = ?, updated_at = ?
+			good_count = ?, easy_count = ?, total_time_ms = ?, updated_at = ?
 		WHERE id = ?
 	`,
 		session.Status,
@@ -68,6 +95,7 @@ func (s *SQLiteStore) UpdateStudySessionRecord(session *StudySession) error {
 		session.HardCount,
 		session.GoodCount,
 		session.EasyCount,
+		session.TotalTimeMs,
 		session.UpdatedAt.Unix(),
 		session.ID,
 	)
@@ -101,6 +129,7 @@ func scanStudySession(scanner interface{ Scan(dest ...any) error }) (*StudySessi
 		&session.HardCount,
 		&session.GoodCount,
 		&session.EasyCount,
+		&session.TotalTimeMs,
 		&createdAt,
 		&updatedAt,
 	); err != nil {
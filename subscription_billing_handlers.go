@@ -21,30 +21,25 @@ func (h *APIHandler) handleBillingCheckout(w http.ResponseWriter, r *http.Reques
 	}
 
 	var req billingCheckoutRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid checkout request.")
+	if !decodeValidatedRequest(w, r, &req) {
 		return
 	}
-	if req.Plan != PlanPro && req.Plan != PlanTeam {
-		if req.Plan == PlanEnterprise {
-			respondAPIError(w, http.StatusConflict, "enterprise_sales_required", "Enterprise plans are handled manually. Contact sales to continue.")
-			return
-		}
-		respondAPIError(w, http.StatusBadRequest, "invalid_plan", "Checkout is only available for Pro and Team plans.")
+	if req.Plan == PlanEnterprise {
+		respondAPIError(w, http.StatusConflict, "enterprise_sales_required", "Enterprise plans are handled manually. Contact sales to continue.")
 		return
 	}
 
-	user, err := h.store.GetUserByID(session.UserID)
+	user, err := h.store.GetUserByID(r.Context(), session.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "user_lookup_failed", err.Error())
 		return
 	}
-	workspace, err := h.workspaceForSession(session)
+	workspace, err := h.workspaceForSession(r.Context(), session)
 	if err != nil || workspace == nil {
 		respondAPIError(w, http.StatusInternalServerError, "workspace_not_found", "Workspace not found.")
 		return
 	}
-	org, orgMember, err := h.billingWorkspaceContext(session, workspace)
+	org, orgMember, err := h.billingWorkspaceContext(r.Context(), session, workspace)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "workspace_context_failed", err.Error())
 		return
@@ -64,7 +59,7 @@ func (h *APIHandler) handleBillingCheckout(w http.ResponseWriter, r *http.Reques
 		if h.config.IsDevelopment() {
 			orgForManual := org
 			if req.Plan == PlanTeam {
-				orgForManual, err = h.ensureTeamOrganizationForBilling(workspace, user, session)
+				orgForManual, err = h.ensureTeamOrganizationForBilling(r.Context(), workspace, user, session)
 				if err != nil {
 					respondAPIError(w, http.StatusInternalServerError, "organization_create_failed", err.Error())
 					return
@@ -93,12 +88,12 @@ func (h *APIHandler) handleBillingCheckout(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	existingSubscription := h.organizationSubscription(workspace.OrganizationID, workspace.ID)
+	existingSubscription := h.organizationSubscription(r.Context(), workspace.OrganizationID, workspace.ID)
 	quantity := 1
 	if req.Plan == PlanTeam {
 		activeMembers := 0
 		if org != nil {
-			activeMembers, err = h.store.CountActiveOrganizationMembers(org.ID)
+			activeMembers, err = h.store.CountActiveOrganizationMembers(r.Context(), org.ID)
 			if err != nil {
 				respondAPIError(w, http.StatusInternalServerError, "organization_members_failed", err.Error())
 				return
@@ -143,7 +138,7 @@ func (h *APIHandler) handleBillingCheckout(w http.ResponseWriter, r *http.Reques
 	subscription.CancelAtPeriodEnd = false
 	subscription.ScheduledPlan = ""
 	subscription.UpdatedAt = now
-	if err := h.store.UpsertSubscription(subscription); err != nil {
+	if err := h.store.UpsertSubscription(r.Context(), subscription); err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "billing_subscription_failed", err.Error())
 		return
 	}
@@ -165,16 +160,16 @@ func (h *APIHandler) handleBillingPortal(w http.ResponseWriter, r *http.Request)
 	}
 
 	var req billingPortalRequest
-	if r.Body != nil {
-		_ = json.NewDecoder(r.Body).Decode(&req)
+	if !decodeOptionalValidatedRequest(w, r, &req) {
+		return
 	}
 
-	workspace, err := h.workspaceForSession(session)
+	workspace, err := h.workspaceForSession(r.Context(), session)
 	if err != nil || workspace == nil {
 		respondAPIError(w, http.StatusInternalServerError, "workspace_not_found", "Workspace not found.")
 		return
 	}
-	_, orgMember, err := h.billingWorkspaceContext(session, workspace)
+	_, orgMember, err := h.billingWorkspaceContext(r.Context(), session, workspace)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "workspace_context_failed", err.Error())
 		return
@@ -184,7 +179,7 @@ func (h *APIHandler) handleBillingPortal(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	subscription := h.organizationSubscription(workspace.OrganizationID, workspace.ID)
+	subscription := h.organizationSubscription(r.Context(), workspace.OrganizationID, workspace.ID)
 	if !subscriptionGrantsPlan(subscription, time.Now()) || subscription == nil || strings.TrimSpace(subscription.ProviderCustomerID) == "" {
 		respondAPIError(w, http.StatusConflict, "billing_portal_unavailable", "This workspace does not have an active Stripe subscription to manage.")
 		return
@@ -203,7 +198,7 @@ func (h *APIHandler) handleBillingPortal(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	if subscription.Plan == PlanTeam && (targetPlan == PlanFree || targetPlan == PlanPro) {
-		activeMembers, err := h.store.CountActiveOrganizationMembers(workspace.OrganizationID)
+		activeMembers, err := h.store.CountActiveOrganizationMembers(r.Context(), workspace.OrganizationID)
 		if err != nil {
 			respondAPIError(w, http.StatusInternalServerError, "organization_members_failed", err.Error())
 			return
@@ -248,12 +243,12 @@ func (h *APIHandler) handleBillingCheckoutSync(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	subscription, err := h.store.GetSubscriptionByProviderCheckoutSessionID(checkoutSessionID)
+	subscription, err := h.store.GetSubscriptionByProviderCheckoutSessionID(r.Context(), checkoutSessionID)
 	if err != nil {
 		respondAPIError(w, http.StatusNotFound, "billing_checkout_not_found", "Billing checkout session not found.")
 		return
 	}
-	if !h.subscriptionBelongsToWorkspaceSession(subscription, session) {
+	if !h.subscriptionBelongsToWorkspaceSession(r.Context(), subscription, session) {
 		respondAPIError(w, http.StatusForbidden, "billing_checkout_forbidden", "That checkout session does not belong to the current workspace.")
 		return
 	}
@@ -272,12 +267,12 @@ func (h *APIHandler) handleBillingCheckoutSync(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	user, err := h.store.GetUserByID(session.UserID)
+	user, err := h.store.GetUserByID(r.Context(), session.UserID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "user_lookup_failed", err.Error())
 		return
 	}
-	workspace, err := h.store.GetWorkspaceRecord(subscription.WorkspaceID)
+	workspace, err := h.store.GetWorkspaceRecord(r.Context(), subscription.WorkspaceID)
 	if err != nil {
 		respondAPIError(w, http.StatusInternalServerError, "workspace_not_found", err.Error())
 		return
@@ -339,7 +334,7 @@ func (h *APIHandler) handleBillingWebhook(w http.ResponseWriter, r *http.Request
 				respondAPIError(w, http.StatusBadGateway, "billing_subscription_sync_failed", err.Error())
 				return
 			}
-			subscription, workspace, user, err := h.resolveSubscriptionRecordForStripe(checkoutSession.Metadata, checkoutSession.Subscription, checkoutSession.ID)
+			subscription, workspace, user, err := h.resolveSubscriptionRecordForStripe(r.Context(), checkoutSession.Metadata, checkoutSession.Subscription, checkoutSession.ID)
 			if err != nil {
 				respondAPIError(w, http.StatusInternalServerError, "billing_subscription_failed", err.Error())
 				return
@@ -357,7 +352,7 @@ func (h *APIHandler) handleBillingWebhook(w http.ResponseWriter, r *http.Request
 			return
 		}
 		state := h.subscriptionBilling.(*stripeSubscriptionBillingProvider).stateFromStripeSubscription(&stripeSubscription)
-		subscription, workspace, user, err := h.resolveSubscriptionRecordForStripe(stripeSubscription.Metadata, stripeSubscription.ID, "")
+		subscription, workspace, user, err := h.resolveSubscriptionRecordForStripe(r.Context(), stripeSubscription.Metadata, stripeSubscription.ID, "")
 		if err != nil {
 			respondAPIError(w, http.StatusInternalServerError, "billing_subscription_failed", err.Error())
 			return
@@ -373,7 +368,7 @@ func (h *APIHandler) handleBillingWebhook(w http.ResponseWriter, r *http.Request
 	}
 
 	if strings.TrimSpace(eventSubscriptionID) != "" {
-		if err := h.store.CreateSubscriptionEvent(&SubscriptionEvent{
+		if err := h.store.CreateSubscriptionEvent(r.Context(), &SubscriptionEvent{
 			ID:              newID("subevt"),
 			SubscriptionID:  eventSubscriptionID,
 			EventType:       envelope.Type,
@@ -389,15 +384,15 @@ func (h *APIHandler) handleBillingWebhook(w http.ResponseWriter, r *http.Request
 	respondJSON(w, http.StatusOK, map[string]bool{"ok": true})
 }
 
-func (h *APIHandler) billingWorkspaceContext(session *SessionRecord, workspace *Workspace) (*Organization, *OrganizationMember, error) {
+func (h *APIHandler) billingWorkspaceContext(ctx context.Context, session *SessionRecord, workspace *Workspace) (*Organization, *OrganizationMember, error) {
 	if workspace == nil || strings.TrimSpace(workspace.OrganizationID) == "" {
 		return nil, nil, nil
 	}
-	org, err := h.store.GetOrganizationRecord(workspace.OrganizationID)
+	org, err := h.store.GetOrganizationRecord(ctx, workspace.OrganizationID)
 	if err != nil {
 		return nil, nil, err
 	}
-	member, err := h.store.GetOrganizationMemberByUser(workspace.OrganizationID, session.UserID)
+	member, err := h.store.GetOrganizationMemberByUser(ctx, workspace.OrganizationID, session.UserID)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return nil, nil, err
 	}
@@ -417,23 +412,23 @@ func (h *APIHandler) canManageBillingPlan(session *SessionRecord, workspace *Wor
 	return orgMember != nil && orgMember.Status == "active" && canManageOrganizationPlan(orgMember.Role)
 }
 
-func (h *APIHandler) ensureTeamOrganizationForBilling(workspace *Workspace, user *User, session *SessionRecord) (*Organization, error) {
+func (h *APIHandler) ensureTeamOrganizationForBilling(ctx context.Context, workspace *Workspace, user *User, session *SessionRecord) (*Organization, error) {
 	if workspace == nil {
 		return nil, sql.ErrNoRows
 	}
 	if strings.TrimSpace(workspace.OrganizationID) != "" {
-		return h.store.GetOrganizationRecord(workspace.OrganizationID)
+		return h.store.GetOrganizationRecord(ctx, workspace.OrganizationID)
 	}
 	if user == nil {
 		switch {
 		case session != nil && strings.TrimSpace(session.UserID) != "":
-			resolvedUser, err := h.store.GetUserByID(session.UserID)
+			resolvedUser, err := h.store.GetUserByID(ctx, session.UserID)
 			if err != nil {
 				return nil, err
 			}
 			user = resolvedUser
 		case strings.TrimSpace(workspace.OwnerUserID) != "":
-			resolvedUser, err := h.store.GetUserByID(workspace.OwnerUserID)
+			resolvedUser, err := h.store.GetUserByID(ctx, workspace.OwnerUserID)
 			if err != nil {
 				return nil, err
 			}
@@ -451,7 +446,7 @@ func (h *APIHandler) ensureTeamOrganizationForBilling(workspace *Workspace, user
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
-	if err := h.store.CreateOrganizationRecord(org); err != nil {
+	if err := h.store.CreateOrganizationRecord(ctx, org); err != nil {
 		return nil, err
 	}
 	member := &OrganizationMember{
@@ -464,43 +459,43 @@ func (h *APIHandler) ensureTeamOrganizationForBilling(workspace *Workspace, user
 		JoinedAt:       now,
 		CreatedAt:      now,
 	}
-	if err := h.store.CreateOrganizationMemberRecord(member); err != nil {
+	if err := h.store.CreateOrganizationMemberRecord(ctx, member); err != nil {
 		return nil, err
 	}
-	if err := h.ensureWorkspaceAttachedToOrganization(workspace, org); err != nil {
+	if err := h.ensureWorkspaceAttachedToOrganization(ctx, workspace, org); err != nil {
 		return nil, err
 	}
 	workspace.OrganizationID = org.ID
 	return org, nil
 }
 
-func (h *APIHandler) resolveSubscriptionRecordForStripe(metadata map[string]string, providerSubscriptionID string, checkoutSessionID string) (*Subscription, *Workspace, *User, error) {
+func (h *APIHandler) resolveSubscriptionRecordForStripe(ctx context.Context, metadata map[string]string, providerSubscriptionID string, checkoutSessionID string) (*Subscription, *Workspace, *User, error) {
 	if strings.TrimSpace(providerSubscriptionID) != "" {
-		if subscription, err := h.store.GetSubscriptionByProviderSubscriptionID(providerSubscriptionID); err == nil {
-			workspace, err := h.store.GetWorkspaceRecord(subscription.WorkspaceID)
+		if subscription, err := h.store.GetSubscriptionByProviderSubscriptionID(ctx, providerSubscriptionID); err == nil {
+			workspace, err := h.store.GetWorkspaceRecord(ctx, subscription.WorkspaceID)
 			if err != nil {
 				return nil, nil, nil, err
 			}
 			var user *User
 			if userID := strings.TrimSpace(metadata["user_id"]); userID != "" {
-				user, _ = h.store.GetUserByID(userID)
+				user, _ = h.store.GetUserByID(ctx, userID)
 			} else if strings.TrimSpace(workspace.OwnerUserID) != "" {
-				user, _ = h.store.GetUserByID(workspace.OwnerUserID)
+				user, _ = h.store.GetUserByID(ctx, workspace.OwnerUserID)
 			}
 			return subscription, workspace, user, nil
 		}
 	}
 	if strings.TrimSpace(checkoutSessionID) != "" {
-		if subscription, err := h.store.GetSubscriptionByProviderCheckoutSessionID(checkoutSessionID); err == nil {
-			workspace, err := h.store.GetWorkspaceRecord(subscription.WorkspaceID)
+		if subscription, err := h.store.GetSubscriptionByProviderCheckoutSessionID(ctx, checkoutSessionID); err == nil {
+			workspace, err := h.store.GetWorkspaceRecord(ctx, subscription.WorkspaceID)
 			if err != nil {
 				return nil, nil, nil, err
 			}
 			var user *User
 			if userID := strings.TrimSpace(metadata["user_id"]); userID != "" {
-				user, _ = h.store.GetUserByID(userID)
+				user, _ = h.store.GetUserByID(ctx, userID)
 			} else if strings.TrimSpace(workspace.OwnerUserID) != "" {
-				user, _ = h.store.GetUserByID(workspace.OwnerUserID)
+				user, _ = h.store.GetUserByID(ctx, workspace.OwnerUserID)
 			}
 			return subscription, workspace, user, nil
 		}
@@ -510,12 +505,12 @@ func (h *APIHandler) resolveSubscriptionRecordForStripe(metadata map[string]stri
 	if workspaceID == "" {
 		return nil, nil, nil, sql.ErrNoRows
 	}
-	workspace, err := h.store.GetWorkspaceRecord(workspaceID)
+	workspace, err := h.store.GetWorkspaceRecord(ctx, workspaceID)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 	var subscription *Subscription
-	if existing, err := h.store.GetSubscriptionForWorkspace(workspace.ID); err == nil {
+	if existing, err := h.store.GetSubscriptionForWorkspace(ctx, workspace.ID); err == nil {
 		subscription = existing
 	} else {
 		subscription = &Subscription{
@@ -527,9 +522,9 @@ func (h *APIHandler) resolveSubscriptionRecordForStripe(metadata map[string]stri
 	userID := strings.TrimSpace(metadata["user_id"])
 	var user *User
 	if userID != "" {
-		user, _ = h.store.GetUserByID(userID)
+		user, _ = h.store.GetUserByID(ctx, userID)
 	} else if strings.TrimSpace(workspace.OwnerUserID) != "" {
-		user, _ = h.store.GetUserByID(workspace.OwnerUserID)
+		user, _ = h.store.GetUserByID(ctx, workspace.OwnerUserID)
 	}
 	return subscription, workspace, user, nil
 }
@@ -540,7 +535,7 @@ func (h *APIHandler) applyStripeSubscriptionState(ctx context.Context, subscript
 	}
 
 	if state.Plan == PlanTeam {
-		org, err := h.ensureTeamOrganizationForBilling(workspace, user, nil)
+		org, err := h.ensureTeamOrganizationForBilling(ctx, workspace, user, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -560,18 +555,18 @@ func (h *APIHandler) applyStripeSubscriptionState(ctx context.Context, subscript
 	if subscription.CreatedAt.IsZero() {
 		subscription.CreatedAt = subscription.UpdatedAt
 	}
-	if err := h.store.UpsertSubscription(subscription); err != nil {
+	if err := h.store.UpsertSubscription(ctx, subscription); err != nil {
 		return nil, err
 	}
 	if user != nil && user.Onboarding && (state.Plan == PlanPro || state.Plan == PlanTeam) {
-		if err := h.store.UpdateUserOnboarding(user.ID, false); err != nil {
+		if err := h.store.UpdateUserOnboarding(ctx, user.ID, false); err != nil {
 			return nil, err
 		}
 	}
 	return subscription, nil
 }
 
-func (h *APIHandler) subscriptionBelongsToWorkspaceSession(subscription *Subscription, session *SessionRecord) bool {
+func (h *APIHandler) subscriptionBelongsToWorkspaceSession(ctx context.Context, subscription *Subscription, session *SessionRecord) bool {
 	if subscription == nil || session == nil {
 		return false
 	}
@@ -581,7 +576,7 @@ func (h *APIHandler) subscriptionBelongsToWorkspaceSession(subscription *Subscri
 	if strings.TrimSpace(subscription.OrganizationID) == "" {
 		return false
 	}
-	workspace, err := h.workspaceForSession(session)
+	workspace, err := h.workspaceForSession(ctx, session)
 	if err != nil || workspace == nil {
 		return false
 	}
@@ -592,11 +587,11 @@ func (h *APIHandler) reconcileOrganizationSeatBilling(ctx context.Context, organ
 	if strings.TrimSpace(organizationID) == "" {
 		return nil
 	}
-	subscription := h.organizationSubscription(organizationID, workspaceID)
+	subscription := h.organizationSubscription(ctx, organizationID, workspaceID)
 	if subscription == nil || strings.TrimSpace(subscription.Provider) != subscriptionProviderStripe || subscription.Plan != PlanTeam || strings.TrimSpace(subscription.ProviderSubscriptionID) == "" {
 		return nil
 	}
-	activeMembers, err := h.store.CountActiveOrganizationMembers(organizationID)
+	activeMembers, err := h.store.CountActiveOrganizationMembers(ctx, organizationID)
 	if err != nil {
 		return err
 	}
@@ -614,5 +609,5 @@ func (h *APIHandler) reconcileOrganizationSeatBilling(ctx context.Context, organ
 	}
 	applySubscriptionState(subscription, state)
 	subscription.UpdatedAt = time.Now()
-	return h.store.UpsertSubscription(subscription)
+	return h.store.UpsertSubscription(ctx, subscription)
 }
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SuperMemo exports collections either as Q&A text, where each item is a "Q:" line and
+// an "A:" line, or as XML. The XML nests items under topics, whose titles become the
+// deck path, and keeps each item's learning data: last repetition, interval,
+// repetitions, lapses and A-Factor. That summary is mapped onto FSRS state; SuperMemo
+// does not export individual repetitions.
+
+const (
+	// SuperMemo A-Factors run from 1.2 for the hardest items to 6.9 for the easiest.
+	superMemoHardestAFactor = 1.2
+	superMemoEasiestAFactor = 6.9
+)
+
+func parseSuperMemoImport(data []byte, format string, opts importParseOptions) ([]importNormalizedNote, error) {
+	if format == "xml" {
+		return parseSuperMemoXML(data, opts)
+	}
+	return parseSuperMemoQA(data, opts)
+}
+
+// looksLikeSuperMemoQA reports whether text starts like a SuperMemo Q&A export.
+func looksLikeSuperMemoQA(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		return strings.HasPrefix(line, "Q:") && !strings.Contains(line, "\t")
+	}
+	return false
+}
+
+func parseSuperMemoQA(data []byte, opts importParseOptions) ([]importNormalizedNote, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	deckName := firstNonEmpty(opts.DefaultDeckName, "Default")
+
+	var out []importNormalizedNote
+	var question, answer []string
+	part := -1 // 0 while reading a question, 1 while reading its answer
+	flush := func() {
+		front := strings.TrimSpace(strings.Join(question, "<br>"))
+		back := strings.TrimSpace(strings.Join(answer, "<br>"))
+		if part == 1 && front != "" && back != "" {
+			out = append(out, importNormalizedNote{
+				DeckName: deckName,
+				NoteType: "Basic",
+				Fields:   map[string]string{"Front": front, "Back": back},
+			})
+		}
+		question, answer, part = nil, nil, -1
+	}
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "Q:"):
+			flush()
+			question, part = []string{strings.TrimSpace(trimmed[2:])}, 0
+		case strings.HasPrefix(trimmed, "A:") && part >= 0:
+			answer, part = append(answer, strings.TrimSpace(trimmed[2:])), 1
+		case trimmed == "":
+			// Blank lines separate items but may also sit inside a long answer.
+		case part == 0:
+			question = append(question, trimmed)
+		case part == 1:
+			answer = append(answer, trimmed)
+		}
+	}
+	flush()
+	if len(out) == 0 {
+		return nil, errors.New("no Q: and A: pairs found in SuperMemo text")
+	}
+	return out, nil
+}
+
+type superMemoCollection struct {
+	XMLName  xml.Name           `xml:"SuperMemoCollection"`
+	Elements []superMemoElement `xml:"SuperMemoElement"`
+}
+
+type superMemoElement struct {
+	Title        string                 `xml:"Title"`
+	Question     string                 `xml:"Content>Question"`
+	Answer       string                 `xml:"Content>Answer"`
+	LearningData *superMemoLearningData `xml:"LearningData"`
+	Elements     []superMemoElement     `xml:"SuperMemoElement"`
+}
+
+type superMemoLearningData struct {
+	Interval       float64 `xml:"Interval"`
+	Repetitions    int     `xml:"Repetitions"`
+	Lapses         int     `xml:"Lapses"`
+	LastRepetition string  `xml:"LastRepetition"`
+	AFactor        string  `xml:"AFactor"`
+}
+
+// scheduling maps an item's learning data onto an import summary.
+func (d *superMemoLearningData) scheduling() (importScheduling, bool) {
+	if d == nil || d.Repetitions <= 0 {
+		return importScheduling{}, false
+	}
+	lastReview, ok := parseSuperMemoDate(d.LastRepetition)
+	if !ok {
+		return importScheduling{}, false
+	}
+	aFactor, _ := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(d.AFactor), ",", "."), 64)
+	return importScheduling{Summary: &importSchedulingSummary{
+		LastReview:   lastReview,
+		IntervalDays: d.Interval,
+		Reps:         d.Repetitions,
+		Lapses:       d.Lapses,
+		Difficulty:   difficultyFromEase(aFactor, superMemoHardestAFactor, superMemoEasiestAFactor),
+	}}, true
+}
+
+func parseSuperMemoDate(raw string) (time.Time, bool) {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range []string{"02.01.2006", "2.1.2006", "02.01.06", "2006-01-02"} {
+		if parsed, err := time.Parse(layout, raw); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func parseSuperMemoXML(data []byte, opts importParseOptions) ([]importNormalizedNote, error) {
+	var collection superMemoCollection
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = xmlCharsetReader
+	if err := decoder.Decode(&collection); err != nil {
+		return nil, fmt.Errorf("invalid SuperMemo XML: %w", err)
+	}
+
+	var out []importNormalizedNote
+	var walk func(elements []superMemoElement, path []string)
+	walk = func(elements []superMemoElement, path []string) {
+		for _, element := range elements {
+			if strings.TrimSpace(element.Question) != "" && strings.TrimSpace(element.Answer) != "" {
+				note := importNormalizedNote{
+					DeckName: firstNonEmpty(strings.Join(path, "::"), opts.DefaultDeckName, "Default"),
+					NoteType: "Basic",
+					Fields:   map[string]string{"Front": strings.TrimSpace(element.Question), "Back": strings.TrimSpace(element.Answer)},
+				}
+				if scheduling, ok := element.LearningData.scheduling(); ok {
+					note.Scheduling = map[int]importScheduling{0: scheduling}
+				}
+				out = append(out, note)
+			}
+			childPath := path
+			if title := strings.TrimSpace(element.Title); title != "" && len(element.Elements) > 0 {
+				childPath = append(append([]string{}, path...), strings.ReplaceAll(title, "::", ":"))
+			}
+			walk(element.Elements, childPath)
+		}
+	}
+	walk(collection.Elements, nil)
+	if len(out) == 0 {
+		return nil, errors.New("no items with a question and answer found in SuperMemo XML")
+	}
+	return out, nil
+}
+
+// xmlCharsetReader decodes the single-byte encodings older SRS tools declare in their
+// XML exports. Bytes are read as Latin-1, which matches Windows-1252 outside 0x80-0x9F.
+func xmlCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252", "cp1252":
+	default:
+		return nil, fmt.Errorf("unsupported XML encoding %q", charset)
+	}
+	raw, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	runes := make([]rune, len(raw))
+	for i, b := range raw {
+		runes[i] = rune(b)
+	}
+	return strings.NewReader(string(runes)), nil
+}
+
+// detectXMLImportSource names the tool that wrote an XML export, or "" if unknown.
+func detectXMLImportSource(data []byte) string {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = xmlCharsetReader
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			switch start.Name.Local {
+			case "SuperMemoCollection":
+				return "supermemo"
+			case "mnemosyne":
+				return "mnemosyne"
+			}
+			return ""
+		}
+	}
+}
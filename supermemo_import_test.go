@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestParseSuperMemoXML(t *testing.T) {
+	superMemoXML := `<?xml version="1.0" encoding="windows-1252"?>
+<SuperMemoCollection><Count>3</Count>
+<SuperMemoElement><ID>1</ID><Title>Geography</Title><Type>Topic</Type>
+  <SuperMemoElement><ID>2</ID><Title>Rivers</Title><Type>Topic</Type>
+    <SuperMemoElement><ID>3</ID><Type>Item</Type>
+      <Content><Question>Longest river?</Question><Answer>Nil ` + "\xe9" + `</Answer></Content>
+      <LearningData><Interval>12</Interval><Repetitions>4</Repetitions><Lapses>0</Lapses><LastRepetition>20.05.2009</LastRepetition><AFactor>3,920</AFactor><UFactor>2.8</UFactor></LearningData>
+    </SuperMemoElement>
+  </SuperMemoElement>
+</SuperMemoElement>
+</SuperMemoCollection>`
+	parsed, err := parseImportData([]byte(superMemoXML), importParseOptions{Filename: "collection.xml", DefaultDeckName: "Default"})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if parsed.Source != "supermemo" || len(parsed.Notes) != 1 {
+		t.Fatalf("unexpected SuperMemo XML result %+v", parsed)
+	}
+	note := parsed.Notes[0]
+	if note.DeckName != "Geography::Rivers" || note.Fields["Back"] != "Nil é" {
+		t.Fatalf("unexpected SuperMemo note %+v", note)
+	}
+	summary := note.Scheduling[0].Summary
+	if summary == nil || summary.IntervalDays != 12 || !summary.LastReview.Equal(time.Date(2009, 5, 20, 0, 0, 0, 0, time.UTC)) || summary.Difficulty <= 1 || summary.Difficulty >= 10 {
+		t.Fatalf("unexpected SuperMemo summary %+v", summary)
+	}
+}
+
+func TestFSRSStateForImport(t *testing.T) {
+	params := NewCollection().Params
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	card, entries := fsrsStateForImport(params, importScheduling{Reviews: []importReview{
+		{At: start.AddDate(0, 0, 1), Rating: fsrs.Good},
+		{At: start, Rating: fsrs.Again},
+	}}, start.AddDate(0, 1, 0))
+	if len(entries) != 2 || entries[0].Rating != int(fsrs.Again) || card.Reps != 2 || !card.LastReview.Equal(start.AddDate(0, 0, 1)) {
+		t.Fatalf("expected history replayed in order, got card %+v entries %+v", card, entries)
+	}
+
+	card, entries = fsrsStateForImport(params, importScheduling{Summary: &importSchedulingSummary{
+		LastReview: start, IntervalDays: 20, Reps: 5, Lapses: 1,
+	}}, start.AddDate(0, 0, 5))
+	if entries != nil || card.State != fsrs.Review || card.Stability != 20 || !card.Due.Equal(start.AddDate(0, 0, 20)) || card.ElapsedDays != 5 {
+		t.Fatalf("unexpected summary state %+v", card)
+	}
+}
+
+func TestAPI_ImportNotes_SuperMemoQAKeepsHistory(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	resp := doMultipartImportRequest(t, env.router, nil, "supermemo.txt", []byte("Q: Capital of Japan?\nA: Tokyo\n\nQ: Capital of Chile?\nA: Santiago\nde Chile\n"))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected import 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	result := decodeJSON[ImportNotesResponse](t, resp)
+	if result.Imported != 2 || result.Source != "supermemo" {
+		t.Fatalf("unexpected import result %+v", result)
+	}
+
+	resp = doMultipartImportRequest(t, env.router, map[string]string{"source": "mnemosyne"}, "default.db", buildMnemosyneDatabase(t))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected import 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if result := decodeJSON[ImportNotesResponse](t, resp); result.Imported != 3 || len(result.Errors) != 0 {
+		t.Fatalf("unexpected Mnemosyne import result %+v", result)
+	}
+
+	exported := decodeJSON[CollectionExport](t, doRawRequest(env.router, http.MethodGet, "/api/collection/export", ""))
+	if len(exported.Revlog) != 3 {
+		t.Fatalf("expected the three Mnemosyne repetitions in the review log, got %d", len(exported.Revlog))
+	}
+	reviewed := 0
+	for _, card := range exported.Cards {
+		if card.SRS.Reps > 0 {
+			reviewed++
+		}
+	}
+	if reviewed != 2 {
+		t.Fatalf("expected two cards to carry review progress, got %d", reviewed)
+	}
+}
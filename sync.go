@@ -0,0 +1,728 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sync lets a second device or client keep a local copy of a collection. It builds on the
+// change log: USNs there increase strictly, so a client's sync position is the last USN it
+// has pulled.
+//
+//  1. GET /api/sync/meta reports the collection's latest USN and when it last synced.
+//  2. GET /api/sync/changes?sinceUSN= returns the decks, notes, cards and review log
+//     entries written after that USN, plus deletions, a page at a time.
+//  3. POST /api/sync/apply sends local changes with the USN the client last pulled. Any
+//     entity the server changed after that USN is left alone and reported as a conflict;
+//     the client takes the server's version on its next pull. New decks and notes carry
+//     negative temporary IDs, which the response maps to server IDs.
+//
+// Applied changes are logged like any other write, so they come back on the next pull;
+// clients treat them as ordinary upserts.
+
+// maxSyncApplyEntities caps how many entities one POST /api/sync/apply may carry.
+const maxSyncApplyEntities = maxChangeFeedLimit
+
+type SyncMetaResponse struct {
+	CollectionID string     `json:"collectionId"`
+	USN          int64      `json:"usn"` // latest change-log USN; pass as sinceUSN to pull
+	LastSync     *time.Time `json:"lastSync,omitempty"`
+	ServerTime   time.Time  `json:"serverTime"`
+}
+
+// SyncDeck is a deck as exchanged by sync. Name is the full "Parent::Child" path.
+type SyncDeck struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	ParentID      *int64 `json:"parentId,omitempty"`
+	OptionsID     *int64 `json:"optionsId,omitempty"`
+	PriorityOrder int    `json:"priorityOrder"`
+}
+
+// SyncNote is a note sent to POST /api/sync/apply. DeckID is only read for new notes and
+// picks the deck their cards are generated in.
+type SyncNote struct {
+	Note
+	DeckID int64 `json:"deckId,omitempty"`
+}
+
+type SyncDeletions struct {
+	Decks  []int64 `json:"decks"`
+	Notes  []int64 `json:"notes"`
+	Cards  []int64 `json:"cards"`
+	Revlog []int64 `json:"revlog"` // pulled only; the review log is append-only for clients
+}
+
+type SyncChangesResponse struct {
+	Decks     []SyncDeck    `json:"decks"`
+	Notes     []Note        `json:"notes"`
+	Cards     []Card        `json:"cards"` // with the requesting user's review state
+	Revlog    []RevlogEntry `json:"revlog"`
+	Deleted   SyncDeletions `json:"deleted"`
+	LatestUSN int64         `json:"latestUsn"`
+	HasMore   bool          `json:"hasMore"`
+}
+
+type SyncApplyRequest struct {
+	SinceUSN int64         `json:"sinceUsn"` // last USN the client pulled
+	Decks    []SyncDeck    `json:"decks"`
+	Notes    []SyncNote    `json:"notes"`
+	Cards    []Card        `json:"cards"` // deck, review state, flag, marked and suspended are applied
+	Revlog   []RevlogEntry `json:"revlog"`
+	Deleted  SyncDeletions `json:"deleted"`
+}
+
+func (req *SyncApplyRequest) Validate(v *requestValidator) {
+	v.check(req.SinceUSN >= 0, "sinceUsn", "must not be negative")
+}
+
+// SyncConflict is a change that was not applied. Reason is changed_on_server, not_found
+// or invalid.
+type SyncConflict struct {
+	EntityType string `json:"entityType"`
+	EntityID   int64  `json:"entityId"`
+	Reason     string `json:"reason"`
+	Message    string `json:"message,omitempty"`
+}
+
+type SyncApplyCounts struct {
+	Decks   int `json:"decks"`
+	Notes   int `json:"notes"`
+	Cards   int `json:"cards"`
+	Revlog  int `json:"revlog"`
+	Deleted int `json:"deleted"`
+}
+
+type SyncApplyResponse struct {
+	USN          int64           `json:"usn"`
+	LastSync     time.Time       `json:"lastSync"`
+	Applied      SyncApplyCounts `json:"applied"`
+	DeckIDs      map[int64]int64 `json:"deckIds,omitempty"` // temporary ID -> server ID
+	NoteIDs      map[int64]int64 `json:"noteIds,omitempty"` // temporary ID -> server ID
+	CreatedCards []Card          `json:"createdCards,omitempty"`
+	Conflicts    []SyncConflict  `json:"conflicts,omitempty"`
+}
+
+func syncDeckFromDeck(deck *Deck) SyncDeck {
+	return SyncDeck{ID: deck.ID, Name: deck.Name, ParentID: deck.ParentID, OptionsID: deck.OptionsID, PriorityOrder: deck.PriorityOrder}
+}
+
+// LatestChangeUSN returns the highest change-log USN recorded for a collection.
+func (s *SQLStore) LatestChangeUSN(ctx context.Context, collectionID string) (int64, error) {
+	var usn int64
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(usn), 0) FROM change_log WHERE collection_id = ?`, collectionID).Scan(&usn)
+	return usn, err
+}
+
+// GetRevlogEntry returns one review log entry.
+func (s *SQLStore) GetRevlogEntry(ctx context.Context, id int64) (*RevlogEntry, error) {
+	var (
+		entry      RevlogEntry
+		due        int64
+		reviewedAt int64
+		stability  sql.NullFloat64
+		difficulty sql.NullFloat64
+		nextState  sql.NullInt64
+	)
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, COALESCE(user_id, ''), card_id, rating, COALESCE(state, 0), COALESCE(due, 0), COALESCE(reviewed_at, 0), COALESCE(time_taken_ms, 0),
+			stability, difficulty, elapsed_days, scheduled_days, kind, next_state
+		FROM revlog
+		WHERE id = ?
+	`, id).Scan(&entry.ID, &entry.UserID, &entry.CardID, &entry.Rating, &entry.State, &due, &reviewedAt, &entry.TimeTakenMs,
+		&stability, &difficulty, &entry.ElapsedDays, &entry.ScheduledDays, &entry.Kind, &nextState)
+	if err != nil {
+		return nil, err
+	}
+	if stability.Valid {
+		entry.Stability = &stability.Float64
+	}
+	if difficulty.Valid {
+		entry.Difficulty = &difficulty.Float64
+	}
+	if nextState.Valid {
+		state := int(nextState.Int64)
+		entry.NextState = &state
+	}
+	entry.Due = time.Unix(due, 0)
+	entry.ReviewedAt = time.Unix(reviewedAt, 0)
+	return &entry, nil
+}
+
+// HasRevlogEntry reports whether userID already has a review of cardID at reviewedAt,
+// which makes re-sent reviews from a retried sync harmless.
+func (s *SQLStore) HasRevlogEntry(ctx context.Context, userID string, cardID int64, reviewedAt time.Time) (bool, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM revlog WHERE card_id = ? AND reviewed_at = ? AND COALESCE(user_id, '') = ?
+	`, cardID, reviewedAt.Unix(), strings.TrimSpace(userID)).Scan(&count)
+	return count > 0, err
+}
+
+// GetSyncMeta handles GET /api/sync/meta.
+func (h *APIHandler) GetSyncMeta(w http.ResponseWriter, r *http.Request) {
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	usn, err := h.store.LatestChangeUSN(r.Context(), collectionID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "sync_meta_failed", err.Error())
+		return
+	}
+	resp := SyncMetaResponse{CollectionID: collectionID, USN: usn, ServerTime: time.Now().UTC()}
+	if !col.LastSync.IsZero() {
+		lastSync := col.LastSync.UTC()
+		resp.LastSync = &lastSync
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// GetSyncChanges handles GET /api/sync/changes?sinceUSN=&limit=. Each entity appears
+// once per page in its latest state; limit counts change-log events, not entities.
+func (h *APIHandler) GetSyncChanges(w http.ResponseWriter, r *http.Request) {
+	collectionID := h.collectionIDForRequest(r)
+	userID := h.userIDFromRequest(r)
+	sinceUSN, ok := parseSinceUSN(w, r)
+	if !ok {
+		return
+	}
+	limit := changeFeedLimit(r)
+	// Review state rows are created lazily on first read, and creating one logs a card
+	// change. Create them up front so reading cards below cannot log changes this page
+	// has already passed.
+	if err := h.store.EnsureReviewStatesForUser(r.Context(), userID); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "changes_load_failed", err.Error())
+		return
+	}
+
+	events, err := h.store.ListChanges(r.Context(), collectionID, userID, sinceUSN, limit+1)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "changes_load_failed", err.Error())
+		return
+	}
+	resp := SyncChangesResponse{
+		Decks:     []SyncDeck{},
+		Notes:     []Note{},
+		Cards:     []Card{},
+		Revlog:    []RevlogEntry{},
+		Deleted:   SyncDeletions{Decks: []int64{}, Notes: []int64{}, Cards: []int64{}, Revlog: []int64{}},
+		LatestUSN: sinceUSN,
+	}
+	if len(events) > limit {
+		events = events[:limit]
+		resp.HasMore = true
+	}
+	if len(events) > 0 {
+		resp.LatestUSN = events[len(events)-1].USN
+	}
+
+	// Only the last event per entity matters: it says whether the entity still exists.
+	type entityKey struct {
+		entityType string
+		id         int64
+	}
+	lastOp := map[entityKey]string{}
+	var order []entityKey
+	for _, event := range events {
+		id, err := strconv.ParseInt(event.EntityID, 10, 64)
+		if err != nil {
+			continue // note types and media are keyed by name and not part of sync
+		}
+		key := entityKey{event.EntityType, id}
+		if _, seen := lastOp[key]; !seen {
+			order = append(order, key)
+		}
+		lastOp[key] = event.Op
+	}
+
+	for _, key := range order {
+		deleted := lastOp[key] == changeOpDelete
+		// A row missing now was deleted after this page; the deletion arrives on a later page.
+		switch key.entityType {
+		case "deck":
+			if deleted {
+				resp.Deleted.Decks = append(resp.Deleted.Decks, key.id)
+			} else if deck, err := h.store.GetDeck(r.Context(), key.id); err == nil {
+				resp.Decks = append(resp.Decks, syncDeckFromDeck(deck))
+			}
+		case "note":
+			if deleted {
+				resp.Deleted.Notes = append(resp.Deleted.Notes, key.id)
+			} else if note, err := h.store.GetNote(r.Context(), key.id); err == nil {
+				resp.Notes = append(resp.Notes, *note)
+			}
+		case "card":
+			if deleted {
+				resp.Deleted.Cards = append(resp.Deleted.Cards, key.id)
+			} else if card, err := h.store.GetCardForUser(r.Context(), userID, key.id); err == nil {
+				resp.Cards = append(resp.Cards, *card)
+			}
+		case "revlog":
+			if deleted {
+				resp.Deleted.Revlog = append(resp.Deleted.Revlog, key.id)
+			} else if entry, err := h.store.GetRevlogEntry(r.Context(), key.id); err == nil {
+				resp.Revlog = append(resp.Revlog, *entry)
+			}
+		}
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// syncChangedEntities returns the decks, notes and cards written after sinceUSN, keyed
+// by entity type and ID.
+func (h *APIHandler) syncChangedEntities(ctx context.Context, collectionID, userID string, sinceUSN int64) (map[string]bool, error) {
+	changed := map[string]bool{}
+	for {
+		events, err := h.store.ListChanges(ctx, collectionID, userID, sinceUSN, maxChangeFeedLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			changed[event.EntityType+":"+event.EntityID] = true
+			sinceUSN = event.USN
+		}
+		if len(events) < maxChangeFeedLimit {
+			return changed, nil
+		}
+	}
+}
+
+// ApplySyncChanges handles POST /api/sync/apply.
+func (h *APIHandler) ApplySyncChanges(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	var req SyncApplyRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	total := len(req.Decks) + len(req.Notes) + len(req.Cards) + len(req.Revlog) +
+		len(req.Deleted.Decks) + len(req.Deleted.Notes) + len(req.Deleted.Cards)
+	if total > maxSyncApplyEntities {
+		respondAPIError(w, http.StatusRequestEntityTooLarge, "sync_batch_too_large",
+			fmt.Sprintf("Send at most %d changes per sync request", maxSyncApplyEntities))
+		return
+	}
+
+	userID := h.userIDFromRequest(r)
+	if err := h.store.EnsureReviewStatesForUser(r.Context(), userID); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "sync_apply_failed", err.Error())
+		return
+	}
+	changed, err := h.syncChangedEntities(r.Context(), collectionID, userID, req.SinceUSN)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "changes_load_failed", err.Error())
+		return
+	}
+	applier := &syncApplier{
+		ctx:          r.Context(),
+		h:            h,
+		col:          col,
+		collectionID: collectionID,
+		userID:       userID,
+		changed:      changed,
+		session:      h.sessionFromRequest(r),
+		resp:         SyncApplyResponse{DeckIDs: map[int64]int64{}, NoteIDs: map[int64]int64{}},
+	}
+	applier.plan = h.planForRequest(r, applier.session)
+	applier.usage = h.usageForSession(r.Context(), applier.session)
+	if err := applier.apply(req); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "sync_apply_failed", err.Error())
+		return
+	}
+	// Cards created above get their review state now, so the USN returned covers it.
+	if err := h.store.EnsureReviewStatesForUser(r.Context(), userID); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "sync_apply_failed", err.Error())
+		return
+	}
+
+	col.LastSync = time.Now()
+	if err := h.store.UpdateCollectionByID(r.Context(), collectionID, col); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "sync_apply_failed", err.Error())
+		return
+	}
+	resp := applier.resp
+	resp.LastSync = col.LastSync.UTC()
+	if resp.USN, err = h.store.LatestChangeUSN(r.Context(), collectionID); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "sync_apply_failed", err.Error())
+		return
+	}
+	h.events.publish(RealtimeEvent{Type: eventSyncCompleted, CollectionID: collectionID, USN: resp.USN})
+	h.events.publish(RealtimeEvent{Type: eventDueCountsChanged, CollectionID: collectionID})
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// syncApplier applies one POST /api/sync/apply. Rejected changes become conflicts;
+// returned errors are storage failures.
+type syncApplier struct {
+	ctx          context.Context // the request's
+	h            *APIHandler
+	col          *Collection
+	collectionID string
+	userID       string
+	changed      map[string]bool
+	session      *SessionRecord
+	plan         Plan
+	usage        EntitlementUsage
+	resp         SyncApplyResponse
+}
+
+func (a *syncApplier) conflict(entityType string, id int64, reason, message string) {
+	a.resp.Conflicts = append(a.resp.Conflicts, SyncConflict{EntityType: entityType, EntityID: id, Reason: reason, Message: message})
+}
+
+// claim reports whether a client change to an existing entity may be applied.
+func (a *syncApplier) claim(entityType string, id int64, exists bool) bool {
+	if !exists {
+		a.conflict(entityType, id, "not_found", "")
+		return false
+	}
+	if a.changed[entityType+":"+strconv.FormatInt(id, 10)] {
+		a.conflict(entityType, id, "changed_on_server", "")
+		return false
+	}
+	return true
+}
+
+// deckID maps a temporary deck ID from this request onto its server ID.
+func (a *syncApplier) deckID(id int64) int64 {
+	if mapped, ok := a.resp.DeckIDs[id]; ok {
+		return mapped
+	}
+	return id
+}
+
+func (a *syncApplier) apply(req SyncApplyRequest) error {
+	steps := []func(SyncApplyRequest) error{a.applyDecks, a.applyNotes, a.applyCards, a.applyRevlog, a.applyDeletions}
+	for _, step := range steps {
+		if err := step(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *syncApplier) applyDecks(req SyncApplyRequest) error {
+	for _, incoming := range req.Decks {
+		if incoming.ID <= 0 {
+			if err := a.createDeck(incoming); err != nil {
+				return err
+			}
+			continue
+		}
+		deck, ok := a.col.Decks[incoming.ID]
+		if !a.claim("deck", incoming.ID, ok) {
+			continue
+		}
+		if name := strings.TrimSpace(incoming.Name); name != "" {
+			deck.Name = sanitizeHTML(name)
+		}
+		if incoming.PriorityOrder > 0 {
+			deck.PriorityOrder = incoming.PriorityOrder
+		}
+		deck.ParentID = nil
+		if incoming.ParentID != nil {
+			parentID := a.deckID(*incoming.ParentID)
+			if code, message := a.h.validateDeckParent(a.ctx, deck, parentID); code != "" {
+				a.conflict("deck", incoming.ID, "invalid", message)
+				continue
+			}
+			deck.ParentID = &parentID
+		}
+		deck.OptionsID = nil
+		if incoming.OptionsID != nil {
+			if _, err := a.h.store.GetDeckOptions(a.ctx, *incoming.OptionsID); err != nil {
+				a.conflict("deck", incoming.ID, "invalid", "Deck options preset not found")
+				continue
+			}
+			optionsID := *incoming.OptionsID
+			deck.OptionsID = &optionsID
+		}
+		if err := a.h.store.UpdateDeck(a.ctx, deck); err != nil {
+			return fmt.Errorf("deck %d: %w", incoming.ID, err)
+		}
+		a.resp.Applied.Decks++
+	}
+	return nil
+}
+
+func (a *syncApplier) createDeck(incoming SyncDeck) error {
+	path, err := splitDeckPath(sanitizeHTML(incoming.Name))
+	if err != nil {
+		a.conflict("deck", incoming.ID, "invalid", err.Error())
+		return nil
+	}
+	if existing := a.col.deckByName(strings.Join(path, deckPathSeparator)); existing != nil {
+		// Another device created the same deck first.
+		a.resp.DeckIDs[incoming.ID] = existing.ID
+		return nil
+	}
+	usage := a.usage
+	usage.Decks += len(a.col.missingDeckAncestors(path))
+	if err := validateDeckLimit(a.plan, usage); err != nil {
+		a.conflict("deck", incoming.ID, "invalid", err.Error())
+		return nil
+	}
+	deck, err := a.h.createDeckPath(a.ctx, a.col, a.collectionID, path)
+	if err != nil {
+		return fmt.Errorf("deck %q: %w", incoming.Name, err)
+	}
+	a.usage = usage
+	a.usage.Decks++
+	a.resp.DeckIDs[incoming.ID] = deck.ID
+	a.resp.Applied.Decks++
+	return nil
+}
+
+func (a *syncApplier) applyNotes(req SyncApplyRequest) error {
+	for _, incoming := range req.Notes {
+		if incoming.ID <= 0 {
+			if err := a.createNote(incoming); err != nil {
+				return err
+			}
+			continue
+		}
+		_, ok := a.col.Notes[incoming.ID]
+		if !a.claim("note", incoming.ID, ok) {
+			continue
+		}
+		note, err := a.h.store.GetNote(a.ctx, incoming.ID)
+		if err != nil {
+			return fmt.Errorf("note %d: %w", incoming.ID, err)
+		}
+		if incoming.Type != "" && incoming.Type != note.Type {
+			a.conflict("note", incoming.ID, "invalid", "Changing a note's type is not synced")
+			continue
+		}
+		note.FieldMap = sanitizeFieldVals(incoming.FieldMap)
+		note.Tags = sanitizeTags(incoming.Tags)
+		a.col.USN++
+		note.USN = a.col.USN
+		note.ModifiedAt = incoming.ModifiedAt
+		if note.ModifiedAt.IsZero() {
+			note.ModifiedAt = time.Now()
+		}
+
+		existingCards, err := a.h.store.GetCardsByNote(a.ctx, note.ID)
+		if err != nil {
+			return fmt.Errorf("note %d: %w", incoming.ID, err)
+		}
+		deckID, _ := a.h.primaryDeckDetails(existingCards, a.col)
+		previewCards, err := a.col.generateCardsFromNote(a.col.NoteTypes[note.Type], *note, deckID, note.ModifiedAt)
+		if err != nil {
+			a.conflict("note", incoming.ID, "invalid", err.Error())
+			continue
+		}
+		if err := validateCardsTotalLimit(a.plan, a.usage, len(previewCards)-len(existingCards)); err != nil {
+			a.conflict("note", incoming.ID, "invalid", err.Error())
+			continue
+		}
+		if err := a.h.store.UpdateNote(a.ctx, note); err != nil {
+			return fmt.Errorf("note %d: %w", incoming.ID, err)
+		}
+		if _, err := a.h.regenerateCardsForSingleNote(a.ctx, a.col, note, deckID, nil); err != nil {
+			return fmt.Errorf("note %d: %w", incoming.ID, err)
+		}
+		a.h.syncCollectionNote(a.col, note)
+		a.usage.CardsTotal += len(previewCards) - len(existingCards)
+		a.resp.Applied.Notes++
+	}
+	return nil
+}
+
+func (a *syncApplier) createNote(incoming SyncNote) error {
+	deckID := a.deckID(incoming.DeckID)
+	if _, ok := a.col.Decks[deckID]; !ok {
+		a.conflict("note", incoming.ID, "invalid", "deckId must name a deck")
+		return nil
+	}
+	noteType, ok := a.col.NoteTypes[incoming.Type]
+	if !ok {
+		a.conflict("note", incoming.ID, "invalid", "Note type not found")
+		return nil
+	}
+	if err := validateNoteLimit(a.plan, a.usage); err != nil {
+		a.conflict("note", incoming.ID, "invalid", err.Error())
+		return nil
+	}
+	fields := noteType.withFieldDefaults(sanitizeFieldVals(incoming.FieldMap))
+	createdAt := incoming.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	previewCards, err := a.col.generateCardsFromNote(noteType, Note{Type: incoming.Type, FieldMap: fields}, deckID, createdAt)
+	if err != nil {
+		a.conflict("note", incoming.ID, "invalid", err.Error())
+		return nil
+	}
+	if err := validateCardsTotalLimit(a.plan, a.usage, len(previewCards)); err != nil {
+		a.conflict("note", incoming.ID, "invalid", err.Error())
+		return nil
+	}
+
+	note, cards, err := a.col.AddNote(deckID, incoming.Type, fields, createdAt)
+	if err != nil {
+		a.conflict("note", incoming.ID, "invalid", err.Error())
+		return nil
+	}
+	note.Tags = sanitizeTags(incoming.Tags)
+	if err := a.h.store.CreateNoteWithCards(a.ctx, a.collectionID, &note, cards); err != nil {
+		a.col.removeAddedNote(note, cards)
+		return fmt.Errorf("note %d: %w", incoming.ID, err)
+	}
+	for _, card := range cards {
+		a.resp.CreatedCards = append(a.resp.CreatedCards, *card)
+	}
+	a.usage.Notes++
+	a.usage.CardsTotal += len(cards)
+	a.resp.NoteIDs[incoming.ID] = note.ID
+	a.resp.Applied.Notes++
+	return nil
+}
+
+func (a *syncApplier) applyCards(req SyncApplyRequest) error {
+	for _, incoming := range req.Cards {
+		_, ok := a.col.Cards[incoming.ID]
+		if !a.claim("card", incoming.ID, ok) {
+			continue
+		}
+		if incoming.Flag < 0 || incoming.Flag > 7 {
+			a.conflict("card", incoming.ID, "invalid", "Flag must be 0-7")
+			continue
+		}
+		card, err := a.h.store.GetCardForUser(a.ctx, a.userID, incoming.ID)
+		if err != nil {
+			return fmt.Errorf("card %d: %w", incoming.ID, err)
+		}
+		if deckID := a.deckID(incoming.DeckID); deckID != 0 && deckID != card.DeckID {
+			if _, ok := a.col.Decks[deckID]; !ok {
+				a.conflict("card", incoming.ID, "invalid", "deckId must name a deck")
+				continue
+			}
+			a.h.removeCardFromDeck(a.col, card.DeckID, card.ID)
+			card.DeckID = deckID
+			a.col.USN++
+			card.USN = a.col.USN
+			if err := a.h.store.UpdateCard(a.ctx, card); err != nil {
+				return fmt.Errorf("card %d: %w", incoming.ID, err)
+			}
+			a.h.ensureCardOnDeck(a.col, deckID, card.ID)
+		}
+		card.SRS = incoming.SRS
+		card.Flag = incoming.Flag
+		card.Marked = incoming.Marked
+		card.Suspended = incoming.Suspended
+		if err := a.h.store.UpdateCardReviewState(a.ctx, a.userID, card); err != nil {
+			return fmt.Errorf("card %d: %w", incoming.ID, err)
+		}
+		a.resp.Applied.Cards++
+	}
+	return nil
+}
+
+// applyRevlog appends reviews made on the client. Reviews never conflict: they happened
+// whatever the server did meanwhile. A review already on the server is skipped.
+func (a *syncApplier) applyRevlog(req SyncApplyRequest) error {
+	entries := make([]RevlogEntry, 0, len(req.Revlog))
+	for _, entry := range req.Revlog {
+		if _, ok := a.col.Cards[entry.CardID]; !ok {
+			a.conflict("revlog", entry.ID, "not_found", fmt.Sprintf("card %d not found", entry.CardID))
+			continue
+		}
+		if entry.Rating < 1 || entry.Rating > 4 || entry.ReviewedAt.IsZero() {
+			a.conflict("revlog", entry.ID, "invalid", "Reviews need a rating of 1-4 and reviewedAt")
+			continue
+		}
+		exists, err := a.h.store.HasRevlogEntry(a.ctx, a.userID, entry.CardID, entry.ReviewedAt)
+		if err != nil {
+			return fmt.Errorf("review %d: %w", entry.ID, err)
+		}
+		if !exists {
+			entries = append(entries, entry)
+		}
+	}
+	if err := a.h.store.ImportRevlogEntries(a.ctx, a.userID, entries); err != nil {
+		return fmt.Errorf("review log: %w", err)
+	}
+	a.resp.Applied.Revlog += len(entries)
+	return nil
+}
+
+func (a *syncApplier) applyDeletions(req SyncApplyRequest) error {
+	for _, id := range req.Deleted.Cards {
+		if _, ok := a.col.Cards[id]; !a.claim("card", id, ok) {
+			continue
+		}
+		if err := a.h.store.DeleteCard(a.ctx, id); err != nil {
+			return fmt.Errorf("card %d: %w", id, err)
+		}
+		a.h.removeCardFromDeck(a.col, a.col.Cards[id].DeckID, id)
+		delete(a.col.Cards, id)
+		a.resp.Applied.Deleted++
+	}
+
+	var noteIDs []int64
+	for _, id := range req.Deleted.Notes {
+		if _, ok := a.col.Notes[id]; a.claim("note", id, ok) {
+			noteIDs = append(noteIDs, id)
+		}
+	}
+	cards, err := a.h.store.DeleteNotesWithCards(a.ctx, noteIDs)
+	if err != nil {
+		return fmt.Errorf("notes: %w", err)
+	}
+	for _, card := range cards {
+		a.h.removeCardFromDeck(a.col, card.DeckID, card.ID)
+		delete(a.col.Cards, card.ID)
+	}
+	for _, id := range noteIDs {
+		delete(a.col.Notes, id)
+	}
+	a.resp.Applied.Deleted += len(noteIDs)
+
+	// Decks go last so deleting a deck's notes in the same sync empties it first.
+	for _, id := range req.Deleted.Decks {
+		if _, ok := a.col.Decks[id]; !a.claim("deck", id, ok) {
+			continue
+		}
+		if err := a.deleteDeck(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *syncApplier) deleteDeck(id int64) error {
+	for _, candidate := range a.col.Decks {
+		if candidate.ParentID != nil && *candidate.ParentID == id {
+			a.conflict("deck", id, "invalid", "This deck has child decks")
+			return nil
+		}
+	}
+	cards, err := a.h.store.ListCardsInDeck(a.ctx, id)
+	if err != nil {
+		return fmt.Errorf("deck %d: %w", id, err)
+	}
+	if len(cards) > 0 {
+		a.conflict("deck", id, "invalid", "This deck has cards")
+		return nil
+	}
+	if err := a.h.store.DeleteDeck(a.ctx, id); err != nil {
+		return fmt.Errorf("deck %d: %w", id, err)
+	}
+	delete(a.col.Decks, id)
+	a.resp.Applied.Deleted++
+	return nil
+}
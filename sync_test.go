@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func pullSyncChanges(t *testing.T, env *apiTestEnv, sinceUSN int64) SyncChangesResponse {
+	t.Helper()
+	rr := doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/sync/changes?sinceUSN=%d", sinceUSN), "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected sync changes 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	return decodeJSON[SyncChangesResponse](t, rr)
+}
+
+func applySyncChanges(t *testing.T, env *apiTestEnv, req SyncApplyRequest) SyncApplyResponse {
+	t.Helper()
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/sync/apply", req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected sync apply 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	return decodeJSON[SyncApplyResponse](t, rr)
+}
+
+func TestAPI_SyncChangesReturnEntities(t *testing.T) {
+	env := setupAPITestEnv(t)
+	meta := decodeJSON[SyncMetaResponse](t, doRawRequest(env.router, http.MethodGet, "/api/sync/meta", ""))
+	if meta.LastSync != nil {
+		t.Fatalf("expected a collection that never synced, got %+v", meta)
+	}
+
+	kept := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Trie", "Back": "Prefix tree"},
+	}, nil)
+	removed := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Removed", "Back": "Soon"},
+	}, nil)
+	cardID := kept.Cards[0].ID
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", cardID), AnswerCardRequest{Rating: 3}); rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := doRawRequest(env.router, http.MethodDelete, fmt.Sprintf("/api/notes/%d", removed.Note.ID), ""); rr.Code != http.StatusOK && rr.Code != http.StatusNoContent {
+		t.Fatalf("expected note delete to succeed, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	changes := pullSyncChanges(t, env, meta.USN)
+	if changes.HasMore || changes.LatestUSN <= meta.USN {
+		t.Fatalf("expected one complete page after USN %d, got %+v", meta.USN, changes)
+	}
+	if len(changes.Notes) != 1 || changes.Notes[0].ID != kept.Note.ID {
+		t.Fatalf("expected only the kept note, got %+v", changes.Notes)
+	}
+	if len(changes.Cards) != 1 || changes.Cards[0].ID != cardID || changes.Cards[0].SRS.Reps != 1 {
+		t.Fatalf("expected the answered card with its review state, got %+v", changes.Cards)
+	}
+	if len(changes.Revlog) != 1 || changes.Revlog[0].CardID != cardID {
+		t.Fatalf("expected one review, got %+v", changes.Revlog)
+	}
+	if !slices.Contains(changes.Deleted.Notes, removed.Note.ID) || !slices.Contains(changes.Deleted.Cards, removed.Cards[0].ID) {
+		t.Fatalf("expected the removed note and card as deletions, got %+v", changes.Deleted)
+	}
+
+	again := pullSyncChanges(t, env, changes.LatestUSN)
+	if len(again.Notes)+len(again.Cards)+len(again.Revlog)+len(again.Deleted.Notes) != 0 || again.LatestUSN != changes.LatestUSN {
+		t.Fatalf("expected nothing new after latestUsn, got %+v", again)
+	}
+	page := decodeJSON[SyncChangesResponse](t, doRawRequest(env.router, http.MethodGet, fmt.Sprintf("/api/sync/changes?sinceUSN=%d&limit=1", meta.USN), ""))
+	if !page.HasMore || page.LatestUSN <= meta.USN || page.LatestUSN >= changes.LatestUSN {
+		t.Fatalf("expected a partial first page, got %+v", page)
+	}
+}
+
+func TestAPI_SyncApplyMergesClientChanges(t *testing.T) {
+	env := setupAPITestEnv(t)
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Heap", "Back": "Tree"},
+	}, nil)
+	cursor := pullSyncChanges(t, env, 0).LatestUSN
+
+	reviewedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	card := created.Cards[0]
+	card.Flag = 2
+	card.SRS.Reps = 1
+	card.SRS.Due = reviewedAt.Add(24 * time.Hour)
+	req := SyncApplyRequest{
+		SinceUSN: cursor,
+		Decks:    []SyncDeck{{ID: -1, Name: "Offline"}},
+		Notes: []SyncNote{
+			{Note: Note{ID: created.Note.ID, Type: "Basic", FieldMap: map[string]string{"Front": "Heap", "Back": "Complete binary tree"}, Tags: []string{"offline"}}},
+			{Note: Note{ID: -1, Type: "Basic", FieldMap: map[string]string{"Front": "Graph", "Back": "Vertices and edges"}}, DeckID: -1},
+		},
+		Cards:  []Card{card},
+		Revlog: []RevlogEntry{{ID: -1, CardID: card.ID, Rating: 3, ReviewedAt: reviewedAt, Due: card.SRS.Due, TimeTakenMs: 1500}},
+	}
+	result := applySyncChanges(t, env, req)
+	if len(result.Conflicts) != 0 {
+		t.Fatalf("expected no conflicts, got %+v", result.Conflicts)
+	}
+	if result.Applied != (SyncApplyCounts{Decks: 1, Notes: 2, Cards: 1, Revlog: 1}) {
+		t.Fatalf("unexpected applied counts %+v", result.Applied)
+	}
+	deckID, noteID := result.DeckIDs[-1], result.NoteIDs[-1]
+	if deckID <= 1 || noteID <= 0 || len(result.CreatedCards) != 1 || result.CreatedCards[0].DeckID != deckID {
+		t.Fatalf("expected temporary IDs mapped and cards created in the new deck, got %+v", result)
+	}
+	if result.USN <= cursor || result.LastSync.IsZero() {
+		t.Fatalf("expected the USN to advance and lastSync to be set, got %+v", result)
+	}
+
+	note, err := env.store.GetNote(context.Background(), created.Note.ID)
+	if err != nil {
+		t.Fatalf("load note failed: %v", err)
+	}
+	if note.FieldMap["Back"] != "Complete binary tree" || !containsExact(note.Tags, "offline") || note.USN == created.Note.USN {
+		t.Fatalf("expected the edited note with a new USN, got %+v", note)
+	}
+	var synced *Card
+	for _, pulled := range pullSyncChanges(t, env, cursor).Cards {
+		if pulled.ID == card.ID {
+			synced = &pulled
+		}
+	}
+	if synced == nil || synced.Flag != 2 || synced.SRS.Reps != 1 {
+		t.Fatalf("expected the card's flag and review state to sync, got %+v", synced)
+	}
+	meta := decodeJSON[SyncMetaResponse](t, doRawRequest(env.router, http.MethodGet, "/api/sync/meta", ""))
+	if meta.LastSync == nil || meta.USN != result.USN {
+		t.Fatalf("expected meta to reflect the sync, got %+v", meta)
+	}
+
+	// Re-sending the same review after a retry does not duplicate it.
+	retry := applySyncChanges(t, env, SyncApplyRequest{SinceUSN: result.USN, Revlog: req.Revlog})
+	if retry.Applied.Revlog != 0 {
+		t.Fatalf("expected the repeated review to be skipped, got %+v", retry.Applied)
+	}
+}
+
+func TestAPI_SyncApplyReportsConflicts(t *testing.T) {
+	env := setupAPITestEnv(t)
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Stack", "Back": "LIFO"},
+	}, nil)
+	cursor := pullSyncChanges(t, env, 0).LatestUSN
+
+	rr := doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/notes/%d", created.Note.ID), UpdateNoteRequest{
+		FieldVals: map[string]string{"Front": "Stack", "Back": "Last in, first out"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected note update 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	result := applySyncChanges(t, env, SyncApplyRequest{
+		SinceUSN: cursor,
+		Notes:    []SyncNote{{Note: Note{ID: created.Note.ID, Type: "Basic", FieldMap: map[string]string{"Front": "Stack", "Back": "Offline edit"}}}},
+		Deleted:  SyncDeletions{Notes: []int64{987654}},
+	})
+	if result.Applied != (SyncApplyCounts{}) || len(result.Conflicts) != 2 {
+		t.Fatalf("expected two conflicts and nothing applied, got %+v", result)
+	}
+	if got := result.Conflicts[0]; got.EntityType != "note" || got.EntityID != created.Note.ID || got.Reason != "changed_on_server" {
+		t.Fatalf("unexpected conflict %+v", got)
+	}
+	if got := result.Conflicts[1]; got.EntityID != 987654 || got.Reason != "not_found" {
+		t.Fatalf("unexpected conflict %+v", got)
+	}
+	note, err := env.store.GetNote(context.Background(), created.Note.ID)
+	if err != nil {
+		t.Fatalf("load note failed: %v", err)
+	}
+	if note.FieldMap["Back"] != "Last in, first out" {
+		t.Fatalf("expected the server edit to win, got %+v", note.FieldMap)
+	}
+
+	if bad := doRawRequest(env.router, http.MethodGet, "/api/sync/changes?sinceUSN=-1", ""); bad.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a negative sinceUSN, got %d", bad.Code)
+	}
+}
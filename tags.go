@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// tagPathSeparator nests tags the same way decks are nested: "anatomy::upper-limb".
+const tagPathSeparator = "::"
+
+// TagNode is one level of the tag hierarchy. NoteCount counts notes carrying this tag
+// or any tag beneath it.
+type TagNode struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	NoteCount int       `json:"noteCount"`
+	Children  []TagNode `json:"children"`
+}
+
+type RenameTagRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (req *RenameTagRequest) Validate(v *requestValidator) {
+	v.required("from", normalizeTagPath(req.From))
+	v.required("to", normalizeTagPath(sanitizeHTML(req.To)))
+}
+
+type RenameTagResponse struct {
+	From         string `json:"from"`
+	To           string `json:"to"`
+	UpdatedNotes int    `json:"updatedNotes"`
+}
+
+// normalizeTagPath trims each level of a hierarchical tag and drops empty levels, so
+// " anatomy :: upper-limb " and "anatomy::::upper-limb" both become "anatomy::upper-limb".
+func normalizeTagPath(tag string) string {
+	parts := strings.Split(tag, tagPathSeparator)
+	kept := parts[:0]
+	for _, part := range parts {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			kept = append(kept, trimmed)
+		}
+	}
+	return strings.Join(kept, tagPathSeparator)
+}
+
+// renameTagPath maps tag onto the renamed subtree when it is from or sits beneath it.
+// Levels compare case-insensitively, as in Anki.
+func renameTagPath(tag, from, to string) (string, bool) {
+	levels := strings.Split(normalizeTagPath(tag), tagPathSeparator)
+	fromLevels := strings.Split(from, tagPathSeparator)
+	if len(levels) < len(fromLevels) {
+		return tag, false
+	}
+	for i, level := range fromLevels {
+		if !strings.EqualFold(levels[i], level) {
+			return tag, false
+		}
+	}
+	return strings.Join(append([]string{to}, levels[len(fromLevels):]...), tagPathSeparator), true
+}
+
+// dedupeTagsFold removes repeated tags ignoring case, keeping the first spelling.
+func dedupeTagsFold(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		key := strings.ToLower(tag)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, tag)
+	}
+	return out
+}
+
+// ListTagTree returns every tag used in the collection, nested by "::" levels. Parent
+// levels that are never used on their own still appear so the tree is connected.
+func (s *SQLStore) ListTagTree(ctx context.Context, collectionID string) ([]TagNode, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT n.id, t.value
+		FROM notes n, `+s.dialect.jsonArrayValues("n.tags", "t")+`
+		WHERE n.collection_id = ?
+	`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type tagEntry struct {
+		name     string
+		path     string
+		notes    map[int64]bool
+		children map[string]bool
+	}
+	entries := map[string]*tagEntry{}
+	roots := map[string]bool{}
+	for rows.Next() {
+		var (
+			noteID int64
+			tag    string
+		)
+		if err := rows.Scan(&noteID, &tag); err != nil {
+			return nil, err
+		}
+		levels := strings.Split(normalizeTagPath(tag), tagPathSeparator)
+		if levels[0] == "" {
+			continue
+		}
+		parentKey := ""
+		for depth := range levels {
+			path := strings.Join(levels[:depth+1], tagPathSeparator)
+			key := strings.ToLower(path)
+			entry, ok := entries[key]
+			if !ok {
+				entry = &tagEntry{name: levels[depth], path: path, notes: map[int64]bool{}, children: map[string]bool{}}
+				entries[key] = entry
+			}
+			entry.notes[noteID] = true
+			if parentKey == "" {
+				roots[key] = true
+			} else {
+				entries[parentKey].children[key] = true
+			}
+			parentKey = key
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var build func(keys map[string]bool) []TagNode
+	build = func(keys map[string]bool) []TagNode {
+		nodes := make([]TagNode, 0, len(keys))
+		for key := range keys {
+			entry := entries[key]
+			nodes = append(nodes, TagNode{
+				Name:      entry.name,
+				Path:      entry.path,
+				NoteCount: len(entry.notes),
+				Children:  build(entry.children),
+			})
+		}
+		sort.Slice(nodes, func(i, j int) bool {
+			return strings.ToLower(nodes[i].Name) < strings.ToLower(nodes[j].Name)
+		})
+		return nodes
+	}
+	return build(roots), nil
+}
+
+// RenameTag renames from and every tag beneath it in one transaction, returning the
+// notes whose tags changed with their new tag lists.
+func (s *SQLStore) RenameTag(ctx context.Context, collectionID, from, to string, now time.Time) (map[int64][]string, error) {
+	var updated map[int64][]string
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+			SELECT n.id, n.tags FROM notes n
+			WHERE n.collection_id = ?
+			  AND EXISTS (
+				SELECT 1 FROM `+s.dialect.jsonArrayValues("n.tags", "t")+`
+				WHERE t.value `+s.dialect.like()+` ? ESCAPE '\' OR t.value `+s.dialect.like()+` ? ESCAPE '\'
+			  )
+		`, collectionID, strings.TrimSuffix(strings.TrimPrefix(likeContains(from), "%"), "%"), strings.TrimPrefix(likeContains(from+tagPathSeparator), "%"))
+		if err != nil {
+			return err
+		}
+		updated = map[int64][]string{}
+		for rows.Next() {
+			var (
+				noteID   int64
+				tagsJSON []byte
+				tags     []string
+			)
+			if err := rows.Scan(&noteID, &tagsJSON); err != nil {
+				rows.Close()
+				return err
+			}
+			if err := json.Unmarshal(tagsJSON, &tags); err != nil {
+				rows.Close()
+				return err
+			}
+			changed := false
+			for i, tag := range tags {
+				if renamed, ok := renameTagPath(tag, from, to); ok {
+					tags[i] = renamed
+					changed = true
+				}
+			}
+			if changed {
+				updated[noteID] = dedupeTagsFold(tags)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for noteID, tags := range updated {
+			tagsJSON, err := json.Marshal(tags)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, `UPDATE notes SET tags = ?, modified_at = ? WHERE id = ?`, tagsJSON, now.Unix(), noteID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// ListTags serves GET /api/tags, returning the collection's tags as a tree.
+func (h *APIHandler) ListTags(w http.ResponseWriter, r *http.Request) {
+	tree, err := h.store.ListTagTree(r.Context(), h.collectionIDForRequest(r))
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "tag_list_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, tree)
+}
+
+// RenameTag serves POST /api/tags/rename. Renaming "anatomy" to "body" also turns
+// "anatomy::upper-limb" into "body::upper-limb".
+func (h *APIHandler) RenameTag(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+
+	var req RenameTagRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+	from := normalizeTagPath(req.From)
+	to := normalizeTagPath(sanitizeHTML(req.To))
+
+	now := time.Now()
+	updated, err := h.store.RenameTag(r.Context(), collectionID, from, to, now)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "tag_rename_failed", err.Error())
+		return
+	}
+	for noteID, tags := range updated {
+		if note, ok := col.Notes[noteID]; ok {
+			note.Tags = tags
+			note.ModifiedAt = now
+			col.Notes[noteID] = note
+		}
+	}
+
+	respondJSON(w, http.StatusOK, RenameTagResponse{From: from, To: to, UpdatedNotes: len(updated)})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestRenameTagPath(t *testing.T) {
+	cases := []struct {
+		tag, from, to, want string
+		renamed             bool
+	}{
+		{"anatomy", "anatomy", "body", "body", true},
+		{"Anatomy::Upper-Limb", "anatomy", "body", "body::Upper-Limb", true},
+		{"anatomy :: upper-limb::hand", "anatomy::upper-limb", "arm", "arm::hand", true},
+		{"anatomy-extra", "anatomy", "body", "anatomy-extra", false},
+		{"physiology", "anatomy", "body", "physiology", false},
+	}
+	for _, tc := range cases {
+		got, renamed := renameTagPath(tc.tag, tc.from, tc.to)
+		if got != tc.want || renamed != tc.renamed {
+			t.Fatalf("renameTagPath(%q, %q, %q) = %q, %v; want %q, %v", tc.tag, tc.from, tc.to, got, renamed, tc.want, tc.renamed)
+		}
+	}
+}
+
+func TestAPI_TagTreeAndSubtreeRename(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	add := func(front string, tags ...string) int64 {
+		return createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": front, "Back": "answer"},
+			Tags:      tags,
+		}, nil).Note.ID
+	}
+	hand := add("hand", "anatomy::upper-limb::hand", "high-yield")
+	femur := add("femur", "anatomy :: lower-limb")
+	add("kidney", "physiology")
+
+	rr := doRawRequest(env.router, http.MethodGet, "/api/tags", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected tags 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	tree := decodeJSON[[]TagNode](t, rr)
+	if len(tree) != 3 || tree[0].Path != "anatomy" || tree[0].NoteCount != 2 {
+		t.Fatalf("expected anatomy, high-yield, physiology roots with anatomy covering 2 notes, got %+v", tree)
+	}
+	anatomy := tree[0]
+	if len(anatomy.Children) != 2 || anatomy.Children[0].Path != "anatomy::lower-limb" || anatomy.Children[1].Children[0].Path != "anatomy::upper-limb::hand" {
+		t.Fatalf("unexpected anatomy subtree %+v", anatomy.Children)
+	}
+
+	rr = doJSONRequest(t, env.router, http.MethodPost, "/api/tags/rename", RenameTagRequest{From: "Anatomy", To: "body"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected rename 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if resp := decodeJSON[RenameTagResponse](t, rr); resp.UpdatedNotes != 2 {
+		t.Fatalf("expected two notes renamed, got %+v", resp)
+	}
+
+	for noteID, want := range map[int64][]string{
+		hand:  {"body::upper-limb::hand", "high-yield"},
+		femur: {"body::lower-limb"},
+	} {
+		note, err := env.store.GetNote(context.Background(), noteID)
+		if err != nil {
+			t.Fatalf("failed to load note %d: %v", noteID, err)
+		}
+		if !reflect.DeepEqual(note.Tags, want) {
+			t.Fatalf("note %d: expected tags %v, got %v", noteID, want, note.Tags)
+		}
+	}
+
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/tags/rename", RenameTagRequest{From: "body", To: " :: "}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected empty target to be rejected, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// serverTLS is how a server with TLS enabled serves HTTPS. Redirect handles the plain
+// HTTP listener on TLS.RedirectPort: with autocert it answers Let's Encrypt's HTTP
+// challenges, and otherwise it sends every request to HTTPS.
+type serverTLS struct {
+	Config   *tls.Config
+	Redirect http.Handler
+}
+
+// newServerTLS loads the configured certificate or prepares autocert, returning nil when
+// TLS is off.
+func newServerTLS(cfg TLSConfig, httpsPort string) (*serverTLS, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	if len(cfg.AutocertHosts) > 0 {
+		if err := os.MkdirAll(cfg.AutocertDir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create autocert directory: %w", err)
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(cfg.AutocertDir),
+			HostPolicy: autocert.HostWhitelist(cfg.AutocertHosts...),
+			Email:      cfg.AutocertEmail,
+		}
+		return &serverTLS{Config: manager.TLSConfig(), Redirect: manager.HTTPHandler(nil)}, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	return &serverTLS{
+		Config:   &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12},
+		Redirect: redirectToHTTPS(httpsPort),
+	}, nil
+}
+
+// redirectToHTTPS sends plain HTTP requests to the same URL over HTTPS on httpsPort.
+func redirectToHTTPS(httpsPort string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if name, _, err := net.SplitHostPort(host); err == nil {
+			host = name
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestCertificate(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return certPath, keyPath
+}
+
+func TestNewServerTLSServesConfiguredCertificate(t *testing.T) {
+	certPath, keyPath := writeTestCertificate(t, t.TempDir())
+	serverTLS, err := newServerTLS(TLSConfig{CertFile: certPath, KeyFile: keyPath}, "8443")
+	if err != nil {
+		t.Fatalf("failed to set up TLS: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	server.TLS = serverTLS.Config
+	server.StartTLS()
+	defer server.Close()
+	client := server.Client()
+	client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected an HTTPS response: %v", err)
+	}
+	resp.Body.Close()
+	if resp.TLS == nil || resp.TLS.PeerCertificates[0].Subject.CommonName != "localhost" {
+		t.Fatalf("expected the configured certificate, got %+v", resp.TLS)
+	}
+
+	rr := httptest.NewRecorder()
+	serverTLS.Redirect.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://example.com:8080/api/decks?x=1", nil))
+	if rr.Code != http.StatusPermanentRedirect || rr.Header().Get("Location") != "https://example.com:8443/api/decks?x=1" {
+		t.Fatalf("expected a redirect to HTTPS, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+}
+
+func TestNewServerTLSAutocertOnlyServesConfiguredHosts(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "autocert")
+	serverTLS, err := newServerTLS(TLSConfig{AutocertHosts: []string{"cards.example.com"}, AutocertDir: dir}, "443")
+	if err != nil {
+		t.Fatalf("failed to set up autocert: %v", err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("expected the certificate cache directory: %v", err)
+	}
+	if _, err := serverTLS.Config.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.example.com"}); err == nil {
+		t.Fatalf("expected a host outside the list to be refused a certificate")
+	}
+}
+
+func TestValidateRejectsConflictingTLSSettings(t *testing.T) {
+	cfg := mustLocalAppConfig()
+	cfg.TLS.CertFile = "cert.pem"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected a certificate without a key to be rejected")
+	}
+	cfg.TLS.KeyFile = "key.pem"
+	cfg.TLS.AutocertHosts = []string{"cards.example.com"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected a certificate file and autocert to be rejected together")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// TodayStats summarizes the answers given in the current study day. Answers are split
+// by the state the card was in before them; manual reschedules are not answers and are
+// left out.
+type TodayStats struct {
+	Date         string  `json:"date"`
+	CardsStudied int     `json:"cardsStudied"`
+	Answers      int     `json:"answers"`
+	NewCards     int     `json:"newCards"`
+	Learning     int     `json:"learning"`
+	Relearning   int     `json:"relearning"`
+	Review       int     `json:"review"`
+	AgainCount   int     `json:"againCount"`
+	AgainRate    float64 `json:"againRate"`
+	TotalTimeMs  int64   `json:"totalTimeMs"`
+}
+
+// GetTodayStats counts the user's answers in a collection between dayStart and dayEnd.
+// A blank userID counts answers from every user.
+func (s *SQLStore) GetTodayStats(ctx context.Context, userID, collectionID string, dayStart, dayEnd time.Time) (TodayStats, error) {
+	query := `
+		SELECT COUNT(DISTINCT r.card_id), COUNT(*),
+			COALESCE(SUM(CASE WHEN r.state = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN r.state = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN r.state = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN r.state = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN r.rating = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(r.time_taken_ms), 0)
+		FROM revlog r
+		JOIN cards c ON c.id = r.card_id
+		JOIN decks d ON d.id = c.deck_id
+		WHERE d.collection_id = ?
+		  AND r.reviewed_at >= ?
+		  AND r.reviewed_at < ?
+		  AND r.kind != ?
+	`
+	args := []interface{}{int(fsrs.New), int(fsrs.Learning), int(fsrs.Relearning), int(fsrs.Review), int(fsrs.Again),
+		collectionID, dayStart.Unix(), dayEnd.Unix(), reviewKindManual}
+	if strings.TrimSpace(userID) != "" {
+		query += ` AND r.user_id = ?`
+		args = append(args, userID)
+	}
+
+	stats := TodayStats{Date: dayStart.Format("2006-01-02")}
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&stats.CardsStudied, &stats.Answers, &stats.NewCards, &stats.Learning,
+		&stats.Relearning, &stats.Review, &stats.AgainCount, &stats.TotalTimeMs)
+	if err != nil {
+		return TodayStats{}, err
+	}
+	if stats.Answers > 0 {
+		stats.AgainRate = math.Round(float64(stats.AgainCount)/float64(stats.Answers)*1000) / 1000
+	}
+	return stats, nil
+}
+
+// GetTodayStats serves GET /api/stats/today.
+func (h *APIHandler) GetTodayStats(w http.ResponseWriter, r *http.Request) {
+	collectionID := h.collectionIDForRequest(r)
+	dayStart, dayEnd, err := h.store.studyDay(r.Context(), collectionID, time.Now())
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "today_stats_failed", err.Error())
+		return
+	}
+	stats, err := h.store.GetTodayStats(r.Context(), h.userIDFromRequest(r), collectionID, dayStart, dayEnd)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "today_stats_failed", err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, stats)
+}
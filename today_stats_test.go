@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestAPI_TodayStats(t *testing.T) {
+	env := setupAPITestEnv(t)
+	var ids []int64
+	for _, front := range []string{"Cortex", "Medulla"} {
+		ids = append(ids, createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": front, "Back": "Brain"},
+		}, nil).Cards[0].ID)
+	}
+	answers := []struct {
+		card   int64
+		rating int
+		ms     int
+	}{{ids[0], 1, 4000}, {ids[0], 3, 2000}, {ids[1], 4, 1000}}
+	for _, answer := range answers {
+		rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", answer.card), AnswerCardRequest{Rating: answer.rating, TimeTakenMs: answer.ms})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+		}
+	}
+	// A manual reschedule is not an answer.
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/set-due", SetDueRequest{BulkCardSelectionRequest: BulkCardSelectionRequest{CardIDs: []int64{ids[1]}}, Days: "3"}); rr.Code != http.StatusOK {
+		t.Fatalf("expected set-due 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr := doRawRequest(env.router, http.MethodGet, "/api/stats/today", "")
+	stats := decodeJSON[TodayStats](t, rr)
+	if rr.Code != http.StatusOK || stats.CardsStudied != 2 || stats.Answers != 3 || stats.NewCards != 2 || stats.Learning != 1 {
+		t.Fatalf("expected two cards studied with three answers, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if stats.AgainCount != 1 || stats.AgainRate != 0.333 || stats.TotalTimeMs != 7000 || stats.Date == "" {
+		t.Fatalf("expected again rate and study time from the answers, got %+v", stats)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Templates ask for speech with Anki's {{tts <lang> [voices=...] [speed=...]:Field}}
+// syntax. The card shows a [sound:...] reference whose filename is derived from the
+// language, voice, speed and text, so the audio is generated once per distinct
+// utterance and shared by every card and note that says the same thing. Audio is
+// always MP3.
+const (
+	ttsProviderOpenAI  = "openai"
+	ttsProviderCommand = "command"
+
+	ttsSynthesizeTimeout = 30 * time.Second
+)
+
+// ttsSpec is a parsed {{tts ...}} reference.
+type ttsSpec struct {
+	Lang  string
+	Voice string
+	Speed float64
+	Field string
+}
+
+type ttsUtterance struct {
+	spec ttsSpec
+	text string
+}
+
+// ttsProvider turns text into MP3 audio.
+type ttsProvider interface {
+	Synthesize(ctx context.Context, spec ttsSpec, text string) ([]byte, error)
+}
+
+// parseTTSToken parses the inside of a {{tts ...}} reference, such as
+// "tts ja_JP voices=Kyoko speed=0.8:Front".
+func parseTTSToken(key string) (ttsSpec, bool) {
+	rest, ok := strings.CutPrefix(key, "tts ")
+	if !ok {
+		return ttsSpec{}, false
+	}
+	idx := strings.LastIndex(rest, ":")
+	if idx < 0 {
+		return ttsSpec{}, false
+	}
+	spec := ttsSpec{Speed: 1, Field: strings.TrimSpace(rest[idx+1:])}
+	for i, option := range strings.Fields(rest[:idx]) {
+		name, value, found := strings.Cut(option, "=")
+		switch {
+		case i == 0 && !found:
+			spec.Lang = option
+		case name == "voices":
+			// Anki lists fallbacks; the first voice is the preferred one.
+			spec.Voice, _, _ = strings.Cut(value, ",")
+		case name == "speed":
+			if speed, err := strconv.ParseFloat(value, 64); err == nil && speed > 0 {
+				spec.Speed = speed
+			}
+		}
+	}
+	if spec.Lang == "" || spec.Field == "" {
+		return ttsSpec{}, false
+	}
+	return spec, true
+}
+
+// filename names the audio for text spoken with spec.
+func (spec ttsSpec) filename(text string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{spec.Lang, spec.Voice, strconv.FormatFloat(spec.Speed, 'f', -1, 64), text}, "\x00")))
+	return "tts-" + hex.EncodeToString(sum[:12]) + ".mp3"
+}
+
+// ttsSoundTag renders a {{tts ...}} reference as a [sound:...] tag. ok is false when key
+// is not a tts reference; an empty field renders nothing.
+func ttsSoundTag(key string, fields map[string]string) (string, bool) {
+	spec, ok := parseTTSToken(key)
+	if !ok {
+		return "", false
+	}
+	text := typedAnswerText(fields[spec.Field])
+	if text == "" {
+		return "", true
+	}
+	return "[sound:" + spec.filename(text) + "]", true
+}
+
+// ttsUtterances lists what the note type's templates would speak for note, keyed by
+// audio filename.
+func ttsUtterances(nt NoteType, note Note) map[string]ttsUtterance {
+	utterances := map[string]ttsUtterance{}
+	for _, tmpl := range nt.Templates {
+		for _, m := range fieldTokenRe.FindAllStringSubmatch(tmpl.QFmt+tmpl.AFmt, -1) {
+			spec, ok := parseTTSToken(strings.TrimSpace(m[1]))
+			if !ok {
+				continue
+			}
+			text := typedAnswerText(note.FieldMap[spec.Field])
+			if text == "" {
+				continue
+			}
+			utterances[spec.filename(text)] = ttsUtterance{spec: spec, text: text}
+		}
+	}
+	return utterances
+}
+
+// synthesizeNoteSpeech generates and stores any audio that note's cards refer to but
+// that does not exist yet. Failures are logged rather than failing the note save; the
+// audio is retried the next time the note is saved.
+func (h *APIHandler) synthesizeNoteSpeech(ctx context.Context, collectionID string, col *Collection, note Note) {
+	if h.tts == nil {
+		return
+	}
+	nt, ok := col.NoteTypes[note.Type]
+	if !ok {
+		return
+	}
+	for filename, utterance := range ttsUtterances(nt, note) {
+		if _, err := h.store.GetMedia(ctx, filename); err == nil {
+			continue
+		}
+		synthCtx, cancel := context.WithTimeout(ctx, ttsSynthesizeTimeout)
+		audio, err := h.tts.Synthesize(synthCtx, utterance.spec, utterance.text)
+		cancel()
+		if err != nil {
+			log.Printf("tts: failed to synthesize %s for note %d: %v", filename, note.ID, err)
+			continue
+		}
+		if err := h.store.AddMedia(ctx, collectionID, &MediaRef{Filename: filename, Data: audio, AddedAt: time.Now()}); err != nil {
+			log.Printf("tts: failed to store %s for note %d: %v", filename, note.ID, err)
+		}
+	}
+}
+
+type openAITTSProvider struct {
+	openAI OpenAIConfig
+	cfg    TTSConfig
+}
+
+func (p *openAITTSProvider) Synthesize(ctx context.Context, spec ttsSpec, text string) ([]byte, error) {
+	payload := map[string]any{
+		"model":           p.cfg.Model,
+		"voice":           p.cfg.Voice,
+		"input":           text,
+		"response_format": "mp3",
+		"speed":           spec.Speed,
+		"instructions":    fmt.Sprintf("Read the text aloud as a native speaker of the %s locale.", spec.Lang),
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.openAI.BaseURL+"/audio/speech", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.openAI.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("OpenAI speech request failed with status %d", res.StatusCode)
+	}
+	return io.ReadAll(res.Body)
+}
+
+// commandTTSProvider runs a local engine. The text is written to the command's stdin,
+// the locale, voice and speed are passed as TTS_LANG, TTS_VOICE and TTS_SPEED, and the
+// command must write MP3 audio to stdout.
+type commandTTSProvider struct {
+	command []string
+}
+
+func (p *commandTTSProvider) Synthesize(ctx context.Context, spec ttsSpec, text string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, p.command[0], p.command[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Env = append(os.Environ(),
+		"TTS_LANG="+spec.Lang,
+		"TTS_VOICE="+spec.Voice,
+		"TTS_SPEED="+strconv.FormatFloat(spec.Speed, 'f', -1, 64),
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	audio, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if len(audio) == 0 {
+		return nil, fmt.Errorf("tts command produced no audio")
+	}
+	return audio, nil
+}
+
+// newTTSProvider returns the configured provider, or nil when speech is disabled.
+func newTTSProvider(cfg AppConfig) ttsProvider {
+	switch cfg.TTS.Provider {
+	case ttsProviderOpenAI:
+		if strings.TrimSpace(cfg.OpenAI.APIKey) == "" {
+			log.Printf("tts: VUTADEX_TTS_PROVIDER=openai needs VUTADEX_OPENAI_API_KEY; speech is disabled")
+			return nil
+		}
+		return &openAITTSProvider{openAI: cfg.OpenAI, cfg: cfg.TTS}
+	case ttsProviderCommand:
+		command := strings.Fields(cfg.TTS.Command)
+		if len(command) == 0 {
+			log.Printf("tts: VUTADEX_TTS_PROVIDER=command needs VUTADEX_TTS_COMMAND; speech is disabled")
+			return nil
+		}
+		return &commandTTSProvider{command: command}
+	}
+	return nil
+}
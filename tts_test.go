@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type fakeTTSProvider struct {
+	calls []string
+}
+
+func (p *fakeTTSProvider) Synthesize(ctx context.Context, spec ttsSpec, text string) ([]byte, error) {
+	p.calls = append(p.calls, spec.Lang+":"+text)
+	return []byte("ID3 " + text), nil
+}
+
+func TestParseTTSToken(t *testing.T) {
+	spec, ok := parseTTSToken("tts ja_JP voices=Kyoko,Otoya speed=0.8:Front")
+	if !ok || spec.Lang != "ja_JP" || spec.Voice != "Kyoko" || spec.Speed != 0.8 || spec.Field != "Front" {
+		t.Fatalf("unexpected spec %+v (%v)", spec, ok)
+	}
+	if _, ok := parseTTSToken("Front"); ok {
+		t.Fatalf("expected plain field not to parse as tts")
+	}
+	if _, ok := parseTTSToken("tts :Front"); ok {
+		t.Fatalf("expected tts without a language to be rejected")
+	}
+
+	fields := map[string]string{"Front": "<b>ねこ</b>"}
+	tag, ok := ttsSoundTag("tts ja_JP:Front", fields)
+	if !ok || !strings.HasPrefix(tag, "[sound:tts-") || !strings.HasSuffix(tag, ".mp3]") {
+		t.Fatalf("unexpected sound tag %q", tag)
+	}
+	if again, _ := ttsSoundTag("tts ja_JP:Front", map[string]string{"Front": "ねこ"}); again != tag {
+		t.Fatalf("expected markup-insensitive filenames, got %q and %q", tag, again)
+	}
+	if empty, ok := ttsSoundTag("tts ja_JP:Missing", fields); !ok || empty != "" {
+		t.Fatalf("expected empty field to render nothing, got %q", empty)
+	}
+}
+
+func TestAPI_TTSGeneratesAudioOnNoteSave(t *testing.T) {
+	env := setupAPITestEnv(t)
+	provider := &fakeTTSProvider{}
+	env.handler.tts = provider
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/note-types", CreateNoteTypeRequest{
+		Name:   "Spoken",
+		Fields: []string{"Word", "Meaning"},
+		Templates: []TemplateInfo{
+			{Name: "Card 1", QFmt: "{{Word}}", AFmt: "{{FrontSide}}<hr>{{Meaning}} {{tts ja_JP:Word}}"},
+		},
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected note type 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	note := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Spoken",
+		DeckID:    1,
+		FieldVals: map[string]string{"Word": "いぬ", "Meaning": "dog"},
+	}, nil)
+	back := note.Cards[0].Back
+	start := strings.Index(back, "[sound:")
+	if start < 0 {
+		t.Fatalf("expected a sound reference on the back, got %q", back)
+	}
+	filename := strings.TrimSuffix(back[start+len("[sound:"):], "]")
+	media, err := env.store.GetMedia(context.Background(), filename)
+	if err != nil || string(media.Data) != "ID3 いぬ" {
+		t.Fatalf("expected generated audio stored as %s, got %+v (%v)", filename, media, err)
+	}
+
+	createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Spoken",
+		DeckID:    1,
+		FieldVals: map[string]string{"Word": "いぬ", "Meaning": "dog (again)"},
+	}, nil)
+	if len(provider.calls) != 1 || provider.calls[0] != "ja_JP:いぬ" {
+		t.Fatalf("expected the shared utterance synthesized once, got %v", provider.calls)
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+const (
+	defaultVacationSpreadDays = 7
+	maxVacationSpreadDays     = 365
+)
+
+// VacationSettings pauses the study queue for a collection between StartsAt and EndsAt.
+// When the user returns, cards that fell due while away are spread over SpreadDays
+// instead of all landing on the first day back.
+type VacationSettings struct {
+	Enabled              bool      `json:"enabled"`
+	StartsAt             time.Time `json:"startsAt,omitempty"`
+	EndsAt               time.Time `json:"endsAt,omitempty"`
+	SpreadDays           int       `json:"spreadDays"`
+	LastReturnAt         time.Time `json:"lastReturnAt,omitempty"`
+	LastRescheduledCards int       `json:"lastRescheduledCards"`
+}
+
+// Active reports whether due dates are currently frozen.
+func (v VacationSettings) Active(now time.Time) bool {
+	return v.Enabled && !now.Before(v.StartsAt) && now.Before(v.EndsAt)
+}
+
+type UpdateVacationRequest struct {
+	StartsAt   time.Time `json:"startsAt"`
+	EndsAt     time.Time `json:"endsAt"`
+	SpreadDays *int      `json:"spreadDays,omitempty"`
+}
+
+// Validate checks the range; a missing start means the vacation starts now.
+func (req *UpdateVacationRequest) Validate(v *requestValidator) {
+	now := time.Now()
+	start := req.StartsAt
+	if start.IsZero() {
+		start = now
+	}
+	switch {
+	case req.EndsAt.IsZero() || !req.EndsAt.After(start):
+		v.fail("endsAt", "must be after the start")
+	case !req.EndsAt.After(now):
+		v.fail("endsAt", "must be in the future")
+	}
+	if req.SpreadDays != nil {
+		v.check(*req.SpreadDays >= 0 && *req.SpreadDays <= maxVacationSpreadDays, "spreadDays", fmt.Sprintf("must be from 0 to %d", maxVacationSpreadDays))
+	}
+}
+
+type VacationStatusResponse struct {
+	Vacation VacationSettings `json:"vacation"`
+	Active   bool             `json:"active"`
+}
+
+// vacationSpreadDue returns the new due date for the index-th backlog card when total
+// cards are spread evenly over spreadDays starting at returnAt.
+func vacationSpreadDue(returnAt time.Time, index, total, spreadDays int) time.Time {
+	if spreadDays <= 1 || total <= 0 {
+		return returnAt
+	}
+	day := index * spreadDays / total
+	return returnAt.AddDate(0, 0, day)
+}
+
+// RescheduleVacationBacklog moves cards in the collection that came due between
+// startsAt and returnAt onto the days following returnAt, keeping their relative order.
+// Both the shared card rows and every user's review state are rescheduled. New cards
+// are left untouched because they are not part of the review backlog.
+func (s *SQLStore) RescheduleVacationBacklog(ctx context.Context, collectionID string, startsAt, returnAt time.Time, spreadDays int) (int, error) {
+	now := time.Now()
+	var rescheduled int
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		cardRows, err := tx.QueryContext(ctx, `
+			SELECT c.id
+			FROM cards c
+			JOIN decks d ON d.id = c.deck_id
+			WHERE d.collection_id = ?
+			  AND c.state != ?
+			  AND c.due >= ?
+			  AND c.due <= ?
+			ORDER BY c.due ASC, c.id ASC
+		`, collectionID, int(fsrs.New), startsAt.Unix(), returnAt.Unix())
+		if err != nil {
+			return err
+		}
+		var cardIDs []int64
+		for cardRows.Next() {
+			var cardID int64
+			if err := cardRows.Scan(&cardID); err != nil {
+				cardRows.Close()
+				return err
+			}
+			cardIDs = append(cardIDs, cardID)
+		}
+		cardRows.Close()
+		if err := cardRows.Err(); err != nil {
+			return err
+		}
+
+		if err := rescheduleBacklogTx(ctx, tx, newCardStateTable(""), cardIDs, returnAt, spreadDays, now); err != nil {
+			return err
+		}
+
+		stateRows, err := tx.QueryContext(ctx, `
+			SELECT rs.user_id, rs.card_id, rs.state
+			FROM card_review_states rs
+			JOIN cards c ON c.id = rs.card_id
+			JOIN decks d ON d.id = c.deck_id
+			WHERE d.collection_id = ?
+			  AND rs.state != ?
+			  AND rs.due >= ?
+			  AND rs.due <= ?
+			ORDER BY rs.user_id ASC, rs.due ASC, rs.card_id ASC
+		`, collectionID, int(fsrs.New), startsAt.Unix(), returnAt.Unix())
+		if err != nil {
+			return err
+		}
+		backlogByUser := make(map[string][]int64)
+		stateByCard := make(map[string]map[int64]int)
+		var userOrder []string
+		for stateRows.Next() {
+			var (
+				userID string
+				cardID int64
+				state  int
+			)
+			if err := stateRows.Scan(&userID, &cardID, &state); err != nil {
+				stateRows.Close()
+				return err
+			}
+			if _, ok := backlogByUser[userID]; !ok {
+				userOrder = append(userOrder, userID)
+				stateByCard[userID] = make(map[int64]int)
+			}
+			backlogByUser[userID] = append(backlogByUser[userID], cardID)
+			stateByCard[userID][cardID] = state
+		}
+		stateRows.Close()
+		if err := stateRows.Err(); err != nil {
+			return err
+		}
+
+		rescheduled = len(cardIDs)
+		for _, userID := range userOrder {
+			backlog := backlogByUser[userID]
+			if err := rescheduleBacklogTx(ctx, tx, newCardStateTable(userID), backlog, returnAt, spreadDays, now); err != nil {
+				return err
+			}
+			for i, cardID := range backlog {
+				due := vacationSpreadDue(returnAt, i, len(backlog), spreadDays)
+				if err := insertManualRevlogTx(ctx, tx, userID, cardID, stateByCard[userID][cardID], due, now); err != nil {
+					return err
+				}
+			}
+			rescheduled += len(backlog)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rescheduled, nil
+}
+
+// rescheduleBacklogTx spreads the backlog cardIDs, in order, over spreadDays from
+// returnAt in table.
+func rescheduleBacklogTx(ctx context.Context, tx *sql.Tx, table cardStateTable, cardIDs []int64, returnAt time.Time, spreadDays int, now time.Time) error {
+	states, err := table.load(ctx, tx, cardIDs)
+	if err != nil {
+		return err
+	}
+	for i, cardID := range cardIDs {
+		card := states[cardID]
+		card.Due = vacationSpreadDue(returnAt, i, len(cardIDs), spreadDays)
+		if err := table.saveDue(ctx, tx, cardID, card, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// settleVacation ends a vacation whose window has passed and spreads the backlog it
+// left behind. It is safe to call on every study request.
+func (h *APIHandler) settleVacation(ctx context.Context, collectionID string, now time.Time) (CollectionPreferences, error) {
+	prefs, err := h.store.GetCollectionPreferences(ctx, collectionID)
+	if err != nil {
+		return prefs, err
+	}
+	vacation := prefs.Vacation
+	if !vacation.Enabled || now.Before(vacation.EndsAt) {
+		return prefs, nil
+	}
+
+	rescheduled, err := h.store.RescheduleVacationBacklog(ctx, collectionID, vacation.StartsAt, now, vacation.SpreadDays)
+	if err != nil {
+		return prefs, err
+	}
+	prefs.Vacation.Enabled = false
+	prefs.Vacation.LastReturnAt = now
+	prefs.Vacation.LastRescheduledCards = rescheduled
+	if err := h.store.SaveCollectionPreferences(ctx, collectionID, prefs); err != nil {
+		return prefs, err
+	}
+	return prefs, nil
+}
+
+func (h *APIHandler) GetVacation(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	prefs, err := h.settleVacation(r.Context(), h.collectionIDForRequest(r), now)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "vacation_load_failed", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, VacationStatusResponse{
+		Vacation: prefs.Vacation,
+		Active:   prefs.Vacation.Active(now),
+	})
+}
+
+func (h *APIHandler) UpdateVacation(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+
+	var req UpdateVacationRequest
+	if !decodeValidatedRequest(w, r, &req) {
+		return
+	}
+
+	now := time.Now()
+	if req.StartsAt.IsZero() {
+		req.StartsAt = now
+	}
+
+	collectionID := h.collectionIDForRequest(r)
+	prefs, err := h.settleVacation(r.Context(), collectionID, now)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "vacation_load_failed", err.Error())
+		return
+	}
+
+	spreadDays := prefs.Vacation.SpreadDays
+	if req.SpreadDays != nil {
+		spreadDays = *req.SpreadDays
+	}
+	if spreadDays <= 0 {
+		spreadDays = defaultVacationSpreadDays
+	}
+
+	prefs.Vacation.Enabled = true
+	prefs.Vacation.StartsAt = req.StartsAt
+	prefs.Vacation.EndsAt = req.EndsAt
+	prefs.Vacation.SpreadDays = spreadDays
+	if err := h.store.SaveCollectionPreferences(r.Context(), collectionID, prefs); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "vacation_update_failed", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, VacationStatusResponse{
+		Vacation: prefs.Vacation,
+		Active:   prefs.Vacation.Active(now),
+	})
+}
+
+// EndVacation cuts a vacation short and immediately spreads the backlog.
+func (h *APIHandler) EndVacation(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWorkspaceWritePermission(w, r) {
+		return
+	}
+
+	collectionID := h.collectionIDForRequest(r)
+	prefs, err := h.store.GetCollectionPreferences(r.Context(), collectionID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "vacation_load_failed", err.Error())
+		return
+	}
+	if !prefs.Vacation.Enabled {
+		respondAPIError(w, http.StatusConflict, "vacation_not_enabled", "No vacation is scheduled")
+		return
+	}
+
+	now := time.Now()
+	if now.Before(prefs.Vacation.StartsAt) {
+		// The vacation never started; just cancel it.
+		prefs.Vacation.Enabled = false
+		if err := h.store.SaveCollectionPreferences(r.Context(), collectionID, prefs); err != nil {
+			respondAPIError(w, http.StatusInternalServerError, "vacation_update_failed", err.Error())
+			return
+		}
+		respondJSON(w, http.StatusOK, VacationStatusResponse{Vacation: prefs.Vacation})
+		return
+	}
+
+	prefs.Vacation.EndsAt = now
+	if err := h.store.SaveCollectionPreferences(r.Context(), collectionID, prefs); err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "vacation_update_failed", err.Error())
+		return
+	}
+	prefs, err = h.settleVacation(r.Context(), collectionID, now)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "vacation_update_failed", err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, VacationStatusResponse{Vacation: prefs.Vacation})
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestVacationSpreadDue(t *testing.T) {
+	returnAt := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	days := make([]int, 0, 6)
+	for i := 0; i < 6; i++ {
+		due := vacationSpreadDue(returnAt, i, 6, 3)
+		days = append(days, int(due.Sub(returnAt).Hours()/24))
+	}
+	want := []int{0, 0, 1, 1, 2, 2}
+	for i := range want {
+		if days[i] != want[i] {
+			t.Fatalf("expected spread days %v, got %v", want, days)
+		}
+	}
+
+	if got := vacationSpreadDue(returnAt, 4, 5, 1); !got.Equal(returnAt) {
+		t.Fatalf("expected single-day spread to keep return date, got %v", got)
+	}
+}
+
+func TestAPI_VacationPausesQueueAndSpreadsBacklog(t *testing.T) {
+	env := setupAPITestEnv(t)
+	sessionID := strings.TrimPrefix(env.authCookie, sessionCookieName+"=")
+	sessionRecord, err := env.store.GetSessionRecord(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("failed to load current session: %v", err)
+	}
+
+	for i := 0; i < 6; i++ {
+		createNoteForTest(t, env, CreateNoteRequest{
+			TypeID: "Basic",
+			DeckID: 1,
+			FieldVals: map[string]string{
+				"Front": fmt.Sprintf("Vacation %d", i),
+				"Back":  "Answer",
+			},
+		}, nil)
+	}
+
+	dueRR := doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=100", "")
+	if dueRR.Code != http.StatusOK {
+		t.Fatalf("expected due request 200, got %d (%s)", dueRR.Code, dueRR.Body.String())
+	}
+	dueCards := decodeJSON[[]Card](t, dueRR)
+	if len(dueCards) != 6 {
+		t.Fatalf("expected 6 due cards before vacation, got %d", len(dueCards))
+	}
+
+	now := time.Now()
+	for i, card := range dueCards {
+		if _, err := env.store.db.Exec(`
+			UPDATE card_review_states
+			SET state = ?, due = ?
+			WHERE user_id = ? AND card_id = ?
+		`, int(fsrs.Review), now.Add(-time.Duration(i+1)*time.Hour).Unix(), sessionRecord.UserID, card.ID); err != nil {
+			t.Fatalf("failed to move card %d into review backlog: %v", card.ID, err)
+		}
+	}
+
+	spreadDays := 3
+	startRR := doJSONRequest(t, env.router, http.MethodPut, "/api/vacation", UpdateVacationRequest{
+		StartsAt:   now.Add(-48 * time.Hour),
+		EndsAt:     now.Add(24 * time.Hour),
+		SpreadDays: &spreadDays,
+	})
+	if startRR.Code != http.StatusOK {
+		t.Fatalf("expected vacation update 200, got %d (%s)", startRR.Code, startRR.Body.String())
+	}
+	status := decodeJSON[VacationStatusResponse](t, startRR)
+	if !status.Active || status.Vacation.SpreadDays != 3 {
+		t.Fatalf("expected active vacation with 3 spread days, got %+v", status)
+	}
+
+	pausedRR := doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=100", "")
+	if pausedRR.Code != http.StatusOK {
+		t.Fatalf("expected paused due request 200, got %d (%s)", pausedRR.Code, pausedRR.Body.String())
+	}
+	if paused := decodeJSON[[]Card](t, pausedRR); len(paused) != 0 {
+		t.Fatalf("expected no due cards during vacation, got %d", len(paused))
+	}
+
+	endRR := doRawRequest(env.router, http.MethodDelete, "/api/vacation", "")
+	if endRR.Code != http.StatusOK {
+		t.Fatalf("expected vacation end 200, got %d (%s)", endRR.Code, endRR.Body.String())
+	}
+	ended := decodeJSON[VacationStatusResponse](t, endRR)
+	if ended.Vacation.Enabled || ended.Vacation.LastRescheduledCards < 6 {
+		t.Fatalf("expected vacation to end and reschedule the backlog, got %+v", ended.Vacation)
+	}
+
+	var manualEntries int
+	if err := env.store.db.QueryRow(`SELECT COUNT(*) FROM revlog WHERE user_id = ? AND kind = ? AND rating = 0`, sessionRecord.UserID, reviewKindManual).Scan(&manualEntries); err != nil {
+		t.Fatalf("failed to count manual revlog entries: %v", err)
+	}
+	if manualEntries != 6 {
+		t.Fatalf("expected a manual revlog entry per rescheduled card, got %d", manualEntries)
+	}
+
+	resumedRR := doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=100", "")
+	if resumedRR.Code != http.StatusOK {
+		t.Fatalf("expected resumed due request 200, got %d (%s)", resumedRR.Code, resumedRR.Body.String())
+	}
+	if resumed := decodeJSON[[]Card](t, resumedRR); len(resumed) != 2 {
+		t.Fatalf("expected first day back to hold 2 of 6 backlog cards, got %d", len(resumed))
+	}
+
+	var latestDue int64
+	if err := env.store.db.QueryRow(`SELECT MAX(due) FROM card_review_states WHERE user_id = ?`, sessionRecord.UserID).Scan(&latestDue); err != nil {
+		t.Fatalf("failed to read latest due: %v", err)
+	}
+	if latestDue < now.Add(47*time.Hour).Unix() {
+		t.Fatalf("expected backlog to extend across 3 days, latest due=%v", time.Unix(latestDue, 0))
+	}
+}
+
+func TestAPI_VacationRescheduleSurvivesNoteEdit(t *testing.T) {
+	env := setupAPITestEnv(t)
+	created := createNoteForTest(t, env, CreateNoteRequest{
+		TypeID:    "Basic",
+		DeckID:    1,
+		FieldVals: map[string]string{"Front": "Away", "Back": "Back soon"},
+	}, nil)
+	cardID := created.Cards[0].ID
+
+	now := time.Now()
+	card, err := env.store.GetCard(context.Background(), cardID)
+	if err != nil {
+		t.Fatalf("failed to load card: %v", err)
+	}
+	card.SRS.State = fsrs.Review
+	card.SRS.Due = now.Add(-time.Hour)
+	if err := env.store.UpdateCard(context.Background(), card); err != nil {
+		t.Fatalf("failed to move the card into the backlog: %v", err)
+	}
+
+	returnAt := now.Add(time.Hour)
+	if n, err := env.store.RescheduleVacationBacklog(context.Background(), "default", now.Add(-48*time.Hour), returnAt, 1); err != nil || n != 1 {
+		t.Fatalf("expected one card rescheduled, got %d (err %v)", n, err)
+	}
+	cards, err := env.store.GetCardsByNote(context.Background(), created.Note.ID)
+	if err != nil || len(cards) != 1 || cards[0].SRS.Due.Unix() != returnAt.Unix() {
+		t.Fatalf("expected the FSRS state to carry the new due date, got %+v (err %v)", cards, err)
+	}
+
+	if rr := doJSONRequest(t, env.router, http.MethodPatch, fmt.Sprintf("/api/notes/%d", created.Note.ID), UpdateNoteRequest{
+		FieldVals: map[string]string{"Front": "Away again", "Back": "Back soon"},
+	}); rr.Code != http.StatusOK {
+		t.Fatalf("expected the note updated, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	var due int64
+	if err := env.store.db.QueryRow(`SELECT due FROM cards WHERE id = ?`, cardID).Scan(&due); err != nil || due != returnAt.Unix() {
+		t.Fatalf("expected the note edit to keep the rescheduled due date, got %v (err %v)", time.Unix(due, 0), err)
+	}
+
+	spreadDays := -1
+	if rr := doJSONRequest(t, env.router, http.MethodPut, "/api/vacation", UpdateVacationRequest{
+		EndsAt:     now.Add(24 * time.Hour),
+		SpreadDays: &spreadDays,
+	}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected a negative spread to be rejected, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Request bodies check themselves by implementing validatedRequest; a handler decodes
+// one with decodeValidatedRequest, which answers a bad request with every problem found
+// rather than only the first:
+//
+//	{"code": "validation_failed", "message": "rating must be 1-4 (Again/Hard/Good/Easy)",
+//	 "details": {"fields": [{"field": "rating", "message": "must be 1-4 (Again/Hard/Good/Easy)"}]}}
+type validatedRequest interface {
+	Validate(v *requestValidator)
+}
+
+// FieldError names a request field and what is wrong with it.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ValidationErrorDetails struct {
+	Fields []FieldError `json:"fields"`
+}
+
+// requestValidator collects the field errors of one request.
+type requestValidator struct {
+	errs []FieldError
+}
+
+func (v *requestValidator) fail(field, message string) {
+	v.errs = append(v.errs, FieldError{Field: field, Message: message})
+}
+
+func (v *requestValidator) check(ok bool, field, message string) {
+	if !ok {
+		v.fail(field, message)
+	}
+}
+
+func (v *requestValidator) required(field, value string) {
+	v.check(strings.TrimSpace(value) != "", field, "is required")
+}
+
+func (v *requestValidator) intRange(field string, value, min, max int) {
+	v.check(value >= min && value <= max, field, fmt.Sprintf("must be %d-%d", min, max))
+}
+
+func (v *requestValidator) maxLength(field, value string, max int) {
+	v.check(len(value) <= max, field, fmt.Sprintf("must be at most %d characters", max))
+}
+
+// oneOf checks a trimmed value against the allowed ones; a blank value passes, since
+// handlers default it.
+func (v *requestValidator) oneOf(field, value string, allowed ...string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	for _, candidate := range allowed {
+		if value == candidate {
+			return
+		}
+	}
+	v.fail(field, "must be one of "+strings.Join(allowed, ", "))
+}
+
+func (v *requestValidator) email(field, value string) {
+	switch {
+	case strings.TrimSpace(value) == "":
+		v.fail(field, "is required")
+	default:
+		if _, err := normalizeEmail(value); err != nil {
+			v.fail(field, "must be a valid email address")
+		}
+	}
+}
+
+func (v *requestValidator) rating(field string, value int) {
+	v.check(validRating(value), field, ratingRangeMessage)
+}
+
+func (v *requestValidator) flag(field string, value int) {
+	v.intRange(field, value, 0, 7)
+}
+
+// fieldName checks a note type field name as it will be stored, after sanitizing.
+func (v *requestValidator) fieldName(field, value string) {
+	if problem := fieldNameProblem(sanitizeHTML(strings.TrimSpace(value))); problem != "" {
+		v.fail(field, problem)
+	}
+}
+
+// respond writes a validation_failed response when there are errors, and reports
+// whether the request was valid.
+func (v *requestValidator) respond(w http.ResponseWriter) bool {
+	if len(v.errs) == 0 {
+		return true
+	}
+	first := v.errs[0]
+	respondAPIErrorWithDetails(w, http.StatusBadRequest, "validation_failed", first.Field+" "+first.Message, ValidationErrorDetails{Fields: v.errs})
+	return false
+}
+
+// decodeValidatedRequest decodes the JSON body into req and validates it, writing
+// invalid_request or validation_failed and returning false when either fails.
+func decodeValidatedRequest(w http.ResponseWriter, r *http.Request, req validatedRequest) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return false
+	}
+	var v requestValidator
+	req.Validate(&v)
+	return v.respond(w)
+}
+
+// decodeOptionalValidatedRequest is decodeValidatedRequest for endpoints whose body may
+// be left out; an empty body validates as the zero request.
+func decodeOptionalValidatedRequest(w http.ResponseWriter, r *http.Request, req validatedRequest) bool {
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil && !errors.Is(err, io.EOF) {
+			respondAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+			return false
+		}
+	}
+	var v requestValidator
+	req.Validate(&v)
+	return v.respond(w)
+}
+
+const ratingRangeMessage = "must be 1-4 (Again/Hard/Good/Easy)"
+
+func validRating(rating int) bool {
+	return rating >= 1 && rating <= 4
+}
+
+// fieldNameProblem describes what makes a sanitized field name unusable, or returns "".
+// Template syntax relies on the excluded characters.
+func fieldNameProblem(name string) string {
+	switch {
+	case name == "":
+		return "is required"
+	case reservedFieldNames[name]:
+		return fmt.Sprintf("cannot be %q, a reserved field name", name)
+	case strings.ContainsAny(name, ":{}#^/"):
+		return "cannot contain : { } # ^ or /"
+	}
+	return ""
+}
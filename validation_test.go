@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAPI_ValidationReportsEveryFieldError(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	rr := doJSONRequest(t, env.router, http.MethodPost, "/api/cards/1/answer", AnswerCardRequest{Rating: 9, TimeTakenMs: -1})
+	body := decodeJSON[struct {
+		Code    string                 `json:"code"`
+		Details ValidationErrorDetails `json:"details"`
+	}](t, rr)
+	if rr.Code != http.StatusBadRequest || body.Code != "validation_failed" {
+		t.Fatalf("expected validation_failed, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if len(body.Details.Fields) != 2 || body.Details.Fields[0].Field != "rating" || body.Details.Fields[1].Field != "timeTakenMs" {
+		t.Fatalf("expected rating and timeTakenMs errors, got %+v", body.Details.Fields)
+	}
+
+	flag := 8
+	if rr := doJSONRequest(t, env.router, http.MethodPatch, "/api/cards/1", UpdateCardRequest{Flag: &flag}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected an out-of-range flag to be rejected, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	for _, name := range []string{"", "Deck", "Front:Back", "{{Cloze}}"} {
+		if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/note-types/Basic/fields", AddFieldRequest{FieldName: name}); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected field name %q to be rejected, got %d (%s)", name, rr.Code, rr.Body.String())
+		}
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPost, "/api/note-types/Basic/fields", AddFieldRequest{FieldName: "Example"}); rr.Code != http.StatusOK && rr.Code != http.StatusCreated {
+		t.Fatalf("expected a valid field name to be added, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
+
+func TestAPI_RequestBodiesAreValidatedBeforeHandling(t *testing.T) {
+	env := setupAPITestEnv(t)
+
+	zero := 0
+	cases := []struct {
+		method, path string
+		body         any
+		field        string
+	}{
+		{http.MethodPost, "/api/decks", CreateDeckRequest{}, "name"},
+		{http.MethodPost, "/api/notes", CreateNoteRequest{DeckID: 1}, "typeId"},
+		{http.MethodPost, "/api/deck-options", DeckOptionsRequest{NewCardsPerDay: &zero}, "name"},
+		{http.MethodPost, "/api/deck-options", map[string]any{"name": "Slow", "easyBonus": 50}, "easyBonus"},
+		{http.MethodPost, "/api/cards/set-due", SetDueRequest{BulkCardSelectionRequest: BulkCardSelectionRequest{CardIDs: []int64{1}}}, "days"},
+		{http.MethodPut, "/api/vacation", map[string]any{"endsAt": "2000-01-01T00:00:00Z"}, "endsAt"},
+		{http.MethodPost, "/api/tags/rename", RenameTagRequest{From: "a"}, "to"},
+		{http.MethodPost, "/api/note-types", CreateNoteTypeRequest{Name: "Custom"}, "fields"},
+	}
+	for _, tc := range cases {
+		rr := doJSONRequest(t, env.router, tc.method, tc.path, tc.body)
+		body := decodeJSON[struct {
+			Code    string                 `json:"code"`
+			Details ValidationErrorDetails `json:"details"`
+		}](t, rr)
+		if rr.Code != http.StatusBadRequest || body.Code != "validation_failed" || len(body.Details.Fields) == 0 || body.Details.Fields[0].Field != tc.field {
+			t.Fatalf("%s %s: expected a %s validation error, got %d (%s)", tc.method, tc.path, tc.field, rr.Code, rr.Body.String())
+		}
+	}
+}
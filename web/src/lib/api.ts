@@ -3,15 +3,51 @@ const API_BASE = (import.meta.env.VITE_API_BASE ?? "/api").replace(/\/$/, "");
 export class APIError extends Error {
   status: number;
   code?: string;
-
-  constructor(message: string, status: number, code?: string) {
+  details?: unknown;
+  requestId?: string;
+
+  constructor(
+    message: string,
+    status: number,
+    code?: string,
+    details?: unknown,
+    requestId?: string,
+  ) {
     super(message);
     this.name = "APIError";
     this.status = status;
     this.code = code;
+    this.details = details;
+    this.requestId = requestId;
   }
 }
 
+// apiErrorFromResponse reads the server's JSON error envelope, falling back to the
+// body text for responses that are not JSON.
+async function apiErrorFromResponse(
+  res: Response,
+  fallback: string,
+): Promise<APIError> {
+  const contentType = res.headers.get("content-type") || "";
+  if (contentType.includes("application/json")) {
+    const payload = (await res.json().catch(() => null)) as {
+      message?: string;
+      code?: string;
+      details?: unknown;
+      requestId?: string;
+    } | null;
+    return new APIError(
+      payload?.message || fallback,
+      res.status,
+      payload?.code,
+      payload?.details,
+      payload?.requestId || res.headers.get("x-request-id") || undefined,
+    );
+  }
+  const text = await res.text();
+  return new APIError(text || fallback, res.status);
+}
+
 async function requestJSON<T>(path: string, init?: RequestInit): Promise<T> {
   const res = await fetch(path, {
     credentials: "include",
@@ -26,20 +62,7 @@ async function requestJSON<T>(path: string, init?: RequestInit): Promise<T> {
     ) {
       window.location.assign("/login");
     }
-    const contentType = res.headers.get("content-type") || "";
-    if (contentType.includes("application/json")) {
-      const payload = (await res.json().catch(() => null)) as {
-        message?: string;
-        code?: string;
-      } | null;
-      throw new APIError(
-        payload?.message || "Request failed",
-        res.status,
-        payload?.code,
-      );
-    }
-    const text = await res.text();
-    throw new APIError(text || "Request failed", res.status);
+    throw await apiErrorFromResponse(res, "Request failed");
   }
 
   if (res.status === 204) {
@@ -135,7 +158,7 @@ export interface CreateDeckRequest {
   name: string;
 }
 
-export type ImportSource = "auto" | "native" | "anki" | "quizlet";
+export type ImportSource = "auto" | "native" | "anki" | "quizlet" | "markdown" | "mnemosyne" | "supermemo" | "microdote";
 
 export interface ImportFileRequest {
   file: File;
@@ -145,6 +168,13 @@ export interface ImportFileRequest {
   format?: string;
 }
 
+export interface ImportURLRequest {
+  url: string;
+  source?: ImportSource;
+  deckName?: string;
+  noteType?: string;
+}
+
 export interface ImportNotesResponse {
   imported: number;
   skipped: number;
@@ -960,12 +990,21 @@ export async function importNotesFile(
   });
 
   if (!res.ok) {
-    const text = await res.text();
-    throw new Error(text || "Failed to import file");
+    throw await apiErrorFromResponse(res, "Failed to import file");
   }
   return res.json();
 }
 
+export async function importNotesFromURL(
+  req: ImportURLRequest,
+): Promise<ImportNotesResponse> {
+  return requestJSON(`${API_BASE}/import/url`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(req),
+  });
+}
+
 // Note Type endpoints
 export async function fetchNoteTypes(): Promise<NoteType[]> {
   return requestJSON(`${API_BASE}/note-types`);
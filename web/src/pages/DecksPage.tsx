@@ -205,7 +205,7 @@ export function DecksPage() {
           <input
             ref={importInputRef}
             type="file"
-            accept=".json,.yaml,.yml,.csv,.tsv,.txt,.apkg,.colpkg"
+            accept=".json,.yaml,.yml,.csv,.tsv,.txt,.md,.markdown,.apkg,.colpkg,.db,.xml"
             onChange={(event) => setImportFile(event.target.files?.[0] ?? null)}
             className="w-full rounded-2xl border border-[var(--app-line-strong)] bg-[var(--app-card-strong)] px-4 py-3 text-sm text-[var(--app-text)]"
           />
@@ -218,6 +218,9 @@ export function DecksPage() {
             <option value="native">Native JSON/YAML</option>
             <option value="anki">Anki</option>
             <option value="quizlet">Quizlet</option>
+            <option value="markdown">Markdown</option>
+            <option value="mnemosyne">Mnemosyne</option>
+            <option value="supermemo">SuperMemo</option>
           </select>
           <input
             type="text"
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// The workload forecast simulates the coming days of study from each card's current
+// memory state, so users can see what a different desired retention would cost them
+// before changing it.
+const (
+	defaultForecastDays    = 90
+	maxForecastDays        = 365
+	maxForecastRetentions  = 4
+	maxForecastNewPerDay   = 1000
+	minForecastRetention   = 0.70
+	maxForecastRetention   = 0.99
+	workloadSimulationSeed = 42
+)
+
+// WorkloadDay is the number of reviews one simulated study day holds.
+type WorkloadDay struct {
+	Date     string `json:"date"`
+	Reviews  int    `json:"reviews"`
+	NewCards int    `json:"newCards"`
+}
+
+// WorkloadForecast is the simulated load for one desired retention.
+type WorkloadForecast struct {
+	Retention      float64       `json:"retention"`
+	TotalReviews   int           `json:"totalReviews"`
+	AveragePerDay  float64       `json:"averagePerDay"`
+	PeakReviews    int           `json:"peakReviews"`
+	MemorizedAtEnd float64       `json:"memorizedAtEnd"`
+	Days           []WorkloadDay `json:"days"`
+}
+
+type workloadForecastResponse struct {
+	Days           int                `json:"days"`
+	Cards          int                `json:"cards"`
+	NewCardsPerDay int                `json:"newCardsPerDay"`
+	Forecasts      []WorkloadForecast `json:"forecasts"`
+}
+
+// ListCardMemoryStates returns the FSRS state and due time of the user's unsuspended
+// cards in a collection. A deckID of 0 covers every deck; otherwise the deck and its
+// descendants.
+func (s *SQLStore) ListCardMemoryStates(ctx context.Context, userID, collectionID string, deckID int64) ([]fsrs.Card, error) {
+	query := `
+		SELECT COALESCE(rs.due, c.due), COALESCE(rs.fsrs_data, c.fsrs_data)
+		FROM cards c
+		JOIN decks d ON d.id = c.deck_id
+		LEFT JOIN card_review_states rs ON rs.card_id = c.id AND rs.user_id = ?
+		WHERE d.collection_id = ? AND COALESCE(rs.suspended, c.suspended, 0) = 0
+	`
+	args := []interface{}{strings.TrimSpace(userID), collectionID}
+	if deckID > 0 {
+		query = deckSubtreeCTE + query + ` AND c.deck_id IN (SELECT id FROM subtree)`
+		args = append([]interface{}{deckID}, args...)
+	}
+	query += ` ORDER BY c.id`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cards := []fsrs.Card{}
+	for rows.Next() {
+		var (
+			dueUnix  int64
+			fsrsJSON []byte
+			card     fsrs.Card
+		)
+		if err := rows.Scan(&dueUnix, &fsrsJSON); err != nil {
+			return nil, err
+		}
+		if len(fsrsJSON) > 0 {
+			if err := json.Unmarshal(fsrsJSON, &card); err != nil {
+				return nil, err
+			}
+		}
+		card.Due = time.Unix(dueUnix, 0)
+		cards = append(cards, card)
+	}
+	return cards, rows.Err()
+}
+
+// simulateWorkload plays cards forward day by day from dayStart at the given desired
+// retention. A due card is recalled with the probability FSRS predicts for it and is
+// answered Good, otherwise Again; up to newPerDay new cards are introduced each day.
+// The seed is fixed so that forecasts for different retentions are comparable.
+func simulateWorkload(cards []fsrs.Card, params fsrs.Parameters, retention float64, dayStart time.Time, days, newPerDay int) WorkloadForecast {
+	params.RequestRetention = retention
+	params.EnableShortTerm = false
+	params.EnableFuzz = false
+	scheduler := fsrs.NewFSRS(params)
+	rng := rand.New(rand.NewPCG(workloadSimulationSeed, uint64(retention*1000)))
+
+	forecast := WorkloadForecast{Retention: retention, Days: make([]WorkloadDay, days)}
+	for i := range forecast.Days {
+		forecast.Days[i].Date = dayStart.AddDate(0, 0, i).Format("2006-01-02")
+	}
+
+	var (
+		newCards []fsrs.Card
+		seen     []fsrs.Card
+		dueDays  []int
+	)
+	for _, card := range cards {
+		if card.State == fsrs.New {
+			newCards = append(newCards, card)
+			continue
+		}
+		seen = append(seen, card)
+		dueDays = append(dueDays, max(int(math.Floor(card.Due.Sub(dayStart).Hours()/24)), 0))
+	}
+
+	for day := 0; day < days; day++ {
+		for introduced := 0; introduced < newPerDay && len(newCards) > 0; introduced++ {
+			seen = append(seen, newCards[0])
+			dueDays = append(dueDays, day)
+			newCards = newCards[1:]
+			forecast.Days[day].NewCards++
+		}
+		reviewedAt := dayStart.AddDate(0, 0, day).Add(12 * time.Hour)
+		for i := range seen {
+			if dueDays[i] != day {
+				continue
+			}
+			rating := fsrs.Good
+			if seen[i].State != fsrs.New && rng.Float64() >= scheduler.GetRetrievability(seen[i], reviewedAt) {
+				rating = fsrs.Again
+			}
+			seen[i] = scheduler.Next(seen[i], reviewedAt, rating).Card
+			dueDays[i] = day + max(int(seen[i].ScheduledDays), 1)
+			forecast.Days[day].Reviews++
+		}
+		forecast.TotalReviews += forecast.Days[day].Reviews
+		forecast.PeakReviews = max(forecast.PeakReviews, forecast.Days[day].Reviews)
+	}
+
+	end := dayStart.AddDate(0, 0, days)
+	for _, card := range seen {
+		forecast.MemorizedAtEnd += scheduler.GetRetrievability(card, end)
+	}
+	forecast.MemorizedAtEnd = math.Round(forecast.MemorizedAtEnd*10) / 10
+	if days > 0 {
+		forecast.AveragePerDay = math.Round(float64(forecast.TotalReviews)/float64(days)*10) / 10
+	}
+	return forecast
+}
+
+// parseForecastRetentions reads a comma-separated list of desired retentions, falling
+// back to the collection's own when none is given.
+func parseForecastRetentions(raw string, fallback float64) ([]float64, error) {
+	if strings.TrimSpace(raw) == "" {
+		return []float64{fallback}, nil
+	}
+	parts := strings.Split(raw, ",")
+	if len(parts) > maxForecastRetentions {
+		return nil, fmt.Errorf("at most %d retentions can be compared", maxForecastRetentions)
+	}
+	retentions := make([]float64, 0, len(parts))
+	for _, part := range parts {
+		retention, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || retention < minForecastRetention || retention > maxForecastRetention {
+			return nil, fmt.Errorf("retention must be between %.2f and %.2f", minForecastRetention, maxForecastRetention)
+		}
+		retentions = append(retentions, retention)
+	}
+	return retentions, nil
+}
+
+// ForecastWorkload serves GET /api/stats/workload. Query parameters: days (1-365,
+// default 90), retention (comma-separated, default the collection's), deckId and newCardsPerDay.
+func (h *APIHandler) ForecastWorkload(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	days := defaultForecastDays
+	if raw := strings.TrimSpace(query.Get("days")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxForecastDays {
+			respondAPIError(w, http.StatusBadRequest, "invalid_days", fmt.Sprintf("days must be between 1 and %d", maxForecastDays))
+			return
+		}
+		days = parsed
+	}
+	var (
+		deckID int64
+		err    error
+	)
+	if raw := strings.TrimSpace(query.Get("deckId")); raw != "" {
+		deckID, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || deckID <= 0 {
+			respondAPIError(w, http.StatusBadRequest, "invalid_deck_id", "Invalid deck ID")
+			return
+		}
+	}
+	newPerDay := 0
+	if raw := strings.TrimSpace(query.Get("newCardsPerDay")); raw != "" {
+		newPerDay, err = strconv.Atoi(raw)
+		if err != nil || newPerDay < 0 || newPerDay > maxForecastNewPerDay {
+			respondAPIError(w, http.StatusBadRequest, "invalid_new_cards_per_day", "newCardsPerDay is out of range")
+			return
+		}
+	}
+
+	col, collectionID, err := h.collectionForRequest(r)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "collection_load_failed", err.Error())
+		return
+	}
+	retentions, err := parseForecastRetentions(query.Get("retention"), col.Params.RequestRetention)
+	if err != nil {
+		respondAPIError(w, http.StatusBadRequest, "invalid_retention", err.Error())
+		return
+	}
+	if deckID > 0 {
+		if _, ok := col.Decks[deckID]; !ok {
+			respondAPIError(w, http.StatusNotFound, "deck_not_found", "Deck not found")
+			return
+		}
+	}
+	cards, err := h.store.ListCardMemoryStates(r.Context(), h.userIDFromRequest(r), collectionID, deckID)
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "workload_forecast_failed", err.Error())
+		return
+	}
+	dayStart, _, err := h.store.studyDay(r.Context(), collectionID, time.Now())
+	if err != nil {
+		respondAPIError(w, http.StatusInternalServerError, "workload_forecast_failed", err.Error())
+		return
+	}
+
+	response := workloadForecastResponse{Days: days, Cards: len(cards), NewCardsPerDay: newPerDay}
+	for _, retention := range retentions {
+		response.Forecasts = append(response.Forecasts, simulateWorkload(cards, col.Params, retention, dayStart, days, newPerDay))
+	}
+	respondJSON(w, http.StatusOK, response)
+}
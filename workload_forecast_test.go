@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	fsrs "github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+func TestSimulateWorkloadHigherRetentionCostsMoreReviews(t *testing.T) {
+	dayStart := time.Date(2026, 3, 1, 4, 0, 0, 0, time.UTC)
+	cards := make([]fsrs.Card, 50)
+	for i := range cards {
+		cards[i] = fsrs.NewCard()
+	}
+	params := NewCollection().Params
+
+	high := simulateWorkload(cards, params, 0.95, dayStart, 60, 10)
+	low := simulateWorkload(cards, params, 0.80, dayStart, 60, 10)
+	if len(high.Days) != 60 || high.Days[0].Date != "2026-03-01" || high.Days[0].NewCards != 10 {
+		t.Fatalf("unexpected forecast days: %+v", high.Days[:2])
+	}
+	if high.TotalReviews <= low.TotalReviews {
+		t.Fatalf("expected 0.95 to cost more reviews than 0.80, got %d and %d", high.TotalReviews, low.TotalReviews)
+	}
+	if high.MemorizedAtEnd <= low.MemorizedAtEnd {
+		t.Fatalf("expected 0.95 to keep more cards memorised, got %.1f and %.1f", high.MemorizedAtEnd, low.MemorizedAtEnd)
+	}
+	if again := simulateWorkload(cards, params, 0.95, dayStart, 60, 10); again.TotalReviews != high.TotalReviews {
+		t.Fatalf("expected the simulation to be deterministic, got %d and %d", again.TotalReviews, high.TotalReviews)
+	}
+}
+
+func TestAPI_WorkloadForecast(t *testing.T) {
+	env := setupAPITestEnv(t)
+	for i := 0; i < 3; i++ {
+		createNoteForTest(t, env, CreateNoteRequest{
+			TypeID:    "Basic",
+			DeckID:    1,
+			FieldVals: map[string]string{"Front": fmt.Sprintf("Element %d", i), "Back": "Symbol"},
+		}, nil)
+	}
+	due := decodeJSON[[]*Card](t, doRawRequest(env.router, http.MethodGet, "/api/decks/1/due?limit=10", ""))
+	if len(due) == 0 {
+		t.Fatalf("expected due cards")
+	}
+	if rr := doJSONRequest(t, env.router, http.MethodPost, fmt.Sprintf("/api/cards/%d/answer", due[0].ID), AnswerCardRequest{Rating: 4}); rr.Code != http.StatusOK {
+		t.Fatalf("expected answer 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr := doRawRequest(env.router, http.MethodGet, "/api/stats/workload?days=30&retention=0.9,0.8&newCardsPerDay=1&deckId=1", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected forecast 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	resp := decodeJSON[workloadForecastResponse](t, rr)
+	if resp.Cards != 3 || len(resp.Forecasts) != 2 || resp.Forecasts[1].Retention != 0.8 {
+		t.Fatalf("unexpected forecast: %+v", resp)
+	}
+	for _, forecast := range resp.Forecasts {
+		if len(forecast.Days) != 30 || forecast.Days[0].NewCards != 1 || forecast.Days[1].NewCards != 1 || forecast.Days[2].NewCards != 0 {
+			t.Fatalf("expected the two new cards introduced over two days, got %+v", forecast.Days[:3])
+		}
+		if forecast.TotalReviews < 3 {
+			t.Fatalf("expected every card reviewed at least once, got %d", forecast.TotalReviews)
+		}
+	}
+
+	for _, query := range []string{"days=0", "days=400", "retention=0.5", "retention=0.9,0.9,0.9,0.9,0.9", "deckId=x", "newCardsPerDay=-1"} {
+		if rr := doRawRequest(env.router, http.MethodGet, "/api/stats/workload?"+query, ""); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected %s to be rejected, got %d", query, rr.Code)
+		}
+	}
+	if rr := doRawRequest(env.router, http.MethodGet, "/api/stats/workload?deckId=999", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown deck 404, got %d", rr.Code)
+	}
+}